@@ -0,0 +1,183 @@
+// Package access fulfils access requests from the service catalog once they
+// are approved, by adding requesters to Google Workspace groups, GitHub teams
+// and Okta groups, or removing them for revocations. Changes which have been
+// made can be rolled back.
+package access
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/skybet/go-helpdesk/catalog"
+	"github.com/skybet/go-helpdesk/ticket"
+)
+
+// The systems access can be granted in
+const (
+	Google = "google"
+	GitHub = "github"
+	Okta   = "okta"
+)
+
+// names are how systems are described in Slack
+var names = map[string]string{Google: "Google Workspace", GitHub: "GitHub", Okta: "Okta"}
+
+// ErrNothingToRollBack is returned when rolling back a change which has not
+// been made, or has been rolled back already
+var ErrNothingToRollBack = errors.New("there is nothing to roll back")
+
+// Adapter adds members to groups in one system and removes them. Adding a
+// member who is already in the group is not an error.
+type Adapter interface {
+	Add(ctx context.Context, group, member string) error
+	Remove(ctx context.Context, group, member string) error
+}
+
+// Adapters are the systems access can be granted in by name
+type Adapters map[string]Adapter
+
+// Missing returns the systems items of c grant access in which have no
+// adapter
+func (a Adapters) Missing(c *catalog.Catalog) []string {
+	missing := map[string]bool{}
+	for _, it := range c.Items {
+		for _, acc := range it.Access {
+			if a[acc.System] == nil {
+				missing[acc.System] = true
+			}
+		}
+	}
+	var systems []string
+	for s := range missing {
+		systems = append(systems, s)
+	}
+	sort.Strings(systems)
+	return systems
+}
+
+// Apply makes the change of g at now, returning it with when it was made or
+// why it failed
+func (a Adapters) Apply(ctx context.Context, g ticket.Grant, now time.Time) ticket.Grant {
+	if err := a.change(ctx, g, g.Revoke); err != nil {
+		g.Error = err.Error()
+		return g
+	}
+	g.DoneAt, g.Error = now, ""
+	return g
+}
+
+// Rollback undoes the change of g, recording that by did so at now
+func (a Adapters) Rollback(ctx context.Context, g *ticket.Grant, by string, now time.Time) error {
+	if !g.Done() || g.RolledBack() {
+		return ErrNothingToRollBack
+	}
+	if err := a.change(ctx, *g, !g.Revoke); err != nil {
+		return err
+	}
+	g.RolledBackBy, g.RolledBackAt = by, now
+	return nil
+}
+
+func (a Adapters) change(ctx context.Context, g ticket.Grant, remove bool) error {
+	ad := a[g.System]
+	if ad == nil {
+		return fmt.Errorf("%s has not been set up", Name(g.System))
+	}
+	if remove {
+		return ad.Remove(ctx, g.Group, g.Member)
+	}
+	return ad.Add(ctx, g.Group, g.Member)
+}
+
+// Name returns how a system is described in Slack
+func Name(system string) string {
+	if n, ok := names[system]; ok {
+		return n
+	}
+	return system
+}
+
+// Describe says what g does, has done or why it failed
+func Describe(g ticket.Grant) string {
+	verb, done, prep := "add", "Added", "to"
+	if g.Revoke {
+		verb, done, prep = "remove", "Removed", "from"
+	}
+	change := fmt.Sprintf("%s %s %s in %s", g.Member, prep, g.Group, Name(g.System))
+	switch {
+	case g.RolledBack():
+		return fmt.Sprintf("%s %s, rolled back by <@%s>", done, change, g.RolledBackBy)
+	case g.Done():
+		return done + " " + change
+	case g.Error != "":
+		return fmt.Sprintf("Could not %s %s: %s", verb, change, g.Error)
+	}
+	return fmt.Sprintf("Waiting to %s %s", verb, change)
+}
+
+// StatusError is the response of an API which refused a request
+type StatusError struct {
+	Code    int
+	Message string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%s %s", http.StatusText(e.Code), e.Message)
+}
+
+// statusIs reports whether err is a StatusError with code
+func statusIs(err error, code int) bool {
+	var se *StatusError
+	return errors.As(err, &se) && se.Code == code
+}
+
+// do sends body as JSON with the headers set by auth and decodes the
+// response into out, if it is not nil
+func do(ctx context.Context, client *http.Client, method, url string, auth func(*http.Request), body, out interface{}) error {
+	var r io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		r = bytes.NewReader(b)
+	}
+	req, err := http.NewRequest(method, url, r)
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	auth(req)
+	if client == nil {
+		client = http.DefaultClient
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode/100 != 2 {
+		msg, _ := ioutil.ReadAll(io.LimitReader(res.Body, 512))
+		return &StatusError{Code: res.StatusCode, Message: strings.TrimSpace(string(msg))}
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(res.Body).Decode(out); err != nil {
+		return fmt.Errorf("error decoding response: %s", err)
+	}
+	return nil
+}
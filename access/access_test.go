@@ -0,0 +1,82 @@
+package access
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/skybet/go-helpdesk/catalog"
+	"github.com/skybet/go-helpdesk/ticket"
+)
+
+// fakeAdapter records the members of its groups
+type fakeAdapter struct {
+	members map[string]bool
+	err     error
+}
+
+func (f *fakeAdapter) Add(ctx context.Context, group, member string) error {
+	if f.err != nil {
+		return f.err
+	}
+	f.members[group+"/"+member] = true
+	return nil
+}
+
+func (f *fakeAdapter) Remove(ctx context.Context, group, member string) error {
+	if f.err != nil {
+		return f.err
+	}
+	delete(f.members, group+"/"+member)
+	return nil
+}
+
+func TestApplyAndRollback(t *testing.T) {
+	fake := &fakeAdapter{members: map[string]bool{"ops/octocat": true}}
+	a := Adapters{GitHub: fake}
+	now := time.Date(2026, 10, 14, 9, 0, 0, 0, time.UTC)
+
+	g := a.Apply(context.Background(), ticket.Grant{System: GitHub, Group: "ops", Member: "octocat", Revoke: true}, now)
+	if !g.Done() || fake.members["ops/octocat"] {
+		t.Fatalf("Expected octocat to be removed, got %+v %v", g, fake.members)
+	}
+	if got := Describe(g); got != "Removed octocat from ops in GitHub" {
+		t.Errorf("Unexpected description %q", got)
+	}
+	if err := a.Rollback(context.Background(), &g, "UAGENT", now); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if !fake.members["ops/octocat"] || g.RolledBackBy != "UAGENT" {
+		t.Errorf("Expected the removal to be rolled back, got %+v %v", g, fake.members)
+	}
+	if err := a.Rollback(context.Background(), &g, "UAGENT", now); err != ErrNothingToRollBack {
+		t.Errorf("Expected a change to be rolled back only once, got %v", err)
+	}
+
+	fake.err = errors.New("boom")
+	g = a.Apply(context.Background(), ticket.Grant{System: GitHub, Group: "ops", Member: "hubot"}, now)
+	if g.Done() || Describe(g) != "Could not add hubot to ops in GitHub: boom" {
+		t.Errorf("Expected the failure to be recorded, got %+v", g)
+	}
+	g = a.Apply(context.Background(), ticket.Grant{System: Okta, Group: "00g1", Member: "ann"}, now)
+	if g.Error != "Okta has not been set up" {
+		t.Errorf("Expected a system without an adapter to fail, got %+v", g)
+	}
+}
+
+func TestMissing(t *testing.T) {
+	c, err := catalog.New(&catalog.Catalog{Channel: "C", Items: []*catalog.Item{{
+		ID: "repo", Name: "Repo", Queue: "it",
+		Fields:    []catalog.Field{{Name: "login", Label: "Login"}},
+		Approvals: []catalog.Step{{Name: "owner", Approvers: []string{"U1"}}},
+		Access:    []catalog.Access{{System: Okta, Group: "g", Member: "login"}, {System: GitHub, Group: "ops", Member: "login"}, {System: Google, Group: "g@example.com", Member: "login"}},
+	}}})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if got := (Adapters{GitHub: &fakeAdapter{}}).Missing(c); fmt.Sprint(got) != "[google okta]" {
+		t.Errorf("Expected google and okta to be missing, got %v", got)
+	}
+}
@@ -0,0 +1,51 @@
+package access
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// GitHubTeams adds members to the teams of an organisation, inviting them to the
+// organisation if they are not in it yet
+type GitHubTeams struct {
+	// URL is the API's, https://api.github.com if empty
+	URL string
+	Org string
+	// Token needs the admin:org scope
+	Token string
+	// HTTP sends the requests, http.DefaultClient if nil
+	HTTP *http.Client
+}
+
+// Add adds the user named member to the team with the slug group
+func (g *GitHubTeams) Add(ctx context.Context, group, member string) error {
+	if err := do(ctx, g.HTTP, http.MethodPut, g.membership(group, member), g.auth, map[string]string{"role": "member"}, nil); err != nil {
+		return fmt.Errorf("error adding %s to team %s: %s", member, group, err)
+	}
+	return nil
+}
+
+// Remove removes the user named member from the team with the slug group
+func (g *GitHubTeams) Remove(ctx context.Context, group, member string) error {
+	if err := do(ctx, g.HTTP, http.MethodDelete, g.membership(group, member), g.auth, nil, nil); err != nil {
+		return fmt.Errorf("error removing %s from team %s: %s", member, group, err)
+	}
+	return nil
+}
+
+func (g *GitHubTeams) membership(team, user string) string {
+	base := g.URL
+	if base == "" {
+		base = "https://api.github.com"
+	}
+	return fmt.Sprintf("%s/orgs/%s/teams/%s/memberships/%s", strings.TrimSuffix(base, "/"), url.PathEscape(g.Org), url.PathEscape(team), url.PathEscape(user))
+}
+
+func (g *GitHubTeams) auth(req *http.Request) {
+	req.Header.Set("Authorization", "Bearer "+g.Token)
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+}
@@ -0,0 +1,44 @@
+package access
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestGitHub(t *testing.T) {
+	var calls []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		calls = append(calls, r.Method+" "+r.URL.Path+" "+string(body))
+		if r.Header.Get("Authorization") != "Bearer t0ken" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if strings.HasSuffix(r.URL.Path, "/ghost") {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"message": "Not Found"}`))
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+	g := &GitHubTeams{URL: srv.URL, Org: "acme", Token: "t0ken"}
+
+	if err := g.Add(context.Background(), "platform", "octocat"); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if err := g.Remove(context.Background(), "platform", "octocat"); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	want := []string{`PUT /orgs/acme/teams/platform/memberships/octocat {"role":"member"}`, "DELETE /orgs/acme/teams/platform/memberships/octocat "}
+	if strings.Join(calls, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Expected %q, got %q", want, calls)
+	}
+	if err := g.Add(context.Background(), "platform", "ghost"); err == nil || !strings.Contains(err.Error(), "Not Found") {
+		t.Errorf("Expected the API's error, got %v", err)
+	}
+}
@@ -0,0 +1,170 @@
+package access
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// googleScope is the Directory API scope needed to manage group members
+const googleScope = "https://www.googleapis.com/auth/admin.directory.group.member"
+
+// GoogleGroups adds members to Google Workspace groups with the Directory
+// API, as a service account with domain-wide delegation acting for an admin
+type GoogleGroups struct {
+	// Email and Key are the service account's, TokenURL where it is given
+	// access tokens
+	Email    string
+	Key      *rsa.PrivateKey
+	TokenURL string
+	// Subject is the admin the service account acts for
+	Subject string
+	// URL is the API's, https://admin.googleapis.com if empty
+	URL string
+	// HTTP sends the requests, http.DefaultClient if nil
+	HTTP *http.Client
+
+	mu      sync.Mutex
+	token   string
+	expires time.Time
+}
+
+// NewGoogleGroups reads a service account's JSON key file to act for subject
+func NewGoogleGroups(keyFile, subject string) (*GoogleGroups, error) {
+	b, err := ioutil.ReadFile(keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("error reading Google service account key: %s", err)
+	}
+	var sa struct {
+		ClientEmail string `json:"client_email"`
+		PrivateKey  string `json:"private_key"`
+		TokenURI    string `json:"token_uri"`
+	}
+	if err := json.Unmarshal(b, &sa); err != nil {
+		return nil, fmt.Errorf("error parsing Google service account key %s: %s", keyFile, err)
+	}
+	block, _ := pem.Decode([]byte(sa.PrivateKey))
+	if block == nil {
+		return nil, fmt.Errorf("Google service account key %s has no private key", keyFile)
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing the private key of %s: %s", keyFile, err)
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("the private key of %s is not an RSA key", keyFile)
+	}
+	return &GoogleGroups{Email: sa.ClientEmail, Key: key, TokenURL: sa.TokenURI, Subject: subject}, nil
+}
+
+// Add adds the account with the email address member to the group with the
+// email address group
+func (g *GoogleGroups) Add(ctx context.Context, group, member string) error {
+	auth, err := g.auth(ctx)
+	if err != nil {
+		return err
+	}
+	err = do(ctx, g.HTTP, http.MethodPost, g.members(group), auth, map[string]string{"email": member, "role": "MEMBER"}, nil)
+	if err != nil && !statusIs(err, http.StatusConflict) {
+		return fmt.Errorf("error adding %s to group %s: %s", member, group, err)
+	}
+	return nil
+}
+
+// Remove removes the account with the email address member from the group
+// with the email address group
+func (g *GoogleGroups) Remove(ctx context.Context, group, member string) error {
+	auth, err := g.auth(ctx)
+	if err != nil {
+		return err
+	}
+	if err := do(ctx, g.HTTP, http.MethodDelete, g.members(group)+"/"+url.PathEscape(member), auth, nil, nil); err != nil {
+		return fmt.Errorf("error removing %s from group %s: %s", member, group, err)
+	}
+	return nil
+}
+
+func (g *GoogleGroups) members(group string) string {
+	base := g.URL
+	if base == "" {
+		base = "https://admin.googleapis.com"
+	}
+	return strings.TrimSuffix(base, "/") + "/admin/directory/v1/groups/" + url.PathEscape(group) + "/members"
+}
+
+// auth returns a function adding an access token to requests, exchanging a
+// signed assertion for a new token when the last one is about to expire
+func (g *GoogleGroups) auth(ctx context.Context) (func(*http.Request), error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	now := time.Now()
+	if g.token == "" || now.After(g.expires.Add(-time.Minute)) {
+		assertion, err := g.assertion(now)
+		if err != nil {
+			return nil, err
+		}
+		var token struct {
+			AccessToken string `json:"access_token"`
+			ExpiresIn   int    `json:"expires_in"`
+		}
+		form := url.Values{"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"}, "assertion": {assertion}}
+		req, err := http.NewRequest(http.MethodPost, g.TokenURL, strings.NewReader(form.Encode()))
+		if err != nil {
+			return nil, err
+		}
+		req = req.WithContext(ctx)
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		client := g.HTTP
+		if client == nil {
+			client = http.DefaultClient
+		}
+		res, err := client.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("error getting a Google access token: %s", err)
+		}
+		defer res.Body.Close()
+		if res.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("error getting a Google access token: %s", res.Status)
+		}
+		if err := json.NewDecoder(res.Body).Decode(&token); err != nil {
+			return nil, fmt.Errorf("error decoding Google access token: %s", err)
+		}
+		g.token, g.expires = token.AccessToken, now.Add(time.Duration(token.ExpiresIn)*time.Second)
+	}
+	token := g.token
+	return func(req *http.Request) { req.Header.Set("Authorization", "Bearer "+token) }, nil
+}
+
+// assertion signs the JWT a service account exchanges for an access token
+func (g *GoogleGroups) assertion(now time.Time) (string, error) {
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	claims, _ := json.Marshal(map[string]interface{}{
+		"iss":   g.Email,
+		"sub":   g.Subject,
+		"scope": googleScope,
+		"aud":   g.TokenURL,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	})
+	unsigned := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	sum := sha256.Sum256([]byte(unsigned))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, g.Key, crypto.SHA256, sum[:])
+	if err != nil {
+		return "", fmt.Errorf("error signing Google token request: %s", err)
+	}
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(sig), nil
+}
@@ -0,0 +1,99 @@
+package access
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGoogle(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	tokens := 0
+	var calls []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/token" {
+			r.ParseForm()
+			parts := strings.Split(r.Form.Get("assertion"), ".")
+			sig, _ := base64.RawURLEncoding.DecodeString(parts[len(parts)-1])
+			sum := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+			if len(parts) != 3 || rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA256, sum[:], sig) != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			claims, _ := base64.RawURLEncoding.DecodeString(parts[1])
+			if !strings.Contains(string(claims), `"sub":"admin@example.com"`) {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			tokens++
+			w.Write([]byte(`{"access_token": "ya29", "expires_in": 3600}`))
+			return
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		calls = append(calls, r.Method+" "+r.URL.Path+" "+string(body))
+		switch {
+		case r.Header.Get("Authorization") != "Bearer ya29":
+			w.WriteHeader(http.StatusUnauthorized)
+		case strings.Contains(string(body), "bob@"):
+			w.WriteHeader(http.StatusConflict)
+		}
+	}))
+	defer srv.Close()
+
+	der, _ := x509.MarshalPKCS8PrivateKey(key)
+	sa, _ := json.Marshal(map[string]string{
+		"client_email": "helpdesk@acme.iam.gserviceaccount.com",
+		"private_key":  string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})),
+		"token_uri":    srv.URL + "/token",
+	})
+	dir, err := ioutil.TempDir("", "google")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "key.json")
+	ioutil.WriteFile(path, sa, 0600)
+	g, err := NewGoogleGroups(path, "admin@example.com")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	g.URL = srv.URL
+
+	if err := g.Add(context.Background(), "eng@example.com", "ann@example.com"); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	// Already being a member is not an error
+	if err := g.Add(context.Background(), "eng@example.com", "bob@example.com"); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if err := g.Remove(context.Background(), "eng@example.com", "ann@example.com"); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	want := []string{
+		`POST /admin/directory/v1/groups/eng@example.com/members {"email":"ann@example.com","role":"MEMBER"}`,
+		`POST /admin/directory/v1/groups/eng@example.com/members {"email":"bob@example.com","role":"MEMBER"}`,
+		`DELETE /admin/directory/v1/groups/eng@example.com/members/ann@example.com `,
+	}
+	if strings.Join(calls, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Expected %q, got %q", want, calls)
+	}
+	if tokens != 1 {
+		t.Errorf("Expected the access token to be reused, got %d tokens", tokens)
+	}
+}
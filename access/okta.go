@@ -0,0 +1,68 @@
+package access
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// OktaGroups assigns users to groups in an Okta organisation
+type OktaGroups struct {
+	// URL is the organisation's, such as https://example.okta.com
+	URL string
+	// Token is an API token of an admin who can manage the groups
+	Token string
+	// HTTP sends the requests, http.DefaultClient if nil
+	HTTP *http.Client
+}
+
+// Add assigns the user whose login is member to the group with the ID group
+func (o *OktaGroups) Add(ctx context.Context, group, member string) error {
+	id, err := o.user(ctx, member)
+	if err != nil {
+		return err
+	}
+	if err := do(ctx, o.HTTP, http.MethodPut, o.url("/api/v1/groups/%s/users/%s", group, id), o.auth, nil, nil); err != nil {
+		return fmt.Errorf("error adding %s to group %s: %s", member, group, err)
+	}
+	return nil
+}
+
+// Remove unassigns the user whose login is member from the group with the ID
+// group
+func (o *OktaGroups) Remove(ctx context.Context, group, member string) error {
+	id, err := o.user(ctx, member)
+	if err != nil {
+		return err
+	}
+	if err := do(ctx, o.HTTP, http.MethodDelete, o.url("/api/v1/groups/%s/users/%s", group, id), o.auth, nil, nil); err != nil {
+		return fmt.Errorf("error removing %s from group %s: %s", member, group, err)
+	}
+	return nil
+}
+
+// user looks up the ID of the user with a login
+func (o *OktaGroups) user(ctx context.Context, login string) (string, error) {
+	var u struct {
+		ID string `json:"id"`
+	}
+	if err := do(ctx, o.HTTP, http.MethodGet, o.url("/api/v1/users/%s", login), o.auth, nil, &u); err != nil {
+		return "", fmt.Errorf("error looking up %s: %s", login, err)
+	}
+	return u.ID, nil
+}
+
+// url returns the URL of path with each arg escaped
+func (o *OktaGroups) url(path string, args ...string) string {
+	escaped := make([]interface{}, len(args))
+	for i, a := range args {
+		escaped[i] = url.PathEscape(a)
+	}
+	return strings.TrimSuffix(o.URL, "/") + fmt.Sprintf(path, escaped...)
+}
+
+func (o *OktaGroups) auth(req *http.Request) {
+	req.Header.Set("Authorization", "SSWS "+o.Token)
+}
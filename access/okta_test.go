@@ -0,0 +1,44 @@
+package access
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestOkta(t *testing.T) {
+	var calls []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls = append(calls, r.Method+" "+r.URL.Path)
+		if r.Header.Get("Authorization") != "SSWS t0ken" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch {
+		case r.URL.Path == "/api/v1/users/ann@example.com":
+			w.Write([]byte(`{"id": "00u1"}`))
+		case strings.HasPrefix(r.URL.Path, "/api/v1/users/"):
+			w.WriteHeader(http.StatusNotFound)
+		default:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer srv.Close()
+	o := &OktaGroups{URL: srv.URL + "/", Token: "t0ken"}
+
+	if err := o.Add(context.Background(), "00g1", "ann@example.com"); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if err := o.Remove(context.Background(), "00g1", "ann@example.com"); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	want := []string{"GET /api/v1/users/ann@example.com", "PUT /api/v1/groups/00g1/users/00u1", "GET /api/v1/users/ann@example.com", "DELETE /api/v1/groups/00g1/users/00u1"}
+	if strings.Join(calls, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Expected %q, got %q", want, calls)
+	}
+	if err := o.Add(context.Background(), "00g1", "bob@example.com"); err == nil || !strings.Contains(err.Error(), "error looking up bob@example.com") {
+		t.Errorf("Expected an unknown user to fail, got %v", err)
+	}
+}
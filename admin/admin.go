@@ -0,0 +1,245 @@
+// Package admin holds the commands which change or expose many tickets at once
+// such as closing a whole queue, these need a second admin's approval before
+// they are run
+package admin
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/skybet/go-helpdesk/store"
+	"github.com/skybet/go-helpdesk/ticket"
+)
+
+// Erased replaces the user IDs removed from tickets by Erase
+const Erased = "erased"
+
+// BulkClose closes every open ticket matching f at now, such as a whole
+// queue's, returning how many were closed
+func BulkClose(ctx context.Context, s store.Store, f store.Filter, now time.Time) (int, error) {
+	n := 0
+	err := s.Tx(ctx, func(tx store.Store) error {
+		ts, err := all(ctx, tx, f)
+		if err != nil {
+			return err
+		}
+		for _, t := range ts {
+			if !t.Status.Open() {
+				continue
+			}
+			t.SetStatus(ticket.StatusClosed, now)
+			if err := tx.UpdateTicket(ctx, t); err != nil {
+				return fmt.Errorf("error closing ticket %s: %s", t.ID, err)
+			}
+			n++
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// Watermark identifies who an export was made for and when, so that a copy
+// which turns up elsewhere can be traced back to the request
+type Watermark struct {
+	User string
+	At   time.Time
+}
+
+// Export writes every ticket outside the trash matching f to w as CSV with a
+// header row, returning how many were written. Every row carries the
+// watermark, so it is kept by copies of part of the export too. Tickets are
+// read from the store a page at a time and each page is written before the
+// next is read, so exports of any size only hold one page in memory.
+func Export(ctx context.Context, s store.Store, w io.Writer, f store.Filter, mark Watermark) (int, error) {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"id", "queue", "title", "status", "priority", "reporter", "assignee", "created", "updated", "resolved", "comments", "exported_for", "exported_at"})
+	n := 0
+	f.Deleted, f.Cursor = false, ""
+	err := each(ctx, s, f, func(page []*ticket.Ticket) error {
+		for _, t := range page {
+			cw.Write([]string{
+				t.ID, t.Queue, t.Title, string(t.Status), strconv.Itoa(int(t.Priority)), t.Reporter, t.Assignee,
+				formatTime(t.CreatedAt), formatTime(t.UpdatedAt), formatTime(t.ResolvedAt), strconv.Itoa(len(t.Comments)),
+				mark.User, formatTime(mark.At),
+			})
+		}
+		n += len(page)
+		cw.Flush()
+		if err := cw.Error(); err != nil {
+			return fmt.Errorf("error writing export: %s", err)
+		}
+		return nil
+	})
+	return n, err
+}
+
+// Erase removes a user from every ticket, including those in the trash. The
+// description of the tickets they reported is removed too as it is written by
+// them, the title is kept so the ticket can still be reported on. It returns
+// how many tickets were changed.
+func Erase(ctx context.Context, s store.Store, user string, now time.Time) (int, error) {
+	n := 0
+	err := s.Tx(ctx, func(tx store.Store) error {
+		var ts []*ticket.Ticket
+		for _, deleted := range []bool{false, true} {
+			page, err := all(ctx, tx, store.Filter{Deleted: deleted})
+			if err != nil {
+				return err
+			}
+			ts = append(ts, page...)
+		}
+		for _, t := range ts {
+			if !erase(t, user) {
+				continue
+			}
+			t.UpdatedAt = now
+			if err := tx.UpdateTicket(ctx, t); err != nil {
+				return fmt.Errorf("error erasing ticket %s: %s", t.ID, err)
+			}
+			n++
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// erase replaces user in t, reporting whether they were found
+func erase(t *ticket.Ticket, user string) bool {
+	found := false
+	replace := func(id *string) {
+		if *id == user {
+			*id = Erased
+			found = true
+		}
+	}
+	if t.Reporter == user {
+		t.Description = ""
+		t.Email = ""
+	}
+	replace(&t.Reporter)
+	replace(&t.Assignee)
+	replace(&t.AcknowledgedBy)
+	replace(&t.DeletedBy)
+	for i := range t.Shares {
+		replace(&t.Shares[i].DoneBy)
+	}
+	for i := range t.Comments {
+		if t.Comments[i].Author == user {
+			t.Comments[i].Text = ""
+		}
+		replace(&t.Comments[i].Author)
+	}
+	for i := range t.Notices {
+		replace(&t.Notices[i].To)
+	}
+	for i := range t.Reactions {
+		replace(&t.Reactions[i].By)
+		replace(&t.Reactions[i].For)
+	}
+	return found
+}
+
+func all(ctx context.Context, s store.Store, f store.Filter) ([]*ticket.Ticket, error) {
+	var ts []*ticket.Ticket
+	err := each(ctx, s, f, func(page []*ticket.Ticket) error {
+		ts = append(ts, page...)
+		return nil
+	})
+	return ts, err
+}
+
+// each calls fn with every page of the tickets matching f until there are no
+// more or fn returns an error
+func each(ctx context.Context, s store.Store, f store.Filter, fn func(page []*ticket.Ticket) error) error {
+	f.Limit = 500
+	for {
+		page, next, err := s.ListTickets(ctx, f)
+		if err != nil {
+			return fmt.Errorf("error listing tickets: %s", err)
+		}
+		if err := fn(page); err != nil {
+			return err
+		}
+		if next == "" {
+			return nil
+		}
+		f.Cursor = next
+	}
+}
+
+// ExportDetails are the details of an export recorded in the audit log: its
+// filter, how many rows it has and where it was sent
+func ExportDetails(f store.Filter, rows int, destination string) map[string]string {
+	return map[string]string{"filter": describe(f), "rows": strconv.Itoa(rows), "destination": destination}
+}
+
+// describe returns a filter as it is recorded in the audit log, such as
+// "queue=support status=new,triaged"
+func describe(f store.Filter) string {
+	var parts []string
+	if f.Queue != "" {
+		parts = append(parts, "queue="+f.Queue)
+	}
+	if len(f.Status) > 0 {
+		statuses := make([]string, len(f.Status))
+		for i, s := range f.Status {
+			statuses[i] = string(s)
+		}
+		parts = append(parts, "status="+strings.Join(statuses, ","))
+	}
+	if f.Assignee != "" {
+		parts = append(parts, "assignee="+f.Assignee)
+	}
+	if f.Reporter != "" {
+		parts = append(parts, "reporter="+f.Reporter)
+	}
+	if len(f.Tags) > 0 {
+		parts = append(parts, "tags="+strings.Join(f.Tags, ","))
+	}
+	if len(f.Priority) > 0 {
+		priorities := make([]string, len(f.Priority))
+		for i, p := range f.Priority {
+			priorities[i] = p.String()
+		}
+		parts = append(parts, "priority="+strings.Join(priorities, ","))
+	}
+	if f.Unassigned {
+		parts = append(parts, "unassigned")
+	}
+	for _, r := range []struct {
+		name string
+		at   time.Time
+	}{{"created_after", f.CreatedAfter}, {"created_before", f.CreatedBefore}, {"updated_after", f.UpdatedAfter}, {"updated_before", f.UpdatedBefore}} {
+		if !r.at.IsZero() {
+			parts = append(parts, r.name+"="+formatTime(r.at))
+		}
+	}
+	if f.Text != "" {
+		parts = append(parts, fmt.Sprintf("text=%q", f.Text))
+	}
+	for _, ex := range f.Exclude {
+		parts = append(parts, "not("+describe(ex)+")")
+	}
+	if len(parts) == 0 {
+		return "all"
+	}
+	return strings.Join(parts, " ")
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
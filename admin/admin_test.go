@@ -0,0 +1,83 @@
+package admin
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/skybet/go-helpdesk/store"
+	"github.com/skybet/go-helpdesk/ticket"
+)
+
+var now = time.Date(2026, 10, 14, 9, 0, 0, 0, time.UTC)
+
+func TestBulkClose(t *testing.T) {
+	s := store.NewMemory()
+	s.CreateTicket(context.Background(), &ticket.Ticket{ID: "1", Queue: "support", Status: ticket.StatusNew})
+	s.CreateTicket(context.Background(), &ticket.Ticket{ID: "2", Queue: "support", Status: ticket.StatusResolved})
+	s.CreateTicket(context.Background(), &ticket.Ticket{ID: "3", Queue: "infra", Status: ticket.StatusNew})
+	n, err := BulkClose(context.Background(), s, store.Filter{Queue: "support"}, now)
+	if err != nil || n != 1 {
+		t.Fatalf("Expected one ticket to be closed, got %d, %v", n, err)
+	}
+	for id, want := range map[string]ticket.Status{"1": ticket.StatusClosed, "2": ticket.StatusResolved, "3": ticket.StatusNew} {
+		if tk, _ := s.GetTicket(context.Background(), id); tk.Status != want {
+			t.Errorf("Expected ticket %s to be %s, got %s", id, want, tk.Status)
+		}
+	}
+}
+
+func TestExport(t *testing.T) {
+	s := store.NewMemory()
+	s.CreateTicket(context.Background(), &ticket.Ticket{ID: "1", Queue: "support", Title: "VPN, again", Status: ticket.StatusNew, Reporter: "U1", CreatedAt: now})
+	s.CreateTicket(context.Background(), &ticket.Ticket{ID: "2", Queue: "hr", Title: "Payroll", Status: ticket.StatusNew, Reporter: "U1", CreatedAt: now})
+	var buf bytes.Buffer
+	n, err := Export(context.Background(), s, &buf, store.Filter{Queue: "support"}, Watermark{User: "U2", At: now})
+	if err != nil || n != 1 {
+		t.Fatalf("Expected one ticket to be exported, got %d, %v", n, err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 || !strings.HasPrefix(lines[0], "id,queue,title") || !strings.HasPrefix(lines[1], `1,support,"VPN, again",new,`) {
+		t.Errorf("Expected a header and the ticket, got %q", buf.String())
+	}
+	if !strings.HasSuffix(lines[0], ",exported_for,exported_at") || !strings.HasSuffix(lines[1], ",U2,2026-10-14T09:00:00Z") {
+		t.Errorf("Expected every row to be watermarked, got %q", buf.String())
+	}
+}
+
+func TestExportDetails(t *testing.T) {
+	for want, f := range map[string]store.Filter{
+		"all":                              {},
+		"queue=support":                    {Queue: "support"},
+		"queue=support status=new,triaged": {Queue: "support", Status: []ticket.Status{ticket.StatusNew, ticket.StatusTriaged}},
+		`assignee=U1 tags=vpn,urgent text="wifi"`:                                    {Assignee: "U1", Tags: []string{"vpn", "urgent"}, Text: "wifi"},
+		"priority=P1,P2 unassigned created_after=2026-10-14T09:00:00Z not(queue=hr)": {Priority: []ticket.Priority{ticket.P1, ticket.P2}, Unassigned: true, CreatedAfter: now, Exclude: []store.Filter{{Queue: "hr"}}},
+	} {
+		if d := ExportDetails(f, 3, "Slack DM to U1"); d["filter"] != want || d["rows"] != "3" || d["destination"] != "Slack DM to U1" {
+			t.Errorf("Expected the filter to be %q, got %+v", want, d)
+		}
+	}
+}
+
+func TestErase(t *testing.T) {
+	s := store.NewMemory()
+	s.CreateTicket(context.Background(), &ticket.Ticket{ID: "1", Title: "VPN", Description: "I am U1", Reporter: "U1", Assignee: "U2"})
+	s.CreateTicket(context.Background(), &ticket.Ticket{ID: "2", Description: "Printer", Reporter: "U3", Assignee: "U1", Reactions: []ticket.Reaction{{Emoji: "pray", By: "U3", For: "U1"}}})
+	s.CreateTicket(context.Background(), &ticket.Ticket{ID: "3", Reporter: "U3", DeletedAt: now, DeletedBy: "U1"})
+	s.CreateTicket(context.Background(), &ticket.Ticket{ID: "4", Reporter: "U3"})
+	n, err := Erase(context.Background(), s, "U1", now)
+	if err != nil || n != 3 {
+		t.Fatalf("Expected three tickets to be changed, got %d, %v", n, err)
+	}
+	if tk, _ := s.GetTicket(context.Background(), "1"); tk.Reporter != Erased || tk.Description != "" || tk.Title != "VPN" || tk.Assignee != "U2" {
+		t.Errorf("Expected the reporter and description to be erased, got %+v", tk)
+	}
+	if tk, _ := s.GetTicket(context.Background(), "2"); tk.Assignee != Erased || tk.Description != "Printer" || tk.Reactions[0].For != Erased {
+		t.Errorf("Expected only the assignee to be erased, got %+v", tk)
+	}
+	if tk, _ := s.GetTicket(context.Background(), "3"); tk.DeletedBy != Erased {
+		t.Errorf("Expected tickets in the trash to be erased too, got %+v", tk)
+	}
+}
@@ -0,0 +1,123 @@
+package admin
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/skybet/go-helpdesk/audit"
+	"github.com/skybet/go-helpdesk/query"
+	"github.com/skybet/go-helpdesk/server"
+	"github.com/skybet/go-helpdesk/sso"
+	"github.com/skybet/go-helpdesk/store"
+	"github.com/skybet/go-helpdesk/ticket"
+)
+
+// APIUser is who exports made through the API with its token are watermarked
+// for and recorded as in the audit log, as the token does not identify anyone.
+// Exports by someone signed in with sso are recorded as them.
+const APIUser = "admin API"
+
+// API streams exports of the tickets over HTTP for admins. Every request must
+// carry the token as a bearer token.
+type API struct {
+	store   store.Store
+	handler http.Handler
+	audit   *audit.Log
+	now     func() time.Time
+}
+
+// NewAPI returns an API exporting the tickets in s, recording each export in
+// l if it is not nil. Mount it with http.StripPrefix so that its routes, such as /export, are
+// at the root.
+func NewAPI(s store.Store, token string, l *audit.Log) *API {
+	a := &API{store: s, audit: l, now: time.Now}
+	a.handler = server.RequireToken(token, http.HandlerFunc(a.route))
+	return a
+}
+
+// ServeHTTP satisfies http.Handler. GET /export returns every ticket outside
+// the trash as CSV, the same as /hd export. The tickets can be filtered with
+// a search query in the query parameter and the queue, status, assignee and
+// reporter parameters, status taking a comma separated list.
+func (a *API) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	a.handler.ServeHTTP(w, r)
+}
+
+// route serves the requests which carried the token
+func (a *API) route(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.URL.Path == "/export" && r.Method == http.MethodGet:
+		a.export(w, r)
+	case r.URL.Path == "/export":
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// export streams the CSV as it is read from the store. There is no
+// Content-Length so it is sent chunked, and a failure part way through aborts
+// the response so that clients do not mistake a truncated export for a
+// complete one.
+func (a *API) export(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	now := a.now()
+	f, err := query.Parse(q.Get("query"), "", now)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if v := q.Get("queue"); v != "" {
+		f.Queue = v
+	}
+	if v := q.Get("assignee"); v != "" {
+		f.Assignee = v
+	}
+	if v := q.Get("reporter"); v != "" {
+		f.Reporter = v
+	}
+	if s := q.Get("status"); s != "" {
+		f.Status = nil
+		for _, status := range strings.Split(s, ",") {
+			f.Status = append(f.Status, ticket.Status(status))
+		}
+	}
+	user := APIUser
+	if u := sso.FromContext(r.Context()); u != nil {
+		user = u.String()
+	}
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="tickets-%s.csv"`, now.UTC().Format("2006-01-02")))
+	n, err := Export(r.Context(), a.store, flushWriter{w}, f, Watermark{User: user, At: now})
+	outcome := fmt.Sprintf("exported %d tickets", n)
+	if err != nil {
+		outcome = fmt.Sprintf("failed after %d tickets: %s", n, err)
+	}
+	e := audit.Entry{At: now, Action: "GET /api/admin/export", Actor: user, Outcome: outcome, Details: ExportDetails(f, n, "admin API to "+r.RemoteAddr)}
+	if a.audit != nil {
+		if err := a.audit.Record(e); err != nil {
+			log.Errorf("Failed to record an export in the audit log: %s", err)
+		}
+	}
+	if err != nil {
+		log.Errorf("Export failed after %d tickets: %s", n, err)
+		panic(http.ErrAbortHandler)
+	}
+}
+
+// flushWriter sends each write to the client straight away
+type flushWriter struct {
+	w http.ResponseWriter
+}
+
+func (f flushWriter) Write(p []byte) (int, error) {
+	n, err := f.w.Write(p)
+	if fl, ok := f.w.(http.Flusher); ok {
+		fl.Flush()
+	}
+	return n, err
+}
@@ -0,0 +1,97 @@
+package admin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/skybet/go-helpdesk/audit"
+	"github.com/skybet/go-helpdesk/sso"
+	"github.com/skybet/go-helpdesk/store"
+	"github.com/skybet/go-helpdesk/ticket"
+)
+
+// failingStore fails to list the tickets after the first page
+type failingStore struct {
+	store.Store
+}
+
+func (f failingStore) ListTickets(ctx context.Context, filter store.Filter) ([]*ticket.Ticket, string, error) {
+	if filter.Cursor != "" {
+		return nil, "", errors.New("connection reset")
+	}
+	return f.Store.ListTickets(ctx, filter)
+}
+
+func TestAPI(t *testing.T) {
+	s := store.NewMemory()
+	for i := 0; i < 500; i++ {
+		s.CreateTicket(context.Background(), &ticket.Ticket{ID: fmt.Sprint(i), Title: "VPN"})
+	}
+	s.CreateTicket(context.Background(), &ticket.Ticket{ID: "hr", Title: "Payroll", Queue: "hr"})
+	l := &audit.Log{}
+	a := NewAPI(s, "secret", l)
+	a.now = func() time.Time { return now }
+	serve := func(a *API, method, path, token string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, path, nil)
+		if token != "" {
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		a.ServeHTTP(w, r)
+		return w
+	}
+
+	if w := serve(a, "GET", "/export", "wrong"); w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected requests without the token to be refused, got %d", w.Code)
+	}
+	if w := serve(a, "POST", "/export", "secret"); w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected exporting to need a GET, got %d", w.Code)
+	}
+	w := serve(a, "GET", "/export", "secret")
+	if lines := strings.Count(w.Body.String(), "\n"); w.Code != http.StatusOK || lines != 502 || !w.Flushed {
+		t.Errorf("Expected a header and every ticket to be streamed, got %d with %d lines", w.Code, lines)
+	}
+	if d := w.Header().Get("Content-Disposition"); d != `attachment; filename="tickets-2026-10-14.csv"` {
+		t.Errorf("Unexpected Content-Disposition %q", d)
+	}
+	if !strings.Contains(w.Body.String(), ",admin API,2026-10-14T09:00:00Z\n") {
+		t.Errorf("Expected the export to be watermarked, got %q", w.Body.String()[:200])
+	}
+	w = serve(a, "GET", "/export?queue=hr", "secret")
+	if lines := strings.Count(w.Body.String(), "\n"); lines != 2 {
+		t.Errorf("Expected only the queue's ticket to be exported, got %d lines", lines)
+	}
+	r := httptest.NewRequest("GET", "/export?queue=hr", nil)
+	r.Header.Set("Authorization", "Bearer secret")
+	r = r.WithContext(sso.NewContext(r.Context(), &sso.User{Subject: "00u1", Email: "ann@example.com"}))
+	w = httptest.NewRecorder()
+	a.ServeHTTP(w, r)
+	if !strings.Contains(w.Body.String(), ",ann@example.com,2026-10-14T09:00:00Z\n") {
+		t.Errorf("Expected an export by someone signed in to be watermarked for them, got %q", w.Body.String())
+	}
+	entries := l.Entries()
+	if len(entries) != 3 || entries[2].Actor != "ann@example.com" || entries[0].Actor != APIUser || entries[0].Details["rows"] != "501" || entries[1].Details["filter"] != "queue=hr" || entries[1].Details["rows"] != "1" || !strings.HasPrefix(entries[1].Details["destination"], "admin API to ") {
+		t.Errorf("Expected each export to be audited, got %+v", entries)
+	}
+	w = serve(a, "GET", "/export?query="+url.QueryEscape(`-queue:hr payroll`), "secret")
+	if lines := strings.Count(w.Body.String(), "\n"); w.Code != http.StatusOK || lines != 1 {
+		t.Errorf("Expected the query to leave out every ticket, got %d with %d lines", w.Code, lines)
+	}
+	if w := serve(a, "GET", "/export?query=colour:red", "secret"); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected an invalid query to be refused, got %d", w.Code)
+	}
+
+	defer func() {
+		if r := recover(); r != http.ErrAbortHandler {
+			t.Errorf("Expected a failed export to abort the response, got %v", r)
+		}
+	}()
+	serve(NewAPI(failingStore{s}, "secret", nil), "GET", "/export", "secret")
+}
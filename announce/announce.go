@@ -0,0 +1,197 @@
+// Package announce broadcasts announcements to many Slack channels at a pace
+// which stays within Slack's rate limits, and keeps track of every copy so that
+// they can be edited together
+package announce
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/nlopes/slack"
+)
+
+// Poster is the part of the Slack API used to deliver announcements
+type Poster interface {
+	PostMessage(channelID string, options ...slack.MsgOption) (string, string, error)
+	UpdateMessage(channelID, timestamp string, options ...slack.MsgOption) (string, string, string, error)
+}
+
+// Delivery is the result of posting an announcement to one channel
+type Delivery struct {
+	Channel   string
+	Timestamp string
+	Err       error
+}
+
+// Announcement is a message broadcast to a set of channels
+type Announcement struct {
+	ID         string
+	Author     string
+	Text       string
+	Created    time.Time
+	Edited     time.Time
+	Deliveries []Delivery
+}
+
+// Failed returns the deliveries which did not reach their channel
+func (a *Announcement) Failed() []Delivery {
+	var failed []Delivery
+	for _, d := range a.Deliveries {
+		if d.Err != nil {
+			failed = append(failed, d)
+		}
+	}
+	return failed
+}
+
+// Summary describes the outcome of the latest delivery, e.g. for a DM to the
+// author
+func (a *Announcement) Summary() string {
+	failed := a.Failed()
+	s := fmt.Sprintf("Announcement %s reached %d of %d channels", a.ID, len(a.Deliveries)-len(failed), len(a.Deliveries))
+	for _, d := range failed {
+		s += fmt.Sprintf("\n• <#%s>: %s", d.Channel, d.Err)
+	}
+	return s
+}
+
+func (a *Announcement) copy() *Announcement {
+	c := *a
+	c.Deliveries = append([]Delivery(nil), a.Deliveries...)
+	return &c
+}
+
+// Broadcaster posts announcements to a configured set of channels
+type Broadcaster struct {
+	// Channels receive every announcement
+	Channels []string
+	// Interval is the pause between channels, chat.postMessage allows
+	// roughly one message per second
+	Interval time.Duration
+	// MaxRetries is how many times a rate limited post is retried
+	MaxRetries int
+
+	poster        Poster
+	mu            sync.Mutex
+	seq           int
+	announcements map[string]*Announcement
+	sleep         func(ctx context.Context, d time.Duration) error
+}
+
+// NewBroadcaster returns a Broadcaster posting to channels via p
+func NewBroadcaster(p Poster, channels []string) *Broadcaster {
+	return &Broadcaster{
+		Channels:      channels,
+		Interval:      time.Second,
+		MaxRetries:    3,
+		poster:        p,
+		announcements: make(map[string]*Announcement),
+		sleep:         sleep,
+	}
+}
+
+// Send posts text to every channel, one at a time. It blocks until every
+// channel has been tried, failures are recorded against each delivery rather
+// than returned. An error is only returned if ctx is cancelled.
+func (b *Broadcaster) Send(ctx context.Context, author, text string) (*Announcement, error) {
+	b.mu.Lock()
+	b.seq++
+	a := &Announcement{ID: strconv.Itoa(b.seq), Author: author, Text: text, Created: time.Now()}
+	b.announcements[a.ID] = a
+	b.mu.Unlock()
+
+	var deliveries []Delivery
+	for i, ch := range b.Channels {
+		if i > 0 {
+			if err := b.sleep(ctx, b.Interval); err != nil {
+				return b.record(a.ID, text, deliveries), err
+			}
+		}
+		d := Delivery{Channel: ch}
+		d.Err = b.retry(ctx, func() error {
+			var err error
+			_, d.Timestamp, err = b.poster.PostMessage(ch, slack.MsgOptionText(text, false))
+			return err
+		})
+		deliveries = append(deliveries, d)
+	}
+	return b.record(a.ID, text, deliveries), nil
+}
+
+// Edit replaces the text of every delivered copy of an announcement
+func (b *Broadcaster) Edit(ctx context.Context, id, text string) (*Announcement, error) {
+	a, ok := b.Get(id)
+	if !ok {
+		return nil, fmt.Errorf("no announcement with ID %s", id)
+	}
+	deliveries := a.Deliveries
+	sent := 0
+	for i, d := range deliveries {
+		if d.Timestamp == "" {
+			continue
+		}
+		if sent > 0 {
+			if err := b.sleep(ctx, b.Interval); err != nil {
+				return b.record(id, text, deliveries), err
+			}
+		}
+		sent++
+		deliveries[i].Err = b.retry(ctx, func() error {
+			_, _, _, err := b.poster.UpdateMessage(d.Channel, d.Timestamp, slack.MsgOptionText(text, false))
+			return err
+		})
+	}
+	return b.record(id, text, deliveries), nil
+}
+
+// Get returns a copy of an announcement
+func (b *Broadcaster) Get(id string) (*Announcement, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	a, ok := b.announcements[id]
+	if !ok {
+		return nil, false
+	}
+	return a.copy(), true
+}
+
+func (b *Broadcaster) record(id, text string, deliveries []Delivery) *Announcement {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	a := b.announcements[id]
+	if a.Text != text {
+		a.Edited = time.Now()
+	}
+	a.Text = text
+	a.Deliveries = deliveries
+	return a.copy()
+}
+
+// retry calls f until it succeeds, fails with an error other than being rate
+// limited or MaxRetries is reached
+func (b *Broadcaster) retry(ctx context.Context, f func() error) error {
+	for attempt := 0; ; attempt++ {
+		err := f()
+		rl, ok := err.(*slack.RateLimitedError)
+		if !ok || attempt >= b.MaxRetries {
+			return err
+		}
+		if err := b.sleep(ctx, rl.RetryAfter); err != nil {
+			return err
+		}
+	}
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
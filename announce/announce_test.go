@@ -0,0 +1,111 @@
+package announce
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/nlopes/slack"
+)
+
+type fakePoster struct {
+	posted      map[string]string
+	rateLimited map[string]int
+	failing     map[string]error
+}
+
+func text(options []slack.MsgOption) string {
+	_, values, _ := slack.UnsafeApplyMsgOptions("", "", "", options...)
+	return values.Get("text")
+}
+
+func (f *fakePoster) PostMessage(channelID string, options ...slack.MsgOption) (string, string, error) {
+	if f.rateLimited[channelID] > 0 {
+		f.rateLimited[channelID]--
+		return "", "", &slack.RateLimitedError{RetryAfter: time.Second}
+	}
+	if err := f.failing[channelID]; err != nil {
+		return "", "", err
+	}
+	f.posted[channelID] = text(options)
+	return channelID, "ts-" + channelID, nil
+}
+
+func (f *fakePoster) UpdateMessage(channelID, timestamp string, options ...slack.MsgOption) (string, string, string, error) {
+	if timestamp != "ts-"+channelID {
+		return "", "", "", errors.New("message_not_found")
+	}
+	f.posted[channelID] = text(options)
+	return channelID, timestamp, "", nil
+}
+
+func newTestBroadcaster(p Poster, channels ...string) (*Broadcaster, *[]time.Duration) {
+	var slept []time.Duration
+	b := NewBroadcaster(p, channels)
+	b.sleep = func(ctx context.Context, d time.Duration) error {
+		slept = append(slept, d)
+		return ctx.Err()
+	}
+	return b, &slept
+}
+
+func TestSendPacesAndRetries(t *testing.T) {
+	p := &fakePoster{
+		posted:      map[string]string{},
+		rateLimited: map[string]int{"C2": 1},
+		failing:     map[string]error{"C3": errors.New("channel_not_found")},
+	}
+	b, slept := newTestBroadcaster(p, "C1", "C2", "C3")
+
+	a, err := b.Send(context.Background(), "U1", "Lunch is served")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if p.posted["C1"] != "Lunch is served" || p.posted["C2"] != "Lunch is served" {
+		t.Errorf("Expected the announcement to be posted, got %v", p.posted)
+	}
+	if failed := a.Failed(); len(failed) != 1 || failed[0].Channel != "C3" {
+		t.Errorf("Expected C3 to fail, got %+v", failed)
+	}
+	// Interval between three channels plus the Retry-After for C2
+	want := []time.Duration{time.Second, time.Second, time.Second}
+	if len(*slept) != len(want) {
+		t.Errorf("Expected to sleep %v, slept %v", want, *slept)
+	}
+	if s := a.Summary(); s != "Announcement 1 reached 2 of 3 channels\n• <#C3>: channel_not_found" {
+		t.Errorf("Unexpected summary: %s", s)
+	}
+}
+
+func TestEditUpdatesEveryCopy(t *testing.T) {
+	p := &fakePoster{posted: map[string]string{}, failing: map[string]error{"C2": errors.New("not_in_channel")}}
+	b, _ := newTestBroadcaster(p, "C1", "C2", "C3")
+	a, _ := b.Send(context.Background(), "U1", "Lunch is served")
+
+	p.posted = map[string]string{}
+	edited, err := b.Edit(context.Background(), a.ID, "Lunch is cancelled")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if len(p.posted) != 2 || p.posted["C1"] != "Lunch is cancelled" || p.posted["C3"] != "Lunch is cancelled" {
+		t.Errorf("Expected the delivered copies to be updated, got %v", p.posted)
+	}
+	if edited.Text != "Lunch is cancelled" || edited.Edited.IsZero() {
+		t.Errorf("Expected the edit to be recorded, got %+v", edited)
+	}
+	if _, err := b.Edit(context.Background(), "42", "x"); err == nil {
+		t.Errorf("Expected an error editing an unknown announcement")
+	}
+}
+
+func TestSendCancelled(t *testing.T) {
+	p := &fakePoster{posted: map[string]string{}}
+	b, _ := newTestBroadcaster(p, "C1", "C2")
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	a, err := b.Send(ctx, "U1", "x")
+	if err == nil || len(a.Deliveries) != 1 {
+		t.Errorf("Expected delivery to stop when cancelled, got %+v %v", a, err)
+	}
+}
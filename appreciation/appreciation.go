@@ -0,0 +1,142 @@
+// Package appreciation records the reactions thanking agents, such as :pray:
+// and :tada:, added to the messages in tickets' threads, so reports can count
+// them per agent and queue
+package appreciation
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/skybet/go-helpdesk/store"
+	"github.com/skybet/go-helpdesk/ticket"
+)
+
+// DefaultEmoji are the reactions counted as appreciation by default
+var DefaultEmoji = []string{"pray", "tada"}
+
+// Slack is the part of the Slack API used to find the thread of a reply
+type Slack interface {
+	ThreadOf(channelID, ts string) (string, error)
+}
+
+// Tracker records appreciation reactions on the tickets whose threads they
+// were added in
+type Tracker struct {
+	Store store.Store
+	Slack Slack
+
+	emoji map[string]bool
+}
+
+// NewTracker returns a Tracker counting the given emoji
+func NewTracker(s store.Store, sl Slack, emoji ...string) *Tracker {
+	t := &Tracker{Store: s, Slack: sl, emoji: map[string]bool{}}
+	for _, e := range emoji {
+		t.emoji[name(e)] = true
+	}
+	return t
+}
+
+// Counts reports whether emoji is counted as appreciation, whatever its skin
+// tone
+func (t *Tracker) Counts(emoji string) bool {
+	return t.emoji[name(emoji)]
+}
+
+// Added records the reaction emoji added by a user at now to the message ts in
+// channel, written by author. It returns the ticket if the message is in a
+// ticket's thread and the reaction counts, nil otherwise. Reactions to your
+// own messages do not count.
+func (t *Tracker) Added(ctx context.Context, channel, ts, emoji, by, author string, now time.Time) (*ticket.Ticket, error) {
+	if !t.Counts(emoji) || by == author {
+		return nil, nil
+	}
+	r := ticket.Reaction{Emoji: name(emoji), By: by, For: author, TS: ts, At: now}
+	return t.update(ctx, channel, ts, func(tk *ticket.Ticket) bool {
+		if _, ok := find(tk, r.Emoji, by, ts); ok {
+			return false
+		}
+		tk.Reactions = append(tk.Reactions, r)
+		return true
+	})
+}
+
+// Removed forgets the reaction emoji the user had added to the message ts in
+// channel, returning the ticket it was on
+func (t *Tracker) Removed(ctx context.Context, channel, ts, emoji, by string) (*ticket.Ticket, error) {
+	if !t.Counts(emoji) {
+		return nil, nil
+	}
+	return t.update(ctx, channel, ts, func(tk *ticket.Ticket) bool {
+		i, ok := find(tk, name(emoji), by, ts)
+		if !ok {
+			return false
+		}
+		tk.Reactions = append(tk.Reactions[:i], tk.Reactions[i+1:]...)
+		return true
+	})
+}
+
+// update applies fn to the ticket whose thread the message ts is in, writing it
+// if fn changed it
+func (t *Tracker) update(ctx context.Context, channel, ts string, fn func(tk *ticket.Ticket) bool) (*ticket.Ticket, error) {
+	thread, err := t.thread(ctx, channel, ts)
+	if err != nil || thread == "" {
+		return nil, err
+	}
+	var updated *ticket.Ticket
+	err = t.Store.Tx(ctx, func(tx store.Store) error {
+		found, _, err := tx.ListTickets(ctx, store.Filter{ChannelID: channel, ThreadTS: thread, Limit: 1})
+		if err != nil || len(found) == 0 {
+			return err
+		}
+		if !fn(found[0]) {
+			return nil
+		}
+		if err := tx.UpdateTicket(ctx, found[0]); err != nil {
+			return err
+		}
+		updated = found[0]
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error recording reaction: %s", err)
+	}
+	return updated, nil
+}
+
+// thread returns the ticket thread the message ts is in, empty if it is not in
+// one. The ticket's opening message is found without asking Slack.
+func (t *Tracker) thread(ctx context.Context, channel, ts string) (string, error) {
+	found, _, err := t.Store.ListTickets(ctx, store.Filter{ChannelID: channel, ThreadTS: ts, Limit: 1})
+	if err != nil {
+		return "", fmt.Errorf("error finding ticket: %s", err)
+	}
+	if len(found) > 0 {
+		return ts, nil
+	}
+	thread, err := t.Slack.ThreadOf(channel, ts)
+	if err != nil {
+		return "", fmt.Errorf("error finding the thread of %s: %s", ts, err)
+	}
+	if thread == ts {
+		return "", nil
+	}
+	return thread, nil
+}
+
+func find(tk *ticket.Ticket, emoji, by, ts string) (int, bool) {
+	for i, r := range tk.Reactions {
+		if r.Emoji == emoji && r.By == by && r.TS == ts {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// name strips the skin tone from an emoji, e.g. pray::skin-tone-2
+func name(emoji string) string {
+	return strings.SplitN(strings.Trim(emoji, ":"), "::", 2)[0]
+}
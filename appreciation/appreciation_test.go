@@ -0,0 +1,61 @@
+package appreciation
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/skybet/go-helpdesk/store"
+	"github.com/skybet/go-helpdesk/ticket"
+)
+
+// threads maps replies to the threads they are in
+type threads map[string]string
+
+func (th threads) ThreadOf(channelID, ts string) (string, error) {
+	if thread, ok := th[ts]; ok {
+		return thread, nil
+	}
+	return ts, nil
+}
+
+func TestTracker(t *testing.T) {
+	s := store.NewMemory()
+	s.CreateTicket(context.Background(), &ticket.Ticket{ID: "1", Queue: "it", Reporter: "U1", Assignee: "U2", ChannelID: "C1", ThreadTS: "1.0"})
+	tr := NewTracker(s, threads{"1.5": "1.0"}, DefaultEmoji...)
+	now := time.Date(2019, 3, 4, 10, 0, 0, 0, time.UTC)
+
+	tk, err := tr.Added(context.Background(), "C1", "1.5", "pray::skin-tone-3", "U1", "U2", now)
+	if err != nil || tk == nil {
+		t.Fatalf("Expected the reaction to a reply to be recorded, got %v %v", tk, err)
+	}
+	expected := ticket.Reaction{Emoji: "pray", By: "U1", For: "U2", TS: "1.5", At: now}
+	if len(tk.Reactions) != 1 || tk.Reactions[0] != expected {
+		t.Errorf("Expected %+v, got %+v", expected, tk.Reactions)
+	}
+	for _, r := range []struct{ ts, emoji, by, author string }{
+		// Already counted
+		{"1.5", "pray", "U1", "U2"},
+		// Not appreciation
+		{"1.5", "eyes", "U1", "U2"},
+		// Reacting to your own message
+		{"1.5", "tada", "U2", "U2"},
+		// Not in a ticket's thread
+		{"9.0", "tada", "U1", "U2"},
+	} {
+		if tk, err := tr.Added(context.Background(), "C1", r.ts, r.emoji, r.by, r.author, now); err != nil || tk != nil {
+			t.Errorf("Expected %+v not to be recorded, got %+v %v", r, tk, err)
+		}
+	}
+	if tk, _ := tr.Added(context.Background(), "C1", "1.0", "tada", "U3", "U1", now); tk == nil || len(tk.Reactions) != 2 {
+		t.Errorf("Expected the reaction to the opening message to be recorded, got %+v", tk)
+	}
+
+	tk, err = tr.Removed(context.Background(), "C1", "1.5", "pray", "U1")
+	if err != nil || tk == nil || len(tk.Reactions) != 1 || tk.Reactions[0].Emoji != "tada" {
+		t.Errorf("Expected the removed reaction to be forgotten, got %+v %v", tk, err)
+	}
+	if tk, _ := tr.Removed(context.Background(), "C1", "1.5", "pray", "U1"); tk != nil {
+		t.Errorf("Expected removing it again to change nothing, got %+v", tk)
+	}
+}
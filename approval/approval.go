@@ -0,0 +1,112 @@
+// Package approval holds back sensitive commands until a second admin approves
+// them, within a time window
+package approval
+
+import (
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ApproveActionID is the action ID of the "Approve" buttons sent to admins,
+// their value is the ID of the request
+const ApproveActionID = "approval_approve"
+
+var (
+	// ErrNotFound is returned for requests which do not exist or have
+	// already been approved
+	ErrNotFound = errors.New("there is no such request waiting for approval")
+	// ErrExpired is returned when approving after the window has closed
+	ErrExpired = errors.New("the request has expired")
+	// ErrSelfApproval is returned when the admin who asked tries to approve
+	ErrSelfApproval = errors.New("requests must be approved by another admin")
+)
+
+// Notice is a message asking an admin to approve a request
+type Notice struct {
+	ChannelID string
+	TS        string
+}
+
+// Request is a command waiting for approval
+type Request struct {
+	ID string
+	// Command and Text are the slash command as it was run
+	Command string
+	Text    string
+	// RequestedBy is the admin who ran the command in ChannelID of TeamID
+	RequestedBy string
+	ChannelID   string
+	TeamID      string
+	RequestedAt time.Time
+	ExpiresAt   time.Time
+	// Notices are the messages sent to the other admins
+	Notices []Notice
+}
+
+// Requests are the commands waiting for approval. Requests is safe for
+// concurrent use.
+type Requests struct {
+	// Window is how long the other admins have to approve a request
+	Window time.Duration
+
+	mu      sync.Mutex
+	seq     int
+	pending map[string]*Request
+}
+
+// New returns Requests which have to be approved within window
+func New(window time.Duration) *Requests {
+	return &Requests{Window: window, pending: map[string]*Request{}}
+}
+
+// Add records a request made at now, assigning its ID and expiry
+func (r *Requests) Add(req *Request, now time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.seq++
+	req.ID = strconv.Itoa(r.seq)
+	req.RequestedAt, req.ExpiresAt = now, now.Add(r.Window)
+	r.expire(now)
+	r.pending[req.ID] = req
+}
+
+// Noticed records the messages sent to approve a request
+func (r *Requests) Noticed(id string, notices ...Notice) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if req, ok := r.pending[id]; ok {
+		req.Notices = append(req.Notices, notices...)
+	}
+}
+
+// Approve takes a request for approver to run. A request can only be
+// approved once, by an admin other than the one who made it, before it
+// expires. Expired requests are returned along with ErrExpired.
+func (r *Requests) Approve(id, approver string, now time.Time) (*Request, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	req, ok := r.pending[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if req.RequestedBy == approver {
+		return nil, ErrSelfApproval
+	}
+	delete(r.pending, id)
+	if !now.Before(req.ExpiresAt) {
+		return req, ErrExpired
+	}
+	return req, nil
+}
+
+// expire forgets requests which can no longer be approved, the lock must be
+// held
+func (r *Requests) expire(now time.Time) {
+	for id, req := range r.pending {
+		if !now.Before(req.ExpiresAt) {
+			delete(r.pending, id)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package approval
+
+import (
+	"testing"
+	"time"
+)
+
+func TestApprove(t *testing.T) {
+	now := time.Date(2026, 10, 14, 9, 0, 0, 0, time.UTC)
+	r := New(15 * time.Minute)
+	req := &Request{Command: "/hd", Text: "export", RequestedBy: "U1"}
+	r.Add(req, now)
+	if req.ID == "" || !req.ExpiresAt.Equal(now.Add(15*time.Minute)) {
+		t.Fatalf("Expected the request to be given an ID and expiry, got %+v", req)
+	}
+	if _, err := r.Approve(req.ID, "U1", now); err != ErrSelfApproval {
+		t.Errorf("Expected the requester not to be able to approve, got %v", err)
+	}
+	got, err := r.Approve(req.ID, "U2", now.Add(time.Minute))
+	if err != nil || got != req {
+		t.Fatalf("Expected the request to be approved, got %+v, %v", got, err)
+	}
+	if _, err := r.Approve(req.ID, "U3", now.Add(time.Minute)); err != ErrNotFound {
+		t.Errorf("Expected a request to be approved only once, got %v", err)
+	}
+}
+
+func TestApproveExpired(t *testing.T) {
+	now := time.Date(2026, 10, 14, 9, 0, 0, 0, time.UTC)
+	r := New(15 * time.Minute)
+	req := &Request{Text: "bulk-close support", RequestedBy: "U1"}
+	r.Add(req, now)
+	if got, err := r.Approve(req.ID, "U2", now.Add(15*time.Minute)); err != ErrExpired || got != req {
+		t.Errorf("Expected the request to have expired, got %+v, %v", got, err)
+	}
+	if _, err := r.Approve(req.ID, "U2", now.Add(16*time.Minute)); err != ErrNotFound {
+		t.Errorf("Expected an expired request to be forgotten, got %v", err)
+	}
+
+	// Requests which are never approved are forgotten when others are made
+	old := &Request{RequestedBy: "U1"}
+	r.Add(old, now)
+	r.Add(&Request{RequestedBy: "U1"}, now.Add(time.Hour))
+	if _, err := r.Approve(old.ID, "U2", now.Add(time.Hour)); err != ErrNotFound {
+		t.Errorf("Expected the old request to be forgotten, got %v", err)
+	}
+}
@@ -0,0 +1,139 @@
+// Package archive tidies up the Slack threads of tickets once they have been
+// resolved for a while, without deleting any history
+package archive
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/nlopes/slack"
+
+	"github.com/skybet/go-helpdesk/clock"
+	"github.com/skybet/go-helpdesk/outbox"
+	"github.com/skybet/go-helpdesk/render"
+	"github.com/skybet/go-helpdesk/store"
+	"github.com/skybet/go-helpdesk/ticket"
+)
+
+// Tag labels archived tickets, the bot must not post in their threads again
+const Tag = "archived"
+
+// Locked reports whether the bot should leave the ticket's thread alone
+func Locked(t *ticket.Ticket) bool {
+	return t.HasTag(Tag)
+}
+
+// Slack is the part of the Slack API used to archive threads
+type Slack interface {
+	RemovePin(channel string, item slack.ItemRef) error
+}
+
+// Archiver archives tickets which have stayed resolved for QuietPeriod. It
+// tags each ticket with Tag and enqueues a final summary for its thread in the
+// outbox.
+type Archiver struct {
+	Store store.Store
+	Slack Slack
+	// QuietPeriod is how long after being resolved a ticket is archived
+	QuietPeriod time.Duration
+	// Unpin removes the pin from the ticket's first message in its channel
+	Unpin bool
+	// Clock, if set, replaces the wall clock
+	Clock clock.Clock
+}
+
+// Archive archives the tickets which have been resolved for QuietPeriod as of
+// now, returning how many were archived
+func (a *Archiver) Archive(ctx context.Context, now time.Time) (int, error) {
+	f := store.Filter{Status: []ticket.Status{ticket.StatusResolved, ticket.StatusClosed}, Limit: 500}
+	var due []*ticket.Ticket
+	for {
+		page, next, err := a.Store.ListTickets(ctx, f)
+		if err != nil {
+			return 0, fmt.Errorf("error listing tickets: %s", err)
+		}
+		for _, t := range page {
+			if !Locked(t) && !t.ResolvedAt.IsZero() && now.Sub(t.ResolvedAt) >= a.QuietPeriod {
+				due = append(due, t)
+			}
+		}
+		if next == "" {
+			break
+		}
+		f.Cursor = next
+	}
+
+	archived := 0
+	var errs []string
+	for _, t := range due {
+		if err := a.archive(ctx, t); err != nil {
+			errs = append(errs, fmt.Sprintf("#%s: %s", t.ID, err))
+			continue
+		}
+		archived++
+	}
+	if len(errs) > 0 {
+		return archived, fmt.Errorf("error archiving tickets: %s", strings.Join(errs, ", "))
+	}
+	return archived, nil
+}
+
+// archive tags the ticket and enqueues its summary together, so the summary is
+// posted from the outbox exactly when the ticket is archived
+func (a *Archiver) archive(ctx context.Context, t *ticket.Ticket) error {
+	err := a.Store.Tx(ctx, func(tx store.Store) error {
+		current, err := tx.GetTicket(ctx, t.ID)
+		if err != nil {
+			return err
+		}
+		if current.Status.Open() || Locked(current) {
+			return nil
+		}
+		current.Tags = append(current.Tags, Tag)
+		if err := tx.UpdateTicket(ctx, current); err != nil {
+			return err
+		}
+		*t = *current
+		if t.ChannelID == "" || t.ThreadTS == "" {
+			return nil
+		}
+		return tx.Enqueue(ctx, outbox.Thread(t, Summary(t)))
+	})
+	if err != nil || !Locked(t) || t.ChannelID == "" || t.ThreadTS == "" {
+		return err
+	}
+	if a.Unpin {
+		err := a.Slack.RemovePin(t.ChannelID, slack.NewRefToMessage(t.ChannelID, t.ThreadTS))
+		if err != nil && err.Error() != "no_pin" {
+			return fmt.Errorf("error removing pin: %s", err)
+		}
+	}
+	return nil
+}
+
+// Summary is the final message posted in an archived ticket's thread
+func Summary(t *ticket.Ticket) string {
+	s := fmt.Sprintf("%s Ticket #%s %q was %s on %s", render.Archived.Emoji, t.ID, t.Title, t.Status, t.ResolvedAt.Format("2 Jan 2006"))
+	if t.Assignee != "" {
+		s += fmt.Sprintf(" by <@%s>", t.Assignee)
+	}
+	return s + " and has been archived. This thread is no longer watched, please raise a new ticket if you need more help."
+}
+
+// Run archives tickets every interval until ctx is cancelled
+func (a *Archiver) Run(ctx context.Context, interval time.Duration, errorf func(string, ...interface{})) {
+	tick := clock.Or(a.Clock).NewTicker(interval)
+	defer tick.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-tick.C():
+			if _, err := a.Archive(ctx, now); err != nil {
+				errorf("Archiving tickets failed: %s", err)
+			}
+		}
+	}
+}
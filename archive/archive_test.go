@@ -0,0 +1,61 @@
+package archive
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nlopes/slack"
+
+	"github.com/skybet/go-helpdesk/mocks"
+	"github.com/skybet/go-helpdesk/store"
+	"github.com/skybet/go-helpdesk/ticket"
+)
+
+func resolved(s store.Store, id string, at time.Time) {
+	s.CreateTicket(context.Background(), &ticket.Ticket{ID: id, Title: "VPN", Assignee: "U1", ChannelID: "C1", ThreadTS: id + ".1"})
+	t, _ := s.GetTicket(context.Background(), id)
+	t.SetStatus(ticket.StatusResolved, at)
+	s.UpdateTicket(context.Background(), t)
+}
+
+func TestArchive(t *testing.T) {
+	now := time.Now()
+	s := store.NewMemory()
+	resolved(s, "1", now.Add(-8*24*time.Hour))
+	resolved(s, "2", now.Add(-time.Hour))
+	s.CreateTicket(context.Background(), &ticket.Ticket{ID: "3"})
+	mockSlack := &mocks.SlackWrapper{}
+	mockSlack.On("RemovePin", "C1", slack.NewRefToMessage("C1", "1.1")).Return(errors.New("no_pin"))
+	a := &Archiver{Store: s, Slack: mockSlack, QuietPeriod: 7 * 24 * time.Hour, Unpin: true}
+
+	n, err := a.Archive(context.Background(), now)
+	if err != nil || n != 1 {
+		t.Fatalf("Expected one ticket to be archived, got %d %v", n, err)
+	}
+	mockSlack.AssertExpectations(t)
+	if tk, _ := s.GetTicket(context.Background(), "1"); !Locked(tk) {
+		t.Errorf("Expected the ticket to be labelled %s, got %v", Tag, tk.Tags)
+	}
+	if tk, _ := s.GetTicket(context.Background(), "2"); Locked(tk) {
+		t.Errorf("Expected recently resolved tickets to be left alone")
+	}
+
+	// Archived tickets are left alone afterwards
+	if n, err := a.Archive(context.Background(), now); err != nil || n != 0 {
+		t.Errorf("Expected nothing more to archive, got %d %v", n, err)
+	}
+	pending, _ := s.Outbox(context.Background(), 0)
+	if len(pending) != 1 || pending[0].ThreadTS != "1.1" || !strings.Contains(pending[0].Text, "has been archived") {
+		t.Errorf("Expected one summary in the outbox for the archived ticket's thread, got %+v", pending)
+	}
+}
+
+func TestSummary(t *testing.T) {
+	tk := &ticket.Ticket{ID: "1", Title: "VPN", Status: ticket.StatusResolved, Assignee: "U1", ResolvedAt: time.Date(2019, 3, 4, 0, 0, 0, 0, time.UTC)}
+	if s := Summary(tk); !strings.Contains(s, `#1 "VPN" was resolved on 4 Mar 2019 by <@U1>`) {
+		t.Errorf("Unexpected summary %q", s)
+	}
+}
@@ -0,0 +1,249 @@
+// Package assign hands new tickets to the agents on call for their queue,
+// either taking turns or picking whoever has the fewest open tickets. Agents
+// outside their working hours are passed over, and agents with the skills for
+// a ticket's tags are preferred.
+package assign
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/skybet/go-helpdesk/archive"
+	"github.com/skybet/go-helpdesk/notify"
+	"github.com/skybet/go-helpdesk/outbox"
+	"github.com/skybet/go-helpdesk/store"
+	"github.com/skybet/go-helpdesk/ticket"
+	"github.com/skybet/go-helpdesk/wip"
+)
+
+// ErrNobodyAvailable is returned when none of the queue's on-call group is
+// working or under their WIP limit
+var ErrNobodyAvailable = errors.New("nobody on call is available")
+
+// AnyQueue is the queue name of the group used for queues without their own
+const AnyQueue = "*"
+
+// Strategy is how an agent is chosen from a group
+type Strategy string
+
+// The strategies tickets can be assigned with
+const (
+	// RoundRobin assigns each agent in turn
+	RoundRobin Strategy = "round-robin"
+	// LeastOpen assigns the agent with the fewest open tickets, taking turns
+	// between agents with the same number
+	LeastOpen Strategy = "least-open"
+)
+
+// ParseStrategy parses round-robin or least-open
+func ParseStrategy(s string) (Strategy, error) {
+	switch Strategy(s) {
+	case RoundRobin, LeastOpen:
+		return Strategy(s), nil
+	}
+	return "", fmt.Errorf("invalid assignment strategy %q, expected round-robin or least-open", s)
+}
+
+// Agent is a member of an on-call group
+type Agent struct {
+	ID string
+	// Hours are the agent's working hours in their time zone, notify.Off
+	// if they can be assigned at any time
+	Hours notify.Hours
+}
+
+// Working reports whether the agent is working at t, in their time zone
+func (a Agent) Working(t time.Time) bool {
+	return a.Hours == notify.Off || !a.Hours.Until(t).IsZero()
+}
+
+// Groups are the agents on call for each queue, AnyQueue applies to queues
+// without a group of their own
+type Groups map[string][]Agent
+
+// For returns the group on call for a queue
+func (g Groups) For(queue string) []Agent {
+	if agents, ok := g[queue]; ok {
+		return agents
+	}
+	return g[AnyQueue]
+}
+
+// ParseGroups parses groups in the form <queue>:<agent>[@<hours>]+<agent>,
+// e.g. it:U1@09:00-17:00+U2. Agents without hours work all day.
+func ParseGroups(specs []string) (Groups, error) {
+	groups := Groups{}
+	for _, spec := range specs {
+		parts := strings.SplitN(spec, ":", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return nil, fmt.Errorf("invalid on-call group %q, expected <queue>:<agent>[@<hours>]+<agent>", spec)
+		}
+		for _, a := range strings.Split(parts[1], "+") {
+			fields := strings.SplitN(a, "@", 2)
+			agent := Agent{ID: fields[0]}
+			if agent.ID == "" {
+				return nil, fmt.Errorf("invalid on-call group %q, expected <queue>:<agent>[@<hours>]+<agent>", spec)
+			}
+			if len(fields) == 2 {
+				h, err := notify.ParseHours(fields[1])
+				if err != nil {
+					return nil, fmt.Errorf("invalid working hours in %q: %s", spec, err)
+				}
+				agent.Hours = h
+			}
+			groups[parts[0]] = append(groups[parts[0]], agent)
+		}
+	}
+	return groups, nil
+}
+
+// Assigner assigns tickets to the queue's on-call group within the WIP
+// limits, and mentions the assignee in the ticket's thread. Assigner is safe
+// for concurrent use.
+type Assigner struct {
+	Store    store.Store
+	Groups   Groups
+	Strategy Strategy
+	// Limits, if set, skips agents already at their WIP limit
+	Limits *wip.Limits
+	// Group, if set, names the group on call for a queue at now, such as
+	// the group of the region owning it, instead of the queue's own
+	Group func(queue string, now time.Time) string
+	// Skills, if set, offers tickets to the agents with the most skills
+	// matching their tags first, in the strategy's order otherwise
+	Skills *Skills
+	// Location, if set, returns an agent's time zone for their working
+	// hours, they are in UTC otherwise
+	Location func(agent string) *time.Location
+
+	mu   sync.Mutex
+	next map[string]int
+}
+
+// Assign assigns the ticket with id to the next agent on call for its queue
+// at now. Tickets which are already assigned are returned as they are. It
+// fails with ErrNobodyAvailable if nobody in the group can take it, or with
+// an *wip.ErrOverLimit if the queue is at its limit.
+func (a *Assigner) Assign(ctx context.Context, id string, now time.Time) (*ticket.Ticket, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	var assigned *ticket.Ticket
+	var turn int
+	var name string
+	err := a.Store.Tx(ctx, func(tx store.Store) error {
+		t, err := tx.GetTicket(ctx, id)
+		if err != nil {
+			return err
+		}
+		assigned = t
+		if t.Assignee != "" {
+			return nil
+		}
+		name = a.groupName(t.Queue, now)
+		group := a.Groups.For(name)
+		candidates, err := a.candidates(ctx, tx, t, name, group, now)
+		if err != nil {
+			return err
+		}
+		for _, i := range candidates {
+			agent := group[i].ID
+			if a.Limits != nil {
+				err := a.Limits.Check(ctx, tx, t, agent)
+				if over, ok := err.(*wip.ErrOverLimit); ok && over.Kind == "agent" {
+					continue
+				}
+				if err != nil {
+					return err
+				}
+			}
+			t.Assignee = agent
+			if err := tx.UpdateTicket(ctx, t); err != nil {
+				return err
+			}
+			turn = i + 1
+			if t.ChannelID == "" || archive.Locked(t) {
+				return nil
+			}
+			return tx.Enqueue(ctx, outbox.Thread(t, fmt.Sprintf("<@%s> you have been assigned this ticket", agent)))
+		}
+		return ErrNobodyAvailable
+	})
+	if err != nil {
+		return nil, err
+	}
+	if turn > 0 {
+		if a.next == nil {
+			a.next = map[string]int{}
+		}
+		a.next[name] = turn
+	}
+	return assigned, nil
+}
+
+// groupName returns the name of the group on call for a queue at now
+func (a *Assigner) groupName(queue string, now time.Time) string {
+	if a.Group == nil {
+		return queue
+	}
+	return a.Group(queue, now)
+}
+
+// candidates returns the indexes in the group called name of the agents
+// working at now, in the order they should be offered the ticket
+func (a *Assigner) candidates(ctx context.Context, s store.Store, t *ticket.Ticket, name string, group []Agent, now time.Time) ([]int, error) {
+	var candidates []int
+	for n := range group {
+		i := (a.next[name] + n) % len(group)
+		if group[i].Working(now.In(a.location(group[i].ID))) {
+			candidates = append(candidates, i)
+		}
+	}
+	if a.Strategy == LeastOpen {
+		open := map[int]int{}
+		for _, i := range candidates {
+			n, err := countOpen(ctx, s, group[i].ID)
+			if err != nil {
+				return nil, err
+			}
+			open[i] = n
+		}
+		sort.SliceStable(candidates, func(x, y int) bool { return open[candidates[x]] < open[candidates[y]] })
+	}
+	if a.Skills != nil {
+		matching := map[int]int{}
+		for _, i := range candidates {
+			matching[i] = a.Skills.Matching(group[i].ID, t)
+		}
+		sort.SliceStable(candidates, func(x, y int) bool { return matching[candidates[x]] > matching[candidates[y]] })
+	}
+	return candidates, nil
+}
+
+func (a *Assigner) location(agent string) *time.Location {
+	if a.Location == nil {
+		return time.UTC
+	}
+	return a.Location(agent)
+}
+
+// countOpen returns the number of open tickets assigned to agent
+func countOpen(ctx context.Context, s store.Store, agent string) (int, error) {
+	f := store.Filter{Assignee: agent, Status: wip.OpenStatuses}
+	n := 0
+	for {
+		page, next, err := s.ListTickets(ctx, f)
+		if err != nil {
+			return 0, fmt.Errorf("error counting open tickets: %s", err)
+		}
+		n += len(page)
+		if next == "" {
+			return n, nil
+		}
+		f.Cursor = next
+	}
+}
@@ -0,0 +1,144 @@
+package assign
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/skybet/go-helpdesk/notify"
+	"github.com/skybet/go-helpdesk/store"
+	"github.com/skybet/go-helpdesk/ticket"
+	"github.com/skybet/go-helpdesk/wip"
+)
+
+func TestParseGroups(t *testing.T) {
+	g, err := ParseGroups([]string{"it:U1@09:00-17:00+U2", "*:U3"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if it := g.For("it"); len(it) != 2 || it[0].ID != "U1" || it[0].Hours.String() != "09:00-17:00" || it[1].Hours != notify.Off {
+		t.Errorf("Expected the it group, got %+v", it)
+	}
+	if hr := g.For("hr"); len(hr) != 1 || hr[0].ID != "U3" {
+		t.Errorf("Expected queues without a group to use *, got %+v", hr)
+	}
+	for _, spec := range []string{"it", "it:", ":U1", "it:U1+", "it:U1@9-5"} {
+		if _, err := ParseGroups([]string{spec}); err == nil {
+			t.Errorf("Expected an error parsing %q", spec)
+		}
+	}
+}
+
+func newTickets(t *testing.T, ids ...string) store.Store {
+	s := store.NewMemory()
+	for _, id := range ids {
+		if err := s.CreateTicket(context.Background(), &ticket.Ticket{ID: id, Queue: "it", ChannelID: "C1", ThreadTS: id + ".0"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return s
+}
+
+func assignees(t *testing.T, a *Assigner, now time.Time, ids ...string) []string {
+	t.Helper()
+	var got []string
+	for _, id := range ids {
+		tk, err := a.Assign(context.Background(), id, now)
+		if err == ErrNobodyAvailable {
+			got = append(got, "")
+			continue
+		}
+		if err != nil {
+			t.Fatalf("Unexpected error assigning %s: %s", id, err)
+		}
+		got = append(got, tk.Assignee)
+	}
+	return got
+}
+
+func TestRoundRobin(t *testing.T) {
+	s := newTickets(t, "1", "2", "3", "4")
+	nineToFive, _ := notify.ParseHours("09:00-17:00")
+	a := &Assigner{Store: s, Strategy: RoundRobin, Groups: Groups{"it": {{ID: "U1"}, {ID: "U2", Hours: nineToFive}, {ID: "U3"}}}}
+
+	morning := time.Date(2026, 10, 14, 10, 0, 0, 0, time.UTC)
+	if got := assignees(t, a, morning, "1", "2", "3"); got[0] != "U1" || got[1] != "U2" || got[2] != "U3" {
+		t.Errorf("Expected each agent in turn, got %v", got)
+	}
+	// U2 has gone home, the turn goes from U1 to U3
+	if got := assignees(t, a, morning.Add(8*time.Hour), "4"); got[0] != "U1" {
+		t.Errorf("Expected U1 to be next, got %v", got)
+	}
+	if got := assignees(t, a, morning, "1"); got[0] != "U1" {
+		t.Errorf("Expected assigned tickets to be left alone, got %v", got)
+	}
+	pending, _ := s.Outbox(context.Background(), 10)
+	if len(pending) != 4 || pending[0].ThreadTS != "1.0" || pending[0].Text != "<@U1> you have been assigned this ticket" {
+		t.Errorf("Expected the assignees to be mentioned in the tickets' threads, got %+v", pending)
+	}
+}
+
+func TestLeastOpen(t *testing.T) {
+	s := newTickets(t, "1", "2", "3", "4")
+	for _, id := range []string{"8", "9"} {
+		s.CreateTicket(context.Background(), &ticket.Ticket{ID: id, Queue: "it", Assignee: "U1"})
+	}
+	limits := wip.NewLimits()
+	limits.SetAgent("U3", 1)
+	a := &Assigner{Store: s, Strategy: LeastOpen, Limits: limits, Groups: Groups{AnyQueue: {{ID: "U1"}, {ID: "U2"}, {ID: "U3"}}}}
+
+	// U3 has the fewest open tickets for the last one, but is at their limit
+	if got := assignees(t, a, time.Now(), "1", "2", "3", "4"); got[0] != "U2" || got[1] != "U3" || got[2] != "U2" || got[3] != "U1" {
+		t.Errorf("Expected the agents with the fewest open tickets, got %v", got)
+	}
+}
+
+func TestNobodyAvailable(t *testing.T) {
+	s := newTickets(t, "1")
+	nights, _ := notify.ParseHours("22:00-06:00")
+	a := &Assigner{Store: s, Strategy: RoundRobin, Groups: Groups{"it": {{ID: "U1", Hours: nights}}}}
+	a.Location = func(string) *time.Location { return time.FixedZone("UTC+8", 8*3600) }
+
+	if got := assignees(t, a, time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC), "1"); got[0] != "" {
+		t.Errorf("Expected nobody to be available at 20:00 local time, got %v", got)
+	}
+	if got := assignees(t, a, time.Date(2026, 10, 14, 15, 0, 0, 0, time.UTC), "1"); got[0] != "U1" {
+		t.Errorf("Expected U1 to be available at 23:00 local time, got %v", got)
+	}
+}
+
+func TestSkills(t *testing.T) {
+	s := newTickets(t, "1", "2")
+	tk, _ := s.GetTicket(context.Background(), "2")
+	tk.Tags = []string{"VPN", "laptop"}
+	s.UpdateTicket(context.Background(), tk)
+	skills := NewSkills([]string{"vpn", "laptop"})
+	skills.Toggle("U2", "vpn")
+	skills.Toggle("U3", "vpn")
+	skills.Toggle("U3", "laptop")
+	a := &Assigner{Store: s, Strategy: RoundRobin, Skills: skills, Groups: Groups{"it": {{ID: "U1"}, {ID: "U2"}, {ID: "U3"}}}}
+
+	// Nobody has a skill for ticket 1, it goes to the next in turn
+	if got := assignees(t, a, time.Now(), "1", "2"); got[0] != "U1" || got[1] != "U3" {
+		t.Errorf("Expected the agent with the most matching skills, got %v", got)
+	}
+}
+
+func TestGroup(t *testing.T) {
+	s := newTickets(t, "1", "2", "3")
+	a := &Assigner{Store: s, Strategy: RoundRobin, Groups: Groups{"it": {{ID: "U1"}}, "it/emea": {{ID: "U2"}, {ID: "U3"}}}}
+	a.Group = func(queue string, now time.Time) string {
+		if now.Hour() < 12 {
+			return queue + "/emea"
+		}
+		return queue
+	}
+
+	morning := time.Date(2026, 10, 14, 10, 0, 0, 0, time.UTC)
+	if got := assignees(t, a, morning, "1", "2"); got[0] != "U2" || got[1] != "U3" {
+		t.Errorf("Expected the morning's group in turn, got %v", got)
+	}
+	if got := assignees(t, a, morning.Add(4*time.Hour), "3"); got[0] != "U1" {
+		t.Errorf("Expected the queue's own group in the afternoon, got %v", got)
+	}
+}
@@ -0,0 +1,195 @@
+package assign
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/skybet/go-helpdesk/atomicfile"
+	"github.com/skybet/go-helpdesk/store"
+	"github.com/skybet/go-helpdesk/ticket"
+	"github.com/skybet/go-helpdesk/wip"
+)
+
+// Skills are the tags of the tickets each agent has said they are best at.
+// The Assigner offers a ticket to the agents with the most of its tags first.
+// Skills is safe for concurrent use and a nil *Skills has none.
+type Skills struct {
+	// Offered are the skills agents can choose from
+	Offered []string
+
+	path   string
+	mu     sync.Mutex
+	agents map[string][]string
+}
+
+// NewSkills returns skills agents can choose from offered, kept in memory
+func NewSkills(offered []string) *Skills {
+	s := &Skills{agents: map[string][]string{}}
+	for _, o := range offered {
+		s.Offered = append(s.Offered, strings.ToLower(strings.TrimSpace(o)))
+	}
+	return s
+}
+
+// LoadSkills returns skills agents can choose from offered whose choices are
+// saved in the JSON file at path, which is created by the first choice if it
+// does not exist
+func LoadSkills(offered []string, path string) (*Skills, error) {
+	s := NewSkills(offered)
+	s.path = path
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading skills: %s", err)
+	}
+	if err := json.Unmarshal(b, &s.agents); err != nil {
+		return nil, fmt.Errorf("error decoding skills in %s: %s", path, err)
+	}
+	return s, nil
+}
+
+// Of returns an agent's skills in the order they are offered
+func (s *Skills) Of(agent string) []string {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.agents[agent]...)
+}
+
+// Agents returns the agents with a skill, sorted
+func (s *Skills) Agents(skill string) []string {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var agents []string
+	for agent, skills := range s.agents {
+		for _, sk := range skills {
+			if sk == skill {
+				agents = append(agents, agent)
+			}
+		}
+	}
+	sort.Strings(agents)
+	return agents
+}
+
+// Toggle adds a skill to an agent, or removes it if they have it already,
+// returning whether they have it now
+func (s *Skills) Toggle(agent, skill string) (bool, error) {
+	skill = strings.ToLower(skill)
+	if !s.offers(skill) {
+		return false, fmt.Errorf("%q is not one of the skills offered", skill)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	had := map[string]bool{}
+	for _, sk := range s.agents[agent] {
+		had[sk] = true
+	}
+	had[skill] = !had[skill]
+	var skills []string
+	for _, o := range s.Offered {
+		if had[o] {
+			skills = append(skills, o)
+		}
+	}
+	if len(skills) == 0 {
+		delete(s.agents, agent)
+	} else {
+		s.agents[agent] = skills
+	}
+	return had[skill], s.save()
+}
+
+// Matching counts the ticket's tags an agent has the skill for
+func (s *Skills) Matching(agent string, t *ticket.Ticket) int {
+	n := 0
+	for _, sk := range s.Of(agent) {
+		for _, tag := range t.Tags {
+			if strings.EqualFold(tag, sk) {
+				n++
+				break
+			}
+		}
+	}
+	return n
+}
+
+// Coverage is how well a skill is covered
+type Coverage struct {
+	Skill string
+	// Agents have the skill
+	Agents []string
+	// Open counts the open tickets tagged with the skill
+	Open int
+}
+
+// Gap reports whether there are open tickets for the skill but nobody has it
+func (c Coverage) Gap() bool {
+	return c.Open > 0 && len(c.Agents) == 0
+}
+
+// Coverage returns the coverage of every skill offered for the open tickets
+// in st, least covered first: the fewest agents, then the most open tickets
+func (s *Skills) Coverage(ctx context.Context, st store.Store) ([]Coverage, error) {
+	open := map[string]int{}
+	f := store.Filter{Status: wip.OpenStatuses}
+	for {
+		page, next, err := st.ListTickets(ctx, f)
+		if err != nil {
+			return nil, fmt.Errorf("error listing open tickets: %s", err)
+		}
+		for _, t := range page {
+			for _, tag := range t.Tags {
+				open[strings.ToLower(tag)]++
+			}
+		}
+		if next == "" {
+			break
+		}
+		f.Cursor = next
+	}
+	var coverage []Coverage
+	for _, skill := range s.Offered {
+		coverage = append(coverage, Coverage{Skill: skill, Agents: s.Agents(skill), Open: open[skill]})
+	}
+	sort.SliceStable(coverage, func(i, j int) bool {
+		if len(coverage[i].Agents) != len(coverage[j].Agents) {
+			return len(coverage[i].Agents) < len(coverage[j].Agents)
+		}
+		return coverage[i].Open > coverage[j].Open
+	})
+	return coverage, nil
+}
+
+func (s *Skills) offers(skill string) bool {
+	for _, o := range s.Offered {
+		if o == skill {
+			return true
+		}
+	}
+	return false
+}
+
+// save writes the skills to the file, if there is one. s.mu must be held.
+func (s *Skills) save() error {
+	if s.path == "" {
+		return nil
+	}
+	if err := atomicfile.WriteJSON(s.path, s.agents); err != nil {
+		return fmt.Errorf("error saving skills: %s", err)
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package assign
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/skybet/go-helpdesk/store"
+	"github.com/skybet/go-helpdesk/ticket"
+)
+
+func TestToggleSkills(t *testing.T) {
+	dir, err := ioutil.TempDir("", "skills")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "skills.json")
+	s, err := LoadSkills([]string{"VPN", "laptop", "billing"}, path)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	for _, skill := range []string{"billing", "vpn", "laptop"} {
+		if has, err := s.Toggle("U1", skill); !has || err != nil {
+			t.Errorf("Expected U1 to have %s, got %t %v", skill, has, err)
+		}
+	}
+	if has, err := s.Toggle("U1", "laptop"); has || err != nil {
+		t.Errorf("Expected toggling laptop again to remove it, got %t %v", has, err)
+	}
+	if _, err := s.Toggle("U1", "printers"); err == nil {
+		t.Error("Expected a skill which is not offered to be refused")
+	}
+	s.Toggle("U2", "vpn")
+
+	s, err = LoadSkills([]string{"vpn", "laptop", "billing"}, path)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if got := s.Of("U1"); len(got) != 2 || got[0] != "vpn" || got[1] != "billing" {
+		t.Errorf("Expected U1's skills to be saved in the order offered, got %v", got)
+	}
+	if got := s.Agents("vpn"); len(got) != 2 || got[0] != "U1" || got[1] != "U2" {
+		t.Errorf("Expected U1 and U2 to have vpn, got %v", got)
+	}
+	if n := s.Matching("U1", &ticket.Ticket{Tags: []string{"Billing", "vpn", "laptop"}}); n != 2 {
+		t.Errorf("Expected 2 matching skills, got %d", n)
+	}
+	var none *Skills
+	if none.Of("U1") != nil || none.Matching("U1", &ticket.Ticket{Tags: []string{"vpn"}}) != 0 {
+		t.Error("Expected nil skills to have none")
+	}
+}
+
+func TestCoverage(t *testing.T) {
+	ctx := context.Background()
+	st := store.NewMemory()
+	for _, tk := range []*ticket.Ticket{
+		{Tags: []string{"billing"}},
+		{Tags: []string{"Billing", "vpn"}},
+		{Tags: []string{"vpn"}},
+		{Tags: []string{"vpn"}, Status: ticket.StatusClosed},
+	} {
+		st.CreateTicket(ctx, tk)
+	}
+	s := NewSkills([]string{"vpn", "billing", "printers"})
+	s.Toggle("U1", "vpn")
+
+	coverage, err := s.Coverage(ctx, st)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if len(coverage) != 3 || coverage[0].Skill != "billing" || coverage[0].Open != 2 || !coverage[0].Gap() {
+		t.Fatalf("Expected billing to be the biggest gap, got %+v", coverage)
+	}
+	if coverage[1].Skill != "printers" || coverage[1].Gap() || coverage[2].Skill != "vpn" || coverage[2].Open != 2 || len(coverage[2].Agents) != 1 {
+		t.Errorf("Expected printers then vpn, got %+v", coverage)
+	}
+}
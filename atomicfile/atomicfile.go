@@ -0,0 +1,43 @@
+// Package atomicfile replaces files by writing a temporary file beside them
+// and renaming it over them, so that readers and crashes part way through
+// only ever see the previous version or the new one, never half of it
+package atomicfile
+
+import (
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// Write replaces the file at path with what write writes to it. The file is
+// left alone if write returns an error. The new file can only be read and
+// written by its owner.
+func Write(path string, write func(w io.Writer) error) error {
+	f, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if err := write(f); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+// WriteJSON replaces the file at path with v as indented JSON
+func WriteJSON(path string, v interface{}) error {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return Write(path, func(w io.Writer) error {
+		_, err := w.Write(b)
+		return err
+	})
+}
@@ -0,0 +1,39 @@
+package atomicfile
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteJSON(t *testing.T) {
+	dir, err := ioutil.TempDir("", "atomicfile")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "v.json")
+	if err := WriteJSON(path, map[string]int{"a": 1}); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if b, _ := ioutil.ReadFile(path); string(b) != "{\n  \"a\": 1\n}" {
+		t.Errorf("Expected the JSON to be written, got %q", b)
+	}
+
+	err = Write(path, func(w io.Writer) error {
+		w.Write([]byte("{"))
+		return errors.New("disk full")
+	})
+	if err == nil || err.Error() != "disk full" {
+		t.Fatalf("Expected the write's error, got %v", err)
+	}
+	if b, _ := ioutil.ReadFile(path); string(b) != "{\n  \"a\": 1\n}" {
+		t.Errorf("Expected a failed write to leave the file alone, got %q", b)
+	}
+	if files, _ := ioutil.ReadDir(dir); len(files) != 1 {
+		t.Errorf("Expected the temporary file to be removed, got %d files", len(files))
+	}
+}
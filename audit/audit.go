@@ -0,0 +1,196 @@
+// Package audit records who did what to the helpdesk, for actions which need
+// to be accounted for such as erasing a user's data. Entries are hash chained,
+// so that altering, removing or reordering any of them can be detected.
+package audit
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// Entry is an action recorded in the audit log
+type Entry struct {
+	// Seq numbers the entries, starting at 1
+	Seq int
+	At  time.Time
+	// Action is what was done, such as the command which was run
+	Action string
+	// Actor is the Slack user who did it and ApprovedBy the admin who
+	// approved it, if it needed approval
+	Actor      string
+	ApprovedBy string
+	// Outcome is the result of the action, such as how many tickets it
+	// changed or why it failed
+	Outcome string
+	// Details are further facts about the action, such as the filter, row
+	// count and destination of an export
+	Details map[string]string `json:",omitempty"`
+	// PrevHash is the Hash of the entry before, empty for the first, and Hash
+	// is the SHA-256 of the rest of this entry. Both are set by Record.
+	PrevHash string
+	Hash     string
+}
+
+// sum returns the hash of the entry, everything but its Hash
+func (e Entry) sum() string {
+	e.Hash = ""
+	b, _ := json.Marshal(e)
+	h := sha256.Sum256(b)
+	return hex.EncodeToString(h[:])
+}
+
+// ErrTampered is returned when the audit log is not the chain Record wrote
+type ErrTampered struct {
+	// Seq is the first entry found to be out of place
+	Seq    int
+	Reason string
+}
+
+func (e *ErrTampered) Error() string {
+	return fmt.Sprintf("audit log has been altered at entry %d: %s", e.Seq, e.Reason)
+}
+
+// Verify checks entries are an unbroken chain from the first, returning an
+// ErrTampered for the first which is not. Removing the latest entries leaves
+// an unbroken chain, compare the last Hash with one kept elsewhere, such as
+// in the application log, to detect it.
+func Verify(entries []Entry) error {
+	prev := ""
+	for i, e := range entries {
+		switch {
+		case e.Seq != i+1:
+			return &ErrTampered{Seq: i + 1, Reason: fmt.Sprintf("found entry %d in its place", e.Seq)}
+		case e.PrevHash != prev:
+			return &ErrTampered{Seq: e.Seq, Reason: "it does not follow the entry before"}
+		case e.Hash != e.sum():
+			return &ErrTampered{Seq: e.Seq, Reason: "its hash does not match its contents"}
+		}
+		prev = e.Hash
+	}
+	return nil
+}
+
+// ReadFile returns the entries in an audit log file, oldest first
+func ReadFile(path string) ([]Entry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error opening audit log: %s", err)
+	}
+	defer f.Close()
+	return read(f)
+}
+
+func read(r io.Reader) ([]Entry, error) {
+	var entries []Entry
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		var e Entry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return nil, fmt.Errorf("error reading audit log line %d: %s", line, err)
+		}
+		entries = append(entries, e)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading audit log: %s", err)
+	}
+	return entries, nil
+}
+
+// Log is an append-only audit log kept in memory, and in a file if it was
+// opened with Open. Log is safe for concurrent use.
+type Log struct {
+	// Sink is also given each entry as it is recorded, e.g. to write it to
+	// the application log
+	Sink func(Entry)
+
+	mu      sync.Mutex
+	entries []Entry
+	file    *os.File
+}
+
+// Open returns a log kept in the file at path, creating it if it does not
+// exist. Entries already in the file are loaded, without being verified, and
+// the entries recorded afterwards continue their chain.
+func Open(path string) (*Log, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("error opening audit log: %s", err)
+	}
+	entries, err := read(f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &Log{entries: entries, file: f}, nil
+}
+
+// Close closes the log's file, if it has one
+func (l *Log) Close() error {
+	if l.file == nil {
+		return nil
+	}
+	return l.file.Close()
+}
+
+// Record appends an entry to the log, chaining it to the one before. The
+// entry is only kept if it could be written to the log's file.
+func (l *Log) Record(e Entry) error {
+	l.mu.Lock()
+	e.Seq, e.PrevHash = len(l.entries)+1, ""
+	if len(l.entries) > 0 {
+		e.PrevHash = l.entries[len(l.entries)-1].Hash
+	}
+	e.Hash = e.sum()
+	if l.file != nil {
+		b, _ := json.Marshal(e)
+		if _, err := l.file.Write(append(b, '\n')); err != nil {
+			l.mu.Unlock()
+			return fmt.Errorf("error writing audit log: %s", err)
+		}
+	}
+	l.entries = append(l.entries, e)
+	l.mu.Unlock()
+	if l.Sink != nil {
+		l.Sink(e)
+	}
+	return nil
+}
+
+// Entries returns every entry recorded, oldest first
+func (l *Log) Entries() []Entry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]Entry(nil), l.entries...)
+}
+
+// Verify checks the log has not been altered, returning its entries. A log
+// kept in a file is read back from it, and must also still hold every entry
+// recorded since it was opened.
+func (l *Log) Verify() ([]Entry, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	entries := append([]Entry(nil), l.entries...)
+	if l.file != nil {
+		var err error
+		if entries, err = ReadFile(l.file.Name()); err != nil {
+			return nil, err
+		}
+	}
+	if err := Verify(entries); err != nil {
+		return entries, err
+	}
+	if n := len(l.entries); len(entries) < n {
+		return entries, &ErrTampered{Seq: n, Reason: "it is missing"}
+	} else if n > 0 && entries[n-1].Hash != l.entries[n-1].Hash {
+		return entries, &ErrTampered{Seq: n, Reason: "it is not the entry which was recorded"}
+	}
+	return entries, nil
+}
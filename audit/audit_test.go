@@ -0,0 +1,117 @@
+package audit
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRecord(t *testing.T) {
+	var sunk []Entry
+	l := &Log{Sink: func(e Entry) { sunk = append(sunk, e) }}
+	e := Entry{At: time.Now(), Action: "/hd export", Actor: "U1", ApprovedBy: "U2", Outcome: "exported 3 tickets"}
+	if err := l.Record(e); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	got := l.Entries()
+	if len(got) != 1 || got[0].Action != e.Action || got[0].Actor != e.Actor || got[0].ApprovedBy != e.ApprovedBy || got[0].Outcome != e.Outcome || !got[0].At.Equal(e.At) {
+		t.Errorf("Expected the entry to be recorded, got %+v", got)
+	}
+	if len(sunk) != 1 || sunk[0].Hash != got[0].Hash {
+		t.Errorf("Expected the entry to be given to the sink, got %+v", sunk)
+	}
+	l.Record(Entry{At: time.Now(), Action: "/hd erase", Actor: "U2", ApprovedBy: "U1", Outcome: "erased U3"})
+	got = l.Entries()
+	if got[0].Seq != 1 || got[0].PrevHash != "" || got[0].Hash == "" || got[1].Seq != 2 || got[1].PrevHash != got[0].Hash || got[1].Hash == got[0].Hash {
+		t.Errorf("Expected the entries to be chained, got %+v", got)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	l := &Log{}
+	for _, action := range []string{"/hd export", "/hd erase", "/hd bulk-close"} {
+		l.Record(Entry{At: time.Now(), Action: action, Actor: "U1", ApprovedBy: "U2", Outcome: "done", Details: map[string]string{"rows": "3"}})
+	}
+	if err := Verify(l.Entries()); err != nil {
+		t.Errorf("Expected the log to verify, got %s", err)
+	}
+	if err := Verify(nil); err != nil {
+		t.Errorf("Expected an empty log to verify, got %s", err)
+	}
+	for name, tc := range map[string]struct {
+		tamper func([]Entry) []Entry
+		seq    int
+	}{
+		"Changed": {func(e []Entry) []Entry { e[1].Outcome = "nothing"; return e }, 2},
+		"Actor":   {func(e []Entry) []Entry { e[0].ApprovedBy = "U1"; return e }, 1},
+		"Details": {func(e []Entry) []Entry { e[2].Details = map[string]string{"rows": "30"}; return e }, 3},
+		"Removed": {func(e []Entry) []Entry { return append(e[:1], e[2:]...) }, 2},
+		"Swapped": {func(e []Entry) []Entry { e[1], e[2] = e[2], e[1]; return e }, 2},
+		"Rehashed": {func(e []Entry) []Entry {
+			e[1].Outcome = "nothing"
+			e[1].Hash = e[1].sum()
+			return e
+		}, 3},
+		"Renumbered": {func(e []Entry) []Entry {
+			e = append(e[:1], e[2:]...)
+			e[1].Seq = 2
+			return e
+		}, 2},
+	} {
+		t.Run(name, func(t *testing.T) {
+			err := Verify(tc.tamper(l.Entries()))
+			if tampered, ok := err.(*ErrTampered); !ok || tampered.Seq != tc.seq {
+				t.Errorf("Expected entry %d to be found altered, got %v", tc.seq, err)
+			}
+		})
+	}
+}
+
+func TestOpen(t *testing.T) {
+	dir, err := ioutil.TempDir("", "audit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "audit.log")
+	l, err := Open(path)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	l.Record(Entry{At: time.Now(), Action: "/hd export", Actor: "U1", ApprovedBy: "U2", Outcome: "exported 3 tickets"})
+	l.Close()
+
+	l, err = Open(path)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	defer l.Close()
+	if err := l.Record(Entry{At: time.Now(), Action: "/hd erase", Actor: "U2", ApprovedBy: "U1", Outcome: "erased U3"}); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	entries, err := l.Verify()
+	if err != nil || len(entries) != 2 || entries[1].PrevHash != entries[0].Hash {
+		t.Fatalf("Expected the reopened log to continue the chain, got %+v %v", entries, err)
+	}
+	if read, err := ReadFile(path); err != nil || len(read) != 2 || read[1].Hash != l.Entries()[1].Hash {
+		t.Errorf("Expected the entries to be written to the file, got %+v %v", read, err)
+	}
+
+	content, _ := ioutil.ReadFile(path)
+	edited := strings.Replace(string(content), "erased U3", "erased U4", 1)
+	ioutil.WriteFile(path, []byte(edited), 0600)
+	if _, err := l.Verify(); err == nil || !strings.Contains(err.Error(), "altered at entry 2") {
+		t.Errorf("Expected the edited file to be found altered, got %v", err)
+	}
+	lines := strings.SplitAfter(string(content), "\n")
+	ioutil.WriteFile(path, []byte(lines[0]), 0600)
+	if _, err := l.Verify(); err == nil || !strings.Contains(err.Error(), "entry 2: it is missing") {
+		t.Errorf("Expected the truncated file to be found altered, got %v", err)
+	}
+	if _, err := ReadFile(filepath.Join(dir, "missing.log")); err == nil {
+		t.Error("Expected an error reading a missing file")
+	}
+}
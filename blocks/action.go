@@ -0,0 +1,31 @@
+package blocks
+
+import (
+	"fmt"
+
+	"github.com/nlopes/slack"
+)
+
+// Action is a block action a user took in a block_actions interaction, such
+// as clicking a button or choosing from an overflow menu
+type Action struct {
+	ActionID string
+	BlockID  string
+	// Type is the type of element, such as button or overflow
+	Type string
+	// Value is the button's value or the value of the chosen option
+	Value string
+}
+
+// ActionOf returns the action a user took, Slack sends one at a time
+func ActionOf(ic *slack.InteractionCallback) (Action, error) {
+	if len(ic.ActionCallback.BlockActions) == 0 {
+		return Action{}, fmt.Errorf("Expected a block action")
+	}
+	a := ic.ActionCallback.BlockActions[0]
+	value := a.Value
+	if value == "" {
+		value = a.SelectedOption.Value
+	}
+	return Action{ActionID: a.ActionID, BlockID: a.BlockID, Type: string(a.Type), Value: value}, nil
+}
@@ -0,0 +1,36 @@
+package blocks
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/nlopes/slack"
+)
+
+func TestActionOf(t *testing.T) {
+	for name, tc := range map[string]struct {
+		payload  string
+		expected Action
+	}{
+		"button": {
+			`{"type":"block_actions","actions":[{"type":"button","action_id":"ack","block_id":"b1","value":"42"}]}`,
+			Action{ActionID: "ack", BlockID: "b1", Type: "button", Value: "42"},
+		},
+		"overflow": {
+			`{"type":"block_actions","actions":[{"type":"overflow","action_id":"more","block_id":"b1","selected_option":{"text":{"type":"plain_text","text":"Close"},"value":"close"}}]}`,
+			Action{ActionID: "more", BlockID: "b1", Type: "overflow", Value: "close"},
+		},
+	} {
+		var ic slack.InteractionCallback
+		if err := json.Unmarshal([]byte(tc.payload), &ic); err != nil {
+			t.Fatalf("%s: unexpected error decoding the payload: %s", name, err)
+		}
+		a, err := ActionOf(&ic)
+		if err != nil || a != tc.expected {
+			t.Errorf("%s: expected %+v, got %+v %v", name, tc.expected, a, err)
+		}
+	}
+	if _, err := ActionOf(&slack.InteractionCallback{}); err == nil {
+		t.Errorf("Expected an error without a block action")
+	}
+}
@@ -0,0 +1,105 @@
+// Package blocks builds Block Kit messages and views, and reads the actions
+// users take on them
+package blocks
+
+import (
+	"github.com/nlopes/slack"
+	"github.com/skybet/go-helpdesk/views"
+)
+
+// Builder builds a list of blocks. Each method appends a block and returns
+// the builder, so a message reads top to bottom:
+//
+//	blocks.New().Section("*Printer on fire*").Context("Opened by <@U1>").Divider().Actions("", blocks.Button("ack", "42", "Acknowledge")).Blocks()
+type Builder struct {
+	blocks []slack.Block
+}
+
+// New returns an empty Builder
+func New() *Builder {
+	return &Builder{}
+}
+
+// Section appends a section of markdown text, with an optional accessory
+// such as a button or overflow menu shown beside it
+func (b *Builder) Section(text string, accessory ...slack.BlockElement) *Builder {
+	var a *slack.Accessory
+	if len(accessory) > 0 {
+		a = slack.NewAccessory(accessory[0])
+	}
+	return b.add(slack.NewSectionBlock(Markdown(text), nil, a))
+}
+
+// Fields appends a section of markdown fields, shown two to a row
+func (b *Builder) Fields(fields ...string) *Builder {
+	objs := make([]*slack.TextBlockObject, len(fields))
+	for i, f := range fields {
+		objs[i] = Markdown(f)
+	}
+	return b.add(slack.NewSectionBlock(nil, objs, nil))
+}
+
+// Context appends a context block of small markdown text
+func (b *Builder) Context(texts ...string) *Builder {
+	elements := make([]slack.MixedElement, len(texts))
+	for i, t := range texts {
+		elements[i] = Markdown(t)
+	}
+	return b.add(slack.NewContextBlock("", elements...))
+}
+
+// Divider appends a divider
+func (b *Builder) Divider() *Builder {
+	return b.add(slack.NewDividerBlock())
+}
+
+// Actions appends a block of interactive elements, such as buttons
+func (b *Builder) Actions(blockID string, elements ...slack.BlockElement) *Builder {
+	return b.add(slack.NewActionBlock(blockID, elements...))
+}
+
+// Input appends an input labelled with label, inputs are only valid in views
+func (b *Builder) Input(blockID, label string, element interface{}) *Builder {
+	return b.add(views.NewInputBlock(blockID, label, element))
+}
+
+// Block appends any other block
+func (b *Builder) Block(block slack.Block) *Builder {
+	return b.add(block)
+}
+
+func (b *Builder) add(block slack.Block) *Builder {
+	b.blocks = append(b.blocks, block)
+	return b
+}
+
+// Blocks returns the blocks built so far
+func (b *Builder) Blocks() []slack.Block {
+	return b.blocks
+}
+
+// Markdown returns a markdown text object
+func Markdown(text string) *slack.TextBlockObject {
+	return slack.NewTextBlockObject(slack.MarkdownType, text, false, false)
+}
+
+// PlainText returns a plain text object, as buttons and labels require
+func PlainText(text string) *slack.TextBlockObject {
+	return slack.NewTextBlockObject(slack.PlainTextType, text, false, false)
+}
+
+// Button returns a button which sends value with the action actionID
+func Button(actionID, value, text string) *slack.ButtonBlockElement {
+	return slack.NewButtonBlockElement(actionID, value, PlainText(text))
+}
+
+// Option returns an option for an overflow menu or select
+func Option(value, text string) *slack.OptionBlockObject {
+	return slack.NewOptionBlockObject(value, PlainText(text))
+}
+
+// Overflow returns an overflow menu, which sends the chosen option's value
+// with the action actionID
+func Overflow(actionID string, options ...*slack.OptionBlockObject) *slack.OverflowBlockElement {
+	return slack.NewOverflowBlockElement(actionID, options...)
+}
@@ -0,0 +1,41 @@
+package blocks
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/nlopes/slack"
+	"github.com/skybet/go-helpdesk/views"
+)
+
+func TestBuilder(t *testing.T) {
+	ack := Button("ack", "42", "Acknowledge")
+	ack.Style = slack.StylePrimary
+	b := New().
+		Section("*Printer on fire*", Overflow("more", Option("close", "Close"))).
+		Fields("*Queue*\nIT", "*Priority*\nP1").
+		Context("Opened by Ada").
+		Divider().
+		Actions("ticket-42", ack)
+	data, err := json.Marshal(slack.Blocks{BlockSet: b.Blocks()})
+	if err != nil {
+		t.Fatalf("Unexpected error encoding the blocks: %s", err)
+	}
+	expected := `[` +
+		`{"type":"section","text":{"type":"mrkdwn","text":"*Printer on fire*"},"accessory":{"type":"overflow","action_id":"more","options":[{"text":{"type":"plain_text","text":"Close"},"value":"close"}]}},` +
+		`{"type":"section","fields":[{"type":"mrkdwn","text":"*Queue*\nIT"},{"type":"mrkdwn","text":"*Priority*\nP1"}]},` +
+		`{"type":"context","elements":[{"type":"mrkdwn","text":"Opened by Ada"}]},` +
+		`{"type":"divider"},` +
+		`{"type":"actions","block_id":"ticket-42","elements":[{"type":"button","text":{"type":"plain_text","text":"Acknowledge"},"action_id":"ack","value":"42","style":"primary"}]}` +
+		`]`
+	if string(data) != expected {
+		t.Errorf("Unexpected blocks:\n%s\nExpected:\n%s", data, expected)
+	}
+}
+
+func TestBuilderInput(t *testing.T) {
+	bs := New().Input("title", "Title", views.NewPlainTextInput("title")).Blocks()
+	if in, ok := bs[0].(*views.InputBlock); !ok || in.BlockID != "title" || in.Label.Text != "Title" {
+		t.Errorf("Expected an input block, got %+v", bs[0])
+	}
+}
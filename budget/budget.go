@@ -0,0 +1,259 @@
+// Package budget counts the bot's Slack Web API calls against a budget for
+// each method, and warns an ops channel when a method nears its budget or is
+// rate limited. Calls are broken down by the feature which made them, so the
+// noisy component can be found.
+package budget
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nlopes/slack"
+
+	"github.com/skybet/go-helpdesk/clock"
+	"github.com/skybet/go-helpdesk/wrapper"
+)
+
+// module is the import path of this repository, the feature making a call is
+// the first package of it on the stack which is not the wrapper
+const module = "github.com/skybet/go-helpdesk/"
+
+// Budgets are the calls per minute allowed to each Web API method
+type Budgets map[string]int
+
+// Tiers are the budgets of the methods the helpdesk calls most, from the rate
+// limit tiers Slack documents. chat.postMessage is limited per channel,
+// about one message a second.
+var Tiers = Budgets{
+	"chat.postMessage":      60,
+	"chat.postEphemeral":    100,
+	"chat.update":           50,
+	"chat.getPermalink":     100,
+	"chat.scheduleMessage":  50,
+	"conversations.history": 50,
+	"conversations.info":    50,
+	"conversations.replies": 50,
+	"conversations.list":    20,
+	"users.info":            100,
+	"users.list":            20,
+	"usergroups.users.list": 20,
+	"reactions.add":         50,
+	"pins.add":              20,
+	"pins.remove":           20,
+	"files.upload":          20,
+	"views.open":            100,
+	"views.update":          100,
+	"views.push":            100,
+	"views.publish":         100,
+}
+
+// ParseBudgets parses budgets in the form <method>=<calls per minute> on top
+// of base
+func ParseBudgets(base Budgets, specs []string) (Budgets, error) {
+	budgets := Budgets{}
+	for m, n := range base {
+		budgets[m] = n
+	}
+	for _, spec := range specs {
+		parts := strings.SplitN(spec, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, fmt.Errorf("invalid API budget %q, expected <method>=<calls per minute>", spec)
+		}
+		n, err := strconv.Atoi(parts[1])
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("invalid calls per minute in API budget %q", spec)
+		}
+		budgets[parts[0]] = n
+	}
+	return budgets, nil
+}
+
+// Poster sends messages with chat.postMessage
+type Poster interface {
+	PostMessage(channelID string, options ...slack.MsgOption) (string, string, error)
+}
+
+// Usage is a method's calls in the last minute
+type Usage struct {
+	Method string `json:"method"`
+	Calls  int    `json:"calls"`
+	// Budget is zero for methods without one
+	Budget int `json:"budget,omitempty"`
+	// RateLimited is how many calls Slack refused with a 429
+	RateLimited int `json:"rate_limited,omitempty"`
+	// Features are the calls made by each feature, such as handlers/digest
+	// or notify
+	Features map[string]int `json:"features"`
+}
+
+// Tracker counts calls as a wrapper response hook. Every minute it warns
+// Channel of the methods whose calls reached Warn of their budget, or which
+// were rate limited, then starts counting afresh.
+type Tracker struct {
+	Budgets Budgets
+	// Warn is the fraction of a budget which is warned of, e.g. 0.8
+	Warn    float64
+	Poster  Poster
+	Channel string
+	// Quiet is how long a method is not warned of again after a warning
+	Quiet time.Duration
+	// Feature, if set, names the feature making a call instead of the
+	// caller's package
+	Feature func(c *wrapper.Call) string
+	// Clock, if set, replaces the wall clock
+	Clock clock.Clock
+
+	mu      sync.Mutex
+	usage   map[string]*Usage
+	warned  map[string]time.Time
+	lastMin []Usage
+}
+
+// Hook counts a finished call, pass it to wrapper.WithResponseHook. It must
+// be called on the goroutine making the call to find its feature.
+func (t *Tracker) Hook(c *wrapper.Call) {
+	feature := ""
+	if t.Feature != nil {
+		feature = t.Feature(c)
+	} else {
+		feature = caller()
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.usage == nil {
+		t.usage = map[string]*Usage{}
+	}
+	u, ok := t.usage[c.Method]
+	if !ok {
+		u = &Usage{Method: c.Method, Budget: t.Budgets[c.Method], Features: map[string]int{}}
+		t.usage[c.Method] = u
+	}
+	u.Calls++
+	u.Features[feature]++
+	if c.StatusCode == 429 {
+		u.RateLimited++
+	}
+}
+
+// caller returns the package of the first caller outside the wrapper and this
+// package, with the file for the handlers package as it holds every feature
+func caller() string {
+	pcs := make([]uintptr, 64)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(3, pcs)])
+	for {
+		f, more := frames.Next()
+		fn := f.Function
+		switch {
+		case strings.HasPrefix(fn, "main."):
+			return "main"
+		case !strings.HasPrefix(fn, module) || strings.Contains(fn, "/vendor/"):
+		case strings.HasPrefix(fn, module+"wrapper."), strings.HasPrefix(fn, module+"budget."):
+		case strings.HasPrefix(fn, module+"handlers."):
+			return "handlers/" + strings.TrimSuffix(path.Base(f.File), ".go")
+		default:
+			pkg := strings.TrimPrefix(fn, module)
+			return pkg[:strings.IndexByte(pkg, '.')]
+		}
+		if !more {
+			return "unknown"
+		}
+	}
+}
+
+// LastMinute returns the usage of each method in the last full minute, the
+// busiest first
+func (t *Tracker) LastMinute() []Usage {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]Usage(nil), t.lastMin...)
+}
+
+// Check ends the minute, warning of the methods over Warn of their budget or
+// rate limited in it
+func (t *Tracker) Check(now time.Time) error {
+	t.mu.Lock()
+	var usage []Usage
+	for _, u := range t.usage {
+		usage = append(usage, *u)
+	}
+	t.usage = nil
+	sort.Slice(usage, func(i, j int) bool {
+		if usage[i].Calls != usage[j].Calls {
+			return usage[i].Calls > usage[j].Calls
+		}
+		return usage[i].Method < usage[j].Method
+	})
+	t.lastMin = usage
+	if t.warned == nil {
+		t.warned = map[string]time.Time{}
+	}
+	var lines []string
+	for _, u := range usage {
+		near := u.Budget > 0 && float64(u.Calls) >= t.Warn*float64(u.Budget)
+		if !near && u.RateLimited == 0 {
+			continue
+		}
+		if at, ok := t.warned[u.Method]; ok && now.Sub(at) < t.Quiet {
+			continue
+		}
+		t.warned[u.Method] = now
+		lines = append(lines, u.warning())
+	}
+	t.mu.Unlock()
+
+	if len(lines) == 0 || t.Poster == nil || t.Channel == "" {
+		return nil
+	}
+	text := ":warning: *Slack API usage in the last minute*\n" + strings.Join(lines, "\n")
+	if _, _, err := t.Poster.PostMessage(t.Channel, slack.MsgOptionText(text, false)); err != nil {
+		return fmt.Errorf("error posting API usage warning: %s", err)
+	}
+	return nil
+}
+
+func (u Usage) warning() string {
+	line := fmt.Sprintf("`%s` %d calls", u.Method, u.Calls)
+	if u.Budget > 0 {
+		line += fmt.Sprintf(", %d%% of its budget of %d", u.Calls*100/u.Budget, u.Budget)
+	}
+	if u.RateLimited > 0 {
+		line += fmt.Sprintf(", rate limited %d times", u.RateLimited)
+	}
+	features := make([]string, 0, len(u.Features))
+	for f := range u.Features {
+		features = append(features, f)
+	}
+	sort.Slice(features, func(i, j int) bool {
+		if u.Features[features[i]] != u.Features[features[j]] {
+			return u.Features[features[i]] > u.Features[features[j]]
+		}
+		return features[i] < features[j]
+	})
+	for i, f := range features {
+		features[i] = fmt.Sprintf("%s %d", f, u.Features[f])
+	}
+	return line + ": " + strings.Join(features, ", ")
+}
+
+// Run checks the usage every minute until ctx is cancelled
+func (t *Tracker) Run(ctx context.Context, errorf func(string, ...interface{})) {
+	ticker := clock.Or(t.Clock).NewTicker(time.Minute)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C():
+			if err := t.Check(now); err != nil {
+				errorf("API budget check failed: %s", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package budget
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nlopes/slack"
+
+	"github.com/skybet/go-helpdesk/wrapper"
+)
+
+type fakePoster struct {
+	posted []string
+}
+
+func (f *fakePoster) PostMessage(channelID string, options ...slack.MsgOption) (string, string, error) {
+	_, values, _ := slack.UnsafeApplyMsgOptions("", channelID, "", options...)
+	f.posted = append(f.posted, values.Get("text"))
+	return channelID, "1.1", nil
+}
+
+func TestParseBudgets(t *testing.T) {
+	b, err := ParseBudgets(Tiers, []string{"chat.update=30", "team.info=5"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if b["chat.update"] != 30 || b["team.info"] != 5 || b["users.info"] != Tiers["users.info"] {
+		t.Errorf("Expected the budgets to override the tiers, got %v", b)
+	}
+	if Tiers["chat.update"] != 50 {
+		t.Errorf("Expected the tiers to be left alone, got %d", Tiers["chat.update"])
+	}
+	for _, spec := range []string{"chat.update", "chat.update=0", "=5"} {
+		if _, err := ParseBudgets(nil, []string{spec}); err == nil {
+			t.Errorf("Expected %q to be refused", spec)
+		}
+	}
+}
+
+func TestTracker(t *testing.T) {
+	poster := &fakePoster{}
+	feature := "handlers/digest"
+	tr := &Tracker{
+		Budgets: Budgets{"chat.update": 10, "users.info": 100},
+		Warn:    0.8,
+		Poster:  poster,
+		Channel: "COPS",
+		Quiet:   time.Hour,
+		Feature: func(c *wrapper.Call) string { return feature },
+	}
+	now := time.Date(2019, 3, 4, 9, 0, 0, 0, time.UTC)
+	calls := func(method string, n, status int) {
+		for i := 0; i < n; i++ {
+			tr.Hook(&wrapper.Call{Method: method, StatusCode: status})
+		}
+	}
+	calls("chat.update", 6, 200)
+	feature = "handlers/dashboard"
+	calls("chat.update", 2, 200)
+	calls("users.info", 10, 200)
+	calls("conversations.history", 1, 429)
+	if err := tr.Check(now); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if len(poster.posted) != 1 {
+		t.Fatalf("Expected one warning, got %v", poster.posted)
+	}
+	text := poster.posted[0]
+	for _, want := range []string{
+		"`chat.update` 8 calls, 80% of its budget of 10: handlers/digest 6, handlers/dashboard 2",
+		"`conversations.history` 1 calls, rate limited 1 times: handlers/dashboard 1",
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("Expected the warning to contain %q, got %s", want, text)
+		}
+	}
+	if strings.Contains(text, "users.info") {
+		t.Errorf("Expected methods within budget to be left out, got %s", text)
+	}
+	if usage := tr.LastMinute(); len(usage) != 3 || usage[0].Method != "users.info" || usage[0].Calls != 10 {
+		t.Errorf("Expected the last minute's usage busiest first, got %+v", usage)
+	}
+
+	// Methods are not warned of again while quiet
+	calls("chat.update", 9, 200)
+	if err := tr.Check(now.Add(time.Minute)); err != nil || len(poster.posted) != 1 {
+		t.Errorf("Expected no warning while quiet, got %v %v", poster.posted, err)
+	}
+	calls("chat.update", 9, 200)
+	if err := tr.Check(now.Add(2 * time.Hour)); err != nil || len(poster.posted) != 2 {
+		t.Errorf("Expected a warning after the quiet period, got %v %v", poster.posted, err)
+	}
+}
+
+func TestCaller(t *testing.T) {
+	tr := &Tracker{}
+	tr.Hook(&wrapper.Call{Method: "auth.test"})
+	tr.Check(time.Now())
+	// This package's frames are skipped, leaving the test's call unattributed
+	if usage := tr.LastMinute(); len(usage) != 1 || usage[0].Features["unknown"] != 1 {
+		t.Errorf("Expected the call to be counted as unknown, got %+v", usage)
+	}
+}
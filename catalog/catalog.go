@@ -0,0 +1,339 @@
+// Package catalog is the service catalog, the types of request users can
+// raise, such as an access request or a hardware order. Each item has the
+// form its requester fills in, the chain of approvals it needs, the
+// checklist agents work through to fulfil it and SLA targets of its own.
+package catalog
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/skybet/go-helpdesk/sla"
+	"github.com/skybet/go-helpdesk/ticket"
+)
+
+// The types of form field
+const (
+	Text      = "text"
+	Multiline = "multiline"
+	Select    = "select"
+	User      = "user"
+	Date      = "date"
+)
+
+var (
+	// ErrNotPending is returned when deciding a request which is not waiting
+	// for approval, because it has been approved or rejected already
+	ErrNotPending = errors.New("the request is not waiting for approval")
+	// ErrNotApprover is returned when deciding a request for a step the user
+	// can not approve
+	ErrNotApprover = errors.New("you can not approve this step of the request")
+)
+
+// Field is a question on an item's form
+type Field struct {
+	// Name identifies the answer, Label is the question
+	Name  string `json:"name"`
+	Label string `json:"label"`
+	// Type is one of Text, the default, Multiline, Select, User or Date
+	Type string `json:"type,omitempty"`
+	// Options are the choices of a Select
+	Options  []string `json:"options,omitempty"`
+	Hint     string   `json:"hint,omitempty"`
+	Optional bool     `json:"optional,omitempty"`
+}
+
+// Step is one approval in an item's chain, which any of its Approvers can
+// give
+type Step struct {
+	Name      string   `json:"name"`
+	Approvers []string `json:"approvers"`
+}
+
+// Access is a group an item's requests add the requester's account to once
+// they are approved
+type Access struct {
+	// System is the adapter which grants it, such as google, github or okta
+	System string `json:"system"`
+	// Group is the group's email address, the GitHub team's slug or the Okta
+	// group's ID
+	Group string `json:"group"`
+	// Member is the name of the field whose answer is the account to add,
+	// such as an email address or GitHub username
+	Member string `json:"member"`
+}
+
+// Item is a type of request in the catalog
+type Item struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	// Category groups items in the catalog, such as Access or Hardware
+	Category string `json:"category,omitempty"`
+	// Queue, Priority and Tags are set on the item's tickets, which are
+	// posted in Channel, or the catalog's channel if it is empty
+	Queue    string   `json:"queue"`
+	Priority string   `json:"priority,omitempty"`
+	Tags     []string `json:"tags,omitempty"`
+	Channel  string   `json:"channel,omitempty"`
+	Fields   []Field  `json:"fields"`
+	// Approvals are given in order, the checklist is only worked through
+	// once every one has been
+	Approvals []Step   `json:"approvals,omitempty"`
+	Checklist []string `json:"checklist,omitempty"`
+	// Access is granted once the request is approved, or taken away if the
+	// item is a Revoke
+	Access []Access `json:"access,omitempty"`
+	Revoke bool     `json:"revoke,omitempty"`
+	// Response and Resolution are the SLA targets of the item's queue in the
+	// form <priority>=<duration>
+	Response   []string `json:"response,omitempty"`
+	Resolution []string `json:"resolution,omitempty"`
+
+	priority ticket.Priority
+}
+
+// Catalog is every item users can request
+type Catalog struct {
+	// Channel is where the tickets of items without a channel of their own
+	// are posted
+	Channel string  `json:"channel"`
+	Items   []*Item `json:"items"`
+
+	byID  map[string]*Item
+	queue map[string]sla.SLA
+}
+
+// New checks the items of c and returns it ready to use
+func New(c *Catalog) (*Catalog, error) {
+	c.byID, c.queue = map[string]*Item{}, map[string]sla.SLA{}
+	for _, it := range c.Items {
+		if it.ID == "" || c.byID[it.ID] != nil {
+			return nil, fmt.Errorf("catalog item %q needs an ID of its own", it.Name)
+		}
+		if it.Name == "" || it.Queue == "" {
+			return nil, fmt.Errorf("catalog item %s needs a name and a queue", it.ID)
+		}
+		if it.Channel == "" && c.Channel == "" {
+			return nil, fmt.Errorf("catalog item %s has no channel to post its tickets in", it.ID)
+		}
+		if len(it.Fields) == 0 {
+			return nil, fmt.Errorf("catalog item %s has no fields", it.ID)
+		}
+		if it.Priority != "" {
+			p, err := ticket.ParsePriority(it.Priority)
+			if err != nil {
+				return nil, fmt.Errorf("catalog item %s: %s", it.ID, err)
+			}
+			it.priority = p
+		}
+		if err := it.checkFields(); err != nil {
+			return nil, err
+		}
+		for _, s := range it.Approvals {
+			if s.Name == "" || len(s.Approvers) == 0 {
+				return nil, fmt.Errorf("every approval of catalog item %s needs a name and approvers", it.ID)
+			}
+		}
+		if err := it.checkAccess(); err != nil {
+			return nil, err
+		}
+		if err := c.addTargets(it); err != nil {
+			return nil, err
+		}
+		c.byID[it.ID] = it
+	}
+	return c, nil
+}
+
+func (it *Item) checkFields() error {
+	seen := map[string]bool{}
+	for _, f := range it.Fields {
+		if f.Name == "" || f.Label == "" || seen[f.Name] {
+			return fmt.Errorf("every field of catalog item %s needs a label and a name of its own", it.ID)
+		}
+		seen[f.Name] = true
+		switch f.Type {
+		case "", Text, Multiline, User, Date:
+		case Select:
+			if len(f.Options) == 0 {
+				return fmt.Errorf("field %s of catalog item %s has no options", f.Name, it.ID)
+			}
+		default:
+			return fmt.Errorf("field %s of catalog item %s has unknown type %q", f.Name, it.ID, f.Type)
+		}
+	}
+	return nil
+}
+
+// checkAccess checks each access names a required field for the account and
+// that it is only granted with approval
+func (it *Item) checkAccess() error {
+	if len(it.Access) > 0 && len(it.Approvals) == 0 {
+		return fmt.Errorf("catalog item %s changes access so it needs an approval", it.ID)
+	}
+	for _, a := range it.Access {
+		if a.System == "" || a.Group == "" {
+			return fmt.Errorf("every access of catalog item %s needs a system and a group", it.ID)
+		}
+		found := false
+		for _, f := range it.Fields {
+			found = found || (f.Name == a.Member && !f.Optional)
+		}
+		if !found {
+			return fmt.Errorf("access to %s of catalog item %s needs the name of a required field for its member", a.Group, it.ID)
+		}
+	}
+	return nil
+}
+
+// addTargets records the SLA of an item's queue, which items sharing the
+// queue must agree on
+func (c *Catalog) addTargets(it *Item) error {
+	if len(it.Response) == 0 && len(it.Resolution) == 0 {
+		return nil
+	}
+	response, err := sla.ParseTargets(it.Response)
+	if err != nil {
+		return fmt.Errorf("catalog item %s: %s", it.ID, err)
+	}
+	resolution, err := sla.ParseTargets(it.Resolution)
+	if err != nil {
+		return fmt.Errorf("catalog item %s: %s", it.ID, err)
+	}
+	s := sla.SLA{Response: response, Resolution: resolution}
+	if other, ok := c.queue[it.Queue]; ok && (fmt.Sprint(other.Response) != fmt.Sprint(s.Response) || fmt.Sprint(other.Resolution) != fmt.Sprint(s.Resolution)) {
+		return fmt.Errorf("catalog item %s has different SLA targets to another item in queue %s", it.ID, it.Queue)
+	}
+	c.queue[it.Queue] = s
+	return nil
+}
+
+// Load reads the catalog from a JSON file, see New
+func Load(path string) (*Catalog, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading catalog: %s", err)
+	}
+	defer f.Close()
+	var c Catalog
+	if err := json.NewDecoder(f).Decode(&c); err != nil {
+		return nil, fmt.Errorf("error parsing catalog %s: %s", path, err)
+	}
+	return New(&c)
+}
+
+// Get returns an item by its ID, nil if there is none
+func (c *Catalog) Get(id string) *Item {
+	return c.byID[id]
+}
+
+// Categories returns the items grouped by category, in the order each
+// category first appears. Items without a category are grouped as Other.
+func (c *Catalog) Categories() (names []string, items map[string][]*Item) {
+	items = map[string][]*Item{}
+	for _, it := range c.Items {
+		cat := it.Category
+		if cat == "" {
+			cat = "Other"
+		}
+		if items[cat] == nil {
+			names = append(names, cat)
+		}
+		items[cat] = append(items[cat], it)
+	}
+	return names, items
+}
+
+// SLA returns base with the targets of the items' queues, which replace
+// those base has for them
+func (c *Catalog) SLA(base sla.SLA) sla.SLA {
+	queues := map[string]sla.SLA{}
+	for q, s := range base.Queues {
+		queues[q] = s
+	}
+	for q, s := range c.queue {
+		queues[q] = s
+	}
+	base.Queues = queues
+	return base
+}
+
+// ChannelOf returns the channel an item's tickets are posted in
+func (c *Catalog) ChannelOf(it *Item) string {
+	if it.Channel != "" {
+		return it.Channel
+	}
+	return c.Channel
+}
+
+// Apply sets the queue, priority and tags of the item on a ticket raised for
+// it with the answers given, in the order of the item's fields
+func (it *Item) Apply(t *ticket.Ticket, answers []ticket.Answer) {
+	t.Title = it.Name
+	t.Description = Describe(answers)
+	t.Queue = it.Queue
+	if it.priority != 0 {
+		t.Priority = it.priority
+	}
+	t.Tags = append(t.Tags, it.Tags...)
+	t.Request = ticket.Request{Item: it.ID, Answers: answers}
+	for _, c := range it.Checklist {
+		t.Request.Checklist = append(t.Request.Checklist, ticket.Check{Text: c})
+	}
+	for _, a := range it.Access {
+		g := ticket.Grant{System: a.System, Group: a.Group, Revoke: it.Revoke}
+		for _, ans := range answers {
+			if ans.Field == a.Member {
+				g.Member = ans.Value
+			}
+		}
+		t.Request.Grants = append(t.Request.Grants, g)
+	}
+}
+
+// Describe lists the answers to a form, leaving out those not given
+func Describe(answers []ticket.Answer) string {
+	var lines []string
+	for _, a := range answers {
+		if a.Value != "" {
+			lines = append(lines, a.Label+": "+a.Value)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Pending returns the step of the item's chain a request is waiting for,
+// false if it has been rejected or every step approved
+func (it *Item) Pending(r ticket.Request) (Step, bool) {
+	if r.Rejected() || len(r.Approvals) >= len(it.Approvals) {
+		return Step{}, false
+	}
+	return it.Approvals[len(r.Approvals)], true
+}
+
+// Approved reports whether every step of the item's chain has approved the
+// request
+func (it *Item) Approved(r ticket.Request) bool {
+	return !r.Rejected() && len(r.Approvals) >= len(it.Approvals)
+}
+
+// Decide records user approving, or rejecting, the step a request is waiting
+// for at now, returning the step
+func (it *Item) Decide(r *ticket.Request, user string, approve bool, now time.Time) (Step, error) {
+	step, ok := it.Pending(*r)
+	if !ok {
+		return Step{}, ErrNotPending
+	}
+	for _, a := range step.Approvers {
+		if a == user {
+			r.Approvals = append(r.Approvals, ticket.Approval{Step: step.Name, By: user, At: now, Rejected: !approve})
+			return step, nil
+		}
+	}
+	return Step{}, ErrNotApprover
+}
@@ -0,0 +1,151 @@
+package catalog
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/skybet/go-helpdesk/sla"
+	"github.com/skybet/go-helpdesk/ticket"
+)
+
+const catalogJSON = `{
+	"channel": "CREQUESTS",
+	"items": [
+		{"id": "laptop", "name": "New laptop", "category": "Hardware", "queue": "it-hardware", "priority": "P3", "tags": ["hardware"],
+		 "fields": [{"name": "model", "label": "Model", "type": "select", "options": ["13\"", "15\""]}, {"name": "why", "label": "Why", "type": "multiline", "optional": true}],
+		 "approvals": [{"name": "manager", "approvers": ["UMANAGER"]}, {"name": "finance", "approvers": ["UFIN1", "UFIN2"]}],
+		 "checklist": ["Order the laptop", "Image it"],
+		 "response": ["*=4h"], "resolution": ["*=72h"]},
+		{"id": "vpn", "name": "VPN access", "category": "Access", "queue": "it", "channel": "CACCESS",
+		 "fields": [{"name": "user", "label": "For", "type": "user"}]},
+		{"id": "desk", "name": "Desk move", "queue": "facilities", "fields": [{"name": "desk", "label": "Desk"}]}
+	]
+}`
+
+func load(t *testing.T, data string) (*Catalog, error) {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "catalog")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "catalog.json")
+	ioutil.WriteFile(path, []byte(data), 0600)
+	return Load(path)
+}
+
+func TestLoad(t *testing.T) {
+	c, err := load(t, catalogJSON)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if c.Get("laptop") == nil || c.Get("printer") != nil {
+		t.Errorf("Expected items to be found by ID")
+	}
+	if c.ChannelOf(c.Get("laptop")) != "CREQUESTS" || c.ChannelOf(c.Get("vpn")) != "CACCESS" {
+		t.Errorf("Expected items to be posted in their own channel or the catalog's")
+	}
+	names, items := c.Categories()
+	if strings.Join(names, ",") != "Hardware,Access,Other" || items["Other"][0].ID != "desk" {
+		t.Errorf("Expected items grouped by category in order, got %v %v", names, items)
+	}
+	levels := c.SLA(sla.SLA{Queues: map[string]sla.SLA{"hr": {}}}).Queues
+	if d, _ := levels["it-hardware"].Resolution.For(ticket.P3); d != 72*time.Hour {
+		t.Errorf("Expected the laptop queue's resolution target, got %s", d)
+	}
+	if _, ok := levels["hr"]; !ok {
+		t.Errorf("Expected the base queues to be kept, got %v", levels)
+	}
+	if _, ok := levels["it"]; ok {
+		t.Errorf("Expected items without targets to inherit them, got %v", levels)
+	}
+
+	for _, bad := range []string{
+		`{"items": [{"id": "a", "name": "A", "queue": "q", "fields": [{"name": "f", "label": "F"}]}]}`,
+		`{"channel": "C", "items": [{"id": "a", "name": "A", "queue": "q"}]}`,
+		`{"channel": "C", "items": [{"id": "a", "name": "A", "queue": "q", "fields": [{"name": "f", "label": "F", "type": "select"}]}]}`,
+		`{"channel": "C", "items": [{"id": "a", "name": "A", "queue": "q", "fields": [{"name": "f", "label": "F"}], "approvals": [{"name": "manager"}]}]}`,
+		`{"channel": "C", "items": [{"id": "a", "name": "A", "queue": "q", "fields": [{"name": "f", "label": "F"}], "response": ["*=1h"]}, {"id": "b", "name": "B", "queue": "q", "fields": [{"name": "f", "label": "F"}], "response": ["*=2h"]}]}`,
+	} {
+		if _, err := load(t, bad); err == nil {
+			t.Errorf("Expected an error loading %s", bad)
+		}
+	}
+}
+
+func TestApprovalChain(t *testing.T) {
+	c, err := load(t, catalogJSON)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	it := c.Get("laptop")
+	tk := &ticket.Ticket{}
+	it.Apply(tk, []ticket.Answer{{Field: "model", Label: "Model", Value: `13"`}, {Field: "why", Label: "Why"}})
+	if tk.Title != "New laptop" || tk.Description != `Model: 13"` || tk.Queue != "it-hardware" || tk.Priority != ticket.P3 || !tk.HasTag("hardware") || len(tk.Request.Checklist) != 2 {
+		t.Errorf("Expected the item to be applied, got %+v", tk)
+	}
+
+	now := time.Now()
+	if step, ok := it.Pending(tk.Request); !ok || step.Name != "manager" {
+		t.Errorf("Expected the manager to approve first, got %+v", step)
+	}
+	if _, err := it.Decide(&tk.Request, "UFIN1", true, now); err != ErrNotApprover {
+		t.Errorf("Expected finance not to approve the manager's step, got %v", err)
+	}
+	if _, err := it.Decide(&tk.Request, "UMANAGER", true, now); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if it.Approved(tk.Request) {
+		t.Errorf("Expected finance still to approve")
+	}
+	if step, err := it.Decide(&tk.Request, "UFIN2", true, now); err != nil || step.Name != "finance" {
+		t.Fatalf("Expected finance to approve, got %+v %v", step, err)
+	}
+	if !it.Approved(tk.Request) {
+		t.Errorf("Expected the request to be approved, got %+v", tk.Request)
+	}
+	if _, err := it.Decide(&tk.Request, "UFIN1", true, now); err != ErrNotPending {
+		t.Errorf("Expected an approved request not to need approval, got %v", err)
+	}
+
+	rejected := ticket.Request{}
+	it.Decide(&rejected, "UMANAGER", false, now)
+	if _, ok := it.Pending(rejected); ok || it.Approved(rejected) || !rejected.Rejected() {
+		t.Errorf("Expected a rejection to end the chain, got %+v", rejected)
+	}
+	if !c.Get("vpn").Approved(ticket.Request{}) {
+		t.Errorf("Expected an item without approvals to be approved straight away")
+	}
+}
+
+func TestAccess(t *testing.T) {
+	c, err := load(t, `{"channel": "C", "items": [
+		{"id": "repo", "name": "Repository access", "queue": "it", "revoke": true,
+		 "fields": [{"name": "login", "label": "GitHub username"}, {"name": "email", "label": "Email"}],
+		 "approvals": [{"name": "owner", "approvers": ["UOWNER"]}],
+		 "access": [{"system": "github", "group": "platform", "member": "login"}, {"system": "google", "group": "platform@example.com", "member": "email"}]}
+	]}`)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	tk := &ticket.Ticket{}
+	c.Get("repo").Apply(tk, []ticket.Answer{{Field: "login", Value: "octocat"}, {Field: "email", Value: "octo@example.com"}})
+	want := []ticket.Grant{{System: "github", Group: "platform", Member: "octocat", Revoke: true}, {System: "google", Group: "platform@example.com", Member: "octo@example.com", Revoke: true}}
+	if len(tk.Request.Grants) != 2 || tk.Request.Grants[0] != want[0] || tk.Request.Grants[1] != want[1] {
+		t.Errorf("Expected %+v, got %+v", want, tk.Request.Grants)
+	}
+
+	for _, bad := range []string{
+		`{"channel": "C", "items": [{"id": "a", "name": "A", "queue": "q", "fields": [{"name": "f", "label": "F"}], "access": [{"system": "okta", "group": "g", "member": "f"}]}]}`,
+		`{"channel": "C", "items": [{"id": "a", "name": "A", "queue": "q", "fields": [{"name": "f", "label": "F", "optional": true}], "approvals": [{"name": "m", "approvers": ["U"]}], "access": [{"system": "okta", "group": "g", "member": "f"}]}]}`,
+		`{"channel": "C", "items": [{"id": "a", "name": "A", "queue": "q", "fields": [{"name": "f", "label": "F"}], "approvals": [{"name": "m", "approvers": ["U"]}], "access": [{"system": "okta", "member": "f"}]}]}`,
+	} {
+		if _, err := load(t, bad); err == nil {
+			t.Errorf("Expected an error loading %s", bad)
+		}
+	}
+}
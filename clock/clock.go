@@ -0,0 +1,71 @@
+// Package clock abstracts reading the time and waiting for it, so that
+// features which depend on it, such as SLA timers, escalations and scheduled
+// reports, can be tested with a Fake clock instead of the wall clock
+package clock
+
+import (
+	"time"
+)
+
+// Clock tells the time and sets timers
+type Clock interface {
+	Now() time.Time
+	// NewTicker returns a ticker which sends the time every d
+	NewTicker(d time.Duration) Ticker
+	// NewTimer returns a timer which sends the time once after d
+	NewTimer(d time.Duration) Timer
+}
+
+// Ticker is a *time.Ticker of a Clock
+type Ticker interface {
+	C() <-chan time.Time
+	Stop()
+}
+
+// Timer is a *time.Timer of a Clock
+type Timer interface {
+	C() <-chan time.Time
+	Stop() bool
+}
+
+// Real is the wall clock
+var Real Clock = wall{}
+
+// Or returns c, or the wall clock if c is nil, so that a Clock field can be
+// left unset outside tests
+func Or(c Clock) Clock {
+	if c == nil {
+		return Real
+	}
+	return c
+}
+
+type wall struct{}
+
+func (wall) Now() time.Time {
+	return time.Now()
+}
+
+func (wall) NewTicker(d time.Duration) Ticker {
+	return realTicker{time.NewTicker(d)}
+}
+
+func (wall) NewTimer(d time.Duration) Timer {
+	return realTimer{time.NewTimer(d)}
+}
+
+type realTicker struct {
+	*time.Ticker
+}
+
+func (t realTicker) C() <-chan time.Time {
+	return t.Ticker.C
+}
+
+type realTimer struct {
+	*time.Timer
+}
+
+func (t realTimer) C() <-chan time.Time {
+	return t.Timer.C
+}
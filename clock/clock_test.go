@@ -0,0 +1,76 @@
+package clock
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFake(t *testing.T) {
+	start := time.Date(2026, 10, 14, 9, 0, 0, 0, time.UTC)
+	c := NewFake(start)
+	ticker := c.NewTicker(time.Minute)
+	timer := c.NewTimer(90 * time.Second)
+
+	c.Advance(30 * time.Second)
+	select {
+	case <-ticker.C():
+		t.Fatalf("Expected nothing to fire before its deadline")
+	case <-timer.C():
+		t.Fatalf("Expected nothing to fire before its deadline")
+	default:
+	}
+	c.Advance(time.Minute)
+	if tick := <-ticker.C(); !tick.Equal(start.Add(time.Minute)) {
+		t.Errorf("Expected a tick after a minute, got %s", tick)
+	}
+	if fired := <-timer.C(); !fired.Equal(start.Add(90 * time.Second)) {
+		t.Errorf("Expected the timer to fire after 90s, got %s", fired)
+	}
+	if now := c.Now(); !now.Equal(start.Add(90 * time.Second)) {
+		t.Errorf("Expected the clock to have moved 90s, got %s", now)
+	}
+
+	// Ticks which are not received are dropped, like a time.Ticker's
+	c.Advance(3 * time.Minute)
+	<-ticker.C()
+	select {
+	case <-ticker.C():
+		t.Errorf("Expected missed ticks to be dropped")
+	default:
+	}
+	if timer.Stop() {
+		t.Errorf("Expected a fired timer not to be stopped")
+	}
+	ticker.Stop()
+	c.Advance(time.Hour)
+	select {
+	case <-ticker.C():
+		t.Errorf("Expected a stopped ticker not to tick")
+	default:
+	}
+}
+
+func TestFakeBlockUntil(t *testing.T) {
+	c := NewFake(time.Now())
+	fired := make(chan time.Time)
+	go func() {
+		fired <- <-c.NewTimer(time.Second).C()
+	}()
+	c.BlockUntil(1)
+	c.Advance(time.Second)
+	select {
+	case <-fired:
+	case <-time.After(2 * time.Second):
+		t.Fatalf("Expected the timer set by the goroutine to fire")
+	}
+}
+
+func TestOr(t *testing.T) {
+	if Or(nil) != Real {
+		t.Errorf("Expected the wall clock when none is set")
+	}
+	c := NewFake(time.Now())
+	if Or(c) != c {
+		t.Errorf("Expected the clock set to be used")
+	}
+}
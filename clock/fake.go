@@ -0,0 +1,132 @@
+package clock
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Fake is a Clock which only moves when it is advanced. Timers and tickers
+// fire, in order, as Advance passes their deadlines, and Now reads the time
+// they fired at. Fake is safe for concurrent use.
+type Fake struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters []*fakeWaiter
+	// changed is closed and replaced whenever a timer or ticker is set
+	changed chan struct{}
+}
+
+// fakeWaiter is a timer, or a ticker when period is set
+type fakeWaiter struct {
+	c      chan time.Time
+	at     time.Time
+	period time.Duration
+	f      *Fake
+}
+
+// NewFake returns a Fake clock reading now
+func NewFake(now time.Time) *Fake {
+	return &Fake{now: now, changed: make(chan struct{})}
+}
+
+// Now returns the fake time
+func (f *Fake) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+// NewTicker returns a ticker which ticks every d of fake time
+func (f *Fake) NewTicker(d time.Duration) Ticker {
+	if d <= 0 {
+		panic("non-positive interval for NewTicker")
+	}
+	return fakeTicker{f.add(d, d)}
+}
+
+// NewTimer returns a timer which fires once d of fake time has passed
+func (f *Fake) NewTimer(d time.Duration) Timer {
+	return f.add(d, 0)
+}
+
+func (f *Fake) add(d, period time.Duration) *fakeWaiter {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	w := &fakeWaiter{c: make(chan time.Time, 1), at: f.now.Add(d), period: period, f: f}
+	f.waiters = append(f.waiters, w)
+	close(f.changed)
+	f.changed = make(chan struct{})
+	return w
+}
+
+// Advance moves the clock forward by d, firing every timer and ticker due on
+// the way. Like their real counterparts a tick is dropped if the last one has
+// not been received.
+func (f *Fake) Advance(d time.Duration) {
+	f.mu.Lock()
+	end := f.now.Add(d)
+	f.mu.Unlock()
+	for {
+		f.mu.Lock()
+		sort.SliceStable(f.waiters, func(i, j int) bool { return f.waiters[i].at.Before(f.waiters[j].at) })
+		if len(f.waiters) == 0 || f.waiters[0].at.After(end) {
+			f.now = end
+			f.mu.Unlock()
+			return
+		}
+		w := f.waiters[0]
+		f.now = w.at
+		if w.period > 0 {
+			w.at = w.at.Add(w.period)
+		} else {
+			f.waiters = f.waiters[1:]
+		}
+		now := f.now
+		f.mu.Unlock()
+		select {
+		case w.c <- now:
+		default:
+		}
+	}
+}
+
+// BlockUntil waits until at least n timers and tickers are waiting to fire,
+// so that a test can advance the clock knowing the code under test has set
+// its timers
+func (f *Fake) BlockUntil(n int) {
+	for {
+		f.mu.Lock()
+		waiting, changed := len(f.waiters), f.changed
+		f.mu.Unlock()
+		if waiting >= n {
+			return
+		}
+		<-changed
+	}
+}
+
+func (w *fakeWaiter) C() <-chan time.Time {
+	return w.c
+}
+
+// Stop stops the timer or ticker, reporting whether it had yet to fire
+func (w *fakeWaiter) Stop() bool {
+	w.f.mu.Lock()
+	defer w.f.mu.Unlock()
+	for i, other := range w.f.waiters {
+		if other == w {
+			w.f.waiters = append(w.f.waiters[:i], w.f.waiters[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+type fakeTicker struct {
+	*fakeWaiter
+}
+
+func (t fakeTicker) Stop() {
+	t.fakeWaiter.Stop()
+}
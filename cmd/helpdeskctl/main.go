@@ -0,0 +1,130 @@
+// package main is a command line tool for operating a go-helpdesk deployment
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+
+	"github.com/skybet/go-helpdesk/audit"
+	"github.com/skybet/go-helpdesk/doctor"
+	"github.com/skybet/go-helpdesk/store"
+	"github.com/skybet/go-helpdesk/wrapper"
+)
+
+const usage = `Usage: helpdeskctl doctor [flags]
+       helpdeskctl audit verify [--audit-log <file>]
+
+doctor checks the tokens, signing secret, store, channels and request URL the
+helpdesk is configured with, reading the same HELP_ environment variables as
+the server, and exits with status 1 if it finds problems.
+
+audit verify checks the hash chain of the audit log file, printing how many
+entries it has and the hash of the latest, and exits with status 1 if it has
+been altered.
+`
+
+func main() {
+	switch {
+	case len(os.Args) >= 2 && os.Args[1] == "doctor":
+		initFlags(os.Args[2:])
+		os.Exit(runDoctor())
+	case len(os.Args) >= 3 && os.Args[1] == "audit" && os.Args[2] == "verify":
+		initAuditFlags(os.Args[3:])
+		os.Exit(runAuditVerify())
+	}
+	fmt.Fprint(os.Stderr, usage)
+	os.Exit(2)
+}
+
+func runDoctor() int {
+	d := &doctor.Doctor{
+		SigningSecret:    viper.GetString("signing-secret"),
+		RequiredChannels: doctor.RequiredChannels(viper.GetViper()),
+		PublicURL:        viper.GetString("public-url"),
+	}
+	var fs []doctor.Finding
+	sw, err := wrapper.New(viper.GetString("app-token"), viper.GetString("bot-token"))
+	if err != nil {
+		fs = append(fs, doctor.Finding{Check: "tokens", Severity: doctor.Problem, Message: err.Error(), Fix: "check --app-token and --bot-token are the tokens of an app installed in the workspace"})
+	} else {
+		d.Slack, d.Channels = sw, sw.Directory
+	}
+	if path := viper.GetString("event-log"); path != "" {
+		events, err := store.OpenEventLog(path)
+		if err != nil {
+			fs = append(fs, doctor.Finding{Check: "store", Severity: doctor.Problem, Message: err.Error(), Fix: "check --event-log is readable and was written by this version or an earlier one"})
+		} else {
+			defer events.Close()
+			d.Store = events
+		}
+	}
+	fs = append(fs, d.SelfCheck(context.Background())...)
+	doctor.Print(os.Stdout, fs)
+	if n := doctor.Problems(fs); n > 0 {
+		fmt.Printf("\n%d problems found\n", n)
+		return 1
+	}
+	return 0
+}
+
+func runAuditVerify() int {
+	path := viper.GetString("audit-log")
+	if path == "" {
+		fmt.Fprintln(os.Stderr, "--audit-log is required")
+		return 2
+	}
+	entries, err := audit.ReadFile(path)
+	if err == nil {
+		err = audit.Verify(entries)
+	}
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	if len(entries) == 0 {
+		fmt.Println("The audit log is empty")
+		return 0
+	}
+	last := entries[len(entries)-1]
+	fmt.Printf("The audit log's %d entries are intact, the latest was recorded %s with hash %s\n", len(entries), last.At.UTC().Format(time.RFC3339), last.Hash)
+	return 0
+}
+
+func initFlags(args []string) {
+	flags := pflag.NewFlagSet("doctor", pflag.ExitOnError)
+	flags.StringP("app-token", "a", "", "Slack API token for your slash command")
+	flags.StringP("bot-token", "b", "", "Slack API token for bot integration")
+	flags.StringP("signing-secret", "s", "", "Slack API signing secret for request verification")
+	flags.String("event-log", "", "File the helpdesk keeps tickets in, the store is not checked if empty")
+	flags.String("public-url", "", "Request URL Slack sends callbacks to, sent a signed URL verification to check it reaches the helpdesk")
+	flags.StringSlice("announce-channels", nil, "IDs of the channels /hd announce posts to")
+	flags.StringSlice("support-channels", nil, "IDs of the channels watched for unanswered questions")
+	flags.StringSlice("trigger-channels", nil, "IDs of the channels where messages matching a trigger become tickets")
+	flags.String("digest-channel", "", "ID of the channel agents are sent the unanswered question digest in")
+	flags.String("vip-channel", "", "ID of the channel notified of tickets from VIP users")
+	flags.String("ops-channel", "", "ID of the channel alerted of spikes in new tickets")
+	flags.String("sla-channel", "", "ID of the channel warned of tickets about to miss an SLA target and alerted when they do")
+	flags.StringSlice("queue-channels", nil, "Channel of each queue in the form <queue>=<channel ID>")
+	bindFlags(flags, args)
+}
+
+func initAuditFlags(args []string) {
+	flags := pflag.NewFlagSet("audit", pflag.ExitOnError)
+	flags.String("audit-log", "", "File the helpdesk keeps the audit log in")
+	bindFlags(flags, args)
+}
+
+func bindFlags(flags *pflag.FlagSet, args []string) {
+	flags.Parse(args)
+	viper.BindPFlags(flags)
+	// The same environment variables as the server, e.g. HELP_APP_TOKEN
+	viper.SetEnvPrefix("help")
+	viper.AutomaticEnv()
+	viper.SetEnvKeyReplacer(strings.NewReplacer("-", "_"))
+}
@@ -0,0 +1,82 @@
+// package main is a command line load tester for a running go-helpdesk server
+package main
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/skybet/go-helpdesk/loadtest"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+func main() {
+	initFlags()
+	target := viper.GetString("target")
+	secret := viper.GetString("signing-secret")
+	if target == "" || secret == "" {
+		pflag.PrintDefaults()
+		return
+	}
+	var dnHeader *string
+	if h := viper.GetString("dn-header"); h != "" {
+		dnHeader = &h
+	}
+	c := loadtest.Config{
+		Target:   target,
+		Secret:   secret,
+		DNHeader: dnHeader,
+		Rates: map[loadtest.Kind]float64{
+			loadtest.KindCommand: viper.GetFloat64("command-rate"),
+			loadtest.KindAction:  viper.GetFloat64("action-rate"),
+			loadtest.KindEvent:   viper.GetFloat64("event-rate"),
+		},
+		Duration:    viper.GetDuration("duration"),
+		Concurrency: viper.GetInt("concurrency"),
+		Client:      &http.Client{Timeout: viper.GetDuration("timeout")},
+		Generator:   loadtest.NewGenerator(viper.GetString("command"), viper.GetString("callback-id"), viper.GetString("event-type")),
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	terminate := make(chan os.Signal, 1)
+	signal.Notify(terminate, syscall.SIGTERM, syscall.SIGINT)
+	go func() {
+		<-terminate
+		cancel()
+	}()
+
+	log.Infof("Sending load to '%s' for %s", target, c.Duration)
+	r, err := loadtest.Run(ctx, c)
+	if err != nil {
+		log.Fatalf("Load test failed: %s", err)
+	}
+	r.Print(os.Stdout)
+}
+
+func initFlags() {
+	pflag.StringP("target", "t", "", "URL of the go-helpdesk Slack endpoint, e.g. http://localhost:4390/slack (required)")
+	pflag.StringP("signing-secret", "s", "", "Slack API signing secret the server is configured with (required)")
+	pflag.String("dn-header", "", "Header to send a Slack mutual TLS DN in, if the server requires one")
+	pflag.Float64("command-rate", 10, "Slash commands to send per second")
+	pflag.Float64("action-rate", 0, "Block actions to send per second")
+	pflag.Float64("event-rate", 0, "Events API callbacks to send per second")
+	pflag.String("command", "/help-me", "Slash command to send")
+	pflag.String("callback-id", "HelpRequest", "Callback ID to send with block actions")
+	pflag.String("event-type", "app_mention", "Inner event type to send with event callbacks")
+	pflag.DurationP("duration", "d", 30*time.Second, "How long to send traffic for")
+	pflag.IntP("concurrency", "c", 10, "Number of concurrent workers sending requests")
+	pflag.Duration("timeout", 10*time.Second, "Timeout for each request")
+	pflag.Parse()
+	viper.BindPFlags(pflag.CommandLine)
+	// Example: LOADTEST_SIGNING_SECRET will set the signing-secret flag
+	viper.SetEnvPrefix("loadtest")
+	viper.AutomaticEnv()
+	viper.SetEnvKeyReplacer(strings.NewReplacer("-", "_"))
+}
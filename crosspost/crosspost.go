@@ -0,0 +1,186 @@
+// Package crosspost mirrors the card of a ticket which spans teams into the
+// channel of every queue working on it, and resolves the ticket once they have
+// all finished their part
+package crosspost
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nlopes/slack"
+
+	"github.com/skybet/go-helpdesk/archive"
+	"github.com/skybet/go-helpdesk/outbox"
+	"github.com/skybet/go-helpdesk/store"
+	"github.com/skybet/go-helpdesk/ticket"
+)
+
+// DoneActionID is the action ID of the buttons marking a queue's part of a
+// ticket done, their value is the ticket's Ref and queue separated by a colon
+const DoneActionID = "crosspost_done"
+
+// Slack is the part of the Slack API used to post and update mirrors
+type Slack interface {
+	PostMessage(channelID string, options ...slack.MsgOption) (string, string, error)
+	UpdateMessage(channelID, timestamp string, options ...slack.MsgOption) (string, string, string, error)
+}
+
+// Mirror posts and updates the copies of shared tickets' cards
+type Mirror struct {
+	Store store.Store
+	Slack Slack
+	// Channels are the channel IDs of each queue
+	Channels map[string]string
+	// Card renders the copy of a ticket for one of its shares
+	Card func(t *ticket.Ticket, s ticket.Share) []slack.MsgOption
+
+	mu sync.RWMutex
+}
+
+// SetChannel sets the channel of a queue, such as one which has just been
+// provisioned, while tickets are being shared
+func (m *Mirror) SetChannel(queue, channelID string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.Channels == nil {
+		m.Channels = map[string]string{}
+	}
+	m.Channels[queue] = channelID
+}
+
+func (m *Mirror) channel(queue string) string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.Channels[queue]
+}
+
+// Share adds queues to a ticket, posting its card in their channels. The
+// ticket's own queue is always one of the shares, so it has to finish its part
+// too. Every queue must have a channel.
+func (m *Mirror) Share(ctx context.Context, id string, queues ...string) (*ticket.Ticket, error) {
+	var shared *ticket.Ticket
+	err := m.Store.Tx(ctx, func(tx store.Store) error {
+		t, err := tx.GetTicket(ctx, id)
+		if err != nil {
+			return err
+		}
+		if !t.Status.Open() {
+			return fmt.Errorf("ticket %s is %s", t.ID, t.Status)
+		}
+		added := false
+		for _, q := range append([]string{t.Queue}, queues...) {
+			if _, ok := shareOf(t, q); ok {
+				continue
+			}
+			ch := m.channel(q)
+			if ch == "" {
+				return fmt.Errorf("queue %q has no channel", q)
+			}
+			t.Shares = append(t.Shares, ticket.Share{Queue: q, ChannelID: ch})
+			added = true
+		}
+		shared = t
+		if !added {
+			return nil
+		}
+		// Post once every queue is known to be valid, so that the cards list
+		// all of them
+		for i := range t.Shares {
+			s := &t.Shares[i]
+			if s.TS != "" {
+				continue
+			}
+			if _, s.TS, err = m.Slack.PostMessage(s.ChannelID, m.Card(t, *s)...); err != nil {
+				return fmt.Errorf("error posting to %s: %s", s.Queue, err)
+			}
+		}
+		return tx.UpdateTicket(ctx, t)
+	})
+	if err != nil {
+		return nil, err
+	}
+	// The cards posted before this one was added need to list it
+	return shared, m.Sync(shared)
+}
+
+// Done marks a queue's part of a ticket done by user at now. Once every queue
+// is done the ticket is resolved, which is reported by resolved. The progress
+// is posted in the ticket's thread through the outbox. Version is
+// the version of the ticket the user saw, store.ErrStale is returned if it has
+// changed since, zero skips the check.
+func (m *Mirror) Done(ctx context.Context, id, queue, user string, version int, now time.Time) (t *ticket.Ticket, resolved bool, err error) {
+	err = m.Store.Tx(ctx, func(tx store.Store) error {
+		t, err = tx.GetTicket(ctx, id)
+		if err != nil {
+			return err
+		}
+		if version != 0 && t.Version != version {
+			return store.ErrStale
+		}
+		i, ok := shareOf(t, queue)
+		if !ok {
+			return fmt.Errorf("ticket %s is not shared with %s", id, queue)
+		}
+		if t.Shares[i].Done() {
+			return nil
+		}
+		t.Shares[i].DoneBy, t.Shares[i].DoneAt = user, now
+		text := fmt.Sprintf("<@%s> finished the %s part of this ticket, %d of %d queues are done", user, queue, len(t.Shares)-Remaining(t), len(t.Shares))
+		if Remaining(t) == 0 && t.Status.Open() {
+			t.SetStatus(ticket.StatusResolved, now)
+			resolved = true
+			text = fmt.Sprintf("<@%s> finished the %s part of this ticket, every queue is done so it has been resolved", user, queue)
+		}
+		if err := tx.UpdateTicket(ctx, t); err != nil {
+			return err
+		}
+		if t.ChannelID == "" || archive.Locked(t) {
+			return nil
+		}
+		return tx.Enqueue(ctx, outbox.Thread(t, text))
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	return t, resolved, m.Sync(t)
+}
+
+// Sync updates every copy of a ticket's card to match the ticket
+func (m *Mirror) Sync(t *ticket.Ticket) error {
+	var errs []string
+	for _, s := range t.Shares {
+		if s.TS == "" {
+			continue
+		}
+		if _, _, _, err := m.Slack.UpdateMessage(s.ChannelID, s.TS, m.Card(t, s)...); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %s", s.Queue, err))
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("error updating copies of ticket %s: %s", t.ID, strings.Join(errs, ", "))
+	}
+	return nil
+}
+
+// Remaining returns how many queues have still to finish their part
+func Remaining(t *ticket.Ticket) int {
+	n := 0
+	for _, s := range t.Shares {
+		if !s.Done() {
+			n++
+		}
+	}
+	return n
+}
+
+func shareOf(t *ticket.Ticket, queue string) (int, bool) {
+	for i, s := range t.Shares {
+		if s.Queue == queue {
+			return i, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,93 @@
+package crosspost
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/nlopes/slack"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/skybet/go-helpdesk/mocks"
+	"github.com/skybet/go-helpdesk/store"
+	"github.com/skybet/go-helpdesk/ticket"
+)
+
+func newMirror(s store.Store, sw *mocks.SlackWrapper) *Mirror {
+	return &Mirror{
+		Store:    s,
+		Slack:    sw,
+		Channels: map[string]string{"it": "CIT", "hr": "CHR"},
+		Card: func(t *ticket.Ticket, s ticket.Share) []slack.MsgOption {
+			return []slack.MsgOption{slack.MsgOptionText(t.Title, false)}
+		},
+	}
+}
+
+func TestShare(t *testing.T) {
+	s := store.NewMemory()
+	s.CreateTicket(context.Background(), &ticket.Ticket{ID: "1", Queue: "it", Title: "New starter"})
+	mockSlack := &mocks.SlackWrapper{}
+	mockSlack.On("PostMessage", "CIT", mock.Anything).Return("CIT", "1.1", nil).Once()
+	mockSlack.On("PostMessage", "CHR", mock.Anything).Return("CHR", "2.1", nil).Once()
+	mockSlack.On("UpdateMessage", mock.Anything, mock.Anything, mock.Anything).Return("", "", "", nil)
+	m := newMirror(s, mockSlack)
+
+	tk, err := m.Share(context.Background(), "1", "hr")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if len(tk.Shares) != 2 || tk.Shares[0] != (ticket.Share{Queue: "it", ChannelID: "CIT", TS: "1.1"}) || tk.Shares[1].TS != "2.1" {
+		t.Errorf("Expected the ticket to be shared between its own queue and hr, got %+v", tk.Shares)
+	}
+	// Sharing again does not post more copies
+	if _, err := m.Share(context.Background(), "1", "hr"); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if _, err := m.Share(context.Background(), "1", "legal"); err == nil {
+		t.Errorf("Expected queues without a channel to be refused")
+	}
+	if stored, _ := s.GetTicket(context.Background(), "1"); len(stored.Shares) != 2 {
+		t.Errorf("Expected a failed share to change nothing, got %+v", stored.Shares)
+	}
+	// Queues given a channel later can be shared with
+	mockSlack.On("PostMessage", "CLEGAL", mock.Anything).Return("CLEGAL", "3.1", nil).Once()
+	m.SetChannel("legal", "CLEGAL")
+	if tk, err := m.Share(context.Background(), "1", "legal"); err != nil || len(tk.Shares) != 3 {
+		t.Errorf("Expected the ticket to be shared with legal once it has a channel, got %v", err)
+	}
+	mockSlack.AssertExpectations(t)
+}
+
+func TestDone(t *testing.T) {
+	s := store.NewMemory()
+	s.CreateTicket(context.Background(), &ticket.Ticket{ID: "1", Queue: "it", ChannelID: "C1", ThreadTS: "0.1", Shares: []ticket.Share{
+		{Queue: "it", ChannelID: "CIT", TS: "1.1"},
+		{Queue: "hr", ChannelID: "CHR", TS: "2.1"},
+	}})
+	mockSlack := &mocks.SlackWrapper{}
+	mockSlack.On("UpdateMessage", "CIT", "1.1", mock.Anything).Return("", "", "", nil)
+	mockSlack.On("UpdateMessage", "CHR", "2.1", mock.Anything).Return("", "", "", nil)
+	m := newMirror(s, mockSlack)
+	now := time.Now()
+
+	tk, resolved, err := m.Done(context.Background(), "1", "hr", "U1", 1, now)
+	if err != nil || resolved || Remaining(tk) != 1 || tk.Status != ticket.StatusNew {
+		t.Fatalf("Expected the ticket to stay open until every queue is done, got %v %v %+v", resolved, err, tk)
+	}
+	mockSlack.AssertNumberOfCalls(t, "UpdateMessage", 2)
+	if _, _, err := m.Done(context.Background(), "1", "it", "U2", 1, now); err != store.ErrStale {
+		t.Errorf("Expected acting on an out of date card to return ErrStale, got %v", err)
+	}
+	tk, resolved, err = m.Done(context.Background(), "1", "it", "U2", tk.Version, now)
+	if err != nil || !resolved || tk.Status != ticket.StatusResolved || tk.Shares[0].DoneBy != "U2" {
+		t.Errorf("Expected the ticket to be resolved, got %v %v %+v", resolved, err, tk)
+	}
+	if _, _, err := m.Done(context.Background(), "1", "legal", "U2", 0, now); err == nil {
+		t.Errorf("Expected queues the ticket is not shared with to be refused")
+	}
+	pending, _ := s.Outbox(context.Background(), 0)
+	if len(pending) != 2 || pending[0].Text != "<@U1> finished the hr part of this ticket, 1 of 2 queues are done" || pending[1].ThreadTS != "0.1" {
+		t.Errorf("Expected the progress of each queue in the outbox, got %+v", pending)
+	}
+}
@@ -0,0 +1,113 @@
+// Package diagnostics serves pprof profiles, goroutine dumps and the
+// helpdesk's internal stats, such as queue depths and cache hit rates, for
+// diagnosing performance issues in production. It is meant for a separate
+// listener which is not exposed to Slack, and every request must be
+// authenticated.
+package diagnostics
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	runtimepprof "runtime/pprof"
+	"sort"
+	"time"
+)
+
+// Source reports one part of the internal stats, such as the depth of a queue
+type Source func(ctx context.Context) (interface{}, error)
+
+// Handler serves the diagnostics endpoints
+type Handler struct {
+	token   string
+	sources map[string]Source
+	started time.Time
+	mux     *http.ServeMux
+}
+
+// NewHandler returns a Handler which reports sources, keyed by name, in its
+// stats. Requests must carry the token as a bearer token or as the password of
+// basic auth, which go tool pprof sends for URLs such as
+// http://:token@host/debug/pprof/heap.
+func NewHandler(token string, sources map[string]Source) *Handler {
+	h := &Handler{token: token, sources: sources, started: time.Now(), mux: http.NewServeMux()}
+	h.mux.HandleFunc("/debug/pprof/", pprof.Index)
+	h.mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	h.mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	h.mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	h.mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	h.mux.HandleFunc("/debug/goroutines", h.goroutines)
+	h.mux.HandleFunc("/debug/stats", h.stats)
+	return h
+}
+
+// ServeHTTP satisfies http.Handler
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !h.authorized(r) {
+		w.Header().Set("WWW-Authenticate", `Basic realm="diagnostics"`)
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	h.mux.ServeHTTP(w, r)
+}
+
+func (h *Handler) authorized(r *http.Request) bool {
+	if h.token == "" {
+		return false
+	}
+	got := r.Header.Get("Authorization")
+	if _, password, ok := r.BasicAuth(); ok {
+		got = "Bearer " + password
+	}
+	return subtle.ConstantTimeCompare([]byte(got), []byte("Bearer "+h.token)) == 1
+}
+
+// goroutines dumps the stack of every goroutine
+func (h *Handler) goroutines(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	runtimepprof.Lookup("goroutine").WriteTo(w, 2)
+}
+
+// runtimeJSON is the Go runtime's view of the process in the stats
+type runtimeJSON struct {
+	Uptime     string `json:"uptime"`
+	Goroutines int    `json:"goroutines"`
+	HeapAlloc  uint64 `json:"heap_alloc_bytes"`
+	HeapInuse  uint64 `json:"heap_inuse_bytes"`
+	Sys        uint64 `json:"sys_bytes"`
+	NumGC      uint32 `json:"num_gc"`
+}
+
+// stats reports the runtime and every source, a source which fails reports
+// its error instead
+func (h *Handler) stats(w http.ResponseWriter, r *http.Request) {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	body := map[string]interface{}{
+		"runtime": runtimeJSON{
+			Uptime:     time.Since(h.started).Round(time.Second).String(),
+			Goroutines: runtime.NumGoroutine(),
+			HeapAlloc:  m.HeapAlloc,
+			HeapInuse:  m.HeapInuse,
+			Sys:        m.Sys,
+			NumGC:      m.NumGC,
+		},
+	}
+	names := make([]string, 0, len(h.sources))
+	for name := range h.sources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		v, err := h.sources[name](r.Context())
+		if err != nil {
+			v = map[string]string{"error": err.Error()}
+		}
+		body[name] = v
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,60 @@
+package diagnostics
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHandler(t *testing.T) {
+	h := NewHandler("secret", map[string]Source{
+		"outbox": func(ctx context.Context) (interface{}, error) { return 3, nil },
+		"broken": func(ctx context.Context) (interface{}, error) { return nil, fmt.Errorf("store down") },
+	})
+	serve := func(path string, auth func(r *http.Request)) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("GET", path, nil)
+		if auth != nil {
+			auth(r)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+	bearer := func(r *http.Request) { r.Header.Set("Authorization", "Bearer secret") }
+
+	for _, auth := range []func(r *http.Request){nil, func(r *http.Request) { r.Header.Set("Authorization", "Bearer wrong") }, func(r *http.Request) { r.SetBasicAuth("", "wrong") }} {
+		if w := serve("/debug/stats", auth); w.Code != http.StatusUnauthorized {
+			t.Errorf("Expected unauthenticated requests to be refused, got %d", w.Code)
+		}
+	}
+
+	w := serve("/debug/stats", bearer)
+	var body struct {
+		Runtime runtimeJSON       `json:"runtime"`
+		Outbox  int               `json:"outbox"`
+		Broken  map[string]string `json:"broken"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("Unexpected error: %s, body %s", err, w.Body)
+	}
+	if body.Runtime.Goroutines == 0 || body.Outbox != 3 || body.Broken["error"] != "store down" {
+		t.Errorf("Unexpected stats: %s", w.Body)
+	}
+
+	if w := serve("/debug/goroutines", func(r *http.Request) { r.SetBasicAuth("", "secret") }); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "goroutine") {
+		t.Errorf("Expected a goroutine dump with basic auth, got %d", w.Code)
+	}
+	if w := serve("/debug/pprof/", bearer); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "heap") {
+		t.Errorf("Expected the pprof index, got %d", w.Code)
+	}
+	if w := serve("/debug/stats", func(r *http.Request) {}); w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected requests without credentials to be refused, got %d", w.Code)
+	}
+	if w := NewHandler("", nil); w.authorized(httptest.NewRequest("GET", "/debug/stats", nil)) {
+		t.Errorf("Expected an empty token to refuse everything")
+	}
+}
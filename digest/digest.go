@@ -0,0 +1,186 @@
+// Package digest watches support channels for questions nobody has answered
+// and compiles them into a digest for agents
+package digest
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nlopes/slack"
+	"github.com/nlopes/slack/slackevents"
+
+	"github.com/skybet/go-helpdesk/clock"
+)
+
+// ConvertActionID is the action ID of the "convert to ticket" buttons, their
+// value is the channel and timestamp of the question separated by a colon
+const ConvertActionID = "digest_convert"
+
+var questionWords = regexp.MustCompile(`(?i)^(who|what|when|where|why|how|which|is|are|can|could|does|do|has|have|should|will|would|any ?(one|body))\b`)
+
+// IsQuestion reports whether text looks like a question
+func IsQuestion(text string) bool {
+	text = strings.TrimSpace(text)
+	return strings.Contains(text, "?") || questionWords.MatchString(text)
+}
+
+// Question is an unanswered message in a watched channel
+type Question struct {
+	Channel string
+	TS      string
+	User    string
+	Text    string
+	Asked   time.Time
+}
+
+// Poster is the part of the Slack API used to post digests
+type Poster interface {
+	PostMessage(channelID string, options ...slack.MsgOption) (string, string, error)
+}
+
+// Tracker remembers questions asked in its channels until someone other than
+// the asker replies in their thread
+type Tracker struct {
+	// MaxAge is how long a question may go unanswered before it is included
+	// in the digest
+	MaxAge time.Duration
+	// Clock, if set, replaces the wall clock
+	Clock clock.Clock
+
+	mu        sync.Mutex
+	channels  map[string]bool
+	questions map[string]Question
+}
+
+// NewTracker returns a Tracker watching channels. Questions are included in
+// the digest once they have gone maxAge without an answer.
+func NewTracker(channels []string, maxAge time.Duration) *Tracker {
+	t := &Tracker{
+		MaxAge:    maxAge,
+		channels:  map[string]bool{},
+		questions: map[string]Question{},
+	}
+	for _, c := range channels {
+		t.channels[c] = true
+	}
+	return t
+}
+
+// Observe records a message event, tracking new questions and forgetting those
+// which have been answered
+func (t *Tracker) Observe(ev *slackevents.MessageEvent) {
+	if !t.channels[ev.Channel] || ev.BotID != "" || (ev.SubType != "" && ev.SubType != "thread_broadcast") {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if ev.ThreadTimeStamp != "" && ev.ThreadTimeStamp != ev.TimeStamp {
+		key := ev.Channel + ":" + ev.ThreadTimeStamp
+		if q, ok := t.questions[key]; ok && q.User != ev.User {
+			delete(t.questions, key)
+		}
+		return
+	}
+	if IsQuestion(ev.Text) {
+		t.questions[ev.Channel+":"+ev.TimeStamp] = Question{
+			Channel: ev.Channel,
+			TS:      ev.TimeStamp,
+			User:    ev.User,
+			Text:    ev.Text,
+			Asked:   parseTS(ev.TimeStamp, clock.Or(t.Clock).Now()),
+		}
+	}
+}
+
+// Resolve stops tracking a question, e.g. once it has become a ticket
+func (t *Tracker) Resolve(channel, ts string) (Question, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	q, ok := t.questions[channel+":"+ts]
+	delete(t.questions, channel+":"+ts)
+	return q, ok
+}
+
+// Unanswered returns the questions older than MaxAge, oldest first
+func (t *Tracker) Unanswered() []Question {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	cutoff := clock.Or(t.Clock).Now().Add(-t.MaxAge)
+	var qs []Question
+	for _, q := range t.questions {
+		if q.Asked.Before(cutoff) {
+			qs = append(qs, q)
+		}
+	}
+	sort.Slice(qs, func(i, j int) bool { return qs[i].Asked.Before(qs[j].Asked) })
+	return qs
+}
+
+// Blocks renders questions as a digest message
+func Blocks(qs []Question) []slack.Block {
+	header := fmt.Sprintf("*%d unanswered questions*", len(qs))
+	if len(qs) == 1 {
+		header = "*1 unanswered question*"
+	}
+	blocks := []slack.Block{
+		slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType, header, false, false), nil, nil),
+	}
+	for _, q := range qs {
+		text := fmt.Sprintf("<@%s> in <#%s>: %s", q.User, q.Channel, truncate(q.Text, 200))
+		button := slack.NewButtonBlockElement(ConvertActionID, q.Channel+":"+q.TS, slack.NewTextBlockObject(slack.PlainTextType, "Convert to ticket", false, false))
+		blocks = append(blocks, slack.NewDividerBlock(), slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType, text, false, false), nil, slack.NewAccessory(button)))
+	}
+	return blocks
+}
+
+// Run posts a digest of the unanswered questions to channel every interval
+// until ctx is cancelled. Nothing is posted when there are no questions.
+func (t *Tracker) Run(ctx context.Context, p Poster, channel string, interval time.Duration) {
+	tick := clock.Or(t.Clock).NewTicker(interval)
+	defer tick.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-tick.C():
+			t.Post(p, channel)
+		}
+	}
+}
+
+// Post sends a single digest to channel
+func (t *Tracker) Post(p Poster, channel string) error {
+	qs := t.Unanswered()
+	if len(qs) == 0 {
+		return nil
+	}
+	_, _, err := p.PostMessage(channel, slack.MsgOptionBlocks(Blocks(qs)...), slack.MsgOptionText(fmt.Sprintf("%d unanswered questions", len(qs)), false))
+	if err != nil {
+		return fmt.Errorf("error posting digest: %s", err)
+	}
+	return nil
+}
+
+// parseTS converts a Slack message timestamp to a time, def is returned if
+// it is invalid
+func parseTS(ts string, def time.Time) time.Time {
+	f, err := strconv.ParseFloat(ts, 64)
+	if err != nil {
+		return def
+	}
+	return time.Unix(0, int64(f*float64(time.Second)))
+}
+
+func truncate(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n-1]) + "…"
+}
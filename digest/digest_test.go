@@ -0,0 +1,76 @@
+package digest
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/nlopes/slack"
+	"github.com/nlopes/slack/slackevents"
+
+	"github.com/skybet/go-helpdesk/clock"
+)
+
+func TestIsQuestion(t *testing.T) {
+	tt := map[string]bool{
+		"How do I reset my password":   true,
+		"my laptop won't boot?":        true,
+		"anyone seen the VPN go down":  true,
+		"Thanks, that fixed it":        false,
+		"Is the build broken again":    true,
+		"Issue resolved after restart": false,
+	}
+	for text, want := range tt {
+		if got := IsQuestion(text); got != want {
+			t.Errorf("IsQuestion(%q) = %v, want %v", text, got, want)
+		}
+	}
+}
+
+func TestTracker(t *testing.T) {
+	now := time.Unix(1000000, 0)
+	tr := NewTracker([]string{"C1"}, time.Hour)
+	tr.Clock = clock.NewFake(now)
+	ts := func(d time.Duration) string { return slackTS(now.Add(d)) }
+
+	tr.Observe(&slackevents.MessageEvent{Channel: "C1", User: "U1", Text: "How do I get VPN access?", TimeStamp: ts(-2 * time.Hour)})
+	tr.Observe(&slackevents.MessageEvent{Channel: "C1", User: "U2", Text: "What is the wifi password?", TimeStamp: ts(-3 * time.Hour)})
+	tr.Observe(&slackevents.MessageEvent{Channel: "C1", User: "U3", Text: "Who broke prod?", TimeStamp: ts(-time.Minute)})
+	tr.Observe(&slackevents.MessageEvent{Channel: "C2", User: "U4", Text: "Unwatched?", TimeStamp: ts(-3 * time.Hour)})
+	tr.Observe(&slackevents.MessageEvent{Channel: "C1", User: "U5", Text: "Morning all", TimeStamp: ts(-3 * time.Hour)})
+	// The asker bumping their own question does not answer it
+	tr.Observe(&slackevents.MessageEvent{Channel: "C1", User: "U1", Text: "bump", TimeStamp: ts(0), ThreadTimeStamp: ts(-2 * time.Hour)})
+
+	qs := tr.Unanswered()
+	if len(qs) != 2 || qs[0].User != "U2" || qs[1].User != "U1" {
+		t.Fatalf("Expected the two old questions oldest first, got %+v", qs)
+	}
+
+	tr.Observe(&slackevents.MessageEvent{Channel: "C1", User: "U9", Text: "See the wiki", TimeStamp: ts(0), ThreadTimeStamp: ts(-3 * time.Hour)})
+	if qs := tr.Unanswered(); len(qs) != 1 || qs[0].User != "U1" {
+		t.Fatalf("Expected the answered question to be dropped, got %+v", qs)
+	}
+
+	if _, ok := tr.Resolve("C1", ts(-2*time.Hour)); !ok {
+		t.Errorf("Expected to resolve the question")
+	}
+	if qs := tr.Unanswered(); len(qs) != 0 {
+		t.Errorf("Expected no questions, got %+v", qs)
+	}
+}
+
+func TestBlocks(t *testing.T) {
+	blocks := Blocks([]Question{{Channel: "C1", TS: "1.2", User: "U1", Text: "How?"}})
+	if len(blocks) != 3 {
+		t.Fatalf("Expected a header, divider and question, got %d blocks", len(blocks))
+	}
+	section := blocks[2].(*slack.SectionBlock)
+	button := section.Accessory.ButtonElement
+	if button == nil || button.ActionID != ConvertActionID || button.Value != "C1:1.2" {
+		t.Errorf("Unexpected convert button: %+v", section.Accessory)
+	}
+}
+
+func slackTS(t time.Time) string {
+	return strconv.FormatInt(t.Unix(), 10) + ".000100"
+}
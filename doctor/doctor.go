@@ -0,0 +1,291 @@
+// Package doctor checks a helpdesk's configuration against Slack and its store
+// before it goes live, so that a missing scope or a typo in a channel ID is
+// reported with how to fix it rather than found by the first user
+package doctor
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/nlopes/slack"
+
+	"github.com/skybet/go-helpdesk/loadtest"
+	"github.com/skybet/go-helpdesk/store"
+)
+
+// DefaultAppScopes and DefaultBotScopes are the scopes the helpdesk needs each
+// token to have
+var (
+	DefaultAppScopes = []string{"commands"}
+	DefaultBotScopes = []string{"chat:write", "reactions:write", "pins:write", "files:write", "users:read", "channels:read", "im:write"}
+)
+
+// Severity is how much a finding matters
+type Severity int
+
+// The severities of findings, problems will stop the helpdesk working
+const (
+	OK Severity = iota
+	Warning
+	Problem
+)
+
+func (s Severity) String() string {
+	switch s {
+	case OK:
+		return "ok"
+	case Warning:
+		return "warning"
+	default:
+		return "problem"
+	}
+}
+
+// Finding is the result of one check
+type Finding struct {
+	Check    string
+	Severity Severity
+	Message  string
+	// Fix is what to do about a warning or problem
+	Fix string
+}
+
+// Slack is the part of the Slack API used to check the tokens
+type Slack interface {
+	Scopes() (app, bot []string, err error)
+}
+
+// Channels looks up the channels the helpdesk is configured with
+type Channels interface {
+	Channel(ctx context.Context, id string) (*slack.Channel, error)
+}
+
+// Doctor checks the helpdesk's configuration
+type Doctor struct {
+	Slack    Slack
+	Channels Channels
+	// AppScopes and BotScopes are the scopes each token needs, the defaults
+	// if nil
+	AppScopes []string
+	BotScopes []string
+	// SigningSecret is the secret requests from Slack are checked with
+	SigningSecret string
+	Store         store.Store
+	// RequiredChannels are the channel IDs the helpdesk posts in keyed by the
+	// flag which configures them
+	RequiredChannels map[string][]string
+	// PublicURL is where Slack sends requests, it is sent a signed URL
+	// verification to check it reaches the helpdesk
+	PublicURL string
+	Client    *http.Client
+}
+
+var signingSecretPattern = regexp.MustCompile(`^[0-9a-f]{32}$`)
+
+// Config reads the helpdesk's flags, such as viper.GetViper()
+type Config interface {
+	GetString(key string) string
+	GetStringSlice(key string) []string
+}
+
+// RequiredChannels returns the channels the helpdesk posts in keyed by the
+// flag which configures them, for Doctor.RequiredChannels
+func RequiredChannels(c Config) map[string][]string {
+	channels := map[string][]string{}
+	for _, flag := range []string{"announce-channels", "support-channels", "trigger-channels"} {
+		if ids := c.GetStringSlice(flag); len(ids) > 0 {
+			channels[flag] = ids
+		}
+	}
+	for _, flag := range []string{"digest-channel", "vip-channel", "ops-channel", "sla-channel"} {
+		if id := c.GetString(flag); id != "" {
+			channels[flag] = []string{id}
+		}
+	}
+	for _, qc := range c.GetStringSlice("queue-channels") {
+		if parts := strings.SplitN(qc, "=", 2); len(parts) == 2 {
+			channels["queue-channels"] = append(channels["queue-channels"], parts[1])
+		}
+	}
+	return channels
+}
+
+// SelfCheck runs every check, returning what it found in the order they ran
+func (d *Doctor) SelfCheck(ctx context.Context) []Finding {
+	var fs []Finding
+	fs = append(fs, d.checkScopes()...)
+	fs = append(fs, d.checkSigningSecret())
+	fs = append(fs, d.checkStore(ctx)...)
+	fs = append(fs, d.checkChannels(ctx)...)
+	fs = append(fs, d.checkWebhook(ctx))
+	return fs
+}
+
+// Problems counts the findings which will stop the helpdesk working
+func Problems(fs []Finding) int {
+	n := 0
+	for _, f := range fs {
+		if f.Severity == Problem {
+			n++
+		}
+	}
+	return n
+}
+
+// Print writes the findings to w, one per line followed by how to fix it
+func Print(w io.Writer, fs []Finding) {
+	for _, f := range fs {
+		fmt.Fprintf(w, "[%s] %s: %s\n", f.Severity, f.Check, f.Message)
+		if f.Fix != "" {
+			fmt.Fprintf(w, "    fix: %s\n", f.Fix)
+		}
+	}
+}
+
+func (d *Doctor) checkScopes() []Finding {
+	if d.Slack == nil {
+		return nil
+	}
+	app, bot, err := d.Slack.Scopes()
+	if err != nil {
+		return []Finding{{Check: "tokens", Severity: Problem, Message: err.Error(), Fix: "check --app-token and --bot-token are the tokens of an app installed in the workspace"}}
+	}
+	appNeed, botNeed := d.AppScopes, d.BotScopes
+	if appNeed == nil {
+		appNeed = DefaultAppScopes
+	}
+	if botNeed == nil {
+		botNeed = DefaultBotScopes
+	}
+	return []Finding{scopes("app token scopes", app, appNeed), scopes("bot token scopes", bot, botNeed)}
+}
+
+func scopes(check string, granted, need []string) Finding {
+	has := map[string]bool{}
+	for _, s := range granted {
+		has[s] = true
+	}
+	var missing []string
+	for _, s := range need {
+		if !has[s] {
+			missing = append(missing, s)
+		}
+	}
+	if len(missing) > 0 {
+		return Finding{Check: check, Severity: Problem, Message: fmt.Sprintf("missing %s", strings.Join(missing, ", ")), Fix: "add the scopes under OAuth & Permissions in the app's settings and reinstall it"}
+	}
+	return Finding{Check: check, Severity: OK, Message: fmt.Sprintf("%d scopes granted", len(granted))}
+}
+
+func (d *Doctor) checkSigningSecret() Finding {
+	f := Finding{Check: "signing secret"}
+	switch {
+	case d.SigningSecret == "":
+		f.Severity, f.Message, f.Fix = Problem, "not set, every request from Slack will be refused", "set --signing-secret from Basic Information in the app's settings"
+	case !signingSecretPattern.MatchString(d.SigningSecret):
+		f.Severity, f.Message, f.Fix = Warning, "does not look like a signing secret, which are 32 hex characters", "check --signing-secret is the signing secret and not the deprecated verification token"
+	default:
+		f.Message = "looks valid"
+	}
+	return f
+}
+
+func (d *Doctor) checkStore(ctx context.Context) []Finding {
+	if d.Store == nil {
+		return nil
+	}
+	if _, _, err := d.Store.ListTickets(ctx, store.Filter{Limit: 1}); err != nil {
+		return []Finding{{Check: "store", Severity: Problem, Message: fmt.Sprintf("error listing tickets: %s", err), Fix: "check the store is reachable and --event-log can be read"}}
+	}
+	fs := []Finding{{Check: "store", Severity: OK, Message: "tickets can be listed"}}
+	if v, ok := d.Store.(interface{ Schema() int }); ok {
+		f := Finding{Check: "store schema", Severity: OK, Message: fmt.Sprintf("version %d", v.Schema())}
+		if v.Schema() < store.SchemaVersion {
+			f.Message = fmt.Sprintf("version %d, new events will be written with version %d", v.Schema(), store.SchemaVersion)
+		}
+		fs = append(fs, f)
+	}
+	return fs
+}
+
+func (d *Doctor) checkChannels(ctx context.Context) []Finding {
+	if d.Channels == nil {
+		return nil
+	}
+	flags := make([]string, 0, len(d.RequiredChannels))
+	for flag := range d.RequiredChannels {
+		flags = append(flags, flag)
+	}
+	sort.Strings(flags)
+	var fs []Finding
+	for _, flag := range flags {
+		for _, id := range d.RequiredChannels[flag] {
+			check := fmt.Sprintf("channel %s (--%s)", id, flag)
+			c, err := d.Channels.Channel(ctx, id)
+			switch {
+			case err != nil:
+				fs = append(fs, Finding{Check: check, Severity: Problem, Message: fmt.Sprintf("not found: %s", err), Fix: fmt.Sprintf("check the ID in --%s, private channels need the bot to be invited before it can see them", flag)})
+			case c.IsArchived:
+				fs = append(fs, Finding{Check: check, Severity: Problem, Message: fmt.Sprintf("#%s is archived", c.Name), Fix: fmt.Sprintf("unarchive it or replace it in --%s", flag)})
+			case c.IsChannel && !c.IsMember:
+				fs = append(fs, Finding{Check: check, Severity: Warning, Message: fmt.Sprintf("the bot is not a member of #%s", c.Name), Fix: fmt.Sprintf("invite the bot with /invite in #%s", c.Name)})
+			default:
+				fs = append(fs, Finding{Check: check, Severity: OK, Message: fmt.Sprintf("#%s", c.Name)})
+			}
+		}
+	}
+	return fs
+}
+
+func (d *Doctor) checkWebhook(ctx context.Context) Finding {
+	f := Finding{Check: "request URL"}
+	if d.PublicURL == "" {
+		f.Severity, f.Message, f.Fix = Warning, "not checked", "set --public-url to the Request URL in the app's settings to check Slack can reach the helpdesk"
+		return f
+	}
+	if d.SigningSecret == "" {
+		f.Severity, f.Message, f.Fix = Warning, "not checked without a signing secret", "set --signing-secret"
+		return f
+	}
+	fix := fmt.Sprintf("check %s is reachable from the internet, routes to the helpdesk and that it is running with this signing secret", d.PublicURL)
+	b := make([]byte, 16)
+	rand.Read(b)
+	challenge := hex.EncodeToString(b)
+	body, _ := json.Marshal(map[string]string{"type": "url_verification", "challenge": challenge})
+	r, err := http.NewRequest("POST", d.PublicURL, bytes.NewReader(body))
+	if err != nil {
+		f.Severity, f.Message, f.Fix = Problem, fmt.Sprintf("invalid URL: %s", err), "set --public-url to the Request URL in the app's settings"
+		return f
+	}
+	r = r.WithContext(ctx)
+	r.Header.Set("Content-Type", "application/json")
+	loadtest.Sign(r, d.SigningSecret, body, time.Now())
+	client := d.Client
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	res, err := client.Do(r)
+	if err != nil {
+		f.Severity, f.Message, f.Fix = Problem, fmt.Sprintf("unreachable: %s", err), fix
+		return f
+	}
+	defer res.Body.Close()
+	got, _ := ioutil.ReadAll(io.LimitReader(res.Body, 1024))
+	if res.StatusCode != http.StatusOK || string(got) != challenge {
+		f.Severity, f.Message, f.Fix = Problem, fmt.Sprintf("answered a URL verification with %s %q", res.Status, got), fix
+		return f
+	}
+	f.Message = "reaches the helpdesk"
+	return f
+}
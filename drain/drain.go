@@ -0,0 +1,108 @@
+// Package drain takes an instance out of service before it stops, so that
+// orchestrators can roll a deployment without dropping Slack callbacks. Once
+// draining starts the readiness endpoint fails, so no new traffic is sent to
+// the instance, while it keeps serving the callbacks already on their way and
+// posting the notifications waiting in the outbox.
+package drain
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Drainer reports whether the instance is ready for traffic and drains it on
+// shutdown
+type Drainer struct {
+	// Period is the least time to keep serving once draining starts, long
+	// enough for load balancers to stop sending traffic
+	Period time.Duration
+	// Outbox returns the number of notifications waiting to be posted,
+	// draining waits for it to be zero after Period. Nil skips the wait.
+	Outbox func(ctx context.Context) (int, error)
+	// Logf reports progress, it may be nil
+	Logf func(format string, args ...interface{})
+
+	mu       sync.Mutex
+	draining bool
+	// interval is how often the outbox is checked, a second if zero
+	interval time.Duration
+}
+
+// ServeHTTP satisfies http.Handler, answering readiness probes with a 200
+// until draining starts and a 503 afterwards
+func (d *Drainer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body := map[string]interface{}{"ready": !d.Draining()}
+	code := http.StatusOK
+	if d.Draining() {
+		code = http.StatusServiceUnavailable
+		if d.Outbox != nil {
+			if n, err := d.Outbox(r.Context()); err == nil {
+				body["outbox"] = n
+			}
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(body)
+}
+
+// Draining returns true once Drain has been called
+func (d *Drainer) Draining() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.draining
+}
+
+// Drain marks the instance as not ready, then returns once Period has passed
+// and the outbox is empty, or when ctx is done. It returns the notifications
+// still waiting, which a store that is not persisted loses on exit.
+func (d *Drainer) Drain(ctx context.Context) int {
+	d.mu.Lock()
+	d.draining = true
+	d.mu.Unlock()
+	interval := d.interval
+	if interval == 0 {
+		interval = time.Second
+	}
+	d.logf("Draining, no longer ready for traffic")
+
+	select {
+	case <-time.After(d.Period):
+	case <-ctx.Done():
+		n, _ := d.pending()
+		return n
+	}
+	for {
+		n, err := d.pending()
+		if err != nil {
+			d.logf("Error reading the outbox while draining: %s", err)
+		} else if n == 0 {
+			d.logf("The outbox is empty")
+			return 0
+		}
+		select {
+		case <-time.After(interval):
+		case <-ctx.Done():
+			d.logf("Stopped draining with %d notifications in the outbox", n)
+			return n
+		}
+	}
+}
+
+// pending reads the outbox without the drain's context, so what is left can
+// still be reported once it is done
+func (d *Drainer) pending() (int, error) {
+	if d.Outbox == nil {
+		return 0, nil
+	}
+	return d.Outbox(context.Background())
+}
+
+func (d *Drainer) logf(format string, args ...interface{}) {
+	if d.Logf != nil {
+		d.Logf(format, args...)
+	}
+}
@@ -0,0 +1,56 @@
+package drain
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDrain(t *testing.T) {
+	var pending int32 = 2
+	d := &Drainer{
+		Period: 20 * time.Millisecond,
+		Outbox: func(ctx context.Context) (int, error) {
+			// One notification is posted every time the outbox is read
+			return int(atomic.AddInt32(&pending, -1)) + 1, nil
+		},
+		interval: time.Millisecond,
+	}
+	probe := func() (int, map[string]interface{}) {
+		w := httptest.NewRecorder()
+		d.ServeHTTP(w, httptest.NewRequest("GET", "/readyz", nil))
+		var body map[string]interface{}
+		json.Unmarshal(w.Body.Bytes(), &body)
+		return w.Code, body
+	}
+	if code, body := probe(); code != http.StatusOK || body["ready"] != true {
+		t.Errorf("Expected the instance to be ready, got %d %v", code, body)
+	}
+
+	start := time.Now()
+	left := make(chan int)
+	go func() { left <- d.Drain(context.Background()) }()
+	time.Sleep(5 * time.Millisecond)
+	if code, body := probe(); code != http.StatusServiceUnavailable || body["ready"] != false || body["outbox"] != 2.0 {
+		t.Errorf("Expected the instance not to be ready while draining, got %d %v", code, body)
+	}
+	if n := <-left; n != 0 || time.Since(start) < d.Period {
+		t.Errorf("Expected draining to wait out the period and the outbox, got %d after %s", n, time.Since(start))
+	}
+}
+
+func TestDrainTimeout(t *testing.T) {
+	d := &Drainer{
+		Outbox:   func(ctx context.Context) (int, error) { return 3, nil },
+		interval: time.Millisecond,
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if n := d.Drain(ctx); n != 3 {
+		t.Errorf("Expected the notifications left to be returned, got %d", n)
+	}
+}
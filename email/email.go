@@ -0,0 +1,153 @@
+// Package email receives support emails from the inbound webhooks of email
+// services such as Mailgun and SendGrid, and sends the replies to them by
+// SMTP, threaded with the requester's original email
+package email
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/mail"
+	"regexp"
+	"strings"
+)
+
+// maxInbound is the largest inbound email accepted, attachments included
+const maxInbound = 10 << 20
+
+// Message is an inbound email
+type Message struct {
+	From string
+	Name string
+	// Subject and Text are the subject and plain text body of the email
+	Subject string
+	Text    string
+	// MessageID, InReplyTo and References are the threading headers,
+	// including their angle brackets
+	MessageID  string
+	InReplyTo  string
+	References []string
+}
+
+// Parser reads an inbound email from a webhook request, returning an error if
+// the request did not come from the email service
+type Parser func(r *http.Request) (*Message, error)
+
+// Mailgun parses the requests of Mailgun's inbound routes, which are verified
+// with the webhook signing key
+func Mailgun(signingKey string) Parser {
+	return func(r *http.Request) (*Message, error) {
+		if err := parseForm(r); err != nil {
+			return nil, err
+		}
+		mac := hmac.New(sha256.New, []byte(signingKey))
+		mac.Write([]byte(r.FormValue("timestamp") + r.FormValue("token")))
+		if signingKey == "" || !hmac.Equal([]byte(r.FormValue("signature")), []byte(hex.EncodeToString(mac.Sum(nil)))) {
+			return nil, fmt.Errorf("invalid Mailgun signature")
+		}
+		m := &Message{
+			Subject:   r.FormValue("subject"),
+			Text:      r.FormValue("body-plain"),
+			MessageID: r.FormValue("Message-Id"),
+			InReplyTo: r.FormValue("In-Reply-To"),
+		}
+		m.References = strings.Fields(r.FormValue("References"))
+		return m, from(m, r.FormValue("from"))
+	}
+}
+
+// SendGrid parses the requests of SendGrid's Inbound Parse webhook, which
+// has no signature so the webhook URL must carry the password as basic auth,
+// e.g. https://helpdesk:<password>@helpdesk.example.com/email
+func SendGrid(password string) Parser {
+	return func(r *http.Request) (*Message, error) {
+		_, pass, _ := r.BasicAuth()
+		if password == "" || subtle.ConstantTimeCompare([]byte(pass), []byte(password)) != 1 {
+			return nil, fmt.Errorf("invalid SendGrid password")
+		}
+		if err := parseForm(r); err != nil {
+			return nil, err
+		}
+		m := &Message{Subject: r.FormValue("subject"), Text: r.FormValue("text")}
+		if headers, err := mail.ReadMessage(strings.NewReader(strings.TrimSpace(r.FormValue("headers")) + "\r\n\r\n")); err == nil {
+			m.MessageID = headers.Header.Get("Message-Id")
+			m.InReplyTo = headers.Header.Get("In-Reply-To")
+			m.References = strings.Fields(headers.Header.Get("References"))
+		}
+		return m, from(m, r.FormValue("from"))
+	}
+}
+
+func parseForm(r *http.Request) error {
+	r.Body = http.MaxBytesReader(nil, r.Body, maxInbound)
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		return r.ParseMultipartForm(1 << 20)
+	}
+	return r.ParseForm()
+}
+
+// from sets the sender of m from a From header
+func from(m *Message, header string) error {
+	a, err := mail.ParseAddress(header)
+	if err != nil {
+		return fmt.Errorf("invalid sender %q: %s", header, err)
+	}
+	m.From, m.Name = strings.ToLower(a.Address), a.Name
+	return nil
+}
+
+var (
+	// quote starts the quoted email in a reply, e.g. "On Mon, 1 Jan 2024
+	// at 09:00, Helpdesk <help@example.com> wrote:"
+	quote = regexp.MustCompile(`(?m)^(On .+wrote:|-----Original Message-----|From: .+)\s*$`)
+	// ticketSubject finds the ticket ID in a subject such as "Re: [#12] VPN"
+	ticketSubject = regexp.MustCompile(`\[#(\w+)\]`)
+)
+
+// Reply returns the text of a reply without the email it quotes
+func Reply(text string) string {
+	text = strings.Replace(text, "\r\n", "\n", -1)
+	if loc := quote.FindStringIndex(text); loc != nil {
+		text = text[:loc[0]]
+	}
+	var lines []string
+	for _, l := range strings.Split(text, "\n") {
+		if !strings.HasPrefix(l, ">") {
+			lines = append(lines, l)
+		}
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
+// MessageID returns the Message-ID of an email about a ticket, unique by
+// the Slack timestamp of the message it carries, which replies reference
+func MessageID(ticketID, ts, domain string) string {
+	return fmt.Sprintf("<ticket.%s.%s@%s>", ticketID, ts, domain)
+}
+
+// TicketID returns the ID of the ticket an email replies to, from the
+// Message-IDs it references or otherwise from its subject, and whether it is
+// a reply to one
+func (m *Message) TicketID(domain string) (string, bool) {
+	for _, ref := range append([]string{m.InReplyTo}, m.References...) {
+		ref = strings.Trim(ref, "<>")
+		if !strings.HasPrefix(ref, "ticket.") || !strings.HasSuffix(ref, "@"+domain) {
+			continue
+		}
+		if parts := strings.SplitN(strings.TrimPrefix(ref, "ticket."), ".", 2); parts[0] != "" {
+			return parts[0], true
+		}
+	}
+	if match := ticketSubject.FindStringSubmatch(m.Subject); match != nil {
+		return match[1], true
+	}
+	return "", false
+}
+
+// Subject returns the subject of the emails about a ticket
+func Subject(ticketID, title string) string {
+	return fmt.Sprintf("[#%s] %s", ticketID, title)
+}
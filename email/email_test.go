@@ -0,0 +1,98 @@
+package email
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMailgun(t *testing.T) {
+	mac := hmac.New(sha256.New, []byte("key"))
+	mac.Write([]byte("1500000000" + "tok"))
+	form := url.Values{
+		"timestamp":   {"1500000000"},
+		"token":       {"tok"},
+		"signature":   {hex.EncodeToString(mac.Sum(nil))},
+		"from":        {"Alice <Alice@Example.com>"},
+		"subject":     {"Re: [#12] VPN down"},
+		"body-plain":  {"Still down"},
+		"Message-Id":  {"<a2@example.com>"},
+		"In-Reply-To": {"<ticket.12.1.1@help.example.com>"},
+		"References":  {"<a1@example.com> <ticket.12.1.1@help.example.com>"},
+	}
+	request := func(form url.Values) *http.Request {
+		r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		return r
+	}
+	m, err := Mailgun("key")(request(form))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if m.From != "alice@example.com" || m.Name != "Alice" || m.Text != "Still down" || len(m.References) != 2 {
+		t.Errorf("Expected the email to be parsed, got %+v", m)
+	}
+	if id, ok := m.TicketID("help.example.com"); !ok || id != "12" {
+		t.Errorf("Expected a reply to ticket 12, got %q", id)
+	}
+	form.Set("subject", "Forged")
+	form.Set("signature", "00")
+	if _, err := Mailgun("key")(request(form)); err == nil {
+		t.Errorf("Expected a bad signature to be refused")
+	}
+}
+
+func TestSendGrid(t *testing.T) {
+	form := url.Values{
+		"from":    {"bob@example.com"},
+		"subject": {"Printer"},
+		"text":    {"On fire"},
+		"headers": {"Message-ID: <b1@example.com>\nSubject: Printer"},
+	}
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if _, err := SendGrid("secret")(r); err == nil {
+		t.Errorf("Expected a request without the password to be refused")
+	}
+	r = httptest.NewRequest(http.MethodPost, "/", strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	r.SetBasicAuth("helpdesk", "secret")
+	m, err := SendGrid("secret")(r)
+	if err != nil || m.From != "bob@example.com" || m.MessageID != "<b1@example.com>" || m.Text != "On fire" {
+		t.Fatalf("Expected the email to be parsed, got %+v (%v)", m, err)
+	}
+	if _, ok := m.TicketID("help.example.com"); ok {
+		t.Errorf("Expected a new email not to be a reply")
+	}
+}
+
+func TestReply(t *testing.T) {
+	for in, want := range map[string]string{
+		"Still down\r\n\r\nOn Mon, 1 Jan 2024 at 09:00, Helpdesk <help@example.com> wrote:\r\n> Thanks": "Still down",
+		"Fixed, thanks\n> quoted\nSent from my phone":                                                   "Fixed, thanks\nSent from my phone",
+		"Ok\n\n-----Original Message-----\nFrom: Helpdesk":                                              "Ok",
+	} {
+		if got := Reply(in); got != want {
+			t.Errorf("%q: expected %q, got %q", in, want, got)
+		}
+	}
+}
+
+func TestFormat(t *testing.T) {
+	m := Outgoing{To: "alice@example.com", Subject: "Re: [#1] VPN down\r\nBcc: eve@example.com", Text: "Try now\n\nThanks", MessageID: "<ticket.1.2.2@help.example.com>", InReplyTo: "<a1@example.com>", References: []string{"<a1@example.com>"}}
+	got := string(Format("help@example.com", m, time.Date(2026, 10, 14, 9, 0, 0, 0, time.UTC)))
+	for _, want := range []string{"From: help@example.com\r\n", "In-Reply-To: <a1@example.com>\r\n", "References: <a1@example.com>\r\n", "\r\n\r\nTry now\r\n\r\nThanks\r\n"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("Expected %q in\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "\r\nBcc:") {
+		t.Errorf("Expected a header not to be injected through the subject, got\n%s", got)
+	}
+}
@@ -0,0 +1,77 @@
+package email
+
+import (
+	"bytes"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// Outgoing is an email to a requester
+type Outgoing struct {
+	To      string
+	Subject string
+	Text    string
+	// MessageID identifies the email, and InReplyTo and References thread
+	// it with the requester's emails
+	MessageID  string
+	InReplyTo  string
+	References []string
+}
+
+// Sender sends emails to requesters
+type Sender interface {
+	Send(m Outgoing) error
+}
+
+// SMTP sends emails From an address through a mail server at Addr, such as
+// smtp.mailgun.org:587, authenticating if Username is set
+type SMTP struct {
+	Addr     string
+	Username string
+	Password string
+	From     string
+}
+
+// Send satisfies Sender
+func (s *SMTP) Send(m Outgoing) error {
+	var auth smtp.Auth
+	if s.Username != "" {
+		host, _, err := net.SplitHostPort(s.Addr)
+		if err != nil {
+			return fmt.Errorf("invalid SMTP address %q: %s", s.Addr, err)
+		}
+		auth = smtp.PlainAuth("", s.Username, s.Password, host)
+	}
+	if err := smtp.SendMail(s.Addr, auth, s.From, []string{m.To}, Format(s.From, m, time.Now())); err != nil {
+		return fmt.Errorf("error sending email to %s: %s", m.To, err)
+	}
+	return nil
+}
+
+// Format returns m as a plain text email from an address sent at date
+func Format(from string, m Outgoing, date time.Time) []byte {
+	var b bytes.Buffer
+	header := func(k, v string) {
+		if v != "" {
+			// Headers can not be split by a value
+			fmt.Fprintf(&b, "%s: %s\r\n", k, strings.NewReplacer("\r", "", "\n", "").Replace(v))
+		}
+	}
+	header("From", from)
+	header("To", m.To)
+	header("Subject", mime.QEncoding.Encode("utf-8", m.Subject))
+	header("Date", date.Format(time.RFC1123Z))
+	header("Message-ID", m.MessageID)
+	header("In-Reply-To", m.InReplyTo)
+	header("References", strings.Join(m.References, " "))
+	header("MIME-Version", "1.0")
+	header("Content-Type", "text/plain; charset=utf-8")
+	b.WriteString("\r\n")
+	b.WriteString(strings.Replace(strings.Replace(m.Text, "\r\n", "\n", -1), "\n", "\r\n", -1))
+	b.WriteString("\r\n")
+	return b.Bytes()
+}
@@ -0,0 +1,269 @@
+// Package encrypt encrypts the sensitive fields of tickets at rest with
+// envelope encryption: each value is sealed with AES-GCM under a data key,
+// and the data key is stored alongside it wrapped by a master key which only
+// the KeyProvider, such as a KMS, can use
+package encrypt
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/skybet/go-helpdesk/clock"
+)
+
+// prefix starts every sealed value, values without it are plain text
+// written before encryption was turned on
+const prefix = "enc:v1:"
+
+// maxCachedKeys bounds the unwrapped data keys kept to save asking the
+// KeyProvider for them on every read
+const maxCachedKeys = 1024
+
+// KeyProvider makes and unwraps the data keys values are sealed with. Master
+// keys are identified by ID so that values sealed before a rotation can still
+// be opened.
+type KeyProvider interface {
+	// GenerateDataKey returns a new 256 bit data key, in the clear and
+	// wrapped by the current master key, and the ID of that master key
+	GenerateDataKey(ctx context.Context) (key, wrapped []byte, keyID string, err error)
+	// DecryptDataKey unwraps a data key wrapped by the master key keyID
+	DecryptDataKey(ctx context.Context, keyID string, wrapped []byte) ([]byte, error)
+}
+
+// Encrypter seals and opens values with data keys from its KeyProvider
+type Encrypter struct {
+	// KeyLifetime is how long a data key seals new values before another is
+	// generated, an hour if zero
+	KeyLifetime time.Duration
+	// Clock, if set, replaces the wall clock
+	Clock clock.Clock
+
+	keys    KeyProvider
+	mu      sync.Mutex
+	current *dataKey
+	cache   map[string]cipher.AEAD
+}
+
+// dataKey is the data key new values are sealed with
+type dataKey struct {
+	aead    cipher.AEAD
+	wrapped string
+	keyID   string
+	made    time.Time
+}
+
+// New returns an Encrypter using data keys from p
+func New(p KeyProvider) *Encrypter {
+	return &Encrypter{keys: p, cache: map[string]cipher.AEAD{}}
+}
+
+// Sealed reports whether a value has been sealed
+func Sealed(value string) bool {
+	return strings.HasPrefix(value, prefix)
+}
+
+// Seal encrypts a value of field, which is authenticated with it so that it
+// can not be moved to another field. Empty and already sealed values are
+// returned as they are.
+func (e *Encrypter) Seal(ctx context.Context, field, value string) (string, error) {
+	if value == "" || Sealed(value) {
+		return value, nil
+	}
+	k, err := e.dataKey(ctx)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, k.aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", fmt.Errorf("error making nonce: %s", err)
+	}
+	sealed := k.aead.Seal(nonce, nonce, []byte(value), []byte(field))
+	return prefix + k.keyID + ":" + k.wrapped + ":" + base64.RawStdEncoding.EncodeToString(sealed), nil
+}
+
+// Open decrypts a value of field sealed by Seal. Values which are not sealed
+// are returned as they are.
+func (e *Encrypter) Open(ctx context.Context, field, value string) (string, error) {
+	if !Sealed(value) {
+		return value, nil
+	}
+	parts := strings.SplitN(strings.TrimPrefix(value, prefix), ":", 3)
+	if len(parts) != 3 {
+		return "", fmt.Errorf("malformed sealed value")
+	}
+	aead, err := e.unwrap(ctx, parts[0], parts[1])
+	if err != nil {
+		return "", err
+	}
+	sealed, err := base64.RawStdEncoding.DecodeString(parts[2])
+	if err != nil || len(sealed) < aead.NonceSize() {
+		return "", fmt.Errorf("malformed sealed value")
+	}
+	plain, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], []byte(field))
+	if err != nil {
+		return "", fmt.Errorf("error opening sealed %s: %s", field, err)
+	}
+	return string(plain), nil
+}
+
+// KeyID returns the ID of the master key a sealed value's data key is
+// wrapped by, empty if it is not sealed
+func KeyID(value string) string {
+	if !Sealed(value) {
+		return ""
+	}
+	return strings.SplitN(strings.TrimPrefix(value, prefix), ":", 2)[0]
+}
+
+// Rotate stops sealing new values with the current data key, such as after
+// the master key has been rotated
+func (e *Encrypter) Rotate() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.current = nil
+}
+
+// dataKey returns the data key to seal with, generating one if the current
+// key has expired
+func (e *Encrypter) dataKey(ctx context.Context) (*dataKey, error) {
+	lifetime := e.KeyLifetime
+	if lifetime <= 0 {
+		lifetime = time.Hour
+	}
+	now := clock.Or(e.Clock).Now()
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.current != nil && now.Sub(e.current.made) < lifetime {
+		return e.current, nil
+	}
+	key, wrapped, keyID, err := e.keys.GenerateDataKey(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error generating data key: %s", err)
+	}
+	if strings.Contains(keyID, ":") {
+		return nil, fmt.Errorf("invalid master key ID %q", keyID)
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	e.current = &dataKey{aead: aead, wrapped: base64.RawStdEncoding.EncodeToString(wrapped), keyID: keyID, made: now}
+	e.cacheKey(keyID+":"+e.current.wrapped, aead)
+	return e.current, nil
+}
+
+// unwrap returns the data key wrapped by keyID, from the cache if it has been
+// unwrapped before
+func (e *Encrypter) unwrap(ctx context.Context, keyID, wrapped string) (cipher.AEAD, error) {
+	e.mu.Lock()
+	aead, ok := e.cache[keyID+":"+wrapped]
+	e.mu.Unlock()
+	if ok {
+		return aead, nil
+	}
+	w, err := base64.RawStdEncoding.DecodeString(wrapped)
+	if err != nil {
+		return nil, fmt.Errorf("malformed sealed value")
+	}
+	key, err := e.keys.DecryptDataKey(ctx, keyID, w)
+	if err != nil {
+		return nil, fmt.Errorf("error decrypting data key: %s", err)
+	}
+	if aead, err = newAEAD(key); err != nil {
+		return nil, err
+	}
+	e.mu.Lock()
+	e.cacheKey(keyID+":"+wrapped, aead)
+	e.mu.Unlock()
+	return aead, nil
+}
+
+// cacheKey keeps an unwrapped data key, forgetting the others once there are
+// too many. It is called with the lock held.
+func (e *Encrypter) cacheKey(k string, aead cipher.AEAD) {
+	if len(e.cache) >= maxCachedKeys {
+		e.cache = map[string]cipher.AEAD{}
+	}
+	e.cache[k] = aead
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	if len(key) != 32 {
+		return nil, fmt.Errorf("data key is %d bytes, expected 32", len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// Keyring is a KeyProvider holding the master keys itself, for when there is
+// no KMS. The first key wraps new data keys, the others are kept to unwrap
+// those wrapped before it was rotated in.
+type Keyring struct {
+	ids  []string
+	keys map[string]cipher.AEAD
+}
+
+// ParseKeyring parses master keys in the form <id>=<base64 32 byte key>, the
+// current key first
+func ParseKeyring(specs []string) (*Keyring, error) {
+	k := &Keyring{keys: map[string]cipher.AEAD{}}
+	for _, spec := range specs {
+		parts := strings.SplitN(spec, "=", 2)
+		if len(parts) != 2 || parts[0] == "" || strings.Contains(parts[0], ":") {
+			return nil, fmt.Errorf("invalid master key %q, expected <id>=<base64 key>", spec)
+		}
+		key, err := base64.StdEncoding.DecodeString(parts[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid master key %s: %s", parts[0], err)
+		}
+		aead, err := newAEAD(key)
+		if err != nil {
+			return nil, fmt.Errorf("invalid master key %s: %s", parts[0], err)
+		}
+		if _, ok := k.keys[parts[0]]; ok {
+			return nil, fmt.Errorf("master key %s is given twice", parts[0])
+		}
+		k.ids = append(k.ids, parts[0])
+		k.keys[parts[0]] = aead
+	}
+	if len(k.ids) == 0 {
+		return nil, fmt.Errorf("no master keys")
+	}
+	return k, nil
+}
+
+// GenerateDataKey satisfies KeyProvider
+func (k *Keyring) GenerateDataKey(ctx context.Context) ([]byte, []byte, string, error) {
+	key := make([]byte, 32)
+	nonce := make([]byte, k.keys[k.ids[0]].NonceSize())
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return nil, nil, "", err
+	}
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, nil, "", err
+	}
+	return key, k.keys[k.ids[0]].Seal(nonce, nonce, key, nil), k.ids[0], nil
+}
+
+// DecryptDataKey satisfies KeyProvider
+func (k *Keyring) DecryptDataKey(ctx context.Context, keyID string, wrapped []byte) ([]byte, error) {
+	aead, ok := k.keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("unknown master key %s", keyID)
+	}
+	if len(wrapped) < aead.NonceSize() {
+		return nil, fmt.Errorf("malformed data key")
+	}
+	return aead.Open(nil, wrapped[:aead.NonceSize()], wrapped[aead.NonceSize():], nil)
+}
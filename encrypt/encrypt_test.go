@@ -0,0 +1,113 @@
+package encrypt
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"testing"
+	"time"
+
+	"github.com/skybet/go-helpdesk/clock"
+)
+
+func testKey(t *testing.T) string {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatal(err)
+	}
+	return base64.StdEncoding.EncodeToString(key)
+}
+
+func testKeyring(t *testing.T, ids ...string) *Keyring {
+	var specs []string
+	for _, id := range ids {
+		specs = append(specs, id+"="+testKey(t))
+	}
+	k, err := ParseKeyring(specs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return k
+}
+
+// countingKeys counts the data keys generated and unwrapped
+type countingKeys struct {
+	KeyProvider
+	generated, unwrapped int
+}
+
+func (c *countingKeys) GenerateDataKey(ctx context.Context) ([]byte, []byte, string, error) {
+	c.generated++
+	return c.KeyProvider.GenerateDataKey(ctx)
+}
+
+func (c *countingKeys) DecryptDataKey(ctx context.Context, keyID string, wrapped []byte) ([]byte, error) {
+	c.unwrapped++
+	return c.KeyProvider.DecryptDataKey(ctx, keyID, wrapped)
+}
+
+func TestSealOpen(t *testing.T) {
+	ctx := context.Background()
+	keys := &countingKeys{KeyProvider: testKeyring(t, "k1")}
+	c := clock.NewFake(time.Date(2026, 10, 14, 9, 0, 0, 0, time.UTC))
+	e := New(keys)
+	e.Clock = c
+
+	sealed, err := e.Seal(ctx, "description", "My password is hunter2")
+	if err != nil || !Sealed(sealed) || KeyID(sealed) != "k1" {
+		t.Fatalf("Expected the value to be sealed with k1, got %q (%v)", sealed, err)
+	}
+	if again, _ := e.Seal(ctx, "description", sealed); again != sealed {
+		t.Errorf("Expected a sealed value not to be sealed twice")
+	}
+	if empty, _ := e.Seal(ctx, "description", ""); empty != "" {
+		t.Errorf("Expected empty values to be left empty, got %q", empty)
+	}
+	if plain, err := e.Open(ctx, "description", sealed); err != nil || plain != "My password is hunter2" {
+		t.Errorf("Expected the value to open, got %q (%v)", plain, err)
+	}
+	if _, err := e.Open(ctx, "comment", sealed); err == nil {
+		t.Errorf("Expected a value moved to another field not to open")
+	}
+	if plain, err := e.Open(ctx, "description", "written before encryption"); err != nil || plain != "written before encryption" {
+		t.Errorf("Expected plain values to be read as they are, got %q (%v)", plain, err)
+	}
+
+	e.Seal(ctx, "description", "another")
+	c.Advance(time.Hour)
+	e.Seal(ctx, "description", "after an hour")
+	if keys.generated != 2 {
+		t.Errorf("Expected a data key an hour, got %d", keys.generated)
+	}
+	fresh := New(keys)
+	fresh.Open(ctx, "description", sealed)
+	fresh.Open(ctx, "description", sealed)
+	if keys.unwrapped != 1 {
+		t.Errorf("Expected unwrapped data keys to be cached, got %d unwraps", keys.unwrapped)
+	}
+}
+
+func TestKeyringRotation(t *testing.T) {
+	ctx := context.Background()
+	old := testKey(t)
+	before, _ := ParseKeyring([]string{"k1=" + old})
+	sealed, _ := New(before).Seal(ctx, "description", "VPN")
+
+	after, err := ParseKeyring([]string{"k2=" + testKey(t), "k1=" + old})
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := New(after)
+	if plain, err := e.Open(ctx, "description", sealed); err != nil || plain != "VPN" {
+		t.Errorf("Expected values sealed before the rotation to open, got %q (%v)", plain, err)
+	}
+	if resealed, _ := e.Seal(ctx, "description", "VPN"); KeyID(resealed) != "k2" {
+		t.Errorf("Expected new values to be sealed with the new key, got %s", KeyID(resealed))
+	}
+
+	for _, specs := range [][]string{nil, {"k1"}, {"k1=short"}, {"a:b=" + old}, {"k1=" + old, "k1=" + old}} {
+		if _, err := ParseKeyring(specs); err == nil {
+			t.Errorf("%v: expected an error", specs)
+		}
+	}
+}
@@ -0,0 +1,191 @@
+package encrypt
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/skybet/go-helpdesk/store"
+	"github.com/skybet/go-helpdesk/ticket"
+)
+
+// The fields sealed, which are authenticated with their values
+const (
+	description = "description"
+	comment     = "comment"
+	snippet     = "snippet"
+)
+
+// NewStore returns a store which seals the descriptions, comments and file
+// snippets of the tickets written to s, and opens them again when they are
+// read. Only the paths which show tickets to the people allowed to see them
+// should read through it, see NewSealedStore.
+func NewStore(s store.Store, e *Encrypter) store.Store {
+	return &encrypted{Store: s, enc: e, open: true}
+}
+
+// NewSealedStore returns a store which seals tickets written to s like
+// NewStore, but leaves them sealed when they are read. Writing a sealed ticket
+// back leaves its sealed values as they are.
+func NewSealedStore(s store.Store, e *Encrypter) store.Store {
+	return &encrypted{Store: s, enc: e}
+}
+
+type encrypted struct {
+	store.Store
+	enc  *Encrypter
+	open bool
+}
+
+// each calls fn with every sealed field of t
+func each(t *ticket.Ticket, fn func(field string, value *string) error) error {
+	if err := fn(description, &t.Description); err != nil {
+		return err
+	}
+	for i := range t.Comments {
+		if err := fn(comment, &t.Comments[i].Text); err != nil {
+			return err
+		}
+	}
+	for i := range t.Attachments {
+		if err := fn(snippet, &t.Attachments[i].Snippet); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// seal returns a copy of t with its fields sealed
+func (s *encrypted) seal(ctx context.Context, t *ticket.Ticket) (*ticket.Ticket, error) {
+	sealed := t.Copy()
+	err := each(sealed, func(field string, value *string) (err error) {
+		*value, err = s.enc.Seal(ctx, field, *value)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error sealing ticket %s: %s", t.ID, err)
+	}
+	return sealed, nil
+}
+
+// written copies what the store set on the sealed copy of t, such as its ID
+// and Version, back to t
+func written(t, sealed *ticket.Ticket) {
+	plain := t.Copy()
+	*t = *sealed
+	t.Description = plain.Description
+	t.Comments = plain.Comments
+	t.Attachments = plain.Attachments
+}
+
+// opened returns t with its fields opened, in place
+func (s *encrypted) opened(ctx context.Context, t *ticket.Ticket) (*ticket.Ticket, error) {
+	err := each(t, func(field string, value *string) (err error) {
+		*value, err = s.enc.Open(ctx, field, *value)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error opening ticket %s: %s", t.ID, err)
+	}
+	return t, nil
+}
+
+// read returns t as the store's readers should see it
+func (s *encrypted) read(ctx context.Context, t *ticket.Ticket) (*ticket.Ticket, error) {
+	if !s.open {
+		return t, nil
+	}
+	return s.opened(ctx, t)
+}
+
+func (s *encrypted) CreateTicket(ctx context.Context, t *ticket.Ticket) error {
+	sealed, err := s.seal(ctx, t)
+	if err != nil {
+		return err
+	}
+	if err := s.Store.CreateTicket(ctx, sealed); err != nil {
+		return err
+	}
+	written(t, sealed)
+	return nil
+}
+
+func (s *encrypted) UpdateTicket(ctx context.Context, t *ticket.Ticket) error {
+	sealed, err := s.seal(ctx, t)
+	if err != nil {
+		return err
+	}
+	if err := s.Store.UpdateTicket(ctx, sealed); err != nil {
+		return err
+	}
+	written(t, sealed)
+	return nil
+}
+
+func (s *encrypted) GetTicket(ctx context.Context, id string) (*ticket.Ticket, error) {
+	t, err := s.Store.GetTicket(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return s.read(ctx, t)
+}
+
+func (s *encrypted) Transition(ctx context.Context, id string, from, to ticket.Status) (*ticket.Ticket, error) {
+	t, err := s.Store.Transition(ctx, id, from, to)
+	if err != nil {
+		return nil, err
+	}
+	return s.read(ctx, t)
+}
+
+// ListTickets satisfies store.Store. The wrapped store can not search sealed
+// text, so a filter with Text is matched here against the opened tickets,
+// reading the wrapped store's pages until the page is full.
+func (s *encrypted) ListTickets(ctx context.Context, f store.Filter) ([]*ticket.Ticket, string, error) {
+	if f.Text == "" {
+		ts, next, err := s.Store.ListTickets(ctx, f)
+		if err != nil {
+			return nil, "", err
+		}
+		for i, t := range ts {
+			if ts[i], err = s.read(ctx, t); err != nil {
+				return nil, "", err
+			}
+		}
+		return ts, next, nil
+	}
+	inner := f
+	inner.Text = ""
+	var matched []*ticket.Ticket
+	for {
+		if f.Limit > 0 {
+			inner.Limit = f.Limit - len(matched)
+		}
+		ts, next, err := s.Store.ListTickets(ctx, inner)
+		if err != nil {
+			return nil, "", err
+		}
+		for _, t := range ts {
+			plain, err := s.opened(ctx, t.Copy())
+			if err != nil {
+				return nil, "", err
+			}
+			if !f.Match(plain) {
+				continue
+			}
+			if s.open {
+				t = plain
+			}
+			matched = append(matched, t)
+		}
+		if next == "" || (f.Limit > 0 && len(matched) >= f.Limit) {
+			return matched, next, nil
+		}
+		inner.Cursor = next
+	}
+}
+
+func (s *encrypted) Tx(ctx context.Context, fn func(s store.Store) error) error {
+	return s.Store.Tx(ctx, func(tx store.Store) error {
+		return fn(&encrypted{Store: tx, enc: s.enc, open: s.open})
+	})
+}
@@ -0,0 +1,95 @@
+package encrypt
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/skybet/go-helpdesk/store"
+	"github.com/skybet/go-helpdesk/store/storetest"
+	"github.com/skybet/go-helpdesk/ticket"
+)
+
+func TestStoreConformance(t *testing.T) {
+	storetest.RunConformance(t, func(t *testing.T) store.Store {
+		return NewStore(store.NewMemory(), New(testKeyring(t, "k1")))
+	})
+}
+
+func TestStore(t *testing.T) {
+	ctx := context.Background()
+	raw := store.NewMemory()
+	enc := New(testKeyring(t, "k1"))
+	s := NewStore(raw, enc)
+	tk := &ticket.Ticket{
+		Title:       "VPN down",
+		Description: "My password is hunter2",
+		Comments:    []ticket.Comment{{ID: "1.2", Author: "U2", Text: "Have you tried hunter3?"}},
+		Attachments: []ticket.Attachment{{ID: "F1", Name: "vpn.log", Snippet: "user=alice"}},
+	}
+	if err := s.CreateTicket(ctx, tk); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if tk.ID == "" || tk.Version != 1 || tk.Description != "My password is hunter2" {
+		t.Errorf("Expected the ticket to be created and left in the clear, got %+v", tk)
+	}
+
+	stored, _ := raw.GetTicket(ctx, tk.ID)
+	for _, v := range []string{stored.Description, stored.Comments[0].Text, stored.Attachments[0].Snippet} {
+		if !Sealed(v) || strings.Contains(v, "hunter") || strings.Contains(v, "alice") {
+			t.Errorf("Expected the field to be sealed at rest, got %q", v)
+		}
+	}
+	if stored.Title != "VPN down" || stored.Comments[0].Author != "U2" {
+		t.Errorf("Expected the other fields to be in the clear, got %+v", stored)
+	}
+
+	got, err := s.GetTicket(ctx, tk.ID)
+	if err != nil || got.Description != "My password is hunter2" || got.Comments[0].Text != "Have you tried hunter3?" || got.Attachments[0].Snippet != "user=alice" {
+		t.Fatalf("Expected the fields to be opened on read, got %+v (%v)", got, err)
+	}
+	if found, _, _ := s.ListTickets(ctx, store.Filter{Text: "hunter3"}); len(found) != 1 || found[0].Comments[0].Text != "Have you tried hunter3?" {
+		t.Errorf("Expected sealed comments to be searched, got %v", found)
+	}
+
+	sealed := NewSealedStore(raw, enc)
+	view, _ := sealed.GetTicket(ctx, tk.ID)
+	if !Sealed(view.Description) {
+		t.Errorf("Expected the sealed store to leave fields sealed, got %q", view.Description)
+	}
+	view.Title = "VPN down again"
+	if err := sealed.UpdateTicket(ctx, view); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if got, _ := s.GetTicket(ctx, tk.ID); got.Title != "VPN down again" || got.Description != "My password is hunter2" {
+		t.Errorf("Expected writing a sealed ticket back to keep its fields, got %+v", got)
+	}
+	if found, _, _ := sealed.ListTickets(ctx, store.Filter{Text: "hunter2"}); len(found) != 1 || !Sealed(found[0].Description) {
+		t.Errorf("Expected the sealed store to search but not open tickets, got %v", found)
+	}
+}
+
+func TestStoreTextPages(t *testing.T) {
+	ctx := context.Background()
+	s := NewStore(store.NewMemory(), New(testKeyring(t, "k1")))
+	for _, d := range []string{"printer", "vpn", "printer", "vpn", "printer"} {
+		s.CreateTicket(ctx, &ticket.Ticket{Title: "Help", Description: d})
+	}
+	var pages, found int
+	f := store.Filter{Text: "printer", Limit: 2}
+	for {
+		ts, next, err := s.ListTickets(ctx, f)
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		pages++
+		found += len(ts)
+		if next == "" {
+			break
+		}
+		f.Cursor = next
+	}
+	if found != 3 || pages != 2 {
+		t.Errorf("Expected 3 matches over 2 pages, got %d over %d", found, pages)
+	}
+}
@@ -0,0 +1,93 @@
+package encrypt
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Transit is a KeyProvider backed by a HashiCorp Vault transit key, which
+// never leaves Vault. Rotating the key in Vault, with
+// vault write -f transit/keys/<key>/rotate, wraps new data keys with the new
+// version while the old versions still unwrap the data keys before it.
+type Transit struct {
+	// URL is Vault's address, e.g. https://vault.example.com:8200
+	URL   string
+	Token string
+	// Key is the name of the transit key
+	Key string
+	// Client makes the requests, with a 10 second timeout if nil
+	Client *http.Client
+}
+
+// GenerateDataKey satisfies KeyProvider
+func (t *Transit) GenerateDataKey(ctx context.Context) ([]byte, []byte, string, error) {
+	var resp struct {
+		Plaintext  string `json:"plaintext"`
+		Ciphertext string `json:"ciphertext"`
+	}
+	if err := t.post(ctx, "datakey/plaintext/"+url.PathEscape(t.Key), map[string]interface{}{"bits": 256}, &resp); err != nil {
+		return nil, nil, "", err
+	}
+	key, err := base64.StdEncoding.DecodeString(resp.Plaintext)
+	if err != nil {
+		return nil, nil, "", fmt.Errorf("error decoding data key from Vault: %s", err)
+	}
+	return key, []byte(resp.Ciphertext), t.Key, nil
+}
+
+// DecryptDataKey satisfies KeyProvider
+func (t *Transit) DecryptDataKey(ctx context.Context, keyID string, wrapped []byte) ([]byte, error) {
+	var resp struct {
+		Plaintext string `json:"plaintext"`
+	}
+	if err := t.post(ctx, "decrypt/"+url.PathEscape(keyID), map[string]interface{}{"ciphertext": string(wrapped)}, &resp); err != nil {
+		return nil, err
+	}
+	key, err := base64.StdEncoding.DecodeString(resp.Plaintext)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding data key from Vault: %s", err)
+	}
+	return key, nil
+}
+
+// post calls a transit endpoint, decoding the data of its response into v
+func (t *Transit) post(ctx context.Context, path string, body, v interface{}) error {
+	b, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, strings.TrimSuffix(t.URL, "/")+"/v1/transit/"+path, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("X-Vault-Token", t.Token)
+	req.Header.Set("Content-Type", "application/json")
+	c := t.Client
+	if c == nil {
+		c = &http.Client{Timeout: 10 * time.Second}
+	}
+	res, err := c.Do(req)
+	if err != nil {
+		return fmt.Errorf("error calling Vault: %s", err)
+	}
+	defer res.Body.Close()
+	var resp struct {
+		Data   json.RawMessage `json:"data"`
+		Errors []string        `json:"errors"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&resp); err != nil {
+		return fmt.Errorf("error decoding Vault's %s response: %s", res.Status, err)
+	}
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("Vault returned %s: %s", res.Status, strings.Join(resp.Errors, ", "))
+	}
+	return json.Unmarshal(resp.Data, v)
+}
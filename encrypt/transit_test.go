@@ -0,0 +1,51 @@
+package encrypt
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestTransit(t *testing.T) {
+	key := make([]byte, 32)
+	key[0] = 7
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "s.token" {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"errors":["permission denied"]}`))
+			return
+		}
+		var body map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&body)
+		switch r.URL.Path {
+		case "/v1/transit/datakey/plaintext/helpdesk":
+			json.NewEncoder(w).Encode(map[string]interface{}{"data": map[string]string{"plaintext": base64.StdEncoding.EncodeToString(key), "ciphertext": "vault:v1:abc"}})
+		case "/v1/transit/decrypt/helpdesk":
+			if body["ciphertext"] != "vault:v1:abc" {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(`{"errors":["invalid ciphertext"]}`))
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"data": map[string]string{"plaintext": base64.StdEncoding.EncodeToString(key)}})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	e := New(&Transit{URL: srv.URL, Token: "s.token", Key: "helpdesk"})
+	sealed, err := e.Seal(context.Background(), "description", "VPN")
+	if err != nil || KeyID(sealed) != "helpdesk" {
+		t.Fatalf("Expected the value to be sealed with the transit key, got %q (%v)", sealed, err)
+	}
+	if plain, err := New(&Transit{URL: srv.URL, Token: "s.token", Key: "helpdesk"}).Open(context.Background(), "description", sealed); err != nil || plain != "VPN" {
+		t.Errorf("Expected Vault to unwrap the data key, got %q (%v)", plain, err)
+	}
+	if _, err := New(&Transit{URL: srv.URL, Token: "wrong", Key: "helpdesk"}).Open(context.Background(), "description", sealed); err == nil || !strings.Contains(err.Error(), "permission denied") {
+		t.Errorf("Expected Vault's error, got %v", err)
+	}
+}
@@ -0,0 +1,396 @@
+// Package escalate chases tickets nobody has acknowledged up a chain of
+// people, e.g. from the assignee to their lead, manager and then director
+package escalate
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nlopes/slack"
+
+	"github.com/skybet/go-helpdesk/archive"
+	"github.com/skybet/go-helpdesk/blocks"
+	"github.com/skybet/go-helpdesk/clock"
+	"github.com/skybet/go-helpdesk/links"
+	"github.com/skybet/go-helpdesk/notify"
+	"github.com/skybet/go-helpdesk/store"
+	"github.com/skybet/go-helpdesk/ticket"
+)
+
+// AckActionID is the action ID of the "Acknowledge" buttons on escalations,
+// their value is the ticket ID
+const AckActionID = "escalation_ack"
+
+// Assignee stands in for the ticket's assignee in a level's users
+const Assignee = "assignee"
+
+// AnyQueue is the queue name of the chain used for queues without their own
+const AnyQueue = "*"
+
+// Method is how a level of a chain is notified
+type Method string
+
+// The ways a level can be notified
+const (
+	// Thread mentions the level's users in the ticket's thread
+	Thread Method = "thread"
+	// DM sends each of the level's users a direct message
+	DM Method = "dm"
+	// Page sends each of the level's users an urgent direct message which
+	// may break through their quiet hours
+	Page Method = "page"
+)
+
+// Level is one step of an escalation chain
+type Level struct {
+	// Name describes the level, e.g. lead
+	Name string
+	// After is how long the previous level, or the ticket's creation for the
+	// first level, goes unacknowledged before this level is notified
+	After  time.Duration
+	Method Method
+	// Users are the Slack user IDs notified, Assignee is the ticket's assignee
+	Users []string
+}
+
+// Chain is the levels a ticket is escalated through in order
+type Chain []Level
+
+// Chains are the escalation chains of each queue, AnyQueue applies to queues
+// without a chain of their own
+type Chains map[string]Chain
+
+// For returns the chain for a queue, nil if it is not escalated
+func (c Chains) For(queue string) Chain {
+	if chain, ok := c[queue]; ok {
+		return chain
+	}
+	return c[AnyQueue]
+}
+
+// ParseChains parses levels in the form <queue>:<name>:<after>:<method>[:<user>+<user>],
+// e.g. it:lead:2h:dm:U123. Levels without users notify the ticket's assignee.
+// Each queue's levels are escalated in the order given.
+func ParseChains(specs []string) (Chains, error) {
+	chains := Chains{}
+	for _, spec := range specs {
+		parts := strings.Split(spec, ":")
+		if len(parts) < 4 || len(parts) > 5 || parts[0] == "" || parts[1] == "" {
+			return nil, fmt.Errorf("invalid escalation level %q, expected <queue>:<name>:<after>:<method>[:<users>]", spec)
+		}
+		after, err := time.ParseDuration(parts[2])
+		if err != nil || after < 0 {
+			return nil, fmt.Errorf("invalid escalation timeout in %q", spec)
+		}
+		l := Level{Name: parts[1], After: after, Method: Method(parts[3]), Users: []string{Assignee}}
+		switch l.Method {
+		case Thread, DM, Page:
+		default:
+			return nil, fmt.Errorf("invalid escalation method %q, expected thread, dm or page", parts[3])
+		}
+		if len(parts) == 5 {
+			l.Users = strings.Split(parts[4], "+")
+		}
+		chains[parts[0]] = append(chains[parts[0]], l)
+	}
+	return chains, nil
+}
+
+// Critical reports whether a ticket's direct escalations are notices which
+// every recipient has to acknowledge, as they are for P1 tickets
+func Critical(t *ticket.Ticket) bool {
+	return t.Priority == ticket.P1
+}
+
+// Acknowledged reports whether someone has taken responsibility for the
+// ticket, which stops it being escalated. Only the Acknowledge button counts
+// for critical tickets, which carry on up the chain while anyone sent a
+// notice has not pressed it.
+func Acknowledged(t *ticket.Ticket) bool {
+	if !Critical(t) {
+		return !t.AcknowledgedAt.IsZero() || !t.FirstResponseAt.IsZero()
+	}
+	if t.AcknowledgedAt.IsZero() {
+		return false
+	}
+	for _, n := range t.Notices {
+		if !n.Acked() {
+			return false
+		}
+	}
+	return true
+}
+
+// Acknowledge records that user has acknowledged a ticket, and any notices
+// they were sent about it, at now. It is also the ticket's first response if
+// nobody has replied yet. Later acknowledgements leave the first in place.
+func Acknowledge(ctx context.Context, s store.Store, id, user string, now time.Time) (*ticket.Ticket, error) {
+	var acked *ticket.Ticket
+	err := s.Tx(ctx, func(tx store.Store) error {
+		t, err := tx.GetTicket(ctx, id)
+		if err != nil {
+			return err
+		}
+		acked = t
+		changed := false
+		for i := range t.Notices {
+			if n := &t.Notices[i]; n.To == user && !n.Acked() {
+				n.AckedAt = now
+				changed = true
+			}
+		}
+		if t.AcknowledgedAt.IsZero() {
+			t.AcknowledgedAt, t.AcknowledgedBy = now, user
+			if t.FirstResponseAt.IsZero() {
+				t.FirstResponseAt = now
+			}
+			changed = true
+		}
+		if !changed {
+			return nil
+		}
+		return tx.UpdateTicket(ctx, t)
+	})
+	return acked, err
+}
+
+// Receipts describes who has and has not acknowledged the notices sent about
+// a ticket, empty if none were sent
+func Receipts(t *ticket.Ticket) string {
+	var users []string
+	waiting := map[string]bool{}
+	for _, n := range t.Notices {
+		if _, ok := waiting[n.To]; !ok {
+			users = append(users, n.To)
+		}
+		waiting[n.To] = waiting[n.To] || !n.Acked()
+	}
+	var acked, pending []string
+	for _, u := range users {
+		if waiting[u] {
+			pending = append(pending, fmt.Sprintf("<@%s>", u))
+		} else {
+			acked = append(acked, fmt.Sprintf("<@%s>", u))
+		}
+	}
+	var parts []string
+	if len(acked) > 0 {
+		parts = append(parts, "Acknowledged by "+strings.Join(acked, ", "))
+	}
+	if len(pending) > 0 {
+		parts = append(parts, "Waiting on "+strings.Join(pending, ", "))
+	}
+	return strings.Join(parts, ". ")
+}
+
+// Slack is the part of the Slack API used to notify levels, pages are sent
+// with PostUrgent if it also implements notify.UrgentPoster
+type Slack interface {
+	PostMessage(channelID string, options ...slack.MsgOption) (string, string, error)
+}
+
+// Escalator notifies each level of a ticket's chain in turn until the ticket
+// is acknowledged or resolved
+type Escalator struct {
+	Store  store.Store
+	Slack  Slack
+	Chains Chains
+	// Links, if set, links tickets in escalations to their thread
+	Links *links.Links
+	// Route, if set, replaces the users a level of a queue's chain notifies
+	// at now, e.g. with the region on call for the queue
+	Route func(queue string, users []string, now time.Time) []string
+	// Clock, if set, replaces the wall clock
+	Clock clock.Clock
+
+	mu    sync.Mutex
+	state map[string]progress
+}
+
+// progress is the next level of a ticket's chain to notify and when the
+// previous one was
+type progress struct {
+	level int
+	since time.Time
+}
+
+// Escalate notifies the levels which are due as of now. A ticket moves up at
+// most one level each time so that every level gets its full timeout.
+func (e *Escalator) Escalate(ctx context.Context, now time.Time) error {
+	f := store.Filter{Limit: 500}
+	var open []*ticket.Ticket
+	for {
+		page, next, err := e.Store.ListTickets(ctx, f)
+		if err != nil {
+			return fmt.Errorf("error listing tickets: %s", err)
+		}
+		for _, t := range page {
+			if t.Status.Open() && !Acknowledged(t) && len(e.Chains.For(t.Queue)) > 0 {
+				open = append(open, t)
+			}
+		}
+		if next == "" {
+			break
+		}
+		f.Cursor = next
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.state == nil {
+		e.state = map[string]progress{}
+	}
+	pending := map[string]bool{}
+	var errs []string
+	for _, t := range open {
+		pending[t.ID] = true
+		chain := e.Chains.For(t.Queue)
+		p, ok := e.state[t.ID]
+		if !ok {
+			p = resume(t, chain)
+		}
+		for p.level < len(chain) && now.Sub(p.since) >= chain[p.level].After {
+			l := chain[p.level]
+			p.level++
+			users := l.users(t)
+			if e.Route != nil {
+				users = e.Route(t.Queue, users, now)
+			}
+			if len(users) == 0 {
+				// Nobody to tell, e.g. the ticket is unassigned, so the
+				// next level is due straight away
+				continue
+			}
+			sent, err := e.notify(t, l, users, now)
+			if err != nil {
+				errs = append(errs, fmt.Sprintf("#%s to %s: %s", t.ID, l.Name, err))
+			}
+			if Critical(t) && len(sent) > 0 {
+				if err := e.record(ctx, t.ID, l.Name, sent, now); err != nil {
+					errs = append(errs, fmt.Sprintf("#%s notices to %s: %s", t.ID, l.Name, err))
+				}
+			}
+			p.since = now
+			break
+		}
+		e.state[t.ID] = p
+	}
+	for id := range e.state {
+		if !pending[id] {
+			delete(e.state, id)
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("error escalating tickets: %s", strings.Join(errs, ", "))
+	}
+	return nil
+}
+
+// resume picks a ticket's chain up after the level it last sent notices for,
+// so that a restart does not notify the levels of a critical ticket again
+func resume(t *ticket.Ticket, chain Chain) progress {
+	p := progress{since: t.CreatedAt}
+	if len(t.Notices) == 0 {
+		return p
+	}
+	last := t.Notices[len(t.Notices)-1]
+	for i := len(chain) - 1; i >= 0; i-- {
+		if chain[i].Name == last.Level {
+			return progress{level: i + 1, since: last.SentAt}
+		}
+	}
+	return p
+}
+
+// record saves the notices sent to users as level of a ticket's chain
+func (e *Escalator) record(ctx context.Context, id, level string, users []string, now time.Time) error {
+	return e.Store.Tx(ctx, func(tx store.Store) error {
+		t, err := tx.GetTicket(ctx, id)
+		if err != nil {
+			return err
+		}
+		for _, u := range users {
+			t.Notices = append(t.Notices, ticket.Notice{To: u, Level: level, SentAt: now})
+		}
+		return tx.UpdateTicket(ctx, t)
+	})
+}
+
+func (l Level) users(t *ticket.Ticket) []string {
+	var users []string
+	for _, u := range l.Users {
+		if u == Assignee {
+			u = t.Assignee
+		}
+		if u != "" {
+			users = append(users, u)
+		}
+	}
+	return users
+}
+
+// notify tells a level's users about a ticket, returning those sent it
+// directly. Thread escalations fall back to DMs when the ticket has no thread
+// the bot may post in.
+func (e *Escalator) notify(t *ticket.Ticket, l Level, users []string, now time.Time) ([]string, error) {
+	text := Text(t, l, now, e.Links)
+	if l.Method == Thread && t.ChannelID != "" && t.ThreadTS != "" && !archive.Locked(t) {
+		mentions := make([]string, len(users))
+		for i, u := range users {
+			mentions[i] = fmt.Sprintf("<@%s>", u)
+		}
+		_, _, err := e.Slack.PostMessage(t.ChannelID, append(Message(t, strings.Join(mentions, " ")+" "+text), slack.MsgOptionTS(t.ThreadTS))...)
+		return nil, err
+	}
+	post := e.Slack.PostMessage
+	if u, ok := e.Slack.(notify.UrgentPoster); ok && l.Method == Page {
+		post = u.PostUrgent
+	}
+	var sent, errs []string
+	for _, u := range users {
+		if _, _, err := post(u, Message(t, text)...); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %s", u, err))
+			continue
+		}
+		sent = append(sent, u)
+	}
+	if len(errs) > 0 {
+		return sent, fmt.Errorf("%s", strings.Join(errs, ", "))
+	}
+	return sent, nil
+}
+
+// Text describes the escalation of a ticket to level at now, referring to the
+// ticket with l
+func Text(t *ticket.Ticket, level Level, now time.Time, l *links.Links) string {
+	return fmt.Sprintf("Ticket %s %s has not been acknowledged for %s and has been escalated to the %s.", l.Ref(t.ID), t.Title, now.Sub(t.CreatedAt).Round(time.Minute), level.Name)
+}
+
+// Message is text with a button to acknowledge the ticket
+func Message(t *ticket.Ticket, text string) []slack.MsgOption {
+	button := blocks.Button(AckActionID, t.ID, "Acknowledge")
+	button.Style = slack.StylePrimary
+	return []slack.MsgOption{
+		slack.MsgOptionText(text, false),
+		slack.MsgOptionBlocks(blocks.New().Section(text).Actions("", button).Blocks()...),
+	}
+}
+
+// Run escalates every interval until ctx is cancelled
+func (e *Escalator) Run(ctx context.Context, interval time.Duration, errorf func(string, ...interface{})) {
+	tick := clock.Or(e.Clock).NewTicker(interval)
+	defer tick.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-tick.C():
+			if err := e.Escalate(ctx, now); err != nil {
+				errorf("Escalating tickets failed: %s", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,220 @@
+package escalate
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nlopes/slack"
+
+	"github.com/skybet/go-helpdesk/clock"
+	"github.com/skybet/go-helpdesk/store"
+	"github.com/skybet/go-helpdesk/ticket"
+)
+
+type post struct {
+	channel string
+	urgent  bool
+	text    string
+}
+
+type fakeSlack struct {
+	mu    sync.Mutex
+	posts []post
+}
+
+func (f *fakeSlack) PostMessage(channelID string, options ...slack.MsgOption) (string, string, error) {
+	return f.post(channelID, false, options)
+}
+
+func (f *fakeSlack) PostUrgent(channelID string, options ...slack.MsgOption) (string, string, error) {
+	return f.post(channelID, true, options)
+}
+
+func (f *fakeSlack) post(channelID string, urgent bool, options []slack.MsgOption) (string, string, error) {
+	_, values, _ := slack.UnsafeApplyMsgOptions("", channelID, "", options...)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.posts = append(f.posts, post{channel: channelID, urgent: urgent, text: values.Get("text")})
+	return channelID, "1.2", nil
+}
+
+func TestParseChains(t *testing.T) {
+	chains, err := ParseChains([]string{"it:assignee:30m:thread", "it:lead:1h:dm:U1", "*:director:2h:page:U2+U3"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	it := chains.For("it")
+	if len(it) != 2 || it[0].Users[0] != Assignee || it[1].After != time.Hour || it[1].Method != DM {
+		t.Errorf("Unexpected chain for it: %+v", it)
+	}
+	if other := chains.For("hr"); len(other) != 1 || len(other[0].Users) != 2 || other[0].Method != Page {
+		t.Errorf("Expected queues without a chain to use the default, got %+v", other)
+	}
+	for _, spec := range []string{"it:lead:1h", "it:lead:soon:dm", "it:lead:1h:email:U1", ":lead:1h:dm"} {
+		if _, err := ParseChains([]string{spec}); err == nil {
+			t.Errorf("Expected %q to be invalid", spec)
+		}
+	}
+}
+
+func TestEscalate(t *testing.T) {
+	created := time.Now().Add(-time.Hour)
+	s := store.NewMemory()
+	s.CreateTicket(context.Background(), &ticket.Ticket{ID: "1", Queue: "it", Title: "VPN", Assignee: "U1", ChannelID: "C1", ThreadTS: "1.1", CreatedAt: created})
+	s.CreateTicket(context.Background(), &ticket.Ticket{ID: "2", Queue: "hr", CreatedAt: created})
+	chains, _ := ParseChains([]string{"it:assignee:30m:thread", "it:lead:1h:dm:U2", "it:director:2h:page:U3"})
+	f := &fakeSlack{}
+	e := &Escalator{Store: s, Slack: f, Chains: chains}
+
+	e.Escalate(context.Background(), created.Add(10*time.Minute))
+	if len(f.posts) != 0 {
+		t.Fatalf("Expected nothing to be escalated before the first timeout, got %v", f.posts)
+	}
+	e.Escalate(context.Background(), created.Add(31*time.Minute))
+	if len(f.posts) != 1 || f.posts[0].channel != "C1" || !strings.HasPrefix(f.posts[0].text, "<@U1> Ticket #1 VPN has not been acknowledged for 31m0s") {
+		t.Fatalf("Expected the assignee to be mentioned in the thread, got %v", f.posts)
+	}
+	// The lead's timeout starts when the assignee was told
+	e.Escalate(context.Background(), created.Add(80*time.Minute))
+	if len(f.posts) != 1 {
+		t.Fatalf("Expected the assignee to get the full timeout, got %v", f.posts)
+	}
+	e.Escalate(context.Background(), created.Add(91*time.Minute))
+	if len(f.posts) != 2 || f.posts[1].channel != "U2" || f.posts[1].urgent {
+		t.Fatalf("Expected the lead to be sent a DM, got %v", f.posts)
+	}
+
+	if _, err := Acknowledge(context.Background(), s, "1", "U2", created.Add(100*time.Minute)); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	e.Escalate(context.Background(), created.Add(5*time.Hour))
+	if len(f.posts) != 2 {
+		t.Errorf("Expected escalation to stop once acknowledged, got %v", f.posts)
+	}
+	if tk, _ := Acknowledge(context.Background(), s, "1", "U3", created.Add(6*time.Hour)); tk.AcknowledgedBy != "U2" {
+		t.Errorf("Expected the first acknowledgement to be kept, got %s", tk.AcknowledgedBy)
+	}
+}
+
+func TestEscalateSkipsUnassigned(t *testing.T) {
+	created := time.Now().Add(-time.Hour)
+	s := store.NewMemory()
+	s.CreateTicket(context.Background(), &ticket.Ticket{ID: "1", Queue: "it", CreatedAt: created})
+	chains, _ := ParseChains([]string{"it:assignee:30m:thread", "it:director:15m:page:U3"})
+	f := &fakeSlack{}
+	e := &Escalator{Store: s, Slack: f, Chains: chains}
+
+	e.Escalate(context.Background(), created.Add(45*time.Minute))
+	if len(f.posts) != 1 || f.posts[0].channel != "U3" || !f.posts[0].urgent {
+		t.Errorf("Expected an unassigned ticket to go straight to the director's page, got %v", f.posts)
+	}
+}
+
+// waitForPosts waits for the running escalator to have posted n messages
+func (f *fakeSlack) waitForPosts(t *testing.T, n int) []post {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		f.mu.Lock()
+		posts := append([]post(nil), f.posts...)
+		f.mu.Unlock()
+		if len(posts) >= n {
+			return posts
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for %d posts, got %v", n, posts)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestRunFollowsChain(t *testing.T) {
+	c := clock.NewFake(time.Date(2026, 10, 14, 9, 0, 0, 0, time.UTC))
+	s := store.NewMemory()
+	s.CreateTicket(context.Background(), &ticket.Ticket{ID: "1", Queue: "it", Title: "VPN", Assignee: "U1", ChannelID: "C1", ThreadTS: "1.1", CreatedAt: c.Now()})
+	chains, _ := ParseChains([]string{"it:assignee:30m:thread", "it:lead:30m:dm:U2", "it:director:30m:page:U3"})
+	f := &fakeSlack{}
+	e := &Escalator{Store: s, Slack: f, Chains: chains, Clock: c}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go e.Run(ctx, 30*time.Minute, t.Errorf)
+	c.BlockUntil(1)
+
+	for i, expected := range []post{{channel: "C1"}, {channel: "U2"}, {channel: "U3", urgent: true}} {
+		c.Advance(30 * time.Minute)
+		p := f.waitForPosts(t, i+1)[i]
+		if p.channel != expected.channel || p.urgent != expected.urgent {
+			t.Errorf("Expected level %d to be sent to %s, got %v", i+1, expected.channel, p)
+		}
+		if waited := fmt.Sprintf("for %s", time.Duration(i+1)*30*time.Minute); !strings.Contains(p.text, waited) {
+			t.Errorf("Expected level %d to say the ticket waited %s, got %q", i+1, waited, p.text)
+		}
+	}
+}
+
+func TestEscalateCritical(t *testing.T) {
+	created := time.Now().Add(-time.Hour)
+	s := store.NewMemory()
+	s.CreateTicket(context.Background(), &ticket.Ticket{ID: "1", Queue: "it", Title: "Outage", Priority: ticket.P1, ChannelID: "C1", ThreadTS: "1.1", CreatedAt: created})
+	chains, _ := ParseChains([]string{"it:lead:30m:dm:U2+U3", "it:director:30m:page:U4"})
+	f := &fakeSlack{}
+	e := &Escalator{Store: s, Slack: f, Chains: chains}
+
+	e.Escalate(context.Background(), created.Add(31*time.Minute))
+	tk, _ := s.GetTicket(context.Background(), "1")
+	if len(f.posts) != 2 || len(tk.Notices) != 2 || tk.Notices[1].To != "U3" || tk.Notices[1].Level != "lead" {
+		t.Fatalf("Expected a notice to be recorded for each DM, got %v and %+v", f.posts, tk.Notices)
+	}
+	// A reply in the thread does not stop a critical ticket
+	tk.FirstResponseAt = created.Add(35 * time.Minute)
+	s.UpdateTicket(context.Background(), tk)
+	if _, err := Acknowledge(context.Background(), s, "1", "U2", created.Add(40*time.Minute)); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	// A restarted escalator carries on from the notices already sent
+	e = &Escalator{Store: s, Slack: f, Chains: chains}
+	e.Escalate(context.Background(), created.Add(45*time.Minute))
+	if len(f.posts) != 2 {
+		t.Fatalf("Expected the lead not to be notified again, got %v", f.posts)
+	}
+	e.Escalate(context.Background(), created.Add(62*time.Minute))
+	if len(f.posts) != 3 || f.posts[2].channel != "U4" || !f.posts[2].urgent {
+		t.Fatalf("Expected the director to be paged while U3 has not acknowledged, got %v", f.posts)
+	}
+	tk, _ = s.GetTicket(context.Background(), "1")
+	if r := Receipts(tk); r != "Acknowledged by <@U2>. Waiting on <@U3>, <@U4>" {
+		t.Errorf("Unexpected receipts %q", r)
+	}
+	for _, u := range []string{"U3", "U4"} {
+		if tk, _ = Acknowledge(context.Background(), s, "1", u, created.Add(70*time.Minute)); tk.AcknowledgedBy != "U2" {
+			t.Errorf("Expected the first acknowledgement to be kept, got %s", tk.AcknowledgedBy)
+		}
+	}
+	if !Acknowledged(tk) {
+		t.Errorf("Expected the ticket to be acknowledged once every notice was, got %+v", tk.Notices)
+	}
+}
+
+func TestEscalateRoute(t *testing.T) {
+	created := time.Now().Add(-time.Hour)
+	s := store.NewMemory()
+	s.CreateTicket(context.Background(), &ticket.Ticket{ID: "1", Queue: "it", Assignee: "U1", CreatedAt: created})
+	chains, _ := ParseChains([]string{"it:assignee:30m:dm"})
+	f := &fakeSlack{}
+	e := &Escalator{Store: s, Slack: f, Chains: chains}
+	e.Route = func(queue string, users []string, now time.Time) []string {
+		if queue != "it" || len(users) != 1 || users[0] != "U1" {
+			t.Errorf("Unexpected route of %v for %s", users, queue)
+		}
+		return []string{"U7", "U8"}
+	}
+
+	e.Escalate(context.Background(), created.Add(31*time.Minute))
+	if len(f.posts) != 2 || f.posts[0].channel != "U7" || f.posts[1].channel != "U8" {
+		t.Errorf("Expected the escalation to go to the routed users, got %v", f.posts)
+	}
+}
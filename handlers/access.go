@@ -0,0 +1,220 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/nlopes/slack"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/skybet/go-helpdesk/access"
+	"github.com/skybet/go-helpdesk/blocks"
+	"github.com/skybet/go-helpdesk/policy"
+	"github.com/skybet/go-helpdesk/server"
+	"github.com/skybet/go-helpdesk/store"
+	"github.com/skybet/go-helpdesk/ticket"
+)
+
+// Action IDs of the buttons on the outcome of a request's grants, their value
+// is the ticket's ID and the grant's index
+const (
+	// AccessRetryActionID tries a grant which failed again
+	AccessRetryActionID = "access_retry"
+	// AccessRollbackActionID undoes a revocation
+	AccessRollbackActionID = "access_rollback"
+)
+
+// accessAdapters make the grants of approved catalog requests
+var accessAdapters access.Adapters
+
+// InitAccess sets the systems catalog requests grant access in
+func InitAccess(a access.Adapters) {
+	accessAdapters = a
+}
+
+// grantAccess makes the grants of an approved request once the handler has
+// responded, then posts how each went in its thread. The request is resolved
+// if that fulfils it.
+func grantAccess(ctx context.Context, t *ticket.Ticket, by string) {
+	if len(t.Request.Grants) == 0 {
+		return
+	}
+	// The request's context is cancelled once the handler returns, the grants
+	// keep its values without it
+	ctx = context.WithoutCancel(ctx)
+	async(func() {
+		now := clk.Now()
+		made := map[int]ticket.Grant{}
+		for i, g := range t.Request.Grants {
+			if !g.Done() {
+				made[i] = accessAdapters.Apply(ctx, g, now)
+			}
+		}
+		stored, err := updateGrants(ctx, t.ID, func(t *ticket.Ticket) bool {
+			for i, g := range made {
+				if !t.Request.Grants[i].Done() {
+					t.Request.Grants[i] = g
+				}
+			}
+			return true
+		})
+		if err != nil {
+			log.Errorf("Failed to record the grants of ticket %s: %s", t.ID, err)
+			return
+		}
+		opts := append(grantsMessage(stored), slack.MsgOptionTS(stored.ThreadTS))
+		if _, _, err := slackIn(ctx).PostMessage(stored.ChannelID, opts...); err != nil {
+			log.Errorf("Failed to post the grants of ticket %s: %s", t.ID, err)
+		}
+		resolveFulfilled(ctx, stored, by)
+	})
+}
+
+// updateGrants applies change to the ticket with id in a transaction, storing
+// it if change returns true
+func updateGrants(ctx context.Context, id string, change func(t *ticket.Ticket) bool) (*ticket.Ticket, error) {
+	var t *ticket.Ticket
+	err := tickets.Tx(ctx, func(tx store.Store) error {
+		var err error
+		if t, err = tx.GetTicket(ctx, id); err != nil {
+			return err
+		}
+		if !change(t) {
+			return nil
+		}
+		t.UpdatedAt = clk.Now()
+		return tx.UpdateTicket(ctx, t)
+	})
+	if t == nil {
+		t = &ticket.Ticket{ID: id}
+	}
+	return t, err
+}
+
+// resolveFulfilled resolves a request once its checklist is done and its
+// grants made
+func resolveFulfilled(ctx context.Context, t *ticket.Ticket, by string) {
+	if !t.Request.Fulfilled() || !t.Status.Open() {
+		return
+	}
+	if _, err := machine.Move(ctx, tickets, t.ID, ticket.StatusResolved, by, clk.Now()); err != nil {
+		log.Errorf("Failed to resolve fulfilled ticket %s: %s", t.ID, err)
+	}
+}
+
+// grantsMessage renders how each grant of a request went, with a button to
+// retry those which failed and to roll back revocations
+func grantsMessage(t *ticket.Ticket) []slack.MsgOption {
+	var lines []string
+	set := []slack.Block{slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType, "*Access*", false, false), nil, nil)}
+	for i, g := range t.Request.Grants {
+		text := access.Describe(g)
+		lines = append(lines, text)
+		var accessory *slack.Accessory
+		value := t.ID + ":" + strconv.Itoa(i)
+		switch {
+		case g.RolledBack():
+			text = ":leftwards_arrow_with_hook: " + text
+		case g.Done():
+			text = ":white_check_mark: " + text
+			if g.Revoke {
+				accessory = slack.NewAccessory(slack.NewButtonBlockElement(AccessRollbackActionID, value, slack.NewTextBlockObject(slack.PlainTextType, "Roll back", false, false)))
+			}
+		default:
+			text = ":x: " + text
+			accessory = slack.NewAccessory(slack.NewButtonBlockElement(AccessRetryActionID, value, slack.NewTextBlockObject(slack.PlainTextType, "Retry", false, false)))
+		}
+		set = append(set, slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType, text, false, false), nil, accessory))
+	}
+	return []slack.MsgOption{slack.MsgOptionText(strings.Join(lines, "\n"), false), slack.MsgOptionBlocks(set...)}
+}
+
+// mayChangeAccess reports whether user may retry or roll back the grants of
+// t: the approvers of its item and those the policy allows, but never its
+// reporter
+func mayChangeAccess(ctx context.Context, t *ticket.Ticket, user string) bool {
+	if t.Reporter == user {
+		return false
+	}
+	if it := services.Get(t.Request.Item); it != nil {
+		for _, step := range it.Approvals {
+			for _, id := range step.Approvers {
+				if id == user {
+					return true
+				}
+			}
+		}
+	}
+	return allowed(ctx, user, policy.Access)
+}
+
+// AccessAction handles the buttons retrying a grant which failed and rolling
+// back a revocation, for the approvers of the request and the admins
+// allowed by the policy.
+func AccessAction(res *server.Response, req *server.Request, ctx interface{}) error {
+	ic, ok := ctx.(*slack.InteractionCallback)
+	if !ok {
+		return fmt.Errorf("Expected a *slack.InteractionCallback to be passed to the handler")
+	}
+	action, err := blocks.ActionOf(ic)
+	if err != nil {
+		return err
+	}
+	parts := strings.SplitN(action.Value, ":", 2)
+	i, err := strconv.Atoi(parts[len(parts)-1])
+	if len(parts) != 2 || err != nil {
+		return fmt.Errorf("Invalid grant: %q", action.Value)
+	}
+	user := ic.User.ID
+	t, err := tickets.GetTicket(req.Context(), parts[0])
+	if err != nil {
+		return fmt.Errorf("Failed to get ticket %s: %s", parts[0], err)
+	}
+	if i < 0 || i >= len(t.Request.Grants) {
+		return fmt.Errorf("Ticket %s has no grant %d", t.ID, i)
+	}
+	if !mayChangeAccess(req.Context(), t, user) {
+		if _, _, err := slackIn(req.Context()).PostMessage(ic.Channel.ID, slack.MsgOptionPostEphemeral(user), slack.MsgOptionText("Sorry, only the request's approvers and helpdesk admins can change its access", false)); err != nil {
+			log.Errorf("Failed to tell %s they can not change the access of ticket %s: %s", user, t.ID, err)
+		}
+		return nil
+	}
+
+	g, now, rollback := t.Request.Grants[i], clk.Now(), action.ActionID == AccessRollbackActionID
+	changed := false
+	switch {
+	case rollback:
+		err = accessAdapters.Rollback(req.Context(), &g, user, now)
+		if err != nil && err != access.ErrNothingToRollBack {
+			if _, _, err := slackIn(req.Context()).PostMessage(ic.Channel.ID, slack.MsgOptionPostEphemeral(user), slack.MsgOptionText(fmt.Sprintf("Failed to roll back: %s", err), false)); err != nil {
+				log.Errorf("Failed to tell %s the rollback of ticket %s failed: %s", user, t.ID, err)
+			}
+			return nil
+		}
+		changed = err == nil
+	case !g.Done():
+		g, changed = accessAdapters.Apply(req.Context(), g, now), true
+	}
+	t, err = updateGrants(req.Context(), t.ID, func(t *ticket.Ticket) bool {
+		// Someone else may have pressed the button first
+		if !changed || t.Request.Grants[i].RolledBack() || (!rollback && t.Request.Grants[i].Done()) {
+			return false
+		}
+		t.Request.Grants[i] = g
+		return true
+	})
+	if err != nil {
+		return fmt.Errorf("Failed to record the grants of ticket %s: %s", t.ID, err)
+	}
+	if ic.Channel.ID != "" && ic.Message.Timestamp != "" {
+		if _, _, _, err := slackIn(req.Context()).UpdateMessage(ic.Channel.ID, ic.Message.Timestamp, grantsMessage(t)...); err != nil {
+			return fmt.Errorf("Failed to update the grants of ticket %s: %s", t.ID, err)
+		}
+	}
+	if !rollback {
+		resolveFulfilled(req.Context(), t, user)
+	}
+	return nil
+}
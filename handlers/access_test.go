@@ -0,0 +1,162 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/nlopes/slack"
+
+	"github.com/skybet/go-helpdesk/access"
+	"github.com/skybet/go-helpdesk/catalog"
+	"github.com/skybet/go-helpdesk/clock"
+	"github.com/skybet/go-helpdesk/mocks"
+	"github.com/skybet/go-helpdesk/server"
+	"github.com/skybet/go-helpdesk/store"
+	"github.com/skybet/go-helpdesk/ticket"
+)
+
+// fakeGroups records the members of its groups
+type fakeGroups struct {
+	members map[string]bool
+	err     error
+}
+
+func (f *fakeGroups) Add(ctx context.Context, group, member string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if f.err != nil {
+		return f.err
+	}
+	f.members[group+"/"+member] = true
+	return nil
+}
+
+func (f *fakeGroups) Remove(ctx context.Context, group, member string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if f.err != nil {
+		return f.err
+	}
+	delete(f.members, group+"/"+member)
+	return nil
+}
+
+// deferAsync holds the work handlers pass to async until the function it
+// returns is called, which first cancels the context of req as net/http does
+// once the handler has returned
+func deferAsync(t *testing.T, req *server.Request) func() {
+	ctx, cancel := context.WithCancel(req.Context())
+	req.Request = req.Request.WithContext(ctx)
+	var pending []func()
+	async = func(f func()) { pending = append(pending, f) }
+	t.Cleanup(func() {
+		cancel()
+		async = func(f func()) { go f() }
+	})
+	return func() {
+		cancel()
+		for len(pending) > 0 {
+			f := pending[0]
+			pending = pending[1:]
+			f()
+		}
+	}
+}
+
+func TestAccessRevocation(t *testing.T) {
+	InitClock(clock.NewFake(time.Date(2026, 10, 14, 9, 0, 0, 0, time.UTC)))
+	defer InitClock(nil)
+	c, err := catalog.New(&catalog.Catalog{Channel: "CREQ", Items: []*catalog.Item{{
+		ID: "offboard", Name: "Remove access", Queue: "it", Revoke: true,
+		Fields:    []catalog.Field{{Name: "login", Label: "GitHub username"}, {Name: "email", Label: "Email"}},
+		Approvals: []catalog.Step{{Name: "manager", Approvers: []string{"UMGR"}}},
+		Access:    []catalog.Access{{System: access.GitHub, Group: "platform", Member: "login"}, {System: access.Google, Group: "eng@example.com", Member: "email"}},
+	}}})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	InitCatalog(c)
+	defer InitCatalog(nil)
+	github := &fakeGroups{members: map[string]bool{"platform/octocat": true}}
+	google := &fakeGroups{members: map[string]bool{}, err: errors.New("quota exceeded")}
+	InitAccess(access.Adapters{access.GitHub: github, access.Google: google})
+	defer InitAccess(nil)
+	InitAnnouncements(nil, []string{"UAGENT"})
+	defer InitAnnouncements(nil, nil)
+
+	tk := &ticket.Ticket{ID: "1", Reporter: "U1", ChannelID: "CREQ", ThreadTS: "1.1", Status: ticket.StatusNew}
+	c.Get("offboard").Apply(tk, []ticket.Answer{{Field: "login", Value: "octocat"}, {Field: "email", Value: "octo@example.com"}})
+	s := store.NewMemory()
+	s.CreateTicket(context.Background(), tk)
+	InitTickets(s)
+	mockSlack := mocks.NewSlack(t)
+	mockSlack.ExpectPostMessage().ToChannel("U1").WithText("has been approved")
+	mockSlack.ExpectPostMessage().ToChannel("CREQ").WithText("Removed octocat from platform in GitHub").WithText("Roll back").
+		WithText("Could not remove octo@example.com from eng@example.com in Google Workspace: quota exceeded").WithText("Retry").ReturnTS("2.1")
+	Init(mockSlack)
+
+	req, res, _ := newTestRequest()
+	afterResponse := deferAsync(t, req)
+	ic := &slack.InteractionCallback{Type: slack.InteractionTypeBlockActions}
+	ic.ActionCallback.BlockActions = []*slack.BlockAction{{ActionID: CatalogApproveActionID, Value: "1"}}
+	ic.User.ID = "UMGR"
+	if err := CatalogDecision(res, req, ic); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	afterResponse()
+	if github.members["platform/octocat"] {
+		t.Errorf("Expected octocat to be removed from the team once approved")
+	}
+	if tk, _ := s.GetTicket(context.Background(), "1"); tk.Status != ticket.StatusNew || !tk.Request.Grants[0].Done() || tk.Request.Grants[1].Error != "quota exceeded" {
+		t.Fatalf("Expected the failed grant to keep the ticket open, got %s %+v", tk.Status, tk.Request.Grants)
+	}
+
+	press := func(user, actionID, value string) {
+		ic := &slack.InteractionCallback{Type: slack.InteractionTypeBlockActions}
+		ic.ActionCallback.BlockActions = []*slack.BlockAction{{ActionID: actionID, Value: value}}
+		ic.User.ID = user
+		ic.Channel.ID, ic.Message.Timestamp = "CREQ", "2.1"
+		req, res, _ := newTestRequest()
+		if err := AccessAction(res, req, ic); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+	}
+	// Neither the reporter nor anyone else who is not an approver or admin
+	for _, user := range []string{"U1", "U2"} {
+		mockSlack.ExpectPostMessage().ToChannel("CREQ").Ephemeral(user).WithText("only the request's approvers and helpdesk admins")
+		press(user, AccessRetryActionID, "1:1")
+		mockSlack.ExpectPostMessage().ToChannel("CREQ").Ephemeral(user).WithText("only the request's approvers and helpdesk admins")
+		press(user, AccessRollbackActionID, "1:0")
+	}
+	if tk, _ := s.GetTicket(context.Background(), "1"); tk.Request.Grants[0].RolledBack() || tk.Request.Grants[1].Done() {
+		t.Fatalf("Expected refused presses to change nothing, got %+v", tk.Request.Grants)
+	}
+
+	google.err = nil
+	mockSlack.ExpectUpdateMessage().ToChannel("CREQ").ForTS("2.1").WithText(":white_check_mark: Removed octo@example.com from eng@example.com")
+	press("UMGR", AccessRetryActionID, "1:1")
+	if tk, _ := s.GetTicket(context.Background(), "1"); tk.Status != ticket.StatusResolved {
+		t.Errorf("Expected the ticket to be resolved once every grant is made, got %s", tk.Status)
+	}
+
+	mockSlack.ExpectUpdateMessage().ToChannel("CREQ").ForTS("2.1").WithText("rolled back by \\u003c@UAGENT\\u003e")
+	press("UAGENT", AccessRollbackActionID, "1:0")
+	if !github.members["platform/octocat"] {
+		t.Errorf("Expected the rollback to add octocat to the team again")
+	}
+	if tk, _ := s.GetTicket(context.Background(), "1"); tk.Request.Grants[0].RolledBackBy != "UAGENT" {
+		t.Errorf("Expected the rollback to be recorded, got %+v", tk.Request.Grants[0])
+	}
+
+	// Rolling back twice changes nothing
+	delete(github.members, "platform/octocat")
+	mockSlack.ExpectUpdateMessage().ToChannel("CREQ").ForTS("2.1")
+	press("UAGENT", AccessRollbackActionID, "1:0")
+	if github.members["platform/octocat"] {
+		t.Errorf("Expected a change to be rolled back only once")
+	}
+}
@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/nlopes/slack"
+
+	"github.com/skybet/go-helpdesk/report"
+	"github.com/skybet/go-helpdesk/server"
+)
+
+// agingLimit is the most tickets /hd aging lists
+const agingLimit = 20
+
+var sweeper *report.Sweeper
+
+// InitSweeper sets the sweeper whose thresholds the aging report flags tickets
+// against
+func InitSweeper(s *report.Sweeper) {
+	sweeper = s
+}
+
+// Aging handles /hd aging [queue], listing the open tickets which have gone
+// longest without activity
+func Aging(res *server.Response, req *server.Request, ctx interface{}) error {
+	sc, ok := ctx.(slack.SlashCommand)
+	if !ok {
+		return fmt.Errorf("Expected a slack.SlashCommand to be passed to the handler")
+	}
+	if sweeper == nil {
+		return fmt.Errorf("The stale ticket sweeper has not been initialised")
+	}
+	aged, err := sweeper.Aging(req.Context(), clk.Now())
+	if err != nil {
+		return fmt.Errorf("Failed to build aging report: %s", err)
+	}
+	if args := strings.Fields(sc.Text); len(args) > 1 {
+		var queue []report.Aged
+		for _, a := range aged {
+			if a.Ticket.Queue == args[1] {
+				queue = append(queue, a)
+			}
+		}
+		aged = queue
+	}
+	if len(aged) == 0 {
+		res.Text(http.StatusOK, tr(sc, "There are no open tickets"))
+		return nil
+	}
+	header := tr(sc, "*The %d longest idle of %d open tickets*", agingLimit, len(aged))
+	if len(aged) <= agingLimit {
+		header = tr(sc, "*%d open tickets by idle time*", len(aged))
+	} else {
+		aged = aged[:agingLimit]
+	}
+	return res.JSON(http.StatusOK, slack.Msg{ResponseType: slack.ResponseTypeEphemeral, Text: header + "\n" + report.AgingText(aged, ticketLinks, styles.Style(sc.UserID))})
+}
@@ -0,0 +1,30 @@
+package handlers
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nlopes/slack"
+
+	"github.com/skybet/go-helpdesk/report"
+	"github.com/skybet/go-helpdesk/store"
+	"github.com/skybet/go-helpdesk/ticket"
+)
+
+func TestAging(t *testing.T) {
+	s := store.NewMemory()
+	s.CreateTicket(context.Background(), &ticket.Ticket{Title: "VPN", Queue: "it"})
+	s.CreateTicket(context.Background(), &ticket.Ticket{Title: "Payslip", Queue: "hr"})
+	InitSweeper(&report.Sweeper{Store: s, NudgeAfter: time.Hour})
+	defer InitSweeper(nil)
+	req, res, w := newTestRequest()
+
+	if err := Helpdesk(res, req, slack.SlashCommand{Command: "/hd", Text: "aging it"}); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if body := w.Body.String(); !strings.Contains(body, "1 open tickets") || !strings.Contains(body, "VPN") || strings.Contains(body, "Payslip") {
+		t.Errorf("Expected the it queue's tickets, got %s", body)
+	}
+}
@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/nlopes/slack"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/skybet/go-helpdesk/announce"
+	"github.com/skybet/go-helpdesk/policy"
+	"github.com/skybet/go-helpdesk/server"
+	"github.com/skybet/go-helpdesk/views"
+)
+
+// AnnounceCallbackID is the callback ID of the announcement modal
+const AnnounceCallbackID = "announce"
+
+var (
+	broadcaster *announce.Broadcaster
+	admins      = map[string]bool{}
+	// async runs slow work after the handler has responded to Slack
+	async = func(f func()) { go f() }
+)
+
+// InitAnnouncements sets the broadcaster used by /hd announce and the IDs of
+// the users allowed to use it
+func InitAnnouncements(b *announce.Broadcaster, adminIDs []string) {
+	broadcaster = b
+	admins = map[string]bool{}
+	for _, id := range adminIDs {
+		admins[id] = true
+	}
+}
+
+// Announce handles /hd announce, opening a modal to compose an announcement.
+// /hd announce edit <id> opens the modal to edit an announcement already sent.
+func Announce(res *server.Response, req *server.Request, ctx interface{}) error {
+	sc, ok := ctx.(slack.SlashCommand)
+	if !ok {
+		return fmt.Errorf("Expected a slack.SlashCommand to be passed to the handler")
+	}
+	if !allowed(req.Context(), sc.UserID, policy.Announce) || broadcaster == nil {
+		res.Text(http.StatusOK, tr(sc, "Sorry, only helpdesk admins can send announcements"))
+		return nil
+	}
+
+	text, id := "", ""
+	if args := strings.Fields(sc.Text); len(args) == 3 && args[1] == "edit" {
+		a, ok := broadcaster.Get(args[2])
+		if !ok {
+			res.Text(http.StatusOK, tr(sc, "There is no announcement %s", args[2]))
+			return nil
+		}
+		text, id = a.Text, a.ID
+	}
+	if _, err := slackIn(req.Context()).OpenView(sc.TriggerID, announceModal(text, id)); err != nil {
+		return fmt.Errorf("Failed to open announcement modal: %s", err)
+	}
+	return nil
+}
+
+func announceModal(text, id string) *views.View {
+	title := "New announcement"
+	if id != "" {
+		title = "Edit announcement"
+	}
+	input := views.NewPlainTextInput("text")
+	input.Multiline = true
+	input.InitialValue = text
+	v := views.NewModal(AnnounceCallbackID, title)
+	v.Submit = slack.NewTextBlockObject(slack.PlainTextType, "Send", false, false)
+	v.PrivateMetadata = id
+	v.Blocks.BlockSet = []slack.Block{
+		slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType, fmt.Sprintf("This will be posted to %d channels.", len(broadcaster.Channels)), false, false), nil, nil),
+		views.NewInputBlock("announcement", "Announcement", input),
+	}
+	return v
+}
+
+// AnnounceSubmission sends or edits the announcement composed in the modal
+// opened by Announce. Delivery is paced so it carries on after the modal has
+// closed, the author is sent a DM with the results when it finishes.
+func AnnounceSubmission(res *server.Response, req *server.Request, ctx interface{}) error {
+	sub, ok := ctx.(*views.Submission)
+	if !ok {
+		return fmt.Errorf("Expected a *views.Submission to be passed to the handler")
+	}
+	if !allowed(req.Context(), sub.User.ID, policy.Announce) || broadcaster == nil {
+		return fmt.Errorf("User %s is not allowed to send announcements", sub.User.ID)
+	}
+	text := strings.TrimSpace(sub.View.State.Get("announcement", "text").String())
+	if text == "" {
+		return views.ValidationErrors{"announcement": "Enter the announcement to send"}
+	}
+
+	user, id := sub.User.ID, sub.View.PrivateMetadata
+	// Delivery carries on after the request's context is cancelled
+	detached := context.WithoutCancel(req.Context())
+	async(func() {
+		var a *announce.Announcement
+		var err error
+		if id == "" {
+			a, err = broadcaster.Send(detached, user, text)
+		} else {
+			a, err = broadcaster.Edit(detached, id, text)
+		}
+		if err != nil {
+			log.Errorf("Failed to deliver announcement: %s", err)
+			return
+		}
+		summary := a.Summary() + fmt.Sprintf("\nUse `/hd announce edit %s` to change it.", a.ID)
+		if _, _, err := dm().PostMessage(user, slack.MsgOptionText(summary, false)); err != nil {
+			log.Errorf("Failed to send announcement summary to %s: %s", user, err)
+		}
+	})
+	return nil
+}
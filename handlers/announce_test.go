@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"io/ioutil"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nlopes/slack"
+	"github.com/skybet/go-helpdesk/announce"
+	"github.com/skybet/go-helpdesk/mocks"
+	"github.com/skybet/go-helpdesk/server"
+	"github.com/skybet/go-helpdesk/views"
+	"github.com/stretchr/testify/mock"
+)
+
+func newTestRequest() (*server.Request, *server.Response, *httptest.ResponseRecorder) {
+	r := httptest.NewRequest("POST", "/slack", nil)
+	w := httptest.NewRecorder()
+	return &server.Request{Request: r}, &server.Response{ResponseWriter: w}, w
+}
+
+func TestAnnounceAdminOnly(t *testing.T) {
+	mockSlack := &mocks.SlackWrapper{}
+	Init(mockSlack)
+	InitAnnouncements(announce.NewBroadcaster(mockSlack, []string{"C1"}), []string{"UADMIN"})
+	req, res, w := newTestRequest()
+
+	err := Helpdesk(res, req, slack.SlashCommand{Command: "/hd", Text: "announce", UserID: "U1", TriggerID: "T1"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	body, _ := ioutil.ReadAll(w.Body)
+	if !strings.Contains(string(body), "only helpdesk admins") {
+		t.Errorf("Expected non admins to be refused, got %s", body)
+	}
+	mockSlack.AssertNotCalled(t, "OpenView", mock.Anything, mock.Anything)
+}
+
+func TestAnnounceOpensModal(t *testing.T) {
+	mockSlack := &mocks.SlackWrapper{}
+	mockSlack.On("OpenView", "T1", mock.MatchedBy(func(v *views.View) bool {
+		return v.CallbackID == AnnounceCallbackID && v.PrivateMetadata == ""
+	})).Return(&views.View{ID: "V1"}, nil)
+	Init(mockSlack)
+	InitAnnouncements(announce.NewBroadcaster(mockSlack, []string{"C1"}), []string{"UADMIN"})
+	req, res, _ := newTestRequest()
+
+	if err := Helpdesk(res, req, slack.SlashCommand{Command: "/hd", Text: "announce", UserID: "UADMIN", TriggerID: "T1"}); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	mockSlack.AssertExpectations(t)
+}
+
+func TestAnnounceSubmission(t *testing.T) {
+	mockSlack := &mocks.SlackWrapper{}
+	mockSlack.On("PostMessage", "C1", mock.Anything).Return("C1", "1.1", nil)
+	mockSlack.On("PostMessage", "C2", mock.Anything).Return("C2", "1.2", nil)
+	mockSlack.On("PostMessage", "UADMIN", mock.Anything).Return("D1", "2.1", nil)
+	Init(mockSlack)
+	b := announce.NewBroadcaster(mockSlack, []string{"C1", "C2"})
+	b.Interval = time.Millisecond
+	InitAnnouncements(b, []string{"UADMIN"})
+	req, res, _ := newTestRequest()
+	afterResponse := deferAsync(t, req)
+
+	sub := &views.Submission{}
+	sub.User.ID = "UADMIN"
+	sub.View.State = &views.State{Values: map[string]map[string]views.Value{"announcement": {"text": {Value: " "}}}}
+	if _, ok := AnnounceSubmission(res, req, sub).(views.ValidationErrors); !ok {
+		t.Fatalf("Expected an empty announcement to be rejected")
+	}
+
+	sub.View.State.Values["announcement"]["text"] = views.Value{Value: "Lunch is served"}
+	if err := AnnounceSubmission(res, req, sub); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	// Delivery is paced past the response to the submission
+	afterResponse()
+	mockSlack.AssertExpectations(t)
+	a, ok := b.Get("1")
+	if !ok || len(a.Deliveries) != 2 || a.Deliveries[0].Timestamp != "1.1" || a.Deliveries[1].Timestamp != "1.2" {
+		t.Fatalf("Expected the delivery to be recorded, got %+v", a)
+	}
+
+	mockSlack.On("UpdateMessage", "C1", "1.1", mock.Anything).Return("C1", "1.1", "", nil)
+	mockSlack.On("UpdateMessage", "C2", "1.2", mock.Anything).Return("C2", "1.2", "", nil)
+	sub.View.PrivateMetadata = "1"
+	sub.View.State.Values["announcement"]["text"] = views.Value{Value: "Lunch is cancelled"}
+	req, res, _ = newTestRequest()
+	afterResponse = deferAsync(t, req)
+	if err := AnnounceSubmission(res, req, sub); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	afterResponse()
+	mockSlack.AssertCalled(t, "UpdateMessage", "C1", "1.1", mock.Anything)
+	mockSlack.AssertCalled(t, "UpdateMessage", "C2", "1.2", mock.Anything)
+	if a, _ := b.Get("1"); a.Text != "Lunch is cancelled" {
+		t.Errorf("Expected the edit to be recorded, got %+v", a)
+	}
+}
@@ -0,0 +1,320 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nlopes/slack"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/skybet/go-helpdesk/admin"
+	"github.com/skybet/go-helpdesk/approval"
+	"github.com/skybet/go-helpdesk/audit"
+	"github.com/skybet/go-helpdesk/blocks"
+	"github.com/skybet/go-helpdesk/policy"
+	"github.com/skybet/go-helpdesk/server"
+	"github.com/skybet/go-helpdesk/store"
+)
+
+var (
+	approvals = approval.New(15 * time.Minute)
+	auditLog  = &audit.Log{}
+)
+
+// InitApprovals sets the requests waiting for a second admin's approval and
+// the log recording the sensitive commands which were run
+func InitApprovals(r *approval.Requests, l *audit.Log) {
+	approvals = r
+	auditLog = l
+}
+
+// sensitive are the subcommands which only run once another admin approves
+// them, each fills in the outcome of its audit log entry and optionally
+// returns a file to send the admin who asked
+var sensitive = map[string]func(r *approval.Request, args []string, e *audit.Entry) (*slack.FileUploadParameters, error){
+	"bulk-close": runBulkClose,
+	"export":     runExport,
+	"erase":      runErase,
+}
+
+// BulkClose handles /hd bulk-close <queue|query>, closing every open ticket in
+// the queue, or matching the query, once another admin approves it
+func BulkClose(res *server.Response, req *server.Request, ctx interface{}) error {
+	sc, ok := ctx.(slack.SlashCommand)
+	if !ok {
+		return fmt.Errorf("Expected a slack.SlashCommand to be passed to the handler")
+	}
+	if !allowed(req.Context(), sc.UserID, policy.BulkClose) {
+		res.Text(http.StatusOK, tr(sc, "Sorry, only helpdesk admins can close a whole queue"))
+		return nil
+	}
+	args := strings.Fields(sc.Text)
+	if len(args) < 2 {
+		res.Text(http.StatusOK, tr(sc, "Usage: %s bulk-close <queue|query>", sc.Command))
+		return nil
+	}
+	if _, err := commandFilter(args[1:], sc.UserID); err != nil {
+		res.Text(http.StatusOK, tr(sc, "That query could not be understood: %s", err))
+		return nil
+	}
+	return requestApproval(res, sc)
+}
+
+// Export handles /hd export [queue|query], sending the admin a CSV of every
+// ticket, or of the queue's or those matching the query, once another admin
+// approves it
+func Export(res *server.Response, req *server.Request, ctx interface{}) error {
+	sc, ok := ctx.(slack.SlashCommand)
+	if !ok {
+		return fmt.Errorf("Expected a slack.SlashCommand to be passed to the handler")
+	}
+	if !allowed(req.Context(), sc.UserID, policy.Export) {
+		res.Text(http.StatusOK, tr(sc, "Sorry, only helpdesk admins can export tickets"))
+		return nil
+	}
+	if _, err := commandFilter(strings.Fields(sc.Text)[1:], sc.UserID); err != nil {
+		res.Text(http.StatusOK, tr(sc, "That query could not be understood: %s", err))
+		return nil
+	}
+	return requestApproval(res, sc)
+}
+
+// Erase handles /hd erase <@user>, removing the user from every ticket once
+// another admin approves it
+func Erase(res *server.Response, req *server.Request, ctx interface{}) error {
+	sc, ok := ctx.(slack.SlashCommand)
+	if !ok {
+		return fmt.Errorf("Expected a slack.SlashCommand to be passed to the handler")
+	}
+	if !allowed(req.Context(), sc.UserID, policy.Erase) {
+		res.Text(http.StatusOK, tr(sc, "Sorry, only helpdesk admins can erase users"))
+		return nil
+	}
+	args := strings.Fields(sc.Text)
+	if len(args) != 2 || userID(args[1]) == "" {
+		res.Text(http.StatusOK, tr(sc, "Usage: %s erase <@user>", sc.Command))
+		return nil
+	}
+	return requestApproval(res, sc)
+}
+
+// ApprovalApprove handles the button approving a sensitive command, running
+// it and recording both admins in the audit log. Only admins other than the
+// one who asked can approve it, before the window closes.
+func ApprovalApprove(res *server.Response, req *server.Request, ctx interface{}) error {
+	ic, ok := ctx.(*slack.InteractionCallback)
+	if !ok {
+		return fmt.Errorf("Expected a *slack.InteractionCallback to be passed to the handler")
+	}
+	clicked, err := blocks.ActionOf(ic)
+	if err != nil {
+		return err
+	}
+	if !allowed(req.Context(), ic.User.ID, policy.Approve) {
+		return fmt.Errorf("User %s is not allowed to approve commands", ic.User.ID)
+	}
+	r, err := approvals.Approve(clicked.Value, ic.User.ID, clk.Now())
+	switch err {
+	case nil:
+	case approval.ErrExpired:
+		record(audit.Entry{At: clk.Now(), Action: action(r), Actor: r.RequestedBy, ApprovedBy: ic.User.ID, Outcome: "not run, the approval window had closed"})
+		settle(r, fmt.Sprintf("<@%s> asked to run `%s`, the request expired before it was approved", r.RequestedBy, action(r)))
+		return tellRequester(r, fmt.Sprintf("Your request to run `%s` expired before it was approved, run it again if it is still needed", action(r)), nil)
+	case approval.ErrNotFound:
+		if ic.Channel.ID != "" && ic.Message.Timestamp != "" {
+			if _, _, _, err := slackIn(req.Context()).UpdateMessage(ic.Channel.ID, ic.Message.Timestamp, slack.MsgOptionText("This request has already been handled or has expired", false), slack.MsgOptionBlocks()); err != nil {
+				return fmt.Errorf("Failed to update approval request: %s", err)
+			}
+		}
+		return nil
+	case approval.ErrSelfApproval:
+		if ic.Channel.ID == "" {
+			return nil
+		}
+		if _, _, err := slackIn(req.Context()).PostMessage(ic.Channel.ID, slack.MsgOptionPostEphemeral(ic.User.ID), slack.MsgOptionText("You cannot approve your own request, another admin has to", false)); err != nil {
+			return fmt.Errorf("Failed to tell %s they cannot approve: %s", ic.User.ID, err)
+		}
+		return nil
+	default:
+		return fmt.Errorf("Failed to approve request: %s", err)
+	}
+
+	args := strings.Fields(r.Text)
+	e := audit.Entry{At: clk.Now(), Action: action(r), Actor: r.RequestedBy, ApprovedBy: ic.User.ID}
+	file, err := sensitive[strings.ToLower(args[0])](r, args, &e)
+	if err != nil {
+		e.Outcome = fmt.Sprintf("failed: %s", err)
+	}
+	if file != nil {
+		if c, ok := file.Reader.(io.Closer); ok {
+			defer c.Close()
+		}
+	}
+	record(e)
+	settle(r, fmt.Sprintf("<@%s> asked to run `%s`, approved by <@%s>: %s", r.RequestedBy, action(r), ic.User.ID, e.Outcome))
+	return tellRequester(r, fmt.Sprintf("<@%s> approved `%s`: %s", ic.User.ID, action(r), e.Outcome), file)
+}
+
+// record writes an entry to the audit log, logging the error if it fails
+func record(e audit.Entry) {
+	if err := auditLog.Record(e); err != nil {
+		log.Errorf("Failed to record %s in the audit log: %s", e.Action, err)
+	}
+}
+
+// requestApproval holds back a sensitive command, asking every other admin to
+// approve it in a DM
+func requestApproval(res *server.Response, sc slack.SlashCommand) error {
+	var approvers []string
+	for id := range admins {
+		if id != sc.UserID {
+			approvers = append(approvers, id)
+		}
+	}
+	if len(approvers) == 0 {
+		res.Text(http.StatusOK, tr(sc, "This command needs another admin to approve it, but you are the only admin"))
+		return nil
+	}
+	sort.Strings(approvers)
+	r := &approval.Request{Command: sc.Command, Text: sc.Text, RequestedBy: sc.UserID, ChannelID: sc.ChannelID, TeamID: sc.TeamID}
+	approvals.Add(r, clk.Now())
+	text := fmt.Sprintf("<@%s> wants to run `%s`, it needs another admin to approve it before %s", r.RequestedBy, action(r), slackDate(r.ExpiresAt))
+	button := slack.NewButtonBlockElement(approval.ApproveActionID, r.ID, slack.NewTextBlockObject(slack.PlainTextType, "Approve", false, false))
+	button.Style = slack.StyleDanger
+	blocks := slack.MsgOptionBlocks(
+		slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType, text, false, false), nil, nil),
+		slack.NewActionBlock("", button),
+	)
+	asked := 0
+	for _, id := range approvers {
+		// Not sent through the notifier, the request would expire if it
+		// waited for quiet hours to end
+		ch, ts, err := slackWrapper.PostMessage(id, slack.MsgOptionText(text, false), blocks)
+		if err != nil {
+			log.Errorf("Failed to ask %s to approve request %s: %s", id, r.ID, err)
+			continue
+		}
+		approvals.Noticed(r.ID, approval.Notice{ChannelID: ch, TS: ts})
+		asked++
+	}
+	if asked == 0 {
+		return fmt.Errorf("Failed to ask any admin to approve request %s", r.ID)
+	}
+	res.Text(http.StatusOK, tr(sc, "This command needs another admin to approve it, %d admins have been asked and have until %s", asked, slackDate(r.ExpiresAt)))
+	return nil
+}
+
+// settle replaces the approval requests sent to the admins with text
+func settle(r *approval.Request, text string) {
+	for _, n := range r.Notices {
+		if _, _, _, err := slackWrapper.UpdateMessage(n.ChannelID, n.TS, slack.MsgOptionText(text, false), slack.MsgOptionBlocks()); err != nil {
+			log.Errorf("Failed to update approval request %s: %s", r.ID, err)
+		}
+	}
+}
+
+// tellRequester DMs the admin who made the request, followed by the file if
+// there is one
+func tellRequester(r *approval.Request, text string, file *slack.FileUploadParameters) error {
+	ch, _, err := slackWrapper.PostMessage(r.RequestedBy, slack.MsgOptionText(text, false))
+	if err != nil {
+		return fmt.Errorf("Failed to tell %s about request %s: %s", r.RequestedBy, r.ID, err)
+	}
+	if file == nil {
+		return nil
+	}
+	file.Channels = []string{ch}
+	if _, err := slackWrapper.UploadFile(*file); err != nil {
+		return fmt.Errorf("Failed to send %s the file for request %s: %s", r.RequestedBy, r.ID, err)
+	}
+	return nil
+}
+
+func action(r *approval.Request) string {
+	return r.Command + " " + r.Text
+}
+
+func runBulkClose(r *approval.Request, args []string, e *audit.Entry) (*slack.FileUploadParameters, error) {
+	filter, err := commandFilter(args[1:], r.RequestedBy)
+	if err != nil {
+		return nil, err
+	}
+	n, err := admin.BulkClose(store.WithTeam(context.Background(), r.TeamID), tickets, filter, clk.Now())
+	if err != nil {
+		return nil, err
+	}
+	if len(args) == 2 && filter.Queue == args[1] {
+		e.Outcome = fmt.Sprintf("closed %d tickets in %s", n, filter.Queue)
+	} else {
+		e.Outcome = fmt.Sprintf("closed %d tickets matching %s", n, strings.Join(args[1:], " "))
+	}
+	return nil, nil
+}
+
+// runExport writes the export to a temporary file rather than memory, it is
+// streamed from there to Slack and removed once it has been sent
+// runExport exports the tickets, of the queue or matching the query if one is
+// given, watermarked for the admin who asked
+func runExport(r *approval.Request, args []string, e *audit.Entry) (*slack.FileUploadParameters, error) {
+	filter, err := commandFilter(args[1:], r.RequestedBy)
+	if err != nil {
+		return nil, err
+	}
+	e.Details = admin.ExportDetails(filter, 0, fmt.Sprintf("Slack DM to %s", r.RequestedBy))
+	f, err := ioutil.TempFile("", "helpdesk-export-*.csv")
+	if err != nil {
+		return nil, fmt.Errorf("error creating export file: %s", err)
+	}
+	tmp := tempFile{f}
+	w := bufio.NewWriter(f)
+	n, err := admin.Export(store.WithTeam(context.Background(), r.TeamID), tickets, w, filter, admin.Watermark{User: r.RequestedBy, At: clk.Now()})
+	e.Details["rows"] = strconv.Itoa(n)
+	if err == nil {
+		err = w.Flush()
+	}
+	if err == nil {
+		_, err = f.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		tmp.Close()
+		return nil, err
+	}
+	file := &slack.FileUploadParameters{
+		Filename: fmt.Sprintf("tickets-%s.csv", r.RequestedAt.UTC().Format("2006-01-02")),
+		Filetype: "csv",
+		Title:    "Ticket export",
+		Reader:   tmp,
+	}
+	e.Outcome = fmt.Sprintf("exported %d tickets", n)
+	return file, nil
+}
+
+// tempFile is a file which is removed when it is closed
+type tempFile struct {
+	*os.File
+}
+
+func (f tempFile) Close() error {
+	err := f.File.Close()
+	os.Remove(f.Name())
+	return err
+}
+
+func runErase(r *approval.Request, args []string, e *audit.Entry) (*slack.FileUploadParameters, error) {
+	user := userID(args[1])
+	n, err := admin.Erase(store.WithTeam(context.Background(), r.TeamID), tickets, user, clk.Now())
+	if err != nil {
+		return nil, err
+	}
+	e.Outcome = fmt.Sprintf("removed <@%s> from %d tickets", user, n)
+	return nil, nil
+}
@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nlopes/slack"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/skybet/go-helpdesk/approval"
+	"github.com/skybet/go-helpdesk/audit"
+	"github.com/skybet/go-helpdesk/mocks"
+	"github.com/skybet/go-helpdesk/store"
+	"github.com/skybet/go-helpdesk/ticket"
+)
+
+func TestBulkCloseApproval(t *testing.T) {
+	mockSlack := mocks.NewSlack(t)
+	mockSlack.ExpectPostMessage().ToChannel("UADMIN2").WithText("wants to run `/hd bulk-close support`").ReturnTS("2.1")
+	mockSlack.ExpectPostMessage().ToChannel("UADMIN1").Ephemeral("UADMIN1").WithText("cannot approve your own request")
+	mockSlack.ExpectUpdateMessage().ToChannel("UADMIN2").ForTS("2.1").WithText("approved by <@UADMIN2>: closed 1 tickets in support")
+	mockSlack.ExpectPostMessage().ToChannel("UADMIN1").WithText("<@UADMIN2> approved")
+	Init(mockSlack)
+	s := store.NewMemory()
+	s.CreateTicket(context.Background(), &ticket.Ticket{ID: "1", Queue: "support", Status: ticket.StatusNew})
+	InitTickets(s)
+	InitAnnouncements(nil, []string{"UADMIN1", "UADMIN2"})
+	defer InitAnnouncements(nil, nil)
+	requests, log := approval.New(time.Minute), &audit.Log{}
+	InitApprovals(requests, log)
+	defer InitApprovals(approval.New(15*time.Minute), &audit.Log{})
+
+	req, res, w := newTestRequest()
+	if err := Helpdesk(res, req, slack.SlashCommand{Command: "/hd", Text: "bulk-close support", UserID: "UADMIN1"}); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if body := w.Body.String(); !strings.Contains(body, "1 admins have been asked") {
+		t.Errorf("Expected to be told the command needs approval, got %s", body)
+	}
+	if tk, _ := s.GetTicket(context.Background(), "1"); tk.Status != ticket.StatusNew {
+		t.Fatalf("Expected the queue not to be closed before approval, got %s", tk.Status)
+	}
+
+	ic := &slack.InteractionCallback{Type: slack.InteractionTypeBlockActions}
+	ic.ActionCallback.BlockActions = []*slack.BlockAction{{ActionID: approval.ApproveActionID, Value: "1"}}
+	ic.User.ID = "UADMIN1"
+	ic.Channel.ID = "UADMIN1"
+	if err := ApprovalApprove(res, req, ic); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if tk, _ := s.GetTicket(context.Background(), "1"); tk.Status != ticket.StatusNew {
+		t.Fatalf("Expected the requester not to be able to approve their own command, got %s", tk.Status)
+	}
+
+	ic.User.ID = "UADMIN2"
+	if err := ApprovalApprove(res, req, ic); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if tk, _ := s.GetTicket(context.Background(), "1"); tk.Status != ticket.StatusClosed {
+		t.Errorf("Expected the queue to be closed once approved, got %s", tk.Status)
+	}
+	entries := log.Entries()
+	if len(entries) != 1 || entries[0].Actor != "UADMIN1" || entries[0].ApprovedBy != "UADMIN2" || entries[0].Outcome != "closed 1 tickets in support" {
+		t.Errorf("Expected both admins in the audit log, got %+v", entries)
+	}
+}
+
+func TestExportApproval(t *testing.T) {
+	mockSlack := &mocks.SlackWrapper{}
+	mockSlack.On("PostMessage", "UADMIN2", mock.Anything, mock.Anything).Return("D2", "2.1", nil)
+	mockSlack.On("UpdateMessage", "D2", "2.1", mock.Anything, mock.Anything).Return("D2", "2.1", "", nil)
+	mockSlack.On("PostMessage", "UADMIN1", mock.Anything).Return("D1", "3.1", nil)
+	var exported string
+	mockSlack.On("UploadFile", mock.MatchedBy(func(p slack.FileUploadParameters) bool {
+		exported = p.Reader.(tempFile).Name()
+		b, _ := ioutil.ReadAll(p.Reader)
+		return len(p.Channels) == 1 && p.Channels[0] == "D1" && strings.Contains(string(b), "VPN") && !strings.Contains(string(b), "Payroll") && strings.Contains(string(b), ",UADMIN1,")
+	})).Return(&slack.File{}, nil)
+	Init(mockSlack)
+	s := store.NewMemory()
+	s.CreateTicket(context.Background(), &ticket.Ticket{ID: "1", Title: "VPN", Queue: "support"})
+	s.CreateTicket(context.Background(), &ticket.Ticket{ID: "2", Title: "Payroll", Queue: "hr"})
+	InitTickets(s)
+	InitAnnouncements(nil, []string{"UADMIN1", "UADMIN2"})
+	defer InitAnnouncements(nil, nil)
+	requests, log := approval.New(time.Minute), &audit.Log{}
+	InitApprovals(requests, log)
+	defer InitApprovals(approval.New(15*time.Minute), &audit.Log{})
+
+	req, res, _ := newTestRequest()
+	Helpdesk(res, req, slack.SlashCommand{Command: "/hd", Text: "export support", UserID: "UADMIN1"})
+	ic := &slack.InteractionCallback{Type: slack.InteractionTypeBlockActions}
+	ic.ActionCallback.BlockActions = []*slack.BlockAction{{ActionID: approval.ApproveActionID, Value: "1"}}
+	ic.User.ID = "UADMIN2"
+	if err := ApprovalApprove(res, req, ic); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	mockSlack.AssertNumberOfCalls(t, "UploadFile", 1)
+	if _, err := os.Stat(exported); !os.IsNotExist(err) {
+		t.Errorf("Expected the export file to be removed once sent, got %v", err)
+	}
+
+	// The request can only be used once
+	ic.Channel.ID, ic.Message.Timestamp = "D2", "2.1"
+	if err := ApprovalApprove(res, req, ic); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	mockSlack.AssertNumberOfCalls(t, "UploadFile", 1)
+	entries := log.Entries()
+	if len(entries) != 1 {
+		t.Fatalf("Expected the export to be audited once, got %+v", entries)
+	}
+	if d := entries[0].Details; d["filter"] != "queue=support" || d["rows"] != "1" || d["destination"] != "Slack DM to UADMIN1" {
+		t.Errorf("Expected the filter, rows and destination to be audited, got %+v", d)
+	}
+}
+
+func TestBulkCloseInvalidQuery(t *testing.T) {
+	InitAnnouncements(nil, []string{"UADMIN1", "UADMIN2"})
+	defer InitAnnouncements(nil, nil)
+	req, res, w := newTestRequest()
+	Helpdesk(res, req, slack.SlashCommand{Command: "/hd", Text: "bulk-close status:broken", UserID: "UADMIN1"})
+	if body := w.Body.String(); !strings.Contains(body, "That query could not be understood") {
+		t.Errorf("Expected the query to be refused before asking for approval, got %s", body)
+	}
+}
+
+func TestSensitiveCommandsNeedAnotherAdmin(t *testing.T) {
+	InitAnnouncements(nil, []string{"UADMIN1"})
+	defer InitAnnouncements(nil, nil)
+	for _, text := range []string{"bulk-close support", "export", "erase <@U1|bob>"} {
+		req, res, w := newTestRequest()
+		Helpdesk(res, req, slack.SlashCommand{Command: "/hd", Text: text, UserID: "U1"})
+		if body := w.Body.String(); !strings.Contains(body, "only helpdesk admins") {
+			t.Errorf("Expected only admins to be able to run %s, got %s", text, body)
+		}
+		req, res, w = newTestRequest()
+		Helpdesk(res, req, slack.SlashCommand{Command: "/hd", Text: text, UserID: "UADMIN1"})
+		if body := w.Body.String(); !strings.Contains(body, "you are the only admin") {
+			t.Errorf("Expected %s to need a second admin, got %s", text, body)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/nlopes/slack"
+
+	"github.com/skybet/go-helpdesk/audit"
+	"github.com/skybet/go-helpdesk/policy"
+	"github.com/skybet/go-helpdesk/server"
+)
+
+// Audit handles /hd audit verify, checking that the audit log's hash chain is
+// unbroken and showing the hash of its latest entry to compare with one kept
+// elsewhere. Only admins can use it.
+func Audit(res *server.Response, req *server.Request, ctx interface{}) error {
+	sc, ok := ctx.(slack.SlashCommand)
+	if !ok {
+		return fmt.Errorf("Expected a slack.SlashCommand to be passed to the handler")
+	}
+	if !allowed(req.Context(), sc.UserID, policy.Audit) {
+		res.Text(http.StatusOK, tr(sc, "Sorry, only helpdesk admins can verify the audit log"))
+		return nil
+	}
+	if args := strings.Fields(sc.Text); len(args) != 2 || strings.ToLower(args[1]) != "verify" {
+		res.Text(http.StatusOK, tr(sc, "Usage: %s audit verify", sc.Command))
+		return nil
+	}
+	entries, err := auditLog.Verify()
+	if tampered, ok := err.(*audit.ErrTampered); ok {
+		res.Text(http.StatusOK, tr(sc, "The audit log has been altered at entry %d: %s", tampered.Seq, tampered.Reason))
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("Failed to verify the audit log: %s", err)
+	}
+	if len(entries) == 0 {
+		res.Text(http.StatusOK, tr(sc, "The audit log is empty"))
+		return nil
+	}
+	last := entries[len(entries)-1]
+	res.Text(http.StatusOK, tr(sc, "The audit log's %d entries are intact, the latest was recorded %s with hash `%s`", len(entries), slackDate(last.At), last.Hash))
+	return nil
+}
@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nlopes/slack"
+
+	"github.com/skybet/go-helpdesk/approval"
+	"github.com/skybet/go-helpdesk/audit"
+)
+
+func TestAudit(t *testing.T) {
+	l := &audit.Log{}
+	InitApprovals(approval.New(time.Minute), l)
+	defer InitApprovals(approval.New(15*time.Minute), &audit.Log{})
+	InitAnnouncements(nil, []string{"UADMIN"})
+	defer InitAnnouncements(nil, nil)
+	verify := func(user, text string) string {
+		req, res, w := newTestRequest()
+		if err := Helpdesk(res, req, slack.SlashCommand{Command: "/hd", Text: text, UserID: user}); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		return w.Body.String()
+	}
+
+	if body := verify("U1", "audit verify"); !strings.Contains(body, "only helpdesk admins") {
+		t.Errorf("Expected only admins to verify the audit log, got %s", body)
+	}
+	if body := verify("UADMIN", "audit"); !strings.Contains(body, "Usage: /hd audit verify") {
+		t.Errorf("Expected the usage, got %s", body)
+	}
+	if body := verify("UADMIN", "audit verify"); !strings.Contains(body, "The audit log is empty") {
+		t.Errorf("Expected the log to be empty, got %s", body)
+	}
+	l.Record(audit.Entry{At: time.Now(), Action: "/hd export", Actor: "U1", ApprovedBy: "UADMIN", Outcome: "exported 3 tickets"})
+	l.Record(audit.Entry{At: time.Now(), Action: "/hd erase", Actor: "UADMIN", ApprovedBy: "U1", Outcome: "erased U3"})
+	last := l.Entries()[1].Hash
+	if body := verify("UADMIN", "audit verify"); !strings.Contains(body, "2 entries are intact") || !strings.Contains(body, last) {
+		t.Errorf("Expected the log to verify, got %s", body)
+	}
+}
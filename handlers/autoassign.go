@@ -0,0 +1,42 @@
+package handlers
+
+import (
+	"context"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/skybet/go-helpdesk/assign"
+	"github.com/skybet/go-helpdesk/ticket"
+	"github.com/skybet/go-helpdesk/wip"
+)
+
+var assigner *assign.Assigner
+
+// InitAssigner sets the assigner new tickets are handed to, tickets are left
+// unassigned without one
+func InitAssigner(a *assign.Assigner) {
+	assigner = a
+}
+
+// autoAssign assigns a new ticket to whoever is on call for its queue,
+// updating t. Tickets nobody can take are left in the queue for triage.
+func autoAssign(ctx context.Context, t *ticket.Ticket) {
+	if assigner == nil {
+		return
+	}
+	assigned, err := assigner.Assign(ctx, t.ID, clk.Now())
+	if over, ok := err.(*wip.ErrOverLimit); ok {
+		log.Infof("Ticket %s was not assigned: %s", t.ID, over)
+		return
+	}
+	if err == assign.ErrNobodyAvailable {
+		log.Infof("Ticket %s was not assigned: %s", t.ID, err)
+		return
+	}
+	if err != nil {
+		log.Errorf("Failed to assign ticket %s: %s", t.ID, err)
+		return
+	}
+	*t = *assigned
+	suggestRunbooks(t)
+}
@@ -0,0 +1,33 @@
+package handlers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/skybet/go-helpdesk/assign"
+	"github.com/skybet/go-helpdesk/mocks"
+	"github.com/skybet/go-helpdesk/store"
+)
+
+func TestAutoAssign(t *testing.T) {
+	mockSlack := mocks.NewSlack(t)
+	mockSlack.ExpectAddReaction().Named(TrackingReaction).AnyTimes()
+	mockSlack.ExpectPostMessage().ToChannel("C1").WithText("Ticket #1 created").WithText(`*Assignee*\n\u003c@U7\u003e`)
+	mockSlack.ExpectPostMessage().ToChannel("C1").WithText("Ticket #2 created").WithText(`*Assignee*\n\u003c@U8\u003e`)
+	Init(mockSlack)
+	s := store.NewMemory()
+	InitTickets(s)
+	InitAssigner(&assign.Assigner{Store: s, Strategy: assign.RoundRobin, Groups: assign.Groups{assign.AnyQueue: {{ID: "U7"}, {ID: "U8"}}}})
+	defer InitAssigner(nil)
+
+	for _, ev := range []string{"1.1", "1.2"} {
+		req, res, _ := newTestRequest()
+		if err := Mention(res, req, mentionEvent("<@UBOT> new Printer on fire", ev)); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+	}
+	pending, _ := s.Outbox(context.Background(), 10)
+	if len(pending) != 2 || pending[1].ThreadTS != "1.2" || pending[1].Text != "<@U8> you have been assigned this ticket" {
+		t.Errorf("Expected the assignees to be mentioned in the tickets' threads, got %+v", pending)
+	}
+}
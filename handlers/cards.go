@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"fmt"
+
+	"github.com/nlopes/slack"
+
+	"github.com/skybet/go-helpdesk/escalate"
+	"github.com/skybet/go-helpdesk/intake"
+	"github.com/skybet/go-helpdesk/links"
+	"github.com/skybet/go-helpdesk/render"
+	"github.com/skybet/go-helpdesk/ticket"
+)
+
+// cardAudience decides how much of a ticket a card shows
+type cardAudience int
+
+const (
+	// agentCard shows everything
+	agentCard cardAudience = iota
+	// reporterCard is for the reporter, it hides how the ticket is being
+	// triaged such as VIP status
+	reporterCard
+	// publicCard is for channels people outside the organisation can read,
+	// it also leaves out internal details and redacts internal links
+	publicCard
+	// referenceCard is for external organisations which are only told a
+	// ticket's reference and status
+	referenceCard
+)
+
+var (
+	ticketLinks *links.Links
+	taxonomy    = render.DefaultTaxonomy()
+)
+
+// InitTaxonomy sets the emoji and colours cards show statuses and priorities
+// with
+func InitTaxonomy(t *render.Taxonomy) {
+	taxonomy = t
+}
+
+// InitLinks sets how tickets are linked to in cards and messages, without it
+// tickets are referred to by ID alone
+func InitLinks(l *links.Links) {
+	ticketLinks = l
+}
+
+// ticketCard renders a summary of a ticket for posting in Slack
+func ticketCard(t *ticket.Ticket, a cardAudience) []slack.Block {
+	title := fmt.Sprintf("*Ticket %s* %s", ticketLinks.Ref(t.ID), t.Title)
+	if a == referenceCard {
+		title = fmt.Sprintf("*Ticket %s*", ticketLinks.Ref(t.ID))
+	}
+	vip := t.HasTag(intake.VIPTag)
+	if vip && a == agentCard {
+		title = render.VIP.Emoji + " " + title
+	}
+	assignee := "Unassigned"
+	if t.Assignee != "" {
+		assignee = fmt.Sprintf("<@%s>", t.Assignee)
+	}
+	fields := []*slack.TextBlockObject{
+		slack.NewTextBlockObject(slack.MarkdownType, fmt.Sprintf("*Status*\n%s %s", taxonomy.Status(t.Status).Emoji, t.Status), false, false),
+		slack.NewTextBlockObject(slack.MarkdownType, fmt.Sprintf("*Reporter*\n<@%s>", t.Reporter), false, false),
+	}
+	if a == agentCard || a == reporterCard {
+		fields = append(fields, slack.NewTextBlockObject(slack.MarkdownType, fmt.Sprintf("*Assignee*\n%s", assignee), false, false))
+	}
+	if showPriority(t, a) {
+		fields = append(fields, slack.NewTextBlockObject(slack.MarkdownType, fmt.Sprintf("*Priority*\n%s %s", taxonomy.Priority(t.Priority).Emoji, t.Priority), false, false))
+	}
+	if vip && a == agentCard {
+		fields = append(fields, slack.NewTextBlockObject(slack.MarkdownType, "*VIP*\nYes", false, false))
+	}
+	blocks := []slack.Block{
+		slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType, echo(title, a), false, false), fields, nil),
+	}
+	if a == agentCard || a == reporterCard {
+		blocks = append(blocks, previewBlocks(t)...)
+	}
+	if receipts := escalate.Receipts(t); receipts != "" && a == agentCard {
+		blocks = append(blocks, slack.NewContextBlock("", slack.NewTextBlockObject(slack.MarkdownType, ":rotating_light: "+receipts, false, false)))
+	}
+	if rbs := runbooks.For(t); len(rbs) > 0 && a == agentCard {
+		blocks = append(blocks, slack.NewContextBlock("", slack.NewTextBlockObject(slack.MarkdownType, ":book: Runbooks: "+runbookLinks(rbs), false, false)))
+	}
+	if a == agentCard {
+		var elements []slack.MixedElement
+		for _, l := range []struct {
+			label, view string
+			ok          bool
+		}{
+			{"Thread", links.ViewThread, t.ChannelID != ""},
+			{"App Home", links.ViewHome, ticketLinks.Home() != ""},
+			{"Admin", links.ViewAdmin, ticketLinks.Admin(t.ID) != ""},
+		} {
+			if u := ticketLinks.Ticket(t.ID, l.view); u != "" && l.ok {
+				elements = append(elements, slack.NewTextBlockObject(slack.MarkdownType, fmt.Sprintf("<%s|%s>", u, l.label), false, false))
+			}
+		}
+		if len(elements) > 0 {
+			blocks = append(blocks, slack.NewContextBlock("", elements...))
+		}
+	}
+	return blocks
+}
+
+// showPriority reports whether a card for audience a shows the priority, a
+// VIP's boosted priority would give away their status
+func showPriority(t *ticket.Ticket, a cardAudience) bool {
+	return t.Priority != 0 && (a == agentCard || (a == reporterCard && !t.HasTag(intake.VIPTag)))
+}
+
+// refreshed tells the user who acted on an out of date card that the ticket
+// had changed, once its card has been rendered again from t
+func refreshed(ic *slack.InteractionCallback, t *ticket.Ticket) error {
+	if ic.Channel.ID == "" {
+		return nil
+	}
+	text := fmt.Sprintf("Ticket %s changed before your action went through, the card has been refreshed so you can check it and try again", ticketLinks.Ref(t.ID))
+	if _, _, err := slackWrapper.PostMessage(ic.Channel.ID, slack.MsgOptionPostEphemeral(ic.User.ID), slack.MsgOptionText(text, false)); err != nil {
+		return fmt.Errorf("Failed to tell %s the ticket changed: %s", ic.User.ID, err)
+	}
+	return nil
+}
+
+// cardMessage returns the options posting a ticket's card with text as the
+// notification, followed by any extra blocks. The card is coloured by its
+// priority, or status, if the taxonomy gives it a colour.
+func cardMessage(t *ticket.Ticket, a cardAudience, text string, extra ...slack.Block) []slack.MsgOption {
+	color := taxonomy.Status(t.Status).Color
+	if c := taxonomy.Priority(t.Priority).Color; c != "" && showPriority(t, a) {
+		color = c
+	}
+	blocks := append(ticketCard(t, a), extra...)
+	if color == "" {
+		return []slack.MsgOption{slack.MsgOptionText(text, false), slack.MsgOptionBlocks(blocks...)}
+	}
+	return []slack.MsgOption{
+		slack.MsgOptionText(text, false),
+		slack.MsgOptionAttachments(slack.Attachment{Color: color, Fallback: text, Blocks: blocks}),
+	}
+}
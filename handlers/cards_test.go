@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/nlopes/slack"
+
+	"github.com/skybet/go-helpdesk/intake"
+	"github.com/skybet/go-helpdesk/links"
+	"github.com/skybet/go-helpdesk/render"
+	"github.com/skybet/go-helpdesk/ticket"
+)
+
+func TestTicketCardLinks(t *testing.T) {
+	InitLinks(links.New("https://hd.example.com/links", "T1", "A1", ""))
+	defer InitLinks(nil)
+	tk := &ticket.Ticket{ID: "7", Title: "VPN", ChannelID: "C1", ThreadTS: "1.1"}
+
+	agent, _ := json.Marshal(ticketCard(tk, agentCard))
+	if s := string(agent); !strings.Contains(s, "https://hd.example.com/links/t/7|#7") || !strings.Contains(s, "view=home|App Home") || strings.Contains(s, "Admin") {
+		t.Errorf("Expected the agent card to link the ticket, got %s", s)
+	}
+	reporter, _ := json.Marshal(ticketCard(tk, reporterCard))
+	if s := string(reporter); strings.Contains(s, "App Home") || !strings.Contains(s, "links/t/7") {
+		t.Errorf("Expected the reporter card to only link the thread, got %s", s)
+	}
+}
+
+func TestCardMessageColor(t *testing.T) {
+	tax, _ := render.ParseTaxonomy([]string{"priority:P1=#e01e5a", "status:new=:fresh:#00ff00"})
+	InitTaxonomy(tax)
+	defer InitTaxonomy(render.DefaultTaxonomy())
+	tk := &ticket.Ticket{ID: "7", Title: "VPN", Status: ticket.StatusNew, Priority: ticket.P1, Tags: []string{intake.VIPTag}}
+
+	_, agent, _ := slack.UnsafeApplyMsgOptions("", "C1", "", cardMessage(tk, agentCard, "VPN")...)
+	if a := agent.Get("attachments"); !strings.Contains(a, "#e01e5a") || !strings.Contains(a, ":fresh: new") {
+		t.Errorf("Expected the agent card to be coloured by priority, got %s", a)
+	}
+	// The priority colour would give away the reporter's VIP status
+	_, reporter, _ := slack.UnsafeApplyMsgOptions("", "C1", "", cardMessage(tk, reporterCard, "VPN")...)
+	if a := reporter.Get("attachments"); !strings.Contains(a, "#00ff00") || strings.Contains(a, "#e01e5a") {
+		t.Errorf("Expected the reporter card to be coloured by status, got %s", a)
+	}
+}
@@ -0,0 +1,422 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/nlopes/slack"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/skybet/go-helpdesk/blocks"
+	"github.com/skybet/go-helpdesk/catalog"
+	"github.com/skybet/go-helpdesk/outbox"
+	"github.com/skybet/go-helpdesk/server"
+	"github.com/skybet/go-helpdesk/store"
+	"github.com/skybet/go-helpdesk/ticket"
+	"github.com/skybet/go-helpdesk/views"
+)
+
+// Action and callback IDs of the service catalog
+const (
+	// CatalogPickActionID is the menu of items in /hd catalog and on the App
+	// Home tab, its options' values are item IDs
+	CatalogPickActionID = "catalog_pick"
+	// CatalogCallbackID is the callback ID of an item's form
+	CatalogCallbackID = "catalog_request"
+	// CatalogApproveActionID and CatalogRejectActionID are the buttons sent
+	// to approvers, their value is the ticket's ID
+	CatalogApproveActionID = "catalog_approve"
+	CatalogRejectActionID  = "catalog_reject"
+	// CatalogCheckActionID is the button ticking off an item of a request's
+	// checklist, its value is the ticket's ID and the item's index
+	CatalogCheckActionID = "catalog_check"
+)
+
+// services is the service catalog, nil if there is none
+var services *catalog.Catalog
+
+// InitCatalog sets the service catalog users raise requests from
+func InitCatalog(c *catalog.Catalog) {
+	services = c
+}
+
+// Catalog handles /hd catalog [item], replying with the items of the service
+// catalog to pick from, or opening an item's form
+func Catalog(res *server.Response, req *server.Request, ctx interface{}) error {
+	sc, ok := ctx.(slack.SlashCommand)
+	if !ok {
+		return fmt.Errorf("Expected a slack.SlashCommand to be passed to the handler")
+	}
+	if services == nil {
+		res.Text(http.StatusOK, tr(sc, "The service catalog has not been set up"))
+		return nil
+	}
+	if args := strings.Fields(sc.Text); len(args) > 1 {
+		it := services.Get(strings.ToLower(args[1]))
+		if it == nil {
+			res.Text(http.StatusOK, tr(sc, "There is nothing called %s in the service catalog, use %s catalog to see what there is", args[1], sc.Command))
+			return nil
+		}
+		if _, err := slackIn(req.Context()).OpenView(sc.TriggerID, catalogModal(it)); err != nil {
+			return fmt.Errorf("Failed to open the form of %s: %s", it.ID, err)
+		}
+		return nil
+	}
+	return res.JSON(http.StatusOK, slack.Msg{ResponseType: slack.ResponseTypeEphemeral, Text: tr(sc, "Service catalog"), Blocks: slack.Blocks{BlockSet: catalogPicker()}})
+}
+
+// catalogPicker lists the items of each category of the catalog with a menu
+// to pick one from
+func catalogPicker() []slack.Block {
+	md := func(s string) *slack.TextBlockObject {
+		return slack.NewTextBlockObject(slack.MarkdownType, s, false, false)
+	}
+	set := []slack.Block{slack.NewSectionBlock(md("*Service catalog*\nPick what you need to open its request form."), nil, nil)}
+	names, items := services.Categories()
+	for _, name := range names {
+		lines := []string{"*" + name + "*"}
+		var options []*slack.OptionBlockObject
+		for _, it := range items[name] {
+			line := "• " + it.Name
+			if it.Description != "" {
+				line += ": " + it.Description
+			}
+			lines = append(lines, line)
+			options = append(options, slack.NewOptionBlockObject(it.ID, slack.NewTextBlockObject(slack.PlainTextType, it.Name, false, false)))
+		}
+		menu := slack.NewOptionsSelectBlockElement(slack.OptTypeStatic, slack.NewTextBlockObject(slack.PlainTextType, "Request…", false, false), CatalogPickActionID, options...)
+		set = append(set, slack.NewSectionBlock(md(strings.Join(lines, "\n")), nil, slack.NewAccessory(menu)))
+	}
+	return set
+}
+
+// CatalogPick handles an item picked from the catalog, opening its form
+func CatalogPick(res *server.Response, req *server.Request, ctx interface{}) error {
+	ic, ok := ctx.(*slack.InteractionCallback)
+	if !ok {
+		return fmt.Errorf("Expected a *slack.InteractionCallback to be passed to the handler")
+	}
+	if services == nil {
+		return fmt.Errorf("The service catalog has not been initialised")
+	}
+	action, err := blocks.ActionOf(ic)
+	if err != nil {
+		return err
+	}
+	it := services.Get(action.Value)
+	if it == nil {
+		return fmt.Errorf("There is no catalog item %q", action.Value)
+	}
+	if _, err := slackIn(req.Context()).OpenView(ic.TriggerID, catalogModal(it)); err != nil {
+		return fmt.Errorf("Failed to open the form of %s: %s", it.ID, err)
+	}
+	return nil
+}
+
+// catalogModal renders an item's form
+func catalogModal(it *catalog.Item) *views.View {
+	title := it.Name
+	if r := []rune(title); len(r) > 24 {
+		// Slack refuses longer titles
+		title = string(r[:23]) + "…"
+	}
+	v := views.NewModal(CatalogCallbackID, title)
+	v.Submit = slack.NewTextBlockObject(slack.PlainTextType, "Request", false, false)
+	v.PrivateMetadata = it.ID
+	if it.Description != "" {
+		v.Blocks.BlockSet = append(v.Blocks.BlockSet, slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType, it.Description, false, false), nil, nil))
+	}
+	for _, f := range it.Fields {
+		var element interface{}
+		switch f.Type {
+		case catalog.Multiline:
+			input := views.NewPlainTextInput("value")
+			input.Multiline = true
+			element = input
+		case catalog.Select:
+			var options []*slack.OptionBlockObject
+			for _, o := range f.Options {
+				options = append(options, slack.NewOptionBlockObject(o, slack.NewTextBlockObject(slack.PlainTextType, o, false, false)))
+			}
+			element = slack.NewOptionsSelectBlockElement(slack.OptTypeStatic, slack.NewTextBlockObject(slack.PlainTextType, "Choose…", false, false), "value", options...)
+		case catalog.User:
+			element = slack.NewOptionsSelectBlockElement(slack.OptTypeUser, slack.NewTextBlockObject(slack.PlainTextType, "Choose someone…", false, false), "value")
+		case catalog.Date:
+			element = slack.NewDatePickerBlockElement("value")
+		default:
+			element = views.NewPlainTextInput("value")
+		}
+		input := views.NewInputBlock(f.Name, f.Label, element)
+		input.Optional = f.Optional
+		if f.Hint != "" {
+			input.Hint = slack.NewTextBlockObject(slack.PlainTextType, f.Hint, false, false)
+		}
+		v.Blocks.BlockSet = append(v.Blocks.BlockSet, input)
+	}
+	return v
+}
+
+// CatalogSubmission raises a ticket for an item's form, posting its card in
+// the item's channel, then asks the first step of its approval chain to
+// approve it, or posts its checklist if it needs no approval
+func CatalogSubmission(res *server.Response, req *server.Request, ctx interface{}) error {
+	sub, ok := ctx.(*views.Submission)
+	if !ok {
+		return fmt.Errorf("Expected a *views.Submission to be passed to the handler")
+	}
+	if services == nil || tickets == nil {
+		return fmt.Errorf("The service catalog has not been initialised")
+	}
+	it := services.Get(sub.View.PrivateMetadata)
+	if it == nil {
+		return fmt.Errorf("There is no catalog item %q", sub.View.PrivateMetadata)
+	}
+	var answers []ticket.Answer
+	invalid := views.ValidationErrors{}
+	for _, f := range it.Fields {
+		v := sub.View.State.Get(f.Name, "value")
+		value := strings.TrimSpace(v.String())
+		if f.Type == catalog.User && value != "" {
+			value = "<@" + value + ">"
+		}
+		if value == "" && !f.Optional {
+			invalid.Add(f.Name, "Please answer this")
+		}
+		answers = append(answers, ticket.Answer{Field: f.Name, Label: f.Label, Value: value})
+	}
+	if len(invalid) > 0 {
+		return invalid
+	}
+
+	user, channel := sub.User.ID, services.ChannelOf(it)
+	_, ts, err := slackIn(req.Context()).PostMessage(channel, slack.MsgOptionText(fmt.Sprintf("<@%s> requested %s from the service catalog", user, it.Name), false))
+	if err != nil {
+		return fmt.Errorf("Failed to post in %s: %s", channel, err)
+	}
+	t, a, err := createTicket(req.Context(), sub.Team.ID, channel, ts, user, catalog.Describe(answers), func(t *ticket.Ticket) {
+		it.Apply(t, answers)
+	})
+	if err != nil {
+		return err
+	}
+	summary := fmt.Sprintf("Ticket #%s requested by <@%s>: %s", t.ID, user, t.Title)
+	if _, _, _, err := slackIn(req.Context()).UpdateMessage(channel, ts, cardMessage(t, a, summary)...); err != nil {
+		log.Errorf("Failed to show ticket %s in %s: %s", t.ID, channel, err)
+	}
+	text := fmt.Sprintf("Your request for %s is ticket %s", it.Name, ticketLinks.Ref(t.ID))
+	if step, ok := it.Pending(t.Request); ok {
+		text += fmt.Sprintf(", it needs %s approval first", step.Name)
+		askApprovers(req.Context(), t, it, step)
+	} else {
+		postChecklist(req.Context(), t)
+	}
+	if _, _, err := dm().PostMessage(user, slack.MsgOptionText(text, false)); err != nil {
+		log.Errorf("Failed to tell %s about ticket %s: %s", user, t.ID, err)
+	}
+	return nil
+}
+
+// askApprovers sends each approver of a step the request with buttons to
+// approve or reject it
+func askApprovers(ctx context.Context, t *ticket.Ticket, it *catalog.Item, step catalog.Step) {
+	text := fmt.Sprintf("<@%s> is requesting *%s*, ticket %s. Please approve or reject it as the %s approval.", t.Reporter, it.Name, ticketLinks.Ref(t.ID), step.Name)
+	if t.Description != "" {
+		text += "\n>" + strings.Replace(t.Description, "\n", "\n>", -1)
+	}
+	approve := slack.NewButtonBlockElement(CatalogApproveActionID, t.ID, slack.NewTextBlockObject(slack.PlainTextType, "Approve", false, false))
+	approve.Style = slack.StylePrimary
+	reject := slack.NewButtonBlockElement(CatalogRejectActionID, t.ID, slack.NewTextBlockObject(slack.PlainTextType, "Reject", false, false))
+	reject.Style = slack.StyleDanger
+	msg := slack.MsgOptionBlocks(
+		slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType, text, false, false), nil, nil),
+		slack.NewActionBlock("", approve, reject),
+	)
+	for _, id := range step.Approvers {
+		if _, _, err := slackIn(ctx).PostMessage(id, slack.MsgOptionText(text, false), msg); err != nil {
+			log.Errorf("Failed to ask %s to approve ticket %s: %s", id, t.ID, err)
+		}
+	}
+}
+
+// CatalogDecision handles the buttons approving and rejecting a catalog
+// request. The decision is posted in the ticket's thread from the outbox.
+// Once every step has approved the request its checklist is posted and its
+// access granted, and a rejected request is closed.
+func CatalogDecision(res *server.Response, req *server.Request, ctx interface{}) error {
+	ic, ok := ctx.(*slack.InteractionCallback)
+	if !ok {
+		return fmt.Errorf("Expected a *slack.InteractionCallback to be passed to the handler")
+	}
+	if services == nil {
+		return fmt.Errorf("The service catalog has not been initialised")
+	}
+	action, err := blocks.ActionOf(ic)
+	if err != nil {
+		return err
+	}
+	approve, now, user := action.ActionID == CatalogApproveActionID, clk.Now(), ic.User.ID
+	verb := "approved"
+	if !approve {
+		verb = "rejected"
+	}
+	var t *ticket.Ticket
+	var it *catalog.Item
+	err = tickets.Tx(req.Context(), func(tx store.Store) error {
+		var err error
+		if t, err = tx.GetTicket(req.Context(), action.Value); err != nil {
+			return err
+		}
+		if it = services.Get(t.Request.Item); it == nil {
+			return fmt.Errorf("ticket %s is not a request from the catalog", t.ID)
+		}
+		step, err := it.Decide(&t.Request, user, approve, now)
+		if err != nil {
+			return err
+		}
+		t.UpdatedAt = now
+		if err := tx.UpdateTicket(req.Context(), t); err != nil {
+			return err
+		}
+		return tx.Enqueue(req.Context(), outbox.Thread(t, fmt.Sprintf("<@%s> %s this request as the %s approval", user, verb, step.Name)))
+	})
+	switch {
+	case err == catalog.ErrNotPending || err == catalog.ErrNotApprover:
+		return decided(req.Context(), ic, fmt.Sprintf("Ticket %s does not need your approval any more", ticketLinks.Ref(action.Value)))
+	case err != nil:
+		return fmt.Errorf("Failed to decide catalog request: %s", err)
+	}
+	if err := decided(req.Context(), ic, fmt.Sprintf("You %s ticket %s", verb, ticketLinks.Ref(t.ID))); err != nil {
+		log.Errorf("%s", err)
+	}
+
+	if !approve {
+		if _, err := machine.Move(req.Context(), tickets, t.ID, ticket.StatusClosed, user, now); err != nil {
+			log.Errorf("Failed to close rejected ticket %s: %s", t.ID, err)
+		}
+		tellReporter(t, fmt.Sprintf("Your request for %s, ticket %s, was rejected by <@%s>", it.Name, ticketLinks.Ref(t.ID), user))
+		return nil
+	}
+	if step, ok := it.Pending(t.Request); ok {
+		askApprovers(req.Context(), t, it, step)
+		return nil
+	}
+	tellReporter(t, fmt.Sprintf("Your request for %s, ticket %s, has been approved", it.Name, ticketLinks.Ref(t.ID)))
+	postChecklist(req.Context(), t)
+	grantAccess(req.Context(), t, user)
+	return nil
+}
+
+// decided replaces a request sent to an approver with text
+func decided(ctx context.Context, ic *slack.InteractionCallback, text string) error {
+	if ic.Channel.ID == "" || ic.Message.Timestamp == "" {
+		return nil
+	}
+	if _, _, _, err := slackIn(ctx).UpdateMessage(ic.Channel.ID, ic.Message.Timestamp, slack.MsgOptionText(text, false), slack.MsgOptionBlocks(
+		slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType, text, false, false), nil, nil),
+	)); err != nil {
+		return fmt.Errorf("Failed to update approval request: %s", err)
+	}
+	return nil
+}
+
+// tellReporter DMs the reporter of a ticket
+func tellReporter(t *ticket.Ticket, text string) {
+	if t.Reporter == "" {
+		return
+	}
+	if _, _, err := dm().PostMessage(t.Reporter, slack.MsgOptionText(text, false)); err != nil {
+		log.Errorf("Failed to tell %s about ticket %s: %s", t.Reporter, t.ID, err)
+	}
+}
+
+// postChecklist posts a request's checklist in its thread, if it has one
+func postChecklist(ctx context.Context, t *ticket.Ticket) {
+	if len(t.Request.Checklist) == 0 {
+		return
+	}
+	opts := append(checklistMessage(t), slack.MsgOptionTS(t.ThreadTS))
+	if _, _, err := slackIn(ctx).PostMessage(t.ChannelID, opts...); err != nil {
+		log.Errorf("Failed to post the checklist of ticket %s: %s", t.ID, err)
+	}
+}
+
+// checklistMessage renders a request's checklist with a button to tick off
+// each item still to do
+func checklistMessage(t *ticket.Ticket) []slack.MsgOption {
+	text := fmt.Sprintf("Checklist for ticket #%s", t.ID)
+	set := []slack.Block{slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType, "*Checklist*", false, false), nil, nil)}
+	for i, c := range t.Request.Checklist {
+		if c.Done() {
+			set = append(set, slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType, fmt.Sprintf(":white_check_mark: %s, done by <@%s>", c.Text, c.DoneBy), false, false), nil, nil))
+			continue
+		}
+		button := slack.NewButtonBlockElement(CatalogCheckActionID, t.ID+":"+strconv.Itoa(i), slack.NewTextBlockObject(slack.PlainTextType, "Done", false, false))
+		set = append(set, slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType, ":white_large_square: "+c.Text, false, false), nil, slack.NewAccessory(button)))
+	}
+	return []slack.MsgOption{slack.MsgOptionText(text, false), slack.MsgOptionBlocks(set...)}
+}
+
+// CatalogCheck handles the button ticking off an item of a request's
+// checklist, resolving the ticket once every item is done and its access
+// granted. Requesters can not tick off their own requests.
+func CatalogCheck(res *server.Response, req *server.Request, ctx interface{}) error {
+	ic, ok := ctx.(*slack.InteractionCallback)
+	if !ok {
+		return fmt.Errorf("Expected a *slack.InteractionCallback to be passed to the handler")
+	}
+	action, err := blocks.ActionOf(ic)
+	if err != nil {
+		return err
+	}
+	parts := strings.SplitN(action.Value, ":", 2)
+	i, err := strconv.Atoi(parts[len(parts)-1])
+	if len(parts) != 2 || err != nil {
+		return fmt.Errorf("Invalid checklist item: %q", action.Value)
+	}
+	now, user := clk.Now(), ic.User.ID
+	var t *ticket.Ticket
+	changed := false
+	err = tickets.Tx(req.Context(), func(tx store.Store) error {
+		var err error
+		if t, err = tx.GetTicket(req.Context(), parts[0]); err != nil {
+			return err
+		}
+		if i < 0 || i >= len(t.Request.Checklist) {
+			return fmt.Errorf("ticket %s has no checklist item %d", t.ID, i)
+		}
+		if t.Reporter == user || t.Request.Rejected() || t.Request.Checklist[i].Done() {
+			return nil
+		}
+		t.Request.Checklist[i].DoneBy, t.Request.Checklist[i].DoneAt = user, now
+		t.UpdatedAt = now
+		if err := tx.UpdateTicket(req.Context(), t); err != nil {
+			return err
+		}
+		changed = true
+		if !t.Request.ChecklistDone() {
+			return nil
+		}
+		return tx.Enqueue(req.Context(), outbox.Thread(t, "Every item of the checklist is done"))
+	})
+	if err != nil {
+		return fmt.Errorf("Failed to tick off checklist item: %s", err)
+	}
+	if t.Reporter == user {
+		if _, _, err := slackIn(req.Context()).PostMessage(ic.Channel.ID, slack.MsgOptionPostEphemeral(user), slack.MsgOptionText("The checklist is for the agents fulfilling your request", false)); err != nil {
+			log.Errorf("Failed to tell %s they can not tick off ticket %s: %s", user, t.ID, err)
+		}
+		return nil
+	}
+	if ic.Channel.ID != "" && ic.Message.Timestamp != "" {
+		if _, _, _, err := slackIn(req.Context()).UpdateMessage(ic.Channel.ID, ic.Message.Timestamp, checklistMessage(t)...); err != nil {
+			return fmt.Errorf("Failed to update the checklist of ticket %s: %s", t.ID, err)
+		}
+	}
+	if changed {
+		resolveFulfilled(req.Context(), t, user)
+	}
+	return nil
+}
@@ -0,0 +1,176 @@
+package handlers
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nlopes/slack"
+
+	"github.com/skybet/go-helpdesk/catalog"
+	"github.com/skybet/go-helpdesk/clock"
+	"github.com/skybet/go-helpdesk/mocks"
+	"github.com/skybet/go-helpdesk/store"
+	"github.com/skybet/go-helpdesk/ticket"
+	"github.com/skybet/go-helpdesk/views"
+)
+
+func testCatalog(t *testing.T) *catalog.Catalog {
+	c, err := catalog.New(&catalog.Catalog{Channel: "CREQ", Items: []*catalog.Item{{
+		ID: "laptop", Name: "New laptop", Description: "A laptop for work", Category: "Hardware", Queue: "it",
+		Fields: []catalog.Field{
+			{Name: "model", Label: "Model", Type: catalog.Select, Options: []string{"13 inch", "15 inch"}},
+			{Name: "notes", Label: "Notes", Optional: true},
+		},
+		Approvals: []catalog.Step{{Name: "manager", Approvers: []string{"UMGR"}}, {Name: "finance", Approvers: []string{"UFIN"}}},
+		Checklist: []string{"Order it", "Hand it over"},
+	}}})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	return c
+}
+
+func TestCatalogCommand(t *testing.T) {
+	InitCatalog(nil)
+	req, res, w := newTestRequest()
+	Helpdesk(res, req, slack.SlashCommand{Command: "/hd", Text: "catalog", UserID: "U1"})
+	if body := w.Body.String(); !strings.Contains(body, "has not been set up") {
+		t.Errorf("Expected to be told there is no catalog, got %s", body)
+	}
+
+	InitCatalog(testCatalog(t))
+	defer InitCatalog(nil)
+	req, res, w = newTestRequest()
+	Helpdesk(res, req, slack.SlashCommand{Command: "/hd", Text: "catalog", UserID: "U1"})
+	if body := w.Body.String(); !strings.Contains(body, "*Hardware*") || !strings.Contains(body, CatalogPickActionID) || !strings.Contains(body, "New laptop: A laptop for work") {
+		t.Errorf("Expected the items to pick from, got %s", body)
+	}
+	req, res, w = newTestRequest()
+	Helpdesk(res, req, slack.SlashCommand{Command: "/hd", Text: "catalog phone", UserID: "U1"})
+	if body := w.Body.String(); !strings.Contains(body, "nothing called phone") {
+		t.Errorf("Expected to be told there is no such item, got %s", body)
+	}
+
+	mockSlack := mocks.NewSlack(t)
+	mockSlack.ExpectOpenView().Named("T1").Matching("the laptop form", func(c *mocks.Call) bool {
+		return c.View.CallbackID == CatalogCallbackID && c.View.PrivateMetadata == "laptop" && len(c.View.Blocks.BlockSet) == 3
+	})
+	Init(mockSlack)
+	req, res, _ = newTestRequest()
+	if err := Helpdesk(res, req, slack.SlashCommand{Command: "/hd", Text: "catalog laptop", UserID: "U1", TriggerID: "T1"}); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+}
+
+func TestCatalogRequest(t *testing.T) {
+	now := time.Date(2026, 10, 14, 9, 0, 0, 0, time.UTC)
+	InitClock(clock.NewFake(now))
+	defer InitClock(nil)
+	InitCatalog(testCatalog(t))
+	defer InitCatalog(nil)
+	s := store.NewMemory()
+	InitTickets(s)
+	mockSlack := mocks.NewSlack(t)
+	mockSlack.ExpectPostMessage().ToChannel("CREQ").WithText("<@U1> requested New laptop from the service catalog").ReturnTS("1.1")
+	mockSlack.ExpectUpdateMessage().ToChannel("CREQ").ForTS("1.1")
+	mockSlack.ExpectPostMessage().ToChannel("UMGR").WithText("as the manager approval").ReturnTS("2.1")
+	mockSlack.ExpectPostMessage().ToChannel("U1").WithText("it needs manager approval first")
+	Init(mockSlack)
+
+	sub := &views.Submission{}
+	sub.User.ID = "U1"
+	sub.View.PrivateMetadata = "laptop"
+	sub.View.State = &views.State{Values: map[string]map[string]views.Value{}}
+	req, res, _ := newTestRequest()
+	if _, ok := CatalogSubmission(res, req, sub).(views.ValidationErrors); !ok {
+		t.Fatalf("Expected a form without its model to be rejected")
+	}
+	sub.View.State.Values["model"] = map[string]views.Value{"value": {SelectedOption: &slack.OptionBlockObject{Value: "15 inch"}}}
+	if err := CatalogSubmission(res, req, sub); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	tk, err := s.GetTicket(context.Background(), "1")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if tk.Title != "New laptop" || tk.Queue != "it" || tk.Description != "Model: 15 inch" || tk.Request.Item != "laptop" || len(tk.Request.Checklist) != 2 {
+		t.Fatalf("Expected the ticket to be raised for the item, got %+v", tk)
+	}
+
+	decide := func(user, actionID string) {
+		ic := &slack.InteractionCallback{Type: slack.InteractionTypeBlockActions}
+		ic.ActionCallback.BlockActions = []*slack.BlockAction{{ActionID: actionID, Value: "1"}}
+		ic.User.ID = user
+		if err := CatalogDecision(res, req, ic); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+	}
+	// Finance can not approve before the manager has
+	decide("UFIN", CatalogApproveActionID)
+	mockSlack.ExpectPostMessage().ToChannel("UFIN").WithText("as the finance approval")
+	decide("UMGR", CatalogApproveActionID)
+	mockSlack.ExpectPostMessage().ToChannel("U1").WithText("has been approved")
+	mockSlack.ExpectPostMessage().ToChannel("CREQ").WithText("Checklist for ticket #1").ReturnTS("3.1")
+	decide("UFIN", CatalogApproveActionID)
+	if tk, _ := s.GetTicket(context.Background(), "1"); len(tk.Request.Approvals) != 2 || tk.Request.Approvals[1].By != "UFIN" {
+		t.Errorf("Expected both approvals to be recorded, got %+v", tk.Request.Approvals)
+	}
+	pending, _ := s.Outbox(context.Background(), 0)
+	if len(pending) != 2 || !strings.Contains(pending[0].Text, "<@UMGR> approved this request as the manager approval") {
+		t.Errorf("Expected each approval to be posted from the outbox, got %+v", pending)
+	}
+
+	check := func(user, value string) {
+		ic := &slack.InteractionCallback{Type: slack.InteractionTypeBlockActions}
+		ic.ActionCallback.BlockActions = []*slack.BlockAction{{ActionID: CatalogCheckActionID, Value: value}}
+		ic.User.ID = user
+		ic.Channel.ID, ic.Message.Timestamp = "CREQ", "3.1"
+		if err := CatalogCheck(res, req, ic); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+	}
+	mockSlack.ExpectPostMessage().ToChannel("CREQ").Ephemeral("U1").WithText("The checklist is for the agents")
+	check("U1", "1:0")
+	mockSlack.ExpectUpdateMessage().ToChannel("CREQ").ForTS("3.1").WithText(":white_check_mark: Order it, done by")
+	check("UAGENT", "1:0")
+	if tk, _ := s.GetTicket(context.Background(), "1"); !tk.Status.Open() {
+		t.Errorf("Expected the ticket to stay open until the checklist is done, got %s", tk.Status)
+	}
+	mockSlack.ExpectUpdateMessage().ToChannel("CREQ").ForTS("3.1").WithText(":white_check_mark: Hand it over, done by")
+	check("UAGENT", "1:1")
+	if tk, _ := s.GetTicket(context.Background(), "1"); tk.Status != ticket.StatusResolved {
+		t.Errorf("Expected the ticket to be resolved once the checklist is done, got %s", tk.Status)
+	}
+}
+
+func TestCatalogReject(t *testing.T) {
+	InitCatalog(testCatalog(t))
+	defer InitCatalog(nil)
+	s := store.NewMemory()
+	s.CreateTicket(context.Background(), &ticket.Ticket{ID: "1", Reporter: "U1", Status: ticket.StatusNew, Request: ticket.Request{Item: "laptop"}})
+	InitTickets(s)
+	mockSlack := mocks.NewSlack(t)
+	mockSlack.ExpectUpdateMessage().ToChannel("DMGR").ForTS("2.1").WithText("You rejected ticket")
+	mockSlack.ExpectPostMessage().ToChannel("U1").WithText("was rejected by <@UMGR>")
+	Init(mockSlack)
+
+	ic := &slack.InteractionCallback{Type: slack.InteractionTypeBlockActions}
+	ic.ActionCallback.BlockActions = []*slack.BlockAction{{ActionID: CatalogRejectActionID, Value: "1"}}
+	ic.User.ID = "UMGR"
+	ic.Channel.ID, ic.Message.Timestamp = "DMGR", "2.1"
+	req, res, _ := newTestRequest()
+	if err := CatalogDecision(res, req, ic); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if tk, _ := s.GetTicket(context.Background(), "1"); tk.Status != ticket.StatusClosed || !tk.Request.Rejected() {
+		t.Errorf("Expected a rejected request to be closed, got %s %+v", tk.Status, tk.Request)
+	}
+
+	// Pressing the button again changes nothing
+	mockSlack.ExpectUpdateMessage().ToChannel("DMGR").ForTS("2.1").WithText("does not need your approval any more")
+	if err := CatalogDecision(res, req, ic); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+}
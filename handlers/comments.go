@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/nlopes/slack/slackevents"
+
+	"github.com/skybet/go-helpdesk/store"
+	"github.com/skybet/go-helpdesk/threads"
+	"github.com/skybet/go-helpdesk/ticket"
+)
+
+// recordComment keeps a reply in a ticket's thread, or an edit of one, as a
+// comment on the ticket so the conversation can be searched and exported
+// once Slack's history has been trimmed
+func recordComment(ctx context.Context, ev *slackevents.MessageEvent) error {
+	reply := ev
+	if ev.SubType == "message_changed" && ev.Message != nil {
+		reply = ev.Message
+	} else if ev.SubType != "" && ev.SubType != "thread_broadcast" && ev.SubType != "file_share" {
+		return nil
+	}
+	if tickets == nil || reply.BotID != "" || reply.ThreadTimeStamp == "" || reply.ThreadTimeStamp == reply.TimeStamp {
+		return nil
+	}
+	found, _, err := tickets.ListTickets(ctx, store.Filter{ChannelID: ev.Channel, ThreadTS: reply.ThreadTimeStamp, Limit: 1})
+	if err != nil {
+		return fmt.Errorf("Failed to find the ticket in thread %s: %s", reply.ThreadTimeStamp, err)
+	}
+	if len(found) == 0 {
+		return nil
+	}
+	id := found[0].ID
+	c := ticket.Comment{ID: reply.TimeStamp, Author: reply.User, Text: reply.Text, CreatedAt: threads.Time(reply.TimeStamp, clk.Now())}
+	err = tickets.Tx(ctx, func(tx store.Store) error {
+		t, err := tx.GetTicket(ctx, id)
+		if err != nil {
+			return err
+		}
+		if !t.AddComment(c) {
+			return nil
+		}
+		return tx.UpdateTicket(ctx, t)
+	})
+	if err != nil {
+		return fmt.Errorf("Failed to add a comment to ticket %s: %s", id, err)
+	}
+	return nil
+}
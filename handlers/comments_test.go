@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/nlopes/slack/slackevents"
+
+	"github.com/skybet/go-helpdesk/mocks"
+	"github.com/skybet/go-helpdesk/store"
+	"github.com/skybet/go-helpdesk/ticket"
+)
+
+func TestRecordComment(t *testing.T) {
+	Init(mocks.NewSlack(t))
+	s := store.NewMemory()
+	InitTickets(s)
+	s.CreateTicket(context.Background(), &ticket.Ticket{ID: "1", Reporter: "U1", ChannelID: "C1", ThreadTS: "1500000000.000100"})
+
+	for _, ev := range []*slackevents.MessageEvent{
+		{Channel: "C1", User: "U2", Text: "Have you tried turning it off?", ThreadTimeStamp: "1500000000.000100", TimeStamp: "1500000060.000200"},
+		{Channel: "C1", User: "U1", Text: "Yes", ThreadTimeStamp: "1500000000.000100", TimeStamp: "1500000120.000300"},
+		{Channel: "C1", BotID: "B1", Text: "Ticket #1 assigned", ThreadTimeStamp: "1500000000.000100", TimeStamp: "1500000130.000400"},
+		{Channel: "C1", User: "U3", Text: "Another thread", ThreadTimeStamp: "1600000000.000100", TimeStamp: "1600000001.000100"},
+		{Channel: "C1", SubType: "message_changed", Message: &slackevents.MessageEvent{
+			User: "U1", Text: "Yes, twice", ThreadTimeStamp: "1500000000.000100", TimeStamp: "1500000120.000300",
+		}},
+	} {
+		req, res, _ := newTestRequest()
+		if err := Message(res, req, &slackevents.EventsAPIEvent{InnerEvent: slackevents.EventsAPIInnerEvent{Type: "message", Data: ev}}); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+	}
+	tk, err := s.GetTicket(context.Background(), "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tk.Comments) != 2 {
+		t.Fatalf("Expected the replies by people to be comments, got %+v", tk.Comments)
+	}
+	if c := tk.Comments[0]; c.Author != "U2" || c.Text != "Have you tried turning it off?" || c.CreatedAt.Unix() != 1500000060 {
+		t.Errorf("Expected the agent's reply at its timestamp, got %+v", c)
+	}
+	if c := tk.Comments[1]; c.Author != "U1" || c.Text != "Yes, twice" {
+		t.Errorf("Expected the reporter's edited reply, got %+v", c)
+	}
+	found, _, _ := s.ListTickets(context.Background(), store.Filter{Text: "turning it off"})
+	if len(found) != 1 {
+		t.Errorf("Expected the ticket to be found by its comments, got %d", len(found))
+	}
+}
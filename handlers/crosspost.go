@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/nlopes/slack"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/skybet/go-helpdesk/blocks"
+	"github.com/skybet/go-helpdesk/crosspost"
+	"github.com/skybet/go-helpdesk/server"
+	"github.com/skybet/go-helpdesk/store"
+	"github.com/skybet/go-helpdesk/ticket"
+)
+
+var mirror *crosspost.Mirror
+
+// InitCrossPost sets the mirror used to share tickets between queues, its
+// cards are rendered by shareCard
+func InitCrossPost(m *crosspost.Mirror) {
+	if m != nil {
+		m.Card = shareCard
+	}
+	mirror = m
+}
+
+// Share handles /hd share <ticket> <queue>..., posting the ticket's card in
+// the channel of each queue so they can work on it together. The ticket is
+// resolved once every queue, including its own, has marked its part done.
+func Share(res *server.Response, req *server.Request, ctx interface{}) error {
+	sc, ok := ctx.(slack.SlashCommand)
+	if !ok {
+		return fmt.Errorf("Expected a slack.SlashCommand to be passed to the handler")
+	}
+	if mirror == nil {
+		res.Text(http.StatusOK, tr(sc, "No queues have a channel to share tickets in"))
+		return nil
+	}
+	args := strings.Fields(sc.Text)
+	if len(args) < 3 {
+		res.Text(http.StatusOK, tr(sc, "Usage: %s share <ticket> <queue>...", sc.Command))
+		return nil
+	}
+	id := strings.TrimPrefix(args[1], "#")
+	t, err := mirror.Share(req.Context(), id, args[2:]...)
+	if err == store.ErrNotFound {
+		res.Text(http.StatusOK, tr(sc, "There is no ticket #%s", id))
+		return nil
+	}
+	if err != nil && t == nil {
+		res.Text(http.StatusOK, tr(sc, "Ticket #%s could not be shared: %s", id, err))
+		return nil
+	}
+	if err != nil {
+		log.Errorf("Failed to update the copies of ticket %s: %s", t.ID, err)
+	}
+	queues := make([]string, len(t.Shares))
+	for i, s := range t.Shares {
+		queues[i] = s.Queue
+	}
+	res.Text(http.StatusOK, tr(sc, "Ticket %s is shared between %s", ticketLinks.Ref(t.ID), strings.Join(queues, ", ")))
+	return nil
+}
+
+// CrossPostDone handles the button marking a queue's part of a shared ticket
+// done, resolving the ticket once it is the last. The progress is posted in
+// the ticket's thread from the outbox.
+func CrossPostDone(res *server.Response, req *server.Request, ctx interface{}) error {
+	ic, ok := ctx.(*slack.InteractionCallback)
+	if !ok {
+		return fmt.Errorf("Expected a *slack.InteractionCallback to be passed to the handler")
+	}
+	if mirror == nil {
+		return fmt.Errorf("Cross posting has not been initialised")
+	}
+	action, err := blocks.ActionOf(ic)
+	if err != nil {
+		return err
+	}
+	parts := strings.SplitN(action.Value, ":", 2)
+	if len(parts) != 2 {
+		return fmt.Errorf("Invalid share: %q", action.Value)
+	}
+	id, version := ticket.ParseRef(parts[0])
+	t, _, err := mirror.Done(req.Context(), id, parts[1], ic.User.ID, version, clk.Now())
+	if err == store.ErrStale {
+		// Someone else changed the ticket first, show this user what they did
+		if t, err = tickets.GetTicket(req.Context(), id); err != nil {
+			return fmt.Errorf("Failed to get ticket: %s", err)
+		}
+		syncShares(t)
+		return refreshed(ic, t)
+	}
+	if t == nil {
+		return fmt.Errorf("Failed to mark ticket done: %s", err)
+	}
+	if err != nil {
+		log.Errorf("Failed to update the copies of ticket %s: %s", t.ID, err)
+	}
+	return nil
+}
+
+// shareCard renders the copy of a shared ticket's card for share s, listing
+// the progress of every queue with a button for s's queue to mark its part
+// done
+func shareCard(t *ticket.Ticket, s ticket.Share) []slack.MsgOption {
+	lines := []string{"*Queues*"}
+	for _, q := range t.Shares {
+		if q.Done() {
+			lines = append(lines, fmt.Sprintf("%s %s, done by <@%s>", taxonomy.Status(ticket.StatusResolved).Emoji, q.Queue, q.DoneBy))
+		} else {
+			lines = append(lines, fmt.Sprintf("%s %s", taxonomy.Status(ticket.StatusInProgress).Emoji, q.Queue))
+		}
+	}
+	extra := []slack.Block{slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType, strings.Join(lines, "\n"), false, false), nil, nil)}
+	if !s.Done() && t.Status.Open() {
+		button := slack.NewButtonBlockElement(crosspost.DoneActionID, t.Ref()+":"+s.Queue, slack.NewTextBlockObject(slack.PlainTextType, fmt.Sprintf("Mark %s done", s.Queue), false, false))
+		button.Style = slack.StylePrimary
+		extra = append(extra, slack.NewActionBlock("", button))
+	}
+	return cardMessage(t, agentCard, fmt.Sprintf("Ticket #%s shared with %s: %s", t.ID, s.Queue, t.Title), extra...)
+}
+
+// syncShares updates the copies of a shared ticket's card after it changes
+func syncShares(t *ticket.Ticket) {
+	if mirror == nil || len(t.Shares) == 0 {
+		return
+	}
+	if err := mirror.Sync(t); err != nil {
+		log.Errorf("Failed to update the copies of ticket %s: %s", t.ID, err)
+	}
+}
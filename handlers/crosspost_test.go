@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/nlopes/slack"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/skybet/go-helpdesk/crosspost"
+	"github.com/skybet/go-helpdesk/mocks"
+	"github.com/skybet/go-helpdesk/store"
+	"github.com/skybet/go-helpdesk/ticket"
+)
+
+func TestShareAndDone(t *testing.T) {
+	mockSlack := &mocks.SlackWrapper{}
+	var cards []string
+	record := func(args mock.Arguments) {
+		var options []slack.MsgOption
+		for _, a := range args {
+			if o, ok := a.(slack.MsgOption); ok {
+				options = append(options, o)
+			}
+		}
+		_, values, _ := slack.UnsafeApplyMsgOptions("", "", "", options...)
+		cards = append(cards, values.Get("blocks"))
+	}
+	mockSlack.On("PostMessage", "CIT", mock.Anything, mock.Anything).Run(record).Return("CIT", "1.1", nil)
+	mockSlack.On("PostMessage", "CHR", mock.Anything, mock.Anything).Run(record).Return("CHR", "2.1", nil)
+	mockSlack.On("UpdateMessage", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Run(record).Return("", "", "", nil)
+	mockSlack.On("PostMessage", "C1", mock.Anything, mock.Anything).Return("C1", "3.1", nil)
+	Init(mockSlack)
+	s := store.NewMemory()
+	s.CreateTicket(context.Background(), &ticket.Ticket{ID: "1", Queue: "it", Title: "New starter", ChannelID: "C1", ThreadTS: "0.1"})
+	InitTickets(s)
+	InitCrossPost(&crosspost.Mirror{Store: s, Slack: mockSlack, Channels: map[string]string{"it": "CIT", "hr": "CHR"}})
+	defer InitCrossPost(nil)
+
+	req, res, w := newTestRequest()
+	if err := Helpdesk(res, req, slack.SlashCommand{Command: "/hd", Text: "share 1 hr", UserID: "U1"}); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if body := w.Body.String(); !strings.Contains(body, "shared between it, hr") {
+		t.Errorf("Expected the queues to be listed, got %s", body)
+	}
+	if last := cards[len(cards)-1]; !strings.Contains(last, "Mark hr done") || !strings.Contains(last, `"1@2:hr"`) {
+		t.Errorf("Expected hr's copy to have its done button, got %s", last)
+	}
+
+	for _, q := range []string{"hr", "it"} {
+		ic := &slack.InteractionCallback{Type: slack.InteractionTypeBlockActions}
+		ic.User.ID = "U2"
+		ic.ActionCallback.BlockActions = []*slack.BlockAction{{ActionID: crosspost.DoneActionID, Value: "1:" + q}}
+		if err := CrossPostDone(res, req, ic); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+	}
+	if tk, _ := s.GetTicket(context.Background(), "1"); tk.Status != ticket.StatusResolved {
+		t.Errorf("Expected the ticket to be resolved once both queues were done, got %s", tk.Status)
+	}
+	if last := cards[len(cards)-1]; strings.Contains(last, crosspost.DoneActionID) || !strings.Contains(last, "done by \\u003c@U2\\u003e") {
+		t.Errorf("Expected the copies to show both queues done, got %s", last)
+	}
+	mockSlack.AssertNumberOfCalls(t, "PostMessage", 2)
+	if pending, _ := s.Outbox(context.Background(), 0); len(pending) != 2 || !strings.Contains(pending[1].Text, "it has been resolved") {
+		t.Errorf("Expected the progress to be posted from the outbox, got %+v", pending)
+	}
+}
+
+func TestCrossPostDoneConflict(t *testing.T) {
+	mockSlack := &mocks.SlackWrapper{}
+	var ephemeral []string
+	mockSlack.On("UpdateMessage", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return("", "", "", nil)
+	mockSlack.On("PostMessage", "C1", mock.Anything, mock.Anything).Return("C1", "3.1", nil)
+	mockSlack.On("PostMessage", "CHR", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		var options []slack.MsgOption
+		for _, a := range args {
+			if o, ok := a.(slack.MsgOption); ok {
+				options = append(options, o)
+			}
+		}
+		endpoint, values, _ := slack.UnsafeApplyMsgOptions("", "", "", options...)
+		ephemeral = append(ephemeral, endpoint+" "+values.Get("user")+" "+values.Get("text"))
+	}).Return("CHR", "", nil)
+	Init(mockSlack)
+	s := store.NewMemory()
+	s.CreateTicket(context.Background(), &ticket.Ticket{ID: "1", Queue: "it", ChannelID: "C1", ThreadTS: "0.1", Shares: []ticket.Share{
+		{Queue: "it", ChannelID: "CIT", TS: "1.1"},
+		{Queue: "hr", ChannelID: "CHR", TS: "2.1"},
+	}})
+	InitTickets(s)
+	InitCrossPost(&crosspost.Mirror{Store: s, Slack: mockSlack, Channels: map[string]string{"it": "CIT", "hr": "CHR"}})
+	defer InitCrossPost(nil)
+
+	// Both agents click the button on the same version of hr's card
+	req, res, _ := newTestRequest()
+	for _, user := range []string{"U1", "U2"} {
+		ic := &slack.InteractionCallback{Type: slack.InteractionTypeBlockActions}
+		ic.User.ID = user
+		ic.Channel.ID = "CHR"
+		ic.ActionCallback.BlockActions = []*slack.BlockAction{{ActionID: crosspost.DoneActionID, Value: "1@1:hr"}}
+		if err := CrossPostDone(res, req, ic); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+	}
+	if tk, _ := s.GetTicket(context.Background(), "1"); tk.Shares[1].DoneBy != "U1" {
+		t.Errorf("Expected the first agent's action to win, got %+v", tk.Shares[1])
+	}
+	if len(ephemeral) != 1 || !strings.Contains(ephemeral[0], "chat.postEphemeral U2 ") || !strings.Contains(ephemeral[0], "card has been refreshed") {
+		t.Errorf("Expected the second agent to be told the card was refreshed, got %q", ephemeral)
+	}
+	// Each of the two copies is updated after the first action and again for
+	// the second agent
+	mockSlack.AssertNumberOfCalls(t, "UpdateMessage", 4)
+}
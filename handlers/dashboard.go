@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/nlopes/slack"
+
+	"github.com/skybet/go-helpdesk/hierarchy"
+	"github.com/skybet/go-helpdesk/projection"
+	"github.com/skybet/go-helpdesk/render"
+	"github.com/skybet/go-helpdesk/report"
+	"github.com/skybet/go-helpdesk/server"
+)
+
+var (
+	projector *projection.Projector
+	units     *hierarchy.Tree
+)
+
+// InitProjection sets the projection of the tickets dashboards are built from,
+// without it every ticket is listed from the store
+func InitProjection(p *projection.Projector) {
+	projector = p
+}
+
+// InitHierarchy sets the departments queues are grouped into, /hd dashboard
+// then shows the tickets of the organisation, a department or a queue rolled
+// up from the queues beneath it
+func InitHierarchy(t *hierarchy.Tree) {
+	units = t
+}
+
+// Dashboard handles /hd dashboard [unit], replying with the current state of
+// the helpdesk and next week's forecast, or with the dashboard of a unit of
+// the hierarchy
+func Dashboard(res *server.Response, req *server.Request, ctx interface{}) error {
+	sc, ok := ctx.(slack.SlashCommand)
+	if !ok {
+		return fmt.Errorf("Expected a slack.SlashCommand to be passed to the handler")
+	}
+	if args := strings.Fields(sc.Text); len(args) > 1 {
+		return unitDashboard(req.Context(), res, sc, strings.Join(args[1:], " "))
+	}
+	d, err := dashboard(req.Context())
+	if err != nil {
+		return err
+	}
+	if style := styles.Style(sc.UserID); style == render.Plain {
+		return res.JSON(http.StatusOK, slack.Msg{ResponseType: slack.ResponseTypeEphemeral, Text: d.Text(style)})
+	}
+	return res.JSON(http.StatusOK, slack.Msg{ResponseType: slack.ResponseTypeEphemeral, Text: d.Text(render.Rich), Blocks: slack.Blocks{BlockSet: d.Blocks()}})
+}
+
+// unitDashboard replies with the rollup of a unit of the hierarchy
+func unitDashboard(ctx context.Context, res *server.Response, sc slack.SlashCommand, name string) error {
+	if units == nil {
+		res.Text(http.StatusOK, tr(sc, "Departments have not been set up, use %s dashboard", sc.Command))
+		return nil
+	}
+	p := projector
+	if p == nil {
+		if tickets == nil {
+			return fmt.Errorf("Tickets have not been initialised")
+		}
+		p = projection.New(serviceLevels, 0)
+		if err := p.Load(ctx, tickets); err != nil {
+			return fmt.Errorf("Failed to build dashboard: %s", err)
+		}
+	}
+	now := clk.Now()
+	rollup := report.BuildRollup(units, p.Queues(), p.AtRisk(now), now).Find(name)
+	if rollup == nil {
+		res.Text(http.StatusOK, tr(sc, "There is no department or queue called %s", name))
+		return nil
+	}
+	return res.JSON(http.StatusOK, slack.Msg{ResponseType: slack.ResponseTypeEphemeral, Text: rollup.Text(styles.Style(sc.UserID))})
+}
+
+// dashboard builds the current state of the helpdesk, from the projection if
+// there is one
+func dashboard(ctx context.Context) (*report.Dashboard, error) {
+	if tickets == nil {
+		return nil, fmt.Errorf("Tickets have not been initialised")
+	}
+	var d *report.Dashboard
+	if projector != nil {
+		d = report.ProjectDashboard(projector, clk.Now())
+	} else {
+		var err error
+		if d, err = report.BuildDashboard(ctx, tickets, clk.Now()); err != nil {
+			return nil, fmt.Errorf("Failed to build dashboard: %s", err)
+		}
+	}
+	d.WIPLimits = limits.Queues()
+	return d, nil
+}
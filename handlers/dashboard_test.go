@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/nlopes/slack"
+	"github.com/skybet/go-helpdesk/hierarchy"
+	"github.com/skybet/go-helpdesk/sla"
+	"github.com/skybet/go-helpdesk/store"
+	"github.com/skybet/go-helpdesk/ticket"
+)
+
+func TestDashboard(t *testing.T) {
+	s := store.NewMemory()
+	s.CreateTicket(context.Background(), &ticket.Ticket{Queue: "it"})
+	s.CreateTicket(context.Background(), &ticket.Ticket{Queue: "it", Status: ticket.StatusResolved})
+	InitTickets(s)
+	req, res, w := newTestRequest()
+
+	if err := Helpdesk(res, req, slack.SlashCommand{Command: "/hd", Text: "dashboard"}); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if body := w.Body.String(); !strings.Contains(body, `it: *1*`) || !strings.Contains(body, "ephemeral") {
+		t.Errorf("Expected the open tickets to be counted, got %s", body)
+	}
+}
+
+func TestUnitDashboard(t *testing.T) {
+	s := store.NewMemory()
+	s.CreateTicket(context.Background(), &ticket.Ticket{Queue: "it"})
+	s.CreateTicket(context.Background(), &ticket.Ticket{Queue: "it-hardware", Assignee: "U1"})
+	InitTickets(s)
+	serve := func(text string) string {
+		req, res, w := newTestRequest()
+		if err := Helpdesk(res, req, slack.SlashCommand{Command: "/hd", Text: text}); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		return w.Body.String()
+	}
+	if body := serve("dashboard IT"); !strings.Contains(body, "Departments have not been set up") {
+		t.Errorf("Expected units to need a hierarchy, got %s", body)
+	}
+
+	tree, err := hierarchy.New(hierarchy.Unit{Name: "Acme", Departments: []hierarchy.Unit{{Name: "IT", Queues: []hierarchy.Unit{{Name: "it"}, {Name: "it-hardware"}}}}}, sla.SLA{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	InitHierarchy(tree)
+	defer InitHierarchy(nil)
+	if body := serve("dashboard it"); !strings.Contains(body, "*IT dashboard* (department)") || !strings.Contains(body, "Total: *2* open, 1 in progress") || !strings.Contains(body, "ephemeral") {
+		t.Errorf("Expected IT's tickets to be rolled up, got %s", body)
+	}
+	if body := serve("dashboard finance"); !strings.Contains(body, "There is no department or queue called finance") {
+		t.Errorf("Expected an unknown unit to be reported, got %s", body)
+	}
+}
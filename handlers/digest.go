@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/nlopes/slack"
+
+	"github.com/skybet/go-helpdesk/blocks"
+	"github.com/skybet/go-helpdesk/digest"
+	"github.com/skybet/go-helpdesk/server"
+)
+
+var questions *digest.Tracker
+
+// InitDigest sets the tracker which Message feeds with questions asked in
+// support channels
+func InitDigest(t *digest.Tracker) {
+	questions = t
+}
+
+// DigestConvert handles the "convert to ticket" button on a digest, creating a
+// ticket from the question and replying in its thread
+func DigestConvert(res *server.Response, req *server.Request, ctx interface{}) error {
+	ic, ok := ctx.(*slack.InteractionCallback)
+	if !ok {
+		return fmt.Errorf("Expected a *slack.InteractionCallback to be passed to the handler")
+	}
+	if questions == nil || tickets == nil {
+		return fmt.Errorf("Digest conversion has not been initialised")
+	}
+	action, err := blocks.ActionOf(ic)
+	if err != nil {
+		return err
+	}
+	parts := strings.SplitN(action.Value, ":", 2)
+	if len(parts) != 2 {
+		return fmt.Errorf("Invalid question reference: %q", action.Value)
+	}
+	q, ok := questions.Resolve(parts[0], parts[1])
+	if !ok {
+		// Already converted or answered since the digest was posted
+		return nil
+	}
+
+	t, a, err := createTicket(req.Context(), ic.Team.ID, q.Channel, q.TS, q.User, q.Text)
+	if err != nil {
+		return err
+	}
+	reply := echo(fmt.Sprintf("<@%s> is looking into this, it is tracked as ticket %s", ic.User.ID, ticketLinks.Ref(t.ID)), a)
+	if _, _, err := slackIn(req.Context()).PostMessage(q.Channel, slack.MsgOptionTS(q.TS), slack.MsgOptionText(reply, false)); err != nil {
+		return fmt.Errorf("Failed to reply to question: %s", err)
+	}
+	return nil
+}
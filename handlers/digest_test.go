@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/nlopes/slack"
+	"github.com/nlopes/slack/slackevents"
+	"github.com/skybet/go-helpdesk/digest"
+	"github.com/skybet/go-helpdesk/mocks"
+	"github.com/skybet/go-helpdesk/store"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestDigestConvert(t *testing.T) {
+	mockSlack := &mocks.SlackWrapper{}
+	mockSlack.On("PostMessage", "C1", mock.Anything, mock.Anything).Return("C1", "2.1", nil)
+	Init(mockSlack)
+	s := store.NewMemory()
+	InitTickets(s)
+	InitDigest(digest.NewTracker([]string{"C1"}, time.Hour))
+	defer InitDigest(nil)
+	req, res, _ := newTestRequest()
+
+	event := &slackevents.EventsAPIEvent{InnerEvent: slackevents.EventsAPIInnerEvent{
+		Type: "message",
+		Data: &slackevents.MessageEvent{Channel: "C1", User: "U1", Text: "How do I get VPN access?\nI'm new", TimeStamp: "1.1"},
+	}}
+	if err := Message(res, req, event); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	ic := &slack.InteractionCallback{Type: slack.InteractionTypeBlockActions}
+	ic.User.ID = "UAGENT"
+	ic.ActionCallback.BlockActions = []*slack.BlockAction{{ActionID: digest.ConvertActionID, Value: "C1:1.1"}}
+	if err := DigestConvert(res, req, ic); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	mockSlack.AssertExpectations(t)
+
+	tickets, _, _ := s.ListTickets(context.Background(), store.Filter{})
+	if len(tickets) != 1 || tickets[0].Title != "How do I get VPN access?" || tickets[0].Reporter != "U1" || tickets[0].ThreadTS != "1.1" {
+		t.Fatalf("Expected a ticket for the question, got %+v", tickets)
+	}
+
+	// A second click is ignored
+	if err := DigestConvert(res, req, ic); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	mockSlack.AssertNumberOfCalls(t, "PostMessage", 1)
+}
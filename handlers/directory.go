@@ -0,0 +1,39 @@
+package handlers
+
+import (
+	"fmt"
+
+	"github.com/nlopes/slack/slackevents"
+
+	"github.com/skybet/go-helpdesk/server"
+)
+
+// changeApplier keeps cached users, channels and usergroups up to date from
+// Slack's change events
+type changeApplier interface {
+	Apply(ev interface{}) bool
+}
+
+var directoryChanges changeApplier
+
+// InitDirectoryChanges sets the cache updated by DirectoryChange
+func InitDirectoryChanges(c changeApplier) {
+	directoryChanges = c
+}
+
+// DirectoryChange handles user_change, team_join, channel_rename,
+// channel_archive and subteam_updated events, updating the cached users,
+// channels and usergroups
+func DirectoryChange(res *server.Response, req *server.Request, ctx interface{}) error {
+	event, ok := ctx.(*slackevents.EventsAPIEvent)
+	if !ok {
+		return fmt.Errorf("Expected a *slackevents.EventsAPIEvent to be passed to the handler")
+	}
+	if directoryChanges == nil {
+		return nil
+	}
+	if !directoryChanges.Apply(event.InnerEvent.Data) {
+		return fmt.Errorf("Expected a directory change event, got %T", event.InnerEvent.Data)
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/nlopes/slack"
+	"github.com/nlopes/slack/slackevents"
+
+	"github.com/skybet/go-helpdesk/wrapper"
+)
+
+type directoryAPI struct{}
+
+func (directoryAPI) GetUsersContext(ctx context.Context) ([]slack.User, error) {
+	return []slack.User{{ID: "U1", Name: "old"}}, nil
+}
+func (directoryAPI) GetUserInfoContext(ctx context.Context, user string) (*slack.User, error) {
+	return &slack.User{ID: user}, nil
+}
+func (directoryAPI) GetConversationsContext(ctx context.Context, params *slack.GetConversationsParameters) ([]slack.Channel, string, error) {
+	return nil, "", nil
+}
+func (directoryAPI) GetConversationInfoContext(ctx context.Context, channelID string, includeLocale bool) (*slack.Channel, error) {
+	return &slack.Channel{}, nil
+}
+func (directoryAPI) GetUserGroupMembersContext(ctx context.Context, userGroup string) ([]string, error) {
+	return nil, nil
+}
+
+func TestDirectoryChange(t *testing.T) {
+	d := wrapper.NewDirectory(directoryAPI{}, wrapper.DirectoryConfig{TTL: time.Hour})
+	d.Users(context.Background())
+	InitDirectoryChanges(d)
+	defer InitDirectoryChanges(nil)
+	req, res, _ := newTestRequest()
+
+	event := &slackevents.EventsAPIEvent{InnerEvent: slackevents.EventsAPIInnerEvent{
+		Type: "user_change",
+		Data: &slack.UserChangeEvent{Type: "user_change", User: slack.User{ID: "U1", Name: "new"}},
+	}}
+	if err := DirectoryChange(res, req, event); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if u, _ := d.User(context.Background(), "U1"); u.Name != "new" {
+		t.Errorf("Expected the cached user to be updated, got %+v", u)
+	}
+	event.InnerEvent.Data = &slackevents.MessageEvent{}
+	if err := DirectoryChange(res, req, event); err == nil {
+		t.Errorf("Expected other events to be refused")
+	}
+}
@@ -0,0 +1,180 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/nlopes/slack"
+	"github.com/nlopes/slack/slackevents"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/skybet/go-helpdesk/email"
+	"github.com/skybet/go-helpdesk/store"
+	"github.com/skybet/go-helpdesk/ticket"
+)
+
+var (
+	mailer       email.Sender
+	emailChannel string
+	emailDomain  string
+)
+
+// InitEmail sets the channel tickets raised by email are posted in and how
+// the replies in their threads are emailed back, with Message-IDs in domain
+func InitEmail(s email.Sender, channel, domain string) {
+	mailer, emailChannel, emailDomain = s, channel, domain
+}
+
+// InboundEmail handles the inbound webhook of an email service. A new email
+// raises a ticket in the email channel and a reply to one of a ticket's
+// emails is posted in its thread. Emails are raised as tickets whether or
+// not the sender is in the workspace, and the sender is the ticket's
+// reporter if they are.
+func InboundEmail(p email.Parser, users emailLookup, teamID string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		m, err := p(r)
+		if err != nil {
+			log.Warnf("Refused an inbound email: %s", err)
+			http.Error(w, "invalid email", http.StatusNotAcceptable)
+			return
+		}
+		if err := receiveEmail(m, users, teamID); err != nil {
+			log.Errorf("Failed to handle an email from %s: %s", m.From, err)
+			http.Error(w, "error handling email", http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+}
+
+// receiveEmail adds an email to the ticket it replies to, or raises a ticket
+// for it
+func receiveEmail(m *email.Message, users emailLookup, teamID string) error {
+	if mailer == nil || tickets == nil || emailChannel == "" {
+		return fmt.Errorf("email is not configured")
+	}
+	if id, ok := m.TicketID(emailDomain); ok {
+		t, err := tickets.GetTicket(context.Background(), id)
+		if err != nil && err != store.ErrNotFound {
+			return err
+		}
+		// Only the requester replies to a ticket by email, anyone else
+		// raises their own
+		if err == nil && t.Email == m.From && !t.Deleted() {
+			return emailReply(context.Background(), t, m)
+		}
+	}
+	reporter := ""
+	if u, err := users.GetUserByEmail(m.From); err == nil && !u.Deleted && !u.IsBot {
+		reporter = u.ID
+	}
+	text := strings.TrimSpace(m.Subject)
+	if text == "" {
+		text = "No subject"
+	}
+	if body := strings.TrimSpace(m.Text); body != "" {
+		text += "\n\n" + body
+	}
+	_, ts, err := slackWrapper.PostMessage(emailChannel, slack.MsgOptionText(fmt.Sprintf("%s emailed the helpdesk", sender(m)), false))
+	if err != nil {
+		return fmt.Errorf("Failed to post in %s: %s", emailChannel, err)
+	}
+	t, a, err := createTicket(store.WithTeam(context.Background(), teamID), teamID, emailChannel, ts, reporter, text, func(t *ticket.Ticket) {
+		t.Email, t.EmailThread = m.From, m.MessageID
+	})
+	if err != nil {
+		return err
+	}
+	summary := fmt.Sprintf("Ticket #%s emailed by %s: %s", t.ID, sender(m), t.Title)
+	if _, _, _, err := slackWrapper.UpdateMessage(emailChannel, ts, cardMessage(t, a, summary)...); err != nil {
+		log.Errorf("Failed to show ticket %s in %s: %s", t.ID, emailChannel, err)
+	}
+	err = mailer.Send(email.Outgoing{
+		To:         t.Email,
+		Subject:    email.Subject(t.ID, t.Title),
+		Text:       fmt.Sprintf("Thanks, your request is ticket #%s. Reply to this email to add to it.", t.ID),
+		MessageID:  email.MessageID(t.ID, ts, emailDomain),
+		InReplyTo:  t.EmailThread,
+		References: references(t),
+	})
+	if err != nil {
+		log.Errorf("Failed to acknowledge ticket %s by email: %s", t.ID, err)
+	}
+	return nil
+}
+
+// emailReply posts the requester's reply by email in the ticket's thread and
+// keeps it as a comment, as the bot's own messages are not
+func emailReply(ctx context.Context, t *ticket.Ticket, m *email.Message) error {
+	reply := email.Reply(m.Text)
+	if reply == "" {
+		return nil
+	}
+	_, ts, err := slackIn(ctx).PostMessage(t.ChannelID, slack.MsgOptionText(fmt.Sprintf("%s replied by email:\n%s", sender(m), reply), false), slack.MsgOptionTS(t.ThreadTS))
+	if err != nil {
+		return fmt.Errorf("Failed to post the reply to ticket %s: %s", t.ID, err)
+	}
+	c := ticket.Comment{ID: ts, Author: t.Reporter, Text: reply, CreatedAt: clk.Now()}
+	err = tickets.Tx(ctx, func(tx store.Store) error {
+		t, err := tx.GetTicket(ctx, t.ID)
+		if err != nil {
+			return err
+		}
+		t.AddComment(c)
+		return tx.UpdateTicket(ctx, t)
+	})
+	if err != nil {
+		return fmt.Errorf("Failed to add the reply to ticket %s: %s", t.ID, err)
+	}
+	return nil
+}
+
+// emailResponse emails a reply in the thread of a ticket raised by email to
+// its requester
+func emailResponse(ctx context.Context, ev *slackevents.MessageEvent) error {
+	if mailer == nil || tickets == nil || ev.ThreadTimeStamp == "" || ev.ThreadTimeStamp == ev.TimeStamp || ev.BotID != "" || (ev.SubType != "" && ev.SubType != "thread_broadcast") {
+		return nil
+	}
+	found, _, err := tickets.ListTickets(ctx, store.Filter{ChannelID: ev.Channel, ThreadTS: ev.ThreadTimeStamp, Limit: 1})
+	if err != nil {
+		return fmt.Errorf("Failed to find the ticket in thread %s: %s", ev.ThreadTimeStamp, err)
+	}
+	if len(found) == 0 || found[0].Email == "" || ev.User == found[0].Reporter {
+		return nil
+	}
+	t := found[0]
+	err = mailer.Send(email.Outgoing{
+		To:         t.Email,
+		Subject:    "Re: " + email.Subject(t.ID, t.Title),
+		Text:       fmt.Sprintf("%s\n\n-- \nReply to this email to respond on ticket #%s.", ev.Text, t.ID),
+		MessageID:  email.MessageID(t.ID, ev.TimeStamp, emailDomain),
+		InReplyTo:  t.EmailThread,
+		References: references(t),
+	})
+	if err != nil {
+		return fmt.Errorf("Failed to email the reply on ticket %s: %s", t.ID, err)
+	}
+	return nil
+}
+
+// references returns the Message-IDs a ticket's emails reference
+func references(t *ticket.Ticket) []string {
+	if t.EmailThread == "" {
+		return nil
+	}
+	return []string{t.EmailThread}
+}
+
+// sender names the sender of an email
+func sender(m *email.Message) string {
+	if m.Name != "" {
+		return fmt.Sprintf("%s (%s)", m.Name, m.From)
+	}
+	return m.From
+}
@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/nlopes/slack/slackevents"
+
+	"github.com/skybet/go-helpdesk/email"
+	"github.com/skybet/go-helpdesk/mocks"
+	"github.com/skybet/go-helpdesk/store"
+	"github.com/skybet/go-helpdesk/ticket"
+)
+
+type fakeMailer struct {
+	sent []email.Outgoing
+}
+
+func (f *fakeMailer) Send(m email.Outgoing) error {
+	f.sent = append(f.sent, m)
+	return nil
+}
+
+func TestInboundEmail(t *testing.T) {
+	mockSlack := mocks.NewSlack(t)
+	mockSlack.ExpectPostMessage().ToChannel("C9").WithText("Alice (alice@example.com) emailed the helpdesk").ReturnTS("1500000000.000100")
+	mockSlack.ExpectUpdateMessage().ToChannel("C9").ForTS("1500000000.000100").WithText("Ticket #1 emailed by Alice (alice@example.com): VPN down")
+	mockSlack.ExpectPostMessage().ToChannel("C9").WithText("Alice (alice@example.com) replied by email:\nStill down").ReturnTS("1500000120.000300")
+	Init(mockSlack)
+	s := store.NewMemory()
+	InitTickets(s)
+	mailer := &fakeMailer{}
+	InitEmail(mailer, "C9", "help.example.com")
+	defer InitEmail(nil, "", "")
+
+	var next *email.Message
+	h := InboundEmail(func(r *http.Request) (*email.Message, error) {
+		if next == nil {
+			return nil, errors.New("invalid signature")
+		}
+		return next, nil
+	}, fakeEmails{"alice@example.com": {ID: "U1"}}, "T1")
+	receive := func(m *email.Message) int {
+		next = m
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/mailgun", nil))
+		return w.Code
+	}
+
+	if code := receive(nil); code != http.StatusNotAcceptable {
+		t.Errorf("Expected unverified emails to be refused, got %d", code)
+	}
+	if code := receive(&email.Message{From: "alice@example.com", Name: "Alice", Subject: "VPN down", Text: "It will not connect", MessageID: "<a1@example.com>"}); code != http.StatusOK {
+		t.Fatalf("Expected the email to be handled, got %d", code)
+	}
+	tk, err := s.GetTicket(context.Background(), "1")
+	if err != nil || tk.Reporter != "U1" || tk.Email != "alice@example.com" || tk.EmailThread != "<a1@example.com>" || tk.Description != "VPN down\n\nIt will not connect" {
+		t.Fatalf("Expected a ticket for the email, got %+v (%v)", tk, err)
+	}
+	if len(mailer.sent) != 1 || mailer.sent[0].InReplyTo != "<a1@example.com>" || mailer.sent[0].Subject != "[#1] VPN down" {
+		t.Fatalf("Expected the email to be acknowledged in its thread, got %+v", mailer.sent)
+	}
+
+	reply := &email.Message{From: "alice@example.com", Name: "Alice", Subject: "Re: [#1] VPN down", Text: "Still down\n\nOn Mon, Helpdesk wrote:\n> Thanks", InReplyTo: mailer.sent[0].MessageID}
+	if code := receive(reply); code != http.StatusOK {
+		t.Fatalf("Expected the reply to be handled, got %d", code)
+	}
+	if tk, _ := s.GetTicket(context.Background(), "1"); len(tk.Comments) != 1 || tk.Comments[0].Text != "Still down" || tk.Comments[0].Author != "U1" {
+		t.Errorf("Expected the reply to be a comment, got %+v", tk.Comments)
+	}
+
+	for _, ev := range []*slackevents.MessageEvent{
+		{Channel: "C9", User: "U1", Text: "Any news?", ThreadTimeStamp: "1500000000.000100", TimeStamp: "1500000130.000400"},
+		{Channel: "C9", User: "U2", Text: "Try now", ThreadTimeStamp: "1500000000.000100", TimeStamp: "1500000140.000500"},
+	} {
+		req, res, _ := newTestRequest()
+		if err := Message(res, req, &slackevents.EventsAPIEvent{InnerEvent: slackevents.EventsAPIInnerEvent{Type: "message", Data: ev}}); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+	}
+	if len(mailer.sent) != 2 {
+		t.Fatalf("Expected only the agent's reply to be emailed, got %+v", mailer.sent)
+	}
+	if m := mailer.sent[1]; m.To != "alice@example.com" || m.Subject != "Re: [#1] VPN down" || m.MessageID != "<ticket.1.1500000140.000500@help.example.com>" || m.References[0] != "<a1@example.com>" {
+		t.Errorf("Expected the agent's reply threaded with the email, got %+v", m)
+	}
+}
+
+func TestInboundEmailSpoofedReply(t *testing.T) {
+	mockSlack := mocks.NewSlack(t)
+	mockSlack.ExpectPostMessage().ToChannel("C9").WithText("eve@example.com emailed the helpdesk").ReturnTS("2.1")
+	mockSlack.ExpectUpdateMessage().ToChannel("C9").ForTS("2.1")
+	Init(mockSlack)
+	s := store.NewMemory()
+	InitTickets(s)
+	s.CreateTicket(context.Background(), &ticket.Ticket{ID: "1", Title: "VPN down", Email: "alice@example.com", ChannelID: "C9", ThreadTS: "1.1"})
+	InitEmail(&fakeMailer{}, "C9", "help.example.com")
+	defer InitEmail(nil, "", "")
+
+	if err := receiveEmail(&email.Message{From: "eve@example.com", Subject: "Re: [#1] VPN down", Text: "Close it"}, fakeEmails{}, "T1"); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if tk, _ := s.GetTicket(context.Background(), "1"); len(tk.Comments) != 0 {
+		t.Errorf("Expected someone else's reply not to be added, got %+v", tk.Comments)
+	}
+	if tk, err := s.GetTicket(context.Background(), "2"); err != nil || tk.Reporter != "" || tk.Email != "eve@example.com" {
+		t.Errorf("Expected their own ticket instead, got %+v (%v)", tk, err)
+	}
+}
@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/nlopes/slack"
+
+	"github.com/skybet/go-helpdesk/archive"
+	"github.com/skybet/go-helpdesk/blocks"
+	"github.com/skybet/go-helpdesk/escalate"
+	"github.com/skybet/go-helpdesk/outbox"
+	"github.com/skybet/go-helpdesk/server"
+	"github.com/skybet/go-helpdesk/store"
+	"github.com/skybet/go-helpdesk/ticket"
+)
+
+// EscalationAck handles the "Acknowledge" button on escalations, which stops
+// the ticket being escalated any further. The escalation is updated to show
+// who acknowledged it and the first acknowledgement is posted in the ticket's
+// thread from the outbox. Each acknowledgement of a critical notice is posted
+// too, with who has still to acknowledge theirs.
+func EscalationAck(res *server.Response, req *server.Request, ctx interface{}) error {
+	ic, ok := ctx.(*slack.InteractionCallback)
+	if !ok {
+		return fmt.Errorf("Expected a *slack.InteractionCallback to be passed to the handler")
+	}
+	action, err := blocks.ActionOf(ic)
+	if err != nil {
+		return err
+	}
+	now := clk.Now()
+	var t *ticket.Ticket
+	err = tickets.Tx(req.Context(), func(tx store.Store) error {
+		var err error
+		if t, err = escalate.Acknowledge(req.Context(), tx, action.Value, ic.User.ID, now); err != nil {
+			return err
+		}
+		if !t.AcknowledgedAt.Equal(now) && !ackedNotice(t, ic.User.ID, now) || t.ChannelID == "" || t.ThreadTS == "" || archive.Locked(t) {
+			return nil
+		}
+		text := fmt.Sprintf("<@%s> acknowledged this ticket, it will not be escalated any further", t.AcknowledgedBy)
+		if escalate.Critical(t) && len(t.Notices) > 0 {
+			text = fmt.Sprintf("<@%s> acknowledged the escalation. %s", ic.User.ID, escalate.Receipts(t))
+		}
+		return tx.Enqueue(req.Context(), outbox.Thread(t, text))
+	})
+	if err != nil {
+		return fmt.Errorf("Failed to acknowledge ticket: %s", err)
+	}
+	syncShares(t)
+	text := fmt.Sprintf("Ticket %s was acknowledged by <@%s>", ticketLinks.Ref(t.ID), t.AcknowledgedBy)
+	if ic.Channel.ID != "" && ic.Message.Timestamp != "" {
+		if _, _, _, err := slackIn(req.Context()).UpdateMessage(ic.Channel.ID, ic.Message.Timestamp, slack.MsgOptionText(text, false), slack.MsgOptionBlocks(
+			// Replace the blocks so the button goes
+			slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType, text, false, false), nil, nil),
+		)); err != nil {
+			return fmt.Errorf("Failed to update escalation: %s", err)
+		}
+	}
+	return nil
+}
+
+// ackedNotice reports whether user acknowledged a notice about t at now
+func ackedNotice(t *ticket.Ticket, user string, now time.Time) bool {
+	for _, n := range t.Notices {
+		if n.To == user && n.AckedAt.Equal(now) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nlopes/slack"
+
+	"github.com/skybet/go-helpdesk/clock"
+	"github.com/skybet/go-helpdesk/escalate"
+	"github.com/skybet/go-helpdesk/mocks"
+	"github.com/skybet/go-helpdesk/store"
+	"github.com/skybet/go-helpdesk/ticket"
+)
+
+func TestEscalationAck(t *testing.T) {
+	mockSlack := mocks.NewSlack(t)
+	mockSlack.ExpectUpdateMessage().ToChannel("D1").ForTS("2.1").Times(2)
+	Init(mockSlack)
+	s := store.NewMemory()
+	s.CreateTicket(context.Background(), &ticket.Ticket{ID: "1", ChannelID: "C1", ThreadTS: "1.1"})
+	InitTickets(s)
+	now := time.Date(2026, 10, 14, 9, 0, 0, 0, time.UTC)
+	c := clock.NewFake(now)
+	InitClock(c)
+	defer InitClock(nil)
+	req, res, _ := newTestRequest()
+
+	ic := &slack.InteractionCallback{Type: slack.InteractionTypeBlockActions}
+	ic.User.ID = "U2"
+	ic.Channel.ID = "D1"
+	ic.Message.Timestamp = "2.1"
+	ic.ActionCallback.BlockActions = []*slack.BlockAction{{ActionID: escalate.AckActionID, Value: "1"}}
+	for i := 0; i < 2; i++ {
+		if err := EscalationAck(res, req, ic); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		c.Advance(time.Second)
+	}
+	if tk, _ := s.GetTicket(context.Background(), "1"); tk.AcknowledgedBy != "U2" || !tk.AcknowledgedAt.Equal(now) {
+		t.Errorf("Expected the ticket to be acknowledged, got %+v", tk)
+	}
+	// Only the first acknowledgement is posted in the thread
+	pending, _ := s.Outbox(context.Background(), 0)
+	if len(pending) != 1 || pending[0].ThreadTS != "1.1" || !strings.Contains(pending[0].Text, "<@U2> acknowledged") {
+		t.Errorf("Expected the acknowledgement in the outbox once, got %+v", pending)
+	}
+}
+
+func TestEscalationAckCritical(t *testing.T) {
+	mockSlack := mocks.NewSlack(t)
+	mockSlack.ExpectUpdateMessage().ToChannel("D1").ForTS("2.1").Times(2)
+	Init(mockSlack)
+	s := store.NewMemory()
+	s.CreateTicket(context.Background(), &ticket.Ticket{ID: "1", Priority: ticket.P1, ChannelID: "C1", ThreadTS: "1.1", Notices: []ticket.Notice{{To: "U2", Level: "lead"}, {To: "U3", Level: "lead"}}})
+	InitTickets(s)
+	c := clock.NewFake(time.Date(2026, 10, 14, 9, 0, 0, 0, time.UTC))
+	InitClock(c)
+	defer InitClock(nil)
+	req, res, _ := newTestRequest()
+
+	for _, u := range []string{"U2", "U3"} {
+		ic := &slack.InteractionCallback{Type: slack.InteractionTypeBlockActions}
+		ic.User.ID = u
+		ic.Channel.ID = "D1"
+		ic.Message.Timestamp = "2.1"
+		ic.ActionCallback.BlockActions = []*slack.BlockAction{{ActionID: escalate.AckActionID, Value: "1"}}
+		if err := EscalationAck(res, req, ic); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		c.Advance(time.Second)
+	}
+	// Every notice's acknowledgement is posted with who is still to respond
+	pending, _ := s.Outbox(context.Background(), 0)
+	if len(pending) != 2 || pending[0].Text != "<@U2> acknowledged the escalation. Acknowledged by <@U2>. Waiting on <@U3>" || pending[1].Text != "<@U3> acknowledged the escalation. Acknowledged by <@U2>, <@U3>" {
+		t.Errorf("Expected each acknowledgement in the outbox, got %+v", pending)
+	}
+	tk, _ := s.GetTicket(context.Background(), "1")
+	if !escalate.Acknowledged(tk) {
+		t.Errorf("Expected the ticket to be acknowledged, got %+v", tk.Notices)
+	}
+	blocks := ticketCard(tk, agentCard)
+	if ctx, ok := blocks[1].(*slack.ContextBlock); !ok || !strings.Contains(ctx.ContextElements.Elements[0].(*slack.TextBlockObject).Text, "Acknowledged by <@U2>, <@U3>") {
+		t.Errorf("Expected the card to show the receipts, got %+v", blocks)
+	}
+}
@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/nlopes/slack"
+
+	"github.com/skybet/go-helpdesk/render"
+	"github.com/skybet/go-helpdesk/server"
+)
+
+var styles = render.NewPreferences(nil)
+
+// InitStyles sets the users' notification styles
+func InitStyles(p *render.Preferences) {
+	styles = p
+}
+
+// Format handles /hd format [plain|rich], showing or setting whether the user
+// is sent plain text notifications for screen readers or rich ones with emoji
+func Format(res *server.Response, req *server.Request, ctx interface{}) error {
+	sc, ok := ctx.(slack.SlashCommand)
+	if !ok {
+		return fmt.Errorf("Expected a slack.SlashCommand to be passed to the handler")
+	}
+	args := strings.Fields(sc.Text)
+	if len(args) == 1 {
+		res.Text(http.StatusOK, tr(sc, "Your notifications are %s. Use %s format plain or %s format rich to change them.", styles.Style(sc.UserID), sc.Command, sc.Command))
+		return nil
+	}
+	switch strings.ToLower(args[1]) {
+	case "plain":
+		styles.Set(sc.UserID, render.Plain)
+	case "rich":
+		styles.Set(sc.UserID, render.Rich)
+	default:
+		res.Text(http.StatusOK, tr(sc, "Usage: %s format [plain|rich]", sc.Command))
+		return nil
+	}
+	res.Text(http.StatusOK, tr(sc, "Your notifications are now %s", styles.Style(sc.UserID)))
+	return nil
+}
@@ -0,0 +1,41 @@
+package handlers
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/nlopes/slack"
+
+	"github.com/skybet/go-helpdesk/render"
+	"github.com/skybet/go-helpdesk/store"
+	"github.com/skybet/go-helpdesk/ticket"
+	"github.com/skybet/go-helpdesk/wip"
+)
+
+func TestFormat(t *testing.T) {
+	defer InitStyles(render.NewPreferences(nil))
+	req, res, w := newTestRequest()
+	Helpdesk(res, req, slack.SlashCommand{Command: "/hd", Text: "format plain", UserID: "U1"})
+	if styles.Style("U1") != render.Plain || !strings.Contains(w.Body.String(), "now plain") {
+		t.Fatalf("Expected plain text to be chosen, got %s", w.Body.String())
+	}
+
+	s := store.NewMemory()
+	s.CreateTicket(context.Background(), &ticket.Ticket{Queue: "it", Assignee: "U1"})
+	s.CreateTicket(context.Background(), &ticket.Ticket{Queue: "it", Assignee: "U2"})
+	InitTickets(s)
+	l := wip.NewLimits()
+	l.SetQueue("it", 1)
+	InitWIP(l)
+	defer InitWIP(wip.NewLimits())
+
+	req, res, w = newTestRequest()
+	if err := Helpdesk(res, req, slack.SlashCommand{Command: "/hd", Text: "dashboard", UserID: "U1"}); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	body := w.Body.String()
+	if strings.Contains(body, `"blocks":[`) || strings.Contains(body, ":warning:") || !strings.Contains(body, "[Over WIP limit] 2 in progress") {
+		t.Errorf("Expected a plain text dashboard, got %s", body)
+	}
+}
@@ -1,22 +1,39 @@
 package handlers
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/nlopes/slack"
 	log "github.com/sirupsen/logrus"
 
+	"github.com/skybet/go-helpdesk/clock"
 	"github.com/skybet/go-helpdesk/server"
 	"github.com/skybet/go-helpdesk/wrapper"
 )
 
 var slackWrapper wrapper.SlackWrapper
 
+// clk tells the handlers the time
+var clk clock.Clock = clock.Real
+
 // Init initialises any external dependencies
 func Init(sw wrapper.SlackWrapper) {
 	slackWrapper = sw
 }
 
+// slackIn returns the Slack wrapper making its calls in ctx, so they are
+// traced as part of the request ctx belongs to
+func slackIn(ctx context.Context) wrapper.SlackWrapper {
+	return wrapper.WithContext(ctx, slackWrapper)
+}
+
+// InitClock sets the clock the handlers read the time from, such as a
+// clock.Fake in tests
+func InitClock(c clock.Clock) {
+	clk = clock.Or(c)
+}
+
 // HelpCallback is a handler that takes a dialogCallback, generated by the HelpRequest
 // handler and logs the help request
 func HelpCallback(res *server.Response, req *server.Request, ctx interface{}) error {
@@ -54,7 +71,7 @@ func HelpRequest(res *server.Response, req *server.Request, ctx interface{}) err
 		Elements:       elements,
 	}
 
-	if err := slackWrapper.OpenDialog(sc.TriggerID, dialog); err != nil {
+	if err := slackIn(req.Context()).OpenDialog(sc.TriggerID, dialog); err != nil {
 		return fmt.Errorf("Failed to open dialog: %s", err)
 	}
 	return nil
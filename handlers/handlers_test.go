@@ -41,7 +41,7 @@ func TestHelpCallback(t *testing.T) {
 			r := httptest.NewRequest("POST", "/slack", nil)
 			w := httptest.NewRecorder()
 			req := &server.Request{Request: r}
-			res := &server.Response{w}
+			res := &server.Response{ResponseWriter: w}
 
 			err := HelpCallback(res, req, tc.jsonString)
 			if err != nil {
@@ -63,7 +63,7 @@ func TestHelpRequest(t *testing.T) {
 	r := httptest.NewRequest("POST", "/slack", nil)
 	w := httptest.NewRecorder()
 	req := &server.Request{Request: r}
-	res := &server.Response{w}
+	res := &server.Response{ResponseWriter: w}
 
 	err := HelpRequest(res, req, sc)
 	if err != nil {
@@ -79,7 +79,7 @@ func TestHelpRequestErrors(t *testing.T) {
 	r := httptest.NewRequest("POST", "/slack", nil)
 	w := httptest.NewRecorder()
 	req := &server.Request{Request: r}
-	res := &server.Response{w}
+	res := &server.Response{ResponseWriter: w}
 
 	err := HelpRequest(res, req, "foobar")
 	if err == nil {
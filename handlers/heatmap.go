@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/nlopes/slack"
+
+	"github.com/skybet/go-helpdesk/render"
+	"github.com/skybet/go-helpdesk/report"
+	"github.com/skybet/go-helpdesk/server"
+	"github.com/skybet/go-helpdesk/store"
+)
+
+// heatmapPeaks is how many of the busiest hours a heatmap lists
+const heatmapPeaks = 5
+
+// Heatmap handles /hd heatmap [queue] [timezone] [csv], replying with when
+// tickets were raised and replied to by hour of the week over the last
+// report.HeatmapWeeks weeks. With csv the counts are sent to the user as a
+// file instead.
+func Heatmap(res *server.Response, req *server.Request, ctx interface{}) error {
+	sc, ok := ctx.(slack.SlashCommand)
+	if !ok {
+		return fmt.Errorf("Expected a slack.SlashCommand to be passed to the handler")
+	}
+	if tickets == nil {
+		return fmt.Errorf("Tickets have not been initialised")
+	}
+	var queue string
+	var asCSV bool
+	loc := time.UTC
+	for _, arg := range strings.Fields(sc.Text)[1:] {
+		switch {
+		case strings.EqualFold(arg, "csv"):
+			asCSV = true
+		case strings.Contains(arg, "/") || arg == "UTC":
+			l, err := time.LoadLocation(arg)
+			if err != nil {
+				res.Text(http.StatusOK, tr(sc, "%s is not a timezone, use e.g. Europe/London", arg))
+				return nil
+			}
+			loc = l
+		case queue == "":
+			queue = arg
+		default:
+			res.Text(http.StatusOK, tr(sc, "Usage: %s heatmap [queue] [timezone] [csv]", sc.Command))
+			return nil
+		}
+	}
+
+	to := clk.Now()
+	from := to.AddDate(0, 0, -7*report.HeatmapWeeks)
+	// Tickets raised before the period may have been replied to during it
+	all, err := report.All(req.Context(), tickets, store.Filter{Queue: queue, UpdatedAfter: from})
+	if err != nil {
+		return fmt.Errorf("Failed to list tickets for the heatmap: %s", err)
+	}
+	h := report.Activity(all, from, to, loc)
+	title := tr(sc, "Ticket activity")
+	if queue != "" {
+		title = tr(sc, "Ticket activity in %s", queue)
+	}
+
+	if asCSV {
+		var buf strings.Builder
+		if err := h.WriteCSV(&buf); err != nil {
+			return fmt.Errorf("Failed to write the heatmap: %s", err)
+		}
+		s := slackIn(req.Context())
+		ch, _, err := s.PostMessage(sc.UserID, slack.MsgOptionText(title, false))
+		if err != nil {
+			return fmt.Errorf("Failed to send %s the heatmap: %s", sc.UserID, err)
+		}
+		file := slack.FileUploadParameters{
+			Filename: fmt.Sprintf("heatmap-%s.csv", to.In(loc).Format("2006-01-02")),
+			Filetype: "csv",
+			Title:    title,
+			Content:  buf.String(),
+			Channels: []string{ch},
+		}
+		if _, err := s.UploadFile(file); err != nil {
+			return fmt.Errorf("Failed to send %s the heatmap: %s", sc.UserID, err)
+		}
+		res.Text(http.StatusOK, tr(sc, "The heatmap has been sent to you as a CSV"))
+		return nil
+	}
+	text := h.Text(title, heatmapPeaks)
+	if styles.Style(sc.UserID) == render.Plain {
+		return res.JSON(http.StatusOK, slack.Msg{ResponseType: slack.ResponseTypeEphemeral, Text: text})
+	}
+	return res.JSON(http.StatusOK, slack.Msg{ResponseType: slack.ResponseTypeEphemeral, Text: text, Blocks: slack.Blocks{BlockSet: h.Blocks(title, heatmapPeaks)}})
+}
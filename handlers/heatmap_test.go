@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nlopes/slack"
+
+	"github.com/skybet/go-helpdesk/clock"
+	"github.com/skybet/go-helpdesk/mocks"
+	"github.com/skybet/go-helpdesk/store"
+	"github.com/skybet/go-helpdesk/ticket"
+)
+
+func TestHeatmap(t *testing.T) {
+	now := time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC)
+	InitClock(clock.NewFake(now))
+	defer InitClock(nil)
+	s := store.NewMemory()
+	s.CreateTicket(context.Background(), &ticket.Ticket{ID: "1", Queue: "it", CreatedAt: now.Add(-3 * time.Hour), UpdatedAt: now})
+	s.CreateTicket(context.Background(), &ticket.Ticket{ID: "2", Queue: "hr", CreatedAt: now.Add(-2 * time.Hour), UpdatedAt: now})
+	InitTickets(s)
+	serve := func(text string) string {
+		req, res, w := newTestRequest()
+		if err := Helpdesk(res, req, slack.SlashCommand{Command: "/hd", Text: text, UserID: "U1"}); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		return w.Body.String()
+	}
+	if body := serve("heatmap it"); !strings.Contains(body, "Ticket activity in it") || !strings.Contains(body, "Wed 09:00–10:00: 1 raised") || strings.Contains(body, "Wed 10:00") || !strings.Contains(body, "large_red_square") {
+		t.Errorf("Expected the queue's heatmap, got %s", body)
+	}
+	if body := serve("heatmap America/New_York"); !strings.Contains(body, "Wed 05:00–06:00: 1 raised") || !strings.Contains(body, "Wed 06:00–07:00: 1 raised") {
+		t.Errorf("Expected hours in the timezone, got %s", body)
+	}
+	if body := serve("heatmap Mars/Olympus"); !strings.Contains(body, "Mars/Olympus is not a timezone") {
+		t.Errorf("Expected an unknown timezone to be reported, got %s", body)
+	}
+
+	mockSlack := mocks.NewSlack(t)
+	mockSlack.ExpectPostMessage().ToChannel("U1").WithText("Ticket activity")
+	mockSlack.ExpectUploadFile().ToChannel("U1").Named("heatmap-2026-10-14.csv").WithText("Wednesday,10,1,0,UTC")
+	Init(mockSlack)
+	if body := serve("heatmap csv"); !strings.Contains(body, "sent to you as a CSV") {
+		t.Errorf("Expected the CSV to be sent, got %s", body)
+	}
+}
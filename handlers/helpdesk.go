@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/nlopes/slack"
+
+	"github.com/skybet/go-helpdesk/i18n"
+	"github.com/skybet/go-helpdesk/server"
+)
+
+// Commands are the subcommands of /hd, keyed by the first word of the
+// command text
+var Commands = newCommands()
+
+func newCommands() *server.CommandRouter {
+	r := server.NewCommandRouter()
+	r.Translate = tr
+	for _, s := range []server.Subcommand{
+		{Name: "aging", Usage: "[queue]", Raw: Aging, Summary: "Lists the open tickets which have gone longest without activity"},
+		{Name: "announce", Raw: Announce, Summary: "Composes an announcement to the announcement channels", Keywords: []string{"edit"}},
+		{Name: "assign", Args: []server.Arg{{Name: "ticket", Kind: server.Ticket}, {Name: "agent", Kind: server.User, Optional: true}}, Handler: Assign, Summary: "Assigns a ticket to you or to an agent"},
+		{Name: "audit", Usage: "verify", Raw: Audit, Summary: "Checks the audit log has not been altered", Keywords: []string{"verify"}},
+		{Name: "bulk-close", Usage: "<queue|query>", Raw: BulkClose, Summary: "Closes every open ticket in a queue, or matching a query, once another admin approves it"},
+		{Name: "catalog", Usage: "[item]", Raw: Catalog, Summary: "Lists what you can request from the service catalog, or opens an item's request form"},
+		{Name: "dashboard", Usage: "[department|queue]", Raw: Dashboard, Summary: "Shows the state of the helpdesk and next week's forecast, or of a department or queue"},
+		{Name: "debug", Usage: "[level <level> | capture <minutes> | stop]", Raw: Debug, Summary: "Shows or changes the log level and captures payloads"},
+		{Name: "delete", Usage: "<ticket>", Raw: Delete, Summary: "Moves a ticket to the trash"},
+		{Name: "erase", Usage: "<@user>", Raw: Erase, Summary: "Removes a user from every ticket once another admin approves it"},
+		{Name: "export", Usage: "[queue|query]", Raw: Export, Summary: "Sends you a CSV of every ticket, or of a queue's or those matching a query, once another admin approves it"},
+		{Name: "format", Usage: "[plain|rich]", Raw: Format, Summary: "Shows or sets whether you are sent plain text or rich notifications", Keywords: []string{"plain", "rich"}},
+		{Name: "heatmap", Usage: "[queue] [timezone] [csv]", Raw: Heatmap, Summary: "Shows when tickets are raised and replied to by hour of the week, to plan shift cover"},
+		{Name: "move", Args: []server.Arg{{Name: "ticket", Kind: server.Ticket}, {Name: "status", Kind: server.Text}}, Handler: Move, Summary: "Moves a ticket to another status"},
+		{Name: "new", Raw: HelpRequest, Summary: "Opens the form to raise a ticket"},
+		{Name: "provision", Usage: "<queue> <channel> [@usergroup]", Raw: Provision, Summary: "Sets up a queue's triage channel"},
+		{Name: "restore", Usage: "<ticket>", Raw: Restore, Summary: "Takes a ticket out of the trash"},
+		{Name: "runbooks", Raw: Runbooks, Summary: "Lists the runbooks with how often each has been suggested and opened"},
+		{Name: "saved", Usage: "[add|run|subscribe|unsubscribe|remove <name>]", Raw: Saved, Summary: "Lists, saves and runs your saved searches, and sends you new tickets matching them"},
+		{Name: "search", Usage: "<query>", Raw: Search, Summary: "Lists the tickets matching a query such as status:open tag:vpn -assignee:@me"},
+		{Name: "share", Usage: "<ticket> <queue>...", Raw: Share, Summary: "Posts a ticket in other queues' channels to work on it together"},
+		{Name: "skills", Raw: Skills, Summary: "Reports how many agents have each skill against the open tickets needing it"},
+		{Name: "status", Usage: "[ticket]", Raw: Status, Summary: "Shows a ticket, or your open tickets"},
+		{Name: "trash", Raw: Trash, Summary: "Lists the tickets in the trash"},
+		{Name: "wip", Usage: "[queue <queue>|agent <@agent>] <limit>", Raw: WIP, Summary: "Shows or changes the WIP limits", Keywords: []string{"queue", "agent"}},
+	} {
+		r.Handle(s)
+	}
+	return r
+}
+
+// Helpdesk handles the /hd command and its localized aliases by dispatching to
+// one of its Commands. A localized subcommand, and the keyword it takes as
+// its first argument, are translated to English before the subcommand sees
+// them, the rest of the text is passed on as it was written.
+func Helpdesk(res *server.Response, req *server.Request, ctx interface{}) error {
+	sc, ok := ctx.(slack.SlashCommand)
+	if !ok {
+		return fmt.Errorf("Expected a slack.SlashCommand to be passed to the handler")
+	}
+	locale := commandLocale(sc)
+	name, at, end := word(sc.Text, 0)
+	if name == "" {
+		return Commands.ServeCommand(res, req, sc)
+	}
+	name = i18n.Keyword(locale, name)
+	text := sc.Text[:at] + name
+	if arg, argAt, argEnd := word(sc.Text, end); arg != "" {
+		if k := i18n.Keyword(locale, arg); k != arg && takesKeyword(name, k) {
+			text += sc.Text[end:argAt] + k
+			end = argEnd
+		}
+	}
+	sc.Text = text + sc.Text[end:]
+	return Commands.ServeCommand(res, req, sc)
+}
+
+// takesKeyword reports whether the subcommand name takes keyword as its first
+// argument
+func takesKeyword(name, keyword string) bool {
+	for _, k := range Commands.Keywords(name) {
+		if k == keyword {
+			return true
+		}
+	}
+	return false
+}
+
+// word returns the first word of text from from and where it starts and
+// ends, an empty word if there is none
+func word(text string, from int) (string, int, int) {
+	at := from + len(text[from:]) - len(strings.TrimLeftFunc(text[from:], unicode.IsSpace))
+	end := strings.IndexFunc(text[at:], unicode.IsSpace)
+	if end < 0 {
+		end = len(text)
+	} else {
+		end += at
+	}
+	return text[at:end], at, end
+}
@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"fmt"
+
+	"github.com/nlopes/slack"
+	"github.com/nlopes/slack/slackevents"
+
+	"github.com/skybet/go-helpdesk/blocks"
+	"github.com/skybet/go-helpdesk/notify"
+	"github.com/skybet/go-helpdesk/server"
+	"github.com/skybet/go-helpdesk/views"
+)
+
+// QuietHoursActionID is the action ID of the quiet hours menu on the App Home
+// tab, its options' values are quiet hours as notify.ParseHours accepts them
+const QuietHoursActionID = "home_quiet_hours"
+
+// quietHoursChoices are offered on the App Home tab
+var quietHoursChoices = []string{"off", "18:00-08:00", "20:00-08:00", "21:00-07:00", "22:00-07:00", "22:00-08:00", "23:00-08:00"}
+
+var notifier *notify.Notifier
+
+// InitNotifier sets the notifier DMs are sent through, it holds non-urgent DMs
+// back during the recipient's quiet hours
+func InitNotifier(n *notify.Notifier) {
+	notifier = n
+}
+
+// dm returns the poster for direct messages
+func dm() interface {
+	PostMessage(channelID string, options ...slack.MsgOption) (string, string, error)
+} {
+	if notifier != nil {
+		return notifier
+	}
+	return slackWrapper
+}
+
+// AppHome handles app_home_opened events, publishing the user's App Home tab
+func AppHome(res *server.Response, req *server.Request, ctx interface{}) error {
+	event, ok := ctx.(*slackevents.EventsAPIEvent)
+	if !ok {
+		return fmt.Errorf("Expected a *slackevents.EventsAPIEvent to be passed to the handler")
+	}
+	ev, ok := event.InnerEvent.Data.(*slackevents.AppHomeOpenedEvent)
+	if !ok {
+		return fmt.Errorf("Expected an app_home_opened event, got %T", event.InnerEvent.Data)
+	}
+	return publishHome(ev.User)
+}
+
+// QuietHours handles the quiet hours menu on the App Home tab
+func QuietHours(res *server.Response, req *server.Request, ctx interface{}) error {
+	ic, ok := ctx.(*slack.InteractionCallback)
+	if !ok {
+		return fmt.Errorf("Expected a *slack.InteractionCallback to be passed to the handler")
+	}
+	if notifier == nil {
+		return fmt.Errorf("Quiet hours have not been initialised")
+	}
+	action, err := blocks.ActionOf(ic)
+	if err != nil {
+		return err
+	}
+	h, err := notify.ParseHours(action.Value)
+	if err != nil {
+		return err
+	}
+	notifier.SetHours(ic.User.ID, h)
+	return publishHome(ic.User.ID)
+}
+
+func publishHome(user string) error {
+	if _, err := slackWrapper.PublishView(user, homeView(user)); err != nil {
+		return fmt.Errorf("Failed to publish App Home for %s: %s", user, err)
+	}
+	return nil
+}
+
+// homeView renders a user's App Home tab
+func homeView(user string) *views.View {
+	v := views.NewHome()
+	v.Blocks.BlockSet = []slack.Block{
+		slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType, "*Helpdesk*", false, false), nil, nil),
+	}
+	if notifier != nil {
+		v.Blocks.BlockSet = append(v.Blocks.BlockSet, slack.NewDividerBlock(), quietHoursSection(user))
+	}
+	if services != nil && len(services.Items) > 0 {
+		v.Blocks.BlockSet = append(append(v.Blocks.BlockSet, slack.NewDividerBlock()), catalogPicker()...)
+	}
+	if skills != nil && len(skills.Offered) > 0 {
+		v.Blocks.BlockSet = append(append(v.Blocks.BlockSet, slack.NewDividerBlock()), skillsSection(user)...)
+	}
+	return v
+}
+
+func quietHoursSection(user string) slack.Block {
+	h, _ := notifier.Hours(user)
+	text := fmt.Sprintf("*Quiet hours*\nDMs which are not urgent are held back until your quiet hours end, in your Slack time zone (%s).", notifier.Location(user))
+	if notifier.BreakThrough {
+		text += " Urgent escalations are still sent straight away."
+	}
+	var options []*slack.OptionBlockObject
+	var current *slack.OptionBlockObject
+	for _, c := range quietHoursChoices {
+		o := slack.NewOptionBlockObject(c, slack.NewTextBlockObject(slack.PlainTextType, c, false, false))
+		options = append(options, o)
+		if c == h.String() {
+			current = o
+		}
+	}
+	menu := slack.NewOptionsSelectBlockElement(slack.OptTypeStatic, slack.NewTextBlockObject(slack.PlainTextType, h.String(), false, false), QuietHoursActionID, options...)
+	menu.InitialOption = current
+	return slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType, text, false, false), nil, slack.NewAccessory(menu))
+}
@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/nlopes/slack"
+	"github.com/nlopes/slack/slackevents"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/skybet/go-helpdesk/mocks"
+	"github.com/skybet/go-helpdesk/notify"
+	"github.com/skybet/go-helpdesk/views"
+)
+
+func TestQuietHours(t *testing.T) {
+	mockSlack := &mocks.SlackWrapper{}
+	var published []*views.View
+	mockSlack.On("PublishView", "U1", mock.Anything).Run(func(args mock.Arguments) {
+		published = append(published, args.Get(1).(*views.View))
+	}).Return(&views.View{}, nil)
+	Init(mockSlack)
+	n := notify.New(mockSlack, nil, notify.Off)
+	InitNotifier(n)
+	defer InitNotifier(nil)
+	req, res, _ := newTestRequest()
+
+	event := &slackevents.EventsAPIEvent{InnerEvent: slackevents.EventsAPIInnerEvent{
+		Type: slackevents.AppHomeOpened,
+		Data: &slackevents.AppHomeOpenedEvent{User: "U1"},
+	}}
+	if err := AppHome(res, req, event); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	ic := &slack.InteractionCallback{Type: slack.InteractionTypeBlockActions}
+	ic.User.ID = "U1"
+	ic.ActionCallback.BlockActions = []*slack.BlockAction{{ActionID: QuietHoursActionID, SelectedOption: slack.OptionBlockObject{Value: "22:00-08:00"}}}
+	if err := QuietHours(res, req, ic); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if h, ok := n.Hours("U1"); !ok || h.String() != "22:00-08:00" {
+		t.Errorf("Expected the quiet hours to be saved, got %s %v", h, ok)
+	}
+	if len(published) != 2 {
+		t.Fatalf("Expected the home tab to be published twice, got %d", len(published))
+	}
+	body, _ := json.Marshal(published[1])
+	if !strings.Contains(string(body), `"initial_option":{"text":{"type":"plain_text","text":"22:00-08:00"},"value":"22:00-08:00"}`) {
+		t.Errorf("Expected the new quiet hours to be selected, got %s", body)
+	}
+}
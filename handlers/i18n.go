@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"context"
+
+	"github.com/nlopes/slack"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/skybet/go-helpdesk/i18n"
+)
+
+// commandLocales are the locales of the localized aliases of /hd, e.g. /ayuda
+var commandLocales = map[string]string{}
+
+// InitLocales registers localized aliases of /hd keyed by command, e.g.
+// /ayuda, with the locale of their keywords. The commands must also be routed
+// to Helpdesk.
+func InitLocales(commands map[string]string) {
+	commandLocales = commands
+}
+
+// commandLocale returns the locale of a command's keywords, falling back to
+// the user's locale for /hd
+func commandLocale(sc slack.SlashCommand) string {
+	if l, ok := commandLocales[sc.Command]; ok {
+		return l
+	}
+	return userLocale(sc)
+}
+
+// userLocale returns the locale replies to the user are written in, their
+// Slack locale if it is supported otherwise that of the command
+func userLocale(sc slack.SlashCommand) string {
+	if directory != nil {
+		u, err := directory.User(context.Background(), sc.UserID)
+		if err != nil {
+			log.Errorf("Failed to look up the locale of %s: %s", sc.UserID, err)
+		} else if i18n.Supported(u.Locale) {
+			return u.Locale
+		}
+	}
+	return commandLocales[sc.Command]
+}
+
+// tr formats a reply to the user who ran sc in their language
+func tr(sc slack.SlashCommand, format string, args ...interface{}) string {
+	return i18n.Sprintf(userLocale(sc), format, args...)
+}
@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/nlopes/slack"
+
+	"github.com/skybet/go-helpdesk/intake"
+	"github.com/skybet/go-helpdesk/store"
+	"github.com/skybet/go-helpdesk/ticket"
+)
+
+func TestLocalizedAlias(t *testing.T) {
+	s := store.NewMemory()
+	s.CreateTicket(context.Background(), &ticket.Ticket{ID: "1", Queue: "it"})
+	InitTickets(s)
+	InitLocales(map[string]string{"/ayuda": "es"})
+	defer InitLocales(nil)
+	req, res, w := newTestRequest()
+
+	if err := Helpdesk(res, req, slack.SlashCommand{Command: "/ayuda", Text: "asignar 1", UserID: "U1"}); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if tk, _ := s.GetTicket(context.Background(), "1"); tk.Assignee != "U1" {
+		t.Errorf("Expected asignar to assign the ticket, got %q", tk.Assignee)
+	}
+	if body := w.Body.String(); !strings.Contains(body, "El ticket #1 está asignado a <@U1>") {
+		t.Errorf("Expected a reply in Spanish, got %s", body)
+	}
+}
+
+func TestUserLocale(t *testing.T) {
+	InitGuestPolicy(fakeDirectory{users: map[string]slack.User{"U1": {ID: "U1", Locale: "es-ES"}}}, intake.Policy{})
+	defer InitGuestPolicy(nil, intake.Policy{})
+	req, res, w := newTestRequest()
+
+	Helpdesk(res, req, slack.SlashCommand{Command: "/hd", Text: "asignar", UserID: "U1"})
+	if body := w.Body.String(); !strings.Contains(body, "Uso: /hd asignar") {
+		t.Errorf("Expected the user's locale to be used, got %s", body)
+	}
+
+	req, res, w = newTestRequest()
+	Helpdesk(res, req, slack.SlashCommand{Command: "/hd", Text: "help", UserID: "U2"})
+	if body := w.Body.String(); !strings.HasPrefix(body, "Usage: /hd") {
+		t.Errorf("Expected English for other users, got %s", body)
+	}
+}
+
+func TestLocalizedArguments(t *testing.T) {
+	s := store.NewMemory()
+	s.CreateTicket(context.Background(), &ticket.Ticket{Title: "Panel roto", Queue: "it", Reporter: "U1"})
+	s.CreateTicket(context.Background(), &ticket.Ticket{Title: "Dashboard roto", Queue: "it", Reporter: "U1"})
+	InitTickets(s)
+	InitLocales(map[string]string{"/ayuda": "es"})
+	defer InitLocales(nil)
+	run := func(text string) string {
+		req, res, w := newTestRequest()
+		if err := Helpdesk(res, req, slack.SlashCommand{Command: "/ayuda", Text: text, UserID: "U1"}); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		return w.Body.String()
+	}
+
+	// Only the subcommand and its keywords are translated, panel is searched
+	// for rather than read as dashboard
+	if got := run("buscar panel roto"); !strings.Contains(got, "Panel roto") || strings.Contains(got, "Dashboard roto") {
+		t.Errorf("Expected the search terms as they were written, got %s", got)
+	}
+	if got := run("formato  sencillo"); !strings.Contains(got, "Tus notificaciones son ahora") {
+		t.Errorf("Expected the subcommand's keyword to be translated, got %s", got)
+	}
+}
@@ -0,0 +1,149 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/nlopes/slack"
+	"github.com/nlopes/slack/slackevents"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/skybet/go-helpdesk/blocks"
+	"github.com/skybet/go-helpdesk/inbox"
+	"github.com/skybet/go-helpdesk/server"
+	"github.com/skybet/go-helpdesk/store"
+	"github.com/skybet/go-helpdesk/ticket"
+	"github.com/skybet/go-helpdesk/wip"
+)
+
+// Action IDs of the triage inbox's buttons, each is handled by InboxAction
+const (
+	InboxNextActionID   = "inbox_next"
+	InboxClaimActionID  = "inbox_claim"
+	InboxSkipActionID   = "inbox_skip"
+	InboxSnoozeActionID = "inbox_snooze"
+)
+
+// InboxActionIDs are the action IDs to route to InboxAction
+var InboxActionIDs = []string{InboxNextActionID, InboxClaimActionID, InboxSkipActionID, InboxSnoozeActionID}
+
+// inboxCommand is the DM asking for the next ticket
+const inboxCommand = "next"
+
+var (
+	triage      *inbox.Inbox
+	inboxSnooze = time.Hour
+)
+
+// InitInbox sets the inbox agents triage from by sending the bot "next" in a
+// DM. Snoozed tickets are hidden from the agent for snooze.
+func InitInbox(i *inbox.Inbox, snooze time.Duration) {
+	triage = i
+	inboxSnooze = snooze
+}
+
+// inboxRequest replies to "next" in a DM with the agent's next ticket, it
+// reports whether the message was one
+func inboxRequest(ctx context.Context, ev *slackevents.MessageEvent) (bool, error) {
+	if triage == nil || ev.ChannelType != "im" || ev.BotID != "" || ev.SubType != "" || !strings.EqualFold(strings.TrimSpace(ev.Text), inboxCommand) {
+		return false, nil
+	}
+	opts, err := nextTicket(ctx, ev.User, "")
+	if err != nil {
+		return true, err
+	}
+	if _, _, err := slackIn(ctx).PostMessage(ev.Channel, opts...); err != nil {
+		return true, fmt.Errorf("Failed to send %s their next ticket: %s", ev.User, err)
+	}
+	return true, nil
+}
+
+// InboxAction handles the buttons on a triage inbox message. The message is
+// replaced with the agent's next ticket, or with the ticket they claimed.
+func InboxAction(res *server.Response, req *server.Request, ctx interface{}) error {
+	ic, ok := ctx.(*slack.InteractionCallback)
+	if !ok {
+		return fmt.Errorf("Expected a *slack.InteractionCallback to be passed to the handler")
+	}
+	action, err := blocks.ActionOf(ic)
+	if err != nil {
+		return err
+	}
+	if triage == nil {
+		return fmt.Errorf("The triage inbox is not enabled")
+	}
+	agent, id := ic.User.ID, action.Value
+	var opts []slack.MsgOption
+	switch action.ActionID {
+	case InboxClaimActionID:
+		opts, err = claimTicket(req.Context(), agent, id)
+	case InboxSkipActionID:
+		triage.Skip(agent, id)
+		opts, err = nextTicket(req.Context(), agent, "")
+	case InboxSnoozeActionID:
+		triage.Snooze(agent, id, clk.Now().Add(inboxSnooze))
+		opts, err = nextTicket(req.Context(), agent, "")
+	default:
+		opts, err = nextTicket(req.Context(), agent, "")
+	}
+	if err != nil {
+		return err
+	}
+	if _, _, _, err := slackIn(req.Context()).UpdateMessage(ic.Channel.ID, ic.Message.Timestamp, opts...); err != nil {
+		return fmt.Errorf("Failed to update %s's inbox: %s", agent, err)
+	}
+	return nil
+}
+
+// nextTicket renders the agent's next ticket with buttons to claim, skip or
+// snooze it, after note if there is one
+func nextTicket(ctx context.Context, agent, note string) ([]slack.MsgOption, error) {
+	t, err := triage.Next(ctx, agent, clk.Now())
+	if err != nil {
+		return nil, fmt.Errorf("Failed to find %s's next ticket: %s", agent, err)
+	}
+	if t == nil {
+		text := strings.TrimSpace(note + " Nothing is waiting to be triaged in your queues.")
+		return []slack.MsgOption{
+			slack.MsgOptionText(text, false),
+			slack.MsgOptionBlocks(blocks.New().Section(text).Actions("", blocks.Button(InboxNextActionID, "", "Check again")).Blocks()...),
+		}, nil
+	}
+	claim := blocks.Button(InboxClaimActionID, t.ID, "Claim")
+	claim.Style = slack.StylePrimary
+	text := strings.TrimSpace(fmt.Sprintf("%s Next up in %s: ticket #%s", note, queueName(t), t.ID))
+	extra := blocks.New().Actions("",
+		claim,
+		blocks.Button(InboxSkipActionID, t.ID, "Skip"),
+		blocks.Button(InboxSnoozeActionID, t.ID, fmt.Sprintf("Snooze %s", inboxSnooze)),
+	).Blocks()
+	return cardMessage(t, agentCard, text, extra...), nil
+}
+
+// claimTicket assigns the ticket to the agent, or moves on to the next ticket
+// if it can not be claimed
+func claimTicket(ctx context.Context, agent, id string) ([]slack.MsgOption, error) {
+	t, err := triage.Claim(ctx, agent, id)
+	if over, ok := err.(*wip.ErrOverLimit); ok {
+		return nextTicket(ctx, agent, fmt.Sprintf("Ticket #%s was not claimed: %s.", id, over))
+	}
+	if err == inbox.ErrClaimed || err == store.ErrNotFound {
+		return nextTicket(ctx, agent, fmt.Sprintf("Ticket #%s has already been claimed.", id))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Failed to claim ticket %s: %s", id, err)
+	}
+	log.Infof("%s claimed ticket %s from their inbox", agent, t.ID)
+	syncShares(t)
+	text := fmt.Sprintf("You claimed ticket %s", ticketLinks.Ref(t.ID))
+	return cardMessage(t, agentCard, text, blocks.New().Actions("", blocks.Button(InboxNextActionID, "", "Next ticket")).Blocks()...), nil
+}
+
+func queueName(t *ticket.Ticket) string {
+	if t.Queue == "" {
+		return "the default queue"
+	}
+	return t.Queue
+}
@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/nlopes/slack"
+	"github.com/nlopes/slack/slackevents"
+
+	"github.com/skybet/go-helpdesk/inbox"
+	"github.com/skybet/go-helpdesk/mocks"
+	"github.com/skybet/go-helpdesk/store"
+	"github.com/skybet/go-helpdesk/ticket"
+)
+
+func TestInbox(t *testing.T) {
+	mockSlack := mocks.NewSlack(t)
+	mockSlack.ExpectPostMessage().ToChannel("D1").WithText("Next up in it: ticket #1").ReturnTS("2.1")
+	mockSlack.ExpectUpdateMessage().ToChannel("D1").ForTS("2.1").WithText("Next up in it: ticket #2")
+	mockSlack.ExpectUpdateMessage().ToChannel("D1").ForTS("2.1").WithText("You claimed ticket #2")
+	mockSlack.ExpectUpdateMessage().ToChannel("D1").ForTS("2.1").WithText("Ticket #1 has already been claimed. Nothing is waiting to be triaged in your queues.")
+	Init(mockSlack)
+	s := store.NewMemory()
+	s.CreateTicket(context.Background(), &ticket.Ticket{ID: "1", Queue: "it", Title: "VPN down", Priority: ticket.P1})
+	s.CreateTicket(context.Background(), &ticket.Ticket{ID: "2", Queue: "it", Title: "Printer"})
+	InitTickets(s)
+	InitInbox(inbox.New(s, nil, map[string][]string{"U1": {"it"}}), 0)
+	defer InitInbox(nil, 0)
+
+	req, res, _ := newTestRequest()
+	event := &slackevents.EventsAPIEvent{InnerEvent: slackevents.EventsAPIInnerEvent{
+		Type: "message",
+		Data: &slackevents.MessageEvent{Channel: "D1", ChannelType: "im", User: "U1", Text: "Next", TimeStamp: "1.1"},
+	}}
+	if err := Message(res, req, event); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	click := func(user, actionID, value string) {
+		t.Helper()
+		ic := &slack.InteractionCallback{Type: slack.InteractionTypeBlockActions}
+		ic.User.ID = user
+		ic.Channel.ID = "D1"
+		ic.Message.Timestamp = "2.1"
+		ic.ActionCallback.BlockActions = []*slack.BlockAction{{ActionID: actionID, Value: value}}
+		if err := InboxAction(res, req, ic); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+	}
+	click("U1", InboxSkipActionID, "1")
+	click("U1", InboxClaimActionID, "2")
+	// Someone else claims ticket 1 before U1 does
+	s.UpdateTicket(context.Background(), func() *ticket.Ticket {
+		tk, _ := s.GetTicket(context.Background(), "1")
+		tk.Assignee = "U2"
+		return tk
+	}())
+	click("U1", InboxClaimActionID, "1")
+
+	if tk, _ := s.GetTicket(context.Background(), "2"); tk.Assignee != "U1" {
+		t.Errorf("Expected ticket 2 to be claimed, got %+v", tk)
+	}
+}
@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/nlopes/slack"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/skybet/go-helpdesk/intake"
+	"github.com/skybet/go-helpdesk/ticket"
+)
+
+// Directory looks up the users and channels tickets come from, it is
+// satisfied by *wrapper.Directory
+type Directory interface {
+	User(ctx context.Context, id string) (*slack.User, error)
+	Channel(ctx context.Context, id string) (*slack.Channel, error)
+}
+
+var (
+	directory   Directory
+	guestPolicy intake.Policy
+	vips        *intake.VIPs
+	orgs        *intake.Orgs
+)
+
+// InitGuestPolicy sets the policy applied to tickets from guests and external
+// users, who are identified by looking them up in d
+func InitGuestPolicy(d Directory, p intake.Policy) {
+	directory = d
+	guestPolicy = p
+}
+
+// InitVIPs sets the reporters whose tickets are boosted. VIPs are looked up in
+// the Directory set by InitGuestPolicy.
+func InitVIPs(v *intake.VIPs) {
+	vips = v
+}
+
+// InitOrgs sets the Slack Connect organisations whose users' tickets have a
+// policy of their own, applied after the guest policy
+func InitOrgs(o *intake.Orgs) {
+	orgs = o
+}
+
+// createTicket creates a ticket for a message, applying the guest, external
+// organisation and VIP policies for its reporter after any prepare funcs,
+// which fill in what the message came with. The returned audience is
+// who can read the message's channel, replies into it must be passed through
+// echo.
+func createTicket(ctx context.Context, teamID, channel, ts, user, text string, prepare ...func(*ticket.Ticket)) (t *ticket.Ticket, a cardAudience, err error) {
+	t = ticketFromMessage(channel, ts, user, text)
+	t.TeamID = teamID
+	for _, p := range prepare {
+		p(t)
+	}
+	a = reporterCard
+	vip := false
+	if directory != nil && user != "" {
+		var audience intake.Audience
+		u, err := directory.User(ctx, user)
+		if err != nil {
+			log.Errorf("Failed to look up user %s, treating them as external: %s", user, err)
+			audience = intake.External
+		} else {
+			audience = intake.Classify(u, teamID)
+		}
+		guestPolicy.Apply(t, audience)
+		switch {
+		case audience == intake.Member:
+			vip = vips != nil && vips.Is(u)
+			if sharedChannel(ctx, channel) {
+				a = publicCard
+			}
+		case audience == intake.External && u != nil:
+			a = publicCard
+			if org, ok := orgFor(u); ok {
+				org.Apply(t, text)
+				if org.Share == intake.ShareReference {
+					a = referenceCard
+				}
+			}
+		default:
+			a = publicCard
+		}
+	}
+	if vip {
+		vips.Apply(t)
+	}
+
+	if err := tickets.CreateTicket(ctx, t); err != nil {
+		return nil, a, fmt.Errorf("Failed to create ticket: %s", err)
+	}
+	autoAssign(ctx, t)
+	if vip && vips.Channel != "" {
+		summary := fmt.Sprintf("VIP ticket #%s from <@%s>: %s", t.ID, t.Reporter, t.Title)
+		if _, _, err := slackIn(ctx).PostMessage(vips.Channel, cardMessage(t, agentCard, summary)...); err != nil {
+			log.Errorf("Failed to notify %s of VIP ticket %s: %s", vips.Channel, t.ID, err)
+		}
+	}
+	return t, a, nil
+}
+
+// orgFor returns the policy of the organisation an external user belongs to
+func orgFor(u *slack.User) (intake.Org, bool) {
+	if orgs == nil {
+		return intake.Org{}, false
+	}
+	return orgs.Get(u.TeamID)
+}
+
+func sharedChannel(ctx context.Context, id string) bool {
+	c, err := directory.Channel(ctx, id)
+	if err != nil {
+		log.Errorf("Failed to look up channel %s, treating it as shared: %s", id, err)
+		return true
+	}
+	return c.IsExtShared || c.IsShared
+}
+
+// echo returns text to post into a channel read by audience a, redacted if
+// anyone outside the organisation can read it
+func echo(text string, a cardAudience) string {
+	if a == publicCard || a == referenceCard {
+		return guestPolicy.Redact(text)
+	}
+	return text
+}
@@ -0,0 +1,161 @@
+package handlers
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/nlopes/slack"
+	"github.com/nlopes/slack/slackevents"
+	"github.com/skybet/go-helpdesk/intake"
+	"github.com/skybet/go-helpdesk/mocks"
+	"github.com/skybet/go-helpdesk/store"
+	"github.com/skybet/go-helpdesk/ticket"
+	"github.com/stretchr/testify/mock"
+)
+
+type fakeDirectory struct {
+	users    map[string]slack.User
+	channels map[string]slack.Channel
+}
+
+func (d fakeDirectory) User(ctx context.Context, id string) (*slack.User, error) {
+	u := d.users[id]
+	return &u, nil
+}
+
+func (d fakeDirectory) Channel(ctx context.Context, id string) (*slack.Channel, error) {
+	c := d.channels[id]
+	return &c, nil
+}
+
+func TestGuestTicket(t *testing.T) {
+	var posted []slack.MsgOption
+	mockSlack := &mocks.SlackWrapper{}
+	mockSlack.On("AddReaction", mock.Anything, mock.Anything).Return(nil)
+	mockSlack.On("PostMessage", "C1", mock.Anything, mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		for _, o := range args[1:] {
+			posted = append(posted, o.(slack.MsgOption))
+		}
+	}).Return("C1", "1.2", nil)
+	Init(mockSlack)
+	s := store.NewMemory()
+	InitTickets(s)
+	InitTriggers(intake.NewWatcher([]string{"C1"}, intake.Prefix("help:")))
+	defer InitTriggers(nil)
+	shared := slack.Channel{}
+	shared.IsExtShared = true
+	InitGuestPolicy(fakeDirectory{
+		users:    map[string]slack.User{"UEXT": {ID: "UEXT", TeamID: "T2"}},
+		channels: map[string]slack.Channel{"C1": shared},
+	}, intake.Policy{Queue: "external", InternalDomains: []string{"corp.example.com"}})
+	defer InitGuestPolicy(nil, intake.Policy{})
+	req, res, _ := newTestRequest()
+
+	event := &slackevents.EventsAPIEvent{TeamID: "T1", InnerEvent: slackevents.EventsAPIInnerEvent{
+		Type: "message",
+		Data: &slackevents.MessageEvent{Channel: "C1", User: "UEXT", Text: "help: https://corp.example.com/app is down", TimeStamp: "1.1"},
+	}}
+	if err := Message(res, req, event); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	tickets, _, _ := s.ListTickets(context.Background(), store.Filter{})
+	if len(tickets) != 1 || tickets[0].Queue != "external" || !tickets[0].HasTag("external") {
+		t.Fatalf("Expected the ticket to be routed to the external queue, got %+v", tickets)
+	}
+	_, values, _ := slack.UnsafeApplyMsgOptions("", "C1", "", posted...)
+	if strings.Contains(values.Encode(), "corp.example.com") || strings.Contains(values.Get("blocks"), "Assignee") {
+		t.Errorf("Expected internal details to be left out of the reply, got %v", values)
+	}
+}
+
+func TestExternalOrgTicket(t *testing.T) {
+	var posted []slack.MsgOption
+	mockSlack := &mocks.SlackWrapper{}
+	mockSlack.On("AddReaction", mock.Anything, mock.Anything).Return(nil)
+	mockSlack.On("PostMessage", "C1", mock.Anything, mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		for _, o := range args[1:] {
+			posted = append(posted, o.(slack.MsgOption))
+		}
+	}).Return("C1", "1.2", nil)
+	Init(mockSlack)
+	s := store.NewMemory()
+	InitTickets(s)
+	InitTriggers(intake.NewWatcher([]string{"C1"}, intake.Prefix("help:")))
+	defer InitTriggers(nil)
+	InitGuestPolicy(fakeDirectory{
+		users: map[string]slack.User{"UEXT": {ID: "UEXT", TeamID: "T2"}},
+	}, intake.Policy{Queue: "external"})
+	defer InitGuestPolicy(nil, intake.Policy{})
+	InitOrgs(intake.NewOrgs(intake.Org{TeamID: "T2", Queue: "acme", Categories: []string{"billing"}, Share: intake.ShareReference}))
+	defer InitOrgs(nil)
+	req, res, _ := newTestRequest()
+
+	event := &slackevents.EventsAPIEvent{TeamID: "T1", InnerEvent: slackevents.EventsAPIInnerEvent{
+		Type: "message",
+		Data: &slackevents.MessageEvent{Channel: "C1", User: "UEXT", Text: "help: invoice 42 is wrong #billing #p1", TimeStamp: "1.1"},
+	}}
+	if err := Message(res, req, event); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	tickets, _, _ := s.ListTickets(context.Background(), store.Filter{})
+	if len(tickets) != 1 || tickets[0].Queue != "acme" || !tickets[0].HasTag("billing") || tickets[0].HasTag("p1") {
+		t.Fatalf("Expected the ticket to follow the organisation's policy, got %+v", tickets)
+	}
+	_, values, _ := slack.UnsafeApplyMsgOptions("", "C1", "", posted...)
+	if strings.Contains(values.Encode(), "invoice") {
+		t.Errorf("Expected only the ticket's reference to be shared, got %v", values)
+	}
+}
+
+func TestVIPTicket(t *testing.T) {
+	var reply, notification []slack.MsgOption
+	mockSlack := &mocks.SlackWrapper{}
+	mockSlack.On("AddReaction", mock.Anything, mock.Anything).Return(nil)
+	mockSlack.On("PostMessage", "C1", mock.Anything, mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		for _, o := range args[1:] {
+			reply = append(reply, o.(slack.MsgOption))
+		}
+	}).Return("C1", "1.2", nil)
+	mockSlack.On("PostMessage", "CVIP", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		for _, o := range args[1:] {
+			notification = append(notification, o.(slack.MsgOption))
+		}
+	}).Return("CVIP", "1.3", nil)
+	Init(mockSlack)
+	s := store.NewMemory()
+	InitTickets(s)
+	InitTriggers(intake.NewWatcher([]string{"C1"}, intake.Prefix("help:")))
+	defer InitTriggers(nil)
+	InitGuestPolicy(fakeDirectory{users: map[string]slack.User{"UCEO": {ID: "UCEO", TeamID: "T1"}}}, intake.Policy{})
+	defer InitGuestPolicy(nil, intake.Policy{})
+	v, _ := intake.NewVIPs([]string{"UCEO"}, "")
+	v.Queue, v.Channel = "senior", "CVIP"
+	InitVIPs(v)
+	defer InitVIPs(nil)
+	req, res, _ := newTestRequest()
+
+	event := &slackevents.EventsAPIEvent{TeamID: "T1", InnerEvent: slackevents.EventsAPIInnerEvent{
+		Type: "message",
+		Data: &slackevents.MessageEvent{Channel: "C1", User: "UCEO", Text: "help: email is down", TimeStamp: "1.1"},
+	}}
+	if err := Message(res, req, event); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	mockSlack.AssertExpectations(t)
+
+	tickets, _, _ := s.ListTickets(context.Background(), store.Filter{})
+	if len(tickets) != 1 || tickets[0].Queue != "senior" || tickets[0].Priority != ticket.P2 || !tickets[0].HasTag(intake.VIPTag) {
+		t.Fatalf("Expected the ticket to be boosted, got %+v", tickets)
+	}
+	_, values, _ := slack.UnsafeApplyMsgOptions("", "C1", "", reply...)
+	if strings.Contains(values.Get("blocks"), "VIP") || strings.Contains(values.Get("blocks"), "P2") {
+		t.Errorf("Expected VIP status to be hidden from the reporter, got %s", values.Get("blocks"))
+	}
+	_, values, _ = slack.UnsafeApplyMsgOptions("", "CVIP", "", notification...)
+	if !strings.Contains(values.Get("blocks"), "VIP") {
+		t.Errorf("Expected VIP status to be shown to agents, got %s", values.Get("blocks"))
+	}
+}
@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/nlopes/slack"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/skybet/go-helpdesk/lifecycle"
+	"github.com/skybet/go-helpdesk/server"
+	"github.com/skybet/go-helpdesk/store"
+	"github.com/skybet/go-helpdesk/ticket"
+)
+
+var machine = lifecycle.New(lifecycle.DefaultTransitions)
+
+// InitLifecycle sets the machine /hd move moves tickets with. Every move is
+// posted in the ticket's thread.
+func InitLifecycle(m *lifecycle.Machine) {
+	m.OnMove(announceMove)
+	machine = m
+}
+
+// Move handles /hd move <ticket> <status>, moving a ticket to another status
+// if the lifecycle allows it
+func Move(res *server.Response, req *server.Request, sc slack.SlashCommand, args server.Args) error {
+	id := args.String("ticket")
+	to, err := ticket.ParseStatus(args.String("status"))
+	if err != nil {
+		res.Text(http.StatusOK, tr(sc, "Unknown status %s, use one of %s", args.String("status"), joinStatuses(ticket.Statuses)))
+		return nil
+	}
+	t, err := machine.Move(req.Context(), tickets, id, to, sc.UserID, clk.Now())
+	switch {
+	case err == store.ErrNotFound:
+		res.Text(http.StatusOK, tr(sc, "There is no ticket #%s", id))
+	case errors.Is(err, lifecycle.ErrNotAllowed):
+		current, err := tickets.GetTicket(req.Context(), id)
+		if err != nil {
+			return fmt.Errorf("Failed to get ticket %s: %s", id, err)
+		}
+		res.Text(http.StatusOK, tr(sc, "Ticket #%s can not move from %s to %s, it can move to %s", id, current.Status, to, joinStatuses(machine.Next(current.Status))))
+	case err == store.ErrConflict:
+		res.Text(http.StatusOK, tr(sc, "Ticket #%s changed status while it was being moved, try again", id))
+	case err != nil:
+		res.Text(http.StatusOK, tr(sc, "Ticket #%s could not be moved: %s", id, err))
+	default:
+		res.Text(http.StatusOK, tr(sc, "Ticket #%s is %s", t.ID, t.Status))
+	}
+	return nil
+}
+
+// announceMove posts a move in the ticket's thread
+func announceMove(m lifecycle.Move) {
+	t := m.Ticket
+	if t.ChannelID == "" || slackWrapper == nil {
+		return
+	}
+	text := fmt.Sprintf("<@%s> moved ticket %s from %s to %s", m.By, ticketLinks.Ref(t.ID), m.From, m.To)
+	if _, _, err := slackWrapper.PostMessage(t.ChannelID, slack.MsgOptionText(text, false), slack.MsgOptionTS(t.ThreadTS)); err != nil {
+		log.Errorf("Failed to post the move of ticket %s: %s", t.ID, err)
+	}
+}
+
+func joinStatuses(statuses []ticket.Status) string {
+	if len(statuses) == 0 {
+		return "nothing"
+	}
+	names := make([]string, len(statuses))
+	for i, s := range statuses {
+		names[i] = string(s)
+	}
+	return strings.Join(names, ", ")
+}
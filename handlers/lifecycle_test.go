@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/nlopes/slack"
+
+	"github.com/skybet/go-helpdesk/lifecycle"
+	"github.com/skybet/go-helpdesk/mocks"
+	"github.com/skybet/go-helpdesk/store"
+	"github.com/skybet/go-helpdesk/ticket"
+)
+
+func TestMove(t *testing.T) {
+	mockSlack := mocks.NewSlack(t)
+	mockSlack.ExpectPostMessage().ToChannel("C1").WithText("<@U1> moved ticket #1 from new to triaged")
+	Init(mockSlack)
+	s := store.NewMemory()
+	s.CreateTicket(context.Background(), &ticket.Ticket{ID: "1", ChannelID: "C1", ThreadTS: "1.1"})
+	InitTickets(s)
+	InitLifecycle(lifecycle.New(lifecycle.DefaultTransitions))
+
+	for _, tc := range []struct{ text, reply string }{
+		{"move 1 triaged", "Ticket #1 is triaged"},
+		{"move #1 new", "can not move from triaged to new, it can move to in_progress, waiting, resolved, closed"},
+		{"move 1 done", "Unknown status done"},
+		{"move 9 closed", "There is no ticket #9"},
+		{"move 1", "Usage: /hd move <ticket> <status>"},
+	} {
+		req, res, w := newTestRequest()
+		if err := Helpdesk(res, req, slack.SlashCommand{Command: "/hd", Text: tc.text, UserID: "U1"}); err != nil {
+			t.Fatalf("%s: unexpected error: %s", tc.text, err)
+		}
+		if !strings.Contains(w.Body.String(), tc.reply) {
+			t.Errorf("%s: expected %q, got %s", tc.text, tc.reply, w.Body)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"html/template"
+	"net/http"
+	"strings"
+
+	"github.com/nlopes/slack"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/skybet/go-helpdesk/intake"
+	"github.com/skybet/go-helpdesk/store"
+)
+
+// maxLocationForm is the largest request accepted by the location intake form
+const maxLocationForm = 64 << 10
+
+// emailLookup finds the Slack user with a work email, it is satisfied by
+// *slack.Client
+type emailLookup interface {
+	GetUserByEmail(email string) (*slack.User, error)
+}
+
+var locationForm = template.Must(template.New("location").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Location.Name}} - Helpdesk</title>
+</head>
+<body>
+<h1>{{.Location.Name}}</h1>
+{{if .Ticket}}<p>Thanks, your request is ticket #{{.Ticket}}. We will follow up with you in Slack.</p>
+{{else}}{{if .Error}}<p><strong>{{.Error}}</strong></p>
+{{end}}<form method="post">
+<p><label>What is the problem?<br><textarea name="description" rows="6" cols="40" required>{{.Description}}</textarea></label></p>
+<p><label>Your work email<br><input type="email" name="email" value="{{.Email}}" required></label></p>
+<p><button type="submit">Report</button></p>
+</form>
+{{end}}</body>
+</html>
+`))
+
+type locationPage struct {
+	Location    intake.Location
+	Description string
+	Email       string
+	Error       string
+	Ticket      string
+}
+
+// LocationIntake serves the page a location's QR code or short code opens at
+// /<code>, where anyone can report a problem there. Reporters give their work
+// email, which must belong to someone in the workspace, and the ticket is
+// raised in the location's channel with its queue and fields filled in.
+func LocationIntake(l *intake.Locations, users emailLookup, teamID string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		loc, ok := l.Get(strings.Trim(r.URL.Path, "/"))
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		page := locationPage{Location: loc}
+		switch r.Method {
+		case http.MethodGet, http.MethodHead:
+		case http.MethodPost:
+			r.Body = http.MaxBytesReader(w, r.Body, maxLocationForm)
+			if err := r.ParseForm(); err != nil {
+				http.Error(w, "invalid form", http.StatusBadRequest)
+				return
+			}
+			page.Description = strings.TrimSpace(r.PostForm.Get("description"))
+			page.Email = strings.TrimSpace(r.PostForm.Get("email"))
+			status, err := reportAtLocation(loc, users, teamID, &page)
+			if err != nil {
+				log.Errorf("Failed to report a problem at %s: %s", loc.Code, err)
+				http.Error(w, "error creating ticket", http.StatusInternalServerError)
+				return
+			}
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.WriteHeader(status)
+			locationForm.Execute(w, page)
+			return
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		locationForm.Execute(w, page)
+	})
+}
+
+// reportAtLocation creates the ticket for a submitted form, the returned
+// status is that of the page, which explains what was wrong with the form
+func reportAtLocation(loc intake.Location, users emailLookup, teamID string, page *locationPage) (int, error) {
+	if page.Description == "" || page.Email == "" {
+		page.Error = "Please describe the problem and give your work email."
+		return http.StatusBadRequest, nil
+	}
+	u, err := users.GetUserByEmail(page.Email)
+	if err != nil || u.Deleted || u.IsBot {
+		page.Error = "That email does not belong to anyone in the workspace."
+		return http.StatusBadRequest, nil
+	}
+	_, ts, err := slackWrapper.PostMessage(loc.Channel, slack.MsgOptionText(fmt.Sprintf("<@%s> reported a problem at %s", u.ID, loc.Name), false))
+	if err != nil {
+		return 0, fmt.Errorf("Failed to post in %s: %s", loc.Channel, err)
+	}
+	t, a, err := createTicket(store.WithTeam(context.Background(), teamID), teamID, loc.Channel, ts, u.ID, page.Description, loc.Apply)
+	if err != nil {
+		return 0, err
+	}
+	summary := fmt.Sprintf("Ticket #%s reported by <@%s> at %s: %s", t.ID, u.ID, loc.Name, t.Title)
+	if _, _, _, err := slackWrapper.UpdateMessage(loc.Channel, ts, cardMessage(t, a, summary)...); err != nil {
+		log.Errorf("Failed to show ticket %s in %s: %s", t.ID, loc.Channel, err)
+	}
+	page.Ticket = t.ID
+	return http.StatusCreated, nil
+}
@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/nlopes/slack"
+
+	"github.com/skybet/go-helpdesk/intake"
+	"github.com/skybet/go-helpdesk/mocks"
+	"github.com/skybet/go-helpdesk/store"
+)
+
+type fakeEmails map[string]*slack.User
+
+func (f fakeEmails) GetUserByEmail(email string) (*slack.User, error) {
+	if u, ok := f[email]; ok {
+		return u, nil
+	}
+	return nil, errors.New("users_not_found")
+}
+
+func TestLocationIntake(t *testing.T) {
+	mockSlack := mocks.NewSlack(t)
+	mockSlack.ExpectPostMessage().ToChannel("C1").WithText("<@U1> reported a problem at Printer room").ReturnTS("1.1")
+	mockSlack.ExpectUpdateMessage().ToChannel("C1").ForTS("1.1").WithText("Ticket #1 reported by")
+	Init(mockSlack)
+	s := store.NewMemory()
+	InitTickets(s)
+	l := intake.NewLocations(intake.Location{Code: "PRN2FL", Name: "Printer room", Queue: "facilities", Channel: "C1", Fields: map[string]string{"floor": "2"}})
+	h := LocationIntake(l, fakeEmails{"bob@example.com": {ID: "U1"}}, "T1")
+
+	serve := func(method, path string, form url.Values) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, path, strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+	if w := serve("GET", "/prn2fl", nil); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "<h1>Printer room</h1>") {
+		t.Errorf("Expected the location's form, got %d: %s", w.Code, w.Body)
+	}
+	if w := serve("GET", "/NOWHERE", nil); w.Code != http.StatusNotFound {
+		t.Errorf("Expected an unknown code to be not found, got %d", w.Code)
+	}
+	if w := serve("POST", "/PRN2FL", url.Values{"description": {"Out of toner"}, "email": {"eve@example.com"}}); w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "does not belong") {
+		t.Errorf("Expected an unknown email to be refused, got %d: %s", w.Code, w.Body)
+	}
+	if w := serve("POST", "/PRN2FL", url.Values{"description": {"Out of toner"}, "email": {"bob@example.com"}}); w.Code != http.StatusCreated || !strings.Contains(w.Body.String(), "ticket #1") {
+		t.Errorf("Expected the ticket to be created, got %d: %s", w.Code, w.Body)
+	}
+
+	tk, err := s.GetTicket(context.Background(), "1")
+	if err != nil || tk.Reporter != "U1" || tk.ChannelID != "C1" || tk.ThreadTS != "1.1" || tk.Queue != "facilities" {
+		t.Fatalf("Expected a ticket in the location's channel, got %+v %v", tk, err)
+	}
+	if tk.Title != "Out of toner" || !strings.Contains(tk.Description, "Location: Printer room (PRN2FL)\nfloor: 2") {
+		t.Errorf("Expected the location's fields in the description, got %q", tk.Description)
+	}
+}
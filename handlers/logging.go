@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nlopes/slack"
+
+	"github.com/skybet/go-helpdesk/logging"
+	"github.com/skybet/go-helpdesk/policy"
+	"github.com/skybet/go-helpdesk/server"
+)
+
+var logs *logging.Controller
+
+// InitLogging sets the controller /hd debug changes the logging settings with
+func InitLogging(c *logging.Controller) {
+	logs = c
+}
+
+// Debug handles /hd debug [level <level> | capture <minutes> | stop], showing
+// or changing the log level and capturing payloads for a while. Only admins
+// can use it.
+func Debug(res *server.Response, req *server.Request, ctx interface{}) error {
+	sc, ok := ctx.(slack.SlashCommand)
+	if !ok {
+		return fmt.Errorf("Expected a slack.SlashCommand to be passed to the handler")
+	}
+	if !allowed(req.Context(), sc.UserID, policy.Debug) {
+		res.Text(http.StatusOK, tr(sc, "Sorry, only helpdesk admins can change logging"))
+		return nil
+	}
+	if logs == nil {
+		return fmt.Errorf("Logging has not been initialised")
+	}
+	args := strings.Fields(sc.Text)
+	switch {
+	case len(args) == 1:
+	case len(args) == 3 && strings.ToLower(args[1]) == "level":
+		if err := logs.SetLevel(args[2]); err != nil {
+			res.Text(http.StatusOK, tr(sc, "Unknown log level %q, use one of debug, info, warning or error", args[2]))
+			return nil
+		}
+	case len(args) == 3 && strings.ToLower(args[1]) == "capture":
+		minutes, err := strconv.Atoi(args[2])
+		if err != nil || minutes <= 0 {
+			res.Text(http.StatusOK, tr(sc, "Usage: %s debug [level <level> | capture <minutes> | stop]", sc.Command))
+			return nil
+		}
+		if _, err := logs.Capture(time.Duration(minutes) * time.Minute); err != nil {
+			res.Text(http.StatusOK, tr(sc, "Payloads can be captured for up to %d minutes", int(logging.MaxCapture/time.Minute)))
+			return nil
+		}
+	case len(args) == 2 && strings.ToLower(args[1]) == "stop":
+		logs.Capture(0)
+	default:
+		res.Text(http.StatusOK, tr(sc, "Usage: %s debug [level <level> | capture <minutes> | stop]", sc.Command))
+		return nil
+	}
+	if until := logs.CaptureUntil(); !until.IsZero() {
+		res.Text(http.StatusOK, tr(sc, "The log level is %s, payloads are captured with secrets redacted until %s", logs.Level(), slackDate(until)))
+		return nil
+	}
+	res.Text(http.StatusOK, tr(sc, "The log level is %s, payloads are not being captured", logs.Level()))
+	return nil
+}
@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/nlopes/slack"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/skybet/go-helpdesk/logging"
+)
+
+func TestDebug(t *testing.T) {
+	level := log.GetLevel()
+	defer log.SetLevel(level)
+	c := logging.New()
+	InitLogging(c)
+	defer InitLogging(nil)
+	InitAnnouncements(nil, []string{"UADMIN"})
+	defer InitAnnouncements(nil, nil)
+	debug := func(user, text string) string {
+		req, res, w := newTestRequest()
+		if err := Helpdesk(res, req, slack.SlashCommand{Command: "/hd", Text: text, UserID: user}); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		return w.Body.String()
+	}
+
+	if body := debug("U1", "debug level debug"); !strings.Contains(body, "only helpdesk admins") || log.GetLevel() != level {
+		t.Errorf("Expected only admins to change logging, got %s", body)
+	}
+	if body := debug("UADMIN", "debug level debug"); !strings.Contains(body, "The log level is debug, payloads are not being captured") {
+		t.Errorf("Expected the level to be changed, got %s", body)
+	}
+	if body := debug("UADMIN", "debug capture 15"); !strings.Contains(body, "payloads are captured with secrets redacted until") || c.CaptureUntil().IsZero() {
+		t.Errorf("Expected payloads to be captured, got %s", body)
+	}
+	if body := debug("UADMIN", "debug capture 600"); !strings.Contains(body, "up to 60 minutes") {
+		t.Errorf("Expected long captures to be refused, got %s", body)
+	}
+	if body := debug("UADMIN", "debug stop"); !strings.Contains(body, "not being captured") {
+		t.Errorf("Expected capturing to stop, got %s", body)
+	}
+	if body := debug("UADMIN", "debug level loud"); !strings.Contains(body, "Unknown log level") {
+		t.Errorf("Expected unknown levels to be refused, got %s", body)
+	}
+}
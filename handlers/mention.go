@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/nlopes/slack"
+	"github.com/nlopes/slack/slackevents"
+
+	"github.com/skybet/go-helpdesk/i18n"
+	"github.com/skybet/go-helpdesk/server"
+)
+
+// mentionPrefix matches the mention of the bot a message starts with
+var mentionPrefix = regexp.MustCompile(`^\s*(<@[A-Z0-9]+(\|[^>]*)?>)\s*`)
+
+// Mention handles app_mention events, running the /hd subcommand written after
+// the mention such as "@helpdesk status 42". Unlike slash commands these can
+// be seen by everyone in the channel. Replies are posted in the thread, and
+// those a slash command would show only to the user are ephemeral.
+func Mention(res *server.Response, req *server.Request, ctx interface{}) error {
+	event, ok := ctx.(*slackevents.EventsAPIEvent)
+	if !ok {
+		return fmt.Errorf("Expected a *slackevents.EventsAPIEvent to be passed to the handler")
+	}
+	ev, ok := event.InnerEvent.Data.(*slackevents.AppMentionEvent)
+	if !ok {
+		return fmt.Errorf("Expected an app_mention event, got %T", event.InnerEvent.Data)
+	}
+	m := mentionPrefix.FindStringSubmatch(ev.Text)
+	if m == nil {
+		// The bot was mentioned part way through a message
+		return nil
+	}
+	thread := ev.ThreadTimeStamp
+	if thread == "" {
+		thread = ev.TimeStamp
+	}
+	sc := slack.SlashCommand{
+		TeamID:    event.TeamID,
+		ChannelID: ev.Channel,
+		UserID:    ev.User,
+		Command:   m[1],
+		Text:      strings.TrimSpace(ev.Text[len(m[0]):]),
+	}
+	// Mentions have no trigger to open the new ticket dialog with, so new in
+	// the user's language creates the ticket from the text
+	if args := strings.Fields(sc.Text); len(args) > 0 && strings.ToLower(i18n.Keyword(commandLocale(sc), args[0])) == "new" {
+		return ticketFromMention(req.Context(), sc, ev, thread, strings.TrimSpace(sc.Text[len(args[0]):]))
+	}
+
+	w := &mentionResponse{header: http.Header{}}
+	if err := Helpdesk(&server.Response{ResponseWriter: w}, req, sc); err != nil {
+		return err
+	}
+	return w.post(sc, thread)
+}
+
+// ticketFromMention creates a ticket from the text after "new", there is no
+// dialog to fill in as mentions have no trigger to open one with
+func ticketFromMention(ctx context.Context, sc slack.SlashCommand, ev *slackevents.AppMentionEvent, thread, text string) error {
+	if tickets == nil {
+		return nil
+	}
+	if text == "" {
+		_, _, err := slackIn(ctx).PostMessage(sc.ChannelID, slack.MsgOptionPostEphemeral(sc.UserID), slack.MsgOptionTS(thread), slack.MsgOptionText(tr(sc, "Usage: %s new <description>", sc.Command), false))
+		return err
+	}
+	t, a, err := createTicket(ctx, sc.TeamID, sc.ChannelID, thread, sc.UserID, text)
+	if err != nil {
+		return err
+	}
+	if err := slackIn(ctx).AddReaction(TrackingReaction, slack.NewRefToMessage(ev.Channel, ev.TimeStamp)); err != nil {
+		return fmt.Errorf("Failed to react to message: %s", err)
+	}
+	summary := fmt.Sprintf("Ticket #%s created: %s", t.ID, t.Title)
+	if a == referenceCard {
+		summary = fmt.Sprintf("Ticket #%s created", t.ID)
+	}
+	if _, _, err := slackIn(ctx).PostMessage(sc.ChannelID, append(cardMessage(t, a, echo(summary, a)), slack.MsgOptionTS(thread))...); err != nil {
+		return fmt.Errorf("Failed to reply with ticket: %s", err)
+	}
+	return nil
+}
+
+// mentionResponse keeps the reply a subcommand would have sent to a slash
+// command so that it can be posted instead
+type mentionResponse struct {
+	header http.Header
+	body   bytes.Buffer
+}
+
+func (r *mentionResponse) Header() http.Header {
+	return r.header
+}
+
+func (r *mentionResponse) Write(p []byte) (int, error) {
+	return r.body.Write(p)
+}
+
+func (r *mentionResponse) WriteHeader(code int) {}
+
+// post replies in the thread with what the subcommand responded
+func (r *mentionResponse) post(sc slack.SlashCommand, thread string) error {
+	if r.body.Len() == 0 {
+		return nil
+	}
+	msg := slack.Msg{ResponseType: slack.ResponseTypeEphemeral, Text: strings.TrimSpace(r.body.String())}
+	if strings.HasPrefix(r.header.Get("Content-Type"), "application/json") {
+		msg = slack.Msg{}
+		if err := json.Unmarshal(r.body.Bytes(), &msg); err != nil {
+			return fmt.Errorf("Failed to decode the reply to %q: %s", sc.Text, err)
+		}
+	}
+	opts := []slack.MsgOption{slack.MsgOptionText(msg.Text, false), slack.MsgOptionTS(thread)}
+	if len(msg.Blocks.BlockSet) > 0 {
+		opts = append(opts, slack.MsgOptionBlocks(msg.Blocks.BlockSet...))
+	}
+	if len(msg.Attachments) > 0 {
+		opts = append(opts, slack.MsgOptionAttachments(msg.Attachments...))
+	}
+	if msg.ResponseType != slack.ResponseTypeInChannel {
+		opts = append(opts, slack.MsgOptionPostEphemeral(sc.UserID))
+	}
+	if _, _, err := slackWrapper.PostMessage(sc.ChannelID, opts...); err != nil {
+		return fmt.Errorf("Failed to reply to %s: %s", sc.UserID, err)
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/nlopes/slack"
+	"github.com/nlopes/slack/slackevents"
+
+	"github.com/skybet/go-helpdesk/intake"
+	"github.com/skybet/go-helpdesk/mocks"
+	"github.com/skybet/go-helpdesk/store"
+	"github.com/skybet/go-helpdesk/ticket"
+)
+
+func mentionEvent(text, ts string) *slackevents.EventsAPIEvent {
+	return &slackevents.EventsAPIEvent{TeamID: "T1", InnerEvent: slackevents.EventsAPIInnerEvent{
+		Type: "app_mention",
+		Data: &slackevents.AppMentionEvent{Channel: "C1", User: "U1", Text: text, TimeStamp: ts},
+	}}
+}
+
+func TestMention(t *testing.T) {
+	inThread := func(ts string) (string, func(c *mocks.Call) bool) {
+		return "in thread " + ts, func(c *mocks.Call) bool {
+			_, values, _ := slack.UnsafeApplyMsgOptions("", c.Channel, "", c.Options...)
+			return values.Get("thread_ts") == ts
+		}
+	}
+	mockSlack := mocks.NewSlack(t)
+	mockSlack.ExpectPostMessage().ToChannel("C1").Ephemeral("U1").WithText("VPN down").Matching(inThread("1.1"))
+	mockSlack.ExpectAddReaction().Named(TrackingReaction).ForTS("1.2")
+	mockSlack.ExpectPostMessage().ToChannel("C1").WithText("Ticket #2 created: Printer on fire").Matching(inThread("1.2"))
+	mockSlack.ExpectPostMessage().ToChannel("C1").Ephemeral("U1").WithText("Usage: <@UBOT> new <description>")
+	Init(mockSlack)
+	s := store.NewMemory()
+	s.CreateTicket(context.Background(), &ticket.Ticket{ID: "1", Title: "VPN down", Reporter: "U1"})
+	InitTickets(s)
+
+	for _, ev := range []*slackevents.EventsAPIEvent{
+		mentionEvent("<@UBOT> status 1", "1.1"),
+		mentionEvent("<@UBOT>  new Printer on fire", "1.2"),
+		mentionEvent("<@UBOT> new", "1.3"),
+		mentionEvent("Thanks <@UBOT>", "1.4"),
+	} {
+		req, res, _ := newTestRequest()
+		if err := Mention(res, req, ev); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+	}
+	if tk, err := s.GetTicket(context.Background(), "2"); err != nil || tk.ThreadTS != "1.2" || tk.Reporter != "U1" {
+		t.Errorf("Expected a ticket in the mention's thread, got %+v %v", tk, err)
+	}
+}
+
+func TestLocalizedMention(t *testing.T) {
+	InitGuestPolicy(fakeDirectory{users: map[string]slack.User{"U1": {ID: "U1", Locale: "es-ES"}}}, intake.Policy{})
+	defer InitGuestPolicy(nil, intake.Policy{})
+	mockSlack := mocks.NewSlack(t)
+	mockSlack.ExpectAddReaction().Named(TrackingReaction).ForTS("1.1")
+	mockSlack.ExpectPostMessage().ToChannel("C1").WithText("Ticket #1 created: Impresora en llamas")
+	Init(mockSlack)
+	s := store.NewMemory()
+	InitTickets(s)
+
+	req, res, _ := newTestRequest()
+	if err := Mention(res, req, mentionEvent("<@UBOT> nuevo Impresora en llamas", "1.1")); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if tk, err := s.GetTicket(context.Background(), "1"); err != nil || tk.Title != "Impresora en llamas" {
+		t.Errorf("Expected nuevo to create a ticket from the mention, got %+v %v", tk, err)
+	}
+}
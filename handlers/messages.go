@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/nlopes/slack/slackevents"
+
+	"github.com/skybet/go-helpdesk/server"
+	"github.com/skybet/go-helpdesk/sla"
+	"github.com/skybet/go-helpdesk/store"
+	"github.com/skybet/go-helpdesk/ticket"
+)
+
+var tickets store.Store
+
+// InitTickets sets the store tickets are created in
+func InitTickets(s store.Store) {
+	tickets = s
+}
+
+// Message handles message events from the channels the bot is in, passing
+// them to each passive listener which has been initialised
+func Message(res *server.Response, req *server.Request, ctx interface{}) error {
+	event, ok := ctx.(*slackevents.EventsAPIEvent)
+	if !ok {
+		return fmt.Errorf("Expected a *slackevents.EventsAPIEvent to be passed to the handler")
+	}
+	ev, ok := event.InnerEvent.Data.(*slackevents.MessageEvent)
+	if !ok {
+		return fmt.Errorf("Expected a message event, got %T", event.InnerEvent.Data)
+	}
+	if ok, err := inboxRequest(req.Context(), ev); ok {
+		return err
+	}
+	if ok, err := voiceNote(req.Context(), event.TeamID, ev); ok {
+		return err
+	}
+	if questions != nil {
+		questions.Observe(ev)
+	}
+	if err := recordResponse(req.Context(), ev); err != nil {
+		return err
+	}
+	if err := recordComment(req.Context(), ev); err != nil {
+		return err
+	}
+	if err := emailResponse(req.Context(), ev); err != nil {
+		return err
+	}
+	if err := screenshotReply(req.Context(), ev); err != nil {
+		return err
+	}
+	if err := attachmentReply(req.Context(), ev); err != nil {
+		return err
+	}
+	return ticketFromTrigger(req.Context(), event.TeamID, ev)
+}
+
+// recordResponse sets the first response of the ticket whose thread a reply
+// is in, unless it is from the reporter or the bot
+func recordResponse(ctx context.Context, ev *slackevents.MessageEvent) error {
+	if tickets == nil || ev.ThreadTimeStamp == "" || ev.ThreadTimeStamp == ev.TimeStamp || ev.BotID != "" || (ev.SubType != "" && ev.SubType != "thread_broadcast") {
+		return nil
+	}
+	if _, _, err := sla.Respond(ctx, tickets, ev.Channel, ev.ThreadTimeStamp, ev.User, clk.Now()); err != nil {
+		return fmt.Errorf("Failed to record first response: %s", err)
+	}
+	return nil
+}
+
+// ticketFromMessage returns a new ticket for a Slack message, its thread
+// becomes the ticket's thread
+func ticketFromMessage(channel, ts, user, text string) *ticket.Ticket {
+	title := strings.TrimSpace(strings.SplitN(text, "\n", 2)[0])
+	if r := []rune(title); len(r) > 80 {
+		title = string(r[:79]) + "…"
+	}
+	return &ticket.Ticket{
+		Title:       title,
+		Description: text,
+		Reporter:    user,
+		ChannelID:   channel,
+		ThreadTS:    ts,
+	}
+}
@@ -0,0 +1,41 @@
+package handlers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/nlopes/slack/slackevents"
+
+	"github.com/skybet/go-helpdesk/store"
+	"github.com/skybet/go-helpdesk/ticket"
+)
+
+func TestFirstResponse(t *testing.T) {
+	s := store.NewMemory()
+	s.CreateTicket(context.Background(), &ticket.Ticket{ID: "1", Reporter: "U1", ChannelID: "C1", ThreadTS: "1.1"})
+	InitTickets(s)
+	InitTriggers(nil)
+	reply := func(ev *slackevents.MessageEvent) {
+		req, res, _ := newTestRequest()
+		event := &slackevents.EventsAPIEvent{InnerEvent: slackevents.EventsAPIInnerEvent{Type: "message", Data: ev}}
+		if err := Message(res, req, event); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+	}
+
+	reply(&slackevents.MessageEvent{Channel: "C1", User: "U1", Text: "Any update?", TimeStamp: "1.2", ThreadTimeStamp: "1.1"})
+	reply(&slackevents.MessageEvent{Channel: "C1", BotID: "B1", Text: "Ticket #1 created", TimeStamp: "1.3", ThreadTimeStamp: "1.1"})
+	if tk, _ := s.GetTicket(context.Background(), "1"); !tk.FirstResponseAt.IsZero() {
+		t.Fatalf("Expected messages from the reporter and the bot not to count, got %s", tk.FirstResponseAt)
+	}
+	reply(&slackevents.MessageEvent{Channel: "C1", User: "U2", Text: "Looking", TimeStamp: "1.4", ThreadTimeStamp: "1.1"})
+	tk, _ := s.GetTicket(context.Background(), "1")
+	if tk.FirstResponseAt.IsZero() {
+		t.Fatalf("Expected the agent's reply to be the first response")
+	}
+	first := tk.FirstResponseAt
+	reply(&slackevents.MessageEvent{Channel: "C1", User: "U3", Text: "Me too", TimeStamp: "1.5", ThreadTimeStamp: "1.1"})
+	if tk, _ := s.GetTicket(context.Background(), "1"); !tk.FirstResponseAt.Equal(first) {
+		t.Errorf("Expected the first response to be kept, got %s", tk.FirstResponseAt)
+	}
+}
@@ -0,0 +1,29 @@
+package handlers
+
+import (
+	"context"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/skybet/go-helpdesk/policy"
+)
+
+// decider decides who may use the admin commands
+var decider policy.Decider = policy.Admins{}
+
+// InitPolicy sets what decides who may use the admin commands, by default the
+// --admins may use them all
+func InitPolicy(d policy.Decider) {
+	decider = d
+}
+
+// allowed reports whether user may take action. The action is refused when
+// the policy can not be consulted.
+func allowed(ctx context.Context, user, action string) bool {
+	ok, err := decider.Allowed(ctx, policy.Input{Action: action, User: user, Admin: admins[user]})
+	if err != nil {
+		log.Errorf("Refusing %s to %s: %s", action, user, err)
+		return false
+	}
+	return ok
+}
@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nlopes/slack"
+
+	"github.com/skybet/go-helpdesk/policy"
+	"github.com/skybet/go-helpdesk/store"
+	"github.com/skybet/go-helpdesk/ticket"
+	"github.com/skybet/go-helpdesk/trash"
+)
+
+// fakeDecider allows the actions it lists to anyone and fails for the rest
+type fakeDecider struct {
+	allow  map[string]bool
+	inputs []policy.Input
+}
+
+func (d *fakeDecider) Allowed(ctx context.Context, in policy.Input) (bool, error) {
+	d.inputs = append(d.inputs, in)
+	if _, ok := d.allow[in.Action]; !ok {
+		return false, errors.New("policy unavailable")
+	}
+	return d.allow[in.Action], nil
+}
+
+func TestPolicy(t *testing.T) {
+	s := store.NewMemory()
+	s.CreateTicket(context.Background(), &ticket.Ticket{ID: "1", Title: "VPN down"})
+	InitTickets(s)
+	InitAnnouncements(nil, []string{"UADMIN"})
+	defer InitAnnouncements(nil, nil)
+	InitTrash(&trash.Purger{Store: s, Retention: 24 * time.Hour})
+	defer InitTrash(&trash.Purger{})
+	d := &fakeDecider{allow: map[string]bool{policy.Delete: false, policy.ViewTrash: true}}
+	InitPolicy(d)
+	defer InitPolicy(policy.Admins{})
+
+	req, res, w := newTestRequest()
+	Helpdesk(res, req, slack.SlashCommand{Command: "/hd", Text: "delete 1", UserID: "UADMIN"})
+	if body := w.Body.String(); !strings.Contains(body, "only helpdesk admins can delete") {
+		t.Errorf("Expected the policy to refuse an admin, got %s", body)
+	}
+	if len(d.inputs) != 1 || d.inputs[0] != (policy.Input{Action: policy.Delete, User: "UADMIN", Admin: true}) {
+		t.Errorf("Expected the policy to be asked about the admin deleting, got %+v", d.inputs)
+	}
+
+	req, res, w = newTestRequest()
+	Helpdesk(res, req, slack.SlashCommand{Command: "/hd", Text: "trash", UserID: "U1"})
+	if body := w.Body.String(); strings.Contains(body, "only helpdesk admins") {
+		t.Errorf("Expected the policy to allow a user who is not an admin, got %s", body)
+	}
+
+	req, res, w = newTestRequest()
+	Helpdesk(res, req, slack.SlashCommand{Command: "/hd", Text: "restore 1", UserID: "UADMIN"})
+	if body := w.Body.String(); !strings.Contains(body, "only helpdesk admins can restore") {
+		t.Errorf("Expected actions to be refused when the policy fails, got %s", body)
+	}
+}
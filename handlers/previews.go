@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/nlopes/slack"
+	"github.com/nlopes/slack/slackevents"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/skybet/go-helpdesk/preview"
+	"github.com/skybet/go-helpdesk/store"
+	"github.com/skybet/go-helpdesk/ticket"
+)
+
+var previews *preview.Previews
+
+// InitPreviews sets how the files shared on tickets are previewed on their
+// cards, files are not recorded on tickets without it
+func InitPreviews(p *preview.Previews) {
+	previews = p
+}
+
+// attach returns a ticket preparation adding the files shared with the
+// message it was raised from
+func attach(files []slackevents.File) func(*ticket.Ticket) {
+	return func(t *ticket.Ticket) {
+		if previews == nil || len(files) == 0 {
+			return
+		}
+		attachments, err := previews.Attach(files, slackWrapper)
+		if err != nil {
+			log.Errorf("Failed to preview the files of a new ticket: %s", err)
+		}
+		t.Attachments = append(t.Attachments, attachments...)
+	}
+}
+
+// attachmentReply adds the files shared in a ticket's thread to the ticket
+func attachmentReply(ctx context.Context, ev *slackevents.MessageEvent) error {
+	if previews == nil || tickets == nil || ev.SubType != "file_share" || ev.BotID != "" || ev.ThreadTimeStamp == "" || ev.ThreadTimeStamp == ev.TimeStamp {
+		return nil
+	}
+	found, _, err := tickets.ListTickets(ctx, store.Filter{ChannelID: ev.Channel, ThreadTS: ev.ThreadTimeStamp, Limit: 1})
+	if err != nil {
+		return fmt.Errorf("Failed to find the ticket in thread %s: %s", ev.ThreadTimeStamp, err)
+	}
+	if len(found) == 0 {
+		return nil
+	}
+	attachments, err := previews.Attach(ev.Files, slackWrapper)
+	if err != nil {
+		log.Errorf("Failed to preview the files shared on ticket %s: %s", found[0].ID, err)
+	}
+	id := found[0].ID
+	err = tickets.Tx(ctx, func(tx store.Store) error {
+		t, err := tx.GetTicket(ctx, id)
+		if err != nil {
+			return err
+		}
+		t.Attachments = append(t.Attachments, attachments...)
+		return tx.UpdateTicket(ctx, t)
+	})
+	if err != nil {
+		return fmt.Errorf("Failed to attach files to ticket %s: %s", id, err)
+	}
+	return nil
+}
+
+// previewBlocks shows the first of a ticket's files: a thumbnail of each
+// image, the snippet of each text file and a link to view each in full
+func previewBlocks(t *ticket.Ticket) []slack.Block {
+	if previews == nil || len(t.Attachments) == 0 || previews.Limits.Files <= 0 {
+		return nil
+	}
+	shown := t.Attachments
+	if len(shown) > previews.Limits.Files {
+		shown = shown[:previews.Limits.Files]
+	}
+	var blocks []slack.Block
+	for _, a := range shown {
+		if u := previews.ImageURL(t, a); u != "" {
+			blocks = append(blocks, slack.NewImageBlock(u, a.Name, "", slack.NewTextBlockObject(slack.PlainTextType, a.Name, false, false)))
+		} else if a.Snippet != "" {
+			snippet := a.Snippet
+			if a.Truncated {
+				snippet += "\n…"
+			}
+			blocks = append(blocks, slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType, fmt.Sprintf("*%s*\n```%s```", a.Name, snippet), false, false), nil, nil))
+		}
+		label := fmt.Sprintf(":paperclip: %s", a.Name)
+		full := ticketLinks.Admin(t.ID)
+		if full == "" {
+			full = a.Permalink
+		}
+		if full != "" {
+			label += fmt.Sprintf(" <%s|View full>", full)
+		}
+		blocks = append(blocks, slack.NewContextBlock("", slack.NewTextBlockObject(slack.MarkdownType, label, false, false)))
+	}
+	if more := len(t.Attachments) - len(shown); more > 0 {
+		text := fmt.Sprintf("and %d more files", more)
+		if more == 1 {
+			text = "and 1 more file"
+		}
+		blocks = append(blocks, slack.NewContextBlock("", slack.NewTextBlockObject(slack.MarkdownType, text, false, false)))
+	}
+	return blocks
+}
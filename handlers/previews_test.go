@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/nlopes/slack"
+	"github.com/nlopes/slack/slackevents"
+
+	"github.com/skybet/go-helpdesk/intake"
+	"github.com/skybet/go-helpdesk/mocks"
+	"github.com/skybet/go-helpdesk/preview"
+	"github.com/skybet/go-helpdesk/store"
+)
+
+func TestPreviews(t *testing.T) {
+	mockSlack := mocks.NewSlack(t)
+	mockSlack.ExpectDownloadFile().Named("https://files/F2").ReturnContent("dial tcp: i/o timeout\nretrying")
+	mockSlack.ExpectAddReaction().Named(TrackingReaction)
+	mockSlack.ExpectPostMessage().ToChannel("C1").WithText("Ticket #1 created: VPN will not connect")
+	mockSlack.ExpectDownloadFile().Named("https://files/F3").ReturnContent("route table")
+	Init(mockSlack)
+	s := store.NewMemory()
+	InitTickets(s)
+	InitTriggers(intake.NewWatcher([]string{"C1"}, intake.Prefix("help:")))
+	defer InitTriggers(nil)
+	InitPreviews(preview.New("https://helpdesk.example.com/previews", "secret", preview.Limits{MaxBytes: 1 << 20, Lines: 1, Chars: 100, Size: 360, Files: 2}))
+	defer InitPreviews(nil)
+
+	for _, ev := range []*slackevents.MessageEvent{
+		{Channel: "C1", SubType: "file_share", User: "U1", Text: "help: VPN will not connect", TimeStamp: "1.1", Files: []slackevents.File{
+			{ID: "F1", Name: "vpn.png", Mimetype: "image/png", Size: 2048, URLPrivateDownload: "https://files/F1", Permalink: "https://slack/F1"},
+			{ID: "F2", Name: "vpn.log", Mimetype: "text/plain", Size: 30, URLPrivateDownload: "https://files/F2", Permalink: "https://slack/F2"},
+		}},
+		{Channel: "C1", SubType: "file_share", User: "U1", ThreadTimeStamp: "1.1", TimeStamp: "1.3", Files: []slackevents.File{
+			{ID: "F3", Name: "routes.txt", Mimetype: "text/plain", Size: 11, URLPrivateDownload: "https://files/F3"},
+		}},
+	} {
+		req, res, _ := newTestRequest()
+		event := &slackevents.EventsAPIEvent{InnerEvent: slackevents.EventsAPIInnerEvent{Type: "message", Data: ev}}
+		if err := Message(res, req, event); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+	}
+	tk, err := s.GetTicket(context.Background(), "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tk.Attachments) != 3 || tk.Attachments[1].Snippet != "dial tcp: i/o timeout" || !tk.Attachments[1].Truncated || tk.Attachments[2].Name != "routes.txt" {
+		t.Fatalf("Expected the files to be attached with snippets, got %+v", tk.Attachments)
+	}
+
+	blocks := ticketCard(tk, agentCard)
+	image, ok := blocks[1].(*slack.ImageBlock)
+	if !ok || !strings.HasPrefix(image.ImageURL, "https://helpdesk.example.com/previews/1/F1?sig=") {
+		t.Fatalf("Expected a thumbnail of the image, got %+v", blocks[1])
+	}
+	if link := blocks[2].(*slack.ContextBlock).ContextElements.Elements[0].(*slack.TextBlockObject).Text; link != ":paperclip: vpn.png <https://slack/F1|View full>" {
+		t.Errorf("Expected a link to the file in Slack, got %q", link)
+	}
+	if snippet := blocks[3].(*slack.SectionBlock).Text.Text; snippet != "*vpn.log*\n```dial tcp: i/o timeout\n…```" {
+		t.Errorf("Expected a truncated snippet of the log, got %q", snippet)
+	}
+	if more := blocks[5].(*slack.ContextBlock).ContextElements.Elements[0].(*slack.TextBlockObject).Text; more != "and 1 more file" {
+		t.Errorf("Expected the files over the limit to be counted, got %q", more)
+	}
+	for _, b := range ticketCard(tk, publicCard) {
+		if _, ok := b.(*slack.ImageBlock); ok {
+			t.Errorf("Expected public cards not to preview files")
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/nlopes/slack"
+
+	"github.com/skybet/go-helpdesk/policy"
+	"github.com/skybet/go-helpdesk/provision"
+	"github.com/skybet/go-helpdesk/render"
+	"github.com/skybet/go-helpdesk/server"
+)
+
+var provisioner *provision.Provisioner
+
+// InitProvisioner sets the provisioner used to onboard queues, the dashboard
+// it pins is the one /hd dashboard replies with
+func InitProvisioner(p *provision.Provisioner) {
+	if p != nil && p.Dashboard == nil {
+		p.Dashboard = dashboardMessage
+	}
+	provisioner = p
+}
+
+// Provision handles /hd provision <queue> <channel> [@usergroup], setting up
+// the queue's triage channel and inviting the usergroup to it. Tickets shared
+// with the queue are posted in the channel from then on.
+func Provision(res *server.Response, req *server.Request, ctx interface{}) error {
+	sc, ok := ctx.(slack.SlashCommand)
+	if !ok {
+		return fmt.Errorf("Expected a slack.SlashCommand to be passed to the handler")
+	}
+	if !allowed(req.Context(), sc.UserID, policy.Provision) {
+		res.Text(http.StatusOK, tr(sc, "Sorry, only helpdesk admins can provision queues"))
+		return nil
+	}
+	if provisioner == nil {
+		return fmt.Errorf("Provisioner has not been initialised")
+	}
+	args := strings.Fields(sc.Text)
+	if len(args) < 3 || len(args) > 4 {
+		res.Text(http.StatusOK, tr(sc, "Usage: %s provision <queue> <channel> [@usergroup]", sc.Command))
+		return nil
+	}
+	q := provision.Queue{
+		Name:    args[1],
+		Channel: channelName(args[2]),
+		Topic:   fmt.Sprintf("Triage for the %s helpdesk queue", args[1]),
+		Purpose: fmt.Sprintf("Tickets in the %s queue are posted and worked on here", args[1]),
+	}
+	if len(args) == 4 {
+		if q.Usergroup = usergroupID(args[3]); q.Usergroup == "" {
+			res.Text(http.StatusOK, tr(sc, "Usage: %s provision <queue> <channel> [@usergroup]", sc.Command))
+			return nil
+		}
+	}
+	r, err := provisioner.Provision(req.Context(), q, sc.UserID, clk.Now())
+	if err != nil {
+		res.Text(http.StatusOK, tr(sc, "The %s queue could not be provisioned: %s", q.Name, err))
+		return nil
+	}
+	if mirror != nil {
+		mirror.SetChannel(r.Queue, r.ChannelID)
+	}
+	if r.Created {
+		res.Text(http.StatusOK, tr(sc, "Created <#%s> for the %s queue and invited %d people", r.ChannelID, r.Queue, r.Invited))
+		return nil
+	}
+	res.Text(http.StatusOK, tr(sc, "<#%s> is now the channel of the %s queue, %d people were invited", r.ChannelID, r.Queue, r.Invited))
+	return nil
+}
+
+// dashboardMessage renders the dashboard to post in a channel
+func dashboardMessage(ctx context.Context) ([]slack.MsgOption, error) {
+	d, err := dashboard(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return []slack.MsgOption{slack.MsgOptionText(d.Text(render.Rich), false), slack.MsgOptionBlocks(d.Blocks()...)}, nil
+}
+
+// channelName returns the name of a channel given as #name or as an escaped
+// <#C123|name> reference
+func channelName(arg string) string {
+	if strings.HasPrefix(arg, "<#") && strings.HasSuffix(arg, ">") {
+		if i := strings.Index(arg, "|"); i >= 0 {
+			return arg[i+1 : len(arg)-1]
+		}
+	}
+	return strings.TrimPrefix(arg, "#")
+}
+
+// usergroupID returns the ID of a usergroup given as an escaped
+// <!subteam^S123|@name> mention or as its ID, empty if it is neither
+func usergroupID(arg string) string {
+	if strings.HasPrefix(arg, "<!subteam^") && strings.HasSuffix(arg, ">") {
+		id := strings.TrimSuffix(strings.TrimPrefix(arg, "<!subteam^"), ">")
+		if i := strings.Index(id, "|"); i >= 0 {
+			id = id[:i]
+		}
+		return id
+	}
+	if strings.HasPrefix(arg, "S") && strings.ToUpper(arg) == arg {
+		return arg
+	}
+	return ""
+}
@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nlopes/slack"
+
+	"github.com/skybet/go-helpdesk/crosspost"
+	"github.com/skybet/go-helpdesk/provision"
+	"github.com/skybet/go-helpdesk/store"
+)
+
+// fakeChannels creates channels and records who was invited to them
+type fakeChannels struct {
+	invited []string
+	pinned  string
+}
+
+func (f *fakeChannels) Channels(ctx context.Context) ([]slack.Channel, error) {
+	return nil, nil
+}
+func (f *fakeChannels) CreateChannel(name string) (*slack.Channel, error) {
+	ch := &slack.Channel{}
+	ch.ID, ch.Name = "CNEW", name
+	return ch, nil
+}
+func (f *fakeChannels) InviteUsers(channelID string, users ...string) error {
+	f.invited = append(f.invited, users...)
+	return nil
+}
+func (f *fakeChannels) SetTopic(channelID, topic string) error     { return nil }
+func (f *fakeChannels) SetPurpose(channelID, purpose string) error { return nil }
+func (f *fakeChannels) UsergroupMembers(usergroup string) ([]string, error) {
+	return []string{"U1", "U2"}, nil
+}
+func (f *fakeChannels) PostMessage(channelID string, options ...slack.MsgOption) (string, string, error) {
+	return channelID, "1.1", nil
+}
+func (f *fakeChannels) AddPin(channel string, item slack.ItemRef) error {
+	f.pinned = item.Timestamp
+	return nil
+}
+
+func TestProvision(t *testing.T) {
+	s := store.NewMemory()
+	InitTickets(s)
+	f := &fakeChannels{}
+	InitProvisioner(&provision.Provisioner{Store: s, Slack: f, Channels: f})
+	defer InitProvisioner(nil)
+	m := &crosspost.Mirror{Store: s}
+	InitCrossPost(m)
+	defer InitCrossPost(nil)
+	InitAnnouncements(nil, []string{"UADMIN"})
+	defer InitAnnouncements(nil, nil)
+
+	req, res, w := newTestRequest()
+	if err := Helpdesk(res, req, slack.SlashCommand{Command: "/hd", Text: "provision it it-triage <!subteam^S1|@it>", UserID: "UADMIN"}); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if body := w.Body.String(); !strings.Contains(body, "Created <#CNEW> for the it queue and invited 2 people") {
+		t.Errorf("Expected to be told the channel was created, got %s", body)
+	}
+	if len(f.invited) != 2 || f.pinned != "1.1" {
+		t.Errorf("Expected the usergroup to be invited and the dashboard pinned, got %v %q", f.invited, f.pinned)
+	}
+	if m.Channels["it"] != "CNEW" {
+		t.Errorf("Expected tickets to be shareable with the queue, got %v", m.Channels)
+	}
+	if qcs, _ := s.QueueChannels(context.Background()); len(qcs) != 1 || qcs[0].ProvisionedBy != "UADMIN" || qcs[0].ProvisionedAt.After(time.Now()) {
+		t.Errorf("Expected the channel to be recorded, got %+v", qcs)
+	}
+
+	req, res, w = newTestRequest()
+	if err := Helpdesk(res, req, slack.SlashCommand{Command: "/hd", Text: "provision it it-triage", UserID: "U1"}); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if body := w.Body.String(); !strings.Contains(body, "only helpdesk admins") {
+		t.Errorf("Expected only admins to provision queues, got %s", body)
+	}
+}
+
+func TestChannelAndUsergroupArgs(t *testing.T) {
+	for arg, expected := range map[string]string{"#it": "it", "it": "it", "<#C1|it-triage>": "it-triage"} {
+		if got := channelName(arg); got != expected {
+			t.Errorf("Expected channel %q to be %q, got %q", arg, expected, got)
+		}
+	}
+	for arg, expected := range map[string]string{"<!subteam^S1|@it>": "S1", "<!subteam^S2>": "S2", "S3": "S3", "@it": ""} {
+		if got := usergroupID(arg); got != expected {
+			t.Errorf("Expected usergroup %q to be %q, got %q", arg, expected, got)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"fmt"
+
+	"github.com/nlopes/slack"
+	"github.com/nlopes/slack/slackevents"
+
+	"github.com/skybet/go-helpdesk/appreciation"
+	"github.com/skybet/go-helpdesk/server"
+)
+
+var reactions *appreciation.Tracker
+
+// InitAppreciation sets the tracker recording appreciation reactions in
+// tickets' threads, without it reactions are ignored
+func InitAppreciation(t *appreciation.Tracker) {
+	reactions = t
+}
+
+// Reaction handles reaction_added and reaction_removed events, counting the
+// appreciation reactions on messages in tickets' threads
+func Reaction(res *server.Response, req *server.Request, ctx interface{}) error {
+	event, ok := ctx.(*slackevents.EventsAPIEvent)
+	if !ok {
+		return fmt.Errorf("Expected a *slackevents.EventsAPIEvent to be passed to the handler")
+	}
+	if reactions == nil {
+		return nil
+	}
+	switch ev := event.InnerEvent.Data.(type) {
+	case *slack.ReactionAddedEvent:
+		if ev.Item.Type != "message" {
+			return nil
+		}
+		if _, err := reactions.Added(req.Context(), ev.Item.Channel, ev.Item.Timestamp, ev.Reaction, ev.User, ev.ItemUser, clk.Now()); err != nil {
+			return fmt.Errorf("Failed to record reaction: %s", err)
+		}
+	case *slack.ReactionRemovedEvent:
+		if ev.Item.Type != "message" {
+			return nil
+		}
+		if _, err := reactions.Removed(req.Context(), ev.Item.Channel, ev.Item.Timestamp, ev.Reaction, ev.User); err != nil {
+			return fmt.Errorf("Failed to forget reaction: %s", err)
+		}
+	default:
+		return fmt.Errorf("Expected a reaction event, got %T", event.InnerEvent.Data)
+	}
+	return nil
+}
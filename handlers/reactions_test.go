@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/nlopes/slack"
+	"github.com/nlopes/slack/slackevents"
+
+	"github.com/skybet/go-helpdesk/appreciation"
+	"github.com/skybet/go-helpdesk/store"
+	"github.com/skybet/go-helpdesk/ticket"
+)
+
+type noThreads struct{}
+
+func (noThreads) ThreadOf(channelID, ts string) (string, error) {
+	return ts, nil
+}
+
+func TestReaction(t *testing.T) {
+	s := store.NewMemory()
+	s.CreateTicket(context.Background(), &ticket.Ticket{ID: "1", Reporter: "U1", ChannelID: "C1", ThreadTS: "1.0"})
+	InitAppreciation(appreciation.NewTracker(s, noThreads{}, appreciation.DefaultEmoji...))
+	defer InitAppreciation(nil)
+	req, res, _ := newTestRequest()
+
+	added := &slack.ReactionAddedEvent{Type: "reaction_added", User: "U2", ItemUser: "U1", Reaction: "tada"}
+	added.Item.Type, added.Item.Channel, added.Item.Timestamp = "message", "C1", "1.0"
+	event := &slackevents.EventsAPIEvent{InnerEvent: slackevents.EventsAPIInnerEvent{Type: "reaction_added", Data: added}}
+	if err := Reaction(res, req, event); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if tk, _ := s.GetTicket(context.Background(), "1"); len(tk.Reactions) != 1 || tk.Reactions[0].For != "U1" {
+		t.Fatalf("Expected the reaction to be recorded, got %+v", tk.Reactions)
+	}
+
+	removed := slack.ReactionRemovedEvent(*added)
+	removed.Type = "reaction_removed"
+	event = &slackevents.EventsAPIEvent{InnerEvent: slackevents.EventsAPIInnerEvent{Type: "reaction_removed", Data: &removed}}
+	if err := Reaction(res, req, event); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if tk, _ := s.GetTicket(context.Background(), "1"); len(tk.Reactions) != 0 {
+		t.Errorf("Expected the reaction to be forgotten, got %+v", tk.Reactions)
+	}
+}
@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/nlopes/slack"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/skybet/go-helpdesk/runbook"
+	"github.com/skybet/go-helpdesk/server"
+	"github.com/skybet/go-helpdesk/ticket"
+)
+
+var runbooks *runbook.Runbooks
+
+// InitRunbooks sets the runbooks suggested for tickets' categories, none are
+// suggested without them
+func InitRunbooks(r *runbook.Runbooks) {
+	runbooks = r
+}
+
+// runbookLinks returns the mrkdwn links to a ticket's runbooks
+func runbookLinks(rbs []runbook.Runbook) string {
+	var links []string
+	for _, rb := range rbs {
+		links = append(links, fmt.Sprintf("<%s|%s>", rb.Link, rb.Category))
+	}
+	return strings.Join(links, ", ")
+}
+
+// suggestRunbooks DMs the assignee of a ticket the runbooks for its
+// categories, if it has any
+func suggestRunbooks(t *ticket.Ticket) {
+	rbs := runbooks.For(t)
+	if t.Assignee == "" || len(rbs) == 0 {
+		return
+	}
+	text := fmt.Sprintf("You have been assigned ticket %s %s, these runbooks may help: %s", ticketLinks.Ref(t.ID), t.Title, runbookLinks(rbs))
+	if _, _, err := dm().PostMessage(t.Assignee, slack.MsgOptionText(text, false)); err != nil {
+		log.Errorf("Failed to send %s the runbooks of ticket %s: %s", t.Assignee, t.ID, err)
+		return
+	}
+	if err := runbooks.Suggested(rbs); err != nil {
+		log.Errorf("Failed to count the runbooks suggested for ticket %s: %s", t.ID, err)
+	}
+}
+
+// Runbooks handles /hd runbooks, which lists every runbook with how often it
+// has been suggested and opened, least opened first, to find the stale ones
+func Runbooks(res *server.Response, req *server.Request, ctx interface{}) error {
+	sc, ok := ctx.(slack.SlashCommand)
+	if !ok {
+		return fmt.Errorf("Expected a slack.SlashCommand to be passed to the handler")
+	}
+	usage := runbooks.Usage()
+	if len(usage) == 0 {
+		res.Text(http.StatusOK, tr(sc, "No runbooks have been set up"))
+		return nil
+	}
+	lines := []string{tr(sc, "*Runbooks*, least opened first")}
+	for _, u := range usage {
+		last := tr(sc, "never opened")
+		if !u.LastOpened.IsZero() {
+			last = tr(sc, "last opened %s", slackDate(u.LastOpened))
+		}
+		lines = append(lines, tr(sc, "• %s: suggested %d times, opened %d times, %s", u.Category, u.Suggested, u.Opened, last))
+	}
+	res.Text(http.StatusOK, strings.Join(lines, "\n"))
+	return nil
+}
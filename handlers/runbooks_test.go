@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/nlopes/slack"
+
+	"github.com/skybet/go-helpdesk/mocks"
+	"github.com/skybet/go-helpdesk/runbook"
+	"github.com/skybet/go-helpdesk/store"
+	"github.com/skybet/go-helpdesk/ticket"
+)
+
+func TestRunbooks(t *testing.T) {
+	mockSlack := mocks.NewSlack(t)
+	mockSlack.ExpectPostMessage().ToChannel("U2").WithText("these runbooks may help").WithText("runbooks/vpn|vpn")
+	Init(mockSlack)
+	InitNotifier(nil)
+	s := store.NewMemory()
+	s.CreateTicket(context.Background(), &ticket.Ticket{ID: "1", Title: "VPN down", Queue: "it", Tags: []string{"urgent", "VPN"}})
+	s.CreateTicket(context.Background(), &ticket.Ticket{ID: "2", Title: "Printer", Queue: "it"})
+	InitTickets(s)
+	rbs := runbook.New(map[string]string{"vpn": "https://wiki.example.com/vpn", "printers": "https://wiki.example.com/printers"}, "https://helpdesk.example.com/runbooks")
+	InitRunbooks(rbs)
+	defer InitRunbooks(nil)
+
+	for _, id := range []string{"1", "2"} {
+		req, res, _ := newTestRequest()
+		if err := Helpdesk(res, req, slack.SlashCommand{Command: "/hd", Text: "assign " + id, UserID: "U2"}); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+	}
+
+	tk, _ := s.GetTicket(context.Background(), "1")
+	card, _ := json.Marshal(ticketCard(tk, agentCard))
+	if !strings.Contains(string(card), "Runbooks: \\u003chttps://helpdesk.example.com/runbooks/vpn|vpn\\u003e") {
+		t.Errorf("Expected the agent's card to link the runbook, got %s", card)
+	}
+	if card, _ := json.Marshal(ticketCard(tk, reporterCard)); strings.Contains(string(card), "Runbooks") {
+		t.Errorf("Expected the reporter's card not to link the runbook, got %s", card)
+	}
+
+	req, res, w := newTestRequest()
+	if err := Helpdesk(res, req, slack.SlashCommand{Command: "/hd", Text: "runbooks", UserID: "U2"}); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	body := w.Body.String()
+	if !strings.Contains(body, "printers: suggested 0 times, opened 0 times, never opened") || !strings.Contains(body, "vpn: suggested 1 times, opened 0 times, never opened") {
+		t.Errorf("Expected the runbooks' usage, got %s", body)
+	}
+}
@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/nlopes/slack"
+
+	"github.com/skybet/go-helpdesk/policy"
+	"github.com/skybet/go-helpdesk/query"
+	"github.com/skybet/go-helpdesk/saved"
+	"github.com/skybet/go-helpdesk/server"
+)
+
+// searches are the searches agents have saved, nil if saving them is off
+var searches *saved.Searches
+
+// alertEvery is how often a subscription is alerted unless it says otherwise
+var alertEvery = 15 * time.Minute
+
+// InitSavedSearches sets where agents' saved searches are kept, and how often
+// subscriptions are alerted by default
+func InitSavedSearches(s *saved.Searches, every time.Duration) {
+	searches = s
+	if every > 0 {
+		alertEvery = every
+	}
+}
+
+// Saved handles /hd saved, listing the user's saved searches. Agents allowed
+// to search every ticket can add, run, subscribe to and remove them:
+//
+//	/hd saved add <name> <query>
+//	/hd saved run <name>
+//	/hd saved subscribe <name> [every]
+//	/hd saved unsubscribe <name>
+//	/hd saved remove <name>
+func Saved(res *server.Response, req *server.Request, ctx interface{}) error {
+	sc, ok := ctx.(slack.SlashCommand)
+	if !ok {
+		return fmt.Errorf("Expected a slack.SlashCommand to be passed to the handler")
+	}
+	if searches == nil {
+		res.Text(http.StatusOK, tr(sc, "Saved searches have not been set up"))
+		return nil
+	}
+	args := strings.Fields(sc.Text)
+	if len(args) == 1 {
+		return listSaved(res, sc)
+	}
+	if !allowed(req.Context(), sc.UserID, policy.Search) {
+		res.Text(http.StatusOK, tr(sc, "Sorry, only agents who can search every ticket can save searches"))
+		return nil
+	}
+	action := strings.ToLower(args[1])
+	if len(args) < 3 || (action == "add" && len(args) < 4) {
+		res.Text(http.StatusOK, tr(sc, "Usage: %s saved [add <name> <query> | run <name> | subscribe <name> [every] | unsubscribe <name> | remove <name>]", sc.Command))
+		return nil
+	}
+	name := strings.ToLower(args[2])
+	var err error
+	switch action {
+	case "add":
+		if err = searches.Save(sc.UserID, name, strings.Join(args[3:], " "), clk.Now()); err == nil {
+			res.Text(http.StatusOK, tr(sc, "Saved your search %s, use %s saved subscribe %s to be sent new tickets matching it", name, sc.Command, name))
+		}
+	case "run":
+		s, ok := searches.Get(sc.UserID, name)
+		if !ok {
+			res.Text(http.StatusOK, tr(sc, "You have no saved search called %s", name))
+			return nil
+		}
+		f, err := query.Parse(s.Query, sc.UserID, clk.Now())
+		if err != nil {
+			res.Text(http.StatusOK, tr(sc, "That query could not be understood: %s", err))
+			return nil
+		}
+		return listMatching(res, req, sc, f)
+	case "subscribe":
+		every := alertEvery
+		if len(args) > 3 {
+			if every, err = time.ParseDuration(args[3]); err != nil || every < time.Minute {
+				res.Text(http.StatusOK, tr(sc, "%s is not how often to be sent new tickets, use e.g. 30m or 4h", args[3]))
+				return nil
+			}
+		}
+		if err = searches.Subscribe(sc.UserID, name, every); err == nil {
+			res.Text(http.StatusOK, tr(sc, "You will be sent new tickets matching %s at most every %s", name, every))
+		}
+	case "unsubscribe":
+		if err = searches.Subscribe(sc.UserID, name, 0); err == nil {
+			res.Text(http.StatusOK, tr(sc, "You will no longer be sent new tickets matching %s", name))
+		}
+	case "remove":
+		if err = searches.Remove(sc.UserID, name); err == nil {
+			res.Text(http.StatusOK, tr(sc, "Removed your saved search %s", name))
+		}
+	default:
+		res.Text(http.StatusOK, tr(sc, "Usage: %s saved [add <name> <query> | run <name> | subscribe <name> [every] | unsubscribe <name> | remove <name>]", sc.Command))
+		return nil
+	}
+	if err != nil {
+		res.Text(http.StatusOK, tr(sc, "Your saved searches could not be changed: %s", err))
+	}
+	return nil
+}
+
+// listSaved answers with the user's saved searches
+func listSaved(res *server.Response, sc slack.SlashCommand) error {
+	mine := searches.Of(sc.UserID)
+	if len(mine) == 0 {
+		res.Text(http.StatusOK, tr(sc, "You have no saved searches, use %s saved add <name> <query> to save one", sc.Command))
+		return nil
+	}
+	lines := []string{tr(sc, "*Your saved searches*")}
+	for _, s := range mine {
+		line := fmt.Sprintf("• *%s* `%s`", s.Name, s.Query)
+		if s.Subscribed {
+			line += tr(sc, ", new tickets sent at most every %s", s.Every)
+		}
+		lines = append(lines, line)
+	}
+	return res.JSON(http.StatusOK, slack.Msg{ResponseType: slack.ResponseTypeEphemeral, Text: strings.Join(lines, "\n")})
+}
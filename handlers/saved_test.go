@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nlopes/slack"
+
+	"github.com/skybet/go-helpdesk/saved"
+	"github.com/skybet/go-helpdesk/store"
+	"github.com/skybet/go-helpdesk/ticket"
+)
+
+func TestSaved(t *testing.T) {
+	s := store.NewMemory()
+	s.CreateTicket(context.Background(), &ticket.Ticket{Title: "VPN down", Queue: "it", Reporter: "U1", Priority: ticket.P1, Tags: []string{"vpn"}})
+	s.CreateTicket(context.Background(), &ticket.Ticket{Title: "Laptop", Queue: "it", Reporter: "U2", Priority: ticket.P3})
+	InitTickets(s)
+	InitAnnouncements(nil, []string{"UADMIN"})
+	defer InitAnnouncements(nil, nil)
+	searches := saved.NewSearches()
+	InitSavedSearches(searches, time.Hour)
+	defer InitSavedSearches(nil, 0)
+
+	run := func(user, text string) string {
+		req, res, w := newTestRequest()
+		if err := Helpdesk(res, req, slack.SlashCommand{Command: "/hd", Text: text, UserID: user}); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		return w.Body.String()
+	}
+	if got := run("UADMIN", "saved"); !strings.Contains(got, "You have no saved searches") {
+		t.Errorf("Expected no searches, got %s", got)
+	}
+	if got := run("UADMIN", "saved add p1 status:open priority:P1"); !strings.Contains(got, "Saved your search p1") {
+		t.Errorf("Expected the search to be saved, got %s", got)
+	}
+	if got := run("UADMIN", "saved add broken colour:red"); !strings.Contains(got, "could not be changed: unknown field") {
+		t.Errorf("Expected an invalid query to be refused, got %s", got)
+	}
+	if got := run("UADMIN", "saved run p1"); !strings.Contains(got, "*1 tickets match*") || !strings.Contains(got, "VPN down") {
+		t.Errorf("Expected the saved search to be run, got %s", got)
+	}
+	if got := run("UADMIN", "saved subscribe p1"); !strings.Contains(got, "at most every 1h0m0s") {
+		t.Errorf("Expected the default interval, got %s", got)
+	}
+	if got := run("UADMIN", "saved subscribe p1 30m"); !strings.Contains(got, "at most every 30m0s") {
+		t.Errorf("Expected the interval given, got %s", got)
+	}
+	if got := run("UADMIN", "saved subscribe p1 often"); !strings.Contains(got, "often is not how often") {
+		t.Errorf("Expected an invalid interval to be refused, got %s", got)
+	}
+	if got := run("UADMIN", "saved"); !strings.Contains(got, "• *p1* `status:open priority:P1`, new tickets sent at most every 30m0s") {
+		t.Errorf("Expected the search to be listed, got %s", got)
+	}
+	if got := run("UADMIN", "saved unsubscribe p1"); !strings.Contains(got, "no longer be sent") || len(searches.Subscriptions()) != 0 {
+		t.Errorf("Expected the subscription to end, got %s", got)
+	}
+	if got := run("UADMIN", "saved remove p1"); !strings.Contains(got, "Removed your saved search p1") || len(searches.Of("UADMIN")) != 0 {
+		t.Errorf("Expected the search to be removed, got %s", got)
+	}
+	if got := run("UADMIN", "saved run p1"); !strings.Contains(got, "You have no saved search called p1") {
+		t.Errorf("Expected the removed search to be gone, got %s", got)
+	}
+	if got := run("U1", "saved add mine tag:vpn"); !strings.Contains(got, "Sorry, only agents") {
+		t.Errorf("Expected others not to save searches, got %s", got)
+	}
+}
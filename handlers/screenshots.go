@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/nlopes/slack/slackevents"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/skybet/go-helpdesk/ocr"
+	"github.com/skybet/go-helpdesk/store"
+)
+
+// maxScreenshot is the largest image read, bigger ones are unlikely to be
+// screenshots
+const maxScreenshot = 10 << 20
+
+var recognizer ocr.Recognizer
+
+// InitOCR sets the recognizer which reads the text in screenshots attached to
+// tickets, the text is added to the ticket's description so it can be
+// searched. Screenshots are left alone without one.
+func InitOCR(r ocr.Recognizer) {
+	recognizer = r
+}
+
+// screenshotText returns the text recognised in the images among files,
+// quoted under each image's name
+func screenshotText(ctx context.Context, files []slackevents.File) string {
+	if recognizer == nil {
+		return ""
+	}
+	var sections []string
+	for _, f := range files {
+		if !strings.HasPrefix(f.Mimetype, "image/") || f.Size > maxScreenshot {
+			continue
+		}
+		var buf bytes.Buffer
+		if err := slackIn(ctx).DownloadFile(f.URLPrivateDownload, &buf); err != nil {
+			log.Errorf("Failed to download screenshot %s: %s", f.ID, err)
+			continue
+		}
+		text, err := recognizer.Recognize(ctx, &buf, f.Mimetype)
+		if err != nil {
+			log.Errorf("Failed to recognise the text in screenshot %s: %s", f.ID, err)
+			continue
+		}
+		if text == "" {
+			continue
+		}
+		sections = append(sections, fmt.Sprintf("Text in %s:\n> %s", f.Name, strings.Replace(text, "\n", "\n> ", -1)))
+	}
+	return strings.Join(sections, "\n\n")
+}
+
+// screenshotReply adds the text in screenshots posted in a ticket's thread to
+// its description
+func screenshotReply(ctx context.Context, ev *slackevents.MessageEvent) error {
+	if recognizer == nil || tickets == nil || ev.SubType != "file_share" || ev.BotID != "" || ev.ThreadTimeStamp == "" || ev.ThreadTimeStamp == ev.TimeStamp {
+		return nil
+	}
+	found, _, err := tickets.ListTickets(ctx, store.Filter{ChannelID: ev.Channel, ThreadTS: ev.ThreadTimeStamp, Limit: 1})
+	if err != nil {
+		return fmt.Errorf("Failed to find the ticket in thread %s: %s", ev.ThreadTimeStamp, err)
+	}
+	if len(found) == 0 {
+		return nil
+	}
+	text := screenshotText(ctx, ev.Files)
+	if text == "" {
+		return nil
+	}
+	id := found[0].ID
+	err = tickets.Tx(ctx, func(tx store.Store) error {
+		t, err := tx.GetTicket(ctx, id)
+		if err != nil {
+			return err
+		}
+		t.Description = strings.TrimSpace(t.Description + "\n\n" + text)
+		return tx.UpdateTicket(ctx, t)
+	})
+	if err != nil {
+		return fmt.Errorf("Failed to add screenshot text to ticket %s: %s", id, err)
+	}
+	return nil
+}
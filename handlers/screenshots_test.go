@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	"github.com/nlopes/slack/slackevents"
+
+	"github.com/skybet/go-helpdesk/intake"
+	"github.com/skybet/go-helpdesk/mocks"
+	"github.com/skybet/go-helpdesk/store"
+)
+
+// fakeRecognizer "recognises" the text of an image as its content
+type fakeRecognizer struct{}
+
+func (fakeRecognizer) Recognize(ctx context.Context, image io.Reader, mimetype string) (string, error) {
+	b, err := ioutil.ReadAll(image)
+	return string(b), err
+}
+
+func TestScreenshots(t *testing.T) {
+	mockSlack := mocks.NewSlack(t)
+	mockSlack.ExpectDownloadFile().Named("https://files/F1").ReturnContent("Error 0x80070005\nAccess is denied")
+	mockSlack.ExpectAddReaction().Named(TrackingReaction)
+	mockSlack.ExpectPostMessage().ToChannel("C1").WithText("Ticket #1 created: VPN will not connect")
+	mockSlack.ExpectDownloadFile().Named("https://files/F3").ReturnContent("Certificate expired")
+	Init(mockSlack)
+	s := store.NewMemory()
+	InitTickets(s)
+	InitTriggers(intake.NewWatcher([]string{"C1"}, intake.Prefix("help:")))
+	defer InitTriggers(nil)
+	InitOCR(fakeRecognizer{})
+	defer InitOCR(nil)
+
+	for _, ev := range []*slackevents.MessageEvent{
+		{Channel: "C1", SubType: "file_share", User: "U1", Text: "help: VPN will not connect", TimeStamp: "1.1", Files: []slackevents.File{
+			{ID: "F1", Name: "vpn.png", Mimetype: "image/png", URLPrivateDownload: "https://files/F1"},
+			{ID: "F2", Name: "log.txt", Mimetype: "text/plain", URLPrivateDownload: "https://files/F2"},
+		}},
+		{Channel: "C1", SubType: "file_share", User: "U1", ThreadTimeStamp: "1.1", TimeStamp: "1.3", Files: []slackevents.File{
+			{ID: "F3", Name: "cert.jpg", Mimetype: "image/jpeg", URLPrivateDownload: "https://files/F3"},
+		}},
+	} {
+		req, res, _ := newTestRequest()
+		event := &slackevents.EventsAPIEvent{InnerEvent: slackevents.EventsAPIInnerEvent{Type: "message", Data: ev}}
+		if err := Message(res, req, event); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+	}
+	tk, err := s.GetTicket(context.Background(), "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "VPN will not connect\n\nText in vpn.png:\n> Error 0x80070005\n> Access is denied\n\nText in cert.jpg:\n> Certificate expired"
+	if tk.Title != "VPN will not connect" || tk.Description != want {
+		t.Errorf("Expected the screenshots' text in the description, got %q", tk.Description)
+	}
+	found, _, _ := s.ListTickets(context.Background(), store.Filter{Text: "access is denied"})
+	if len(found) != 1 {
+		t.Errorf("Expected the ticket to be found by its screenshot's text, got %+v", found)
+	}
+}
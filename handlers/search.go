@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/nlopes/slack"
+
+	"github.com/skybet/go-helpdesk/policy"
+	"github.com/skybet/go-helpdesk/query"
+	"github.com/skybet/go-helpdesk/server"
+	"github.com/skybet/go-helpdesk/store"
+)
+
+// searchLimit is the most tickets /hd search lists
+const searchLimit = 20
+
+// Search handles /hd search <query>, listing the tickets matching a query
+// such as status:open tag:vpn -assignee:@me. Only those allowed to search
+// every ticket see other people's, everyone else searches the tickets they
+// reported.
+func Search(res *server.Response, req *server.Request, ctx interface{}) error {
+	sc, ok := ctx.(slack.SlashCommand)
+	if !ok {
+		return fmt.Errorf("Expected a slack.SlashCommand to be passed to the handler")
+	}
+	if tickets == nil {
+		return fmt.Errorf("Tickets have not been initialised")
+	}
+	args := strings.Fields(sc.Text)
+	if len(args) < 2 {
+		res.Text(http.StatusOK, tr(sc, "Usage: %s search <query>, e.g. status:open priority>=P2 tag:vpn created<7d -assignee:@me", sc.Command))
+		return nil
+	}
+	f, err := query.Parse(strings.Join(args[1:], " "), sc.UserID, clk.Now())
+	if err != nil {
+		res.Text(http.StatusOK, tr(sc, "That query could not be understood: %s", err))
+		return nil
+	}
+	return listMatching(res, req, sc, f)
+}
+
+// listMatching answers a command with the tickets matching f, only the
+// user's own unless they are allowed to search every ticket
+func listMatching(res *server.Response, req *server.Request, sc slack.SlashCommand, f store.Filter) error {
+	if !allowed(req.Context(), sc.UserID, policy.Search) {
+		f.Reporter = sc.UserID
+	}
+	f.Limit = searchLimit + 1
+	found, _, err := tickets.ListTickets(req.Context(), f)
+	if err != nil {
+		return fmt.Errorf("Failed to search tickets: %s", err)
+	}
+	if len(found) == 0 {
+		res.Text(http.StatusOK, tr(sc, "No tickets match"))
+		return nil
+	}
+	header := tr(sc, "*%d tickets match*", len(found))
+	if len(found) > searchLimit {
+		header = tr(sc, "*The first %d matching tickets*", searchLimit)
+		found = found[:searchLimit]
+	}
+	lines := []string{header}
+	for _, t := range found {
+		line := fmt.Sprintf("• %s %s: %s", ticketLinks.Ref(t.ID), t.Title, t.Status)
+		if t.Priority != 0 {
+			line += ", " + t.Priority.String()
+		}
+		if t.Assignee != "" {
+			line += tr(sc, ", assigned to <@%s>", t.Assignee)
+		}
+		lines = append(lines, line)
+	}
+	return res.JSON(http.StatusOK, slack.Msg{ResponseType: slack.ResponseTypeEphemeral, Text: strings.Join(lines, "\n")})
+}
+
+// commandFilter returns the filter of the tickets a bulk command such as
+// /hd export is run on from its arguments, a queue name or a query
+func commandFilter(args []string, user string) (store.Filter, error) {
+	if len(args) == 0 {
+		return store.Filter{}, nil
+	}
+	if len(args) == 1 && !strings.ContainsAny(args[0], `:<>="`) && !strings.HasPrefix(args[0], "-") {
+		return store.Filter{Queue: args[0]}, nil
+	}
+	return query.Parse(strings.Join(args, " "), user, clk.Now())
+}
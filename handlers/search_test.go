@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/nlopes/slack"
+
+	"github.com/skybet/go-helpdesk/store"
+	"github.com/skybet/go-helpdesk/ticket"
+)
+
+func TestSearch(t *testing.T) {
+	s := store.NewMemory()
+	s.CreateTicket(context.Background(), &ticket.Ticket{Title: "VPN down", Queue: "it", Reporter: "U1", Priority: ticket.P1, Tags: []string{"vpn"}})
+	s.CreateTicket(context.Background(), &ticket.Ticket{Title: "VPN slow", Queue: "it", Reporter: "U2", Priority: ticket.P3, Tags: []string{"vpn"}})
+	s.CreateTicket(context.Background(), &ticket.Ticket{Title: "VPN token", Queue: "it", Reporter: "U2", Assignee: "UADMIN", Priority: ticket.P2, Tags: []string{"vpn"}})
+	InitTickets(s)
+	InitAnnouncements(nil, []string{"UADMIN"})
+	defer InitAnnouncements(nil, nil)
+
+	search := func(user, text string) string {
+		req, res, w := newTestRequest()
+		if err := Helpdesk(res, req, slack.SlashCommand{Command: "/hd", Text: text, UserID: user}); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		return w.Body.String()
+	}
+	if got := search("UADMIN", "search status:open priority>=P2 tag:vpn -assignee:@me"); !strings.Contains(got, `*1 tickets match*\n• #1 VPN down: new, P1`) || strings.Contains(got, "VPN token") {
+		t.Errorf("Expected an admin to find the urgent ticket not assigned to them, got %s", got)
+	}
+	if got := search("U2", "search tag:vpn"); !strings.Contains(got, "*2 tickets match*") || strings.Contains(got, "VPN down") {
+		t.Errorf("Expected others to only find the tickets they reported, got %s", got)
+	}
+	if got := search("U1", "search colour:red"); !strings.Contains(got, "That query could not be understood: unknown field") {
+		t.Errorf("Expected the query to be refused, got %s", got)
+	}
+	if got := search("U1", "search"); !strings.Contains(got, "Usage: /hd search <query>") {
+		t.Errorf("Expected the usage, got %s", got)
+	}
+}
+
+func TestCommandFilter(t *testing.T) {
+	if f, err := commandFilter([]string{"support"}, "U1"); err != nil || f.Queue != "support" {
+		t.Errorf("Expected a single word to be a queue, got %+v, %v", f, err)
+	}
+	if f, err := commandFilter([]string{"queue:support", "-assignee:@me"}, "U1"); err != nil || f.Queue != "support" || len(f.Exclude) != 1 || f.Exclude[0].Assignee != "U1" {
+		t.Errorf("Expected a query, got %+v, %v", f, err)
+	}
+	if _, err := commandFilter([]string{"priority:P9"}, "U1"); err == nil {
+		t.Error("Expected an invalid query to be refused")
+	}
+}
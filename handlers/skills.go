@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/nlopes/slack"
+
+	"github.com/skybet/go-helpdesk/assign"
+	"github.com/skybet/go-helpdesk/blocks"
+	"github.com/skybet/go-helpdesk/server"
+)
+
+// SkillActionID is the action ID of the skill buttons on the App Home tab,
+// their values are the skills they add or remove
+const SkillActionID = "home_skill"
+
+// maxSkills is the most skills offered on the App Home tab, which holds at
+// most 100 blocks
+const maxSkills = 50
+
+var skills *assign.Skills
+
+// InitSkills sets the skills agents can declare on their App Home tab, the
+// tab has no skills section without them
+func InitSkills(s *assign.Skills) {
+	skills = s
+}
+
+// Skill handles the skill buttons on the App Home tab, adding the skill to the
+// agent or removing it
+func Skill(res *server.Response, req *server.Request, ctx interface{}) error {
+	ic, ok := ctx.(*slack.InteractionCallback)
+	if !ok {
+		return fmt.Errorf("Expected a *slack.InteractionCallback to be passed to the handler")
+	}
+	if skills == nil {
+		return fmt.Errorf("Skills have not been initialised")
+	}
+	action, err := blocks.ActionOf(ic)
+	if err != nil {
+		return err
+	}
+	if _, err := skills.Toggle(ic.User.ID, action.Value); err != nil {
+		return fmt.Errorf("Failed to change the skills of %s: %s", ic.User.ID, err)
+	}
+	return publishHome(ic.User.ID)
+}
+
+// skillsSection renders the skills offered with a button to add or remove
+// each of them
+func skillsSection(user string) []slack.Block {
+	text := "*Skills*\nTickets tagged with your skills are offered to you before others on call for their queue."
+	sections := []slack.Block{slack.NewSectionBlock(blocks.Markdown(text), nil, nil)}
+	mine := map[string]bool{}
+	for _, s := range skills.Of(user) {
+		mine[s] = true
+	}
+	for i, skill := range skills.Offered {
+		if i == maxSkills {
+			break
+		}
+		text, button := skill, "Add"
+		if mine[skill] {
+			text, button = ":white_check_mark: *"+skill+"*", "Remove"
+		}
+		sections = append(sections, slack.NewSectionBlock(blocks.Markdown(text), nil, slack.NewAccessory(blocks.Button(SkillActionID, skill, button))))
+	}
+	return sections
+}
+
+// Skills handles /hd skills, reporting how many agents have each skill
+// against the open tickets needing it, least covered first, to find the gaps
+func Skills(res *server.Response, req *server.Request, ctx interface{}) error {
+	sc, ok := ctx.(slack.SlashCommand)
+	if !ok {
+		return fmt.Errorf("Expected a slack.SlashCommand to be passed to the handler")
+	}
+	if skills == nil || len(skills.Offered) == 0 {
+		res.Text(http.StatusOK, tr(sc, "No skills have been set up"))
+		return nil
+	}
+	coverage, err := skills.Coverage(req.Context(), tickets)
+	if err != nil {
+		return fmt.Errorf("Failed to build skill coverage report: %s", err)
+	}
+	lines := []string{tr(sc, "*Skill coverage*, least covered first")}
+	gaps := 0
+	for _, c := range coverage {
+		var agents []string
+		for _, a := range c.Agents {
+			agents = append(agents, fmt.Sprintf("<@%s>", a))
+		}
+		switch {
+		case c.Gap():
+			gaps++
+			lines = append(lines, tr(sc, "• :warning: %s: nobody, %d open tickets", c.Skill, c.Open))
+		case len(agents) == 0:
+			lines = append(lines, tr(sc, "• %s: nobody, %d open tickets", c.Skill, c.Open))
+		default:
+			lines = append(lines, tr(sc, "• %s: %s, %d open tickets", c.Skill, strings.Join(agents, ", "), c.Open))
+		}
+	}
+	if gaps > 0 {
+		lines = append(lines, tr(sc, "%d skills needed by open tickets have nobody to take them", gaps))
+	}
+	res.Text(http.StatusOK, strings.Join(lines, "\n"))
+	return nil
+}
@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/nlopes/slack"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/skybet/go-helpdesk/assign"
+	"github.com/skybet/go-helpdesk/mocks"
+	"github.com/skybet/go-helpdesk/store"
+	"github.com/skybet/go-helpdesk/ticket"
+	"github.com/skybet/go-helpdesk/views"
+)
+
+func TestSkills(t *testing.T) {
+	mockSlack := &mocks.SlackWrapper{}
+	var published []*views.View
+	mockSlack.On("PublishView", "U1", mock.Anything).Run(func(args mock.Arguments) {
+		published = append(published, args.Get(1).(*views.View))
+	}).Return(&views.View{}, nil)
+	Init(mockSlack)
+	InitNotifier(nil)
+	s := store.NewMemory()
+	s.CreateTicket(context.Background(), &ticket.Ticket{Title: "VPN down", Queue: "it", Tags: []string{"vpn"}})
+	s.CreateTicket(context.Background(), &ticket.Ticket{Title: "Invoice", Queue: "it", Tags: []string{"billing"}})
+	InitTickets(s)
+	InitSkills(assign.NewSkills([]string{"vpn", "billing"}))
+	defer InitSkills(nil)
+
+	req, res, _ := newTestRequest()
+	ic := &slack.InteractionCallback{Type: slack.InteractionTypeBlockActions}
+	ic.User.ID = "U1"
+	ic.ActionCallback.BlockActions = []*slack.BlockAction{{ActionID: SkillActionID, Value: "vpn"}}
+	if err := Skill(res, req, ic); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if got := skills.Of("U1"); len(got) != 1 || got[0] != "vpn" {
+		t.Errorf("Expected U1 to have the vpn skill, got %v", got)
+	}
+	if len(published) != 1 {
+		t.Fatalf("Expected the home tab to be published, got %d", len(published))
+	}
+	body, _ := json.Marshal(published[0])
+	if !strings.Contains(string(body), `:white_check_mark: *vpn*`) || !strings.Contains(string(body), `"text":"Remove"},"action_id":"home_skill","value":"vpn"`) || !strings.Contains(string(body), `"text":"Add"},"action_id":"home_skill","value":"billing"`) {
+		t.Errorf("Expected vpn to be removable and billing to be added, got %s", body)
+	}
+
+	req, res, w := newTestRequest()
+	if err := Helpdesk(res, req, slack.SlashCommand{Command: "/hd", Text: "skills", UserID: "U1"}); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	got := w.Body.String()
+	if !strings.Contains(got, "• :warning: billing: nobody, 1 open tickets\n• vpn: <@U1>, 1 open tickets") || !strings.Contains(got, "1 skills needed by open tickets have nobody to take them") {
+		t.Errorf("Expected billing to be a gap, got %s", got)
+	}
+}
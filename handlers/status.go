@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/nlopes/slack"
+
+	"github.com/skybet/go-helpdesk/intake"
+	"github.com/skybet/go-helpdesk/server"
+	"github.com/skybet/go-helpdesk/sla"
+	"github.com/skybet/go-helpdesk/store"
+	"github.com/skybet/go-helpdesk/ticket"
+	"github.com/skybet/go-helpdesk/wip"
+)
+
+// statusLimit is the most open tickets /hd status lists
+const statusLimit = 20
+
+var serviceLevels sla.SLA
+
+// InitSLA sets the targets reporters are told to expect a response within
+func InitSLA(s sla.SLA) {
+	serviceLevels = s
+}
+
+// Status handles /hd status [ticket], showing the reporter the card of one of
+// their tickets along with when to expect a response. Without a ticket it
+// lists their open tickets.
+func Status(res *server.Response, req *server.Request, ctx interface{}) error {
+	sc, ok := ctx.(slack.SlashCommand)
+	if !ok {
+		return fmt.Errorf("Expected a slack.SlashCommand to be passed to the handler")
+	}
+	if tickets == nil {
+		return fmt.Errorf("Tickets have not been initialised")
+	}
+	args := strings.Fields(sc.Text)
+	if len(args) > 2 {
+		res.Text(http.StatusOK, tr(sc, "Usage: %s status [ticket]", sc.Command))
+		return nil
+	}
+	if len(args) == 1 {
+		return openTickets(req.Context(), res, sc)
+	}
+
+	id := strings.TrimPrefix(args[1], "#")
+	t, err := tickets.GetTicket(req.Context(), id)
+	if err != nil && err != store.ErrNotFound {
+		return fmt.Errorf("Failed to get ticket: %s", err)
+	}
+	// Tickets reported by someone else are not shown, the card is only meant
+	// for its reporter
+	if err == store.ErrNotFound || t.Reporter != sc.UserID || t.Deleted() {
+		res.Text(http.StatusOK, tr(sc, "You have not reported a ticket #%s", id))
+		return nil
+	}
+	blocks := ticketCard(t, statusAudience(req.Context(), sc))
+	if text := expectedResponse(sc, t, clk.Now()); text != "" {
+		blocks = append(blocks, slack.NewContextBlock("", slack.NewTextBlockObject(slack.MarkdownType, text, false, false)))
+	}
+	return res.JSON(http.StatusOK, slack.Msg{
+		ResponseType: slack.ResponseTypeEphemeral,
+		Text:         tr(sc, "Ticket #%s is %s", t.ID, t.Status),
+		Blocks:       slack.Blocks{BlockSet: blocks},
+	})
+}
+
+// openTickets replies with a line for each of the user's open tickets
+func openTickets(ctx context.Context, res *server.Response, sc slack.SlashCommand) error {
+	open, _, err := tickets.ListTickets(ctx, store.Filter{Reporter: sc.UserID, Status: wip.OpenStatuses, Limit: statusLimit})
+	if err != nil {
+		return fmt.Errorf("Failed to list tickets: %s", err)
+	}
+	if len(open) == 0 {
+		res.Text(http.StatusOK, tr(sc, "You have no open tickets"))
+		return nil
+	}
+	style, a := styles.Style(sc.UserID), statusAudience(ctx, sc)
+	lines := []string{tr(sc, "*Your open tickets*")}
+	for _, t := range open {
+		line := strings.TrimSpace(fmt.Sprintf("• %s %s %s: %s", taxonomy.Status(t.Status).In(style), ticketLinks.Ref(t.ID), t.Title, t.Status))
+		if a == referenceCard {
+			line = strings.TrimSpace(fmt.Sprintf("• %s %s: %s", taxonomy.Status(t.Status).In(style), ticketLinks.Ref(t.ID), t.Status))
+		}
+		if t.Assignee != "" && a == reporterCard {
+			line += tr(sc, ", assigned to <@%s>", t.Assignee)
+		}
+		lines = append(lines, line)
+	}
+	lines = append(lines, tr(sc, "Use %s status <ticket> for more detail.", sc.Command))
+	return res.JSON(http.StatusOK, slack.Msg{ResponseType: slack.ResponseTypeEphemeral, Text: strings.Join(lines, "\n")})
+}
+
+// statusAudience returns the card audience for the user running a command,
+// guests and external users see the public card unless their organisation is
+// only shown references
+func statusAudience(ctx context.Context, sc slack.SlashCommand) cardAudience {
+	if directory == nil {
+		return reporterCard
+	}
+	u, err := directory.User(ctx, sc.UserID)
+	if err != nil {
+		return publicCard
+	}
+	switch intake.Classify(u, sc.TeamID) {
+	case intake.Member:
+		return reporterCard
+	case intake.External:
+		if org, ok := orgFor(u); ok && org.Share == intake.ShareReference {
+			return referenceCard
+		}
+	}
+	return publicCard
+}
+
+// expectedResponse tells the reporter when the ticket was or should be
+// responded to, empty if there is no response target for it
+func expectedResponse(sc slack.SlashCommand, t *ticket.Ticket, now time.Time) string {
+	if !t.FirstResponseAt.IsZero() {
+		return tr(sc, "First response %s", slackDate(t.FirstResponseAt))
+	}
+	if !t.Status.Open() {
+		return ""
+	}
+	target, ok := serviceLevels.For(t.Queue).Response.For(t.Priority)
+	if !ok {
+		return ""
+	}
+	if due := t.CreatedAt.Add(target); due.After(now) {
+		return tr(sc, "Expect a response by %s", slackDate(due))
+	}
+	return tr(sc, "A response was expected by %s, sorry for the wait", slackDate(t.CreatedAt.Add(target)))
+}
+
+// slackDate formats t so that Slack shows it in the reader's time zone
+func slackDate(t time.Time) string {
+	return fmt.Sprintf("<!date^%d^{date_short_pretty} at {time}|%s>", t.Unix(), t.UTC().Format("2 Jan 2006 15:04 MST"))
+}
@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nlopes/slack"
+
+	"github.com/skybet/go-helpdesk/intake"
+	"github.com/skybet/go-helpdesk/sla"
+	"github.com/skybet/go-helpdesk/store"
+	"github.com/skybet/go-helpdesk/ticket"
+)
+
+func TestStatus(t *testing.T) {
+	s := store.NewMemory()
+	s.CreateTicket(context.Background(), &ticket.Ticket{ID: "1", Title: "VPN down", Reporter: "U1", Assignee: "U9", Priority: ticket.P1, Tags: []string{intake.VIPTag}})
+	s.CreateTicket(context.Background(), &ticket.Ticket{ID: "2", Title: "Printer", Reporter: "U1", Status: ticket.StatusInProgress})
+	s.CreateTicket(context.Background(), &ticket.Ticket{ID: "3", Title: "Old laptop", Reporter: "U1", Status: ticket.StatusClosed})
+	s.CreateTicket(context.Background(), &ticket.Ticket{ID: "4", Title: "Payroll", Reporter: "U2"})
+	InitTickets(s)
+	InitSLA(sla.SLA{Response: sla.Targets{ticket.P1: time.Hour}})
+	defer InitSLA(sla.SLA{})
+
+	req, res, w := newTestRequest()
+	if err := Helpdesk(res, req, slack.SlashCommand{Command: "/hd", Text: "status #1", UserID: "U1"}); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	body := w.Body.String()
+	if !strings.Contains(body, `"response_type":"ephemeral"`) || !strings.Contains(body, "VPN down") || !strings.Contains(body, "@U9") || !strings.Contains(body, "Expect a response by \\u003c!date^") {
+		t.Errorf("Expected an ephemeral card with the assignee and expected response, got %s", body)
+	}
+	if strings.Contains(body, "VIP") {
+		t.Errorf("Expected VIP status to be hidden from the reporter, got %s", body)
+	}
+
+	req, res, w = newTestRequest()
+	Helpdesk(res, req, slack.SlashCommand{Command: "/hd", Text: "status 4", UserID: "U1"})
+	if body := w.Body.String(); strings.Contains(body, "Payroll") || !strings.Contains(body, "You have not reported a ticket #4") {
+		t.Errorf("Expected other people's tickets to be hidden, got %s", body)
+	}
+
+	req, res, w = newTestRequest()
+	Helpdesk(res, req, slack.SlashCommand{Command: "/hd", Text: "status", UserID: "U1"})
+	body = w.Body.String()
+	if !strings.Contains(body, "VPN down") || !strings.Contains(body, "Printer") || strings.Contains(body, "Old laptop") || strings.Contains(body, "Payroll") {
+		t.Errorf("Expected the user's open tickets to be listed, got %s", body)
+	}
+}
+
+func TestExpectedResponse(t *testing.T) {
+	created := time.Date(2019, 3, 4, 9, 0, 0, 0, time.UTC)
+	InitSLA(sla.SLA{Response: sla.Targets{0: time.Hour}})
+	defer InitSLA(sla.SLA{})
+	sc := slack.SlashCommand{}
+	tk := &ticket.Ticket{Status: ticket.StatusNew, CreatedAt: created}
+
+	if text := expectedResponse(sc, tk, created.Add(2*time.Hour)); !strings.HasPrefix(text, "A response was expected by <!date^1551693600^") {
+		t.Errorf("Expected the response to be late, got %s", text)
+	}
+	tk.FirstResponseAt = created.Add(10 * time.Minute)
+	if text := expectedResponse(sc, tk, created.Add(2*time.Hour)); !strings.Contains(text, "First response <!date^1551690600^") {
+		t.Errorf("Expected the first response to be shown, got %s", text)
+	}
+}
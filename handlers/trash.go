@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/nlopes/slack"
+
+	"github.com/skybet/go-helpdesk/policy"
+	"github.com/skybet/go-helpdesk/server"
+	"github.com/skybet/go-helpdesk/store"
+	"github.com/skybet/go-helpdesk/trash"
+)
+
+// trashLimit is the most tickets /hd trash lists
+const trashLimit = 20
+
+var purger = &trash.Purger{}
+
+// InitTrash sets the purger which decides how long deleted tickets can be
+// restored for
+func InitTrash(p *trash.Purger) {
+	purger = p
+}
+
+// Delete handles /hd delete <ticket>, moving the ticket to the trash. Only
+// admins can delete tickets.
+func Delete(res *server.Response, req *server.Request, ctx interface{}) error {
+	sc, ok := ctx.(slack.SlashCommand)
+	if !ok {
+		return fmt.Errorf("Expected a slack.SlashCommand to be passed to the handler")
+	}
+	args := strings.Fields(sc.Text)
+	if !allowed(req.Context(), sc.UserID, policy.Delete) {
+		res.Text(http.StatusOK, tr(sc, "Sorry, only helpdesk admins can delete tickets"))
+		return nil
+	}
+	if len(args) != 2 {
+		res.Text(http.StatusOK, tr(sc, "Usage: %s delete <ticket>", sc.Command))
+		return nil
+	}
+	id := strings.TrimPrefix(args[1], "#")
+	t, err := trash.Delete(req.Context(), tickets, id, sc.UserID, clk.Now())
+	if err == store.ErrNotFound {
+		res.Text(http.StatusOK, tr(sc, "There is no ticket #%s", id))
+		return nil
+	}
+	if err != nil {
+		res.Text(http.StatusOK, tr(sc, "Ticket #%s could not be deleted: %s", id, err))
+		return nil
+	}
+	res.Text(http.StatusOK, tr(sc, "Ticket #%s is in the trash, use %s restore %s to get it back before it is purged %s", t.ID, sc.Command, t.ID, slackDate(purger.PurgeAt(t))))
+	return nil
+}
+
+// Restore handles /hd restore <ticket>, taking the ticket out of the trash
+func Restore(res *server.Response, req *server.Request, ctx interface{}) error {
+	sc, ok := ctx.(slack.SlashCommand)
+	if !ok {
+		return fmt.Errorf("Expected a slack.SlashCommand to be passed to the handler")
+	}
+	args := strings.Fields(sc.Text)
+	if !allowed(req.Context(), sc.UserID, policy.Restore) {
+		res.Text(http.StatusOK, tr(sc, "Sorry, only helpdesk admins can restore tickets"))
+		return nil
+	}
+	if len(args) != 2 {
+		res.Text(http.StatusOK, tr(sc, "Usage: %s restore <ticket>", sc.Command))
+		return nil
+	}
+	id := strings.TrimPrefix(args[1], "#")
+	t, err := trash.Restore(req.Context(), tickets, id)
+	if err == store.ErrNotFound {
+		res.Text(http.StatusOK, tr(sc, "There is no ticket #%s", id))
+		return nil
+	}
+	if err != nil {
+		res.Text(http.StatusOK, tr(sc, "Ticket #%s could not be restored: %s", id, err))
+		return nil
+	}
+	syncShares(t)
+	res.Text(http.StatusOK, tr(sc, "Ticket %s has been restored", ticketLinks.Ref(t.ID)))
+	return nil
+}
+
+// Trash handles /hd trash, listing the tickets in the trash with when they
+// will be purged
+func Trash(res *server.Response, req *server.Request, ctx interface{}) error {
+	sc, ok := ctx.(slack.SlashCommand)
+	if !ok {
+		return fmt.Errorf("Expected a slack.SlashCommand to be passed to the handler")
+	}
+	if !allowed(req.Context(), sc.UserID, policy.ViewTrash) {
+		res.Text(http.StatusOK, tr(sc, "Sorry, only helpdesk admins can see the trash"))
+		return nil
+	}
+	deleted, err := trash.List(req.Context(), tickets)
+	if err != nil {
+		return fmt.Errorf("Failed to list the trash: %s", err)
+	}
+	if len(deleted) == 0 {
+		res.Text(http.StatusOK, tr(sc, "The trash is empty"))
+		return nil
+	}
+	lines := []string{tr(sc, "*%d tickets in the trash*", len(deleted))}
+	for i, t := range deleted {
+		if i == trashLimit {
+			lines = append(lines, tr(sc, "and %d more", len(deleted)-trashLimit))
+			break
+		}
+		lines = append(lines, tr(sc, "• #%s %s, deleted by <@%s>, purged %s", t.ID, t.Title, t.DeletedBy, slackDate(purger.PurgeAt(t))))
+	}
+	return res.JSON(http.StatusOK, slack.Msg{ResponseType: slack.ResponseTypeEphemeral, Text: strings.Join(lines, "\n")})
+}
@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nlopes/slack"
+
+	"github.com/skybet/go-helpdesk/store"
+	"github.com/skybet/go-helpdesk/ticket"
+	"github.com/skybet/go-helpdesk/trash"
+)
+
+func TestDeleteAndRestore(t *testing.T) {
+	s := store.NewMemory()
+	s.CreateTicket(context.Background(), &ticket.Ticket{ID: "1", Title: "VPN down", Reporter: "U1"})
+	InitTickets(s)
+	InitAnnouncements(nil, []string{"UADMIN"})
+	defer InitAnnouncements(nil, nil)
+	InitTrash(&trash.Purger{Store: s, Retention: 24 * time.Hour})
+	defer InitTrash(&trash.Purger{})
+
+	req, res, w := newTestRequest()
+	Helpdesk(res, req, slack.SlashCommand{Command: "/hd", Text: "delete 1", UserID: "U1"})
+	if body := w.Body.String(); !strings.Contains(body, "only helpdesk admins can delete") {
+		t.Errorf("Expected only admins to be able to delete tickets, got %s", body)
+	}
+
+	req, res, w = newTestRequest()
+	if err := Helpdesk(res, req, slack.SlashCommand{Command: "/hd", Text: "delete #1", UserID: "UADMIN"}); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if body := w.Body.String(); !strings.Contains(body, "/hd restore 1") {
+		t.Errorf("Expected to be told how to restore the ticket, got %s", body)
+	}
+	if tk, _ := s.GetTicket(context.Background(), "1"); tk.DeletedBy != "UADMIN" {
+		t.Errorf("Expected the ticket to be in the trash, got %+v", tk)
+	}
+
+	req, res, w = newTestRequest()
+	Helpdesk(res, req, slack.SlashCommand{Command: "/hd", Text: "status 1", UserID: "U1"})
+	if body := w.Body.String(); !strings.Contains(body, "You have not reported a ticket #1") {
+		t.Errorf("Expected tickets in the trash to be hidden from their reporter, got %s", body)
+	}
+
+	req, res, w = newTestRequest()
+	if err := Helpdesk(res, req, slack.SlashCommand{Command: "/hd", Text: "trash", UserID: "UADMIN"}); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if body := w.Body.String(); !strings.Contains(body, "#1 VPN down, deleted by \\u003c@UADMIN\\u003e") {
+		t.Errorf("Expected the ticket to be listed in the trash, got %s", body)
+	}
+
+	req, res, w = newTestRequest()
+	if err := Helpdesk(res, req, slack.SlashCommand{Command: "/hd", Text: "restore 1", UserID: "UADMIN"}); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if body := w.Body.String(); !strings.Contains(body, "Ticket #1 has been restored") {
+		t.Errorf("Expected the ticket to be restored, got %s", body)
+	}
+	if tk, _ := s.GetTicket(context.Background(), "1"); tk.Deleted() {
+		t.Errorf("Expected the ticket to be out of the trash")
+	}
+
+	req, res, w = newTestRequest()
+	Helpdesk(res, req, slack.SlashCommand{Command: "/hd", Text: "trash", UserID: "UADMIN"})
+	if body := w.Body.String(); !strings.Contains(body, "The trash is empty") {
+		t.Errorf("Expected the trash to be empty, got %s", body)
+	}
+}
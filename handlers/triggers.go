@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/nlopes/slack"
+	"github.com/nlopes/slack/slackevents"
+
+	"github.com/skybet/go-helpdesk/intake"
+)
+
+// TrackingReaction is added to messages which have been turned into tickets
+const TrackingReaction = "ticket"
+
+var triggers *intake.Watcher
+
+// InitTriggers sets the watcher which decides which messages seen by Message
+// automatically become tickets
+func InitTriggers(w *intake.Watcher) {
+	triggers = w
+}
+
+// ticketFromTrigger creates a ticket if a message matches a trigger, reacting to
+// the message and replying in its thread with the ticket card
+func ticketFromTrigger(ctx context.Context, teamID string, ev *slackevents.MessageEvent) error {
+	if triggers == nil || tickets == nil {
+		return nil
+	}
+	text, ok := triggers.Match(ev)
+	if !ok {
+		return nil
+	}
+	if recognised := screenshotText(ctx, ev.Files); recognised != "" {
+		text += "\n\n" + recognised
+	}
+	t, a, err := createTicket(ctx, teamID, ev.Channel, ev.TimeStamp, ev.User, text, attach(ev.Files))
+	if err != nil {
+		return err
+	}
+	// The digest does not need to chase a question which is now a ticket
+	if questions != nil {
+		questions.Resolve(ev.Channel, ev.TimeStamp)
+	}
+	if err := slackIn(ctx).AddReaction(TrackingReaction, slack.NewRefToMessage(ev.Channel, ev.TimeStamp)); err != nil {
+		return fmt.Errorf("Failed to react to message: %s", err)
+	}
+	summary := fmt.Sprintf("Ticket #%s created: %s", t.ID, t.Title)
+	if a == referenceCard {
+		summary = fmt.Sprintf("Ticket #%s created", t.ID)
+	}
+	if _, _, err := slackIn(ctx).PostMessage(ev.Channel, append(cardMessage(t, a, echo(summary, a)), slack.MsgOptionTS(ev.TimeStamp))...); err != nil {
+		return fmt.Errorf("Failed to reply with ticket: %s", err)
+	}
+	return nil
+}
@@ -0,0 +1,42 @@
+package handlers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/nlopes/slack"
+	"github.com/nlopes/slack/slackevents"
+	"github.com/skybet/go-helpdesk/intake"
+	"github.com/skybet/go-helpdesk/mocks"
+	"github.com/skybet/go-helpdesk/store"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestTriggeredTicket(t *testing.T) {
+	mockSlack := &mocks.SlackWrapper{}
+	mockSlack.On("AddReaction", TrackingReaction, slack.NewRefToMessage("C1", "1.1")).Return(nil)
+	mockSlack.On("PostMessage", "C1", mock.Anything, mock.Anything, mock.Anything).Return("C1", "1.2", nil)
+	Init(mockSlack)
+	s := store.NewMemory()
+	InitTickets(s)
+	InitTriggers(intake.NewWatcher([]string{"C1"}, intake.Prefix("help:")))
+	defer InitTriggers(nil)
+	req, res, _ := newTestRequest()
+
+	for _, text := range []string{"help: printer is jammed", "the printer is fine now"} {
+		event := &slackevents.EventsAPIEvent{InnerEvent: slackevents.EventsAPIInnerEvent{
+			Type: "message",
+			Data: &slackevents.MessageEvent{Channel: "C1", User: "U1", Text: text, TimeStamp: "1.1"},
+		}}
+		if err := Message(res, req, event); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+	}
+	mockSlack.AssertExpectations(t)
+	mockSlack.AssertNumberOfCalls(t, "PostMessage", 1)
+
+	tickets, _, _ := s.ListTickets(context.Background(), store.Filter{})
+	if len(tickets) != 1 || tickets[0].Title != "printer is jammed" || tickets[0].ChannelID != "C1" {
+		t.Fatalf("Expected a ticket for the triggered message, got %+v", tickets)
+	}
+}
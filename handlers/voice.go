@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/nlopes/slack"
+	"github.com/nlopes/slack/slackevents"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/skybet/go-helpdesk/transcribe"
+)
+
+// maxVoiceNote is the largest voice note transcribed, about half an hour of
+// compressed audio
+const maxVoiceNote = 25 << 20
+
+var transcriber transcribe.Transcriber
+
+// InitTranscriber sets the transcriber of voice notes sent to the bot in a
+// DM, each becomes a ticket. Voice notes are ignored without one.
+func InitTranscriber(t transcribe.Transcriber) {
+	transcriber = t
+}
+
+// voiceNote creates a ticket from the transcript of audio shared in a DM with
+// the bot, it reports whether the message was one. The message's thread
+// becomes the ticket's thread, so the audio stays with the ticket.
+func voiceNote(ctx context.Context, teamID string, ev *slackevents.MessageEvent) (bool, error) {
+	if transcriber == nil || tickets == nil || ev.ChannelType != "im" || ev.BotID != "" || ev.SubType != "file_share" {
+		return false, nil
+	}
+	var audio *slackevents.File
+	for i, f := range ev.Files {
+		if strings.HasPrefix(f.Mimetype, "audio/") {
+			audio = &ev.Files[i]
+			break
+		}
+	}
+	if audio == nil {
+		return false, nil
+	}
+	if audio.Size > maxVoiceNote {
+		return true, replyToVoiceNote(ev, fmt.Sprintf("That voice note is too long to transcribe, please keep it under %dMB or type your request instead.", maxVoiceNote>>20))
+	}
+	var buf bytes.Buffer
+	if err := slackIn(ctx).DownloadFile(audio.URLPrivateDownload, &buf); err != nil {
+		return true, fmt.Errorf("Failed to download voice note %s: %s", audio.ID, err)
+	}
+	transcript, err := transcriber.Transcribe(ctx, &buf, audio.Mimetype)
+	if err != nil {
+		log.Errorf("Failed to transcribe voice note %s from %s: %s", audio.ID, ev.User, err)
+		return true, replyToVoiceNote(ev, "Sorry, I could not transcribe that voice note, please try again or type your request instead.")
+	}
+	if transcript == "" {
+		return true, replyToVoiceNote(ev, "I could not hear anything in that voice note, please try again or type your request instead.")
+	}
+	text := transcript
+	if caption := strings.TrimSpace(ev.Text); caption != "" {
+		text += "\n\n" + caption
+	}
+	if audio.Permalink != "" {
+		text += fmt.Sprintf("\n\nVoice note: %s", audio.Permalink)
+	}
+	t, a, err := createTicket(ctx, teamID, ev.Channel, ev.TimeStamp, ev.User, text)
+	if err != nil {
+		return true, err
+	}
+	summary := fmt.Sprintf("Ticket #%s created from your voice note: %s", t.ID, t.Title)
+	if _, _, err := slackIn(ctx).PostMessage(ev.Channel, append(cardMessage(t, a, summary), slack.MsgOptionTS(ev.TimeStamp))...); err != nil {
+		return true, fmt.Errorf("Failed to reply with ticket: %s", err)
+	}
+	return true, nil
+}
+
+func replyToVoiceNote(ev *slackevents.MessageEvent, text string) error {
+	if _, _, err := slackWrapper.PostMessage(ev.Channel, slack.MsgOptionText(text, false), slack.MsgOptionTS(ev.TimeStamp)); err != nil {
+		return fmt.Errorf("Failed to reply to voice note: %s", err)
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/nlopes/slack"
+	"github.com/nlopes/slack/slackevents"
+
+	"github.com/skybet/go-helpdesk/mocks"
+	"github.com/skybet/go-helpdesk/store"
+)
+
+// fakeTranscriber "transcribes" audio by reversing its words
+type fakeTranscriber struct{}
+
+func (fakeTranscriber) Transcribe(ctx context.Context, audio io.Reader, mimetype string) (string, error) {
+	b, err := ioutil.ReadAll(audio)
+	if err != nil {
+		return "", err
+	}
+	if string(b) == "static" {
+		return "", errors.New("unintelligible")
+	}
+	words := strings.Fields(string(b))
+	for i, j := 0, len(words)-1; i < j; i, j = i+1, j-1 {
+		words[i], words[j] = words[j], words[i]
+	}
+	return strings.Join(words, " "), nil
+}
+
+func voiceEvent(ts, text string, files ...slackevents.File) *slackevents.EventsAPIEvent {
+	return &slackevents.EventsAPIEvent{TeamID: "T1", InnerEvent: slackevents.EventsAPIInnerEvent{
+		Type: "message",
+		Data: &slackevents.MessageEvent{Channel: "D1", ChannelType: "im", SubType: "file_share", User: "U1", Text: text, TimeStamp: ts, Files: files},
+	}}
+}
+
+func TestVoiceNote(t *testing.T) {
+	inThread := func(ts string) (string, func(c *mocks.Call) bool) {
+		return "in thread " + ts, func(c *mocks.Call) bool {
+			_, values, _ := slack.UnsafeApplyMsgOptions("", c.Channel, "", c.Options...)
+			return values.Get("thread_ts") == ts
+		}
+	}
+	mockSlack := mocks.NewSlack(t)
+	mockSlack.ExpectDownloadFile().Named("https://files/F1").ReturnContent("fire on is printer The")
+	mockSlack.ExpectPostMessage().ToChannel("D1").WithText("Ticket #1 created from your voice note: The printer is on fire").Matching(inThread("1.1"))
+	mockSlack.ExpectDownloadFile().Named("https://files/F2").ReturnContent("static")
+	mockSlack.ExpectPostMessage().ToChannel("D1").WithText("could not transcribe").Matching(inThread("1.2"))
+	mockSlack.ExpectPostMessage().ToChannel("D1").WithText("too long").Matching(inThread("1.3"))
+	Init(mockSlack)
+	s := store.NewMemory()
+	InitTickets(s)
+	InitTranscriber(fakeTranscriber{})
+	defer InitTranscriber(nil)
+
+	for _, ev := range []*slackevents.EventsAPIEvent{
+		voiceEvent("1.1", "Floor 3", slackevents.File{ID: "F1", Mimetype: "audio/mp4", URLPrivateDownload: "https://files/F1", Permalink: "https://slack/F1"}),
+		voiceEvent("1.2", "", slackevents.File{ID: "F2", Mimetype: "audio/webm", URLPrivateDownload: "https://files/F2"}),
+		voiceEvent("1.3", "", slackevents.File{ID: "F3", Mimetype: "audio/webm", Size: maxVoiceNote + 1}),
+		// Files which are not audio are left alone
+		voiceEvent("1.4", "", slackevents.File{ID: "F4", Mimetype: "image/png"}),
+	} {
+		req, res, _ := newTestRequest()
+		if err := Message(res, req, ev); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+	}
+	tk, err := s.GetTicket(context.Background(), "1")
+	if err != nil || tk.ThreadTS != "1.1" || tk.ChannelID != "D1" || tk.Reporter != "U1" {
+		t.Fatalf("Expected a ticket in the voice note's thread, got %+v %v", tk, err)
+	}
+	if tk.Title != "The printer is on fire" || !strings.Contains(tk.Description, "Floor 3") || !strings.Contains(tk.Description, "Voice note: https://slack/F1") {
+		t.Errorf("Expected the transcript, caption and a link to the audio, got %+v", tk)
+	}
+	if _, err := s.GetTicket(context.Background(), "2"); err != store.ErrNotFound {
+		t.Errorf("Expected no other tickets, got %v", err)
+	}
+}
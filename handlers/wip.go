@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/nlopes/slack"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/skybet/go-helpdesk/archive"
+	"github.com/skybet/go-helpdesk/blocks"
+	"github.com/skybet/go-helpdesk/outbox"
+	"github.com/skybet/go-helpdesk/policy"
+	"github.com/skybet/go-helpdesk/server"
+	"github.com/skybet/go-helpdesk/store"
+	"github.com/skybet/go-helpdesk/ticket"
+	"github.com/skybet/go-helpdesk/wip"
+)
+
+// WIPOverrideActionID is the action ID of the button confirming an assignment
+// over a WIP limit, its value is the ticket ID and agent separated by a colon
+const WIPOverrideActionID = "wip_override"
+
+var limits = wip.NewLimits()
+
+// InitWIP sets the WIP limits enforced when assigning tickets
+func InitWIP(l *wip.Limits) {
+	limits = l
+}
+
+// Assign handles /hd assign <ticket> [@agent], assigning the ticket to the
+// agent or to the user running the command. If that would exceed a WIP limit
+// the user is asked to confirm.
+func Assign(res *server.Response, req *server.Request, sc slack.SlashCommand, args server.Args) error {
+	id, agent := args.String("ticket"), sc.UserID
+	if args.Has("agent") {
+		agent = args.String("agent")
+	}
+
+	t, err := limits.Assign(req.Context(), tickets, id, agent, false)
+	if over, ok := err.(*wip.ErrOverLimit); ok {
+		text := tr(sc, "Assigning ticket #%s to <@%s> would exceed the WIP limit: %s.", id, agent, over)
+		button := blocks.Button(WIPOverrideActionID, id+":"+agent, tr(sc, "Assign anyway"))
+		button.Style = slack.StyleDanger
+		return res.JSON(http.StatusOK, slack.Msg{ResponseType: slack.ResponseTypeEphemeral, Text: text, Blocks: slack.Blocks{
+			BlockSet: blocks.New().Section(text).Actions("", button).Blocks(),
+		}})
+	}
+	if err == store.ErrNotFound {
+		res.Text(http.StatusOK, tr(sc, "There is no ticket #%s", id))
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("Failed to assign ticket: %s", err)
+	}
+	syncShares(t)
+	suggestRunbooks(t)
+	res.Text(http.StatusOK, tr(sc, "Ticket %s is assigned to <@%s>", ticketLinks.Ref(t.ID), agent))
+	return nil
+}
+
+// WIPOverride handles the button confirming an assignment over a WIP limit,
+// the override is posted in the ticket's thread from the outbox
+func WIPOverride(res *server.Response, req *server.Request, ctx interface{}) error {
+	ic, ok := ctx.(*slack.InteractionCallback)
+	if !ok {
+		return fmt.Errorf("Expected a *slack.InteractionCallback to be passed to the handler")
+	}
+	action, err := blocks.ActionOf(ic)
+	if err != nil {
+		return err
+	}
+	parts := strings.SplitN(action.Value, ":", 2)
+	if len(parts) != 2 {
+		return fmt.Errorf("Invalid assignment: %q", action.Value)
+	}
+	var t *ticket.Ticket
+	err = tickets.Tx(req.Context(), func(tx store.Store) error {
+		var err error
+		if t, err = limits.Assign(req.Context(), tx, parts[0], parts[1], true); err != nil {
+			return err
+		}
+		if t.ChannelID == "" || archive.Locked(t) {
+			return nil
+		}
+		return tx.Enqueue(req.Context(), outbox.Thread(t, fmt.Sprintf("<@%s> assigned this ticket to <@%s>, overriding the WIP limit", ic.User.ID, t.Assignee)))
+	})
+	if err != nil {
+		return fmt.Errorf("Failed to assign ticket: %s", err)
+	}
+	log.Infof("%s assigned ticket %s to %s over its WIP limit", ic.User.ID, t.ID, t.Assignee)
+	syncShares(t)
+	suggestRunbooks(t)
+	return nil
+}
+
+// WIP handles /hd wip, which lists the WIP limits. Admins can change them with
+// /hd wip queue <queue> <limit> and /hd wip agent <@agent> <limit>, a limit of
+// zero removes it.
+func WIP(res *server.Response, req *server.Request, ctx interface{}) error {
+	sc, ok := ctx.(slack.SlashCommand)
+	if !ok {
+		return fmt.Errorf("Expected a slack.SlashCommand to be passed to the handler")
+	}
+	args := strings.Fields(sc.Text)
+	if len(args) == 1 {
+		var lines []string
+		for q, n := range limits.Queues() {
+			lines = append(lines, fmt.Sprintf("%s: %d", q, n))
+		}
+		sort.Strings(lines)
+		if len(lines) == 0 {
+			lines = []string{tr(sc, "No queues have a WIP limit")}
+		}
+		res.Text(http.StatusOK, strings.Join(lines, "\n"))
+		return nil
+	}
+	if !allowed(req.Context(), sc.UserID, policy.SetWIP) {
+		res.Text(http.StatusOK, tr(sc, "Sorry, only helpdesk admins can change WIP limits"))
+		return nil
+	}
+	var n int
+	var err error
+	if len(args) == 4 {
+		n, err = strconv.Atoi(args[3])
+	}
+	if len(args) != 4 || err != nil || n < 0 || (args[1] != "queue" && args[1] != "agent") {
+		res.Text(http.StatusOK, tr(sc, "Usage: %s wip [queue <queue>|agent <@agent>] <limit>", sc.Command))
+		return nil
+	}
+	if args[1] == "queue" {
+		limits.SetQueue(args[2], n)
+	} else {
+		agent := userID(args[2])
+		if agent == "" {
+			res.Text(http.StatusOK, tr(sc, "%s is not a user", args[2]))
+			return nil
+		}
+		limits.SetAgent(agent, n)
+	}
+	res.Text(http.StatusOK, tr(sc, "The WIP limit for %s %s is now %d", args[1], args[2], n))
+	return nil
+}
+
+// userID returns the ID from an escaped user mention such as <@U123|bob>
+func userID(mention string) string {
+	if !strings.HasPrefix(mention, "<@") || !strings.HasSuffix(mention, ">") {
+		return ""
+	}
+	return strings.SplitN(strings.TrimSuffix(strings.TrimPrefix(mention, "<@"), ">"), "|", 2)[0]
+}
@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/nlopes/slack"
+
+	"github.com/skybet/go-helpdesk/mocks"
+	"github.com/skybet/go-helpdesk/store"
+	"github.com/skybet/go-helpdesk/ticket"
+	"github.com/skybet/go-helpdesk/wip"
+)
+
+func TestAssignOverLimit(t *testing.T) {
+	mockSlack := &mocks.SlackWrapper{}
+	Init(mockSlack)
+	s := store.NewMemory()
+	s.CreateTicket(context.Background(), &ticket.Ticket{ID: "1", Queue: "it", Assignee: "U1"})
+	s.CreateTicket(context.Background(), &ticket.Ticket{ID: "2", Queue: "it", ChannelID: "C1", ThreadTS: "1.1"})
+	InitTickets(s)
+	l := wip.NewLimits()
+	l.SetAgent("U1", 1)
+	InitWIP(l)
+	defer InitWIP(wip.NewLimits())
+
+	req, res, w := newTestRequest()
+	if err := Helpdesk(res, req, slack.SlashCommand{Command: "/hd", Text: "assign 2 <@U1|bob>", UserID: "U2"}); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if body := w.Body.String(); !strings.Contains(body, WIPOverrideActionID) || !strings.Contains(body, `"2:U1"`) {
+		t.Fatalf("Expected to be asked to confirm, got %s", body)
+	}
+
+	ic := &slack.InteractionCallback{Type: slack.InteractionTypeBlockActions}
+	ic.User.ID = "U2"
+	ic.ActionCallback.BlockActions = []*slack.BlockAction{{ActionID: WIPOverrideActionID, Value: "2:U1"}}
+	if err := WIPOverride(res, req, ic); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if tk, _ := s.GetTicket(context.Background(), "2"); tk.Assignee != "U1" {
+		t.Errorf("Expected the override to assign the ticket, got %q", tk.Assignee)
+	}
+	if pending, _ := s.Outbox(context.Background(), 0); len(pending) != 1 || !strings.Contains(pending[0].Text, "overriding the WIP limit") {
+		t.Errorf("Expected the override to be posted from the outbox, got %+v", pending)
+	}
+}
+
+func TestAssignSelf(t *testing.T) {
+	s := store.NewMemory()
+	s.CreateTicket(context.Background(), &ticket.Ticket{ID: "1", Queue: "it"})
+	InitTickets(s)
+	req, res, w := newTestRequest()
+
+	if err := Helpdesk(res, req, slack.SlashCommand{Command: "/hd", Text: "assign #1", UserID: "U2"}); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if tk, _ := s.GetTicket(context.Background(), "1"); tk.Assignee != "U2" || !strings.Contains(w.Body.String(), "assigned to <@U2>") {
+		t.Errorf("Expected the ticket to be assigned to the user, got %q %s", tk.Assignee, w.Body.String())
+	}
+}
+
+func TestWIPAdminOnly(t *testing.T) {
+	InitAnnouncements(nil, []string{"UADMIN"})
+	defer InitWIP(wip.NewLimits())
+	req, res, w := newTestRequest()
+
+	Helpdesk(res, req, slack.SlashCommand{Command: "/hd", Text: "wip queue it 3", UserID: "U1"})
+	if !strings.Contains(w.Body.String(), "only helpdesk admins") || len(limits.Queues()) != 0 {
+		t.Errorf("Expected non admins to be refused, got %s", w.Body.String())
+	}
+
+	req, res, w = newTestRequest()
+	Helpdesk(res, req, slack.SlashCommand{Command: "/hd", Text: "wip queue it 3", UserID: "UADMIN"})
+	if limits.Queues()["it"] != 3 {
+		t.Errorf("Expected the limit to be set, got %v %s", limits.Queues(), w.Body.String())
+	}
+}
+
+func TestDashboardFlagsWIP(t *testing.T) {
+	s := store.NewMemory()
+	s.CreateTicket(context.Background(), &ticket.Ticket{Queue: "it", Assignee: "U1"})
+	s.CreateTicket(context.Background(), &ticket.Ticket{Queue: "it", Assignee: "U2"})
+	InitTickets(s)
+	l := wip.NewLimits()
+	l.SetQueue("it", 1)
+	InitWIP(l)
+	defer InitWIP(wip.NewLimits())
+	req, res, w := newTestRequest()
+
+	if err := Helpdesk(res, req, slack.SlashCommand{Command: "/hd", Text: "dashboard"}); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if !strings.Contains(w.Body.String(), "over the WIP limit of 1") {
+		t.Errorf("Expected the queue to be flagged, got %s", w.Body.String())
+	}
+}
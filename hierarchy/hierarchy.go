@@ -0,0 +1,180 @@
+// Package hierarchy groups queues into departments within an organisation.
+// The SLA targets and WIP limit set on the organisation apply to every queue
+// in it unless the queue's department, or the queue itself, sets its own, so
+// a department only has to say how it differs.
+package hierarchy
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/skybet/go-helpdesk/sla"
+)
+
+// Level is how far down the hierarchy a unit is
+type Level string
+
+// The levels of the hierarchy, from the top
+const (
+	Organisation Level = "organisation"
+	Department   Level = "department"
+	Queue        Level = "queue"
+)
+
+// Policy is what a unit sets for the queues beneath it. Anything it leaves
+// empty is inherited from the unit above.
+type Policy struct {
+	// Response and Resolution are SLA targets in the form
+	// <priority>=<duration>, each replaces the inherited target for its
+	// priority and leaves the others alone
+	Response   []string `json:"response,omitempty"`
+	Resolution []string `json:"resolution,omitempty"`
+	// WIPLimit is the most open tickets each queue may have assigned
+	WIPLimit int `json:"wip_limit,omitempty"`
+}
+
+// Unit is the organisation, one of its departments or one of their queues.
+// The organisation has Departments and each department has Queues.
+type Unit struct {
+	Name string `json:"name"`
+	Policy
+	Departments []Unit `json:"departments,omitempty"`
+	Queues      []Unit `json:"queues,omitempty"`
+}
+
+// Tree is an organisation with the policy of each of its queues worked out
+type Tree struct {
+	Root Unit
+
+	departments map[string]string
+	levels      map[string]sla.SLA
+	limits      map[string]int
+}
+
+// New works out the policy of every queue in root, starting from the
+// targets in base
+func New(root Unit, base sla.SLA) (*Tree, error) {
+	if root.Name == "" {
+		return nil, fmt.Errorf("the organisation has no name")
+	}
+	if len(root.Queues) > 0 {
+		return nil, fmt.Errorf("queues must be in a department, not directly in %s", root.Name)
+	}
+	t := &Tree{Root: root, departments: map[string]string{}, levels: map[string]sla.SLA{}, limits: map[string]int{}}
+	org, limit, err := inherit(root, sla.SLA{Response: base.Response, Resolution: base.Resolution}, 0)
+	if err != nil {
+		return nil, err
+	}
+	seen := map[string]bool{root.Name: true}
+	for _, d := range root.Departments {
+		if d.Name == "" || seen[d.Name] {
+			return nil, fmt.Errorf("department %q needs a name of its own", d.Name)
+		}
+		seen[d.Name] = true
+		if len(d.Departments) > 0 {
+			return nil, fmt.Errorf("department %s can only have queues", d.Name)
+		}
+		dept, deptLimit, err := inherit(d, org, limit)
+		if err != nil {
+			return nil, err
+		}
+		for _, q := range d.Queues {
+			if q.Name == "" || seen[q.Name] {
+				return nil, fmt.Errorf("queue %q in %s needs a name of its own", q.Name, d.Name)
+			}
+			seen[q.Name] = true
+			if len(q.Departments) > 0 || len(q.Queues) > 0 {
+				return nil, fmt.Errorf("queue %s can not have units beneath it", q.Name)
+			}
+			levels, queueLimit, err := inherit(q, dept, deptLimit)
+			if err != nil {
+				return nil, err
+			}
+			t.departments[q.Name] = d.Name
+			t.levels[q.Name] = levels
+			if queueLimit > 0 {
+				t.limits[q.Name] = queueLimit
+			}
+		}
+	}
+	return t, nil
+}
+
+// inherit returns the targets and WIP limit of u given those of the unit
+// above it
+func inherit(u Unit, above sla.SLA, limit int) (sla.SLA, int, error) {
+	response, err := override(above.Response, u.Response)
+	if err != nil {
+		return sla.SLA{}, 0, fmt.Errorf("%s: %s", u.Name, err)
+	}
+	resolution, err := override(above.Resolution, u.Resolution)
+	if err != nil {
+		return sla.SLA{}, 0, fmt.Errorf("%s: %s", u.Name, err)
+	}
+	if u.WIPLimit < 0 {
+		return sla.SLA{}, 0, fmt.Errorf("%s: WIP limits can not be negative", u.Name)
+	}
+	if u.WIPLimit > 0 {
+		limit = u.WIPLimit
+	}
+	return sla.SLA{Response: response, Resolution: resolution}, limit, nil
+}
+
+func override(inherited sla.Targets, specs []string) (sla.Targets, error) {
+	own, err := sla.ParseTargets(specs)
+	if err != nil {
+		return nil, err
+	}
+	targets := sla.Targets{}
+	for p, d := range inherited {
+		targets[p] = d
+	}
+	for p, d := range own {
+		targets[p] = d
+	}
+	return targets, nil
+}
+
+// Load reads the organisation from a JSON file, see New
+func Load(path string, base sla.SLA) (*Tree, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading hierarchy: %s", err)
+	}
+	defer f.Close()
+	var root Unit
+	if err := json.NewDecoder(f).Decode(&root); err != nil {
+		return nil, fmt.Errorf("error parsing hierarchy %s: %s", path, err)
+	}
+	return New(root, base)
+}
+
+// SLA returns base with the targets of every queue in the hierarchy
+func (t *Tree) SLA(base sla.SLA) sla.SLA {
+	queues := map[string]sla.SLA{}
+	for q, s := range base.Queues {
+		queues[q] = s
+	}
+	for q, s := range t.levels {
+		queues[q] = s
+	}
+	base.Queues = queues
+	return base
+}
+
+// WIPLimits returns the WIP limit of each queue which has one
+func (t *Tree) WIPLimits() map[string]int {
+	limits := make(map[string]int, len(t.limits))
+	for q, n := range t.limits {
+		limits[q] = n
+	}
+	return limits
+}
+
+// Department returns the department a queue is in, false if it is not in the
+// hierarchy
+func (t *Tree) Department(queue string) (string, bool) {
+	d, ok := t.departments[queue]
+	return d, ok
+}
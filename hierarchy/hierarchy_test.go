@@ -0,0 +1,89 @@
+package hierarchy
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/skybet/go-helpdesk/sla"
+	"github.com/skybet/go-helpdesk/ticket"
+)
+
+const acme = `{
+	"name": "Acme",
+	"response": ["P1=30m"],
+	"wip_limit": 10,
+	"departments": [
+		{"name": "IT", "response": ["P1=15m"], "queues": [{"name": "it"}, {"name": "it-hardware", "wip_limit": 3}]},
+		{"name": "People", "resolution": ["*=120h"], "queues": [{"name": "payroll", "response": ["P2=2h"]}]}
+	]
+}`
+
+func TestLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hierarchy")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "hierarchy.json")
+	if err := ioutil.WriteFile(path, []byte(acme), 0644); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	base := sla.SLA{Response: sla.Targets{ticket.P1: time.Hour, 0: 8 * time.Hour}, Resolution: sla.Targets{0: 3 * 24 * time.Hour}}
+	tree, err := Load(path, base)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	s := tree.SLA(base)
+
+	for _, tt := range []struct {
+		queue   string
+		targets sla.Targets
+		p       ticket.Priority
+		want    time.Duration
+	}{
+		// Set by the department over the organisation
+		{"it", s.For("it").Response, ticket.P1, 15 * time.Minute},
+		// Set by the organisation over the base
+		{"payroll", s.For("payroll").Response, ticket.P1, 30 * time.Minute},
+		// Set by the queue, alongside what it inherits
+		{"payroll", s.For("payroll").Response, ticket.P2, 2 * time.Hour},
+		{"payroll", s.For("payroll").Resolution, ticket.P3, 5 * 24 * time.Hour},
+		// Inherited from the base all the way down
+		{"it", s.For("it").Response, ticket.P3, 8 * time.Hour},
+		// Queues outside the hierarchy keep the base targets
+		{"other", s.For("other").Response, ticket.P1, time.Hour},
+	} {
+		if d, ok := tt.targets.For(tt.p); !ok || d != tt.want {
+			t.Errorf("Expected %s's %s target to be %s, got %s", tt.queue, tt.p, tt.want, d)
+		}
+	}
+
+	limits := tree.WIPLimits()
+	if len(limits) != 3 || limits["it"] != 10 || limits["it-hardware"] != 3 || limits["payroll"] != 10 {
+		t.Errorf("Expected the organisation's WIP limit to be inherited unless overridden, got %v", limits)
+	}
+	if d, ok := tree.Department("it-hardware"); !ok || d != "IT" {
+		t.Errorf("Expected it-hardware to be in IT, got %q", d)
+	}
+}
+
+func TestNewInvalid(t *testing.T) {
+	for _, tt := range []struct {
+		root Unit
+		err  string
+	}{
+		{Unit{}, "no name"},
+		{Unit{Name: "Acme", Queues: []Unit{{Name: "it"}}}, "must be in a department"},
+		{Unit{Name: "Acme", Departments: []Unit{{Name: "IT", Queues: []Unit{{Name: "it"}}}, {Name: "Ops", Queues: []Unit{{Name: "it"}}}}}, "name of its own"},
+		{Unit{Name: "Acme", Departments: []Unit{{Name: "IT", Policy: Policy{Response: []string{"P9=1h"}}}}}, "IT:"},
+		{Unit{Name: "Acme", Departments: []Unit{{Name: "IT", Queues: []Unit{{Name: "it", Policy: Policy{WIPLimit: -1}}}}}}, "negative"},
+	} {
+		if _, err := New(tt.root, sla.SLA{}); err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("Expected an error containing %q for %+v, got %v", tt.err, tt.root, err)
+		}
+	}
+}
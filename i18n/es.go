@@ -0,0 +1,158 @@
+package i18n
+
+var spanish = &Catalog{
+	Keywords: map[string]string{
+		"nuevo":        "new",
+		"antiguedad":   "aging",
+		"antigüedad":   "aging",
+		"anunciar":     "announce",
+		"editar":       "edit",
+		"asignar":      "assign",
+		"panel":        "dashboard",
+		"limites":      "wip",
+		"límites":      "wip",
+		"cola":         "queue",
+		"agente":       "agent",
+		"formato":      "format",
+		"sencillo":     "plain",
+		"enriquecido":  "rich",
+		"estado":       "status",
+		"compartir":    "share",
+		"borrar":       "delete",
+		"restaurar":    "restore",
+		"papelera":     "trash",
+		"cerrar-todo":  "bulk-close",
+		"exportar":     "export",
+		"olvidar":      "erase",
+		"aprovisionar": "provision",
+		"depurar":      "debug",
+		"auditoria":    "audit",
+		"auditoría":    "audit",
+		"verificar":    "verify",
+		"mover":        "move",
+		"guias":        "runbooks",
+		"guías":        "runbooks",
+		"habilidades":  "skills",
+		"buscar":       "search",
+		"guardadas":    "saved",
+		"actividad":    "heatmap",
+		"catalogo":     "catalog",
+		"catálogo":     "catalog",
+		"ayuda":        "help",
+	},
+	Messages: map[string]string{
+		"Your notifications are %s. Use %s format plain or %s format rich to change them.": "Tus notificaciones son %s. Usa %s formato sencillo o %s formato enriquecido para cambiarlas.",
+		"Usage: %s format [plain|rich]":                                 "Uso: %s formato [sencillo|enriquecido]",
+		"Your notifications are now %s":                                 "Tus notificaciones son ahora %s",
+		"Usage: %s [%s]":                                                "Uso: %s [%s]",
+		"Usage: %s assign <ticket> [@agent]":                            "Uso: %s asignar <ticket> [@agente]",
+		"Usage: %s wip [queue <queue>|agent <@agent>] <limit>":          "Uso: %s límites [cola <cola>|agente <@agente>] <límite>",
+		"%s is not a user":                                              "%s no es un usuario",
+		"%s is not a number":                                            "%s no es un número",
+		"%s is not a channel":                                           "%s no es un canal",
+		"%s is not a ticket":                                            "%s no es un ticket",
+		"%s is not a duration, such as 90m":                             "%s no es una duración, como 90m",
+		"Usage: %s move <ticket> <status>":                              "Uso: %s mover <ticket> <estado>",
+		"There is no ticket #%s":                                        "No existe el ticket #%s",
+		"Ticket %s is assigned to <@%s>":                                "El ticket %s está asignado a <@%s>",
+		"Assigning ticket #%s to <@%s> would exceed the WIP limit: %s.": "Asignar el ticket #%s a <@%s> superaría el límite de trabajo en curso: %s.",
+		"Assign anyway":                                                 "Asignar de todos modos",
+		"No queues have a WIP limit":                                    "Ninguna cola tiene límite de trabajo en curso",
+		"Sorry, only helpdesk admins can change WIP limits":             "Lo siento, solo los administradores pueden cambiar los límites de trabajo en curso",
+		"The WIP limit for %s %s is now %d":                             "El límite de trabajo en curso de %s %s es ahora %d",
+		"Sorry, only helpdesk admins can send announcements":            "Lo siento, solo los administradores pueden enviar anuncios",
+		"There is no announcement %s":                                   "No existe el anuncio %s",
+		"There are no open tickets":                                     "No hay tickets abiertos",
+		"Departments have not been set up, use %s dashboard":            "No hay departamentos configurados, usa %s panel",
+		"There is no department or queue called %s":                     "No existe ningún departamento ni cola llamado %s",
+		"*The %d longest idle of %d open tickets*":                      "*Los %d tickets inactivos más tiempo de %d abiertos*",
+		"*%d open tickets by idle time*":                                "*%d tickets abiertos por tiempo de inactividad*",
+		"Usage: %s status [ticket]":                                     "Uso: %s estado [ticket]",
+		"You have not reported a ticket #%s":                            "No has abierto ningún ticket #%s",
+		"Ticket #%s is %s":                                              "El ticket #%s está %s",
+		"You have no open tickets":                                      "No tienes tickets abiertos",
+		"*Your open tickets*":                                           "*Tus tickets abiertos*",
+		", assigned to <@%s>":                                           ", asignado a <@%s>",
+		"Use %s status <ticket> for more detail.":                       "Usa %s estado <ticket> para ver más detalles.",
+		"First response %s":                                             "Primera respuesta %s",
+		"Expect a response by %s":                                       "Recibirás una respuesta antes de %s",
+		"A response was expected by %s, sorry for the wait":             "Se esperaba una respuesta antes de %s, disculpa la espera",
+		"No queues have a channel to share tickets in":                  "Ninguna cola tiene un canal donde compartir tickets",
+		"Usage: %s share <ticket> <queue>...":                           "Uso: %s compartir <ticket> <cola>...",
+		"Ticket #%s could not be shared: %s":                            "No se pudo compartir el ticket #%s: %s",
+		"Ticket %s is shared between %s":                                "El ticket %s está compartido entre %s",
+		"Sorry, only helpdesk admins can delete tickets":                "Lo siento, solo los administradores pueden borrar tickets",
+		"Usage: %s delete <ticket>":                                     "Uso: %s borrar <ticket>",
+		"Ticket #%s could not be deleted: %s":                           "No se pudo borrar el ticket #%s: %s",
+		"Ticket #%s is in the trash, use %s restore %s to get it back before it is purged %s": "El ticket #%s está en la papelera, usa %s restaurar %s para recuperarlo antes de que se elimine %s",
+		"Sorry, only helpdesk admins can restore tickets":                                     "Lo siento, solo los administradores pueden restaurar tickets",
+		"Usage: %s restore <ticket>":                                                          "Uso: %s restaurar <ticket>",
+		"Ticket #%s could not be restored: %s":                                                "No se pudo restaurar el ticket #%s: %s",
+		"Ticket %s has been restored":                                                         "El ticket %s ha sido restaurado",
+		"Sorry, only helpdesk admins can see the trash":                                       "Lo siento, solo los administradores pueden ver la papelera",
+		"The trash is empty":                                                                  "La papelera está vacía",
+		"*%d tickets in the trash*":                                                           "*%d tickets en la papelera*",
+		"and %d more":                                                                         "y %d más",
+		"• #%s %s, deleted by <@%s>, purged %s":                                               "• #%s %s, borrado por <@%s>, se eliminará %s",
+		"Sorry, only helpdesk admins can close a whole queue":                                 "Lo siento, solo los administradores pueden cerrar una cola entera",
+		"Usage: %s bulk-close <queue|query>":                                                  "Uso: %s cerrar-todo <cola|consulta>",
+		"Sorry, only helpdesk admins can export tickets":                                      "Lo siento, solo los administradores pueden exportar tickets",
+		"That query could not be understood: %s":                                              "No se ha entendido la consulta: %s",
+		"Usage: %s search <query>, e.g. status:open priority>=P2 tag:vpn created<7d -assignee:@me": "Uso: %s buscar <consulta>, p. ej. status:open priority>=P2 tag:vpn created<7d -assignee:@me",
+		"No tickets match":                    "Ningún ticket coincide",
+		"*%d tickets match*":                  "*%d tickets coinciden*",
+		"*The first %d matching tickets*":     "*Los primeros %d tickets que coinciden*",
+		"Saved searches have not been set up": "No se han configurado las búsquedas guardadas",
+		"Sorry, only agents who can search every ticket can save searches":                                                  "Lo siento, solo los agentes que pueden buscar en todos los tickets pueden guardar búsquedas",
+		"Usage: %s saved [add <name> <query> | run <name> | subscribe <name> [every] | unsubscribe <name> | remove <name>]": "Uso: %s guardadas [add <nombre> <consulta> | run <nombre> | subscribe <nombre> [cada] | unsubscribe <nombre> | remove <nombre>]",
+		"Saved your search %s, use %s saved subscribe %s to be sent new tickets matching it":                                "Se ha guardado tu búsqueda %s, usa %s guardadas subscribe %s para recibir los tickets nuevos que coincidan",
+		"You have no saved search called %s":                                                                                "No tienes ninguna búsqueda guardada llamada %s",
+		"%s is not how often to be sent new tickets, use e.g. 30m or 4h":                                                    "%s no es una frecuencia válida para recibir tickets nuevos, usa p. ej. 30m o 4h",
+		"You will be sent new tickets matching %s at most every %s":                                                         "Recibirás los tickets nuevos que coincidan con %s como mucho cada %s",
+		"You will no longer be sent new tickets matching %s":                                                                "Ya no recibirás los tickets nuevos que coincidan con %s",
+		"Removed your saved search %s":                                                                                      "Se ha eliminado tu búsqueda guardada %s",
+		"Your saved searches could not be changed: %s":                                                                      "No se han podido cambiar tus búsquedas guardadas: %s",
+		"You have no saved searches, use %s saved add <name> <query> to save one":                                           "No tienes búsquedas guardadas, usa %s guardadas add <nombre> <consulta> para guardar una",
+		"*Your saved searches*":                       "*Tus búsquedas guardadas*",
+		", new tickets sent at most every %s":         ", se envían los tickets nuevos como mucho cada %s",
+		"Sorry, only helpdesk admins can erase users": "Lo siento, solo los administradores pueden olvidar usuarios",
+		"Usage: %s erase <@user>":                     "Uso: %s olvidar <@usuario>",
+		"This command needs another admin to approve it, but you are the only admin":                  "Este comando necesita que otro administrador lo apruebe, pero eres el único administrador",
+		"This command needs another admin to approve it, %d admins have been asked and have until %s": "Este comando necesita que otro administrador lo apruebe, se ha pedido a %d administradores y tienen hasta %s",
+		"Sorry, only helpdesk admins can provision queues":                                            "Lo siento, solo los administradores pueden aprovisionar colas",
+		"Usage: %s provision <queue> <channel> [@usergroup]":                                          "Uso: %s aprovisionar <cola> <canal> [@grupo]",
+		"The %s queue could not be provisioned: %s":                                                   "No se ha podido aprovisionar la cola %s: %s",
+		"Created <#%s> for the %s queue and invited %d people":                                        "Se ha creado <#%s> para la cola %s y se ha invitado a %d personas",
+		"<#%s> is now the channel of the %s queue, %d people were invited":                            "<#%s> es ahora el canal de la cola %s, se ha invitado a %d personas",
+		"Sorry, only helpdesk admins can change logging":                                              "Lo siento, solo los administradores pueden cambiar los registros",
+		"Unknown log level %q, use one of debug, info, warning or error":                              "Nivel de registro %q desconocido, usa debug, info, warning o error",
+		"Usage: %s debug [level <level> | capture <minutes> | stop]":                                  "Uso: %s depurar [level <nivel> | capture <minutos> | stop]",
+		"Payloads can be captured for up to %d minutes":                                               "Los mensajes se pueden capturar durante un máximo de %d minutos",
+		"The log level is %s, payloads are captured with secrets redacted until %s":                   "El nivel de registro es %s, los mensajes se capturan con los secretos ocultos hasta %s",
+		"The log level is %s, payloads are not being captured":                                        "El nivel de registro es %s, no se están capturando los mensajes",
+		"Sorry, only helpdesk admins can verify the audit log":                                        "Lo siento, solo los administradores pueden verificar el registro de auditoría",
+		"Usage: %s audit verify":                                                                      "Uso: %s auditoría verificar",
+		"The audit log has been altered at entry %d: %s":                                              "El registro de auditoría se ha alterado en la entrada %d: %s",
+		"The audit log is empty":                                                                      "El registro de auditoría está vacío",
+		"The audit log's %d entries are intact, the latest was recorded %s with hash `%s`":            "Las %d entradas del registro de auditoría están intactas, la última se registró %s con el hash `%s`",
+		"No runbooks have been set up":                                                                "No se ha configurado ninguna guía",
+		"*Runbooks*, least opened first":                                                              "*Guías*, las menos abiertas primero",
+		"never opened":                                                                                "nunca abierta",
+		"last opened %s":                                                                              "abierta por última vez %s",
+		"• %s: suggested %d times, opened %d times, %s":                                               "• %s: sugerida %d veces, abierta %d veces, %s",
+		"No skills have been set up":                                                                  "No se ha configurado ninguna habilidad",
+		"*Skill coverage*, least covered first":                                                       "*Cobertura de habilidades*, las menos cubiertas primero",
+		"• :warning: %s: nobody, %d open tickets":                                                     "• :warning: %s: nadie, %d tickets abiertos",
+		"• %s: nobody, %d open tickets":                                                               "• %s: nadie, %d tickets abiertos",
+		"• %s: %s, %d open tickets":                                                                   "• %s: %s, %d tickets abiertos",
+		"%d skills needed by open tickets have nobody to take them":                                   "%d habilidades que necesitan los tickets abiertos no tienen a nadie",
+		"Ticket activity":                              "Actividad de tickets",
+		"Ticket activity in %s":                        "Actividad de tickets en %s",
+		"%s is not a timezone, use e.g. Europe/London": "%s no es una zona horaria, usa p. ej. Europe/Madrid",
+		"Usage: %s heatmap [queue] [timezone] [csv]":   "Uso: %s actividad [cola] [zona horaria] [csv]",
+		"The heatmap has been sent to you as a CSV":    "Se te ha enviado la actividad como CSV",
+		"The service catalog has not been set up":      "No se ha configurado el catálogo de servicios",
+		"There is nothing called %s in the service catalog, use %s catalog to see what there is": "No hay nada llamado %s en el catálogo de servicios, usa %s catálogo para ver lo que hay",
+		"Service catalog": "Catálogo de servicios",
+	},
+}
@@ -0,0 +1,71 @@
+// Package i18n translates the helpdesk's commands and replies. Messages are
+// written in English and looked up in a Catalog by their English format.
+package i18n
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Catalog is the translation of the helpdesk into one language
+type Catalog struct {
+	// Keywords map localized subcommands and arguments, e.g. nuevo, to the
+	// English ones
+	Keywords map[string]string
+	// Messages map English message formats to translated formats, which
+	// must take the same arguments in the same order
+	Messages map[string]string
+}
+
+var (
+	mu       sync.RWMutex
+	catalogs = map[string]*Catalog{
+		"es": spanish,
+	}
+)
+
+// Register adds or replaces the catalog for a language
+func Register(lang string, c *Catalog) {
+	mu.Lock()
+	defer mu.Unlock()
+	catalogs[Lang(lang)] = c
+}
+
+// Lang returns the language of a locale such as Slack's es-ES
+func Lang(locale string) string {
+	return strings.ToLower(strings.SplitN(strings.Replace(locale, "_", "-", -1), "-", 2)[0])
+}
+
+// Supported reports whether there is a catalog for the locale's language
+func Supported(locale string) bool {
+	return catalog(locale) != nil
+}
+
+func catalog(locale string) *Catalog {
+	mu.RLock()
+	defer mu.RUnlock()
+	return catalogs[Lang(locale)]
+}
+
+// Sprintf formats a message in the locale's language, falling back to the
+// English format if it has not been translated
+func Sprintf(locale, format string, args ...interface{}) string {
+	if c := catalog(locale); c != nil {
+		if t, ok := c.Messages[format]; ok {
+			format = t
+		}
+	}
+	return fmt.Sprintf(format, args...)
+}
+
+// Keyword returns the English keyword for a word in the locale's language,
+// other words are returned unchanged
+func Keyword(locale, word string) string {
+	if c := catalog(locale); c != nil {
+		if k, ok := c.Keywords[strings.ToLower(word)]; ok {
+			return k
+		}
+	}
+	return word
+}
@@ -0,0 +1,36 @@
+package i18n
+
+import "testing"
+
+func TestSprintf(t *testing.T) {
+	if s := Sprintf("es-ES", "There is no ticket #%s", "7"); s != "No existe el ticket #7" {
+		t.Errorf("Unexpected translation %q", s)
+	}
+	if s := Sprintf("es", "Not translated %d", 1); s != "Not translated 1" {
+		t.Errorf("Expected untranslated messages in English, got %q", s)
+	}
+	if s := Sprintf("", "There is no ticket #%s", "7"); s != "There is no ticket #7" {
+		t.Errorf("Expected English without a locale, got %q", s)
+	}
+}
+
+func TestKeyword(t *testing.T) {
+	if k := Keyword("es-MX", "Asignar"); k != "assign" {
+		t.Errorf("Expected asignar to be assign, got %s", k)
+	}
+	if k := Keyword("fr", "assign"); k != "assign" {
+		t.Errorf("Expected unknown languages to be left alone, got %s", k)
+	}
+}
+
+func TestRegister(t *testing.T) {
+	Register("fr-FR", &Catalog{Keywords: map[string]string{"attribuer": "assign"}})
+	defer func() {
+		mu.Lock()
+		delete(catalogs, "fr")
+		mu.Unlock()
+	}()
+	if !Supported("fr_CA") || Keyword("fr", "attribuer") != "assign" {
+		t.Errorf("Expected the French catalog to be used")
+	}
+}
@@ -0,0 +1,186 @@
+// Package inbox hands agents the unassigned tickets in their queues one at a
+// time, a focused alternative to scanning the queue's channel
+package inbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/skybet/go-helpdesk/store"
+	"github.com/skybet/go-helpdesk/ticket"
+	"github.com/skybet/go-helpdesk/wip"
+)
+
+// ErrClaimed is returned when claiming a ticket someone else is assigned to
+var ErrClaimed = errors.New("ticket already claimed")
+
+// Inbox is each agent's view of the tickets waiting to be triaged. Skips and
+// snoozes are per agent and only kept in memory. Inbox is safe for concurrent
+// use.
+type Inbox struct {
+	store  store.Store
+	limits *wip.Limits
+	queues map[string][]string
+
+	mu      sync.Mutex
+	skipped map[string]map[string]bool
+	snoozed map[string]map[string]time.Time
+}
+
+// New returns an inbox of the tickets in s. queues are the queues each agent
+// triages, agents without any triage every queue. Claims are refused if they
+// would go over limits, which may be nil.
+func New(s store.Store, limits *wip.Limits, queues map[string][]string) *Inbox {
+	if limits == nil {
+		limits = wip.NewLimits()
+	}
+	return &Inbox{
+		store:   s,
+		limits:  limits,
+		queues:  queues,
+		skipped: map[string]map[string]bool{},
+		snoozed: map[string]map[string]time.Time{},
+	}
+}
+
+// ParseQueues parses the queues of agents in the form <agent>:<queue>+<queue>
+func ParseQueues(specs []string) (map[string][]string, error) {
+	queues := map[string][]string{}
+	for _, spec := range specs {
+		parts := strings.SplitN(spec, ":", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return nil, fmt.Errorf("invalid triage queues %q, expected <agent>:<queue>+<queue>", spec)
+		}
+		queues[parts[0]] = append(queues[parts[0]], strings.Split(parts[1], "+")...)
+	}
+	return queues, nil
+}
+
+// Next returns the most urgent unassigned ticket in the agent's queues, oldest
+// first within a priority, or nil if there are none. Tickets the agent skipped
+// come round again once they are all that is left.
+func (i *Inbox) Next(ctx context.Context, agent string, now time.Time) (*ticket.Ticket, error) {
+	waiting, err := i.waiting(ctx, agent)
+	if err != nil {
+		return nil, err
+	}
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	var skipped []*ticket.Ticket
+	for _, t := range waiting {
+		if until, ok := i.snoozed[agent][t.ID]; ok {
+			if now.Before(until) {
+				continue
+			}
+			delete(i.snoozed[agent], t.ID)
+		}
+		if i.skipped[agent][t.ID] {
+			skipped = append(skipped, t)
+			continue
+		}
+		return t, nil
+	}
+	delete(i.skipped, agent)
+	if len(skipped) > 0 {
+		return skipped[0], nil
+	}
+	return nil, nil
+}
+
+// waiting returns the open, unassigned tickets in the agent's queues in the
+// order they should be triaged
+func (i *Inbox) waiting(ctx context.Context, agent string) ([]*ticket.Ticket, error) {
+	queues := i.queues[agent]
+	if len(queues) == 0 {
+		queues = []string{""}
+	}
+	var waiting []*ticket.Ticket
+	for _, q := range queues {
+		f := store.Filter{Queue: q, Status: wip.OpenStatuses}
+		for {
+			page, next, err := i.store.ListTickets(ctx, f)
+			if err != nil {
+				return nil, fmt.Errorf("error listing tickets: %s", err)
+			}
+			for _, t := range page {
+				if t.Assignee == "" {
+					waiting = append(waiting, t)
+				}
+			}
+			if next == "" {
+				break
+			}
+			f.Cursor = next
+		}
+	}
+	sort.SliceStable(waiting, func(a, b int) bool {
+		pa, pb := rank(waiting[a].Priority), rank(waiting[b].Priority)
+		if pa != pb {
+			return pa < pb
+		}
+		return waiting[a].CreatedAt.Before(waiting[b].CreatedAt)
+	})
+	return waiting, nil
+}
+
+// rank orders tickets without a priority after P4
+func rank(p ticket.Priority) ticket.Priority {
+	if p == 0 {
+		return ticket.P4 + 1
+	}
+	return p
+}
+
+// Skip passes over a ticket for now
+func (i *Inbox) Skip(agent, id string) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if i.skipped[agent] == nil {
+		i.skipped[agent] = map[string]bool{}
+	}
+	i.skipped[agent][id] = true
+}
+
+// Snooze hides a ticket from the agent until a time
+func (i *Inbox) Snooze(agent, id string, until time.Time) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if i.snoozed[agent] == nil {
+		i.snoozed[agent] = map[string]time.Time{}
+	}
+	i.snoozed[agent][id] = until
+}
+
+// Claim assigns a ticket to the agent unless someone else already has it, in
+// which case it fails with ErrClaimed, or it would go over a WIP limit
+func (i *Inbox) Claim(ctx context.Context, agent, id string) (*ticket.Ticket, error) {
+	var claimed *ticket.Ticket
+	err := i.store.Tx(ctx, func(tx store.Store) error {
+		t, err := tx.GetTicket(ctx, id)
+		if err != nil {
+			return err
+		}
+		if t.Assignee == agent {
+			claimed = t
+			return nil
+		}
+		if t.Assignee != "" {
+			return ErrClaimed
+		}
+		if err := i.limits.Check(ctx, tx, t, agent); err != nil {
+			return err
+		}
+		t.Assignee = agent
+		if err := tx.UpdateTicket(ctx, t); err != nil {
+			return err
+		}
+		claimed = t
+		return nil
+	})
+	return claimed, err
+}
@@ -0,0 +1,88 @@
+package inbox
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/skybet/go-helpdesk/store"
+	"github.com/skybet/go-helpdesk/ticket"
+	"github.com/skybet/go-helpdesk/wip"
+)
+
+func TestNext(t *testing.T) {
+	ctx := context.Background()
+	s := store.NewMemory()
+	s.CreateTicket(ctx, &ticket.Ticket{Queue: "it", Title: "Old P3", Priority: ticket.P3})
+	s.CreateTicket(ctx, &ticket.Ticket{Queue: "it", Title: "Unprioritised"})
+	s.CreateTicket(ctx, &ticket.Ticket{Queue: "it", Title: "Assigned P1", Priority: ticket.P1, Assignee: "U2"})
+	s.CreateTicket(ctx, &ticket.Ticket{Queue: "hr", Title: "Other queue P1", Priority: ticket.P1})
+	s.CreateTicket(ctx, &ticket.Ticket{Queue: "it", Title: "New P2", Priority: ticket.P2})
+	i := New(s, nil, map[string][]string{"U1": {"it"}})
+	now := time.Date(2019, 3, 4, 9, 0, 0, 0, time.UTC)
+	next := func() string {
+		t.Helper()
+		tk, err := i.Next(ctx, "U1", now)
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		if tk == nil {
+			return ""
+		}
+		return tk.Title
+	}
+
+	if got := next(); got != "New P2" {
+		t.Errorf("Expected the most urgent ticket in the agent's queues, got %q", got)
+	}
+	i.Skip("U1", "5")
+	if got := next(); got != "Old P3" {
+		t.Errorf("Expected the skipped ticket to be passed over, got %q", got)
+	}
+	i.Snooze("U1", "1", now.Add(time.Hour))
+	i.Skip("U1", "2")
+	if got := next(); got != "New P2" {
+		t.Errorf("Expected skipped tickets to come round again once nothing else is left, got %q", got)
+	}
+	if got := next(); got != "New P2" {
+		t.Errorf("Expected the skips to have been cleared, got %q", got)
+	}
+	now = now.Add(2 * time.Hour)
+	i.Skip("U1", "5")
+	if got := next(); got != "Old P3" {
+		t.Errorf("Expected the snooze to have run out, got %q", got)
+	}
+	if tk, _ := i.Next(ctx, "U9", now); tk == nil || tk.Title != "Other queue P1" {
+		t.Errorf("Expected agents without queues to triage every queue, got %+v", tk)
+	}
+}
+
+func TestClaim(t *testing.T) {
+	ctx := context.Background()
+	s := store.NewMemory()
+	s.CreateTicket(ctx, &ticket.Ticket{ID: "1", Queue: "it"})
+	s.CreateTicket(ctx, &ticket.Ticket{ID: "2", Queue: "it"})
+	limits := wip.NewLimits()
+	limits.SetAgent("U1", 1)
+	i := New(s, limits, nil)
+
+	if tk, err := i.Claim(ctx, "U1", "1"); err != nil || tk.Assignee != "U1" {
+		t.Fatalf("Expected the ticket to be claimed, got %+v %v", tk, err)
+	}
+	if _, err := i.Claim(ctx, "U2", "1"); err != ErrClaimed {
+		t.Errorf("Expected a claimed ticket to be refused, got %v", err)
+	}
+	if _, err := i.Claim(ctx, "U1", "2"); err == nil {
+		t.Errorf("Expected the agent's WIP limit to be enforced")
+	}
+}
+
+func TestParseQueues(t *testing.T) {
+	queues, err := ParseQueues([]string{"U1:it+hr", "U2:it"})
+	if err != nil || len(queues["U1"]) != 2 || queues["U2"][0] != "it" {
+		t.Errorf("Unexpected queues %+v %v", queues, err)
+	}
+	if _, err := ParseQueues([]string{"U1"}); err == nil {
+		t.Errorf("Expected an error for an agent without queues")
+	}
+}
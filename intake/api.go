@@ -0,0 +1,204 @@
+package intake
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/skybet/go-helpdesk/server"
+)
+
+// OrgAPI manages the registry of Slack Connect organisations over HTTP for
+// admins. Every request must carry the token as a bearer token.
+type OrgAPI struct {
+	orgs    *Orgs
+	handler http.Handler
+}
+
+// NewOrgAPI returns an API managing o. Mount it with http.StripPrefix so that
+// its routes, such as /orgs, are at the root.
+func NewOrgAPI(o *Orgs, token string) *OrgAPI {
+	a := &OrgAPI{orgs: o}
+	a.handler = server.RequireToken(token, http.HandlerFunc(a.route))
+	return a
+}
+
+// ServeHTTP satisfies http.Handler. GET /orgs lists the organisations, and
+// GET, PUT and DELETE /orgs/<team ID> read, replace and remove one.
+func (a *OrgAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	a.handler.ServeHTTP(w, r)
+}
+
+// route serves the requests which carried the token
+func (a *OrgAPI) route(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	switch {
+	case len(parts) == 1 && parts[0] == "orgs" && r.Method == http.MethodGet:
+		writeJSON(w, map[string]interface{}{"orgs": a.orgs.List()})
+	case len(parts) == 2 && parts[0] == "orgs" && r.Method == http.MethodGet:
+		org, ok := a.orgs.Get(parts[1])
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		writeJSON(w, org)
+	case len(parts) == 2 && parts[0] == "orgs" && r.Method == http.MethodPut:
+		a.put(w, r, parts[1])
+	case len(parts) == 2 && parts[0] == "orgs" && r.Method == http.MethodDelete:
+		a.delete(w, r, parts[1])
+	case len(parts) <= 2 && parts[0] == "orgs":
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// put replaces an organisation's policy, the team ID in the path wins over
+// any in the body
+func (a *OrgAPI) put(w http.ResponseWriter, r *http.Request, teamID string) {
+	var org Org
+	if err := json.NewDecoder(r.Body).Decode(&org); err != nil {
+		http.Error(w, "invalid organisation: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	org.TeamID = teamID
+	if err := org.validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := a.orgs.Put(org); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	org, _ = a.orgs.Get(teamID)
+	writeJSON(w, org)
+}
+
+func (a *OrgAPI) delete(w http.ResponseWriter, r *http.Request, teamID string) {
+	ok, err := a.orgs.Delete(teamID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// LocationAPI manages the registry of locations over HTTP for admins. Every
+// request must carry the token as a bearer token.
+type LocationAPI struct {
+	locations *Locations
+	baseURL   string
+	handler   http.Handler
+}
+
+// NewLocationAPI returns an API managing l. Locations are returned with the
+// URL to put in their QR code, baseURL followed by the code. Mount it with
+// http.StripPrefix so that its routes, such as /locations, are at the root.
+func NewLocationAPI(l *Locations, token, baseURL string) *LocationAPI {
+	a := &LocationAPI{locations: l, baseURL: strings.TrimSuffix(baseURL, "/")}
+	a.handler = server.RequireToken(token, http.HandlerFunc(a.route))
+	return a
+}
+
+// locationJSON is a location with the URL to scan
+type locationJSON struct {
+	Location
+	URL string `json:"url,omitempty"`
+}
+
+func (a *LocationAPI) withURL(l Location) locationJSON {
+	j := locationJSON{Location: l}
+	if a.baseURL != "" {
+		j.URL = a.baseURL + "/" + l.Code
+	}
+	return j
+}
+
+// ServeHTTP satisfies http.Handler. GET /locations lists the locations and
+// POST /locations adds one with a new code. GET, PUT and DELETE
+// /locations/<code> read, replace and remove one.
+func (a *LocationAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	a.handler.ServeHTTP(w, r)
+}
+
+// route serves the requests which carried the token
+func (a *LocationAPI) route(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	switch {
+	case len(parts) == 1 && parts[0] == "locations" && r.Method == http.MethodGet:
+		locations := []locationJSON{}
+		for _, l := range a.locations.List() {
+			locations = append(locations, a.withURL(l))
+		}
+		writeJSON(w, map[string]interface{}{"locations": locations})
+	case len(parts) == 1 && parts[0] == "locations" && r.Method == http.MethodPost:
+		a.put(w, r, "")
+	case len(parts) == 2 && parts[0] == "locations" && r.Method == http.MethodGet:
+		l, ok := a.locations.Get(parts[1])
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		writeJSON(w, a.withURL(l))
+	case len(parts) == 2 && parts[0] == "locations" && r.Method == http.MethodPut:
+		a.put(w, r, parts[1])
+	case len(parts) == 2 && parts[0] == "locations" && r.Method == http.MethodDelete:
+		ok, err := a.locations.Delete(parts[1])
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	case len(parts) <= 2 && parts[0] == "locations":
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// put replaces the location with code, or adds a location with a new code if
+// code is empty
+func (a *LocationAPI) put(w http.ResponseWriter, r *http.Request, code string) {
+	var l Location
+	if err := json.NewDecoder(r.Body).Decode(&l); err != nil {
+		http.Error(w, "invalid location: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	l.Code = code
+	if err := l.validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var err error
+	if code == "" {
+		l, err = a.locations.Add(l)
+	} else {
+		err = a.locations.Put(l)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	l, _ = a.locations.Get(l.Code)
+	if code == "" {
+		w.Header().Set("Location", a.withURL(l).URL)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(a.withURL(l))
+		return
+	}
+	writeJSON(w, a.withURL(l))
+}
+
+func writeJSON(w http.ResponseWriter, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,95 @@
+package intake
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestOrgAPI(t *testing.T) {
+	o := NewOrgs(Org{TeamID: "T2", Name: "Acme"})
+	a := NewOrgAPI(o, "secret")
+	serve := func(method, path, token, body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, path, strings.NewReader(body))
+		if token != "" {
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		a.ServeHTTP(w, r)
+		return w
+	}
+
+	if w := serve("GET", "/orgs", "wrong", ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected requests without the token to be refused, got %d", w.Code)
+	}
+	w := serve("GET", "/orgs", "secret", "")
+	var body struct {
+		Orgs []Org `json:"orgs"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if len(body.Orgs) != 1 || body.Orgs[0].Name != "Acme" {
+		t.Errorf("Expected Acme to be listed, got %+v", body.Orgs)
+	}
+
+	if w := serve("PUT", "/orgs/T3", "secret", `{"team_id":"T9","queue":"globex","share":"reference"}`); w.Code != http.StatusOK {
+		t.Errorf("Expected the organisation to be added, got %d: %s", w.Code, w.Body)
+	}
+	if org, ok := o.Get("T3"); !ok || org.Queue != "globex" || org.Share != ShareReference {
+		t.Errorf("Expected T3 to be registered from the path, got %+v", org)
+	}
+	if w := serve("PUT", "/orgs/T3", "secret", `{"share":"all"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected an invalid policy to be refused, got %d", w.Code)
+	}
+	if w := serve("GET", "/orgs/T3", "secret", ""); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "globex") {
+		t.Errorf("Expected T3 to be returned, got %d: %s", w.Code, w.Body)
+	}
+	if w := serve("DELETE", "/orgs/T2", "secret", ""); w.Code != http.StatusNoContent {
+		t.Errorf("Expected T2 to be deleted, got %d", w.Code)
+	}
+	if w := serve("GET", "/orgs/T2", "secret", ""); w.Code != http.StatusNotFound {
+		t.Errorf("Expected a deleted organisation to be not found, got %d", w.Code)
+	}
+	if w := serve("POST", "/orgs", "secret", ""); w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected POST to be refused, got %d", w.Code)
+	}
+}
+
+func TestLocationAPI(t *testing.T) {
+	l := NewLocations(Location{Code: "LOBBY", Name: "Lobby", Channel: "C1"})
+	a := NewLocationAPI(l, "secret", "https://helpdesk.example.com/intake/")
+	serve := func(method, path, body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, path, strings.NewReader(body))
+		r.Header.Set("Authorization", "Bearer secret")
+		w := httptest.NewRecorder()
+		a.ServeHTTP(w, r)
+		return w
+	}
+
+	w := serve("POST", "/locations", `{"name":"Printer room","channel":"C2","fields":{"floor":"2"}}`)
+	var created locationJSON
+	if err := json.Unmarshal(w.Body.Bytes(), &created); err != nil || w.Code != http.StatusCreated {
+		t.Fatalf("Expected the location to be created, got %d: %s", w.Code, w.Body)
+	}
+	if created.Code == "" || created.URL != "https://helpdesk.example.com/intake/"+created.Code || created.Fields["floor"] != "2" {
+		t.Errorf("Expected a code and the URL to scan, got %+v", created)
+	}
+	if w := serve("POST", "/locations", `{"name":"Nowhere"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected a location without a channel to be refused, got %d", w.Code)
+	}
+	if w := serve("PUT", "/locations/lobby", `{"name":"Front lobby","channel":"C1"}`); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"code":"LOBBY"`) {
+		t.Errorf("Expected the lobby to be replaced, got %d: %s", w.Code, w.Body)
+	}
+	if w := serve("GET", "/locations", ""); !strings.Contains(w.Body.String(), "Front lobby") || !strings.Contains(w.Body.String(), created.Code) {
+		t.Errorf("Expected both locations to be listed, got %s", w.Body)
+	}
+	if w := serve("DELETE", "/locations/LOBBY", ""); w.Code != http.StatusNoContent {
+		t.Errorf("Expected the lobby to be deleted, got %d", w.Code)
+	}
+	if w := serve("GET", "/locations/LOBBY", ""); w.Code != http.StatusNotFound {
+		t.Errorf("Expected a deleted location to be not found, got %d", w.Code)
+	}
+}
@@ -0,0 +1,118 @@
+package intake
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/nlopes/slack"
+
+	"github.com/skybet/go-helpdesk/ticket"
+)
+
+// Audience is the kind of user a ticket comes from
+type Audience int
+
+// Audiences, from most to least trusted
+const (
+	Member Audience = iota
+	Guest
+	External
+)
+
+// String returns the name of the audience, used as a ticket tag
+func (a Audience) String() string {
+	switch a {
+	case Guest:
+		return "guest"
+	case External:
+		return "external"
+	}
+	return "member"
+}
+
+// Classify returns the audience of u. homeTeam is the ID of the workspace the
+// bot is installed in, users from any other team arrived via Slack Connect.
+func Classify(u *slack.User, homeTeam string) Audience {
+	if u.IsStranger || (homeTeam != "" && u.TeamID != "" && u.TeamID != homeTeam) {
+		return External
+	}
+	if u.IsRestricted || u.IsUltraRestricted {
+		return Guest
+	}
+	return Member
+}
+
+// Policy is applied to tickets from guests and external users and to anything
+// echoed back into channels they can read
+type Policy struct {
+	// Queue receives tickets from guests and external users, empty leaves the
+	// queue unchanged
+	Queue string
+	// InternalDomains are the hosts, and their subdomains, whose links are
+	// removed from replies
+	InternalDomains []string
+}
+
+// Apply restricts a new ticket from audience a. Fields such as priority,
+// assignee and tags can not be chosen from outside the organisation.
+func (p Policy) Apply(t *ticket.Ticket, a Audience) {
+	if a == Member {
+		return
+	}
+	if p.Queue != "" {
+		t.Queue = p.Queue
+	}
+	t.Priority = 0
+	t.Assignee = ""
+	t.Tags = []string{a.String()}
+}
+
+var slackLink = regexp.MustCompile(`<(https?://[^|>]+)(\|([^>]*))?>|https?://[^\s<>]+`)
+
+// Redact removes links to internal domains from text. Slack formatted links
+// keep their label.
+func (p Policy) Redact(text string) string {
+	return slackLink.ReplaceAllStringFunc(text, func(link string) string {
+		m := slackLink.FindStringSubmatch(link)
+		u := m[1]
+		if u == "" {
+			u = m[0]
+		}
+		if !p.internal(u) {
+			return link
+		}
+		if m[3] != "" {
+			return m[3] + " [internal link removed]"
+		}
+		return "[internal link removed]"
+	})
+}
+
+func (p Policy) internal(u string) bool {
+	host := strings.TrimPrefix(strings.TrimPrefix(u, "https://"), "http://")
+	if i := strings.IndexAny(host, "/:?#"); i >= 0 {
+		host = host[:i]
+	}
+	host = strings.ToLower(host)
+	for _, d := range p.InternalDomains {
+		d = strings.ToLower(d)
+		if host == d || strings.HasSuffix(host, "."+d) {
+			return true
+		}
+	}
+	return false
+}
+
+// PublicComments returns the comments which may be shown outside the
+// organisation, with internal links redacted
+func (p Policy) PublicComments(comments []ticket.Comment) []ticket.Comment {
+	var public []ticket.Comment
+	for _, c := range comments {
+		if c.Internal {
+			continue
+		}
+		c.Text = p.Redact(c.Text)
+		public = append(public, c)
+	}
+	return public
+}
@@ -0,0 +1,64 @@
+package intake
+
+import (
+	"testing"
+
+	"github.com/nlopes/slack"
+
+	"github.com/skybet/go-helpdesk/ticket"
+)
+
+func TestClassify(t *testing.T) {
+	tt := []struct {
+		user slack.User
+		want Audience
+	}{
+		{slack.User{TeamID: "T1"}, Member},
+		{slack.User{TeamID: "T1", IsRestricted: true}, Guest},
+		{slack.User{TeamID: "T1", IsUltraRestricted: true}, Guest},
+		{slack.User{TeamID: "T2"}, External},
+		{slack.User{IsStranger: true}, External},
+	}
+	for _, tc := range tt {
+		if got := Classify(&tc.user, "T1"); got != tc.want {
+			t.Errorf("Classify(%+v) = %s, want %s", tc.user, got, tc.want)
+		}
+	}
+}
+
+func TestPolicyApply(t *testing.T) {
+	p := Policy{Queue: "external"}
+	tk := &ticket.Ticket{Queue: "it", Priority: ticket.P1, Assignee: "U1", Tags: []string{"vip"}}
+	p.Apply(tk, Member)
+	if tk.Queue != "it" || tk.Priority != ticket.P1 {
+		t.Errorf("Expected member tickets to be unchanged, got %+v", tk)
+	}
+	p.Apply(tk, External)
+	if tk.Queue != "external" || tk.Priority != 0 || tk.Assignee != "" || !tk.HasTag("external") || tk.HasTag("vip") {
+		t.Errorf("Expected external tickets to be restricted, got %+v", tk)
+	}
+}
+
+func TestPolicyRedact(t *testing.T) {
+	p := Policy{InternalDomains: []string{"corp.example.com"}}
+	tt := map[string]string{
+		"See https://wiki.corp.example.com/vpn for details": "See [internal link removed] for details",
+		"See <https://corp.example.com/vpn|the VPN guide>":  "See the VPN guide [internal link removed]",
+		"See <https://wiki.corp.example.com:8443/x>":        "See [internal link removed]",
+		"See https://example.com/status":                    "See https://example.com/status",
+		"See <https://notcorp.example.com.evil.io/x|this>":  "See <https://notcorp.example.com.evil.io/x|this>",
+	}
+	for in, want := range tt {
+		if got := p.Redact(in); got != want {
+			t.Errorf("Redact(%q) = %q, want %q", in, got, want)
+		}
+	}
+
+	comments := p.PublicComments([]ticket.Comment{
+		{Text: "Reporter is on the naughty list", Internal: true},
+		{Text: "Try https://corp.example.com/reset"},
+	})
+	if len(comments) != 1 || comments[0].Text != "Try [internal link removed]" {
+		t.Errorf("Expected only redacted public comments, got %+v", comments)
+	}
+}
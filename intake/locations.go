@@ -0,0 +1,229 @@
+package intake
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/skybet/go-helpdesk/atomicfile"
+	"github.com/skybet/go-helpdesk/ticket"
+)
+
+// codeAlphabet leaves out letters and digits which are easily mistaken for
+// each other, so codes can be typed from a printed label
+const codeAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+// codeLength gives about 10^9 codes
+const codeLength = 6
+
+// Location is a physical place, such as a printer room, with a short code
+// printed on a label or in a QR code. Reports made with the code are
+// prefilled with the location's details.
+type Location struct {
+	Code string `json:"code"`
+	Name string `json:"name"`
+	// Queue receives the location's tickets, empty for the default queue
+	Queue string `json:"queue,omitempty"`
+	// Channel is where the location's tickets are posted, their thread
+	// becomes the ticket's thread
+	Channel string `json:"channel"`
+	// Fields are details added to every ticket, such as building=HQ or
+	// asset=PRN-0042
+	Fields map[string]string `json:"fields,omitempty"`
+}
+
+// Apply routes a new ticket from the location to its queue and adds the
+// location's details to its description
+func (l Location) Apply(t *ticket.Ticket) {
+	if l.Queue != "" {
+		t.Queue = l.Queue
+	}
+	lines := []string{fmt.Sprintf("Location: %s (%s)", l.Name, l.Code)}
+	for _, k := range l.fieldNames() {
+		lines = append(lines, fmt.Sprintf("%s: %s", k, l.Fields[k]))
+	}
+	t.Description = strings.TrimSpace(t.Description + "\n\n" + strings.Join(lines, "\n"))
+}
+
+// fieldNames returns the names of the location's fields in order
+func (l Location) fieldNames() []string {
+	names := make([]string, 0, len(l.Fields))
+	for k := range l.Fields {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// validate checks the location's details, its code is checked when it is put
+// in a registry as Add makes one up
+func (l Location) validate() error {
+	if l.Name == "" || l.Channel == "" {
+		return fmt.Errorf("locations need a name and a channel")
+	}
+	return nil
+}
+
+// NewCode returns a random short code
+func NewCode() (string, error) {
+	b := make([]byte, codeLength)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("error generating code: %s", err)
+	}
+	for i := range b {
+		b[i] = codeAlphabet[int(b[i])%len(codeAlphabet)]
+	}
+	return string(b), nil
+}
+
+// Locations is the registry of locations by code. It is safe for concurrent
+// use, and changes are saved to its file if it has one.
+type Locations struct {
+	path      string
+	mu        sync.RWMutex
+	locations map[string]Location
+}
+
+// NewLocations returns a registry of locations which is not saved anywhere
+func NewLocations(locations ...Location) *Locations {
+	l := &Locations{locations: map[string]Location{}}
+	for _, loc := range locations {
+		l.locations[normalCode(loc.Code)] = loc
+	}
+	return l
+}
+
+// LoadLocations returns the registry saved in the JSON file at path, which is
+// created by the first change if it does not exist
+func LoadLocations(path string) (*Locations, error) {
+	l := NewLocations()
+	l.path = path
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return l, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading locations: %s", err)
+	}
+	var locations []Location
+	if err := json.Unmarshal(b, &locations); err != nil {
+		return nil, fmt.Errorf("error decoding locations in %s: %s", path, err)
+	}
+	for _, loc := range locations {
+		if loc.Code == "" {
+			return nil, fmt.Errorf("invalid location in %s: missing code", path)
+		}
+		if err := loc.validate(); err != nil {
+			return nil, fmt.Errorf("invalid location in %s: %s", path, err)
+		}
+		l.locations[normalCode(loc.Code)] = loc
+	}
+	return l, nil
+}
+
+// normalCode makes codes case insensitive
+func normalCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// Get returns the location with a code, ignoring case
+func (l *Locations) Get(code string) (Location, bool) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	loc, ok := l.locations[normalCode(code)]
+	return loc, ok
+}
+
+// List returns every location ordered by code
+func (l *Locations) List() []Location {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.list()
+}
+
+func (l *Locations) list() []Location {
+	locations := make([]Location, 0, len(l.locations))
+	for _, loc := range l.locations {
+		locations = append(locations, loc)
+	}
+	sort.Slice(locations, func(i, j int) bool { return locations[i].Code < locations[j].Code })
+	return locations
+}
+
+// Add adds a new location with a code nobody else has, returning it with
+// its code
+func (l *Locations) Add(loc Location) (Location, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for {
+		code, err := NewCode()
+		if err != nil {
+			return loc, err
+		}
+		if _, taken := l.locations[code]; !taken {
+			loc.Code = code
+			break
+		}
+	}
+	return loc, l.put(loc)
+}
+
+// Put adds or replaces the location with loc's code
+func (l *Locations) Put(loc Location) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.put(loc)
+}
+
+func (l *Locations) put(loc Location) error {
+	loc.Code = normalCode(loc.Code)
+	if loc.Code == "" {
+		return fmt.Errorf("missing code")
+	}
+	if err := loc.validate(); err != nil {
+		return err
+	}
+	old, existed := l.locations[loc.Code]
+	l.locations[loc.Code] = loc
+	if err := l.save(); err != nil {
+		if existed {
+			l.locations[loc.Code] = old
+		} else {
+			delete(l.locations, loc.Code)
+		}
+		return err
+	}
+	return nil
+}
+
+// Delete removes a location, reporting whether there was one
+func (l *Locations) Delete(code string) (bool, error) {
+	code = normalCode(code)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	old, ok := l.locations[code]
+	if !ok {
+		return false, nil
+	}
+	delete(l.locations, code)
+	if err := l.save(); err != nil {
+		l.locations[code] = old
+		return false, err
+	}
+	return true, nil
+}
+
+func (l *Locations) save() error {
+	if l.path == "" {
+		return nil
+	}
+	if err := atomicfile.WriteJSON(l.path, l.list()); err != nil {
+		return fmt.Errorf("error saving locations: %s", err)
+	}
+	return nil
+}
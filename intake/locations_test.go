@@ -0,0 +1,73 @@
+package intake
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/skybet/go-helpdesk/ticket"
+)
+
+func TestLocationApply(t *testing.T) {
+	l := Location{Code: "PRN2FL", Name: "Printer room, 2nd floor", Queue: "facilities", Channel: "C1", Fields: map[string]string{"building": "HQ", "asset": "PRN-0042"}}
+	tk := &ticket.Ticket{Description: "Paper jam"}
+	l.Apply(tk)
+	want := "Paper jam\n\nLocation: Printer room, 2nd floor (PRN2FL)\nasset: PRN-0042\nbuilding: HQ"
+	if tk.Queue != "facilities" || tk.Description != want {
+		t.Errorf("Expected the ticket to be routed with the location's details, got %+v", tk)
+	}
+}
+
+func TestNewCode(t *testing.T) {
+	seen := map[string]bool{}
+	for i := 0; i < 100; i++ {
+		code, err := NewCode()
+		if err != nil || len(code) != codeLength || strings.Trim(code, codeAlphabet) != "" {
+			t.Fatalf("Expected a code from the alphabet, got %q %v", code, err)
+		}
+		seen[code] = true
+	}
+	if len(seen) < 99 {
+		t.Errorf("Expected codes to be random, got %d distinct codes", len(seen))
+	}
+}
+
+func TestLocations(t *testing.T) {
+	dir, err := ioutil.TempDir("", "locations")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "locations.json")
+
+	l, err := LoadLocations(path)
+	if err != nil || len(l.List()) != 0 {
+		t.Fatalf("Expected a missing file to be an empty registry, got %v %v", l, err)
+	}
+	added, err := l.Add(Location{Name: "Printer room", Channel: "C1"})
+	if err != nil || added.Code == "" {
+		t.Fatalf("Expected the location to be given a code, got %+v %v", added, err)
+	}
+	if err := l.Put(Location{Code: "lobby", Name: "Lobby", Channel: "C2"}); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if err := l.Put(Location{Code: "CAFE", Name: "Cafe"}); err == nil {
+		t.Errorf("Expected a location without a channel to be refused")
+	}
+	if loc, ok := l.Get("Lobby"); !ok || loc.Code != "LOBBY" {
+		t.Errorf("Expected codes to ignore case, got %+v %v", loc, ok)
+	}
+	if ok, err := l.Delete("lobby"); !ok || err != nil {
+		t.Errorf("Expected the lobby to be deleted, got %v %v", ok, err)
+	}
+
+	loaded, err := LoadLocations(path)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if locations := loaded.List(); len(locations) != 1 || locations[0].Code != added.Code {
+		t.Errorf("Expected the changes to be saved, got %+v", locations)
+	}
+}
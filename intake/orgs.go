@@ -0,0 +1,188 @@
+package intake
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/skybet/go-helpdesk/atomicfile"
+	"github.com/skybet/go-helpdesk/ticket"
+)
+
+// Share is how much of a ticket is posted back into channels an external
+// organisation can read
+type Share string
+
+// Shares, from most to least
+const (
+	// ShareSummary posts the ticket's title and public details, with
+	// internal links redacted
+	ShareSummary Share = "summary"
+	// ShareReference posts only the ticket's reference and status
+	ShareReference Share = "reference"
+)
+
+// Org is the policy for tickets from users of one Slack Connect organisation,
+// it is applied on top of the guest Policy
+type Org struct {
+	// TeamID is the ID of the organisation's workspace
+	TeamID string `json:"team_id"`
+	Name   string `json:"name,omitempty"`
+	// Queue receives the organisation's tickets, empty leaves them in the
+	// guest queue
+	Queue string `json:"queue,omitempty"`
+	// Categories are the tags the organisation's users may give a ticket
+	// with a hashtag in their message, such as #billing. Any other hashtag
+	// is ignored.
+	Categories []string `json:"categories,omitempty"`
+	Share      Share    `json:"share,omitempty"`
+}
+
+var hashtag = regexp.MustCompile(`(?:^|\s)#([\w-]+)`)
+
+// Apply routes a new ticket from the organisation, created from text, to its
+// queue and tags it with the allowed categories text mentions
+func (o Org) Apply(t *ticket.Ticket, text string) {
+	if o.Queue != "" {
+		t.Queue = o.Queue
+	}
+	for _, m := range hashtag.FindAllStringSubmatch(text, -1) {
+		for _, c := range o.Categories {
+			if strings.EqualFold(m[1], c) && !t.HasTag(c) {
+				t.Tags = append(t.Tags, c)
+			}
+		}
+	}
+}
+
+// Orgs is the registry of Slack Connect organisations with their own policy.
+// It is safe for concurrent use, and changes are saved to its file if it has
+// one.
+type Orgs struct {
+	path string
+	mu   sync.RWMutex
+	orgs map[string]Org
+}
+
+// NewOrgs returns a registry of orgs which is not saved anywhere
+func NewOrgs(orgs ...Org) *Orgs {
+	o := &Orgs{orgs: map[string]Org{}}
+	for _, org := range orgs {
+		o.orgs[org.TeamID] = org
+	}
+	return o
+}
+
+// LoadOrgs returns the registry saved in the JSON file at path, which is
+// created by the first change if it does not exist
+func LoadOrgs(path string) (*Orgs, error) {
+	o := NewOrgs()
+	o.path = path
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return o, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading organisations: %s", err)
+	}
+	var orgs []Org
+	if err := json.Unmarshal(b, &orgs); err != nil {
+		return nil, fmt.Errorf("error decoding organisations in %s: %s", path, err)
+	}
+	for _, org := range orgs {
+		if err := org.validate(); err != nil {
+			return nil, fmt.Errorf("invalid organisation in %s: %s", path, err)
+		}
+		o.orgs[org.TeamID] = org
+	}
+	return o, nil
+}
+
+func (o Org) validate() error {
+	if o.TeamID == "" {
+		return fmt.Errorf("missing team ID")
+	}
+	switch o.Share {
+	case "", ShareSummary, ShareReference:
+		return nil
+	}
+	return fmt.Errorf("unknown share %q for %s, expected %s or %s", o.Share, o.TeamID, ShareSummary, ShareReference)
+}
+
+// Get returns the policy for the organisation with the workspace teamID
+func (o *Orgs) Get(teamID string) (Org, bool) {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	org, ok := o.orgs[teamID]
+	return org, ok
+}
+
+// List returns every organisation ordered by team ID
+func (o *Orgs) List() []Org {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	return o.list()
+}
+
+func (o *Orgs) list() []Org {
+	orgs := make([]Org, 0, len(o.orgs))
+	for _, org := range o.orgs {
+		orgs = append(orgs, org)
+	}
+	sort.Slice(orgs, func(i, j int) bool { return orgs[i].TeamID < orgs[j].TeamID })
+	return orgs
+}
+
+// Put adds or replaces the policy for an organisation
+func (o *Orgs) Put(org Org) error {
+	if err := org.validate(); err != nil {
+		return err
+	}
+	if org.Share == "" {
+		org.Share = ShareSummary
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	old, existed := o.orgs[org.TeamID]
+	o.orgs[org.TeamID] = org
+	if err := o.save(); err != nil {
+		if existed {
+			o.orgs[org.TeamID] = old
+		} else {
+			delete(o.orgs, org.TeamID)
+		}
+		return err
+	}
+	return nil
+}
+
+// Delete removes the policy for an organisation, reporting whether it had one
+func (o *Orgs) Delete(teamID string) (bool, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	old, ok := o.orgs[teamID]
+	if !ok {
+		return false, nil
+	}
+	delete(o.orgs, teamID)
+	if err := o.save(); err != nil {
+		o.orgs[teamID] = old
+		return false, err
+	}
+	return true, nil
+}
+
+func (o *Orgs) save() error {
+	if o.path == "" {
+		return nil
+	}
+	if err := atomicfile.WriteJSON(o.path, o.list()); err != nil {
+		return fmt.Errorf("error saving organisations: %s", err)
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package intake
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/skybet/go-helpdesk/ticket"
+)
+
+func TestOrgApply(t *testing.T) {
+	org := Org{TeamID: "T2", Queue: "acme", Categories: []string{"billing", "outage"}}
+	tk := &ticket.Ticket{Queue: "external", Tags: []string{"external"}}
+	org.Apply(tk, "#Billing is wrong again #urgent, see also #billing and issue#42")
+	if tk.Queue != "acme" || len(tk.Tags) != 2 || !tk.HasTag("billing") {
+		t.Errorf("Expected the ticket to be routed and only allowed categories tagged, got %+v", tk)
+	}
+}
+
+func TestOrgs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "orgs")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "orgs.json")
+
+	o, err := LoadOrgs(path)
+	if err != nil || len(o.List()) != 0 {
+		t.Fatalf("Expected a missing file to be an empty registry, got %v %v", o, err)
+	}
+	if err := o.Put(Org{TeamID: "T2", Name: "Acme", Queue: "acme"}); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if err := o.Put(Org{TeamID: "T3", Share: ShareReference}); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if err := o.Put(Org{TeamID: "T4", Share: "everything"}); err == nil {
+		t.Errorf("Expected an unknown share to be refused")
+	}
+	if ok, err := o.Delete("T3"); !ok || err != nil {
+		t.Errorf("Expected T3 to be deleted, got %v %v", ok, err)
+	}
+	if ok, _ := o.Delete("T3"); ok {
+		t.Errorf("Expected deleting a missing organisation to report it")
+	}
+
+	loaded, err := LoadOrgs(path)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	orgs := loaded.List()
+	if len(orgs) != 1 || orgs[0].Name != "Acme" || orgs[0].Share != ShareSummary {
+		t.Errorf("Expected the changes to be saved, got %+v", orgs)
+	}
+
+	ioutil.WriteFile(path, []byte(`[{"name":"No team"}]`), 0600)
+	if _, err := LoadOrgs(path); err == nil {
+		t.Errorf("Expected an organisation without a team ID to be refused")
+	}
+}
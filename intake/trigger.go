@@ -0,0 +1,99 @@
+// Package intake decides which Slack messages should become tickets
+package intake
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/nlopes/slack/slackevents"
+)
+
+// Trigger matches messages which should automatically become tickets
+type Trigger struct {
+	re    *regexp.Regexp
+	strip bool
+}
+
+// Prefix matches messages starting with p, ignoring case. The prefix is
+// removed from the ticket text.
+func Prefix(p string) Trigger {
+	return Trigger{re: regexp.MustCompile(`(?i)^\s*` + regexp.QuoteMeta(p) + `\s*`), strip: true}
+}
+
+// Keyword matches messages containing the word k, ignoring case
+func Keyword(k string) Trigger {
+	return Trigger{re: regexp.MustCompile(`(?i)\b` + regexp.QuoteMeta(k) + `\b`)}
+}
+
+// Pattern matches messages matching the regular expression expr
+func Pattern(expr string) (Trigger, error) {
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return Trigger{}, fmt.Errorf("invalid trigger pattern %q: %s", expr, err)
+	}
+	return Trigger{re: re}, nil
+}
+
+// ParseTrigger parses a trigger from configuration in the form prefix:<text>,
+// keyword:<word> or regex:<expression>
+func ParseTrigger(s string) (Trigger, error) {
+	parts := strings.SplitN(s, ":", 2)
+	if len(parts) != 2 || parts[1] == "" {
+		return Trigger{}, fmt.Errorf("invalid trigger %q, expected prefix:, keyword: or regex:", s)
+	}
+	switch parts[0] {
+	case "prefix":
+		return Prefix(parts[1]), nil
+	case "keyword":
+		return Keyword(parts[1]), nil
+	case "regex":
+		return Pattern(parts[1])
+	}
+	return Trigger{}, fmt.Errorf("unknown trigger type %q", parts[0])
+}
+
+// Match reports whether text triggers a ticket, returning the text the ticket
+// should be created with
+func (t Trigger) Match(text string) (string, bool) {
+	loc := t.re.FindStringIndex(text)
+	if loc == nil {
+		return "", false
+	}
+	if t.strip && loc[0] == 0 {
+		text = text[loc[1]:]
+	}
+	return strings.TrimSpace(text), true
+}
+
+// Watcher applies triggers to the messages posted in a set of channels
+type Watcher struct {
+	channels map[string]bool
+	triggers []Trigger
+}
+
+// NewWatcher returns a Watcher applying triggers to messages in channels
+func NewWatcher(channels []string, triggers ...Trigger) *Watcher {
+	w := &Watcher{channels: map[string]bool{}, triggers: triggers}
+	for _, c := range channels {
+		w.channels[c] = true
+	}
+	return w
+}
+
+// Match returns the ticket text for a message which should become a ticket.
+// Only new top level messages from users in watched channels are considered.
+func (w *Watcher) Match(ev *slackevents.MessageEvent) (string, bool) {
+	if !w.channels[ev.Channel] || ev.BotID != "" || (ev.SubType != "" && ev.SubType != "file_share") {
+		return "", false
+	}
+	if ev.ThreadTimeStamp != "" && ev.ThreadTimeStamp != ev.TimeStamp {
+		return "", false
+	}
+	for _, t := range w.triggers {
+		if text, ok := t.Match(ev.Text); ok && text != "" {
+			return text, true
+		}
+	}
+	return "", false
+}
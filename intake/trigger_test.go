@@ -0,0 +1,58 @@
+package intake
+
+import (
+	"testing"
+
+	"github.com/nlopes/slack/slackevents"
+)
+
+func TestParseTrigger(t *testing.T) {
+	tt := []struct {
+		trigger string
+		text    string
+		want    string
+		match   bool
+	}{
+		{"prefix:help:", "Help: my laptop is on fire", "my laptop is on fire", true},
+		{"prefix:help:", "can someone help: please", "", false},
+		{"keyword:outage", "Is there an OUTAGE right now?", "Is there an OUTAGE right now?", true},
+		{"keyword:outage", "outages are fun", "", false},
+		{"regex:^!ticket\\b", "!ticket printer jammed", "!ticket printer jammed", true},
+	}
+	for _, tc := range tt {
+		trigger, err := ParseTrigger(tc.trigger)
+		if err != nil {
+			t.Fatalf("Unexpected error parsing %s: %s", tc.trigger, err)
+		}
+		text, ok := trigger.Match(tc.text)
+		if ok != tc.match || text != tc.want {
+			t.Errorf("%s matching %q: got %q %v, want %q %v", tc.trigger, tc.text, text, ok, tc.want, tc.match)
+		}
+	}
+	for _, bad := range []string{"help:", "prefix:", "regex:(", "glob:*"} {
+		if _, err := ParseTrigger(bad); err == nil {
+			t.Errorf("Expected an error parsing %q", bad)
+		}
+	}
+}
+
+func TestWatcherMatch(t *testing.T) {
+	w := NewWatcher([]string{"C1"}, Prefix("help:"))
+	tt := []struct {
+		ev    slackevents.MessageEvent
+		match bool
+	}{
+		{slackevents.MessageEvent{Channel: "C1", Text: "help: VPN", TimeStamp: "1.1"}, true},
+		{slackevents.MessageEvent{Channel: "C2", Text: "help: VPN", TimeStamp: "1.1"}, false},
+		{slackevents.MessageEvent{Channel: "C1", SubType: "file_share", Text: "help: VPN", TimeStamp: "1.1"}, true},
+		{slackevents.MessageEvent{Channel: "C1", SubType: "message_changed", Text: "help: VPN", TimeStamp: "1.1"}, false},
+		{slackevents.MessageEvent{Channel: "C1", Text: "help: VPN", TimeStamp: "1.2", ThreadTimeStamp: "1.1"}, false},
+		{slackevents.MessageEvent{Channel: "C1", Text: "help: VPN", TimeStamp: "1.1", BotID: "B1"}, false},
+		{slackevents.MessageEvent{Channel: "C1", Text: "help:", TimeStamp: "1.1"}, false},
+	}
+	for i, tc := range tt {
+		if _, ok := w.Match(&tc.ev); ok != tc.match {
+			t.Errorf("Case %d: expected match %v", i, tc.match)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package intake
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/nlopes/slack"
+
+	"github.com/skybet/go-helpdesk/ticket"
+)
+
+// VIPTag is added to tickets reported by VIPs
+const VIPTag = "vip"
+
+// VIPs identifies reporters whose tickets are handled ahead of others, either
+// by user ID or by their job title in Slack
+type VIPs struct {
+	// Queue receives VIP tickets, empty leaves the queue unchanged
+	Queue string
+	// Priority is the lowest priority a VIP ticket can have
+	Priority ticket.Priority
+	// Channel is notified of every VIP ticket
+	Channel string
+
+	ids    map[string]bool
+	titles *regexp.Regexp
+}
+
+// NewVIPs returns VIPs matching the users in ids and any user whose profile
+// title matches titlePattern, if it is not empty
+func NewVIPs(ids []string, titlePattern string) (*VIPs, error) {
+	v := &VIPs{Priority: ticket.P2, ids: map[string]bool{}}
+	for _, id := range ids {
+		v.ids[id] = true
+	}
+	if titlePattern != "" {
+		re, err := regexp.Compile(titlePattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid VIP title pattern %q: %s", titlePattern, err)
+		}
+		v.titles = re
+	}
+	return v, nil
+}
+
+// Is reports whether u is a VIP
+func (v *VIPs) Is(u *slack.User) bool {
+	if v.ids[u.ID] {
+		return true
+	}
+	return v.titles != nil && u.Profile.Title != "" && v.titles.MatchString(u.Profile.Title)
+}
+
+// Apply boosts a VIP's ticket to at least Priority, moves it to Queue and tags
+// it with VIPTag
+func (v *VIPs) Apply(t *ticket.Ticket) {
+	if t.Priority == 0 || t.Priority > v.Priority {
+		t.Priority = v.Priority
+	}
+	if v.Queue != "" {
+		t.Queue = v.Queue
+	}
+	if !t.HasTag(VIPTag) {
+		t.Tags = append(t.Tags, VIPTag)
+	}
+}
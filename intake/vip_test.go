@@ -0,0 +1,40 @@
+package intake
+
+import (
+	"testing"
+
+	"github.com/nlopes/slack"
+
+	"github.com/skybet/go-helpdesk/ticket"
+)
+
+func TestVIPs(t *testing.T) {
+	v, err := NewVIPs([]string{"UCEO"}, `(?i)\b(director|vp)\b`)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	ceo := &slack.User{ID: "UCEO"}
+	director := &slack.User{ID: "U2"}
+	director.Profile.Title = "Director of Engineering"
+	dev := &slack.User{ID: "U3"}
+	dev.Profile.Title = "Developer"
+	if !v.Is(ceo) || !v.Is(director) || v.Is(dev) {
+		t.Errorf("Unexpected VIP classification")
+	}
+
+	v.Queue = "senior"
+	tk := &ticket.Ticket{Queue: "it", Priority: ticket.P4}
+	v.Apply(tk)
+	if tk.Priority != ticket.P2 || tk.Queue != "senior" || !tk.HasTag(VIPTag) {
+		t.Errorf("Expected the ticket to be boosted, got %+v", tk)
+	}
+	tk.Priority = ticket.P1
+	v.Apply(tk)
+	if tk.Priority != ticket.P1 || len(tk.Tags) != 1 {
+		t.Errorf("Expected a higher priority to be kept, got %+v", tk)
+	}
+
+	if _, err := NewVIPs(nil, "("); err == nil {
+		t.Errorf("Expected an error for an invalid pattern")
+	}
+}
@@ -0,0 +1,110 @@
+package jira
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Client calls the parts of Jira's REST API used to mirror tickets
+type Client struct {
+	URL   string
+	User  string
+	Token string
+	// HTTP sends the requests, http.DefaultClient if nil
+	HTTP *http.Client
+}
+
+// CreateIssue creates an issue with fields, returning its key
+func (c *Client) CreateIssue(ctx context.Context, fields map[string]interface{}) (string, error) {
+	var created struct {
+		Key string `json:"key"`
+	}
+	if err := c.do(ctx, http.MethodPost, "/rest/api/2/issue", map[string]interface{}{"fields": fields}, &created); err != nil {
+		return "", fmt.Errorf("error creating issue: %s", err)
+	}
+	return created.Key, nil
+}
+
+// Transition takes the issue's transition with the given name, ignoring case.
+// It is an error if the issue has no such transition from its status.
+func (c *Client) Transition(ctx context.Context, key, name string) error {
+	var available struct {
+		Transitions []struct {
+			ID   string `json:"id"`
+			Name string `json:"name"`
+		} `json:"transitions"`
+	}
+	path := "/rest/api/2/issue/" + url.PathEscape(key) + "/transitions"
+	if err := c.do(ctx, http.MethodGet, path, nil, &available); err != nil {
+		return fmt.Errorf("error listing transitions of %s: %s", key, err)
+	}
+	for _, t := range available.Transitions {
+		if strings.EqualFold(t.Name, name) {
+			if err := c.do(ctx, http.MethodPost, path, map[string]interface{}{"transition": map[string]string{"id": t.ID}}, nil); err != nil {
+				return fmt.Errorf("error moving %s: %s", key, err)
+			}
+			return nil
+		}
+	}
+	return fmt.Errorf("%s has no transition %q", key, name)
+}
+
+// AddComment comments on the issue, returning the comment's ID
+func (c *Client) AddComment(ctx context.Context, key, body string) (string, error) {
+	var created struct {
+		ID string `json:"id"`
+	}
+	if err := c.do(ctx, http.MethodPost, "/rest/api/2/issue/"+url.PathEscape(key)+"/comment", map[string]string{"body": body}, &created); err != nil {
+		return "", fmt.Errorf("error commenting on %s: %s", key, err)
+	}
+	return created.ID, nil
+}
+
+// do sends body as JSON and decodes the response into out, if it is not nil
+func (c *Client) do(ctx context.Context, method, path string, body, out interface{}) error {
+	var r io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		r = bytes.NewReader(b)
+	}
+	req, err := http.NewRequest(method, strings.TrimSuffix(c.URL, "/")+path, r)
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.SetBasicAuth(c.User, c.Token)
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	client := c.HTTP
+	if client == nil {
+		client = http.DefaultClient
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode/100 != 2 {
+		msg, _ := ioutil.ReadAll(io.LimitReader(res.Body, 512))
+		return fmt.Errorf("Jira returned %s: %s", res.Status, strings.TrimSpace(string(msg)))
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(res.Body).Decode(out); err != nil {
+		return fmt.Errorf("error decoding response: %s", err)
+	}
+	return nil
+}
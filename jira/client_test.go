@@ -0,0 +1,91 @@
+package jira
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeJira serves the parts of Jira's REST API the Client calls
+type fakeJira struct {
+	mu       sync.Mutex
+	issues   map[string]map[string]interface{}
+	statuses map[string]string
+	comments map[string][]string
+}
+
+func newFakeJira() *fakeJira {
+	return &fakeJira{issues: map[string]map[string]interface{}{}, statuses: map[string]string{}, comments: map[string][]string{}}
+}
+
+func (f *fakeJira) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if user, token, _ := r.BasicAuth(); user != "helpdesk@example.com" || token != "secret" {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/rest/api/2/issue"), "/")
+	switch {
+	case len(parts) == 1 && r.Method == http.MethodPost:
+		var body struct {
+			Fields map[string]interface{} `json:"fields"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		key := "HD-" + string(rune('0'+len(f.issues)+1))
+		f.issues[key], f.statuses[key] = body.Fields, "To Do"
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]string{"id": "1000", "key": key})
+	case len(parts) == 3 && parts[2] == "transitions" && r.Method == http.MethodGet:
+		w.Write([]byte(`{"transitions": [{"id": "11", "name": "Start Progress"}, {"id": "31", "name": "Done"}]}`))
+	case len(parts) == 3 && parts[2] == "transitions" && r.Method == http.MethodPost:
+		var body struct {
+			Transition struct {
+				ID string `json:"id"`
+			} `json:"transition"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		f.statuses[parts[1]] = map[string]string{"11": "In Progress", "31": "Done"}[body.Transition.ID]
+		w.WriteHeader(http.StatusNoContent)
+	case len(parts) == 3 && parts[2] == "comment" && r.Method == http.MethodPost:
+		var body struct {
+			Body string `json:"body"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		f.comments[parts[1]] = append(f.comments[parts[1]], body.Body)
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]string{"id": string(rune('0' + len(f.comments[parts[1]])))})
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func TestClient(t *testing.T) {
+	jira := newFakeJira()
+	srv := httptest.NewServer(jira)
+	defer srv.Close()
+	c := &Client{URL: srv.URL + "/", User: "helpdesk@example.com", Token: "secret"}
+	ctx := context.Background()
+
+	key, err := c.CreateIssue(ctx, map[string]interface{}{"summary": "VPN down"})
+	if err != nil || key != "HD-1" || jira.issues["HD-1"]["summary"] != "VPN down" {
+		t.Fatalf("Expected the issue to be created, got %q %v", key, err)
+	}
+	if err := c.Transition(ctx, key, "done"); err != nil || jira.statuses[key] != "Done" {
+		t.Errorf("Expected the issue to be moved, got %q %v", jira.statuses[key], err)
+	}
+	if err := c.Transition(ctx, key, "Reopen"); err == nil || !strings.Contains(err.Error(), `no transition "Reopen"`) {
+		t.Errorf("Expected a missing transition to fail, got %v", err)
+	}
+	if id, err := c.AddComment(ctx, key, "Have you tried turning it off?"); err != nil || id != "1" || jira.comments[key][0] != "Have you tried turning it off?" {
+		t.Errorf("Expected the comment to be added, got %q %v", id, err)
+	}
+	c.Token = "wrong"
+	if _, err := c.CreateIssue(ctx, nil); err == nil || !strings.Contains(err.Error(), "401") {
+		t.Errorf("Expected Jira's error to be returned, got %v", err)
+	}
+}
@@ -0,0 +1,155 @@
+// Package jira mirrors tickets into Jira issues, for teams who report on their
+// work there. Each ticket gets an issue which is moved along with the ticket's
+// status and sent its comments, and Jira's webhooks bring comments and status
+// changes made in Jira back to the ticket's Slack thread.
+package jira
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	yaml "gopkg.in/yaml.v2"
+
+	"github.com/skybet/go-helpdesk/ticket"
+)
+
+// Config maps tickets onto a Jira instance
+type Config struct {
+	// URL is the Jira instance, such as https://example.atlassian.net, and
+	// User and Token the account and API token issues are written as
+	URL   string `yaml:"url"`
+	User  string `yaml:"user"`
+	Token string `yaml:"token"`
+	// Project is the key of the project issues are created in, unless the
+	// ticket's queue is in Projects
+	Project  string            `yaml:"project"`
+	Projects map[string]string `yaml:"projects"`
+	// IssueType is the type of the issues created, Task if empty
+	IssueType string `yaml:"issue_type"`
+	// Priorities are the names of the Jira priorities of each ticket
+	// priority, such as P1: Highest. Unmapped priorities are left to Jira.
+	Priorities map[string]string `yaml:"priorities"`
+	// Transitions are the names of the Jira transitions to take when a
+	// ticket moves to each status, such as resolved: Done. Issues are left
+	// where they are for unmapped statuses.
+	Transitions map[string]string `yaml:"transitions"`
+	// Statuses are the ticket statuses each Jira status moves a ticket to
+	// when its issue is moved in Jira, such as Done: resolved
+	Statuses map[string]string `yaml:"statuses"`
+	// Fields are the IDs of custom fields, such as customfield_10010, to set
+	// to the ticket's queue, reporter or ID
+	Fields map[string]string `yaml:"fields"`
+	// WebhookSecret verifies the signature of Jira's webhooks, which are
+	// refused without it
+	WebhookSecret string `yaml:"webhook_secret"`
+}
+
+// fieldValues are the ticket values Fields can map to custom fields
+var fieldValues = map[string]func(t *ticket.Ticket) string{
+	"queue":    func(t *ticket.Ticket) string { return t.Queue },
+	"reporter": func(t *ticket.Ticket) string { return t.Reporter },
+	"id":       func(t *ticket.Ticket) string { return t.ID },
+}
+
+// LoadConfig reads the configuration from a YAML file
+func LoadConfig(path string) (*Config, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading Jira config: %s", err)
+	}
+	var c Config
+	if err := yaml.UnmarshalStrict(b, &c); err != nil {
+		return nil, fmt.Errorf("error parsing Jira config %s: %s", path, err)
+	}
+	if err := c.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid Jira config %s: %s", path, err)
+	}
+	return &c, nil
+}
+
+// Validate checks the configuration names a Jira instance and project, and
+// that its mappings are of known priorities, statuses and fields
+func (c *Config) Validate() error {
+	if c.URL == "" || c.Project == "" {
+		return fmt.Errorf("url and project are required")
+	}
+	for p := range c.Priorities {
+		if _, err := ticket.ParsePriority(p); err != nil {
+			return err
+		}
+	}
+	for s := range c.Transitions {
+		if _, err := ticket.ParseStatus(s); err != nil {
+			return err
+		}
+	}
+	for _, s := range c.Statuses {
+		if _, err := ticket.ParseStatus(s); err != nil {
+			return err
+		}
+	}
+	for f := range c.Fields {
+		if fieldValues[f] == nil {
+			return fmt.Errorf("unknown field %q, expected queue, reporter or id", f)
+		}
+	}
+	return nil
+}
+
+// fields returns the fields of the issue a ticket is created as
+func (c *Config) fields(t *ticket.Ticket) map[string]interface{} {
+	project := c.Project
+	if p := c.Projects[t.Queue]; p != "" {
+		project = p
+	}
+	issueType := c.IssueType
+	if issueType == "" {
+		issueType = "Task"
+	}
+	summary := t.Title
+	if summary == "" {
+		summary = fmt.Sprintf("Ticket %s", t.ID)
+	}
+	fields := map[string]interface{}{
+		"project":     map[string]string{"key": project},
+		"issuetype":   map[string]string{"name": issueType},
+		"summary":     summary,
+		"description": fmt.Sprintf("%s\n\nRaised in the helpdesk as ticket %s in the %s queue.", t.Description, t.ID, t.Queue),
+	}
+	for p, name := range c.Priorities {
+		if pr, _ := ticket.ParsePriority(p); pr == t.Priority {
+			fields["priority"] = map[string]string{"name": name}
+		}
+	}
+	for f, id := range c.Fields {
+		fields[id] = fieldValues[f](t)
+	}
+	return fields
+}
+
+// transition returns the name of the transition to take for a ticket status
+func (c *Config) transition(s ticket.Status) string {
+	for name, t := range c.Transitions {
+		if st, _ := ticket.ParseStatus(name); st == s {
+			return t
+		}
+	}
+	return ""
+}
+
+// status returns the ticket status of a Jira status, ignoring case
+func (c *Config) status(name string) ticket.Status {
+	for jira, s := range c.Statuses {
+		if strings.EqualFold(jira, name) {
+			st, _ := ticket.ParseStatus(s)
+			return st
+		}
+	}
+	return ""
+}
+
+// browse returns the link to an issue
+func (c *Config) browse(key string) string {
+	return strings.TrimSuffix(c.URL, "/") + "/browse/" + key
+}
@@ -0,0 +1,86 @@
+package jira
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/skybet/go-helpdesk/ticket"
+)
+
+const testConfig = `
+url: https://example.atlassian.net/
+user: helpdesk@example.com
+token: secret
+project: HD
+projects:
+  payroll: PAY
+priorities:
+  P1: Highest
+transitions:
+  in_progress: Start Progress
+  resolved: Done
+statuses:
+  Done: resolved
+  In Progress: in_progress
+fields:
+  queue: customfield_10010
+webhook_secret: hush
+`
+
+func TestLoadConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "jira")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "jira.yaml")
+	ioutil.WriteFile(path, []byte(testConfig), 0600)
+	c, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if c.Project != "HD" || c.Projects["payroll"] != "PAY" || c.WebhookSecret != "hush" || c.browse("HD-1") != "https://example.atlassian.net/browse/HD-1" {
+		t.Errorf("Unexpected config %+v", c)
+	}
+	if c.transition(ticket.StatusResolved) != "Done" || c.transition(ticket.StatusWaiting) != "" {
+		t.Errorf("Expected the transitions to be mapped from the ticket statuses")
+	}
+	if c.status("done") != ticket.StatusResolved || c.status("Backlog") != "" {
+		t.Errorf("Expected the statuses to be mapped from Jira's, ignoring case")
+	}
+
+	for name, bad := range map[string]string{
+		"Unknown key":      testConfig + "colour: blue\n",
+		"No project":       "url: https://example.atlassian.net\n",
+		"Bad priority":     testConfig + "priorities:\n  P9: Lowest\n",
+		"Bad status":       strings.Replace(testConfig, "Done: resolved", "Done: finished", 1),
+		"Bad transition":   strings.Replace(testConfig, "resolved: Done", "finished: Done", 1),
+		"Bad custom field": strings.Replace(testConfig, "queue: customfield_10010", "colour: customfield_10010", 1),
+	} {
+		ioutil.WriteFile(path, []byte(bad), 0600)
+		if _, err := LoadConfig(path); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+	if _, err := LoadConfig(filepath.Join(dir, "missing.yaml")); err == nil {
+		t.Error("Expected an error reading a missing file")
+	}
+}
+
+func TestFields(t *testing.T) {
+	c := &Config{Project: "HD", Projects: map[string]string{"payroll": "PAY"}, Priorities: map[string]string{"P1": "Highest"}, Fields: map[string]string{"queue": "customfield_10010", "reporter": "customfield_10011"}}
+	f := c.fields(&ticket.Ticket{ID: "7", Queue: "payroll", Title: "Missing payslip", Description: "Where is it?", Priority: ticket.P1, Reporter: "U1"})
+	if f["project"].(map[string]string)["key"] != "PAY" || f["issuetype"].(map[string]string)["name"] != "Task" || f["summary"] != "Missing payslip" || f["priority"].(map[string]string)["name"] != "Highest" {
+		t.Errorf("Unexpected fields %+v", f)
+	}
+	if f["customfield_10010"] != "payroll" || f["customfield_10011"] != "U1" || !strings.HasPrefix(f["description"].(string), "Where is it?\n\nRaised in the helpdesk as ticket 7") {
+		t.Errorf("Expected the custom fields and description to be set, got %+v", f)
+	}
+	f = c.fields(&ticket.Ticket{ID: "8", Queue: "it", Priority: ticket.P3})
+	if f["project"].(map[string]string)["key"] != "HD" || f["summary"] != "Ticket 8" || f["priority"] != nil {
+		t.Errorf("Expected the default project and no priority, got %+v", f)
+	}
+}
@@ -0,0 +1,250 @@
+package jira
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/nlopes/slack"
+
+	"github.com/skybet/go-helpdesk/clock"
+	"github.com/skybet/go-helpdesk/store"
+	"github.com/skybet/go-helpdesk/ticket"
+)
+
+// tracker prefixes the IDs of Jira's comments in a ticket's Issue.Synced
+const tracker = "jira:"
+
+// Tracker is the part of Jira's API used to mirror tickets, see Client
+type Tracker interface {
+	CreateIssue(ctx context.Context, fields map[string]interface{}) (string, error)
+	Transition(ctx context.Context, key, name string) error
+	AddComment(ctx context.Context, key, body string) (string, error)
+}
+
+// Slack is the part of the Slack API used to post Jira's changes in tickets'
+// threads
+type Slack interface {
+	PostMessage(channelID string, options ...slack.MsgOption) (string, string, error)
+}
+
+// Sync mirrors tickets into Jira issues and Jira's changes back to the tickets
+type Sync struct {
+	Config *Config
+	Jira   Tracker
+	// Store is where tickets are read from and their issues saved. It must
+	// not be the store returned by Wrap, so that saving an issue is not
+	// mirrored again.
+	Store store.Store
+	Slack Slack
+	// Logf logs the tickets which failed to be mirrored, they are tried
+	// again when they next change
+	Logf func(format string, args ...interface{})
+	// Clock, if set, replaces the wall clock
+	Clock clock.Clock
+
+	mu      sync.Mutex
+	pending map[string]bool
+	wake    chan struct{}
+}
+
+// New returns a Sync mirroring the tickets in s with the Jira API
+func New(c *Config, s store.Store, sl Slack) *Sync {
+	return &Sync{
+		Config:  c,
+		Jira:    &Client{URL: c.URL, User: c.User, Token: c.Token, HTTP: &http.Client{Timeout: 30 * time.Second}},
+		Store:   s,
+		Slack:   sl,
+		pending: map[string]bool{},
+		wake:    make(chan struct{}, 1),
+	}
+}
+
+// Wrap returns a store which writes to s and queues every ticket it writes
+// to be mirrored by Run once the write has been committed
+func (s *Sync) Wrap(st store.Store) store.Store {
+	return &queuer{Store: st, queue: s.queue}
+}
+
+// Run mirrors the tickets written through the wrapped stores until ctx is
+// cancelled
+func (s *Sync) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.wake:
+		}
+		s.mu.Lock()
+		ids := s.pending
+		s.pending = map[string]bool{}
+		s.mu.Unlock()
+		for id := range ids {
+			if err := s.Mirror(ctx, id); err != nil && s.Logf != nil {
+				s.Logf("Failed to mirror ticket %s to Jira: %s", id, err)
+			}
+		}
+	}
+}
+
+// queue marks tickets to be mirrored by Run, it never blocks the writer
+func (s *Sync) queue(ids ...string) {
+	if len(ids) == 0 {
+		return
+	}
+	s.mu.Lock()
+	for _, id := range ids {
+		s.pending[id] = true
+	}
+	s.mu.Unlock()
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Mirror brings a ticket's issue up to date, creating it if the ticket has
+// none, moving it to match the ticket's status and adding the ticket's new
+// comments to it. Tickets in the trash are left alone.
+func (s *Sync) Mirror(ctx context.Context, id string) error {
+	t, err := s.Store.GetTicket(ctx, id)
+	if err != nil {
+		return err
+	}
+	if t.Deleted() {
+		return nil
+	}
+	issue := t.Issue
+	issue.Synced = append([]string(nil), issue.Synced...)
+	if issue.Key == "" {
+		key, err := s.Jira.CreateIssue(ctx, s.Config.fields(t))
+		if err != nil {
+			return err
+		}
+		issue.Key, issue.URL, issue.Status = key, s.Config.browse(key), ticket.StatusNew
+		// Save the key straight away so that a failure below can not lead to
+		// a second issue
+		if err := s.save(ctx, id, issue); err != nil {
+			return err
+		}
+	}
+	// What was mirrored before a failure is still saved
+	err = s.update(ctx, t, &issue)
+	if saveErr := s.save(ctx, id, issue); err == nil {
+		err = saveErr
+	}
+	return err
+}
+
+// update moves the issue to match the ticket's status and adds the ticket's
+// new comments to it
+func (s *Sync) update(ctx context.Context, t *ticket.Ticket, issue *ticket.Issue) error {
+	if t.Status != issue.Status {
+		if name := s.Config.transition(t.Status); name != "" {
+			if err := s.Jira.Transition(ctx, issue.Key, name); err != nil {
+				return err
+			}
+		}
+		issue.Status = t.Status
+	}
+	for _, c := range t.Comments {
+		if synced(*issue, c.ID) {
+			continue
+		}
+		commentID, err := s.Jira.AddComment(ctx, issue.Key, fmt.Sprintf("%s wrote in Slack:\n%s", c.Author, c.Text))
+		if err != nil {
+			return err
+		}
+		issue.Synced = append(issue.Synced, c.ID, tracker+commentID)
+	}
+	return nil
+}
+
+// save sets a ticket's issue if it has changed
+func (s *Sync) save(ctx context.Context, id string, issue ticket.Issue) error {
+	return s.Store.Tx(ctx, func(tx store.Store) error {
+		t, err := tx.GetTicket(ctx, id)
+		if err != nil {
+			return err
+		}
+		if sameIssue(t.Issue, issue) {
+			return nil
+		}
+		// Keep the Jira comments the webhook recorded in the meantime
+		for _, c := range t.Issue.Synced {
+			if !synced(issue, c) {
+				issue.Synced = append(issue.Synced, c)
+			}
+		}
+		t.Issue = issue
+		return tx.UpdateTicket(ctx, t)
+	})
+}
+
+func sameIssue(a, b ticket.Issue) bool {
+	if a.Key != b.Key || a.URL != b.URL || a.Status != b.Status || len(a.Synced) != len(b.Synced) {
+		return false
+	}
+	for i := range a.Synced {
+		if a.Synced[i] != b.Synced[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func synced(issue ticket.Issue, id string) bool {
+	for _, s := range issue.Synced {
+		if s == id {
+			return true
+		}
+	}
+	return false
+}
+
+// queuer queues each write as soon as the wrapped store returns
+type queuer struct {
+	store.Store
+	queue func(ids ...string)
+}
+
+func (s *queuer) CreateTicket(ctx context.Context, t *ticket.Ticket) error {
+	if err := s.Store.CreateTicket(ctx, t); err != nil {
+		return err
+	}
+	s.queue(t.ID)
+	return nil
+}
+
+func (s *queuer) UpdateTicket(ctx context.Context, t *ticket.Ticket) error {
+	if err := s.Store.UpdateTicket(ctx, t); err != nil {
+		return err
+	}
+	s.queue(t.ID)
+	return nil
+}
+
+func (s *queuer) Transition(ctx context.Context, id string, from, to ticket.Status) (*ticket.Ticket, error) {
+	t, err := s.Store.Transition(ctx, id, from, to)
+	if err != nil {
+		return nil, err
+	}
+	s.queue(id)
+	return t, nil
+}
+
+// Tx holds back the transaction's writes until it commits, they are dropped
+// if it fails
+func (s *queuer) Tx(ctx context.Context, fn func(s store.Store) error) error {
+	var written []string
+	err := s.Store.Tx(ctx, func(tx store.Store) error {
+		return fn(&queuer{Store: tx, queue: func(ids ...string) { written = append(written, ids...) }})
+	})
+	if err != nil {
+		return err
+	}
+	s.queue(written...)
+	return nil
+}
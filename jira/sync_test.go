@@ -0,0 +1,142 @@
+package jira
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nlopes/slack"
+
+	"github.com/skybet/go-helpdesk/store"
+	"github.com/skybet/go-helpdesk/ticket"
+)
+
+// fakeSlack records the text of the messages posted
+type fakeSlack struct {
+	posted []string
+}
+
+func (f *fakeSlack) PostMessage(channelID string, options ...slack.MsgOption) (string, string, error) {
+	_, values, _ := slack.UnsafeApplyMsgOptions("", channelID, "", options...)
+	f.posted = append(f.posted, channelID+" "+values.Get("thread_ts")+" "+values.Get("text"))
+	return channelID, "9.9", nil
+}
+
+func newTestSync(t *testing.T) (*Sync, *fakeJira, *fakeSlack, func()) {
+	jira := newFakeJira()
+	srv := httptest.NewServer(jira)
+	c := &Config{
+		URL: srv.URL, User: "helpdesk@example.com", Token: "secret", Project: "HD", WebhookSecret: "hush",
+		Transitions: map[string]string{"in_progress": "Start Progress", "resolved": "Done"},
+		Statuses:    map[string]string{"Done": "resolved", "In Progress": "in_progress"},
+	}
+	sl := &fakeSlack{}
+	return New(c, store.NewMemory(), sl), jira, sl, srv.Close
+}
+
+func TestMirror(t *testing.T) {
+	s, jira, _, done := newTestSync(t)
+	defer done()
+	ctx := context.Background()
+	tk := &ticket.Ticket{Title: "VPN down", Status: ticket.StatusNew}
+	s.Store.CreateTicket(ctx, tk)
+
+	if err := s.Mirror(ctx, tk.ID); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	got, _ := s.Store.GetTicket(ctx, tk.ID)
+	if got.Issue.Key != "HD-1" || !strings.HasSuffix(got.Issue.URL, "/browse/HD-1") || got.Issue.Status != ticket.StatusNew || len(jira.issues) != 1 {
+		t.Fatalf("Expected an issue to be created and linked, got %+v", got.Issue)
+	}
+
+	got.SetStatus(ticket.StatusInProgress, time.Now())
+	got.AddComment(ticket.Comment{ID: "1.2", Author: "U9", Text: "Looking", CreatedAt: time.Now()})
+	s.Store.UpdateTicket(ctx, got)
+	if err := s.Mirror(ctx, tk.ID); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	got, _ = s.Store.GetTicket(ctx, tk.ID)
+	if jira.statuses["HD-1"] != "In Progress" || len(jira.comments["HD-1"]) != 1 || jira.comments["HD-1"][0] != "U9 wrote in Slack:\nLooking" {
+		t.Errorf("Expected the issue to be moved and commented on, got %q %q", jira.statuses["HD-1"], jira.comments["HD-1"])
+	}
+	if got.Issue.Status != ticket.StatusInProgress || len(got.Issue.Synced) != 2 || got.Issue.Synced[0] != "1.2" || got.Issue.Synced[1] != "jira:1" {
+		t.Errorf("Expected the mirrored status and comment to be recorded, got %+v", got.Issue)
+	}
+
+	// Nothing has changed, so nothing is sent again
+	version := got.Version
+	if err := s.Mirror(ctx, tk.ID); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if got, _ = s.Store.GetTicket(ctx, tk.ID); got.Version != version || len(jira.issues) != 1 || len(jira.comments["HD-1"]) != 1 {
+		t.Errorf("Expected an up to date ticket to be left alone")
+	}
+
+	// Statuses without a transition are recorded without moving the issue
+	got.SetStatus(ticket.StatusWaiting, time.Now())
+	s.Store.UpdateTicket(ctx, got)
+	s.Mirror(ctx, tk.ID)
+	if got, _ = s.Store.GetTicket(ctx, tk.ID); got.Issue.Status != ticket.StatusWaiting || jira.statuses["HD-1"] != "In Progress" {
+		t.Errorf("Expected the issue to stay where it was, got %q", jira.statuses["HD-1"])
+	}
+}
+
+func TestMirrorKeepsProgress(t *testing.T) {
+	s, jira, _, done := newTestSync(t)
+	defer done()
+	ctx := context.Background()
+	tk := &ticket.Ticket{Title: "VPN down", Status: ticket.StatusWaiting}
+	s.Store.CreateTicket(ctx, tk)
+	s.Config.Transitions["waiting"] = "Wait"
+
+	if err := s.Mirror(ctx, tk.ID); err == nil {
+		t.Fatal("Expected the missing transition to fail")
+	}
+	got, _ := s.Store.GetTicket(ctx, tk.ID)
+	if got.Issue.Key != "HD-1" || got.Issue.Status != ticket.StatusNew {
+		t.Errorf("Expected the issue to be linked despite the failure, got %+v", got.Issue)
+	}
+	delete(s.Config.Transitions, "waiting")
+	s.Mirror(ctx, tk.ID)
+	if len(jira.issues) != 1 {
+		t.Errorf("Expected the issue to be created once, got %d", len(jira.issues))
+	}
+}
+
+func TestWrap(t *testing.T) {
+	s, jira, _, done := newTestSync(t)
+	defer done()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var logged []string
+	s.Logf = func(format string, args ...interface{}) { logged = append(logged, format) }
+	go s.Run(ctx)
+	wrapped := s.Wrap(s.Store)
+
+	wrapped.CreateTicket(ctx, &ticket.Ticket{Title: "VPN down"})
+	wrapped.Tx(ctx, func(tx store.Store) error {
+		return tx.CreateTicket(ctx, &ticket.Ticket{Title: "Printer on fire"})
+	})
+	wrapped.Tx(ctx, func(tx store.Store) error {
+		tx.CreateTicket(ctx, &ticket.Ticket{Title: "Rolled back"})
+		return context.Canceled
+	})
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		jira.mu.Lock()
+		n := len(jira.issues)
+		jira.mu.Unlock()
+		if n == 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected the committed tickets to be mirrored, got %d issues", n)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if len(logged) != 0 {
+		t.Errorf("Unexpected errors %q", logged)
+	}
+}
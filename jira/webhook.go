@@ -0,0 +1,189 @@
+package jira
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/nlopes/slack"
+
+	"github.com/skybet/go-helpdesk/clock"
+	"github.com/skybet/go-helpdesk/store"
+	"github.com/skybet/go-helpdesk/ticket"
+)
+
+// Event is the part of a Jira webhook the Sync reads
+type Event struct {
+	WebhookEvent string `json:"webhookEvent"`
+	Issue        struct {
+		Key string `json:"key"`
+	} `json:"issue"`
+	User    User `json:"user"`
+	Comment *struct {
+		ID     string `json:"id"`
+		Body   string `json:"body"`
+		Author User   `json:"author"`
+	} `json:"comment"`
+	Changelog *struct {
+		Items []struct {
+			Field    string `json:"field"`
+			ToString string `json:"toString"`
+		} `json:"items"`
+	} `json:"changelog"`
+}
+
+// User is a Jira user
+type User struct {
+	Name         string `json:"name"`
+	EmailAddress string `json:"emailAddress"`
+	DisplayName  string `json:"displayName"`
+}
+
+// Sign returns the X-Hub-Signature Jira sends with a webhook's body
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Webhook handles Jira's webhooks for the mirrored issues: comments are
+// posted in the ticket's thread and status changes move the ticket. Webhooks
+// must be signed with the configured secret.
+func (s *Sync) Webhook() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, 1<<20))
+		if err != nil {
+			http.Error(w, "error reading webhook", http.StatusBadRequest)
+			return
+		}
+		if s.Config.WebhookSecret == "" || !hmac.Equal([]byte(r.Header.Get("X-Hub-Signature")), []byte(Sign(s.Config.WebhookSecret, body))) {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		var ev Event
+		if err := json.Unmarshal(body, &ev); err != nil {
+			http.Error(w, "error decoding webhook", http.StatusBadRequest)
+			return
+		}
+		if err := s.Receive(r.Context(), &ev); err != nil {
+			if s.Logf != nil {
+				s.Logf("Failed to handle Jira webhook %s for %s: %s", ev.WebhookEvent, ev.Issue.Key, err)
+			}
+			http.Error(w, "error handling webhook", http.StatusInternalServerError)
+		}
+	})
+}
+
+// Receive applies a webhook to the ticket mirrored to its issue. Webhooks
+// about other issues, and about the comments the Sync made itself, are
+// ignored.
+func (s *Sync) Receive(ctx context.Context, ev *Event) error {
+	if ev.Issue.Key == "" {
+		return nil
+	}
+	found, _, err := s.Store.ListTickets(ctx, store.Filter{Issue: ev.Issue.Key, Limit: 1})
+	if err != nil {
+		return fmt.Errorf("error finding the ticket of %s: %s", ev.Issue.Key, err)
+	}
+	if len(found) == 0 {
+		return nil
+	}
+	t := found[0]
+	switch {
+	case ev.Comment != nil && (ev.WebhookEvent == "comment_created" || ev.WebhookEvent == "jira:issue_updated"):
+		if synced(t.Issue, tracker+ev.Comment.ID) || s.ours(ev.Comment.Author) {
+			return nil
+		}
+		text := fmt.Sprintf("%s commented on <%s|%s>:\n%s", name(ev.Comment.Author), t.Issue.URL, t.Issue.Key, quote(ev.Comment.Body))
+		if err := s.post(t, text); err != nil {
+			return err
+		}
+		return s.record(ctx, t.ID, func(t *ticket.Ticket) bool {
+			if synced(t.Issue, tracker+ev.Comment.ID) {
+				return false
+			}
+			t.Issue.Synced = append(t.Issue.Synced, tracker+ev.Comment.ID)
+			return true
+		})
+	case ev.Changelog != nil && !s.ours(ev.User):
+		var to ticket.Status
+		for _, item := range ev.Changelog.Items {
+			if item.Field == "status" {
+				to = s.Config.status(item.ToString)
+			}
+		}
+		if to == "" || to == t.Status {
+			return nil
+		}
+		moved := false
+		err := s.record(ctx, t.ID, func(t *ticket.Ticket) bool {
+			if t.Status == to {
+				return false
+			}
+			t.SetStatus(to, clock.Or(s.Clock).Now())
+			t.Issue.Status = to
+			moved = true
+			return true
+		})
+		if err != nil || !moved {
+			return err
+		}
+		return s.post(t, fmt.Sprintf("%s moved <%s|%s> in Jira, the ticket is now %s", name(ev.User), t.Issue.URL, t.Issue.Key, strings.Replace(string(to), "_", " ", -1)))
+	}
+	return nil
+}
+
+// record changes a ticket if fn reports it changed it
+func (s *Sync) record(ctx context.Context, id string, fn func(t *ticket.Ticket) bool) error {
+	return s.Store.Tx(ctx, func(tx store.Store) error {
+		t, err := tx.GetTicket(ctx, id)
+		if err != nil {
+			return err
+		}
+		if !fn(t) {
+			return nil
+		}
+		return tx.UpdateTicket(ctx, t)
+	})
+}
+
+// post replies in a ticket's thread, if it has one
+func (s *Sync) post(t *ticket.Ticket, text string) error {
+	if t.ChannelID == "" || t.ThreadTS == "" {
+		return nil
+	}
+	if _, _, err := s.Slack.PostMessage(t.ChannelID, slack.MsgOptionText(text, false), slack.MsgOptionTS(t.ThreadTS)); err != nil {
+		return fmt.Errorf("error posting in the thread of ticket %s: %s", t.ID, err)
+	}
+	return nil
+}
+
+// ours reports whether a Jira user is the account the Sync writes as, so that
+// its own changes are not brought back
+func (s *Sync) ours(u User) bool {
+	return s.Config.User != "" && (strings.EqualFold(u.EmailAddress, s.Config.User) || strings.EqualFold(u.Name, s.Config.User))
+}
+
+func name(u User) string {
+	if u.DisplayName != "" {
+		return u.DisplayName
+	}
+	if u.Name != "" {
+		return u.Name
+	}
+	return "Someone"
+}
+
+func quote(text string) string {
+	return "> " + strings.Replace(strings.TrimSpace(text), "\n", "\n> ", -1)
+}
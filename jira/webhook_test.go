@@ -0,0 +1,65 @@
+package jira
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/skybet/go-helpdesk/ticket"
+)
+
+func TestWebhook(t *testing.T) {
+	s, _, sl, done := newTestSync(t)
+	defer done()
+	ctx := context.Background()
+	tk := &ticket.Ticket{Title: "VPN down", Status: ticket.StatusInProgress, ChannelID: "C1", ThreadTS: "1.1", Issue: ticket.Issue{Key: "HD-1", URL: "https://jira/browse/HD-1", Status: ticket.StatusInProgress, Synced: []string{"1.2", "jira:5"}}}
+	s.Store.CreateTicket(ctx, tk)
+	send := func(body, signature string) int {
+		r := httptest.NewRequest(http.MethodPost, "/jira", strings.NewReader(body))
+		if signature == "" {
+			signature = Sign("hush", []byte(body))
+		}
+		r.Header.Set("X-Hub-Signature", signature)
+		w := httptest.NewRecorder()
+		s.Webhook().ServeHTTP(w, r)
+		return w.Code
+	}
+
+	comment := `{"webhookEvent": "comment_created", "issue": {"key": "HD-1"}, "comment": {"id": "6", "body": "Fixed the tunnel\nTry now", "author": {"displayName": "Ada"}}}`
+	if code := send(comment, "sha256=bad"); code != http.StatusUnauthorized || len(sl.posted) != 0 {
+		t.Errorf("Expected an unsigned webhook to be refused, got %d", code)
+	}
+	if code := send(comment, ""); code != http.StatusOK || len(sl.posted) != 1 || sl.posted[0] != "C1 1.1 Ada commented on <https://jira/browse/HD-1|HD-1>:\n> Fixed the tunnel\n> Try now" {
+		t.Fatalf("Expected the comment to be posted in the thread, got %d %q", code, sl.posted)
+	}
+	if got, _ := s.Store.GetTicket(ctx, tk.ID); len(got.Issue.Synced) != 3 || got.Issue.Synced[2] != "jira:6" {
+		t.Errorf("Expected the comment to be recorded, got %+v", got.Issue)
+	}
+	// Comments already seen, and those the helpdesk made, are not posted
+	send(comment, "")
+	send(`{"webhookEvent": "comment_created", "issue": {"key": "HD-1"}, "comment": {"id": "5", "body": "U9 wrote in Slack"}}`, "")
+	send(`{"webhookEvent": "comment_created", "issue": {"key": "HD-1"}, "comment": {"id": "7", "body": "U9 wrote in Slack", "author": {"emailAddress": "helpdesk@example.com"}}}`, "")
+	send(`{"webhookEvent": "comment_created", "issue": {"key": "OTHER-1"}, "comment": {"id": "8", "body": "Not ours"}}`, "")
+	if len(sl.posted) != 1 {
+		t.Errorf("Expected no more comments to be posted, got %q", sl.posted)
+	}
+
+	moved := `{"webhookEvent": "jira:issue_updated", "issue": {"key": "HD-1"}, "user": {"displayName": "Ada"}, "changelog": {"items": [{"field": "status", "toString": "Done"}]}}`
+	if code := send(moved, ""); code != http.StatusOK || len(sl.posted) != 2 || sl.posted[1] != "C1 1.1 Ada moved <https://jira/browse/HD-1|HD-1> in Jira, the ticket is now resolved" {
+		t.Fatalf("Expected the move to be posted in the thread, got %d %q", code, sl.posted)
+	}
+	got, _ := s.Store.GetTicket(ctx, tk.ID)
+	if got.Status != ticket.StatusResolved || got.Issue.Status != ticket.StatusResolved || got.ResolvedAt.IsZero() {
+		t.Errorf("Expected the ticket to be resolved without moving the issue back, got %s %+v", got.Status, got.Issue)
+	}
+	send(moved, "")
+	send(`{"webhookEvent": "jira:issue_updated", "issue": {"key": "HD-1"}, "changelog": {"items": [{"field": "status", "toString": "Backlog"}]}}`, "")
+	if len(sl.posted) != 2 {
+		t.Errorf("Expected moves to the same or unmapped statuses to be ignored, got %q", sl.posted)
+	}
+	if code := send("{", ""); code != http.StatusBadRequest {
+		t.Errorf("Expected a malformed webhook to be refused, got %d", code)
+	}
+}
@@ -0,0 +1,155 @@
+// Package lifecycle enforces which statuses a ticket may move between. Guards
+// can refuse a move, such as starting work on a ticket nobody is assigned to,
+// and listeners are told about every move, such as to post it in the ticket's
+// thread.
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/skybet/go-helpdesk/store"
+	"github.com/skybet/go-helpdesk/ticket"
+)
+
+// ErrNotAllowed is returned when a move is not one of the machine's
+// transitions
+var ErrNotAllowed = errors.New("transition not allowed")
+
+// Transitions are the statuses each status may move to
+type Transitions map[ticket.Status][]ticket.Status
+
+// DefaultTransitions take a ticket forward from new to closed, skipping
+// steps, and let work resume while it waits on the reporter or once it has
+// been resolved or closed
+var DefaultTransitions = Transitions{
+	ticket.StatusNew:        {ticket.StatusTriaged, ticket.StatusInProgress, ticket.StatusResolved, ticket.StatusClosed},
+	ticket.StatusTriaged:    {ticket.StatusInProgress, ticket.StatusWaiting, ticket.StatusResolved, ticket.StatusClosed},
+	ticket.StatusInProgress: {ticket.StatusWaiting, ticket.StatusResolved, ticket.StatusClosed},
+	ticket.StatusWaiting:    {ticket.StatusInProgress, ticket.StatusResolved, ticket.StatusClosed},
+	ticket.StatusResolved:   {ticket.StatusInProgress, ticket.StatusClosed},
+	ticket.StatusClosed:     {ticket.StatusInProgress},
+}
+
+// ParseTransitions parses transitions in the form <from>:<to>+<to>, e.g.
+// waiting:in_progress+resolved
+func ParseTransitions(specs []string) (Transitions, error) {
+	ts := Transitions{}
+	for _, spec := range specs {
+		parts := strings.SplitN(spec, ":", 2)
+		if len(parts) != 2 || parts[1] == "" {
+			return nil, fmt.Errorf("invalid transition %q, expected <from>:<to>+<to>", spec)
+		}
+		from, err := ticket.ParseStatus(parts[0])
+		if err != nil {
+			return nil, fmt.Errorf("invalid transition %q: %s", spec, err)
+		}
+		for _, s := range strings.Split(parts[1], "+") {
+			to, err := ticket.ParseStatus(s)
+			if err != nil {
+				return nil, fmt.Errorf("invalid transition %q: %s", spec, err)
+			}
+			ts[from] = append(ts[from], to)
+		}
+	}
+	return ts, nil
+}
+
+// Move is a ticket being moved to a new status By a user
+type Move struct {
+	Ticket   *ticket.Ticket
+	From, To ticket.Status
+	By       string
+	At       time.Time
+}
+
+// Guard can refuse a move by returning an error, which is passed back to
+// whoever asked for it
+type Guard func(ctx context.Context, m Move) error
+
+// Machine moves tickets between statuses. Add its guards and listeners before
+// moving any tickets, they do not change while it is in use.
+type Machine struct {
+	transitions Transitions
+	guards      []Guard
+	listeners   []func(Move)
+}
+
+// New returns a machine allowing transitions t
+func New(t Transitions) *Machine {
+	return &Machine{transitions: t}
+}
+
+// Guard adds a check which every move must pass
+func (m *Machine) Guard(g Guard) {
+	m.guards = append(m.guards, g)
+}
+
+// OnMove adds a function called after every successful move, in the order
+// they were added
+func (m *Machine) OnMove(fn func(Move)) {
+	m.listeners = append(m.listeners, fn)
+}
+
+// Allowed reports whether a ticket may move from one status to another
+func (m *Machine) Allowed(from, to ticket.Status) bool {
+	for _, s := range m.transitions[from] {
+		if s == to {
+			return true
+		}
+	}
+	return false
+}
+
+// Next returns the statuses a ticket in status from may move to
+func (m *Machine) Next(from ticket.Status) []ticket.Status {
+	return append([]ticket.Status(nil), m.transitions[from]...)
+}
+
+// Move moves the ticket with id to status to on behalf of user by. It fails
+// with ErrNotAllowed if the machine has no such transition, with the error of
+// the first guard which refuses, or with store.ErrConflict if the ticket's
+// status changed while it was being checked.
+func (m *Machine) Move(ctx context.Context, s store.Store, id string, to ticket.Status, by string, now time.Time) (*ticket.Ticket, error) {
+	t, err := s.GetTicket(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !m.Allowed(t.Status, to) {
+		return nil, fmt.Errorf("%w: %s to %s", ErrNotAllowed, t.Status, to)
+	}
+	move := Move{Ticket: t, From: t.Status, To: to, By: by, At: now}
+	for _, g := range m.guards {
+		if err := g(ctx, move); err != nil {
+			return nil, err
+		}
+	}
+	moved, err := s.Transition(ctx, id, t.Status, to)
+	if err != nil {
+		return nil, err
+	}
+	move.Ticket = moved
+	for _, fn := range m.listeners {
+		fn(move)
+	}
+	return moved, nil
+}
+
+// RequireAssignee is a guard refusing to move unassigned tickets to any of
+// statuses
+func RequireAssignee(statuses ...ticket.Status) Guard {
+	return func(ctx context.Context, m Move) error {
+		if m.Ticket.Assignee != "" {
+			return nil
+		}
+		for _, s := range statuses {
+			if m.To == s {
+				return fmt.Errorf("ticket #%s must be assigned before it is %s", m.Ticket.ID, s)
+			}
+		}
+		return nil
+	}
+}
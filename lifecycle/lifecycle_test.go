@@ -0,0 +1,54 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/skybet/go-helpdesk/store"
+	"github.com/skybet/go-helpdesk/ticket"
+)
+
+func TestMove(t *testing.T) {
+	s := store.NewMemory()
+	s.CreateTicket(context.Background(), &ticket.Ticket{ID: "1", Status: ticket.StatusNew})
+	m := New(DefaultTransitions)
+	m.Guard(RequireAssignee(ticket.StatusInProgress))
+	var moves []Move
+	m.OnMove(func(mv Move) { moves = append(moves, mv) })
+	now := time.Date(2019, 3, 4, 9, 0, 0, 0, time.UTC)
+
+	if _, err := m.Move(context.Background(), s, "1", ticket.StatusWaiting, "U1", now); !errors.Is(err, ErrNotAllowed) {
+		t.Errorf("Expected new tickets not to wait on the reporter, got %v", err)
+	}
+	if _, err := m.Move(context.Background(), s, "1", ticket.StatusInProgress, "U1", now); err == nil {
+		t.Errorf("Expected the guard to refuse starting an unassigned ticket")
+	}
+	moved, err := m.Move(context.Background(), s, "1", ticket.StatusTriaged, "U1", now)
+	if err != nil || moved.Status != ticket.StatusTriaged {
+		t.Fatalf("Expected the ticket to be triaged, got %+v %v", moved, err)
+	}
+	if len(moves) != 1 || moves[0].From != ticket.StatusNew || moves[0].To != ticket.StatusTriaged || moves[0].By != "U1" || moves[0].Ticket.Version != moved.Version {
+		t.Errorf("Expected one move to be published, got %+v", moves)
+	}
+	if _, err := m.Move(context.Background(), s, "9", ticket.StatusTriaged, "U1", now); err != store.ErrNotFound {
+		t.Errorf("Expected a missing ticket to be not found, got %v", err)
+	}
+}
+
+func TestParseTransitions(t *testing.T) {
+	ts, err := ParseTransitions([]string{"new:triaged", "triaged:in-progress+closed"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	m := New(ts)
+	if !m.Allowed(ticket.StatusTriaged, ticket.StatusInProgress) || !m.Allowed(ticket.StatusTriaged, ticket.StatusClosed) || m.Allowed(ticket.StatusNew, ticket.StatusClosed) {
+		t.Errorf("Unexpected transitions %+v", ts)
+	}
+	for _, spec := range []string{"new", "new:", "done:closed", "new:triaged+done"} {
+		if _, err := ParseTransitions([]string{spec}); err == nil {
+			t.Errorf("%s: expected an error", spec)
+		}
+	}
+}
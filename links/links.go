@@ -0,0 +1,173 @@
+// Package links builds stable links to tickets and serves the resolver they
+// point at, which redirects to wherever the ticket can be viewed
+package links
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/nlopes/slack"
+
+	"github.com/skybet/go-helpdesk/store"
+)
+
+// The views a link can open, passed to the resolver as the view parameter
+const (
+	ViewThread = "thread"
+	ViewHome   = "home"
+	ViewAdmin  = "admin"
+)
+
+// Permalinker is the part of the Slack API used to find a ticket's thread
+type Permalinker interface {
+	GetPermalink(params *slack.PermalinkParameters) (string, error)
+}
+
+// Links builds links to tickets. Every link goes through the resolver at
+// BaseURL so that links already shared keep working when tickets move or are
+// renumbered. A nil *Links builds no links, references fall back to #<id>.
+type Links struct {
+	// BaseURL is where the Handler is mounted, e.g. https://helpdesk.example.com/links
+	BaseURL string
+	// TeamID and AppID locate the app's Home tab in Slack
+	TeamID string
+	AppID  string
+	// AdminURL is the admin UI's ticket page with %s for the ticket ID, admin
+	// links are not built if it is empty
+	AdminURL string
+
+	mu      sync.RWMutex
+	aliases map[string]string
+}
+
+// New returns Links served from baseURL
+func New(baseURL, teamID, appID, adminURL string) *Links {
+	return &Links{
+		BaseURL:  strings.TrimSuffix(baseURL, "/"),
+		TeamID:   teamID,
+		AppID:    appID,
+		AdminURL: adminURL,
+		aliases:  map[string]string{},
+	}
+}
+
+// Alias redirects links to the old ticket ID to the new one, e.g. after
+// tickets are migrated to a new ID scheme
+func (l *Links) Alias(old, new string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.aliases == nil {
+		l.aliases = map[string]string{}
+	}
+	l.aliases[old] = new
+}
+
+// Resolve returns the current ID of a ticket, following any aliases
+func (l *Links) Resolve(id string) string {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	// Bound the chain in case aliases loop
+	for i := 0; i <= len(l.aliases); i++ {
+		next, ok := l.aliases[id]
+		if !ok {
+			break
+		}
+		id = next
+	}
+	return id
+}
+
+// Ticket returns the stable link opening the ticket in view, empty if links
+// are not configured
+func (l *Links) Ticket(id, view string) string {
+	if l == nil || l.BaseURL == "" {
+		return ""
+	}
+	u := l.BaseURL + "/t/" + url.PathEscape(id)
+	if view != "" && view != ViewThread {
+		u += "?view=" + view
+	}
+	return u
+}
+
+// Ref returns a mrkdwn reference to the ticket, linked to its thread if links
+// are configured
+func (l *Links) Ref(id string) string {
+	if u := l.Ticket(id, ViewThread); u != "" {
+		return fmt.Sprintf("<%s|#%s>", u, id)
+	}
+	return "#" + id
+}
+
+// Home returns the slack:// link to the app's Home tab, empty if it is not
+// configured
+func (l *Links) Home() string {
+	if l == nil || l.TeamID == "" || l.AppID == "" {
+		return ""
+	}
+	return fmt.Sprintf("slack://app?team=%s&id=%s&tab=home", url.QueryEscape(l.TeamID), url.QueryEscape(l.AppID))
+}
+
+// Admin returns the admin UI's page for the ticket, empty if it is not
+// configured
+func (l *Links) Admin(id string) string {
+	if l == nil || l.AdminURL == "" {
+		return ""
+	}
+	return fmt.Sprintf(l.AdminURL, url.PathEscape(id))
+}
+
+// Handler resolves links built by Ticket, redirecting to the ticket's Slack
+// thread, the app's Home tab or the admin UI. Mount it at BaseURL with
+// http.StripPrefix.
+func (l *Links) Handler(s store.Store, p Permalinker) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		id := strings.TrimPrefix(r.URL.Path, "/t/")
+		if id == r.URL.Path || id == "" {
+			http.NotFound(w, r)
+			return
+		}
+		id = l.Resolve(id)
+		t, err := s.GetTicket(r.Context(), id)
+		if err == store.ErrNotFound {
+			http.NotFound(w, r)
+			return
+		}
+		if err != nil {
+			http.Error(w, "error getting ticket", http.StatusInternalServerError)
+			return
+		}
+
+		var target string
+		switch view := r.URL.Query().Get("view"); view {
+		case "", ViewThread:
+			if t.ChannelID == "" || t.ThreadTS == "" {
+				break
+			}
+			target, err = p.GetPermalink(&slack.PermalinkParameters{Channel: t.ChannelID, Ts: t.ThreadTS})
+			if err != nil {
+				// Slack can still open the channel if the message has gone
+				target = fmt.Sprintf("slack://channel?team=%s&id=%s", url.QueryEscape(l.TeamID), url.QueryEscape(t.ChannelID))
+			}
+		case ViewHome:
+			target = l.Home()
+		case ViewAdmin:
+			target = l.Admin(t.ID)
+		default:
+			http.Error(w, "unknown view", http.StatusBadRequest)
+			return
+		}
+		if target == "" {
+			http.NotFound(w, r)
+			return
+		}
+		http.Redirect(w, r, target, http.StatusFound)
+	})
+}
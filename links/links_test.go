@@ -0,0 +1,78 @@
+package links
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/nlopes/slack"
+
+	"github.com/skybet/go-helpdesk/store"
+	"github.com/skybet/go-helpdesk/ticket"
+)
+
+type fakePermalinker struct{ err error }
+
+func (f fakePermalinker) GetPermalink(params *slack.PermalinkParameters) (string, error) {
+	return "https://example.slack.com/archives/" + params.Channel + "/p" + params.Ts, f.err
+}
+
+func TestLinks(t *testing.T) {
+	l := New("https://hd.example.com/links/", "T1", "A1", "https://admin.example.com/tickets/%s")
+	if u := l.Ticket("7", ViewThread); u != "https://hd.example.com/links/t/7" {
+		t.Errorf("Unexpected thread link %s", u)
+	}
+	if u := l.Ticket("7", ViewAdmin); u != "https://hd.example.com/links/t/7?view=admin" {
+		t.Errorf("Unexpected admin link %s", u)
+	}
+	if r := l.Ref("7"); r != "<https://hd.example.com/links/t/7|#7>" {
+		t.Errorf("Unexpected reference %s", r)
+	}
+	if h := l.Home(); h != "slack://app?team=T1&id=A1&tab=home" {
+		t.Errorf("Unexpected home link %s", h)
+	}
+
+	var unset *Links
+	if r := unset.Ref("7"); r != "#7" {
+		t.Errorf("Expected plain references without links, got %s", r)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	s := store.NewMemory()
+	s.CreateTicket(context.Background(), &ticket.Ticket{ID: "HD-7", ChannelID: "C1", ThreadTS: "1.1"})
+	l := New("https://hd.example.com/links", "T1", "A1", "https://admin.example.com/tickets/%s")
+	l.Alias("7", "HD-7")
+
+	for _, c := range []struct {
+		path     string
+		p        Permalinker
+		code     int
+		location string
+	}{
+		{"/t/HD-7", fakePermalinker{}, http.StatusFound, "https://example.slack.com/archives/C1/p1.1"},
+		{"/t/7", fakePermalinker{}, http.StatusFound, "https://example.slack.com/archives/C1/p1.1"},
+		{"/t/7", fakePermalinker{err: errors.New("message_not_found")}, http.StatusFound, "slack://channel?team=T1&id=C1"},
+		{"/t/7?view=admin", fakePermalinker{}, http.StatusFound, "https://admin.example.com/tickets/HD-7"},
+		{"/t/7?view=home", fakePermalinker{}, http.StatusFound, "slack://app?team=T1&id=A1&tab=home"},
+		{"/t/7?view=other", fakePermalinker{}, http.StatusBadRequest, ""},
+		{"/t/8", fakePermalinker{}, http.StatusNotFound, ""},
+	} {
+		w := httptest.NewRecorder()
+		l.Handler(s, c.p).ServeHTTP(w, httptest.NewRequest("GET", c.path, nil))
+		if w.Code != c.code || w.Header().Get("Location") != c.location {
+			t.Errorf("%s: expected %d %s, got %d %s", c.path, c.code, c.location, w.Code, w.Header().Get("Location"))
+		}
+	}
+}
+
+func TestAliasLoop(t *testing.T) {
+	l := &Links{}
+	l.Alias("1", "2")
+	l.Alias("2", "1")
+	if id := l.Resolve("1"); id != "1" && id != "2" {
+		t.Errorf("Unexpected ID %s", id)
+	}
+}
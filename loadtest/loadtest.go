@@ -0,0 +1,238 @@
+// Package loadtest generates signed synthetic Slack traffic against a running
+// go-helpdesk server and reports how it coped
+package loadtest
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Config controls a load test run
+type Config struct {
+	// Target is the URL of the Slack callback endpoint, e.g. http://localhost:4390/slack
+	Target string
+	// Secret is the Slack signing secret the server has been configured with
+	Secret string
+	// DNHeader optionally sets a mutual TLS DN header containing the Slack CN
+	DNHeader *string
+	// Rates is the number of requests per second to send for each kind
+	Rates map[Kind]float64
+	// Duration is how long to generate traffic for
+	Duration time.Duration
+	// Concurrency is the number of workers sending requests
+	Concurrency int
+	// Backlog is the number of scheduled requests which may wait for a free
+	// worker before new requests are dropped
+	Backlog int
+	// Client is used to send requests, http.DefaultClient if nil
+	Client *http.Client
+	// Generator builds the payloads, a default generator if nil
+	Generator *Generator
+}
+
+// Stats holds the results for a single kind of request
+type Stats struct {
+	Sent      int
+	Errors    int
+	Dropped   int
+	Latencies []time.Duration
+}
+
+// ErrorRate returns the fraction of sent requests which failed
+func (s *Stats) ErrorRate() float64 {
+	if s.Sent == 0 {
+		return 0
+	}
+	return float64(s.Errors) / float64(s.Sent)
+}
+
+// Percentile returns the latency at percentile p (0-100)
+func (s *Stats) Percentile(p float64) time.Duration {
+	if len(s.Latencies) == 0 {
+		return 0
+	}
+	l := make([]time.Duration, len(s.Latencies))
+	copy(l, s.Latencies)
+	sort.Slice(l, func(i, j int) bool { return l[i] < l[j] })
+	i := int(float64(len(l)-1) * p / 100)
+	return l[i]
+}
+
+// Report is the outcome of a load test run
+type Report struct {
+	Kinds      map[Kind]*Stats
+	Elapsed    time.Duration
+	MaxBacklog int
+}
+
+// Print writes a human readable summary of the report
+func (r *Report) Print(w io.Writer) {
+	fmt.Fprintf(w, "Elapsed: %s, max backlog: %d\n", r.Elapsed.Round(time.Millisecond), r.MaxBacklog)
+	fmt.Fprintf(w, "%-8s %8s %8s %8s %8s %10s %10s %10s\n", "kind", "sent", "errors", "dropped", "err%", "p50", "p90", "p99")
+	for _, k := range Kinds {
+		s, ok := r.Kinds[k]
+		if !ok {
+			continue
+		}
+		fmt.Fprintf(w, "%-8s %8d %8d %8d %7.2f%% %10s %10s %10s\n", k, s.Sent, s.Errors, s.Dropped, s.ErrorRate()*100,
+			s.Percentile(50).Round(time.Microsecond), s.Percentile(90).Round(time.Microsecond), s.Percentile(99).Round(time.Microsecond))
+	}
+}
+
+type result struct {
+	kind    Kind
+	latency time.Duration
+	err     bool
+}
+
+// Run generates traffic as described by the config until the duration has
+// elapsed or the context is cancelled, then waits for in-flight requests
+func Run(ctx context.Context, c Config) (*Report, error) {
+	if c.Target == "" {
+		return nil, fmt.Errorf("a target URL is required")
+	}
+	if c.Concurrency < 1 {
+		c.Concurrency = 1
+	}
+	if c.Backlog < 1 {
+		c.Backlog = c.Concurrency * 100
+	}
+	if c.Client == nil {
+		c.Client = http.DefaultClient
+	}
+	if c.Generator == nil {
+		c.Generator = NewGenerator("/help-me", "HelpRequest", "app_mention")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.Duration)
+	defer cancel()
+
+	report := &Report{Kinds: map[Kind]*Stats{}}
+	for k, rate := range c.Rates {
+		if rate > 0 {
+			report.stats(k)
+		}
+	}
+	jobs := make(chan *Payload, c.Backlog)
+	results := make(chan result, c.Backlog)
+	var backlog, maxBacklog int64
+
+	// Workers send requests and report their outcome
+	var workers sync.WaitGroup
+	for i := 0; i < c.Concurrency; i++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for p := range jobs {
+				atomic.AddInt64(&backlog, -1)
+				results <- send(c, p)
+			}
+		}()
+	}
+
+	// Collect results until all workers have finished
+	var collected sync.WaitGroup
+	collected.Add(1)
+	go func() {
+		defer collected.Done()
+		for r := range results {
+			s := report.stats(r.kind)
+			s.Sent++
+			if r.err {
+				s.Errors++
+			}
+			s.Latencies = append(s.Latencies, r.latency)
+		}
+	}()
+
+	// One scheduler per kind, each ticking at its configured rate
+	var dropped sync.Map
+	var schedulers sync.WaitGroup
+	var genLock sync.Mutex
+	start := time.Now()
+	for k, rate := range c.Rates {
+		if rate <= 0 {
+			continue
+		}
+		schedulers.Add(1)
+		go func(k Kind, interval time.Duration) {
+			defer schedulers.Done()
+			var d int
+			defer func() { dropped.Store(k, d) }()
+			t := time.NewTicker(interval)
+			defer t.Stop()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-t.C:
+					genLock.Lock()
+					p, err := c.Generator.Generate(k)
+					genLock.Unlock()
+					if err != nil {
+						d++
+						continue
+					}
+					n := atomic.AddInt64(&backlog, 1)
+					select {
+					case jobs <- p:
+						for {
+							m := atomic.LoadInt64(&maxBacklog)
+							if n <= m || atomic.CompareAndSwapInt64(&maxBacklog, m, n) {
+								break
+							}
+						}
+					default:
+						atomic.AddInt64(&backlog, -1)
+						d++
+					}
+				}
+			}
+		}(k, time.Duration(float64(time.Second)/rate))
+	}
+
+	schedulers.Wait()
+	close(jobs)
+	workers.Wait()
+	close(results)
+	collected.Wait()
+
+	report.Elapsed = time.Since(start)
+	report.MaxBacklog = int(atomic.LoadInt64(&maxBacklog))
+	dropped.Range(func(k, v interface{}) bool {
+		report.stats(k.(Kind)).Dropped = v.(int)
+		return true
+	})
+	return report, nil
+}
+
+func (r *Report) stats(k Kind) *Stats {
+	s, ok := r.Kinds[k]
+	if !ok {
+		s = &Stats{}
+		r.Kinds[k] = s
+	}
+	return s
+}
+
+func send(c Config, p *Payload) result {
+	req, err := NewRequest(c.Target, c.Secret, c.DNHeader, p)
+	if err != nil {
+		return result{kind: p.Kind, err: true}
+	}
+	start := time.Now()
+	resp, err := c.Client.Do(req)
+	if err != nil {
+		return result{kind: p.Kind, latency: time.Since(start), err: true}
+	}
+	io.Copy(ioutil.Discard, resp.Body)
+	resp.Body.Close()
+	return result{kind: p.Kind, latency: time.Since(start), err: resp.StatusCode >= 400}
+}
@@ -0,0 +1,87 @@
+package loadtest
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/skybet/go-helpdesk/server"
+)
+
+func noopLog(...interface{})          {}
+func noopLogf(string, ...interface{}) {}
+
+func TestRun(t *testing.T) {
+	s := server.NewSlackHandler("/slack", "TOKEN", "secret", nil, noopLog, noopLogf, noopLog, noopLogf)
+	ok := func(res *server.Response, req *server.Request, ctx interface{}) error {
+		res.Text(200, "ok")
+		return nil
+	}
+	s.HandleCommand("/help-me", ok)
+	s.HandleEventCallback("app_mention", ok)
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	r, err := Run(context.Background(), Config{
+		Target:      ts.URL + "/slack",
+		Secret:      "secret",
+		Rates:       map[Kind]float64{KindCommand: 200, KindEvent: 200},
+		Duration:    200 * time.Millisecond,
+		Concurrency: 4,
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	for _, k := range []Kind{KindCommand, KindEvent} {
+		st := r.Kinds[k]
+		if st == nil || st.Sent == 0 {
+			t.Fatalf("Expected %s requests to be sent", k)
+		}
+		if st.Errors != 0 {
+			t.Errorf("Expected no %s errors, got %d of %d", k, st.Errors, st.Sent)
+		}
+	}
+	if _, ok := r.Kinds[KindAction]; ok {
+		t.Errorf("Did not expect actions to be reported with a zero rate")
+	}
+	var b strings.Builder
+	r.Print(&b)
+	if !strings.Contains(b.String(), "command") {
+		t.Errorf("Expected report to include commands: %s", b.String())
+	}
+}
+
+func TestRunBadSecret(t *testing.T) {
+	s := server.NewSlackHandler("/slack", "TOKEN", "secret", nil, noopLog, noopLogf, noopLog, noopLogf)
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	r, err := Run(context.Background(), Config{
+		Target:   ts.URL + "/slack",
+		Secret:   "wrong",
+		Rates:    map[Kind]float64{KindAction: 100},
+		Duration: 100 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	st := r.Kinds[KindAction]
+	if st.Sent == 0 || st.ErrorRate() != 1 {
+		t.Fatalf("Expected every request to fail signature validation, got %d of %d", st.Errors, st.Sent)
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	s := &Stats{}
+	for i := 1; i <= 100; i++ {
+		s.Latencies = append(s.Latencies, time.Duration(i)*time.Millisecond)
+	}
+	if p := s.Percentile(50); p != 50*time.Millisecond {
+		t.Errorf("Unexpected p50: %s", p)
+	}
+	if p := s.Percentile(99); p != 99*time.Millisecond {
+		t.Errorf("Unexpected p99: %s", p)
+	}
+}
@@ -0,0 +1,141 @@
+package loadtest
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// Kind is the type of synthetic Slack request being generated
+type Kind string
+
+// The kinds of request the load tester knows how to generate
+const (
+	KindCommand Kind = "command"
+	KindAction  Kind = "action"
+	KindEvent   Kind = "event"
+)
+
+// Kinds lists every Kind in the order they are reported
+var Kinds = []Kind{KindCommand, KindAction, KindEvent}
+
+// Payload is a synthetic request body along with its content type
+type Payload struct {
+	Kind        Kind
+	ContentType string
+	Body        []byte
+}
+
+// Generator builds the payloads sent by the load tester
+type Generator struct {
+	Command    string
+	CallbackID string
+	EventType  string
+	TeamID     string
+	rand       *rand.Rand
+}
+
+// NewGenerator returns a Generator producing requests for the given command,
+// block action callback ID and event type
+func NewGenerator(command, callbackID, eventType string) *Generator {
+	return &Generator{
+		Command:    command,
+		CallbackID: callbackID,
+		EventType:  eventType,
+		TeamID:     "TLOADTEST",
+		rand:       rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// Generate returns a new payload of the requested kind
+func (g *Generator) Generate(k Kind) (*Payload, error) {
+	user := fmt.Sprintf("ULOAD%05d", g.rand.Intn(100000))
+	switch k {
+	case KindCommand:
+		v := url.Values{}
+		v.Set("token", "loadtest")
+		v.Set("team_id", g.TeamID)
+		v.Set("team_domain", "loadtest")
+		v.Set("channel_id", "CLOADTEST")
+		v.Set("channel_name", "loadtest")
+		v.Set("user_id", user)
+		v.Set("user_name", user)
+		v.Set("command", g.Command)
+		v.Set("text", "load test")
+		v.Set("response_url", "https://hooks.slack.com/commands/loadtest")
+		v.Set("trigger_id", strconv.FormatInt(g.rand.Int63(), 10))
+		return &Payload{Kind: k, ContentType: "application/x-www-form-urlencoded", Body: []byte(v.Encode())}, nil
+	case KindAction:
+		p := map[string]interface{}{
+			"type":        "block_actions",
+			"callback_id": g.CallbackID,
+			"trigger_id":  strconv.FormatInt(g.rand.Int63(), 10),
+			"team":        map[string]string{"id": g.TeamID, "domain": "loadtest"},
+			"user":        map[string]string{"id": user, "name": user},
+			"channel":     map[string]string{"id": "CLOADTEST", "name": "loadtest"},
+			"actions": []map[string]string{
+				{"type": "button", "block_id": "loadtest", "action_id": "loadtest", "value": "loadtest"},
+			},
+		}
+		j, err := json.Marshal(p)
+		if err != nil {
+			return nil, err
+		}
+		v := url.Values{}
+		v.Set("payload", string(j))
+		return &Payload{Kind: k, ContentType: "application/x-www-form-urlencoded", Body: []byte(v.Encode())}, nil
+	case KindEvent:
+		ts := fmt.Sprintf("%d.%06d", time.Now().Unix(), g.rand.Intn(1000000))
+		p := map[string]interface{}{
+			"type":    "event_callback",
+			"team_id": g.TeamID,
+			"event": map[string]string{
+				"type":     g.EventType,
+				"user":     user,
+				"text":     "load test",
+				"channel":  "CLOADTEST",
+				"ts":       ts,
+				"event_ts": ts,
+			},
+		}
+		j, err := json.Marshal(p)
+		if err != nil {
+			return nil, err
+		}
+		return &Payload{Kind: k, ContentType: "application/json", Body: j}, nil
+	}
+	return nil, fmt.Errorf("unknown request kind: %s", k)
+}
+
+// NewRequest builds a signed HTTP request for the payload
+func NewRequest(target, secret string, dnHeader *string, p *Payload) (*http.Request, error) {
+	req, err := http.NewRequest("POST", target, bytes.NewReader(p.Body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", p.ContentType)
+	Sign(req, secret, p.Body, time.Now())
+	if dnHeader != nil {
+		req.Header.Set(*dnHeader, "CN=platform-tls-client.slack.com,O=Slack Technologies")
+	}
+	return req, nil
+}
+
+// Sign adds the X-Slack-Request-Timestamp and X-Slack-Signature headers Slack
+// would send for the given body
+func Sign(r *http.Request, secret string, body []byte, now time.Time) {
+	ts := now.Unix()
+	base := []byte(fmt.Sprintf("v0:%d:%s", ts, body))
+	h := hmac.New(sha256.New, []byte(secret))
+	h.Write(base)
+	r.Header.Set("X-Slack-Request-Timestamp", strconv.FormatInt(ts, 10))
+	r.Header.Set("X-Slack-Signature", fmt.Sprintf("v0=%s", hex.EncodeToString(h.Sum(nil))))
+}
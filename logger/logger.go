@@ -0,0 +1,156 @@
+// Package logger is the logging interface the Slack client, its websocket
+// connections and the server log through, so that deployments can plug in
+// logrus, log/slog or their own logger. Loggers carry fields such as the
+// request and team IDs, which are added to every line they log.
+package logger
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Logger logs lines with printf formatting at a level
+type Logger interface {
+	Debugf(format string, args ...interface{})
+	Infof(format string, args ...interface{})
+	Warnf(format string, args ...interface{})
+	Errorf(format string, args ...interface{})
+	// With returns a logger adding fields to every line, keyvals are pairs
+	// of field names and values
+	With(keyvals ...interface{}) Logger
+}
+
+// Default logs through logrus' standard logger
+var Default Logger = Logrus(log.StandardLogger())
+
+// Or returns l, or Default if l is nil
+func Or(l Logger) Logger {
+	if l == nil {
+		return Default
+	}
+	return l
+}
+
+// Logrus returns a logger writing to l
+func Logrus(l log.FieldLogger) Logger {
+	return logrusLogger{l}
+}
+
+type logrusLogger struct {
+	log.FieldLogger
+}
+
+func (l logrusLogger) With(keyvals ...interface{}) Logger {
+	fields := log.Fields{}
+	for k, v := range pairs(keyvals) {
+		fields[k] = v
+	}
+	return logrusLogger{l.WithFields(fields)}
+}
+
+// Slog returns a logger writing to l
+func Slog(l *slog.Logger) Logger {
+	return slogLogger{l}
+}
+
+type slogLogger struct {
+	l *slog.Logger
+}
+
+func (l slogLogger) Debugf(format string, args ...interface{}) {
+	l.l.Debug(fmt.Sprintf(format, args...))
+}
+
+func (l slogLogger) Infof(format string, args ...interface{}) {
+	l.l.Info(fmt.Sprintf(format, args...))
+}
+
+func (l slogLogger) Warnf(format string, args ...interface{}) {
+	l.l.Warn(fmt.Sprintf(format, args...))
+}
+
+func (l slogLogger) Errorf(format string, args ...interface{}) {
+	l.l.Error(fmt.Sprintf(format, args...))
+}
+
+func (l slogLogger) With(keyvals ...interface{}) Logger {
+	return slogLogger{l.l.With(keyvals...)}
+}
+
+// Funcs returns a logger calling logf for debug and info lines and errorf for
+// warnings and errors, either of which may be nil to discard them. Fields are
+// appended to the line as key=value.
+func Funcs(logf, errorf func(string, ...interface{})) Logger {
+	return &funcLogger{logf: logf, errorf: errorf}
+}
+
+type funcLogger struct {
+	logf, errorf func(string, ...interface{})
+	fields       string
+}
+
+func (l *funcLogger) Debugf(format string, args ...interface{}) { l.log(l.logf, format, args) }
+func (l *funcLogger) Infof(format string, args ...interface{})  { l.log(l.logf, format, args) }
+func (l *funcLogger) Warnf(format string, args ...interface{})  { l.log(l.errorf, format, args) }
+func (l *funcLogger) Errorf(format string, args ...interface{}) { l.log(l.errorf, format, args) }
+
+func (l *funcLogger) log(fn func(string, ...interface{}), format string, args []interface{}) {
+	if fn == nil {
+		return
+	}
+	if l.fields != "" {
+		fn("%s%s", fmt.Sprintf(format, args...), l.fields)
+		return
+	}
+	fn(format, args...)
+}
+
+func (l *funcLogger) With(keyvals ...interface{}) Logger {
+	var b strings.Builder
+	b.WriteString(l.fields)
+	for i := 0; i+1 < len(keyvals); i += 2 {
+		fmt.Fprintf(&b, " %v=%v", keyvals[i], keyvals[i+1])
+	}
+	return &funcLogger{logf: l.logf, errorf: l.errorf, fields: b.String()}
+}
+
+// pairs returns keyvals as a map, dropping a trailing key without a value
+func pairs(keyvals []interface{}) map[string]interface{} {
+	m := map[string]interface{}{}
+	for i := 0; i+1 < len(keyvals); i += 2 {
+		m[fmt.Sprint(keyvals[i])] = keyvals[i+1]
+	}
+	return m
+}
+
+type contextKey struct{}
+
+// NewContext returns a copy of ctx carrying l
+func NewContext(ctx context.Context, l Logger) context.Context {
+	return context.WithValue(ctx, contextKey{}, l)
+}
+
+// FromContext returns the logger carried by ctx, nil if it has none
+func FromContext(ctx context.Context) Logger {
+	if ctx == nil {
+		return nil
+	}
+	l, _ := ctx.Value(contextKey{}).(Logger)
+	return l
+}
+
+// Output adapts a logger to the Output method the slack package logs its
+// debugging through, logging each line at debug level
+type Output struct {
+	Logger Logger
+}
+
+// Output satisfies the slack package's logger interface
+func (o Output) Output(calldepth int, s string) error {
+	o.Logger.Debugf("%s", strings.TrimSuffix(s, "\n"))
+	return nil
+}
@@ -0,0 +1,78 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"testing"
+
+	log "github.com/sirupsen/logrus"
+)
+
+func TestLogrus(t *testing.T) {
+	var buf bytes.Buffer
+	l := log.New()
+	l.Out = &buf
+	l.Formatter = &log.TextFormatter{DisableTimestamp: true}
+	Logrus(l).With("request_id", "r1", "team_id", "T1").Warnf("slow %s", "call")
+	got := strings.TrimSpace(buf.String())
+	if want := `level=warning msg="slow call" request_id=r1 team_id=T1`; got != want {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+}
+
+func TestSlog(t *testing.T) {
+	var buf bytes.Buffer
+	l := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+		if a.Key == slog.TimeKey {
+			return slog.Attr{}
+		}
+		return a
+	}}))
+	Slog(l).With("team_id", "T1").Errorf("failed: %s", "boom")
+	Slog(l).Debugf("dropped below the handler's level")
+	got := strings.TrimSpace(buf.String())
+	if want := `level=ERROR msg="failed: boom" team_id=T1`; got != want {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+}
+
+func TestFuncs(t *testing.T) {
+	var lines []string
+	logf := func(format string, args ...interface{}) { lines = append(lines, "info: "+fmt.Sprintf(format, args...)) }
+	errorf := func(format string, args ...interface{}) {
+		lines = append(lines, "error: "+fmt.Sprintf(format, args...))
+	}
+	l := Funcs(logf, errorf).With("request_id", "r1").With("team_id", "T1")
+	l.Infof("routed %s", "/hd")
+	l.Errorf("100%% broken")
+	Funcs(nil, nil).Errorf("discarded")
+	want := []string{"info: routed /hd request_id=r1 team_id=T1", "error: 100% broken request_id=r1 team_id=T1"}
+	if fmt.Sprint(lines) != fmt.Sprint(want) {
+		t.Errorf("Expected %q, got %q", want, lines)
+	}
+}
+
+func TestContext(t *testing.T) {
+	if FromContext(context.Background()) != nil {
+		t.Errorf("Expected no logger in an empty context")
+	}
+	l := Funcs(nil, nil)
+	if got := FromContext(NewContext(context.Background(), l)); got != l {
+		t.Errorf("Expected the logger put in the context, got %v", got)
+	}
+	if Or(nil) != Default || Or(l) != l {
+		t.Errorf("Expected Or to fall back to Default only for nil")
+	}
+}
+
+func TestOutput(t *testing.T) {
+	var lines []string
+	o := Output{Logger: Funcs(func(format string, args ...interface{}) { lines = append(lines, fmt.Sprintf(format, args...)) }, nil)}
+	o.Output(2, "Incoming Event: {}\n")
+	if len(lines) != 1 || lines[0] != "Incoming Event: {}" {
+		t.Errorf("Expected the line without its newline, got %q", lines)
+	}
+}
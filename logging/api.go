@@ -0,0 +1,88 @@
+package logging
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/skybet/go-helpdesk/server"
+)
+
+// API lets admins change the logging settings over HTTP. Every request must
+// carry the token as a bearer token.
+type API struct {
+	controller *Controller
+	handler    http.Handler
+}
+
+// NewAPI returns an API for c. Mount it with http.StripPrefix so that its
+// routes, such as /debug, are at the root.
+func NewAPI(c *Controller, token string) *API {
+	a := &API{controller: c}
+	a.handler = server.RequireToken(token, http.HandlerFunc(a.route))
+	return a
+}
+
+// settingsJSON is the logging settings in the API
+type settingsJSON struct {
+	Level        string     `json:"level"`
+	CaptureUntil *time.Time `json:"capture_until,omitempty"`
+}
+
+// ServeHTTP satisfies http.Handler. GET /debug returns the settings, PUT
+// /debug/level sets the log level from {"level": "debug"}, PUT /debug/capture
+// captures payloads for {"minutes": 10} and DELETE /debug/capture stops.
+func (a *API) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	a.handler.ServeHTTP(w, r)
+}
+
+// route serves the requests which carried the token
+func (a *API) route(w http.ResponseWriter, r *http.Request) {
+	switch path := strings.Trim(r.URL.Path, "/"); {
+	case path == "debug" && r.Method == http.MethodGet:
+		a.settings(w)
+	case path == "debug/level" && r.Method == http.MethodPut:
+		var body struct {
+			Level string `json:"level"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, "expected {\"level\": \"<level>\"}", http.StatusBadRequest)
+			return
+		}
+		if err := a.controller.SetLevel(body.Level); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		a.settings(w)
+	case path == "debug/capture" && r.Method == http.MethodPut:
+		var body struct {
+			Minutes int `json:"minutes"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.Minutes <= 0 {
+			http.Error(w, "expected {\"minutes\": <minutes>}", http.StatusBadRequest)
+			return
+		}
+		if _, err := a.controller.Capture(time.Duration(body.Minutes) * time.Minute); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		a.settings(w)
+	case path == "debug/capture" && r.Method == http.MethodDelete:
+		a.controller.Capture(0)
+		a.settings(w)
+	case path == "debug" || path == "debug/level" || path == "debug/capture":
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (a *API) settings(w http.ResponseWriter) {
+	s := settingsJSON{Level: a.controller.Level().String()}
+	if until := a.controller.CaptureUntil(); !until.IsZero() {
+		s.CaptureUntil = &until
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s)
+}
@@ -0,0 +1,55 @@
+package logging
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAPI(t *testing.T) {
+	captureLog(t)
+	c := New()
+	a := NewAPI(c, "secret")
+	serve := func(method, path, token, body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, path, strings.NewReader(body))
+		if token != "" {
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		a.ServeHTTP(w, r)
+		return w
+	}
+	settings := func(w *httptest.ResponseRecorder) settingsJSON {
+		var s settingsJSON
+		if err := json.Unmarshal(w.Body.Bytes(), &s); err != nil {
+			t.Fatalf("Unexpected error: %s, body %s", err, w.Body)
+		}
+		return s
+	}
+
+	if w := serve("GET", "/debug", "wrong", ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected requests without the token to be refused, got %d", w.Code)
+	}
+	if s := settings(serve("PUT", "/debug/level", "secret", `{"level":"debug"}`)); s.Level != "debug" {
+		t.Errorf("Expected the level to be set, got %+v", s)
+	}
+	if w := serve("PUT", "/debug/level", "secret", `{"level":"loud"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected unknown levels to be refused, got %d", w.Code)
+	}
+	s := settings(serve("PUT", "/debug/capture", "secret", `{"minutes":5}`))
+	if s.CaptureUntil == nil || s.CaptureUntil.Before(time.Now().Add(4*time.Minute)) {
+		t.Errorf("Expected payloads to be captured for 5 minutes, got %+v", s)
+	}
+	if w := serve("PUT", "/debug/capture", "secret", `{"minutes":600}`); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected long captures to be refused, got %d", w.Code)
+	}
+	if s := settings(serve("DELETE", "/debug/capture", "secret", "")); s.CaptureUntil != nil {
+		t.Errorf("Expected capturing to stop, got %+v", s)
+	}
+	if w := serve("POST", "/debug", "secret", ""); w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected other methods to be refused, got %d", w.Code)
+	}
+}
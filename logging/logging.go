@@ -0,0 +1,161 @@
+// Package logging switches the log level at runtime and captures the payloads
+// of Slack callbacks and API calls for a while, with secrets redacted, so that
+// production issues can be debugged without restarting with debug flags.
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/skybet/go-helpdesk/wrapper"
+)
+
+// MaxCapture is the longest payloads can be captured for at once
+const MaxCapture = time.Hour
+
+// secrets are the fields redacted from captured payloads. Response URLs can
+// be used to post as the app so they are secrets too.
+var secrets = map[string]bool{"token": true, "client_secret": true, "response_url": true}
+
+// Controller holds the runtime logging settings of the standard logger
+type Controller struct {
+	mu           sync.Mutex
+	captureUntil time.Time
+	now          func() time.Time
+}
+
+// New returns a Controller which is not capturing payloads
+func New() *Controller {
+	return &Controller{now: time.Now}
+}
+
+// Level returns the current log level
+func (c *Controller) Level() log.Level {
+	return log.GetLevel()
+}
+
+// SetLevel parses and sets the log level, e.g. debug
+func (c *Controller) SetLevel(level string) error {
+	l, err := log.ParseLevel(level)
+	if err != nil {
+		return err
+	}
+	log.SetLevel(l)
+	log.Infof("Log level set to %s", l)
+	return nil
+}
+
+// Capture logs the payloads of callbacks and API calls for d, up to
+// MaxCapture, returning when capturing will stop. Zero stops capturing.
+func (c *Controller) Capture(d time.Duration) (time.Time, error) {
+	if d < 0 || d > MaxCapture {
+		return time.Time{}, fmt.Errorf("payloads can be captured for up to %s", MaxCapture)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if d == 0 {
+		c.captureUntil = time.Time{}
+		log.Info("Stopped capturing payloads")
+		return time.Time{}, nil
+	}
+	c.captureUntil = c.now().Add(d)
+	log.Infof("Capturing payloads until %s", c.captureUntil.Format(time.RFC3339))
+	return c.captureUntil, nil
+}
+
+// CaptureUntil returns when capturing payloads stops, zero if it is not
+// capturing
+func (c *Controller) CaptureUntil() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.now().Before(c.captureUntil) {
+		return time.Time{}
+	}
+	return c.captureUntil
+}
+
+// capturing reports whether payloads are being captured
+func (c *Controller) capturing() bool {
+	return !c.CaptureUntil().IsZero()
+}
+
+// Middleware logs the payload of every request to next while capturing
+func (c *Controller) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if c.capturing() && r.Body != nil {
+			body, err := ioutil.ReadAll(r.Body)
+			r.Body.Close()
+			if err != nil {
+				http.Error(w, "error reading request", http.StatusBadRequest)
+				return
+			}
+			r.Body = ioutil.NopCloser(bytes.NewReader(body))
+			log.WithFields(log.Fields{"path": r.URL.Path, "payload": Redact(body)}).Info("Captured callback")
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Hook logs Slack API calls while capturing, their params are already
+// redacted by the wrapper
+func (c *Controller) Hook(call *wrapper.Call) {
+	if !c.capturing() {
+		return
+	}
+	log.WithFields(log.Fields{"params": call.Params.Encode(), "status": call.StatusCode, "duration": call.Duration, "error": call.Err}).Infof("Captured Slack API call %s", call.Method)
+}
+
+// Redact returns a payload with its secrets replaced by wrapper.Redacted. Form
+// values holding JSON, such as the payload of interactions, are redacted too.
+func Redact(body []byte) string {
+	var v interface{}
+	if json.Unmarshal(body, &v) == nil {
+		return redactJSON(v)
+	}
+	form, err := url.ParseQuery(string(body))
+	if err != nil {
+		return wrapper.Redacted
+	}
+	for k, vs := range form {
+		for i, s := range vs {
+			switch {
+			case secrets[k]:
+				vs[i] = wrapper.Redacted
+			case json.Unmarshal([]byte(s), &v) == nil:
+				vs[i] = redactJSON(v)
+			}
+		}
+	}
+	return form.Encode()
+}
+
+func redactJSON(v interface{}) string {
+	b, _ := json.Marshal(redactValue(v))
+	return string(b)
+}
+
+func redactValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, f := range v {
+			if secrets[k] {
+				v[k] = wrapper.Redacted
+			} else {
+				v[k] = redactValue(f)
+			}
+		}
+	case []interface{}:
+		for i, f := range v {
+			v[i] = redactValue(f)
+		}
+	}
+	return v
+}
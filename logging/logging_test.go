@@ -0,0 +1,95 @@
+package logging
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/skybet/go-helpdesk/wrapper"
+)
+
+// captureLog sends the standard logger's output to a buffer until the test
+// ends
+func captureLog(t *testing.T) *bytes.Buffer {
+	var buf bytes.Buffer
+	out, level := log.StandardLogger().Out, log.GetLevel()
+	log.SetOutput(&buf)
+	t.Cleanup(func() {
+		log.SetOutput(out)
+		log.SetLevel(level)
+	})
+	return &buf
+}
+
+func TestSetLevel(t *testing.T) {
+	captureLog(t)
+	c := New()
+	if err := c.SetLevel("debug"); err != nil || c.Level() != log.DebugLevel {
+		t.Errorf("Expected the level to be debug, got %s %v", c.Level(), err)
+	}
+	if err := c.SetLevel("loud"); err == nil || c.Level() != log.DebugLevel {
+		t.Errorf("Expected unknown levels to be refused")
+	}
+}
+
+func TestCapture(t *testing.T) {
+	buf := captureLog(t)
+	c := New()
+	now := time.Date(2019, 3, 4, 9, 0, 0, 0, time.UTC)
+	c.now = func() time.Time { return now }
+	h := c.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("text") != "help" {
+			t.Errorf("Expected the handler to still read the body, got %q", r.FormValue("text"))
+		}
+	}))
+	post := func() {
+		r := httptest.NewRequest("POST", "/slack", strings.NewReader("token=T0K3N&text=help&response_url=https%3A%2F%2Fhooks.slack.com%2Fx"))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		h.ServeHTTP(httptest.NewRecorder(), r)
+	}
+
+	post()
+	if strings.Contains(buf.String(), "Captured") {
+		t.Errorf("Expected nothing to be captured until asked, got %s", buf)
+	}
+	if _, err := c.Capture(2 * MaxCapture); err == nil {
+		t.Errorf("Expected captures longer than MaxCapture to be refused")
+	}
+	until, err := c.Capture(10 * time.Minute)
+	if err != nil || !until.Equal(now.Add(10*time.Minute)) || !c.CaptureUntil().Equal(until) {
+		t.Fatalf("Expected to capture for 10 minutes, got %s %v", until, err)
+	}
+	post()
+	c.Hook(&wrapper.Call{Method: "chat.postMessage"})
+	out := buf.String()
+	if !strings.Contains(out, "Captured callback") || !strings.Contains(out, "text=help") || !strings.Contains(out, "Captured Slack API call chat.postMessage") {
+		t.Errorf("Expected the callback and the API call to be captured, got %s", out)
+	}
+	if strings.Contains(out, "T0K3N") || strings.Contains(out, "hooks.slack.com") {
+		t.Errorf("Expected secrets to be redacted, got %s", out)
+	}
+
+	buf.Reset()
+	now = now.Add(10 * time.Minute)
+	post()
+	if strings.Contains(buf.String(), "Captured") || !c.CaptureUntil().IsZero() {
+		t.Errorf("Expected capturing to stop by itself, got %s", buf)
+	}
+}
+
+func TestRedact(t *testing.T) {
+	for body, expected := range map[string]string{
+		`{"token":"x","event":{"text":"hi","response_url":"y"}}`: `{"event":{"response_url":"[redacted]","text":"hi"},"token":"[redacted]"}`,
+		`payload=%7B%22token%22%3A%22x%22%7D`:                    `payload=%7B%22token%22%3A%22%5Bredacted%5D%22%7D`,
+		`token=x&user_id=U1`:                                     `token=%5Bredacted%5D&user_id=U1`,
+	} {
+		if got := Redact([]byte(body)); got != expected {
+			t.Errorf("Expected %s to be redacted to %s, got %s", body, expected, got)
+		}
+	}
+}
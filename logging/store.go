@@ -0,0 +1,150 @@
+package logging
+
+import (
+	"context"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/skybet/go-helpdesk/store"
+	"github.com/skybet/go-helpdesk/ticket"
+)
+
+// SlowQueries returns a store which logs calls to s taking threshold or
+// longer as warnings, with their parameters so the query can be reproduced.
+// Calls inside transactions are timed too.
+func SlowQueries(s store.Store, threshold time.Duration) store.Store {
+	return &slowStore{Store: s, threshold: threshold, now: time.Now}
+}
+
+type slowStore struct {
+	store.Store
+	threshold time.Duration
+	now       func() time.Time
+}
+
+// observe logs the call if it started long enough ago, it is deferred by each
+// method
+func (s *slowStore) observe(method string, params log.Fields, start time.Time) {
+	d := s.now().Sub(start)
+	if d < s.threshold {
+		return
+	}
+	params["duration"] = d
+	log.WithFields(params).Warnf("Slow store query %s", method)
+}
+
+func (s *slowStore) CreateTicket(ctx context.Context, t *ticket.Ticket) error {
+	defer s.observe("CreateTicket", log.Fields{"queue": t.Queue}, s.now())
+	return s.Store.CreateTicket(ctx, t)
+}
+
+func (s *slowStore) GetTicket(ctx context.Context, id string) (*ticket.Ticket, error) {
+	defer s.observe("GetTicket", log.Fields{"id": id}, s.now())
+	return s.Store.GetTicket(ctx, id)
+}
+
+func (s *slowStore) DeleteTicket(ctx context.Context, id string) error {
+	defer s.observe("DeleteTicket", log.Fields{"id": id}, s.now())
+	return s.Store.DeleteTicket(ctx, id)
+}
+
+func (s *slowStore) UpdateTicket(ctx context.Context, t *ticket.Ticket) error {
+	defer s.observe("UpdateTicket", log.Fields{"id": t.ID, "version": t.Version}, s.now())
+	return s.Store.UpdateTicket(ctx, t)
+}
+
+func (s *slowStore) ListTickets(ctx context.Context, f store.Filter) ([]*ticket.Ticket, string, error) {
+	defer s.observe("ListTickets", filterFields(f), s.now())
+	return s.Store.ListTickets(ctx, f)
+}
+
+func (s *slowStore) Transition(ctx context.Context, id string, from, to ticket.Status) (*ticket.Ticket, error) {
+	defer s.observe("Transition", log.Fields{"id": id, "from": from, "to": to}, s.now())
+	return s.Store.Transition(ctx, id, from, to)
+}
+
+func (s *slowStore) Tx(ctx context.Context, fn func(s store.Store) error) error {
+	defer s.observe("Tx", log.Fields{}, s.now())
+	return s.Store.Tx(ctx, func(tx store.Store) error {
+		return fn(&slowStore{Store: tx, threshold: s.threshold, now: s.now})
+	})
+}
+
+func (s *slowStore) Enqueue(ctx context.Context, n *store.Notification) error {
+	defer s.observe("Enqueue", log.Fields{"ticket": n.TicketID, "channel": n.ChannelID}, s.now())
+	return s.Store.Enqueue(ctx, n)
+}
+
+func (s *slowStore) Outbox(ctx context.Context, limit int) ([]*store.Notification, error) {
+	defer s.observe("Outbox", log.Fields{"limit": limit}, s.now())
+	return s.Store.Outbox(ctx, limit)
+}
+
+func (s *slowStore) Sent(ctx context.Context, id string) error {
+	defer s.observe("Sent", log.Fields{"id": id}, s.now())
+	return s.Store.Sent(ctx, id)
+}
+
+func (s *slowStore) Failed(ctx context.Context, id string, reason string) error {
+	defer s.observe("Failed", log.Fields{"id": id}, s.now())
+	return s.Store.Failed(ctx, id, reason)
+}
+
+func (s *slowStore) SetQueueChannel(ctx context.Context, qc *store.QueueChannel) error {
+	defer s.observe("SetQueueChannel", log.Fields{"queue": qc.Queue}, s.now())
+	return s.Store.SetQueueChannel(ctx, qc)
+}
+
+func (s *slowStore) QueueChannels(ctx context.Context) ([]*store.QueueChannel, error) {
+	defer s.observe("QueueChannels", log.Fields{}, s.now())
+	return s.Store.QueueChannels(ctx)
+}
+
+// filterFields returns the fields of f which are set
+func filterFields(f store.Filter) log.Fields {
+	fields := log.Fields{}
+	set := func(k, v string) {
+		if v != "" {
+			fields[k] = v
+		}
+	}
+	if len(f.Status) > 0 {
+		fields["status"] = f.Status
+	}
+	set("queue", f.Queue)
+	set("assignee", f.Assignee)
+	set("reporter", f.Reporter)
+	set("channel", f.ChannelID)
+	set("thread", f.ThreadTS)
+	if len(f.Tags) > 0 {
+		fields["tags"] = f.Tags
+	}
+	if len(f.Priority) > 0 {
+		fields["priority"] = f.Priority
+	}
+	if f.Unassigned {
+		fields["unassigned"] = true
+	}
+	for k, t := range map[string]time.Time{"created_after": f.CreatedAfter, "created_before": f.CreatedBefore, "updated_after": f.UpdatedAfter, "updated_before": f.UpdatedBefore} {
+		if !t.IsZero() {
+			fields[k] = t
+		}
+	}
+	if len(f.Exclude) > 0 {
+		exclude := make([]log.Fields, len(f.Exclude))
+		for i, ex := range f.Exclude {
+			exclude[i] = filterFields(ex)
+		}
+		fields["exclude"] = exclude
+	}
+	if f.Deleted {
+		fields["deleted"] = true
+	}
+	set("text", f.Text)
+	if f.Limit > 0 {
+		fields["limit"] = f.Limit
+	}
+	set("cursor", f.Cursor)
+	return fields
+}
@@ -0,0 +1,47 @@
+package logging
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/skybet/go-helpdesk/store"
+	"github.com/skybet/go-helpdesk/ticket"
+)
+
+func TestSlowQueries(t *testing.T) {
+	buf := captureLog(t)
+	s := SlowQueries(store.NewMemory(), 50*time.Millisecond).(*slowStore)
+	clock := time.Date(2026, 10, 14, 9, 0, 0, 0, time.UTC)
+	// Every call to now moves the clock on, so each query takes 10ms and
+	// the transaction around them longer
+	s.now = func() time.Time {
+		clock = clock.Add(10 * time.Millisecond)
+		return clock
+	}
+	ctx := context.Background()
+	s.CreateTicket(ctx, &ticket.Ticket{Queue: "support"})
+	s.ListTickets(ctx, store.Filter{Queue: "support", Status: []ticket.Status{ticket.StatusNew}, Text: "vpn"})
+	if buf.Len() != 0 {
+		t.Errorf("Expected fast queries not to be logged, got %s", buf)
+	}
+
+	s.Tx(ctx, func(tx store.Store) error {
+		for i := 0; i < 3; i++ {
+			tx.ListTickets(ctx, store.Filter{Queue: "support"})
+		}
+		return nil
+	})
+	out := buf.String()
+	if !strings.Contains(out, "Slow store query Tx") || strings.Contains(out, "ListTickets") {
+		t.Errorf("Expected only the transaction to be slow, got %s", out)
+	}
+
+	s.threshold = 10 * time.Millisecond
+	buf.Reset()
+	s.ListTickets(ctx, store.Filter{Queue: "support", Text: "vpn", Limit: 50})
+	if out := buf.String(); !strings.Contains(out, "Slow store query ListTickets") || !strings.Contains(out, "queue=support") || !strings.Contains(out, "text=vpn") || !strings.Contains(out, "limit=50") || strings.Contains(out, "assignee") {
+		t.Errorf("Expected the query to be logged with the filter's parameters, got %s", out)
+	}
+}
@@ -2,16 +2,76 @@
 package main
 
 import (
+	"context"
+	"database/sql"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
 	"strings"
 	"syscall"
+	"time"
 
+	"github.com/skybet/go-helpdesk/access"
+	"github.com/skybet/go-helpdesk/admin"
+	"github.com/skybet/go-helpdesk/announce"
+	"github.com/skybet/go-helpdesk/appreciation"
+	"github.com/skybet/go-helpdesk/approval"
+	"github.com/skybet/go-helpdesk/archive"
+	"github.com/skybet/go-helpdesk/assign"
+	"github.com/skybet/go-helpdesk/atomicfile"
+	"github.com/skybet/go-helpdesk/audit"
+	"github.com/skybet/go-helpdesk/budget"
+	"github.com/skybet/go-helpdesk/catalog"
+	"github.com/skybet/go-helpdesk/clock"
+	"github.com/skybet/go-helpdesk/crosspost"
+	"github.com/skybet/go-helpdesk/diagnostics"
+	"github.com/skybet/go-helpdesk/digest"
+	"github.com/skybet/go-helpdesk/doctor"
+	"github.com/skybet/go-helpdesk/drain"
+	"github.com/skybet/go-helpdesk/email"
+	"github.com/skybet/go-helpdesk/encrypt"
+	"github.com/skybet/go-helpdesk/escalate"
 	"github.com/skybet/go-helpdesk/handlers"
+	"github.com/skybet/go-helpdesk/hierarchy"
+	"github.com/skybet/go-helpdesk/i18n"
+	"github.com/skybet/go-helpdesk/inbox"
+	"github.com/skybet/go-helpdesk/intake"
+	"github.com/skybet/go-helpdesk/jira"
+	"github.com/skybet/go-helpdesk/lifecycle"
+	"github.com/skybet/go-helpdesk/links"
+	"github.com/skybet/go-helpdesk/logger"
+	"github.com/skybet/go-helpdesk/logging"
+	"github.com/skybet/go-helpdesk/metrics"
+	"github.com/skybet/go-helpdesk/notify"
+	"github.com/skybet/go-helpdesk/ocr"
+	"github.com/skybet/go-helpdesk/outbox"
+	"github.com/skybet/go-helpdesk/policy"
+	"github.com/skybet/go-helpdesk/preview"
+	"github.com/skybet/go-helpdesk/projection"
+	"github.com/skybet/go-helpdesk/provision"
+	"github.com/skybet/go-helpdesk/region"
+	"github.com/skybet/go-helpdesk/render"
+	"github.com/skybet/go-helpdesk/report"
+	"github.com/skybet/go-helpdesk/runbook"
+	"github.com/skybet/go-helpdesk/saved"
 	"github.com/skybet/go-helpdesk/server"
+	"github.com/skybet/go-helpdesk/sla"
+	"github.com/skybet/go-helpdesk/sso"
+	"github.com/skybet/go-helpdesk/store"
+	"github.com/skybet/go-helpdesk/store/postgres"
+	"github.com/skybet/go-helpdesk/threads"
+	"github.com/skybet/go-helpdesk/ticket"
+	"github.com/skybet/go-helpdesk/ticketapi"
+	"github.com/skybet/go-helpdesk/tracing"
+	"github.com/skybet/go-helpdesk/transcribe"
+	"github.com/skybet/go-helpdesk/trash"
+	"github.com/skybet/go-helpdesk/webhook"
+	"github.com/skybet/go-helpdesk/wip"
 	"github.com/skybet/go-helpdesk/wrapper"
 
+	_ "github.com/lib/pq"
+	"github.com/nlopes/slack"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
@@ -30,26 +90,838 @@ func main() {
 		pflag.PrintDefaults()
 		return
 	}
-	sw, err := wrapper.New(appToken, botToken)
+	logs := logging.New()
+	if err := logs.SetLevel(viper.GetString("log-level")); err != nil {
+		log.Fatalf("Error setting the log level: %s", err)
+	}
+	handlers.InitLogging(logs)
+	budgets, err := budget.ParseBudgets(budget.Tiers, viper.GetStringSlice("api-budgets"))
+	if err != nil {
+		log.Fatalf("Error parsing API budgets: %s", err)
+	}
+	pacing := wrapper.RateLimits{Retries: viper.GetInt("api-retries")}
+	if viper.GetBool("api-pacing") {
+		pacing.PerMinute = budgets
+	}
+	usage := &budget.Tracker{Budgets: budgets, Warn: viper.GetFloat64("api-budget-warning"), Channel: viper.GetString("api-budget-channel"), Quiet: time.Hour}
+	sw, err := wrapper.New(appToken, botToken, wrapper.WithDirectoryCache(wrapper.DirectoryConfig{
+		TTL:            viper.GetDuration("directory-ttl"),
+		MaxUsers:       viper.GetInt("directory-max-users"),
+		MaxChannels:    viper.GetInt("directory-max-channels"),
+		UserLookups:    viper.GetInt("directory-user-lookups"),
+		ChannelLookups: viper.GetInt("directory-channel-lookups"),
+		Usergroups:     viper.GetInt("directory-usergroups"),
+	}), wrapper.WithResponseHook(func(c *wrapper.Call) {
+		// Calls made for a request are logged with its request and team IDs
+		logger.Or(logger.FromContext(c.Context)).With("duration", c.Duration, "status", c.StatusCode, "error", c.Err).Debugf("Slack API call %s", c.Method)
+	}), wrapper.WithResponseHook(logs.Hook), wrapper.WithResponseHook(usage.Hook), wrapper.WithResponseHook(wrapper.MetricsHook), wrapper.WithRateLimits(pacing), slackClientLogging())
 	if err != nil {
 		log.Fatalf("Error initialising the Slack API: %s", err)
 	}
+	if usage.Channel != "" {
+		usage.Poster = sw
+	}
+	if path := viper.GetString("directory-snapshot"); path != "" {
+		if err := loadSnapshot(sw.Directory, path); err != nil {
+			log.Warnf("Starting with empty directory caches: %s", err)
+		}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if endpoint := viper.GetString("otlp-endpoint"); endpoint != "" {
+		exporter := tracing.NewOTLP(endpoint, viper.GetString("trace-service"))
+		tracing.Default.Exporter = exporter
+		go exporter.Run(ctx, 5*time.Second, log.Errorf)
+	}
+	go sw.Directory.Run(ctx)
+	if usage.Poster != nil {
+		go usage.Run(ctx, log.Errorf)
+	}
 	handlers.Init(sw)
+	responseTargets, err := sla.ParseTargets(viper.GetStringSlice("sla-response"))
+	if err != nil {
+		log.Fatalf("Error parsing first response SLA targets: %s", err)
+	}
+	resolutionTargets, err := sla.ParseTargets(viper.GetStringSlice("sla-resolution"))
+	if err != nil {
+		log.Fatalf("Error parsing resolution SLA targets: %s", err)
+	}
+	serviceLevels := sla.SLA{Response: responseTargets, Resolution: resolutionTargets}
+	var units *hierarchy.Tree
+	if path := viper.GetString("hierarchy"); path != "" {
+		if units, err = hierarchy.Load(path, serviceLevels); err != nil {
+			log.Fatalf("Error loading the hierarchy: %s", err)
+		}
+		serviceLevels = units.SLA(serviceLevels)
+		handlers.InitHierarchy(units)
+	}
+	if path := viper.GetString("catalog"); path != "" {
+		services, err := catalog.Load(path)
+		if err != nil {
+			log.Fatalf("Error loading the service catalog: %s", err)
+		}
+		serviceLevels = services.SLA(serviceLevels)
+		handlers.InitCatalog(services)
+		adapters := access.Adapters{}
+		if path := viper.GetString("google-key"); path != "" {
+			g, err := access.NewGoogleGroups(path, viper.GetString("google-admin"))
+			if err != nil {
+				log.Fatalf("Error setting up Google Workspace groups: %s", err)
+			}
+			adapters[access.Google] = g
+		}
+		if org := viper.GetString("github-org"); org != "" {
+			adapters[access.GitHub] = &access.GitHubTeams{Org: org, Token: viper.GetString("github-token")}
+		}
+		if u := viper.GetString("okta-url"); u != "" {
+			adapters[access.Okta] = &access.OktaGroups{URL: u, Token: viper.GetString("okta-token")}
+		}
+		if missing := adapters.Missing(services); len(missing) > 0 {
+			log.Fatalf("The service catalog grants access in %s, which have not been set up", strings.Join(missing, ", "))
+		}
+		handlers.InitAccess(adapters)
+	}
+	handlers.InitSLA(serviceLevels)
+	// Dashboards and the reporting API read from the projection instead of
+	// listing every ticket
+	projector := projection.New(serviceLevels, viper.GetDuration("sla-warning"))
+	var primary store.Store = store.NewMemory()
+	if path := viper.GetString("event-log"); path != "" {
+		events, err := store.OpenEventLog(path)
+		if err != nil {
+			log.Fatalf("Error opening the event log: %s", err)
+		}
+		defer events.Close()
+		primary = events
+	}
+	if dsn := viper.GetString("postgres-url"); dsn != "" {
+		if viper.GetString("event-log") != "" {
+			log.Fatalf("Tickets can be kept in --event-log or --postgres-url, not both")
+		}
+		db, err := sql.Open("postgres", dsn)
+		if err != nil {
+			log.Fatalf("Error connecting to PostgreSQL: %s", err)
+		}
+		defer db.Close()
+		pg, err := postgres.Open(ctx, db, viper.GetString("postgres-schema"))
+		if err != nil {
+			log.Fatalf("Error opening the PostgreSQL store: %s", err)
+		}
+		primary = pg
+		// Teams with a shard keep their tickets in a schema of their own, or
+		// in another database
+		if specs := viper.GetStringSlice("shards"); len(specs) > 0 {
+			shards := map[string]store.Store{}
+			for _, spec := range specs {
+				parts := strings.SplitN(spec, "=", 2)
+				if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+					log.Fatalf("Error parsing shard %q, expected <team>=<schema or PostgreSQL URL>", spec)
+				}
+				sdb, schema := db, parts[1]
+				if strings.Contains(parts[1], "://") {
+					if sdb, err = sql.Open("postgres", parts[1]); err != nil {
+						log.Fatalf("Error connecting to the shard of team %s: %s", parts[0], err)
+					}
+					defer sdb.Close()
+					schema = viper.GetString("postgres-schema")
+				}
+				if shards[parts[0]], err = postgres.Open(ctx, sdb, schema); err != nil {
+					log.Fatalf("Error opening the shard of team %s: %s", parts[0], err)
+				}
+			}
+			primary = store.NewSharded(pg, shards)
+		}
+	} else if len(viper.GetStringSlice("shards")) > 0 {
+		log.Fatalf("Tickets can only be sharded by team in --postgres-url")
+	}
+	if threshold := viper.GetDuration("slow-store-queries"); threshold > 0 {
+		primary = logging.SlowQueries(primary, threshold)
+	}
+	if tracing.Default.Exporter != nil {
+		primary = tracing.Default.Store(primary)
+	}
+	// The admin APIs only need the tickets' metadata, so they read the
+	// encrypted fields sealed
+	sealed := primary
+	enc, err := encrypter()
+	if err != nil {
+		log.Fatalf("Error configuring encryption: %s", err)
+	}
+	if enc != nil {
+		sealed = encrypt.NewSealedStore(primary, enc)
+		primary = encrypt.NewStore(primary, enc)
+	}
+	if err := projector.Load(ctx, primary); err != nil {
+		log.Fatalf("Error loading ticket projections: %s", err)
+	}
+	go projector.Run(ctx)
+	tickets := projector.Wrap(primary)
+	sealedTickets := projector.Wrap(sealed)
+	if urls := viper.GetStringSlice("webhook-urls"); len(urls) > 0 {
+		events, err := webhook.ParseEvents(viper.GetStringSlice("webhook-events"))
+		if err != nil {
+			log.Fatalf("Error parsing webhook events: %s", err)
+		}
+		hooks := webhook.New(urls, viper.GetString("webhook-secret"))
+		hooks.Events = events
+		hooks.Retries = viper.GetInt("webhook-retries")
+		hooks.Logf = log.Errorf
+		go hooks.Run(ctx)
+		tickets = hooks.Wrap(tickets)
+	}
+	var mirror *jira.Sync
+	if path := viper.GetString("jira-config"); path != "" {
+		cfg, err := jira.LoadConfig(path)
+		if err != nil {
+			log.Fatalf("Error loading the Jira config: %s", err)
+		}
+		if token := viper.GetString("jira-token"); token != "" {
+			cfg.Token = token
+		}
+		mirror = jira.New(cfg, tickets, sw)
+		mirror.Logf = log.Errorf
+		go mirror.Run(ctx)
+		tickets = mirror.Wrap(tickets)
+	}
+	handlers.InitTickets(tickets)
+	handlers.InitProjection(projector)
+	quietHours, err := notify.ParseHours(viper.GetString("quiet-hours"))
+	if err != nil {
+		log.Fatalf("Error parsing quiet hours: %s", err)
+	}
+	notifier := notify.New(sw, sw.Directory, quietHours)
+	notifier.BreakThrough = viper.GetBool("quiet-hours-break-through")
+	handlers.InitNotifier(notifier)
+	ticketLinks := links.New(viper.GetString("links-url"), viper.GetString("team-id"), viper.GetString("app-id"), viper.GetString("admin-url"))
+	for _, a := range viper.GetStringSlice("link-aliases") {
+		ids := strings.SplitN(a, "=", 2)
+		if len(ids) != 2 || ids[0] == "" || ids[1] == "" {
+			log.Fatalf("Error parsing link alias %q, expected <old>=<new>", a)
+		}
+		ticketLinks.Alias(ids[0], ids[1])
+	}
+	handlers.InitLinks(ticketLinks)
+	var runbooks *runbook.Runbooks
+	if specs := viper.GetStringSlice("runbooks"); len(specs) > 0 {
+		urls, err := runbook.Parse(specs)
+		if err != nil {
+			log.Fatalf("Error parsing runbooks: %s", err)
+		}
+		runbooks = runbook.New(urls, viper.GetString("runbooks-url"))
+		if path := viper.GetString("runbook-usage"); path != "" {
+			runbooks, err = runbook.Load(urls, viper.GetString("runbooks-url"), path)
+			if err != nil {
+				log.Fatalf("Error loading runbook usage: %s", err)
+			}
+		}
+		handlers.InitRunbooks(runbooks)
+	}
+	styles := render.NewPreferences(viper.GetStringSlice("plain-text-users"))
+	handlers.InitStyles(styles)
+	taxonomy, err := render.ParseTaxonomy(viper.GetStringSlice("badges"))
+	if err != nil {
+		log.Fatalf("Error parsing badges: %s", err)
+	}
+	if custom, err := sw.Bot.GetEmoji(); err != nil {
+		log.Errorf("Failed to list the workspace's emoji, badges have not been checked: %s", err)
+	} else {
+		for _, w := range taxonomy.Validate(custom) {
+			log.Warn(w)
+		}
+	}
+	handlers.InitTaxonomy(taxonomy)
+	handlers.InitAppreciation(appreciation.NewTracker(tickets, sw, viper.GetStringSlice("appreciation-emoji")...))
+	scorecards := &report.ScorecardDelivery{
+		Store:  tickets,
+		Poster: notifier,
+		Leads:  viper.GetStringSlice("leads"),
+		OptOut: viper.GetStringSlice("scorecard-opt-out"),
+		SLA:    serviceLevels,
+	}
+	go report.Monthly(ctx, clock.Real, func(from, to time.Time) {
+		if err := scorecards.Deliver(ctx, from, to); err != nil {
+			log.Errorf("Failed to deliver scorecards: %s", err)
+		}
+	})
+	questions := digest.NewTracker(viper.GetStringSlice("support-channels"), viper.GetDuration("digest-after"))
+	handlers.InitDigest(questions)
+	if c := viper.GetString("digest-channel"); c != "" {
+		go questions.Run(ctx, sw, c, viper.GetDuration("digest-interval"))
+	}
+	var triggers []intake.Trigger
+	for _, t := range viper.GetStringSlice("triggers") {
+		trigger, err := intake.ParseTrigger(t)
+		if err != nil {
+			log.Fatalf("Error parsing triggers: %s", err)
+		}
+		triggers = append(triggers, trigger)
+	}
+	handlers.InitGuestPolicy(sw.Directory, intake.Policy{
+		Queue:           viper.GetString("guest-queue"),
+		InternalDomains: viper.GetStringSlice("internal-domains"),
+	})
+	vips, err := intake.NewVIPs(viper.GetStringSlice("vip-users"), viper.GetString("vip-title-pattern"))
+	if err != nil {
+		log.Fatalf("Error configuring VIPs: %s", err)
+	}
+	vips.Queue = viper.GetString("vip-queue")
+	vips.Channel = viper.GetString("vip-channel")
+	handlers.InitVIPs(vips)
+	orgs := intake.NewOrgs()
+	if path := viper.GetString("external-orgs"); path != "" {
+		if orgs, err = intake.LoadOrgs(path); err != nil {
+			log.Fatalf("Error loading external organisations: %s", err)
+		}
+	}
+	handlers.InitOrgs(orgs)
+	locations := intake.NewLocations()
+	if path := viper.GetString("locations"); path != "" {
+		if locations, err = intake.LoadLocations(path); err != nil {
+			log.Fatalf("Error loading locations: %s", err)
+		}
+	}
+	handlers.InitTriggers(intake.NewWatcher(viper.GetStringSlice("trigger-channels"), triggers...))
+	queueChannels := map[string]string{}
+	for _, qc := range viper.GetStringSlice("queue-channels") {
+		parts := strings.SplitN(qc, "=", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			log.Fatalf("Error parsing queue channel %q, expected <queue>=<channel>", qc)
+		}
+		queueChannels[parts[0]] = parts[1]
+	}
+	// Channels set up with /hd provision are recorded in the store, flags
+	// take precedence over them
+	provisioned, err := tickets.QueueChannels(ctx)
+	if err != nil {
+		log.Fatalf("Error listing queue channels: %s", err)
+	}
+	for _, qc := range provisioned {
+		if _, ok := queueChannels[qc.Queue]; !ok {
+			queueChannels[qc.Queue] = qc.ChannelID
+		}
+	}
+	handlers.InitCrossPost(&crosspost.Mirror{Store: tickets, Slack: sw, Channels: queueChannels})
+	handlers.InitProvisioner(&provision.Provisioner{Store: tickets, Slack: sw, Channels: sw.Directory})
+	handlers.InitAnnouncements(announce.NewBroadcaster(sw, viper.GetStringSlice("announce-channels")), viper.GetStringSlice("admins"))
+	var decider policy.Decider = policy.Admins{}
+	if u := viper.GetString("policy-url"); u != "" {
+		decider = &policy.OPA{URL: u, Client: &http.Client{Timeout: 2 * time.Second}}
+	}
+	if exporters := viper.GetStringSlice("exporters"); len(exporters) > 0 {
+		decider = policy.Grants{Decider: decider, Users: map[string][]string{policy.Export: exporters}}
+	}
+	handlers.InitPolicy(decider)
+	if u := viper.GetString("ocr-url"); u != "" {
+		handlers.InitOCR(&ocr.HTTP{URL: u, Client: &http.Client{Timeout: 30 * time.Second}})
+	}
+	var previews *preview.Previews
+	if n := viper.GetInt("preview-files"); n > 0 {
+		limits := preview.DefaultLimits
+		limits.Files, limits.Lines, limits.MaxBytes = n, viper.GetInt("preview-lines"), viper.GetInt("preview-max-bytes")
+		previews = preview.New(viper.GetString("preview-url"), signingSecret, limits)
+		handlers.InitPreviews(previews)
+	}
+	if u := viper.GetString("transcription-url"); u != "" {
+		handlers.InitTranscriber(&transcribe.HTTP{URL: u, Client: &http.Client{Timeout: time.Minute}})
+	}
+	auditLog := &audit.Log{}
+	if path := viper.GetString("audit-log"); path != "" {
+		if auditLog, err = audit.Open(path); err != nil {
+			log.Fatalf("Error opening the audit log: %s", err)
+		}
+		defer auditLog.Close()
+		if _, err := auditLog.Verify(); err != nil {
+			log.Errorf("Error verifying the audit log: %s", err)
+		}
+	}
+	// Logging each hash keeps a copy of the chain elsewhere, so that removing
+	// the latest entries from the file can be detected too
+	auditLog.Sink = func(e audit.Entry) {
+		fields := log.Fields{"actor": e.Actor, "approved_by": e.ApprovedBy, "outcome": e.Outcome, "seq": e.Seq, "hash": e.Hash}
+		for k, v := range e.Details {
+			fields[k] = v
+		}
+		log.WithFields(fields).Infof("Audit: %s", e.Action)
+	}
+	handlers.InitApprovals(approval.New(viper.GetDuration("approval-window")), auditLog)
 	log.Info("Connected to Slack API")
 	// Start a server to respond to callbacks from Slack
 	s := server.NewSlackHandler("/slack", appToken, signingSecret, nil, log.Info, log.Infof, log.Error, log.Errorf)
+	s.Logger = logger.Default
+	s.TimestampTolerance = viper.GetDuration("signing-tolerance")
+	s.Use(server.Recover(log.Errorf))
 	s.HandleCommand("/help-me", handlers.HelpRequest)
 	s.HandleInteractionCallback("dialog_submission", "HelpRequest", handlers.HelpCallback)
+	// Subcommands which list tickets, such as /hd aging, can take longer
+	// than Slack waits for
+	deferrer := &server.Deferrer{Retries: 3, Backoff: time.Second, Poster: sw, ErrorLogf: log.Errorf}
+	helpdesk := deferrer.Wrap(handlers.Helpdesk)
+	s.HandleCommand("/hd", helpdesk)
+	commandLocales := map[string]string{}
+	for _, a := range viper.GetStringSlice("command-aliases") {
+		parts := strings.SplitN(a, "=", 2)
+		if len(parts) != 2 || !strings.HasPrefix(parts[0], "/") || !i18n.Supported(parts[1]) {
+			log.Fatalf("Error parsing command alias %q, expected /<command>=<locale> for a supported locale", a)
+		}
+		commandLocales[parts[0]] = parts[1]
+		s.HandleCommand(parts[0], helpdesk)
+	}
+	handlers.InitLocales(commandLocales)
+	s.HandleInteractionCallback("view_submission", handlers.AnnounceCallbackID, handlers.AnnounceSubmission)
+	s.HandleInteractionCallback("view_submission", handlers.CatalogCallbackID, handlers.CatalogSubmission)
+	s.HandleEventCallback("message", handlers.Message)
+	s.HandleEventCallback("app_home_opened", handlers.AppHome)
+	s.HandleEventCallback("app_mention", handlers.Mention)
+	s.HandleEventCallback("reaction_added", handlers.Reaction)
+	s.HandleEventCallback("reaction_removed", handlers.Reaction)
+	handlers.InitDirectoryChanges(sw.Directory)
+	for _, et := range []string{"user_change", "team_join", "channel_rename", "channel_archive", "subteam_updated"} {
+		s.HandleEventCallback(et, handlers.DirectoryChange)
+	}
+	s.HandleInteractionCallback("block_actions", handlers.QuietHoursActionID, handlers.QuietHours)
+	s.HandleInteractionCallback("block_actions", handlers.SkillActionID, handlers.Skill)
+	s.HandleInteractionCallback("block_actions", digest.ConvertActionID, handlers.DigestConvert)
+	s.HandleInteractionCallback("block_actions", handlers.WIPOverrideActionID, handlers.WIPOverride)
+	s.HandleInteractionCallback("block_actions", escalate.AckActionID, handlers.EscalationAck)
+	s.HandleInteractionCallback("block_actions", crosspost.DoneActionID, handlers.CrossPostDone)
+	s.HandleInteractionCallback("block_actions", approval.ApproveActionID, handlers.ApprovalApprove)
+	s.HandleInteractionCallback("block_actions", handlers.CatalogPickActionID, handlers.CatalogPick)
+	s.HandleInteractionCallback("block_actions", handlers.CatalogApproveActionID, handlers.CatalogDecision)
+	s.HandleInteractionCallback("block_actions", handlers.CatalogRejectActionID, handlers.CatalogDecision)
+	s.HandleInteractionCallback("block_actions", handlers.CatalogCheckActionID, handlers.CatalogCheck)
+	s.HandleInteractionCallback("block_actions", handlers.AccessRetryActionID, handlers.AccessAction)
+	s.HandleInteractionCallback("block_actions", handlers.AccessRollbackActionID, handlers.AccessAction)
+	for _, id := range handlers.InboxActionIDs {
+		s.HandleInteractionCallback("block_actions", id, handlers.InboxAction)
+	}
+	if c := viper.GetString("ops-channel"); c != "" {
+		detector := report.DefaultDetector
+		if !viper.GetBool("suggest-incidents") {
+			detector.Similar = 0
+		}
+		monitor := &report.Monitor{Detector: detector, Store: tickets, Poster: sw, Channel: c, Links: ticketLinks}
+		go monitor.Run(ctx, 5*time.Minute, log.Errorf)
+	}
+	if c := viper.GetString("sla-channel"); c != "" {
+		alerter := &report.BreachAlerter{Store: tickets, SLA: serviceLevels, Poster: sw, Channel: c, Warn: viper.GetDuration("sla-warning"), Links: ticketLinks,
+			OnBreach: func(r sla.Risk) { log.Warnf("Ticket %s missed its %s target, due %s", r.Ticket.ID, r.Target, r.Due) },
+		}
+		go alerter.Run(ctx, time.Minute, log.Errorf)
+	}
+	sweeper := &report.Sweeper{
+		Store:         tickets,
+		Poster:        notifier,
+		NudgeAfter:    viper.GetDuration("nudge-after"),
+		EscalateAfter: viper.GetDuration("escalate-after"),
+		Leads:         viper.GetStringSlice("leads"),
+		Links:         ticketLinks,
+		Styles:        styles,
+	}
+	handlers.InitSweeper(sweeper)
+	if sweeper.NudgeAfter > 0 || sweeper.EscalateAfter > 0 {
+		go sweeper.Run(ctx, time.Hour, log.Errorf)
+	}
+	searches := saved.NewSearches()
+	if path := viper.GetString("saved-searches-file"); path != "" {
+		if searches, err = saved.LoadSearches(path); err != nil {
+			log.Fatalf("Error loading saved searches: %s", err)
+		}
+	}
+	handlers.InitSavedSearches(searches, viper.GetDuration("saved-search-alerts"))
+	searchAlerter := &saved.Alerter{Store: tickets, Searches: searches, Poster: notifier, Links: ticketLinks}
+	go searchAlerter.Run(ctx, time.Minute, log.Errorf)
+	groups, err := assign.ParseGroups(viper.GetStringSlice("oncall-groups"))
+	if err != nil {
+		log.Fatalf("Error parsing on-call groups: %s", err)
+	}
+	regions, err := region.ParseRegions(viper.GetStringSlice("regions"))
+	if err != nil {
+		log.Fatalf("Error parsing regions: %s", err)
+	}
+	router := &region.Router{Regions: regions, Groups: groups}
+	if len(regions) > 0 {
+		handover := &region.Handover{Store: tickets, Slack: sw, Regions: regions, Channels: queueChannels, Links: ticketLinks}
+		go handover.Run(ctx, time.Minute, log.Errorf)
+	}
+	chains, err := escalate.ParseChains(viper.GetStringSlice("escalation-chains"))
+	if err != nil {
+		log.Fatalf("Error parsing escalation chains: %s", err)
+	}
+	if len(chains) > 0 {
+		escalator := &escalate.Escalator{Store: tickets, Slack: notifier, Chains: chains, Links: ticketLinks}
+		if len(regions) > 0 {
+			escalator.Route = router.Route
+		}
+		go escalator.Run(ctx, time.Minute, log.Errorf)
+	}
+	purger := &trash.Purger{Store: tickets, Retention: viper.GetDuration("trash-retention")}
+	handlers.InitTrash(purger)
+	transitions := lifecycle.DefaultTransitions
+	if specs := viper.GetStringSlice("transitions"); len(specs) > 0 {
+		if transitions, err = lifecycle.ParseTransitions(specs); err != nil {
+			log.Fatalf("Error parsing transitions: %s", err)
+		}
+	}
+	machine := lifecycle.New(transitions)
+	if names := viper.GetStringSlice("assigned-statuses"); len(names) > 0 {
+		var statuses []ticket.Status
+		for _, name := range names {
+			s, err := ticket.ParseStatus(name)
+			if err != nil {
+				log.Fatalf("Error parsing assigned statuses: %s", err)
+			}
+			statuses = append(statuses, s)
+		}
+		machine.Guard(lifecycle.RequireAssignee(statuses...))
+	}
+	handlers.InitLifecycle(machine)
+	limits := wip.NewLimits()
+	if units != nil {
+		for q, n := range units.WIPLimits() {
+			limits.SetQueue(q, n)
+		}
+	}
+	handlers.InitWIP(limits)
+	triageQueues, err := inbox.ParseQueues(viper.GetStringSlice("triage-queues"))
+	if err != nil {
+		log.Fatalf("Error parsing triage queues: %s", err)
+	}
+	handlers.InitInbox(inbox.New(tickets, limits, triageQueues), viper.GetDuration("inbox-snooze"))
+	var skills *assign.Skills
+	if offered := viper.GetStringSlice("skills"); len(offered) > 0 {
+		skills = assign.NewSkills(offered)
+		if path := viper.GetString("skills-file"); path != "" {
+			skills, err = assign.LoadSkills(offered, path)
+			if err != nil {
+				log.Fatalf("Error loading skills: %s", err)
+			}
+		}
+		handlers.InitSkills(skills)
+	}
+	if len(groups) > 0 {
+		strategy, err := assign.ParseStrategy(viper.GetString("assign-strategy"))
+		if err != nil {
+			log.Fatalf("Error parsing assignment strategy: %s", err)
+		}
+		assigner := &assign.Assigner{Store: tickets, Groups: groups, Strategy: strategy, Limits: limits, Location: notifier.Location, Skills: skills}
+		if len(regions) > 0 {
+			assigner.Group = router.Group
+		}
+		handlers.InitAssigner(assigner)
+	}
+	go purger.Run(ctx, time.Hour, log.Errorf)
+	dispatcher := &outbox.Dispatcher{Store: tickets, Slack: sw, Batch: 100}
+	go dispatcher.Run(ctx, viper.GetDuration("outbox-interval"), log.Errorf)
+	if quiet := viper.GetDuration("archive-after"); quiet > 0 {
+		archiver := &archive.Archiver{Store: tickets, Slack: sw, QuietPeriod: quiet, Unpin: viper.GetBool("archive-unpin")}
+		go archiver.Run(ctx, time.Hour, log.Errorf)
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/", logs.Middleware(s))
+	drainer := &drain.Drainer{Period: viper.GetDuration("drain-period"), Outbox: dispatcher.Pending, Logf: log.Infof}
+	mux.Handle("/readyz", drainer)
+	ticketGauge := metrics.Default.Gauge("helpdesk_tickets", "Open tickets by status", "status")
+	metrics.Default.OnScrape(func() {
+		ticketGauge.Reset()
+		for status, n := range projector.Statuses() {
+			ticketGauge.Set(float64(n), string(status))
+		}
+	})
+	mux.Handle("/metrics", metrics.Protect(metrics.Default, viper.GetString("metrics-token")))
+	apiToken, adminToken, exportToken := viper.GetString("api-token"), viper.GetString("admin-token"), viper.GetString("export-token")
+	ticketsToken := viper.GetString("tickets-token")
+	if exportToken == "" {
+		exportToken = adminToken
+	}
+	// protect puts an API behind the login, if there is one, for the roles
+	protect := func(h http.Handler, token string, roles ...string) http.Handler { return h }
+	if issuer := viper.GetString("oidc-issuer"); issuer != "" {
+		provider, err := sso.Discover(ctx, issuer, viper.GetString("oidc-client-id"), viper.GetString("oidc-client-secret"), &http.Client{Timeout: 30 * time.Second})
+		if err != nil {
+			log.Fatalf("Error setting up single sign-on: %s", err)
+		}
+		roles, err := sso.ParseRoles(viper.GetStringSlice("oidc-roles"))
+		if err != nil {
+			log.Fatalf("Error parsing single sign-on roles: %s", err)
+		}
+		redirect := viper.GetString("oidc-redirect-url")
+		if redirect == "" {
+			log.Fatal("--oidc-redirect-url is required with --oidc-issuer")
+		}
+		login := sso.NewLogin(provider, redirect, roles)
+		login.RoleClaim = viper.GetString("oidc-role-claim")
+		login.TTL = viper.GetDuration("session-ttl")
+		login.Logf = log.Infof
+		mux.Handle("/auth/", http.StripPrefix("/auth", login))
+		protect = login.Require
+		// The APIs are served to those signed in even without their tokens
+		if apiToken == "" {
+			apiToken = login.Token
+		}
+		if adminToken == "" {
+			adminToken = login.Token
+		}
+		if exportToken == "" {
+			exportToken = login.Token
+		}
+		if ticketsToken == "" {
+			ticketsToken = login.Token
+		}
+	}
+	if apiToken != "" {
+		reports := report.NewAPI(projector, apiToken)
+		reports.Hierarchy = units
+		mux.Handle("/api/reports/", protect(http.StripPrefix("/api/reports", reports), apiToken, sso.Reports, sso.Admin))
+	}
+	if ticketsToken != "" {
+		api := ticketapi.NewAPI(tickets, machine, ticketsToken)
+		mux.Handle("/api/v1/", protect(http.StripPrefix("/api/v1", api), ticketsToken, sso.Admin))
+	}
+	if exportToken != "" {
+		mux.Handle("/api/admin/export", protect(http.StripPrefix("/api/admin", admin.NewAPI(sealedTickets, exportToken, auditLog)), exportToken, sso.Export))
+	}
+	if adminToken != "" {
+		locationAPI := intake.NewLocationAPI(locations, adminToken, viper.GetString("intake-url"))
+		for path, api := range map[string]http.Handler{
+			"/api/admin/":           trash.NewAPI(sealedTickets, purger, adminToken),
+			"/api/admin/tickets/":   threads.NewAPI(tickets, sw, adminToken),
+			"/api/admin/debug":      logging.NewAPI(logs, adminToken),
+			"/api/admin/debug/":     logging.NewAPI(logs, adminToken),
+			"/api/admin/orgs":       intake.NewOrgAPI(orgs, adminToken),
+			"/api/admin/orgs/":      intake.NewOrgAPI(orgs, adminToken),
+			"/api/admin/locations":  locationAPI,
+			"/api/admin/locations/": locationAPI,
+		} {
+			mux.Handle(path, protect(http.StripPrefix("/api/admin", api), adminToken, sso.Admin))
+		}
+	}
+	if viper.GetString("locations") != "" {
+		mux.Handle("/intake/", http.StripPrefix("/intake", handlers.LocationIntake(locations, sw.Bot, viper.GetString("team-id"))))
+	}
+	if runbooks != nil {
+		mux.Handle("/runbooks/", http.StripPrefix("/runbooks", runbooks.Handler(log.Errorf)))
+	}
+	if previews != nil && previews.BaseURL != "" {
+		mux.Handle("/previews/", http.StripPrefix("/previews", previews.Handler(sealedTickets, sw)))
+	}
+	if channel := viper.GetString("email-channel"); channel != "" {
+		from := viper.GetString("email-from")
+		at := strings.LastIndex(from, "@")
+		if at < 0 {
+			log.Fatal("--email-from is required with --email-channel")
+		}
+		handlers.InitEmail(&email.SMTP{
+			Addr:     viper.GetString("smtp-addr"),
+			Username: viper.GetString("smtp-username"),
+			Password: viper.GetString("smtp-password"),
+			From:     from,
+		}, channel, from[at+1:])
+		if key := viper.GetString("mailgun-signing-key"); key != "" {
+			mux.Handle("/email/mailgun", handlers.InboundEmail(email.Mailgun(key), sw.Bot, viper.GetString("team-id")))
+		}
+		if password := viper.GetString("sendgrid-password"); password != "" {
+			mux.Handle("/email/sendgrid", handlers.InboundEmail(email.SendGrid(password), sw.Bot, viper.GetString("team-id")))
+		}
+	}
+	if mirror != nil {
+		mux.Handle("/jira/webhook", mirror.Webhook())
+	}
+	if ticketLinks.BaseURL != "" {
+		mux.Handle("/links/", http.StripPrefix("/links", ticketLinks.Handler(tickets, sw.Bot)))
+	}
+	if addr := viper.GetString("diagnostics-address"); addr != "" {
+		token := viper.GetString("diagnostics-token")
+		if token == "" {
+			log.Fatal("--diagnostics-token is required with --diagnostics-address")
+		}
+		diag := diagnostics.NewHandler(token, map[string]diagnostics.Source{
+			"outbox": func(ctx context.Context) (interface{}, error) {
+				n, err := dispatcher.Pending(ctx)
+				return map[string]int{"pending": n}, err
+			},
+			"projection": func(ctx context.Context) (interface{}, error) {
+				return map[string]int{"pending": projector.Pending()}, nil
+			},
+			"directory": func(ctx context.Context) (interface{}, error) {
+				caches := map[string]interface{}{}
+				for name, st := range sw.Directory.Stats() {
+					caches[name] = map[string]interface{}{"entries": st.Entries, "limit": st.Limit, "hits": st.Hits, "misses": st.Misses, "evictions": st.Evictions, "hit_rate": st.HitRate()}
+				}
+				return caches, nil
+			},
+		})
+		go func() {
+			if err := http.ListenAndServe(addr, diag); err != nil {
+				log.Fatalf("Unable to start diagnostics server: %s", err)
+			}
+		}()
+		log.Infof("Serving diagnostics on '%s'", addr)
+	}
 	addr := viper.GetString("listen-address")
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		log.Fatalf("Unable to start server: %s", err)
+	}
+	srv := &http.Server{Handler: mux}
 	go func() {
-		if err := http.ListenAndServe(addr, s); err != nil {
+		if err := srv.Serve(listener); err != nil && err != http.ErrServerClosed {
 			log.Fatalf("Unable to start server: %s", err)
 		}
 	}()
 	log.Infof("Listening for Slack callbacks on '%s'", addr)
+	var sm *wrapper.SocketModeManager
+	if token := viper.GetString("socket-mode-token"); token != "" {
+		sm = wrapper.NewSocketModeManager(ctx, token, slack.APIURL, &http.Client{})
+		sm.Logger = logger.Default
+		go serveSocketMode(sm, s)
+		if err := sm.Connect(); err != nil {
+			log.Fatalf("Unable to connect with Socket Mode: %s", err)
+		}
+		log.Info("Receiving Slack callbacks over Socket Mode")
+	}
+	// The server is listening so the check can reach it through --public-url
+	doc := &doctor.Doctor{
+		Slack:            sw,
+		Channels:         sw.Directory,
+		SigningSecret:    signingSecret,
+		Store:            primary,
+		RequiredChannels: doctor.RequiredChannels(viper.GetViper()),
+		PublicURL:        viper.GetString("public-url"),
+	}
+	findings := doc.SelfCheck(ctx)
+	for _, f := range findings {
+		entry := log.WithFields(log.Fields{"check": f.Check, "fix": f.Fix})
+		switch f.Severity {
+		case doctor.OK:
+			entry.Infof("Self-check: %s", f.Message)
+		case doctor.Warning:
+			entry.Warnf("Self-check: %s", f.Message)
+		default:
+			entry.Errorf("Self-check: %s", f.Message)
+		}
+	}
+	if n := doctor.Problems(findings); n > 0 && viper.GetBool("strict-self-check") {
+		log.Fatalf("The self-check found %d problems", n)
+	}
 	terminate := make(chan os.Signal, 1)
 	signal.Notify(terminate, syscall.SIGTERM, syscall.SIGINT, syscall.SIGKILL)
 	<-terminate
+	// Keep serving callbacks and posting the outbox until traffic has moved to
+	// other instances, then stop taking callbacks, finish the requests in
+	// flight and stop the background jobs. The event log is closed last, once
+	// nothing is left writing to it.
+	drainCtx, cancelDrain := context.WithTimeout(context.Background(), viper.GetDuration("drain-timeout"))
+	defer cancelDrain()
+	if n := drainer.Drain(drainCtx); n > 0 {
+		log.Warnf("Stopping with %d notifications in the outbox", n)
+	}
+	if sm != nil {
+		// Envelopes already delivered are still handled and acknowledged
+		if err := sm.Shutdown(drainCtx); err != nil {
+			log.Warnf("Error closing the Socket Mode connection: %s", err)
+		}
+	}
+	if err := s.Shutdown(drainCtx); err != nil {
+		log.Warnf("Stopping before every Slack callback was handled: %s", err)
+	}
+	if err := srv.Shutdown(drainCtx); err != nil {
+		log.Errorf("Error shutting down the server: %s", err)
+	}
+	if err := deferrer.Wait(drainCtx); err != nil {
+		log.Warnf("Stopping before every late /hd response was posted: %s", err)
+	}
+	cancel()
+	if path := viper.GetString("directory-snapshot"); path != "" {
+		if err := saveSnapshot(sw.Directory, path); err != nil {
+			log.Errorf("Error saving the directory caches: %s", err)
+		}
+	}
+}
+
+// slackClientLogging logs the Slack clients' own debugging, which includes
+// every response and RTM event, if --slack-client-debug is set
+func slackClientLogging() wrapper.Option {
+	if !viper.GetBool("slack-client-debug") {
+		return func(*wrapper.Slack) {}
+	}
+	return wrapper.WithLogger(logger.Default)
+}
+
+// loadSnapshot fills the directory caches from the snapshot at path, if one
+// has been saved
+func loadSnapshot(d *wrapper.Directory, path string) error {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return d.Load(f)
+}
+
+// saveSnapshot writes the directory caches to path, replacing it only once
+// the snapshot is complete. It holds user details, so only we can read it.
+func saveSnapshot(d *wrapper.Directory, path string) error {
+	return atomicfile.Write(path, d.Save)
+}
+
+// serveSocketMode routes the envelopes received by m to the handlers of s,
+// acknowledging each with the handler's response
+func serveSocketMode(m *wrapper.SocketModeManager, s *server.SlackHandler) {
+	for {
+		select {
+		case <-m.Done():
+			return
+		case e := <-m.IncomingEvents():
+			switch ev := e.Data.(type) {
+			case *wrapper.Envelope:
+				go func() {
+					res, err := s.ServeSocketMode(ev.Type, ev.Payload)
+					if err == server.ErrShuttingDown {
+						// Slack sends envelopes which are not acknowledged again
+						return
+					}
+					if err != nil {
+						log.Errorf("Error serving %s envelope %s: %s", ev.Type, ev.ID, err)
+					}
+					if err := ev.Ack(res); err != nil {
+						log.Error(err)
+					}
+				}()
+			case *slack.ConnectionErrorEvent:
+				log.Warnf("Socket Mode connection failed, retrying in %s: %s", ev.Backoff, ev.ErrorObj)
+			case *slack.DisconnectedEvent:
+				if ev.Cause != nil {
+					log.Errorf("Socket Mode disconnected: %s", ev.Cause)
+				}
+			case *slack.HelloEvent:
+				log.Debug("Socket Mode connected")
+			}
+		}
+	}
+}
+
+// encrypter returns what seals tickets' sensitive fields at rest, nil if no
+// keys are configured
+func encrypter() (*encrypt.Encrypter, error) {
+	var keys encrypt.KeyProvider
+	switch {
+	case viper.GetString("vault-transit-key") != "":
+		keys = &encrypt.Transit{URL: viper.GetString("vault-addr"), Token: viper.GetString("vault-token"), Key: viper.GetString("vault-transit-key")}
+	case len(viper.GetStringSlice("encryption-keys")) > 0:
+		keyring, err := encrypt.ParseKeyring(viper.GetStringSlice("encryption-keys"))
+		if err != nil {
+			return nil, err
+		}
+		keys = keyring
+	default:
+		return nil, nil
+	}
+	e := encrypt.New(keys)
+	e.KeyLifetime = viper.GetDuration("data-key-lifetime")
+	return e, nil
 }
 
 func initFlags() {
@@ -57,7 +929,142 @@ func initFlags() {
 	pflag.StringP("app-token", "a", "", "Slack API token for your slash command (required)")
 	pflag.StringP("bot-token", "b", "", "Slack API token for bot integration (required)")
 	pflag.StringP("signing-secret", "s", "", "Slack API signing secret for request verification (required)")
+	pflag.Duration("signing-tolerance", server.DefaultTimestampTolerance, "How far a request's signature timestamp may be from now before it is refused as a replay")
+	pflag.String("socket-mode-token", "", "App-level token to receive callbacks over Socket Mode instead of a public endpoint, disabled if empty")
 	pflag.StringP("listen-address", "l", ":4390", "Address to listen for Slack callbacks on")
+	pflag.Duration("drain-period", 10*time.Second, "How long to keep serving after SIGTERM while /readyz fails, so load balancers can move traffic away")
+	pflag.Duration("drain-timeout", 30*time.Second, "Longest to wait after SIGTERM for the outbox to empty and requests in flight to finish")
+	pflag.String("diagnostics-address", "", "Address of a separate listener serving pprof, goroutine dumps and internal stats, disabled if empty")
+	pflag.String("diagnostics-token", "", "Token required by the diagnostics listener, as a bearer token or the basic auth password")
+	pflag.String("metrics-token", "", "Bearer token required to scrape /metrics, which is open if empty")
+	pflag.String("otlp-endpoint", "", "OTLP/HTTP traces URL of an OpenTelemetry collector to send spans of each Slack request to, e.g. http://localhost:4318/v1/traces, disabled if empty")
+	pflag.String("trace-service", "helpdesk", "Service name of the spans sent to --otlp-endpoint")
+	pflag.String("log-level", "info", "Log level to start with, one of debug, info, warning or error, admins can change it with /hd debug")
+	pflag.Bool("slack-client-debug", false, "Log the Slack clients' own debugging at debug level, including every Web API response and RTM event")
+	pflag.Duration("slow-store-queries", 0, "Log store queries which take this long or longer with their parameters, 0 to disable")
+	pflag.StringSlice("admins", nil, "IDs of the Slack users allowed to use admin commands such as /hd announce")
+	pflag.StringSlice("exporters", nil, "IDs of the only Slack users allowed to export tickets, who must also be allowed by --admins or --policy-url")
+	pflag.String("policy-url", "", "Open Policy Agent document deciding who may use admin commands, e.g. http://localhost:8181/v1/data/helpdesk/allow, instead of --admins")
+	pflag.Int("preview-files", 3, "Most files shared on a ticket previewed on its card, 0 to not record files on tickets")
+	pflag.Int("preview-lines", 10, "Lines of a text file shown in its preview")
+	pflag.Int("preview-max-bytes", 5<<20, "Largest file previewed on a card")
+	pflag.String("preview-url", "", "Public URL of this server's /previews path, e.g. https://helpdesk.example.com/previews, images are not previewed if empty")
+	pflag.String("ocr-url", "", "Text recognition service screenshots attached to tickets are posted to, the text is added to the ticket's description, disabled if empty")
+	pflag.String("transcription-url", "", "Transcription service voice notes sent to the bot in a DM are posted to, each becomes a ticket, disabled if empty")
+	pflag.StringSlice("announce-channels", nil, "IDs of the channels /hd announce posts to")
+	pflag.StringSlice("support-channels", nil, "IDs of the channels watched for unanswered questions")
+	pflag.String("digest-channel", "", "ID of the channel agents are sent the unanswered question digest in")
+	pflag.Duration("digest-after", 4*time.Hour, "How long a question may go unanswered before it is included in the digest")
+	pflag.Duration("digest-interval", time.Hour, "How often to post the unanswered question digest")
+	pflag.StringSlice("trigger-channels", nil, "IDs of the channels where messages matching a trigger become tickets")
+	pflag.StringSlice("triggers", []string{"prefix:help:"}, "Triggers which turn messages into tickets, in the form prefix:<text>, keyword:<word> or regex:<expression>")
+	pflag.String("guest-queue", "external", "Queue for tickets from guests and Slack Connect users")
+	pflag.StringSlice("internal-domains", nil, "Domains whose links are removed from anything posted where guests or external users can see it")
+	pflag.String("external-orgs", "", "JSON file of the Slack Connect organisations with their own ticket policy, managed with the admin API")
+	pflag.String("locations", "", "JSON file of the physical locations people can report problems at by scanning a code, managed with the admin API")
+	pflag.String("email-channel", "", "ID of the channel tickets raised by email are posted in, email is disabled if empty")
+	pflag.String("email-from", "", "Address requesters are emailed from, e.g. help@example.com, whose domain is in the Message-IDs replies reference")
+	pflag.String("smtp-addr", "", "Host and port of the mail server requesters are emailed through, e.g. smtp.mailgun.org:587")
+	pflag.String("smtp-username", "", "Username for the mail server, emails are sent without authenticating if empty")
+	pflag.String("smtp-password", "", "Password for the mail server")
+	pflag.String("mailgun-signing-key", "", "Mailgun webhook signing key, inbound emails are taken from a Mailgun route at /email/mailgun if set")
+	pflag.String("sendgrid-password", "", "Basic auth password of SendGrid's Inbound Parse webhook, inbound emails are taken from it at /email/sendgrid if set")
+	pflag.StringSlice("webhook-urls", nil, "URLs to POST ticket events to as JSON, can be repeated")
+	pflag.String("webhook-secret", "", "Secret to sign webhooks with in their X-Helpdesk-Signature header, they are not signed if empty")
+	pflag.StringSlice("webhook-events", []string{"created", "assigned", "resolved"}, "Ticket events to send to --webhook-urls")
+	pflag.Int("webhook-retries", 5, "How many times to retry a webhook which fails, waiting twice as long before each retry")
+	pflag.String("jira-config", "", "YAML file mapping tickets onto Jira issues, tickets are not mirrored to Jira if empty")
+	pflag.String("jira-token", "", "Jira API token, instead of the one in --jira-config")
+	pflag.String("intake-url", "", "Public URL of this server's /intake path, e.g. https://helpdesk.example.com/intake, included in the locations listed by the admin API")
+	pflag.StringSlice("vip-users", nil, "IDs of the Slack users whose tickets are treated as VIP")
+	pflag.String("vip-title-pattern", "", "Regular expression matching the Slack profile titles of VIP users, e.g. (?i)director|chief")
+	pflag.String("vip-queue", "senior", "Queue for tickets from VIP users")
+	pflag.String("vip-channel", "", "ID of the channel notified of tickets from VIP users")
+	pflag.StringSlice("queue-channels", nil, "Channel of each queue in the form <queue>=<channel ID>, tickets shared with /hd share are posted in them")
+	pflag.StringSlice("leads", nil, "IDs of the Slack users sent reports on the whole team")
+	pflag.StringSlice("scorecard-opt-out", nil, "IDs of the agents who do not want their monthly scorecard")
+	pflag.StringSlice("appreciation-emoji", appreciation.DefaultEmoji, "Reactions in ticket threads counted as appreciation in scorecards, without colons")
+	pflag.StringSlice("sla-response", nil, "First response SLA targets by priority in the form <priority>=<duration>, e.g. P1=15m, * for other priorities")
+	pflag.StringSlice("sla-resolution", nil, "Resolution SLA targets by priority in the form <priority>=<duration>, e.g. P1=4h, * for other priorities")
+	pflag.String("hierarchy", "", "JSON file grouping queues into departments within the organisation, whose SLA targets and WIP limits the queues inherit")
+	pflag.String("catalog", "", "JSON file of the service catalog's request types with their forms, approvals, checklists and SLA targets")
+	pflag.String("google-key", "", "JSON key file of a Google service account with domain-wide delegation, for catalog items granting access to Google Workspace groups")
+	pflag.String("google-admin", "", "Google Workspace admin the --google-key acts for")
+	pflag.String("github-org", "", "GitHub organisation whose teams catalog items grant access to")
+	pflag.String("github-token", "", "GitHub token with the admin:org scope for --github-org")
+	pflag.String("okta-url", "", "URL of the Okta organisation whose groups catalog items grant access to, such as https://example.okta.com")
+	pflag.String("okta-token", "", "Okta API token for --okta-url")
+	pflag.Duration("sla-warning", time.Hour, "How long before breaching an SLA target a ticket is reported as at risk")
+	pflag.String("event-log", "", "File to keep tickets in as an append-only log of events, tickets are only kept in memory if empty")
+	pflag.String("postgres-url", "", "PostgreSQL database to keep tickets in instead of --event-log, such as postgres://helpdesk@localhost/helpdesk?sslmode=disable")
+	pflag.String("postgres-schema", "public", "Schema of --postgres-url the tickets are kept in, created and migrated at startup")
+	pflag.StringSlice("shards", nil, "Teams whose tickets are kept apart from the others in --postgres-url, in the form <team>=<schema> or <team>=<PostgreSQL URL>")
+	pflag.StringSlice("encryption-keys", nil, "Master keys sealing ticket descriptions, comments and file snippets at rest in the form <id>=<base64 32 byte key>, the current key first")
+	pflag.String("vault-addr", "", "Address of the Vault server whose transit key seals the data keys instead of --encryption-keys")
+	pflag.String("vault-token", "", "Token for the Vault transit secrets engine")
+	pflag.String("vault-transit-key", "", "Name of the Vault transit key, encryption uses Vault if set")
+	pflag.Duration("data-key-lifetime", time.Hour, "How long a data key seals new values before another is generated")
+	pflag.Duration("trash-retention", 30*24*time.Hour, "How long deleted tickets stay in the trash, where they can be restored, before they are purged")
+	pflag.String("public-url", "", "Request URL Slack sends callbacks to, checked at startup to make sure Slack can reach the helpdesk")
+	pflag.Bool("strict-self-check", false, "Exit at startup if the self-check finds problems, such as missing scopes or channels")
+	pflag.String("audit-log", "", "File to keep the hash chained audit log in, it is only kept in memory and the application log if empty")
+	pflag.Duration("approval-window", 15*time.Minute, "How long another admin has to approve sensitive commands such as /hd bulk-close, /hd export and /hd erase")
+	pflag.String("admin-token", "", "Bearer token for the admin API under /api/admin/, the API is disabled if empty")
+	pflag.String("export-token", "", "Bearer token for GET /api/admin/export instead of --admin-token, so that exporting can be granted separately")
+	pflag.String("oidc-issuer", "", "OpenID Connect issuer to sign in to the admin and reporting APIs with, e.g. https://example.okta.com, the APIs only take their tokens if empty")
+	pflag.String("oidc-client-id", "", "Client ID of the helpdesk with the OpenID Connect provider")
+	pflag.String("oidc-client-secret", "", "Client secret of the helpdesk with the OpenID Connect provider")
+	pflag.String("oidc-redirect-url", "", "Public URL of this server's /auth/callback path, as registered with the OpenID Connect provider")
+	pflag.String("oidc-role-claim", "groups", "ID token claim whose values give people their roles")
+	pflag.StringSlice("oidc-roles", nil, "Claim value giving each role, e.g. admin=helpdesk-admins, export=auditors or reports=team-leads, can be repeated")
+	pflag.Duration("session-ttl", 8*time.Hour, "How long a single sign-on session lasts")
+	pflag.String("api-token", "", "Bearer token for the reporting API under /api/reports/, the API is disabled if empty")
+	pflag.String("tickets-token", "", "Bearer token for the ticket API under /api/v1/, the API is disabled if empty")
+	pflag.String("sla-channel", "", "ID of the channel warned of tickets about to miss an SLA target and alerted when they do")
+	pflag.String("ops-channel", "", "ID of the channel alerted of spikes in new tickets")
+	pflag.Bool("suggest-incidents", true, "Suggest declaring an incident when many similar tickets arrive together")
+	pflag.Duration("nudge-after", 72*time.Hour, "How long a ticket can go without activity before it is nudged in its thread, 0 to disable")
+	pflag.Duration("escalate-after", 7*24*time.Hour, "How long a ticket can go without activity before it is escalated to the leads, 0 to disable")
+	pflag.StringSlice("triage-queues", nil, "Queues each agent triages when they send the bot \"next\" in a DM, in the form <agent>:<queue>+<queue>, agents without any triage every queue")
+	pflag.Duration("inbox-snooze", time.Hour, "How long snoozing a ticket in the triage inbox hides it for")
+	pflag.StringSlice("oncall-groups", nil, "Agents new tickets in each queue are assigned to, in the form <queue>:<agent>[@<hours>]+<agent>, e.g. it:U1@09:00-17:00+U2, * for queues without a group, agents without hours work all day")
+	pflag.StringSlice("regions", nil, "Regional sub-teams owning each queue in turn, in the form <queue>:<region>@<hours>[@<time zone>]+<region>@<hours>, e.g. it:emea@08:00-16:00@Europe/London+amer@09:00-17:00@America/New_York, each region's agents are the on-call group <queue>/<region>")
+	pflag.String("assign-strategy", "round-robin", "How new tickets are assigned within an on-call group, round-robin or least-open")
+	pflag.StringSlice("skills", nil, "Ticket tags agents can choose as their skills on the App Home tab, e.g. vpn or billing, tickets are offered to agents with the skills for their tags first")
+	pflag.String("skills-file", "", "JSON file to keep the skills agents choose in, they are only kept in memory if empty")
+	pflag.String("saved-searches-file", "", "JSON file to keep the searches agents save with /hd saved in, they are only kept in memory if empty")
+	pflag.Duration("saved-search-alerts", 15*time.Minute, "How often agents subscribed to a saved search are sent the new tickets matching it, unless they choose otherwise")
+	pflag.StringSlice("transitions", nil, "Statuses each status can move to with /hd move, in the form <from>:<to>+<to>, e.g. waiting:in_progress+resolved, replacing the default lifecycle")
+	pflag.StringSlice("assigned-statuses", nil, "Statuses a ticket can only be moved to with /hd move once it is assigned, e.g. in_progress")
+	pflag.StringSlice("escalation-chains", nil, "Levels of each queue's escalation chain in order, in the form <queue>:<name>:<after>:<method>[:<user>+<user>] where method is thread, dm or page, e.g. it:lead:30m:dm:U123, * for queues without a chain, levels without users notify the assignee")
+	pflag.Duration("outbox-interval", 2*time.Second, "How often messages announcing ticket changes are posted from the outbox")
+	pflag.Duration("archive-after", 7*24*time.Hour, "How long after a ticket is resolved its thread is archived, 0 to disable")
+	pflag.Bool("archive-unpin", false, "Unpin the first message of a ticket's thread when it is archived")
+	pflag.String("links-url", "", "Public URL of this server's /links path, e.g. https://helpdesk.example.com/links, tickets are not linked if empty")
+	pflag.StringSlice("runbooks", nil, "Runbook of each ticket category, in the form <tag>=<url>, e.g. vpn=https://wiki.example.com/vpn, linked on agents' cards and sent to assignees")
+	pflag.String("runbooks-url", "", "Public URL of this server's /runbooks path, e.g. https://helpdesk.example.com/runbooks, runbooks are linked directly and their opening is not counted if empty")
+	pflag.String("runbook-usage", "", "JSON file to keep how often each runbook is suggested and opened in, it is only kept in memory if empty")
+	pflag.String("team-id", "", "ID of the Slack workspace, used to link to the app's Home tab")
+	pflag.String("app-id", "", "ID of the Slack app, used to link to its Home tab")
+	pflag.String("admin-url", "", "URL of a ticket in the admin UI with %s for the ticket ID")
+	pflag.StringSlice("link-aliases", nil, "Old ticket IDs to redirect links from, in the form <old>=<new>")
+	pflag.String("quiet-hours", "", "Default quiet hours during which DMs which are not urgent are held back, e.g. 22:00-08:00 in each user's time zone, users can change theirs in App Home")
+	pflag.Bool("quiet-hours-break-through", true, "Send escalations of P1 tickets during quiet hours")
+	pflag.StringSlice("badges", nil, "Emoji and colours for ticket statuses and priorities, in the form status:<status>=<emoji><color> or priority:<priority>=<emoji><color>, e.g. priority:P1=:sev1:#e01e5a")
+	pflag.StringSlice("plain-text-users", nil, "IDs of the Slack users sent plain text notifications without emoji, they can change this with /hd format")
+	pflag.StringSlice("command-aliases", nil, "Localized aliases of /hd in the form /<command>=<locale>, e.g. /ayuda=es")
+	pflag.Duration("directory-ttl", 10*time.Minute, "How long to cache the Slack user and channel lists for, 0 to disable")
+	pflag.Int("directory-max-users", 0, "Maximum number of users to cache, 0 for no limit")
+	pflag.Int("directory-max-channels", 0, "Maximum number of channels to cache, 0 for no limit")
+	pflag.Int("directory-user-lookups", 1000, "Maximum number of users outside the cached list to cache, 0 to disable")
+	pflag.Int("directory-channel-lookups", 1000, "Maximum number of channels outside the cached list to cache, 0 to disable")
+	pflag.Int("directory-usergroups", 500, "Maximum number of usergroups to cache the members of, 0 to disable")
+	pflag.String("directory-snapshot", "", "File to save the directory caches to on shutdown and load them from on startup, empty to disable")
+	pflag.StringSlice("api-budgets", nil, "Slack API calls per minute allowed to a method, in the form <method>=<calls>, overriding its rate limit tier")
+	pflag.Bool("api-pacing", true, "Pace each token's Slack API calls to stay within the methods' budgets")
+	pflag.Int("api-retries", 3, "How many times a Slack API call which is rate limited is retried after its Retry-After")
+	pflag.Float64("api-budget-warning", 0.8, "Fraction of a method's API budget used in a minute which is warned of")
+	pflag.String("api-budget-channel", "", "ID of the channel warned of Slack API methods near their budget or rate limited, disabled if empty")
 	pflag.Parse()
 	viper.BindPFlags(pflag.CommandLine)
 	// Allow setting flags from environment variables
@@ -0,0 +1,353 @@
+// Package metrics keeps counters, gauges and histograms and serves them in
+// the Prometheus text format, so that the helpdesk can be scraped from
+// /metrics
+package metrics
+
+import (
+	"bufio"
+	"crypto/subtle"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultBuckets are the upper bounds in seconds of histograms of latencies
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Default is the registry the helpdesk's packages register their metrics in
+var Default = NewRegistry()
+
+// Registry is a set of metrics served together. Registry and its metrics are
+// safe for concurrent use.
+type Registry struct {
+	mu       sync.Mutex
+	metrics  []metric
+	names    map[string]bool
+	onScrape []func()
+}
+
+// NewRegistry returns an empty registry
+func NewRegistry() *Registry {
+	return &Registry{names: map[string]bool{}}
+}
+
+// metric is a named family of series
+type metric interface {
+	write(w io.Writer)
+}
+
+// desc describes a metric and holds its series, keyed by their label values
+type desc struct {
+	name   string
+	help   string
+	kind   string
+	labels []string
+
+	mu     sync.Mutex
+	series map[string]*series
+}
+
+// series is the value of a metric for one set of label values
+type series struct {
+	values []string
+	value  float64
+	// counts are the observations of a histogram in each bucket, not
+	// cumulative, with the last for those above every bucket
+	counts []uint64
+	sum    float64
+}
+
+func (r *Registry) register(name, help, kind string, labels []string, m metric) *desc {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.names[name] {
+		panic(fmt.Sprintf("metrics: %s is already registered", name))
+	}
+	r.names[name] = true
+	r.metrics = append(r.metrics, m)
+	return &desc{name: name, help: help, kind: kind, labels: labels, series: map[string]*series{}}
+}
+
+// get returns the series for the label values, creating it if needs be.
+// d.mu must be held.
+func (d *desc) get(values []string) *series {
+	if len(values) != len(d.labels) {
+		panic(fmt.Sprintf("metrics: %s has labels %v, got values %v", d.name, d.labels, values))
+	}
+	key := strings.Join(values, "\xff")
+	s := d.series[key]
+	if s == nil {
+		s = &series{values: append([]string(nil), values...)}
+		d.series[key] = s
+	}
+	return s
+}
+
+// lookup returns the series for the label values, nil if there is none.
+// d.mu must be held.
+func (d *desc) lookup(values []string) *series {
+	return d.series[strings.Join(values, "\xff")]
+}
+
+// sorted returns the series in the order of their label values. d.mu must be
+// held.
+func (d *desc) sorted() []*series {
+	var keys []string
+	for k := range d.series {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	all := make([]*series, len(keys))
+	for i, k := range keys {
+		all[i] = d.series[k]
+	}
+	return all
+}
+
+func (d *desc) header(w io.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", d.name, escapeHelp(d.help), d.name, d.kind)
+}
+
+// labelSet formats label values, with extra name and value pairs after them
+func (d *desc) labelSet(values []string, extra ...string) string {
+	var pairs []string
+	for i, l := range d.labels {
+		pairs = append(pairs, l+`="`+escapeLabel(values[i])+`"`)
+	}
+	for i := 0; i+1 < len(extra); i += 2 {
+		pairs = append(pairs, extra[i]+`="`+escapeLabel(extra[i+1])+`"`)
+	}
+	if len(pairs) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// Counter is a metric which only goes up, such as requests handled
+type Counter struct {
+	*desc
+}
+
+// Counter registers a counter with the given label names
+func (r *Registry) Counter(name, help string, labels ...string) *Counter {
+	c := &Counter{}
+	c.desc = r.register(name, help, "counter", labels, c)
+	return c
+}
+
+// Inc adds one to the series with the label values
+func (c *Counter) Inc(values ...string) {
+	c.Add(1, values...)
+}
+
+// Add adds v, which must not be negative, to the series with the label values
+func (c *Counter) Add(v float64, values ...string) {
+	if v < 0 {
+		panic(fmt.Sprintf("metrics: counter %s can not go down", c.name))
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.get(values).value += v
+}
+
+// Value returns the value of the series with the label values
+func (c *Counter) Value(values ...string) float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if s := c.lookup(values); s != nil {
+		return s.value
+	}
+	return 0
+}
+
+func (c *Counter) write(w io.Writer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.header(w)
+	for _, s := range c.sorted() {
+		fmt.Fprintf(w, "%s%s %s\n", c.name, c.labelSet(s.values), formatFloat(s.value))
+	}
+}
+
+// Gauge is a metric which goes up and down, such as the number of tickets
+type Gauge struct {
+	*desc
+}
+
+// Gauge registers a gauge with the given label names
+func (r *Registry) Gauge(name, help string, labels ...string) *Gauge {
+	g := &Gauge{}
+	g.desc = r.register(name, help, "gauge", labels, g)
+	return g
+}
+
+// Set sets the series with the label values to v
+func (g *Gauge) Set(v float64, values ...string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.get(values).value = v
+}
+
+// Reset removes every series, so that label values which are gone are no
+// longer reported
+func (g *Gauge) Reset() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.series = map[string]*series{}
+}
+
+// Value returns the value of the series with the label values
+func (g *Gauge) Value(values ...string) float64 {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if s := g.lookup(values); s != nil {
+		return s.value
+	}
+	return 0
+}
+
+func (g *Gauge) write(w io.Writer) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.header(w)
+	for _, s := range g.sorted() {
+		fmt.Fprintf(w, "%s%s %s\n", g.name, g.labelSet(s.values), formatFloat(s.value))
+	}
+}
+
+// Histogram counts observations, such as latencies, in buckets
+type Histogram struct {
+	*desc
+	buckets []float64
+}
+
+// Histogram registers a histogram with the given upper bounds of its buckets,
+// in increasing order, and label names
+func (r *Registry) Histogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	if !sort.Float64sAreSorted(buckets) {
+		panic(fmt.Sprintf("metrics: buckets of %s are not in increasing order", name))
+	}
+	h := &Histogram{buckets: buckets}
+	h.desc = r.register(name, help, "histogram", labels, h)
+	return h
+}
+
+// Observe counts v in the series with the label values
+func (h *Histogram) Observe(v float64, values ...string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s := h.get(values)
+	if s.counts == nil {
+		s.counts = make([]uint64, len(h.buckets)+1)
+	}
+	s.counts[sort.SearchFloat64s(h.buckets, v)]++
+	s.sum += v
+}
+
+// Count returns the number of observations in the series with the label
+// values
+func (h *Histogram) Count(values ...string) uint64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	var n uint64
+	if s := h.lookup(values); s != nil {
+		for _, c := range s.counts {
+			n += c
+		}
+	}
+	return n
+}
+
+func (h *Histogram) write(w io.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.header(w)
+	for _, s := range h.sorted() {
+		var n uint64
+		for i, c := range s.counts {
+			n += c
+			le := "+Inf"
+			if i < len(h.buckets) {
+				le = formatFloat(h.buckets[i])
+			}
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, h.labelSet(s.values, "le", le), n)
+		}
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.name, h.labelSet(s.values), formatFloat(s.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.name, h.labelSet(s.values), n)
+	}
+}
+
+// OnScrape calls fn before the metrics are written, to set gauges which are
+// read from elsewhere, such as the number of tickets in each status
+func (r *Registry) OnScrape(fn func()) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.onScrape = append(r.onScrape, fn)
+}
+
+// Write writes every metric in the Prometheus text format, in the order they
+// were registered
+func (r *Registry) Write(w io.Writer) error {
+	r.mu.Lock()
+	fns := append([]func(){}, r.onScrape...)
+	metrics := append([]metric{}, r.metrics...)
+	r.mu.Unlock()
+	for _, fn := range fns {
+		fn()
+	}
+	b := bufio.NewWriter(w)
+	for _, m := range metrics {
+		m.write(b)
+	}
+	return b.Flush()
+}
+
+// ServeHTTP satisfies http.Handler, serving the metrics to Prometheus
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	r.Write(w)
+}
+
+// Protect requires requests to h to carry token as a bearer token, unless
+// token is empty
+func Protect(h http.Handler, token string) http.Handler {
+	if token == "" {
+		return h
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte("Bearer "+token)) != 1 {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func escapeHelp(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(s)
+}
+
+func escapeLabel(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s)
+}
@@ -0,0 +1,93 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRegistry(t *testing.T) {
+	r := NewRegistry()
+	requests := r.Counter("requests_total", "Requests handled", "kind", "outcome")
+	tickets := r.Gauge("tickets", "Tickets by status", "status")
+	latency := r.Histogram("latency_seconds", "How long requests\ntake", []float64{0.1, 1})
+	up := r.Gauge("up", "Whether the server is up")
+
+	requests.Inc("command", "ok")
+	requests.Add(2, "event", "ok")
+	requests.Inc("command", "ok")
+	requests.Inc("event", `bad "quote"`)
+	scrapes := 0
+	r.OnScrape(func() {
+		scrapes++
+		tickets.Reset()
+		tickets.Set(3, "new")
+	})
+	tickets.Set(9, "gone")
+	latency.Observe(0.05)
+	latency.Observe(0.1)
+	latency.Observe(0.5)
+	latency.Observe(3)
+	up.Set(1)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	want := `# HELP requests_total Requests handled
+# TYPE requests_total counter
+requests_total{kind="command",outcome="ok"} 2
+requests_total{kind="event",outcome="bad \"quote\""} 1
+requests_total{kind="event",outcome="ok"} 2
+# HELP tickets Tickets by status
+# TYPE tickets gauge
+tickets{status="new"} 3
+# HELP latency_seconds How long requests\ntake
+# TYPE latency_seconds histogram
+latency_seconds_bucket{le="0.1"} 2
+latency_seconds_bucket{le="1"} 3
+latency_seconds_bucket{le="+Inf"} 4
+latency_seconds_sum 3.65
+latency_seconds_count 4
+# HELP up Whether the server is up
+# TYPE up gauge
+up 1
+`
+	if got := w.Body.String(); got != want {
+		t.Errorf("Expected\n%s\ngot\n%s", want, got)
+	}
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("Unexpected content type %s", ct)
+	}
+	if scrapes != 1 || requests.Value("command", "ok") != 2 || requests.Value("hr", "ok") != 0 || latency.Count() != 4 {
+		t.Errorf("Unexpected values after %d scrapes", scrapes)
+	}
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("POST", "/metrics", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected a POST to be refused, got %d", w.Code)
+	}
+}
+
+func TestProtect(t *testing.T) {
+	h := Protect(NewRegistry(), "secret")
+	for auth, want := range map[string]int{"": http.StatusUnauthorized, "Bearer wrong": http.StatusUnauthorized, "Bearer secret": http.StatusOK} {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", "/metrics", nil)
+		r.Header.Set("Authorization", auth)
+		h.ServeHTTP(w, r)
+		if w.Code != want {
+			t.Errorf("Expected %d with %q, got %d", want, auth, w.Code)
+		}
+	}
+}
+
+func TestRegisterTwice(t *testing.T) {
+	r := NewRegistry()
+	r.Counter("requests_total", "Requests handled")
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected registering a name twice to panic")
+		}
+	}()
+	r.Gauge("requests_total", "Requests handled")
+}
@@ -1,14 +1,45 @@
 // Code generated by mockery v1.0.0. DO NOT EDIT.
 package mocks
 
+import io "io"
 import mock "github.com/stretchr/testify/mock"
 import slack "github.com/nlopes/slack"
+import time "time"
+import views "github.com/skybet/go-helpdesk/views"
 
 // SlackWrapper is an autogenerated mock type for the SlackWrapper type
 type SlackWrapper struct {
 	mock.Mock
 }
 
+// AddReaction provides a mock function with given fields: name, item
+func (_m *SlackWrapper) AddReaction(name string, item slack.ItemRef) error {
+	ret := _m.Called(name, item)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string, slack.ItemRef) error); ok {
+		r0 = rf(name, item)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// DownloadFile provides a mock function with given fields: url, w
+func (_m *SlackWrapper) DownloadFile(url string, w io.Writer) error {
+	ret := _m.Called(url, w)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string, io.Writer) error); ok {
+		r0 = rf(url, w)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // OpenDialog provides a mock function with given fields: triggerID, dialog
 func (_m *SlackWrapper) OpenDialog(triggerID string, dialog slack.Dialog) error {
 	ret := _m.Called(triggerID, dialog)
@@ -23,7 +54,241 @@ func (_m *SlackWrapper) OpenDialog(triggerID string, dialog slack.Dialog) error
 	return r0
 }
 
+// OpenView provides a mock function with given fields: triggerID, view
+func (_m *SlackWrapper) OpenView(triggerID string, view *views.View) (*views.View, error) {
+	ret := _m.Called(triggerID, view)
+
+	var r0 *views.View
+	if rf, ok := ret.Get(0).(func(string, *views.View) *views.View); ok {
+		r0 = rf(triggerID, view)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*views.View)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(string, *views.View) error); ok {
+		r1 = rf(triggerID, view)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// PostMessage provides a mock function with given fields: channelID, options
+func (_m *SlackWrapper) PostMessage(channelID string, options ...slack.MsgOption) (string, string, error) {
+	_va := make([]interface{}, len(options))
+	for _i := range options {
+		_va[_i] = options[_i]
+	}
+	var _ca []interface{}
+	_ca = append(_ca, channelID)
+	_ca = append(_ca, _va...)
+	ret := _m.Called(_ca...)
+
+	var r0 string
+	if rf, ok := ret.Get(0).(func(string, ...slack.MsgOption) string); ok {
+		r0 = rf(channelID, options...)
+	} else {
+		r0 = ret.Get(0).(string)
+	}
+
+	var r1 string
+	if rf, ok := ret.Get(1).(func(string, ...slack.MsgOption) string); ok {
+		r1 = rf(channelID, options...)
+	} else {
+		r1 = ret.Get(1).(string)
+	}
+
+	var r2 error
+	if rf, ok := ret.Get(2).(func(string, ...slack.MsgOption) error); ok {
+		r2 = rf(channelID, options...)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
+// PublishView provides a mock function with given fields: userID, view
+func (_m *SlackWrapper) PublishView(userID string, view *views.View) (*views.View, error) {
+	ret := _m.Called(userID, view)
+
+	var r0 *views.View
+	if rf, ok := ret.Get(0).(func(string, *views.View) *views.View); ok {
+		r0 = rf(userID, view)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*views.View)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(string, *views.View) error); ok {
+		r1 = rf(userID, view)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// PushView provides a mock function with given fields: triggerID, view
+func (_m *SlackWrapper) PushView(triggerID string, view *views.View) (*views.View, error) {
+	ret := _m.Called(triggerID, view)
+
+	var r0 *views.View
+	if rf, ok := ret.Get(0).(func(string, *views.View) *views.View); ok {
+		r0 = rf(triggerID, view)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*views.View)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(string, *views.View) error); ok {
+		r1 = rf(triggerID, view)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// RemovePin provides a mock function with given fields: channel, item
+func (_m *SlackWrapper) RemovePin(channel string, item slack.ItemRef) error {
+	ret := _m.Called(channel, item)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string, slack.ItemRef) error); ok {
+		r0 = rf(channel, item)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// ScheduleMessage provides a mock function with given fields: channelID, postAt, options
+func (_m *SlackWrapper) ScheduleMessage(channelID string, postAt time.Time, options ...slack.MsgOption) (string, error) {
+	_va := make([]interface{}, len(options))
+	for _i := range options {
+		_va[_i] = options[_i]
+	}
+	var _ca []interface{}
+	_ca = append(_ca, channelID, postAt)
+	_ca = append(_ca, _va...)
+	ret := _m.Called(_ca...)
+
+	var r0 string
+	if rf, ok := ret.Get(0).(func(string, time.Time, ...slack.MsgOption) string); ok {
+		r0 = rf(channelID, postAt, options...)
+	} else {
+		r0 = ret.Get(0).(string)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(string, time.Time, ...slack.MsgOption) error); ok {
+		r1 = rf(channelID, postAt, options...)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // SendMessage provides a mock function with given fields: message, channel
 func (_m *SlackWrapper) SendMessage(message string, channel string) {
 	_m.Called(message, channel)
 }
+
+// UpdateMessage provides a mock function with given fields: channelID, timestamp, options
+func (_m *SlackWrapper) UpdateMessage(channelID string, timestamp string, options ...slack.MsgOption) (string, string, string, error) {
+	_va := make([]interface{}, len(options))
+	for _i := range options {
+		_va[_i] = options[_i]
+	}
+	var _ca []interface{}
+	_ca = append(_ca, channelID, timestamp)
+	_ca = append(_ca, _va...)
+	ret := _m.Called(_ca...)
+
+	var r0 string
+	if rf, ok := ret.Get(0).(func(string, string, ...slack.MsgOption) string); ok {
+		r0 = rf(channelID, timestamp, options...)
+	} else {
+		r0 = ret.Get(0).(string)
+	}
+
+	var r1 string
+	if rf, ok := ret.Get(1).(func(string, string, ...slack.MsgOption) string); ok {
+		r1 = rf(channelID, timestamp, options...)
+	} else {
+		r1 = ret.Get(1).(string)
+	}
+
+	var r2 string
+	if rf, ok := ret.Get(2).(func(string, string, ...slack.MsgOption) string); ok {
+		r2 = rf(channelID, timestamp, options...)
+	} else {
+		r2 = ret.Get(2).(string)
+	}
+
+	var r3 error
+	if rf, ok := ret.Get(3).(func(string, string, ...slack.MsgOption) error); ok {
+		r3 = rf(channelID, timestamp, options...)
+	} else {
+		r3 = ret.Error(3)
+	}
+
+	return r0, r1, r2, r3
+}
+
+// UpdateView provides a mock function with given fields: view, viewID, hash
+func (_m *SlackWrapper) UpdateView(view *views.View, viewID string, hash string) (*views.View, error) {
+	ret := _m.Called(view, viewID, hash)
+
+	var r0 *views.View
+	if rf, ok := ret.Get(0).(func(*views.View, string, string) *views.View); ok {
+		r0 = rf(view, viewID, hash)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*views.View)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(*views.View, string, string) error); ok {
+		r1 = rf(view, viewID, hash)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// UploadFile provides a mock function with given fields: params
+func (_m *SlackWrapper) UploadFile(params slack.FileUploadParameters) (*slack.File, error) {
+	ret := _m.Called(params)
+
+	var r0 *slack.File
+	if rf, ok := ret.Get(0).(func(slack.FileUploadParameters) *slack.File); ok {
+		r0 = rf(params)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*slack.File)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(slack.FileUploadParameters) error); ok {
+		r1 = rf(params)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
@@ -0,0 +1,413 @@
+package mocks
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nlopes/slack"
+
+	"github.com/skybet/go-helpdesk/views"
+	"github.com/skybet/go-helpdesk/wrapper"
+)
+
+var _ wrapper.SlackWrapper = (*Slack)(nil)
+
+// Slack is a fake wrapper.SlackWrapper which is told which calls to expect,
+// e.g. s.ExpectPostMessage().ToChannel("C1").ReturnTS("123.456"). Calls
+// which no expectation matches fail the test and return an error, as do
+// expectations which are not met by the end of the test.
+type Slack struct {
+	t testing.TB
+
+	mu           sync.Mutex
+	expectations []*Expectation
+	calls        []*Call
+}
+
+// NewSlack returns a fake which checks its expectations when t finishes
+func NewSlack(t testing.TB) *Slack {
+	s := &Slack{t: t}
+	t.Cleanup(s.AssertExpectations)
+	return s
+}
+
+// Call is a call made to the fake
+type Call struct {
+	Method string
+	// Channel, TS and User are the channel, message timestamp and user the
+	// call was for, where it has them
+	Channel string
+	TS      string
+	User    string
+	// Text is the message's text followed by its blocks as JSON, or the
+	// dialog, view or file content
+	Text string
+	// Ephemeral is true for messages posted so only User can see them
+	Ephemeral bool
+	// Name is the reaction added, the trigger ID views were opened with or
+	// the URL a file was downloaded from
+	Name    string
+	Options []slack.MsgOption
+	View    *views.View
+	PostAt  time.Time
+	File    slack.FileUploadParameters
+}
+
+func (c *Call) String() string {
+	var fields []string
+	for _, f := range []struct{ name, value string }{
+		{"channel", c.Channel}, {"ts", c.TS}, {"user", c.User}, {"name", c.Name}, {"text", c.Text},
+	} {
+		if f.value != "" {
+			fields = append(fields, fmt.Sprintf("%s=%q", f.name, f.value))
+		}
+	}
+	if c.Ephemeral {
+		fields = append(fields, "ephemeral")
+	}
+	return fmt.Sprintf("%s(%s)", c.Method, strings.Join(fields, " "))
+}
+
+// Expectation is a call the fake expects, built by chaining conditions and
+// return values onto one of the Expect methods. It expects to be called once
+// unless told otherwise with Times or AnyTimes.
+type Expectation struct {
+	method  string
+	checks  []check
+	min     int
+	max     int
+	calls   int
+	ts      string
+	id      string
+	view    *views.View
+	content string
+	err     error
+}
+
+type check struct {
+	desc  string
+	match func(c *Call) bool
+}
+
+// ToChannel expects the call to be for a channel or DM
+func (e *Expectation) ToChannel(id string) *Expectation {
+	return e.where(fmt.Sprintf("channel=%q", id), func(c *Call) bool { return c.Channel == id })
+}
+
+// ForTS expects the call to be for the message with timestamp ts
+func (e *Expectation) ForTS(ts string) *Expectation {
+	return e.where(fmt.Sprintf("ts=%q", ts), func(c *Call) bool { return c.TS == ts })
+}
+
+// ForUser expects the call to be for a user, such as the user an ephemeral
+// message or App Home is for
+func (e *Expectation) ForUser(id string) *Expectation {
+	return e.where(fmt.Sprintf("user=%q", id), func(c *Call) bool { return c.User == id })
+}
+
+// Named expects the reaction or trigger ID of the call
+func (e *Expectation) Named(name string) *Expectation {
+	return e.where(fmt.Sprintf("name=%q", name), func(c *Call) bool { return c.Name == name })
+}
+
+// WithText expects the call's text or blocks to contain s
+func (e *Expectation) WithText(s string) *Expectation {
+	return e.where(fmt.Sprintf("text containing %q", s), func(c *Call) bool { return strings.Contains(c.Text, s) })
+}
+
+// Ephemeral expects a message to be posted so only user can see it
+func (e *Expectation) Ephemeral(user string) *Expectation {
+	return e.where(fmt.Sprintf("ephemeral to %q", user), func(c *Call) bool { return c.Ephemeral && c.User == user })
+}
+
+// Matching expects the call to satisfy fn, described by desc when it does not
+func (e *Expectation) Matching(desc string, fn func(c *Call) bool) *Expectation {
+	return e.where(desc, fn)
+}
+
+// Times expects the call exactly n times
+func (e *Expectation) Times(n int) *Expectation {
+	e.min, e.max = n, n
+	return e
+}
+
+// AnyTimes allows the call any number of times, including none
+func (e *Expectation) AnyTimes() *Expectation {
+	e.min, e.max = 0, -1
+	return e
+}
+
+// ReturnTS sets the timestamp of the message posted or updated
+func (e *Expectation) ReturnTS(ts string) *Expectation {
+	e.ts = ts
+	return e
+}
+
+// ReturnID sets the ID of the message scheduled
+func (e *Expectation) ReturnID(id string) *Expectation {
+	e.id = id
+	return e
+}
+
+// ReturnView sets the view opened, published or updated
+func (e *Expectation) ReturnView(v *views.View) *Expectation {
+	e.view = v
+	return e
+}
+
+// ReturnContent sets the content of the file downloaded
+func (e *Expectation) ReturnContent(content string) *Expectation {
+	e.content = content
+	return e
+}
+
+// ReturnError makes the call fail with err
+func (e *Expectation) ReturnError(err error) *Expectation {
+	e.err = err
+	return e
+}
+
+func (e *Expectation) where(desc string, match func(c *Call) bool) *Expectation {
+	e.checks = append(e.checks, check{desc, match})
+	return e
+}
+
+func (e *Expectation) String() string {
+	descs := make([]string, len(e.checks))
+	for i, c := range e.checks {
+		descs[i] = c.desc
+	}
+	return fmt.Sprintf("%s(%s)", e.method, strings.Join(descs, ", "))
+}
+
+func (e *Expectation) matches(c *Call) bool {
+	if e.method != c.Method || (e.max >= 0 && e.calls >= e.max) {
+		return false
+	}
+	for _, ch := range e.checks {
+		if !ch.match(c) {
+			return false
+		}
+	}
+	return true
+}
+
+// ExpectOpenDialog expects a dialog to be opened
+func (s *Slack) ExpectOpenDialog() *Expectation { return s.expect("OpenDialog") }
+
+// ExpectOpenView expects a modal to be opened
+func (s *Slack) ExpectOpenView() *Expectation { return s.expect("OpenView") }
+
+// ExpectUpdateView expects a modal to be updated
+func (s *Slack) ExpectUpdateView() *Expectation { return s.expect("UpdateView") }
+
+// ExpectPushView expects a view to be pushed onto a modal's stack
+func (s *Slack) ExpectPushView() *Expectation { return s.expect("PushView") }
+
+// ExpectPublishView expects a user's App Home to be published
+func (s *Slack) ExpectPublishView() *Expectation { return s.expect("PublishView") }
+
+// ExpectPostMessage expects a message to be posted
+func (s *Slack) ExpectPostMessage() *Expectation { return s.expect("PostMessage") }
+
+// ExpectScheduleMessage expects a message to be scheduled
+func (s *Slack) ExpectScheduleMessage() *Expectation { return s.expect("ScheduleMessage") }
+
+// ExpectUpdateMessage expects a message to be edited
+func (s *Slack) ExpectUpdateMessage() *Expectation { return s.expect("UpdateMessage") }
+
+// ExpectAddReaction expects a reaction to be added
+func (s *Slack) ExpectAddReaction() *Expectation { return s.expect("AddReaction") }
+
+// ExpectRemovePin expects a message to be unpinned
+func (s *Slack) ExpectRemovePin() *Expectation { return s.expect("RemovePin") }
+
+// ExpectUploadFile expects a file to be uploaded
+func (s *Slack) ExpectUploadFile() *Expectation { return s.expect("UploadFile") }
+
+// ExpectDownloadFile expects a file to be downloaded
+func (s *Slack) ExpectDownloadFile() *Expectation { return s.expect("DownloadFile") }
+
+func (s *Slack) expect(method string) *Expectation {
+	e := &Expectation{method: method, min: 1, max: 1}
+	s.mu.Lock()
+	s.expectations = append(s.expectations, e)
+	s.mu.Unlock()
+	return e
+}
+
+// Calls returns the calls made to method, or every call if method is empty
+func (s *Slack) Calls(method string) []*Call {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var calls []*Call
+	for _, c := range s.calls {
+		if method == "" || c.Method == method {
+			calls = append(calls, c)
+		}
+	}
+	return calls
+}
+
+// AssertExpectations fails the test for each expectation which was not met
+func (s *Slack) AssertExpectations() {
+	s.t.Helper()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, e := range s.expectations {
+		if e.calls < e.min {
+			s.t.Errorf("Expected %s to be called %d times, it was called %d times", e, e.min, e.calls)
+		}
+	}
+}
+
+// called records c, returning the expectation it met. Unexpected calls fail
+// the test, they are reported with Errorf so handlers running in other
+// goroutines can make them too.
+func (s *Slack) called(c *Call) (*Expectation, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls = append(s.calls, c)
+	for _, e := range s.expectations {
+		if e.matches(c) {
+			e.calls++
+			return e, e.err
+		}
+	}
+	var expected []string
+	for _, e := range s.expectations {
+		if e.method == c.Method {
+			expected = append(expected, e.String())
+		}
+	}
+	if len(expected) == 0 {
+		expected = []string{"none"}
+	}
+	s.t.Errorf("Unexpected call %s, expected %s", c, strings.Join(expected, " or "))
+	return nil, fmt.Errorf("unexpected call %s", c)
+}
+
+// message fills in a call from the options of a message
+func message(c *Call, options []slack.MsgOption) *Call {
+	c.Options = options
+	endpoint, values, _ := slack.UnsafeApplyMsgOptions("", c.Channel, "", options...)
+	c.Ephemeral = strings.HasSuffix(endpoint, "chat.postEphemeral")
+	if c.User == "" {
+		c.User = values.Get("user")
+	}
+	c.Text = text(values)
+	return c
+}
+
+func text(values url.Values) string {
+	return strings.TrimSpace(values.Get("text") + "\n" + values.Get("blocks"))
+}
+
+// OpenDialog records the dialog's title as the call's text
+func (s *Slack) OpenDialog(triggerID string, dialog slack.Dialog) error {
+	_, err := s.called(&Call{Method: "OpenDialog", Name: triggerID, Text: dialog.Title})
+	return err
+}
+
+// OpenView returns the view it is given unless told otherwise
+func (s *Slack) OpenView(triggerID string, view *views.View) (*views.View, error) {
+	return s.view(&Call{Method: "OpenView", Name: triggerID, View: view})
+}
+
+// UpdateView returns the view it is given unless told otherwise
+func (s *Slack) UpdateView(view *views.View, viewID, hash string) (*views.View, error) {
+	return s.view(&Call{Method: "UpdateView", Name: viewID, View: view})
+}
+
+// PushView returns the view it is given unless told otherwise
+func (s *Slack) PushView(triggerID string, view *views.View) (*views.View, error) {
+	return s.view(&Call{Method: "PushView", Name: triggerID, View: view})
+}
+
+// PublishView returns the view it is given unless told otherwise
+func (s *Slack) PublishView(userID string, view *views.View) (*views.View, error) {
+	return s.view(&Call{Method: "PublishView", User: userID, View: view})
+}
+
+func (s *Slack) view(c *Call) (*views.View, error) {
+	e, err := s.called(c)
+	if err != nil {
+		return nil, err
+	}
+	if e.view != nil {
+		return e.view, nil
+	}
+	return c.View, nil
+}
+
+// PostMessage returns the channel it is given and the expectation's TS
+func (s *Slack) PostMessage(channelID string, options ...slack.MsgOption) (string, string, error) {
+	e, err := s.called(message(&Call{Method: "PostMessage", Channel: channelID}, options))
+	if err != nil {
+		return "", "", err
+	}
+	return channelID, e.ts, nil
+}
+
+// ScheduleMessage returns the expectation's ID
+func (s *Slack) ScheduleMessage(channelID string, postAt time.Time, options ...slack.MsgOption) (string, error) {
+	e, err := s.called(message(&Call{Method: "ScheduleMessage", Channel: channelID, PostAt: postAt}, options))
+	if err != nil {
+		return "", err
+	}
+	return e.id, nil
+}
+
+// UpdateMessage returns the channel and timestamp it is given, or the
+// expectation's TS if it has one
+func (s *Slack) UpdateMessage(channelID, timestamp string, options ...slack.MsgOption) (string, string, string, error) {
+	e, err := s.called(message(&Call{Method: "UpdateMessage", Channel: channelID, TS: timestamp}, options))
+	if err != nil {
+		return "", "", "", err
+	}
+	if e.ts != "" {
+		timestamp = e.ts
+	}
+	return channelID, timestamp, "", nil
+}
+
+// AddReaction records the reaction as the call's name
+func (s *Slack) AddReaction(name string, item slack.ItemRef) error {
+	_, err := s.called(&Call{Method: "AddReaction", Name: name, Channel: item.Channel, TS: item.Timestamp})
+	return err
+}
+
+// RemovePin records the pinned message
+func (s *Slack) RemovePin(channel string, item slack.ItemRef) error {
+	_, err := s.called(&Call{Method: "RemovePin", Channel: channel, TS: item.Timestamp})
+	return err
+}
+
+// UploadFile records the file's first channel and its content as the call's
+// text
+func (s *Slack) UploadFile(params slack.FileUploadParameters) (*slack.File, error) {
+	c := &Call{Method: "UploadFile", Name: params.Filename, Text: params.Content, File: params}
+	if len(params.Channels) > 0 {
+		c.Channel = params.Channels[0]
+	}
+	if _, err := s.called(c); err != nil {
+		return nil, err
+	}
+	return &slack.File{Name: params.Filename, Title: params.Title}, nil
+}
+
+// DownloadFile records the URL as the call's name and writes the content the
+// expectation returns
+func (s *Slack) DownloadFile(url string, w io.Writer) error {
+	e, err := s.called(&Call{Method: "DownloadFile", Name: url})
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, e.content)
+	return err
+}
@@ -0,0 +1,81 @@
+package mocks
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/nlopes/slack"
+)
+
+// recorder collects the failures of a fake test
+type recorder struct {
+	testing.TB
+	errors   []string
+	cleanups []func()
+}
+
+func (r *recorder) Helper()          {}
+func (r *recorder) Cleanup(f func()) { r.cleanups = append(r.cleanups, f) }
+func (r *recorder) Errorf(format string, args ...interface{}) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func (r *recorder) finish() {
+	for _, f := range r.cleanups {
+		f()
+	}
+}
+
+func TestSlackExpectations(t *testing.T) {
+	r := &recorder{}
+	s := NewSlack(r)
+	s.ExpectPostMessage().ToChannel("C1").WithText("Hello").ReturnTS("123.456")
+	s.ExpectPostMessage().ToChannel("C1").Ephemeral("U1").AnyTimes()
+	s.ExpectAddReaction().Named("tada").ReturnError(errors.New("already_reacted"))
+
+	if ch, ts, err := s.PostMessage("C1", slack.MsgOptionText("Hello world", false)); ch != "C1" || ts != "123.456" || err != nil {
+		t.Errorf("Expected the message to be posted, got %s %s %v", ch, ts, err)
+	}
+	for i := 0; i < 2; i++ {
+		if _, _, err := s.PostMessage("C1", slack.MsgOptionPostEphemeral("U1"), slack.MsgOptionText("Psst", false)); err != nil {
+			t.Errorf("Unexpected error: %s", err)
+		}
+	}
+	if err := s.AddReaction("tada", slack.ItemRef{Channel: "C1", Timestamp: "123.456"}); err == nil || err.Error() != "already_reacted" {
+		t.Errorf("Expected the reaction to fail, got %v", err)
+	}
+	r.finish()
+	if len(r.errors) > 0 {
+		t.Errorf("Expected every call to be expected, got %v", r.errors)
+	}
+	if calls := s.Calls("PostMessage"); len(calls) != 3 || !calls[1].Ephemeral || calls[1].User != "U1" {
+		t.Errorf("Expected the posts to be recorded, got %v", calls)
+	}
+}
+
+func TestSlackUnexpectedCalls(t *testing.T) {
+	r := &recorder{}
+	s := NewSlack(r)
+	s.ExpectPostMessage().ToChannel("C1")
+	s.ExpectUpdateMessage().ForTS("1.1")
+
+	// The first message is posted to the wrong channel and the second is one
+	// more than expected
+	if _, _, err := s.PostMessage("C2", slack.MsgOptionText("Hello", false)); err == nil {
+		t.Errorf("Expected an unexpected call to fail")
+	}
+	s.PostMessage("C1", slack.MsgOptionText("Hello", false))
+	s.PostMessage("C1", slack.MsgOptionText("Hello", false))
+	r.finish()
+	if len(r.errors) != 3 {
+		t.Fatalf("Expected three failures, got %v", r.errors)
+	}
+	if !strings.Contains(r.errors[0], `Unexpected call PostMessage(channel="C2" text="Hello"), expected PostMessage(channel="C1")`) {
+		t.Errorf("Expected the call and expectation to be described, got %s", r.errors[0])
+	}
+	if !strings.Contains(r.errors[2], `Expected UpdateMessage(ts="1.1") to be called 1 times, it was called 0 times`) {
+		t.Errorf("Expected the unmet expectation to be reported, got %s", r.errors[2])
+	}
+}
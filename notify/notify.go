@@ -0,0 +1,189 @@
+// Package notify delivers direct messages to users, holding non-urgent ones
+// back until the end of the user's quiet hours
+package notify
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nlopes/slack"
+)
+
+// Hours is a daily quiet period in the user's time zone, Start and End are
+// offsets from midnight. A period with End before Start runs overnight.
+type Hours struct {
+	Start time.Duration
+	End   time.Duration
+}
+
+// Off are quiet hours which are never quiet
+var Off = Hours{}
+
+// ParseHours parses quiet hours in the form 22:00-08:00, or off
+func ParseHours(s string) (Hours, error) {
+	if s == "" || strings.EqualFold(s, "off") {
+		return Off, nil
+	}
+	parts := strings.SplitN(s, "-", 2)
+	if len(parts) != 2 {
+		return Off, fmt.Errorf("invalid quiet hours %q, expected HH:MM-HH:MM", s)
+	}
+	var h Hours
+	for i, p := range parts {
+		t, err := time.Parse("15:04", strings.TrimSpace(p))
+		if err != nil {
+			return Off, fmt.Errorf("invalid quiet hours %q, expected HH:MM-HH:MM", s)
+		}
+		d := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute
+		if i == 0 {
+			h.Start = d
+		} else {
+			h.End = d
+		}
+	}
+	return h, nil
+}
+
+// String formats the hours as ParseHours accepts them
+func (h Hours) String() string {
+	if h == Off {
+		return "off"
+	}
+	f := func(d time.Duration) string {
+		return fmt.Sprintf("%02d:%02d", int(d/time.Hour), int(d%time.Hour/time.Minute))
+	}
+	return f(h.Start) + "-" + f(h.End)
+}
+
+// Until returns when the quiet hours containing t end, or the zero time if t
+// is not in the quiet hours. t's location is the user's time zone.
+func (h Hours) Until(t time.Time) time.Time {
+	if h == Off || h.Start == h.End {
+		return time.Time{}
+	}
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	offset := t.Sub(midnight)
+	end := func(days int) time.Time {
+		d := midnight.AddDate(0, 0, days)
+		return time.Date(d.Year(), d.Month(), d.Day(), int(h.End/time.Hour), int(h.End%time.Hour/time.Minute), 0, 0, t.Location())
+	}
+	if h.Start < h.End {
+		if offset >= h.Start && offset < h.End {
+			return end(0)
+		}
+		return time.Time{}
+	}
+	switch {
+	case offset >= h.Start:
+		return end(1)
+	case offset < h.End:
+		return end(0)
+	}
+	return time.Time{}
+}
+
+// Slack is the part of the Slack API used to deliver messages
+type Slack interface {
+	PostMessage(channelID string, options ...slack.MsgOption) (string, string, error)
+	ScheduleMessage(channelID string, postAt time.Time, options ...slack.MsgOption) (string, error)
+}
+
+// Users looks up users' time zones, it is satisfied by *wrapper.Directory
+type Users interface {
+	User(ctx context.Context, id string) (*slack.User, error)
+}
+
+// UrgentPoster is implemented by posters which can deliver urgent messages
+// straight away, even during quiet hours
+type UrgentPoster interface {
+	PostUrgent(channelID string, options ...slack.MsgOption) (string, string, error)
+}
+
+// Notifier posts messages, scheduling DMs which arrive in the recipient's
+// quiet hours for when they end. Messages to channels are always posted
+// straight away. Notifier is safe for concurrent use.
+type Notifier struct {
+	Slack Slack
+	Users Users
+	// Default are the quiet hours of users who have not chosen their own
+	Default Hours
+	// BreakThrough posts urgent messages during quiet hours
+	BreakThrough bool
+
+	mu    sync.RWMutex
+	hours map[string]Hours
+	now   func() time.Time
+}
+
+// New returns a Notifier with the given default quiet hours
+func New(s Slack, u Users, def Hours) *Notifier {
+	return &Notifier{Slack: s, Users: u, Default: def, hours: map[string]Hours{}, now: time.Now}
+}
+
+// Hours returns a user's quiet hours and whether they chose them
+func (n *Notifier) Hours(user string) (Hours, bool) {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	h, ok := n.hours[user]
+	if !ok {
+		return n.Default, false
+	}
+	return h, true
+}
+
+// SetHours sets a user's quiet hours
+func (n *Notifier) SetHours(user string, h Hours) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.hours[user] = h
+}
+
+// Location returns a user's Slack time zone, UTC if it is unknown
+func (n *Notifier) Location(user string) *time.Location {
+	if n.Users == nil {
+		return time.UTC
+	}
+	u, err := n.Users.User(context.Background(), user)
+	if err != nil || u == nil {
+		return time.UTC
+	}
+	if loc, err := time.LoadLocation(u.TZ); err == nil && u.TZ != "" {
+		return loc
+	}
+	return time.FixedZone(u.TZLabel, u.TZOffset)
+}
+
+// PostMessage posts a non-urgent message, deferring DMs sent in the quiet
+// hours. Deferred messages return an empty timestamp.
+func (n *Notifier) PostMessage(channelID string, options ...slack.MsgOption) (string, string, error) {
+	return n.post(channelID, false, options...)
+}
+
+// PostUrgent posts an urgent message, which breaks through quiet hours if
+// BreakThrough is set
+func (n *Notifier) PostUrgent(channelID string, options ...slack.MsgOption) (string, string, error) {
+	return n.post(channelID, true, options...)
+}
+
+func (n *Notifier) post(channelID string, urgent bool, options ...slack.MsgOption) (string, string, error) {
+	if !isUser(channelID) || (urgent && n.BreakThrough) {
+		return n.Slack.PostMessage(channelID, options...)
+	}
+	h, _ := n.Hours(channelID)
+	until := h.Until(n.now().In(n.Location(channelID)))
+	if until.IsZero() {
+		return n.Slack.PostMessage(channelID, options...)
+	}
+	if _, err := n.Slack.ScheduleMessage(channelID, until, options...); err != nil {
+		return "", "", fmt.Errorf("error scheduling message for the end of quiet hours: %s", err)
+	}
+	return channelID, "", nil
+}
+
+// isUser reports whether a channel ID is a user's ID, which posts a DM
+func isUser(id string) bool {
+	return strings.HasPrefix(id, "U") || strings.HasPrefix(id, "W")
+}
@@ -0,0 +1,97 @@
+package notify
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/nlopes/slack"
+)
+
+func TestParseHours(t *testing.T) {
+	h, err := ParseHours("22:00-08:30")
+	if err != nil || h.Start != 22*time.Hour || h.End != 8*time.Hour+30*time.Minute || h.String() != "22:00-08:30" {
+		t.Errorf("Unexpected hours %v %v", h, err)
+	}
+	if h, err := ParseHours("off"); err != nil || h != Off {
+		t.Errorf("Expected off, got %v %v", h, err)
+	}
+	for _, s := range []string{"22:00", "25:00-08:00", "late-early"} {
+		if _, err := ParseHours(s); err == nil {
+			t.Errorf("Expected %q to be invalid", s)
+		}
+	}
+}
+
+func TestUntil(t *testing.T) {
+	overnight, _ := ParseHours("22:00-08:00")
+	day, _ := ParseHours("12:00-13:00")
+	at := func(h, m int) time.Time { return time.Date(2019, 3, 4, h, m, 0, 0, time.UTC) }
+	for _, c := range []struct {
+		h    Hours
+		t    time.Time
+		want time.Time
+	}{
+		{overnight, at(23, 0), time.Date(2019, 3, 5, 8, 0, 0, 0, time.UTC)},
+		{overnight, at(7, 59), at(8, 0)},
+		{overnight, at(8, 0), time.Time{}},
+		{overnight, at(21, 59), time.Time{}},
+		{day, at(12, 30), at(13, 0)},
+		{day, at(13, 30), time.Time{}},
+		{Off, at(23, 0), time.Time{}},
+	} {
+		if got := c.h.Until(c.t); !got.Equal(c.want) {
+			t.Errorf("%s at %s: expected %s, got %s", c.h, c.t.Format("15:04"), c.want, got)
+		}
+	}
+}
+
+type fakeSlack struct {
+	posted    []string
+	scheduled map[string]time.Time
+}
+
+func (f *fakeSlack) PostMessage(channelID string, options ...slack.MsgOption) (string, string, error) {
+	f.posted = append(f.posted, channelID)
+	return channelID, "1.1", nil
+}
+
+func (f *fakeSlack) ScheduleMessage(channelID string, postAt time.Time, options ...slack.MsgOption) (string, error) {
+	f.scheduled[channelID] = postAt
+	return "Q1", nil
+}
+
+type fakeUsers map[string]string
+
+func (f fakeUsers) User(ctx context.Context, id string) (*slack.User, error) {
+	return &slack.User{ID: id, TZ: f[id]}, nil
+}
+
+func TestNotifier(t *testing.T) {
+	s := &fakeSlack{scheduled: map[string]time.Time{}}
+	n := New(s, fakeUsers{"U1": "America/New_York", "U2": "Asia/Tokyo"}, Hours{Start: 22 * time.Hour, End: 8 * time.Hour})
+	n.BreakThrough = true
+	// 03:00 UTC is 23:00 in New York and 12:00 in Tokyo
+	n.now = func() time.Time { return time.Date(2019, 6, 4, 3, 0, 0, 0, time.UTC) }
+
+	n.PostMessage("U1", slack.MsgOptionText("Scorecard", false))
+	n.PostMessage("U2", slack.MsgOptionText("Scorecard", false))
+	n.PostMessage("C1", slack.MsgOptionText("Alert", false))
+	if want := time.Date(2019, 6, 4, 12, 0, 0, 0, time.UTC); !s.scheduled["U1"].Equal(want) {
+		t.Errorf("Expected U1's DM to be scheduled for 08:00 New York time, got %s", s.scheduled["U1"])
+	}
+	if len(s.posted) != 2 || s.posted[0] != "U2" || s.posted[1] != "C1" {
+		t.Errorf("Expected U2 and the channel to be posted to straight away, got %v", s.posted)
+	}
+
+	n.PostUrgent("U1", slack.MsgOptionText("P1 escalation", false))
+	if len(s.posted) != 3 {
+		t.Errorf("Expected urgent messages to break through, got %v", s.posted)
+	}
+
+	n.SetHours("U1", Off)
+	n.PostMessage("U1", slack.MsgOptionText("Scorecard", false))
+	if len(s.posted) != 4 {
+		t.Errorf("Expected users to be able to turn quiet hours off, got %v", s.posted)
+	}
+}
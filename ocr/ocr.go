@@ -0,0 +1,68 @@
+// Package ocr reads the text in images. Most IT tickets arrive as screenshots
+// of error dialogs, whose text is far more useful searchable than as pixels.
+package ocr
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Recognizer reads the text in an image of a MIME type, such as image/png
+type Recognizer interface {
+	Recognize(ctx context.Context, image io.Reader, mimetype string) (string, error)
+}
+
+// HTTP posts the image to URL with its MIME type as the Content-Type, the
+// service replies with the text it recognised as JSON, e.g. {"text": "Error
+// 0x80070005: Access is denied"}. The service can wrap Tesseract or a cloud
+// vision API.
+type HTTP struct {
+	URL    string
+	Client *http.Client
+}
+
+// Recognize satisfies Recognizer
+func (h *HTTP) Recognize(ctx context.Context, image io.Reader, mimetype string) (string, error) {
+	req, err := http.NewRequest(http.MethodPost, h.URL, image)
+	if err != nil {
+		return "", err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", mimetype)
+	req.Header.Set("Accept", "application/json")
+	client := h.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("error recognising text: %s", err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("error recognising text: %s", res.Status)
+	}
+	var recognised struct {
+		Text string `json:"text"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&recognised); err != nil {
+		return "", fmt.Errorf("error decoding recognised text: %s", err)
+	}
+	return Clean(recognised.Text), nil
+}
+
+// Clean tidies recognised text, dropping blank lines and the whitespace
+// around each line
+func Clean(text string) string {
+	var lines []string
+	for _, l := range strings.Split(text, "\n") {
+		if l = strings.TrimSpace(l); l != "" {
+			lines = append(lines, l)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,41 @@
+package ocr
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHTTP(t *testing.T) {
+	var gotType string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		gotType = r.Header.Get("Content-Type")
+		switch string(body) {
+		case "dialog":
+			w.Write([]byte(`{"text": "  Outlook\n\n  Cannot start Microsoft Outlook.  \n"}`))
+		case "blurry":
+			w.Write([]byte(`<html>`))
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer srv.Close()
+	h := &HTTP{URL: srv.URL}
+
+	text, err := h.Recognize(context.Background(), strings.NewReader("dialog"), "image/png")
+	if err != nil || text != "Outlook\nCannot start Microsoft Outlook." {
+		t.Errorf("Expected the cleaned text, got %q %v", text, err)
+	}
+	if gotType != "image/png" {
+		t.Errorf("Expected the image's type, got %q", gotType)
+	}
+	for _, image := range []string{"blurry", "video"} {
+		if _, err := h.Recognize(context.Background(), strings.NewReader(image), "image/png"); err == nil {
+			t.Errorf("Expected an error recognising %q", image)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+// Package outbox posts the Slack messages which announce ticket changes. The
+// messages are enqueued in the store in the same transaction as the change
+// and posted from there, so a change is never made without its message
+// eventually being posted.
+package outbox
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/nlopes/slack"
+
+	"github.com/skybet/go-helpdesk/clock"
+	"github.com/skybet/go-helpdesk/store"
+	"github.com/skybet/go-helpdesk/ticket"
+)
+
+// MaxAttempts is how many times a notification is tried before it is dropped
+const MaxAttempts = 10
+
+// Poster is the part of the Slack API used to post notifications
+type Poster interface {
+	PostMessage(channelID string, options ...slack.MsgOption) (string, string, error)
+}
+
+// Dispatcher posts the notifications waiting in the store's outbox. Delivery
+// is at least once: if removing a posted notification fails it is posted
+// again.
+type Dispatcher struct {
+	Store store.Store
+	Slack Poster
+	// Batch is the most notifications posted by each call to Dispatch, zero
+	// for no limit
+	Batch int
+	// Clock, if set, replaces the wall clock
+	Clock clock.Clock
+}
+
+// Dispatch posts the waiting notifications in the order they were enqueued,
+// returning how many were posted. Notifications which fail are tried again on
+// the next call, until they have failed MaxAttempts times.
+func (d *Dispatcher) Dispatch(ctx context.Context) (int, error) {
+	pending, err := d.Store.Outbox(ctx, d.Batch)
+	if err != nil {
+		return 0, fmt.Errorf("error reading the outbox: %s", err)
+	}
+	var errs []string
+	sent := 0
+	for _, n := range pending {
+		options := []slack.MsgOption{slack.MsgOptionText(n.Text, false)}
+		if n.ThreadTS != "" {
+			options = append(options, slack.MsgOptionTS(n.ThreadTS))
+		}
+		if _, _, err := d.Slack.PostMessage(n.ChannelID, options...); err != nil {
+			errs = append(errs, fmt.Sprintf("ticket %s: %s", n.TicketID, err))
+			if n.Attempts+1 >= MaxAttempts {
+				errs = append(errs, fmt.Sprintf("ticket %s: dropped %q after %d attempts", n.TicketID, n.Text, MaxAttempts))
+				err = d.Store.Sent(ctx, n.ID)
+			} else {
+				err = d.Store.Failed(ctx, n.ID, err.Error())
+			}
+			if err != nil {
+				errs = append(errs, fmt.Sprintf("ticket %s: %s", n.TicketID, err))
+			}
+			continue
+		}
+		sent++
+		if err := d.Store.Sent(ctx, n.ID); err != nil {
+			errs = append(errs, fmt.Sprintf("ticket %s: %s", n.TicketID, err))
+		}
+	}
+	if len(errs) > 0 {
+		return sent, fmt.Errorf("error posting notifications: %s", strings.Join(errs, ", "))
+	}
+	return sent, nil
+}
+
+// Pending returns the number of notifications waiting in the outbox
+func (d *Dispatcher) Pending(ctx context.Context) (int, error) {
+	pending, err := d.Store.Outbox(ctx, 0)
+	if err != nil {
+		return 0, fmt.Errorf("error reading the outbox: %s", err)
+	}
+	return len(pending), nil
+}
+
+// Run dispatches notifications every interval until ctx is cancelled
+func (d *Dispatcher) Run(ctx context.Context, interval time.Duration, errorf func(string, ...interface{})) {
+	tick := clock.Or(d.Clock).NewTicker(interval)
+	defer tick.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-tick.C():
+			if _, err := d.Dispatch(ctx); err != nil {
+				errorf("Dispatching notifications failed: %s", err)
+			}
+		}
+	}
+}
+
+// Thread returns a notification posting text in t's thread
+func Thread(t *ticket.Ticket, text string) *store.Notification {
+	return &store.Notification{TicketID: t.ID, ChannelID: t.ChannelID, ThreadTS: t.ThreadTS, Text: text}
+}
@@ -0,0 +1,67 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/nlopes/slack"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/skybet/go-helpdesk/mocks"
+	"github.com/skybet/go-helpdesk/store"
+	"github.com/skybet/go-helpdesk/ticket"
+)
+
+func TestDispatch(t *testing.T) {
+	s := store.NewMemory()
+	tk := &ticket.Ticket{ID: "1", ChannelID: "C1", ThreadTS: "1.1"}
+	s.Enqueue(context.Background(), Thread(tk, "assigned"))
+	s.Enqueue(context.Background(), &store.Notification{TicketID: "2", ChannelID: "C2", Text: "resolved"})
+	mockSlack := &mocks.SlackWrapper{}
+	var texts []string
+	mockSlack.On("PostMessage", "C1", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		_, values, _ := slack.UnsafeApplyMsgOptions("", "C1", "", args.Get(1).(slack.MsgOption), args.Get(2).(slack.MsgOption))
+		texts = append(texts, values.Get("thread_ts")+" "+values.Get("text"))
+	}).Return("C1", "1.2", nil)
+	mockSlack.On("PostMessage", "C2", mock.Anything).Return("", "", errors.New("channel_not_found"))
+	d := &Dispatcher{Store: s, Slack: mockSlack}
+
+	n, err := d.Dispatch(context.Background())
+	if n != 1 || err == nil {
+		t.Fatalf("Expected one notification to be posted and the other to fail, got %d %v", n, err)
+	}
+	if len(texts) != 1 || texts[0] != "1.1 assigned" {
+		t.Errorf("Expected the notification to be posted in the thread, got %q", texts)
+	}
+	pending, _ := s.Outbox(context.Background(), 0)
+	if len(pending) != 1 || pending[0].TicketID != "2" || pending[0].Attempts != 1 || pending[0].LastError != "channel_not_found" {
+		t.Fatalf("Expected the failed notification to be kept for a retry, got %+v", pending)
+	}
+
+	// It is dropped after MaxAttempts
+	for i := 1; i < MaxAttempts; i++ {
+		d.Dispatch(context.Background())
+	}
+	if pending, _ := s.Outbox(context.Background(), 0); len(pending) != 0 {
+		t.Errorf("Expected the notification to be dropped, got %+v", pending)
+	}
+	mockSlack.AssertNumberOfCalls(t, "PostMessage", 1+MaxAttempts)
+}
+
+func TestDispatchBatch(t *testing.T) {
+	s := store.NewMemory()
+	for i := 0; i < 3; i++ {
+		s.Enqueue(context.Background(), &store.Notification{ChannelID: "C1", Text: "hello"})
+	}
+	mockSlack := &mocks.SlackWrapper{}
+	mockSlack.On("PostMessage", "C1", mock.Anything).Return("C1", "1.2", nil)
+	d := &Dispatcher{Store: s, Slack: mockSlack, Batch: 2}
+
+	if n, err := d.Dispatch(context.Background()); n != 2 || err != nil {
+		t.Errorf("Expected a batch of 2 to be posted, got %d %v", n, err)
+	}
+	if n, err := d.Pending(context.Background()); n != 1 || err != nil {
+		t.Errorf("Expected 1 notification left, got %d %v", n, err)
+	}
+}
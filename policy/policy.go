@@ -0,0 +1,117 @@
+// Package policy decides who may take sensitive actions, such as exporting or
+// deleting tickets. By default the helpdesk admins may take every one, an
+// Open Policy Agent server can be consulted instead to keep the rules with the
+// rest of an organisation's policy.
+package policy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// The actions decisions are made about
+const (
+	Announce  = "announce"
+	BulkClose = "bulk_close"
+	Export    = "export"
+	Erase     = "erase"
+	Approve   = "approve"
+	Delete    = "delete"
+	Restore   = "restore"
+	ViewTrash = "view_trash"
+	Debug     = "debug"
+	SetWIP    = "set_wip"
+	Provision = "provision"
+	Audit     = "audit"
+	Search    = "search"
+	Access    = "access"
+)
+
+// Input is what a decision is made about
+type Input struct {
+	Action string `json:"action"`
+	User   string `json:"user"`
+	// Admin is set when the user is one of the helpdesk admins, policies can
+	// build on the existing configuration rather than repeat it
+	Admin bool `json:"admin"`
+}
+
+// Decider decides whether an action is allowed
+type Decider interface {
+	Allowed(ctx context.Context, in Input) (bool, error)
+}
+
+// Admins allows the helpdesk admins to take every action, and nobody else
+type Admins struct{}
+
+// Allowed satisfies Decider
+func (Admins) Allowed(ctx context.Context, in Input) (bool, error) {
+	return in.Admin, nil
+}
+
+// Grants restricts actions to the users they are granted to, who must also be
+// allowed the action by Decider. Actions without grants are left to Decider.
+type Grants struct {
+	Decider Decider
+	// Users are the users granted each action
+	Users map[string][]string
+}
+
+// Allowed satisfies Decider
+func (g Grants) Allowed(ctx context.Context, in Input) (bool, error) {
+	if users, ok := g.Users[in.Action]; ok {
+		granted := false
+		for _, u := range users {
+			granted = granted || u == in.User
+		}
+		if !granted {
+			return false, nil
+		}
+	}
+	return g.Decider.Allowed(ctx, in)
+}
+
+// OPA asks an Open Policy Agent server for decisions through its Data API.
+// URL is the document holding the decision, such as
+// http://localhost:8181/v1/data/helpdesk/allow, which must be true for the
+// action to be allowed. An undefined document denies it.
+type OPA struct {
+	URL    string
+	Client *http.Client
+}
+
+// Allowed satisfies Decider
+func (o *OPA) Allowed(ctx context.Context, in Input) (bool, error) {
+	body, err := json.Marshal(map[string]Input{"input": in})
+	if err != nil {
+		return false, err
+	}
+	req, err := http.NewRequest(http.MethodPost, o.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+	client := o.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return false, fmt.Errorf("error querying policy: %s", err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("error querying policy: %s", res.Status)
+	}
+	var decision struct {
+		Result *bool `json:"result"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&decision); err != nil {
+		return false, fmt.Errorf("error decoding policy decision: %s", err)
+	}
+	return decision.Result != nil && *decision.Result, nil
+}
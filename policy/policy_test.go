@@ -0,0 +1,79 @@
+package policy
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestOPA(t *testing.T) {
+	var got Input
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/data/helpdesk/allow" {
+			http.NotFound(w, r)
+			return
+		}
+		var body struct {
+			Input Input `json:"input"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		got = body.Input
+		switch body.Input.User {
+		case "U1":
+			w.Write([]byte(`{"result": true}`))
+		case "U2":
+			w.Write([]byte(`{"result": false}`))
+		case "U3":
+			// The document is undefined for this input
+			w.Write([]byte(`{}`))
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer srv.Close()
+	o := &OPA{URL: srv.URL + "/v1/data/helpdesk/allow"}
+
+	in := Input{Action: Export, User: "U1", Admin: true}
+	if ok, err := o.Allowed(context.Background(), in); !ok || err != nil {
+		t.Errorf("Expected U1 to be allowed, got %v %v", ok, err)
+	}
+	if got != in {
+		t.Errorf("Expected the input to be sent to OPA, got %+v", got)
+	}
+	for _, user := range []string{"U2", "U3"} {
+		if ok, err := o.Allowed(context.Background(), Input{Action: Export, User: user}); ok || err != nil {
+			t.Errorf("Expected %s to be denied, got %v %v", user, ok, err)
+		}
+	}
+	if ok, err := o.Allowed(context.Background(), Input{Action: Export, User: "U4"}); ok || err == nil {
+		t.Errorf("Expected an error when OPA fails, got %v %v", ok, err)
+	}
+}
+
+func TestAdmins(t *testing.T) {
+	if ok, _ := (Admins{}).Allowed(context.Background(), Input{Action: Delete, User: "U1", Admin: true}); !ok {
+		t.Errorf("Expected admins to be allowed")
+	}
+	if ok, _ := (Admins{}).Allowed(context.Background(), Input{Action: Delete, User: "U2"}); ok {
+		t.Errorf("Expected other users to be denied")
+	}
+}
+
+func TestGrants(t *testing.T) {
+	g := Grants{Decider: Admins{}, Users: map[string][]string{Export: {"U1", "U3"}}}
+	for _, tc := range []struct {
+		in   Input
+		want bool
+	}{
+		{Input{Action: Export, User: "U1", Admin: true}, true},
+		{Input{Action: Export, User: "U2", Admin: true}, false},
+		{Input{Action: Export, User: "U3"}, false},
+		{Input{Action: Delete, User: "U2", Admin: true}, true},
+	} {
+		if ok, err := g.Allowed(context.Background(), tc.in); ok != tc.want || err != nil {
+			t.Errorf("Expected %+v to be allowed %t, got %t, %v", tc.in, tc.want, ok, err)
+		}
+	}
+}
@@ -0,0 +1,236 @@
+// Package preview previews the files attached to tickets on their cards: a
+// snippet of the start of each text file, and a thumbnail of each image which
+// is served to Slack by the Handler
+package preview
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"image"
+	// Register the formats Slack shows inline so they can be decoded
+	_ "image/gif"
+	_ "image/jpeg"
+	"image/png"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/nlopes/slack/slackevents"
+
+	"github.com/skybet/go-helpdesk/store"
+	"github.com/skybet/go-helpdesk/ticket"
+)
+
+// maxPixels is the most pixels an image may have to be thumbnailed, so that a
+// small file with huge dimensions can not exhaust memory
+const maxPixels = 40 << 20
+
+// Limits bound what is previewed
+type Limits struct {
+	// MaxBytes is the largest file previewed
+	MaxBytes int
+	// Lines and Chars are the most of a text file shown in its snippet
+	Lines int
+	Chars int
+	// Size is the longest side of a thumbnail in pixels
+	Size int
+	// Files is the most files previewed on a card
+	Files int
+}
+
+// DefaultLimits preview the first 3 files of up to 5MB, with snippets of 10
+// lines and thumbnails 360 pixels across
+var DefaultLimits = Limits{MaxBytes: 5 << 20, Lines: 10, Chars: 1000, Size: 360, Files: 3}
+
+// Downloader is the part of the Slack API used to read files
+type Downloader interface {
+	DownloadFile(url string, w io.Writer) error
+}
+
+// Previews makes the previews of files within its Limits
+type Previews struct {
+	Limits Limits
+	// BaseURL is where the Handler is mounted, e.g.
+	// https://helpdesk.example.com/previews, images are not previewed
+	// without it as Slack has to fetch their thumbnails
+	BaseURL string
+
+	secret []byte
+}
+
+// New returns previews whose thumbnail links are served from baseURL and
+// signed with secret
+func New(baseURL, secret string, l Limits) *Previews {
+	return &Previews{Limits: l, BaseURL: strings.TrimSuffix(baseURL, "/"), secret: []byte(secret)}
+}
+
+// Image reports whether a MIME type is an image which can be thumbnailed
+func Image(mimetype string) bool {
+	switch mimetype {
+	case "image/png", "image/jpeg", "image/gif":
+		return true
+	}
+	return false
+}
+
+// Text reports whether a MIME type is text which can be shown in a snippet
+func Text(mimetype string) bool {
+	switch mimetype {
+	case "application/json", "application/xml", "application/x-yaml", "application/x-sh":
+		return true
+	}
+	return strings.HasPrefix(mimetype, "text/")
+}
+
+// Attach returns the attachments for files shared in Slack, downloading the
+// text files within the limits for their snippets. Files which fail to
+// download are still attached, without a snippet.
+func (p *Previews) Attach(files []slackevents.File, d Downloader) ([]ticket.Attachment, error) {
+	var attachments []ticket.Attachment
+	var errs []string
+	for _, f := range files {
+		a := ticket.Attachment{ID: f.ID, Name: f.Name, Mimetype: f.Mimetype, Size: f.Size, URL: f.URLPrivateDownload, Permalink: f.Permalink}
+		if Text(f.Mimetype) && f.Size <= p.Limits.MaxBytes && f.URLPrivateDownload != "" {
+			var buf bytes.Buffer
+			if err := d.DownloadFile(f.URLPrivateDownload, &buf); err != nil {
+				errs = append(errs, fmt.Sprintf("%s: %s", f.ID, err))
+			} else {
+				a.Snippet, a.Truncated = Snippet(buf.Bytes(), p.Limits)
+			}
+		}
+		attachments = append(attachments, a)
+	}
+	if len(errs) > 0 {
+		return attachments, fmt.Errorf("error downloading files: %s", strings.Join(errs, ", "))
+	}
+	return attachments, nil
+}
+
+// Snippet returns the start of a text file within the limits' lines and
+// characters, and whether it was cut short. Binary content has no snippet.
+// Backticks which would close the code block the snippet is shown in are
+// replaced.
+func Snippet(content []byte, l Limits) (string, bool) {
+	if !utf8.Valid(content) || bytes.IndexByte(content, 0) >= 0 {
+		return "", false
+	}
+	text := strings.TrimRight(strings.Replace(string(content), "\r\n", "\n", -1), "\n")
+	truncated := false
+	if lines := strings.SplitN(text, "\n", l.Lines+1); l.Lines > 0 && len(lines) > l.Lines {
+		text, truncated = strings.Join(lines[:l.Lines], "\n"), true
+	}
+	if l.Chars > 0 && utf8.RuneCountInString(text) > l.Chars {
+		text, truncated = string([]rune(text)[:l.Chars]), true
+	}
+	return strings.Replace(text, "```", "'''", -1), truncated
+}
+
+// Thumbnail scales an image down so that its longest side is at most size
+// pixels, returning it as a PNG
+func Thumbnail(content []byte, size int) ([]byte, error) {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(content))
+	if err != nil {
+		return nil, fmt.Errorf("error reading image: %s", err)
+	}
+	if cfg.Width*cfg.Height > maxPixels {
+		return nil, fmt.Errorf("image is too large at %dx%d", cfg.Width, cfg.Height)
+	}
+	src, _, err := image.Decode(bytes.NewReader(content))
+	if err != nil {
+		return nil, fmt.Errorf("error decoding image: %s", err)
+	}
+	b := src.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w > size || h > size {
+		if w >= h {
+			w, h = size, h*size/w
+		} else {
+			w, h = w*size/h, size
+		}
+	}
+	if w < 1 {
+		w = 1
+	}
+	if h < 1 {
+		h = 1
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			dst.Set(x, y, src.At(b.Min.X+x*b.Dx()/w, b.Min.Y+y*b.Dy()/h))
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, dst); err != nil {
+		return nil, fmt.Errorf("error encoding thumbnail: %s", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// ImageURL returns the signed link to the thumbnail of an image attached to
+// a ticket, empty if it is not previewed
+func (p *Previews) ImageURL(t *ticket.Ticket, a ticket.Attachment) string {
+	if p == nil || p.BaseURL == "" || !Image(a.Mimetype) || a.Size > p.Limits.MaxBytes {
+		return ""
+	}
+	return fmt.Sprintf("%s/%s/%s?sig=%s", p.BaseURL, url.PathEscape(t.ID), url.PathEscape(a.ID), p.sign(t.ID, a.ID))
+}
+
+func (p *Previews) sign(ticketID, fileID string) string {
+	mac := hmac.New(sha256.New, p.secret)
+	fmt.Fprintf(mac, "preview:%s/%s", ticketID, fileID)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Handler serves the thumbnails linked to by ImageURL, downloading the image
+// from Slack with d. Mount it at BaseURL with http.StripPrefix.
+func (p *Previews) Handler(s store.Store, d Downloader) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/"), "/")
+		if len(parts) != 2 || !hmac.Equal([]byte(r.URL.Query().Get("sig")), []byte(p.sign(parts[0], parts[1]))) {
+			http.NotFound(w, r)
+			return
+		}
+		t, err := s.GetTicket(r.Context(), parts[0])
+		if err == store.ErrNotFound {
+			http.NotFound(w, r)
+			return
+		}
+		if err != nil {
+			http.Error(w, "error getting ticket", http.StatusInternalServerError)
+			return
+		}
+		var found *ticket.Attachment
+		for i, a := range t.Attachments {
+			if a.ID == parts[1] {
+				found = &t.Attachments[i]
+			}
+		}
+		if found == nil || p.ImageURL(t, *found) == "" {
+			http.NotFound(w, r)
+			return
+		}
+		var buf bytes.Buffer
+		if err := d.DownloadFile(found.URL, &buf); err != nil {
+			http.Error(w, "error downloading image", http.StatusBadGateway)
+			return
+		}
+		thumb, err := Thumbnail(buf.Bytes(), p.Limits.Size)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+		w.Header().Set("Content-Type", "image/png")
+		w.Header().Set("Cache-Control", "private, max-age=86400")
+		w.Write(thumb)
+	})
+}
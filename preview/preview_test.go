@@ -0,0 +1,130 @@
+package preview
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/nlopes/slack/slackevents"
+
+	"github.com/skybet/go-helpdesk/store"
+	"github.com/skybet/go-helpdesk/ticket"
+)
+
+// fakeDownloader serves files by their URL
+type fakeDownloader map[string][]byte
+
+func (f fakeDownloader) DownloadFile(url string, w io.Writer) error {
+	content, ok := f[url]
+	if !ok {
+		return fmt.Errorf("file_not_found")
+	}
+	_, err := w.Write(content)
+	return err
+}
+
+func testImage(t *testing.T, w, h int) []byte {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	img.Set(0, 0, color.White)
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestSnippet(t *testing.T) {
+	l := Limits{Lines: 2, Chars: 12}
+	for _, tt := range []struct {
+		content   string
+		want      string
+		truncated bool
+	}{
+		{"one\r\ntwo\n", "one\ntwo", false},
+		{"one\ntwo\nthree", "one\ntwo", true},
+		{"a long line of text", "a long line ", true},
+		{"```sh\nrm```", "'''sh\nrm'''", false},
+		{"\x00\x01binary", "", false},
+	} {
+		if got, truncated := Snippet([]byte(tt.content), l); got != tt.want || truncated != tt.truncated {
+			t.Errorf("Expected the snippet of %q to be %q (truncated %t), got %q (%t)", tt.content, tt.want, tt.truncated, got, truncated)
+		}
+	}
+}
+
+func TestThumbnail(t *testing.T) {
+	thumb, err := Thumbnail(testImage(t, 1200, 600), 360)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	cfg, err := png.DecodeConfig(bytes.NewReader(thumb))
+	if err != nil || cfg.Width != 360 || cfg.Height != 180 {
+		t.Errorf("Expected a 360x180 thumbnail, got %+v (%v)", cfg, err)
+	}
+	if _, err := Thumbnail([]byte("not an image"), 360); err == nil {
+		t.Errorf("Expected an error for content which is not an image")
+	}
+}
+
+func TestAttach(t *testing.T) {
+	p := New("", "secret", Limits{MaxBytes: 100, Lines: 1, Chars: 100, Files: 3})
+	d := fakeDownloader{"https://files/F1": []byte("panic: nil map\ngoroutine 1")}
+	attachments, err := p.Attach([]slackevents.File{
+		{ID: "F1", Name: "crash.log", Mimetype: "text/plain", Size: 26, URLPrivateDownload: "https://files/F1"},
+		{ID: "F2", Name: "huge.log", Mimetype: "text/plain", Size: 1000, URLPrivateDownload: "https://files/F2"},
+		{ID: "F3", Name: "missing.txt", Mimetype: "text/plain", Size: 10, URLPrivateDownload: "https://files/F3"},
+	}, d)
+	if err == nil || !strings.Contains(err.Error(), "F3") {
+		t.Errorf("Expected the failed download to be reported, got %v", err)
+	}
+	if len(attachments) != 3 || attachments[0].Snippet != "panic: nil map" || !attachments[0].Truncated || attachments[1].Snippet != "" || attachments[2].Snippet != "" {
+		t.Errorf("Expected every file attached with a snippet of the small one, got %+v", attachments)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	s := store.NewMemory()
+	tk := &ticket.Ticket{ID: "1", Attachments: []ticket.Attachment{
+		{ID: "F1", Name: "vpn.png", Mimetype: "image/png", Size: 100, URL: "https://files/F1"},
+		{ID: "F2", Name: "log.txt", Mimetype: "text/plain", Size: 10, URL: "https://files/F2"},
+	}}
+	s.CreateTicket(context.Background(), tk)
+	p := New("https://helpdesk.example.com/previews/", "secret", DefaultLimits)
+	h := http.StripPrefix("/previews", p.Handler(s, fakeDownloader{"https://files/F1": testImage(t, 720, 720)}))
+
+	u := p.ImageURL(tk, tk.Attachments[0])
+	if !strings.HasPrefix(u, "https://helpdesk.example.com/previews/1/F1?sig=") {
+		t.Fatalf("Unexpected image URL %q", u)
+	}
+	if p.ImageURL(tk, tk.Attachments[1]) != "" {
+		t.Errorf("Expected text files not to have an image URL")
+	}
+	for _, tt := range []struct {
+		path string
+		code int
+	}{
+		{strings.TrimPrefix(u, "https://helpdesk.example.com"), http.StatusOK},
+		{"/previews/1/F1?sig=forged", http.StatusNotFound},
+		{"/previews/1/F2?sig=" + p.sign("1", "F2"), http.StatusNotFound},
+		{"/previews/2/F1?sig=" + p.sign("2", "F1"), http.StatusNotFound},
+	} {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("GET", tt.path, nil))
+		if w.Code != tt.code {
+			t.Errorf("Expected %s to return %d, got %d", tt.path, tt.code, w.Code)
+		}
+		if tt.code == http.StatusOK {
+			if cfg, err := png.DecodeConfig(w.Body); err != nil || cfg.Width != 360 {
+				t.Errorf("Expected a 360 pixel thumbnail, got %+v (%v)", cfg, err)
+			}
+		}
+	}
+}
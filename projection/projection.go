@@ -0,0 +1,229 @@
+// Package projection maintains read models of the tickets, such as the number
+// open in each queue, so that dashboards and reports do not have to list every
+// ticket in the store. The models are fed with every ticket written through a
+// wrapped store and updated in the background.
+package projection
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/skybet/go-helpdesk/sla"
+	"github.com/skybet/go-helpdesk/store"
+	"github.com/skybet/go-helpdesk/ticket"
+)
+
+// Queue counts the open tickets in a queue
+type Queue struct {
+	Open int `json:"open"`
+	// Assigned is the open tickets with an assignee, the queue's work in
+	// progress
+	Assigned int `json:"assigned"`
+}
+
+// Projector keeps the read models up to date with the tickets written through
+// the stores it wraps
+type Projector struct {
+	// SLA and Warn decide which tickets are at risk, those within Warn of
+	// breaching a target
+	SLA  sla.SLA
+	Warn time.Duration
+
+	mu      sync.RWMutex
+	seen    map[string]int
+	open    map[string]*ticket.Ticket
+	created map[string]*ticket.Ticket
+	queues  map[string]Queue
+	agents  map[string]int
+
+	pendingMu sync.Mutex
+	pending   []*ticket.Ticket
+	wake      chan struct{}
+}
+
+// New returns an empty Projector, use Load to fill it from the store
+func New(s sla.SLA, warn time.Duration) *Projector {
+	return &Projector{
+		SLA:     s,
+		Warn:    warn,
+		seen:    map[string]int{},
+		open:    map[string]*ticket.Ticket{},
+		created: map[string]*ticket.Ticket{},
+		queues:  map[string]Queue{},
+		agents:  map[string]int{},
+		wake:    make(chan struct{}, 1),
+	}
+}
+
+// Load applies every ticket in s straight away
+func (p *Projector) Load(ctx context.Context, s store.Store) error {
+	f := store.Filter{Limit: 500}
+	for {
+		page, next, err := s.ListTickets(ctx, f)
+		if err != nil {
+			return fmt.Errorf("error listing tickets: %s", err)
+		}
+		for _, t := range page {
+			p.apply(t)
+		}
+		if next == "" {
+			return nil
+		}
+		f.Cursor = next
+	}
+}
+
+// Run applies the tickets written through the wrapped stores until ctx is
+// cancelled
+func (p *Projector) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-p.wake:
+			p.drain()
+		}
+	}
+}
+
+// publish queues tickets to be applied by Run, it never blocks the writer
+func (p *Projector) publish(ts ...*ticket.Ticket) {
+	if len(ts) == 0 {
+		return
+	}
+	p.pendingMu.Lock()
+	p.pending = append(p.pending, ts...)
+	p.pendingMu.Unlock()
+	select {
+	case p.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Pending returns the number of written tickets waiting to be applied
+func (p *Projector) Pending() int {
+	p.pendingMu.Lock()
+	defer p.pendingMu.Unlock()
+	return len(p.pending)
+}
+
+func (p *Projector) drain() {
+	p.pendingMu.Lock()
+	ts := p.pending
+	p.pending = nil
+	p.pendingMu.Unlock()
+	for _, t := range ts {
+		p.apply(t)
+	}
+}
+
+// apply replaces the read models' view of a ticket. Writes committed at the
+// same time can be published out of order, so older versions are ignored.
+// Tickets in the trash are left out until they are restored.
+func (p *Projector) apply(t *ticket.Ticket) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if seen, ok := p.seen[t.ID]; ok && t.Version < seen {
+		return
+	}
+	p.seen[t.ID] = t.Version
+	if old, ok := p.open[t.ID]; ok {
+		p.count(old, -1)
+		delete(p.open, t.ID)
+	}
+	if t.Deleted() {
+		delete(p.created, t.ID)
+		return
+	}
+	if t.Status.Open() {
+		p.open[t.ID] = t.Copy()
+		p.count(t, 1)
+	}
+	p.created[t.ID] = &ticket.Ticket{ID: t.ID, Queue: t.Queue, CreatedAt: t.CreatedAt}
+}
+
+func (p *Projector) count(t *ticket.Ticket, n int) {
+	q := p.queues[t.Queue]
+	q.Open += n
+	if t.Assignee != "" {
+		q.Assigned += n
+		p.agents[t.Assignee] += n
+		if p.agents[t.Assignee] == 0 {
+			delete(p.agents, t.Assignee)
+		}
+	}
+	if q.Open == 0 {
+		delete(p.queues, t.Queue)
+	} else {
+		p.queues[t.Queue] = q
+	}
+}
+
+// Queues returns the open tickets in each queue with any
+func (p *Projector) Queues() map[string]Queue {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	qs := make(map[string]Queue, len(p.queues))
+	for name, q := range p.queues {
+		qs[name] = q
+	}
+	return qs
+}
+
+// Workload returns the number of open tickets assigned to each agent
+func (p *Projector) Workload() map[string]int {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	agents := make(map[string]int, len(p.agents))
+	for a, n := range p.agents {
+		agents[a] = n
+	}
+	return agents
+}
+
+// Statuses returns the number of open tickets in each status with any
+func (p *Projector) Statuses() map[ticket.Status]int {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	statuses := map[ticket.Status]int{}
+	for _, t := range p.open {
+		statuses[t.Status]++
+	}
+	return statuses
+}
+
+// AtRisk returns the open tickets which have breached a target or will within
+// Warn of now, soonest due first
+func (p *Projector) AtRisk(now time.Time) []sla.Risk {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	var risks []sla.Risk
+	for _, t := range p.open {
+		if r, ok := p.SLA.AtRisk(t, now, p.Warn); ok {
+			r.Ticket = t.Copy()
+			risks = append(risks, r)
+		}
+	}
+	sort.Slice(risks, func(i, j int) bool {
+		if !risks[i].Due.Equal(risks[j].Due) {
+			return risks[i].Due.Before(risks[j].Due)
+		}
+		return risks[i].Ticket.ID < risks[j].Ticket.ID
+	})
+	return risks
+}
+
+// Volume returns every ticket with only its ID, queue and creation time, which
+// is all forecasts need
+func (p *Projector) Volume() []*ticket.Ticket {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	ts := make([]*ticket.Ticket, 0, len(p.created))
+	for _, t := range p.created {
+		ts = append(ts, t.Copy())
+	}
+	return ts
+}
@@ -0,0 +1,110 @@
+package projection
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/skybet/go-helpdesk/sla"
+	"github.com/skybet/go-helpdesk/store"
+	"github.com/skybet/go-helpdesk/ticket"
+)
+
+func TestProjection(t *testing.T) {
+	ctx := context.Background()
+	p := New(sla.SLA{}, 0)
+	s := p.Wrap(store.NewMemory())
+
+	a := &ticket.Ticket{Queue: "it", Status: ticket.StatusNew}
+	b := &ticket.Ticket{Queue: "it", Status: ticket.StatusNew}
+	c := &ticket.Ticket{Queue: "hr", Status: ticket.StatusNew}
+	for _, tk := range []*ticket.Ticket{a, b, c} {
+		if err := s.CreateTicket(ctx, tk); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+	}
+	a.Assignee = "U1"
+	a.SetStatus(ticket.StatusInProgress, time.Now())
+	s.UpdateTicket(ctx, a)
+	c.SetStatus(ticket.StatusResolved, time.Now())
+	s.UpdateTicket(ctx, c)
+	p.drain()
+
+	qs := p.Queues()
+	if len(qs) != 1 || qs["it"] != (Queue{Open: 2, Assigned: 1}) {
+		t.Errorf("Expected 2 open tickets in it with 1 assigned, got %+v", qs)
+	}
+	if w := p.Workload(); len(w) != 1 || w["U1"] != 1 {
+		t.Errorf("Expected U1 to have 1 ticket, got %v", w)
+	}
+	if v := p.Volume(); len(v) != 3 {
+		t.Errorf("Expected every ticket in the volume, got %d", len(v))
+	}
+	if st := p.Statuses(); len(st) != 2 || st[ticket.StatusNew] != 1 || st[ticket.StatusInProgress] != 1 {
+		t.Errorf("Expected 1 new and 1 in progress ticket, got %v", st)
+	}
+
+	a.SetStatus(ticket.StatusResolved, time.Now())
+	s.UpdateTicket(ctx, a)
+	p.drain()
+	if qs := p.Queues(); qs["it"] != (Queue{Open: 1}) {
+		t.Errorf("Expected resolving the assigned ticket to leave 1 open, got %+v", qs)
+	}
+	if w := p.Workload(); len(w) != 0 {
+		t.Errorf("Expected no workload, got %v", w)
+	}
+
+	b.DeletedAt = time.Now()
+	s.UpdateTicket(ctx, b)
+	p.drain()
+	if qs := p.Queues(); len(qs) != 0 {
+		t.Errorf("Expected tickets in the trash to be left out, got %+v", qs)
+	}
+	if v := p.Volume(); len(v) != 2 {
+		t.Errorf("Expected tickets in the trash to be left out of the volume, got %d", len(v))
+	}
+}
+
+func TestProjectionLoad(t *testing.T) {
+	ctx := context.Background()
+	s := store.NewMemory()
+	for i := 0; i < 1200; i++ {
+		s.CreateTicket(ctx, &ticket.Ticket{Queue: fmt.Sprintf("q%d", i%3), Status: ticket.StatusNew})
+	}
+	p := New(sla.SLA{}, 0)
+	if err := p.Load(ctx, s); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if qs := p.Queues(); len(qs) != 3 || qs["q0"].Open != 400 {
+		t.Errorf("Expected every page to be loaded, got %+v", qs)
+	}
+}
+
+func TestProjectionIgnoresStale(t *testing.T) {
+	p := New(sla.SLA{}, 0)
+	p.publish(
+		&ticket.Ticket{ID: "1", Queue: "it", Status: ticket.StatusResolved, Version: 2},
+		&ticket.Ticket{ID: "1", Queue: "it", Status: ticket.StatusNew, Version: 1},
+	)
+	p.drain()
+	if qs := p.Queues(); len(qs) != 0 {
+		t.Errorf("Expected the older version to be ignored, got %+v", qs)
+	}
+}
+
+func TestProjectionAtRisk(t *testing.T) {
+	now := time.Now()
+	p := New(sla.SLA{Response: sla.Targets{ticket.P1: 15 * time.Minute, 0: 8 * time.Hour}}, 10*time.Minute)
+	p.apply(&ticket.Ticket{ID: "1", Priority: ticket.P1, Status: ticket.StatusNew, CreatedAt: now.Add(-10 * time.Minute)})
+	p.apply(&ticket.Ticket{ID: "2", Priority: ticket.P1, Status: ticket.StatusNew, CreatedAt: now.Add(-20 * time.Minute)})
+	p.apply(&ticket.Ticket{ID: "3", Priority: ticket.P3, Status: ticket.StatusNew, CreatedAt: now})
+
+	risks := p.AtRisk(now)
+	if len(risks) != 2 || risks[0].Ticket.ID != "2" || risks[1].Ticket.ID != "1" {
+		t.Fatalf("Expected tickets 2 and 1 to be at risk, got %+v", risks)
+	}
+	if !risks[0].Breached(now) || risks[1].Breached(now) {
+		t.Errorf("Expected only ticket 2 to have breached")
+	}
+}
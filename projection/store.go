@@ -0,0 +1,59 @@
+package projection
+
+import (
+	"context"
+
+	"github.com/skybet/go-helpdesk/store"
+	"github.com/skybet/go-helpdesk/ticket"
+)
+
+// Wrap returns a store which writes to s and publishes every ticket it writes
+// to p once the write has been committed
+func (p *Projector) Wrap(s store.Store) store.Store {
+	return &publisher{Store: s, publish: p.publish}
+}
+
+// publisher publishes each write as soon as the wrapped store returns
+type publisher struct {
+	store.Store
+	publish func(...*ticket.Ticket)
+}
+
+func (s *publisher) CreateTicket(ctx context.Context, t *ticket.Ticket) error {
+	if err := s.Store.CreateTicket(ctx, t); err != nil {
+		return err
+	}
+	s.publish(t.Copy())
+	return nil
+}
+
+func (s *publisher) UpdateTicket(ctx context.Context, t *ticket.Ticket) error {
+	if err := s.Store.UpdateTicket(ctx, t); err != nil {
+		return err
+	}
+	s.publish(t.Copy())
+	return nil
+}
+
+func (s *publisher) Transition(ctx context.Context, id string, from, to ticket.Status) (*ticket.Ticket, error) {
+	t, err := s.Store.Transition(ctx, id, from, to)
+	if err != nil {
+		return nil, err
+	}
+	s.publish(t.Copy())
+	return t, nil
+}
+
+// Tx holds back the transaction's writes until it commits, they are dropped
+// if it fails
+func (s *publisher) Tx(ctx context.Context, fn func(s store.Store) error) error {
+	var written []*ticket.Ticket
+	err := s.Store.Tx(ctx, func(tx store.Store) error {
+		return fn(&publisher{Store: tx, publish: func(ts ...*ticket.Ticket) { written = append(written, ts...) }})
+	})
+	if err != nil {
+		return err
+	}
+	s.publish(written...)
+	return nil
+}
@@ -0,0 +1,50 @@
+package projection
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/skybet/go-helpdesk/sla"
+	"github.com/skybet/go-helpdesk/store"
+	"github.com/skybet/go-helpdesk/ticket"
+)
+
+func TestWrapTx(t *testing.T) {
+	ctx := context.Background()
+	p := New(sla.SLA{}, 0)
+	s := p.Wrap(store.NewMemory())
+
+	err := s.Tx(ctx, func(tx store.Store) error {
+		if err := tx.CreateTicket(ctx, &ticket.Ticket{Queue: "it", Status: ticket.StatusNew}); err != nil {
+			return err
+		}
+		return errors.New("rolled back")
+	})
+	if err == nil {
+		t.Fatalf("Expected the transaction to fail")
+	}
+	p.drain()
+	if qs := p.Queues(); len(qs) != 0 {
+		t.Errorf("Expected a failed transaction to publish nothing, got %+v", qs)
+	}
+
+	err = s.Tx(ctx, func(tx store.Store) error {
+		if err := tx.CreateTicket(ctx, &ticket.Ticket{Queue: "it", Status: ticket.StatusNew}); err != nil {
+			return err
+		}
+		// Not applied until the transaction commits
+		p.drain()
+		if qs := p.Queues(); len(qs) != 0 {
+			t.Errorf("Expected nothing to be published before the commit, got %+v", qs)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	p.drain()
+	if qs := p.Queues(); qs["it"].Open != 1 {
+		t.Errorf("Expected the committed ticket to be published, got %+v", qs)
+	}
+}
@@ -0,0 +1,178 @@
+// Package provision onboards a queue by setting up its triage channel: the
+// channel is created if it does not exist, the queue's usergroup is invited,
+// its topic and purpose are set and the dashboard is posted and pinned. The
+// channel is recorded in the store, so running it again only fills in what is
+// missing.
+package provision
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/nlopes/slack"
+
+	"github.com/skybet/go-helpdesk/store"
+)
+
+// Slack is the part of the Slack API used to set up channels
+type Slack interface {
+	CreateChannel(name string) (*slack.Channel, error)
+	InviteUsers(channelID string, users ...string) error
+	SetTopic(channelID, topic string) error
+	SetPurpose(channelID, purpose string) error
+	UsergroupMembers(usergroup string) ([]string, error)
+	PostMessage(channelID string, options ...slack.MsgOption) (string, string, error)
+	AddPin(channel string, item slack.ItemRef) error
+}
+
+// Channels lists the workspace's channels, to find a triage channel which
+// already exists
+type Channels interface {
+	Channels(ctx context.Context) ([]slack.Channel, error)
+}
+
+// Queue describes a queue to onboard
+type Queue struct {
+	// Name is the queue's name
+	Name string
+	// Channel is the name of its triage channel, without the #
+	Channel string
+	// Usergroup is the ID of the usergroup working the queue, whose members
+	// are invited to the channel, empty to invite nobody
+	Usergroup string
+	// Topic and Purpose are set on the channel, empty ones are left alone
+	Topic   string
+	Purpose string
+}
+
+// Validate returns why q cannot be provisioned, nil if it can
+func (q Queue) Validate() error {
+	if q.Name == "" {
+		return fmt.Errorf("the queue has no name")
+	}
+	if q.Channel == "" {
+		return fmt.Errorf("the queue has no channel")
+	}
+	if q.Channel != strings.ToLower(q.Channel) || strings.ContainsAny(q.Channel, " #.") || len(q.Channel) > 80 {
+		return fmt.Errorf("%q is not a valid channel name, use up to 80 lowercase letters, numbers, hyphens and underscores", q.Channel)
+	}
+	return nil
+}
+
+// Result is what provisioning a queue did
+type Result struct {
+	*store.QueueChannel
+	// Created is whether the channel was created, rather than found
+	Created bool
+	// Invited is the number of the usergroup's members invited
+	Invited int
+}
+
+// Provisioner sets up queues' triage channels
+type Provisioner struct {
+	Store    store.Store
+	Slack    Slack
+	Channels Channels
+	// Dashboard renders the dashboard posted and pinned in new channels
+	Dashboard func(ctx context.Context) ([]slack.MsgOption, error)
+}
+
+// Provision sets up q's triage channel for user at now and records it in the
+// store. A queue which already has a channel keeps it, and its dashboard is
+// only posted again if the queue moves to a different channel.
+func (p *Provisioner) Provision(ctx context.Context, q Queue, user string, now time.Time) (*Result, error) {
+	if err := q.Validate(); err != nil {
+		return nil, err
+	}
+	existing, err := p.recorded(ctx, q.Name)
+	if err != nil {
+		return nil, err
+	}
+	r := &Result{QueueChannel: &store.QueueChannel{Queue: q.Name, ProvisionedBy: user, ProvisionedAt: now}}
+	if r.ChannelID, err = p.find(ctx, q.Channel); err != nil {
+		return nil, err
+	}
+	if r.ChannelID == "" {
+		ch, err := p.Slack.CreateChannel(q.Channel)
+		if err != nil {
+			return nil, fmt.Errorf("error creating #%s: %s", q.Channel, err)
+		}
+		r.ChannelID, r.Created = ch.ID, true
+	}
+
+	if q.Usergroup != "" {
+		members, err := p.Slack.UsergroupMembers(q.Usergroup)
+		if err != nil {
+			return nil, fmt.Errorf("error listing the members of %s: %s", q.Usergroup, err)
+		}
+		if len(members) > 0 {
+			if err := p.Slack.InviteUsers(r.ChannelID, members...); err != nil {
+				return nil, fmt.Errorf("error inviting %s to #%s: %s", q.Usergroup, q.Channel, err)
+			}
+			r.Invited = len(members)
+		}
+	}
+	if q.Topic != "" {
+		if err := p.Slack.SetTopic(r.ChannelID, q.Topic); err != nil {
+			return nil, fmt.Errorf("error setting the topic of #%s: %s", q.Channel, err)
+		}
+	}
+	if q.Purpose != "" {
+		if err := p.Slack.SetPurpose(r.ChannelID, q.Purpose); err != nil {
+			return nil, fmt.Errorf("error setting the purpose of #%s: %s", q.Channel, err)
+		}
+	}
+
+	if existing != nil && existing.ChannelID == r.ChannelID && existing.DashboardTS != "" {
+		r.DashboardTS = existing.DashboardTS
+	} else if p.Dashboard != nil {
+		options, err := p.Dashboard(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("error building the dashboard: %s", err)
+		}
+		if _, r.DashboardTS, err = p.Slack.PostMessage(r.ChannelID, options...); err != nil {
+			return nil, fmt.Errorf("error posting the dashboard in #%s: %s", q.Channel, err)
+		}
+		if err := p.Slack.AddPin(r.ChannelID, slack.NewRefToMessage(r.ChannelID, r.DashboardTS)); err != nil {
+			return nil, fmt.Errorf("error pinning the dashboard in #%s: %s", q.Channel, err)
+		}
+	}
+
+	if err := p.Store.SetQueueChannel(ctx, r.QueueChannel); err != nil {
+		return nil, fmt.Errorf("error recording the channel of %s: %s", q.Name, err)
+	}
+	return r, nil
+}
+
+// recorded returns the channel recorded for queue, nil if there is none
+func (p *Provisioner) recorded(ctx context.Context, queue string) (*store.QueueChannel, error) {
+	qcs, err := p.Store.QueueChannels(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error listing queue channels: %s", err)
+	}
+	for _, qc := range qcs {
+		if qc.Queue == queue {
+			return qc, nil
+		}
+	}
+	return nil, nil
+}
+
+// find returns the ID of the channel called name, empty if there is none
+func (p *Provisioner) find(ctx context.Context, name string) (string, error) {
+	if p.Channels == nil {
+		return "", nil
+	}
+	chs, err := p.Channels.Channels(ctx)
+	if err != nil {
+		return "", fmt.Errorf("error listing channels: %s", err)
+	}
+	for _, ch := range chs {
+		if ch.Name == name && !ch.IsArchived {
+			return ch.ID, nil
+		}
+	}
+	return "", nil
+}
@@ -0,0 +1,141 @@
+package provision
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/nlopes/slack"
+
+	"github.com/skybet/go-helpdesk/store"
+)
+
+// fakeSlack records the calls made to set up channels
+type fakeSlack struct {
+	channels []slack.Channel
+	members  map[string][]string
+	calls    []string
+	posts    int
+}
+
+func (f *fakeSlack) Channels(ctx context.Context) ([]slack.Channel, error) {
+	return f.channels, nil
+}
+
+func (f *fakeSlack) CreateChannel(name string) (*slack.Channel, error) {
+	f.calls = append(f.calls, "create "+name)
+	ch := slack.Channel{}
+	ch.ID, ch.Name = "CNEW", name
+	f.channels = append(f.channels, ch)
+	return &ch, nil
+}
+
+func (f *fakeSlack) InviteUsers(channelID string, users ...string) error {
+	f.calls = append(f.calls, fmt.Sprintf("invite %s %v", channelID, users))
+	return nil
+}
+
+func (f *fakeSlack) SetTopic(channelID, topic string) error {
+	f.calls = append(f.calls, "topic "+channelID+" "+topic)
+	return nil
+}
+
+func (f *fakeSlack) SetPurpose(channelID, purpose string) error {
+	f.calls = append(f.calls, "purpose "+channelID+" "+purpose)
+	return nil
+}
+
+func (f *fakeSlack) UsergroupMembers(usergroup string) ([]string, error) {
+	if m, ok := f.members[usergroup]; ok {
+		return m, nil
+	}
+	return nil, fmt.Errorf("no_such_subteam")
+}
+
+func (f *fakeSlack) PostMessage(channelID string, options ...slack.MsgOption) (string, string, error) {
+	f.posts++
+	ts := fmt.Sprintf("%d.1", f.posts)
+	f.calls = append(f.calls, "post "+channelID+" "+ts)
+	return channelID, ts, nil
+}
+
+func (f *fakeSlack) AddPin(channel string, item slack.ItemRef) error {
+	f.calls = append(f.calls, "pin "+channel+" "+item.Timestamp)
+	return nil
+}
+
+func newProvisioner(s store.Store, f *fakeSlack) *Provisioner {
+	return &Provisioner{Store: s, Slack: f, Channels: f, Dashboard: func(ctx context.Context) ([]slack.MsgOption, error) {
+		return []slack.MsgOption{slack.MsgOptionText("dashboard", false)}, nil
+	}}
+}
+
+func TestProvision(t *testing.T) {
+	s := store.NewMemory()
+	f := &fakeSlack{members: map[string][]string{"S1": {"U1", "U2"}}}
+	p := newProvisioner(s, f)
+	now := time.Date(2019, 5, 1, 9, 0, 0, 0, time.UTC)
+	q := Queue{Name: "it", Channel: "it-triage", Usergroup: "S1", Topic: "IT tickets", Purpose: "Triage IT tickets"}
+
+	r, err := p.Provision(context.Background(), q, "UADMIN", now)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if !r.Created || r.Invited != 2 || r.ChannelID != "CNEW" || r.DashboardTS != "1.1" {
+		t.Errorf("Expected the channel to be created with the group invited and the dashboard posted, got %+v %+v", r, r.QueueChannel)
+	}
+	expected := []string{"create it-triage", "invite CNEW [U1 U2]", "topic CNEW IT tickets", "purpose CNEW Triage IT tickets", "post CNEW 1.1", "pin CNEW 1.1"}
+	if !reflect.DeepEqual(f.calls, expected) {
+		t.Errorf("Expected calls %v, got %v", expected, f.calls)
+	}
+	qcs, _ := s.QueueChannels(context.Background())
+	if len(qcs) != 1 || *qcs[0] != (store.QueueChannel{Queue: "it", ChannelID: "CNEW", DashboardTS: "1.1", ProvisionedBy: "UADMIN", ProvisionedAt: now}) {
+		t.Errorf("Expected the channel to be recorded, got %+v", qcs)
+	}
+
+	// Running it again finds the channel and keeps the pinned dashboard
+	f.calls = nil
+	r, err = p.Provision(context.Background(), q, "UADMIN", now.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if r.Created || r.DashboardTS != "1.1" || f.posts != 1 {
+		t.Errorf("Expected the existing channel and dashboard to be reused, got %+v %+v", r, f.calls)
+	}
+}
+
+func TestProvisionExistingChannel(t *testing.T) {
+	s := store.NewMemory()
+	ch := slack.Channel{}
+	ch.ID, ch.Name = "CHR", "hr-triage"
+	f := &fakeSlack{channels: []slack.Channel{ch}}
+	p := newProvisioner(s, f)
+
+	r, err := p.Provision(context.Background(), Queue{Name: "hr", Channel: "hr-triage"}, "UADMIN", time.Now())
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	expected := []string{"post CHR 1.1", "pin CHR 1.1"}
+	if r.Created || r.ChannelID != "CHR" || !reflect.DeepEqual(f.calls, expected) {
+		t.Errorf("Expected only the dashboard to be posted in the existing channel, got %+v %v", r, f.calls)
+	}
+}
+
+func TestProvisionErrors(t *testing.T) {
+	for _, q := range []Queue{{Channel: "it"}, {Name: "it"}, {Name: "it", Channel: "IT Triage"}} {
+		p := newProvisioner(store.NewMemory(), &fakeSlack{})
+		if _, err := p.Provision(context.Background(), q, "UADMIN", time.Now()); err == nil {
+			t.Errorf("Expected %+v to be refused", q)
+		}
+	}
+	s := store.NewMemory()
+	p := newProvisioner(s, &fakeSlack{})
+	if _, err := p.Provision(context.Background(), Queue{Name: "it", Channel: "it-triage", Usergroup: "SNONE"}, "UADMIN", time.Now()); err == nil {
+		t.Errorf("Expected an unknown usergroup to fail")
+	}
+	if qcs, _ := s.QueueChannels(context.Background()); len(qcs) != 0 {
+		t.Errorf("Expected nothing to be recorded after a failure, got %+v", qcs)
+	}
+}
@@ -0,0 +1,314 @@
+// Package query parses the search language used to pick tickets in /hd
+// search, bulk commands, exports and the APIs, such as
+// `status:open priority>=P2 tag:vpn created<7d -assignee:@me`, into store
+// filters.
+package query
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/skybet/go-helpdesk/store"
+	"github.com/skybet/go-helpdesk/ticket"
+	"github.com/skybet/go-helpdesk/wip"
+)
+
+// Me is the value of assignee and reporter which stands for whoever runs the
+// query
+const Me = "@me"
+
+// dateLayout is how absolute dates are written in queries
+const dateLayout = "2006-01-02"
+
+// term is a word of a query. Quoted terms are always searched for as text.
+type term struct {
+	text    string
+	quoted  bool
+	negated bool
+}
+
+// Parse parses a query into a filter. Terms are <field>:<value>, or a
+// comparison such as priority>=P2 for priority, created and updated, and
+// every term must match. A leading - leaves out the tickets matching the term
+// instead. Other words, or text in double quotes, are searched for as a phrase
+// in the title, description and comments.
+//
+// The fields are:
+//   - status, a comma separated list of statuses, where open stands for every
+//     status which still needs work
+//   - queue
+//   - assignee and reporter, a user mention or ID or @me for user, and
+//     assignee:none for unassigned tickets
+//   - tag, which may be given more than once
+//   - priority, a comma separated list or compared by urgency, so that
+//     priority>=P2 is P1 and P2
+//   - created and updated, compared to an age such as 30m, 12h, 7d or 2w,
+//     where created<7d is less than 7 days old, or to a date in the form
+//     2006-01-02 in UTC, where created<2026-10-01 is before that day
+//
+// user is who @me stands for, it is refused if empty, and now is when ages are
+// counted back from.
+func Parse(q, user string, now time.Time) (store.Filter, error) {
+	terms, err := split(q)
+	if err != nil {
+		return store.Filter{}, err
+	}
+	var f store.Filter
+	var text []string
+	seen := map[string]bool{}
+	for _, t := range terms {
+		key, op, value, ok := field(t)
+		if !ok {
+			if t.negated {
+				f.Exclude = append(f.Exclude, store.Filter{Text: t.text})
+			} else {
+				text = append(text, t.text)
+			}
+			continue
+		}
+		if t.negated {
+			var ex store.Filter
+			if err := apply(&ex, key, op, value, user, now, map[string]bool{}); err != nil {
+				return store.Filter{}, err
+			}
+			f.Exclude = append(f.Exclude, ex)
+			continue
+		}
+		if err := apply(&f, key, op, value, user, now, seen); err != nil {
+			return store.Filter{}, err
+		}
+	}
+	f.Text = strings.Join(text, " ")
+	return f, nil
+}
+
+// split splits a query into its terms at spaces outside double quotes
+func split(q string) ([]term, error) {
+	var terms []term
+	var cur strings.Builder
+	var t term
+	inQuote, started := false, false
+	end := func() {
+		if started {
+			t.text = cur.String()
+			if t.text != "" || t.quoted {
+				terms = append(terms, t)
+			}
+		}
+		cur.Reset()
+		t, started = term{}, false
+	}
+	for _, r := range q {
+		switch {
+		case r == '"':
+			if !started || (t.negated && cur.Len() == 0) {
+				t.quoted = true
+			}
+			started = true
+			inQuote = !inQuote
+		case r == ' ' && !inQuote, r == '\t' && !inQuote, r == '\n' && !inQuote:
+			end()
+		case r == '-' && !started:
+			t.negated, started = true, true
+		default:
+			started = true
+			cur.WriteRune(r)
+		}
+	}
+	if inQuote {
+		return nil, fmt.Errorf("unterminated quote in query %q", q)
+	}
+	end()
+	return terms, nil
+}
+
+// fields are the fields a query can filter on, with whether they can be
+// compared with < and >
+var fields = map[string]bool{
+	"status":   false,
+	"queue":    false,
+	"assignee": false,
+	"reporter": false,
+	"tag":      false,
+	"priority": true,
+	"created":  true,
+	"updated":  true,
+}
+
+// field splits a term into its field, operator and value, false if it is text
+func field(t term) (key, op, value string, ok bool) {
+	if t.quoted {
+		return "", "", "", false
+	}
+	i := strings.IndexAny(t.text, ":<>=")
+	if i <= 0 {
+		return "", "", "", false
+	}
+	key, rest := strings.ToLower(t.text[:i]), t.text[i:]
+	op = rest[:1]
+	if len(rest) > 1 && rest[1] == '=' && (op == "<" || op == ">") {
+		op = rest[:2]
+	}
+	return key, op, rest[len(op):], true
+}
+
+// apply sets the filter's field for a term. seen holds the fields which may
+// only be given once and already have been.
+func apply(f *store.Filter, key, op, value, user string, now time.Time, seen map[string]bool) error {
+	comparable, ok := fields[key]
+	if !ok {
+		return fmt.Errorf("unknown field %q, put text containing : in double quotes", key)
+	}
+	if !comparable && op != ":" && op != "=" {
+		return fmt.Errorf("%s can not be compared with %s", key, op)
+	}
+	if value == "" {
+		return fmt.Errorf("%s needs a value", key)
+	}
+	if key != "tag" && key != "created" && key != "updated" {
+		if seen[key] {
+			return fmt.Errorf("%s can only be given once", key)
+		}
+		seen[key] = true
+	}
+	var err error
+	switch key {
+	case "status":
+		f.Status, err = statuses(value)
+	case "queue":
+		f.Queue = value
+	case "assignee":
+		if strings.EqualFold(value, "none") {
+			f.Unassigned = true
+		} else {
+			f.Assignee, err = userOf(value, user)
+		}
+	case "reporter":
+		f.Reporter, err = userOf(value, user)
+	case "tag":
+		f.Tags = append(f.Tags, value)
+	case "priority":
+		f.Priority, err = priorities(op, value)
+	case "created":
+		err = timeRange(&f.CreatedAfter, &f.CreatedBefore, op, value, now)
+	case "updated":
+		err = timeRange(&f.UpdatedAfter, &f.UpdatedBefore, op, value, now)
+	}
+	return err
+}
+
+func statuses(value string) ([]ticket.Status, error) {
+	var all []ticket.Status
+	for _, name := range strings.Split(value, ",") {
+		if strings.EqualFold(name, "open") {
+			all = append(all, wip.OpenStatuses...)
+			continue
+		}
+		s, err := ticket.ParseStatus(name)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, s)
+	}
+	return all, nil
+}
+
+// userOf returns the user ID of a mention such as <@U1|bob>, an ID or @me
+func userOf(value, user string) (string, error) {
+	if strings.EqualFold(value, Me) {
+		if user == "" {
+			return "", fmt.Errorf("%s can only be used by someone signed in to Slack", Me)
+		}
+		return user, nil
+	}
+	if strings.HasPrefix(value, "<@") && strings.HasSuffix(value, ">") {
+		return strings.SplitN(strings.TrimSuffix(strings.TrimPrefix(value, "<@"), ">"), "|", 2)[0], nil
+	}
+	return strings.TrimPrefix(value, "@"), nil
+}
+
+// priorities returns the priorities matching a comparison, where a more
+// urgent priority is greater
+func priorities(op, value string) ([]ticket.Priority, error) {
+	if op == ":" || op == "=" {
+		var all []ticket.Priority
+		for _, v := range strings.Split(value, ",") {
+			p, err := ticket.ParsePriority(v)
+			if err != nil {
+				return nil, err
+			}
+			all = append(all, p)
+		}
+		return all, nil
+	}
+	than, err := ticket.ParsePriority(value)
+	if err != nil {
+		return nil, err
+	}
+	var all []ticket.Priority
+	for p := ticket.P1; p <= ticket.P4; p++ {
+		if (op == ">" && p < than) || (op == ">=" && p <= than) || (op == "<" && p > than) || (op == "<=" && p >= than) {
+			all = append(all, p)
+		}
+	}
+	if len(all) == 0 {
+		return nil, fmt.Errorf("no priority is %s %s", op, than)
+	}
+	return all, nil
+}
+
+// timeRange narrows the range from after to before by a comparison with an
+// age or a date
+func timeRange(after, before *time.Time, op, value string, now time.Time) error {
+	from, to := time.Time{}, time.Time{}
+	if age, err := parseAge(value); err == nil {
+		cutoff := now.Add(-age)
+		switch op {
+		case ":", "=", "<", "<=":
+			from = cutoff
+		default:
+			to = cutoff
+		}
+	} else if day, err := time.Parse(dateLayout, value); err == nil {
+		next := day.AddDate(0, 0, 1)
+		switch op {
+		case ":", "=":
+			from, to = day, next
+		case "<":
+			to = day
+		case "<=":
+			to = next
+		case ">":
+			from = next
+		case ">=":
+			from = day
+		}
+	} else {
+		return fmt.Errorf("invalid age or date %q, expected e.g. 7d or %s", value, dateLayout)
+	}
+	if !from.IsZero() && from.After(*after) {
+		*after = from
+	}
+	if !to.IsZero() && (before.IsZero() || to.Before(*before)) {
+		*before = to
+	}
+	return nil
+}
+
+// ageUnits are the units of ages in queries
+var ageUnits = map[byte]time.Duration{'m': time.Minute, 'h': time.Hour, 'd': 24 * time.Hour, 'w': 7 * 24 * time.Hour}
+
+// parseAge parses an age such as 7d
+func parseAge(s string) (time.Duration, error) {
+	if len(s) < 2 {
+		return 0, fmt.Errorf("invalid age %q", s)
+	}
+	unit, ok := ageUnits[s[len(s)-1]]
+	n, err := strconv.Atoi(s[:len(s)-1])
+	if !ok || err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid age %q", s)
+	}
+	return time.Duration(n) * unit, nil
+}
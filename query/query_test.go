@@ -0,0 +1,81 @@
+package query
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/skybet/go-helpdesk/store"
+	"github.com/skybet/go-helpdesk/ticket"
+	"github.com/skybet/go-helpdesk/wip"
+)
+
+var now = time.Date(2026, 10, 14, 9, 0, 0, 0, time.UTC)
+
+func TestParse(t *testing.T) {
+	day := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	for q, want := range map[string]store.Filter{
+		"":                   {},
+		"vpn is slow":        {Text: "vpn is slow"},
+		`"status:open" wifi`: {Text: "status:open wifi"},
+		"status:open priority>=P2 tag:vpn created<7d -assignee:@me": {
+			Status:       wip.OpenStatuses,
+			Priority:     []ticket.Priority{ticket.P1, ticket.P2},
+			Tags:         []string{"vpn"},
+			CreatedAfter: now.Add(-7 * 24 * time.Hour),
+			Exclude:      []store.Filter{{Assignee: "U1"}},
+		},
+		"status:resolved,closed queue:hr reporter:<@U2|bob> assignee:none": {Status: []ticket.Status{ticket.StatusResolved, ticket.StatusClosed}, Queue: "hr", Reporter: "U2", Unassigned: true},
+		"priority:P3,4 tag:vpn tag:network":                                {Priority: []ticket.Priority{ticket.P3, ticket.P4}, Tags: []string{"vpn", "network"}},
+		"priority<P2":                                                      {Priority: []ticket.Priority{ticket.P3, ticket.P4}},
+		"updated>2w":                                                       {UpdatedBefore: now.Add(-14 * 24 * time.Hour)},
+		"created:2026-10-01":                                               {CreatedAfter: day, CreatedBefore: day.AddDate(0, 0, 1)},
+		"created>=2026-10-01 created<=2026-10-01 created<30d":              {CreatedAfter: day, CreatedBefore: day.AddDate(0, 0, 1)},
+		`-"printer" -status:closed`:                                        {Exclude: []store.Filter{{Text: "printer"}, {Status: []ticket.Status{ticket.StatusClosed}}}},
+	} {
+		got, err := Parse(q, "U1", now)
+		if err != nil {
+			t.Errorf("Unexpected error parsing %q: %s", q, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("Expected %q to be parsed to %+v, got %+v", q, want, got)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, q := range []string{
+		"colour:red",
+		"status:broken",
+		"status>new",
+		"queue:it queue:hr",
+		"priority:P9",
+		"priority>P1",
+		"created<soon",
+		"tag:",
+		`"unterminated`,
+	} {
+		if _, err := Parse(q, "U1", now); err == nil {
+			t.Errorf("Expected an error parsing %q", q)
+		}
+	}
+	if _, err := Parse("assignee:@me", "", now); err == nil {
+		t.Error("Expected @me to be refused without a user")
+	}
+}
+
+func TestParseMatches(t *testing.T) {
+	f, err := Parse("status:open priority>=P2 -assignee:@me", "U1", now)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	for want, tk := range map[bool]*ticket.Ticket{
+		true:  {Status: ticket.StatusNew, Priority: ticket.P2, Assignee: "U2"},
+		false: {Status: ticket.StatusNew, Priority: ticket.P1, Assignee: "U1"},
+	} {
+		if got := f.Match(tk); got != want {
+			t.Errorf("Expected matching %+v to be %t", tk, want)
+		}
+	}
+}
@@ -0,0 +1,266 @@
+// Package region hands queues between regional sub-teams following the sun,
+// each owning the queue during its window. New tickets and escalations go
+// to the region on call and a handover report is posted as it changes.
+package region
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nlopes/slack"
+
+	"github.com/skybet/go-helpdesk/assign"
+	"github.com/skybet/go-helpdesk/clock"
+	"github.com/skybet/go-helpdesk/links"
+	"github.com/skybet/go-helpdesk/notify"
+	"github.com/skybet/go-helpdesk/store"
+	"github.com/skybet/go-helpdesk/ticket"
+	"github.com/skybet/go-helpdesk/wip"
+)
+
+// Region is a regional sub-team of a queue
+type Region struct {
+	Name string
+	// Hours are the window the region owns the queue in, in Location
+	Hours    notify.Hours
+	Location *time.Location
+}
+
+// Active reports whether t is in the region's window
+func (r Region) Active(t time.Time) bool {
+	return !r.Hours.Until(t.In(r.Location)).IsZero()
+}
+
+// Regions are the regions of each queue in order of precedence, where their
+// windows overlap the first owns the queue
+type Regions map[string][]Region
+
+// ParseRegions parses regions in the form
+// <queue>:<region>@<hours>[@<time zone>]+<region>@<hours>, e.g.
+// it:emea@08:00-16:00@Europe/London+amer@09:00-17:00@America/New_York.
+// Hours without a time zone are in UTC.
+func ParseRegions(specs []string) (Regions, error) {
+	regions := Regions{}
+	for _, spec := range specs {
+		parts := strings.SplitN(spec, ":", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return nil, fmt.Errorf("invalid regions %q, expected <queue>:<region>@<hours>[@<time zone>]+<region>@<hours>", spec)
+		}
+		for _, r := range strings.Split(parts[1], "+") {
+			fields := strings.Split(r, "@")
+			if len(fields) < 2 || len(fields) > 3 || fields[0] == "" {
+				return nil, fmt.Errorf("invalid region %q in %q, expected <region>@<hours>[@<time zone>]", r, spec)
+			}
+			h, err := notify.ParseHours(fields[1])
+			if err != nil || h == notify.Off || h.Start == h.End {
+				return nil, fmt.Errorf("invalid window of region %q in %q, expected HH:MM-HH:MM", fields[0], spec)
+			}
+			region := Region{Name: fields[0], Hours: h, Location: time.UTC}
+			if len(fields) == 3 {
+				if region.Location, err = time.LoadLocation(fields[2]); err != nil {
+					return nil, fmt.Errorf("invalid time zone of region %q in %q: %s", fields[0], spec, err)
+				}
+			}
+			regions[parts[0]] = append(regions[parts[0]], region)
+		}
+	}
+	return regions, nil
+}
+
+// Owner returns the region owning a queue at t, false if the queue has no
+// regions. Between windows the queue stays with the region whose window ended
+// last.
+func (r Regions) Owner(queue string, t time.Time) (Region, bool) {
+	regions := r[queue]
+	if len(regions) == 0 {
+		return Region{}, false
+	}
+	for back := time.Duration(0); back <= 24*time.Hour; back += time.Minute {
+		for _, region := range regions {
+			if region.Active(t.Add(-back)) {
+				return region, true
+			}
+		}
+	}
+	return regions[0], true
+}
+
+// Router routes a queue's work to its region on call, whose agents are the
+// on-call group named <queue>/<region>
+type Router struct {
+	Regions Regions
+	Groups  assign.Groups
+}
+
+// GroupName returns the name of a region's on-call group
+func GroupName(queue, region string) string {
+	return queue + "/" + region
+}
+
+// Group returns the on-call group for a queue at now, its owner's if the
+// queue has regions, for assign.Assigner.Group
+func (r *Router) Group(queue string, now time.Time) string {
+	if owner, ok := r.Regions.Owner(queue, now); ok {
+		if _, ok := r.Groups[GroupName(queue, owner.Name)]; ok {
+			return GroupName(queue, owner.Name)
+		}
+	}
+	return queue
+}
+
+// Route replaces the users of a queue's other regions with the agents of the
+// region on call at now, so that nobody off shift is escalated to, for
+// escalate.Escalator.Route. Other users are kept.
+func (r *Router) Route(queue string, users []string, now time.Time) []string {
+	owner, ok := r.Regions.Owner(queue, now)
+	if !ok {
+		return users
+	}
+	offShift := map[string]bool{}
+	for _, region := range r.Regions[queue] {
+		if region.Name == owner.Name {
+			continue
+		}
+		for _, a := range r.Groups[GroupName(queue, region.Name)] {
+			offShift[a.ID] = true
+		}
+	}
+	var routed []string
+	seen := map[string]bool{}
+	add := func(u string) {
+		if !seen[u] {
+			seen[u] = true
+			routed = append(routed, u)
+		}
+	}
+	for _, u := range users {
+		if !offShift[u] {
+			add(u)
+			continue
+		}
+		for _, a := range r.Groups[GroupName(queue, owner.Name)] {
+			add(a.ID)
+		}
+	}
+	return routed
+}
+
+// Slack is the part of the Slack API used to post handover reports
+type Slack interface {
+	PostMessage(channelID string, options ...slack.MsgOption) (string, string, error)
+}
+
+// Handover posts a report of a queue's open tickets in its channel whenever
+// it passes from one region to the next
+type Handover struct {
+	Store   store.Store
+	Slack   Slack
+	Regions Regions
+	// Channels are the channels of each queue, queues without one are
+	// handed over without a report
+	Channels map[string]string
+	// Links, if set, links tickets in the reports to their thread
+	Links *links.Links
+	// Clock, if set, replaces the wall clock
+	Clock clock.Clock
+
+	mu     sync.Mutex
+	owners map[string]string
+}
+
+// Check posts the handover report of every queue whose owner has changed
+// since the last check. The first check only notes the owners.
+func (h *Handover) Check(ctx context.Context, now time.Time) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	first := h.owners == nil
+	if first {
+		h.owners = map[string]string{}
+	}
+	var errs []string
+	for queue := range h.Regions {
+		owner, _ := h.Regions.Owner(queue, now)
+		from, ok := h.owners[queue]
+		h.owners[queue] = owner.Name
+		if first || !ok || from == owner.Name {
+			continue
+		}
+		channel := h.Channels[queue]
+		if channel == "" {
+			continue
+		}
+		text, err := h.Report(ctx, queue, from, owner.Name)
+		if err == nil {
+			_, _, err = h.Slack.PostMessage(channel, slack.MsgOptionText(text, false))
+		}
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %s", queue, err))
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("error handing over queues: %s", strings.Join(errs, ", "))
+	}
+	return nil
+}
+
+// Report describes the open tickets of a queue handed from one region to
+// another, the unassigned ones first
+func (h *Handover) Report(ctx context.Context, queue, from, to string) (string, error) {
+	f := store.Filter{Queue: queue, Status: wip.OpenStatuses}
+	var unassigned, assigned []*ticket.Ticket
+	for {
+		page, next, err := h.Store.ListTickets(ctx, f)
+		if err != nil {
+			return "", fmt.Errorf("error listing open tickets: %s", err)
+		}
+		for _, t := range page {
+			if t.Deleted() {
+				continue
+			}
+			if t.Assignee == "" {
+				unassigned = append(unassigned, t)
+			} else {
+				assigned = append(assigned, t)
+			}
+		}
+		if next == "" {
+			break
+		}
+		f.Cursor = next
+	}
+	lines := []string{fmt.Sprintf("*Handover of %s from %s to %s*", queue, from, to)}
+	if len(unassigned)+len(assigned) == 0 {
+		return lines[0] + "\nThere are no open tickets.", nil
+	}
+	lines = append(lines, fmt.Sprintf("%d open tickets, %d of them unassigned", len(unassigned)+len(assigned), len(unassigned)))
+	for _, t := range append(unassigned, assigned...) {
+		who := "unassigned"
+		if t.Assignee != "" {
+			who = fmt.Sprintf("<@%s>", t.Assignee)
+		}
+		lines = append(lines, fmt.Sprintf("• %s %s (%s, %s)", h.Links.Ref(t.ID), t.Title, t.Status, who))
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// Run checks for handovers every interval until ctx is cancelled
+func (h *Handover) Run(ctx context.Context, interval time.Duration, errorf func(string, ...interface{})) {
+	tick := clock.Or(h.Clock).NewTicker(interval)
+	defer tick.Stop()
+	if err := h.Check(ctx, clock.Or(h.Clock).Now()); err != nil {
+		errorf("Handing over queues failed: %s", err)
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-tick.C():
+			if err := h.Check(ctx, now); err != nil {
+				errorf("Handing over queues failed: %s", err)
+			}
+		}
+	}
+}
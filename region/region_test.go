@@ -0,0 +1,108 @@
+package region
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nlopes/slack"
+
+	"github.com/skybet/go-helpdesk/assign"
+	"github.com/skybet/go-helpdesk/store"
+	"github.com/skybet/go-helpdesk/ticket"
+)
+
+type fakeSlack struct {
+	mu    sync.Mutex
+	posts map[string][]string
+}
+
+func (f *fakeSlack) PostMessage(channelID string, options ...slack.MsgOption) (string, string, error) {
+	_, values, _ := slack.UnsafeApplyMsgOptions("", channelID, "", options...)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.posts == nil {
+		f.posts = map[string][]string{}
+	}
+	f.posts[channelID] = append(f.posts[channelID], values.Get("text"))
+	return channelID, "1.2", nil
+}
+
+func TestParseRegions(t *testing.T) {
+	regions, err := ParseRegions([]string{"it:emea@08:00-16:00@Europe/London+apac@22:00-06:00"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	it := regions["it"]
+	if len(it) != 2 || it[0].Name != "emea" || it[0].Location.String() != "Europe/London" || it[1].Hours.String() != "22:00-06:00" || it[1].Location != time.UTC {
+		t.Errorf("Unexpected regions %+v", it)
+	}
+	for _, spec := range []string{"it", ":emea@08:00-16:00", "it:emea", "it:emea@off", "it:emea@08:00-08:00", "it:emea@8-4", "it:emea@08:00-16:00@Mars/Olympus", "it:@08:00-16:00"} {
+		if _, err := ParseRegions([]string{spec}); err == nil {
+			t.Errorf("Expected an error parsing %q", spec)
+		}
+	}
+}
+
+func TestOwner(t *testing.T) {
+	regions, _ := ParseRegions([]string{"it:emea@08:00-16:00+amer@14:00-20:00"})
+	day := time.Date(2026, 10, 14, 0, 0, 0, 0, time.UTC)
+	for hour, want := range map[int]string{9: "emea", 15: "emea", 17: "amer", 23: "amer", 3: "amer"} {
+		if got, ok := regions.Owner("it", day.Add(time.Duration(hour)*time.Hour)); !ok || got.Name != want {
+			t.Errorf("Expected %s to own it at %02d:00, got %s", want, hour, got.Name)
+		}
+	}
+	if _, ok := regions.Owner("hr", day); ok {
+		t.Error("Expected a queue without regions to have no owner")
+	}
+}
+
+func TestRouter(t *testing.T) {
+	regions, _ := ParseRegions([]string{"it:emea@08:00-16:00+amer@16:00-00:00"})
+	groups, _ := assign.ParseGroups([]string{"it:U9", "it/emea:U1+U2", "it/amer:U3+U4"})
+	r := &Router{Regions: regions, Groups: groups}
+	morning, evening := time.Date(2026, 10, 14, 9, 0, 0, 0, time.UTC), time.Date(2026, 10, 14, 18, 0, 0, 0, time.UTC)
+
+	if got := r.Group("it", morning); got != "it/emea" {
+		t.Errorf("Expected emea's group in the morning, got %s", got)
+	}
+	if got := r.Group("it", evening); got != "it/amer" {
+		t.Errorf("Expected amer's group in the evening, got %s", got)
+	}
+	if got := r.Group("hr", evening); got != "hr" {
+		t.Errorf("Expected queues without regions to keep their group, got %s", got)
+	}
+	if got := r.Route("it", []string{"U1", "U7", "U3"}, evening); strings.Join(got, ",") != "U3,U4,U7" {
+		t.Errorf("Expected emea's agents to be replaced by amer's, got %v", got)
+	}
+	if got := r.Route("it", []string{"U1"}, morning); strings.Join(got, ",") != "U1" {
+		t.Errorf("Expected the region on call to be escalated to, got %v", got)
+	}
+}
+
+func TestHandover(t *testing.T) {
+	ctx := context.Background()
+	s := store.NewMemory()
+	s.CreateTicket(ctx, &ticket.Ticket{Title: "VPN down", Queue: "it", Assignee: "U1", Status: ticket.StatusInProgress})
+	s.CreateTicket(ctx, &ticket.Ticket{Title: "Printer", Queue: "it"})
+	s.CreateTicket(ctx, &ticket.Ticket{Title: "Laptop", Queue: "it", Status: ticket.StatusClosed})
+	regions, _ := ParseRegions([]string{"it:emea@08:00-16:00+amer@16:00-00:00", "hr:emea@08:00-16:00+amer@16:00-00:00"})
+	f := &fakeSlack{}
+	h := &Handover{Store: s, Slack: f, Regions: regions, Channels: map[string]string{"it": "CIT"}}
+	day := time.Date(2026, 10, 14, 0, 0, 0, 0, time.UTC)
+
+	for _, hour := range []int{15, 16, 17} {
+		if err := h.Check(ctx, day.Add(time.Duration(hour)*time.Hour)); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+	}
+	if len(f.posts) != 1 || len(f.posts["CIT"]) != 1 {
+		t.Fatalf("Expected one handover report in the it channel, got %v", f.posts)
+	}
+	want := "*Handover of it from emea to amer*\n2 open tickets, 1 of them unassigned\n• #2 Printer (new, unassigned)\n• #1 VPN down (in_progress, <@U1>)"
+	if got := f.posts["CIT"][0]; got != want {
+		t.Errorf("Expected the report\n%s\ngot\n%s", want, got)
+	}
+}
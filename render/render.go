@@ -0,0 +1,115 @@
+// Package render formats notifications either richly, with emoji and blocks,
+// or as plain text with explicit labels for people using screen readers
+package render
+
+import (
+	"sync"
+
+	"github.com/nlopes/slack"
+)
+
+// Style is how a notification is formatted
+type Style int
+
+// The styles a notification can be formatted in
+const (
+	Rich Style = iota
+	Plain
+)
+
+// String returns the name of the style as used by /hd format
+func (s Style) String() string {
+	if s == Plain {
+		return "plain"
+	}
+	return "rich"
+}
+
+// Indicator is a status which rich notifications show as an emoji and plain
+// notifications spell out
+type Indicator struct {
+	Emoji string
+	// Label is shown in plain notifications, decorative indicators have
+	// none and are left out
+	Label string
+}
+
+// In renders the indicator in a style
+func (i Indicator) In(s Style) string {
+	if s != Plain {
+		return i.Emoji
+	}
+	if i.Label == "" {
+		return ""
+	}
+	return "[" + i.Label + "]"
+}
+
+// The indicators used in notifications
+var (
+	VIP       = Indicator{":star:", "VIP"}
+	Stale     = Indicator{":zzz:", "Stale"}
+	Escalated = Indicator{":rotating_light:", "Escalated"}
+	OverLimit = Indicator{":warning:", "Over WIP limit"}
+	Spike     = Indicator{":chart_with_upwards_trend:", "Spike"}
+	Incident  = Indicator{":rotating_light:", "Possible incident"}
+	Archived  = Indicator{":file_cabinet:", "Archived"}
+	AtRisk    = Indicator{":hourglass_flowing_sand:", "SLA at risk"}
+	Breached  = Indicator{":fire:", "SLA breached"}
+	Done      = Indicator{":tada:", ""}
+)
+
+// Preferences remembers which users want plain text notifications, everyone
+// else gets rich ones. A nil *Preferences is rich for everyone. Preferences is
+// safe for concurrent use.
+type Preferences struct {
+	mu    sync.RWMutex
+	plain map[string]bool
+}
+
+// NewPreferences returns preferences with plain text for the given user IDs
+func NewPreferences(plain []string) *Preferences {
+	p := &Preferences{plain: map[string]bool{}}
+	for _, id := range plain {
+		p.plain[id] = true
+	}
+	return p
+}
+
+// Style returns the style of notifications to a user
+func (p *Preferences) Style(user string) Style {
+	if p == nil {
+		return Rich
+	}
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.plain[user] {
+		return Plain
+	}
+	return Rich
+}
+
+// Set sets the style of notifications to a user
+func (p *Preferences) Set(user string, s Style) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.plain == nil {
+		p.plain = map[string]bool{}
+	}
+	if s == Plain {
+		p.plain[user] = true
+	} else {
+		delete(p.plain, user)
+	}
+}
+
+// Message returns the options for posting a notification. Plain notifications
+// leave out the blocks so the text, which must carry everything the blocks do,
+// is read on its own.
+func Message(s Style, text string, blocks ...slack.Block) []slack.MsgOption {
+	opts := []slack.MsgOption{slack.MsgOptionText(text, false)}
+	if s != Plain && len(blocks) > 0 {
+		opts = append(opts, slack.MsgOptionBlocks(blocks...))
+	}
+	return opts
+}
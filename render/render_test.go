@@ -0,0 +1,44 @@
+package render
+
+import (
+	"testing"
+
+	"github.com/nlopes/slack"
+)
+
+func TestIndicator(t *testing.T) {
+	if s := Stale.In(Rich); s != ":zzz:" {
+		t.Errorf("Expected the emoji, got %s", s)
+	}
+	if s := Stale.In(Plain); s != "[Stale]" {
+		t.Errorf("Expected the label, got %s", s)
+	}
+	if s := Done.In(Plain); s != "" {
+		t.Errorf("Expected decorative indicators to be left out, got %s", s)
+	}
+}
+
+func TestPreferences(t *testing.T) {
+	p := NewPreferences([]string{"U1"})
+	if p.Style("U1") != Plain || p.Style("U2") != Rich {
+		t.Errorf("Expected U1 to be plain and U2 rich")
+	}
+	p.Set("U1", Rich)
+	p.Set("U2", Plain)
+	if p.Style("U1") != Rich || p.Style("U2") != Plain {
+		t.Errorf("Expected the styles to be swapped")
+	}
+	var unset *Preferences
+	if unset.Style("U1") != Rich {
+		t.Errorf("Expected rich notifications without preferences")
+	}
+}
+
+func TestMessage(t *testing.T) {
+	block := slack.NewDividerBlock()
+	_, rich, _ := slack.UnsafeApplyMsgOptions("", "C1", "", Message(Rich, "hi", block)...)
+	_, plain, _ := slack.UnsafeApplyMsgOptions("", "C1", "", Message(Plain, "hi", block)...)
+	if rich.Get("blocks") == "" || plain.Get("blocks") != "" || plain.Get("text") != "hi" {
+		t.Errorf("Expected plain messages to leave out blocks, got %v and %v", rich, plain)
+	}
+}
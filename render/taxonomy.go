@@ -0,0 +1,149 @@
+package render
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/skybet/go-helpdesk/ticket"
+)
+
+var (
+	emojiPattern = regexp.MustCompile(`^:[a-z0-9_+'-]+:$`)
+	colorPattern = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+)
+
+// Badge shows a ticket's status or priority, Color is a hex colour such as
+// #e01e5a or empty for none
+type Badge struct {
+	Indicator
+	Color string
+}
+
+// Taxonomy maps ticket statuses and priorities to the badges they are shown
+// with
+type Taxonomy struct {
+	Statuses   map[ticket.Status]Badge
+	Priorities map[ticket.Priority]Badge
+}
+
+// DefaultTaxonomy uses standard emoji and no colours
+func DefaultTaxonomy() *Taxonomy {
+	return &Taxonomy{
+		Statuses: map[ticket.Status]Badge{
+			ticket.StatusNew:        {Indicator: Indicator{":new:", "New"}},
+			ticket.StatusTriaged:    {Indicator: Indicator{":mag:", "Triaged"}},
+			ticket.StatusInProgress: {Indicator: Indicator{":hammer_and_wrench:", "In progress"}},
+			ticket.StatusWaiting:    {Indicator: Indicator{":hourglass:", "Waiting"}},
+			ticket.StatusResolved:   {Indicator: Indicator{":white_check_mark:", "Resolved"}},
+			ticket.StatusClosed:     {Indicator: Indicator{":lock:", "Closed"}},
+		},
+		Priorities: map[ticket.Priority]Badge{
+			ticket.P1: {Indicator: Indicator{":red_circle:", "P1"}},
+			ticket.P2: {Indicator: Indicator{":large_orange_diamond:", "P2"}},
+			ticket.P3: {Indicator: Indicator{":large_blue_diamond:", "P3"}},
+			ticket.P4: {Indicator: Indicator{":white_circle:", "P4"}},
+		},
+	}
+}
+
+// ParseTaxonomy returns the default taxonomy overridden by specs in the form
+// status:<status>=<emoji><color> or priority:<priority>=<emoji><color>, e.g.
+// priority:P1=:sev1:#e01e5a. Either the emoji or colour can be left out.
+func ParseTaxonomy(specs []string) (*Taxonomy, error) {
+	t := DefaultTaxonomy()
+	for _, spec := range specs {
+		kv := strings.SplitN(spec, "=", 2)
+		key := strings.SplitN(kv[0], ":", 2)
+		if len(kv) != 2 || len(key) != 2 {
+			return nil, fmt.Errorf("invalid badge %q, expected status:<status>=<emoji><color> or priority:<priority>=<emoji><color>", spec)
+		}
+		emoji, color := kv[1], ""
+		if i := strings.LastIndex(kv[1], "#"); i >= 0 {
+			emoji, color = kv[1][:i], kv[1][i:]
+		}
+		if emoji != "" && !emojiPattern.MatchString(emoji) {
+			return nil, fmt.Errorf("invalid emoji %q in badge %q", emoji, spec)
+		}
+		if color != "" && !colorPattern.MatchString(color) {
+			return nil, fmt.Errorf("invalid colour %q in badge %q", color, spec)
+		}
+		switch key[0] {
+		case "status":
+			s := ticket.Status(key[1])
+			b, ok := t.Statuses[s]
+			if !ok {
+				return nil, fmt.Errorf("unknown status %q in badge %q", key[1], spec)
+			}
+			t.Statuses[s] = override(b, emoji, color)
+		case "priority":
+			p, err := ticket.ParsePriority(key[1])
+			if err != nil {
+				return nil, fmt.Errorf("invalid badge %q: %s", spec, err)
+			}
+			t.Priorities[p] = override(t.Priorities[p], emoji, color)
+		default:
+			return nil, fmt.Errorf("invalid badge %q, expected status or priority", spec)
+		}
+	}
+	return t, nil
+}
+
+func override(b Badge, emoji, color string) Badge {
+	if emoji != "" {
+		b.Emoji = emoji
+	}
+	if color != "" {
+		b.Color = color
+	}
+	return b
+}
+
+// Validate checks the emoji which differ from the defaults against the
+// workspace's custom emoji, as returned by emoji.list, replacing any which are
+// missing with the default. It returns a warning for each one replaced.
+func (t *Taxonomy) Validate(custom map[string]string) []string {
+	defaults := DefaultTaxonomy()
+	var warnings []string
+	check := func(name string, b, def Badge) Badge {
+		if b.Emoji == def.Emoji {
+			return b
+		}
+		if _, ok := custom[strings.Trim(b.Emoji, ":")]; !ok {
+			warnings = append(warnings, fmt.Sprintf("emoji %s for %s is not in the workspace, using %s", b.Emoji, name, def.Emoji))
+			b.Emoji = def.Emoji
+		}
+		return b
+	}
+	for s, b := range t.Statuses {
+		t.Statuses[s] = check("status "+string(s), b, defaults.Statuses[s])
+	}
+	for p, b := range t.Priorities {
+		t.Priorities[p] = check("priority "+p.String(), b, defaults.Priorities[p])
+	}
+	sort.Strings(warnings)
+	return warnings
+}
+
+// Status returns the badge for a status, a nil *Taxonomy uses the defaults
+func (t *Taxonomy) Status(s ticket.Status) Badge {
+	if t == nil {
+		t = DefaultTaxonomy()
+	}
+	if b, ok := t.Statuses[s]; ok {
+		return b
+	}
+	return Badge{Indicator: Indicator{Label: string(s)}}
+}
+
+// Priority returns the badge for a priority, a nil *Taxonomy uses the defaults
+func (t *Taxonomy) Priority(p ticket.Priority) Badge {
+	if t == nil {
+		t = DefaultTaxonomy()
+	}
+	if b, ok := t.Priorities[p]; ok {
+		return b
+	}
+	return Badge{Indicator: Indicator{Label: p.String()}}
+}
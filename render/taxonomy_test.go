@@ -0,0 +1,43 @@
+package render
+
+import (
+	"testing"
+
+	"github.com/skybet/go-helpdesk/ticket"
+)
+
+func TestParseTaxonomy(t *testing.T) {
+	tax, err := ParseTaxonomy([]string{"priority:P1=:sev1:#e01e5a", "status:waiting=#aaaaaa", "status:new=:fresh:"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if b := tax.Priority(ticket.P1); b.Emoji != ":sev1:" || b.Color != "#e01e5a" || b.Label != "P1" {
+		t.Errorf("Unexpected P1 badge %+v", b)
+	}
+	if b := tax.Status(ticket.StatusWaiting); b.Emoji != ":hourglass:" || b.Color != "#aaaaaa" {
+		t.Errorf("Expected the default emoji to be kept, got %+v", b)
+	}
+	if b := tax.Priority(ticket.P2); b.Emoji != ":large_orange_diamond:" {
+		t.Errorf("Expected P2 to keep its default, got %+v", b)
+	}
+
+	for _, spec := range []string{"priority:P9=:x:", "status:open=:x:", "status:new=x", "status:new=#red", "label:x=:x:", "status"} {
+		if _, err := ParseTaxonomy([]string{spec}); err == nil {
+			t.Errorf("Expected %q to be invalid", spec)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tax, _ := ParseTaxonomy([]string{"priority:P1=:sev1:", "status:new=:fresh:#00ff00"})
+	warnings := tax.Validate(map[string]string{"sev1": "https://emoji.slack-edge.com/sev1.png"})
+	if len(warnings) != 1 {
+		t.Fatalf("Expected one warning, got %v", warnings)
+	}
+	if b := tax.Status(ticket.StatusNew); b.Emoji != ":new:" || b.Color != "#00ff00" {
+		t.Errorf("Expected the missing emoji to fall back but keep its colour, got %+v", b)
+	}
+	if b := tax.Priority(ticket.P1); b.Emoji != ":sev1:" {
+		t.Errorf("Expected the custom emoji to be kept, got %+v", b)
+	}
+}
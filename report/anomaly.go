@@ -0,0 +1,253 @@
+package report
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nlopes/slack"
+
+	"github.com/skybet/go-helpdesk/clock"
+	"github.com/skybet/go-helpdesk/links"
+	"github.com/skybet/go-helpdesk/render"
+	"github.com/skybet/go-helpdesk/store"
+	"github.com/skybet/go-helpdesk/ticket"
+)
+
+// Anomaly is an unusual rise in the number of tickets being created
+type Anomaly struct {
+	// Key is what spiked, e.g. queue:it or tag:vpn, or similar:<title> for a
+	// cluster of similar tickets
+	Key      string
+	Count    int
+	Baseline float64
+	// Tickets are the IDs of the tickets in the spike
+	Tickets []string
+	// Incident is set when the tickets look like they share a cause
+	Incident bool
+}
+
+// Detector finds spikes in ticket creation by comparing the latest window with
+// the same sized windows over the baseline period before it
+type Detector struct {
+	// Window is the period checked for a spike
+	Window time.Duration
+	// Baseline is how much history the window is compared with
+	Baseline time.Duration
+	// Threshold is how many standard deviations above the baseline mean a
+	// window must be to be a spike
+	Threshold float64
+	// MinCount stops quiet queues alerting on a couple of tickets
+	MinCount int
+	// Similar is how many tickets with similar titles within Window suggest
+	// an incident, zero disables incident suggestions
+	Similar int
+}
+
+// DefaultDetector compares the last hour with the week before it
+var DefaultDetector = Detector{Window: time.Hour, Baseline: 7 * 24 * time.Hour, Threshold: 3, MinCount: 5, Similar: 5}
+
+// Detect returns the spikes in tickets as of now, largest first
+func (d Detector) Detect(tickets []*ticket.Ticket, now time.Time) []Anomaly {
+	start := now.Add(-d.Window)
+	buckets := int(d.Baseline / d.Window)
+	counts := map[string][]int{}
+	recent := map[string][]string{}
+	var latest []*ticket.Ticket
+	for _, t := range tickets {
+		if !t.CreatedAt.Before(now) || t.CreatedAt.Before(start.Add(-d.Baseline)) {
+			continue
+		}
+		var keys []string
+		if t.Queue != "" {
+			keys = append(keys, "queue:"+t.Queue)
+		}
+		for _, tag := range t.Tags {
+			keys = append(keys, "tag:"+tag)
+		}
+		if !t.CreatedAt.Before(start) {
+			latest = append(latest, t)
+			for _, k := range keys {
+				recent[k] = append(recent[k], t.ID)
+			}
+			continue
+		}
+		b := int(start.Sub(t.CreatedAt) / d.Window)
+		if b >= buckets {
+			continue
+		}
+		for _, k := range keys {
+			if counts[k] == nil {
+				counts[k] = make([]int, buckets)
+			}
+			counts[k][b]++
+		}
+	}
+
+	var anomalies []Anomaly
+	for k, ids := range recent {
+		mean, sd := meanStdDev(counts[k], buckets)
+		if len(ids) >= d.MinCount && float64(len(ids)) > mean+d.Threshold*math.Max(sd, 1) {
+			sort.Strings(ids)
+			anomalies = append(anomalies, Anomaly{Key: k, Count: len(ids), Baseline: mean, Tickets: ids})
+		}
+	}
+	if d.Similar > 0 {
+		for _, c := range clusters(latest) {
+			if len(c) >= d.Similar {
+				a := Anomaly{Key: "similar:" + c[0].Title, Count: len(c), Incident: true}
+				for _, t := range c {
+					a.Tickets = append(a.Tickets, t.ID)
+				}
+				anomalies = append(anomalies, a)
+			}
+		}
+	}
+	sort.Slice(anomalies, func(i, j int) bool {
+		if anomalies[i].Count != anomalies[j].Count {
+			return anomalies[i].Count > anomalies[j].Count
+		}
+		return anomalies[i].Key < anomalies[j].Key
+	})
+	return anomalies
+}
+
+func meanStdDev(counts []int, n int) (float64, float64) {
+	if n == 0 {
+		return 0, 0
+	}
+	var sum float64
+	for _, c := range counts {
+		sum += float64(c)
+	}
+	mean := sum / float64(n)
+	var sq float64
+	for i := 0; i < n; i++ {
+		c := 0.0
+		if i < len(counts) {
+			c = float64(counts[i])
+		}
+		sq += (c - mean) * (c - mean)
+	}
+	return mean, math.Sqrt(sq / float64(n))
+}
+
+// clusters groups tickets whose titles share at least half their words
+func clusters(tickets []*ticket.Ticket) [][]*ticket.Ticket {
+	var groups [][]*ticket.Ticket
+	var words []map[string]bool
+	for _, t := range tickets {
+		w := titleWords(t.Title)
+		if len(w) == 0 {
+			continue
+		}
+		placed := false
+		for i := range groups {
+			if jaccard(words[i], w) >= 0.5 {
+				groups[i] = append(groups[i], t)
+				placed = true
+				break
+			}
+		}
+		if !placed {
+			groups = append(groups, []*ticket.Ticket{t})
+			words = append(words, w)
+		}
+	}
+	return groups
+}
+
+func titleWords(title string) map[string]bool {
+	w := map[string]bool{}
+	for _, f := range strings.FieldsFunc(strings.ToLower(title), func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9')
+	}) {
+		if len(f) > 2 {
+			w[f] = true
+		}
+	}
+	return w
+}
+
+func jaccard(a, b map[string]bool) float64 {
+	var both int
+	for w := range a {
+		if b[w] {
+			both++
+		}
+	}
+	return float64(both) / float64(len(a)+len(b)-both)
+}
+
+// Monitor periodically checks for anomalies and alerts a channel, each spike
+// is only alerted once per detector window
+type Monitor struct {
+	Detector Detector
+	Store    store.Store
+	Poster   Poster
+	Channel  string
+	// Links, if set, links the tickets in alerts to their thread
+	Links *links.Links
+	// Clock, if set, replaces the wall clock
+	Clock clock.Clock
+
+	mu      sync.Mutex
+	alerted map[string]time.Time
+}
+
+// Check alerts the channel of any new anomalies as of now
+func (m *Monitor) Check(ctx context.Context, now time.Time) error {
+	tickets, err := All(ctx, m.Store, store.Filter{})
+	if err != nil {
+		return fmt.Errorf("error listing tickets: %s", err)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.alerted == nil {
+		m.alerted = map[string]time.Time{}
+	}
+	for _, a := range m.Detector.Detect(tickets, now) {
+		if last, ok := m.alerted[a.Key]; ok && now.Sub(last) < m.Detector.Window {
+			continue
+		}
+		if _, _, err := m.Poster.PostMessage(m.Channel, slack.MsgOptionText(a.Text(m.Detector.Window, m.Links), false)); err != nil {
+			return fmt.Errorf("error posting anomaly alert: %s", err)
+		}
+		m.alerted[a.Key] = now
+	}
+	return nil
+}
+
+// Run checks for anomalies every interval until ctx is cancelled
+func (m *Monitor) Run(ctx context.Context, interval time.Duration, errorf func(string, ...interface{})) {
+	t := clock.Or(m.Clock).NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-t.C():
+			if err := m.Check(ctx, now); err != nil {
+				errorf("Anomaly check failed: %s", err)
+			}
+		}
+	}
+}
+
+// Text describes the anomaly for an alert, referring to tickets with l
+func (a Anomaly) Text(window time.Duration, l *links.Links) string {
+	refs := make([]string, len(a.Tickets))
+	for i, id := range a.Tickets {
+		refs[i] = l.Ref(id)
+	}
+	ids := strings.Join(refs, ", ")
+	if a.Incident {
+		return fmt.Sprintf("%s %d similar tickets in the last %s like %q (%s). This looks like an incident, consider declaring one.", render.Incident.Emoji,
+			a.Count, window, strings.TrimPrefix(a.Key, "similar:"), ids)
+	}
+	return fmt.Sprintf("%s %d tickets for %s in the last %s, usually %.1f (%s)", render.Spike.Emoji, a.Count, a.Key, window, a.Baseline, ids)
+}
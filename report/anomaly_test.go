@@ -0,0 +1,82 @@
+package report
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/skybet/go-helpdesk/store"
+	"github.com/skybet/go-helpdesk/ticket"
+)
+
+func TestDetectSpike(t *testing.T) {
+	now := time.Date(2019, time.March, 4, 9, 0, 0, 0, time.UTC)
+	var tickets []*ticket.Ticket
+	id := 0
+	add := func(at time.Time, queue, title string, tags ...string) {
+		id++
+		tickets = append(tickets, &ticket.Ticket{ID: strconv.Itoa(id), Queue: queue, Title: title, Tags: tags, CreatedAt: at})
+	}
+	// A steady ticket an hour in it for the last week
+	for h := 2; h < 7*24; h++ {
+		add(now.Add(-time.Duration(h)*time.Hour), "it", "Laptop request")
+	}
+	// Then a burst of VPN tickets
+	titles := []string{"VPN down", "vpn is down for me", "Cannot connect to VPN, down?", "VPN down again", "The VPN is down"}
+	for i, title := range titles {
+		add(now.Add(-time.Duration(i+1)*time.Minute), "it", title, "vpn")
+	}
+	add(now.Add(-time.Minute), "hr", "Payslip missing")
+
+	anomalies := DefaultDetector.Detect(tickets, now)
+	keys := map[string]Anomaly{}
+	for _, a := range anomalies {
+		keys[a.Key] = a
+	}
+	if a, ok := keys["queue:it"]; !ok || a.Count != 5 || a.Baseline < 0.9 {
+		t.Errorf("Expected the it queue to spike, got %+v", anomalies)
+	}
+	if _, ok := keys["tag:vpn"]; !ok {
+		t.Errorf("Expected the vpn tag to spike, got %+v", anomalies)
+	}
+	if _, ok := keys["queue:hr"]; ok {
+		t.Errorf("Expected a single ticket not to be a spike")
+	}
+	var incident *Anomaly
+	for i := range anomalies {
+		if anomalies[i].Incident {
+			incident = &anomalies[i]
+		}
+	}
+	if incident == nil || incident.Count != 5 {
+		t.Fatalf("Expected the similar VPN tickets to suggest an incident, got %+v", anomalies)
+	}
+	if !strings.Contains(incident.Text(time.Hour, nil), "consider declaring one") {
+		t.Errorf("Unexpected alert text: %s", incident.Text(time.Hour, nil))
+	}
+}
+
+func TestMonitorAlertsOnce(t *testing.T) {
+	now := time.Now()
+	s := store.NewMemory()
+	for i := 0; i < 6; i++ {
+		s.CreateTicket(context.Background(), &ticket.Ticket{Queue: "it", Title: "Ticket " + strconv.Itoa(i), CreatedAt: now.Add(-time.Minute)})
+	}
+	p := recordingPoster{}
+	d := DefaultDetector
+	d.Similar = 0
+	m := &Monitor{Detector: d, Store: s, Poster: p, Channel: "COPS"}
+	if err := m.Check(context.Background(), now); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if !strings.Contains(p["COPS"], "queue:it") {
+		t.Fatalf("Expected an alert, got %q", p["COPS"])
+	}
+	delete(p, "COPS")
+	m.Check(context.Background(), now.Add(time.Minute))
+	if _, ok := p["COPS"]; ok {
+		t.Errorf("Expected the spike to only be alerted once")
+	}
+}
@@ -0,0 +1,129 @@
+package report
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/skybet/go-helpdesk/hierarchy"
+	"github.com/skybet/go-helpdesk/projection"
+	"github.com/skybet/go-helpdesk/server"
+)
+
+// API serves reports as JSON to tools outside Slack such as staffing
+// spreadsheets. Every request must carry the token as a bearer token.
+type API struct {
+	// Hierarchy, if set, is rolled up by /rollup
+	Hierarchy *hierarchy.Tree
+
+	projection *projection.Projector
+	now        func() time.Time
+	mux        *http.ServeMux
+	handler    http.Handler
+}
+
+// NewAPI returns an API reporting on the tickets projected by p, so that
+// requests never list the tickets in the store. Mount it with
+// http.StripPrefix so that its routes, such as /forecast, are at the root.
+func NewAPI(p *projection.Projector, token string) *API {
+	a := &API{projection: p, now: time.Now, mux: http.NewServeMux()}
+	a.handler = server.RequireToken(token, a.mux)
+	a.mux.HandleFunc("/forecast", a.get(a.forecast))
+	a.mux.HandleFunc("/queues", a.get(a.queues))
+	a.mux.HandleFunc("/workload", a.get(a.workload))
+	a.mux.HandleFunc("/at-risk", a.get(a.atRisk))
+	a.mux.HandleFunc("/rollup", a.get(a.rollup))
+	return a
+}
+
+// ServeHTTP satisfies http.Handler
+func (a *API) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	a.handler.ServeHTTP(w, r)
+}
+
+// get only allows GET requests through to h
+func (a *API) get(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		h(w, r)
+	}
+}
+
+// forecast returns next week's forecast for every queue, the weeks parameter
+// sets how much history it is based on
+func (a *API) forecast(w http.ResponseWriter, r *http.Request) {
+	weeks := ForecastWeeks
+	if s := r.URL.Query().Get("weeks"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 || n > 104 {
+			http.Error(w, "weeks must be between 1 and 104", http.StatusBadRequest)
+			return
+		}
+		weeks = n
+	}
+	writeJSON(w, map[string]interface{}{"forecast": ForecastWeek(a.projection.Volume(), a.now(), weeks)})
+}
+
+// queues returns the open and assigned tickets in each queue
+func (a *API) queues(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, map[string]interface{}{"queues": a.projection.Queues()})
+}
+
+// workload returns the open tickets assigned to each agent
+func (a *API) workload(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, map[string]interface{}{"agents": a.projection.Workload()})
+}
+
+// riskJSON is a ticket at risk of breaching its SLA in the API
+type riskJSON struct {
+	ID       string    `json:"id"`
+	Queue    string    `json:"queue"`
+	Priority string    `json:"priority,omitempty"`
+	Assignee string    `json:"assignee,omitempty"`
+	Target   string    `json:"target"`
+	Due      time.Time `json:"due"`
+	Breached bool      `json:"breached"`
+}
+
+// atRisk returns the open tickets which have breached or are about to breach
+// their SLA targets, soonest due first
+func (a *API) atRisk(w http.ResponseWriter, r *http.Request) {
+	now := a.now()
+	risks := []riskJSON{}
+	for _, risk := range a.projection.AtRisk(now) {
+		t := risk.Ticket
+		j := riskJSON{ID: t.ID, Queue: t.Queue, Assignee: t.Assignee, Target: risk.Target, Due: risk.Due, Breached: risk.Breached(now)}
+		if t.Priority != 0 {
+			j.Priority = t.Priority.String()
+		}
+		risks = append(risks, j)
+	}
+	writeJSON(w, map[string]interface{}{"tickets": risks})
+}
+
+// rollup returns the open tickets of the organisation, or of the unit named
+// by the unit parameter, with the units beneath it
+func (a *API) rollup(w http.ResponseWriter, r *http.Request) {
+	if a.Hierarchy == nil {
+		http.Error(w, "no hierarchy", http.StatusNotFound)
+		return
+	}
+	now := a.now()
+	rollup := BuildRollup(a.Hierarchy, a.projection.Queues(), a.projection.AtRisk(now), now)
+	if unit := r.URL.Query().Get("unit"); unit != "" {
+		if rollup = rollup.Find(unit); rollup == nil {
+			http.Error(w, "unknown unit", http.StatusNotFound)
+			return
+		}
+	}
+	writeJSON(w, rollup)
+}
+
+func writeJSON(w http.ResponseWriter, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(body)
+}
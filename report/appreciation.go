@@ -0,0 +1,61 @@
+package report
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/skybet/go-helpdesk/ticket"
+)
+
+// Appreciation counts the appreciation reactions given in tickets' threads
+// over a period
+type Appreciation struct {
+	Total int
+	// ByAgent counts the reactions to each user's messages and ByQueue the
+	// reactions in each queue's tickets
+	ByAgent map[string]int
+	ByQueue map[string]int
+}
+
+// Appreciated counts the reactions added to tickets between from and to
+func Appreciated(tickets []*ticket.Ticket, from, to time.Time) Appreciation {
+	a := Appreciation{ByAgent: map[string]int{}, ByQueue: map[string]int{}}
+	for _, t := range tickets {
+		for _, r := range t.Reactions {
+			if r.At.Before(from) || !r.At.Before(to) {
+				continue
+			}
+			a.Total++
+			if r.For != "" {
+				a.ByAgent[r.For]++
+			}
+			a.ByQueue[t.Queue]++
+		}
+	}
+	return a
+}
+
+// Queues lists the reactions in each queue, most appreciated first
+func (a Appreciation) Queues() string {
+	queues := make([]string, 0, len(a.ByQueue))
+	for q := range a.ByQueue {
+		queues = append(queues, q)
+	}
+	sort.Slice(queues, func(i, j int) bool {
+		if a.ByQueue[queues[i]] != a.ByQueue[queues[j]] {
+			return a.ByQueue[queues[i]] > a.ByQueue[queues[j]]
+		}
+		return queues[i] < queues[j]
+	})
+	parts := make([]string, len(queues))
+	for i, q := range queues {
+		name := q
+		if name == "" {
+			name = "no queue"
+		}
+		parts[i] = fmt.Sprintf("%s %d", name, a.ByQueue[q])
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,106 @@
+package report
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/nlopes/slack"
+
+	"github.com/skybet/go-helpdesk/clock"
+	"github.com/skybet/go-helpdesk/links"
+	"github.com/skybet/go-helpdesk/render"
+	"github.com/skybet/go-helpdesk/sla"
+	"github.com/skybet/go-helpdesk/store"
+)
+
+// BreachAlerter watches the open tickets' first response and resolution
+// deadlines. It warns Channel once a deadline is within Warn, alerts it again
+// when the deadline passes and calls OnBreach for each breach. Each deadline
+// is only warned and alerted once, a change of priority makes a new one.
+type BreachAlerter struct {
+	Store   store.Store
+	SLA     sla.SLA
+	Poster  Poster
+	Channel string
+	// Warn is how long before a deadline the channel is warned, zero only
+	// alerts breaches
+	Warn time.Duration
+	// OnBreach, if set, is called with every deadline missed, after the
+	// channel has been alerted
+	OnBreach func(sla.Risk)
+	// Links, if set, links tickets in alerts to their thread
+	Links *links.Links
+	// Clock, if set, replaces the wall clock
+	Clock clock.Clock
+
+	mu      sync.Mutex
+	alerted map[string]bool
+}
+
+// Check alerts the deadlines which have come within Warn or passed since the
+// last check
+func (b *BreachAlerter) Check(ctx context.Context, now time.Time) error {
+	open, err := All(ctx, b.Store, store.Filter{})
+	if err != nil {
+		return fmt.Errorf("error listing tickets: %s", err)
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	// Deadlines which were met, or whose ticket was closed, are forgotten
+	alerted := map[string]bool{}
+	var errs []error
+	for _, t := range open {
+		for _, r := range b.SLA.Risks(t, now, b.Warn) {
+			breached := r.Breached(now)
+			key := fmt.Sprintf("%s/%s/%d/%t", t.ID, r.Target, r.Due.Unix(), breached)
+			if b.alerted[key] {
+				alerted[key] = true
+				continue
+			}
+			if _, _, err := b.Poster.PostMessage(b.Channel, slack.MsgOptionText(b.text(r, breached), false)); err != nil {
+				errs = append(errs, fmt.Errorf("ticket %s: %s", t.ID, err))
+				continue
+			}
+			alerted[key] = true
+			if breached && b.OnBreach != nil {
+				b.OnBreach(r)
+			}
+		}
+	}
+	b.alerted = alerted
+	if len(errs) > 0 {
+		return fmt.Errorf("error posting %d SLA alerts, the first: %s", len(errs), errs[0])
+	}
+	return nil
+}
+
+func (b *BreachAlerter) text(r sla.Risk, breached bool) string {
+	t := r.Ticket
+	priority := "no priority"
+	if t.Priority != 0 {
+		priority = t.Priority.String()
+	}
+	due := fmt.Sprintf("<!date^%d^{date_short_pretty} at {time}|%s>", r.Due.Unix(), r.Due.UTC().Format("2 Jan 2006 15:04 MST"))
+	if breached {
+		return fmt.Sprintf("%s Ticket %s (%s) missed its %s target, it was due %s: %s", render.Breached.Emoji, b.Links.Ref(t.ID), priority, r.Target, due, t.Title)
+	}
+	return fmt.Sprintf("%s Ticket %s (%s) will miss its %s target %s: %s", render.AtRisk.Emoji, b.Links.Ref(t.ID), priority, r.Target, due, t.Title)
+}
+
+// Run checks the deadlines every interval until ctx is cancelled
+func (b *BreachAlerter) Run(ctx context.Context, interval time.Duration, errorf func(string, ...interface{})) {
+	t := clock.Or(b.Clock).NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-t.C():
+			if err := b.Check(ctx, now); err != nil {
+				errorf("SLA breach check failed: %s", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package report
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/skybet/go-helpdesk/sla"
+	"github.com/skybet/go-helpdesk/store"
+	"github.com/skybet/go-helpdesk/ticket"
+)
+
+func TestBreachAlerter(t *testing.T) {
+	s := store.NewMemory()
+	s.CreateTicket(context.Background(), &ticket.Ticket{ID: "1", Title: "VPN down", Priority: ticket.P1})
+	tk, _ := s.GetTicket(context.Background(), "1")
+	created := tk.CreatedAt
+	p := &postLog{}
+	var breaches []sla.Risk
+	b := &BreachAlerter{
+		Store:    s,
+		SLA:      sla.SLA{Response: sla.Targets{ticket.P1: time.Hour}, Resolution: sla.Targets{ticket.P1: 4 * time.Hour}},
+		Poster:   p,
+		Channel:  "COPS",
+		Warn:     15 * time.Minute,
+		OnBreach: func(r sla.Risk) { breaches = append(breaches, r) },
+	}
+	check := func(at time.Duration) {
+		t.Helper()
+		if err := b.Check(context.Background(), created.Add(at)); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+	}
+
+	check(30 * time.Minute)
+	if len(p.posts) != 0 {
+		t.Fatalf("Expected nothing to be alerted yet, got %+v", p.posts)
+	}
+	check(50 * time.Minute)
+	check(55 * time.Minute)
+	if len(p.posts) != 1 || p.posts[0].channel != "COPS" || !strings.Contains(p.posts[0].text, "will miss its response target") {
+		t.Fatalf("Expected one warning, got %+v", p.posts)
+	}
+	check(61 * time.Minute)
+	check(90 * time.Minute)
+	if len(p.posts) != 2 || !strings.Contains(p.posts[1].text, "Ticket #1 (P1) missed its response target") {
+		t.Fatalf("Expected one breach alert, got %+v", p.posts)
+	}
+	if len(breaches) != 1 || breaches[0].Target != "response" {
+		t.Errorf("Expected the breach callback, got %+v", breaches)
+	}
+
+	tk, _ = s.GetTicket(context.Background(), "1")
+	tk.SetStatus(ticket.StatusResolved, created.Add(2*time.Hour))
+	s.UpdateTicket(context.Background(), tk)
+	check(5 * time.Hour)
+	if len(p.posts) != 2 || len(breaches) != 1 {
+		t.Errorf("Expected resolved tickets to be left alone, got %+v", p.posts)
+	}
+}
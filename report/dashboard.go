@@ -0,0 +1,106 @@
+package report
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/nlopes/slack"
+
+	"github.com/skybet/go-helpdesk/projection"
+	"github.com/skybet/go-helpdesk/render"
+	"github.com/skybet/go-helpdesk/sla"
+	"github.com/skybet/go-helpdesk/store"
+)
+
+// ForecastWeeks is how many weeks of history forecasts are made from
+const ForecastWeeks = 8
+
+// Dashboard summarises the current state of the helpdesk
+type Dashboard struct {
+	Generated time.Time
+	// Open is the number of open tickets in each queue
+	Open map[string]int
+	// Assigned is the number of open tickets in each queue with an assignee,
+	// their work in progress
+	Assigned map[string]int
+	// WIPLimits are flagged when a queue's Assigned tickets exceed them
+	WIPLimits map[string]int
+	Forecast  []QueueForecast
+}
+
+// BuildDashboard builds the dashboard from every ticket in s, use
+// ProjectDashboard instead where a projection is kept
+func BuildDashboard(ctx context.Context, s store.Store, now time.Time) (*Dashboard, error) {
+	p := projection.New(sla.SLA{}, 0)
+	if err := p.Load(ctx, s); err != nil {
+		return nil, err
+	}
+	return ProjectDashboard(p, now), nil
+}
+
+// ProjectDashboard builds the dashboard from a projection of the tickets
+func ProjectDashboard(p *projection.Projector, now time.Time) *Dashboard {
+	d := &Dashboard{Generated: now, Open: map[string]int{}, Assigned: map[string]int{}}
+	for name, q := range p.Queues() {
+		d.Open[name] = q.Open
+		d.Assigned[name] = q.Assigned
+	}
+	d.Forecast = ForecastWeek(p.Volume(), now, ForecastWeeks)
+	return d
+}
+
+// lines renders the open tickets and forecast sections in style s
+func (d *Dashboard) lines(s render.Style) (open, forecast []string) {
+	for q, n := range d.Open {
+		line := fmt.Sprintf("%s: *%d*", queueName(q), n)
+		if limit := d.WIPLimits[q]; limit > 0 && d.Assigned[q] > limit {
+			line += fmt.Sprintf(" %s %d in progress, over the WIP limit of %d", render.OverLimit.In(s), d.Assigned[q], limit)
+		}
+		open = append(open, line)
+	}
+	sort.Strings(open)
+	if len(open) == 0 {
+		open = []string{strings.TrimSpace("No open tickets " + render.Done.In(s))}
+	}
+	for _, f := range d.Forecast {
+		forecast = append(forecast, fmt.Sprintf("%s: ~%.0f", queueName(f.Queue), f.Total))
+	}
+	if len(forecast) == 0 {
+		forecast = []string{"Not enough history"}
+	}
+	return open, forecast
+}
+
+// Blocks renders the dashboard as a Slack message
+func (d *Dashboard) Blocks() []slack.Block {
+	open, forecast := d.lines(render.Rich)
+	md := func(s string) *slack.TextBlockObject {
+		return slack.NewTextBlockObject(slack.MarkdownType, s, false, false)
+	}
+	return []slack.Block{
+		slack.NewSectionBlock(md("*Helpdesk dashboard*"), nil, nil),
+		slack.NewSectionBlock(nil, []*slack.TextBlockObject{
+			md("*Open tickets*\n" + strings.Join(open, "\n")),
+			md("*Forecast for the next 7 days*\n" + strings.Join(forecast, "\n")),
+		}, nil),
+		slack.NewContextBlock("", md(fmt.Sprintf("Generated %s", d.Generated.Format(time.RFC1123)))),
+	}
+}
+
+// Text renders the dashboard as text in style s, with the same content as
+// Blocks
+func (d *Dashboard) Text(s render.Style) string {
+	open, forecast := d.lines(s)
+	return fmt.Sprintf("*Helpdesk dashboard*\n\n*Open tickets*\n%s\n\n*Forecast for the next 7 days*\n%s\n\nGenerated %s",
+		strings.Join(open, "\n"), strings.Join(forecast, "\n"), d.Generated.Format(time.RFC1123))
+}
+
+func queueName(q string) string {
+	if q == "" {
+		return "No queue"
+	}
+	return q
+}
@@ -0,0 +1,93 @@
+package report
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/nlopes/slack"
+
+	"github.com/skybet/go-helpdesk/clock"
+	"github.com/skybet/go-helpdesk/sla"
+	"github.com/skybet/go-helpdesk/store"
+)
+
+// Poster is the part of the Slack API used to deliver reports
+type Poster interface {
+	PostMessage(channelID string, options ...slack.MsgOption) (string, string, error)
+}
+
+// ScorecardDelivery sends each agent their own scorecard and leads the
+// scorecards of the whole team
+type ScorecardDelivery struct {
+	Store  store.Store
+	Poster Poster
+	// Leads are the user IDs sent every scorecard
+	Leads []string
+	// OptOut are the user IDs of agents who do not want a scorecard, they are
+	// still included in the leads' view
+	OptOut []string
+	// SLA is the targets tickets are measured against
+	SLA sla.SLA
+}
+
+// Deliver sends the scorecards for tickets resolved between from and to
+func (d *ScorecardDelivery) Deliver(ctx context.Context, from, to time.Time) error {
+	tickets, err := All(ctx, d.Store, store.Filter{})
+	if err != nil {
+		return fmt.Errorf("error listing tickets: %s", err)
+	}
+	cards := Scorecards(tickets, from, to, d.SLA)
+	optOut := map[string]bool{}
+	for _, id := range d.OptOut {
+		optOut[id] = true
+	}
+
+	var errs []string
+	for _, c := range cards {
+		if optOut[c.Agent] {
+			continue
+		}
+		if _, _, err := d.Poster.PostMessage(c.Agent, slack.MsgOptionText(c.Text(), false)); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %s", c.Agent, err))
+		}
+	}
+
+	summary := []string{Team(tickets, from, to, d.SLA).Text()}
+	for _, c := range cards {
+		summary = append(summary, c.Text())
+	}
+	for _, lead := range d.Leads {
+		if _, _, err := d.Poster.PostMessage(lead, slack.MsgOptionText(strings.Join(summary, "\n\n"), false)); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %s", lead, err))
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("error delivering scorecards: %s", strings.Join(errs, ", "))
+	}
+	return nil
+}
+
+// LastMonth returns the start and end of the calendar month before now
+func LastMonth(now time.Time) (from, to time.Time) {
+	to = time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+	return to.AddDate(0, -1, 0), to
+}
+
+// Monthly calls f with the previous month's bounds at the start of every month
+// on c until ctx is cancelled
+func Monthly(ctx context.Context, c clock.Clock, f func(from, to time.Time)) {
+	for {
+		now := c.Now()
+		next := time.Date(now.Year(), now.Month()+1, 1, 0, 0, 0, 0, now.Location())
+		t := c.NewTimer(next.Sub(now))
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return
+		case <-t.C():
+			f(LastMonth(c.Now()))
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package report
+
+import (
+	"sort"
+	"time"
+
+	"github.com/skybet/go-helpdesk/ticket"
+)
+
+// Season is the length of the weekly cycle in ticket volume, in days
+const Season = 7
+
+// HoltWinters is additive triple exponential smoothing with a weekly season
+type HoltWinters struct {
+	// Alpha, Beta and Gamma smooth the level, trend and season, each between
+	// 0 and 1
+	Alpha, Beta, Gamma float64
+}
+
+// DefaultHoltWinters is tuned for daily ticket volumes, which change slowly
+// but have a strong weekly shape
+var DefaultHoltWinters = HoltWinters{Alpha: 0.3, Beta: 0.05, Gamma: 0.3}
+
+// Forecast predicts the next horizon values of a daily series. Holt-Winters
+// needs two full seasons of history, with less it falls back to the seasonal
+// average. Forecasts are never negative.
+func (hw HoltWinters) Forecast(series []float64, horizon int) []float64 {
+	if len(series) < 2*Season {
+		return SeasonalAverage(series, horizon)
+	}
+	// Initialise the level and trend from the first two seasons and the
+	// seasonal components from the first
+	var first, second float64
+	for i := 0; i < Season; i++ {
+		first += series[i]
+		second += series[Season+i]
+	}
+	level := first / Season
+	trend := (second - first) / (Season * Season)
+	seasonal := make([]float64, Season)
+	for i := 0; i < Season; i++ {
+		seasonal[i] = series[i] - level
+	}
+
+	for i := Season; i < len(series); i++ {
+		s := seasonal[i%Season]
+		last := level
+		level = hw.Alpha*(series[i]-s) + (1-hw.Alpha)*(level+trend)
+		trend = hw.Beta*(level-last) + (1-hw.Beta)*trend
+		seasonal[i%Season] = hw.Gamma*(series[i]-level) + (1-hw.Gamma)*s
+	}
+
+	out := make([]float64, horizon)
+	for h := 0; h < horizon; h++ {
+		out[h] = nonNegative(level + float64(h+1)*trend + seasonal[(len(series)+h)%Season])
+	}
+	return out
+}
+
+// SeasonalAverage predicts each day as the mean of the same weekday in the
+// history, or the overall mean for weekdays with no history
+func SeasonalAverage(series []float64, horizon int) []float64 {
+	out := make([]float64, horizon)
+	if len(series) == 0 {
+		return out
+	}
+	var total float64
+	sums := make([]float64, Season)
+	counts := make([]float64, Season)
+	for i, v := range series {
+		total += v
+		sums[i%Season] += v
+		counts[i%Season]++
+	}
+	for h := range out {
+		d := (len(series) + h) % Season
+		if counts[d] > 0 {
+			out[h] = sums[d] / counts[d]
+		} else {
+			out[h] = total / float64(len(series))
+		}
+	}
+	return out
+}
+
+func nonNegative(f float64) float64 {
+	if f < 0 {
+		return 0
+	}
+	return f
+}
+
+// QueueForecast is the predicted number of new tickets in a queue for each of
+// the days starting at From
+type QueueForecast struct {
+	Queue string    `json:"queue"`
+	From  time.Time `json:"from"`
+	Daily []float64 `json:"daily"`
+	Total float64   `json:"total"`
+}
+
+// DailyVolume counts the tickets created on each day from from, for days
+// days, optionally restricted to a queue
+func DailyVolume(tickets []*ticket.Ticket, queue string, from time.Time, days int) []float64 {
+	series := make([]float64, days)
+	for _, t := range tickets {
+		if queue != "" && t.Queue != queue {
+			continue
+		}
+		d := int(t.CreatedAt.Sub(from) / (24 * time.Hour))
+		if t.CreatedAt.Before(from) || d >= days {
+			continue
+		}
+		series[d]++
+	}
+	return series
+}
+
+// ForecastWeek predicts next week's volume for every queue from the weeks of
+// history before today. Tickets without a queue are forecast under "".
+func ForecastWeek(tickets []*ticket.Ticket, now time.Time, weeks int) []QueueForecast {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	from := today.AddDate(0, 0, -weeks*Season)
+	queues := map[string]bool{}
+	for _, t := range tickets {
+		queues[t.Queue] = true
+	}
+	var fs []QueueForecast
+	for q := range queues {
+		series := DailyVolume(tickets, q, from, weeks*Season)
+		f := QueueForecast{Queue: q, From: today, Daily: DefaultHoltWinters.Forecast(series, Season)}
+		for _, v := range f.Daily {
+			f.Total += v
+		}
+		fs = append(fs, f)
+	}
+	sort.Slice(fs, func(i, j int) bool { return fs[i].Queue < fs[j].Queue })
+	return fs
+}
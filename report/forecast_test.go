@@ -0,0 +1,138 @@
+package report
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/skybet/go-helpdesk/projection"
+	"github.com/skybet/go-helpdesk/sla"
+	"github.com/skybet/go-helpdesk/store"
+	"github.com/skybet/go-helpdesk/ticket"
+)
+
+// weekly is quiet at the weekend and busy on Mondays
+var weekly = []float64{20, 12, 10, 10, 8, 2, 1}
+
+func TestHoltWintersSeasonal(t *testing.T) {
+	var series []float64
+	for w := 0; w < 8; w++ {
+		series = append(series, weekly...)
+	}
+	f := DefaultHoltWinters.Forecast(series, Season)
+	for i, want := range weekly {
+		if math.Abs(f[i]-want) > 0.5 {
+			t.Errorf("Day %d: expected ~%.0f, got %.2f", i, want, f[i])
+		}
+	}
+}
+
+func TestHoltWintersTrend(t *testing.T) {
+	var series []float64
+	for w := 0; w < 8; w++ {
+		for _, v := range weekly {
+			series = append(series, v+float64(w*7))
+		}
+	}
+	f := DefaultHoltWinters.Forecast(series, Season)
+	if f[0] <= series[len(series)-7] {
+		t.Errorf("Expected the upward trend to carry on, got %.2f after %.2f", f[0], series[len(series)-7])
+	}
+}
+
+func TestSeasonalAverageFallback(t *testing.T) {
+	f := DefaultHoltWinters.Forecast([]float64{4, 2, 0, 1, 1, 1, 1, 6}, Season)
+	if f[0] != 2 || f[6] != 5 {
+		t.Errorf("Expected each day to be the mean of the same weekday, got %v", f)
+	}
+	// Weekdays with no history get the overall mean
+	if f := SeasonalAverage([]float64{4, 2, 0}, 4); f[3] != 2 {
+		t.Errorf("Expected the overall mean, got %v", f)
+	}
+	if f := SeasonalAverage(nil, 2); f[0] != 0 || f[1] != 0 {
+		t.Errorf("Expected no history to forecast nothing, got %v", f)
+	}
+}
+
+func TestForecastAPI(t *testing.T) {
+	now := time.Date(2019, time.March, 4, 9, 0, 0, 0, time.UTC)
+	s := store.NewMemory()
+	for d := 1; d <= 28; d++ {
+		for i := 0; i < d%7; i++ {
+			s.CreateTicket(context.Background(), &ticket.Ticket{Queue: "it", CreatedAt: now.AddDate(0, 0, -d)})
+		}
+	}
+	p := projection.New(sla.SLA{}, 0)
+	p.Load(context.Background(), s)
+	a := NewAPI(p, "secret")
+	a.now = func() time.Time { return now }
+
+	r := httptest.NewRequest("GET", "/forecast?weeks=4", nil)
+	w := httptest.NewRecorder()
+	a.ServeHTTP(w, r)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected requests without the token to be refused, got %d", w.Code)
+	}
+
+	r.Header.Set("Authorization", "Bearer secret")
+	w = httptest.NewRecorder()
+	a.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected a 200, got %d: %s", w.Code, w.Body)
+	}
+	var body struct {
+		Forecast []QueueForecast `json:"forecast"`
+	}
+	json.NewDecoder(w.Body).Decode(&body)
+	if len(body.Forecast) != 1 || body.Forecast[0].Queue != "it" || len(body.Forecast[0].Daily) != 7 || body.Forecast[0].Total < 1 {
+		t.Errorf("Unexpected forecast: %+v", body.Forecast)
+	}
+
+	r = httptest.NewRequest("GET", "/forecast?weeks=0", nil)
+	r.Header.Set("Authorization", "Bearer secret")
+	w = httptest.NewRecorder()
+	a.ServeHTTP(w, r)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected an invalid weeks parameter to be rejected, got %d", w.Code)
+	}
+}
+
+func TestAtRiskAPI(t *testing.T) {
+	now := time.Date(2019, time.March, 4, 9, 0, 0, 0, time.UTC)
+	s := store.NewMemory()
+	s.CreateTicket(context.Background(), &ticket.Ticket{ID: "1", Queue: "it", Priority: ticket.P1, Status: ticket.StatusNew, CreatedAt: now.Add(-20 * time.Minute)})
+	s.CreateTicket(context.Background(), &ticket.Ticket{ID: "2", Queue: "it", Priority: ticket.P1, Status: ticket.StatusNew, CreatedAt: now})
+	p := projection.New(sla.SLA{Response: sla.Targets{ticket.P1: 15 * time.Minute}}, 5*time.Minute)
+	p.Load(context.Background(), s)
+	a := NewAPI(p, "secret")
+	a.now = func() time.Time { return now }
+
+	r := httptest.NewRequest("GET", "/at-risk", nil)
+	r.Header.Set("Authorization", "Bearer secret")
+	w := httptest.NewRecorder()
+	a.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body)
+	}
+	var body struct {
+		Tickets []riskJSON `json:"tickets"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if len(body.Tickets) != 1 || body.Tickets[0].ID != "1" || !body.Tickets[0].Breached || body.Tickets[0].Target != "response" {
+		t.Errorf("Expected ticket 1 to have breached its response target, got %+v", body.Tickets)
+	}
+
+	r = httptest.NewRequest("POST", "/queues", nil)
+	r.Header.Set("Authorization", "Bearer secret")
+	w = httptest.NewRecorder()
+	a.ServeHTTP(w, r)
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected only GET to be allowed, got %d", w.Code)
+	}
+}
@@ -0,0 +1,187 @@
+package report
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nlopes/slack"
+
+	"github.com/skybet/go-helpdesk/ticket"
+)
+
+// HeatmapWeeks is how many weeks of activity heatmaps cover by default
+const HeatmapWeeks = 4
+
+// heatLevels are the emoji a heatmap cell is drawn with, from no activity to
+// the busiest hours
+var heatLevels = []string{":white_large_square:", ":large_green_square:", ":large_yellow_square:", ":large_orange_square:", ":large_red_square:"}
+
+// days are the rows of a heatmap, the week starting on Monday
+var days = []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday, time.Saturday, time.Sunday}
+
+// Heatmap counts the tickets raised and the replies agents made to them in
+// each hour of the week, to line shift coverage up with demand. Rows are the
+// days of the week from Monday and columns the hours in Location.
+type Heatmap struct {
+	From, To  time.Time
+	Location  *time.Location
+	Created   [7][24]int
+	Responses [7][24]int
+}
+
+// HourOfWeek is one hour of a heatmap
+type HourOfWeek struct {
+	Day       time.Weekday
+	Hour      int
+	Created   int
+	Responses int
+}
+
+// Activity builds the heatmap of the tickets raised and replied to from from
+// until to, counting hours in loc. Replies are the comments of anyone but the
+// reporter, including internal notes, which are agents' work too.
+func Activity(tickets []*ticket.Ticket, from, to time.Time, loc *time.Location) *Heatmap {
+	if loc == nil {
+		loc = time.UTC
+	}
+	h := &Heatmap{From: from, To: to, Location: loc}
+	in := func(t time.Time) bool { return !t.Before(from) && t.Before(to) }
+	for _, t := range tickets {
+		if in(t.CreatedAt) {
+			d, hr := h.cell(t.CreatedAt)
+			h.Created[d][hr]++
+		}
+		for _, c := range t.Comments {
+			if c.Author != t.Reporter && in(c.CreatedAt) {
+				d, hr := h.cell(c.CreatedAt)
+				h.Responses[d][hr]++
+			}
+		}
+	}
+	return h
+}
+
+// cell returns the row and column of a time
+func (h *Heatmap) cell(t time.Time) (int, int) {
+	t = t.In(h.Location)
+	return (int(t.Weekday()) + 6) % 7, t.Hour()
+}
+
+// Hours returns every hour of the week, from Monday at midnight
+func (h *Heatmap) Hours() []HourOfWeek {
+	hours := make([]HourOfWeek, 0, 7*24)
+	for d, day := range days {
+		for hr := 0; hr < 24; hr++ {
+			hours = append(hours, HourOfWeek{Day: day, Hour: hr, Created: h.Created[d][hr], Responses: h.Responses[d][hr]})
+		}
+	}
+	return hours
+}
+
+// Peaks returns the n busiest hours by tickets raised and replies together,
+// busiest first, leaving out hours without activity
+func (h *Heatmap) Peaks(n int) []HourOfWeek {
+	var busy []HourOfWeek
+	for _, hr := range h.Hours() {
+		if hr.Created+hr.Responses > 0 {
+			busy = append(busy, hr)
+		}
+	}
+	sort.SliceStable(busy, func(i, j int) bool {
+		return busy[i].Created+busy[i].Responses > busy[j].Created+busy[j].Responses
+	})
+	if len(busy) > n {
+		busy = busy[:n]
+	}
+	return busy
+}
+
+// grid draws counts as rows of emoji, one per day, shaded by how close each
+// hour is to the busiest
+func grid(counts *[7][24]int) []string {
+	max := 0
+	for d := range counts {
+		for _, n := range counts[d] {
+			if n > max {
+				max = n
+			}
+		}
+	}
+	rows := make([]string, len(days))
+	for d, day := range days {
+		var b strings.Builder
+		fmt.Fprintf(&b, "`%s` ", day.String()[:3])
+		for _, n := range counts[d] {
+			level := 0
+			if n > 0 {
+				// Any activity is at least the first shade
+				level = 1 + (n*(len(heatLevels)-1)-1)/max
+			}
+			b.WriteString(heatLevels[level])
+		}
+		rows[d] = b.String()
+	}
+	return rows
+}
+
+// period describes the time the heatmap covers
+func (h *Heatmap) period() string {
+	return fmt.Sprintf("%s to %s, hours 00 to 23 in %s", h.From.In(h.Location).Format("2 Jan"), h.To.In(h.Location).Format("2 Jan 2006"), h.Location)
+}
+
+// peakLines lists the busiest hours
+func (h *Heatmap) peakLines(n int) []string {
+	var lines []string
+	for _, p := range h.Peaks(n) {
+		lines = append(lines, fmt.Sprintf("• %s %02d:00–%02d:00: %d raised, %d replies", p.Day.String()[:3], p.Hour, (p.Hour+1)%24, p.Created, p.Responses))
+	}
+	if len(lines) == 0 {
+		lines = []string{"No activity"}
+	}
+	return lines
+}
+
+// Blocks renders the heatmaps of tickets raised and replies with the n
+// busiest hours as a Slack message. Each day is a section of its own as a
+// whole grid is longer than a section can hold.
+func (h *Heatmap) Blocks(title string, n int) []slack.Block {
+	md := func(s string) *slack.TextBlockObject {
+		return slack.NewTextBlockObject(slack.MarkdownType, s, false, false)
+	}
+	scale := "Quiet " + strings.Join(heatLevels, "") + " Busy"
+	blocks := []slack.Block{
+		slack.NewSectionBlock(md("*"+title+"*"), nil, nil),
+		slack.NewContextBlock("", md(h.period()+" · "+scale)),
+	}
+	for _, g := range []struct {
+		name   string
+		counts *[7][24]int
+	}{{"Tickets raised", &h.Created}, {"Replies by agents", &h.Responses}} {
+		blocks = append(blocks, slack.NewSectionBlock(md("*"+g.name+"*"), nil, nil))
+		for _, row := range grid(g.counts) {
+			blocks = append(blocks, slack.NewSectionBlock(md(row), nil, nil))
+		}
+	}
+	return append(blocks, slack.NewSectionBlock(md("*Peak hours*\n"+strings.Join(h.peakLines(n), "\n")), nil, nil))
+}
+
+// Text renders the n busiest hours, for clients which can not show Blocks
+func (h *Heatmap) Text(title string, n int) string {
+	return fmt.Sprintf("*%s*\n%s\n\n*Peak hours*\n%s", title, h.period(), strings.Join(h.peakLines(n), "\n"))
+}
+
+// WriteCSV writes a row for every hour of the week with a header row
+func (h *Heatmap) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"day", "hour", "created", "responses", "timezone"})
+	for _, hr := range h.Hours() {
+		cw.Write([]string{hr.Day.String(), strconv.Itoa(hr.Hour), strconv.Itoa(hr.Created), strconv.Itoa(hr.Responses), h.Location.String()})
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,90 @@
+package report
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/skybet/go-helpdesk/ticket"
+)
+
+func TestActivity(t *testing.T) {
+	// Monday 12 October 2026
+	from := time.Date(2026, 10, 12, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 0, 7)
+	at := func(day, hour int) time.Time { return from.AddDate(0, 0, day).Add(time.Duration(hour) * time.Hour) }
+	tickets := []*ticket.Ticket{
+		{ID: "1", Reporter: "U1", CreatedAt: at(0, 9), Comments: []ticket.Comment{
+			{Author: "U1", CreatedAt: at(0, 9)},
+			{Author: "UAGENT", CreatedAt: at(0, 10)},
+			{Author: "UAGENT", Internal: true, CreatedAt: at(0, 10)},
+		}},
+		{ID: "2", Reporter: "U2", CreatedAt: at(0, 9)},
+		{ID: "3", Reporter: "U3", CreatedAt: at(6, 23)},
+		// Raised before the period, replied to during it
+		{ID: "4", Reporter: "U4", CreatedAt: at(-1, 9), Comments: []ticket.Comment{{Author: "UAGENT", CreatedAt: at(2, 14)}}},
+	}
+	h := Activity(tickets, from, to, nil)
+	if h.Created[0][9] != 2 || h.Created[6][23] != 1 || h.Responses[0][10] != 2 || h.Responses[2][14] != 1 || h.Responses[0][9] != 0 {
+		t.Errorf("Expected tickets and replies counted in their hours, got %v %v", h.Created, h.Responses)
+	}
+	total := 0
+	for _, hr := range h.Hours() {
+		total += hr.Created
+	}
+	if total != 3 {
+		t.Errorf("Expected tickets raised before the period left out, got %d", total)
+	}
+
+	peaks := h.Peaks(3)
+	if len(peaks) != 3 || peaks[0].Day != time.Monday || peaks[0].Hour != 9 || peaks[1].Hour != 10 || peaks[2].Day != time.Wednesday {
+		t.Errorf("Expected the busiest hours first, got %+v", peaks)
+	}
+	if len(h.Peaks(10)) != 4 {
+		t.Errorf("Expected hours without activity left out, got %+v", h.Peaks(10))
+	}
+
+	// Sunday 23:00 UTC is Monday 01:00 in Madrid in summer time
+	madrid, _ := time.LoadLocation("Europe/Madrid")
+	if h := Activity(tickets, from, to, madrid); h.Created[0][1] != 1 || h.Created[0][11] != 2 {
+		t.Errorf("Expected hours in the timezone, got %v", h.Created)
+	}
+}
+
+func TestHeatmapGrid(t *testing.T) {
+	var counts [7][24]int
+	counts[0][0], counts[0][1], counts[0][2] = 1, 2, 8
+	rows := grid(&counts)
+	if len(rows) != 7 || !strings.HasPrefix(rows[0], "`Mon` :large_green_square::large_green_square::large_red_square::white_large_square:") {
+		t.Errorf("Expected shades relative to the busiest hour, got %q", rows)
+	}
+	if !strings.HasPrefix(rows[6], "`Sun` ") || strings.Contains(rows[6], "green") {
+		t.Errorf("Expected Sunday last and empty, got %q", rows[6])
+	}
+	if rows := grid(&[7][24]int{}); strings.Contains(strings.Join(rows, ""), "green") {
+		t.Errorf("Expected an empty grid without shading, got %q", rows)
+	}
+}
+
+func TestHeatmapRender(t *testing.T) {
+	from := time.Date(2026, 10, 12, 0, 0, 0, 0, time.UTC)
+	h := Activity([]*ticket.Ticket{{CreatedAt: from.Add(9 * time.Hour)}}, from, from.AddDate(0, 0, 28), time.UTC)
+	if text := h.Text("Ticket activity", 5); !strings.Contains(text, "12 Oct to 9 Nov 2026") || !strings.Contains(text, "• Mon 09:00–10:00: 1 raised, 0 replies") {
+		t.Errorf("Expected the period and peak hours, got %q", text)
+	}
+	if blocks := h.Blocks("Ticket activity", 5); len(blocks) != 2+2*8+1 {
+		t.Errorf("Expected a section for each day of both grids, got %d blocks", len(blocks))
+	}
+	if text := (&Heatmap{Location: time.UTC}).Text("Ticket activity", 5); !strings.Contains(text, "No activity") {
+		t.Errorf("Expected no peak hours, got %q", text)
+	}
+
+	var b strings.Builder
+	if err := h.WriteCSV(&b); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	lines := strings.Split(strings.TrimSpace(b.String()), "\n")
+	if len(lines) != 1+7*24 || lines[0] != "day,hour,created,responses,timezone" || lines[10] != "Monday,9,1,0,UTC" || lines[168] != "Sunday,23,0,0,UTC" {
+		t.Errorf("Expected a row for every hour of the week, got %d lines %q", len(lines), lines[:11])
+	}
+}
@@ -0,0 +1,57 @@
+// Package report summarises helpdesk tickets for agents and leads
+package report
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/skybet/go-helpdesk/store"
+	"github.com/skybet/go-helpdesk/ticket"
+)
+
+// pageSize is how many tickets All fetches at a time
+const pageSize = 500
+
+// All returns every ticket matching f, following the store's pagination
+func All(ctx context.Context, s store.Store, f store.Filter) ([]*ticket.Ticket, error) {
+	var all []*ticket.Ticket
+	f.Limit = pageSize
+	for {
+		page, next, err := s.ListTickets(ctx, f)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, page...)
+		if next == "" {
+			return all, nil
+		}
+		f.Cursor = next
+	}
+}
+
+// median returns the median of ds, zero if there are none
+func median(ds []time.Duration) time.Duration {
+	if len(ds) == 0 {
+		return 0
+	}
+	sorted := append([]time.Duration(nil), ds...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[mid-1] + sorted[mid]) / 2
+	}
+	return sorted[mid]
+}
+
+// humanize formats a duration to the nearest minute, or hour when longer than
+// a day
+func humanize(d time.Duration) string {
+	if d == 0 {
+		return "n/a"
+	}
+	if d >= 24*time.Hour {
+		return d.Round(time.Hour).String()
+	}
+	return d.Round(time.Minute).String()
+}
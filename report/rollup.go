@@ -0,0 +1,128 @@
+package report
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/skybet/go-helpdesk/hierarchy"
+	"github.com/skybet/go-helpdesk/projection"
+	"github.com/skybet/go-helpdesk/render"
+	"github.com/skybet/go-helpdesk/sla"
+)
+
+// otherQueues is the department rolling up the queues outside the hierarchy
+const otherQueues = "Other queues"
+
+// Rollup is the open tickets of a unit of the hierarchy, summed over the
+// units beneath it
+type Rollup struct {
+	Name     string          `json:"name"`
+	Level    hierarchy.Level `json:"level"`
+	Open     int             `json:"open"`
+	Assigned int             `json:"assigned"`
+	// AtRisk and Breached count the open tickets about to miss, or which
+	// have missed, an SLA target
+	AtRisk   int       `json:"at_risk"`
+	Breached int       `json:"breached"`
+	Units    []*Rollup `json:"units,omitempty"`
+}
+
+// BuildRollup rolls the open tickets in each queue and those at risk up the
+// hierarchy. Queues with open tickets which are not in it are rolled up into
+// a department of their own.
+func BuildRollup(tree *hierarchy.Tree, queues map[string]projection.Queue, risks []sla.Risk, now time.Time) *Rollup {
+	at := map[string]*Rollup{}
+	leaf := func(name string) *Rollup {
+		q := queues[name]
+		r := &Rollup{Name: name, Level: hierarchy.Queue, Open: q.Open, Assigned: q.Assigned}
+		at[name] = r
+		return r
+	}
+	root := &Rollup{Name: tree.Root.Name, Level: hierarchy.Organisation}
+	for _, d := range tree.Root.Departments {
+		dept := &Rollup{Name: d.Name, Level: hierarchy.Department}
+		for _, q := range d.Queues {
+			dept.Units = append(dept.Units, leaf(q.Name))
+		}
+		root.Units = append(root.Units, dept)
+	}
+	other := &Rollup{Name: otherQueues, Level: hierarchy.Department}
+	var outside []string
+	for name := range queues {
+		if _, ok := tree.Department(name); !ok {
+			outside = append(outside, name)
+		}
+	}
+	sort.Strings(outside)
+	for _, name := range outside {
+		other.Units = append(other.Units, leaf(name))
+	}
+	if len(other.Units) > 0 {
+		root.Units = append(root.Units, other)
+	}
+	for _, r := range risks {
+		q, ok := at[r.Ticket.Queue]
+		if !ok {
+			continue
+		}
+		if r.Breached(now) {
+			q.Breached++
+		} else {
+			q.AtRisk++
+		}
+	}
+	root.sum()
+	return root
+}
+
+// sum adds the units beneath r up into it
+func (r *Rollup) sum() {
+	if len(r.Units) == 0 {
+		return
+	}
+	r.Open, r.Assigned, r.AtRisk, r.Breached = 0, 0, 0, 0
+	for _, u := range r.Units {
+		u.sum()
+		r.Open += u.Open
+		r.Assigned += u.Assigned
+		r.AtRisk += u.AtRisk
+		r.Breached += u.Breached
+	}
+}
+
+// Find returns the unit with a name at or beneath r, case insensitively, nil
+// if there is none
+func (r *Rollup) Find(name string) *Rollup {
+	if strings.EqualFold(r.Name, name) {
+		return r
+	}
+	for _, u := range r.Units {
+		if found := u.Find(name); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// Text renders the unit's dashboard in style s: its totals, then a line for
+// each unit directly beneath it
+func (r *Rollup) Text(s render.Style) string {
+	lines := []string{fmt.Sprintf("*%s dashboard* (%s)", r.Name, r.Level), r.line("Total", s)}
+	for _, u := range r.Units {
+		lines = append(lines, u.line(queueName(u.Name), s))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (r *Rollup) line(name string, s render.Style) string {
+	line := fmt.Sprintf("%s: *%d* open, %d in progress", name, r.Open, r.Assigned)
+	if r.AtRisk > 0 {
+		line += fmt.Sprintf(", %s %d at risk", render.AtRisk.In(s), r.AtRisk)
+	}
+	if r.Breached > 0 {
+		line += fmt.Sprintf(", %s %d breached", render.Breached.In(s), r.Breached)
+	}
+	return line
+}
@@ -0,0 +1,97 @@
+package report
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/skybet/go-helpdesk/hierarchy"
+	"github.com/skybet/go-helpdesk/projection"
+	"github.com/skybet/go-helpdesk/render"
+	"github.com/skybet/go-helpdesk/sla"
+	"github.com/skybet/go-helpdesk/store"
+	"github.com/skybet/go-helpdesk/ticket"
+)
+
+func TestBuildRollup(t *testing.T) {
+	tree, err := hierarchy.New(hierarchy.Unit{Name: "Acme", Departments: []hierarchy.Unit{
+		{Name: "IT", Queues: []hierarchy.Unit{{Name: "it"}, {Name: "it-hardware"}}},
+		{Name: "People", Queues: []hierarchy.Unit{{Name: "payroll"}}},
+	}}, sla.SLA{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	now := time.Date(2019, 3, 4, 9, 0, 0, 0, time.UTC)
+	queues := map[string]projection.Queue{
+		"it":          {Open: 3, Assigned: 1},
+		"it-hardware": {Open: 2, Assigned: 2},
+		"":            {Open: 1},
+	}
+	risks := []sla.Risk{
+		{Ticket: &ticket.Ticket{Queue: "it"}, Due: now.Add(time.Minute)},
+		{Ticket: &ticket.Ticket{Queue: "it-hardware"}, Due: now.Add(-time.Minute)},
+	}
+	r := BuildRollup(tree, queues, risks, now)
+
+	if r.Open != 6 || r.Assigned != 3 || r.AtRisk != 1 || r.Breached != 1 || len(r.Units) != 3 {
+		t.Errorf("Expected the organisation to add up every queue, got %+v", r)
+	}
+	it := r.Find("it")
+	if it == nil || it.Level != hierarchy.Department || it.Open != 5 || it.Assigned != 3 || len(it.Units) != 2 {
+		t.Errorf("Expected IT to add up its queues, got %+v", it)
+	}
+	if people := r.Find("People"); people == nil || people.Open != 0 || len(people.Units) != 1 {
+		t.Errorf("Expected People to be listed without open tickets, got %+v", people)
+	}
+	if other := r.Find(otherQueues); other == nil || other.Open != 1 || other.Units[0].Name != "" {
+		t.Errorf("Expected the queues outside the hierarchy to have a department of their own, got %+v", other)
+	}
+
+	text := it.Text(render.Plain)
+	for _, want := range []string{"*IT dashboard* (department)", "Total: *5* open, 3 in progress", "it-hardware: *2* open, 2 in progress, [SLA breached] 1 breached"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("Expected the dashboard to contain %q, got %s", want, text)
+		}
+	}
+}
+
+func TestRollupAPI(t *testing.T) {
+	now := time.Date(2019, 3, 4, 9, 0, 0, 0, time.UTC)
+	s := store.NewMemory()
+	s.CreateTicket(context.Background(), &ticket.Ticket{Queue: "payroll", Status: ticket.StatusNew, CreatedAt: now})
+	p := projection.New(sla.SLA{}, 0)
+	p.Load(context.Background(), s)
+	a := NewAPI(p, "secret")
+	a.now = func() time.Time { return now }
+	get := func(path string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("GET", path, nil)
+		r.Header.Set("Authorization", "Bearer secret")
+		w := httptest.NewRecorder()
+		a.ServeHTTP(w, r)
+		return w
+	}
+	if w := get("/rollup"); w.Code != http.StatusNotFound {
+		t.Errorf("Expected no rollup without a hierarchy, got %d", w.Code)
+	}
+
+	tree, err := hierarchy.New(hierarchy.Unit{Name: "Acme", Departments: []hierarchy.Unit{{Name: "People", Queues: []hierarchy.Unit{{Name: "payroll"}}}}}, sla.SLA{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	a.Hierarchy = tree
+	w := get("/rollup?unit=people")
+	var body Rollup
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("Expected the department's rollup, got %d: %s", w.Code, w.Body)
+	}
+	if body.Name != "People" || body.Level != hierarchy.Department || body.Open != 1 || len(body.Units) != 1 || body.Units[0].Name != "payroll" {
+		t.Errorf("Expected People's open ticket in payroll, got %+v", body)
+	}
+	if w := get("/rollup?unit=finance"); w.Code != http.StatusNotFound {
+		t.Errorf("Expected an unknown unit to be not found, got %d", w.Code)
+	}
+}
@@ -0,0 +1,153 @@
+package report
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/skybet/go-helpdesk/sla"
+	"github.com/skybet/go-helpdesk/ticket"
+)
+
+// Scorecard summarises the tickets an agent resolved over a period. The team
+// scorecard has an empty Agent.
+type Scorecard struct {
+	Agent    string
+	From, To time.Time
+	// Handled is the number of tickets resolved
+	Handled          int
+	MedianResponse   time.Duration
+	MedianResolution time.Duration
+	// ResponseSLA and ResolutionSLA count the handled tickets which met their
+	// first response and resolution targets
+	ResponseSLA   Compliance
+	ResolutionSLA Compliance
+	// CSAT is the mean satisfaction rating of the Rated tickets
+	CSAT  float64
+	Rated int
+	// ReopenedRate is the fraction of handled tickets which were reopened
+	ReopenedRate float64
+	// Appreciation counts the appreciation reactions to the agent's replies
+	// over the period, on any ticket. The team scorecard counts every
+	// reaction and breaks them down ByQueue.
+	Appreciation int
+	ByQueue      map[string]int
+}
+
+// Compliance counts the tickets which met and breached an SLA target
+type Compliance struct {
+	Met      int
+	Breached int
+}
+
+func (c *Compliance) add(r sla.Result) {
+	switch r {
+	case sla.Met:
+		c.Met++
+	case sla.Breached:
+		c.Breached++
+	}
+}
+
+// String returns the percentage of tickets which met the target
+func (c Compliance) String() string {
+	total := c.Met + c.Breached
+	if total == 0 {
+		return "n/a"
+	}
+	return fmt.Sprintf("%.0f%% (%d of %d)", float64(c.Met)/float64(total)*100, c.Met, total)
+}
+
+// Scorecards returns a scorecard for each agent with tickets resolved between
+// from and to, ordered by agent. Tickets are measured against the targets in s.
+func Scorecards(tickets []*ticket.Ticket, from, to time.Time, s sla.SLA) []Scorecard {
+	byAgent := map[string][]*ticket.Ticket{}
+	for _, t := range resolved(tickets, from, to) {
+		if t.Assignee != "" {
+			byAgent[t.Assignee] = append(byAgent[t.Assignee], t)
+		}
+	}
+	appreciation := Appreciated(tickets, from, to)
+	var cards []Scorecard
+	for agent, ts := range byAgent {
+		c := score(ts, from, to, s)
+		c.Agent = agent
+		c.Appreciation = appreciation.ByAgent[agent]
+		cards = append(cards, c)
+	}
+	sort.Slice(cards, func(i, j int) bool { return cards[i].Agent < cards[j].Agent })
+	return cards
+}
+
+// Team returns the scorecard for every ticket resolved between from and to
+func Team(tickets []*ticket.Ticket, from, to time.Time, s sla.SLA) Scorecard {
+	c := score(resolved(tickets, from, to), from, to, s)
+	appreciation := Appreciated(tickets, from, to)
+	c.Appreciation, c.ByQueue = appreciation.Total, appreciation.ByQueue
+	return c
+}
+
+func resolved(tickets []*ticket.Ticket, from, to time.Time) []*ticket.Ticket {
+	var rs []*ticket.Ticket
+	for _, t := range tickets {
+		if !t.ResolvedAt.IsZero() && !t.ResolvedAt.Before(from) && t.ResolvedAt.Before(to) {
+			rs = append(rs, t)
+		}
+	}
+	return rs
+}
+
+func score(tickets []*ticket.Ticket, from, to time.Time, s sla.SLA) Scorecard {
+	c := Scorecard{From: from, To: to, Handled: len(tickets)}
+	var responses, resolutions []time.Duration
+	var csat, reopened int
+	for _, t := range tickets {
+		if !t.FirstResponseAt.IsZero() {
+			responses = append(responses, t.FirstResponseAt.Sub(t.CreatedAt))
+		}
+		resolutions = append(resolutions, t.ResolvedAt.Sub(t.CreatedAt))
+		c.ResponseSLA.add(s.Responded(t, t.ResolvedAt))
+		c.ResolutionSLA.add(s.Resolved(t, t.ResolvedAt))
+		if t.CSAT > 0 {
+			csat += t.CSAT
+			c.Rated++
+		}
+		if t.Reopened > 0 {
+			reopened++
+		}
+	}
+	c.MedianResponse = median(responses)
+	c.MedianResolution = median(resolutions)
+	if c.Rated > 0 {
+		c.CSAT = float64(csat) / float64(c.Rated)
+	}
+	if c.Handled > 0 {
+		c.ReopenedRate = float64(reopened) / float64(c.Handled)
+	}
+	return c
+}
+
+// Text renders the scorecard as a Slack message
+func (c Scorecard) Text() string {
+	who := "The team"
+	if c.Agent != "" {
+		who = fmt.Sprintf("<@%s>", c.Agent)
+	}
+	csat := "n/a"
+	if c.Rated > 0 {
+		csat = fmt.Sprintf("%.1f/5 from %d ratings", c.CSAT, c.Rated)
+	}
+	appreciation := fmt.Sprintf("%d reactions", c.Appreciation)
+	if c.Agent == "" && c.Appreciation > 0 {
+		appreciation += fmt.Sprintf(" (%s)", Appreciation{ByQueue: c.ByQueue}.Queues())
+	}
+	return fmt.Sprintf("*Scorecard for %s, %s to %s*\n"+
+		"Tickets handled: %d\n"+
+		"Median first response: %s, within SLA: %s\n"+
+		"Median resolution: %s, within SLA: %s\n"+
+		"CSAT: %s\n"+
+		"Reopened: %.0f%%\n"+
+		"Appreciation: %s",
+		who, c.From.Format("2 Jan"), c.To.Add(-time.Nanosecond).Format("2 Jan 2006"),
+		c.Handled, humanize(c.MedianResponse), c.ResponseSLA, humanize(c.MedianResolution), c.ResolutionSLA, csat, c.ReopenedRate*100, appreciation)
+}
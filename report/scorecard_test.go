@@ -0,0 +1,131 @@
+package report
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nlopes/slack"
+
+	"github.com/skybet/go-helpdesk/sla"
+	"github.com/skybet/go-helpdesk/store"
+	"github.com/skybet/go-helpdesk/ticket"
+)
+
+var month = time.Date(2019, time.March, 1, 0, 0, 0, 0, time.UTC)
+
+func resolvedTicket(agent string, response, resolution time.Duration, csat, reopened int) *ticket.Ticket {
+	created := month.Add(24 * time.Hour)
+	t := &ticket.Ticket{Assignee: agent, CreatedAt: created, ResolvedAt: created.Add(resolution), CSAT: csat, Reopened: reopened, Status: ticket.StatusResolved}
+	if response > 0 {
+		t.FirstResponseAt = created.Add(response)
+	}
+	return t
+}
+
+func TestScorecards(t *testing.T) {
+	tickets := []*ticket.Ticket{
+		resolvedTicket("U1", 10*time.Minute, time.Hour, 5, 0),
+		resolvedTicket("U1", 20*time.Minute, 3*time.Hour, 3, 1),
+		resolvedTicket("U1", 0, 2*time.Hour, 0, 0),
+		resolvedTicket("U2", time.Minute, time.Hour, 4, 0),
+		// Resolved outside the month
+		resolvedTicket("U2", time.Minute, 40*24*time.Hour, 1, 0),
+		// Still open
+		{Assignee: "U2", CreatedAt: month, Status: ticket.StatusInProgress},
+	}
+	from, to := LastMonth(month.AddDate(0, 1, 0))
+	targets := sla.SLA{Response: sla.Targets{0: 15 * time.Minute}, Resolution: sla.Targets{0: 2 * time.Hour}}
+	cards := Scorecards(tickets, from, to, targets)
+	if len(cards) != 2 || cards[0].Agent != "U1" || cards[1].Agent != "U2" {
+		t.Fatalf("Expected a scorecard per agent, got %+v", cards)
+	}
+	u1 := cards[0]
+	if u1.Handled != 3 || u1.MedianResponse != 15*time.Minute || u1.MedianResolution != 2*time.Hour {
+		t.Errorf("Unexpected counts and times: %+v", u1)
+	}
+	if u1.CSAT != 4 || u1.Rated != 2 || u1.ReopenedRate != 1.0/3 {
+		t.Errorf("Unexpected CSAT and reopened rate: %+v", u1)
+	}
+	// Resolving a ticket without a reply is its first response
+	if u1.ResponseSLA != (Compliance{Met: 1, Breached: 2}) || u1.ResolutionSLA != (Compliance{Met: 2, Breached: 1}) {
+		t.Errorf("Unexpected SLA compliance: %+v %+v", u1.ResponseSLA, u1.ResolutionSLA)
+	}
+	if team := Team(tickets, from, to, targets); team.Handled != 4 || team.Agent != "" {
+		t.Errorf("Unexpected team scorecard: %+v", team)
+	}
+	if text := u1.Text(); !strings.Contains(text, "<@U1>, 1 Mar to 31 Mar 2019") || !strings.Contains(text, "Reopened: 33%") || !strings.Contains(text, "within SLA: 33% (1 of 3)") {
+		t.Errorf("Unexpected scorecard text: %s", text)
+	}
+}
+
+type recordingPoster map[string]string
+
+func (p recordingPoster) PostMessage(channelID string, options ...slack.MsgOption) (string, string, error) {
+	if channelID == "UBROKEN" {
+		return "", "", errors.New("user_not_found")
+	}
+	_, values, _ := slack.UnsafeApplyMsgOptions("", channelID, "", options...)
+	p[channelID] = values.Get("text")
+	return channelID, "1", nil
+}
+
+func TestScorecardDelivery(t *testing.T) {
+	s := store.NewMemory()
+	for _, tk := range []*ticket.Ticket{
+		resolvedTicket("U1", time.Minute, time.Hour, 5, 0),
+		resolvedTicket("U2", time.Minute, time.Hour, 4, 0),
+	} {
+		s.CreateTicket(context.Background(), tk)
+	}
+	p := recordingPoster{}
+	d := &ScorecardDelivery{Store: s, Poster: p, Leads: []string{"ULEAD", "UBROKEN"}, OptOut: []string{"U2"}}
+
+	err := d.Deliver(context.Background(), month, month.AddDate(0, 1, 0))
+	if err == nil || !strings.Contains(err.Error(), "UBROKEN") {
+		t.Errorf("Expected the failed delivery to be reported, got %v", err)
+	}
+	if _, ok := p["U1"]; !ok {
+		t.Errorf("Expected U1 to be sent their scorecard")
+	}
+	if _, ok := p["U2"]; ok {
+		t.Errorf("Expected U2 to have opted out")
+	}
+	if lead := p["ULEAD"]; !strings.Contains(lead, "The team") || !strings.Contains(lead, "<@U2>") {
+		t.Errorf("Expected leads to see every scorecard, got %s", lead)
+	}
+}
+
+func TestLastMonth(t *testing.T) {
+	from, to := LastMonth(time.Date(2019, time.January, 31, 12, 0, 0, 0, time.UTC))
+	if !from.Equal(time.Date(2018, time.December, 1, 0, 0, 0, 0, time.UTC)) || !to.Equal(time.Date(2019, time.January, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Unexpected bounds %s to %s", from, to)
+	}
+}
+
+func TestScorecardAppreciation(t *testing.T) {
+	from, to := LastMonth(month.AddDate(0, 1, 0))
+	in, out := from.Add(time.Hour), from.Add(-time.Hour)
+	resolved := resolvedTicket("U1", time.Minute, time.Hour, 0, 0)
+	resolved.Queue = "it"
+	resolved.Reactions = []ticket.Reaction{{Emoji: "pray", By: "U9", For: "U1", At: in}, {Emoji: "tada", By: "U9", For: "U1", At: out}}
+	open := &ticket.Ticket{Queue: "hr", Assignee: "U2", Status: ticket.StatusInProgress, Reactions: []ticket.Reaction{
+		{Emoji: "pray", By: "U8", For: "U1", At: in},
+		{Emoji: "tada", By: "U8", For: "U2", At: in},
+	}}
+	tickets := []*ticket.Ticket{resolved, open}
+
+	cards := Scorecards(tickets, from, to, sla.SLA{})
+	if len(cards) != 1 || cards[0].Appreciation != 2 {
+		t.Errorf("Expected U1's replies to be appreciated twice in the month, got %+v", cards)
+	}
+	team := Team(tickets, from, to, sla.SLA{})
+	if team.Appreciation != 3 || team.ByQueue["hr"] != 2 || team.ByQueue["it"] != 1 {
+		t.Errorf("Unexpected team appreciation: %d %v", team.Appreciation, team.ByQueue)
+	}
+	if text := team.Text(); !strings.Contains(text, "Appreciation: 3 reactions (hr 2, it 1)") {
+		t.Errorf("Unexpected team scorecard text: %s", text)
+	}
+}
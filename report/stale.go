@@ -0,0 +1,222 @@
+package report
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nlopes/slack"
+
+	"github.com/skybet/go-helpdesk/archive"
+	"github.com/skybet/go-helpdesk/clock"
+	"github.com/skybet/go-helpdesk/links"
+	"github.com/skybet/go-helpdesk/notify"
+	"github.com/skybet/go-helpdesk/render"
+	"github.com/skybet/go-helpdesk/store"
+	"github.com/skybet/go-helpdesk/ticket"
+)
+
+// Stage is how far an idle ticket has been chased
+type Stage int
+
+// The stages of an idle ticket
+const (
+	Active Stage = iota
+	// Stale tickets are nudged in their thread
+	Stale
+	// Escalated tickets are reported to the leads
+	Escalated
+)
+
+// Aged is an open ticket and how long it has gone without activity
+type Aged struct {
+	Ticket *ticket.Ticket
+	Idle   time.Duration
+	Stage  Stage
+}
+
+// Sweeper chases open tickets which have had no activity, nudging them in
+// their thread after NudgeAfter and escalating them to the leads after
+// EscalateAfter. Each ticket is only nudged and escalated once until there is
+// activity on it again.
+type Sweeper struct {
+	Store  store.Store
+	Poster Poster
+	// NudgeAfter and EscalateAfter are how long a ticket can be idle for
+	// before it is nudged and escalated, zero disables that stage
+	NudgeAfter    time.Duration
+	EscalateAfter time.Duration
+	// Leads are the user IDs sent escalations
+	Leads []string
+	// Links, if set, links tickets in escalations to their thread
+	Links *links.Links
+	// Styles are the leads' notification styles
+	Styles *render.Preferences
+	// Clock, if set, replaces the wall clock
+	Clock clock.Clock
+
+	mu    sync.Mutex
+	swept map[string]swept
+}
+
+// swept records the stage a ticket was chased to and its UpdatedAt at the
+// time, so that later activity starts it over
+type swept struct {
+	updated time.Time
+	stage   Stage
+}
+
+// Aging returns the open tickets as of now, longest idle first
+func (s *Sweeper) Aging(ctx context.Context, now time.Time) ([]Aged, error) {
+	tickets, err := All(ctx, s.Store, store.Filter{})
+	if err != nil {
+		return nil, fmt.Errorf("error listing tickets: %s", err)
+	}
+	var aged []Aged
+	for _, t := range tickets {
+		if !t.Status.Open() {
+			continue
+		}
+		idle := now.Sub(t.UpdatedAt)
+		aged = append(aged, Aged{Ticket: t, Idle: idle, Stage: s.stage(idle)})
+	}
+	sort.SliceStable(aged, func(i, j int) bool { return aged[i].Idle > aged[j].Idle })
+	return aged, nil
+}
+
+func (s *Sweeper) stage(idle time.Duration) Stage {
+	switch {
+	case s.EscalateAfter > 0 && idle >= s.EscalateAfter:
+		return Escalated
+	case s.NudgeAfter > 0 && idle >= s.NudgeAfter:
+		return Stale
+	}
+	return Active
+}
+
+// Sweep nudges and escalates the tickets which have become idle since the
+// last sweep
+func (s *Sweeper) Sweep(ctx context.Context, now time.Time) error {
+	aged, err := s.Aging(ctx, now)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.swept == nil {
+		s.swept = map[string]swept{}
+	}
+	open := map[string]bool{}
+	var escalate []Aged
+	var errs []string
+	for _, a := range aged {
+		t := a.Ticket
+		open[t.ID] = true
+		prev := s.swept[t.ID]
+		if !prev.updated.Equal(t.UpdatedAt) {
+			prev = swept{updated: t.UpdatedAt}
+		}
+		if a.Stage <= prev.stage {
+			continue
+		}
+		if t.ChannelID != "" && t.ThreadTS != "" && !archive.Locked(t) {
+			if _, _, err := s.Poster.PostMessage(t.ChannelID, slack.MsgOptionTS(t.ThreadTS), slack.MsgOptionText(nudge(a), false)); err != nil {
+				errs = append(errs, fmt.Sprintf("#%s: %s", t.ID, err))
+				continue
+			}
+		}
+		if a.Stage == Escalated {
+			escalate = append(escalate, a)
+		}
+		s.swept[t.ID] = swept{updated: t.UpdatedAt, stage: a.Stage}
+	}
+	for id := range s.swept {
+		if !open[id] {
+			delete(s.swept, id)
+		}
+	}
+
+	if len(escalate) > 0 {
+		post := s.Poster.PostMessage
+		if u, ok := s.Poster.(notify.UrgentPoster); ok && urgent(escalate) {
+			post = u.PostUrgent
+		}
+		for _, lead := range s.Leads {
+			style := s.Styles.Style(lead)
+			text := fmt.Sprintf("*%d tickets have had no activity for over %s*\n%s", len(escalate), humanize(s.EscalateAfter), AgingText(escalate, s.Links, style))
+			if _, _, err := post(lead, render.Message(style, text)...); err != nil {
+				errs = append(errs, fmt.Sprintf("%s: %s", lead, err))
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("error chasing idle tickets: %s", strings.Join(errs, ", "))
+	}
+	return nil
+}
+
+// urgent reports whether any of the escalated tickets are P1, which may break
+// through the leads' quiet hours
+func urgent(escalate []Aged) bool {
+	for _, a := range escalate {
+		if a.Ticket.Priority == ticket.P1 {
+			return true
+		}
+	}
+	return false
+}
+
+// nudge is the message posted in an idle ticket's thread
+func nudge(a Aged) string {
+	who := a.Ticket.Assignee
+	if who == "" {
+		who = a.Ticket.Reporter
+	}
+	text := fmt.Sprintf("This ticket has had no activity for %s.", humanize(a.Idle))
+	if who != "" {
+		text += fmt.Sprintf(" <@%s> is there an update?", who)
+	}
+	if a.Stage == Escalated {
+		text += " It has been escalated to the helpdesk leads."
+	}
+	return text
+}
+
+// Run sweeps every interval until ctx is cancelled
+func (s *Sweeper) Run(ctx context.Context, interval time.Duration, errorf func(string, ...interface{})) {
+	t := clock.Or(s.Clock).NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-t.C():
+			if err := s.Sweep(ctx, now); err != nil {
+				errorf("Stale ticket sweep failed: %s", err)
+			}
+		}
+	}
+}
+
+// AgingText lists aged tickets one per line in style s, referring to them
+// with l
+func AgingText(aged []Aged, l *links.Links, s render.Style) string {
+	lines := make([]string, 0, len(aged))
+	for _, a := range aged {
+		line := fmt.Sprintf("• %s %s (%s) idle for %s", l.Ref(a.Ticket.ID), a.Ticket.Title, queueName(a.Ticket.Queue), humanize(a.Idle))
+		if a.Ticket.Assignee != "" {
+			line += fmt.Sprintf(", assigned to <@%s>", a.Ticket.Assignee)
+		}
+		switch a.Stage {
+		case Stale:
+			line += " " + render.Stale.In(s)
+		case Escalated:
+			line += " " + render.Escalated.In(s)
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,82 @@
+package report
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nlopes/slack"
+
+	"github.com/skybet/go-helpdesk/render"
+	"github.com/skybet/go-helpdesk/store"
+	"github.com/skybet/go-helpdesk/ticket"
+)
+
+type post struct{ channel, thread, text string }
+
+// postLog records posts in order, with their thread
+type postLog struct{ posts []post }
+
+func (p *postLog) PostMessage(channelID string, options ...slack.MsgOption) (string, string, error) {
+	_, values, _ := slack.UnsafeApplyMsgOptions("", channelID, "", options...)
+	p.posts = append(p.posts, post{channelID, values.Get("thread_ts"), values.Get("text")})
+	return channelID, "1", nil
+}
+
+func TestSweeper(t *testing.T) {
+	s := store.NewMemory()
+	s.CreateTicket(context.Background(), &ticket.Ticket{ID: "1", Title: "VPN", Queue: "it", Assignee: "U1", ChannelID: "C1", ThreadTS: "1.1"})
+	s.CreateTicket(context.Background(), &ticket.Ticket{ID: "2", Title: "Laptop", Queue: "it", Reporter: "U2"})
+	s.CreateTicket(context.Background(), &ticket.Ticket{ID: "3", Title: "Done", Status: ticket.StatusResolved, ChannelID: "C1", ThreadTS: "2.1"})
+	p := &postLog{}
+	sw := &Sweeper{Store: s, Poster: p, NudgeAfter: 72 * time.Hour, EscalateAfter: 7 * 24 * time.Hour, Leads: []string{"ULEAD"}}
+	now := time.Now()
+
+	if err := sw.Sweep(context.Background(), now); err != nil || len(p.posts) != 0 {
+		t.Fatalf("Expected nothing to be chased yet, got %v %v", p.posts, err)
+	}
+	if err := sw.Sweep(context.Background(), now.Add(80*time.Hour)); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if len(p.posts) != 1 || p.posts[0].thread != "1.1" || !strings.Contains(p.posts[0].text, "<@U1> is there an update?") {
+		t.Fatalf("Expected the idle ticket to be nudged in its thread, got %+v", p.posts)
+	}
+	// Nudges are not repeated
+	sw.Sweep(context.Background(), now.Add(90*time.Hour))
+	if len(p.posts) != 1 {
+		t.Fatalf("Expected a single nudge, got %+v", p.posts)
+	}
+
+	sw.Sweep(context.Background(), now.Add(8*24*time.Hour))
+	if len(p.posts) != 3 || p.posts[2].channel != "ULEAD" || !strings.Contains(p.posts[2].text, "#1 VPN") || !strings.Contains(p.posts[2].text, "#2 Laptop") {
+		t.Fatalf("Expected both tickets to be escalated to the lead, got %+v", p.posts)
+	}
+
+	// Activity starts the ticket over
+	tk, _ := s.GetTicket(context.Background(), "1")
+	s.UpdateTicket(context.Background(), tk)
+	p.posts = nil
+	sw.Sweep(context.Background(), time.Now().Add(time.Hour))
+	if len(p.posts) != 0 {
+		t.Errorf("Expected active tickets to be left alone, got %+v", p.posts)
+	}
+}
+
+func TestAging(t *testing.T) {
+	s := store.NewMemory()
+	s.CreateTicket(context.Background(), &ticket.Ticket{ID: "1", Title: "New"})
+	s.CreateTicket(context.Background(), &ticket.Ticket{ID: "2", Title: "Old"})
+	tk, _ := s.GetTicket(context.Background(), "1")
+	time.Sleep(time.Millisecond)
+	s.UpdateTicket(context.Background(), tk)
+	sw := &Sweeper{Store: s, NudgeAfter: time.Hour}
+
+	aged, err := sw.Aging(context.Background(), time.Now().Add(2*time.Hour))
+	if err != nil || len(aged) != 2 || aged[0].Ticket.ID != "2" || aged[0].Stage != Stale {
+		t.Fatalf("Expected the oldest ticket first, got %+v %v", aged, err)
+	}
+	if text := AgingText(aged, nil, render.Rich); !strings.Contains(text, "#2 Old (No queue) idle for 2h0m0s :zzz:") {
+		t.Errorf("Unexpected aging text %q", text)
+	}
+}
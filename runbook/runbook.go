@@ -0,0 +1,216 @@
+// Package runbook suggests the runbook for a ticket's category to whoever
+// works on it, and counts how often each runbook is opened so that the ones
+// nobody uses can be found and fixed or retired
+package runbook
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/skybet/go-helpdesk/atomicfile"
+	"github.com/skybet/go-helpdesk/clock"
+	"github.com/skybet/go-helpdesk/ticket"
+)
+
+// Runbook is the runbook suggested for a category of tickets
+type Runbook struct {
+	// Category is the tag of the tickets it is for
+	Category string
+	// URL is the runbook, such as a wiki page or a Slack canvas
+	URL string
+	// Link opens the runbook, through the Handler if it is tracked
+	Link string
+}
+
+// Usage is how a runbook has been used
+type Usage struct {
+	Category string `json:"category"`
+	// Suggested counts the assignees who were sent the runbook
+	Suggested int `json:"suggested"`
+	// Opened counts the times it was opened from a suggestion or a card
+	Opened     int       `json:"opened"`
+	LastOpened time.Time `json:"last_opened,omitempty"`
+}
+
+// Parse parses runbooks in the form <category>=<url>, e.g.
+// vpn=https://wiki.example.com/vpn
+func Parse(specs []string) (map[string]string, error) {
+	runbooks := map[string]string{}
+	for _, spec := range specs {
+		parts := strings.SplitN(spec, "=", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" {
+			return nil, fmt.Errorf("invalid runbook %q, expected <category>=<url>", spec)
+		}
+		u, err := url.Parse(parts[1])
+		if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			return nil, fmt.Errorf("invalid runbook %q, expected an http or https URL", spec)
+		}
+		runbooks[strings.ToLower(strings.TrimSpace(parts[0]))] = parts[1]
+	}
+	return runbooks, nil
+}
+
+// Runbooks maps categories of tickets to their runbooks and keeps their
+// usage. A nil *Runbooks suggests nothing.
+type Runbooks struct {
+	// BaseURL is where the Handler is mounted, e.g.
+	// https://helpdesk.example.com/runbooks. Links go straight to the
+	// runbooks, so their opening is not counted, if it is empty.
+	BaseURL string
+	// Clock, if set, replaces the wall clock
+	Clock clock.Clock
+
+	path  string
+	mu    sync.Mutex
+	urls  map[string]string
+	usage map[string]*Usage
+}
+
+// New returns the runbooks of each category, linked through baseURL, whose
+// usage is kept in memory
+func New(urls map[string]string, baseURL string) *Runbooks {
+	return &Runbooks{BaseURL: strings.TrimSuffix(baseURL, "/"), urls: urls, usage: map[string]*Usage{}}
+}
+
+// Load returns the runbooks of each category whose usage is saved in the JSON
+// file at path, which is created by the first use if it does not exist
+func Load(urls map[string]string, baseURL, path string) (*Runbooks, error) {
+	r := New(urls, baseURL)
+	r.path = path
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return r, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading runbook usage: %s", err)
+	}
+	var usage []Usage
+	if err := json.Unmarshal(b, &usage); err != nil {
+		return nil, fmt.Errorf("error decoding runbook usage in %s: %s", path, err)
+	}
+	for i := range usage {
+		r.usage[usage[i].Category] = &usage[i]
+	}
+	return r, nil
+}
+
+// For returns the runbooks of a ticket's tags, in the order of the tags
+func (r *Runbooks) For(t *ticket.Ticket) []Runbook {
+	if r == nil {
+		return nil
+	}
+	var runbooks []Runbook
+	seen := map[string]bool{}
+	for _, tag := range t.Tags {
+		category := strings.ToLower(tag)
+		u, ok := r.urls[category]
+		if !ok || seen[u] {
+			continue
+		}
+		seen[u] = true
+		runbooks = append(runbooks, Runbook{Category: category, URL: u, Link: r.link(category, u)})
+	}
+	return runbooks
+}
+
+func (r *Runbooks) link(category, u string) string {
+	if r.BaseURL == "" {
+		return u
+	}
+	return r.BaseURL + "/" + url.PathEscape(category)
+}
+
+// Suggested counts the runbooks being sent to an assignee
+func (r *Runbooks) Suggested(runbooks []Runbook) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, rb := range runbooks {
+		r.get(rb.Category).Suggested++
+	}
+	return r.save()
+}
+
+// Usage returns the usage of every runbook, least opened first. Runbooks
+// which have never been used are included with zero counts.
+func (r *Runbooks) Usage() []Usage {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var usage []Usage
+	for category := range r.urls {
+		usage = append(usage, *r.get(category))
+	}
+	sort.Slice(usage, func(i, j int) bool {
+		if usage[i].Opened != usage[j].Opened {
+			return usage[i].Opened < usage[j].Opened
+		}
+		return usage[i].Category < usage[j].Category
+	})
+	return usage
+}
+
+// Handler counts each runbook opened through a link, redirecting to it.
+// Mount it at BaseURL with http.StripPrefix. Failures to save the usage are
+// passed to logf, the runbook is opened anyway.
+func (r *Runbooks) Handler(logf func(format string, args ...interface{})) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodGet && req.Method != http.MethodHead {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		category := strings.ToLower(strings.TrimPrefix(req.URL.Path, "/"))
+		u, ok := r.urls[category]
+		if !ok {
+			http.NotFound(w, req)
+			return
+		}
+		if req.Method == http.MethodGet {
+			r.mu.Lock()
+			usage := r.get(category)
+			usage.Opened++
+			usage.LastOpened = clock.Or(r.Clock).Now()
+			err := r.save()
+			r.mu.Unlock()
+			if err != nil {
+				logf("Failed to count runbook %s being opened: %s", category, err)
+			}
+		}
+		http.Redirect(w, req, u, http.StatusFound)
+	})
+}
+
+// get returns the usage of a category, r.mu must be held
+func (r *Runbooks) get(category string) *Usage {
+	u := r.usage[category]
+	if u == nil {
+		u = &Usage{Category: category}
+		r.usage[category] = u
+	}
+	return u
+}
+
+// save writes the usage to the file, if there is one. r.mu must be held.
+func (r *Runbooks) save() error {
+	if r.path == "" {
+		return nil
+	}
+	var usage []Usage
+	for _, u := range r.usage {
+		usage = append(usage, *u)
+	}
+	sort.Slice(usage, func(i, j int) bool { return usage[i].Category < usage[j].Category })
+	if err := atomicfile.WriteJSON(r.path, usage); err != nil {
+		return fmt.Errorf("error saving runbook usage: %s", err)
+	}
+	return nil
+}
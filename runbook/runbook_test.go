@@ -0,0 +1,84 @@
+package runbook
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/skybet/go-helpdesk/clock"
+	"github.com/skybet/go-helpdesk/ticket"
+)
+
+func TestParse(t *testing.T) {
+	urls, err := Parse([]string{"VPN=https://wiki.example.com/vpn", "printers=https://example.slack.com/docs/T1/F1"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if len(urls) != 2 || urls["vpn"] != "https://wiki.example.com/vpn" {
+		t.Errorf("Unexpected runbooks %v", urls)
+	}
+	for _, spec := range []string{"vpn", "=https://wiki.example.com", "vpn=wiki", "vpn=javascript:alert(1)"} {
+		if _, err := Parse([]string{spec}); err == nil {
+			t.Errorf("Expected an error parsing %q", spec)
+		}
+	}
+}
+
+func TestFor(t *testing.T) {
+	urls := map[string]string{"vpn": "https://wiki.example.com/vpn", "remote": "https://wiki.example.com/vpn", "printers": "https://wiki.example.com/printers"}
+	tk := &ticket.Ticket{Tags: []string{"urgent", "Printers", "vpn", "remote"}}
+	got := New(urls, "").For(tk)
+	if len(got) != 2 || got[0].Category != "printers" || got[0].Link != "https://wiki.example.com/printers" || got[1].Category != "vpn" {
+		t.Errorf("Expected the runbooks of the tags in order, each once, got %+v", got)
+	}
+	if got := New(urls, "https://helpdesk.example.com/runbooks/").For(tk); got[0].Link != "https://helpdesk.example.com/runbooks/printers" {
+		t.Errorf("Expected the runbook to be linked through the handler, got %q", got[0].Link)
+	}
+	var none *Runbooks
+	if got := none.For(tk); got != nil {
+		t.Errorf("Expected no runbooks, got %+v", got)
+	}
+}
+
+func TestUsage(t *testing.T) {
+	dir, err := ioutil.TempDir("", "runbooks")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "usage.json")
+	urls := map[string]string{"vpn": "https://wiki.example.com/vpn", "printers": "https://wiki.example.com/printers"}
+	r, err := Load(urls, "https://helpdesk.example.com/runbooks", path)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	now := time.Date(2026, 10, 14, 9, 0, 0, 0, time.UTC)
+	r.Clock = clock.NewFake(now)
+	r.Suggested(r.For(&ticket.Ticket{Tags: []string{"vpn"}}))
+	h := r.Handler(t.Errorf)
+	for _, path := range []string{"/vpn", "/VPN", "/printers"} {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		if w.Code != http.StatusFound || w.Header().Get("Location") == "" {
+			t.Errorf("Expected %s to redirect to its runbook, got %d", path, w.Code)
+		}
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/wifi", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected an unknown runbook to be not found, got %d", w.Code)
+	}
+
+	r, err = Load(urls, "", path)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	usage := r.Usage()
+	if len(usage) != 2 || usage[0].Category != "printers" || usage[0].Opened != 1 || usage[1].Category != "vpn" || usage[1].Opened != 2 || usage[1].Suggested != 1 || !usage[1].LastOpened.Equal(now) {
+		t.Errorf("Expected the saved usage, least opened first, got %+v", usage)
+	}
+}
@@ -0,0 +1,156 @@
+package saved
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nlopes/slack"
+
+	"github.com/skybet/go-helpdesk/clock"
+	"github.com/skybet/go-helpdesk/links"
+	"github.com/skybet/go-helpdesk/query"
+	"github.com/skybet/go-helpdesk/report"
+	"github.com/skybet/go-helpdesk/store"
+	"github.com/skybet/go-helpdesk/ticket"
+)
+
+// maxListed is the most tickets listed in one alert, the rest are counted
+const maxListed = 10
+
+// Poster sends alerts to users
+type Poster interface {
+	PostMessage(channelID string, options ...slack.MsgOption) (string, string, error)
+}
+
+// Alerter sends the users subscribed to a search the new tickets which match
+// it. Tickets which match while a subscription is throttled are held and
+// sent together once it has been Every since the last alert.
+type Alerter struct {
+	Store    store.Store
+	Searches *Searches
+	// Poster sends the alerts as direct messages, such as a notify.Notifier
+	Poster Poster
+	// Links, if set, links tickets in alerts to their thread
+	Links *links.Links
+	// Clock, if set, replaces the wall clock
+	Clock clock.Clock
+
+	mu sync.Mutex
+	// checked is when tickets were last listed, zero before the first check
+	checked time.Time
+	// seen are the tickets found by the last check, which may be found again
+	// because they were created at the instant it was made
+	seen map[string]bool
+	// held are the tickets waiting for each subscription's throttle
+	held map[string][]*ticket.Ticket
+}
+
+// Check finds the tickets created since the last check, holding them for
+// each subscription they match, and alerts the subscriptions which are not
+// throttled. The first check only notes the time, tickets created before it
+// are not alerted.
+func (a *Alerter) Check(ctx context.Context, now time.Time) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.checked.IsZero() {
+		a.checked = now
+		return nil
+	}
+	created, err := report.All(ctx, a.Store, store.Filter{CreatedAfter: a.checked})
+	if err != nil {
+		return fmt.Errorf("error listing new tickets: %s", err)
+	}
+	a.checked = now
+	seen := map[string]bool{}
+	var fresh []*ticket.Ticket
+	for _, t := range created {
+		seen[t.ID] = true
+		if !a.seen[t.ID] {
+			fresh = append(fresh, t)
+		}
+	}
+	a.seen = seen
+	if a.held == nil {
+		a.held = map[string][]*ticket.Ticket{}
+	}
+
+	var errs []error
+	subscribed := map[string]bool{}
+	for _, sub := range a.Searches.Subscriptions() {
+		key := sub.User + "/" + sub.Name
+		subscribed[key] = true
+		f, err := query.Parse(sub.Query, sub.User, now)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("search %s of %s: %s", sub.Name, sub.User, err))
+			continue
+		}
+		for _, t := range fresh {
+			if f.Match(t) {
+				a.held[key] = append(a.held[key], t)
+			}
+		}
+		if len(a.held[key]) == 0 || now.Sub(sub.Alerted) < sub.Every {
+			continue
+		}
+		if _, _, err := a.Poster.PostMessage(sub.User, slack.MsgOptionText(a.text(sub, a.held[key]), false)); err != nil {
+			errs = append(errs, fmt.Errorf("search %s of %s: %s", sub.Name, sub.User, err))
+			continue
+		}
+		delete(a.held, key)
+		if err := a.Searches.alerted(sub.User, sub.Name, now); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	// Tickets held for searches unsubscribed from are dropped
+	for key := range a.held {
+		if !subscribed[key] {
+			delete(a.held, key)
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("error sending %d saved search alerts, the first: %s", len(errs), errs[0])
+	}
+	return nil
+}
+
+func (a *Alerter) text(sub Subscription, tickets []*ticket.Ticket) string {
+	header := fmt.Sprintf("A new ticket matches your saved search *%s*:", sub.Name)
+	if len(tickets) > 1 {
+		header = fmt.Sprintf("%d new tickets match your saved search *%s*:", len(tickets), sub.Name)
+	}
+	lines := []string{header}
+	for i, t := range tickets {
+		if i == maxListed {
+			lines = append(lines, fmt.Sprintf("…and %d more", len(tickets)-maxListed))
+			break
+		}
+		line := fmt.Sprintf("• %s %s", a.Links.Ref(t.ID), t.Title)
+		if t.Priority != 0 {
+			line += " (" + t.Priority.String() + ")"
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Run checks for new tickets every interval until ctx is cancelled
+func (a *Alerter) Run(ctx context.Context, interval time.Duration, errorf func(string, ...interface{})) {
+	t := clock.Or(a.Clock).NewTicker(interval)
+	defer t.Stop()
+	if err := a.Check(ctx, clock.Or(a.Clock).Now()); err != nil {
+		errorf("Saved search check failed: %s", err)
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-t.C():
+			if err := a.Check(ctx, now); err != nil {
+				errorf("Saved search check failed: %s", err)
+			}
+		}
+	}
+}
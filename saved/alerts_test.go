@@ -0,0 +1,84 @@
+package saved
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nlopes/slack"
+
+	"github.com/skybet/go-helpdesk/store"
+	"github.com/skybet/go-helpdesk/ticket"
+)
+
+type post struct{ channel, text string }
+
+type postLog struct{ posts []post }
+
+func (p *postLog) PostMessage(channelID string, options ...slack.MsgOption) (string, string, error) {
+	_, values, _ := slack.UnsafeApplyMsgOptions("", channelID, "", options...)
+	p.posts = append(p.posts, post{channelID, values.Get("text")})
+	return channelID, "1", nil
+}
+
+func TestAlerter(t *testing.T) {
+	ctx := context.Background()
+	st := store.NewMemory()
+	searches := NewSearches()
+	searches.Save("U1", "p1", "priority:P1", now)
+	searches.Subscribe("U1", "p1", time.Hour)
+	searches.Save("U2", "vpn", "tag:vpn -assignee:@me", now)
+	searches.Subscribe("U2", "vpn", time.Minute)
+	searches.Save("U3", "all", "status:open", now)
+	p := &postLog{}
+	a := &Alerter{Store: st, Searches: searches, Poster: p}
+
+	create := func(id string, at time.Duration, priority ticket.Priority, assignee string, tags ...string) {
+		t.Helper()
+		if err := st.CreateTicket(ctx, &ticket.Ticket{ID: id, Title: "Ticket " + id, Priority: priority, Assignee: assignee, Tags: tags, CreatedAt: now.Add(at)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	check := func(at time.Duration) {
+		t.Helper()
+		if err := a.Check(ctx, now.Add(at)); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+	}
+	create("1", -time.Hour, ticket.P1, "", "vpn")
+	check(0)
+	if len(p.posts) != 0 {
+		t.Fatalf("Expected tickets from before the first check not to be alerted, got %v", p.posts)
+	}
+
+	create("2", time.Minute, ticket.P1, "", "vpn")
+	create("3", time.Minute, ticket.P3, "U2", "vpn")
+	check(2 * time.Minute)
+	if len(p.posts) != 2 {
+		t.Fatalf("Expected an alert for each subscription, got %v", p.posts)
+	}
+	if p.posts[0].channel != "U1" || !strings.Contains(p.posts[0].text, "A new ticket matches your saved search *p1*") || !strings.Contains(p.posts[0].text, "#2 Ticket 2 (P1)") {
+		t.Errorf("Unexpected alert %+v", p.posts[0])
+	}
+	if p.posts[1].channel != "U2" || !strings.Contains(p.posts[1].text, "#2") || strings.Contains(p.posts[1].text, "#3") {
+		t.Errorf("Expected U2 not to be sent the ticket assigned to them, got %+v", p.posts[1])
+	}
+
+	// U1's subscription is throttled, so the next tickets wait for the hour
+	p.posts = nil
+	create("4", 3*time.Minute, ticket.P1, "")
+	check(4 * time.Minute)
+	create("5", 5*time.Minute, ticket.P1, "")
+	check(6 * time.Minute)
+	if len(p.posts) != 0 {
+		t.Fatalf("Expected the throttled subscription to wait, got %v", p.posts)
+	}
+	check(62 * time.Minute)
+	if len(p.posts) != 1 || !strings.Contains(p.posts[0].text, "2 new tickets match your saved search *p1*") || !strings.Contains(p.posts[0].text, "#4") || !strings.Contains(p.posts[0].text, "#5") {
+		t.Errorf("Expected the held tickets together, got %v", p.posts)
+	}
+	if s, _ := searches.Get("U1", "p1"); !s.Alerted.Equal(now.Add(62 * time.Minute)) {
+		t.Errorf("Expected the alert to be recorded, got %s", s.Alerted)
+	}
+}
@@ -0,0 +1,210 @@
+// Package saved keeps the searches agents save by name, such as p1-emea for
+// `status:open priority:P1 tag:emea`, and alerts the agents subscribed to a
+// search when new tickets match it.
+package saved
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/skybet/go-helpdesk/atomicfile"
+	"github.com/skybet/go-helpdesk/query"
+)
+
+// MaxPerUser is the most searches someone can save
+const MaxPerUser = 25
+
+// validName is what a saved search can be called, a single word so that it
+// can be given to commands
+var validName = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,39}$`)
+
+// Search is a query saved under a name
+type Search struct {
+	Name  string `json:"name"`
+	Query string `json:"query"`
+	// Subscribed is whether new tickets matching the query are sent to its
+	// owner, at most once every Every
+	Subscribed bool          `json:"subscribed,omitempty"`
+	Every      time.Duration `json:"every,omitempty"`
+	// Alerted is when the owner was last sent tickets matching the query
+	Alerted time.Time `json:"alerted,omitempty"`
+}
+
+// Subscription is a subscribed search with its owner
+type Subscription struct {
+	User string
+	Search
+}
+
+// Searches are the searches each user has saved. Searches is safe for
+// concurrent use and a nil *Searches has none.
+type Searches struct {
+	path  string
+	mu    sync.Mutex
+	users map[string][]Search
+}
+
+// NewSearches returns searches kept in memory
+func NewSearches() *Searches {
+	return &Searches{users: map[string][]Search{}}
+}
+
+// LoadSearches returns the searches saved in the JSON file at path, which is
+// created by the first search saved if it does not exist
+func LoadSearches(path string) (*Searches, error) {
+	s := NewSearches()
+	s.path = path
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading saved searches: %s", err)
+	}
+	if err := json.Unmarshal(b, &s.users); err != nil {
+		return nil, fmt.Errorf("error decoding saved searches in %s: %s", path, err)
+	}
+	return s, nil
+}
+
+// Of returns a user's searches sorted by name
+func (s *Searches) Of(user string) []Search {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Search(nil), s.users[user]...)
+}
+
+// Get returns a user's search, false if they have none of that name
+func (s *Searches) Get(user, name string) (Search, bool) {
+	for _, search := range s.Of(user) {
+		if search.Name == strings.ToLower(name) {
+			return search, true
+		}
+	}
+	return Search{}, false
+}
+
+// Save saves a query as a user's search called name, replacing the query of
+// a search of that name but keeping its subscription. The query is checked
+// as the user would run it at now.
+func (s *Searches) Save(user, name, q string, now time.Time) error {
+	name = strings.ToLower(name)
+	if !validName.MatchString(name) {
+		return fmt.Errorf("%q can not be the name of a search, use up to 40 letters, digits, - and _", name)
+	}
+	if _, err := query.Parse(q, user, now); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	searches := s.users[user]
+	for i := range searches {
+		if searches[i].Name == name {
+			searches[i].Query = q
+			return s.save()
+		}
+	}
+	if len(searches) >= MaxPerUser {
+		return fmt.Errorf("you already have %d saved searches, remove one first", MaxPerUser)
+	}
+	searches = append(searches, Search{Name: name, Query: q})
+	sort.Slice(searches, func(i, j int) bool { return searches[i].Name < searches[j].Name })
+	s.users[user] = searches
+	return s.save()
+}
+
+// Remove removes a user's search
+func (s *Searches) Remove(user, name string) error {
+	name = strings.ToLower(name)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	searches := s.users[user]
+	for i := range searches {
+		if searches[i].Name == name {
+			searches = append(searches[:i:i], searches[i+1:]...)
+			if len(searches) == 0 {
+				delete(s.users, user)
+			} else {
+				s.users[user] = searches
+			}
+			return s.save()
+		}
+	}
+	return fmt.Errorf("you have no saved search called %s", name)
+}
+
+// Subscribe subscribes a user to their search, so they are sent new tickets
+// matching it at most once every every. A zero every unsubscribes them.
+func (s *Searches) Subscribe(user, name string, every time.Duration) error {
+	return s.update(user, name, func(search *Search) {
+		search.Subscribed, search.Every = every > 0, every
+		if every == 0 {
+			search.Alerted = time.Time{}
+		}
+	})
+}
+
+// Subscriptions returns every subscribed search, by user then name
+func (s *Searches) Subscriptions() []Subscription {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var subs []Subscription
+	for user, searches := range s.users {
+		for _, search := range searches {
+			if search.Subscribed {
+				subs = append(subs, Subscription{User: user, Search: search})
+			}
+		}
+	}
+	sort.Slice(subs, func(i, j int) bool {
+		if subs[i].User != subs[j].User {
+			return subs[i].User < subs[j].User
+		}
+		return subs[i].Name < subs[j].Name
+	})
+	return subs
+}
+
+// alerted records when the owner of a search was last sent its tickets
+func (s *Searches) alerted(user, name string, at time.Time) error {
+	return s.update(user, name, func(search *Search) {
+		search.Alerted = at
+	})
+}
+
+func (s *Searches) update(user, name string, fn func(*Search)) error {
+	name = strings.ToLower(name)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, search := range s.users[user] {
+		if search.Name == name {
+			fn(&s.users[user][i])
+			return s.save()
+		}
+	}
+	return fmt.Errorf("you have no saved search called %s", name)
+}
+
+// save writes the searches to the file, if there is one. s.mu must be held.
+func (s *Searches) save() error {
+	if s.path == "" {
+		return nil
+	}
+	if err := atomicfile.WriteJSON(s.path, s.users); err != nil {
+		return fmt.Errorf("error saving searches: %s", err)
+	}
+	return nil
+}
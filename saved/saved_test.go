@@ -0,0 +1,88 @@
+package saved
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var now = time.Date(2026, 10, 14, 9, 0, 0, 0, time.UTC)
+
+func TestSearches(t *testing.T) {
+	dir, err := ioutil.TempDir("", "saved")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "searches.json")
+	s, err := LoadSearches(path)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if err := s.Save("U1", "P1-EMEA", "status:open priority:P1 tag:emea", now); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if err := s.Save("U1", "mine", "assignee:@me", now); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	for name, q := range map[string]string{"my search": "tag:vpn", "vpn": "colour:red", "": "tag:vpn"} {
+		if err := s.Save("U1", name, q, now); err == nil {
+			t.Errorf("Expected saving %q as %q to be refused", q, name)
+		}
+	}
+	if err := s.Subscribe("U1", "p1-emea", time.Hour); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if err := s.Save("U1", "p1-emea", "status:open priority:P1", now); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if err := s.Subscribe("U2", "p1-emea", time.Hour); err == nil {
+		t.Error("Expected subscribing to someone else's search to fail")
+	}
+
+	s, err = LoadSearches(path)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	got := s.Of("U1")
+	if len(got) != 2 || got[0].Name != "mine" || got[1].Name != "p1-emea" {
+		t.Fatalf("Expected both searches to be saved by name, got %+v", got)
+	}
+	if got[1].Query != "status:open priority:P1" || !got[1].Subscribed || got[1].Every != time.Hour {
+		t.Errorf("Expected saving again to keep the subscription, got %+v", got[1])
+	}
+	if subs := s.Subscriptions(); len(subs) != 1 || subs[0].User != "U1" || subs[0].Name != "p1-emea" {
+		t.Errorf("Unexpected subscriptions %+v", subs)
+	}
+	s.Subscribe("U1", "p1-emea", 0)
+	if len(s.Subscriptions()) != 0 {
+		t.Error("Expected a zero interval to unsubscribe")
+	}
+	if err := s.Remove("U1", "mine"); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if _, ok := s.Get("U1", "mine"); ok || s.Remove("U1", "mine") == nil {
+		t.Error("Expected the search to be removed")
+	}
+	var none *Searches
+	if none.Of("U1") != nil || none.Subscriptions() != nil {
+		t.Error("Expected nil searches to have none")
+	}
+}
+
+func TestSaveLimit(t *testing.T) {
+	s := NewSearches()
+	for i := 0; i < MaxPerUser; i++ {
+		if err := s.Save("U1", string(rune('a'+i)), "tag:vpn", now); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+	}
+	if err := s.Save("U1", "more", "tag:vpn", now); err == nil {
+		t.Error("Expected a search over the limit to be refused")
+	}
+	if err := s.Save("U1", "a", "tag:laptop", now); err != nil {
+		t.Errorf("Expected a search to be replaced at the limit, got %s", err)
+	}
+}
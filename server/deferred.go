@@ -0,0 +1,202 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nlopes/slack"
+)
+
+// DefaultDeferAfter is how long a handler may take before its request is
+// acknowledged without it, leaving time for the response to reach Slack
+// within the 3 seconds it waits
+const DefaultDeferAfter = 2500 * time.Millisecond
+
+// Poster sends messages with chat.postMessage
+type Poster interface {
+	PostMessage(channelID string, options ...slack.MsgOption) (string, string, error)
+}
+
+// Deferrer answers slash commands and interactions in time however long their
+// handler takes. A handler which has not finished within After is left
+// running, the request is acknowledged with an empty 200 and the handler's
+// response is posted to the request's response_url once it is ready.
+type Deferrer struct {
+	// After is how long to wait for a handler, DefaultDeferAfter if zero
+	After time.Duration
+	// Client posts to response URLs, one with a 10 second timeout if nil
+	Client *http.Client
+	// Retries is how many more times a failed post is tried, waiting
+	// Backoff before the first retry and twice as long before each after
+	Retries int
+	Backoff time.Duration
+	// Poster, if set, sends the response to the user in a DM when it can not
+	// be posted to the response URL
+	Poster    Poster
+	ErrorLogf LogfFunc
+
+	wg sync.WaitGroup
+}
+
+// Wrap returns a handler acknowledging slash commands and interactions for h
+// if it runs late. Other requests, including view submissions whose response
+// has to update the modal, are passed straight to h. A late handler must not
+// rely on the request's context, which is done once the request is
+// acknowledged.
+func (d *Deferrer) Wrap(h SlackHandlerFunc) SlackHandlerFunc {
+	return func(res *Response, req *Request, ctx interface{}) error {
+		responseURL, user, ok := replyTo(ctx)
+		if !ok {
+			return h(res, req, ctx)
+		}
+		buf := &bufferedResponse{header: http.Header{}, code: http.StatusOK}
+		done := make(chan error, 1)
+		go func() {
+			// Running on its own goroutine the handler is out of reach of
+			// Recover
+			defer func() {
+				if p := recover(); p != nil {
+					done <- fmt.Errorf("handler panicked: %v", p)
+				}
+			}()
+			done <- h(&Response{buf}, req, ctx)
+		}()
+		after := d.After
+		if after <= 0 {
+			after = DefaultDeferAfter
+		}
+		timer := time.NewTimer(after)
+		defer timer.Stop()
+		select {
+		case err := <-done:
+			for k, v := range buf.header {
+				res.Header()[k] = v
+			}
+			res.WriteHeader(buf.code)
+			res.Write(buf.body.Bytes())
+			return err
+		case <-timer.C:
+		}
+
+		res.WriteHeader(http.StatusOK)
+		d.wg.Add(1)
+		go func() {
+			defer d.wg.Done()
+			if err := <-done; err != nil {
+				d.errorf("Deferred handler error: %s", err)
+			}
+			if err := d.deliver(responseURL, user, buf); err != nil {
+				d.errorf("Failed to deliver deferred response to %s: %s", user, err)
+			}
+		}()
+		return nil
+	}
+}
+
+// Wait returns once every late handler's response has been delivered, or
+// ctx is done
+func (d *Deferrer) Wait(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		d.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// replyTo returns where the response to a slash command or interaction goes
+func replyTo(ctx interface{}) (responseURL, user string, ok bool) {
+	switch c := ctx.(type) {
+	case slack.SlashCommand:
+		return c.ResponseURL, c.UserID, true
+	case *slack.InteractionCallback:
+		return c.ResponseURL, c.User.ID, true
+	}
+	return "", "", false
+}
+
+// deliver posts a late handler's response to the response URL, or to the
+// user with the Poster if that fails
+func (d *Deferrer) deliver(responseURL, user string, buf *bufferedResponse) error {
+	if buf.code != http.StatusOK {
+		return fmt.Errorf("handler responded with %d: %s", buf.code, strings.TrimSpace(buf.body.String()))
+	}
+	if buf.body.Len() == 0 {
+		return nil
+	}
+	var msg slack.Msg
+	if strings.HasPrefix(buf.header.Get("Content-Type"), "application/json") {
+		if err := json.Unmarshal(buf.body.Bytes(), &msg); err != nil {
+			return fmt.Errorf("error decoding response: %s", err)
+		}
+	} else {
+		msg = slack.Msg{ResponseType: slack.ResponseTypeEphemeral, Text: strings.TrimSpace(buf.body.String())}
+	}
+	err := fmt.Errorf("there is no response URL")
+	if responseURL != "" {
+		if err = d.post(responseURL, msg); err == nil {
+			return nil
+		}
+	}
+	if d.Poster == nil || user == "" {
+		return err
+	}
+	opts := []slack.MsgOption{slack.MsgOptionText(msg.Text, false)}
+	if len(msg.Blocks.BlockSet) > 0 {
+		opts = append(opts, slack.MsgOptionBlocks(msg.Blocks.BlockSet...))
+	}
+	if _, _, perr := d.Poster.PostMessage(user, opts...); perr != nil {
+		return fmt.Errorf("%s, and posting to the user failed: %s", err, perr)
+	}
+	return nil
+}
+
+// post sends msg to the response URL, retrying errors which may not happen
+// again
+func (d *Deferrer) post(responseURL string, msg slack.Msg) error {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("error encoding response: %s", err)
+	}
+	client := d.Client
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	wait := d.Backoff
+	for attempt := 0; ; attempt++ {
+		var retry bool
+		resp, err := client.Post(responseURL, "application/json", bytes.NewReader(body))
+		if err == nil {
+			resp.Body.Close()
+			if resp.StatusCode == http.StatusOK {
+				return nil
+			}
+			err = fmt.Errorf("response URL returned %s", resp.Status)
+			retry = resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+		} else {
+			retry = true
+		}
+		if !retry || attempt >= d.Retries {
+			return err
+		}
+		time.Sleep(wait)
+		wait *= 2
+	}
+}
+
+func (d *Deferrer) errorf(format string, args ...interface{}) {
+	if d.ErrorLogf != nil {
+		d.ErrorLogf(format, args...)
+	}
+}
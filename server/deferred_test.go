@@ -0,0 +1,98 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nlopes/slack"
+)
+
+type fakePoster struct {
+	mu     sync.Mutex
+	posted []string
+}
+
+func (f *fakePoster) PostMessage(channelID string, options ...slack.MsgOption) (string, string, error) {
+	_, values, _ := slack.UnsafeApplyMsgOptions("", channelID, "", options...)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.posted = append(f.posted, channelID+": "+values.Get("text"))
+	return channelID, "1.1", nil
+}
+
+func TestDeferrer(t *testing.T) {
+	var mu sync.Mutex
+	var received []slack.Msg
+	failures := 0
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if r.URL.Path == "/flaky" && failures < 1 {
+			failures++
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		if r.URL.Path == "/expired" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		var m slack.Msg
+		b, _ := ioutil.ReadAll(r.Body)
+		json.Unmarshal(b, &m)
+		received = append(received, m)
+	}))
+	defer hook.Close()
+
+	poster := &fakePoster{}
+	d := &Deferrer{After: 20 * time.Millisecond, Retries: 2, Backoff: time.Millisecond, Poster: poster, ErrorLogf: errorLogf}
+	slow := make(chan struct{})
+	h := d.Wrap(func(res *Response, req *Request, ctx interface{}) error {
+		if sc, ok := ctx.(slack.SlashCommand); ok && sc.Text == "slow" {
+			<-slow
+		}
+		res.Text(http.StatusOK, "Done for "+ctx.(slack.SlashCommand).UserID)
+		return nil
+	})
+	serve := func(sc slack.SlashCommand) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		if err := h(&Response{w}, &Request{}, sc); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		return w
+	}
+
+	// Handlers which finish in time respond as usual
+	if w := serve(slack.SlashCommand{UserID: "U1", ResponseURL: hook.URL + "/fast"}); w.Code != http.StatusOK || strings.TrimSpace(w.Body.String()) != "Done for U1" {
+		t.Errorf("Expected the handler's response, got %d %q", w.Code, w.Body)
+	}
+	// Slow ones are acknowledged then posted to the response URL, retrying
+	// errors which may go away
+	for _, sc := range []slack.SlashCommand{
+		{UserID: "U2", Text: "slow", ResponseURL: hook.URL + "/flaky"},
+		{UserID: "U3", Text: "slow", ResponseURL: hook.URL + "/expired"},
+	} {
+		if w := serve(sc); w.Code != http.StatusOK || w.Body.Len() != 0 {
+			t.Errorf("Expected an empty acknowledgement, got %d %q", w.Code, w.Body)
+		}
+	}
+	close(slow)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := d.Wait(ctx); err != nil {
+		t.Fatalf("Expected the deferred responses to be delivered, got %s", err)
+	}
+	if len(received) != 1 || received[0].Text != "Done for U2" || received[0].ResponseType != slack.ResponseTypeEphemeral || failures != 1 {
+		t.Errorf("Expected U2's response to be posted after a retry, got %+v", received)
+	}
+	// A response URL which refuses it is not retried, the user is sent a DM
+	if len(poster.posted) != 1 || poster.posted[0] != "U3: Done for U3" {
+		t.Errorf("Expected U3 to be sent their response, got %v", poster.posted)
+	}
+}
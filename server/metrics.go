@@ -0,0 +1,25 @@
+package server
+
+import (
+	"time"
+
+	"github.com/skybet/go-helpdesk/metrics"
+)
+
+var (
+	requestsHandled = metrics.Default.Counter("helpdesk_requests_total", "Slack requests handled, by kind, route and whether the handler failed", "kind", "route", "outcome")
+	callbackLatency = metrics.Default.Histogram("helpdesk_callback_duration_seconds", "Time from receiving a Slack request to its handler returning", metrics.DefaultBuckets, "kind")
+)
+
+// observe counts a request of kind routed to route, received at received,
+// whose handler returned err
+func observe(kind, route string, received time.Time, err error) {
+	outcome := "ok"
+	if err != nil {
+		outcome = "error"
+	}
+	requestsHandled.Inc(kind, route, outcome)
+	if !received.IsZero() {
+		callbackLatency.Observe(time.Since(received).Seconds(), kind)
+	}
+}
@@ -0,0 +1,30 @@
+package server
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestMetrics(t *testing.T) {
+	s := NewSlackHandler("/slack", "TOKEN", slackSecret, &dnHeader, log, logf, errorLog, errorLogf)
+	s.HandlePath("/metered", func(res *Response, req *Request, ctx interface{}) error {
+		return nil
+	})
+	s.HandlePath("/metered-fail", func(res *Response, req *Request, ctx interface{}) error {
+		return fmt.Errorf("serious problem")
+	})
+	ok, failed, latency := requestsHandled.Value("path", "/metered", "ok"), requestsHandled.Value("path", "/metered-fail", "error"), callbackLatency.Count("path")
+	performGenericFormRequest("foo=bar", "/metered", s)
+	performGenericFormRequest("foo=bar", "/metered", s)
+	performGenericFormRequest("foo=bar", "/metered-fail", s)
+
+	if got := requestsHandled.Value("path", "/metered", "ok") - ok; got != 2 {
+		t.Errorf("Expected 2 requests handled, got %v", got)
+	}
+	if got := requestsHandled.Value("path", "/metered-fail", "error") - failed; got != 1 {
+		t.Errorf("Expected 1 failed request, got %v", got)
+	}
+	if got := callbackLatency.Count("path") - latency; got != 3 {
+		t.Errorf("Expected the latency of 3 requests, got %d", got)
+	}
+}
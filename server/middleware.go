@@ -0,0 +1,98 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"net/http"
+	"runtime/debug"
+	"time"
+
+	"github.com/skybet/go-helpdesk/logger"
+)
+
+// RequestIDHeader is the header a request's ID is taken from, if a proxy in
+// front of the server set one, and returned in
+const RequestIDHeader = "X-Request-Id"
+
+// Use adds middleware around the handlers, to log, authorise, recover or
+// measure requests without changing how they are routed. The first
+// middleware added is the outermost. Middleware only sees requests which were
+// verified to come from Slack, and runs for Socket Mode envelopes as well as
+// HTTP requests. Add it before serving requests.
+func (h *SlackHandler) Use(middleware ...func(http.Handler) http.Handler) {
+	h.middleware = append(h.middleware, middleware...)
+}
+
+// routed returns the handler routing a request received at a time, wrapped in
+// the middleware. Each request is given an ID, and its context a logger
+// adding the ID to every line, before the middleware sees it.
+func (h *SlackHandler) routed(received time.Time) http.Handler {
+	var next http.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.route(w, &Request{Request: r, Received: received, ID: RequestID(r.Context())})
+	})
+	for i := len(h.middleware) - 1; i >= 0; i-- {
+		next = h.middleware[i](next)
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIDHeader)
+		if id == "" {
+			id = newRequestID()
+		}
+		w.Header().Set(RequestIDHeader, id)
+		ctx := context.WithValue(r.Context(), requestIDKey{}, id)
+		ctx = logger.NewContext(ctx, h.logger().With("request_id", id))
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+type requestIDKey struct{}
+
+// RequestID returns the ID of the request being served in ctx, empty if
+// there is none
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// newRequestID returns a random hex ID
+func newRequestID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// Recover is middleware which answers a request whose handler panicked with a
+// 500 and logs the panic with its stack, rather than dropping the connection
+func Recover(errorf LogfFunc) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			defer func() {
+				p := recover()
+				if p == nil {
+					return
+				}
+				if p == http.ErrAbortHandler {
+					panic(p)
+				}
+				errorf("Handler panicked serving %s (request %s): %v\n%s", r.URL.Path, RequestID(r.Context()), p, debug.Stack())
+				http.Error(w, "internal error", http.StatusInternalServerError)
+			}()
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RequireToken requires requests to h to carry token as a bearer token, every
+// request is refused if token is empty
+func RequireToken(token string, h http.Handler) http.Handler {
+	want := []byte("Bearer " + token)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if token == "" || subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), want) != 1 {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
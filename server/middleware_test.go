@@ -0,0 +1,87 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestUse(t *testing.T) {
+	s := NewSlackHandler(basePath, "TOKEN", slackSecret, &dnHeader, log, logf, errorLog, errorLogf)
+	var order []string
+	tag := func(name string) func(http.Handler) http.Handler {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				order = append(order, name)
+				w.Header().Set("X-"+name, "yes")
+				next.ServeHTTP(w, r)
+			})
+		}
+	}
+	s.Use(tag("Outer"), tag("Inner"))
+	s.HandleCommand("/hd", func(res *Response, req *Request, ctx interface{}) error {
+		order = append(order, "handler")
+		res.Text(http.StatusOK, "ok")
+		return nil
+	})
+
+	resp := performGenericFormRequest("command=/hd&text=help", basePath, s)
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("X-Outer") != "yes" || strings.Join(order, ",") != "Outer,Inner,handler" {
+		t.Errorf("Expected the middleware to wrap the handler in order, got %d %v", resp.StatusCode, order)
+	}
+
+	// Socket Mode envelopes go through the middleware too
+	order = nil
+	if _, err := s.ServeSocketMode("slash_commands", []byte(`{"command":"/hd","text":"help"}`)); err != nil || strings.Join(order, ",") != "Outer,Inner,handler" {
+		t.Errorf("Expected the envelope to go through the middleware, got %v %v", order, err)
+	}
+
+	// Requests which are not from Slack never reach it
+	order = nil
+	forged := NewSlackHandler(basePath, "TOKEN", "other", &dnHeader, log, logf, errorLog, errorLogf)
+	forged.Use(tag("Outer"))
+	if resp := performGenericFormRequest("command=/hd", basePath, forged); resp.StatusCode != http.StatusBadRequest || len(order) != 0 {
+		t.Errorf("Expected an unsigned request to be refused before the middleware, got %d %v", resp.StatusCode, order)
+	}
+}
+
+func TestRecover(t *testing.T) {
+	s := NewSlackHandler(basePath, "TOKEN", slackSecret, &dnHeader, log, logf, errorLog, errorLogf)
+	var logged string
+	s.Use(Recover(func(format string, args ...interface{}) { logged = format }))
+	s.HandleCommand("/hd", func(res *Response, req *Request, ctx interface{}) error {
+		panic("boom")
+	})
+	if resp := performGenericFormRequest("command=/hd", basePath, s); resp.StatusCode != http.StatusInternalServerError || !strings.Contains(logged, "panicked") {
+		t.Errorf("Expected the panic to be answered with a 500 and logged, got %d %q", resp.StatusCode, logged)
+	}
+	if _, err := s.ServeSocketMode("slash_commands", []byte(`{"command":"/hd"}`)); err == nil || !strings.Contains(err.Error(), "500") {
+		t.Errorf("Expected the envelope to fail rather than the connection, got %v", err)
+	}
+}
+
+func TestRequireToken(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	for _, tt := range []struct {
+		token, header string
+		want          int
+	}{
+		{"secret", "Bearer secret", http.StatusOK},
+		{"secret", "Bearer wrong", http.StatusUnauthorized},
+		{"secret", "secret", http.StatusUnauthorized},
+		{"secret", "", http.StatusUnauthorized},
+		{"", "Bearer ", http.StatusUnauthorized},
+		{"", "", http.StatusUnauthorized},
+	} {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		if tt.header != "" {
+			r.Header.Set("Authorization", tt.header)
+		}
+		w := httptest.NewRecorder()
+		RequireToken(tt.token, ok).ServeHTTP(w, r)
+		if w.Code != tt.want {
+			t.Errorf("Expected %q with token %q to get %d, got %d", tt.header, tt.token, tt.want, w.Code)
+		}
+	}
+}
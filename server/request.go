@@ -2,31 +2,38 @@ package server
 
 import (
 	"bytes"
-	"crypto/hmac"
-	"crypto/sha256"
-	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/nlopes/slack/slackevents"
 	"io/ioutil"
 	"net/http"
-	"strconv"
 	"strings"
 	"time"
 
 	"github.com/nlopes/slack"
+	"github.com/skybet/go-helpdesk/views"
 	"regexp"
 )
 
 // Request wraps http.Request
 type Request struct {
 	*http.Request
-	payload *slack.InteractionCallback
+	// Received is when the request arrived, Slack trigger IDs expire 3 seconds after this
+	Received time.Time
+	// ID identifies the request in logs, it is returned in the
+	// X-Request-Id header
+	ID         string
+	payload    *slack.InteractionCallback
+	submission *views.Submission
 }
 
 // Validate the request comes from Slack
 func (r *Request) Validate(secret string, dnHeader *string) error {
+	return r.validate(secret, dnHeader, DefaultTimestampTolerance)
+}
+
+func (r *Request) validate(secret string, dnHeader *string, tolerance time.Duration) error {
 	// If a dnHeader has been provided, check that the header contains the slack CN
 	if dnHeader != nil {
 		slackDNHeader := r.Header.Get(*dnHeader)
@@ -34,7 +41,7 @@ func (r *Request) Validate(secret string, dnHeader *string) error {
 
 		r, _ := regexp.Compile("CN=(.*?),")
 		cn := r.FindStringSubmatch(slackDNHeader)
-		if len(cn) != 2 {		// It should match the CN exactly one, and contain the CN value as a group
+		if len(cn) != 2 { // It should match the CN exactly one, and contain the CN value as a group
 			return dnError
 		}
 
@@ -43,35 +50,15 @@ func (r *Request) Validate(secret string, dnHeader *string) error {
 		}
 	}
 
-	slackTimestampHeader := r.Header.Get("X-Slack-Request-Timestamp")
-	slackTimestamp, err := strconv.ParseInt(slackTimestampHeader, 10, 64)
-
-	// Abort if timestamp is invalid
-	if err != nil {
-		return fmt.Errorf("invalid timestamp sent from slack: %s", err)
-	}
-
-	// Abort if timestamp is stale (older than 5 minutes)
-	now := int64(time.Now().Unix())
-	if (now - slackTimestamp) > (60 * 5) {
-		return fmt.Errorf("stale timestamp sent from slack: %s", err)
-	}
-
 	// Abort if request body is invalid
 	body, err := ioutil.ReadAll(r.Body)
 	if err != nil {
 		return fmt.Errorf("invalid request body sent from slack: %s", err)
 	}
-	slackBody := string(body)
 
 	// Abort if the signature does not correspond to the signing secret
-	slackBaseStr := []byte(fmt.Sprintf("v0:%d:%s", slackTimestamp, slackBody))
-	slackSignature := r.Header.Get("X-Slack-Signature")
-	sec := hmac.New(sha256.New, []byte(secret))
-	sec.Write(slackBaseStr)
-	mySig := fmt.Sprintf("v0=%s", []byte(hex.EncodeToString(sec.Sum(nil))))
-	if mySig != slackSignature {
-		return errors.New("invalid signature sent from slack")
+	if err := VerifySignature(r.Header, body, secret, tolerance, time.Now()); err != nil {
+		return err
 	}
 	// All good! The request is valid
 	r.Body = ioutil.NopCloser(bytes.NewBuffer(body))
@@ -114,8 +101,14 @@ func (r *Request) EventAPIEvent(body []byte) (*slackevents.EventsAPIEvent, error
 	return &eventsAPIEvent, nil
 }
 
+// ViewSubmission returns the parsed payload for a view_submission or view_closed
+// interaction, or nil if the request is for another kind of interaction
+func (r *Request) ViewSubmission() *views.Submission {
+	return r.submission
+}
+
 func (r *Request) parseInteractionPayload() error {
-	var payload slack.InteractionCallback
+	var payload views.Submission
 	j := r.Form.Get("payload")
 	if j == "" {
 		return errors.New("empty payload")
@@ -123,6 +116,17 @@ func (r *Request) parseInteractionPayload() error {
 	if err := json.Unmarshal([]byte(j), &payload); err != nil {
 		return fmt.Errorf("error parsing payload JSON: %s", err)
 	}
-	r.payload = &payload
+	r.payload = &payload.InteractionCallback
+	// View interactions carry their callback ID on the view rather than the payload
+	if payload.Type == views.InteractionTypeViewSubmission || payload.Type == views.InteractionTypeViewClosed {
+		if r.payload.CallbackID == "" {
+			r.payload.CallbackID = payload.View.CallbackID
+		}
+		r.submission = &payload
+	}
+	// Block actions have no callback ID, route them by the action ID instead
+	if payload.Type == slack.InteractionTypeBlockActions && r.payload.CallbackID == "" && len(r.payload.ActionCallback.BlockActions) > 0 {
+		r.payload.CallbackID = r.payload.ActionCallback.BlockActions[0].ActionID
+	}
 	return nil
-}
\ No newline at end of file
+}
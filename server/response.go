@@ -1,6 +1,7 @@
 package server
 
 import (
+	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
@@ -17,3 +18,15 @@ func (r *Response) Text(code int, body string) {
 	r.WriteHeader(code)
 	io.WriteString(r, fmt.Sprintf("%s\n", body))
 }
+
+// JSON is a convenience method for sending a JSON encoded response
+func (r *Response) JSON(code int, body interface{}) error {
+	b, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("error encoding JSON response: %s", err)
+	}
+	r.Header().Set("Content-Type", "application/json")
+	r.WriteHeader(code)
+	_, err = r.Write(b)
+	return err
+}
@@ -0,0 +1,310 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nlopes/slack"
+)
+
+// ArgKind is the type a subcommand's argument is parsed as
+type ArgKind int
+
+// The kinds of argument a subcommand can declare
+const (
+	// Word is a single word, bound as a string
+	Word ArgKind = iota
+	// Number is a whole number, bound as an int
+	Number
+	// Duration is a duration such as 90m, bound as a time.Duration
+	Duration
+	// User is a user mention such as <@U123|bob>, bound as the user's ID
+	User
+	// Channel is a channel mention such as <#C123|it>, bound as the
+	// channel's ID
+	Channel
+	// Ticket is a ticket ID with or without a leading #, bound without it
+	Ticket
+	// Text is the rest of the command's text, it must be the last argument
+	Text
+)
+
+// Arg declares an argument of a subcommand
+type Arg struct {
+	Name     string
+	Kind     ArgKind
+	Optional bool
+}
+
+func (a Arg) String() string {
+	name := a.Name
+	if a.Kind == User {
+		name = "@" + name
+	}
+	if a.Optional {
+		return "[" + name + "]"
+	}
+	return "<" + name + ">"
+}
+
+// Args are the arguments a subcommand was run with, keyed by name. Optional
+// arguments which were not given are missing.
+type Args map[string]interface{}
+
+// Has reports whether the argument was given
+func (a Args) Has(name string) bool {
+	_, ok := a[name]
+	return ok
+}
+
+// String returns a Word, User, Channel, Ticket or Text argument, or the empty
+// string if it was not given
+func (a Args) String(name string) string {
+	s, _ := a[name].(string)
+	return s
+}
+
+// Int returns a Number argument, or zero if it was not given
+func (a Args) Int(name string) int {
+	n, _ := a[name].(int)
+	return n
+}
+
+// Duration returns a Duration argument, or zero if it was not given
+func (a Args) Duration(name string) time.Duration {
+	d, _ := a[name].(time.Duration)
+	return d
+}
+
+// CommandFunc handles a subcommand with the arguments it was run with
+type CommandFunc func(res *Response, req *Request, sc slack.SlashCommand, args Args) error
+
+// Subcommand is a subcommand of a slash command, such as /hd move
+type Subcommand struct {
+	Name string
+	// Summary describes the subcommand in the help text
+	Summary string
+	Args    []Arg
+	Handler CommandFunc
+	// Raw, if set, handles the subcommand instead of Handler and is passed
+	// the slack.SlashCommand, for subcommands which parse their own
+	// arguments. Usage then describes them.
+	Raw   SlackHandlerFunc
+	Usage string
+	// Keywords are the words the subcommand takes as its first argument,
+	// such as edit for announce edit
+	Keywords []string
+}
+
+// usage returns the subcommand's arguments as shown in its usage
+func (s Subcommand) usage() string {
+	if s.Raw != nil {
+		return strings.TrimSpace(s.Name + " " + s.Usage)
+	}
+	parts := []string{s.Name}
+	for _, a := range s.Args {
+		parts = append(parts, a.String())
+	}
+	return strings.Join(parts, " ")
+}
+
+// CommandRouter dispatches a slash command to the subcommand named by the
+// first word of its text, parsing the rest into the arguments the subcommand
+// declares. A command which does not parse is answered with its usage, and
+// help lists every subcommand. Register the subcommands before routing
+// commands, they do not change while it is in use.
+type CommandRouter struct {
+	// Translate, if set, formats the router's replies, e.g. in the user's
+	// language, instead of fmt.Sprintf
+	Translate   func(sc slack.SlashCommand, format string, args ...interface{}) string
+	subcommands map[string]Subcommand
+}
+
+// NewCommandRouter returns a router without any subcommands
+func NewCommandRouter() *CommandRouter {
+	return &CommandRouter{subcommands: map[string]Subcommand{}}
+}
+
+// Handle registers a subcommand. It panics if the subcommand's arguments can
+// not be parsed, as they are fixed when the program is written.
+func (r *CommandRouter) Handle(s Subcommand) {
+	if s.Name == "" || (s.Handler == nil && s.Raw == nil) {
+		panic("server: subcommands need a name and a handler")
+	}
+	optional := false
+	for i, a := range s.Args {
+		switch {
+		case a.Kind == Text && i != len(s.Args)-1:
+			panic(fmt.Sprintf("server: %s's text argument %s must be its last", s.Name, a.Name))
+		case optional && !a.Optional:
+			panic(fmt.Sprintf("server: %s's argument %s must be optional as it follows an optional argument", s.Name, a.Name))
+		}
+		optional = a.Optional
+	}
+	r.subcommands[strings.ToLower(s.Name)] = s
+}
+
+// Names returns the names of the subcommands in order
+func (r *CommandRouter) Names() []string {
+	var names []string
+	for name := range r.subcommands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Keywords returns the words the subcommand name takes as its first
+// argument, those of its Keywords or for help the subcommands' names
+func (r *CommandRouter) Keywords(name string) []string {
+	name = strings.ToLower(name)
+	if s, ok := r.subcommands[name]; ok {
+		return s.Keywords
+	}
+	if name == "help" {
+		return r.Names()
+	}
+	return nil
+}
+
+// Usage returns the usage of a subcommand, or of the command itself if it has
+// no such subcommand
+func (r *CommandRouter) Usage(sc slack.SlashCommand, name string) string {
+	s, ok := r.subcommands[strings.ToLower(name)]
+	if !ok {
+		return r.sprintf(sc, "Usage: %s [%s]", sc.Command, strings.Join(r.Names(), "|"))
+	}
+	return r.sprintf(sc, "Usage: %s "+s.usage(), sc.Command)
+}
+
+// ServeCommand satisfies SlackHandlerFunc, dispatching a slack.SlashCommand
+// to its subcommand
+func (r *CommandRouter) ServeCommand(res *Response, req *Request, ctx interface{}) error {
+	sc, ok := ctx.(slack.SlashCommand)
+	if !ok {
+		return fmt.Errorf("Expected a slack.SlashCommand to be passed to the handler")
+	}
+	words := strings.Fields(sc.Text)
+	if len(words) == 0 {
+		res.Text(http.StatusOK, r.Usage(sc, ""))
+		return nil
+	}
+	name := strings.ToLower(words[0])
+	s, ok := r.subcommands[name]
+	if !ok {
+		if name == "help" {
+			res.Text(http.StatusOK, r.help(sc, words[1:]))
+		} else {
+			res.Text(http.StatusOK, r.Usage(sc, ""))
+		}
+		return nil
+	}
+	if s.Raw != nil {
+		return s.Raw(res, req, sc)
+	}
+	args, err := r.bind(sc, s, words[1:])
+	if err != nil {
+		res.Text(http.StatusOK, err.Error())
+		return nil
+	}
+	return s.Handler(res, req, sc, args)
+}
+
+// bind parses words into the subcommand's arguments, the error is the reply
+// explaining what was wrong with them
+func (r *CommandRouter) bind(sc slack.SlashCommand, s Subcommand, words []string) (Args, error) {
+	args := Args{}
+	for i, a := range s.Args {
+		if i >= len(words) {
+			if a.Optional {
+				break
+			}
+			return nil, fmt.Errorf("%s", r.Usage(sc, s.Name))
+		}
+		word := words[i]
+		var v interface{}
+		var err error
+		switch a.Kind {
+		case Word:
+			v = word
+		case Number:
+			if v, err = strconv.Atoi(word); err != nil {
+				err = fmt.Errorf("%s", r.sprintf(sc, "%s is not a number", word))
+			}
+		case Duration:
+			if v, err = time.ParseDuration(word); err != nil {
+				err = fmt.Errorf("%s", r.sprintf(sc, "%s is not a duration, such as 90m", word))
+			}
+		case User:
+			if v = mentioned(word, "<@"); v == "" {
+				err = fmt.Errorf("%s", r.sprintf(sc, "%s is not a user", word))
+			}
+		case Channel:
+			if v = mentioned(word, "<#"); v == "" {
+				err = fmt.Errorf("%s", r.sprintf(sc, "%s is not a channel", word))
+			}
+		case Ticket:
+			if v = strings.TrimPrefix(word, "#"); v == "" {
+				err = fmt.Errorf("%s", r.sprintf(sc, "%s is not a ticket", word))
+			}
+		case Text:
+			v = strings.Join(words[i:], " ")
+			words = words[:i+1]
+		}
+		if err != nil {
+			return nil, err
+		}
+		args[a.Name] = v
+	}
+	if len(words) > len(s.Args) {
+		return nil, fmt.Errorf("%s", r.Usage(sc, s.Name))
+	}
+	return args, nil
+}
+
+// help lists every subcommand's usage and summary, or only those of the
+// subcommand asked about
+func (r *CommandRouter) help(sc slack.SlashCommand, words []string) string {
+	names := r.Names()
+	if len(words) > 0 {
+		if _, ok := r.subcommands[strings.ToLower(words[0])]; ok {
+			names = []string{strings.ToLower(words[0])}
+		}
+	}
+	lines := []string{r.Usage(sc, "")}
+	if len(names) == 1 {
+		lines = []string{r.Usage(sc, names[0])}
+	}
+	for _, name := range names {
+		s := r.subcommands[name]
+		line := fmt.Sprintf("`%s %s`", sc.Command, s.usage())
+		if summary := s.Summary; summary != "" {
+			if r.Translate != nil {
+				summary = r.Translate(sc, summary)
+			}
+			line += " " + summary
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (r *CommandRouter) sprintf(sc slack.SlashCommand, format string, args ...interface{}) string {
+	if r.Translate != nil {
+		return r.Translate(sc, format, args...)
+	}
+	return fmt.Sprintf(format, args...)
+}
+
+// mentioned returns the ID from an escaped mention such as <@U123|bob> or
+// <#C123|it>, or the empty string if word is not one
+func mentioned(word, prefix string) string {
+	if !strings.HasPrefix(word, prefix) || !strings.HasSuffix(word, ">") {
+		return ""
+	}
+	return strings.SplitN(strings.TrimSuffix(strings.TrimPrefix(word, prefix), ">"), "|", 2)[0]
+}
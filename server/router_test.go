@@ -0,0 +1,101 @@
+package server
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nlopes/slack"
+)
+
+func TestCommandRouter(t *testing.T) {
+	var got Args
+	r := NewCommandRouter()
+	r.Handle(Subcommand{
+		Name:    "snooze",
+		Summary: "Snoozes a ticket",
+		Args: []Arg{
+			{Name: "ticket", Kind: Ticket},
+			{Name: "for", Kind: Duration},
+			{Name: "agent", Kind: User, Optional: true},
+			{Name: "reason", Kind: Text, Optional: true},
+		},
+		Handler: func(res *Response, req *Request, sc slack.SlashCommand, args Args) error {
+			got = args
+			res.Text(200, "ok")
+			return nil
+		},
+	})
+	r.Handle(Subcommand{Name: "raw", Usage: "<anything>", Raw: func(res *Response, req *Request, ctx interface{}) error {
+		res.Text(200, "raw "+ctx.(slack.SlashCommand).Text)
+		return nil
+	}})
+
+	serve := func(text string) string {
+		t.Helper()
+		w := httptest.NewRecorder()
+		if err := r.ServeCommand(&Response{w}, &Request{}, slack.SlashCommand{Command: "/hd", Text: text}); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		return strings.TrimSpace(w.Body.String())
+	}
+
+	if body := serve("snooze #12 2h <@U1|bob> waiting on a part"); body != "ok" {
+		t.Fatalf("Expected the subcommand to be run, got %s", body)
+	}
+	if got.String("ticket") != "12" || got.Duration("for") != 2*time.Hour || got.String("agent") != "U1" || got.String("reason") != "waiting on a part" {
+		t.Errorf("Expected the arguments to be bound, got %+v", got)
+	}
+	if serve("SNOOZE 12 30m"); got.Has("agent") || got.Has("reason") {
+		t.Errorf("Expected optional arguments to be missing, got %+v", got)
+	}
+	tt := []struct {
+		text, want string
+	}{
+		{"snooze 12", "Usage: /hd snooze <ticket> <for> [@agent] [reason]"},
+		{"snooze 12 soon", "soon is not a duration, such as 90m"},
+		{"snooze 12 1h bob", "bob is not a user"},
+		{"raw  a b", "raw raw  a b"},
+		{"", "Usage: /hd [raw|snooze]"},
+		{"bogus", "Usage: /hd [raw|snooze]"},
+		{"help", "Usage: /hd [raw|snooze]\n`/hd raw <anything>`\n`/hd snooze <ticket> <for> [@agent] [reason]` Snoozes a ticket"},
+		{"help snooze", "Usage: /hd snooze <ticket> <for> [@agent] [reason]\n`/hd snooze <ticket> <for> [@agent] [reason]` Snoozes a ticket"},
+	}
+	for _, tc := range tt {
+		if body := serve(tc.text); body != tc.want {
+			t.Errorf("Expected %q to reply %q, got %q", tc.text, tc.want, body)
+		}
+	}
+}
+
+func TestCommandRouterTranslate(t *testing.T) {
+	r := NewCommandRouter()
+	r.Translate = func(sc slack.SlashCommand, format string, args ...interface{}) string {
+		return strings.ToUpper(format)
+	}
+	r.Handle(Subcommand{Name: "count", Args: []Arg{{Name: "n", Kind: Number}}, Handler: func(res *Response, req *Request, sc slack.SlashCommand, args Args) error {
+		return nil
+	}})
+	w := httptest.NewRecorder()
+	r.ServeCommand(&Response{w}, &Request{}, slack.SlashCommand{Command: "/hd", Text: "count many"})
+	if body := strings.TrimSpace(w.Body.String()); body != "%S IS NOT A NUMBER" {
+		t.Errorf("Expected the reply to be translated, got %q", body)
+	}
+}
+
+func TestCommandRouterSpecs(t *testing.T) {
+	for _, args := range [][]Arg{
+		{{Name: "reason", Kind: Text}, {Name: "ticket", Kind: Ticket}},
+		{{Name: "agent", Kind: User, Optional: true}, {Name: "ticket", Kind: Ticket}},
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("Expected %+v to be refused", args)
+				}
+			}()
+			NewCommandRouter().Handle(Subcommand{Name: "bad", Args: args, Handler: func(*Response, *Request, slack.SlashCommand, Args) error { return nil }})
+		}()
+	}
+}
@@ -4,9 +4,15 @@ import (
 	"encoding/json"
 	"github.com/nlopes/slack"
 	"github.com/nlopes/slack/slackevents"
+	"github.com/skybet/go-helpdesk/logger"
+	"github.com/skybet/go-helpdesk/store"
+	"github.com/skybet/go-helpdesk/tracing"
+	"github.com/skybet/go-helpdesk/views"
 	"io/ioutil"
 	"net/http"
 	"strings"
+	"sync"
+	"time"
 )
 
 // LogFunc is an abstraction that allows using any external logger with a Print signature
@@ -30,16 +36,28 @@ type Route struct {
 
 // SlackHandler is a function executed when a route is invoked
 type SlackHandler struct {
-	Log          LogFunc
-	Logf         LogfFunc
-	ErrorLog     LogFunc
-	ErrorLogf    LogfFunc
+	Log       LogFunc
+	Logf      LogfFunc
+	ErrorLog  LogFunc
+	ErrorLogf LogfFunc
+	// Logger, if set, is logged to instead of Logf and ErrorLogf. Lines about
+	// a request carry its request_id and team_id.
+	Logger       logger.Logger
 	Routes       []*Route
 	DefaultRoute SlackHandlerFunc
-	basePath     string
-	appToken     string
-	secretToken  string
-	dnHeader     *string	// Used for Mutual TLS
+	// TimestampTolerance is how old, or how far in the future, a request's
+	// signature may be, DefaultTimestampTolerance if zero
+	TimestampTolerance time.Duration
+	basePath           string
+	appToken           string
+	secretToken        string
+	dnHeader           *string // Used for Mutual TLS
+	middleware         []func(http.Handler) http.Handler
+	// mu guards stopping, which is set by Shutdown, and adding to inflight,
+	// the requests being served
+	mu       sync.Mutex
+	stopping bool
+	inflight sync.WaitGroup
 }
 
 // NewSlackHandler returns an initialised SlackHandler
@@ -68,7 +86,10 @@ func (h *SlackHandler) HandleInteractionCallback(it, cid string, f SlackHandlerF
 }
 
 // HandleEventCallback registers a handler to be executed when a specific
-// EventsAPICallbackEvent type is present in the request
+// EventsAPICallbackEvent type is present in the request. Messages can also be
+// routed by where they were posted with the Events API subscription names
+// message.channels, message.groups, message.im, message.mpim and
+// message.app_home, these are preferred to a handler for every message.
 func (h *SlackHandler) HandleEventCallback(et string, f SlackHandlerFunc) {
 	r := &Route{Path: h.basePath, EventType: et, Handler: f}
 	h.handle(r)
@@ -87,6 +108,14 @@ func (h *SlackHandler) HandlePath(p string, f SlackHandlerFunc) {
 	h.handle(r)
 }
 
+// logger returns the handler's Logger, or one calling Logf and ErrorLogf
+func (h *SlackHandler) logger() logger.Logger {
+	if h.Logger != nil {
+		return h.Logger
+	}
+	return logger.Funcs(h.Logf, h.ErrorLogf)
+}
+
 func (h *SlackHandler) handle(r *Route) {
 	// TODO: validate no duplicates
 	h.Routes = append(h.Routes, r)
@@ -94,21 +123,42 @@ func (h *SlackHandler) handle(r *Route) {
 
 // ServeHTTP satisfies http.Handler interface
 func (h *SlackHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	req := &Request{Request: r}
+	// If the request did not look like it came from slack, 400 and abort
+	h.tracked(h.Verify(h.routed(time.Now()))).ServeHTTP(w, r)
+}
+
+// route serves a request which has been verified to come from Slack
+func (h *SlackHandler) route(w http.ResponseWriter, req *Request) {
+	r := req.Request
 	res := &Response{w}
+	log := logger.Or(logger.FromContext(req.Context()))
 
-	// Generic serve function which captures and logs handler errors
-	serve := func(f SlackHandlerFunc, ctx interface{}) {
-		if err := f(res, req, ctx); err != nil {
-			h.ErrorLogf("HTTP handler error: %s", err)
+	// Generic serve function which captures and logs handler errors, and
+	// counts and traces the request by its kind and route. The handler's
+	// context carries the team the request came from, for its logger and a
+	// store sharded by team.
+	serve := func(kind, route, team string, f SlackHandlerFunc, ctx interface{}) {
+		traced, span := tracing.Start(req.Context(), strings.TrimSpace("slack "+kind+" "+route), tracing.Server)
+		span.Set("slack.kind", kind)
+		span.Set("slack.route", route)
+		log := log
+		if team != "" {
+			log = log.With("team_id", team)
+			traced = store.WithTeam(logger.NewContext(traced, log), team)
 		}
-	}
-
-	// If the request did not look like it came from slack, 400 and abort
-	if err := req.Validate(h.secretToken, h.dnHeader); err != nil {
-		h.ErrorLogf("Bad request from slack: %s", err)
-		res.Text(400, "invalid slack request")
-		return
+		req.Request = req.Request.WithContext(traced)
+		err := f(res, req, ctx)
+		// Response actions such as validation errors or the next step of a
+		// modal are for the user rather than the logs
+		if ra, ok := err.(views.Responder); ok {
+			if err = res.JSON(http.StatusOK, ra.Response()); err != nil {
+				log.Errorf("HTTP handler error: %s", err)
+			}
+		} else if err != nil {
+			log.Errorf("HTTP handler error: %s", err)
+		}
+		observe(kind, route, req.Received, err)
+		span.Finish(err)
 	}
 
 	// First check if path matches our BasePath and has valid form data
@@ -127,9 +177,9 @@ func (h *SlackHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 				// This seems to be a url verification request from Slack, check it is and respond accordingly
 				if verificationEvent.Type == slackevents.URLVerification {
 					if _, err := w.Write([]byte(verificationEvent.Challenge)); err != nil {
-						h.ErrorLogf("Failed writing challenge back to verificationEvent: %w", err)
+						log.Errorf("Failed writing challenge back to verificationEvent: %s", err)
 					}
-					h.Logf("Successfully responded to URL verification requested from Slack")
+					log.Infof("Successfully responded to URL verification requested from Slack")
 					return
 				}
 			}
@@ -138,12 +188,12 @@ func (h *SlackHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		// Is it a slash command?
 		if r.Form.Get("command") != "" {
 			sc, _ := slack.SlashCommandParse(r)
-			h.Logf("slack command triggered: %s", sc.Command)
+			log.With("team_id", sc.TeamID).Infof("slack command triggered: %s", sc.Command)
 			// Loop through all our routes and attempt a match on the Command
 			for _, rt := range h.Routes {
 				if rt.Command == sc.Command {
 					// Send the SlackCommand struct as context
-					serve(rt.Handler, sc)
+					serve("command", rt.Command, sc.TeamID, rt.Handler, sc)
 					return
 				}
 			}
@@ -153,35 +203,43 @@ func (h *SlackHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		event, err := req.EventAPIEvent(body)
 		if err == nil && event != nil {
 			eventType := event.InnerEvent.Type
-			h.Logf("slack event triggered: %s", eventType)
-			// Loop through all our routes and attempt a match on the Event type
-			for _, rt := range h.Routes {
-				if eventType == rt.EventType {
-					// Send the interactionPayload as context
-					h.Logf("Serving request....")
-					serve(rt.Handler, event)
-					return
+			elog := log.With("team_id", event.TeamID)
+			elog.Infof("slack event triggered: %s", eventType)
+			// Loop through all our routes and attempt a match on the
+			// subscription, then the Event type
+			for _, et := range []string{subscription(event), eventType} {
+				for _, rt := range h.Routes {
+					if et != "" && et == rt.EventType {
+						// Send the interactionPayload as context
+						elog.Debugf("Serving request....")
+						serve("event", rt.EventType, event.TeamID, rt.Handler, event)
+						return
+					}
 				}
 			}
 			// We want to exit here because it's a valid event, but we don't have a route for it
-			h.Logf("no valid route found that matches [%s], returning", eventType)
+			elog.Infof("no valid route found that matches [%s], returning", eventType)
 			return
 		}
 
-		h.Logf("Event err: %s", err)
+		log.Debugf("Event err: %s", err)
 
 		// Does it have a valid interaction callback payload? - If so, it's an interaction callback
 		interactionPayload, err := req.InteractionCallbackPayload()
 		if err != nil {
-			h.ErrorLogf("Error parsing interactionPayload: %s", err)
+			log.Errorf("Error parsing interactionPayload: %s", err)
 		}
 		// Loop through all our routes and attempt a match on the InteractionType / CallbackID pair
 		if interactionPayload != nil {
-			h.Logf("slack interaction callback triggered: %s", interactionPayload.CallbackID)
+			log.With("team_id", interactionPayload.Team.ID).Infof("slack interaction callback triggered: %s", interactionPayload.CallbackID)
 			for _, rt := range h.Routes {
 				if string(interactionPayload.Type) == rt.InteractionType && interactionPayload.CallbackID == rt.CallbackID {
-					// Send the interactionPayload as context
-					serve(rt.Handler, interactionPayload)
+					// Send the interactionPayload as context, or the full view payload for view interactions
+					if sub := req.ViewSubmission(); sub != nil {
+						serve("interaction", rt.CallbackID, interactionPayload.Team.ID, rt.Handler, sub)
+					} else {
+						serve("interaction", rt.CallbackID, interactionPayload.Team.ID, rt.Handler, interactionPayload)
+					}
 					return
 				}
 			}
@@ -190,12 +248,31 @@ func (h *SlackHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		// If nothing else works, loop through all our routes and attempt a match on the path
 		for _, rt := range h.Routes {
 			if rt.Path == r.URL.Path {
-				serve(rt.Handler, nil)
+				serve("path", rt.Path, "", rt.Handler, nil)
 				return
 			}
 		}
 	}
 
 	// No matches - 404
-	serve(h.DefaultRoute, nil)
+	serve("unmatched", "", "", h.DefaultRoute, nil)
+}
+
+// messageSubscriptions are the Events API subscriptions for messages keyed by
+// the channel_type of the message
+var messageSubscriptions = map[string]string{
+	"channel":  "message.channels",
+	"group":    "message.groups",
+	"im":       "message.im",
+	"mpim":     "message.mpim",
+	"app_home": "message.app_home",
+}
+
+// subscription returns the name of the subscription a message event was sent
+// for, such as message.im, or "" for other events
+func subscription(event *slackevents.EventsAPIEvent) string {
+	if ev, ok := event.InnerEvent.Data.(*slackevents.MessageEvent); ok {
+		return messageSubscriptions[ev.ChannelType]
+	}
+	return ""
 }
@@ -2,10 +2,15 @@ package server
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"github.com/nlopes/slack/slackevents"
+	"github.com/skybet/go-helpdesk/logger"
+	"github.com/skybet/go-helpdesk/tracing"
+	"github.com/skybet/go-helpdesk/views"
 	"io/ioutil"
 	"net/http/httptest"
+	"net/url"
 	"testing"
 
 	"crypto/hmac"
@@ -24,17 +29,17 @@ var (
 	dnHeader    = "dummy-dn"
 	basePath    = "/slack"
 	logString   string
-	log = func(i ...interface{}) {
+	log         = func(i ...interface{}) {
 		logString = fmt.Sprintf("%s", i)
 	}
 	logf = func(msg string, i ...interface{}) {
-		logString = fmt.Sprintf(msg, i)
+		logString = fmt.Sprintf(msg, i...)
 	}
 	errorLog = func(i ...interface{}) {
-		logString = fmt.Sprintf(i[0].(string))
+		logString = fmt.Sprint(i[0])
 	}
 	errorLogf = func(msg string, i ...interface{}) {
-		logString = fmt.Sprintf(msg, i[0])
+		logString = fmt.Sprintf(msg, i...)
 	}
 )
 
@@ -144,6 +149,59 @@ func TestDialogSubmissionEvent(t *testing.T) {
 	}
 }
 
+func performViewSubmission(payload string, s *SlackHandler) *http.Response {
+	return performGenericFormRequest("payload="+url.QueryEscape(payload), basePath, s)
+}
+
+func TestViewSubmissionValidationErrors(t *testing.T) {
+	raw := `{"type":"view_submission","team":{"id":"T1"},"user":{"id":"U1"},"view":{"id":"V1","type":"modal","callback_id":"new_ticket","blocks":[{"type":"input","block_id":"title","element":{"type":"plain_text_input","action_id":"value"}}],"state":{"values":{"title":{"value":{"type":"plain_text_input","value":"x"}}}}}}`
+	h := func(res *Response, req *Request, ctx interface{}) error {
+		sub, ok := ctx.(*views.Submission)
+		if !ok {
+			t.Fatalf("Expected a *views.Submission to be passed to the handler")
+		}
+		if v := sub.View.State.Get("title", "value").String(); v != "x" {
+			t.Fatalf("Unexpected value for title: %s", v)
+		}
+		return views.ValidationErrors{"title": "Title must be at least 5 characters"}
+	}
+	s := NewSlackHandler(basePath, "TOKEN", slackSecret, &dnHeader, log, logf, errorLog, errorLogf)
+	s.HandleInteractionCallback("view_submission", "new_ticket", h)
+	resp := performViewSubmission(raw, s)
+
+	if resp.StatusCode != 200 {
+		t.Logf("ErrString: %s", logString)
+		t.Fatalf("Expected a 200 status. Got '%d'", resp.StatusCode)
+	}
+	var body views.ResponseAction
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatalf("Failed decoding response body: %s", err)
+	}
+	if body.Action != "errors" || body.Errors["title"] != "Title must be at least 5 characters" {
+		t.Fatalf("Unexpected response action: %+v", body)
+	}
+}
+
+func TestViewSubmissionPush(t *testing.T) {
+	raw := `{"type":"view_submission","user":{"id":"U1"},"view":{"id":"V1","type":"modal","callback_id":"step_one"}}`
+	h := func(res *Response, req *Request, ctx interface{}) error {
+		next := views.NewModal("step_two", "Step two")
+		next.PrivateMetadata = ctx.(*views.Submission).View.ID
+		return views.Push(next)
+	}
+	s := NewSlackHandler(basePath, "TOKEN", slackSecret, &dnHeader, log, logf, errorLog, errorLogf)
+	s.HandleInteractionCallback("view_submission", "step_one", h)
+	resp := performViewSubmission(raw, s)
+
+	var body views.ResponseAction
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatalf("Failed decoding response body: %s", err)
+	}
+	if body.Action != "push" || body.View == nil || body.View.CallbackID != "step_two" || body.View.PrivateMetadata != "V1" {
+		t.Fatalf("Unexpected response action: %+v", body)
+	}
+}
+
 func TestEventValidationRequest(t *testing.T) {
 	raw := "{\"token\":\"TOKEN\",\"challenge\":\"CHALLENGE\",\"type\":\"url_verification\"}"
 	h := func(res *Response, req *Request, ctx interface{}) error {
@@ -241,7 +299,7 @@ func TestMalformedActionEvent(t *testing.T) {
 			if resp.StatusCode != tc.sCode {
 				t.Errorf("Expected a %d status. Got '%d'", tc.sCode, resp.StatusCode)
 			}
-			if logString != tc.err {
+			if !strings.HasPrefix(logString, tc.err+" request_id=") {
 				t.Errorf("Test Name: %s - Should result in: %s - Got: %s", tc.name, tc.err, logString)
 			}
 		})
@@ -270,13 +328,49 @@ func TestHandlerErrors(t *testing.T) {
 	s := NewSlackHandler("/slack", "TOKEN", slackSecret, &dnHeader, log, logf, errorLog, errorLogf)
 	s.HandlePath("/foo", h)
 	raw := "foo=bar"
-	performGenericFormRequest(raw, "/foo", s)
+	r := httptest.NewRequest("POST", "/foo", bytes.NewBufferString(raw))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	r.Header.Set(RequestIDHeader, "req-1")
+	addSlackHeaders(raw, r)
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, r)
 
-	if logString != "HTTP handler error: serious problem" {
+	if logString != "HTTP handler error: serious problem request_id=req-1" || w.Header().Get(RequestIDHeader) != "req-1" {
 		t.Fatalf("Unexpected error string: %s", logString)
 	}
 }
 
+func TestRequestLogger(t *testing.T) {
+	var lines []string
+	s := NewSlackHandler("/slack", "TOKEN", slackSecret, &dnHeader, nil, nil, nil, nil)
+	s.Logger = logger.Funcs(func(format string, args ...interface{}) {
+		lines = append(lines, fmt.Sprintf(format, args...))
+	}, nil)
+	var id string
+	s.HandleCommand("/hd", func(res *Response, req *Request, ctx interface{}) error {
+		id = req.ID
+		logger.FromContext(req.Context()).Infof("handled")
+		return nil
+	})
+	rec := httptest.NewRecorder()
+	raw := "command=/hd&team_id=T1"
+	r := httptest.NewRequest("POST", "/slack", bytes.NewBufferString(raw))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	addSlackHeaders(raw, r)
+	s.ServeHTTP(rec, r)
+
+	if id == "" || rec.Header().Get(RequestIDHeader) != id {
+		t.Fatalf("Expected the request to be given an ID and return it, got %q %q", id, rec.Header().Get(RequestIDHeader))
+	}
+	want := []string{
+		"slack command triggered: /hd request_id=" + id + " team_id=T1",
+		"handled request_id=" + id + " team_id=T1",
+	}
+	if strings.Join(lines, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Expected %q, got %q", want, lines)
+	}
+}
+
 func TestMissingTimestamp(t *testing.T) {
 	s := NewSlackHandler("/slack", "TOKEN", slackSecret, nil, log, logf, errorLog, errorLogf)
 	s.HandlePath("/foo", nil)
@@ -355,4 +449,112 @@ func TestInvalidDN(t *testing.T) {
 	}
 }
 
+func TestViewClosed(t *testing.T) {
+	raw := `{"type":"view_closed","user":{"id":"U1"},"is_cleared":true,"view":{"id":"V2","type":"modal","callback_id":"step_two","root_view_id":"V1"}}`
+	called := false
+	h := func(res *Response, req *Request, ctx interface{}) error {
+		sub, ok := ctx.(*views.Submission)
+		if !ok {
+			t.Fatalf("Expected a *views.Submission to be passed to the handler")
+		}
+		if !sub.IsCleared || sub.View.RootViewID != "V1" {
+			t.Fatalf("Unexpected view_closed payload: %+v", sub)
+		}
+		called = true
+		return nil
+	}
+	s := NewSlackHandler(basePath, "TOKEN", slackSecret, &dnHeader, log, logf, errorLog, errorLogf)
+	s.HandleInteractionCallback("view_closed", "step_two", h)
+	resp := performViewSubmission(raw, s)
+
+	if resp.StatusCode != 200 || !called {
+		t.Logf("ErrString: %s", logString)
+		t.Fatalf("Expected the view_closed interaction to be routed by the view's callback ID. Got '%d'", resp.StatusCode)
+	}
+}
+
+func TestMatchBlockAction(t *testing.T) {
+	raw := `{"type":"block_actions","team":{"id":"T1"},"user":{"id":"U1"},"actions":[{"type":"button","action_id":"convert","block_id":"b1","value":"C1:1.1"}]}`
+	called := false
+	h := func(res *Response, req *Request, ctx interface{}) error {
+		ic, ok := ctx.(*slack.InteractionCallback)
+		if !ok {
+			t.Fatalf("Expected a *slack.InteractionCallback to be passed to the handler")
+		}
+		if v := ic.ActionCallback.BlockActions[0].Value; v != "C1:1.1" {
+			t.Fatalf("Unexpected value for the action: %s", v)
+		}
+		called = true
+		return nil
+	}
+	s := NewSlackHandler(basePath, "TOKEN", slackSecret, &dnHeader, log, logf, errorLog, errorLogf)
+	s.HandleInteractionCallback("block_actions", "convert", h)
+	resp := performViewSubmission(raw, s)
+
+	if resp.StatusCode != 200 || !called {
+		t.Logf("ErrString: %s", logString)
+		t.Fatalf("Expected the block action to be routed by action ID. Got '%d'", resp.StatusCode)
+	}
+}
+
+func TestMessageSubscriptions(t *testing.T) {
+	var routed []string
+	route := func(name string) SlackHandlerFunc {
+		return func(res *Response, req *Request, ctx interface{}) error {
+			routed = append(routed, name)
+			return nil
+		}
+	}
+	s := NewSlackHandler(basePath, "TOKEN", slackSecret, &dnHeader, log, logf, errorLog, errorLogf)
+	s.HandleEventCallback("message", route("message"))
+	s.HandleEventCallback("message.im", route("message.im"))
+	s.HandleEventCallback("app_mention", route("app_mention"))
+	for _, raw := range []string{
+		`{"type":"event_callback","event":{"type":"message","channel":"D1","channel_type":"im","text":"hi"}}`,
+		`{"type":"event_callback","event":{"type":"message","channel":"C1","channel_type":"channel","text":"hi"}}`,
+		`{"type":"event_callback","event":{"type":"app_mention","channel":"C1","text":"<@UBOT> hi"}}`,
+	} {
+		if resp := performGenericJsonRequest(raw, basePath, s); resp.StatusCode != 200 {
+			t.Fatalf("Expected a 200 status. Got '%d'", resp.StatusCode)
+		}
+	}
+	if strings.Join(routed, ",") != "message.im,message,app_mention" {
+		t.Errorf("Expected direct messages to go to the message.im handler, got %v", routed)
+	}
+
+	// Requests which are not signed are refused before being routed
+	raw := `{"type":"event_callback","event":{"type":"app_mention","channel":"C1"}}`
+	req := httptest.NewRequest("POST", basePath, bytes.NewBufferString(raw))
+	req.Header.Set("X-Slack-Request-Timestamp", strconv.Itoa(int(time.Now().Unix())))
+	req.Header.Set("X-Slack-Signature", "v0=forged")
+	req.Header.Set(dnHeader, "CN=platform-tls-client.slack.com,O=Slack Technologies")
+	if resp := performGenericRequest(req, s); resp.StatusCode != 400 || len(routed) != 3 {
+		t.Errorf("Expected a forged event to be refused, got %d", resp.StatusCode)
+	}
+}
 
+type spans []*tracing.Span
+
+func (s *spans) Export(span *tracing.Span) {
+	*s = append(*s, span)
+}
+
+func TestTracing(t *testing.T) {
+	var exported spans
+	tracing.Default.Exporter = &exported
+	defer func() { tracing.Default.Exporter = nil }()
+	var inHandler *tracing.Span
+	s := NewSlackHandler("/slack", "TOKEN", slackSecret, &dnHeader, log, logf, errorLog, errorLogf)
+	s.HandlePath("/traced", func(res *Response, req *Request, ctx interface{}) error {
+		inHandler = tracing.FromContext(req.Context())
+		return fmt.Errorf("serious problem")
+	})
+	performGenericFormRequest("foo=bar", "/traced", s)
+
+	if len(exported) != 1 || exported[0] != inHandler {
+		t.Fatalf("Expected the handler to be given the request's span, got %v", exported)
+	}
+	if sp := exported[0]; sp.Name != "slack path /traced" || sp.Kind != tracing.Server || sp.Err == nil || sp.Attributes()["slack.route"] != "/traced" {
+		t.Errorf("Unexpected span %+v", sp)
+	}
+}
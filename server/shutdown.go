@@ -0,0 +1,64 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// ErrShuttingDown is returned by ServeSocketMode for envelopes received after
+// Shutdown, which should be left unacknowledged so that Slack sends them again
+var ErrShuttingDown = errors.New("the server is shutting down")
+
+// Shutdown stops the handler accepting new requests, which are answered with
+// a 503 so that Slack retries them elsewhere, and waits for the requests in
+// flight to finish or ctx to be done. Late responses are waited for by the
+// Deferrer.
+func (h *SlackHandler) Shutdown(ctx context.Context) error {
+	h.mu.Lock()
+	h.stopping = true
+	h.mu.Unlock()
+	done := make(chan struct{})
+	go func() {
+		h.inflight.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("requests were still being handled: %s", ctx.Err())
+	}
+}
+
+// closing returns true once Shutdown has been called
+func (h *SlackHandler) closing() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.stopping
+}
+
+// admit counts a request in flight, false if the handler is shutting down
+func (h *SlackHandler) admit() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.stopping {
+		return false
+	}
+	h.inflight.Add(1)
+	return true
+}
+
+// tracked serves requests with next until Shutdown, then refuses them
+func (h *SlackHandler) tracked(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !h.admit() {
+			w.Header().Set("Connection", "close")
+			(&Response{w}).Text(http.StatusServiceUnavailable, "shutting down")
+			return
+		}
+		defer h.inflight.Done()
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,73 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestShutdown(t *testing.T) {
+	s := NewSlackHandler(basePath, "TOKEN", slackSecret, &dnHeader, log, logf, errorLog, errorLogf)
+	started, release := make(chan struct{}), make(chan struct{})
+	s.HandleCommand("/hd", func(res *Response, req *Request, ctx interface{}) error {
+		close(started)
+		<-release
+		res.Text(http.StatusOK, "done")
+		return nil
+	})
+	s.HandleCommand("/other", func(res *Response, req *Request, ctx interface{}) error {
+		res.Text(http.StatusOK, "done")
+		return nil
+	})
+	served := make(chan int)
+	go func() {
+		served <- performGenericFormRequest("command=/hd", basePath, s).StatusCode
+	}()
+	<-started
+
+	stopped := make(chan error)
+	go func() { stopped <- s.Shutdown(context.Background()) }()
+	// Shutdown has to be under way before new requests are refused
+	deadline := time.Now().Add(2 * time.Second)
+	for !s.closing() && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if resp := performGenericFormRequest("command=/other", basePath, s); resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("Expected new requests to be refused with a 503, got %d", resp.StatusCode)
+	}
+	if _, err := s.ServeSocketMode("slash_commands", []byte(`{"command":"/other"}`)); err != ErrShuttingDown {
+		t.Errorf("Expected new envelopes to be refused, got %v", err)
+	}
+	select {
+	case err := <-stopped:
+		t.Fatalf("Expected Shutdown to wait for the request in flight, got %v", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	close(release)
+	if code := <-served; code != http.StatusOK {
+		t.Errorf("Expected the request in flight to be answered, got %d", code)
+	}
+	if err := <-stopped; err != nil {
+		t.Errorf("Unexpected error shutting down: %s", err)
+	}
+}
+
+func TestShutdownTimeout(t *testing.T) {
+	s := NewSlackHandler(basePath, "TOKEN", slackSecret, &dnHeader, log, logf, errorLog, errorLogf)
+	started, release := make(chan struct{}), make(chan struct{})
+	defer close(release)
+	s.HandleCommand("/hd", func(res *Response, req *Request, ctx interface{}) error {
+		close(started)
+		<-release
+		return nil
+	})
+	go performGenericFormRequest("command=/hd", basePath, s)
+	<-started
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := s.Shutdown(ctx); err == nil {
+		t.Errorf("Expected an error when requests are still in flight")
+	}
+}
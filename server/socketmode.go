@@ -0,0 +1,80 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ServeSocketMode routes the payload of an envelope received over a Socket
+// Mode connection to the same handlers as the HTTP request Slack would
+// otherwise have sent, returning the response to acknowledge the envelope
+// with, nil if there is none. Socket Mode connections are opened with the
+// app-level token so envelopes are not signed.
+func (h *SlackHandler) ServeSocketMode(envelopeType string, payload []byte) (interface{}, error) {
+	var body, contentType string
+	switch envelopeType {
+	case "events_api":
+		body, contentType = string(payload), "application/json"
+	case "slash_commands":
+		var fields map[string]string
+		if err := json.Unmarshal(payload, &fields); err != nil {
+			return nil, fmt.Errorf("error decoding slash command: %s", err)
+		}
+		form := url.Values{}
+		for k, v := range fields {
+			form.Set(k, v)
+		}
+		body, contentType = form.Encode(), "application/x-www-form-urlencoded"
+	case "interactive":
+		body, contentType = url.Values{"payload": {string(payload)}}.Encode(), "application/x-www-form-urlencoded"
+	default:
+		return nil, fmt.Errorf("unsupported envelope type %s", envelopeType)
+	}
+	r, err := http.NewRequest(http.MethodPost, h.basePath, strings.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	r.Header.Set("Content-Type", contentType)
+	if !h.admit() {
+		return nil, ErrShuttingDown
+	}
+	defer h.inflight.Done()
+	w := &bufferedResponse{header: http.Header{}, code: http.StatusOK}
+	h.routed(time.Now()).ServeHTTP(w, r)
+
+	if w.code != http.StatusOK {
+		return nil, fmt.Errorf("handler responded with %d: %s", w.code, strings.TrimSpace(w.body.String()))
+	}
+	if w.body.Len() == 0 {
+		return nil, nil
+	}
+	if strings.HasPrefix(w.header.Get("Content-Type"), "application/json") {
+		return json.RawMessage(w.body.Bytes()), nil
+	}
+	// Plain text replies to slash commands are shown to the user who ran them
+	return map[string]string{"text": strings.TrimSpace(w.body.String())}, nil
+}
+
+// bufferedResponse keeps a handler's response to send in an acknowledgement
+type bufferedResponse struct {
+	header http.Header
+	code   int
+	body   bytes.Buffer
+}
+
+func (b *bufferedResponse) Header() http.Header {
+	return b.header
+}
+
+func (b *bufferedResponse) Write(p []byte) (int, error) {
+	return b.body.Write(p)
+}
+
+func (b *bufferedResponse) WriteHeader(code int) {
+	b.code = code
+}
@@ -0,0 +1,51 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/nlopes/slack"
+	"github.com/nlopes/slack/slackevents"
+
+	"github.com/skybet/go-helpdesk/views"
+)
+
+func TestServeSocketMode(t *testing.T) {
+	s := NewSlackHandler(basePath, "TOKEN", slackSecret, &dnHeader, log, logf, errorLog, errorLogf)
+	s.HandleCommand("/hd", func(res *Response, req *Request, ctx interface{}) error {
+		res.Text(http.StatusOK, "Usage for "+ctx.(slack.SlashCommand).UserID)
+		return nil
+	})
+	var event *slackevents.EventsAPIEvent
+	s.HandleEventCallback("app_home_opened", func(res *Response, req *Request, ctx interface{}) error {
+		event = ctx.(*slackevents.EventsAPIEvent)
+		return nil
+	})
+	s.HandleInteractionCallback("view_submission", "new_ticket", func(res *Response, req *Request, ctx interface{}) error {
+		return views.ValidationErrors{"title": "Too short"}
+	})
+
+	resp, err := s.ServeSocketMode("slash_commands", []byte(`{"command":"/hd","text":"help","user_id":"U1"}`))
+	if b, _ := json.Marshal(resp); err != nil || string(b) != `{"text":"Usage for U1"}` {
+		t.Errorf("Expected the command's reply as the response, got %s %v", b, err)
+	}
+
+	resp, err = s.ServeSocketMode("events_api", []byte(`{"type":"event_callback","event":{"type":"app_home_opened","user":"U1","tab":"home"}}`))
+	if err != nil || resp != nil || event == nil || event.InnerEvent.Type != "app_home_opened" {
+		t.Errorf("Expected the event to be routed without a response, got %v %v %+v", resp, err, event)
+	}
+
+	resp, err = s.ServeSocketMode("interactive", []byte(`{"type":"view_submission","user":{"id":"U1"},"view":{"id":"V1","type":"modal","callback_id":"new_ticket"}}`))
+	var action views.ResponseAction
+	if raw, ok := resp.(json.RawMessage); !ok || err != nil || json.Unmarshal(raw, &action) != nil || action.Errors["title"] != "Too short" {
+		t.Errorf("Expected the response action as the response, got %v %v", resp, err)
+	}
+
+	if _, err := s.ServeSocketMode("slash_commands", []byte(`{"command":"/unknown"}`)); err == nil {
+		t.Errorf("Expected unrouted commands to fail")
+	}
+	if _, err := s.ServeSocketMode("hello", nil); err == nil {
+		t.Errorf("Expected other envelope types to be refused")
+	}
+}
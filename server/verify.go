@@ -0,0 +1,73 @@
+package server
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultTimestampTolerance is how far a signed request's timestamp may be
+// from now before it is refused, as Slack recommends
+const DefaultTimestampTolerance = 5 * time.Minute
+
+// signatureVersion is the version of Slack's signing scheme that is checked
+const signatureVersion = "v0"
+
+// VerifySignature checks that body was signed with secret using Slack's v0
+// request signing, from the X-Slack-Signature and X-Slack-Request-Timestamp
+// headers. Signatures whose timestamp is more than tolerance from now are
+// refused, so a captured request can not be replayed later.
+func VerifySignature(header http.Header, body []byte, secret string, tolerance time.Duration, now time.Time) error {
+	timestamp, err := strconv.ParseInt(header.Get("X-Slack-Request-Timestamp"), 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid timestamp sent from slack: %s", err)
+	}
+	if skew := now.Sub(time.Unix(timestamp, 0)); skew > tolerance || skew < -tolerance {
+		return fmt.Errorf("stale timestamp sent from slack: %s from now", skew.Round(time.Second))
+	}
+	signature := header.Get("X-Slack-Signature")
+	if !strings.HasPrefix(signature, signatureVersion+"=") {
+		return errors.New("unsupported signature version sent from slack")
+	}
+	if !hmac.Equal([]byte(signature), []byte(Sign(secret, timestamp, body))) {
+		return errors.New("invalid signature sent from slack")
+	}
+	return nil
+}
+
+// Sign returns the X-Slack-Signature Slack sends with a body at a timestamp
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%s:%d:", signatureVersion, timestamp)
+	mac.Write(body)
+	return signatureVersion + "=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify is middleware which refuses requests that were not signed with the
+// handler's signing secret within its TimestampTolerance, or do not carry
+// Slack's certificate in its DN header if it has one. The SlackHandler
+// applies it itself, use it for other handlers Slack calls.
+func (h *SlackHandler) Verify(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req := &Request{Request: r}
+		if err := req.validate(h.secretToken, h.dnHeader, h.tolerance()); err != nil {
+			h.logger().Errorf("Bad request from slack: %s", err)
+			(&Response{w}).Text(http.StatusBadRequest, "invalid slack request")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (h *SlackHandler) tolerance() time.Duration {
+	if h.TimestampTolerance <= 0 {
+		return DefaultTimestampTolerance
+	}
+	return h.TimestampTolerance
+}
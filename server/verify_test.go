@@ -0,0 +1,74 @@
+package server
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func signedHeader(secret string, at time.Time, body string) http.Header {
+	h := http.Header{}
+	h.Set("X-Slack-Request-Timestamp", strconv.FormatInt(at.Unix(), 10))
+	h.Set("X-Slack-Signature", Sign(secret, at.Unix(), []byte(body)))
+	return h
+}
+
+func TestVerifySignature(t *testing.T) {
+	now := time.Unix(1600000000, 0)
+	body := "token=x&command=/hd"
+	for _, tt := range []struct {
+		name   string
+		header http.Header
+		body   string
+		err    string
+	}{
+		{"signed", signedHeader(slackSecret, now, body), body, ""},
+		{"within tolerance", signedHeader(slackSecret, now.Add(-time.Minute), body), body, ""},
+		{"replayed", signedHeader(slackSecret, now.Add(-3*time.Minute), body), body, "stale timestamp"},
+		{"from the future", signedHeader(slackSecret, now.Add(3*time.Minute), body), body, "stale timestamp"},
+		{"tampered", signedHeader(slackSecret, now, body), body + "&text=delete", "invalid signature"},
+		{"wrong secret", signedHeader("other", now, body), body, "invalid signature"},
+		{"no timestamp", http.Header{"X-Slack-Signature": {"v0=abc"}}, body, "invalid timestamp"},
+		{"newer version", http.Header{"X-Slack-Request-Timestamp": {"1600000000"}, "X-Slack-Signature": {"v1=abc"}}, body, "unsupported signature version"},
+	} {
+		err := VerifySignature(tt.header, []byte(tt.body), slackSecret, 2*time.Minute, now)
+		if tt.err == "" && err != nil {
+			t.Errorf("%s: Unexpected error: %s", tt.name, err)
+		}
+		if tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+			t.Errorf("%s: Expected an error containing %q, got %v", tt.name, tt.err, err)
+		}
+	}
+}
+
+func TestVerify(t *testing.T) {
+	s := NewSlackHandler(basePath, "TOKEN", slackSecret, nil, log, logf, errorLog, errorLogf)
+	s.TimestampTolerance = time.Minute
+	var served string
+	h := s.Verify(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		served = string(b)
+	}))
+
+	body := "payload=%7B%7D"
+	r := httptest.NewRequest("POST", "/other", strings.NewReader(body))
+	r.Header = signedHeader(slackSecret, time.Now(), body)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if w.Code != http.StatusOK || served != body {
+		t.Errorf("Expected a signed request to be passed on with its body, got %d %q", w.Code, served)
+	}
+
+	served = ""
+	r = httptest.NewRequest("POST", "/other", strings.NewReader(body))
+	r.Header = signedHeader(slackSecret, time.Now().Add(-2*time.Minute), body)
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if w.Code != http.StatusBadRequest || served != "" {
+		t.Errorf("Expected a request older than the tolerance to be refused, got %d", w.Code)
+	}
+}
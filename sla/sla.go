@@ -0,0 +1,198 @@
+// Package sla tracks tickets against separate targets for the first response
+// and for resolution
+package sla
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/skybet/go-helpdesk/store"
+	"github.com/skybet/go-helpdesk/ticket"
+)
+
+// AnyPriority is the priority name of the target used for tickets whose
+// priority has no target of its own, including those without a priority
+const AnyPriority = "*"
+
+// Targets are how long tickets of each priority may take, the zero priority
+// holds the AnyPriority target
+type Targets map[ticket.Priority]time.Duration
+
+// ParseTargets parses targets in the form <priority>=<duration>, e.g. P1=15m,
+// or *=8h for every other priority
+func ParseTargets(specs []string) (Targets, error) {
+	targets := Targets{}
+	for _, spec := range specs {
+		parts := strings.SplitN(spec, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid SLA target %q, expected <priority>=<duration>", spec)
+		}
+		var p ticket.Priority
+		if parts[0] != AnyPriority {
+			var err error
+			if p, err = ticket.ParsePriority(parts[0]); err != nil {
+				return nil, err
+			}
+		}
+		d, err := time.ParseDuration(parts[1])
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid SLA target duration in %q", spec)
+		}
+		targets[p] = d
+	}
+	return targets, nil
+}
+
+// For returns the target for a priority, false if there is none
+func (t Targets) For(p ticket.Priority) (time.Duration, bool) {
+	if d, ok := t[p]; ok {
+		return d, true
+	}
+	d, ok := t[0]
+	return d, ok
+}
+
+// Result is how a ticket is doing against a target
+type Result int
+
+// The results against a target
+const (
+	// NoTarget means the ticket's priority has no target
+	NoTarget Result = iota
+	// Pending tickets have not reached the milestone but are within target
+	Pending
+	Met
+	Breached
+)
+
+// SLA is the targets for the first response to a ticket and for resolving it
+type SLA struct {
+	Response   Targets
+	Resolution Targets
+	// Queues holds the targets of the queues whose targets differ from these
+	Queues map[string]SLA
+}
+
+// For returns the targets of tickets in a queue
+func (s SLA) For(queue string) SLA {
+	if q, ok := s.Queues[queue]; ok {
+		return q
+	}
+	return s
+}
+
+// Responded returns how t is doing against its first response target as of
+// now. A ticket resolved without a response was responded to when it was
+// resolved.
+func (s SLA) Responded(t *ticket.Ticket, now time.Time) Result {
+	at := FirstResponse(t)
+	if at.IsZero() {
+		at = t.ResolvedAt
+	}
+	return result(s.For(t.Queue).Response, t, at, now)
+}
+
+// Resolved returns how t is doing against its resolution target as of now
+func (s SLA) Resolved(t *ticket.Ticket, now time.Time) Result {
+	return result(s.For(t.Queue).Resolution, t, t.ResolvedAt, now)
+}
+
+func result(targets Targets, t *ticket.Ticket, at, now time.Time) Result {
+	target, ok := targets.For(t.Priority)
+	switch {
+	case !ok:
+		return NoTarget
+	case !at.IsZero() && at.Sub(t.CreatedAt) <= target:
+		return Met
+	case at.IsZero() && now.Sub(t.CreatedAt) <= target:
+		return Pending
+	}
+	return Breached
+}
+
+// FirstResponse returns when t was first responded to, zero if it has not
+// been. Without a recorded first response it is the first public comment by
+// someone other than the reporter, such as a reply in an imported thread.
+func FirstResponse(t *ticket.Ticket) time.Time {
+	if !t.FirstResponseAt.IsZero() {
+		return t.FirstResponseAt
+	}
+	for _, c := range t.Comments {
+		if c.Author != t.Reporter && !c.Internal {
+			return c.CreatedAt
+		}
+	}
+	return time.Time{}
+}
+
+// Respond records a message by user at in a ticket's thread. The first message
+// from anyone other than the reporter is the ticket's first response, false is
+// returned if the message was not, including when the thread is not a
+// ticket's.
+func Respond(ctx context.Context, s store.Store, channel, threadTS, user string, at time.Time) (*ticket.Ticket, bool, error) {
+	var responded *ticket.Ticket
+	err := s.Tx(ctx, func(tx store.Store) error {
+		ts, _, err := tx.ListTickets(ctx, store.Filter{ChannelID: channel, ThreadTS: threadTS, Limit: 1})
+		if err != nil || len(ts) == 0 {
+			return err
+		}
+		t := ts[0]
+		if user == t.Reporter || !t.FirstResponseAt.IsZero() {
+			return nil
+		}
+		t.FirstResponseAt = at
+		if err := tx.UpdateTicket(ctx, t); err != nil {
+			return err
+		}
+		responded = t
+		return nil
+	})
+	return responded, responded != nil, err
+}
+
+// Risk is an open ticket which has breached, or within the warning period
+// will breach, one of its targets
+type Risk struct {
+	Ticket *ticket.Ticket
+	// Target is "response" or "resolution"
+	Target string
+	Due    time.Time
+}
+
+// Breached reports whether the target had already been missed at now
+func (r Risk) Breached(now time.Time) bool {
+	return now.After(r.Due)
+}
+
+// AtRisk returns the first target t will miss within warn of now, the response
+// before the resolution
+func (s SLA) AtRisk(t *ticket.Ticket, now time.Time, warn time.Duration) (Risk, bool) {
+	risks := s.Risks(t, now, warn)
+	if len(risks) == 0 {
+		return Risk{}, false
+	}
+	return risks[0], true
+}
+
+// Risks returns every target t will miss within warn of now, the response
+// before the resolution
+func (s SLA) Risks(t *ticket.Ticket, now time.Time, warn time.Duration) []Risk {
+	if !t.Status.Open() {
+		return nil
+	}
+	var risks []Risk
+	s = s.For(t.Queue)
+	if target, ok := s.Response.For(t.Priority); ok && FirstResponse(t).IsZero() {
+		if due := t.CreatedAt.Add(target); !now.Add(warn).Before(due) {
+			risks = append(risks, Risk{Ticket: t, Target: "response", Due: due})
+		}
+	}
+	if target, ok := s.Resolution.For(t.Priority); ok {
+		if due := t.CreatedAt.Add(target); !now.Add(warn).Before(due) {
+			risks = append(risks, Risk{Ticket: t, Target: "resolution", Due: due})
+		}
+	}
+	return risks
+}
@@ -0,0 +1,141 @@
+package sla
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/skybet/go-helpdesk/store"
+	"github.com/skybet/go-helpdesk/ticket"
+)
+
+func TestParseTargets(t *testing.T) {
+	targets, err := ParseTargets([]string{"P1=15m", "*=8h"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if d, ok := targets.For(ticket.P1); !ok || d != 15*time.Minute {
+		t.Errorf("Expected a P1 target of 15m, got %s", d)
+	}
+	if d, ok := targets.For(ticket.P3); !ok || d != 8*time.Hour {
+		t.Errorf("Expected other priorities to use the default, got %s", d)
+	}
+	if _, ok := (Targets{}).For(ticket.P1); ok {
+		t.Errorf("Expected no target without any configured")
+	}
+	for _, spec := range []string{"P1", "P9=1h", "P1=soon", "P1=-1h"} {
+		if _, err := ParseTargets([]string{spec}); err == nil {
+			t.Errorf("Expected %q to be invalid", spec)
+		}
+	}
+}
+
+func TestResults(t *testing.T) {
+	created := time.Date(2019, 3, 4, 9, 0, 0, 0, time.UTC)
+	s := SLA{Response: Targets{ticket.P1: 15 * time.Minute}, Resolution: Targets{ticket.P1: 4 * time.Hour}}
+	tk := &ticket.Ticket{Priority: ticket.P1, CreatedAt: created}
+
+	if r := s.Responded(tk, created.Add(10*time.Minute)); r != Pending {
+		t.Errorf("Expected the response to be pending, got %d", r)
+	}
+	if r := s.Responded(tk, created.Add(20*time.Minute)); r != Breached {
+		t.Errorf("Expected the response to be breached, got %d", r)
+	}
+	tk.FirstResponseAt = created.Add(5 * time.Minute)
+	if r := s.Responded(tk, created.Add(time.Hour)); r != Met {
+		t.Errorf("Expected the response to be met, got %d", r)
+	}
+	// The targets are separate, responding quickly does not stop the clock
+	if r := s.Resolved(tk, created.Add(5*time.Hour)); r != Breached {
+		t.Errorf("Expected the resolution to be breached, got %d", r)
+	}
+	if r := s.Resolved(&ticket.Ticket{Priority: ticket.P2, CreatedAt: created}, created.Add(48*time.Hour)); r != NoTarget {
+		t.Errorf("Expected P2 to have no target, got %d", r)
+	}
+}
+
+func TestQueueTargets(t *testing.T) {
+	created := time.Date(2019, 3, 4, 9, 0, 0, 0, time.UTC)
+	s := SLA{
+		Response: Targets{ticket.P1: 15 * time.Minute},
+		Queues:   map[string]SLA{"payroll": {Response: Targets{ticket.P1: time.Hour}}},
+	}
+	if r := s.Responded(&ticket.Ticket{Priority: ticket.P1, CreatedAt: created}, created.Add(30*time.Minute)); r != Breached {
+		t.Errorf("Expected the default target outside the queue, got %d", r)
+	}
+	tk := &ticket.Ticket{Queue: "payroll", Priority: ticket.P1, CreatedAt: created, Status: ticket.StatusNew}
+	if r := s.Responded(tk, created.Add(30*time.Minute)); r != Pending {
+		t.Errorf("Expected the queue's own target, got %d", r)
+	}
+	if risks := s.Risks(tk, created.Add(30*time.Minute), 0); len(risks) != 0 {
+		t.Errorf("Expected no risks against the queue's target, got %+v", risks)
+	}
+}
+
+func TestRespond(t *testing.T) {
+	s := store.NewMemory()
+	s.CreateTicket(context.Background(), &ticket.Ticket{ID: "1", Reporter: "U1", ChannelID: "C1", ThreadTS: "1.1"})
+	now := time.Now()
+
+	if _, ok, err := Respond(context.Background(), s, "C1", "1.1", "U1", now); ok || err != nil {
+		t.Errorf("Expected the reporter's reply not to be a response, got %v %v", ok, err)
+	}
+	if _, ok, err := Respond(context.Background(), s, "C1", "9.9", "U2", now); ok || err != nil {
+		t.Errorf("Expected replies in other threads to be ignored, got %v %v", ok, err)
+	}
+	if tk, ok, err := Respond(context.Background(), s, "C1", "1.1", "U2", now); !ok || err != nil || !tk.FirstResponseAt.Equal(now) {
+		t.Errorf("Expected the first response to be recorded, got %v %v", ok, err)
+	}
+	if _, ok, _ := Respond(context.Background(), s, "C1", "1.1", "U3", now.Add(time.Minute)); ok {
+		t.Errorf("Expected only the first response to count")
+	}
+}
+
+func TestFirstResponse(t *testing.T) {
+	now := time.Now()
+	s := SLA{Response: Targets{ticket.P1: time.Hour}}
+	tk := &ticket.Ticket{Priority: ticket.P1, Reporter: "U1", CreatedAt: now, Comments: []ticket.Comment{
+		{ID: "1", Author: "U1", Text: "Any news?", CreatedAt: now.Add(10 * time.Minute)},
+		{ID: "2", Author: "U2", Text: "Agents only", Internal: true, CreatedAt: now.Add(20 * time.Minute)},
+		{ID: "3", Author: "U2", Text: "Looking now", CreatedAt: now.Add(30 * time.Minute)},
+	}}
+	if at := FirstResponse(tk); !at.Equal(now.Add(30 * time.Minute)) {
+		t.Errorf("Expected the first public reply by someone else, got %s", at)
+	}
+	if r := s.Responded(tk, now.Add(2*time.Hour)); r != Met {
+		t.Errorf("Expected the commented response to meet the target, got %d", r)
+	}
+	if _, ok := s.AtRisk(tk, now.Add(2*time.Hour), 0); ok {
+		t.Errorf("Expected a responded ticket not to be at risk")
+	}
+}
+
+func TestAtRisk(t *testing.T) {
+	created := time.Date(2019, 3, 4, 9, 0, 0, 0, time.UTC)
+	s := SLA{Response: Targets{ticket.P1: 15 * time.Minute}, Resolution: Targets{ticket.P1: 4 * time.Hour}}
+	tk := &ticket.Ticket{Priority: ticket.P1, Status: ticket.StatusNew, CreatedAt: created}
+
+	if _, ok := s.AtRisk(tk, created, 5*time.Minute); ok {
+		t.Errorf("Expected a new ticket not to be at risk")
+	}
+	r, ok := s.AtRisk(tk, created.Add(12*time.Minute), 5*time.Minute)
+	if !ok || r.Target != "response" || !r.Due.Equal(created.Add(15*time.Minute)) || r.Breached(created.Add(12*time.Minute)) {
+		t.Errorf("Expected the response to be at risk, got %+v, %t", r, ok)
+	}
+	tk.FirstResponseAt = created.Add(10 * time.Minute)
+	if _, ok := s.AtRisk(tk, created.Add(time.Hour), 5*time.Minute); ok {
+		t.Errorf("Expected a responded ticket not to be at risk until its resolution is")
+	}
+	r, ok = s.AtRisk(tk, created.Add(5*time.Hour), 5*time.Minute)
+	if !ok || r.Target != "resolution" || !r.Breached(created.Add(5*time.Hour)) {
+		t.Errorf("Expected the resolution to be breached, got %+v, %t", r, ok)
+	}
+	tk.FirstResponseAt = time.Time{}
+	if risks := s.Risks(tk, created.Add(5*time.Hour), 0); len(risks) != 2 || risks[0].Target != "response" || risks[1].Target != "resolution" {
+		t.Errorf("Expected both targets to be breached, got %+v", risks)
+	}
+	tk.Status = ticket.StatusResolved
+	if _, ok := s.AtRisk(tk, created.Add(5*time.Hour), 5*time.Minute); ok {
+		t.Errorf("Expected resolved tickets not to be at risk")
+	}
+}
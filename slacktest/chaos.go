@@ -0,0 +1,134 @@
+package slacktest
+
+import (
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Chaos configures the failures a Server injects. Rates are probabilities
+// between 0 and 1 evaluated per request (or per websocket frame). The same
+// Seed always produces the same sequence of failures.
+type Chaos struct {
+	Seed int64
+	// RateLimitRate is the chance of a 429 with a Retry-After header
+	RateLimitRate float64
+	// RetryAfter is sent with rate limited responses, rounded up to whole seconds
+	RetryAfter time.Duration
+	// ServerErrorRate is the chance of starting a burst of 5xx responses
+	ServerErrorRate float64
+	// ServerErrorBurst is the number of consecutive requests failed once a burst starts
+	ServerErrorBurst int
+	// SlowRate is the chance of delaying a response by Latency
+	SlowRate float64
+	Latency  time.Duration
+	// MalformedRate is the chance of responding with a body that is not valid JSON
+	MalformedRate float64
+	// DropFrameRate is the chance of silently dropping an outgoing websocket frame
+	DropFrameRate float64
+}
+
+// Fault is a single injected failure
+type Fault int
+
+// The faults which can be scheduled with FailNext
+const (
+	FaultRateLimit Fault = iota
+	FaultServerError
+	FaultSlow
+	FaultMalformed
+)
+
+type chaos struct {
+	Chaos
+	mu     sync.Mutex
+	rand   *rand.Rand
+	burst  int
+	queued []Fault
+}
+
+func newChaos(c Chaos) *chaos {
+	if c.RetryAfter == 0 {
+		c.RetryAfter = time.Second
+	}
+	if c.ServerErrorBurst == 0 {
+		c.ServerErrorBurst = 1
+	}
+	return &chaos{Chaos: c, rand: rand.New(rand.NewSource(c.Seed))}
+}
+
+// FailNext schedules fault to be injected into the next n Web API requests,
+// ahead of any random failures
+func (s *Server) FailNext(fault Fault, n int) {
+	s.chaos.mu.Lock()
+	defer s.chaos.mu.Unlock()
+	for i := 0; i < n; i++ {
+		s.chaos.queued = append(s.chaos.queued, fault)
+	}
+}
+
+// next decides which fault, if any, applies to the current request
+func (c *chaos) next() (Fault, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.queued) > 0 {
+		f := c.queued[0]
+		c.queued = c.queued[1:]
+		return f, true
+	}
+	if c.burst > 0 {
+		c.burst--
+		return FaultServerError, true
+	}
+	switch {
+	case c.roll(c.RateLimitRate):
+		return FaultRateLimit, true
+	case c.roll(c.ServerErrorRate):
+		c.burst = c.ServerErrorBurst - 1
+		return FaultServerError, true
+	case c.roll(c.SlowRate):
+		return FaultSlow, true
+	case c.roll(c.MalformedRate):
+		return FaultMalformed, true
+	}
+	return 0, false
+}
+
+// inject writes a failure response and returns true if the request should not
+// be handled normally. Slow responses are delayed and then handled as usual.
+func (c *chaos) inject(w http.ResponseWriter) bool {
+	f, ok := c.next()
+	if !ok {
+		return false
+	}
+	switch f {
+	case FaultRateLimit:
+		secs := int((c.RetryAfter + time.Second - 1) / time.Second)
+		w.Header().Set("Retry-After", strconv.Itoa(secs))
+		w.WriteHeader(http.StatusTooManyRequests)
+		return true
+	case FaultServerError:
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return true
+	case FaultSlow:
+		time.Sleep(c.Latency)
+		return false
+	case FaultMalformed:
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"ok": tr`))
+		return true
+	}
+	return false
+}
+
+func (c *chaos) dropFrame() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.roll(c.DropFrameRate)
+}
+
+func (c *chaos) roll(p float64) bool {
+	return p > 0 && c.rand.Float64() < p
+}
@@ -0,0 +1,277 @@
+// Package slacktest provides a fake Slack Web API and RTM and Socket Mode
+// websocket server for tests, with optional failure injection
+package slacktest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/gorilla/websocket"
+)
+
+// MethodHandler responds to a single Slack Web API method
+type MethodHandler func(w http.ResponseWriter, c *Call)
+
+// Call is a recorded request to the fake Web API
+type Call struct {
+	Method string
+	Header http.Header
+	Form   url.Values
+	Body   []byte
+}
+
+// Ack is a Socket Mode envelope acknowledged by a client
+type Ack struct {
+	EnvelopeID string          `json:"envelope_id"`
+	Payload    json.RawMessage `json:"payload,omitempty"`
+}
+
+// Server is a fake Slack server. Point a slack.Client at APIURL() with
+// slack.OptionAPIURL to use it.
+type Server struct {
+	*httptest.Server
+	chaos    *chaos
+	mu       sync.Mutex
+	handlers map[string]MethodHandler
+	calls    []*Call
+	conns    []*websocket.Conn
+	acks     []Ack
+	messages []Message
+	ts       int64
+	upgrader websocket.Upgrader
+}
+
+// NewServer starts a fake Slack server with failure injection configured by c
+func NewServer(c Chaos) *Server {
+	s := &Server{
+		chaos:    newChaos(c),
+		handlers: map[string]MethodHandler{},
+		// RTM clients always claim to originate from api.slack.com
+		upgrader: websocket.Upgrader{CheckOrigin: func(*http.Request) bool { return true }},
+	}
+	s.Handle("auth.test", func(w http.ResponseWriter, c *Call) {
+		Reply(w, map[string]interface{}{"url": "https://slacktest.slack.com/", "team": "slacktest", "user": "helpdesk", "team_id": "TSLACKTEST", "user_id": "UBOT"})
+	})
+	s.Handle("rtm.connect", func(w http.ResponseWriter, c *Call) {
+		Reply(w, map[string]interface{}{
+			"url":  s.WebsocketURL(),
+			"self": map[string]string{"id": "UBOT", "name": "helpdesk"},
+			"team": map[string]string{"id": "TSLACKTEST", "name": "slacktest", "domain": "slacktest"},
+		})
+	})
+	s.Handle("apps.connections.open", func(w http.ResponseWriter, c *Call) {
+		Reply(w, map[string]interface{}{"url": s.WebsocketURL()})
+	})
+	s.Handle("chat.postMessage", func(w http.ResponseWriter, c *Call) {
+		Reply(w, map[string]interface{}{"channel": c.Form.Get("channel"), "ts": s.nextTS()})
+	})
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/", s.serveAPI)
+	mux.HandleFunc("/ws", s.serveWebsocket)
+	s.Server = httptest.NewServer(mux)
+	return s
+}
+
+// APIURL returns the base URL of the fake Web API, including the trailing slash
+func (s *Server) APIURL() string {
+	return s.URL + "/api/"
+}
+
+// WebsocketURL returns the URL RTM and Socket Mode clients are told to dial
+func (s *Server) WebsocketURL() string {
+	return "ws" + strings.TrimPrefix(s.URL, "http") + "/ws"
+}
+
+// Handle registers a handler for a Web API method, replacing any existing one
+func (s *Server) Handle(method string, h MethodHandler) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.handlers[method] = h
+}
+
+// Calls returns the recorded calls to a Web API method
+func (s *Server) Calls(method string) []*Call {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var c []*Call
+	for _, call := range s.calls {
+		if call.Method == method {
+			c = append(c, call)
+		}
+	}
+	return c
+}
+
+// SendEvent writes an RTM event or Socket Mode envelope to every connected
+// websocket client
+func (s *Server) SendEvent(event interface{}) error {
+	b, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, c := range s.conns {
+		if s.chaos.dropFrame() {
+			continue
+		}
+		if err := c.WriteMessage(websocket.TextMessage, b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Message is a message sent over the RTM websocket
+type Message struct {
+	ID      int    `json:"id"`
+	Channel string `json:"channel"`
+	Text    string `json:"text"`
+}
+
+// Messages returns the messages sent over the RTM websocket so far
+func (s *Server) Messages() []Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Message(nil), s.messages...)
+}
+
+// Acks returns the Socket Mode envelopes acknowledged so far
+func (s *Server) Acks() []Ack {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Ack(nil), s.acks...)
+}
+
+// CloseConnections drops every connected websocket client
+func (s *Server) CloseConnections() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, c := range s.conns {
+		c.Close()
+	}
+	s.conns = nil
+}
+
+// Reply writes a successful Web API response merging in the given fields
+func Reply(w http.ResponseWriter, fields map[string]interface{}) {
+	body := map[string]interface{}{"ok": true}
+	for k, v := range fields {
+		body[k] = v
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(body)
+}
+
+// ReplyError writes a failed Web API response with the given error code
+func ReplyError(w http.ResponseWriter, code string) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"ok": false, "error": code})
+}
+
+func (s *Server) serveAPI(w http.ResponseWriter, r *http.Request) {
+	body, _ := ioutil.ReadAll(r.Body)
+	c := &Call{
+		Method: strings.TrimPrefix(r.URL.Path, "/api/"),
+		Header: r.Header,
+		Body:   body,
+	}
+	c.Form, _ = url.ParseQuery(string(body))
+	for k, v := range r.URL.Query() {
+		c.Form[k] = v
+	}
+
+	s.mu.Lock()
+	s.calls = append(s.calls, c)
+	h, ok := s.handlers[c.Method]
+	s.mu.Unlock()
+
+	if s.chaos.inject(w) {
+		return
+	}
+	if !ok {
+		Reply(w, nil)
+		return
+	}
+	h(w, c)
+}
+
+func (s *Server) serveWebsocket(w http.ResponseWriter, r *http.Request) {
+	conn, err := s.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	if err := conn.WriteJSON(map[string]string{"type": "hello"}); err != nil {
+		conn.Close()
+		return
+	}
+	s.mu.Lock()
+	s.conns = append(s.conns, conn)
+	s.mu.Unlock()
+
+	// Answer pings so the RTM client believes the connection is healthy,
+	// reply to RTM messages and record Socket Mode acknowledgements
+	for {
+		var msg struct {
+			Type string `json:"type"`
+			Message
+			Ack
+		}
+		if err := conn.ReadJSON(&msg); err != nil {
+			s.removeConn(conn)
+			return
+		}
+		if msg.EnvelopeID != "" {
+			s.mu.Lock()
+			s.acks = append(s.acks, msg.Ack)
+			s.mu.Unlock()
+			continue
+		}
+		reply := map[string]interface{}{"type": "pong", "reply_to": msg.ID}
+		switch {
+		case msg.Type == "message":
+			ts := s.nextTS()
+			s.mu.Lock()
+			s.messages = append(s.messages, msg.Message)
+			s.mu.Unlock()
+			reply = map[string]interface{}{"ok": true, "reply_to": msg.ID, "ts": ts, "text": msg.Text}
+		case msg.Type != "ping":
+			continue
+		}
+		if s.chaos.dropFrame() {
+			continue
+		}
+		s.mu.Lock()
+		err := conn.WriteJSON(reply)
+		s.mu.Unlock()
+		if err != nil {
+			s.removeConn(conn)
+			return
+		}
+	}
+}
+
+func (s *Server) removeConn(conn *websocket.Conn) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, c := range s.conns {
+		if c == conn {
+			s.conns = append(s.conns[:i], s.conns[i+1:]...)
+			break
+		}
+	}
+	conn.Close()
+}
+
+func (s *Server) nextTS() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ts++
+	return fmt.Sprintf("1500000000.%06d", s.ts)
+}
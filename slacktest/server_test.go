@@ -0,0 +1,150 @@
+package slacktest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nlopes/slack"
+)
+
+func client(s *Server) *slack.Client {
+	return slack.New("TOKEN", slack.OptionAPIURL(s.APIURL()))
+}
+
+func TestPostMessage(t *testing.T) {
+	s := NewServer(Chaos{})
+	defer s.Close()
+	_, ts, err := client(s).PostMessage("C1", slack.MsgOptionText("hello", false))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if ts == "" {
+		t.Errorf("Expected a message timestamp")
+	}
+	calls := s.Calls("chat.postMessage")
+	if len(calls) != 1 || calls[0].Form.Get("channel") != "C1" {
+		t.Fatalf("Expected a single recorded call to C1, got %+v", calls)
+	}
+}
+
+func TestFailNext(t *testing.T) {
+	s := NewServer(Chaos{RetryAfter: 2 * time.Second})
+	defer s.Close()
+	c := client(s)
+
+	s.FailNext(FaultRateLimit, 1)
+	_, err := c.AuthTest()
+	rl, ok := err.(*slack.RateLimitedError)
+	if !ok {
+		t.Fatalf("Expected a *slack.RateLimitedError, got %v", err)
+	}
+	if rl.RetryAfter != 2*time.Second {
+		t.Errorf("Unexpected Retry-After: %s", rl.RetryAfter)
+	}
+
+	s.FailNext(FaultServerError, 2)
+	for i := 0; i < 2; i++ {
+		if _, err := c.AuthTest(); err == nil {
+			t.Errorf("Expected request %d of the burst to fail", i)
+		}
+	}
+
+	s.FailNext(FaultMalformed, 1)
+	if _, err := c.AuthTest(); err == nil {
+		t.Errorf("Expected a malformed response to fail to parse")
+	}
+
+	if _, err := c.AuthTest(); err != nil {
+		t.Errorf("Expected faults to be exhausted, got %s", err)
+	}
+}
+
+func TestDeterministicChaos(t *testing.T) {
+	run := func() []bool {
+		s := NewServer(Chaos{Seed: 42, RateLimitRate: 0.3, ServerErrorRate: 0.2, ServerErrorBurst: 3})
+		defer s.Close()
+		c := client(s)
+		var failed []bool
+		for i := 0; i < 20; i++ {
+			_, err := c.AuthTest()
+			failed = append(failed, err != nil)
+		}
+		return failed
+	}
+	a, b := run(), run()
+	var failures int
+	for i := range a {
+		if a[i] != b[i] {
+			t.Fatalf("Expected the same seed to produce the same failures: %v != %v", a, b)
+		}
+		if a[i] {
+			failures++
+		}
+	}
+	if failures == 0 || failures == len(a) {
+		t.Errorf("Expected a mix of failures and successes, got %v", a)
+	}
+}
+
+func TestSlowResponse(t *testing.T) {
+	s := NewServer(Chaos{Latency: 50 * time.Millisecond})
+	defer s.Close()
+	s.FailNext(FaultSlow, 1)
+	start := time.Now()
+	if _, err := client(s).AuthTest(); err != nil {
+		t.Fatalf("Expected slow responses to succeed, got %s", err)
+	}
+	if time.Since(start) < 50*time.Millisecond {
+		t.Errorf("Expected the response to be delayed")
+	}
+}
+
+func waitForEvent(t *testing.T, rtm *slack.RTM, match func(slack.RTMEvent) bool) bool {
+	timeout := time.After(time.Second)
+	for {
+		select {
+		case e := <-rtm.IncomingEvents:
+			if match(e) {
+				return true
+			}
+		case <-timeout:
+			return false
+		}
+	}
+}
+
+func TestWebsocketFrames(t *testing.T) {
+	tt := []struct {
+		name      string
+		dropRate  float64
+		delivered bool
+	}{
+		{"Frames are delivered", 0, true},
+		{"Frames are dropped", 1, false},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			s := NewServer(Chaos{DropFrameRate: tc.dropRate})
+			defer s.Close()
+			rtm := client(s).NewRTM()
+			go rtm.ManageConnection()
+			defer rtm.Disconnect()
+
+			connected := waitForEvent(t, rtm, func(e slack.RTMEvent) bool {
+				_, ok := e.Data.(*slack.ConnectedEvent)
+				return ok
+			})
+			if !connected {
+				t.Fatalf("Expected the RTM client to connect")
+			}
+			s.SendEvent(map[string]string{"type": "message", "channel": "C1", "text": "hello"})
+			got := waitForEvent(t, rtm, func(e slack.RTMEvent) bool {
+				m, ok := e.Data.(*slack.MessageEvent)
+				return ok && m.Text == "hello"
+			})
+			if got != tc.delivered {
+				t.Errorf("Expected delivered to be %t", tc.delivered)
+			}
+		})
+	}
+}
@@ -0,0 +1,383 @@
+package sso
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/skybet/go-helpdesk/clock"
+)
+
+// The roles people can be given, see ParseRoles
+const (
+	// Admin may use the admin API, other than exporting, and the reporting API
+	Admin = "admin"
+	// Export may export tickets through the admin API
+	Export = "export"
+	// Reports may read the reporting API
+	Reports = "reports"
+)
+
+const (
+	sessionCookie = "helpdesk_session"
+	stateCookie   = "helpdesk_login"
+	loginTimeout  = 10 * time.Minute
+)
+
+// User is who a request was signed in as
+type User struct {
+	Subject string   `json:"sub"`
+	Email   string   `json:"email,omitempty"`
+	Name    string   `json:"name,omitempty"`
+	Roles   []string `json:"roles"`
+}
+
+// String returns the user's email, or their subject if the provider gave none
+func (u *User) String() string {
+	if u.Email != "" {
+		return u.Email
+	}
+	return u.Subject
+}
+
+// Has reports whether the user has any of the roles
+func (u *User) Has(roles ...string) bool {
+	for _, have := range u.Roles {
+		for _, want := range roles {
+			if have == want {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+type userKey struct{}
+
+// NewContext returns ctx carrying the user a request was signed in as
+func NewContext(ctx context.Context, u *User) context.Context {
+	return context.WithValue(ctx, userKey{}, u)
+}
+
+// FromContext returns the user a request passed by Require was signed in as,
+// or nil if it was let through with a token instead
+func FromContext(ctx context.Context) *User {
+	u, _ := ctx.Value(userKey{}).(*User)
+	return u
+}
+
+// ParseRoles parses the claim values which give each role, such as
+// admin=helpdesk-admins or reports=team-leads, into the values of each role
+func ParseRoles(specs []string) (map[string][]string, error) {
+	roles := map[string][]string{}
+	for _, spec := range specs {
+		parts := strings.SplitN(spec, "=", 2)
+		if len(parts) != 2 || parts[1] == "" {
+			return nil, fmt.Errorf("invalid role %q, expected role=claim value", spec)
+		}
+		switch parts[0] {
+		case Admin, Export, Reports:
+		default:
+			return nil, fmt.Errorf("unknown role %q, expected %s, %s or %s", parts[0], Admin, Export, Reports)
+		}
+		roles[parts[0]] = append(roles[parts[0]], parts[1])
+	}
+	return roles, nil
+}
+
+type session struct {
+	user    *User
+	expires time.Time
+}
+
+type pending struct {
+	nonce   string
+	next    string
+	expires time.Time
+}
+
+// Login signs people in with a Provider and keeps their sessions. Mount it
+// with http.StripPrefix so that its routes, /login, /callback, /logout and
+// /me, are at the root, and put what needs signing in to behind Require.
+// Sessions are kept in memory, so a restart signs everyone out.
+type Login struct {
+	Provider *Provider
+	// RedirectURL is the URL of /callback, as registered with the provider
+	RedirectURL string
+	// RoleClaim is the claim, such as groups, whose values give the Roles
+	RoleClaim string
+	// Roles are the claim values which give each role
+	Roles map[string][]string
+	// TTL is how long a session lasts
+	TTL time.Duration
+	// Token is presented to the handlers behind Require which were not given
+	// one, in place of the user's credentials. It is random so that it is
+	// never known outside the server.
+	Token string
+	// Logf logs each sign in
+	Logf func(format string, args ...interface{})
+	// Clock, if set, replaces the wall clock
+	Clock clock.Clock
+
+	mu       sync.Mutex
+	sessions map[string]*session
+	pending  map[string]*pending
+}
+
+// NewLogin returns a Login through p, which the provider redirects back to at
+// redirectURL, giving roles by the values of the groups claim
+func NewLogin(p *Provider, redirectURL string, roles map[string][]string) *Login {
+	return &Login{
+		Provider:    p,
+		RedirectURL: redirectURL,
+		RoleClaim:   "groups",
+		Roles:       roles,
+		TTL:         8 * time.Hour,
+		Token:       random(),
+		sessions:    map[string]*session{},
+		pending:     map[string]*pending{},
+	}
+}
+
+// ServeHTTP satisfies http.Handler. GET /login?next=/path sends the browser to
+// the provider, which returns it to /callback to start a session and go on to
+// next. POST /logout ends the session and GET /me returns its user as JSON.
+func (l *Login) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.URL.Path == "/login" && r.Method == http.MethodGet:
+		l.login(w, r)
+	case r.URL.Path == "/callback" && r.Method == http.MethodGet:
+		l.callback(w, r)
+	case r.URL.Path == "/logout" && r.Method == http.MethodPost:
+		l.logout(w, r)
+	case r.URL.Path == "/me" && r.Method == http.MethodGet:
+		u, err := l.authenticate(r)
+		if err != nil || u == nil {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(u)
+	case r.URL.Path == "/login" || r.URL.Path == "/callback" || r.URL.Path == "/logout" || r.URL.Path == "/me":
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// Require returns h behind the login: requests must come from a session, or
+// carry an ID token from the provider as a bearer token, of someone with one
+// of the roles. They are passed to h with token, or Token if it is empty, as
+// their bearer token so that h's own check passes, and the user in their
+// context. Requests with neither are passed to h as they are, so that a token
+// h already accepts keeps working.
+func (l *Login) Require(h http.Handler, token string, roles ...string) http.Handler {
+	if token == "" {
+		token = l.Token
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		u, err := l.authenticate(r)
+		if err != nil {
+			w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if u == nil {
+			h.ServeHTTP(w, r)
+			return
+		}
+		if !u.Has(roles...) {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		r = r.WithContext(NewContext(r.Context(), u))
+		r.Header = cloneHeader(r.Header)
+		r.Header.Set("Authorization", "Bearer "+token)
+		h.ServeHTTP(w, r)
+	})
+}
+
+// authenticate returns the user of a request's session or ID token, nil if
+// it has neither and an error if its ID token is invalid
+func (l *Login) authenticate(r *http.Request) (*User, error) {
+	if c, err := r.Cookie(sessionCookie); err == nil {
+		l.mu.Lock()
+		s := l.sessions[c.Value]
+		if s != nil && !clock.Or(l.Clock).Now().Before(s.expires) {
+			delete(l.sessions, c.Value)
+			s = nil
+		}
+		l.mu.Unlock()
+		if s != nil {
+			return s.user, nil
+		}
+	}
+	// The API tokens are not JWTs, whose three parts are separated by dots
+	raw := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if strings.Count(raw, ".") != 2 {
+		return nil, nil
+	}
+	claims, err := l.Provider.Verify(r.Context(), raw)
+	if err != nil {
+		return nil, err
+	}
+	return l.user(claims), nil
+}
+
+func (l *Login) login(w http.ResponseWriter, r *http.Request) {
+	next := r.URL.Query().Get("next")
+	// Only go on to paths on this server
+	if !strings.HasPrefix(next, "/") || strings.HasPrefix(next, "//") || strings.HasPrefix(next, "/\\") {
+		next = "/auth/me"
+	}
+	state, nonce := random(), random()
+	now := clock.Or(l.Clock).Now()
+	l.mu.Lock()
+	for s, p := range l.pending {
+		if now.After(p.expires) {
+			delete(l.pending, s)
+		}
+	}
+	l.pending[state] = &pending{nonce: nonce, next: next, expires: now.Add(loginTimeout)}
+	l.mu.Unlock()
+	// The state is also kept in a cookie so that only the browser which
+	// started the login can finish it
+	http.SetCookie(w, &http.Cookie{Name: stateCookie, Value: state, Path: "/", MaxAge: int(loginTimeout.Seconds()), HttpOnly: true, Secure: l.secure(), SameSite: http.SameSiteLaxMode})
+	q := url.Values{
+		"response_type": {"code"},
+		"client_id":     {l.Provider.ClientID},
+		"redirect_uri":  {l.RedirectURL},
+		"scope":         {"openid email profile"},
+		"state":         {state},
+		"nonce":         {nonce},
+	}
+	sep := "?"
+	if strings.Contains(l.Provider.AuthURL, "?") {
+		sep = "&"
+	}
+	http.Redirect(w, r, l.Provider.AuthURL+sep+q.Encode(), http.StatusFound)
+}
+
+func (l *Login) callback(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	state := q.Get("state")
+	c, err := r.Cookie(stateCookie)
+	if err != nil || state == "" || subtle.ConstantTimeCompare([]byte(c.Value), []byte(state)) != 1 {
+		http.Error(w, "login not started from this browser", http.StatusBadRequest)
+		return
+	}
+	http.SetCookie(w, &http.Cookie{Name: stateCookie, Path: "/", MaxAge: -1, HttpOnly: true, Secure: l.secure()})
+	now := clock.Or(l.Clock).Now()
+	l.mu.Lock()
+	p := l.pending[state]
+	delete(l.pending, state)
+	l.mu.Unlock()
+	if p == nil || now.After(p.expires) {
+		http.Error(w, "login expired, try again", http.StatusBadRequest)
+		return
+	}
+	if e := q.Get("error"); e != "" {
+		http.Error(w, fmt.Sprintf("login refused: %s", e), http.StatusUnauthorized)
+		return
+	}
+	raw, err := l.Provider.Exchange(r.Context(), q.Get("code"), l.RedirectURL)
+	if err != nil {
+		l.logf("Failed to sign in: %s", err)
+		http.Error(w, "error signing in", http.StatusBadGateway)
+		return
+	}
+	claims, err := l.Provider.Verify(r.Context(), raw)
+	if err != nil || claims.String("nonce") != p.nonce {
+		l.logf("Failed to sign in, the provider's ID token is invalid: %v", err)
+		http.Error(w, "error signing in", http.StatusUnauthorized)
+		return
+	}
+	u := l.user(claims)
+	if len(u.Roles) == 0 {
+		l.logf("Refused %s who has no helpdesk roles", u)
+		http.Error(w, "you have no helpdesk roles", http.StatusForbidden)
+		return
+	}
+	id := random()
+	l.mu.Lock()
+	for id, s := range l.sessions {
+		if !now.Before(s.expires) {
+			delete(l.sessions, id)
+		}
+	}
+	l.sessions[id] = &session{user: u, expires: now.Add(l.TTL)}
+	l.mu.Unlock()
+	l.logf("Signed in %s as %s", u, strings.Join(u.Roles, ", "))
+	http.SetCookie(w, &http.Cookie{Name: sessionCookie, Value: id, Path: "/", MaxAge: int(l.TTL.Seconds()), HttpOnly: true, Secure: l.secure(), SameSite: http.SameSiteLaxMode})
+	http.Redirect(w, r, p.next, http.StatusFound)
+}
+
+func (l *Login) logout(w http.ResponseWriter, r *http.Request) {
+	if c, err := r.Cookie(sessionCookie); err == nil {
+		l.mu.Lock()
+		delete(l.sessions, c.Value)
+		l.mu.Unlock()
+	}
+	http.SetCookie(w, &http.Cookie{Name: sessionCookie, Path: "/", MaxAge: -1, HttpOnly: true, Secure: l.secure()})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// user returns the user of an ID token's claims with the roles they give
+func (l *Login) user(c Claims) *User {
+	u := &User{Subject: c.String("sub"), Email: c.String("email"), Name: c.String("name")}
+	values := c.Strings(l.RoleClaim)
+	for _, role := range []string{Admin, Export, Reports} {
+		for _, want := range l.Roles[role] {
+			if contains(values, want) && !contains(u.Roles, role) {
+				u.Roles = append(u.Roles, role)
+			}
+		}
+	}
+	return u
+}
+
+// secure reports whether cookies should only be sent over HTTPS, which is
+// whenever the server is reached over it
+func (l *Login) secure() bool {
+	return strings.HasPrefix(l.RedirectURL, "https://")
+}
+
+func (l *Login) logf(format string, args ...interface{}) {
+	if l.Logf != nil {
+		l.Logf(format, args...)
+	}
+}
+
+func contains(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func cloneHeader(h http.Header) http.Header {
+	c := make(http.Header, len(h))
+	for k, v := range h {
+		c[k] = append([]string(nil), v...)
+	}
+	return c
+}
+
+// random returns 32 random bytes as hex, for session IDs, states and nonces
+func random() string {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		panic(fmt.Sprintf("error reading random bytes: %s", err))
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,223 @@
+package sso
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/skybet/go-helpdesk/clock"
+)
+
+// newTestLogin returns a server with the login under /auth and an API behind
+// it at /api which accepts the bearer token "static"
+func newTestLogin(t *testing.T, f *fakeProvider) (*Login, *httptest.Server) {
+	p, err := Discover(context.Background(), f.URL, "helpdesk", "shh", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	roles, _ := ParseRoles([]string{"admin=helpdesk-admins", "reports=leads", "reports=helpdesk-admins"})
+	l := NewLogin(p, "", roles)
+	api := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer static" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		user := "token"
+		if u := FromContext(r.Context()); u != nil {
+			user = u.String()
+		}
+		w.Write([]byte(user))
+	})
+	mux := http.NewServeMux()
+	mux.Handle("/auth/", http.StripPrefix("/auth", l))
+	mux.Handle("/api", l.Require(api, "static", Admin))
+	srv := httptest.NewServer(mux)
+	l.RedirectURL = srv.URL + "/auth/callback"
+	return l, srv
+}
+
+// signIn logs in through the fake provider as someone with claims, returning
+// the response to the callback
+func signIn(t *testing.T, f *fakeProvider, srv *httptest.Server, client *http.Client, next string, claims map[string]interface{}) *http.Response {
+	res, err := client.Get(srv.URL + "/auth/login?next=" + url.QueryEscape(next))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusFound {
+		t.Fatalf("Expected a redirect to the provider, got %s", res.Status)
+	}
+	to, _ := url.Parse(res.Header.Get("Location"))
+	q := to.Query()
+	if to.Path != "/authorize" || q.Get("client_id") != "helpdesk" || q.Get("redirect_uri") != srv.URL+"/auth/callback" || q.Get("state") == "" || !strings.Contains(q.Get("scope"), "openid") {
+		t.Fatalf("Expected to be sent to the provider to authorize, got %s", to)
+	}
+	claims["nonce"] = q.Get("nonce")
+	f.mu.Lock()
+	f.codes["code-"+q.Get("state")] = claims
+	f.mu.Unlock()
+	res, err = client.Get(q.Get("redirect_uri") + "?code=code-" + q.Get("state") + "&state=" + q.Get("state"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	return res
+}
+
+func newClient() *http.Client {
+	jar, _ := cookiejar.New(nil)
+	return &http.Client{Jar: jar, CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+}
+
+func get(t *testing.T, client *http.Client, u, bearer string) (int, string) {
+	req, _ := http.NewRequest(http.MethodGet, u, nil)
+	if bearer != "" {
+		req.Header.Set("Authorization", "Bearer "+bearer)
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	body, _ := ioutil.ReadAll(res.Body)
+	return res.StatusCode, strings.TrimSpace(string(body))
+}
+
+func TestLogin(t *testing.T) {
+	f := newFakeProvider(t)
+	defer f.Close()
+	l, srv := newTestLogin(t, f)
+	defer srv.Close()
+	fake := clock.NewFake(time.Now())
+	l.Clock = fake
+	client := newClient()
+
+	if code, _ := get(t, client, srv.URL+"/api", ""); code != http.StatusUnauthorized {
+		t.Errorf("Expected the API to refuse a request without a session, got %d", code)
+	}
+	res := signIn(t, f, srv, client, "/api", f.claims("ann", "staff", "helpdesk-admins"))
+	if res.StatusCode != http.StatusFound || res.Header.Get("Location") != "/api" {
+		t.Fatalf("Expected to be sent on to /api, got %s %s", res.Status, res.Header.Get("Location"))
+	}
+	if code, body := get(t, client, srv.URL+"/api", ""); code != http.StatusOK || body != "ann@example.com" {
+		t.Errorf("Expected the API to be called as the user, got %d %s", code, body)
+	}
+	if code, body := get(t, client, srv.URL+"/auth/me", ""); code != http.StatusOK || !strings.Contains(body, `"roles":["admin","reports"]`) {
+		t.Errorf("Expected the user's roles, got %d %s", code, body)
+	}
+
+	fake.Advance(l.TTL)
+	if code, _ := get(t, client, srv.URL+"/api", ""); code != http.StatusUnauthorized {
+		t.Errorf("Expected the session to expire, got %d", code)
+	}
+
+	signIn(t, f, srv, client, "/api", f.claims("ann", "helpdesk-admins"))
+	req, _ := http.NewRequest(http.MethodPost, srv.URL+"/auth/logout", nil)
+	if res, err := client.Do(req); err != nil || res.StatusCode != http.StatusNoContent {
+		t.Fatalf("Expected to log out, got %v %v", res, err)
+	}
+	if code, _ := get(t, client, srv.URL+"/api", ""); code != http.StatusUnauthorized {
+		t.Errorf("Expected the session to end on logging out, got %d", code)
+	}
+}
+
+func TestLoginRoles(t *testing.T) {
+	f := newFakeProvider(t)
+	defer f.Close()
+	_, srv := newTestLogin(t, f)
+	defer srv.Close()
+
+	client := newClient()
+	if res := signIn(t, f, srv, client, "/api", f.claims("bob", "staff")); res.StatusCode != http.StatusForbidden {
+		t.Errorf("Expected someone without roles to be refused, got %s", res.Status)
+	}
+	if res := signIn(t, f, srv, client, "/api", f.claims("cat", "leads")); res.StatusCode != http.StatusFound {
+		t.Fatalf("Expected to sign in, got %s", res.Status)
+	}
+	if code, _ := get(t, client, srv.URL+"/api", ""); code != http.StatusForbidden {
+		t.Errorf("Expected someone without the API's role to be forbidden, got %d", code)
+	}
+}
+
+func TestLoginBearer(t *testing.T) {
+	f := newFakeProvider(t)
+	defer f.Close()
+	_, srv := newTestLogin(t, f)
+	defer srv.Close()
+	client := newClient()
+
+	if code, body := get(t, client, srv.URL+"/api", f.sign("k1", f.claims("ann", "helpdesk-admins"))); code != http.StatusOK || body != "ann@example.com" {
+		t.Errorf("Expected an ID token to be accepted as a bearer token, got %d %s", code, body)
+	}
+	if code, _ := get(t, client, srv.URL+"/api", f.sign("k1", f.claims("cat", "leads"))); code != http.StatusForbidden {
+		t.Errorf("Expected an ID token without the role to be forbidden, got %d", code)
+	}
+	expired := f.claims("ann", "helpdesk-admins")
+	expired["exp"] = time.Now().Add(-time.Hour).Unix()
+	if code, _ := get(t, client, srv.URL+"/api", f.sign("k1", expired)); code != http.StatusUnauthorized {
+		t.Errorf("Expected an expired ID token to be refused, got %d", code)
+	}
+	if code, body := get(t, client, srv.URL+"/api", "static"); code != http.StatusOK || body != "token" {
+		t.Errorf("Expected the API's own token to keep working, got %d %s", code, body)
+	}
+	if code, _ := get(t, client, srv.URL+"/api", "guess"); code != http.StatusUnauthorized {
+		t.Errorf("Expected a wrong token to be refused, got %d", code)
+	}
+}
+
+func TestLoginCallback(t *testing.T) {
+	f := newFakeProvider(t)
+	defer f.Close()
+	_, srv := newTestLogin(t, f)
+	defer srv.Close()
+
+	client := newClient()
+	res, _ := client.Get(srv.URL + "/auth/login?next=//evil.example.com")
+	to, _ := url.Parse(res.Header.Get("Location"))
+	state := to.Query().Get("state")
+	claims := f.claims("ann", "helpdesk-admins")
+	claims["nonce"] = to.Query().Get("nonce")
+	f.codes["code"] = claims
+
+	if code, _ := get(t, newClient(), srv.URL+"/auth/callback?code=code&state="+state, ""); code != http.StatusBadRequest {
+		t.Errorf("Expected a callback from another browser to be refused, got %d", code)
+	}
+	res, _ = client.Get(srv.URL + "/auth/callback?code=code&state=" + state)
+	if res.StatusCode != http.StatusFound || res.Header.Get("Location") != "/auth/me" {
+		t.Errorf("Expected to be sent to /auth/me rather than another site, got %s %s", res.Status, res.Header.Get("Location"))
+	}
+	if code, _ := get(t, client, srv.URL+"/auth/callback?code=code&state="+state, ""); code != http.StatusBadRequest {
+		t.Errorf("Expected a login to only finish once, got %d", code)
+	}
+
+	res, _ = client.Get(srv.URL + "/auth/login")
+	to, _ = url.Parse(res.Header.Get("Location"))
+	state = to.Query().Get("state")
+	claims = f.claims("ann", "helpdesk-admins")
+	claims["nonce"] = "replayed"
+	f.codes["code"] = claims
+	if code, _ := get(t, client, srv.URL+"/auth/callback?code=code&state="+state, ""); code != http.StatusUnauthorized {
+		t.Errorf("Expected an ID token for another login to be refused, got %d", code)
+	}
+}
+
+func TestParseRoles(t *testing.T) {
+	roles, err := ParseRoles([]string{"admin=helpdesk-admins", "export=auditors", "export=helpdesk-admins"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if len(roles[Admin]) != 1 || len(roles[Export]) != 2 || roles[Export][0] != "auditors" {
+		t.Errorf("Expected the values of each role, got %+v", roles)
+	}
+	for _, spec := range []string{"admin", "admin=", "owner=staff"} {
+		if _, err := ParseRoles([]string{spec}); err == nil {
+			t.Errorf("Expected an error parsing %q", spec)
+		}
+	}
+}
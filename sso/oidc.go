@@ -0,0 +1,251 @@
+// Package sso signs people in to the admin and reporting APIs with an OpenID
+// Connect provider, such as Okta, Azure AD or Google, instead of a token
+// shared between everyone using them. What each person may do comes from the
+// claims of their ID token.
+package sso
+
+import (
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/skybet/go-helpdesk/clock"
+)
+
+// Provider verifies the ID tokens of an OpenID Connect provider and exchanges
+// authorization codes for them
+type Provider struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	// AuthURL, TokenURL and KeysURL are the provider's endpoints, see Discover
+	AuthURL  string
+	TokenURL string
+	KeysURL  string
+	// HTTP sends the requests, http.DefaultClient if nil
+	HTTP *http.Client
+	// Clock, if set, replaces the wall clock
+	Clock clock.Clock
+
+	mu      sync.Mutex
+	keys    map[string]*rsa.PublicKey
+	fetched time.Time
+}
+
+// Discover returns the Provider of issuer, reading its endpoints from its
+// discovery document
+func Discover(ctx context.Context, issuer, clientID, clientSecret string, hc *http.Client) (*Provider, error) {
+	p := &Provider{Issuer: issuer, ClientID: clientID, ClientSecret: clientSecret, HTTP: hc}
+	var doc struct {
+		Issuer   string `json:"issuer"`
+		AuthURL  string `json:"authorization_endpoint"`
+		TokenURL string `json:"token_endpoint"`
+		KeysURL  string `json:"jwks_uri"`
+	}
+	if err := p.get(ctx, strings.TrimSuffix(issuer, "/")+"/.well-known/openid-configuration", &doc); err != nil {
+		return nil, fmt.Errorf("error discovering %s: %s", issuer, err)
+	}
+	if doc.Issuer != issuer {
+		return nil, fmt.Errorf("%s reports its issuer as %q", issuer, doc.Issuer)
+	}
+	if doc.AuthURL == "" || doc.TokenURL == "" || doc.KeysURL == "" {
+		return nil, fmt.Errorf("%s does not list its authorization, token and key endpoints", issuer)
+	}
+	p.AuthURL, p.TokenURL, p.KeysURL = doc.AuthURL, doc.TokenURL, doc.KeysURL
+	return p, nil
+}
+
+// Claims are the claims of a verified ID token
+type Claims map[string]interface{}
+
+// String returns a claim which is a string, or "" if it is not
+func (c Claims) String(name string) string {
+	s, _ := c[name].(string)
+	return s
+}
+
+// Strings returns a claim which is a string or a list of them, such as groups
+func (c Claims) Strings(name string) []string {
+	switch v := c[name].(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		var values []string
+		for _, s := range v {
+			if s, ok := s.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	}
+	return nil
+}
+
+// Verify checks an ID token was signed by the provider for this client and
+// has not expired, returning its claims. Only RS256 signatures are accepted.
+func (p *Provider) Verify(ctx context.Context, raw string) (Claims, error) {
+	parts := strings.Split(raw, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("malformed ID token")
+	}
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("malformed ID token header: %s", err)
+	}
+	if header.Alg != "RS256" {
+		return nil, fmt.Errorf("ID token signed with %q, expected RS256", header.Alg)
+	}
+	key, err := p.key(ctx, header.Kid)
+	if err != nil {
+		return nil, err
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("malformed ID token signature: %s", err)
+	}
+	sum := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, sum[:], sig); err != nil {
+		return nil, fmt.Errorf("invalid ID token signature")
+	}
+	var claims Claims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("malformed ID token claims: %s", err)
+	}
+	if iss := claims.String("iss"); iss != p.Issuer {
+		return nil, fmt.Errorf("ID token issued by %q", iss)
+	}
+	audience := false
+	for _, aud := range claims.Strings("aud") {
+		audience = audience || aud == p.ClientID
+	}
+	if !audience {
+		return nil, fmt.Errorf("ID token is not for this client")
+	}
+	exp, ok := claims["exp"].(float64)
+	// Allow a minute for the clocks of the provider and this server to differ
+	if !ok || clock.Or(p.Clock).Now().After(time.Unix(int64(exp), 0).Add(time.Minute)) {
+		return nil, fmt.Errorf("ID token has expired")
+	}
+	if claims.String("sub") == "" {
+		return nil, fmt.Errorf("ID token has no subject")
+	}
+	return claims, nil
+}
+
+// Exchange redeems an authorization code for its ID token
+func (p *Provider) Exchange(ctx context.Context, code, redirectURL string) (string, error) {
+	form := url.Values{"grant_type": {"authorization_code"}, "code": {code}, "redirect_uri": {redirectURL}}
+	req, err := http.NewRequest(http.MethodPost, p.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.SetBasicAuth(url.QueryEscape(p.ClientID), url.QueryEscape(p.ClientSecret))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	var tokens struct {
+		IDToken string `json:"id_token"`
+	}
+	if err := p.do(req.WithContext(ctx), &tokens); err != nil {
+		return "", fmt.Errorf("error exchanging the authorization code: %s", err)
+	}
+	if tokens.IDToken == "" {
+		return "", fmt.Errorf("the provider returned no ID token")
+	}
+	return tokens.IDToken, nil
+}
+
+// key returns the provider's signing key with ID kid. The keys are fetched
+// again when kid is unknown, as providers rotate them, but no more than once
+// a minute so that tokens with made up IDs can not hammer the provider.
+func (p *Provider) key(ctx context.Context, kid string) (*rsa.PublicKey, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if key := p.keys[kid]; key != nil {
+		return key, nil
+	}
+	now := clock.Or(p.Clock).Now()
+	if now.Sub(p.fetched) < time.Minute {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	var set struct {
+		Keys []struct {
+			Kid string `json:"kid"`
+			Kty string `json:"kty"`
+			Use string `json:"use"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		} `json:"keys"`
+	}
+	if err := p.get(ctx, p.KeysURL, &set); err != nil {
+		return nil, fmt.Errorf("error fetching signing keys: %s", err)
+	}
+	p.fetched = now
+	p.keys = map[string]*rsa.PublicKey{}
+	for _, k := range set.Keys {
+		if k.Kty != "RSA" || (k.Use != "" && k.Use != "sig") {
+			continue
+		}
+		n, errN := base64.RawURLEncoding.DecodeString(k.N)
+		e, errE := base64.RawURLEncoding.DecodeString(k.E)
+		if errN != nil || errE != nil || len(e) > 4 {
+			continue
+		}
+		p.keys[k.Kid] = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+	}
+	if key := p.keys[kid]; key != nil {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+func (p *Provider) get(ctx context.Context, u string, out interface{}) error {
+	req, err := http.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	return p.do(req.WithContext(ctx), out)
+}
+
+// do sends req and decodes its JSON response into out
+func (p *Provider) do(req *http.Request, out interface{}) error {
+	req.Header.Set("Accept", "application/json")
+	client := p.HTTP
+	if client == nil {
+		client = http.DefaultClient
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode/100 != 2 {
+		msg, _ := ioutil.ReadAll(io.LimitReader(res.Body, 512))
+		return fmt.Errorf("%s returned %s: %s", req.URL.Host, res.Status, strings.TrimSpace(string(msg)))
+	}
+	if err := json.NewDecoder(res.Body).Decode(out); err != nil {
+		return fmt.Errorf("error decoding response: %s", err)
+	}
+	return nil
+}
+
+func decodeSegment(s string, out interface{}) error {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, out)
+}
@@ -0,0 +1,197 @@
+package sso
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/skybet/go-helpdesk/clock"
+)
+
+var (
+	keyOnce sync.Once
+	testKey *rsa.PrivateKey
+)
+
+func signingKey(t *testing.T) *rsa.PrivateKey {
+	keyOnce.Do(func() {
+		var err error
+		if testKey, err = rsa.GenerateKey(rand.Reader, 2048); err != nil {
+			t.Fatal(err)
+		}
+	})
+	return testKey
+}
+
+// fakeProvider is an OpenID Connect provider issuing ID tokens for the codes
+// it is given
+type fakeProvider struct {
+	*httptest.Server
+	key *rsa.PrivateKey
+	mu  sync.Mutex
+	// codes are the claims of the ID token each code is exchanged for
+	codes      map[string]map[string]interface{}
+	keyFetches int
+}
+
+func newFakeProvider(t *testing.T) *fakeProvider {
+	f := &fakeProvider{key: signingKey(t), codes: map[string]map[string]interface{}{}}
+	f.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		switch r.URL.Path {
+		case "/.well-known/openid-configuration":
+			json.NewEncoder(w).Encode(map[string]string{
+				"issuer":                 f.URL,
+				"authorization_endpoint": f.URL + "/authorize",
+				"token_endpoint":         f.URL + "/token",
+				"jwks_uri":               f.URL + "/keys",
+			})
+		case "/keys":
+			f.keyFetches++
+			json.NewEncoder(w).Encode(map[string]interface{}{"keys": []map[string]string{{
+				"kid": "k1",
+				"kty": "RSA",
+				"use": "sig",
+				"n":   base64.RawURLEncoding.EncodeToString(f.key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(f.key.E)).Bytes()),
+			}}})
+		case "/token":
+			if id, secret, _ := r.BasicAuth(); id != "helpdesk" || secret != "shh" {
+				http.Error(w, "invalid_client", http.StatusUnauthorized)
+				return
+			}
+			claims := f.codes[r.FormValue("code")]
+			if claims == nil || r.FormValue("grant_type") != "authorization_code" {
+				http.Error(w, "invalid_grant", http.StatusBadRequest)
+				return
+			}
+			delete(f.codes, r.FormValue("code"))
+			json.NewEncoder(w).Encode(map[string]string{"id_token": f.sign("k1", claims)})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	return f
+}
+
+// claims returns the claims of a valid ID token for sub
+func (f *fakeProvider) claims(sub string, groups ...string) map[string]interface{} {
+	return map[string]interface{}{
+		"iss":    f.URL,
+		"aud":    "helpdesk",
+		"sub":    sub,
+		"email":  sub + "@example.com",
+		"exp":    time.Now().Add(time.Hour).Unix(),
+		"groups": groups,
+	}
+}
+
+func (f *fakeProvider) sign(kid string, claims map[string]interface{}) string {
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "kid": kid})
+	payload, _ := json.Marshal(claims)
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	sum := sha256.Sum256([]byte(signed))
+	sig, _ := rsa.SignPKCS1v15(rand.Reader, f.key, crypto.SHA256, sum[:])
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func TestDiscover(t *testing.T) {
+	f := newFakeProvider(t)
+	defer f.Close()
+	p, err := Discover(context.Background(), f.URL, "helpdesk", "shh", nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if p.AuthURL != f.URL+"/authorize" || p.TokenURL != f.URL+"/token" || p.KeysURL != f.URL+"/keys" {
+		t.Errorf("Expected the provider's endpoints, got %+v", p)
+	}
+	if _, err := Discover(context.Background(), f.URL+"/", "helpdesk", "shh", nil); err == nil || !strings.Contains(err.Error(), "reports its issuer") {
+		t.Errorf("Expected an error for the wrong issuer, got %v", err)
+	}
+	if _, err := Discover(context.Background(), f.URL+"/missing", "helpdesk", "shh", nil); err == nil {
+		t.Error("Expected an error without a discovery document")
+	}
+}
+
+func TestVerify(t *testing.T) {
+	f := newFakeProvider(t)
+	defer f.Close()
+	p, _ := Discover(context.Background(), f.URL, "helpdesk", "shh", nil)
+	claims, err := p.Verify(context.Background(), f.sign("k1", f.claims("ann", "helpdesk-admins")))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if claims.String("email") != "ann@example.com" || len(claims.Strings("groups")) != 1 || claims.Strings("groups")[0] != "helpdesk-admins" {
+		t.Errorf("Expected the token's claims, got %+v", claims)
+	}
+
+	valid := f.sign("k1", f.claims("ann"))
+	parts := strings.Split(valid, ".")
+	for name, tc := range map[string]struct {
+		token string
+		err   string
+	}{
+		"Malformed": {"not.a-token", "malformed"},
+		"Altered":   {parts[0] + "." + base64.RawURLEncoding.EncodeToString([]byte(`{"iss":"`+f.URL+`","aud":"helpdesk","sub":"root","exp":9999999999}`)) + "." + parts[2], "signature"},
+		"None":      {base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none"}`)) + "." + parts[1] + ".", "expected RS256"},
+		"Key":       {f.sign("k2", f.claims("ann")), "unknown signing key"},
+		"Issuer": {f.sign("k1", func() map[string]interface{} {
+			c := f.claims("ann")
+			c["iss"] = "https://evil.example.com"
+			return c
+		}()), "issued by"},
+		"Audience": {f.sign("k1", func() map[string]interface{} {
+			c := f.claims("ann")
+			c["aud"] = []string{"other", "another"}
+			return c
+		}()), "not for this client"},
+		"Expired": {f.sign("k1", func() map[string]interface{} {
+			c := f.claims("ann")
+			c["exp"] = time.Now().Add(-2 * time.Minute).Unix()
+			return c
+		}()), "expired"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if _, err := p.Verify(context.Background(), tc.token); err == nil || !strings.Contains(err.Error(), tc.err) {
+				t.Errorf("Expected an error containing %q, got %v", tc.err, err)
+			}
+		})
+	}
+
+	c := f.claims("ann")
+	c["aud"] = []string{"other", "helpdesk"}
+	if _, err := p.Verify(context.Background(), f.sign("k1", c)); err != nil {
+		t.Errorf("Expected a token for several audiences including this client to verify, got %s", err)
+	}
+}
+
+func TestKeysRefetched(t *testing.T) {
+	f := newFakeProvider(t)
+	defer f.Close()
+	fake := clock.NewFake(time.Now())
+	p, _ := Discover(context.Background(), f.URL, "helpdesk", "shh", nil)
+	p.Clock = fake
+	p.Verify(context.Background(), f.sign("k1", f.claims("ann")))
+	p.Verify(context.Background(), f.sign("k1", f.claims("ann")))
+	p.Verify(context.Background(), f.sign("k2", f.claims("ann")))
+	if f.keyFetches != 1 {
+		t.Errorf("Expected the keys to be fetched once within a minute, got %d", f.keyFetches)
+	}
+	fake.Advance(2 * time.Minute)
+	p.Verify(context.Background(), f.sign("k2", f.claims("ann")))
+	if f.keyFetches != 2 {
+		t.Errorf("Expected the keys to be fetched again for an unknown key after a minute, got %d", f.keyFetches)
+	}
+}
@@ -0,0 +1,542 @@
+package store
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/skybet/go-helpdesk/ticket"
+)
+
+// EventType is the kind of change an Event records
+type EventType string
+
+// The changes recorded by an EventLog
+const (
+	EventCreated      EventType = "created"
+	EventUpdated      EventType = "updated"
+	EventTransitioned EventType = "transitioned"
+	// EventDeleted is a ticket permanently removed, its earlier events are
+	// kept in the log
+	EventDeleted EventType = "deleted"
+	// EventImported is a ticket copied from another store by Migrate
+	EventImported EventType = "imported"
+	EventEnqueued EventType = "enqueued"
+	EventSent     EventType = "sent"
+	EventFailed   EventType = "failed"
+	// EventQueueChannel is the channel of a queue being recorded
+	EventQueueChannel EventType = "queue_channel"
+)
+
+// SchemaVersion is the version of the events EventLog writes. It is raised
+// when events change in a way older versions cannot replay, logs with events
+// from a later version are refused rather than misread.
+const SchemaVersion = 1
+
+// Event is an entry in an EventLog
+type Event struct {
+	// Seq orders the events in the log, starting at 1
+	Seq int
+	// Schema is the SchemaVersion the event was written with
+	Schema int `json:",omitempty"`
+	Type   EventType
+	At     time.Time
+	// TicketID is the ticket which changed, for notifications the ticket the
+	// notification is about
+	TicketID string
+	// Ticket is the whole ticket after it was created, updated or imported
+	Ticket *ticket.Ticket `json:",omitempty"`
+	// From and To are the statuses of a transition
+	From ticket.Status `json:",omitempty"`
+	To   ticket.Status `json:",omitempty"`
+	// Notification is the notification enqueued
+	Notification *Notification `json:",omitempty"`
+	// NotificationID and Reason identify the notification sent or failed,
+	// and why it failed
+	NotificationID string `json:",omitempty"`
+	Reason         string `json:",omitempty"`
+	// QueueChannel is the queue channel recorded
+	QueueChannel *QueueChannel `json:",omitempty"`
+}
+
+// EventLog is a Store which keeps every change as an event in an append-only
+// log, the tickets are the result of replaying it. The log can be kept in a
+// file, so that the tickets survive a restart, and read back to see how a
+// ticket came to be in its current state.
+type EventLog struct {
+	mu     sync.Mutex
+	data   *memData
+	events []Event
+	schema int
+	// w is where committed events are appended, nil to keep them in memory
+	w    io.Writer
+	file *os.File
+	// closed is set by Close, changes are refused afterwards rather than
+	// kept only in memory
+	closed bool
+}
+
+// NewEventLog returns an EventLog which is kept in memory
+func NewEventLog() *EventLog {
+	return &EventLog{data: &memData{tickets: map[string]*ticket.Ticket{}}}
+}
+
+// OpenEventLog replays the log in the file at path, creating it if it does
+// not exist, and appends every change made afterwards to it
+func OpenEventLog(path string) (*EventLog, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("error opening event log: %s", err)
+	}
+	l := NewEventLog()
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		var e Event
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			f.Close()
+			return nil, fmt.Errorf("error reading event log line %d: %s", line, err)
+		}
+		if e.Schema > SchemaVersion {
+			f.Close()
+			return nil, fmt.Errorf("event %d was written with schema version %d, this version only understands up to %d", e.Seq, e.Schema, SchemaVersion)
+		}
+		if e.Schema > l.schema {
+			l.schema = e.Schema
+		}
+		if err := l.data.apply(e); err != nil {
+			f.Close()
+			return nil, fmt.Errorf("error replaying event %d: %s", e.Seq, err)
+		}
+		l.events = append(l.events, e)
+	}
+	if err := scanner.Err(); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("error reading event log: %s", err)
+	}
+	l.w, l.file = f, f
+	return l, nil
+}
+
+// Close waits for the transaction being committed, if any, then closes the
+// log's file, if it has one. Changes made afterwards fail.
+func (l *EventLog) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closed {
+		return nil
+	}
+	l.closed = true
+	if l.file == nil {
+		return nil
+	}
+	return l.file.Close()
+}
+
+// CreateTicket satisfies Store
+func (l *EventLog) CreateTicket(ctx context.Context, t *ticket.Ticket) error {
+	return l.Tx(ctx, func(s Store) error { return s.CreateTicket(ctx, t) })
+}
+
+// GetTicket satisfies Store
+func (l *EventLog) GetTicket(ctx context.Context, id string) (*ticket.Ticket, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.data.get(id)
+}
+
+// DeleteTicket satisfies Store. The ticket's events stay in the log, so its
+// history can still be read.
+func (l *EventLog) DeleteTicket(ctx context.Context, id string) error {
+	return l.Tx(ctx, func(s Store) error { return s.DeleteTicket(ctx, id) })
+}
+
+// UpdateTicket satisfies Store
+func (l *EventLog) UpdateTicket(ctx context.Context, t *ticket.Ticket) error {
+	return l.Tx(ctx, func(s Store) error { return s.UpdateTicket(ctx, t) })
+}
+
+// ListTickets satisfies Store
+func (l *EventLog) ListTickets(ctx context.Context, f Filter) ([]*ticket.Ticket, string, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.data.list(f)
+}
+
+// Transition satisfies Store
+func (l *EventLog) Transition(ctx context.Context, id string, from, to ticket.Status) (t *ticket.Ticket, err error) {
+	err = l.Tx(ctx, func(s Store) error {
+		t, err = s.Transition(ctx, id, from, to)
+		return err
+	})
+	return t, err
+}
+
+// Enqueue satisfies Store
+func (l *EventLog) Enqueue(ctx context.Context, n *Notification) error {
+	return l.Tx(ctx, func(s Store) error { return s.Enqueue(ctx, n) })
+}
+
+// Outbox satisfies Store
+func (l *EventLog) Outbox(ctx context.Context, limit int) ([]*Notification, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.data.pending(limit), nil
+}
+
+// Sent satisfies Store
+func (l *EventLog) Sent(ctx context.Context, id string) error {
+	return l.Tx(ctx, func(s Store) error { return s.Sent(ctx, id) })
+}
+
+// Failed satisfies Store
+func (l *EventLog) Failed(ctx context.Context, id string, reason string) error {
+	return l.Tx(ctx, func(s Store) error { return s.Failed(ctx, id, reason) })
+}
+
+// SetQueueChannel satisfies Store
+func (l *EventLog) SetQueueChannel(ctx context.Context, qc *QueueChannel) error {
+	return l.Tx(ctx, func(s Store) error { return s.SetQueueChannel(ctx, qc) })
+}
+
+// QueueChannels satisfies Store
+func (l *EventLog) QueueChannels(ctx context.Context) ([]*QueueChannel, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.data.listQueues(), nil
+}
+
+// Tx satisfies Store. Like Memory, transactions are serialised and work on a
+// copy of the data. Their events are appended to the log only if fn succeeds.
+func (l *EventLog) Tx(ctx context.Context, fn func(s Store) error) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	tx := &eventTx{data: l.data.clone(), seq: len(l.events)}
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return l.commit(tx)
+}
+
+// commit appends a transaction's events to the log, the lock must be held
+func (l *EventLog) commit(tx *eventTx) error {
+	if len(tx.events) == 0 {
+		return nil
+	}
+	if l.closed {
+		return fmt.Errorf("error writing event log: it has been closed")
+	}
+	if l.w != nil {
+		var buf []byte
+		for _, e := range tx.events {
+			b, err := json.Marshal(e)
+			if err != nil {
+				return fmt.Errorf("error encoding event: %s", err)
+			}
+			buf = append(append(buf, b...), '\n')
+		}
+		if _, err := l.w.Write(buf); err != nil {
+			return fmt.Errorf("error writing event log: %s", err)
+		}
+		if l.file != nil {
+			if err := l.file.Sync(); err != nil {
+				return fmt.Errorf("error writing event log: %s", err)
+			}
+		}
+	}
+	l.data = tx.data
+	l.events = append(l.events, tx.events...)
+	l.schema = SchemaVersion
+	return nil
+}
+
+// Schema returns the latest schema version of the events in the log, zero if
+// it is empty or was written before versions were recorded
+func (l *EventLog) Schema() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.schema
+}
+
+// Events returns the events after seq, pass zero for the whole log. Read
+// models can be rebuilt from them, then kept up to date by asking for the
+// events after the last one they saw.
+func (l *EventLog) Events(seq int) []Event {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if seq >= len(l.events) {
+		return nil
+	}
+	if seq < 0 {
+		seq = 0
+	}
+	return copyEvents(l.events[seq:])
+}
+
+// History returns every event which changed a ticket, oldest first
+func (l *EventLog) History(id string) []Event {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var history []Event
+	for _, e := range l.events {
+		if e.TicketID == id && e.Notification == nil && e.NotificationID == "" {
+			history = append(history, e)
+		}
+	}
+	return copyEvents(history)
+}
+
+// TicketAt returns the ticket as it was at a point in time, by replaying its
+// events up to then. It returns ErrNotFound if the ticket did not exist yet.
+func (l *EventLog) TicketAt(id string, at time.Time) (*ticket.Ticket, error) {
+	d := &memData{tickets: map[string]*ticket.Ticket{}}
+	for _, e := range l.History(id) {
+		if e.At.After(at) {
+			break
+		}
+		if err := d.apply(e); err != nil {
+			return nil, err
+		}
+	}
+	return d.get(id)
+}
+
+// Migrate copies every ticket, waiting notification and queue channel in from
+// into to, which should be empty, as they are. It lets a deployment move from
+// a store which keeps only the current state to an event log, and returns the
+// number of tickets copied.
+func Migrate(ctx context.Context, from Store, to *EventLog) (int, error) {
+	var tickets []*ticket.Ticket
+	f := Filter{Limit: 500}
+	for {
+		page, next, err := from.ListTickets(ctx, f)
+		if err != nil {
+			return 0, fmt.Errorf("error listing tickets: %s", err)
+		}
+		tickets = append(tickets, page...)
+		if next == "" {
+			break
+		}
+		f.Cursor = next
+	}
+	notifications, err := from.Outbox(ctx, 0)
+	if err != nil {
+		return 0, fmt.Errorf("error reading the outbox: %s", err)
+	}
+	queues, err := from.QueueChannels(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("error listing queue channels: %s", err)
+	}
+	now := time.Now()
+	to.mu.Lock()
+	defer to.mu.Unlock()
+	tx := &eventTx{data: to.data.clone(), seq: len(to.events)}
+	for _, t := range tickets {
+		if _, ok := tx.data.tickets[t.ID]; ok {
+			return 0, fmt.Errorf("error importing ticket %s: %s", t.ID, ErrExists)
+		}
+		if err := tx.record(Event{Type: EventImported, At: now, TicketID: t.ID, Ticket: t}); err != nil {
+			return 0, err
+		}
+	}
+	for _, n := range notifications {
+		if err := tx.record(Event{Type: EventEnqueued, At: now, TicketID: n.TicketID, Notification: n}); err != nil {
+			return 0, err
+		}
+	}
+	for _, qc := range queues {
+		if err := tx.record(Event{Type: EventQueueChannel, At: now, QueueChannel: qc}); err != nil {
+			return 0, err
+		}
+	}
+	if err := to.commit(tx); err != nil {
+		return 0, err
+	}
+	return len(tickets), nil
+}
+
+// eventTx is the Store handed to an EventLog transaction, the lock is already
+// held. Each write is made to the copy of the data and recorded as an event.
+type eventTx struct {
+	data   *memData
+	seq    int
+	events []Event
+}
+
+// record applies an event which did not come from a write, such as an import
+func (tx *eventTx) record(e Event) error {
+	if err := tx.data.apply(e); err != nil {
+		return err
+	}
+	tx.append(e)
+	return nil
+}
+
+func (tx *eventTx) append(e Event) {
+	tx.seq++
+	e.Seq, e.Schema = tx.seq, SchemaVersion
+	tx.events = append(tx.events, e)
+}
+
+func (tx *eventTx) CreateTicket(ctx context.Context, t *ticket.Ticket) error {
+	if err := tx.data.create(t); err != nil {
+		return err
+	}
+	tx.append(Event{Type: EventCreated, At: t.UpdatedAt, TicketID: t.ID, Ticket: t.Copy()})
+	return nil
+}
+
+func (tx *eventTx) GetTicket(ctx context.Context, id string) (*ticket.Ticket, error) {
+	return tx.data.get(id)
+}
+
+func (tx *eventTx) DeleteTicket(ctx context.Context, id string) error {
+	if err := tx.data.delete(id); err != nil {
+		return err
+	}
+	tx.append(Event{Type: EventDeleted, At: time.Now(), TicketID: id})
+	return nil
+}
+
+func (tx *eventTx) UpdateTicket(ctx context.Context, t *ticket.Ticket) error {
+	if err := tx.data.update(t); err != nil {
+		return err
+	}
+	tx.append(Event{Type: EventUpdated, At: t.UpdatedAt, TicketID: t.ID, Ticket: t.Copy()})
+	return nil
+}
+
+func (tx *eventTx) ListTickets(ctx context.Context, f Filter) ([]*ticket.Ticket, string, error) {
+	return tx.data.list(f)
+}
+
+func (tx *eventTx) Transition(ctx context.Context, id string, from, to ticket.Status) (*ticket.Ticket, error) {
+	t, err := tx.data.transition(id, from, to)
+	if err != nil {
+		return nil, err
+	}
+	tx.append(Event{Type: EventTransitioned, At: t.UpdatedAt, TicketID: id, From: from, To: to})
+	return t, nil
+}
+
+func (tx *eventTx) Enqueue(ctx context.Context, n *Notification) error {
+	if err := tx.data.enqueue(n); err != nil {
+		return err
+	}
+	c := *n
+	tx.append(Event{Type: EventEnqueued, At: n.CreatedAt, TicketID: n.TicketID, Notification: &c})
+	return nil
+}
+
+func (tx *eventTx) Outbox(ctx context.Context, limit int) ([]*Notification, error) {
+	return tx.data.pending(limit), nil
+}
+
+func (tx *eventTx) Sent(ctx context.Context, id string) error {
+	n := tx.data.notification(id)
+	if err := tx.data.sent(id); err != nil {
+		return err
+	}
+	tx.append(Event{Type: EventSent, At: time.Now(), TicketID: n.TicketID, NotificationID: id})
+	return nil
+}
+
+func (tx *eventTx) Failed(ctx context.Context, id string, reason string) error {
+	n := tx.data.notification(id)
+	if err := tx.data.failed(id, reason); err != nil {
+		return err
+	}
+	tx.append(Event{Type: EventFailed, At: time.Now(), TicketID: n.TicketID, NotificationID: id, Reason: reason})
+	return nil
+}
+
+func (tx *eventTx) SetQueueChannel(ctx context.Context, qc *QueueChannel) error {
+	c := *qc
+	tx.data.setQueue(&c)
+	tx.append(Event{Type: EventQueueChannel, At: time.Now(), QueueChannel: &c})
+	return nil
+}
+
+func (tx *eventTx) QueueChannels(ctx context.Context) ([]*QueueChannel, error) {
+	return tx.data.listQueues(), nil
+}
+
+// Tx on a transaction joins the outer transaction
+func (tx *eventTx) Tx(ctx context.Context, fn func(s Store) error) error {
+	return fn(tx)
+}
+
+// apply replays an event, the data must be in the state it was recorded in
+func (d *memData) apply(e Event) error {
+	switch e.Type {
+	case EventCreated, EventImported, EventUpdated:
+		if e.Ticket == nil {
+			return fmt.Errorf("%s event %d has no ticket", e.Type, e.Seq)
+		}
+		d.tickets[e.TicketID] = e.Ticket.Copy()
+		if n, err := strconv.Atoi(e.TicketID); err == nil && n > d.seq {
+			d.seq = n
+		}
+	case EventDeleted:
+		return d.delete(e.TicketID)
+	case EventTransitioned:
+		t, ok := d.tickets[e.TicketID]
+		if !ok {
+			return fmt.Errorf("ticket %s is not in the log", e.TicketID)
+		}
+		t.SetStatus(e.To, e.At)
+		t.Version++
+	case EventEnqueued:
+		if e.Notification == nil {
+			return fmt.Errorf("%s event %d has no notification", e.Type, e.Seq)
+		}
+		d.outbox = append(d.outbox, *e.Notification)
+		if n, err := strconv.Atoi(e.Notification.ID); err == nil && n > d.outboxSeq {
+			d.outboxSeq = n
+		}
+	case EventSent:
+		return d.sent(e.NotificationID)
+	case EventFailed:
+		return d.failed(e.NotificationID, e.Reason)
+	case EventQueueChannel:
+		if e.QueueChannel == nil {
+			return fmt.Errorf("%s event %d has no queue channel", e.Type, e.Seq)
+		}
+		d.setQueue(e.QueueChannel)
+	default:
+		return fmt.Errorf("unknown event type %q", e.Type)
+	}
+	return nil
+}
+
+// notification returns the notification with the given ID in the outbox, or
+// an empty one
+func (d *memData) notification(id string) Notification {
+	for _, n := range d.outbox {
+		if n.ID == id {
+			return n
+		}
+	}
+	return Notification{}
+}
+
+func copyEvents(events []Event) []Event {
+	c := make([]Event, len(events))
+	for i, e := range events {
+		if e.Ticket != nil {
+			e.Ticket = e.Ticket.Copy()
+		}
+		if e.Notification != nil {
+			n := *e.Notification
+			e.Notification = &n
+		}
+		c[i] = e
+	}
+	return c
+}
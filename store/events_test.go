@@ -0,0 +1,216 @@
+package store_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/skybet/go-helpdesk/store"
+	"github.com/skybet/go-helpdesk/store/storetest"
+	"github.com/skybet/go-helpdesk/ticket"
+)
+
+func TestEventLogConformance(t *testing.T) {
+	storetest.RunConformance(t, func(t *testing.T) store.Store {
+		return store.NewEventLog()
+	})
+}
+
+func TestEventLogFileConformance(t *testing.T) {
+	dir := t.TempDir()
+	n := 0
+	storetest.RunConformance(t, func(t *testing.T) store.Store {
+		n++
+		l, err := store.OpenEventLog(filepath.Join(dir, string(rune('a'+n))+".log"))
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		t.Cleanup(func() { l.Close() })
+		return l
+	})
+}
+
+func TestEventLogReplay(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "events.log")
+	l, err := store.OpenEventLog(path)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	tk := &ticket.Ticket{Title: "VPN down", Reporter: "U1"}
+	l.CreateTicket(ctx, tk)
+	tk.Assignee = "U2"
+	l.UpdateTicket(ctx, tk)
+	l.Transition(ctx, tk.ID, ticket.StatusNew, ticket.StatusResolved)
+	l.Enqueue(ctx, &store.Notification{TicketID: tk.ID, ChannelID: "C1", Text: "resolved"})
+	l.Enqueue(ctx, &store.Notification{TicketID: tk.ID, ChannelID: "C1", Text: "archived"})
+	pending, _ := l.Outbox(ctx, 0)
+	l.Sent(ctx, pending[0].ID)
+	want, _ := l.GetTicket(ctx, tk.ID)
+	l.Close()
+
+	l, err = store.OpenEventLog(path)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	defer l.Close()
+	got, err := l.GetTicket(ctx, tk.ID)
+	if err != nil {
+		t.Fatalf("Expected the ticket to be replayed: %s", err)
+	}
+	if got.Assignee != "U2" || got.Status != ticket.StatusResolved || got.Version != want.Version || !got.ResolvedAt.Equal(want.ResolvedAt) || !got.UpdatedAt.Equal(want.UpdatedAt) {
+		t.Errorf("Expected the replayed ticket to match %+v, got %+v", want, got)
+	}
+	if pending, _ := l.Outbox(ctx, 0); len(pending) != 1 || pending[0].Text != "archived" {
+		t.Errorf("Expected the outbox to be replayed, got %+v", pending)
+	}
+	// New IDs carry on from the replayed ones
+	next := &ticket.Ticket{Title: "Printer on fire"}
+	l.CreateTicket(ctx, next)
+	if next.ID == tk.ID {
+		t.Errorf("Expected a new ID, got %s again", next.ID)
+	}
+	if events := l.Events(0); len(events) != 7 || events[6].Seq != 7 || events[6].Type != store.EventCreated {
+		t.Errorf("Expected the new event to be appended to the replayed log, got %+v", events)
+	}
+}
+
+func TestEventLogClose(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "events.log")
+	l, err := store.OpenEventLog(path)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	// A transaction in flight is committed before the file is closed
+	inTx, closed := make(chan struct{}), make(chan error)
+	go func() {
+		l.Tx(ctx, func(s store.Store) error {
+			close(inTx)
+			time.Sleep(20 * time.Millisecond)
+			return s.CreateTicket(ctx, &ticket.Ticket{Title: "VPN down"})
+		})
+	}()
+	<-inTx
+	go func() { closed <- l.Close() }()
+	if err := <-closed; err != nil {
+		t.Fatalf("Unexpected error closing: %s", err)
+	}
+	if err := l.CreateTicket(ctx, &ticket.Ticket{Title: "Too late"}); err == nil {
+		t.Errorf("Expected changes after closing to fail")
+	}
+
+	l, err = store.OpenEventLog(path)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	defer l.Close()
+	if events := l.Events(0); len(events) != 1 {
+		t.Errorf("Expected the ticket created while closing to be written, got %+v", events)
+	}
+}
+
+func TestEventLogHistory(t *testing.T) {
+	ctx := context.Background()
+	l := store.NewEventLog()
+	tk := &ticket.Ticket{Title: "VPN down"}
+	l.CreateTicket(ctx, tk)
+	created := tk.UpdatedAt
+	time.Sleep(time.Millisecond)
+	tk.Assignee = "U2"
+	l.UpdateTicket(ctx, tk)
+	l.Enqueue(ctx, &store.Notification{TicketID: tk.ID, ChannelID: "C1", Text: "assigned"})
+	err := l.Tx(ctx, func(tx store.Store) error {
+		tx.Transition(ctx, tk.ID, ticket.StatusNew, ticket.StatusTriaged)
+		return store.ErrConflict
+	})
+	if err != store.ErrConflict {
+		t.Fatalf("Expected the transaction to fail, got %v", err)
+	}
+
+	history := l.History(tk.ID)
+	if len(history) != 2 || history[0].Type != store.EventCreated || history[1].Type != store.EventUpdated || history[1].Ticket.Assignee != "U2" {
+		t.Fatalf("Expected the creation and update without the rolled back transition, got %+v", history)
+	}
+	then, err := l.TicketAt(tk.ID, created)
+	if err != nil || then.Assignee != "" || then.Version != 1 {
+		t.Errorf("Expected the ticket as it was created, got %+v %v", then, err)
+	}
+	if _, err := l.TicketAt(tk.ID, created.Add(-time.Hour)); err != store.ErrNotFound {
+		t.Errorf("Expected ErrNotFound before the ticket existed, got %v", err)
+	}
+	if events := l.Events(2); len(events) != 1 || events[0].Type != store.EventEnqueued {
+		t.Errorf("Expected the events after the second, got %+v", events)
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	ctx := context.Background()
+	from := store.NewMemory()
+	for i := 0; i < 3; i++ {
+		tk := &ticket.Ticket{Title: "VPN down"}
+		from.CreateTicket(ctx, tk)
+		tk.Assignee = "U1"
+		from.UpdateTicket(ctx, tk)
+	}
+	from.Enqueue(ctx, &store.Notification{TicketID: "1", ChannelID: "C1", Text: "assigned"})
+	to := store.NewEventLog()
+
+	n, err := store.Migrate(ctx, from, to)
+	if err != nil || n != 3 {
+		t.Fatalf("Expected 3 tickets to be migrated, got %d %v", n, err)
+	}
+	want, _ := from.GetTicket(ctx, "2")
+	got, err := to.GetTicket(ctx, "2")
+	if err != nil || got.Assignee != "U1" || got.Version != want.Version || !got.CreatedAt.Equal(want.CreatedAt) {
+		t.Errorf("Expected the ticket to be copied as it was, got %+v %v", got, err)
+	}
+	if history := to.History("2"); len(history) != 1 || history[0].Type != store.EventImported {
+		t.Errorf("Expected an imported event, got %+v", history)
+	}
+	if pending, _ := to.Outbox(ctx, 0); len(pending) != 1 {
+		t.Errorf("Expected the outbox to be copied, got %+v", pending)
+	}
+	if _, err := store.Migrate(ctx, from, to); err == nil {
+		t.Errorf("Expected migrating the same tickets twice to fail")
+	}
+	if next := (&ticket.Ticket{}); to.CreateTicket(ctx, next) != nil || next.ID != "4" {
+		t.Errorf("Expected new tickets to carry on from the migrated IDs, got %q", next.ID)
+	}
+}
+
+func TestEventLogSchema(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "events.log")
+	// Events written before versions were recorded have no schema
+	os.WriteFile(path, []byte(`{"Seq":1,"Type":"created","TicketID":"1","Ticket":{"ID":"1","Version":1}}`+"\n"), 0600)
+	l, err := store.OpenEventLog(path)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if l.Schema() != 0 {
+		t.Errorf("Expected an unversioned log, got schema %d", l.Schema())
+	}
+	l.CreateTicket(ctx, &ticket.Ticket{Title: "VPN down"})
+	if l.Schema() != store.SchemaVersion {
+		t.Errorf("Expected new events to be written with schema %d, got %d", store.SchemaVersion, l.Schema())
+	}
+	l.Close()
+
+	f, _ := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0600)
+	fmt.Fprintf(f, `{"Seq":3,"Schema":%d,"Type":"created","TicketID":"3","Ticket":{"ID":"3"}}`+"\n", store.SchemaVersion+1)
+	f.Close()
+	if _, err := store.OpenEventLog(path); err == nil || !strings.Contains(err.Error(), "schema version") {
+		t.Errorf("Expected a log from a later version to be refused, got %v", err)
+	}
+}
+
+func BenchmarkEventLog(b *testing.B) {
+	storetest.RunBenchmarks(b, func(b *testing.B) store.Store {
+		return store.NewEventLog()
+	})
+}
@@ -0,0 +1,397 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/skybet/go-helpdesk/ticket"
+)
+
+// ErrExists is returned when creating a ticket with an ID which is already in use
+var ErrExists = errors.New("ticket already exists")
+
+// Memory is a Store which keeps tickets in memory. It is intended for tests
+// and single instance deployments which can afford to lose state on restart.
+type Memory struct {
+	mu   sync.Mutex
+	data *memData
+}
+
+// NewMemory returns an empty in-memory Store
+func NewMemory() *Memory {
+	return &Memory{data: &memData{tickets: map[string]*ticket.Ticket{}}}
+}
+
+// CreateTicket satisfies Store
+func (m *Memory) CreateTicket(ctx context.Context, t *ticket.Ticket) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.data.create(t)
+}
+
+// GetTicket satisfies Store
+func (m *Memory) GetTicket(ctx context.Context, id string) (*ticket.Ticket, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.data.get(id)
+}
+
+// DeleteTicket satisfies Store
+func (m *Memory) DeleteTicket(ctx context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.data.delete(id)
+}
+
+// UpdateTicket satisfies Store
+func (m *Memory) UpdateTicket(ctx context.Context, t *ticket.Ticket) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.data.update(t)
+}
+
+// ListTickets satisfies Store
+func (m *Memory) ListTickets(ctx context.Context, f Filter) ([]*ticket.Ticket, string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.data.list(f)
+}
+
+// Transition satisfies Store
+func (m *Memory) Transition(ctx context.Context, id string, from, to ticket.Status) (*ticket.Ticket, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.data.transition(id, from, to)
+}
+
+// Enqueue satisfies Store
+func (m *Memory) Enqueue(ctx context.Context, n *Notification) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.data.enqueue(n)
+}
+
+// Outbox satisfies Store
+func (m *Memory) Outbox(ctx context.Context, limit int) ([]*Notification, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.data.pending(limit), nil
+}
+
+// Sent satisfies Store
+func (m *Memory) Sent(ctx context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.data.sent(id)
+}
+
+// Failed satisfies Store
+func (m *Memory) Failed(ctx context.Context, id string, reason string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.data.failed(id, reason)
+}
+
+// SetQueueChannel satisfies Store
+func (m *Memory) SetQueueChannel(ctx context.Context, qc *QueueChannel) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.data.setQueue(qc)
+	return nil
+}
+
+// QueueChannels satisfies Store
+func (m *Memory) QueueChannels(ctx context.Context) ([]*QueueChannel, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.data.listQueues(), nil
+}
+
+// Tx satisfies Store. Transactions are serialised and work on a copy of the
+// data which replaces the original only if fn succeeds.
+func (m *Memory) Tx(ctx context.Context, fn func(s Store) error) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	tx := &memTx{data: m.data.clone()}
+	if err := fn(tx); err != nil {
+		return err
+	}
+	m.data = tx.data
+	return nil
+}
+
+// memTx is the Store handed to a Memory transaction, the lock is already held
+type memTx struct {
+	data *memData
+}
+
+func (tx *memTx) CreateTicket(ctx context.Context, t *ticket.Ticket) error {
+	return tx.data.create(t)
+}
+
+func (tx *memTx) GetTicket(ctx context.Context, id string) (*ticket.Ticket, error) {
+	return tx.data.get(id)
+}
+
+func (tx *memTx) DeleteTicket(ctx context.Context, id string) error {
+	return tx.data.delete(id)
+}
+
+func (tx *memTx) UpdateTicket(ctx context.Context, t *ticket.Ticket) error {
+	return tx.data.update(t)
+}
+
+func (tx *memTx) ListTickets(ctx context.Context, f Filter) ([]*ticket.Ticket, string, error) {
+	return tx.data.list(f)
+}
+
+func (tx *memTx) Transition(ctx context.Context, id string, from, to ticket.Status) (*ticket.Ticket, error) {
+	return tx.data.transition(id, from, to)
+}
+
+func (tx *memTx) Enqueue(ctx context.Context, n *Notification) error {
+	return tx.data.enqueue(n)
+}
+
+func (tx *memTx) Outbox(ctx context.Context, limit int) ([]*Notification, error) {
+	return tx.data.pending(limit), nil
+}
+
+func (tx *memTx) Sent(ctx context.Context, id string) error {
+	return tx.data.sent(id)
+}
+
+func (tx *memTx) Failed(ctx context.Context, id string, reason string) error {
+	return tx.data.failed(id, reason)
+}
+
+func (tx *memTx) SetQueueChannel(ctx context.Context, qc *QueueChannel) error {
+	tx.data.setQueue(qc)
+	return nil
+}
+
+func (tx *memTx) QueueChannels(ctx context.Context) ([]*QueueChannel, error) {
+	return tx.data.listQueues(), nil
+}
+
+// Tx on a transaction joins the outer transaction
+func (tx *memTx) Tx(ctx context.Context, fn func(s Store) error) error {
+	return fn(tx)
+}
+
+type memData struct {
+	seq     int
+	tickets map[string]*ticket.Ticket
+	// outbox is in the order notifications were enqueued
+	outbox    []Notification
+	outboxSeq int
+	queues    map[string]QueueChannel
+}
+
+func (d *memData) clone() *memData {
+	c := &memData{seq: d.seq, tickets: make(map[string]*ticket.Ticket, len(d.tickets)), outboxSeq: d.outboxSeq}
+	for id, t := range d.tickets {
+		c.tickets[id] = t.Copy()
+	}
+	c.outbox = append([]Notification(nil), d.outbox...)
+	if d.queues != nil {
+		c.queues = make(map[string]QueueChannel, len(d.queues))
+		for q, qc := range d.queues {
+			c.queues[q] = qc
+		}
+	}
+	return c
+}
+
+func (d *memData) create(t *ticket.Ticket) error {
+	if t.ID == "" {
+		for {
+			d.seq++
+			t.ID = strconv.Itoa(d.seq)
+			if _, ok := d.tickets[t.ID]; !ok {
+				break
+			}
+		}
+	} else if _, ok := d.tickets[t.ID]; ok {
+		return ErrExists
+	}
+	now := time.Now()
+	if t.CreatedAt.IsZero() {
+		t.CreatedAt = now
+	}
+	t.UpdatedAt = now
+	t.Version = 1
+	if t.Status == "" {
+		t.Status = ticket.StatusNew
+	}
+	d.tickets[t.ID] = t.Copy()
+	return nil
+}
+
+func (d *memData) get(id string) (*ticket.Ticket, error) {
+	t, ok := d.tickets[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return t.Copy(), nil
+}
+
+func (d *memData) delete(id string) error {
+	if _, ok := d.tickets[id]; !ok {
+		return ErrNotFound
+	}
+	delete(d.tickets, id)
+	return nil
+}
+
+func (d *memData) update(t *ticket.Ticket) error {
+	old, ok := d.tickets[t.ID]
+	if !ok {
+		return ErrNotFound
+	}
+	if t.Version != old.Version {
+		return ErrStale
+	}
+	t.CreatedAt = old.CreatedAt
+	t.UpdatedAt = time.Now()
+	t.Version++
+	d.tickets[t.ID] = t.Copy()
+	return nil
+}
+
+func (d *memData) transition(id string, from, to ticket.Status) (*ticket.Ticket, error) {
+	t, ok := d.tickets[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if t.Status != from {
+		return nil, ErrConflict
+	}
+	t.SetStatus(to, time.Now())
+	t.Version++
+	return t.Copy(), nil
+}
+
+func (d *memData) enqueue(n *Notification) error {
+	d.outboxSeq++
+	n.ID = strconv.Itoa(d.outboxSeq)
+	n.CreatedAt = time.Now()
+	d.outbox = append(d.outbox, *n)
+	return nil
+}
+
+func (d *memData) pending(limit int) []*Notification {
+	res := []*Notification{}
+	for i := range d.outbox {
+		if limit > 0 && len(res) == limit {
+			break
+		}
+		n := d.outbox[i]
+		res = append(res, &n)
+	}
+	return res
+}
+
+func (d *memData) sent(id string) error {
+	for i, n := range d.outbox {
+		if n.ID == id {
+			d.outbox = append(d.outbox[:i:i], d.outbox[i+1:]...)
+			return nil
+		}
+	}
+	return ErrNoNotification
+}
+
+func (d *memData) failed(id string, reason string) error {
+	for i := range d.outbox {
+		if d.outbox[i].ID == id {
+			d.outbox[i].Attempts++
+			d.outbox[i].LastError = reason
+			return nil
+		}
+	}
+	return ErrNoNotification
+}
+
+func (d *memData) setQueue(qc *QueueChannel) {
+	if d.queues == nil {
+		d.queues = map[string]QueueChannel{}
+	}
+	d.queues[qc.Queue] = *qc
+}
+
+func (d *memData) listQueues() []*QueueChannel {
+	res := make([]*QueueChannel, 0, len(d.queues))
+	for _, qc := range d.queues {
+		qc := qc
+		res = append(res, &qc)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Queue < res[j].Queue })
+	return res
+}
+
+func (d *memData) list(f Filter) ([]*ticket.Ticket, string, error) {
+	var after *ticket.Ticket
+	if f.Cursor != "" {
+		c, err := DecodeCursor(f.Cursor)
+		if err != nil {
+			return nil, "", err
+		}
+		after = c
+	}
+	var matched []*ticket.Ticket
+	for _, t := range d.tickets {
+		if f.Match(t) && (after == nil || createdBefore(after, t)) {
+			matched = append(matched, t)
+		}
+	}
+	sort.Slice(matched, func(i, j int) bool { return createdBefore(matched[i], matched[j]) })
+
+	var next string
+	if f.Limit > 0 && len(matched) > f.Limit {
+		matched = matched[:f.Limit]
+		next = EncodeCursor(matched[len(matched)-1])
+	}
+	res := make([]*ticket.Ticket, len(matched))
+	for i, t := range matched {
+		res[i] = t.Copy()
+	}
+	return res, next, nil
+}
+
+// createdBefore orders tickets by creation time, then ID
+func createdBefore(a, b *ticket.Ticket) bool {
+	if !a.CreatedAt.Equal(b.CreatedAt) {
+		return a.CreatedAt.Before(b.CreatedAt)
+	}
+	if len(a.ID) != len(b.ID) {
+		return len(a.ID) < len(b.ID)
+	}
+	return a.ID < b.ID
+}
+
+// EncodeCursor returns the cursor of the page after t, for stores ordering
+// tickets as Memory does
+func EncodeCursor(t *ticket.Ticket) string {
+	return fmt.Sprintf("%d:%s", t.CreatedAt.UnixNano(), t.ID)
+}
+
+// DecodeCursor returns a ticket with the creation time and ID of the one c was
+// made from
+func DecodeCursor(c string) (*ticket.Ticket, error) {
+	parts := strings.SplitN(c, ":", 2)
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("invalid cursor: %q", c)
+	}
+	ns, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor: %q", c)
+	}
+	return &ticket.Ticket{ID: parts[1], CreatedAt: time.Unix(0, ns)}, nil
+}
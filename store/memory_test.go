@@ -0,0 +1,20 @@
+package store_test
+
+import (
+	"testing"
+
+	"github.com/skybet/go-helpdesk/store"
+	"github.com/skybet/go-helpdesk/store/storetest"
+)
+
+func TestMemoryConformance(t *testing.T) {
+	storetest.RunConformance(t, func(t *testing.T) store.Store {
+		return store.NewMemory()
+	})
+}
+
+func BenchmarkMemory(b *testing.B) {
+	storetest.RunBenchmarks(b, func(b *testing.B) store.Store {
+		return store.NewMemory()
+	})
+}
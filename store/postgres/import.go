@@ -0,0 +1,195 @@
+package postgres
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/skybet/go-helpdesk/store"
+	"github.com/skybet/go-helpdesk/ticket"
+)
+
+// Import satisfies store.Importer. The tickets, their tags and their
+// comments are each written with one COPY, which the driver streams to the
+// server rather than sending a statement a row. Tickets are copied into
+// ticket_imports and inserted from there in one statement, which finds those
+// whose IDs were taken since they were checked without failing the COPY.
+func (s *Store) Import(ctx context.Context, tickets []*ticket.Ticket) error {
+	return s.atomic(ctx, func(s *Store) error {
+		now := time.Now()
+		for _, t := range tickets {
+			newTicket(t, now)
+		}
+		if err := s.assignIDs(ctx, tickets); err != nil {
+			return err
+		}
+		err := s.copyIn(ctx, "{schema}.ticket_imports", insertColumns, func(row func(vals ...interface{}) error) error {
+			for _, t := range tickets {
+				vals, err := ticketRow(t)
+				if err != nil {
+					return err
+				}
+				if err := row(vals...); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		cols := strings.Join(insertColumns, ", ")
+		res, err := s.exec(ctx, "INSERT INTO {schema}.tickets ("+cols+") SELECT "+cols+" FROM {schema}.ticket_imports ON CONFLICT (id) DO NOTHING")
+		if err != nil {
+			return err
+		}
+		if n, err := res.RowsAffected(); err != nil {
+			return err
+		} else if n < int64(len(tickets)) {
+			return store.ErrExists
+		}
+		if _, err := s.exec(ctx, "DELETE FROM {schema}.ticket_imports"); err != nil {
+			return err
+		}
+		err = s.copyIn(ctx, "{schema}.ticket_tags", tagColumns, func(row func(vals ...interface{}) error) error {
+			for _, t := range tickets {
+				for _, vals := range tagRows(t) {
+					if err := row(vals...); err != nil {
+						return err
+					}
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		return s.copyIn(ctx, "{schema}.comments", commentColumns, func(row func(vals ...interface{}) error) error {
+			for _, t := range tickets {
+				for _, vals := range commentRows(t, 0) {
+					if err := row(vals...); err != nil {
+						return err
+					}
+				}
+			}
+			return nil
+		})
+	})
+}
+
+// assignIDs returns store.ErrExists if the ID of a ticket is taken, and gives
+// the tickets without an ID the next of the sequence, which as in create skip
+// the IDs of other tickets
+func (s *Store) assignIDs(ctx context.Context, tickets []*ticket.Ticket) error {
+	var need []*ticket.Ticket
+	var explicit []string
+	taken := map[string]bool{}
+	for _, t := range tickets {
+		switch {
+		case t.ID == "":
+			need = append(need, t)
+		case taken[t.ID]:
+			return store.ErrExists
+		default:
+			taken[t.ID] = true
+			explicit = append(explicit, t.ID)
+		}
+	}
+	if existing, err := s.existing(ctx, explicit); err != nil {
+		return err
+	} else if len(existing) > 0 {
+		return store.ErrExists
+	}
+	for len(need) > 0 {
+		ids, err := s.nextIDs(ctx, len(need))
+		if err != nil {
+			return err
+		}
+		existing, err := s.existing(ctx, ids)
+		if err != nil {
+			return err
+		}
+		n := 0
+		for _, id := range ids {
+			if !taken[id] && !existing[id] {
+				need[n].ID = id
+				n++
+			}
+		}
+		need = need[n:]
+	}
+	return nil
+}
+
+// nextIDs returns the next n IDs of the sequence
+func (s *Store) nextIDs(ctx context.Context, n int) ([]string, error) {
+	rows, err := s.query(ctx, "SELECT nextval('{schema}.ticket_ids') FROM generate_series(1, $1)", n)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var ids []string
+	for rows.Next() {
+		var seq int64
+		if err := rows.Scan(&seq); err != nil {
+			return nil, err
+		}
+		ids = append(ids, strconv.FormatInt(seq, 10))
+	}
+	return ids, rows.Err()
+}
+
+// existing returns which of ids are the IDs of stored tickets
+func (s *Store) existing(ctx context.Context, ids []string) (map[string]bool, error) {
+	res := map[string]bool{}
+	for len(ids) > 0 {
+		chunk := ids
+		if len(chunk) > inLimit {
+			chunk = chunk[:inLimit]
+		}
+		ids = ids[len(chunk):]
+		args := make([]interface{}, len(chunk))
+		for i, id := range chunk {
+			args[i] = id
+		}
+		rows, err := s.query(ctx, "SELECT id FROM {schema}.tickets WHERE id IN ("+params(1, len(args))+")", args...)
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			var id string
+			if err := rows.Scan(&id); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			res[id] = true
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+// copyIn writes the rows passed to row by rows into the cols of table with
+// COPY, in the Store's transaction. Drivers such as github.com/lib/pq run
+// COPY FROM STDIN as a statement executed once for each row, then once
+// without values to finish.
+func (s *Store) copyIn(ctx context.Context, table string, cols []string, rows func(row func(vals ...interface{}) error) error) error {
+	st, err := s.tx.PrepareContext(ctx, s.sql("COPY "+table+" ("+strings.Join(cols, ", ")+") FROM STDIN"))
+	if err != nil {
+		return err
+	}
+	defer st.Close()
+	err = rows(func(vals ...interface{}) error {
+		_, err := st.ExecContext(ctx, vals...)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	_, err = st.ExecContext(ctx)
+	return err
+}
@@ -0,0 +1,152 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// migrations are the statements bringing the schema from each version to the
+// next, migrations[0] creating version 1. Released migrations are never
+// changed, changes to the schema are added as new migrations.
+var migrations = [][]string{
+	{
+		`CREATE SEQUENCE {schema}.ticket_ids`,
+		`CREATE TABLE {schema}.tickets (
+			id TEXT PRIMARY KEY,
+			version INTEGER NOT NULL,
+			status TEXT NOT NULL,
+			queue TEXT NOT NULL,
+			assignee TEXT NOT NULL,
+			reporter TEXT NOT NULL,
+			channel_id TEXT NOT NULL,
+			thread_ts TEXT NOT NULL,
+			issue TEXT NOT NULL,
+			priority INTEGER NOT NULL,
+			title TEXT NOT NULL,
+			description TEXT NOT NULL,
+			created_at TIMESTAMPTZ NOT NULL,
+			updated_at TIMESTAMPTZ NOT NULL,
+			deleted_at TIMESTAMPTZ,
+			doc JSONB NOT NULL
+		)`,
+		// Pages are read in creation order
+		`CREATE INDEX tickets_created ON {schema}.tickets (created_at, length(id), id)`,
+		`CREATE INDEX tickets_status ON {schema}.tickets (status)`,
+		`CREATE INDEX tickets_queue ON {schema}.tickets (queue)`,
+		`CREATE INDEX tickets_assignee ON {schema}.tickets (assignee)`,
+		`CREATE INDEX tickets_reporter ON {schema}.tickets (reporter)`,
+		`CREATE INDEX tickets_thread ON {schema}.tickets (channel_id, thread_ts)`,
+		`CREATE INDEX tickets_issue ON {schema}.tickets (issue) WHERE issue <> ''`,
+		`CREATE TABLE {schema}.ticket_tags (
+			ticket_id TEXT NOT NULL REFERENCES {schema}.tickets (id) ON DELETE CASCADE,
+			tag TEXT NOT NULL,
+			PRIMARY KEY (ticket_id, tag)
+		)`,
+		`CREATE INDEX ticket_tags_tag ON {schema}.ticket_tags (tag, ticket_id)`,
+		`CREATE TABLE {schema}.comments (
+			ticket_id TEXT NOT NULL REFERENCES {schema}.tickets (id) ON DELETE CASCADE,
+			position INTEGER NOT NULL,
+			id TEXT NOT NULL,
+			author TEXT NOT NULL,
+			text TEXT NOT NULL,
+			internal BOOLEAN NOT NULL,
+			created_at TIMESTAMPTZ NOT NULL,
+			PRIMARY KEY (ticket_id, position)
+		)`,
+		`CREATE TABLE {schema}.outbox (
+			seq BIGSERIAL PRIMARY KEY,
+			ticket_id TEXT NOT NULL,
+			channel_id TEXT NOT NULL,
+			thread_ts TEXT NOT NULL,
+			text TEXT NOT NULL,
+			created_at TIMESTAMPTZ NOT NULL,
+			attempts INTEGER NOT NULL,
+			last_error TEXT NOT NULL
+		)`,
+		`CREATE TABLE {schema}.queue_channels (
+			queue TEXT PRIMARY KEY,
+			channel_id TEXT NOT NULL,
+			dashboard_ts TEXT NOT NULL,
+			provisioned_by TEXT NOT NULL,
+			provisioned_at TIMESTAMPTZ NOT NULL
+		)`,
+	},
+	{
+		// Queues are listed by status and how long tickets have waited, and
+		// people by their tickets' status, which the single column indexes
+		// on status and assignee only narrowed to one of
+		`CREATE INDEX tickets_status_queue ON {schema}.tickets (status, queue, updated_at)`,
+		`CREATE INDEX tickets_assignee_status ON {schema}.tickets (assignee, status)`,
+		// Index names are resolved through the search path, which not every
+		// server speaking the Postgres protocol does for qualified names
+		`SET LOCAL search_path TO {schema}`,
+		`DROP INDEX tickets_status`,
+		`DROP INDEX tickets_assignee`,
+	},
+	{
+		// Import copies tickets here before inserting them into tickets.
+		// Each transaction only sees the rows it copied, which it deletes
+		// before committing, so the table is always empty.
+		`CREATE TABLE {schema}.ticket_imports (
+			id TEXT NOT NULL,
+			version INTEGER NOT NULL,
+			created_at TIMESTAMPTZ NOT NULL,
+			status TEXT NOT NULL,
+			queue TEXT NOT NULL,
+			assignee TEXT NOT NULL,
+			reporter TEXT NOT NULL,
+			channel_id TEXT NOT NULL,
+			thread_ts TEXT NOT NULL,
+			issue TEXT NOT NULL,
+			priority INTEGER NOT NULL,
+			title TEXT NOT NULL,
+			description TEXT NOT NULL,
+			updated_at TIMESTAMPTZ NOT NULL,
+			deleted_at TIMESTAMPTZ,
+			doc JSONB NOT NULL
+		)`,
+	},
+}
+
+// migrateLock is the key of the advisory lock held while migrating, so that
+// instances starting together do not migrate the same schema twice
+const migrateLock = 0x6864736b
+
+// migrate brings the schema to the latest version, applying the migrations it
+// has not had in one transaction. Schemas from a later version are refused
+// rather than misread.
+func (s *Store) migrate(ctx context.Context) error {
+	for _, stmt := range []string{
+		"CREATE SCHEMA IF NOT EXISTS {schema}",
+		"CREATE TABLE IF NOT EXISTS {schema}.schema_migrations (version INTEGER PRIMARY KEY, applied_at TIMESTAMPTZ NOT NULL)",
+	} {
+		if _, err := s.exec(ctx, stmt); err != nil {
+			return fmt.Errorf("error creating schema %s: %s", s.schema, err)
+		}
+	}
+	return s.atomic(ctx, func(s *Store) error {
+		if _, err := s.exec(ctx, "SELECT pg_advisory_xact_lock($1)", migrateLock); err != nil {
+			return fmt.Errorf("error locking schema %s: %s", s.schema, err)
+		}
+		var version int
+		err := s.queryRow(ctx, "SELECT version FROM {schema}.schema_migrations ORDER BY version DESC LIMIT 1").Scan(&version)
+		if err != nil && err != sql.ErrNoRows {
+			return fmt.Errorf("error reading the version of schema %s: %s", s.schema, err)
+		}
+		if version > len(migrations) {
+			return fmt.Errorf("schema %s is at version %d, later than the %d this version of the helpdesk knows", s.schema, version, len(migrations))
+		}
+		for ; version < len(migrations); version++ {
+			for _, stmt := range migrations[version] {
+				if _, err := s.exec(ctx, stmt); err != nil {
+					return fmt.Errorf("error migrating schema %s to version %d: %s", s.schema, version+1, err)
+				}
+			}
+			if _, err := s.exec(ctx, "INSERT INTO {schema}.schema_migrations (version, applied_at) VALUES ($1, now())", version+1); err != nil {
+				return fmt.Errorf("error migrating schema %s to version %d: %s", s.schema, version+1, err)
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,717 @@
+// Package postgres is a store.Store keeping tickets in PostgreSQL. It only
+// uses database/sql, main chooses the driver with a blank import of
+// github.com/lib/pq.
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/skybet/go-helpdesk/store"
+	"github.com/skybet/go-helpdesk/ticket"
+)
+
+// validSchema is the form of the schema names Open accepts, so that they can
+// be used in queries without quoting
+var validSchema = regexp.MustCompile(`^[a-z_][a-z0-9_]*$`)
+
+// Store is a store.Store keeping tickets in the tables of one PostgreSQL
+// schema. Each ticket is a row with the fields it is filtered by as columns
+// and the whole ticket as a JSON document, so new ticket fields do not need a
+// migration. Its tags and comments are rows of their own which filters by tag
+// and text are answered from.
+type Store struct {
+	db     *sql.DB
+	schema string
+	// prepared are the statements prepared on db, shared with the Stores of
+	// its transactions
+	prepared *statements
+	// tx is the transaction of a Store handed to Tx's fn, nil otherwise, and
+	// txPrepared the prepared statements it has used
+	tx         *sql.Tx
+	txPrepared *statements
+}
+
+// Open returns a Store keeping tickets in schema of db, public if empty. The
+// schema is created if need be and migrated to the latest version.
+func Open(ctx context.Context, db *sql.DB, schema string) (*Store, error) {
+	if schema == "" {
+		schema = "public"
+	}
+	if !validSchema.MatchString(schema) {
+		return nil, fmt.Errorf("invalid schema name %q, only lower case letters, digits and underscores are allowed", schema)
+	}
+	s := &Store{db: db, schema: schema, prepared: &statements{}}
+	if err := s.migrate(ctx); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// conn is what the Store's queries run on
+type conn interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+func (s *Store) conn() conn {
+	if s.tx != nil {
+		return s.tx
+	}
+	return s.db
+}
+
+// sql returns query with {schema} replaced by the Store's schema
+func (s *Store) sql(query string) string {
+	return strings.Replace(query, "{schema}", s.schema, -1)
+}
+
+func (s *Store) exec(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return s.conn().ExecContext(ctx, s.sql(query), args...)
+}
+
+func (s *Store) query(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return s.conn().QueryContext(ctx, s.sql(query), args...)
+}
+
+func (s *Store) queryRow(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	return s.conn().QueryRowContext(ctx, s.sql(query), args...)
+}
+
+// statements are prepared statements by query
+type statements struct {
+	mu    sync.Mutex
+	stmts map[string]*sql.Stmt
+}
+
+// get returns the statement of query, calling prepare the first time
+func (ss *statements) get(query string, prepare func() (*sql.Stmt, error)) (*sql.Stmt, error) {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	if st, ok := ss.stmts[query]; ok {
+		return st, nil
+	}
+	st, err := prepare()
+	if err != nil {
+		return nil, err
+	}
+	if ss.stmts == nil {
+		ss.stmts = map[string]*sql.Stmt{}
+	}
+	ss.stmts[query] = st
+	return st, nil
+}
+
+// stmt returns query prepared, in the Store's transaction if it has one.
+// Queries are prepared once on db, on each of its connections as they are
+// used, rather than parsed and planned every time they run.
+func (s *Store) stmt(ctx context.Context, query string) (*sql.Stmt, error) {
+	query = s.sql(query)
+	st, err := s.prepared.get(query, func() (*sql.Stmt, error) {
+		return s.db.PrepareContext(ctx, query)
+	})
+	if err != nil || s.tx == nil {
+		return st, err
+	}
+	return s.txPrepared.get(query, func() (*sql.Stmt, error) {
+		return s.tx.StmtContext(ctx, st), nil
+	})
+}
+
+// execPrepared and queryRowPrepared are exec and queryRow for the queries
+// the Store runs most, which are prepared
+func (s *Store) execPrepared(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	st, err := s.stmt(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	return st.ExecContext(ctx, args...)
+}
+
+func (s *Store) queryRowPrepared(ctx context.Context, query string, args ...interface{}) row {
+	st, err := s.stmt(ctx, query)
+	if err != nil {
+		return errRow{err}
+	}
+	return st.QueryRowContext(ctx, args...)
+}
+
+// row is a row returned by a query
+type row interface {
+	Scan(dest ...interface{}) error
+}
+
+// errRow is a row whose query could not be run
+type errRow struct {
+	err error
+}
+
+func (r errRow) Scan(dest ...interface{}) error {
+	return r.err
+}
+
+// batchRows is the most rows insertRows inserts with one statement, keeping
+// well within the parameters a statement can have
+const batchRows = 500
+
+// insertRows inserts rows of the values of cols into table, batchRows at a
+// time
+func (s *Store) insertRows(ctx context.Context, table string, cols []string, rows [][]interface{}) error {
+	for len(rows) > 0 {
+		chunk := rows
+		if len(chunk) > batchRows {
+			chunk = chunk[:batchRows]
+		}
+		rows = rows[len(chunk):]
+		tuples := make([]string, len(chunk))
+		var args []interface{}
+		for i, r := range chunk {
+			tuples[i] = "(" + params(len(args)+1, len(r)) + ")"
+			args = append(args, r...)
+		}
+		if _, err := s.exec(ctx, "INSERT INTO {schema}."+table+" ("+strings.Join(cols, ", ")+") VALUES "+strings.Join(tuples, ", "), args...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Tx satisfies store.Store. fn runs in a SQL transaction, Tx on the Store it
+// is given joins the transaction.
+func (s *Store) Tx(ctx context.Context, fn func(s store.Store) error) error {
+	return s.atomic(ctx, func(tx *Store) error {
+		return fn(tx)
+	})
+}
+
+// atomic runs fn in the Store's transaction, or in a new one committed if fn
+// succeeds
+func (s *Store) atomic(ctx context.Context, fn func(s *Store) error) error {
+	if s.tx != nil {
+		return fn(s)
+	}
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := fn(&Store{db: s.db, schema: s.schema, prepared: s.prepared, tx: tx, txPrepared: &statements{}}); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// columns are the columns of tickets written from a ticket besides id,
+// version and created_at, in the order of the values returned by values
+var columns = []string{"status", "queue", "assignee", "reporter", "channel_id", "thread_ts", "issue", "priority", "title", "description", "updated_at", "deleted_at", "doc"}
+
+// insertColumns are the columns written when a ticket is created, in the
+// order of the values returned by ticketRow
+var insertColumns = append([]string{"id", "version", "created_at"}, columns...)
+
+// ticketRow returns the values of insertColumns for t
+func ticketRow(t *ticket.Ticket) ([]interface{}, error) {
+	vals, err := values(t)
+	if err != nil {
+		return nil, err
+	}
+	return append([]interface{}{t.ID, t.Version, t.CreatedAt}, vals...), nil
+}
+
+// values returns the values of columns for t
+func values(t *ticket.Ticket) ([]interface{}, error) {
+	// Comments are kept in their own table
+	doc := *t
+	doc.Comments = nil
+	b, err := json.Marshal(&doc)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding ticket %s: %s", t.ID, err)
+	}
+	var deleted interface{}
+	if !t.DeletedAt.IsZero() {
+		deleted = t.DeletedAt
+	}
+	return []interface{}{string(t.Status), t.Queue, t.Assignee, t.Reporter, t.ChannelID, t.ThreadTS, t.Issue.Key, int(t.Priority), t.Title, t.Description, t.UpdatedAt, deleted, string(b)}, nil
+}
+
+// params returns the placeholders of n parameters from $from
+func params(from, n int) string {
+	ps := make([]string, n)
+	for i := range ps {
+		ps[i] = "$" + strconv.Itoa(from+i)
+	}
+	return strings.Join(ps, ", ")
+}
+
+// ticketColumns are the columns scanTicket reads
+const ticketColumns = "t.id, t.version, t.created_at, t.updated_at, t.doc"
+
+// scanTicket reads a ticket from a row of ticketColumns. The columns are
+// authoritative for the fields the database sets.
+func scanTicket(r row) (*ticket.Ticket, error) {
+	var t ticket.Ticket
+	var id string
+	var version int
+	var created, updated time.Time
+	var doc []byte
+	if err := r.Scan(&id, &version, &created, &updated, &doc); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(doc, &t); err != nil {
+		return nil, fmt.Errorf("error decoding ticket %s: %s", id, err)
+	}
+	t.ID, t.Version, t.CreatedAt, t.UpdatedAt = id, version, created, updated
+	return &t, nil
+}
+
+// CreateTicket satisfies store.Store
+func (s *Store) CreateTicket(ctx context.Context, t *ticket.Ticket) error {
+	return s.atomic(ctx, func(s *Store) error {
+		return s.create(ctx, t)
+	})
+}
+
+// insertTicket creates a ticket from the values of ticketRow, unless one has
+// its ID
+var insertTicket = "INSERT INTO {schema}.tickets (" + strings.Join(insertColumns, ", ") + ") VALUES (" + params(1, len(insertColumns)) + ") ON CONFLICT (id) DO NOTHING"
+
+// newTicket sets the fields the store sets on a ticket it creates at now
+func newTicket(t *ticket.Ticket, now time.Time) {
+	// PostgreSQL keeps times to the microsecond
+	now = now.Truncate(time.Microsecond)
+	if t.CreatedAt.IsZero() {
+		t.CreatedAt = now
+	}
+	t.CreatedAt = t.CreatedAt.Truncate(time.Microsecond)
+	t.UpdatedAt = now
+	t.Version = 1
+	if t.Status == "" {
+		t.Status = ticket.StatusNew
+	}
+}
+
+func (s *Store) create(ctx context.Context, t *ticket.Ticket) error {
+	newTicket(t, time.Now())
+	generated := t.ID == ""
+	for {
+		if generated {
+			var seq int64
+			if err := s.queryRowPrepared(ctx, "SELECT nextval('{schema}.ticket_ids')").Scan(&seq); err != nil {
+				return err
+			}
+			t.ID = strconv.FormatInt(seq, 10)
+		}
+		vals, err := ticketRow(t)
+		if err != nil {
+			return err
+		}
+		res, err := s.execPrepared(ctx, insertTicket, vals...)
+		if err != nil {
+			return err
+		}
+		if n, err := res.RowsAffected(); err != nil {
+			return err
+		} else if n == 1 {
+			break
+		}
+		// Generated IDs skip those of tickets imported with theirs
+		if !generated {
+			return store.ErrExists
+		}
+	}
+	if err := s.setTags(ctx, t, false); err != nil {
+		return err
+	}
+	return s.setComments(ctx, t, nil)
+}
+
+// GetTicket satisfies store.Store
+func (s *Store) GetTicket(ctx context.Context, id string) (*ticket.Ticket, error) {
+	t, err := scanTicket(s.queryRowPrepared(ctx, "SELECT "+ticketColumns+" FROM {schema}.tickets t WHERE t.id = $1", id))
+	if err == sql.ErrNoRows {
+		return nil, store.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := s.loadComments(ctx, []*ticket.Ticket{t}); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// DeleteTicket satisfies store.Store, the ticket's tags and comments are
+// deleted with it
+func (s *Store) DeleteTicket(ctx context.Context, id string) error {
+	res, err := s.execPrepared(ctx, "DELETE FROM {schema}.tickets WHERE id = $1", id)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return store.ErrNotFound
+	}
+	return nil
+}
+
+// UpdateTicket satisfies store.Store
+func (s *Store) UpdateTicket(ctx context.Context, t *ticket.Ticket) error {
+	return s.atomic(ctx, func(s *Store) error {
+		return s.update(ctx, t)
+	})
+}
+
+// updateTicket writes the values of columns from $3 over the ticket with ID
+// $1 if it is at version $2
+var updateTicket = func() string {
+	set := make([]string, len(columns))
+	for i, c := range columns {
+		set[i] = c + " = $" + strconv.Itoa(i+3)
+	}
+	return "UPDATE {schema}.tickets SET version = version + 1, " + strings.Join(set, ", ") + " WHERE id = $1 AND version = $2 RETURNING created_at"
+}()
+
+// update writes t over the stored ticket if that is still at t's Version
+func (s *Store) update(ctx context.Context, t *ticket.Ticket) error {
+	next := *t
+	next.UpdatedAt = time.Now().Truncate(time.Microsecond)
+	next.Version++
+	vals, err := values(&next)
+	if err != nil {
+		return err
+	}
+	var created time.Time
+	err = s.queryRowPrepared(ctx, updateTicket, append([]interface{}{t.ID, t.Version}, vals...)...).Scan(&created)
+	if err == sql.ErrNoRows {
+		var n int
+		if err := s.queryRowPrepared(ctx, "SELECT count(*) FROM {schema}.tickets WHERE id = $1", t.ID).Scan(&n); err != nil {
+			return err
+		}
+		if n == 0 {
+			return store.ErrNotFound
+		}
+		return store.ErrStale
+	}
+	if err != nil {
+		return err
+	}
+	if err := s.setTags(ctx, t, true); err != nil {
+		return err
+	}
+	stored, err := s.comments(ctx, []string{t.ID})
+	if err != nil {
+		return err
+	}
+	if err := s.setComments(ctx, t, stored[t.ID]); err != nil {
+		return err
+	}
+	t.CreatedAt, t.UpdatedAt, t.Version = created, next.UpdatedAt, next.Version
+	return nil
+}
+
+// setTags writes the tags of t, replacing those stored if replace is set
+func (s *Store) setTags(ctx context.Context, t *ticket.Ticket, replace bool) error {
+	if replace {
+		if _, err := s.execPrepared(ctx, "DELETE FROM {schema}.ticket_tags WHERE ticket_id = $1", t.ID); err != nil {
+			return err
+		}
+	}
+	return s.insertRows(ctx, "ticket_tags", tagColumns, tagRows(t))
+}
+
+// tagColumns are the columns of ticket_tags, in the order of tagRows
+var tagColumns = []string{"ticket_id", "tag"}
+
+// tagRows returns the rows of ticket_tags for t, once for each tag
+func tagRows(t *ticket.Ticket) [][]interface{} {
+	var rows [][]interface{}
+	seen := map[string]bool{}
+	for _, tag := range t.Tags {
+		if !seen[tag] {
+			seen[tag] = true
+			rows = append(rows, []interface{}{t.ID, tag})
+		}
+	}
+	return rows
+}
+
+// setComments writes the comments of t from the first which differs from
+// stored, the comments it has in the database. Comments are normally added
+// to the end, so only the new ones are written.
+func (s *Store) setComments(ctx context.Context, t *ticket.Ticket, stored []ticket.Comment) error {
+	from := 0
+	for from < len(stored) && from < len(t.Comments) && sameComment(stored[from], t.Comments[from]) {
+		from++
+	}
+	if from < len(stored) {
+		if _, err := s.execPrepared(ctx, "DELETE FROM {schema}.comments WHERE ticket_id = $1 AND position >= $2", t.ID, from); err != nil {
+			return err
+		}
+	}
+	return s.insertRows(ctx, "comments", commentColumns, commentRows(t, from))
+}
+
+// commentColumns are the columns of comments, in the order of commentRows
+var commentColumns = []string{"ticket_id", "position", "id", "author", "text", "internal", "created_at"}
+
+// commentRows returns the rows of comments for the comments of t from
+// position from
+func commentRows(t *ticket.Ticket, from int) [][]interface{} {
+	var rows [][]interface{}
+	for i := from; i < len(t.Comments); i++ {
+		c := t.Comments[i]
+		rows = append(rows, []interface{}{t.ID, i, c.ID, c.Author, c.Text, c.Internal, c.CreatedAt})
+	}
+	return rows
+}
+
+// sameComment reports whether the stored comment a is c
+func sameComment(a, c ticket.Comment) bool {
+	return a.ID == c.ID && a.Author == c.Author && a.Text == c.Text && a.Internal == c.Internal && a.CreatedAt.Equal(c.CreatedAt.Truncate(time.Microsecond))
+}
+
+// inLimit is the most IDs looked up in one query
+const inLimit = 1000
+
+// comments returns the stored comments of the tickets with ids, in order
+func (s *Store) comments(ctx context.Context, ids []string) (map[string][]ticket.Comment, error) {
+	res := map[string][]ticket.Comment{}
+	for len(ids) > 0 {
+		chunk := ids
+		if len(chunk) > inLimit {
+			chunk = chunk[:inLimit]
+		}
+		ids = ids[len(chunk):]
+		args := make([]interface{}, len(chunk))
+		for i, id := range chunk {
+			args[i] = id
+		}
+		rows, err := s.query(ctx, "SELECT ticket_id, id, author, text, internal, created_at FROM {schema}.comments WHERE ticket_id IN ("+params(1, len(args))+") ORDER BY ticket_id, position", args...)
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			var id string
+			var c ticket.Comment
+			if err := rows.Scan(&id, &c.ID, &c.Author, &c.Text, &c.Internal, &c.CreatedAt); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			res[id] = append(res[id], c)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+// loadComments sets the comments of tickets to those stored
+func (s *Store) loadComments(ctx context.Context, tickets []*ticket.Ticket) error {
+	ids := make([]string, len(tickets))
+	for i, t := range tickets {
+		ids[i] = t.ID
+	}
+	comments, err := s.comments(ctx, ids)
+	if err != nil {
+		return err
+	}
+	for _, t := range tickets {
+		t.Comments = comments[t.ID]
+	}
+	return nil
+}
+
+// ListTickets satisfies store.Store
+func (s *Store) ListTickets(ctx context.Context, f store.Filter) ([]*ticket.Ticket, string, error) {
+	var q query
+	conds := []string{"t.deleted_at IS NULL"}
+	if f.Deleted {
+		conds[0] = "t.deleted_at IS NOT NULL"
+	}
+	conds = append(conds, q.match(f)...)
+	if f.Cursor != "" {
+		after, err := store.DecodeCursor(f.Cursor)
+		if err != nil {
+			return nil, "", err
+		}
+		conds = append(conds, fmt.Sprintf("(t.created_at, length(t.id), t.id) > (%s, %s, %s)", q.arg(after.CreatedAt), q.arg(len(after.ID)), q.arg(after.ID)))
+	}
+	sql := "SELECT " + ticketColumns + " FROM {schema}.tickets t WHERE " + strings.Join(conds, " AND ") + " ORDER BY t.created_at, length(t.id), t.id"
+	if f.Limit > 0 {
+		sql += " LIMIT " + q.arg(f.Limit+1)
+	}
+	rows, err := s.query(ctx, sql, q.args...)
+	if err != nil {
+		return nil, "", err
+	}
+	defer rows.Close()
+	res := []*ticket.Ticket{}
+	for rows.Next() {
+		t, err := scanTicket(rows)
+		if err != nil {
+			return nil, "", err
+		}
+		res = append(res, t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", err
+	}
+	rows.Close()
+	var next string
+	if f.Limit > 0 && len(res) > f.Limit {
+		res = res[:f.Limit]
+		next = store.EncodeCursor(res[len(res)-1])
+	}
+	if err := s.loadComments(ctx, res); err != nil {
+		return nil, "", err
+	}
+	return res, next, nil
+}
+
+// Transition satisfies store.Store. The ticket is read and written back if
+// it is still at the version read, a concurrent write makes it read again.
+// Outside Tx a transition which fails to serialize with another is retried.
+func (s *Store) Transition(ctx context.Context, id string, from, to ticket.Status) (*ticket.Ticket, error) {
+	var t *ticket.Ticket
+	for {
+		err := s.atomic(ctx, func(s *Store) error {
+			for {
+				var err error
+				if t, err = s.GetTicket(ctx, id); err != nil {
+					return err
+				}
+				if t.Status != from {
+					return store.ErrConflict
+				}
+				t.SetStatus(to, time.Now())
+				if err := s.update(ctx, t); err != store.ErrStale {
+					return err
+				}
+			}
+		})
+		if err == nil {
+			return t, nil
+		}
+		if s.tx != nil || !serializationFailure(err) {
+			return nil, err
+		}
+	}
+}
+
+// serializationFailure reports whether err is from a transaction which
+// conflicted with another and would succeed if run again, which is how
+// databases running transactions at the serializable isolation level refuse
+// concurrent writes
+func serializationFailure(err error) bool {
+	var e interface{ SQLState() string }
+	return errors.As(err, &e) && e.SQLState() == "40001"
+}
+
+// Enqueue satisfies store.Store
+func (s *Store) Enqueue(ctx context.Context, n *store.Notification) error {
+	created := time.Now().Truncate(time.Microsecond)
+	var seq int64
+	err := s.queryRowPrepared(ctx, "INSERT INTO {schema}.outbox (ticket_id, channel_id, thread_ts, text, created_at, attempts, last_error) VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING seq",
+		n.TicketID, n.ChannelID, n.ThreadTS, n.Text, created, n.Attempts, n.LastError).Scan(&seq)
+	if err != nil {
+		return err
+	}
+	n.ID, n.CreatedAt = strconv.FormatInt(seq, 10), created
+	return nil
+}
+
+// Outbox satisfies store.Store
+func (s *Store) Outbox(ctx context.Context, limit int) ([]*store.Notification, error) {
+	sql := "SELECT seq, ticket_id, channel_id, thread_ts, text, created_at, attempts, last_error FROM {schema}.outbox ORDER BY seq"
+	var args []interface{}
+	if limit > 0 {
+		sql += " LIMIT $1"
+		args = append(args, limit)
+	}
+	rows, err := s.query(ctx, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	res := []*store.Notification{}
+	for rows.Next() {
+		var n store.Notification
+		var seq int64
+		if err := rows.Scan(&seq, &n.TicketID, &n.ChannelID, &n.ThreadTS, &n.Text, &n.CreatedAt, &n.Attempts, &n.LastError); err != nil {
+			return nil, err
+		}
+		n.ID = strconv.FormatInt(seq, 10)
+		res = append(res, &n)
+	}
+	return res, rows.Err()
+}
+
+// Sent satisfies store.Store
+func (s *Store) Sent(ctx context.Context, id string) error {
+	return s.notification(ctx, "DELETE FROM {schema}.outbox WHERE seq = $1", id)
+}
+
+// Failed satisfies store.Store
+func (s *Store) Failed(ctx context.Context, id string, reason string) error {
+	return s.notification(ctx, "UPDATE {schema}.outbox SET attempts = attempts + 1, last_error = $2 WHERE seq = $1", id, reason)
+}
+
+// notification runs a statement changing the notification with id, the
+// first parameter, returning store.ErrNoNotification if there is none
+func (s *Store) notification(ctx context.Context, query, id string, args ...interface{}) error {
+	seq, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return store.ErrNoNotification
+	}
+	res, err := s.execPrepared(ctx, query, append([]interface{}{seq}, args...)...)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return store.ErrNoNotification
+	}
+	return nil
+}
+
+// SetQueueChannel satisfies store.Store
+func (s *Store) SetQueueChannel(ctx context.Context, qc *store.QueueChannel) error {
+	_, err := s.exec(ctx, `INSERT INTO {schema}.queue_channels (queue, channel_id, dashboard_ts, provisioned_by, provisioned_at) VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (queue) DO UPDATE SET channel_id = excluded.channel_id, dashboard_ts = excluded.dashboard_ts, provisioned_by = excluded.provisioned_by, provisioned_at = excluded.provisioned_at`,
+		qc.Queue, qc.ChannelID, qc.DashboardTS, qc.ProvisionedBy, qc.ProvisionedAt)
+	return err
+}
+
+// QueueChannels satisfies store.Store
+func (s *Store) QueueChannels(ctx context.Context) ([]*store.QueueChannel, error) {
+	rows, err := s.query(ctx, "SELECT queue, channel_id, dashboard_ts, provisioned_by, provisioned_at FROM {schema}.queue_channels")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	res := []*store.QueueChannel{}
+	for rows.Next() {
+		var qc store.QueueChannel
+		if err := rows.Scan(&qc.Queue, &qc.ChannelID, &qc.DashboardTS, &qc.ProvisionedBy, &qc.ProvisionedAt); err != nil {
+			return nil, err
+		}
+		res = append(res, &qc)
+	}
+	// Ordered as the other stores order them, whatever the collation
+	sort.Slice(res, func(i, j int) bool { return res[i].Queue < res[j].Queue })
+	return res, rows.Err()
+}
@@ -0,0 +1,219 @@
+package postgres_test
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	_ "github.com/lib/pq"
+
+	"github.com/skybet/go-helpdesk/store"
+	"github.com/skybet/go-helpdesk/store/postgres"
+	"github.com/skybet/go-helpdesk/store/storetest"
+	"github.com/skybet/go-helpdesk/ticket"
+)
+
+// dsn is the server the tests create their databases on, such as
+// postgres://postgres@localhost/postgres?sslmode=disable. The tests are
+// skipped if it is not set.
+var dsn = os.Getenv("HELPDESK_TEST_POSTGRES")
+
+// databases counts the databases created by the tests
+var databases int32
+
+// newDatabase returns a new database on the test server, which is dropped
+// once tb finishes
+func newDatabase(tb testing.TB) *sql.DB {
+	tb.Helper()
+	if dsn == "" {
+		tb.Skip("HELPDESK_TEST_POSTGRES is not set")
+	}
+	u, err := url.Parse(dsn)
+	if err != nil {
+		tb.Fatalf("Invalid HELPDESK_TEST_POSTGRES: %s", err)
+	}
+	server, err := sql.Open("postgres", dsn)
+	if err != nil {
+		tb.Fatalf("Unexpected error: %s", err)
+	}
+	name := fmt.Sprintf("helpdesk_test_%d_%d", os.Getpid(), atomic.AddInt32(&databases, 1))
+	if _, err := server.Exec("CREATE DATABASE " + name); err != nil {
+		tb.Fatalf("Unexpected error creating database %s: %s", name, err)
+	}
+	u.Path = "/" + name
+	db, err := sql.Open("postgres", u.String())
+	if err != nil {
+		tb.Fatalf("Unexpected error: %s", err)
+	}
+	tb.Cleanup(func() {
+		db.Close()
+		if _, err := server.Exec("DROP DATABASE " + name); err != nil {
+			tb.Logf("Failed to drop database %s: %s", name, err)
+		}
+		server.Close()
+	})
+	return db
+}
+
+// open returns a Store in the public schema of a new database
+func open(tb testing.TB) *postgres.Store {
+	tb.Helper()
+	s, err := postgres.Open(context.Background(), newDatabase(tb), "")
+	if err != nil {
+		tb.Fatalf("Unexpected error opening the store: %s", err)
+	}
+	return s
+}
+
+func TestPostgresConformance(t *testing.T) {
+	storetest.RunConformance(t, func(t *testing.T) store.Store {
+		return open(t)
+	})
+}
+
+func TestMigrations(t *testing.T) {
+	db, schema := newDatabase(t), "helpdesk"
+	if _, err := postgres.Open(context.Background(), db, schema); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	// Opening a migrated schema again leaves it as it is
+	if _, err := postgres.Open(context.Background(), db, schema); err != nil {
+		t.Fatalf("Unexpected error opening the store again: %s", err)
+	}
+	if _, err := db.Exec("INSERT INTO " + schema + ".schema_migrations (version, applied_at) VALUES (99, now())"); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if _, err := postgres.Open(context.Background(), db, schema); err == nil {
+		t.Errorf("Expected a schema from a later version to be refused")
+	}
+	if _, err := postgres.Open(context.Background(), db, "Robert'); DROP TABLE tickets;--"); err == nil {
+		t.Errorf("Expected an invalid schema name to be refused")
+	}
+}
+
+func TestImport(t *testing.T) {
+	ctx := context.Background()
+	s := open(t)
+	if err := s.CreateTicket(ctx, &ticket.Ticket{ID: "2", Title: "Created"}); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	created := time.Date(2026, 1, 1, 9, 0, 0, 0, time.UTC)
+	tickets := []*ticket.Ticket{
+		{Title: "VPN down", Tags: []string{"vpn", "vpn", "remote"}, CreatedAt: created, Comments: []ticket.Comment{
+			{ID: "c1", Author: "U1", Text: "Which office?\tTabs\nand lines", CreatedAt: created},
+			{ID: "c2", Author: "U2", Text: "London", Internal: true, CreatedAt: created.Add(time.Minute)},
+		}},
+		{ID: "4", Title: "Imported with its ID"},
+		{Title: "Printer jammed"},
+		{Title: "Laptop stolen"},
+	}
+	if err := s.Import(ctx, tickets); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	// Generated IDs skip those of tickets created or imported with theirs
+	var ids []string
+	for _, tk := range tickets {
+		ids = append(ids, tk.ID)
+	}
+	if fmt.Sprint(ids) != "[1 4 3 5]" {
+		t.Errorf("Expected IDs [1 4 3 5], got %v", ids)
+	}
+	got, err := s.GetTicket(ctx, tickets[0].ID)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if got.Version != 1 || got.Status != ticket.StatusNew || !got.CreatedAt.Equal(created) || fmt.Sprint(got.Tags) != "[vpn vpn remote]" {
+		t.Errorf("Expected the ticket as imported, got %+v", got)
+	}
+	if len(got.Comments) != 2 || got.Comments[0].Text != tickets[0].Comments[0].Text || !got.Comments[1].Internal {
+		t.Errorf("Expected the comments as imported, got %+v", got.Comments)
+	}
+	if page, _, err := s.ListTickets(ctx, store.Filter{Tags: []string{"remote"}}); err != nil || len(page) != 1 {
+		t.Errorf("Expected the imported tags to be searchable, got %d tickets, %v", len(page), err)
+	}
+
+	// An import is written entirely or not at all
+	err = s.Import(ctx, []*ticket.Ticket{{Title: "New"}, {ID: "4", Title: "Again"}})
+	if err != store.ErrExists {
+		t.Errorf("Expected ErrExists importing an existing ID, got %v", err)
+	}
+	if page, _, _ := s.ListTickets(ctx, store.Filter{}); len(page) != 5 {
+		t.Errorf("Expected a failed import to write nothing, got %d tickets", len(page))
+	}
+	err = s.Tx(ctx, func(tx store.Store) error {
+		for i := 0; i < 2; i++ {
+			if err := tx.(store.Importer).Import(ctx, []*ticket.Ticket{{Title: "In a transaction"}}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Errorf("Expected imports in a transaction after a failed one, got %v", err)
+	}
+}
+
+func TestIndexes(t *testing.T) {
+	db := newDatabase(t)
+	if _, err := postgres.Open(context.Background(), db, ""); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	rows, err := db.Query("SELECT indexname FROM pg_indexes WHERE schemaname = 'public' AND tablename = 'tickets'")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	defer rows.Close()
+	indexes := map[string]bool{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		indexes[name] = true
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	// The common filters have composite indexes, replacing those on their
+	// first column
+	for name, want := range map[string]bool{
+		"tickets_status_queue":    true,
+		"tickets_assignee_status": true,
+		"tickets_status":          false,
+		"tickets_assignee":        false,
+	} {
+		if indexes[name] != want {
+			t.Errorf("Expected index %s to exist %v, got %v", name, want, indexes)
+		}
+	}
+}
+
+// benchSizes are the numbers of tickets BenchmarkPostgres uses, from
+// HELPDESK_BENCH_TICKETS such as 1000000, storetest.DefaultSizes if not set
+func benchSizes(b *testing.B) []int {
+	var sizes []int
+	for _, n := range strings.Split(os.Getenv("HELPDESK_BENCH_TICKETS"), ",") {
+		if n == "" {
+			continue
+		}
+		i, err := strconv.Atoi(n)
+		if err != nil {
+			b.Fatalf("Invalid HELPDESK_BENCH_TICKETS: %s", err)
+		}
+		sizes = append(sizes, i)
+	}
+	return sizes
+}
+
+func BenchmarkPostgres(b *testing.B) {
+	storetest.RunBenchmarks(b, func(b *testing.B) store.Store {
+		return open(b)
+	}, benchSizes(b)...)
+}
@@ -0,0 +1,96 @@
+package postgres
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/skybet/go-helpdesk/store"
+)
+
+// query collects the arguments of a query as its conditions are built
+type query struct {
+	args []interface{}
+}
+
+// arg adds v to the arguments and returns its placeholder
+func (q *query) arg(v interface{}) string {
+	q.args = append(q.args, v)
+	return "$" + strconv.Itoa(len(q.args))
+}
+
+// in returns the condition that column is one of values
+func (q *query) in(column string, values []interface{}) string {
+	ps := make([]string, len(values))
+	for i, v := range values {
+		ps[i] = q.arg(v)
+	}
+	return column + " IN (" + strings.Join(ps, ", ") + ")"
+}
+
+// match returns the conditions on the tickets table, t, of f's fields other
+// than Deleted and paging, as store.Filter matches them
+func (q *query) match(f store.Filter) []string {
+	var conds []string
+	if len(f.Status) > 0 {
+		values := make([]interface{}, len(f.Status))
+		for i, s := range f.Status {
+			values[i] = string(s)
+		}
+		conds = append(conds, q.in("t.status", values))
+	}
+	for _, eq := range []struct{ column, value string }{
+		{"t.queue", f.Queue},
+		{"t.assignee", f.Assignee},
+		{"t.reporter", f.Reporter},
+		{"t.channel_id", f.ChannelID},
+		{"t.thread_ts", f.ThreadTS},
+		{"t.issue", f.Issue},
+	} {
+		if eq.value != "" {
+			conds = append(conds, eq.column+" = "+q.arg(eq.value))
+		}
+	}
+	for _, tag := range f.Tags {
+		conds = append(conds, "EXISTS (SELECT 1 FROM {schema}.ticket_tags g WHERE g.ticket_id = t.id AND g.tag = "+q.arg(tag)+")")
+	}
+	if len(f.Priority) > 0 {
+		values := make([]interface{}, len(f.Priority))
+		for i, p := range f.Priority {
+			values[i] = int(p)
+		}
+		conds = append(conds, q.in("t.priority", values))
+	}
+	if f.Unassigned {
+		conds = append(conds, "t.assignee = ''")
+	}
+	for _, r := range []struct {
+		column string
+		at     time.Time
+		op     string
+	}{
+		{"t.created_at", f.CreatedAfter, ">="},
+		{"t.created_at", f.CreatedBefore, "<"},
+		{"t.updated_at", f.UpdatedAfter, ">="},
+		{"t.updated_at", f.UpdatedBefore, "<"},
+	} {
+		if !r.at.IsZero() {
+			conds = append(conds, r.column+" "+r.op+" "+q.arg(r.at))
+		}
+	}
+	if f.Text != "" {
+		text := q.arg(strings.ToLower(f.Text))
+		conds = append(conds, fmt.Sprintf("(strpos(lower(t.title), %[1]s) > 0 OR strpos(lower(t.description), %[1]s) > 0 OR EXISTS (SELECT 1 FROM {schema}.comments c WHERE c.ticket_id = t.id AND strpos(lower(c.text), %[1]s) > 0))", text))
+	}
+	for _, ex := range f.Exclude {
+		sub := q.match(ex)
+		if len(sub) == 0 {
+			// An empty filter matches, so excludes, every ticket
+			conds = append(conds, "FALSE")
+			continue
+		}
+		conds = append(conds, "NOT ("+strings.Join(sub, " AND ")+")")
+	}
+	return conds
+}
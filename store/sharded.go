@@ -0,0 +1,415 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/skybet/go-helpdesk/ticket"
+)
+
+type teamKey struct{}
+
+// WithTeam returns a copy of ctx for the requests of a Slack team, which a
+// Sharded store routes to the team's shard
+func WithTeam(ctx context.Context, team string) context.Context {
+	return context.WithValue(ctx, teamKey{}, team)
+}
+
+// TeamFrom returns the team set by WithTeam, empty if there is none
+func TeamFrom(ctx context.Context) string {
+	team, _ := ctx.Value(teamKey{}).(string)
+	return team
+}
+
+// Sharded is a Store partitioning tickets between the stores of Slack teams,
+// such as a schema or database each, so that large multi-tenant installs do
+// not keep every team's tickets together. Calls are routed to the shard of
+// the team on their context. Tickets and notifications in a team's shard have
+// IDs starting with the team, such as T0123-42, so that calls by ID without a
+// team, such as from background jobs, find them too. Teams without a shard
+// use the fallback store, whose IDs are left as they are, and listing
+// without a team lists every shard.
+//
+// Transactions without a team begin one on each shard as they first use it.
+// Shards whose transactions exclude each other, such as Memory and EventLog,
+// can deadlock when two of them use the same shards in a different order, so
+// those should be shared by few teams. Stores which lock rows, such as
+// store/postgres, do not.
+type Sharded struct {
+	*router
+}
+
+// NewSharded returns a Sharded store routing the teams in shards to their
+// stores, and others to fallback
+func NewSharded(fallback Store, shards map[string]Store) *Sharded {
+	r := &router{shards: map[string]Store{"": fallback}, teams: []string{""}}
+	for team, s := range shards {
+		r.shards[team] = s
+		r.teams = append(r.teams, team)
+	}
+	sort.Strings(r.teams)
+	return &Sharded{r}
+}
+
+// router implements Sharded and the Store handed to its transactions
+type router struct {
+	// shards are the stores by team, "" for the fallback
+	shards map[string]Store
+	// teams are the teams of shards in the order results from several shards
+	// are merged in, the fallback first
+	teams []string
+	// ctx and txs are the context of a transaction and the transactions it
+	// has begun on each shard, txs is nil outside one
+	ctx context.Context
+	txs map[string]*shardTx
+}
+
+// teamOf returns the shard of the team on ctx, "" for the fallback
+func (r *router) teamOf(ctx context.Context) string {
+	if team := TeamFrom(ctx); r.shards[team] != nil {
+		return team
+	}
+	return ""
+}
+
+// byID returns the shard an ID is in and the ID within the shard
+func (r *router) byID(id string) (team, local string) {
+	if i := strings.Index(id, "-"); i > 0 && r.shards[id[:i]] != nil {
+		return id[:i], id[i+1:]
+	}
+	return "", id
+}
+
+// global returns the ID outside the shard of team of an ID within it
+func global(team, id string) string {
+	if team == "" {
+		return id
+	}
+	return team + "-" + id
+}
+
+// shard returns the store of team, in a transaction the transaction begun
+// on it
+func (r *router) shard(team string) (Store, error) {
+	if r.txs == nil {
+		return r.shards[team], nil
+	}
+	if tx, ok := r.txs[team]; ok {
+		return tx.tx, nil
+	}
+	tx, err := begin(r.ctx, r.shards[team])
+	if err != nil {
+		return nil, err
+	}
+	r.txs[team] = tx
+	return tx.tx, nil
+}
+
+func (r *router) CreateTicket(ctx context.Context, t *ticket.Ticket) error {
+	// Tickets imported with their IDs go where the ID says
+	team, local := r.teamOf(ctx), ""
+	if t.ID != "" {
+		team, local = r.byID(t.ID)
+	}
+	s, err := r.shard(team)
+	if err != nil {
+		return err
+	}
+	t.ID = local
+	err = s.CreateTicket(ctx, t)
+	t.ID = global(team, t.ID)
+	return err
+}
+
+func (r *router) GetTicket(ctx context.Context, id string) (*ticket.Ticket, error) {
+	team, local := r.byID(id)
+	s, err := r.shard(team)
+	if err != nil {
+		return nil, err
+	}
+	t, err := s.GetTicket(ctx, local)
+	if err != nil {
+		return nil, err
+	}
+	t.ID = global(team, t.ID)
+	return t, nil
+}
+
+func (r *router) DeleteTicket(ctx context.Context, id string) error {
+	team, local := r.byID(id)
+	s, err := r.shard(team)
+	if err != nil {
+		return err
+	}
+	return s.DeleteTicket(ctx, local)
+}
+
+func (r *router) UpdateTicket(ctx context.Context, t *ticket.Ticket) error {
+	team, local := r.byID(t.ID)
+	s, err := r.shard(team)
+	if err != nil {
+		return err
+	}
+	t.ID = local
+	err = s.UpdateTicket(ctx, t)
+	t.ID = global(team, local)
+	return err
+}
+
+func (r *router) Transition(ctx context.Context, id string, from, to ticket.Status) (*ticket.Ticket, error) {
+	team, local := r.byID(id)
+	s, err := r.shard(team)
+	if err != nil {
+		return nil, err
+	}
+	t, err := s.Transition(ctx, local, from, to)
+	if err != nil {
+		return nil, err
+	}
+	t.ID = global(team, t.ID)
+	return t, nil
+}
+
+// ListTickets lists the shard of the team on ctx, or every shard without a
+// team. Tickets from several shards are in creation order, then in the order
+// of their shards.
+func (r *router) ListTickets(ctx context.Context, f Filter) ([]*ticket.Ticket, string, error) {
+	if TeamFrom(ctx) != "" {
+		team := r.teamOf(ctx)
+		s, err := r.shard(team)
+		if err != nil {
+			return nil, "", err
+		}
+		page, next, err := s.ListTickets(ctx, f)
+		if err != nil {
+			return nil, "", err
+		}
+		for _, t := range page {
+			t.ID = global(team, t.ID)
+		}
+		return page, next, nil
+	}
+
+	// The cursor is the last ticket of the previous page. Shards before its
+	// own only have later tickets left, those after it tickets from then on.
+	var after *ticket.Ticket
+	at := -1
+	if f.Cursor != "" {
+		var err error
+		if after, err = DecodeCursor(f.Cursor); err != nil {
+			return nil, "", err
+		}
+		team, local := r.byID(after.ID)
+		after.ID = local
+		at = sort.SearchStrings(r.teams, team)
+	}
+	var res []*ticket.Ticket
+	more := false
+	for i, team := range r.teams {
+		s, err := r.shard(team)
+		if err != nil {
+			return nil, "", err
+		}
+		g := f
+		g.Cursor = ""
+		switch {
+		case after == nil:
+		case i == at:
+			g.Cursor = EncodeCursor(after)
+		case i < at:
+			g.CreatedAfter = latest(g.CreatedAfter, after.CreatedAt.Add(time.Nanosecond))
+		default:
+			g.CreatedAfter = latest(g.CreatedAfter, after.CreatedAt)
+		}
+		page, next, err := s.ListTickets(ctx, g)
+		if err != nil {
+			return nil, "", err
+		}
+		for _, t := range page {
+			t.ID = global(team, t.ID)
+		}
+		res = append(res, page...)
+		more = more || next != ""
+	}
+	sort.SliceStable(res, func(i, j int) bool { return res[i].CreatedAt.Before(res[j].CreatedAt) })
+	if f.Limit <= 0 || (len(res) <= f.Limit && !more) {
+		return res, "", nil
+	}
+	if len(res) > f.Limit {
+		res = res[:f.Limit]
+	}
+	return res, EncodeCursor(res[len(res)-1]), nil
+}
+
+// latest returns the later of two times, zero being the earliest
+func latest(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
+
+// Tx runs fn with transactions begun on each shard it uses, which are then
+// committed one after another. A transaction is only atomic within each
+// shard, those changing tickets of several teams are not.
+func (r *router) Tx(ctx context.Context, fn func(s Store) error) error {
+	if r.txs != nil {
+		return fn(r)
+	}
+	tx := &router{shards: r.shards, teams: r.teams, ctx: ctx, txs: map[string]*shardTx{}}
+	defer func() {
+		if p := recover(); p != nil {
+			tx.end(fmt.Errorf("panic in transaction: %v", p))
+			panic(p)
+		}
+	}()
+	return tx.end(fn(tx))
+}
+
+// end commits the transactions begun if err is nil, or rolls them back, and
+// returns err or the error committing them
+func (r *router) end(err error) error {
+	for _, team := range r.teams {
+		if tx, ok := r.txs[team]; ok {
+			err = tx.end(err)
+		}
+	}
+	return err
+}
+
+// shardTx is a transaction on one shard, run by Tx in a goroutine of its own
+// until it is ended
+type shardTx struct {
+	tx Store
+	// done is sent the error to end the transaction with, result what Tx
+	// returned
+	done   chan error
+	result chan error
+}
+
+// begin starts a transaction on s
+func begin(ctx context.Context, s Store) (*shardTx, error) {
+	st := &shardTx{done: make(chan error), result: make(chan error, 1)}
+	started := make(chan Store)
+	go func() {
+		st.result <- s.Tx(ctx, func(tx Store) error {
+			started <- tx
+			return <-st.done
+		})
+	}()
+	select {
+	case st.tx = <-started:
+		return st, nil
+	case err := <-st.result:
+		return nil, err
+	}
+}
+
+// end commits the transaction if err is nil or rolls it back, returning err
+// or the error committing it
+func (st *shardTx) end(err error) error {
+	st.done <- err
+	if res := <-st.result; err == nil {
+		return res
+	}
+	return err
+}
+
+func (r *router) Enqueue(ctx context.Context, n *Notification) error {
+	team, local := r.teamOf(ctx), ""
+	if n.TicketID != "" {
+		team, local = r.byID(n.TicketID)
+	}
+	s, err := r.shard(team)
+	if err != nil {
+		return err
+	}
+	n.TicketID = local
+	err = s.Enqueue(ctx, n)
+	n.TicketID = global(team, local)
+	if err == nil {
+		n.ID = global(team, n.ID)
+	}
+	return err
+}
+
+// Outbox returns the notifications of the team on ctx, or the oldest of every
+// shard without a team
+func (r *router) Outbox(ctx context.Context, limit int) ([]*Notification, error) {
+	teams := r.teams
+	if TeamFrom(ctx) != "" {
+		teams = []string{r.teamOf(ctx)}
+	}
+	res := []*Notification{}
+	for _, team := range teams {
+		s, err := r.shard(team)
+		if err != nil {
+			return nil, err
+		}
+		pending, err := s.Outbox(ctx, limit)
+		if err != nil {
+			return nil, err
+		}
+		for _, n := range pending {
+			n.ID, n.TicketID = global(team, n.ID), global(team, n.TicketID)
+		}
+		res = append(res, pending...)
+	}
+	sort.SliceStable(res, func(i, j int) bool { return res[i].CreatedAt.Before(res[j].CreatedAt) })
+	if limit > 0 && len(res) > limit {
+		res = res[:limit]
+	}
+	return res, nil
+}
+
+func (r *router) Sent(ctx context.Context, id string) error {
+	team, local := r.byID(id)
+	s, err := r.shard(team)
+	if err != nil {
+		return err
+	}
+	return s.Sent(ctx, local)
+}
+
+func (r *router) Failed(ctx context.Context, id string, reason string) error {
+	team, local := r.byID(id)
+	s, err := r.shard(team)
+	if err != nil {
+		return err
+	}
+	return s.Failed(ctx, local, reason)
+}
+
+func (r *router) SetQueueChannel(ctx context.Context, qc *QueueChannel) error {
+	s, err := r.shard(r.teamOf(ctx))
+	if err != nil {
+		return err
+	}
+	return s.SetQueueChannel(ctx, qc)
+}
+
+// QueueChannels returns the queue channels of the team on ctx, or those of
+// every shard without a team
+func (r *router) QueueChannels(ctx context.Context) ([]*QueueChannel, error) {
+	teams := r.teams
+	if TeamFrom(ctx) != "" {
+		teams = []string{r.teamOf(ctx)}
+	}
+	res := []*QueueChannel{}
+	for _, team := range teams {
+		s, err := r.shard(team)
+		if err != nil {
+			return nil, err
+		}
+		qcs, err := s.QueueChannels(ctx)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, qcs...)
+	}
+	sort.SliceStable(res, func(i, j int) bool { return res[i].Queue < res[j].Queue })
+	return res, nil
+}
@@ -0,0 +1,190 @@
+package store_test
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/skybet/go-helpdesk/store"
+	"github.com/skybet/go-helpdesk/store/storetest"
+	"github.com/skybet/go-helpdesk/ticket"
+)
+
+// teamStore makes every call to a store for a team, as the handlers of its
+// requests do
+type teamStore struct {
+	store.Store
+	team string
+}
+
+func (s teamStore) ctx(ctx context.Context) context.Context {
+	return store.WithTeam(ctx, s.team)
+}
+
+func (s teamStore) CreateTicket(ctx context.Context, t *ticket.Ticket) error {
+	return s.Store.CreateTicket(s.ctx(ctx), t)
+}
+
+func (s teamStore) GetTicket(ctx context.Context, id string) (*ticket.Ticket, error) {
+	return s.Store.GetTicket(s.ctx(ctx), id)
+}
+
+func (s teamStore) DeleteTicket(ctx context.Context, id string) error {
+	return s.Store.DeleteTicket(s.ctx(ctx), id)
+}
+
+func (s teamStore) UpdateTicket(ctx context.Context, t *ticket.Ticket) error {
+	return s.Store.UpdateTicket(s.ctx(ctx), t)
+}
+
+func (s teamStore) ListTickets(ctx context.Context, f store.Filter) ([]*ticket.Ticket, string, error) {
+	return s.Store.ListTickets(s.ctx(ctx), f)
+}
+
+func (s teamStore) Transition(ctx context.Context, id string, from, to ticket.Status) (*ticket.Ticket, error) {
+	return s.Store.Transition(s.ctx(ctx), id, from, to)
+}
+
+func (s teamStore) Tx(ctx context.Context, fn func(s store.Store) error) error {
+	return s.Store.Tx(s.ctx(ctx), func(tx store.Store) error {
+		return fn(teamStore{tx, s.team})
+	})
+}
+
+func (s teamStore) Enqueue(ctx context.Context, n *store.Notification) error {
+	return s.Store.Enqueue(s.ctx(ctx), n)
+}
+
+func (s teamStore) Outbox(ctx context.Context, limit int) ([]*store.Notification, error) {
+	return s.Store.Outbox(s.ctx(ctx), limit)
+}
+
+func (s teamStore) Sent(ctx context.Context, id string) error {
+	return s.Store.Sent(s.ctx(ctx), id)
+}
+
+func (s teamStore) Failed(ctx context.Context, id string, reason string) error {
+	return s.Store.Failed(s.ctx(ctx), id, reason)
+}
+
+func (s teamStore) SetQueueChannel(ctx context.Context, qc *store.QueueChannel) error {
+	return s.Store.SetQueueChannel(s.ctx(ctx), qc)
+}
+
+func (s teamStore) QueueChannels(ctx context.Context) ([]*store.QueueChannel, error) {
+	return s.Store.QueueChannels(s.ctx(ctx))
+}
+
+func newSharded() *store.Sharded {
+	return store.NewSharded(store.NewMemory(), map[string]store.Store{"T1": store.NewMemory(), "T2": store.NewMemory()})
+}
+
+func TestShardedConformance(t *testing.T) {
+	t.Run("Fallback", func(t *testing.T) {
+		storetest.RunConformance(t, func(t *testing.T) store.Store {
+			return newSharded()
+		})
+	})
+	t.Run("Shard", func(t *testing.T) {
+		storetest.RunConformance(t, func(t *testing.T) store.Store {
+			return teamStore{newSharded(), "T1"}
+		})
+	})
+}
+
+func TestShardedRouting(t *testing.T) {
+	ctx := context.Background()
+	fallback, t1, t2 := store.NewMemory(), store.NewMemory(), store.NewMemory()
+	s := store.NewSharded(fallback, map[string]store.Store{"T1": t1, "T2": t2})
+
+	created := time.Date(2026, 10, 14, 9, 0, 0, 0, time.UTC)
+	var want []string
+	for i, team := range []string{"T2", "T1", "T3", "T2", "T1", "T1", "T2", ""} {
+		tk := &ticket.Ticket{Title: fmt.Sprintf("ticket %d", i), CreatedAt: created.Add(time.Duration(i/2) * time.Minute)}
+		if err := s.CreateTicket(store.WithTeam(ctx, team), tk); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		if prefix := team + "-"; (team == "T1" || team == "T2") != strings.HasPrefix(tk.ID, prefix) {
+			t.Errorf("Expected the ID of a ticket of %q to name its shard, got %s", team, tk.ID)
+		}
+		want = append(want, tk.ID)
+	}
+	if n := counts(t, fallback, t1, t2); n != "2 3 3" {
+		t.Errorf("Expected tickets of teams without a shard in the fallback and the others in their shard, got %s", n)
+	}
+
+	// Background jobs find tickets by ID without a team
+	tk, err := s.GetTicket(ctx, want[0])
+	if err != nil || tk.ID != want[0] || tk.Title != "ticket 0" {
+		t.Fatalf("Expected to get the ticket by its ID, got %+v %v", tk, err)
+	}
+	tk.Assignee = "U1"
+	if err := s.UpdateTicket(ctx, tk); err != nil || tk.ID != want[0] {
+		t.Fatalf("Expected the update to keep the ticket's ID, got %s %v", tk.ID, err)
+	}
+	if got, _ := t2.GetTicket(ctx, strings.TrimPrefix(want[0], "T2-")); got == nil || got.Assignee != "U1" {
+		t.Errorf("Expected the update in the ticket's shard, got %+v", got)
+	}
+	err = s.Tx(ctx, func(tx store.Store) error {
+		_, err := tx.Transition(ctx, want[1], ticket.StatusNew, ticket.StatusTriaged)
+		return err
+	})
+	if got, _ := s.GetTicket(ctx, want[1]); err != nil || got.Status != ticket.StatusTriaged {
+		t.Errorf("Expected a transaction without a team to find the ticket's shard, got %v", err)
+	}
+
+	// Listing without a team pages through every shard in creation order
+	var got []string
+	f := store.Filter{Limit: 3}
+	for pages := 0; ; pages++ {
+		if pages > len(want) {
+			t.Fatalf("Pagination did not terminate")
+		}
+		page, next, err := s.ListTickets(ctx, f)
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		for _, tk := range page {
+			got = append(got, tk.ID)
+		}
+		if next == "" {
+			break
+		}
+		f.Cursor = next
+	}
+	// Tickets created at the same time are in the order of their shards
+	order := []string{want[1], want[0], want[2], want[3], want[4], want[5], want[7], want[6]}
+	if fmt.Sprint(got) != fmt.Sprint(order) {
+		t.Errorf("Expected %v, got %v", order, got)
+	}
+	if page, _, _ := s.ListTickets(store.WithTeam(ctx, "T1"), store.Filter{}); len(page) != 3 {
+		t.Errorf("Expected a team to list only its shard, got %d tickets", len(page))
+	}
+
+	// Notifications are routed by their ticket
+	if err := s.Enqueue(ctx, &store.Notification{TicketID: want[1], Text: "assigned"}); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	pending, err := s.Outbox(ctx, 0)
+	if err != nil || len(pending) != 1 || !strings.HasPrefix(pending[0].ID, "T1-") || pending[0].TicketID != want[1] {
+		t.Fatalf("Expected the notification in the ticket's shard, got %+v %v", pending, err)
+	}
+	if err := s.Sent(ctx, pending[0].ID); err != nil {
+		t.Errorf("Unexpected error: %s", err)
+	}
+}
+
+// counts returns the number of tickets in each store
+func counts(t *testing.T, stores ...store.Store) string {
+	var n []string
+	for _, s := range stores {
+		page, _, err := s.ListTickets(context.Background(), store.Filter{})
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		n = append(n, fmt.Sprint(len(page)))
+	}
+	return strings.Join(n, " ")
+}
@@ -0,0 +1,236 @@
+// Package store defines how helpdesk tickets are persisted
+package store
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/skybet/go-helpdesk/ticket"
+)
+
+var (
+	// ErrNotFound is returned when a ticket does not exist
+	ErrNotFound = errors.New("ticket not found")
+	// ErrConflict is returned when a transition finds the ticket is no
+	// longer in the expected status
+	ErrConflict = errors.New("ticket status has changed")
+	// ErrStale is returned when updating a ticket which has been written
+	// since it was read
+	ErrStale = errors.New("ticket has been changed")
+	// ErrNoNotification is returned when a notification is not in the outbox
+	ErrNoNotification = errors.New("notification not found")
+)
+
+// Notification is a Slack message waiting in the outbox. Enqueueing it in the
+// same transaction as the change it announces means the change is never made
+// without the message eventually being posted.
+type Notification struct {
+	ID string
+	// TicketID is the ticket the message is about
+	TicketID string
+	// ChannelID and ThreadTS are where to post, ThreadTS is empty to post in
+	// the channel itself
+	ChannelID string
+	ThreadTS  string
+	Text      string
+	CreatedAt time.Time
+	// Attempts counts the failed attempts to post the message, the last of
+	// which failed with LastError
+	Attempts  int
+	LastError string
+}
+
+// QueueChannel is the triage channel provisioned for a queue
+type QueueChannel struct {
+	Queue     string
+	ChannelID string
+	// DashboardTS is the pinned dashboard in the channel
+	DashboardTS string
+	// ProvisionedBy is who provisioned the channel at ProvisionedAt
+	ProvisionedBy string
+	ProvisionedAt time.Time
+}
+
+// Filter restricts the tickets returned by ListTickets. Empty fields match
+// everything.
+type Filter struct {
+	// Status matches tickets in any of the given statuses
+	Status   []ticket.Status
+	Queue    string
+	Assignee string
+	Reporter string
+	// ChannelID and ThreadTS match the ticket with that Slack thread
+	ChannelID string
+	ThreadTS  string
+	// Issue matches the ticket mirrored to the issue with that key
+	Issue string
+	// Tags matches tickets with all of the given tags
+	Tags []string
+	// Priority matches tickets with any of the given priorities
+	Priority []ticket.Priority
+	// Unassigned matches only the tickets without an assignee
+	Unassigned bool
+	// CreatedAfter and CreatedBefore match tickets raised at or after and
+	// before the times, UpdatedAfter and UpdatedBefore tickets last written
+	// then. Zero times leave the range open.
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+	UpdatedAfter  time.Time
+	UpdatedBefore time.Time
+	// Exclude leaves out the tickets matching any of the filters, whose
+	// Deleted and paging fields are ignored
+	Exclude []Filter
+	// Deleted matches only the tickets in the trash, otherwise they are left
+	// out
+	Deleted bool
+	// Text matches tickets whose title, description or comments contain it,
+	// ignoring case
+	Text string
+	// Limit is the maximum page size, zero for no limit
+	Limit int
+	// Cursor is the NextCursor from a previous page
+	Cursor string
+}
+
+// Match returns true if the ticket satisfies the filter, ignoring paging
+func (f Filter) Match(t *ticket.Ticket) bool {
+	if t.Deleted() != f.Deleted {
+		return false
+	}
+	return f.match(t)
+}
+
+// match returns true if the ticket satisfies the filter's fields other than
+// Deleted
+func (f Filter) match(t *ticket.Ticket) bool {
+	if len(f.Status) > 0 {
+		var ok bool
+		for _, s := range f.Status {
+			if t.Status == s {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return false
+		}
+	}
+	if f.Queue != "" && t.Queue != f.Queue {
+		return false
+	}
+	if f.Assignee != "" && t.Assignee != f.Assignee {
+		return false
+	}
+	if f.Reporter != "" && t.Reporter != f.Reporter {
+		return false
+	}
+	if (f.ChannelID != "" && t.ChannelID != f.ChannelID) || (f.ThreadTS != "" && t.ThreadTS != f.ThreadTS) {
+		return false
+	}
+	if f.Issue != "" && t.Issue.Key != f.Issue {
+		return false
+	}
+	for _, tag := range f.Tags {
+		if !t.HasTag(tag) {
+			return false
+		}
+	}
+	if len(f.Priority) > 0 {
+		var ok bool
+		for _, p := range f.Priority {
+			if t.Priority == p {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return false
+		}
+	}
+	if f.Unassigned && t.Assignee != "" {
+		return false
+	}
+	if !within(t.CreatedAt, f.CreatedAfter, f.CreatedBefore) || !within(t.UpdatedAt, f.UpdatedAfter, f.UpdatedBefore) {
+		return false
+	}
+	if f.Text != "" {
+		text := strings.ToLower(f.Text)
+		if !strings.Contains(strings.ToLower(t.Title), text) && !strings.Contains(strings.ToLower(t.Description), text) && !commented(t, text) {
+			return false
+		}
+	}
+	for _, ex := range f.Exclude {
+		if ex.match(t) {
+			return false
+		}
+	}
+	return true
+}
+
+// within reports whether t is at or after from and before to, either of which
+// may be zero to leave the range open
+func within(t, from, to time.Time) bool {
+	return (from.IsZero() || !t.Before(from)) && (to.IsZero() || t.Before(to))
+}
+
+// commented reports whether any of t's comments contain the lower case text
+func commented(t *ticket.Ticket, text string) bool {
+	for _, c := range t.Comments {
+		if strings.Contains(strings.ToLower(c.Text), text) {
+			return true
+		}
+	}
+	return false
+}
+
+// Store persists tickets. Implementations must be safe for concurrent use.
+type Store interface {
+	// CreateTicket saves a new ticket, assigning its ID, timestamps and
+	// first Version
+	CreateTicket(ctx context.Context, t *ticket.Ticket) error
+	// GetTicket returns the ticket with the given ID or ErrNotFound, including
+	// tickets in the trash
+	GetTicket(ctx context.Context, id string) (*ticket.Ticket, error)
+	// DeleteTicket permanently removes a ticket, or returns ErrNotFound.
+	// Tickets are normally moved to the trash by setting DeletedAt instead.
+	DeleteTicket(ctx context.Context, id string) error
+	// UpdateTicket replaces a stored ticket if it is still at t's Version,
+	// returning ErrStale otherwise, and refreshes UpdatedAt and Version
+	UpdateTicket(ctx context.Context, t *ticket.Ticket) error
+	// ListTickets returns the tickets matching the filter in creation order
+	// along with a cursor for the next page, empty on the last page
+	ListTickets(ctx context.Context, f Filter) ([]*ticket.Ticket, string, error)
+	// Transition atomically moves a ticket from one status to another,
+	// returning ErrConflict if it is not currently in from
+	Transition(ctx context.Context, id string, from, to ticket.Status) (*ticket.Ticket, error)
+	// Tx runs fn against a transactional view of the store. If fn returns an
+	// error none of its writes are persisted.
+	Tx(ctx context.Context, fn func(s Store) error) error
+	// Enqueue adds a notification to the outbox, assigning its ID and
+	// CreatedAt
+	Enqueue(ctx context.Context, n *Notification) error
+	// Outbox returns up to limit notifications waiting to be posted, oldest
+	// first, zero for no limit
+	Outbox(ctx context.Context, limit int) ([]*Notification, error)
+	// Sent removes a posted notification from the outbox, or returns
+	// ErrNoNotification
+	Sent(ctx context.Context, id string) error
+	// Failed records an unsuccessful attempt to post a notification, or
+	// returns ErrNoNotification
+	Failed(ctx context.Context, id string, reason string) error
+	// SetQueueChannel records the channel of a queue, replacing any it had
+	SetQueueChannel(ctx context.Context, qc *QueueChannel) error
+	// QueueChannels returns the recorded channel of every queue, ordered by
+	// queue
+	QueueChannels(ctx context.Context) ([]*QueueChannel, error)
+}
+
+// Importer is implemented by stores which create many tickets at once faster
+// than one at a time, such as when loading tickets from another helpdesk
+type Importer interface {
+	// Import creates the tickets in one transaction as CreateTicket would,
+	// keeping the IDs of those which have one
+	Import(ctx context.Context, tickets []*ticket.Ticket) error
+}
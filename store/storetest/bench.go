@@ -0,0 +1,133 @@
+package storetest
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/skybet/go-helpdesk/store"
+	"github.com/skybet/go-helpdesk/ticket"
+)
+
+// BenchFactory returns a new, empty Store for a single benchmark
+type BenchFactory func(b *testing.B) store.Store
+
+// DefaultSizes are the numbers of tickets RunBenchmarks uses when none are
+// given. Drivers backed by a database can pass larger sizes, such as a
+// million, to see how they scale.
+var DefaultSizes = []int{1000, 10000}
+
+// RunBenchmarks measures creating tickets one at a time in one transaction,
+// importing them, with Import if the store is a store.Importer, searching
+// them by text, and reading them all a page at a time, against stores of
+// each size built by f
+func RunBenchmarks(b *testing.B, f BenchFactory, sizes ...int) {
+	if len(sizes) == 0 {
+		sizes = DefaultSizes
+	}
+	for _, n := range sizes {
+		b.Run(fmt.Sprintf("Create/%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				s := f(b)
+				b.StartTimer()
+				create(b, s, n)
+			}
+			b.ReportMetric(float64(n*b.N)/b.Elapsed().Seconds(), "tickets/s")
+		})
+		b.Run(fmt.Sprintf("Import/%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				s := f(b)
+				b.StartTimer()
+				fill(b, s, n)
+			}
+			b.ReportMetric(float64(n*b.N)/b.Elapsed().Seconds(), "tickets/s")
+		})
+
+		s := f(b)
+		fill(b, s, n)
+		b.Run(fmt.Sprintf("Search/%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				// One ticket in a hundred matches
+				ts, _, err := s.ListTickets(context.Background(), store.Filter{Text: "printer", Limit: 50})
+				if err != nil || len(ts) == 0 {
+					b.Fatalf("Expected tickets to match, got %d, %v", len(ts), err)
+				}
+			}
+		})
+		b.Run(fmt.Sprintf("List/%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				f := store.Filter{Limit: 500}
+				for {
+					_, next, err := s.ListTickets(context.Background(), f)
+					if err != nil {
+						b.Fatalf("Unexpected error: %s", err)
+					}
+					if next == "" {
+						break
+					}
+					f.Cursor = next
+				}
+			}
+		})
+	}
+}
+
+// tickets returns n tickets to benchmark with, each with a tag and two
+// comments
+func tickets(n int) []*ticket.Ticket {
+	created := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	ts := make([]*ticket.Ticket, n)
+	for i := range ts {
+		title := "VPN down"
+		if i%100 == 0 {
+			title = "Printer jammed"
+		}
+		at := created.Add(time.Duration(i) * time.Second)
+		ts[i] = &ticket.Ticket{
+			Queue:       fmt.Sprintf("queue-%d", i%10),
+			Title:       title,
+			Status:      ticket.StatusNew,
+			Reporter:    fmt.Sprintf("U%d", i%1000),
+			CreatedAt:   at,
+			Description: "It stopped working after the update this morning",
+			Tags:        []string{fmt.Sprintf("site-%d", i%20)},
+			Comments: []ticket.Comment{
+				{ID: "c1", Author: "U1", Text: "Have you tried restarting it?", CreatedAt: at.Add(time.Minute)},
+				{ID: "c2", Author: fmt.Sprintf("U%d", i%1000), Text: "Yes, it made no difference", CreatedAt: at.Add(2 * time.Minute)},
+			},
+		}
+	}
+	return ts
+}
+
+// create creates n tickets in s one at a time in a single transaction
+func create(b *testing.B, s store.Store, n int) {
+	ts := tickets(n)
+	err := s.Tx(context.Background(), func(tx store.Store) error {
+		for _, t := range ts {
+			if err := tx.CreateTicket(context.Background(), t); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		b.Fatalf("Unexpected error creating tickets: %s", err)
+	}
+}
+
+// fill imports n tickets into s, in a single transaction of CreateTicket
+// calls if it is not a store.Importer
+func fill(b *testing.B, s store.Store, n int) {
+	imp, ok := s.(store.Importer)
+	if !ok {
+		create(b, s, n)
+		return
+	}
+	if err := imp.Import(context.Background(), tickets(n)); err != nil {
+		b.Fatalf("Unexpected error importing tickets: %s", err)
+	}
+}
@@ -0,0 +1,492 @@
+// Package storetest is a conformance suite for store.Store implementations.
+// Third party drivers can verify they behave like the bundled stores with:
+//
+//	func TestConformance(t *testing.T) {
+//		storetest.RunConformance(t, func(t *testing.T) store.Store { return newEmptyStore(t) })
+//	}
+//
+// RunBenchmarks measures them against the same workloads as the bundled
+// stores.
+package storetest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/skybet/go-helpdesk/store"
+	"github.com/skybet/go-helpdesk/ticket"
+)
+
+// Factory returns a new, empty Store for a single test
+type Factory func(t *testing.T) store.Store
+
+// RunConformance runs the full conformance suite against stores built by f
+func RunConformance(t *testing.T, f Factory) {
+	tt := []struct {
+		name string
+		test func(*testing.T, store.Store)
+	}{
+		{"CreateAndGet", testCreateAndGet},
+		{"NotFound", testNotFound},
+		{"Update", testUpdate},
+		{"Versions", testVersions},
+		{"ReturnsCopies", testReturnsCopies},
+		{"Transition", testTransition},
+		{"ConcurrentTransitions", testConcurrentTransitions},
+		{"TxCommit", testTxCommit},
+		{"TxRollback", testTxRollback},
+		{"Outbox", testOutbox},
+		{"Trash", testTrash},
+		{"QueueChannels", testQueueChannels},
+		{"Filters", testFilters},
+		{"Pagination", testPagination},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.test(t, f(t))
+		})
+	}
+}
+
+func mustCreate(t *testing.T, s store.Store, tk *ticket.Ticket) *ticket.Ticket {
+	t.Helper()
+	if err := s.CreateTicket(context.Background(), tk); err != nil {
+		t.Fatalf("Unexpected error creating ticket: %s", err)
+	}
+	return tk
+}
+
+func testCreateAndGet(t *testing.T, s store.Store) {
+	tk := mustCreate(t, s, &ticket.Ticket{Title: "VPN down", Reporter: "U1", Priority: ticket.P2, Tags: []string{"vpn"}})
+	if tk.ID == "" {
+		t.Fatalf("Expected an ID to be assigned")
+	}
+	if tk.CreatedAt.IsZero() || tk.UpdatedAt.IsZero() {
+		t.Errorf("Expected timestamps to be assigned")
+	}
+	if tk.Status != ticket.StatusNew {
+		t.Errorf("Expected a new ticket to default to %s, got %s", ticket.StatusNew, tk.Status)
+	}
+	other := mustCreate(t, s, &ticket.Ticket{Title: "Printer on fire"})
+	if other.ID == tk.ID {
+		t.Fatalf("Expected unique IDs, got %s twice", tk.ID)
+	}
+	got, err := s.GetTicket(context.Background(), tk.ID)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if got.Title != "VPN down" || got.Reporter != "U1" || got.Priority != ticket.P2 || !got.HasTag("vpn") {
+		t.Errorf("Stored ticket does not match: %+v", got)
+	}
+}
+
+func testNotFound(t *testing.T, s store.Store) {
+	if _, err := s.GetTicket(context.Background(), "missing"); err != store.ErrNotFound {
+		t.Errorf("Expected ErrNotFound from GetTicket, got %v", err)
+	}
+	if err := s.UpdateTicket(context.Background(), &ticket.Ticket{ID: "missing"}); err != store.ErrNotFound {
+		t.Errorf("Expected ErrNotFound from UpdateTicket, got %v", err)
+	}
+	if _, err := s.Transition(context.Background(), "missing", ticket.StatusNew, ticket.StatusTriaged); err != store.ErrNotFound {
+		t.Errorf("Expected ErrNotFound from Transition, got %v", err)
+	}
+}
+
+func testUpdate(t *testing.T, s store.Store) {
+	tk := mustCreate(t, s, &ticket.Ticket{Title: "VPN down"})
+	created := tk.CreatedAt
+	tk.Assignee = "U2"
+	tk.Tags = []string{"network"}
+	if err := s.UpdateTicket(context.Background(), tk); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	got, err := s.GetTicket(context.Background(), tk.ID)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if got.Assignee != "U2" || !got.HasTag("network") {
+		t.Errorf("Expected update to be stored: %+v", got)
+	}
+	if !got.CreatedAt.Equal(created) {
+		t.Errorf("Expected CreatedAt to be preserved, %s != %s", got.CreatedAt, created)
+	}
+	if got.UpdatedAt.Before(created) {
+		t.Errorf("Expected UpdatedAt to move forward")
+	}
+}
+
+func testVersions(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	tk := mustCreate(t, s, &ticket.Ticket{Title: "VPN down"})
+	if tk.Version != 1 {
+		t.Errorf("Expected a new ticket to be at version 1, got %d", tk.Version)
+	}
+	stale, _ := s.GetTicket(ctx, tk.ID)
+	tk.Assignee = "U1"
+	if err := s.UpdateTicket(ctx, tk); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if tk.Version != 2 {
+		t.Errorf("Expected the update to move the ticket to version 2, got %d", tk.Version)
+	}
+	stale.Assignee = "U2"
+	if err := s.UpdateTicket(ctx, stale); err != store.ErrStale {
+		t.Errorf("Expected updating an out of date copy to return ErrStale, got %v", err)
+	}
+	err := s.Tx(ctx, func(tx store.Store) error {
+		return tx.UpdateTicket(ctx, stale)
+	})
+	if err != store.ErrStale {
+		t.Errorf("Expected ErrStale from a transaction too, got %v", err)
+	}
+	moved, err := s.Transition(ctx, tk.ID, ticket.StatusNew, ticket.StatusTriaged)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if moved.Version != 3 {
+		t.Errorf("Expected a transition to move the ticket to version 3, got %d", moved.Version)
+	}
+	got, _ := s.GetTicket(ctx, tk.ID)
+	if got.Assignee != "U1" || got.Version != 3 {
+		t.Errorf("Expected the first update and transition to be kept, got %+v", got)
+	}
+}
+
+func testReturnsCopies(t *testing.T, s store.Store) {
+	tk := mustCreate(t, s, &ticket.Ticket{Title: "VPN down", Tags: []string{"vpn"}})
+	tk.Title = "changed"
+	got, _ := s.GetTicket(context.Background(), tk.ID)
+	got.Tags[0] = "changed"
+	again, _ := s.GetTicket(context.Background(), tk.ID)
+	if again.Title != "VPN down" || again.Tags[0] != "vpn" {
+		t.Errorf("Expected the store to be unaffected by changes to returned tickets: %+v", again)
+	}
+}
+
+func testTransition(t *testing.T, s store.Store) {
+	tk := mustCreate(t, s, &ticket.Ticket{Title: "VPN down"})
+	got, err := s.Transition(context.Background(), tk.ID, ticket.StatusNew, ticket.StatusTriaged)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if got.Status != ticket.StatusTriaged {
+		t.Errorf("Expected the transitioned ticket to be returned, got %s", got.Status)
+	}
+	if _, err := s.Transition(context.Background(), tk.ID, ticket.StatusNew, ticket.StatusInProgress); err != store.ErrConflict {
+		t.Errorf("Expected ErrConflict transitioning from a stale status, got %v", err)
+	}
+
+	got, err = s.Transition(context.Background(), tk.ID, ticket.StatusTriaged, ticket.StatusResolved)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if got.ResolvedAt.IsZero() {
+		t.Errorf("Expected the resolution time to be recorded")
+	}
+	got, err = s.Transition(context.Background(), tk.ID, ticket.StatusResolved, ticket.StatusInProgress)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if got.Reopened != 1 {
+		t.Errorf("Expected the ticket to be counted as reopened, got %d", got.Reopened)
+	}
+}
+
+func testConcurrentTransitions(t *testing.T, s store.Store) {
+	tk := mustCreate(t, s, &ticket.Ticket{Title: "VPN down"})
+	const workers = 20
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var won, conflicts int
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := s.Transition(context.Background(), tk.ID, ticket.StatusNew, ticket.StatusTriaged)
+			mu.Lock()
+			defer mu.Unlock()
+			switch err {
+			case nil:
+				won++
+			case store.ErrConflict:
+				conflicts++
+			default:
+				t.Errorf("Unexpected error: %s", err)
+			}
+		}()
+	}
+	wg.Wait()
+	if won != 1 || conflicts != workers-1 {
+		t.Errorf("Expected exactly one transition to win, got %d wins and %d conflicts", won, conflicts)
+	}
+}
+
+func testTxCommit(t *testing.T, s store.Store) {
+	var id string
+	err := s.Tx(context.Background(), func(tx store.Store) error {
+		tk := &ticket.Ticket{Title: "VPN down"}
+		if err := tx.CreateTicket(context.Background(), tk); err != nil {
+			return err
+		}
+		id = tk.ID
+		// Reads inside the transaction see its own writes
+		if _, err := tx.GetTicket(context.Background(), id); err != nil {
+			return err
+		}
+		_, err := tx.Transition(context.Background(), id, ticket.StatusNew, ticket.StatusTriaged)
+		return err
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	got, err := s.GetTicket(context.Background(), id)
+	if err != nil {
+		t.Fatalf("Expected committed ticket to exist: %s", err)
+	}
+	if got.Status != ticket.StatusTriaged {
+		t.Errorf("Expected committed transition, got %s", got.Status)
+	}
+}
+
+func testTxRollback(t *testing.T, s store.Store) {
+	existing := mustCreate(t, s, &ticket.Ticket{Title: "VPN down"})
+	var id string
+	boom := errors.New("boom")
+	err := s.Tx(context.Background(), func(tx store.Store) error {
+		tk := &ticket.Ticket{Title: "Printer on fire"}
+		if err := tx.CreateTicket(context.Background(), tk); err != nil {
+			return err
+		}
+		id = tk.ID
+		existing.Assignee = "U2"
+		if err := tx.UpdateTicket(context.Background(), existing); err != nil {
+			return err
+		}
+		return boom
+	})
+	if err != boom {
+		t.Fatalf("Expected the transaction error to be returned, got %v", err)
+	}
+	if _, err := s.GetTicket(context.Background(), id); err != store.ErrNotFound {
+		t.Errorf("Expected rolled back ticket not to exist, got %v", err)
+	}
+	got, _ := s.GetTicket(context.Background(), existing.ID)
+	if got.Assignee != "" {
+		t.Errorf("Expected rolled back update not to be stored, got assignee %s", got.Assignee)
+	}
+}
+
+func testFilters(t *testing.T, s store.Store) {
+	mustCreate(t, s, &ticket.Ticket{Title: "VPN down", Queue: "it", Reporter: "U1", Assignee: "U9", Priority: ticket.P2, Tags: []string{"vpn", "network"}})
+	mustCreate(t, s, &ticket.Ticket{Title: "Printer on fire", Queue: "it", Reporter: "U2", Status: ticket.StatusInProgress, Priority: ticket.P1, ChannelID: "C1", ThreadTS: "1.1", Comments: []ticket.Comment{{ID: "1.2", Author: "U9", Text: "Toner everywhere"}}})
+	mustCreate(t, s, &ticket.Ticket{Title: "Payroll", Description: "Where is my VPN allowance?", Queue: "hr", Reporter: "U1", Tags: []string{"vpn"}})
+	mustCreate(t, s, &ticket.Ticket{Title: "Old laptop", Queue: "it", Reporter: "U3", Status: ticket.StatusClosed, Issue: ticket.Issue{Key: "HD-7"}})
+
+	tt := []struct {
+		name   string
+		filter store.Filter
+		titles []string
+	}{
+		{"No filter", store.Filter{}, []string{"VPN down", "Printer on fire", "Payroll", "Old laptop"}},
+		{"Status", store.Filter{Status: []ticket.Status{ticket.StatusNew, ticket.StatusInProgress}}, []string{"VPN down", "Printer on fire", "Payroll"}},
+		{"Queue", store.Filter{Queue: "hr"}, []string{"Payroll"}},
+		{"Assignee", store.Filter{Assignee: "U9"}, []string{"VPN down"}},
+		{"Reporter", store.Filter{Reporter: "U1"}, []string{"VPN down", "Payroll"}},
+		{"Thread", store.Filter{ChannelID: "C1", ThreadTS: "1.1"}, []string{"Printer on fire"}},
+		{"Issue", store.Filter{Issue: "HD-7"}, []string{"Old laptop"}},
+		{"Tags", store.Filter{Tags: []string{"vpn", "network"}}, []string{"VPN down"}},
+		{"Text", store.Filter{Text: "vpn"}, []string{"VPN down", "Payroll"}},
+		{"Comments", store.Filter{Text: "toner"}, []string{"Printer on fire"}},
+		{"Priority", store.Filter{Priority: []ticket.Priority{ticket.P1, ticket.P2}}, []string{"VPN down", "Printer on fire"}},
+		{"Unassigned", store.Filter{Unassigned: true, Queue: "it"}, []string{"Printer on fire", "Old laptop"}},
+		{"Created", store.Filter{CreatedAfter: time.Now().Add(time.Hour)}, nil},
+		{"Updated", store.Filter{UpdatedBefore: time.Now().Add(time.Hour), Queue: "hr"}, []string{"Payroll"}},
+		{"Exclude", store.Filter{Queue: "it", Exclude: []store.Filter{{Assignee: "U9"}, {Status: []ticket.Status{ticket.StatusClosed}}}}, []string{"Printer on fire"}},
+		{"Combined", store.Filter{Queue: "it", Status: []ticket.Status{ticket.StatusNew}}, []string{"VPN down"}},
+		{"No match", store.Filter{Queue: "legal"}, nil},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			got, next, err := s.ListTickets(context.Background(), tc.filter)
+			if err != nil {
+				t.Fatalf("Unexpected error: %s", err)
+			}
+			if next != "" {
+				t.Errorf("Expected no next cursor without a limit, got %q", next)
+			}
+			if titles := titlesOf(got); fmt.Sprint(titles) != fmt.Sprint(tc.titles) {
+				t.Errorf("Expected %v, got %v", tc.titles, titles)
+			}
+		})
+	}
+}
+
+func testPagination(t *testing.T, s store.Store) {
+	var want []string
+	for i := 0; i < 7; i++ {
+		title := fmt.Sprintf("ticket %d", i)
+		mustCreate(t, s, &ticket.Ticket{Title: title, Queue: "it"})
+		want = append(want, title)
+	}
+	// A non matching ticket in the middle of the results must not affect paging
+	mustCreate(t, s, &ticket.Ticket{Title: "elsewhere", Queue: "hr"})
+	mustCreate(t, s, &ticket.Ticket{Title: "ticket 7", Queue: "it"})
+	want = append(want, "ticket 7")
+
+	var got []string
+	f := store.Filter{Queue: "it", Limit: 3}
+	for pages := 0; ; pages++ {
+		if pages > len(want) {
+			t.Fatalf("Pagination did not terminate")
+		}
+		page, next, err := s.ListTickets(context.Background(), f)
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		if len(page) > f.Limit {
+			t.Fatalf("Expected at most %d tickets, got %d", f.Limit, len(page))
+		}
+		got = append(got, titlesOf(page)...)
+		if next == "" {
+			break
+		}
+		f.Cursor = next
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("Expected pages to cover every ticket once in creation order: %v, got %v", want, got)
+	}
+
+	// An exact multiple of the limit still ends with an empty cursor
+	page, next, err := s.ListTickets(context.Background(), store.Filter{Queue: "hr", Limit: 1})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if len(page) != 1 || next != "" {
+		t.Errorf("Expected a single final page, got %d tickets and cursor %q", len(page), next)
+	}
+}
+
+func titlesOf(tickets []*ticket.Ticket) []string {
+	var titles []string
+	for _, tk := range tickets {
+		titles = append(titles, tk.Title)
+	}
+	return titles
+}
+
+func testOutbox(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	tk := mustCreate(t, s, &ticket.Ticket{Title: "VPN down"})
+	boom := errors.New("boom")
+	err := s.Tx(ctx, func(tx store.Store) error {
+		tk.Assignee = "U1"
+		if err := tx.UpdateTicket(ctx, tk); err != nil {
+			return err
+		}
+		if err := tx.Enqueue(ctx, &store.Notification{TicketID: tk.ID, ChannelID: "C1", Text: "assigned"}); err != nil {
+			return err
+		}
+		return boom
+	})
+	if err != boom {
+		t.Fatalf("Expected the transaction to fail with boom, got %v", err)
+	}
+	if pending, err := s.Outbox(ctx, 0); err != nil || len(pending) != 0 {
+		t.Fatalf("Expected rolled back notifications to be discarded, got %v %v", pending, err)
+	}
+
+	for _, text := range []string{"first", "second"} {
+		err := s.Tx(ctx, func(tx store.Store) error {
+			return tx.Enqueue(ctx, &store.Notification{TicketID: tk.ID, ChannelID: "C1", ThreadTS: "1.1", Text: text})
+		})
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+	}
+	pending, err := s.Outbox(ctx, 1)
+	if err != nil || len(pending) != 1 || pending[0].Text != "first" || pending[0].ID == "" || pending[0].CreatedAt.IsZero() {
+		t.Fatalf("Expected the oldest notification, got %v %v", pending, err)
+	}
+	if err := s.Failed(ctx, pending[0].ID, "rate_limited"); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	pending, _ = s.Outbox(ctx, 0)
+	if len(pending) != 2 || pending[0].Attempts != 1 || pending[0].LastError != "rate_limited" {
+		t.Fatalf("Expected the failed attempt to be recorded, got %+v", pending)
+	}
+	if err := s.Sent(ctx, pending[0].ID); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if err := s.Sent(ctx, pending[0].ID); err != store.ErrNoNotification {
+		t.Errorf("Expected ErrNoNotification sending twice, got %v", err)
+	}
+	if err := s.Failed(ctx, "missing", "boom"); err != store.ErrNoNotification {
+		t.Errorf("Expected ErrNoNotification for a missing notification, got %v", err)
+	}
+	pending, _ = s.Outbox(ctx, 0)
+	if len(pending) != 1 || pending[0].Text != "second" {
+		t.Errorf("Expected only the second notification to be left, got %+v", pending)
+	}
+}
+
+func testTrash(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	kept := mustCreate(t, s, &ticket.Ticket{Title: "VPN down"})
+	trashed := mustCreate(t, s, &ticket.Ticket{Title: "Printer on fire"})
+	trashed.DeletedAt, trashed.DeletedBy = time.Now(), "U1"
+	if err := s.UpdateTicket(ctx, trashed); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if got, _, _ := s.ListTickets(ctx, store.Filter{}); len(got) != 1 || got[0].ID != kept.ID {
+		t.Errorf("Expected tickets in the trash to be left out, got %v", got)
+	}
+	if got, _, _ := s.ListTickets(ctx, store.Filter{Deleted: true}); len(got) != 1 || got[0].ID != trashed.ID {
+		t.Errorf("Expected only the trash, got %v", got)
+	}
+	if got, err := s.GetTicket(ctx, trashed.ID); err != nil || !got.Deleted() {
+		t.Errorf("Expected tickets in the trash to be returned by ID, got %+v %v", got, err)
+	}
+	if err := s.DeleteTicket(ctx, trashed.ID); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if _, err := s.GetTicket(ctx, trashed.ID); err != store.ErrNotFound {
+		t.Errorf("Expected the ticket to be gone, got %v", err)
+	}
+	if err := s.DeleteTicket(ctx, trashed.ID); err != store.ErrNotFound {
+		t.Errorf("Expected ErrNotFound deleting twice, got %v", err)
+	}
+	err := s.Tx(ctx, func(tx store.Store) error {
+		if err := tx.DeleteTicket(ctx, kept.ID); err != nil {
+			return err
+		}
+		return errors.New("boom")
+	})
+	if _, getErr := s.GetTicket(ctx, kept.ID); err == nil || getErr != nil {
+		t.Errorf("Expected a rolled back delete to keep the ticket, got %v", getErr)
+	}
+}
+
+func testQueueChannels(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	if qcs, err := s.QueueChannels(ctx); err != nil || len(qcs) != 0 {
+		t.Fatalf("Expected no queue channels, got %v %v", qcs, err)
+	}
+	s.SetQueueChannel(ctx, &store.QueueChannel{Queue: "payments", ChannelID: "C2"})
+	s.SetQueueChannel(ctx, &store.QueueChannel{Queue: "it", ChannelID: "C1"})
+	boom := errors.New("boom")
+	s.Tx(ctx, func(tx store.Store) error {
+		tx.SetQueueChannel(ctx, &store.QueueChannel{Queue: "hr", ChannelID: "C3"})
+		return boom
+	})
+	if err := s.SetQueueChannel(ctx, &store.QueueChannel{Queue: "it", ChannelID: "C4", DashboardTS: "1.1"}); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	qcs, err := s.QueueChannels(ctx)
+	if err != nil || len(qcs) != 2 || qcs[0].Queue != "it" || qcs[0].ChannelID != "C4" || qcs[0].DashboardTS != "1.1" || qcs[1].ChannelID != "C2" {
+		t.Errorf("Expected the latest channel of each queue ordered by queue, got %+v %v", qcs, err)
+	}
+}
@@ -0,0 +1,120 @@
+// Package threads replays the replies in a ticket's Slack thread into the
+// ticket's comments, so that the record is complete after the bot has missed
+// events or before Slack's history is trimmed
+package threads
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nlopes/slack"
+
+	"github.com/skybet/go-helpdesk/server"
+	"github.com/skybet/go-helpdesk/store"
+	"github.com/skybet/go-helpdesk/ticket"
+)
+
+// Replies is the part of the Slack API used to read a thread
+type Replies interface {
+	GetThreadReplies(ctx context.Context, channelID, threadTS string) ([]slack.Message, error)
+}
+
+// Time returns the time of a Slack message timestamp, def if it is not one
+func Time(ts string, def time.Time) time.Time {
+	f, err := strconv.ParseFloat(ts, 64)
+	if err != nil || f <= 0 {
+		return def
+	}
+	sec := int64(f)
+	return time.Unix(sec, int64((f-float64(sec))*1e9)).UTC()
+}
+
+// Replay adds the replies in a ticket's thread which are missing from its
+// comments, and the edits of those which are not, returning how many
+// comments changed. Replies from bots are not comments.
+func Replay(ctx context.Context, s store.Store, r Replies, id string) (int, error) {
+	t, err := s.GetTicket(ctx, id)
+	if err != nil {
+		return 0, err
+	}
+	if t.ChannelID == "" || t.ThreadTS == "" {
+		return 0, fmt.Errorf("ticket %s has no thread", id)
+	}
+	msgs, err := r.GetThreadReplies(ctx, t.ChannelID, t.ThreadTS)
+	if err != nil {
+		return 0, fmt.Errorf("error reading the thread of ticket %s: %s", id, err)
+	}
+	var changed int
+	err = s.Tx(ctx, func(tx store.Store) error {
+		changed = 0
+		t, err := tx.GetTicket(ctx, id)
+		if err != nil {
+			return err
+		}
+		for _, m := range msgs {
+			if m.BotID != "" || m.SubType == "bot_message" || m.User == "" {
+				continue
+			}
+			if t.AddComment(ticket.Comment{ID: m.Timestamp, Author: m.User, Text: m.Text, CreatedAt: Time(m.Timestamp, time.Now())}) {
+				changed++
+			}
+		}
+		if changed == 0 {
+			return nil
+		}
+		return tx.UpdateTicket(ctx, t)
+	})
+	return changed, err
+}
+
+// API replays tickets' threads for admins. Every request must carry the
+// token as a bearer token.
+type API struct {
+	store   store.Store
+	replies Replies
+	handler http.Handler
+}
+
+// NewAPI returns an API replaying the threads of the tickets in s. Mount it
+// with http.StripPrefix so that its routes, such as /tickets/<id>/replay, are
+// at the root.
+func NewAPI(s store.Store, r Replies, token string) *API {
+	a := &API{store: s, replies: r}
+	a.handler = server.RequireToken(token, http.HandlerFunc(a.route))
+	return a
+}
+
+// ServeHTTP satisfies http.Handler. POST /tickets/<id>/replay replays the
+// ticket's thread and returns how many comments changed.
+func (a *API) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	a.handler.ServeHTTP(w, r)
+}
+
+// route serves the requests which carried the token
+func (a *API) route(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(parts) != 3 || parts[0] != "tickets" || parts[2] != "replay" {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	n, err := Replay(r.Context(), a.store, a.replies, parts[1])
+	if err == store.ErrNotFound {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]int{"changed": n})
+}
@@ -0,0 +1,80 @@
+package threads
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nlopes/slack"
+
+	"github.com/skybet/go-helpdesk/store"
+	"github.com/skybet/go-helpdesk/ticket"
+)
+
+type thread []slack.Message
+
+func (th thread) GetThreadReplies(ctx context.Context, channelID, threadTS string) ([]slack.Message, error) {
+	if channelID != "C1" || threadTS != "1500000000.000100" {
+		return nil, nil
+	}
+	return th, nil
+}
+
+func reply(user, bot, ts, text string) slack.Message {
+	return slack.Message{Msg: slack.Msg{User: user, BotID: bot, Timestamp: ts, Text: text}}
+}
+
+func TestReplay(t *testing.T) {
+	s := store.NewMemory()
+	s.CreateTicket(context.Background(), &ticket.Ticket{ID: "1", Reporter: "U1", ChannelID: "C1", ThreadTS: "1500000000.000100", Comments: []ticket.Comment{
+		{ID: "1500000060.000200", Author: "U2", Text: "Looking", CreatedAt: time.Unix(1500000060, 0)},
+	}})
+	th := thread{
+		reply("U2", "", "1500000060.000200", "Looking now"),
+		reply("", "B1", "1500000070.000300", "Ticket #1 assigned"),
+		reply("U1", "", "1500000120.000400", "Thanks"),
+	}
+
+	n, err := Replay(context.Background(), s, th, "1")
+	if err != nil || n != 2 {
+		t.Fatalf("Expected the edit and the missing reply to change, got %d (%v)", n, err)
+	}
+	tk, _ := s.GetTicket(context.Background(), "1")
+	if len(tk.Comments) != 2 || tk.Comments[0].Text != "Looking now" || tk.Comments[1].Author != "U1" || tk.Comments[1].CreatedAt.Unix() != 1500000120 {
+		t.Errorf("Expected the thread's replies as comments, got %+v", tk.Comments)
+	}
+	if n, err := Replay(context.Background(), s, th, "1"); err != nil || n != 0 {
+		t.Errorf("Expected replaying again to change nothing, got %d (%v)", n, err)
+	}
+	if _, err := Replay(context.Background(), s, th, "2"); err != store.ErrNotFound {
+		t.Errorf("Expected unknown tickets not to be found, got %v", err)
+	}
+}
+
+func TestAPI(t *testing.T) {
+	s := store.NewMemory()
+	s.CreateTicket(context.Background(), &ticket.Ticket{ID: "1", ChannelID: "C1", ThreadTS: "1500000000.000100"})
+	api := NewAPI(s, thread{reply("U2", "", "1500000060.000200", "Looking")}, "secret")
+
+	for _, tc := range []struct {
+		method, path, token string
+		code                int
+		body                string
+	}{
+		{http.MethodPost, "/tickets/1/replay", "nope", http.StatusUnauthorized, ""},
+		{http.MethodGet, "/tickets/1/replay", "secret", http.StatusMethodNotAllowed, ""},
+		{http.MethodPost, "/tickets/2/replay", "secret", http.StatusNotFound, ""},
+		{http.MethodPost, "/tickets/1/replay", "secret", http.StatusOK, `{"changed":1}`},
+	} {
+		req := httptest.NewRequest(tc.method, tc.path, nil)
+		req.Header.Set("Authorization", "Bearer "+tc.token)
+		w := httptest.NewRecorder()
+		api.ServeHTTP(w, req)
+		if w.Code != tc.code || (tc.body != "" && strings.TrimSpace(w.Body.String()) != tc.body) {
+			t.Errorf("%s %s: expected %d %s, got %d %s", tc.method, tc.path, tc.code, tc.body, w.Code, w.Body.String())
+		}
+	}
+}
@@ -0,0 +1,430 @@
+// Package ticket contains the core helpdesk ticket types
+package ticket
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Status is the lifecycle state of a ticket
+type Status string
+
+// The statuses a ticket can be in
+const (
+	StatusNew        Status = "new"
+	StatusTriaged    Status = "triaged"
+	StatusInProgress Status = "in_progress"
+	StatusWaiting    Status = "waiting"
+	StatusResolved   Status = "resolved"
+	StatusClosed     Status = "closed"
+)
+
+// Statuses are the statuses in lifecycle order
+var Statuses = []Status{StatusNew, StatusTriaged, StatusInProgress, StatusWaiting, StatusResolved, StatusClosed}
+
+// ParseStatus parses a status such as in_progress, ignoring case and allowing
+// a dash or space in place of the underscore
+func ParseStatus(s string) (Status, error) {
+	name := Status(strings.NewReplacer("-", "_", " ", "_").Replace(strings.ToLower(strings.TrimSpace(s))))
+	for _, st := range Statuses {
+		if st == name {
+			return st, nil
+		}
+	}
+	return "", fmt.Errorf("invalid status: %q", s)
+}
+
+// Open returns true if the ticket still needs work
+func (s Status) Open() bool {
+	return s != StatusResolved && s != StatusClosed
+}
+
+// Priority is the urgency of a ticket, P1 being the most urgent
+type Priority int
+
+// The priorities a ticket can have
+const (
+	P1 Priority = iota + 1
+	P2
+	P3
+	P4
+)
+
+// String returns the priority in the form P1
+func (p Priority) String() string {
+	return fmt.Sprintf("P%d", int(p))
+}
+
+// ParsePriority parses a priority in the form P1 or 1
+func ParsePriority(s string) (Priority, error) {
+	var p int
+	if _, err := fmt.Sscanf(strings.TrimPrefix(strings.ToUpper(s), "P"), "%d", &p); err != nil || p < int(P1) || p > int(P4) {
+		return 0, fmt.Errorf("invalid priority: %q", s)
+	}
+	return Priority(p), nil
+}
+
+// Ticket is a single request for help
+type Ticket struct {
+	ID          string
+	TeamID      string
+	Queue       string
+	Title       string
+	Description string
+	Status      Status
+	Priority    Priority
+	// Reporter and Assignee are Slack user IDs
+	Reporter string
+	Assignee string
+	// Email is the address of the requester of a ticket raised by email, who
+	// is emailed the replies in its thread, and EmailThread is the
+	// Message-ID of the email they sent
+	Email       string
+	EmailThread string
+	Tags        []string
+	// ChannelID and ThreadTS locate the ticket's Slack thread
+	ChannelID string
+	ThreadTS  string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	// Version is incremented by the store on every write, updates of a ticket
+	// which has been written since it was read fail
+	Version int
+	// FirstResponseAt is when an agent first replied to the reporter
+	FirstResponseAt time.Time
+	// AcknowledgedAt is when AcknowledgedBy took responsibility for the
+	// ticket, e.g. from an escalation
+	AcknowledgedAt time.Time
+	AcknowledgedBy string
+	// Notices are the escalations of a critical ticket sent to people
+	// directly, each of which its recipient has to acknowledge
+	Notices []Notice
+	// ResolvedAt is when the ticket was last resolved
+	ResolvedAt time.Time
+	// Reopened counts how many times the ticket was reopened after being
+	// resolved or closed
+	Reopened int
+	// CSAT is the reporter's satisfaction rating from 1 to 5, zero if they
+	// have not rated the ticket
+	CSAT int
+	// Shares are the queues working on the ticket together when it spans
+	// teams, including its own Queue
+	Shares []Share
+	// Comments are the replies in the ticket's thread, oldest first, kept so
+	// that the conversation outlives Slack's history
+	Comments []Comment
+	// Attachments are the files shared when the ticket was raised and in its
+	// thread since
+	Attachments []Attachment
+	// Reactions are the appreciation reactions, such as :pray:, added to
+	// messages in the ticket's thread
+	Reactions []Reaction
+	// Issue is the issue the ticket is mirrored to in an issue tracker, such
+	// as Jira, with an empty Key if it is not
+	Issue Issue
+	// Request is what the ticket asked for if it was raised from the service
+	// catalog, with an empty Item if it was not
+	Request Request
+	// DeletedAt is when DeletedBy moved the ticket to the trash, it is purged
+	// once it has been there for the retention period
+	DeletedAt time.Time
+	DeletedBy string
+}
+
+// Share is one queue's part of a ticket which spans several queues
+type Share struct {
+	Queue string
+	// ChannelID and TS locate the copy of the ticket's card in the queue's
+	// channel
+	ChannelID string
+	TS        string
+	// DoneBy is who marked the queue's part done at DoneAt
+	DoneBy string
+	DoneAt time.Time
+}
+
+// Attachment is a file shared in Slack about a ticket
+type Attachment struct {
+	// ID is the Slack file ID
+	ID       string
+	Name     string
+	Mimetype string
+	Size     int
+	// URL downloads the file with the bot token and Permalink opens it in
+	// Slack
+	URL       string
+	Permalink string
+	// Snippet is the start of a text file, Truncated if there was more
+	Snippet   string
+	Truncated bool
+}
+
+// Reaction is an emoji reaction By a user to a message in a ticket's thread
+// written For another user, usually the agent who replied
+type Reaction struct {
+	Emoji string
+	By    string
+	For   string
+	// TS is the timestamp of the message reacted to
+	TS string
+	At time.Time
+}
+
+// Issue is a ticket's copy in an issue tracker
+type Issue struct {
+	// Key identifies the issue, such as HD-12, and URL opens it
+	Key string
+	URL string
+	// Status is the ticket status the issue was last moved to match
+	Status Status
+	// Synced are the IDs of the comments copied between the ticket and the
+	// issue, the ticket's own and the issue's prefixed with the tracker's
+	// name, so that neither is copied twice
+	Synced []string
+}
+
+// Request is a ticket raised from an Item of the service catalog, with the
+// reporter's Answers to its form, the Approvals it has been given so far and
+// the Checklist agents fulfil it with. Grants are the changes to group
+// memberships in other systems made once it is approved.
+type Request struct {
+	Item      string
+	Answers   []Answer
+	Approvals []Approval
+	Checklist []Check
+	Grants    []Grant
+}
+
+// Answer is the Value given for a field of a catalog item's form
+type Answer struct {
+	Field string
+	Label string
+	Value string
+}
+
+// Approval is a step of a catalog request's approval chain approved, or
+// rejected, By a user At a time
+type Approval struct {
+	Step     string
+	By       string
+	At       time.Time
+	Rejected bool
+}
+
+// Check is an item of a catalog request's checklist, DoneBy a user at DoneAt
+// once it is done
+type Check struct {
+	Text   string
+	DoneBy string
+	DoneAt time.Time
+}
+
+// Done reports whether the item has been done
+func (c Check) Done() bool {
+	return !c.DoneAt.IsZero()
+}
+
+// Grant adds Member to Group in System, such as a GitHub team, or removes
+// them if it is a Revoke
+type Grant struct {
+	System string
+	Group  string
+	Member string
+	Revoke bool
+	// DoneAt is when the change was made, Error why the last attempt failed
+	DoneAt time.Time
+	Error  string
+	// RolledBackBy undid the change at RolledBackAt
+	RolledBackBy string
+	RolledBackAt time.Time
+}
+
+// Done reports whether the change has been made
+func (g Grant) Done() bool {
+	return !g.DoneAt.IsZero()
+}
+
+// RolledBack reports whether the change has been undone
+func (g Grant) RolledBack() bool {
+	return !g.RolledBackAt.IsZero()
+}
+
+// Rejected reports whether any step of the approval chain rejected the
+// request
+func (r Request) Rejected() bool {
+	for _, a := range r.Approvals {
+		if a.Rejected {
+			return true
+		}
+	}
+	return false
+}
+
+// ChecklistDone reports whether every item of the checklist has been done
+func (r Request) ChecklistDone() bool {
+	for _, c := range r.Checklist {
+		if !c.Done() {
+			return false
+		}
+	}
+	return true
+}
+
+// Fulfilled reports whether every item of the checklist has been done and
+// every grant made
+func (r Request) Fulfilled() bool {
+	if !r.ChecklistDone() {
+		return false
+	}
+	for _, g := range r.Grants {
+		if !g.Done() {
+			return false
+		}
+	}
+	return true
+}
+
+// Notice is an escalation sent To a user at SentAt as Level of the ticket's
+// escalation chain
+type Notice struct {
+	To     string
+	Level  string
+	SentAt time.Time
+	// AckedAt is when the recipient pressed Acknowledge
+	AckedAt time.Time
+}
+
+// Acked reports whether the recipient has acknowledged the notice
+func (n Notice) Acked() bool {
+	return !n.AckedAt.IsZero()
+}
+
+// Done reports whether the queue has finished its part
+func (s Share) Done() bool {
+	return !s.DoneAt.IsZero()
+}
+
+// Copy returns a deep copy of the ticket
+func (t *Ticket) Copy() *Ticket {
+	c := *t
+	if t.Tags != nil {
+		c.Tags = append([]string(nil), t.Tags...)
+	}
+	if t.Shares != nil {
+		c.Shares = append([]Share(nil), t.Shares...)
+	}
+	if t.Reactions != nil {
+		c.Reactions = append([]Reaction(nil), t.Reactions...)
+	}
+	if t.Comments != nil {
+		c.Comments = append([]Comment(nil), t.Comments...)
+	}
+	if t.Attachments != nil {
+		c.Attachments = append([]Attachment(nil), t.Attachments...)
+	}
+	if t.Notices != nil {
+		c.Notices = append([]Notice(nil), t.Notices...)
+	}
+	if t.Issue.Synced != nil {
+		c.Issue.Synced = append([]string(nil), t.Issue.Synced...)
+	}
+	if t.Request.Answers != nil {
+		c.Request.Answers = append([]Answer(nil), t.Request.Answers...)
+	}
+	if t.Request.Approvals != nil {
+		c.Request.Approvals = append([]Approval(nil), t.Request.Approvals...)
+	}
+	if t.Request.Checklist != nil {
+		c.Request.Checklist = append([]Check(nil), t.Request.Checklist...)
+	}
+	if t.Request.Grants != nil {
+		c.Request.Grants = append([]Grant(nil), t.Request.Grants...)
+	}
+	return &c
+}
+
+// Deleted reports whether the ticket is in the trash
+func (t *Ticket) Deleted() bool {
+	return !t.DeletedAt.IsZero()
+}
+
+// Ref identifies this version of the ticket, such as in the value of a button
+// on its card, so that acting on an out of date card can be detected
+func (t *Ticket) Ref() string {
+	return fmt.Sprintf("%s@%d", t.ID, t.Version)
+}
+
+// ParseRef splits a Ref into the ticket's ID and version. The version is zero
+// if ref is a plain ID.
+func ParseRef(ref string) (id string, version int) {
+	i := strings.LastIndex(ref, "@")
+	if i < 0 {
+		return ref, 0
+	}
+	v, err := strconv.Atoi(ref[i+1:])
+	if err != nil {
+		return ref, 0
+	}
+	return ref[:i], v
+}
+
+// SetStatus moves the ticket to s at now, keeping track of when it was
+// resolved and how often it has been reopened
+func (t *Ticket) SetStatus(s Status, now time.Time) {
+	if s == StatusResolved && t.Status != StatusResolved {
+		t.ResolvedAt = now
+	}
+	if s.Open() && !t.Status.Open() && t.Status != "" {
+		t.Reopened++
+	}
+	t.Status = s
+	t.UpdatedAt = now
+}
+
+// HasTag returns true if the ticket is tagged with tag
+func (t *Ticket) HasTag(tag string) bool {
+	for _, tt := range t.Tags {
+		if tt == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// Comment is a note added to a ticket. Internal comments are only for agents
+// and must never be shown to the reporter.
+type Comment struct {
+	// ID is the timestamp of the reply in the ticket's thread
+	ID        string
+	Author    string
+	Text      string
+	Internal  bool
+	CreatedAt time.Time
+}
+
+// AddComment adds c to the ticket's comments in time order. A comment with
+// the same ID is replaced, so that edits and replays of the thread are not
+// added twice. It reports whether the comments changed.
+func (t *Ticket) AddComment(c Comment) bool {
+	for i, e := range t.Comments {
+		if e.ID != c.ID {
+			continue
+		}
+		if e.Text == c.Text && e.Author == c.Author && e.Internal == c.Internal {
+			return false
+		}
+		c.CreatedAt = e.CreatedAt
+		t.Comments[i] = c
+		return true
+	}
+	i := len(t.Comments)
+	for i > 0 && t.Comments[i-1].CreatedAt.After(c.CreatedAt) {
+		i--
+	}
+	t.Comments = append(t.Comments, Comment{})
+	copy(t.Comments[i+1:], t.Comments[i:])
+	t.Comments[i] = c
+	return true
+}
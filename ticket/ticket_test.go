@@ -0,0 +1,122 @@
+package ticket
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParsePriority(t *testing.T) {
+	tt := []struct {
+		in  string
+		out Priority
+		err bool
+	}{
+		{"P1", P1, false},
+		{"p3", P3, false},
+		{"4", P4, false},
+		{"P0", 0, true},
+		{"P5", 0, true},
+		{"urgent", 0, true},
+	}
+	for _, tc := range tt {
+		p, err := ParsePriority(tc.in)
+		if (err != nil) != tc.err {
+			t.Errorf("%s: unexpected error state: %v", tc.in, err)
+		}
+		if p != tc.out {
+			t.Errorf("%s: expected %s, got %s", tc.in, tc.out, p)
+		}
+	}
+}
+
+func TestParseStatus(t *testing.T) {
+	for in, out := range map[string]Status{"new": StatusNew, "In-Progress": StatusInProgress, "in progress": StatusInProgress, "closed": StatusClosed} {
+		if s, err := ParseStatus(in); err != nil || s != out {
+			t.Errorf("%s: expected %s, got %s %v", in, out, s, err)
+		}
+	}
+	if _, err := ParseStatus("done"); err == nil {
+		t.Errorf("Expected an error for an unknown status")
+	}
+}
+
+func TestSetStatus(t *testing.T) {
+	now := time.Now()
+	tk := &Ticket{Status: StatusNew}
+	tk.SetStatus(StatusInProgress, now)
+	if !tk.ResolvedAt.IsZero() || tk.Reopened != 0 || !tk.UpdatedAt.Equal(now) {
+		t.Errorf("Unexpected ticket after starting work: %+v", tk)
+	}
+	tk.SetStatus(StatusResolved, now.Add(time.Hour))
+	if !tk.ResolvedAt.Equal(now.Add(time.Hour)) {
+		t.Errorf("Expected the resolution time to be recorded, got %s", tk.ResolvedAt)
+	}
+	tk.SetStatus(StatusClosed, now.Add(2*time.Hour))
+	tk.SetStatus(StatusInProgress, now.Add(3*time.Hour))
+	if tk.Reopened != 1 {
+		t.Errorf("Expected the ticket to have been reopened once, got %d", tk.Reopened)
+	}
+}
+
+func TestRef(t *testing.T) {
+	tk := &Ticket{ID: "12", Version: 3}
+	if tk.Ref() != "12@3" {
+		t.Errorf("Expected 12@3, got %s", tk.Ref())
+	}
+	for ref, want := range map[string]struct {
+		id      string
+		version int
+	}{
+		"12@3":   {"12", 3},
+		"12":     {"12", 0},
+		"12@new": {"12@new", 0},
+	} {
+		if id, v := ParseRef(ref); id != want.id || v != want.version {
+			t.Errorf("%s: expected %s at %d, got %s at %d", ref, want.id, want.version, id, v)
+		}
+	}
+}
+
+func TestAddComment(t *testing.T) {
+	now := time.Now()
+	tk := &Ticket{}
+	tk.AddComment(Comment{ID: "2", Author: "U2", Text: "Have you tried turning it off?", CreatedAt: now.Add(2 * time.Minute)})
+	tk.AddComment(Comment{ID: "1", Author: "U1", Text: "Still broken", CreatedAt: now.Add(time.Minute)})
+	if len(tk.Comments) != 2 || tk.Comments[0].ID != "1" || tk.Comments[1].ID != "2" {
+		t.Fatalf("Expected the comments in time order, got %+v", tk.Comments)
+	}
+	if tk.AddComment(Comment{ID: "1", Author: "U1", Text: "Still broken", CreatedAt: now.Add(time.Minute)}) {
+		t.Errorf("Expected a repeated comment not to change the ticket")
+	}
+	if !tk.AddComment(Comment{ID: "2", Author: "U2", Text: "Have you tried turning it off and on?", CreatedAt: now.Add(time.Hour)}) {
+		t.Fatalf("Expected an edited comment to change the ticket")
+	}
+	if len(tk.Comments) != 2 || tk.Comments[1].Text != "Have you tried turning it off and on?" || !tk.Comments[1].CreatedAt.Equal(now.Add(2*time.Minute)) {
+		t.Errorf("Expected the edit to replace the text and keep the time, got %+v", tk.Comments)
+	}
+}
+
+func TestRequest(t *testing.T) {
+	tk := &Ticket{Request: Request{Item: "laptop", Approvals: []Approval{{Step: "manager", By: "U1"}}, Checklist: []Check{{Text: "Order it"}, {Text: "Image it"}}}}
+	if tk.Request.Rejected() || tk.Request.Fulfilled() {
+		t.Errorf("Expected an approved request still to be fulfilled, got %+v", tk.Request)
+	}
+	c := tk.Copy()
+	c.Request.Checklist[0].DoneAt, c.Request.Checklist[1].DoneAt = time.Now(), time.Now()
+	c.Request.Approvals[0].Rejected = true
+	if !c.Request.Fulfilled() || !c.Request.Rejected() {
+		t.Errorf("Expected the copy to be fulfilled and rejected, got %+v", c.Request)
+	}
+	if tk.Request.Checklist[0].Done() || tk.Request.Rejected() {
+		t.Errorf("Expected the copy not to change the original, got %+v", tk.Request)
+	}
+
+	c.Request.Grants = []Grant{{System: "github", Group: "ops", Member: "octocat"}}
+	if c.Request.Fulfilled() {
+		t.Errorf("Expected a request to wait for its grants, got %+v", c.Request)
+	}
+	c.Copy().Request.Grants[0].DoneAt = time.Now()
+	if c.Request.Grants[0].Done() {
+		t.Errorf("Expected the copy's grants not to change the original, got %+v", c.Request.Grants)
+	}
+}
@@ -0,0 +1,369 @@
+// Package ticketapi serves the tickets over a versioned HTTP/JSON API, so
+// that dashboards and scripts can read and change the tickets raised in
+// Slack without going through it
+package ticketapi
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/skybet/go-helpdesk/clock"
+	"github.com/skybet/go-helpdesk/lifecycle"
+	"github.com/skybet/go-helpdesk/query"
+	"github.com/skybet/go-helpdesk/server"
+	"github.com/skybet/go-helpdesk/sso"
+	"github.com/skybet/go-helpdesk/store"
+	"github.com/skybet/go-helpdesk/ticket"
+)
+
+const (
+	// defaultLimit and maxLimit bound the tickets listed in a page
+	defaultLimit = 100
+	maxLimit     = 500
+	// APIUser is who moves made through the API with its token are made
+	// by, as the token does not identify anyone
+	APIUser = "ticket API"
+)
+
+// API serves the tickets under /tickets. Every request must carry the token as
+// a bearer token.
+type API struct {
+	store   store.Store
+	handler http.Handler
+	// Lifecycle moves tickets between statuses, so that the API is held to
+	// the same transitions and guards as /hd move and its moves are
+	// announced the same way
+	Lifecycle *lifecycle.Machine
+	// Clock, if set, replaces the wall clock
+	Clock clock.Clock
+}
+
+// NewAPI returns an API for the tickets in s, moving them with m. Mount it
+// with http.StripPrefix so that its routes, such as /tickets, are at the root.
+func NewAPI(s store.Store, m *lifecycle.Machine, token string) *API {
+	a := &API{store: s, Lifecycle: m}
+	a.handler = server.RequireToken(token, http.HandlerFunc(a.route))
+	return a
+}
+
+// Ticket is a ticket in the API
+type Ticket struct {
+	ID          string     `json:"id"`
+	Title       string     `json:"title"`
+	Description string     `json:"description"`
+	Queue       string     `json:"queue"`
+	Status      string     `json:"status"`
+	Priority    string     `json:"priority,omitempty"`
+	Reporter    string     `json:"reporter,omitempty"`
+	Assignee    string     `json:"assignee,omitempty"`
+	Tags        []string   `json:"tags"`
+	ChannelID   string     `json:"channel_id,omitempty"`
+	ThreadTS    string     `json:"thread_ts,omitempty"`
+	Issue       string     `json:"issue,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	ResolvedAt  *time.Time `json:"resolved_at,omitempty"`
+	// Version changes with every write, send it back when changing the
+	// ticket to be sure nobody else has changed it since it was read
+	Version int `json:"version"`
+}
+
+func toJSON(t *ticket.Ticket) Ticket {
+	j := Ticket{
+		ID:          t.ID,
+		Title:       t.Title,
+		Description: t.Description,
+		Queue:       t.Queue,
+		Status:      string(t.Status),
+		Reporter:    t.Reporter,
+		Assignee:    t.Assignee,
+		Tags:        append([]string{}, t.Tags...),
+		ChannelID:   t.ChannelID,
+		ThreadTS:    t.ThreadTS,
+		Issue:       t.Issue.Key,
+		CreatedAt:   t.CreatedAt,
+		UpdatedAt:   t.UpdatedAt,
+		Version:     t.Version,
+	}
+	if !t.ResolvedAt.IsZero() {
+		resolved := t.ResolvedAt
+		j.ResolvedAt = &resolved
+	}
+	if t.Priority != 0 {
+		j.Priority = t.Priority.String()
+	}
+	return j
+}
+
+// change is the body of POST and PATCH requests, fields left out are not
+// changed
+type change struct {
+	Title       *string   `json:"title"`
+	Description *string   `json:"description"`
+	Queue       *string   `json:"queue"`
+	Status      *string   `json:"status"`
+	Priority    *string   `json:"priority"`
+	Reporter    *string   `json:"reporter"`
+	Assignee    *string   `json:"assignee"`
+	Tags        *[]string `json:"tags"`
+	Version     *int      `json:"version"`
+}
+
+// apply sets the fields of t which the change sets, other than its status
+func (c *change) apply(t *ticket.Ticket) error {
+	if c.Title != nil {
+		if strings.TrimSpace(*c.Title) == "" {
+			return fmt.Errorf("title can not be empty")
+		}
+		t.Title = *c.Title
+	}
+	if c.Priority != nil {
+		p, err := ticket.ParsePriority(*c.Priority)
+		if err != nil {
+			return err
+		}
+		t.Priority = p
+	}
+	for _, f := range []struct {
+		from *string
+		to   *string
+	}{{c.Description, &t.Description}, {c.Queue, &t.Queue}, {c.Reporter, &t.Reporter}, {c.Assignee, &t.Assignee}} {
+		if f.from != nil {
+			*f.to = *f.from
+		}
+	}
+	if c.Tags != nil {
+		t.Tags = append([]string(nil), *c.Tags...)
+	}
+	return nil
+}
+
+// ServeHTTP satisfies http.Handler. GET /tickets lists the tickets, filtered
+// by a search query in the query parameter and the queue, status, assignee,
+// reporter, tag and q parameters a page of limit at a time from cursor, and POST /tickets raises one. GET
+// /tickets/<id> returns a ticket and PATCH /tickets/<id> changes it. Tickets
+// in the trash are left out.
+func (a *API) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	a.handler.ServeHTTP(w, r)
+}
+
+// route serves the requests which carried the token
+func (a *API) route(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	switch {
+	case len(parts) == 1 && parts[0] == "tickets" && r.Method == http.MethodGet:
+		a.list(w, r)
+	case len(parts) == 1 && parts[0] == "tickets" && r.Method == http.MethodPost:
+		a.create(w, r)
+	case len(parts) == 2 && parts[0] == "tickets" && r.Method == http.MethodGet:
+		a.get(w, r, parts[1])
+	case len(parts) == 2 && parts[0] == "tickets" && r.Method == http.MethodPatch:
+		a.update(w, r, parts[1])
+	case len(parts) <= 2 && parts[0] == "tickets":
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (a *API) list(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	f, err := query.Parse(q.Get("query"), "", clock.Or(a.Clock).Now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	f.Cursor, f.Limit = q.Get("cursor"), defaultLimit
+	if v := q.Get("queue"); v != "" {
+		f.Queue = v
+	}
+	if v := q.Get("assignee"); v != "" {
+		f.Assignee = v
+	}
+	if v := q.Get("reporter"); v != "" {
+		f.Reporter = v
+	}
+	if v := q.Get("q"); v != "" {
+		f.Text = v
+	}
+	if s := q.Get("status"); s != "" {
+		f.Status = nil
+		for _, name := range strings.Split(s, ",") {
+			status, err := ticket.ParseStatus(name)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			f.Status = append(f.Status, status)
+		}
+	}
+	for _, tags := range q["tag"] {
+		f.Tags = append(f.Tags, strings.Split(tags, ",")...)
+	}
+	if l := q.Get("limit"); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil || n < 1 || n > maxLimit {
+			http.Error(w, fmt.Sprintf("limit must be from 1 to %d", maxLimit), http.StatusBadRequest)
+			return
+		}
+		f.Limit = n
+	}
+	found, next, err := a.store.ListTickets(r.Context(), f)
+	if err != nil {
+		http.Error(w, "error listing tickets", http.StatusInternalServerError)
+		return
+	}
+	tickets := []Ticket{}
+	for _, t := range found {
+		tickets = append(tickets, toJSON(t))
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"tickets": tickets, "next_cursor": next})
+}
+
+func (a *API) get(w http.ResponseWriter, r *http.Request, id string) {
+	t, err := a.store.GetTicket(r.Context(), id)
+	if err == store.ErrNotFound || (err == nil && t.Deleted()) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		http.Error(w, "error getting ticket", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, toJSON(t))
+}
+
+func (a *API) create(w http.ResponseWriter, r *http.Request) {
+	var c change
+	if !decode(w, r, &c) {
+		return
+	}
+	if c.Title == nil {
+		http.Error(w, "title is required", http.StatusBadRequest)
+		return
+	}
+	if c.Status != nil || c.Version != nil {
+		http.Error(w, "new tickets can not set their status or version", http.StatusBadRequest)
+		return
+	}
+	t := &ticket.Ticket{Status: ticket.StatusNew}
+	if err := c.apply(t); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := a.store.CreateTicket(r.Context(), t); err != nil {
+		http.Error(w, "error creating ticket", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Location", "/tickets/"+t.ID)
+	writeJSON(w, http.StatusCreated, toJSON(t))
+}
+
+// update changes the ticket's fields and then moves it to its new status, if
+// the change has one
+func (a *API) update(w http.ResponseWriter, r *http.Request, id string) {
+	var c change
+	if !decode(w, r, &c) {
+		return
+	}
+	var to ticket.Status
+	if c.Status != nil {
+		var err error
+		if to, err = ticket.ParseStatus(*c.Status); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	var t *ticket.Ticket
+	err := a.store.Tx(r.Context(), func(tx store.Store) error {
+		var err error
+		if t, err = tx.GetTicket(r.Context(), id); err != nil {
+			return err
+		}
+		if t.Deleted() {
+			return store.ErrNotFound
+		}
+		if c.Version != nil && *c.Version != t.Version {
+			return store.ErrStale
+		}
+		before := *t
+		if err := c.apply(t); err != nil {
+			return &badRequest{err}
+		}
+		if sameFields(&before, t) {
+			return nil
+		}
+		return tx.UpdateTicket(r.Context(), t)
+	})
+	moving := err == nil && to != "" && to != t.Status
+	if moving {
+		t, err = a.Lifecycle.Move(r.Context(), a.store, id, to, by(r), clock.Or(a.Clock).Now())
+	}
+	var bad *badRequest
+	switch {
+	case err == store.ErrNotFound:
+		http.NotFound(w, r)
+	case err == store.ErrStale || err == store.ErrConflict:
+		http.Error(w, "the ticket has changed since it was read", http.StatusConflict)
+	case errors.As(err, &bad):
+		http.Error(w, bad.Error(), http.StatusBadRequest)
+	case err != nil && moving:
+		// The machine has no such transition or one of its guards refused
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+	case err != nil:
+		http.Error(w, "error updating ticket", http.StatusInternalServerError)
+	default:
+		writeJSON(w, http.StatusOK, toJSON(t))
+	}
+}
+
+// badRequest is an invalid change, which fails the transaction applying it
+type badRequest struct {
+	err error
+}
+
+func (b *badRequest) Error() string {
+	return b.err.Error()
+}
+
+// sameFields reports whether the fields a change can set are the same
+func sameFields(a, b *ticket.Ticket) bool {
+	if a.Title != b.Title || a.Description != b.Description || a.Queue != b.Queue || a.Priority != b.Priority || a.Reporter != b.Reporter || a.Assignee != b.Assignee || len(a.Tags) != len(b.Tags) {
+		return false
+	}
+	for i := range a.Tags {
+		if a.Tags[i] != b.Tags[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// by returns who a request moves tickets on behalf of
+func by(r *http.Request) string {
+	if u := sso.FromContext(r.Context()); u != nil {
+		return u.String()
+	}
+	return APIUser
+}
+
+func decode(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	d := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20))
+	d.DisallowUnknownFields()
+	if err := d.Decode(v); err != nil {
+		http.Error(w, fmt.Sprintf("invalid JSON: %s", err), http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,131 @@
+package ticketapi
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/skybet/go-helpdesk/lifecycle"
+	"github.com/skybet/go-helpdesk/sso"
+	"github.com/skybet/go-helpdesk/store"
+	"github.com/skybet/go-helpdesk/ticket"
+	"github.com/skybet/go-helpdesk/trash"
+)
+
+func TestAPI(t *testing.T) {
+	ctx := context.Background()
+	s := store.NewMemory()
+	s.CreateTicket(ctx, &ticket.Ticket{Title: "VPN down", Queue: "it", Tags: []string{"vpn"}})
+	s.CreateTicket(ctx, &ticket.Ticket{Title: "Printer", Queue: "facilities", Assignee: "U2"})
+	s.CreateTicket(ctx, &ticket.Ticket{Title: "Laptop", Queue: "it"})
+	trash.Delete(ctx, s, "3", "U1", time.Now())
+	m := lifecycle.New(lifecycle.DefaultTransitions)
+	m.Guard(lifecycle.RequireAssignee(ticket.StatusInProgress))
+	var moves []lifecycle.Move
+	m.OnMove(func(mv lifecycle.Move) { moves = append(moves, mv) })
+	a := NewAPI(s, m, "secret")
+	serve := func(method, path, token, body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, path, strings.NewReader(body))
+		if token != "" {
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+		if strings.Contains(path, "sso") {
+			r = r.WithContext(sso.NewContext(r.Context(), &sso.User{Subject: "s1", Email: "ann@example.com"}))
+		}
+		w := httptest.NewRecorder()
+		a.ServeHTTP(w, r)
+		return w
+	}
+	decode := func(w *httptest.ResponseRecorder, v interface{}) {
+		if err := json.Unmarshal(w.Body.Bytes(), v); err != nil {
+			t.Fatalf("Unexpected error decoding %q: %s", w.Body, err)
+		}
+	}
+
+	if w := serve("GET", "/tickets", "wrong", ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected requests without the token to be refused, got %d", w.Code)
+	}
+
+	var page struct {
+		Tickets    []Ticket `json:"tickets"`
+		NextCursor string   `json:"next_cursor"`
+	}
+	decode(serve("GET", "/tickets?queue=it", "secret", ""), &page)
+	if len(page.Tickets) != 1 || page.Tickets[0].ID != "1" || page.Tickets[0].Tags[0] != "vpn" {
+		t.Errorf("Expected ticket 1 in the it queue, leaving out the trash, got %+v", page.Tickets)
+	}
+	decode(serve("GET", "/tickets?limit=1", "secret", ""), &page)
+	if len(page.Tickets) != 1 || page.NextCursor == "" {
+		t.Fatalf("Expected a page of one ticket with a cursor, got %+v", page)
+	}
+	decode(serve("GET", "/tickets?limit=1&cursor="+page.NextCursor, "secret", ""), &page)
+	if len(page.Tickets) != 1 || page.Tickets[0].ID != "2" {
+		t.Errorf("Expected the next page to have ticket 2, got %+v", page.Tickets)
+	}
+	decode(serve("GET", "/tickets?query="+url.QueryEscape("status:open -assignee:none"), "secret", ""), &page)
+	if len(page.Tickets) != 1 || page.Tickets[0].ID != "2" {
+		t.Errorf("Expected the open assigned ticket 2 to match the query, got %+v", page.Tickets)
+	}
+	for _, q := range []string{"status=open", "limit=0", "limit=501", "query=assignee:@me"} {
+		if w := serve("GET", "/tickets?"+q, "secret", ""); w.Code != http.StatusBadRequest {
+			t.Errorf("Expected %s to be refused, got %d", q, w.Code)
+		}
+	}
+
+	w := serve("POST", "/tickets", "secret", `{"title": "Badge", "queue": "facilities", "priority": "P1", "tags": ["access"]}`)
+	var created Ticket
+	decode(w, &created)
+	if w.Code != http.StatusCreated || created.Status != "new" || created.Priority != "P1" || created.Version != 1 || w.Header().Get("Location") != "/tickets/"+created.ID {
+		t.Errorf("Expected the ticket to be created, got %d %+v", w.Code, created)
+	}
+	for _, body := range []string{`{"queue": "it"}`, `{"title": "X", "status": "closed"}`, `{"title": "X", "colour": "red"}`, `{"title": "X", "priority": "urgent"}`} {
+		if w := serve("POST", "/tickets", "secret", body); w.Code != http.StatusBadRequest {
+			t.Errorf("Expected %s to be refused, got %d", body, w.Code)
+		}
+	}
+
+	if w := serve("GET", "/tickets/3", "secret", ""); w.Code != http.StatusNotFound {
+		t.Errorf("Expected a ticket in the trash to be not found, got %d", w.Code)
+	}
+	var got Ticket
+	decode(serve("GET", "/tickets/2", "secret", ""), &got)
+	if got.Title != "Printer" || got.ResolvedAt != nil {
+		t.Errorf("Unexpected ticket %+v", got)
+	}
+
+	w = serve("PATCH", "/tickets/1", "secret", `{"status": "in_progress"}`)
+	if w.Code != http.StatusUnprocessableEntity || !strings.Contains(w.Body.String(), "must be assigned") {
+		t.Errorf("Expected the guard to refuse the move, got %d: %s", w.Code, w.Body)
+	}
+	if w := serve("PATCH", "/tickets/1", "secret", `{"title": "VPN", "version": 7}`); w.Code != http.StatusConflict {
+		t.Errorf("Expected a stale version to conflict, got %d", w.Code)
+	}
+	w = serve("PATCH", "/tickets/1?sso", "secret", `{"assignee": "U2", "status": "in_progress", "version": 1}`)
+	decode(w, &got)
+	if w.Code != http.StatusOK || got.Assignee != "U2" || got.Status != "in_progress" || got.Version != 3 {
+		t.Errorf("Expected the ticket to be assigned and moved, got %d %+v", w.Code, got)
+	}
+	if len(moves) != 1 || moves[0].By != "ann@example.com" {
+		t.Errorf("Expected the move to be made by the signed in user, got %+v", moves)
+	}
+	w = serve("PATCH", "/tickets/1", "secret", `{"status": "triaged"}`)
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Expected a transition the machine does not have to be refused, got %d", w.Code)
+	}
+	w = serve("PATCH", "/tickets/1", "secret", `{"status": "resolved"}`)
+	decode(w, &got)
+	if w.Code != http.StatusOK || got.Status != "resolved" || len(moves) != 2 || moves[1].By != APIUser {
+		t.Errorf("Expected the ticket to be resolved by the API, got %d %+v", w.Code, got)
+	}
+	if w := serve("PATCH", "/tickets/3", "secret", `{"title": "X"}`); w.Code != http.StatusNotFound {
+		t.Errorf("Expected a ticket in the trash to be not found, got %d", w.Code)
+	}
+	if w := serve("DELETE", "/tickets/1", "secret", ""); w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected DELETE to be refused, got %d", w.Code)
+	}
+}
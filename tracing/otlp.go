@@ -0,0 +1,190 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// maxPending is the most spans an OTLP exporter holds between flushes, spans
+// finished while it is full are dropped
+const maxPending = 4096
+
+// OTLP exports spans to an OpenTelemetry collector over OTLP/HTTP with JSON
+// encoding, in batches sent by Flush
+type OTLP struct {
+	// Endpoint is the collector's traces URL, e.g.
+	// http://localhost:4318/v1/traces
+	Endpoint string
+	// Service is the service.name of the spans
+	Service string
+	Client  *http.Client
+
+	mu      sync.Mutex
+	pending []*Span
+	dropped int
+}
+
+// NewOTLP returns an exporter sending spans of service to endpoint
+func NewOTLP(endpoint, service string) *OTLP {
+	return &OTLP{Endpoint: endpoint, Service: service, Client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+// Export satisfies Exporter, holding the span until the next flush
+func (o *OTLP) Export(s *Span) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if len(o.pending) >= maxPending {
+		o.dropped++
+		return
+	}
+	o.pending = append(o.pending, s)
+}
+
+// Flush sends the spans finished since the last flush. Spans are not retried
+// if the collector can not be reached.
+func (o *OTLP) Flush(ctx context.Context) error {
+	o.mu.Lock()
+	spans, dropped := o.pending, o.dropped
+	o.pending, o.dropped = nil, 0
+	o.mu.Unlock()
+	if len(spans) == 0 && dropped == 0 {
+		return nil
+	}
+	if len(spans) > 0 {
+		body, err := json.Marshal(o.request(spans))
+		if err != nil {
+			return fmt.Errorf("error encoding spans: %s", err)
+		}
+		r, err := http.NewRequest("POST", o.Endpoint, bytes.NewReader(body))
+		if err != nil {
+			return fmt.Errorf("error exporting spans: %s", err)
+		}
+		r.Header.Set("Content-Type", "application/json")
+		res, err := o.Client.Do(r.WithContext(ctx))
+		if err != nil {
+			return fmt.Errorf("error exporting %d spans: %s", len(spans), err)
+		}
+		res.Body.Close()
+		if res.StatusCode/100 != 2 {
+			return fmt.Errorf("error exporting %d spans: %s", len(spans), res.Status)
+		}
+	}
+	if dropped > 0 {
+		return fmt.Errorf("dropped %d spans finished while %d were waiting to be exported", dropped, maxPending)
+	}
+	return nil
+}
+
+// Run flushes the spans every interval until ctx is cancelled, and once more
+// when it is
+func (o *OTLP) Run(ctx context.Context, interval time.Duration, errorf func(string, ...interface{})) {
+	tick := time.NewTicker(interval)
+	defer tick.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			flush, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if err := o.Flush(flush); err != nil {
+				errorf("Exporting spans failed: %s", err)
+			}
+			return
+		case <-tick.C:
+			if err := o.Flush(ctx); err != nil {
+				errorf("Exporting spans failed: %s", err)
+			}
+		}
+	}
+}
+
+// The OTLP/HTTP JSON encoding of an ExportTraceServiceRequest
+type (
+	otlpRequest struct {
+		ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+	}
+	otlpResourceSpans struct {
+		Resource   otlpResource     `json:"resource"`
+		ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+	}
+	otlpResource struct {
+		Attributes []otlpAttribute `json:"attributes"`
+	}
+	otlpScopeSpans struct {
+		Scope otlpScope  `json:"scope"`
+		Spans []otlpSpan `json:"spans"`
+	}
+	otlpScope struct {
+		Name string `json:"name"`
+	}
+	otlpSpan struct {
+		TraceID           string          `json:"traceId"`
+		SpanID            string          `json:"spanId"`
+		ParentSpanID      string          `json:"parentSpanId,omitempty"`
+		Name              string          `json:"name"`
+		Kind              Kind            `json:"kind"`
+		StartTimeUnixNano string          `json:"startTimeUnixNano"`
+		EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+		Attributes        []otlpAttribute `json:"attributes,omitempty"`
+		Status            otlpStatus      `json:"status"`
+	}
+	otlpAttribute struct {
+		Key   string    `json:"key"`
+		Value otlpValue `json:"value"`
+	}
+	otlpValue struct {
+		StringValue string `json:"stringValue"`
+	}
+	otlpStatus struct {
+		// Code is 1 for ok and 2 for an error
+		Code    int    `json:"code"`
+		Message string `json:"message,omitempty"`
+	}
+)
+
+func (o *OTLP) request(spans []*Span) otlpRequest {
+	encoded := make([]otlpSpan, len(spans))
+	for i, s := range spans {
+		e := otlpSpan{
+			TraceID:           s.TraceID.String(),
+			SpanID:            s.SpanID.String(),
+			Name:              s.Name,
+			Kind:              s.Kind,
+			StartTimeUnixNano: strconv.FormatInt(s.Start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(s.End.UnixNano(), 10),
+			Attributes:        attributes(s.Attributes()),
+			Status:            otlpStatus{Code: 1},
+		}
+		if s.ParentID != (SpanID{}) {
+			e.ParentSpanID = s.ParentID.String()
+		}
+		if s.Err != nil {
+			e.Status = otlpStatus{Code: 2, Message: s.Err.Error()}
+		}
+		encoded[i] = e
+	}
+	return otlpRequest{ResourceSpans: []otlpResourceSpans{{
+		Resource:   otlpResource{Attributes: attributes(map[string]string{"service.name": o.Service})},
+		ScopeSpans: []otlpScopeSpans{{Scope: otlpScope{Name: "github.com/skybet/go-helpdesk"}, Spans: encoded}},
+	}}}
+}
+
+// attributes encodes attributes in the order of their keys
+func attributes(attrs map[string]string) []otlpAttribute {
+	keys := make([]string, 0, len(attrs))
+	for k := range attrs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	encoded := make([]otlpAttribute, len(keys))
+	for i, k := range keys {
+		encoded[i] = otlpAttribute{Key: k, Value: otlpValue{StringValue: attrs[k]}}
+	}
+	return encoded
+}
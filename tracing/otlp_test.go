@@ -0,0 +1,74 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestOTLP(t *testing.T) {
+	var got map[string]interface{}
+	posts := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		posts++
+		if r.Method != "POST" || r.URL.Path != "/v1/traces" || r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("Unexpected request %s %s", r.Method, r.URL)
+		}
+		json.NewDecoder(r.Body).Decode(&got)
+	}))
+	defer srv.Close()
+
+	o := NewOTLP(srv.URL+"/v1/traces", "helpdesk")
+	start := time.Date(2026, 10, 14, 9, 0, 0, 0, time.UTC)
+	tr := &Tracer{Exporter: o, Now: func() time.Time { return start }}
+	ctx, root := tr.Start(context.Background(), "slack command /hd", Server)
+	_, child := tr.Start(ctx, "slack.api chat.postMessage", Client)
+	child.Set("method", "chat.postMessage")
+	child.Finish(errors.New("ratelimited"))
+	root.Finish(nil)
+	if err := o.Flush(context.Background()); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if err := o.Flush(context.Background()); err != nil || posts != 1 {
+		t.Errorf("Expected nothing to be sent without new spans, got %d posts and %v", posts, err)
+	}
+
+	body, _ := json.Marshal(got)
+	for _, want := range []string{
+		`"key":"service.name","value":{"stringValue":"helpdesk"}`,
+		`"traceId":"` + root.TraceID.String() + `"`,
+		`"parentSpanId":"` + root.SpanID.String() + `"`,
+		`"kind":3`,
+		`"startTimeUnixNano":"1791968400000000000"`,
+		`"key":"method","value":{"stringValue":"chat.postMessage"}`,
+		`"status":{"code":2,"message":"ratelimited"}`,
+		`"status":{"code":1}`,
+	} {
+		if !strings.Contains(string(body), want) {
+			t.Errorf("Expected %s in %s", want, body)
+		}
+	}
+}
+
+func TestOTLPFull(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+	o := NewOTLP(srv.URL, "helpdesk")
+	for i := 0; i < maxPending+2; i++ {
+		o.Export(&Span{})
+	}
+	err := o.Flush(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "503") {
+		t.Errorf("Expected the collector's error, got %v", err)
+	}
+	if err := o.Flush(context.Background()); err != nil {
+		t.Errorf("Expected failed spans not to be retried, got %v", err)
+	}
+}
@@ -0,0 +1,111 @@
+package tracing
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/skybet/go-helpdesk/store"
+	"github.com/skybet/go-helpdesk/ticket"
+)
+
+// Store returns a store which records a span for each call to s, as a child
+// of the span in the call's context. Calls inside transactions are traced
+// too.
+func (t *Tracer) Store(s store.Store) store.Store {
+	return &tracedStore{Store: s, tracer: t}
+}
+
+type tracedStore struct {
+	store.Store
+	tracer *Tracer
+}
+
+// start starts the span of a call, attrs are pairs of attribute keys and
+// values
+func (s *tracedStore) start(ctx context.Context, method string, attrs ...string) (context.Context, *Span) {
+	ctx, span := s.tracer.Start(ctx, "store."+method, Internal)
+	for i := 0; i+1 < len(attrs); i += 2 {
+		span.Set(attrs[i], attrs[i+1])
+	}
+	return ctx, span
+}
+
+func (s *tracedStore) CreateTicket(ctx context.Context, t *ticket.Ticket) (err error) {
+	ctx, span := s.start(ctx, "CreateTicket", "queue", t.Queue)
+	defer func() { span.Finish(err) }()
+	return s.Store.CreateTicket(ctx, t)
+}
+
+func (s *tracedStore) GetTicket(ctx context.Context, id string) (_ *ticket.Ticket, err error) {
+	ctx, span := s.start(ctx, "GetTicket", "ticket", id)
+	defer func() { span.Finish(err) }()
+	return s.Store.GetTicket(ctx, id)
+}
+
+func (s *tracedStore) DeleteTicket(ctx context.Context, id string) (err error) {
+	ctx, span := s.start(ctx, "DeleteTicket", "ticket", id)
+	defer func() { span.Finish(err) }()
+	return s.Store.DeleteTicket(ctx, id)
+}
+
+func (s *tracedStore) UpdateTicket(ctx context.Context, t *ticket.Ticket) (err error) {
+	ctx, span := s.start(ctx, "UpdateTicket", "ticket", t.ID, "version", strconv.Itoa(t.Version))
+	defer func() { span.Finish(err) }()
+	return s.Store.UpdateTicket(ctx, t)
+}
+
+func (s *tracedStore) ListTickets(ctx context.Context, f store.Filter) (_ []*ticket.Ticket, _ string, err error) {
+	ctx, span := s.start(ctx, "ListTickets", "queue", f.Queue)
+	defer func() { span.Finish(err) }()
+	return s.Store.ListTickets(ctx, f)
+}
+
+func (s *tracedStore) Transition(ctx context.Context, id string, from, to ticket.Status) (_ *ticket.Ticket, err error) {
+	ctx, span := s.start(ctx, "Transition", "ticket", id, "from", string(from), "to", string(to))
+	defer func() { span.Finish(err) }()
+	return s.Store.Transition(ctx, id, from, to)
+}
+
+func (s *tracedStore) Tx(ctx context.Context, fn func(s store.Store) error) (err error) {
+	ctx, span := s.start(ctx, "Tx")
+	defer func() { span.Finish(err) }()
+	return s.Store.Tx(ctx, func(tx store.Store) error {
+		return fn(&tracedStore{Store: tx, tracer: s.tracer})
+	})
+}
+
+func (s *tracedStore) Enqueue(ctx context.Context, n *store.Notification) (err error) {
+	ctx, span := s.start(ctx, "Enqueue", "ticket", n.TicketID, "channel", n.ChannelID)
+	defer func() { span.Finish(err) }()
+	return s.Store.Enqueue(ctx, n)
+}
+
+func (s *tracedStore) Outbox(ctx context.Context, limit int) (_ []*store.Notification, err error) {
+	ctx, span := s.start(ctx, "Outbox", "limit", strconv.Itoa(limit))
+	defer func() { span.Finish(err) }()
+	return s.Store.Outbox(ctx, limit)
+}
+
+func (s *tracedStore) Sent(ctx context.Context, id string) (err error) {
+	ctx, span := s.start(ctx, "Sent", "notification", id)
+	defer func() { span.Finish(err) }()
+	return s.Store.Sent(ctx, id)
+}
+
+func (s *tracedStore) Failed(ctx context.Context, id string, reason string) (err error) {
+	ctx, span := s.start(ctx, "Failed", "notification", id)
+	defer func() { span.Finish(err) }()
+	return s.Store.Failed(ctx, id, reason)
+}
+
+func (s *tracedStore) SetQueueChannel(ctx context.Context, qc *store.QueueChannel) (err error) {
+	ctx, span := s.start(ctx, "SetQueueChannel", "queue", qc.Queue)
+	defer func() { span.Finish(err) }()
+	return s.Store.SetQueueChannel(ctx, qc)
+}
+
+func (s *tracedStore) QueueChannels(ctx context.Context) (_ []*store.QueueChannel, err error) {
+	ctx, span := s.start(ctx, "QueueChannels")
+	defer func() { span.Finish(err) }()
+	return s.Store.QueueChannels(ctx)
+}
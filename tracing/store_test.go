@@ -0,0 +1,40 @@
+package tracing
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/skybet/go-helpdesk/store"
+	"github.com/skybet/go-helpdesk/ticket"
+)
+
+func TestStore(t *testing.T) {
+	rec := &recorder{}
+	tr := &Tracer{Exporter: rec}
+	s := tr.Store(store.NewMemory())
+	ctx, root := tr.Start(context.Background(), "slack command /hd", Server)
+	tk := &ticket.Ticket{Queue: "support"}
+	s.CreateTicket(ctx, tk)
+	s.Tx(ctx, func(tx store.Store) error {
+		_, err := tx.GetTicket(ctx, tk.ID)
+		return err
+	})
+	if _, err := s.GetTicket(ctx, "missing"); err != store.ErrNotFound {
+		t.Fatalf("Expected the store's error, got %v", err)
+	}
+	root.Finish(nil)
+
+	want := []string{"store.CreateTicket", "store.GetTicket", "store.Tx", "store.GetTicket", "slack command /hd"}
+	if got := rec.names(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Expected spans %v, got %v", want, got)
+	}
+	for _, sp := range rec.spans[:4] {
+		if sp.TraceID != root.TraceID || sp.ParentID != root.SpanID {
+			t.Errorf("Expected %s to be a child of the request, got %+v", sp.Name, sp)
+		}
+	}
+	if rec.spans[0].Attributes()["queue"] != "support" || rec.spans[3].Err != store.ErrNotFound {
+		t.Errorf("Unexpected spans %+v and %+v", rec.spans[0], rec.spans[3])
+	}
+}
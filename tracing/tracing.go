@@ -0,0 +1,150 @@
+// Package tracing records spans of the work done for each Slack request, from
+// receiving it through the Slack API calls and store writes it makes, and
+// exports them to an OpenTelemetry collector. Spans are carried in the
+// context.Context passed down from the request.
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+// TraceID identifies every span of one request
+type TraceID [16]byte
+
+// String returns the ID in hex, as OpenTelemetry writes it
+func (id TraceID) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+// SpanID identifies a span within its trace
+type SpanID [8]byte
+
+// String returns the ID in hex, as OpenTelemetry writes it
+func (id SpanID) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+// Kind is the OpenTelemetry kind of a span
+type Kind int
+
+// The kinds of span recorded
+const (
+	Internal Kind = 1
+	// Server spans cover handling a request from Slack
+	Server Kind = 2
+	// Client spans cover a call to another service, such as Slack's Web API
+	Client Kind = 3
+)
+
+// Span is a timed operation of a trace. A nil span records nothing, so code
+// can trace without checking whether tracing is on.
+type Span struct {
+	TraceID TraceID
+	SpanID  SpanID
+	// ParentID is the span this one was started in, zero for the root
+	ParentID SpanID
+	Name     string
+	Kind     Kind
+	Start    time.Time
+	End      time.Time
+	// Err is why the operation failed, nil if it succeeded
+	Err error
+
+	mu         sync.Mutex
+	attributes map[string]string
+	tracer     *Tracer
+}
+
+// Set sets an attribute of the span, such as the Slack API method called
+func (s *Span) Set(key, value string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.attributes == nil {
+		s.attributes = map[string]string{}
+	}
+	s.attributes[key] = value
+}
+
+// Attributes returns a copy of the span's attributes
+func (s *Span) Attributes() map[string]string {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	attrs := make(map[string]string, len(s.attributes))
+	for k, v := range s.attributes {
+		attrs[k] = v
+	}
+	return attrs
+}
+
+// Finish ends the span, failed with err if it is not nil, and exports it
+func (s *Span) Finish(err error) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	s.End, s.Err = s.tracer.now(), err
+	s.mu.Unlock()
+	s.tracer.Exporter.Export(s)
+}
+
+// Exporter sends finished spans to be stored, it must not block
+type Exporter interface {
+	Export(s *Span)
+}
+
+// Tracer starts spans, exporting them to Exporter once they finish. Tracing
+// is off while Exporter is nil.
+type Tracer struct {
+	Exporter Exporter
+	// Now, if set, replaces the wall clock
+	Now func() time.Time
+}
+
+// Default is the tracer the helpdesk's packages start spans with
+var Default = &Tracer{}
+
+func (t *Tracer) now() time.Time {
+	if t.Now != nil {
+		return t.Now()
+	}
+	return time.Now()
+}
+
+type spanKey struct{}
+
+// Start starts a span as a child of the span in ctx, or as the root of a new
+// trace, returning a context carrying it. The span is nil if tracing is off.
+func (t *Tracer) Start(ctx context.Context, name string, kind Kind) (context.Context, *Span) {
+	if t.Exporter == nil {
+		return ctx, nil
+	}
+	s := &Span{Name: name, Kind: kind, Start: t.now(), tracer: t}
+	if parent := FromContext(ctx); parent != nil {
+		s.TraceID, s.ParentID = parent.TraceID, parent.SpanID
+	} else {
+		rand.Read(s.TraceID[:])
+	}
+	rand.Read(s.SpanID[:])
+	return context.WithValue(ctx, spanKey{}, s), s
+}
+
+// Start starts a span with the Default tracer
+func Start(ctx context.Context, name string, kind Kind) (context.Context, *Span) {
+	return Default.Start(ctx, name, kind)
+}
+
+// FromContext returns the span carried by ctx, nil if there is none
+func FromContext(ctx context.Context) *Span {
+	s, _ := ctx.Value(spanKey{}).(*Span)
+	return s
+}
@@ -0,0 +1,69 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+)
+
+// recorder is an exporter holding the spans finished
+type recorder struct {
+	mu    sync.Mutex
+	spans []*Span
+}
+
+func (r *recorder) Export(s *Span) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.spans = append(r.spans, s)
+}
+
+func (r *recorder) names() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	names := make([]string, len(r.spans))
+	for i, s := range r.spans {
+		names[i] = s.Name
+	}
+	return names
+}
+
+func TestStart(t *testing.T) {
+	rec := &recorder{}
+	tr := &Tracer{Exporter: rec}
+	ctx, root := tr.Start(context.Background(), "slack command /hd", Server)
+	if FromContext(ctx) != root || root.ParentID != (SpanID{}) || root.TraceID == (TraceID{}) {
+		t.Fatalf("Expected a root span in the context, got %+v", root)
+	}
+	_, child := tr.Start(ctx, "slack.api chat.postMessage", Client)
+	child.Set("method", "chat.postMessage")
+	fail := errors.New("ratelimited")
+	child.Finish(fail)
+	root.Finish(nil)
+
+	if child.TraceID != root.TraceID || child.ParentID != root.SpanID || child.SpanID == root.SpanID {
+		t.Errorf("Expected the child to be in the root's trace, got %+v and %+v", root, child)
+	}
+	if child.Err != fail || child.Attributes()["method"] != "chat.postMessage" || child.End.Before(child.Start) {
+		t.Errorf("Unexpected child span %+v", child)
+	}
+	if names := rec.names(); len(names) != 2 || names[0] != child.Name || names[1] != root.Name {
+		t.Errorf("Expected both spans to be exported, got %v", names)
+	}
+	if _, other := tr.Start(context.Background(), "slack event message", Server); other.TraceID == root.TraceID {
+		t.Error("Expected a new trace for a new request")
+	}
+}
+
+func TestStartOff(t *testing.T) {
+	ctx, s := (&Tracer{}).Start(context.Background(), "slack command /hd", Server)
+	if s != nil || FromContext(ctx) != nil {
+		t.Fatalf("Expected no span while tracing is off, got %+v", s)
+	}
+	s.Set("method", "chat.postMessage")
+	s.Finish(errors.New("ignored"))
+	if s.Attributes() != nil {
+		t.Error("Expected a nil span to have no attributes")
+	}
+}
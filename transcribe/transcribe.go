@@ -0,0 +1,56 @@
+// Package transcribe turns voice notes into text, so tickets can be filed by
+// talking to the bot. Transcription is left to a separate service, which can
+// wrap a cloud speech API or a self-hosted model.
+package transcribe
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Transcriber transcribes audio of a MIME type, such as audio/mp4
+type Transcriber interface {
+	Transcribe(ctx context.Context, audio io.Reader, mimetype string) (string, error)
+}
+
+// HTTP posts the audio to URL with its MIME type as the Content-Type, the
+// service replies with the transcript as JSON, e.g. {"text": "The printer is
+// on fire"}
+type HTTP struct {
+	URL    string
+	Client *http.Client
+}
+
+// Transcribe satisfies Transcriber
+func (h *HTTP) Transcribe(ctx context.Context, audio io.Reader, mimetype string) (string, error) {
+	req, err := http.NewRequest(http.MethodPost, h.URL, audio)
+	if err != nil {
+		return "", err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", mimetype)
+	req.Header.Set("Accept", "application/json")
+	client := h.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("error transcribing audio: %s", err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("error transcribing audio: %s", res.Status)
+	}
+	var transcript struct {
+		Text string `json:"text"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&transcript); err != nil {
+		return "", fmt.Errorf("error decoding transcript: %s", err)
+	}
+	return strings.TrimSpace(transcript.Text), nil
+}
@@ -0,0 +1,41 @@
+package transcribe
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHTTP(t *testing.T) {
+	var gotType, gotBody string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		gotType, gotBody = r.Header.Get("Content-Type"), string(body)
+		switch gotBody {
+		case "hello":
+			w.Write([]byte(`{"text": " The printer is on fire \n"}`))
+		case "garbled":
+			w.Write([]byte(`not json`))
+		default:
+			w.WriteHeader(http.StatusUnsupportedMediaType)
+		}
+	}))
+	defer srv.Close()
+	h := &HTTP{URL: srv.URL}
+
+	text, err := h.Transcribe(context.Background(), strings.NewReader("hello"), "audio/mp4")
+	if err != nil || text != "The printer is on fire" {
+		t.Errorf("Expected the trimmed transcript, got %q %v", text, err)
+	}
+	if gotType != "audio/mp4" || gotBody != "hello" {
+		t.Errorf("Expected the audio to be posted with its type, got %q %q", gotType, gotBody)
+	}
+	for _, audio := range []string{"garbled", "video"} {
+		if _, err := h.Transcribe(context.Background(), strings.NewReader(audio), "audio/mp4"); err == nil {
+			t.Errorf("Expected an error transcribing %q", audio)
+		}
+	}
+}
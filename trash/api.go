@@ -0,0 +1,99 @@
+package trash
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/skybet/go-helpdesk/server"
+	"github.com/skybet/go-helpdesk/store"
+)
+
+// API serves the trash as JSON to the admin UI. Every request must carry the
+// token as a bearer token.
+type API struct {
+	store   store.Store
+	purger  *Purger
+	handler http.Handler
+}
+
+// NewAPI returns an API for the trash of s, whose tickets are purged by p.
+// Mount it with http.StripPrefix so that its routes, such as /trash, are at
+// the root.
+func NewAPI(s store.Store, p *Purger, token string) *API {
+	a := &API{store: s, purger: p}
+	a.handler = server.RequireToken(token, http.HandlerFunc(a.route))
+	return a
+}
+
+// ticketJSON is a ticket in the trash in the API
+type ticketJSON struct {
+	ID        string    `json:"id"`
+	Queue     string    `json:"queue"`
+	Title     string    `json:"title"`
+	Reporter  string    `json:"reporter"`
+	DeletedBy string    `json:"deleted_by"`
+	DeletedAt time.Time `json:"deleted_at"`
+	PurgeAt   time.Time `json:"purge_at"`
+}
+
+// ServeHTTP satisfies http.Handler. GET /trash lists the tickets in the trash
+// and POST /trash/<id>/restore restores one.
+func (a *API) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	a.handler.ServeHTTP(w, r)
+}
+
+// route serves the requests which carried the token
+func (a *API) route(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	switch {
+	case len(parts) == 1 && parts[0] == "trash" && r.Method == http.MethodGet:
+		a.list(w, r)
+	case len(parts) == 3 && parts[0] == "trash" && parts[2] == "restore" && r.Method == http.MethodPost:
+		a.restore(w, r, parts[1])
+	case len(parts) >= 1 && parts[0] == "trash":
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (a *API) list(w http.ResponseWriter, r *http.Request) {
+	trash, err := List(r.Context(), a.store)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	tickets := []ticketJSON{}
+	for _, t := range trash {
+		tickets = append(tickets, ticketJSON{
+			ID:        t.ID,
+			Queue:     t.Queue,
+			Title:     t.Title,
+			Reporter:  t.Reporter,
+			DeletedBy: t.DeletedBy,
+			DeletedAt: t.DeletedAt,
+			PurgeAt:   a.purger.PurgeAt(t),
+		})
+	}
+	writeJSON(w, map[string]interface{}{"tickets": tickets})
+}
+
+func (a *API) restore(w http.ResponseWriter, r *http.Request, id string) {
+	t, err := Restore(r.Context(), a.store, id)
+	if err == store.ErrNotFound {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	writeJSON(w, map[string]interface{}{"id": t.ID, "status": t.Status})
+}
+
+func writeJSON(w http.ResponseWriter, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,58 @@
+package trash
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/skybet/go-helpdesk/store"
+	"github.com/skybet/go-helpdesk/ticket"
+)
+
+func TestAPI(t *testing.T) {
+	s := store.NewMemory()
+	s.CreateTicket(context.Background(), &ticket.Ticket{ID: "1", Title: "VPN down"})
+	s.CreateTicket(context.Background(), &ticket.Ticket{ID: "2", Title: "Printer"})
+	deleted := time.Date(2019, 3, 4, 9, 0, 0, 0, time.UTC)
+	Delete(context.Background(), s, "1", "U1", deleted)
+	a := NewAPI(s, &Purger{Store: s, Retention: 24 * time.Hour}, "secret")
+	serve := func(method, path, token string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, path, nil)
+		if token != "" {
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		a.ServeHTTP(w, r)
+		return w
+	}
+
+	if w := serve("GET", "/trash", "wrong"); w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected requests without the token to be refused, got %d", w.Code)
+	}
+	w := serve("GET", "/trash", "secret")
+	var body struct {
+		Tickets []ticketJSON `json:"tickets"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if len(body.Tickets) != 1 || body.Tickets[0].ID != "1" || body.Tickets[0].DeletedBy != "U1" || !body.Tickets[0].PurgeAt.Equal(deleted.Add(24*time.Hour)) {
+		t.Errorf("Expected ticket 1 in the trash, got %+v", body.Tickets)
+	}
+
+	if w := serve("GET", "/trash/1/restore", "secret"); w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected restoring to need a POST, got %d", w.Code)
+	}
+	if w := serve("POST", "/trash/1/restore", "secret"); w.Code != http.StatusOK {
+		t.Errorf("Expected the ticket to be restored, got %d: %s", w.Code, w.Body)
+	}
+	if w := serve("POST", "/trash/2/restore", "secret"); w.Code != http.StatusConflict {
+		t.Errorf("Expected a ticket not in the trash to be refused, got %d", w.Code)
+	}
+	if w := serve("POST", "/trash/9/restore", "secret"); w.Code != http.StatusNotFound {
+		t.Errorf("Expected a missing ticket to be not found, got %d", w.Code)
+	}
+}
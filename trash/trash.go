@@ -0,0 +1,140 @@
+// Package trash moves deleted tickets to a trash they can be restored from,
+// and purges them for good once they have been there for a retention period
+package trash
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/skybet/go-helpdesk/clock"
+	"github.com/skybet/go-helpdesk/store"
+	"github.com/skybet/go-helpdesk/ticket"
+)
+
+// Delete moves a ticket to the trash, recording who deleted it and when
+func Delete(ctx context.Context, s store.Store, id, user string, now time.Time) (*ticket.Ticket, error) {
+	var deleted *ticket.Ticket
+	err := s.Tx(ctx, func(tx store.Store) error {
+		t, err := tx.GetTicket(ctx, id)
+		if err != nil {
+			return err
+		}
+		if t.Deleted() {
+			return fmt.Errorf("ticket %s is already in the trash", id)
+		}
+		t.DeletedAt, t.DeletedBy = now, user
+		deleted = t
+		return tx.UpdateTicket(ctx, t)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return deleted, nil
+}
+
+// Restore takes a ticket out of the trash, it is as it was before it was
+// deleted
+func Restore(ctx context.Context, s store.Store, id string) (*ticket.Ticket, error) {
+	var restored *ticket.Ticket
+	err := s.Tx(ctx, func(tx store.Store) error {
+		t, err := tx.GetTicket(ctx, id)
+		if err != nil {
+			return err
+		}
+		if !t.Deleted() {
+			return fmt.Errorf("ticket %s is not in the trash", id)
+		}
+		t.DeletedAt, t.DeletedBy = time.Time{}, ""
+		restored = t
+		return tx.UpdateTicket(ctx, t)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return restored, nil
+}
+
+// List returns the tickets in the trash, oldest first
+func List(ctx context.Context, s store.Store) ([]*ticket.Ticket, error) {
+	var trash []*ticket.Ticket
+	f := store.Filter{Deleted: true, Limit: 500}
+	for {
+		page, next, err := s.ListTickets(ctx, f)
+		if err != nil {
+			return nil, fmt.Errorf("error listing tickets: %s", err)
+		}
+		trash = append(trash, page...)
+		if next == "" {
+			return trash, nil
+		}
+		f.Cursor = next
+	}
+}
+
+// Purger permanently removes the tickets which have been in the trash for
+// Retention
+type Purger struct {
+	Store     store.Store
+	Retention time.Duration
+	// Clock, if set, replaces the wall clock
+	Clock clock.Clock
+}
+
+// PurgeAt returns when a ticket in the trash will be purged
+func (p *Purger) PurgeAt(t *ticket.Ticket) time.Time {
+	return t.DeletedAt.Add(p.Retention)
+}
+
+// Purge removes the tickets due to be purged as of now, returning how many
+// were removed
+func (p *Purger) Purge(ctx context.Context, now time.Time) (int, error) {
+	trash, err := List(ctx, p.Store)
+	if err != nil {
+		return 0, err
+	}
+	var errs []string
+	purged := 0
+	for _, t := range trash {
+		if now.Before(p.PurgeAt(t)) {
+			continue
+		}
+		removed := false
+		err := p.Store.Tx(ctx, func(tx store.Store) error {
+			// It may have been restored since the trash was listed
+			current, err := tx.GetTicket(ctx, t.ID)
+			if err != nil || !current.Deleted() || now.Before(p.PurgeAt(current)) {
+				return err
+			}
+			removed = true
+			return tx.DeleteTicket(ctx, t.ID)
+		})
+		switch {
+		case err == nil && removed:
+			purged++
+		case err != nil && err != store.ErrNotFound:
+			errs = append(errs, fmt.Sprintf("#%s: %s", t.ID, err))
+		}
+	}
+	if len(errs) > 0 {
+		return purged, fmt.Errorf("error purging tickets: %s", strings.Join(errs, ", "))
+	}
+	return purged, nil
+}
+
+// Run purges tickets every interval until ctx is cancelled
+func (p *Purger) Run(ctx context.Context, interval time.Duration, errorf func(string, ...interface{})) {
+	tick := clock.Or(p.Clock).NewTicker(interval)
+	defer tick.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-tick.C():
+			if _, err := p.Purge(ctx, now); err != nil {
+				errorf("Purging the trash failed: %s", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package trash
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/skybet/go-helpdesk/store"
+	"github.com/skybet/go-helpdesk/ticket"
+)
+
+func TestDeleteAndRestore(t *testing.T) {
+	s := store.NewMemory()
+	s.CreateTicket(context.Background(), &ticket.Ticket{ID: "1", Assignee: "U2"})
+	now := time.Now()
+
+	tk, err := Delete(context.Background(), s, "1", "U1", now)
+	if err != nil || !tk.DeletedAt.Equal(now) || tk.DeletedBy != "U1" {
+		t.Fatalf("Expected the ticket to be deleted, got %+v %v", tk, err)
+	}
+	if _, err := Delete(context.Background(), s, "1", "U1", now); err == nil {
+		t.Errorf("Expected deleting twice to fail")
+	}
+	if trash, _ := List(context.Background(), s); len(trash) != 1 {
+		t.Errorf("Expected the ticket in the trash, got %v", trash)
+	}
+	tk, err = Restore(context.Background(), s, "1")
+	if err != nil || tk.Deleted() || tk.Assignee != "U2" {
+		t.Fatalf("Expected the ticket to be restored as it was, got %+v %v", tk, err)
+	}
+	if _, err := Restore(context.Background(), s, "1"); err == nil {
+		t.Errorf("Expected restoring a ticket not in the trash to fail")
+	}
+	if _, err := Restore(context.Background(), s, "2"); err != store.ErrNotFound {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestPurge(t *testing.T) {
+	s := store.NewMemory()
+	now := time.Now()
+	for _, id := range []string{"1", "2", "3"} {
+		s.CreateTicket(context.Background(), &ticket.Ticket{ID: id})
+	}
+	Delete(context.Background(), s, "1", "U1", now.Add(-48*time.Hour))
+	Delete(context.Background(), s, "2", "U1", now.Add(-time.Hour))
+	p := &Purger{Store: s, Retention: 24 * time.Hour}
+
+	n, err := p.Purge(context.Background(), now)
+	if err != nil || n != 1 {
+		t.Fatalf("Expected one ticket to be purged, got %d %v", n, err)
+	}
+	if _, err := s.GetTicket(context.Background(), "1"); err != store.ErrNotFound {
+		t.Errorf("Expected the ticket to be purged, got %v", err)
+	}
+	for _, id := range []string{"2", "3"} {
+		if _, err := s.GetTicket(context.Background(), id); err != nil {
+			t.Errorf("Expected ticket %s to be kept, got %v", id, err)
+		}
+	}
+	if at := p.PurgeAt(&ticket.Ticket{DeletedAt: now}); !at.Equal(now.Add(24 * time.Hour)) {
+		t.Errorf("Expected the ticket to be purged after the retention period, got %s", at)
+	}
+}
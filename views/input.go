@@ -0,0 +1,50 @@
+package views
+
+import (
+	"github.com/nlopes/slack"
+)
+
+// MBTInput is the input block type, only valid in views. It is not defined by
+// the slack package.
+const MBTInput = slack.MessageBlockType("input")
+
+// InputBlock collects a value from the user in a modal
+type InputBlock struct {
+	Type     slack.MessageBlockType `json:"type"`
+	BlockID  string                 `json:"block_id,omitempty"`
+	Label    *slack.TextBlockObject `json:"label"`
+	Element  interface{}            `json:"element"`
+	Hint     *slack.TextBlockObject `json:"hint,omitempty"`
+	Optional bool                   `json:"optional,omitempty"`
+}
+
+// BlockType satisfies slack.Block
+func (b InputBlock) BlockType() slack.MessageBlockType {
+	return b.Type
+}
+
+// NewInputBlock returns an input block labelled with label
+func NewInputBlock(blockID, label string, element interface{}) *InputBlock {
+	return &InputBlock{
+		Type:    MBTInput,
+		BlockID: blockID,
+		Label:   slack.NewTextBlockObject(slack.PlainTextType, label, false, false),
+		Element: element,
+	}
+}
+
+// PlainTextInput is a free text input element
+type PlainTextInput struct {
+	Type         string                 `json:"type"`
+	ActionID     string                 `json:"action_id"`
+	Placeholder  *slack.TextBlockObject `json:"placeholder,omitempty"`
+	InitialValue string                 `json:"initial_value,omitempty"`
+	Multiline    bool                   `json:"multiline,omitempty"`
+	MinLength    int                    `json:"min_length,omitempty"`
+	MaxLength    int                    `json:"max_length,omitempty"`
+}
+
+// NewPlainTextInput returns a single line text input
+func NewPlainTextInput(actionID string) *PlainTextInput {
+	return &PlainTextInput{Type: "plain_text_input", ActionID: actionID}
+}
@@ -0,0 +1,85 @@
+package views
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/nlopes/slack"
+)
+
+// TriggerTTL is how long Slack accepts a trigger_id for after sending it
+const TriggerTTL = 3 * time.Second
+
+// ErrTriggerExpired is returned instead of calling Slack when a trigger_id is
+// too old to open a view with
+var ErrTriggerExpired = errors.New("trigger_id has expired")
+
+// Client is the part of the Slack API used to open and update views
+type Client interface {
+	OpenView(triggerID string, view *View) (*View, error)
+	UpdateView(view *View, viewID, hash string) (*View, error)
+}
+
+// Loader builds the real content of a modal, it may take longer than a
+// trigger_id lives for
+type Loader func() (*View, error)
+
+// Loading returns a skeleton modal to display while the real content loads
+func Loading(callbackID, title string) *View {
+	v := NewModal(callbackID, title)
+	v.Blocks.BlockSet = []slack.Block{
+		slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType, ":hourglass_flowing_sand: Loading...", false, false), nil, nil),
+	}
+	return v
+}
+
+// Failed returns a modal explaining that its content could not be loaded
+func Failed(callbackID, title string, err error) *View {
+	v := NewModal(callbackID, title)
+	v.Close = slack.NewTextBlockObject(slack.PlainTextType, "Close", false, false)
+	v.Blocks.BlockSet = []slack.Block{
+		slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType, fmt.Sprintf(":warning: Something went wrong: %s", err), false, false), nil, nil),
+	}
+	return v
+}
+
+// Prewarm opens skeleton straight away, while triggerID is still valid, then
+// replaces it with the result of load once that is ready. received is when
+// Slack sent the trigger, if it is older than TriggerTTL ErrTriggerExpired is
+// returned without calling Slack.
+//
+// Prewarm returns as soon as the skeleton is open so the handler can respond
+// to Slack in time. The returned channel receives the outcome of loading and
+// updating the view and is then closed. If load fails the modal is replaced
+// with a Failed view.
+func Prewarm(c Client, triggerID string, received time.Time, skeleton *View, load Loader) (<-chan error, error) {
+	if !received.IsZero() && time.Since(received) > TriggerTTL {
+		return nil, ErrTriggerExpired
+	}
+	opened, err := c.OpenView(triggerID, skeleton)
+	if err != nil {
+		return nil, err
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		defer close(done)
+		v, err := load()
+		if err != nil {
+			c.UpdateView(Failed(skeleton.CallbackID, titleOf(skeleton), err), opened.ID, opened.Hash)
+			done <- err
+			return
+		}
+		_, err = c.UpdateView(v, opened.ID, opened.Hash)
+		done <- err
+	}()
+	return done, nil
+}
+
+func titleOf(v *View) string {
+	if v.Title == nil {
+		return ""
+	}
+	return v.Title.Text
+}
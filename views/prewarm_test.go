@@ -0,0 +1,92 @@
+package views
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakeClient struct {
+	mu      sync.Mutex
+	opened  []*View
+	updated []*View
+	ids     []string
+	hashes  []string
+}
+
+func (c *fakeClient) OpenView(triggerID string, v *View) (*View, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.opened = append(c.opened, v)
+	return &View{ID: "V1", Hash: "H1", CallbackID: v.CallbackID}, nil
+}
+
+func (c *fakeClient) UpdateView(v *View, viewID, hash string) (*View, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.updated = append(c.updated, v)
+	c.ids = append(c.ids, viewID)
+	c.hashes = append(c.hashes, hash)
+	return v, nil
+}
+
+func TestPrewarm(t *testing.T) {
+	c := &fakeClient{}
+	release := make(chan struct{})
+	done, err := Prewarm(c, "TRIGGER", time.Now(), Loading("new_ticket", "New ticket"), func() (*View, error) {
+		<-release
+		return NewModal("new_ticket", "New ticket"), nil
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	// The skeleton must be open before the slow load has finished
+	c.mu.Lock()
+	opened := len(c.opened)
+	c.mu.Unlock()
+	if opened != 1 {
+		t.Fatalf("Expected the skeleton to be opened straight away")
+	}
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatalf("Unexpected error updating view: %s", err)
+	}
+	if len(c.updated) != 1 || c.ids[0] != "V1" || c.hashes[0] != "H1" {
+		t.Fatalf("Expected the opened view to be updated using its ID and hash: %v %v", c.ids, c.hashes)
+	}
+	if len(c.updated[0].Blocks.BlockSet) != 0 {
+		t.Errorf("Expected the loaded view to replace the skeleton")
+	}
+}
+
+func TestPrewarmLoadFailure(t *testing.T) {
+	c := &fakeClient{}
+	boom := errors.New("directory unavailable")
+	done, err := Prewarm(c, "TRIGGER", time.Now(), Loading("new_ticket", "New ticket"), func() (*View, error) {
+		return nil, boom
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if err := <-done; err != boom {
+		t.Fatalf("Expected the load error, got %v", err)
+	}
+	if len(c.updated) != 1 || c.updated[0].Close == nil || c.updated[0].Title.Text != "New ticket" {
+		t.Fatalf("Expected the skeleton to be replaced with a failure view: %+v", c.updated)
+	}
+}
+
+func TestPrewarmExpiredTrigger(t *testing.T) {
+	c := &fakeClient{}
+	_, err := Prewarm(c, "TRIGGER", time.Now().Add(-5*time.Second), Loading("new_ticket", "New ticket"), func() (*View, error) {
+		t.Fatalf("Did not expect to load an expired view")
+		return nil, nil
+	})
+	if err != ErrTriggerExpired {
+		t.Fatalf("Expected ErrTriggerExpired, got %v", err)
+	}
+	if len(c.opened) != 0 {
+		t.Errorf("Did not expect Slack to be called with an expired trigger")
+	}
+}
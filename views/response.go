@@ -0,0 +1,74 @@
+package views
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Responder is implemented by errors which a view_submission handler returns
+// to reply with a response action rather than report a failure
+type Responder interface {
+	error
+	Response() *ResponseAction
+}
+
+// ResponseAction is the body a view_submission handler may reply with to
+// change what the user sees instead of closing the modal. It satisfies
+// Responder so handlers can return it directly, e.g. return views.Push(next)
+type ResponseAction struct {
+	Action string            `json:"response_action"`
+	Errors map[string]string `json:"errors,omitempty"`
+	View   *View             `json:"view,omitempty"`
+}
+
+// Update replaces the submitted view with v
+func Update(v *View) *ResponseAction {
+	return &ResponseAction{Action: "update", View: v}
+}
+
+// Push adds v to the top of the modal's view stack, the user can go back to
+// the submitted view
+func Push(v *View) *ResponseAction {
+	return &ResponseAction{Action: "push", View: v}
+}
+
+// Clear closes every view in the modal's stack
+func Clear() *ResponseAction {
+	return &ResponseAction{Action: "clear"}
+}
+
+// Error satisfies the error interface
+func (a *ResponseAction) Error() string {
+	return fmt.Sprintf("response action: %s", a.Action)
+}
+
+// Response satisfies Responder
+func (a *ResponseAction) Response() *ResponseAction {
+	return a
+}
+
+// ValidationErrors are field level errors for a view submission keyed by the
+// block ID of the offending input. Returning them from a view_submission
+// handler displays each message inline against its input.
+type ValidationErrors map[string]string
+
+// Add records an error against an input block
+func (v ValidationErrors) Add(blockID, message string) {
+	v[blockID] = message
+}
+
+// Error satisfies the error interface
+func (v ValidationErrors) Error() string {
+	var errs []string
+	for id, msg := range v {
+		errs = append(errs, fmt.Sprintf("%s: %s", id, msg))
+	}
+	sort.Strings(errs)
+	return fmt.Sprintf("invalid submission: %s", strings.Join(errs, ", "))
+}
+
+// Response returns the response action which displays the errors in Slack
+func (v ValidationErrors) Response() *ResponseAction {
+	return &ResponseAction{Action: "errors", Errors: v}
+}
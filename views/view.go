@@ -0,0 +1,127 @@
+// Package views models Slack modal views and the payloads Slack sends when
+// users interact with them
+package views
+
+import (
+	"encoding/json"
+
+	"github.com/nlopes/slack"
+)
+
+// Interaction types sent for views, these are not defined by the slack package
+const (
+	InteractionTypeViewSubmission = slack.InteractionType("view_submission")
+	InteractionTypeViewClosed     = slack.InteractionType("view_closed")
+)
+
+// View is a Slack modal or App Home view
+type View struct {
+	ID              string                 `json:"id,omitempty"`
+	TeamID          string                 `json:"team_id,omitempty"`
+	Type            string                 `json:"type"`
+	Title           *slack.TextBlockObject `json:"title,omitempty"`
+	Submit          *slack.TextBlockObject `json:"submit,omitempty"`
+	Close           *slack.TextBlockObject `json:"close,omitempty"`
+	Blocks          slack.Blocks           `json:"blocks"`
+	CallbackID      string                 `json:"callback_id,omitempty"`
+	PrivateMetadata string                 `json:"private_metadata,omitempty"`
+	ExternalID      string                 `json:"external_id,omitempty"`
+	Hash            string                 `json:"hash,omitempty"`
+	RootViewID      string                 `json:"root_view_id,omitempty"`
+	PreviousViewID  string                 `json:"previous_view_id,omitempty"`
+	ClearOnClose    bool                   `json:"clear_on_close,omitempty"`
+	NotifyOnClose   bool                   `json:"notify_on_close,omitempty"`
+	State           *State                 `json:"state,omitempty"`
+}
+
+// NewModal returns an empty modal view with the given callback ID and title
+func NewModal(callbackID, title string) *View {
+	return &View{
+		Type:       "modal",
+		CallbackID: callbackID,
+		Title:      slack.NewTextBlockObject(slack.PlainTextType, title, false, false),
+	}
+}
+
+// NewHome returns an empty App Home tab view
+func NewHome() *View {
+	return &View{Type: "home"}
+}
+
+// MarshalJSON encodes the view, Slack rejects views without a blocks array
+func (v View) MarshalJSON() ([]byte, error) {
+	type view View
+	if v.Blocks.BlockSet == nil {
+		v.Blocks.BlockSet = []slack.Block{}
+	}
+	return json.Marshal(view(v))
+}
+
+// UnmarshalJSON decodes a view sent by Slack. The slack package cannot decode
+// every block type a view may contain, such as inputs, so blocks are decoded on
+// a best effort basis and State should be used to read submitted values.
+func (v *View) UnmarshalJSON(data []byte) error {
+	type view View
+	var raw struct {
+		view
+		Blocks json.RawMessage `json:"blocks"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	*v = View(raw.view)
+	if len(raw.Blocks) > 0 {
+		json.Unmarshal(raw.Blocks, &v.Blocks)
+	}
+	return nil
+}
+
+// State holds the values of a view's inputs, keyed by block ID then action ID
+type State struct {
+	Values map[string]map[string]Value `json:"values"`
+}
+
+// Value is the current value of a single input element
+type Value struct {
+	Type            string                    `json:"type"`
+	Value           string                    `json:"value,omitempty"`
+	SelectedOption  *slack.OptionBlockObject  `json:"selected_option,omitempty"`
+	SelectedOptions []slack.OptionBlockObject `json:"selected_options,omitempty"`
+	SelectedUser    string                    `json:"selected_user,omitempty"`
+	SelectedUsers   []string                  `json:"selected_users,omitempty"`
+	SelectedChannel string                    `json:"selected_channel,omitempty"`
+	SelectedDate    string                    `json:"selected_date,omitempty"`
+}
+
+// Get returns the value of an input, the zero Value if it is missing
+func (s *State) Get(blockID, actionID string) Value {
+	if s == nil {
+		return Value{}
+	}
+	return s.Values[blockID][actionID]
+}
+
+// String returns the most relevant textual value of the input regardless of
+// its element type
+func (v Value) String() string {
+	switch {
+	case v.Value != "":
+		return v.Value
+	case v.SelectedOption != nil:
+		return v.SelectedOption.Value
+	case v.SelectedUser != "":
+		return v.SelectedUser
+	case v.SelectedChannel != "":
+		return v.SelectedChannel
+	}
+	return v.SelectedDate
+}
+
+// Submission is the payload for view_submission and view_closed interactions
+type Submission struct {
+	slack.InteractionCallback
+	View View `json:"view"`
+	// IsCleared is set on view_closed when the whole stack was closed rather
+	// than only the view
+	IsCleared bool `json:"is_cleared"`
+}
@@ -0,0 +1,62 @@
+package views
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestUnmarshalSubmission(t *testing.T) {
+	raw := `{"type":"view_submission","user":{"id":"U1"},"view":{"id":"V1","type":"modal","callback_id":"new_ticket","private_metadata":"C1","blocks":[{"type":"input","block_id":"title"},{"type":"divider"}],"state":{"values":{"title":{"value":{"type":"plain_text_input","value":"VPN down"}},"priority":{"value":{"type":"static_select","selected_option":{"value":"P2"}}}}}}}`
+	var s Submission
+	if err := json.Unmarshal([]byte(raw), &s); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if s.Type != InteractionTypeViewSubmission || s.User.ID != "U1" {
+		t.Errorf("Expected the interaction fields to be decoded: %+v", s.InteractionCallback)
+	}
+	if s.View.CallbackID != "new_ticket" || s.View.PrivateMetadata != "C1" {
+		t.Errorf("Expected the view to be decoded: %+v", s.View)
+	}
+	if v := s.View.State.Get("title", "value").String(); v != "VPN down" {
+		t.Errorf("Unexpected title: %s", v)
+	}
+	if v := s.View.State.Get("priority", "value").String(); v != "P2" {
+		t.Errorf("Unexpected priority: %s", v)
+	}
+	if v := s.View.State.Get("missing", "value").String(); v != "" {
+		t.Errorf("Expected a missing input to be empty, got %s", v)
+	}
+}
+
+func TestValidationErrors(t *testing.T) {
+	ve := ValidationErrors{}
+	ve.Add("title", "Required")
+	ve.Add("priority", "Pick one")
+	if ve.Error() != "invalid submission: priority: Pick one, title: Required" {
+		t.Errorf("Unexpected error string: %s", ve.Error())
+	}
+	b, _ := json.Marshal(ve.Response())
+	if string(b) != `{"response_action":"errors","errors":{"priority":"Pick one","title":"Required"}}` {
+		t.Errorf("Unexpected response body: %s", b)
+	}
+}
+
+func TestResponseActions(t *testing.T) {
+	v := NewModal("step_two", "Step two")
+	tt := []struct {
+		name string
+		a    *ResponseAction
+		json string
+	}{
+		{"Update", Update(v), `{"response_action":"update","view":{"type":"modal","title":{"type":"plain_text","text":"Step two"},"blocks":[],"callback_id":"step_two"}}`},
+		{"Push", Push(v), `{"response_action":"push","view":{"type":"modal","title":{"type":"plain_text","text":"Step two"},"blocks":[],"callback_id":"step_two"}}`},
+		{"Clear", Clear(), `{"response_action":"clear"}`},
+	}
+	for _, tc := range tt {
+		var r Responder = tc.a
+		b, _ := json.Marshal(r.Response())
+		if string(b) != tc.json {
+			t.Errorf("%s: unexpected response body: %s", tc.name, b)
+		}
+	}
+}
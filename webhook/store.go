@@ -0,0 +1,93 @@
+package webhook
+
+import (
+	"context"
+
+	"github.com/skybet/go-helpdesk/store"
+	"github.com/skybet/go-helpdesk/ticket"
+)
+
+// event is an event about a ticket waiting for its write to commit
+type event struct {
+	name   string
+	ticket *ticket.Ticket
+}
+
+// Wrap returns a store which writes to s and notifies the events of every
+// ticket it writes once the write has been committed. Updates read the
+// ticket first to tell what changed.
+func (n *Notifier) Wrap(s store.Store) store.Store {
+	return &notifier{Store: s, notify: func(events ...event) {
+		for _, e := range events {
+			n.Notify(e.name, e.ticket)
+		}
+	}}
+}
+
+// changes returns the events of a ticket being written over prev, which is
+// nil when it is created
+func changes(prev, t *ticket.Ticket) []event {
+	var events []event
+	if prev == nil {
+		prev = &ticket.Ticket{}
+		events = append(events, event{Created, t.Copy()})
+	}
+	if t.Assignee != "" && t.Assignee != prev.Assignee {
+		events = append(events, event{Assigned, t.Copy()})
+	}
+	if t.Status == ticket.StatusResolved && prev.Status != ticket.StatusResolved {
+		events = append(events, event{Resolved, t.Copy()})
+	}
+	return events
+}
+
+// notifier notifies each write's events as soon as the wrapped store returns
+type notifier struct {
+	store.Store
+	notify func(...event)
+}
+
+func (s *notifier) CreateTicket(ctx context.Context, t *ticket.Ticket) error {
+	if err := s.Store.CreateTicket(ctx, t); err != nil {
+		return err
+	}
+	s.notify(changes(nil, t)...)
+	return nil
+}
+
+func (s *notifier) UpdateTicket(ctx context.Context, t *ticket.Ticket) error {
+	prev, err := s.Store.GetTicket(ctx, t.ID)
+	if err != nil {
+		return err
+	}
+	if err := s.Store.UpdateTicket(ctx, t); err != nil {
+		return err
+	}
+	s.notify(changes(prev, t)...)
+	return nil
+}
+
+func (s *notifier) Transition(ctx context.Context, id string, from, to ticket.Status) (*ticket.Ticket, error) {
+	t, err := s.Store.Transition(ctx, id, from, to)
+	if err != nil {
+		return nil, err
+	}
+	if to == ticket.StatusResolved && from != ticket.StatusResolved {
+		s.notify(event{Resolved, t.Copy()})
+	}
+	return t, nil
+}
+
+// Tx holds back the transaction's events until it commits, they are dropped
+// if it fails
+func (s *notifier) Tx(ctx context.Context, fn func(s store.Store) error) error {
+	var events []event
+	err := s.Store.Tx(ctx, func(tx store.Store) error {
+		return fn(&notifier{Store: tx, notify: func(es ...event) { events = append(events, es...) }})
+	})
+	if err != nil {
+		return err
+	}
+	s.notify(events...)
+	return nil
+}
@@ -0,0 +1,73 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/skybet/go-helpdesk/store"
+	"github.com/skybet/go-helpdesk/ticket"
+)
+
+func TestWrap(t *testing.T) {
+	n := New([]string{"http://example.com/hook"}, "")
+	pending := func() []string {
+		e := n.endpoints[0]
+		e.mu.Lock()
+		defer e.mu.Unlock()
+		var events []string
+		for _, p := range e.pending {
+			events = append(events, p.Event+" "+p.Ticket.ID)
+		}
+		e.pending = nil
+		return events
+	}
+	ctx := context.Background()
+	s := n.Wrap(store.NewMemory())
+
+	tk := &ticket.Ticket{Title: "VPN", Status: ticket.StatusNew}
+	s.CreateTicket(ctx, tk)
+	if got := pending(); len(got) != 1 || got[0] != Created+" "+tk.ID {
+		t.Errorf("Expected the ticket to be created, got %v", got)
+	}
+
+	tk.Assignee = "U2"
+	s.UpdateTicket(ctx, tk)
+	tk.Title = "VPN down"
+	s.UpdateTicket(ctx, tk)
+	if got := pending(); len(got) != 1 || got[0] != Assigned+" "+tk.ID {
+		t.Errorf("Expected the ticket to be assigned once, got %v", got)
+	}
+
+	s.Transition(ctx, tk.ID, ticket.StatusNew, ticket.StatusResolved)
+	if got := pending(); len(got) != 1 || got[0] != Resolved+" "+tk.ID {
+		t.Errorf("Expected the ticket to be resolved, got %v", got)
+	}
+
+	err := s.Tx(ctx, func(tx store.Store) error {
+		tx.CreateTicket(ctx, &ticket.Ticket{Title: "Printer", Assignee: "U3"})
+		if got := pending(); len(got) != 0 {
+			t.Errorf("Expected nothing to be sent before the transaction commits, got %v", got)
+		}
+		return nil
+	})
+	if got := pending(); err != nil || len(got) != 2 || got[0][:len(Created)] != Created || got[1][:len(Assigned)] != Assigned {
+		t.Errorf("Expected the transaction's events once it commits, got %v %v", got, err)
+	}
+
+	s.Tx(ctx, func(tx store.Store) error {
+		tx.CreateTicket(ctx, &ticket.Ticket{Title: "Laptop"})
+		return errors.New("rolled back")
+	})
+	if got := pending(); len(got) != 0 {
+		t.Errorf("Expected a failed transaction's events to be dropped, got %v", got)
+	}
+
+	stale := &ticket.Ticket{ID: tk.ID, Version: 1, Assignee: "U9"}
+	if err := s.UpdateTicket(ctx, stale); err == nil {
+		t.Fatal("Expected a stale update to fail")
+	}
+	if got := pending(); len(got) != 0 {
+		t.Errorf("Expected a failed update to send nothing, got %v", got)
+	}
+}
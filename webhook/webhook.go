@@ -0,0 +1,295 @@
+// Package webhook tells other systems about tickets by POSTing signed JSON to
+// their HTTP endpoints as tickets are created, assigned and resolved, so that
+// they can follow the helpdesk without a Slack integration of their own.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/skybet/go-helpdesk/clock"
+	"github.com/skybet/go-helpdesk/ticket"
+)
+
+// The events sent
+const (
+	Created  = "ticket.created"
+	Assigned = "ticket.assigned"
+	Resolved = "ticket.resolved"
+)
+
+// maxQueued is the most deliveries kept for an endpoint, the oldest are
+// dropped once it has been failing for so long that there are more
+const maxQueued = 10000
+
+// ParseEvents parses the names of events, such as created or ticket.created
+func ParseEvents(names []string) ([]string, error) {
+	var events []string
+	for _, name := range names {
+		e := strings.ToLower(strings.TrimSpace(name))
+		if !strings.HasPrefix(e, "ticket.") {
+			e = "ticket." + e
+		}
+		switch e {
+		case Created, Assigned, Resolved:
+			events = append(events, e)
+		default:
+			return nil, fmt.Errorf("unknown event %q, expected created, assigned or resolved", name)
+		}
+	}
+	return events, nil
+}
+
+// Payload is the JSON body of a webhook
+type Payload struct {
+	// ID identifies the delivery, it is the same for each attempt so that
+	// endpoints can ignore repeats
+	ID     string    `json:"id"`
+	Event  string    `json:"event"`
+	At     time.Time `json:"at"`
+	Ticket Ticket    `json:"ticket"`
+}
+
+// Ticket is what a webhook says about a ticket. It leaves out the ticket's
+// conversation, which stays in Slack.
+type Ticket struct {
+	ID        string    `json:"id"`
+	Title     string    `json:"title"`
+	Queue     string    `json:"queue"`
+	Status    string    `json:"status"`
+	Priority  string    `json:"priority"`
+	Reporter  string    `json:"reporter"`
+	Assignee  string    `json:"assignee,omitempty"`
+	Tags      []string  `json:"tags,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// NewPayload returns the payload of an event about t
+func NewPayload(event string, t *ticket.Ticket, at time.Time) Payload {
+	return Payload{
+		ID:    delivery(),
+		Event: event,
+		At:    at,
+		Ticket: Ticket{
+			ID:        t.ID,
+			Title:     t.Title,
+			Queue:     t.Queue,
+			Status:    string(t.Status),
+			Priority:  t.Priority.String(),
+			Reporter:  t.Reporter,
+			Assignee:  t.Assignee,
+			Tags:      append([]string(nil), t.Tags...),
+			CreatedAt: t.CreatedAt,
+		},
+	}
+}
+
+// Sign returns the X-Helpdesk-Signature of a webhook's body
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Notifier POSTs the events of tickets written through its wrapped stores to
+// each of its URLs. Deliveries are queued in memory and sent in order, one
+// endpoint failing does not hold up the others.
+type Notifier struct {
+	// Secret signs each body, see Sign. Bodies are not signed if it is empty.
+	Secret string
+	// Events are the events sent, every one if empty
+	Events []string
+	// Retries is how many times a failed delivery is tried again, waiting
+	// Backoff before the first retry and twice as long before each one after
+	Retries int
+	Backoff time.Duration
+	// HTTP sends the requests
+	HTTP *http.Client
+	// Logf logs the deliveries which were given up on
+	Logf func(format string, args ...interface{})
+	// Clock, if set, replaces the wall clock
+	Clock clock.Clock
+
+	endpoints []*endpoint
+}
+
+// endpoint is the queue of deliveries to a URL
+type endpoint struct {
+	url     string
+	mu      sync.Mutex
+	pending []Payload
+	wake    chan struct{}
+}
+
+// New returns a Notifier posting to urls with secret
+func New(urls []string, secret string) *Notifier {
+	n := &Notifier{
+		Secret:  secret,
+		Retries: 5,
+		Backoff: time.Second,
+		HTTP:    &http.Client{Timeout: 10 * time.Second},
+	}
+	for _, u := range urls {
+		n.endpoints = append(n.endpoints, &endpoint{url: u, wake: make(chan struct{}, 1)})
+	}
+	return n
+}
+
+// Notify queues an event about t to be sent to every URL by Run, unless it is
+// not one of the Events. It never blocks.
+func (n *Notifier) Notify(event string, t *ticket.Ticket) {
+	if len(n.Events) > 0 && !contains(n.Events, event) {
+		return
+	}
+	p := NewPayload(event, t, clock.Or(n.Clock).Now())
+	for _, e := range n.endpoints {
+		e.mu.Lock()
+		e.pending = append(e.pending, p)
+		if dropped := len(e.pending) - maxQueued; dropped > 0 {
+			e.pending = e.pending[dropped:]
+			n.logf("Dropped %d webhooks for %s, which has been failing", dropped, e.url)
+		}
+		e.mu.Unlock()
+		select {
+		case e.wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Run sends the queued events until ctx is cancelled
+func (n *Notifier) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, e := range n.endpoints {
+		wg.Add(1)
+		go func(e *endpoint) {
+			defer wg.Done()
+			n.run(ctx, e)
+		}(e)
+	}
+	wg.Wait()
+}
+
+func (n *Notifier) run(ctx context.Context, e *endpoint) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-e.wake:
+		}
+		for {
+			e.mu.Lock()
+			if len(e.pending) == 0 {
+				e.mu.Unlock()
+				break
+			}
+			p := e.pending[0]
+			e.pending = e.pending[1:]
+			e.mu.Unlock()
+			if err := n.Deliver(ctx, e.url, p); err != nil {
+				if ctx.Err() != nil {
+					return
+				}
+				n.logf("Failed to send webhook %s for ticket %s to %s: %s", p.Event, p.Ticket.ID, e.url, err)
+			}
+		}
+	}
+}
+
+// Deliver POSTs p to url, trying again after network errors, server errors
+// and rate limiting. Other client errors are not retried, as they would fail
+// again.
+func (n *Notifier) Deliver(ctx context.Context, url string, p Payload) error {
+	body, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+	wait := n.Backoff
+	for attempt := 0; ; attempt++ {
+		retry, err := n.post(ctx, url, p, body)
+		if err == nil {
+			return nil
+		}
+		if !retry || attempt >= n.Retries {
+			return fmt.Errorf("%s after %d attempts", err, attempt+1)
+		}
+		t := clock.Or(n.Clock).NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return ctx.Err()
+		case <-t.C():
+		}
+		wait *= 2
+	}
+}
+
+// post makes one attempt at a delivery, reporting whether it is worth trying
+// again if it fails
+func (n *Notifier) post(ctx context.Context, url string, p Payload, body []byte) (bool, error) {
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "go-helpdesk")
+	req.Header.Set("X-Helpdesk-Event", p.Event)
+	req.Header.Set("X-Helpdesk-Delivery", p.ID)
+	if n.Secret != "" {
+		req.Header.Set("X-Helpdesk-Signature", Sign(n.Secret, body))
+	}
+	client := n.HTTP
+	if client == nil {
+		client = http.DefaultClient
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode/100 == 2 {
+		io.Copy(ioutil.Discard, io.LimitReader(res.Body, 4096))
+		return false, nil
+	}
+	msg, _ := ioutil.ReadAll(io.LimitReader(res.Body, 512))
+	err = fmt.Errorf("%s returned %s: %s", req.URL.Host, res.Status, strings.TrimSpace(string(msg)))
+	retry := res.StatusCode >= 500 || res.StatusCode == http.StatusTooManyRequests || res.StatusCode == http.StatusRequestTimeout
+	return retry, err
+}
+
+func (n *Notifier) logf(format string, args ...interface{}) {
+	if n.Logf != nil {
+		n.Logf(format, args...)
+	}
+}
+
+func contains(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// delivery returns a random delivery ID
+func delivery() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(fmt.Sprintf("error reading random bytes: %s", err))
+	}
+	return hex.EncodeToString(b)
+}
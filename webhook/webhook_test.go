@@ -0,0 +1,135 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/skybet/go-helpdesk/ticket"
+)
+
+// endpointServer records the webhooks it is sent, failing the first fail of
+// them with status
+type endpointServer struct {
+	*httptest.Server
+	mu       sync.Mutex
+	received []Payload
+	headers  []http.Header
+	attempts int
+	fail     int
+	status   int
+}
+
+func newEndpoint(fail, status int) *endpointServer {
+	e := &endpointServer{fail: fail, status: status}
+	e.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		e.mu.Lock()
+		defer e.mu.Unlock()
+		e.attempts++
+		if e.attempts <= e.fail {
+			http.Error(w, "unavailable", e.status)
+			return
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		if r.Header.Get("X-Helpdesk-Signature") != Sign("shh", body) {
+			http.Error(w, "bad signature", http.StatusUnauthorized)
+			return
+		}
+		var p Payload
+		json.Unmarshal(body, &p)
+		e.received = append(e.received, p)
+		e.headers = append(e.headers, r.Header)
+	}))
+	return e
+}
+
+func (e *endpointServer) payloads() []Payload {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]Payload(nil), e.received...)
+}
+
+func TestDeliver(t *testing.T) {
+	tk := &ticket.Ticket{ID: "7", Title: "VPN", Queue: "it", Status: ticket.StatusNew, Priority: ticket.P2, Reporter: "U1", Tags: []string{"vpn"}}
+	n := New(nil, "shh")
+	n.Backoff = time.Millisecond
+	p := NewPayload(Created, tk, time.Date(2026, 10, 14, 9, 0, 0, 0, time.UTC))
+
+	e := newEndpoint(2, http.StatusServiceUnavailable)
+	defer e.Close()
+	if err := n.Deliver(context.Background(), e.URL, p); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	got := e.payloads()
+	if e.attempts != 3 || len(got) != 1 || got[0].ID != p.ID || got[0].Event != Created || got[0].Ticket.ID != "7" || got[0].Ticket.Priority != "P2" || got[0].Ticket.Tags[0] != "vpn" {
+		t.Errorf("Expected the webhook to be delivered on the third attempt, got %d attempts and %+v", e.attempts, got)
+	}
+	if h := e.headers[0]; h.Get("X-Helpdesk-Event") != Created || h.Get("X-Helpdesk-Delivery") != p.ID || h.Get("Content-Type") != "application/json" {
+		t.Errorf("Unexpected headers %v", h)
+	}
+
+	e = newEndpoint(10, http.StatusBadGateway)
+	defer e.Close()
+	n.Retries = 3
+	if err := n.Deliver(context.Background(), e.URL, p); err == nil || !strings.Contains(err.Error(), "after 4 attempts") {
+		t.Errorf("Expected to give up after the retries, got %v", err)
+	}
+
+	e = newEndpoint(10, http.StatusNotFound)
+	defer e.Close()
+	if err := n.Deliver(context.Background(), e.URL, p); err == nil || e.attempts != 1 {
+		t.Errorf("Expected a client error not to be retried, got %d attempts and %v", e.attempts, err)
+	}
+}
+
+func TestNotify(t *testing.T) {
+	a, b := newEndpoint(0, 0), newEndpoint(1, http.StatusTooManyRequests)
+	defer a.Close()
+	defer b.Close()
+	n := New([]string{a.URL, b.URL}, "shh")
+	n.Backoff = time.Millisecond
+	n.Events = []string{Created, Resolved}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan struct{})
+	go func() {
+		n.Run(ctx)
+		close(done)
+	}()
+
+	tk := &ticket.Ticket{ID: "7", Status: ticket.StatusNew}
+	n.Notify(Created, tk)
+	n.Notify(Assigned, tk)
+	n.Notify(Resolved, tk)
+	for _, e := range []*endpointServer{a, b} {
+		deadline := time.Now().Add(5 * time.Second)
+		for len(e.payloads()) < 2 && time.Now().Before(deadline) {
+			time.Sleep(5 * time.Millisecond)
+		}
+		got := e.payloads()
+		if len(got) != 2 || got[0].Event != Created || got[1].Event != Resolved {
+			t.Errorf("Expected the created and resolved events in order, got %+v", got)
+		}
+	}
+	cancel()
+	<-done
+}
+
+func TestParseEvents(t *testing.T) {
+	events, err := ParseEvents([]string{"created", "ticket.assigned", " Resolved"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if len(events) != 3 || events[0] != Created || events[1] != Assigned || events[2] != Resolved {
+		t.Errorf("Unexpected events %v", events)
+	}
+	if _, err := ParseEvents([]string{"deleted"}); err == nil {
+		t.Error("Expected an error for an unknown event")
+	}
+}
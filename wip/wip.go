@@ -0,0 +1,152 @@
+// Package wip enforces work in progress limits on queues and agents
+package wip
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/skybet/go-helpdesk/store"
+	"github.com/skybet/go-helpdesk/ticket"
+)
+
+// ErrOverLimit is returned when an assignment would take a queue or agent over
+// its limit
+type ErrOverLimit struct {
+	// Kind is queue or agent
+	Kind    string
+	Name    string
+	Limit   int
+	Current int
+}
+
+// Error satisfies the error interface
+func (e *ErrOverLimit) Error() string {
+	return fmt.Sprintf("%s %s is at its WIP limit of %d with %d tickets in progress", e.Kind, e.Name, e.Limit, e.Current)
+}
+
+// Limits are the maximum number of open, assigned tickets per queue and per
+// agent. A missing or zero limit is unlimited. Limits is safe for concurrent
+// use.
+type Limits struct {
+	mu     sync.RWMutex
+	queues map[string]int
+	agents map[string]int
+}
+
+// NewLimits returns an empty set of limits
+func NewLimits() *Limits {
+	return &Limits{queues: map[string]int{}, agents: map[string]int{}}
+}
+
+// SetQueue sets the limit for a queue, zero removes it
+func (l *Limits) SetQueue(queue string, n int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	set(l.queues, queue, n)
+}
+
+// SetAgent sets the limit for an agent, zero removes it
+func (l *Limits) SetAgent(agent string, n int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	set(l.agents, agent, n)
+}
+
+func set(m map[string]int, k string, n int) {
+	if n <= 0 {
+		delete(m, k)
+		return
+	}
+	m[k] = n
+}
+
+// Queues returns a copy of the queue limits
+func (l *Limits) Queues() map[string]int {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	c := make(map[string]int, len(l.queues))
+	for k, v := range l.queues {
+		c[k] = v
+	}
+	return c
+}
+
+// Check returns an *ErrOverLimit if assigning t to agent would exceed the
+// limit of the ticket's queue or the agent
+func (l *Limits) Check(ctx context.Context, s store.Store, t *ticket.Ticket, agent string) error {
+	l.mu.RLock()
+	queueLimit, agentLimit := l.queues[t.Queue], l.agents[agent]
+	l.mu.RUnlock()
+
+	if agentLimit > 0 {
+		n, err := count(ctx, s, store.Filter{Assignee: agent}, t.ID)
+		if err != nil {
+			return err
+		}
+		if n >= agentLimit {
+			return &ErrOverLimit{Kind: "agent", Name: agent, Limit: agentLimit, Current: n}
+		}
+	}
+	// Moving a ticket between agents does not change its queue's WIP
+	if queueLimit > 0 && t.Assignee == "" {
+		n, err := count(ctx, s, store.Filter{Queue: t.Queue}, t.ID)
+		if err != nil {
+			return err
+		}
+		if n >= queueLimit {
+			return &ErrOverLimit{Kind: "queue", Name: t.Queue, Limit: queueLimit, Current: n}
+		}
+	}
+	return nil
+}
+
+// count returns the number of open, assigned tickets matching f other than
+// the ticket being assigned
+func count(ctx context.Context, s store.Store, f store.Filter, except string) (int, error) {
+	f.Status = OpenStatuses
+	n := 0
+	for {
+		page, next, err := s.ListTickets(ctx, f)
+		if err != nil {
+			return 0, fmt.Errorf("error counting work in progress: %s", err)
+		}
+		for _, t := range page {
+			if t.Assignee != "" && t.ID != except {
+				n++
+			}
+		}
+		if next == "" {
+			return n, nil
+		}
+		f.Cursor = next
+	}
+}
+
+// OpenStatuses are the statuses which count as work in progress once a ticket
+// is assigned
+var OpenStatuses = []ticket.Status{ticket.StatusNew, ticket.StatusTriaged, ticket.StatusInProgress, ticket.StatusWaiting}
+
+// Assign sets the ticket's assignee, enforcing the limits unless override is
+// set. The check and update happen in one transaction.
+func (l *Limits) Assign(ctx context.Context, s store.Store, id, agent string, override bool) (*ticket.Ticket, error) {
+	var assigned *ticket.Ticket
+	err := s.Tx(ctx, func(tx store.Store) error {
+		t, err := tx.GetTicket(ctx, id)
+		if err != nil {
+			return err
+		}
+		if !override {
+			if err := l.Check(ctx, tx, t, agent); err != nil {
+				return err
+			}
+		}
+		t.Assignee = agent
+		if err := tx.UpdateTicket(ctx, t); err != nil {
+			return err
+		}
+		assigned = t
+		return nil
+	})
+	return assigned, err
+}
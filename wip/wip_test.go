@@ -0,0 +1,78 @@
+package wip
+
+import (
+	"context"
+	"testing"
+
+	"github.com/skybet/go-helpdesk/store"
+	"github.com/skybet/go-helpdesk/ticket"
+)
+
+func seed(t *testing.T, s store.Store, tickets ...*ticket.Ticket) {
+	for _, tk := range tickets {
+		if err := s.CreateTicket(context.Background(), tk); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+	}
+}
+
+func TestAgentLimit(t *testing.T) {
+	s := store.NewMemory()
+	seed(t, s,
+		&ticket.Ticket{ID: "1", Queue: "it", Assignee: "U1"},
+		&ticket.Ticket{ID: "2", Queue: "it", Assignee: "U1", Status: ticket.StatusResolved},
+		&ticket.Ticket{ID: "3", Queue: "it"},
+	)
+	l := NewLimits()
+	l.SetAgent("U1", 1)
+
+	_, err := l.Assign(context.Background(), s, "3", "U1", false)
+	over, ok := err.(*ErrOverLimit)
+	if !ok || over.Kind != "agent" || over.Current != 1 {
+		t.Fatalf("Expected the agent limit to be enforced, got %v", err)
+	}
+	if tk, _ := s.GetTicket(context.Background(), "3"); tk.Assignee != "" {
+		t.Errorf("Expected the ticket to stay unassigned, got %s", tk.Assignee)
+	}
+	// Reassigning a ticket to its assignee does not count it twice
+	if _, err := l.Assign(context.Background(), s, "1", "U1", false); err != nil {
+		t.Errorf("Unexpected error: %s", err)
+	}
+	tk, err := l.Assign(context.Background(), s, "3", "U1", true)
+	if err != nil || tk.Assignee != "U1" {
+		t.Errorf("Expected an override to assign the ticket, got %v %v", tk, err)
+	}
+}
+
+func TestQueueLimit(t *testing.T) {
+	s := store.NewMemory()
+	seed(t, s,
+		&ticket.Ticket{ID: "1", Queue: "it", Assignee: "U1"},
+		&ticket.Ticket{ID: "2", Queue: "it"},
+		&ticket.Ticket{ID: "3", Queue: "hr"},
+	)
+	l := NewLimits()
+	l.SetQueue("it", 1)
+
+	if _, err := l.Assign(context.Background(), s, "2", "U2", false); err == nil {
+		t.Errorf("Expected the queue limit to be enforced")
+	}
+	if _, err := l.Assign(context.Background(), s, "3", "U2", false); err != nil {
+		t.Errorf("Expected other queues to be unlimited, got %s", err)
+	}
+	// Moving work between agents does not add to the queue
+	if _, err := l.Assign(context.Background(), s, "1", "U2", false); err != nil {
+		t.Errorf("Expected reassignment within the queue to be allowed, got %s", err)
+	}
+
+	l.SetQueue("it", 0)
+	if len(l.Queues()) != 0 {
+		t.Errorf("Expected a zero limit to remove it, got %v", l.Queues())
+	}
+}
+
+func TestAssignMissingTicket(t *testing.T) {
+	if _, err := NewLimits().Assign(context.Background(), store.NewMemory(), "1", "U1", false); err != store.ErrNotFound {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,58 @@
+package wrapper
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/nlopes/slack"
+)
+
+// postJSON calls a Web API method which the slack package does not support yet,
+// decoding the result into resp. Slack errors are returned in the same form as
+// the slack package returns them.
+func (s *Slack) postJSON(method, token string, req interface{}, resp interface{}) error {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return fmt.Errorf("error encoding %s request: %s", method, err)
+	}
+	r, err := http.NewRequestWithContext(s.callContext(), "POST", s.apiURL+method, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	r.Header.Set("Content-Type", "application/json; charset=utf-8")
+	r.Header.Set("Authorization", "Bearer "+token)
+	res, err := s.httpClient.Do(r)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusTooManyRequests {
+		retry, _ := strconv.Atoi(res.Header.Get("Retry-After"))
+		return &slack.RateLimitedError{RetryAfter: time.Duration(retry) * time.Second}
+	}
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("slack server error: %s", res.Status)
+	}
+
+	// Decode twice, once for the result and once to check for a Slack error
+	var raw json.RawMessage
+	if err := json.NewDecoder(res.Body).Decode(&raw); err != nil {
+		return fmt.Errorf("error decoding %s response: %s", method, err)
+	}
+	var sr slack.SlackResponse
+	if err := json.Unmarshal(raw, &sr); err != nil {
+		return fmt.Errorf("error decoding %s response: %s", method, err)
+	}
+	if err := sr.Err(); err != nil {
+		return err
+	}
+	if resp == nil {
+		return nil
+	}
+	return json.Unmarshal(raw, resp)
+}
@@ -0,0 +1,74 @@
+package wrapper
+
+import (
+	"github.com/nlopes/slack"
+)
+
+// CreateChannel creates a public channel as the bot, which is a member of it
+func (s *Slack) CreateChannel(name string) (*slack.Channel, error) {
+	if err := s.guard("CreateChannel"); err != nil {
+		return nil, err
+	}
+	return s.Bot.CreateConversationContext(s.callContext(), name, false)
+}
+
+// InviteUsers invites users to a channel as the bot. Users who are already in
+// the channel are not an error.
+func (s *Slack) InviteUsers(channelID string, users ...string) error {
+	if err := s.guard("InviteUsers"); err != nil {
+		return err
+	}
+	_, err := s.Bot.InviteUsersToConversationContext(s.callContext(), channelID, users...)
+	if err != nil && err.Error() == "already_in_channel" {
+		return nil
+	}
+	return err
+}
+
+// SetTopic sets a channel's topic as the bot
+func (s *Slack) SetTopic(channelID, topic string) error {
+	if err := s.guard("SetTopic"); err != nil {
+		return err
+	}
+	_, err := s.Bot.SetTopicOfConversationContext(s.callContext(), channelID, topic)
+	return err
+}
+
+// SetPurpose sets a channel's purpose as the bot
+func (s *Slack) SetPurpose(channelID, purpose string) error {
+	if err := s.guard("SetPurpose"); err != nil {
+		return err
+	}
+	_, err := s.Bot.SetPurposeOfConversationContext(s.callContext(), channelID, purpose)
+	return err
+}
+
+// AddPin pins an item to a channel as the bot
+func (s *Slack) AddPin(channel string, item slack.ItemRef) error {
+	if err := s.guard("AddPin"); err != nil {
+		return err
+	}
+	return s.Bot.AddPinContext(s.callContext(), channel, item)
+}
+
+// UsergroupMembers returns the IDs of the users in a user group, through the
+// Directory's cache
+func (s *Slack) UsergroupMembers(usergroup string) ([]string, error) {
+	if err := s.guard("UsergroupMembers"); err != nil {
+		return nil, err
+	}
+	return s.Directory.UsergroupMembers(s.callContext(), usergroup)
+}
+
+// ThreadOf returns the timestamp of the thread a message is in, the message's
+// own timestamp if it is not a reply
+func (s *Slack) ThreadOf(channelID, ts string) (string, error) {
+	msgs, _, _, err := s.Bot.GetConversationRepliesContext(s.callContext(), &slack.GetConversationRepliesParameters{ChannelID: channelID, Timestamp: ts, Limit: 1})
+	if err != nil {
+		return "", err
+	}
+	if len(msgs) == 0 || msgs[0].ThreadTimestamp == "" {
+		return ts, nil
+	}
+	return msgs[0].ThreadTimestamp, nil
+}
@@ -0,0 +1,256 @@
+package wrapper
+
+import (
+	"context"
+	"time"
+
+	"github.com/nlopes/slack"
+
+	"github.com/skybet/go-helpdesk/clock"
+)
+
+// defaultPageSize is the number of items asked for in each page, Slack
+// recommends no more than 200
+const defaultPageSize = 200
+
+// ConversationsAPI is the part of the Slack API the Pager reads
+type ConversationsAPI interface {
+	GetConversationsContext(ctx context.Context, params *slack.GetConversationsParameters) ([]slack.Channel, string, error)
+	GetUsersInConversationContext(ctx context.Context, params *slack.GetUsersInConversationParameters) ([]string, string, error)
+	GetConversationHistoryContext(ctx context.Context, params *slack.GetConversationHistoryParameters) (*slack.GetConversationHistoryResponse, error)
+	GetConversationRepliesContext(ctx context.Context, params *slack.GetConversationRepliesParameters) ([]slack.Message, bool, string, error)
+}
+
+// Pager follows the cursors of the Conversations API's paginated methods. A
+// page which is rate limited is asked for again once Slack's Retry-After has
+// passed.
+type Pager struct {
+	API ConversationsAPI
+	// PageSize is the limit asked for in each page unless the parameters
+	// give one, 200 if zero
+	PageSize int
+	// MaxWaits is how many times a page is rate limited before its error is
+	// returned, 3 if zero and none if negative
+	MaxWaits int
+	// Clock, if set, replaces the wall clock
+	Clock clock.Clock
+}
+
+// EachConversation calls fn with every page of the conversations matching
+// params until there are no more or fn returns an error
+func (p *Pager) EachConversation(ctx context.Context, params slack.GetConversationsParameters, fn func(page []slack.Channel) error) error {
+	if params.Limit == 0 {
+		params.Limit = p.pageSize()
+	}
+	for {
+		var page []slack.Channel
+		next, err := p.fetch(ctx, func() (next string, err error) {
+			page, next, err = p.API.GetConversationsContext(ctx, &params)
+			return next, err
+		})
+		if err != nil {
+			return err
+		}
+		if err := fn(page); err != nil {
+			return err
+		}
+		if next == "" {
+			return nil
+		}
+		params.Cursor = next
+	}
+}
+
+// EachMember calls fn with every page of the IDs of a conversation's members
+// until there are no more or fn returns an error
+func (p *Pager) EachMember(ctx context.Context, channelID string, fn func(page []string) error) error {
+	params := slack.GetUsersInConversationParameters{ChannelID: channelID, Limit: p.pageSize()}
+	for {
+		var page []string
+		next, err := p.fetch(ctx, func() (next string, err error) {
+			page, next, err = p.API.GetUsersInConversationContext(ctx, &params)
+			return next, err
+		})
+		if err != nil {
+			return err
+		}
+		if err := fn(page); err != nil {
+			return err
+		}
+		if next == "" {
+			return nil
+		}
+		params.Cursor = next
+	}
+}
+
+// EachMessage calls fn with every page of a conversation's history matching
+// params, newest first, until there are no more or fn returns an error
+func (p *Pager) EachMessage(ctx context.Context, params slack.GetConversationHistoryParameters, fn func(page []slack.Message) error) error {
+	if params.Limit == 0 {
+		params.Limit = p.pageSize()
+	}
+	for {
+		var page []slack.Message
+		next, err := p.fetch(ctx, func() (string, error) {
+			resp, err := p.API.GetConversationHistoryContext(ctx, &params)
+			if err != nil {
+				return "", err
+			}
+			page = resp.Messages
+			if !resp.HasMore {
+				return "", nil
+			}
+			return resp.ResponseMetaData.NextCursor, nil
+		})
+		if err != nil {
+			return err
+		}
+		if err := fn(page); err != nil {
+			return err
+		}
+		if next == "" {
+			return nil
+		}
+		params.Cursor = next
+	}
+}
+
+// EachReply calls fn with every page of the replies to a thread, oldest
+// first, until there are no more or fn returns an error. The message which
+// started the thread is not a reply, even though Slack returns it on every
+// page.
+func (p *Pager) EachReply(ctx context.Context, channelID, threadTS string, fn func(page []slack.Message) error) error {
+	params := slack.GetConversationRepliesParameters{ChannelID: channelID, Timestamp: threadTS, Limit: p.pageSize()}
+	for {
+		var page []slack.Message
+		next, err := p.fetch(ctx, func() (string, error) {
+			msgs, more, next, err := p.API.GetConversationRepliesContext(ctx, &params)
+			if err != nil {
+				return "", err
+			}
+			page = page[:0]
+			for _, m := range msgs {
+				if m.Timestamp != threadTS {
+					page = append(page, m)
+				}
+			}
+			if !more {
+				return "", nil
+			}
+			return next, nil
+		})
+		if err != nil {
+			return err
+		}
+		if err := fn(page); err != nil {
+			return err
+		}
+		if next == "" {
+			return nil
+		}
+		params.Cursor = next
+	}
+}
+
+// ListConversations returns every conversation matching params
+func (p *Pager) ListConversations(ctx context.Context, params slack.GetConversationsParameters) ([]slack.Channel, error) {
+	var channels []slack.Channel
+	err := p.EachConversation(ctx, params, func(page []slack.Channel) error {
+		channels = append(channels, page...)
+		return nil
+	})
+	return channels, err
+}
+
+// GetConversationMembers returns the IDs of every member of a conversation
+func (p *Pager) GetConversationMembers(ctx context.Context, channelID string) ([]string, error) {
+	var members []string
+	err := p.EachMember(ctx, channelID, func(page []string) error {
+		members = append(members, page...)
+		return nil
+	})
+	return members, err
+}
+
+// GetConversationHistory returns every message in a conversation's history
+// matching params, newest first. Bound it with params' Oldest and Latest.
+func (p *Pager) GetConversationHistory(ctx context.Context, params slack.GetConversationHistoryParameters) ([]slack.Message, error) {
+	var msgs []slack.Message
+	err := p.EachMessage(ctx, params, func(page []slack.Message) error {
+		msgs = append(msgs, page...)
+		return nil
+	})
+	return msgs, err
+}
+
+// GetThreadReplies returns every reply to a thread, oldest first, without the
+// message which started it
+func (p *Pager) GetThreadReplies(ctx context.Context, channelID, threadTS string) ([]slack.Message, error) {
+	var msgs []slack.Message
+	err := p.EachReply(ctx, channelID, threadTS, func(page []slack.Message) error {
+		msgs = append(msgs, page...)
+		return nil
+	})
+	return msgs, err
+}
+
+// fetch gets a page, waiting out rate limits, and returns the cursor of the
+// next page
+func (p *Pager) fetch(ctx context.Context, get func() (string, error)) (string, error) {
+	maxWaits := p.MaxWaits
+	if maxWaits == 0 {
+		maxWaits = 3
+	}
+	for waits := 0; ; waits++ {
+		next, err := get()
+		limited, ok := err.(*slack.RateLimitedError)
+		if !ok || waits >= maxWaits {
+			return next, err
+		}
+		wait := limited.RetryAfter
+		if wait <= 0 {
+			wait = time.Second
+		}
+		timer := clock.Or(p.Clock).NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return "", ctx.Err()
+		case <-timer.C():
+		}
+	}
+}
+
+func (p *Pager) pageSize() int {
+	if p.PageSize > 0 {
+		return p.PageSize
+	}
+	return defaultPageSize
+}
+
+// ListConversations returns every conversation matching params, see Pager
+func (s *Slack) ListConversations(ctx context.Context, params slack.GetConversationsParameters) ([]slack.Channel, error) {
+	if err := s.guardTypes("ListConversations", params.Types); err != nil {
+		return nil, err
+	}
+	return (&Pager{API: s.Bot}).ListConversations(ctx, params)
+}
+
+// GetConversationMembers returns the IDs of every member of a conversation,
+// see Pager
+func (s *Slack) GetConversationMembers(ctx context.Context, channelID string) ([]string, error) {
+	if err := s.guardChannel("GetConversationMembers", channelID); err != nil {
+		return nil, err
+	}
+	return (&Pager{API: s.Bot}).GetConversationMembers(ctx, channelID)
+}
+
+// GetConversationHistory returns every message in a conversation's history
+// matching params, see Pager
+func (s *Slack) GetConversationHistory(ctx context.Context, params slack.GetConversationHistoryParameters) ([]slack.Message, error) {
+	if err := s.guardChannel("GetConversationHistory", params.ChannelID); err != nil {
+		return nil, err
+	}
+	return (&Pager{API: s.Bot}).GetConversationHistory(ctx, params)
+}
@@ -0,0 +1,144 @@
+package wrapper
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nlopes/slack"
+
+	"github.com/skybet/go-helpdesk/clock"
+)
+
+// fakeConversationsAPI serves each list in pages of the limit asked for,
+// rate limiting the calls in limited
+type fakeConversationsAPI struct {
+	members []string
+	history []slack.Message
+	mu      sync.Mutex
+	calls   int
+	limited map[int]bool
+}
+
+// page returns the page of n items at cursor, the next cursor and whether
+// the call was rate limited
+func (f *fakeConversationsAPI) page(cursor string, limit, n int) (int, int, string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls++
+	if f.limited[f.calls] {
+		return 0, 0, "", &slack.RateLimitedError{RetryAfter: 30 * time.Second}
+	}
+	start, _ := strconv.Atoi(cursor)
+	end := start + limit
+	if end >= n {
+		return start, n, "", nil
+	}
+	return start, end, strconv.Itoa(end), nil
+}
+
+func (f *fakeConversationsAPI) GetConversationsContext(ctx context.Context, params *slack.GetConversationsParameters) ([]slack.Channel, string, error) {
+	start, end, next, err := f.page(params.Cursor, params.Limit, 3)
+	var page []slack.Channel
+	for i := start; i < end; i++ {
+		c := slack.Channel{}
+		c.ID = "C" + strconv.Itoa(i)
+		page = append(page, c)
+	}
+	return page, next, err
+}
+
+func (f *fakeConversationsAPI) GetUsersInConversationContext(ctx context.Context, params *slack.GetUsersInConversationParameters) ([]string, string, error) {
+	start, end, next, err := f.page(params.Cursor, params.Limit, len(f.members))
+	if err != nil {
+		return nil, "", err
+	}
+	return f.members[start:end], next, nil
+}
+
+func (f *fakeConversationsAPI) GetConversationHistoryContext(ctx context.Context, params *slack.GetConversationHistoryParameters) (*slack.GetConversationHistoryResponse, error) {
+	start, end, next, err := f.page(params.Cursor, params.Limit, len(f.history))
+	if err != nil {
+		return nil, err
+	}
+	resp := &slack.GetConversationHistoryResponse{Messages: f.history[start:end], HasMore: next != ""}
+	resp.ResponseMetaData.NextCursor = next
+	return resp, nil
+}
+
+func (f *fakeConversationsAPI) GetConversationRepliesContext(ctx context.Context, params *slack.GetConversationRepliesParameters) ([]slack.Message, bool, string, error) {
+	start, end, next, err := f.page(params.Cursor, params.Limit, len(f.history))
+	if err != nil {
+		return nil, false, "", err
+	}
+	// Slack starts every page with the message the thread is on
+	page := append([]slack.Message{{Msg: slack.Msg{Timestamp: params.Timestamp}}}, f.history[start:end]...)
+	return page, next != "", next, nil
+}
+
+func TestPager(t *testing.T) {
+	api := &fakeConversationsAPI{members: []string{"U1", "U2", "U3", "U4", "U5"}}
+	for i := 0; i < 5; i++ {
+		api.history = append(api.history, slack.Message{Msg: slack.Msg{Timestamp: strconv.Itoa(5 - i)}})
+	}
+	p := &Pager{API: api, PageSize: 2}
+
+	members, err := p.GetConversationMembers(context.Background(), "C1")
+	if err != nil || !reflect.DeepEqual(members, api.members) || api.calls != 3 {
+		t.Errorf("Expected every member over 3 pages, got %v after %d calls (%v)", members, api.calls, err)
+	}
+	msgs, err := p.GetConversationHistory(context.Background(), slack.GetConversationHistoryParameters{ChannelID: "C1"})
+	if err != nil || len(msgs) != 5 || msgs[4].Timestamp != "1" {
+		t.Errorf("Expected the whole history, got %v (%v)", msgs, err)
+	}
+	channels, err := p.ListConversations(context.Background(), slack.GetConversationsParameters{Limit: 1})
+	if err != nil || len(channels) != 3 || channels[2].ID != "C2" {
+		t.Errorf("Expected every channel a page at a time, got %v (%v)", channels, err)
+	}
+
+	var pages int
+	stop := errors.New("stop")
+	if err := p.EachMember(context.Background(), "C1", func(page []string) error {
+		pages++
+		return stop
+	}); err != stop || pages != 1 {
+		t.Errorf("Expected the callback's error to stop paging, got %v after %d pages", err, pages)
+	}
+}
+
+func TestPagerRateLimited(t *testing.T) {
+	c := clock.NewFake(time.Date(2026, 10, 14, 9, 0, 0, 0, time.UTC))
+	api := &fakeConversationsAPI{members: []string{"U1", "U2", "U3"}, limited: map[int]bool{2: true}}
+	p := &Pager{API: api, PageSize: 2, Clock: c}
+
+	done := make(chan []string)
+	go func() {
+		members, err := p.GetConversationMembers(context.Background(), "C1")
+		if err != nil {
+			t.Errorf("Unexpected error: %s", err)
+		}
+		done <- members
+	}()
+	c.BlockUntil(1)
+	c.Advance(30 * time.Second)
+	if members := <-done; len(members) != 3 || api.calls != 3 {
+		t.Errorf("Expected the rate limited page to be asked for again, got %v after %d calls", members, api.calls)
+	}
+
+	api = &fakeConversationsAPI{members: []string{"U1"}, limited: map[int]bool{1: true}}
+	p = &Pager{API: api, MaxWaits: -1}
+	if _, err := p.GetConversationMembers(context.Background(), "C1"); err == nil {
+		t.Errorf("Expected the rate limit error once the waits are used up")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	api = &fakeConversationsAPI{members: []string{"U1"}, limited: map[int]bool{1: true}}
+	p = &Pager{API: api}
+	if _, err := p.GetConversationMembers(ctx, "C1"); err != context.Canceled {
+		t.Errorf("Expected waiting to stop with the context, got %v", err)
+	}
+}
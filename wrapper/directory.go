@@ -0,0 +1,490 @@
+package wrapper
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/nlopes/slack"
+)
+
+// DirectoryAPI is the part of the slack client used to look up users and
+// channels
+type DirectoryAPI interface {
+	GetUsersContext(ctx context.Context) ([]slack.User, error)
+	GetUserInfoContext(ctx context.Context, user string) (*slack.User, error)
+	GetConversationsContext(ctx context.Context, params *slack.GetConversationsParameters) ([]slack.Channel, string, error)
+	GetConversationInfoContext(ctx context.Context, channelID string, includeLocale bool) (*slack.Channel, error)
+	GetUserGroupMembersContext(ctx context.Context, userGroup string) ([]string, error)
+}
+
+// DirectoryConfig configures caching of the user and channel lists
+type DirectoryConfig struct {
+	// TTL is how long a list is served from memory before it is fetched
+	// again, zero disables caching
+	TTL time.Duration
+	// RefreshInterval is how often Run refreshes the lists in the background,
+	// it defaults to TTL so readers never wait on Slack
+	RefreshInterval time.Duration
+	// MaxUsers and MaxChannels bound how many entries are held in memory,
+	// lookups of entries beyond the bound go to Slack. Zero means no bound.
+	MaxUsers    int
+	MaxChannels int
+	// UserLookups and ChannelLookups bound the caches of users and channels
+	// looked up one at a time because they are not in the lists, such as
+	// users beyond MaxUsers, and Usergroups the cache of usergroup members.
+	// The least recently used entries are evicted to make room, zero caches
+	// none.
+	UserLookups    int
+	ChannelLookups int
+	Usergroups     int
+	// ChannelTypes are the conversation types to list, public channels only
+	// by default
+	ChannelTypes []string
+}
+
+// Directory is a read-through cache of the users.list and conversations.list
+// endpoints, which are slow and heavily rate limited. It is safe for
+// concurrent use and concurrent misses share a single call to Slack.
+//
+// Slack does not support conditional requests on these endpoints so freshness
+// is time based, unless the change events passed to Apply say an entry has
+// changed sooner. If a refresh fails the previous list is served until Slack
+// recovers.
+type Directory struct {
+	api      DirectoryAPI
+	config   DirectoryConfig
+	now      func() time.Time
+	users    listCache
+	channels listCache
+
+	userLookups    *lru
+	channelLookups *lru
+	groups         *lru
+}
+
+// CacheStats counts the lookups served from a cache and those which went to
+// Slack, and the entries evicted to keep the cache within its Limit
+type CacheStats struct {
+	Entries   int   `json:"entries"`
+	Limit     int   `json:"limit"`
+	Hits      int64 `json:"hits"`
+	Misses    int64 `json:"misses"`
+	Evictions int64 `json:"evictions"`
+}
+
+// HitRate returns the fraction of lookups served from the cache
+func (s CacheStats) HitRate() float64 {
+	if s.Hits+s.Misses == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(s.Hits+s.Misses)
+}
+
+// listCache holds one cached list and the index of its entries by ID
+type listCache struct {
+	mu      sync.Mutex
+	fetched time.Time
+	list    interface{}
+	index   map[string]int
+	loading chan struct{}
+	err     error
+	hits    int64
+	misses  int64
+	// edits are the changes made while loading, which are applied again to
+	// the loaded list as it may have been fetched before them
+	edits []edit
+	// restored is set when the list came from a snapshot and has not been
+	// loaded since, it is served even once stale while it is revalidated
+	restored bool
+}
+
+// edit changes a cached list, returning the new list and index. Lists handed
+// to callers are shared so edits must copy them rather than change them.
+type edit func(list interface{}, index map[string]int) (interface{}, map[string]int)
+
+// NewDirectory returns a Directory reading from api
+func NewDirectory(api DirectoryAPI, config DirectoryConfig) *Directory {
+	if config.RefreshInterval == 0 {
+		config.RefreshInterval = config.TTL
+	}
+	if len(config.ChannelTypes) == 0 {
+		config.ChannelTypes = []string{"public_channel"}
+	}
+	return &Directory{
+		api:            api,
+		config:         config,
+		now:            time.Now,
+		userLookups:    newLRU(config.UserLookups),
+		channelLookups: newLRU(config.ChannelLookups),
+		groups:         newLRU(config.Usergroups),
+	}
+}
+
+// Users returns every user in the workspace, up to MaxUsers
+func (d *Directory) Users(ctx context.Context) ([]slack.User, error) {
+	list, _, cached, err := d.users.get(ctx, d, d.loadUsers)
+	d.users.count(cached)
+	if err != nil {
+		return nil, err
+	}
+	return list.([]slack.User), nil
+}
+
+// User returns a single user, from the cached list when possible
+func (d *Directory) User(ctx context.Context, id string) (*slack.User, error) {
+	if d.config.TTL <= 0 {
+		d.users.count(false)
+		return d.api.GetUserInfoContext(ctx, id)
+	}
+	// The index is only valid for the list it was returned with, edits
+	// replace both
+	list, index, cached, _ := d.users.get(ctx, d, d.loadUsers)
+	if list != nil {
+		if i, ok := index[id]; ok {
+			d.users.count(cached)
+			u := list.([]slack.User)[i]
+			return &u, nil
+		}
+	}
+	d.users.count(false)
+	// Users beyond MaxUsers or who joined since the last refresh are not in
+	// the list
+	if u, ok := d.userLookups.get(id, d.config.TTL, d.now()); ok {
+		u := u.(slack.User)
+		return &u, nil
+	}
+	u, err := d.api.GetUserInfoContext(ctx, id)
+	if err == nil {
+		d.userLookups.add(id, *u, d.now())
+	}
+	return u, err
+}
+
+// Channels returns every channel of the configured types, up to MaxChannels
+func (d *Directory) Channels(ctx context.Context) ([]slack.Channel, error) {
+	list, _, cached, err := d.channels.get(ctx, d, d.loadChannels)
+	d.channels.count(cached)
+	if err != nil {
+		return nil, err
+	}
+	return list.([]slack.Channel), nil
+}
+
+// Channel returns a single channel, from the cached list when possible
+func (d *Directory) Channel(ctx context.Context, id string) (*slack.Channel, error) {
+	if d.config.TTL <= 0 {
+		d.channels.count(false)
+		return d.api.GetConversationInfoContext(ctx, id, false)
+	}
+	list, index, cached, _ := d.channels.get(ctx, d, d.loadChannels)
+	if list != nil {
+		if i, ok := index[id]; ok {
+			d.channels.count(cached)
+			c := list.([]slack.Channel)[i]
+			return &c, nil
+		}
+	}
+	d.channels.count(false)
+	// Channels of other types or created since the last refresh are not in
+	// the list
+	if c, ok := d.channelLookups.get(id, d.config.TTL, d.now()); ok {
+		c := c.(slack.Channel)
+		return &c, nil
+	}
+	c, err := d.api.GetConversationInfoContext(ctx, id, false)
+	if err == nil {
+		d.channelLookups.add(id, *c, d.now())
+	}
+	return c, err
+}
+
+// UsergroupMembers returns the IDs of the users in a usergroup, cached for the
+// TTL like the lists
+func (d *Directory) UsergroupMembers(ctx context.Context, id string) ([]string, error) {
+	if d.config.TTL <= 0 {
+		return d.api.GetUserGroupMembersContext(ctx, id)
+	}
+	if members, ok := d.groups.get(id, d.config.TTL, d.now()); ok {
+		return append([]string(nil), members.([]string)...), nil
+	}
+	members, err := d.api.GetUserGroupMembersContext(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	d.groups.add(id, append([]string(nil), members...), d.now())
+	return members, nil
+}
+
+// Apply updates the cache from a Slack change event: user_change, team_join,
+// channel_rename, channel_archive or subteam_updated, so that changes show
+// within seconds rather than after the TTL. It reports whether the event was
+// one of those. Events are ignored if caching is disabled.
+func (d *Directory) Apply(ev interface{}) bool {
+	switch ev := ev.(type) {
+	case *slack.UserChangeEvent:
+		d.putUser(ev.User)
+	case *slack.TeamJoinEvent:
+		d.putUser(ev.User)
+	case *slack.ChannelRenameEvent:
+		d.channelLookups.update(ev.Channel.ID, func(v interface{}) interface{} {
+			c := v.(slack.Channel)
+			c.Name = ev.Channel.Name
+			return c
+		})
+		d.channels.update(func(list interface{}, index map[string]int) (interface{}, map[string]int) {
+			i, ok := index[ev.Channel.ID]
+			if !ok {
+				return list, index
+			}
+			channels := append([]slack.Channel(nil), list.([]slack.Channel)...)
+			channels[i].Name = ev.Channel.Name
+			return channels, index
+		})
+	case *slack.ChannelArchiveEvent:
+		d.channelLookups.update(ev.Channel, func(v interface{}) interface{} {
+			c := v.(slack.Channel)
+			c.IsArchived = true
+			return c
+		})
+		// Archived channels are not listed
+		d.channels.update(func(list interface{}, index map[string]int) (interface{}, map[string]int) {
+			i, ok := index[ev.Channel]
+			if !ok {
+				return list, index
+			}
+			old := list.([]slack.Channel)
+			channels := append(append([]slack.Channel(nil), old[:i]...), old[i+1:]...)
+			newIndex := make(map[string]int, len(channels))
+			for j, c := range channels {
+				newIndex[c.ID] = j
+			}
+			return channels, newIndex
+		})
+	case *slack.SubteamUpdatedEvent:
+		if ev.Subteam.Users == nil {
+			// Slack leaves the members out of some updates, fetch them again
+			d.groups.remove(ev.Subteam.ID)
+		} else {
+			d.groups.add(ev.Subteam.ID, append([]string(nil), ev.Subteam.Users...), d.now())
+		}
+	default:
+		return false
+	}
+	return true
+}
+
+// putUser replaces or adds a user in the cached list, users beyond MaxUsers
+// are left to be looked up individually
+func (d *Directory) putUser(u slack.User) {
+	d.userLookups.update(u.ID, func(interface{}) interface{} { return u })
+	d.users.update(func(list interface{}, index map[string]int) (interface{}, map[string]int) {
+		old := list.([]slack.User)
+		i, ok := index[u.ID]
+		if !ok && d.config.MaxUsers > 0 && len(old) >= d.config.MaxUsers {
+			return list, index
+		}
+		users := append(make([]slack.User, 0, len(old)+1), old...)
+		if ok {
+			users[i] = u
+			return users, index
+		}
+		newIndex := make(map[string]int, len(index)+1)
+		for id, j := range index {
+			newIndex[id] = j
+		}
+		newIndex[u.ID] = len(users)
+		return append(users, u), newIndex
+	})
+}
+
+// Stats returns the size, hits, misses and evictions of each cache: the users
+// and channels lists, the users and channels looked up one at a time and the
+// usergroups. List lookups which go to Slack are misses, including when
+// caching is disabled.
+func (d *Directory) Stats() map[string]CacheStats {
+	users, channels := d.users.stats(), d.channels.stats()
+	users.Limit, channels.Limit = d.config.MaxUsers, d.config.MaxChannels
+	return map[string]CacheStats{
+		"users":           users,
+		"channels":        channels,
+		"user_lookups":    d.userLookups.stats(),
+		"channel_lookups": d.channelLookups.stats(),
+		"usergroups":      d.groups.stats(),
+	}
+}
+
+// Refresh fetches both lists from Slack now, regardless of their age
+func (d *Directory) Refresh(ctx context.Context) error {
+	if _, _, err := d.users.refresh(ctx, d, d.loadUsers); err != nil {
+		return err
+	}
+	_, _, err := d.channels.refresh(ctx, d, d.loadChannels)
+	return err
+}
+
+// Run refreshes the lists every RefreshInterval until ctx is cancelled, it
+// does nothing if caching is disabled
+func (d *Directory) Run(ctx context.Context) {
+	if d.config.TTL <= 0 {
+		return
+	}
+	t := time.NewTicker(d.config.RefreshInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			d.Refresh(ctx)
+		}
+	}
+}
+
+func (d *Directory) loadUsers(ctx context.Context) (interface{}, map[string]int, error) {
+	users, err := d.api.GetUsersContext(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	if d.config.MaxUsers > 0 && len(users) > d.config.MaxUsers {
+		// Copy so the rest of the response can be garbage collected
+		users = append([]slack.User(nil), users[:d.config.MaxUsers]...)
+	}
+	index := make(map[string]int, len(users))
+	for i, u := range users {
+		index[u.ID] = i
+	}
+	return users, index, nil
+}
+
+func (d *Directory) loadChannels(ctx context.Context) (interface{}, map[string]int, error) {
+	var channels []slack.Channel
+	params := &slack.GetConversationsParameters{ExcludeArchived: "true", Limit: 1000, Types: d.config.ChannelTypes}
+	for {
+		page, cursor, err := d.api.GetConversationsContext(ctx, params)
+		if err != nil {
+			return nil, nil, err
+		}
+		channels = append(channels, page...)
+		if d.config.MaxChannels > 0 && len(channels) >= d.config.MaxChannels {
+			channels = channels[:d.config.MaxChannels]
+			break
+		}
+		if cursor == "" {
+			break
+		}
+		params.Cursor = cursor
+	}
+	index := make(map[string]int, len(channels))
+	for i, c := range channels {
+		index[c.ID] = i
+	}
+	return channels, index, nil
+}
+
+type loader func(ctx context.Context) (list interface{}, index map[string]int, err error)
+
+// get returns the cached list with its index, loading them if they are
+// missing or older than the TTL, and whether they were served without loading.
+// The stale list is returned if loading fails.
+func (c *listCache) get(ctx context.Context, d *Directory, load loader) (interface{}, map[string]int, bool, error) {
+	if d.config.TTL <= 0 {
+		list, index, err := load(ctx)
+		return list, index, false, err
+	}
+	c.mu.Lock()
+	if c.list != nil && d.now().Sub(c.fetched) < d.config.TTL {
+		list, index := c.list, c.index
+		c.mu.Unlock()
+		return list, index, true, nil
+	}
+	if c.list != nil && c.restored {
+		// Readers do not wait on Slack after a restart, the snapshot is
+		// served while it is loaded again
+		if c.loading == nil {
+			c.loading = make(chan struct{})
+			go c.load(d, load)
+		}
+		list, index := c.list, c.index
+		c.mu.Unlock()
+		return list, index, true, nil
+	}
+	c.mu.Unlock()
+	list, index, err := c.refresh(ctx, d, load)
+	if err != nil && list != nil && ctx.Err() == nil {
+		return list, index, false, nil
+	}
+	return list, index, false, err
+}
+
+func (c *listCache) count(hit bool) {
+	if hit {
+		atomic.AddInt64(&c.hits, 1)
+	} else {
+		atomic.AddInt64(&c.misses, 1)
+	}
+}
+
+func (c *listCache) stats() CacheStats {
+	c.mu.Lock()
+	entries := len(c.index)
+	c.mu.Unlock()
+	return CacheStats{Entries: entries, Hits: atomic.LoadInt64(&c.hits), Misses: atomic.LoadInt64(&c.misses)}
+}
+
+// refresh loads the list and its index, joining a load already in progress
+func (c *listCache) refresh(ctx context.Context, d *Directory, load loader) (interface{}, map[string]int, error) {
+	c.mu.Lock()
+	if c.loading == nil {
+		c.loading = make(chan struct{})
+		go c.load(d, load)
+	}
+	loading := c.loading
+	c.mu.Unlock()
+
+	select {
+	case <-ctx.Done():
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		return c.list, c.index, ctx.Err()
+	case <-loading:
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.list, c.index, c.err
+}
+
+// load runs without the caller's context so that one impatient caller does not
+// fail the load for everyone waiting on it
+func (c *listCache) load(d *Directory, load loader) {
+	list, index, err := load(context.Background())
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.err = err
+	if err == nil {
+		for _, e := range c.edits {
+			list, index = e(list, index)
+		}
+		c.list, c.index = list, index
+		c.fetched = d.now()
+	}
+	c.edits = nil
+	c.restored = false
+	close(c.loading)
+	c.loading = nil
+}
+
+// update applies e to the cached list, and to the list being loaded if there
+// is one. Lists which have not been loaded yet are left alone, they will be
+// up to date when they are.
+func (c *listCache) update(e edit) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.loading != nil {
+		c.edits = append(c.edits, e)
+	}
+	if c.list != nil {
+		c.list, c.index = e(c.list, c.index)
+	}
+}
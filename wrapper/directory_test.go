@@ -0,0 +1,322 @@
+package wrapper
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/nlopes/slack"
+)
+
+type fakeDirectoryAPI struct {
+	users     []slack.User
+	channels  [][]slack.Channel
+	groups    map[string][]string
+	err       error
+	block     chan struct{}
+	listCalls int32
+	infoCalls int32
+}
+
+func (f *fakeDirectoryAPI) GetUsersContext(ctx context.Context) ([]slack.User, error) {
+	atomic.AddInt32(&f.listCalls, 1)
+	if f.block != nil {
+		<-f.block
+	}
+	return f.users, f.err
+}
+
+func (f *fakeDirectoryAPI) GetUserInfoContext(ctx context.Context, user string) (*slack.User, error) {
+	atomic.AddInt32(&f.infoCalls, 1)
+	return &slack.User{ID: user}, nil
+}
+
+func (f *fakeDirectoryAPI) GetConversationsContext(ctx context.Context, params *slack.GetConversationsParameters) ([]slack.Channel, string, error) {
+	atomic.AddInt32(&f.listCalls, 1)
+	page := 0
+	if params.Cursor != "" {
+		page = int(params.Cursor[0] - '0')
+	}
+	cursor := ""
+	if page+1 < len(f.channels) {
+		cursor = string('0' + byte(page+1))
+	}
+	return f.channels[page], cursor, f.err
+}
+
+func (f *fakeDirectoryAPI) GetConversationInfoContext(ctx context.Context, channelID string, includeLocale bool) (*slack.Channel, error) {
+	atomic.AddInt32(&f.infoCalls, 1)
+	c := &slack.Channel{}
+	c.ID = channelID
+	return c, nil
+}
+
+func (f *fakeDirectoryAPI) GetUserGroupMembersContext(ctx context.Context, userGroup string) ([]string, error) {
+	atomic.AddInt32(&f.infoCalls, 1)
+	return f.groups[userGroup], nil
+}
+
+func channel(id string) slack.Channel {
+	var c slack.Channel
+	c.ID = id
+	return c
+}
+
+func TestDirectoryCachesUntilTTL(t *testing.T) {
+	api := &fakeDirectoryAPI{users: []slack.User{{ID: "U1"}, {ID: "U2"}}}
+	d := NewDirectory(api, DirectoryConfig{TTL: time.Minute})
+	now := time.Now()
+	d.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		users, err := d.Users(context.Background())
+		if err != nil || len(users) != 2 {
+			t.Fatalf("Expected 2 users, got %v %v", users, err)
+		}
+	}
+	u, err := d.User(context.Background(), "U2")
+	if err != nil || u.ID != "U2" {
+		t.Fatalf("Expected U2, got %v %v", u, err)
+	}
+	if api.listCalls != 1 || api.infoCalls != 0 {
+		t.Errorf("Expected a single users.list call, got %d list and %d info calls", api.listCalls, api.infoCalls)
+	}
+	if s := d.Stats()["users"]; s != (CacheStats{Entries: 2, Hits: 3, Misses: 1}) || s.HitRate() != 0.75 {
+		t.Errorf("Expected the first list to miss and the rest to hit, got %+v", s)
+	}
+
+	now = now.Add(time.Minute)
+	d.Users(context.Background())
+	if api.listCalls != 2 {
+		t.Errorf("Expected the list to be fetched again after the TTL, got %d calls", api.listCalls)
+	}
+}
+
+func TestDirectoryServesStaleOnError(t *testing.T) {
+	api := &fakeDirectoryAPI{users: []slack.User{{ID: "U1"}}}
+	d := NewDirectory(api, DirectoryConfig{TTL: time.Minute})
+	now := time.Now()
+	d.now = func() time.Time { return now }
+	d.Users(context.Background())
+
+	api.err = errors.New("ratelimited")
+	now = now.Add(time.Hour)
+	users, err := d.Users(context.Background())
+	if err != nil || len(users) != 1 {
+		t.Errorf("Expected the stale list to be served, got %v %v", users, err)
+	}
+	if err := d.Refresh(context.Background()); err == nil {
+		t.Errorf("Expected Refresh to report the error")
+	}
+}
+
+func TestDirectorySharesConcurrentLoads(t *testing.T) {
+	api := &fakeDirectoryAPI{users: []slack.User{{ID: "U1"}}, block: make(chan struct{})}
+	d := NewDirectory(api, DirectoryConfig{TTL: time.Minute})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := d.Users(context.Background()); err != nil {
+				t.Errorf("Unexpected error: %s", err)
+			}
+		}()
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(api.block)
+	wg.Wait()
+	if api.listCalls != 1 {
+		t.Errorf("Expected concurrent misses to share one call, got %d", api.listCalls)
+	}
+}
+
+func TestDirectoryBounds(t *testing.T) {
+	api := &fakeDirectoryAPI{
+		users:    []slack.User{{ID: "U1"}, {ID: "U2"}, {ID: "U3"}},
+		channels: [][]slack.Channel{{channel("C1"), channel("C2")}, {channel("C3")}},
+	}
+	d := NewDirectory(api, DirectoryConfig{TTL: time.Minute, MaxUsers: 2, MaxChannels: 2})
+
+	users, _ := d.Users(context.Background())
+	if len(users) != 2 {
+		t.Errorf("Expected the users to be bounded to 2, got %d", len(users))
+	}
+	if u, err := d.User(context.Background(), "U3"); err != nil || u.ID != "U3" || api.infoCalls != 1 {
+		t.Errorf("Expected users beyond the bound to be looked up, got %v %v", u, err)
+	}
+	channels, _ := d.Channels(context.Background())
+	if len(channels) != 2 {
+		t.Errorf("Expected the channels to be bounded to 2, got %d", len(channels))
+	}
+	if api.listCalls != 2 {
+		t.Errorf("Expected paging to stop once the bound was reached, got %d list calls", api.listCalls)
+	}
+}
+
+func TestDirectoryLookups(t *testing.T) {
+	api := &fakeDirectoryAPI{channels: [][]slack.Channel{{}}}
+	d := NewDirectory(api, DirectoryConfig{TTL: time.Minute, UserLookups: 2, ChannelLookups: 1})
+	for _, id := range []string{"U1", "U2", "U1", "U3", "U1", "U2"} {
+		d.User(context.Background(), id)
+	}
+	if api.infoCalls != 4 {
+		t.Errorf("Expected the least recently used user to be evicted, got %d info calls", api.infoCalls)
+	}
+	if s := d.Stats()["user_lookups"]; s != (CacheStats{Entries: 2, Limit: 2, Hits: 2, Misses: 4, Evictions: 2}) {
+		t.Errorf("Unexpected user lookup stats %+v", s)
+	}
+	d.Channel(context.Background(), "C1")
+	d.Channel(context.Background(), "C1")
+	if api.infoCalls != 5 {
+		t.Errorf("Expected the channel to be served from the cache, got %d info calls", api.infoCalls)
+	}
+	d.Apply(&slack.ChannelRenameEvent{Type: "channel_rename", Channel: slack.ChannelRenameInfo{ID: "C1", Name: "renamed"}})
+	if c, _ := d.Channel(context.Background(), "C1"); c.Name != "renamed" || api.infoCalls != 5 {
+		t.Errorf("Expected the cached channel to be renamed, got %+v", c)
+	}
+}
+
+func TestDirectoryPaging(t *testing.T) {
+	api := &fakeDirectoryAPI{channels: [][]slack.Channel{{channel("C1")}, {channel("C2")}, {channel("C3")}}}
+	d := NewDirectory(api, DirectoryConfig{TTL: time.Minute})
+	channels, err := d.Channels(context.Background())
+	if err != nil || len(channels) != 3 {
+		t.Fatalf("Expected every page to be fetched, got %v %v", channels, err)
+	}
+	if c, _ := d.Channel(context.Background(), "C3"); c.ID != "C3" || api.infoCalls != 0 {
+		t.Errorf("Expected C3 to be served from the cache")
+	}
+}
+
+func TestDirectoryDisabled(t *testing.T) {
+	api := &fakeDirectoryAPI{users: []slack.User{{ID: "U1"}}}
+	d := NewDirectory(api, DirectoryConfig{})
+	d.Users(context.Background())
+	d.Users(context.Background())
+	d.User(context.Background(), "U1")
+	if api.listCalls != 2 || api.infoCalls != 1 {
+		t.Errorf("Expected every call to go to Slack, got %d list and %d info calls", api.listCalls, api.infoCalls)
+	}
+}
+
+func TestDirectoryApply(t *testing.T) {
+	general := channel("C1")
+	general.Name = "general"
+	api := &fakeDirectoryAPI{
+		users:    []slack.User{{ID: "U1", Name: "old"}},
+		channels: [][]slack.Channel{{general, channel("C2")}},
+		groups:   map[string][]string{"S1": {"U1"}},
+	}
+	d := NewDirectory(api, DirectoryConfig{TTL: time.Hour, MaxUsers: 2, Usergroups: 10})
+	users, _ := d.Users(context.Background())
+	d.Channels(context.Background())
+	d.UsergroupMembers(context.Background(), "S1")
+
+	d.Apply(&slack.UserChangeEvent{Type: "user_change", User: slack.User{ID: "U1", Name: "new"}})
+	d.Apply(&slack.TeamJoinEvent{Type: "team_join", User: slack.User{ID: "U2"}})
+	d.Apply(&slack.TeamJoinEvent{Type: "team_join", User: slack.User{ID: "U3"}})
+	if u, _ := d.User(context.Background(), "U1"); u.Name != "new" {
+		t.Errorf("Expected the changed user to be served, got %+v", u)
+	}
+	if users[0].Name != "old" {
+		t.Errorf("Expected lists already handed out not to change")
+	}
+	if users, _ := d.Users(context.Background()); len(users) != 2 || users[1].ID != "U2" {
+		t.Errorf("Expected the new user to be added up to MaxUsers, got %+v", users)
+	}
+
+	d.Apply(&slack.ChannelRenameEvent{Type: "channel_rename", Channel: slack.ChannelRenameInfo{ID: "C1", Name: "announcements"}})
+	d.Apply(&slack.ChannelArchiveEvent{Type: "channel_archive", Channel: "C2"})
+	if channels, _ := d.Channels(context.Background()); len(channels) != 1 || channels[0].Name != "announcements" {
+		t.Errorf("Expected the channel to be renamed and the archived one dropped, got %+v", channels)
+	}
+	if c, _ := d.Channel(context.Background(), "C1"); c.Name != "announcements" {
+		t.Errorf("Expected the renamed channel to be served, got %+v", c)
+	}
+
+	d.Apply(&slack.SubteamUpdatedEvent{Type: "subteam_updated", Subteam: slack.UserGroup{ID: "S1", Users: []string{"U1", "U2"}}})
+	infoCalls := api.infoCalls
+	if members, _ := d.UsergroupMembers(context.Background(), "S1"); len(members) != 2 || api.infoCalls != infoCalls {
+		t.Errorf("Expected the updated members to be served from the cache, got %v", members)
+	}
+	if api.listCalls != 2 {
+		t.Errorf("Expected no lists to be fetched again, got %d calls", api.listCalls)
+	}
+	if d.Apply(&slack.EmojiChangedEvent{}) {
+		t.Errorf("Expected other events to be ignored")
+	}
+}
+
+func TestDirectoryApplyWhileLoading(t *testing.T) {
+	api := &fakeDirectoryAPI{users: []slack.User{{ID: "U1", Name: "old"}}, block: make(chan struct{})}
+	d := NewDirectory(api, DirectoryConfig{TTL: time.Hour})
+	done := make(chan []slack.User)
+	go func() {
+		users, _ := d.Users(context.Background())
+		done <- users
+	}()
+	for atomic.LoadInt32(&api.listCalls) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	// The list being fetched predates the change
+	d.Apply(&slack.UserChangeEvent{Type: "user_change", User: slack.User{ID: "U1", Name: "new"}})
+	close(api.block)
+	if users := <-done; len(users) != 1 || users[0].Name != "new" {
+		t.Errorf("Expected changes made while loading to be applied to the loaded list, got %+v", users)
+	}
+}
+
+func TestDirectoryApplyDuringLookups(t *testing.T) {
+	const n = 500
+	var channels []slack.Channel
+	for i := 0; i < n; i++ {
+		channels = append(channels, channel("C"+strconv.Itoa(i)))
+	}
+	api := &fakeDirectoryAPI{users: []slack.User{{ID: "U0"}}, channels: [][]slack.Channel{channels}}
+	d := NewDirectory(api, DirectoryConfig{TTL: time.Hour})
+	d.Users(context.Background())
+	d.Channels(context.Background())
+
+	var joined, archived int32
+	go func() {
+		for i := 1; i < n; i++ {
+			d.Apply(&slack.TeamJoinEvent{Type: "team_join", User: slack.User{ID: "U" + strconv.Itoa(i)}})
+			atomic.StoreInt32(&joined, int32(i))
+		}
+	}()
+	go func() {
+		// Archive the even channels, the odd ones stay listed
+		for i := 0; i < n; i += 2 {
+			d.Apply(&slack.ChannelArchiveEvent{Type: "channel_archive", Channel: "C" + strconv.Itoa(i)})
+			atomic.StoreInt32(&archived, int32(i))
+		}
+	}()
+	var wg sync.WaitGroup
+	for r := 0; r < 4; r++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for atomic.LoadInt32(&joined) < n-1 || atomic.LoadInt32(&archived) < n-2 {
+				// The user who just joined and a channel next to the one just
+				// archived
+				id := "U" + strconv.Itoa(int(atomic.LoadInt32(&joined)))
+				if u, err := d.User(context.Background(), id); err != nil || u.ID != id {
+					t.Errorf("Expected user %s, got %+v %v", id, u, err)
+					return
+				}
+				id = "C" + strconv.Itoa(int(atomic.LoadInt32(&archived))+1)
+				if c, err := d.Channel(context.Background(), id); err != nil || c.ID != id {
+					t.Errorf("Expected channel %s, got %+v %v", id, c, err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
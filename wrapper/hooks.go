@@ -0,0 +1,190 @@
+package wrapper
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+)
+
+// Redacted replaces secrets such as tokens in the params and headers given to
+// hooks
+const Redacted = "[redacted]"
+
+// secretParams are the params which are redacted before hooks see them
+var secretParams = map[string]bool{"token": true, "client_secret": true}
+
+// Call is a Slack Web API call as seen by request and response hooks
+type Call struct {
+	// Method is the Web API method, e.g. chat.postMessage
+	Method string
+	// Params are the call's arguments with secrets redacted. Arguments sent
+	// as JSON which are not strings are given in their JSON encoding.
+	Params url.Values
+	// Header is the request's headers with the Authorization header
+	// redacted. Request hooks can set headers on it to send them with the
+	// request.
+	Header http.Header
+	// Duration is how long the call took and StatusCode the HTTP status of
+	// its response, zero if there was none
+	Duration   time.Duration
+	StatusCode int
+	// Err is why the call failed, including errors returned by Slack in a
+	// successful response, nil if it succeeded
+	Err error
+	// Context is the context the call was made in, carrying the logger of
+	// the request it was made for if there is one
+	Context context.Context
+}
+
+// Hook instruments Slack Web API calls
+type Hook func(c *Call)
+
+// WithRequestHook calls fn before every Slack Web API call is sent. Hooks are
+// called in the order they were added.
+func WithRequestHook(fn Hook) Option {
+	return func(s *Slack) {
+		s.requestHooks = append(s.requestHooks, fn)
+	}
+}
+
+// WithResponseHook calls fn after every Slack Web API call finishes, whether
+// or not it succeeded. Hooks are called in the order they were added.
+func WithResponseHook(fn Hook) Option {
+	return func(s *Slack) {
+		s.responseHooks = append(s.responseHooks, fn)
+	}
+}
+
+// hooked calls the hooks around each request made by next
+type hooked struct {
+	next          http.RoundTripper
+	requestHooks  []Hook
+	responseHooks []Hook
+}
+
+// hook wraps c's transport with the hooks, returning c if there are none
+func hook(c *http.Client, requestHooks, responseHooks []Hook) *http.Client {
+	if len(requestHooks) == 0 && len(responseHooks) == 0 {
+		return c
+	}
+	next := c.Transport
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	hc := *c
+	hc.Transport = &hooked{next: next, requestHooks: requestHooks, responseHooks: responseHooks}
+	return &hc
+}
+
+func (h *hooked) RoundTrip(r *http.Request) (*http.Response, error) {
+	// Requests must not be changed in place, so the body read for the hooks
+	// and the headers they set go on a copy
+	r = r.Clone(r.Context())
+	c, err := newCall(r)
+	if err != nil {
+		return nil, err
+	}
+	for _, fn := range h.requestHooks {
+		fn(c)
+	}
+	for k, v := range c.Header {
+		if http.CanonicalHeaderKey(k) != "Authorization" {
+			r.Header[k] = v
+		}
+	}
+
+	start := time.Now()
+	res, err := h.next.RoundTrip(r)
+	c.Err = err
+	if err == nil {
+		c.StatusCode = res.StatusCode
+		c.Err = outcome(res)
+	}
+	c.Duration = time.Since(start)
+	for _, fn := range h.responseHooks {
+		fn(c)
+	}
+	return res, err
+}
+
+// newCall describes r for the hooks, replacing r's body so it can still be
+// sent
+func newCall(r *http.Request) (*Call, error) {
+	c := &Call{Method: path.Base(r.URL.Path), Params: url.Values{}, Header: r.Header.Clone(), Context: r.Context()}
+	if c.Header.Get("Authorization") != "" {
+		c.Header.Set("Authorization", Redacted)
+	}
+	for k, v := range r.URL.Query() {
+		c.Params[k] = v
+	}
+	if r.Body != nil {
+		body, err := ioutil.ReadAll(r.Body)
+		r.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("error reading %s request: %s", c.Method, err)
+		}
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+		addParams(c.Params, r.Header.Get("Content-Type"), body)
+	}
+	for k := range c.Params {
+		if secretParams[k] {
+			c.Params[k] = []string{Redacted}
+		}
+	}
+	return c, nil
+}
+
+func addParams(params url.Values, contentType string, body []byte) {
+	if strings.HasPrefix(contentType, "application/json") {
+		var fields map[string]json.RawMessage
+		if json.Unmarshal(body, &fields) != nil {
+			return
+		}
+		for k, raw := range fields {
+			var s string
+			if json.Unmarshal(raw, &s) == nil {
+				params.Add(k, s)
+			} else {
+				params.Add(k, string(raw))
+			}
+		}
+		return
+	}
+	if form, err := url.ParseQuery(string(body)); err == nil {
+		for k, v := range form {
+			params[k] = append(params[k], v...)
+		}
+	}
+}
+
+// outcome returns why a response failed. The body is read to find errors
+// Slack returns with a 200 and replaced so the caller can decode it.
+func outcome(res *http.Response) error {
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("slack server error: %s", res.Status)
+	}
+	if !strings.HasPrefix(res.Header.Get("Content-Type"), "application/json") {
+		return nil
+	}
+	body, err := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	res.Body = ioutil.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	var sr struct {
+		OK    bool   `json:"ok"`
+		Error string `json:"error"`
+	}
+	if json.Unmarshal(body, &sr) == nil && !sr.OK {
+		return fmt.Errorf("slack error: %s", sr.Error)
+	}
+	return nil
+}
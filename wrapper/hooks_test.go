@@ -0,0 +1,96 @@
+package wrapper
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nlopes/slack"
+
+	"github.com/skybet/go-helpdesk/slacktest"
+)
+
+func TestHooks(t *testing.T) {
+	s := slacktest.NewServer(slacktest.Chaos{})
+	defer s.Close()
+	s.Handle("reactions.add", func(w http.ResponseWriter, c *slacktest.Call) {
+		slacktest.ReplyError(w, "already_reacted")
+	})
+	var (
+		mu    sync.Mutex
+		order []string
+		calls []*Call
+	)
+	sw, err := New("APP", "BOT", WithAPIURL(s.APIURL()),
+		WithRequestHook(func(c *Call) {
+			mu.Lock()
+			defer mu.Unlock()
+			order = append(order, "request "+c.Method)
+			c.Header.Set("X-Request-Id", "req-1")
+			c.Header.Set("Authorization", "Bearer stolen")
+		}),
+		WithResponseHook(func(c *Call) {
+			mu.Lock()
+			defer mu.Unlock()
+			order = append(order, "response "+c.Method)
+			calls = append(calls, c)
+		}),
+	)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	calls, order = nil, nil
+
+	if _, _, err := sw.PostMessage("C1", slack.MsgOptionText("Hello", false)); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if err := sw.AddReaction("tada", slack.ItemRef{Channel: "C1", Timestamp: "1.1"}); err == nil || err.Error() != "already_reacted" {
+		t.Fatalf("Expected the Slack error to reach the caller, got %v", err)
+	}
+
+	if got := strings.Join(order, ", "); got != "request chat.postMessage, response chat.postMessage, request reactions.add, response reactions.add" {
+		t.Errorf("Expected the hooks around each call, got %s", got)
+	}
+	post := calls[0]
+	if post.Params.Get("channel") != "C1" || post.Params.Get("text") != "Hello" || post.Params.Get("token") != Redacted {
+		t.Errorf("Expected the params with the token redacted, got %v", post.Params)
+	}
+	if post.StatusCode != http.StatusOK || post.Err != nil || post.Duration <= 0 {
+		t.Errorf("Expected the call to succeed, got %+v", post)
+	}
+	if calls[1].Err == nil || !strings.Contains(calls[1].Err.Error(), "already_reacted") {
+		t.Errorf("Expected the Slack error as the outcome, got %v", calls[1].Err)
+	}
+
+	sent := s.Calls("chat.postMessage")[0]
+	if sent.Header.Get("X-Request-Id") != "req-1" {
+		t.Errorf("Expected the hook's header to be sent, got %v", sent.Header)
+	}
+	if sent.Form.Get("token") != "BOT" {
+		t.Errorf("Expected hooks not to change the token, got %s", sent.Form.Get("token"))
+	}
+}
+
+func TestHooksJSONParams(t *testing.T) {
+	s := slacktest.NewServer(slacktest.Chaos{})
+	defer s.Close()
+	var call *Call
+	sw, err := New("APP", "BOT", WithAPIURL(s.APIURL()), WithResponseHook(func(c *Call) { call = c }))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	s.Handle("chat.scheduleMessage", func(w http.ResponseWriter, c *slacktest.Call) {
+		slacktest.Reply(w, map[string]interface{}{"scheduled_message_id": "Q1"})
+	})
+	if _, err := sw.ScheduleMessage("U1", time.Unix(1552896000, 0), slack.MsgOptionText("Good morning", false)); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if call.Method != "chat.scheduleMessage" || call.Params.Get("channel") != "U1" || call.Params.Get("post_at") != "1552896000" {
+		t.Errorf("Expected the JSON params, got %v", call.Params)
+	}
+	if call.Header.Get("Authorization") != Redacted {
+		t.Errorf("Expected the token to be redacted, got %s", call.Header.Get("Authorization"))
+	}
+}
@@ -0,0 +1,113 @@
+package wrapper
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// lru is a cache of up to size entries which evicts the least recently used
+// entry to make room for a new one. Entries older than the maximum age given
+// to get are misses. It is safe for concurrent use.
+type lru struct {
+	mu        sync.Mutex
+	size      int
+	order     *list.List
+	items     map[string]*list.Element
+	hits      int64
+	misses    int64
+	evictions int64
+}
+
+type lruEntry struct {
+	key     string
+	value   interface{}
+	fetched time.Time
+}
+
+// newLRU returns an empty cache of up to size entries, nothing is cached if
+// size is zero
+func newLRU(size int) *lru {
+	return &lru{size: size, order: list.New(), items: map[string]*list.Element{}}
+}
+
+// get returns the entry for key if it was added less than maxAge before now
+func (c *lru) get(key string, maxAge time.Duration, now time.Time) (interface{}, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.items[key]
+	if !ok {
+		c.misses++
+		return nil, false
+	}
+	e := el.Value.(*lruEntry)
+	if now.Sub(e.fetched) >= maxAge {
+		c.order.Remove(el)
+		delete(c.items, key)
+		c.misses++
+		return nil, false
+	}
+	c.order.MoveToFront(el)
+	c.hits++
+	return e.value, true
+}
+
+// add sets the entry for key as fetched at now, evicting the least recently
+// used entry if the cache is full
+func (c *lru) add(key string, value interface{}, now time.Time) {
+	if c.size <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.items[key]; ok {
+		el.Value = &lruEntry{key: key, value: value, fetched: now}
+		c.order.MoveToFront(el)
+		return
+	}
+	c.items[key] = c.order.PushFront(&lruEntry{key: key, value: value, fetched: now})
+	if c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.items, oldest.Value.(*lruEntry).key)
+		c.evictions++
+	}
+}
+
+// update replaces the entry for key with fn's result if there is one, without
+// changing how recently it was used or when it was fetched
+func (c *lru) update(key string, fn func(value interface{}) interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.items[key]; ok {
+		e := el.Value.(*lruEntry)
+		e.value = fn(e.value)
+	}
+}
+
+// remove drops the entry for key
+func (c *lru) remove(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.items[key]; ok {
+		c.order.Remove(el)
+		delete(c.items, key)
+	}
+}
+
+// each calls fn with every entry, least recently used first, so that adding
+// them in that order to another cache keeps their order
+func (c *lru) each(fn func(key string, value interface{}, fetched time.Time)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for el := c.order.Back(); el != nil; el = el.Prev() {
+		e := el.Value.(*lruEntry)
+		fn(e.key, e.value, e.fetched)
+	}
+}
+
+func (c *lru) stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return CacheStats{Entries: c.order.Len(), Limit: c.size, Hits: c.hits, Misses: c.misses, Evictions: c.evictions}
+}
@@ -0,0 +1,40 @@
+package wrapper
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLRU(t *testing.T) {
+	now := time.Now()
+	c := newLRU(2)
+	c.add("a", 1, now)
+	c.add("b", 2, now)
+	if v, ok := c.get("a", time.Minute, now); !ok || v != 1 {
+		t.Fatalf("Expected a, got %v %v", v, ok)
+	}
+	// b is now the least recently used
+	c.add("c", 3, now)
+	if _, ok := c.get("b", time.Minute, now); ok {
+		t.Errorf("Expected b to be evicted")
+	}
+	if _, ok := c.get("a", time.Minute, now); !ok {
+		t.Errorf("Expected a to be kept")
+	}
+	c.update("c", func(v interface{}) interface{} { return v.(int) * 10 })
+	if v, _ := c.get("c", time.Minute, now); v != 30 {
+		t.Errorf("Expected c to be updated, got %v", v)
+	}
+	if _, ok := c.get("c", time.Minute, now.Add(time.Minute)); ok {
+		t.Errorf("Expected entries older than the maximum age to miss")
+	}
+	if s := c.stats(); s != (CacheStats{Entries: 1, Limit: 2, Hits: 3, Misses: 2, Evictions: 1}) {
+		t.Errorf("Unexpected stats %+v", s)
+	}
+
+	disabled := newLRU(0)
+	disabled.add("a", 1, now)
+	if _, ok := disabled.get("a", time.Minute, now); ok {
+		t.Errorf("Expected nothing to be cached with a size of zero")
+	}
+}
@@ -0,0 +1,32 @@
+package wrapper
+
+import (
+	"github.com/nlopes/slack"
+
+	"github.com/skybet/go-helpdesk/metrics"
+)
+
+var (
+	apiCalls      = metrics.Default.Counter("helpdesk_slack_api_calls_total", "Slack Web API calls made, by method", "method")
+	apiErrors     = metrics.Default.Counter("helpdesk_slack_api_errors_total", "Slack Web API calls which failed, by method", "method")
+	apiDuration   = metrics.Default.Histogram("helpdesk_slack_api_duration_seconds", "How long Slack Web API calls took, by method", metrics.DefaultBuckets, "method")
+	reconnections = metrics.Default.Counter("helpdesk_websocket_reconnects_total", "Attempts to reconnect the websocket to Slack after the first connection, by mode", "mode")
+)
+
+// MetricsHook is a response hook counting Slack Web API calls, their errors
+// and how long they took
+func MetricsHook(c *Call) {
+	apiCalls.Inc(c.Method)
+	if c.Err != nil {
+		apiErrors.Inc(c.Method)
+	}
+	apiDuration.Observe(c.Duration.Seconds(), c.Method)
+}
+
+// countReconnect counts e if it is an attempt to reconnect a websocket of the
+// given mode, a retry of the first connection or any after it
+func countReconnect(mode string, e slack.RTMEvent) {
+	if c, ok := e.Data.(*slack.ConnectingEvent); ok && (c.ConnectionCount > 0 || c.Attempt > 1) {
+		reconnections.Inc(mode)
+	}
+}
@@ -0,0 +1,39 @@
+package wrapper
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/nlopes/slack"
+)
+
+func TestMetricsHook(t *testing.T) {
+	calls, errs := apiCalls.Value("test.metrics"), apiErrors.Value("test.metrics")
+	MetricsHook(&Call{Method: "test.metrics", Duration: time.Millisecond})
+	MetricsHook(&Call{Method: "test.metrics", Duration: time.Second, Err: fmt.Errorf("ratelimited")})
+	if got := apiCalls.Value("test.metrics") - calls; got != 2 {
+		t.Errorf("Expected 2 calls, got %v", got)
+	}
+	if got := apiErrors.Value("test.metrics") - errs; got != 1 {
+		t.Errorf("Expected 1 error, got %v", got)
+	}
+	if got := apiDuration.Count("test.metrics"); got != 2 {
+		t.Errorf("Expected the duration of 2 calls, got %d", got)
+	}
+}
+
+func TestCountReconnect(t *testing.T) {
+	before := reconnections.Value("test")
+	for _, e := range []slack.RTMEvent{
+		{Type: "connecting", Data: &slack.ConnectingEvent{Attempt: 1}},
+		{Type: "hello", Data: &slack.HelloEvent{}},
+		{Type: "connecting", Data: &slack.ConnectingEvent{Attempt: 2}},
+		{Type: "connecting", Data: &slack.ConnectingEvent{Attempt: 1, ConnectionCount: 1}},
+	} {
+		countReconnect("test", e)
+	}
+	if got := reconnections.Value("test") - before; got != 2 {
+		t.Errorf("Expected 2 reconnects, got %v", got)
+	}
+}
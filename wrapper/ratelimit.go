@@ -0,0 +1,177 @@
+package wrapper
+
+import (
+	"context"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/skybet/go-helpdesk/clock"
+)
+
+// RateLimits configures WithRateLimits
+type RateLimits struct {
+	// PerMinute is how many calls each token may make to a method a minute,
+	// e.g. budget.Tiers. Methods which are not in it are not paced.
+	PerMinute map[string]int
+	// Retries is how many times a call Slack rate limits is tried again
+	Retries int
+	// Clock, if set, replaces the wall clock
+	Clock clock.Clock
+}
+
+// WithRateLimits paces each token's calls to each method to stay within its
+// limit, allowing bursts of a tenth of a minute's calls. A call Slack answers
+// with a 429 holds back that token's calls to the method until its
+// Retry-After has passed, plus some jitter so that the waiting calls do not
+// all go at once, and is then retried.
+func WithRateLimits(l RateLimits) Option {
+	return func(s *Slack) {
+		s.rateLimits = &l
+	}
+}
+
+// limiter paces the requests made through next
+type limiter struct {
+	next    http.RoundTripper
+	limits  RateLimits
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	rand    *rand.Rand
+}
+
+// bucket is the pace of one token's calls to a method
+type bucket struct {
+	// due is when the calls reserved so far have all been paid for
+	due time.Time
+	// paused is when Slack will take calls again after a 429
+	paused time.Time
+}
+
+// limit wraps c's transport with a limiter, returning c if l is nil
+func limit(c *http.Client, l *RateLimits) *http.Client {
+	if l == nil {
+		return c
+	}
+	next := c.Transport
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	lc := *c
+	lc.Transport = &limiter{next: next, limits: *l, buckets: map[string]*bucket{}, rand: rand.New(rand.NewSource(time.Now().UnixNano()))}
+	return &lc
+}
+
+func (l *limiter) RoundTrip(r *http.Request) (*http.Response, error) {
+	method := path.Base(r.URL.Path)
+	key := token(r) + " " + method
+	for attempt := 0; ; attempt++ {
+		if err := l.wait(r.Context(), key, method); err != nil {
+			return nil, err
+		}
+		req := r
+		if attempt > 0 {
+			req = r.Clone(r.Context())
+			if r.GetBody != nil {
+				body, err := r.GetBody()
+				if err != nil {
+					return nil, err
+				}
+				req.Body = body
+			}
+		}
+		res, err := l.next.RoundTrip(req)
+		replayable := r.Body == nil || r.Body == http.NoBody || r.GetBody != nil
+		if err != nil || res.StatusCode != http.StatusTooManyRequests || attempt >= l.limits.Retries || !replayable {
+			return res, err
+		}
+		retry, _ := strconv.Atoi(res.Header.Get("Retry-After"))
+		ioutil.ReadAll(res.Body)
+		res.Body.Close()
+		l.pause(key, time.Duration(retry)*time.Second)
+	}
+}
+
+// wait blocks until the call can be made, reserving its place
+func (l *limiter) wait(ctx context.Context, key, method string) error {
+	c := clock.Or(l.limits.Clock)
+	now := c.Now()
+	var wait time.Duration
+	l.mu.Lock()
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{}
+		l.buckets[key] = b
+	}
+	if b.paused.After(now) {
+		wait = b.paused.Sub(now)
+	}
+	if n := l.limits.PerMinute[method]; n > 0 {
+		interval := time.Minute / time.Duration(n)
+		burst := time.Duration(n/10) * interval
+		if burst < interval {
+			burst = interval
+		}
+		if b.due.Before(now) {
+			b.due = now
+		}
+		b.due = b.due.Add(interval)
+		if w := b.due.Sub(now) - burst; w > wait {
+			wait = w
+		}
+	}
+	l.mu.Unlock()
+	if wait <= 0 {
+		return nil
+	}
+	timer := c.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C():
+		return nil
+	}
+}
+
+// pause holds back the calls for key for retry, which is at least a second,
+// with up to a tenth more as jitter
+func (l *limiter) pause(key string, retry time.Duration) {
+	if retry < time.Second {
+		retry = time.Second
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	until := clock.Or(l.limits.Clock).Now().Add(retry + time.Duration(l.rand.Int63n(int64(retry/10)+1)))
+	if b := l.buckets[key]; until.After(b.paused) {
+		b.paused = until
+	}
+}
+
+// token identifies the token a request is made with, from its Authorization
+// header or token parameter, without keeping the token itself
+func token(r *http.Request) string {
+	t := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if t == "" {
+		t = r.URL.Query().Get("token")
+	}
+	if t == "" && r.GetBody != nil && strings.HasPrefix(r.Header.Get("Content-Type"), "application/x-www-form-urlencoded") {
+		if body, err := r.GetBody(); err == nil {
+			b, _ := ioutil.ReadAll(body)
+			body.Close()
+			form, _ := url.ParseQuery(string(b))
+			t = form.Get("token")
+		}
+	}
+	// The last characters tell the tokens apart well enough
+	if len(t) > 8 {
+		t = t[len(t)-8:]
+	}
+	return t
+}
@@ -0,0 +1,78 @@
+package wrapper
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/skybet/go-helpdesk/clock"
+)
+
+func call(t *testing.T, c *http.Client, url, token string) int {
+	t.Helper()
+	req, _ := http.NewRequest("POST", url, strings.NewReader("channel=C1"))
+	req.Header.Set("Authorization", "Bearer "+token)
+	res, err := c.Do(req)
+	if err != nil {
+		t.Errorf("Unexpected error: %s", err)
+		return 0
+	}
+	res.Body.Close()
+	return res.StatusCode
+}
+
+func TestRateLimitsRetry(t *testing.T) {
+	var hits int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&hits, 1) == 1 {
+			w.Header().Set("Retry-After", "2")
+			w.WriteHeader(http.StatusTooManyRequests)
+		}
+	}))
+	defer srv.Close()
+	c := clock.NewFake(time.Date(2026, 10, 14, 9, 0, 0, 0, time.UTC))
+	hc := limit(&http.Client{}, &RateLimits{Retries: 1, Clock: c})
+
+	done := make(chan int)
+	go func() { done <- call(t, hc, srv.URL+"/api/chat.update", "xoxb-1") }()
+	c.BlockUntil(1)
+	if n := atomic.LoadInt32(&hits); n != 1 {
+		t.Fatalf("Expected the retry to wait for Retry-After, got %d calls", n)
+	}
+	c.Advance(2*time.Second + 200*time.Millisecond)
+	if code := <-done; code != http.StatusOK || atomic.LoadInt32(&hits) != 2 {
+		t.Errorf("Expected the call to succeed on its retry, got %d after %d calls", code, atomic.LoadInt32(&hits))
+	}
+}
+
+func TestRateLimitsPace(t *testing.T) {
+	var hits int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+	}))
+	defer srv.Close()
+	c := clock.NewFake(time.Date(2026, 10, 14, 9, 0, 0, 0, time.UTC))
+	hc := limit(&http.Client{}, &RateLimits{PerMinute: map[string]int{"chat.postMessage": 60}, Clock: c})
+	url := srv.URL + "/api/chat.postMessage"
+
+	// A tenth of a minute's calls go straight away
+	for i := 0; i < 6; i++ {
+		call(t, hc, url, "xoxb-1")
+	}
+	done := make(chan int)
+	go func() { done <- call(t, hc, url, "xoxb-1") }()
+	c.BlockUntil(1)
+	// Other tokens and methods have their own pace
+	call(t, hc, url, "xoxb-2")
+	call(t, hc, srv.URL+"/api/chat.update", "xoxb-1")
+	if n := atomic.LoadInt32(&hits); n != 8 {
+		t.Fatalf("Expected the seventh call to wait, got %d calls", n)
+	}
+	c.Advance(time.Second)
+	if code := <-done; code != http.StatusOK || atomic.LoadInt32(&hits) != 9 {
+		t.Errorf("Expected the seventh call to go after a second, got %d", code)
+	}
+}
@@ -0,0 +1,213 @@
+package wrapper
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nlopes/slack"
+
+	"github.com/skybet/go-helpdesk/clock"
+)
+
+// ResponseURLUses and ResponseURLLifetime are how many messages Slack takes
+// on a response URL, and for how long after it was issued
+const (
+	ResponseURLUses     = 5
+	ResponseURLLifetime = 30 * time.Minute
+)
+
+// ErrResponseURLExpired is returned instead of posting to a response URL
+// which has been used up or is too old, or which Slack refused as expired
+type ErrResponseURLExpired struct {
+	Reason string
+}
+
+func (e *ErrResponseURLExpired) Error() string {
+	return fmt.Sprintf("response URL has expired: %s", e.Reason)
+}
+
+// ResponseURLClient posts replies to the response URLs of slash commands and
+// interactions, keeping count of each URL's uses and age so that an expired
+// URL fails fast with ErrResponseURLExpired
+type ResponseURLClient struct {
+	// Retries is how many more times a post which failed with a 429, 5xx or
+	// transport error is tried, waiting Backoff before the first retry and
+	// twice as long before each after
+	Retries int
+	Backoff time.Duration
+	// Clock, if set, replaces the wall clock
+	Clock clock.Clock
+
+	client *http.Client
+	mu     sync.Mutex
+	urls   map[string]*issuedURL
+}
+
+// issuedURL is what is known of a response URL
+type issuedURL struct {
+	issued time.Time
+	uses   int
+	// original is the response type of the message replace_original
+	// replaces
+	original string
+}
+
+// NewResponseURLClient returns a client posting with c, or with a 10 second
+// timeout if c is nil. Response URLs are not Web API methods, so c should
+// not be the one instrumented with the wrapper's hooks.
+func NewResponseURLClient(c *http.Client) *ResponseURLClient {
+	if c == nil {
+		c = &http.Client{Timeout: 10 * time.Second}
+	}
+	return &ResponseURLClient{client: c, urls: map[string]*issuedURL{}}
+}
+
+// Issued records that a response URL came with a request received at, about
+// a message of response type original: ephemeral for slash commands, or the
+// type of the message acted on for interactions. URLs which are not recorded
+// count as issued when first posted to, about an ephemeral message.
+func (c *ResponseURLClient) Issued(responseURL string, at time.Time, original string) {
+	if original == "" {
+		original = slack.ResponseTypeEphemeral
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.urls[responseURL] = &issuedURL{issued: at, original: original}
+}
+
+// Remaining returns how many more messages a response URL takes and when it
+// expires
+func (c *ResponseURLClient) Remaining(responseURL string) (int, time.Time) {
+	now := clock.Or(c.Clock).Now()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	u, ok := c.urls[responseURL]
+	if !ok {
+		return ResponseURLUses, now.Add(ResponseURLLifetime)
+	}
+	return ResponseURLUses - u.uses, u.issued.Add(ResponseURLLifetime)
+}
+
+// Reply posts msg as a new message, ephemeral unless msg says otherwise
+func (c *ResponseURLClient) Reply(responseURL string, msg slack.Msg) error {
+	msg.ReplaceOriginal, msg.DeleteOriginal = false, false
+	if msg.ResponseType == "" {
+		msg.ResponseType = slack.ResponseTypeEphemeral
+	}
+	return c.send(responseURL, msg)
+}
+
+// Replace replaces the original message with msg. A replaced message keeps
+// its response type, so an in_channel msg replacing an ephemeral original is
+// posted as a new message once the original is deleted, using the URL twice.
+func (c *ResponseURLClient) Replace(responseURL string, msg slack.Msg) error {
+	original := c.original(responseURL)
+	if msg.ResponseType == slack.ResponseTypeInChannel && original == slack.ResponseTypeEphemeral {
+		if err := c.Delete(responseURL); err != nil {
+			return err
+		}
+		if err := c.Reply(responseURL, msg); err != nil {
+			return err
+		}
+		c.mu.Lock()
+		c.urls[responseURL].original = slack.ResponseTypeInChannel
+		c.mu.Unlock()
+		return nil
+	}
+	msg.ReplaceOriginal, msg.DeleteOriginal = true, false
+	msg.ResponseType = original
+	return c.send(responseURL, msg)
+}
+
+// Delete deletes the original message
+func (c *ResponseURLClient) Delete(responseURL string) error {
+	return c.send(responseURL, slack.Msg{DeleteOriginal: true})
+}
+
+// original returns the response type of the message a response URL replaces
+func (c *ResponseURLClient) original(responseURL string) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if u, ok := c.urls[responseURL]; ok {
+		return u.original
+	}
+	return slack.ResponseTypeEphemeral
+}
+
+// use takes one of a response URL's uses, forgetting the URLs which have
+// expired
+func (c *ResponseURLClient) use(responseURL string, now time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for k, u := range c.urls {
+		if k != responseURL && now.Sub(u.issued) >= ResponseURLLifetime {
+			delete(c.urls, k)
+		}
+	}
+	u, ok := c.urls[responseURL]
+	if !ok {
+		u = &issuedURL{issued: now, original: slack.ResponseTypeEphemeral}
+		c.urls[responseURL] = u
+	}
+	if u.uses >= ResponseURLUses {
+		return &ErrResponseURLExpired{Reason: fmt.Sprintf("it has been used %d times", ResponseURLUses)}
+	}
+	if now.Sub(u.issued) >= ResponseURLLifetime {
+		return &ErrResponseURLExpired{Reason: fmt.Sprintf("it was issued more than %s ago", ResponseURLLifetime)}
+	}
+	u.uses++
+	return nil
+}
+
+// used marks a response URL Slack refused as used up
+func (c *ResponseURLClient) used(responseURL string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if u, ok := c.urls[responseURL]; ok {
+		u.uses = ResponseURLUses
+	}
+}
+
+// send posts msg to the response URL, retrying errors which may not happen
+// again
+func (c *ResponseURLClient) send(responseURL string, msg slack.Msg) error {
+	if err := c.use(responseURL, clock.Or(c.Clock).Now()); err != nil {
+		return err
+	}
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("error encoding response: %s", err)
+	}
+	wait := c.Backoff
+	for attempt := 0; ; attempt++ {
+		var retry bool
+		res, err := c.client.Post(responseURL, "application/json", bytes.NewReader(body))
+		if err == nil {
+			text, _ := ioutil.ReadAll(res.Body)
+			res.Body.Close()
+			reason := strings.TrimSpace(string(text))
+			switch {
+			case res.StatusCode == http.StatusOK:
+				return nil
+			case res.StatusCode == http.StatusNotFound || reason == "expired_url" || reason == "used_url":
+				c.used(responseURL)
+				return &ErrResponseURLExpired{Reason: fmt.Sprintf("Slack refused it with %s %s", res.Status, reason)}
+			}
+			err = fmt.Errorf("response URL returned %s: %s", res.Status, reason)
+			retry = res.StatusCode == http.StatusTooManyRequests || res.StatusCode >= 500
+		} else {
+			retry = true
+		}
+		if !retry || attempt >= c.Retries {
+			return err
+		}
+		time.Sleep(wait)
+		wait *= 2
+	}
+}
@@ -0,0 +1,104 @@
+package wrapper
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nlopes/slack"
+
+	"github.com/skybet/go-helpdesk/clock"
+)
+
+type responseURLServer struct {
+	*httptest.Server
+	mu    sync.Mutex
+	msgs  []slack.Msg
+	codes []int
+}
+
+func newResponseURLServer(codes ...int) *responseURLServer {
+	s := &responseURLServer{codes: codes}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var msg slack.Msg
+		json.NewDecoder(r.Body).Decode(&msg)
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.msgs = append(s.msgs, msg)
+		if len(s.codes) > 0 {
+			code := s.codes[0]
+			s.codes = s.codes[1:]
+			if code == http.StatusNotFound {
+				http.Error(w, "expired_url", code)
+				return
+			}
+			w.WriteHeader(code)
+		}
+	}))
+	return s
+}
+
+func TestResponseURLClientReplace(t *testing.T) {
+	s := newResponseURLServer()
+	defer s.Close()
+	c := NewResponseURLClient(nil)
+	url := s.URL + "/commands/1"
+
+	if err := c.Reply(url, slack.Msg{Text: "Working on it"}); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if err := c.Replace(url, slack.Msg{Text: "Still working", ResponseType: slack.ResponseTypeEphemeral}); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	// An ephemeral original can only become a channel message by deleting
+	// it and posting again
+	if err := c.Replace(url, slack.Msg{Text: "Done", ResponseType: slack.ResponseTypeInChannel}); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if len(s.msgs) != 4 || s.msgs[0].ResponseType != slack.ResponseTypeEphemeral || !s.msgs[1].ReplaceOriginal ||
+		!s.msgs[2].DeleteOriginal || s.msgs[3].ResponseType != slack.ResponseTypeInChannel || s.msgs[3].ReplaceOriginal {
+		t.Fatalf("Unexpected messages %+v", s.msgs)
+	}
+	if err := c.Replace(url, slack.Msg{Text: "Done, again", ResponseType: slack.ResponseTypeInChannel}); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if len(s.msgs) != 5 || !s.msgs[4].ReplaceOriginal {
+		t.Errorf("Expected the channel message to be replaced in place, got %+v", s.msgs[4:])
+	}
+	err := c.Reply(url, slack.Msg{Text: "One too many"})
+	if _, ok := err.(*ErrResponseURLExpired); !ok || len(s.msgs) != 5 {
+		t.Errorf("Expected a sixth message to be refused without posting, got %v", err)
+	}
+}
+
+func TestResponseURLClientExpiry(t *testing.T) {
+	s := newResponseURLServer(http.StatusServiceUnavailable, http.StatusOK, http.StatusNotFound)
+	defer s.Close()
+	fake := clock.NewFake(time.Date(2026, 10, 14, 9, 0, 0, 0, time.UTC))
+	c := NewResponseURLClient(nil)
+	c.Retries, c.Clock = 1, fake
+
+	old := s.URL + "/old"
+	c.Issued(old, fake.Now(), slack.ResponseTypeInChannel)
+	if err := c.Reply(old, slack.Msg{Text: "Hello"}); err != nil {
+		t.Fatalf("Expected the 503 to be retried, got %s", err)
+	}
+	if n, until := c.Remaining(old); n != 4 || !until.Equal(fake.Now().Add(30*time.Minute)) {
+		t.Errorf("Expected 4 uses left until 09:30, got %d until %s", n, until)
+	}
+	fake.Advance(31 * time.Minute)
+	if err, ok := c.Reply(old, slack.Msg{Text: "Late"}).(*ErrResponseURLExpired); !ok || len(s.msgs) != 2 {
+		t.Errorf("Expected an old URL to be refused without posting, got %v", err)
+	}
+
+	refused := s.URL + "/refused"
+	if _, ok := c.Reply(refused, slack.Msg{Text: "Hello"}).(*ErrResponseURLExpired); !ok {
+		t.Fatalf("Expected Slack's refusal to be reported as expired")
+	}
+	if n, _ := c.Remaining(refused); n != 0 {
+		t.Errorf("Expected a refused URL to be used up, got %d uses left", n)
+	}
+}
@@ -0,0 +1,447 @@
+package wrapper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/nlopes/slack"
+)
+
+var (
+	// ErrRTMStopped is returned when using an RTMManager after its context
+	// has been cancelled, or sending after Shutdown
+	ErrRTMStopped = errors.New("rtm manager has stopped")
+	// ErrRTMNotConnected is returned when sending while there is no
+	// connection
+	ErrRTMNotConnected = errors.New("rtm is not connected")
+)
+
+// Events an RTMManager adds to those of its connection, these are the Type of
+// the slack.RTMEvent each is delivered in
+const (
+	RTMReconnecting    = "reconnecting"
+	RTMReconnected     = "reconnected"
+	RTMReconnectFailed = "reconnect_failed"
+)
+
+// RTMBackoff is how long an RTMManager waits between attempts to reconnect
+type RTMBackoff struct {
+	// Initial is the wait after the first attempt fails, doubled after each
+	// attempt which follows
+	Initial time.Duration
+	// Max is the longest wait, zero for no limit
+	Max time.Duration
+	// Jitter adds a random wait of up to Jitter, so that clients dropped
+	// together do not all reconnect at once
+	Jitter time.Duration
+	// Retries is how many attempts in a row may fail before the manager gives
+	// up, zero to keep trying
+	Retries int
+}
+
+// DefaultRTMBackoff is the backoff of a new RTMManager
+var DefaultRTMBackoff = RTMBackoff{Initial: time.Second, Max: 5 * time.Minute, Jitter: time.Second}
+
+// wait returns how long to wait after n attempts in a row have failed
+func (b RTMBackoff) wait(n int) time.Duration {
+	d := b.Initial
+	for i := 1; i < n && (b.Max <= 0 || d < b.Max) && d < 24*time.Hour; i++ {
+		d *= 2
+	}
+	if b.Max > 0 && d > b.Max {
+		d = b.Max
+	}
+	if b.Jitter > 0 {
+		d += time.Duration(rand.Int63n(int64(b.Jitter)))
+	}
+	return d
+}
+
+// RTMReconnectingEvent is delivered when the connection has dropped or an
+// attempt to reconnect has failed, before the manager tries again
+type RTMReconnectingEvent struct {
+	// Attempt counts the attempts since the connection was last up, the
+	// first after the connection drops is 1
+	Attempt int
+	// Backoff is how long the manager waits before the attempt
+	Backoff time.Duration
+	// Err is why the last attempt failed, nil if the connection dropped
+	Err error
+}
+
+// RTMReconnectedEvent is delivered after the connected event once the
+// connection is back, for consumers to re-sync what they missed while it was
+// down
+type RTMReconnectedEvent struct {
+	// Attempts is how many attempts it took
+	Attempts int
+	// Down is how long the connection was down for
+	Down time.Duration
+}
+
+// RTMReconnectFailedEvent is delivered when Backoff.Retries attempts in a row
+// have failed. The manager stays disconnected until Connect is called.
+type RTMReconnectFailedEvent struct {
+	Attempts int
+	Err      error
+}
+
+// RTMManager owns a Slack RTM connection. Every connect and disconnect is
+// performed by a single goroutine so they can be called concurrently from
+// anywhere, and each connection uses a fresh slack.RTM so a disconnect racing
+// a reconnect can never touch a closed channel.
+//
+// The manager reconnects by itself with Backoff when the connection drops or
+// an attempt to connect fails, delivering RTMReconnecting, RTMReconnected and
+// RTMReconnectFailed events on IncomingEvents alongside those of the
+// connection.
+type RTMManager struct {
+	// Backoff, if changed before connecting, replaces DefaultRTMBackoff
+	Backoff RTMBackoff
+	newRTM  func() *slack.RTM
+	events  chan slack.RTMEvent
+	ops     chan rtmOp
+	current chan chan *rtmConn
+	status  chan rtmStatus
+	done    chan struct{}
+	// mu guards stopping, which is set by Shutdown
+	mu       sync.Mutex
+	stopping bool
+}
+
+type rtmOp struct {
+	connect bool
+	result  chan error
+}
+
+// rtmStatus is sent to the owner goroutine when an attempt to connect fails,
+// with what the next attempt carries on from
+type rtmStatus struct {
+	conn     *rtmConn
+	attempts int
+	lost     time.Time
+	wait     time.Duration
+	giveUp   bool
+}
+
+// rtmConn is a single managed connection and the goroutines serving it
+type rtmConn struct {
+	rtm       *slack.RTM
+	managed   chan struct{}
+	forwarded chan struct{}
+	stopping  chan struct{}
+	// unsent are the IDs of the messages sent which Slack has not replied
+	// to, settled is signalled whenever it replies
+	mu      sync.Mutex
+	unsent  map[int]bool
+	settled chan struct{}
+	// attempts counts the attempts to connect which have failed since the
+	// connection was last up, which was lost at lost. Only forward touches
+	// them after start.
+	attempts int
+	lost     time.Time
+	failed   bool
+}
+
+// NewRTMManager starts a manager for RTM connections made with the given
+// client. The manager disconnects and stops once ctx is cancelled.
+func NewRTMManager(ctx context.Context, client *slack.Client, opts ...slack.RTMOption) *RTMManager {
+	m := &RTMManager{
+		Backoff: DefaultRTMBackoff,
+		newRTM:  func() *slack.RTM { return client.NewRTM(opts...) },
+		events:  make(chan slack.RTMEvent, 50),
+		ops:     make(chan rtmOp),
+		current: make(chan chan *rtmConn),
+		status:  make(chan rtmStatus),
+		done:    make(chan struct{}),
+	}
+	go m.run(ctx)
+	return m
+}
+
+// IncomingEvents returns the events received across every connection
+func (m *RTMManager) IncomingEvents() <-chan slack.RTMEvent {
+	return m.events
+}
+
+// Done returns a channel which is closed once the manager has stopped and
+// its connection has been cleaned up
+func (m *RTMManager) Done() <-chan struct{} {
+	return m.done
+}
+
+// Connect starts a managed connection if one is not already running. While
+// waiting to reconnect it tries again straight away.
+func (m *RTMManager) Connect() error {
+	return m.do(true)
+}
+
+// Disconnect closes the current connection, if any, and waits for it to be
+// cleaned up. It stops the manager reconnecting.
+func (m *RTMManager) Disconnect() error {
+	return m.do(false)
+}
+
+// SendMessage sends a message over the current connection. Slack's reply is
+// delivered on IncomingEvents as an ack or ack_error event.
+func (m *RTMManager) SendMessage(channelID, text string, options ...slack.RTMsgOption) error {
+	// The message is counted before Shutdown can look for unsent messages
+	m.mu.Lock()
+	if m.stopping {
+		m.mu.Unlock()
+		return ErrRTMStopped
+	}
+	c, err := m.conn()
+	if err == nil && c == nil {
+		err = ErrRTMNotConnected
+	}
+	if err != nil {
+		m.mu.Unlock()
+		return err
+	}
+	msg := c.rtm.NewOutgoingMessage(text, channelID, options...)
+	c.mu.Lock()
+	c.unsent[msg.ID] = true
+	c.mu.Unlock()
+	m.mu.Unlock()
+	c.rtm.SendMessage(msg)
+	return nil
+}
+
+// Shutdown stops sending messages, waits for Slack to reply to those already
+// sent or ctx to be done, then disconnects. Slack does not say which message
+// an ack_error is for, so a message it refused is waited for until ctx is
+// done.
+func (m *RTMManager) Shutdown(ctx context.Context) error {
+	m.mu.Lock()
+	m.stopping = true
+	m.mu.Unlock()
+	c, err := m.conn()
+	if err == ErrRTMStopped {
+		return nil
+	}
+	if c != nil {
+		err = c.flush(ctx)
+	}
+	if derr := m.Disconnect(); derr != nil && derr != ErrRTMStopped && err == nil {
+		err = derr
+	}
+	return err
+}
+
+// conn returns the current connection, nil if there is none
+func (m *RTMManager) conn() (*rtmConn, error) {
+	reply := make(chan *rtmConn, 1)
+	select {
+	case m.current <- reply:
+	case <-m.done:
+		return nil, ErrRTMStopped
+	}
+	return <-reply, nil
+}
+
+func (m *RTMManager) do(connect bool) error {
+	op := rtmOp{connect: connect, result: make(chan error, 1)}
+	select {
+	case m.ops <- op:
+	case <-m.done:
+		return ErrRTMStopped
+	}
+	return <-op.result
+}
+
+// run is the owner goroutine, nothing else touches the connection
+func (m *RTMManager) run(ctx context.Context) {
+	defer close(m.done)
+	var conn *rtmConn
+	// retry fires once it is time to reconnect, carrying on from pending
+	var retry <-chan time.Time
+	var pending rtmStatus
+	for {
+		// A nil channel blocks forever, so this only fires while connected
+		var ended chan struct{}
+		if conn != nil {
+			ended = conn.forwarded
+		}
+		select {
+		case <-ctx.Done():
+			if conn != nil {
+				conn.stop()
+			}
+			return
+		case op := <-m.ops:
+			if op.connect && conn == nil {
+				conn = m.start(pending)
+			} else if !op.connect && conn != nil {
+				conn.stop()
+				conn = nil
+			}
+			retry, pending = nil, rtmStatus{}
+			op.result <- nil
+		case reply := <-m.current:
+			reply <- conn
+		case <-ended:
+			// The connection gave up by itself, e.g. on invalid auth
+			conn = nil
+		case s := <-m.status:
+			// A fresh connection replaces one whose attempt failed, rather
+			// than leaving it to retry with the slack package's backoff
+			if s.conn != conn {
+				continue
+			}
+			conn.stop()
+			conn = nil
+			if !s.giveUp {
+				retry, pending = time.After(s.wait), s
+			}
+		case <-retry:
+			conn = m.start(pending)
+			retry, pending = nil, rtmStatus{}
+		}
+	}
+}
+
+func (m *RTMManager) start(from rtmStatus) *rtmConn {
+	c := &rtmConn{
+		attempts:  from.attempts,
+		lost:      from.lost,
+		rtm:       m.newRTM(),
+		managed:   make(chan struct{}),
+		forwarded: make(chan struct{}),
+		stopping:  make(chan struct{}),
+		unsent:    map[int]bool{},
+		settled:   make(chan struct{}, 1),
+	}
+	go func() {
+		defer close(c.managed)
+		c.rtm.ManageConnection()
+	}()
+	go c.forward(m.events, m.status, m.Backoff)
+	return c
+}
+
+// forward copies events to out until the connection has ended, adding the
+// manager's reconnect events, and tells status when an attempt to connect
+// fails. Events are discarded once stopping so ManageConnection is never
+// blocked from exiting.
+func (c *rtmConn) forward(out chan<- slack.RTMEvent, status chan<- rtmStatus, b RTMBackoff) {
+	defer close(c.forwarded)
+	emit := func(e slack.RTMEvent) {
+		select {
+		case out <- e:
+		case <-c.stopping:
+		}
+	}
+	for {
+		select {
+		case e := <-c.rtm.IncomingEvents:
+			var s *rtmStatus
+			switch ev := e.Data.(type) {
+			case *slack.AckMessage:
+				c.settle(ev.ReplyTo)
+			case *slack.MessageTooLongEvent:
+				c.settle(ev.Message.ID)
+			case *slack.ConnectingEvent:
+				// Attempts continue from the connection this one replaced
+				ev.Attempt += c.attempts
+			case *slack.ConnectionErrorEvent:
+				if c.failed {
+					// Already being replaced
+					continue
+				}
+				c.failed = true
+				s = c.failure(ev, b)
+			}
+			countReconnect("rtm", e)
+			emit(e)
+			switch ev := e.Data.(type) {
+			case *slack.ConnectedEvent:
+				if !c.lost.IsZero() {
+					emit(slack.RTMEvent{Type: RTMReconnected, Data: &RTMReconnectedEvent{Attempts: c.attempts + 1, Down: time.Since(c.lost)}})
+				}
+				c.attempts, c.lost = 0, time.Time{}
+			case *slack.DisconnectedEvent:
+				if !ev.Intentional {
+					// The slack package tries once straight away
+					c.attempts, c.lost = 0, time.Now()
+					emit(slack.RTMEvent{Type: RTMReconnecting, Data: &RTMReconnectingEvent{Attempt: 1}})
+				}
+			case *slack.ConnectionErrorEvent:
+				if s.giveUp {
+					emit(slack.RTMEvent{Type: RTMReconnectFailed, Data: &RTMReconnectFailedEvent{Attempts: c.attempts, Err: ev.ErrorObj}})
+				} else {
+					emit(slack.RTMEvent{Type: RTMReconnecting, Data: &RTMReconnectingEvent{Attempt: c.attempts + 1, Backoff: s.wait, Err: ev.ErrorObj}})
+				}
+				select {
+				case status <- *s:
+				case <-c.stopping:
+				}
+			}
+		case <-c.managed:
+			return
+		}
+	}
+}
+
+// failure counts a failed attempt to connect, replacing the slack package's
+// backoff in ev with the manager's
+func (c *rtmConn) failure(ev *slack.ConnectionErrorEvent, b RTMBackoff) *rtmStatus {
+	c.attempts++
+	if c.lost.IsZero() {
+		c.lost = time.Now()
+	}
+	s := &rtmStatus{conn: c, attempts: c.attempts, lost: c.lost}
+	if b.Retries > 0 && c.attempts >= b.Retries {
+		s.giveUp = true
+	} else {
+		s.wait = b.wait(c.attempts)
+		if rl, ok := ev.ErrorObj.(*slack.RateLimitedError); ok && rl.RetryAfter > s.wait {
+			s.wait = rl.RetryAfter
+		}
+	}
+	ev.Attempt, ev.Backoff = c.attempts, s.wait
+	return s
+}
+
+// settle records Slack's reply to a message
+func (c *rtmConn) settle(id int) {
+	c.mu.Lock()
+	delete(c.unsent, id)
+	c.mu.Unlock()
+	select {
+	case c.settled <- struct{}{}:
+	default:
+	}
+}
+
+// flush waits for Slack to reply to every message sent, ctx to be done or the
+// connection to end, which loses the messages it had not sent
+func (c *rtmConn) flush(ctx context.Context) error {
+	for {
+		c.mu.Lock()
+		n := len(c.unsent)
+		c.mu.Unlock()
+		if n == 0 {
+			return nil
+		}
+		select {
+		case <-c.settled:
+		case <-c.managed:
+			return fmt.Errorf("the connection ended with %d messages unsent", n)
+		case <-ctx.Done():
+			return fmt.Errorf("%d messages were still unsent: %s", n, ctx.Err())
+		}
+	}
+}
+
+// stop disconnects and blocks until every goroutine for the connection has exited
+func (c *rtmConn) stop() {
+	close(c.stopping)
+	go c.rtm.Disconnect()
+	<-c.managed
+	<-c.forwarded
+}
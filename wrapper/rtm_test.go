@@ -0,0 +1,249 @@
+package wrapper
+
+import (
+	"context"
+	"math/rand"
+	"net/http"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nlopes/slack"
+	"github.com/skybet/go-helpdesk/slacktest"
+)
+
+func waitForConnected(t *testing.T, m *RTMManager) {
+	t.Helper()
+	timeout := time.After(2 * time.Second)
+	for {
+		select {
+		case e := <-m.IncomingEvents():
+			if _, ok := e.Data.(*slack.ConnectedEvent); ok {
+				return
+			}
+		case <-timeout:
+			t.Fatalf("Timed out waiting for the RTM to connect")
+		}
+	}
+}
+
+func TestRTMManagerReconnect(t *testing.T) {
+	s := slacktest.NewServer(slacktest.Chaos{})
+	defer s.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	m := NewRTMManager(ctx, slack.New("TOKEN", slack.OptionAPIURL(s.APIURL())))
+
+	for i := 0; i < 3; i++ {
+		if err := m.Connect(); err != nil {
+			t.Fatalf("Unexpected error connecting: %s", err)
+		}
+		waitForConnected(t, m)
+		if err := m.Disconnect(); err != nil {
+			t.Fatalf("Unexpected error disconnecting: %s", err)
+		}
+	}
+
+	cancel()
+	select {
+	case <-m.Done():
+	case <-time.After(2 * time.Second):
+		t.Fatalf("Expected the manager to stop once its context was cancelled")
+	}
+	if err := m.Connect(); err != ErrRTMStopped {
+		t.Errorf("Expected ErrRTMStopped after stopping, got %v", err)
+	}
+}
+
+func TestRTMManagerConnectDisconnectStorm(t *testing.T) {
+	s := slacktest.NewServer(slacktest.Chaos{})
+	defer s.Close()
+	before := runtime.NumGoroutine()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	m := NewRTMManager(ctx, slack.New("TOKEN", slack.OptionAPIURL(s.APIURL())))
+	go func() {
+		for {
+			select {
+			case <-m.IncomingEvents():
+			case <-m.Done():
+				return
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(seed int64) {
+			defer wg.Done()
+			r := rand.New(rand.NewSource(seed))
+			for j := 0; j < 20; j++ {
+				if r.Intn(2) == 0 {
+					m.Connect()
+				} else {
+					m.Disconnect()
+				}
+			}
+		}(int64(i))
+	}
+	// Drop the server side of any live connection part way through the storm
+	s.CloseConnections()
+	wg.Wait()
+
+	// Stop while connected to exercise shutdown of a live connection
+	m.Connect()
+	cancel()
+	select {
+	case <-m.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Expected the manager to stop once its context was cancelled")
+	}
+
+	// Give the websocket readers a moment to notice their connections closed,
+	// and discard keep-alive connections left over from rtm.connect calls
+	http.DefaultTransport.(*http.Transport).CloseIdleConnections()
+	deadline := time.Now().Add(2 * time.Second)
+	for runtime.NumGoroutine() > before && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := runtime.NumGoroutine(); n > before {
+		buf := make([]byte, 1<<16)
+		t.Errorf("Expected goroutines to be cleaned up, %d before and %d after:\n%s", before, n, buf[:runtime.Stack(buf, true)])
+	}
+}
+
+func TestRTMManagerShutdown(t *testing.T) {
+	s := slacktest.NewServer(slacktest.Chaos{})
+	defer s.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	m := NewRTMManager(ctx, slack.New("TOKEN", slack.OptionAPIURL(s.APIURL())))
+	if err := m.SendMessage("C1", "too soon"); err != ErrRTMNotConnected {
+		t.Errorf("Expected ErrRTMNotConnected before connecting, got %v", err)
+	}
+	if err := m.Connect(); err != nil {
+		t.Fatalf("Unexpected error connecting: %s", err)
+	}
+	waitForConnected(t, m)
+	for _, text := range []string{"one", "two", "three"} {
+		if err := m.SendMessage("C1", text); err != nil {
+			t.Fatalf("Unexpected error sending: %s", err)
+		}
+	}
+	if err := m.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Unexpected error shutting down: %s", err)
+	}
+	if sent := s.Messages(); len(sent) != 3 || sent[2].Text != "three" {
+		t.Errorf("Expected every message to be sent before disconnecting, got %+v", sent)
+	}
+	if err := m.SendMessage("C1", "too late"); err != ErrRTMStopped {
+		t.Errorf("Expected ErrRTMStopped after shutting down, got %v", err)
+	}
+}
+
+func TestRTMManagerShutdownTimeout(t *testing.T) {
+	// Slack never replies, so the message is never known to be sent
+	s := slacktest.NewServer(slacktest.Chaos{DropFrameRate: 1})
+	defer s.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	m := NewRTMManager(ctx, slack.New("TOKEN", slack.OptionAPIURL(s.APIURL())))
+	m.Connect()
+	waitForConnected(t, m)
+	if err := m.SendMessage("C1", "lost"); err != nil {
+		t.Fatalf("Unexpected error sending: %s", err)
+	}
+	deadline, stop := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer stop()
+	if err := m.Shutdown(deadline); err == nil {
+		t.Errorf("Expected an error when messages were unsent")
+	}
+}
+
+func TestRTMManagerBackoff(t *testing.T) {
+	s := slacktest.NewServer(slacktest.Chaos{})
+	defer s.Close()
+	s.FailNext(slacktest.FaultServerError, 2)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	m := NewRTMManager(ctx, slack.New("TOKEN", slack.OptionAPIURL(s.APIURL())))
+	m.Backoff = RTMBackoff{Initial: 10 * time.Millisecond, Max: 15 * time.Millisecond}
+	if err := m.Connect(); err != nil {
+		t.Fatalf("Unexpected error connecting: %s", err)
+	}
+	for _, want := range []RTMReconnectingEvent{{Attempt: 2, Backoff: 10 * time.Millisecond}, {Attempt: 3, Backoff: 15 * time.Millisecond}} {
+		ev := nextEvent(t, m, RTMReconnecting).Data.(*RTMReconnectingEvent)
+		if ev.Attempt != want.Attempt || ev.Backoff != want.Backoff || ev.Err == nil {
+			t.Errorf("Expected %+v with the error, got %+v", want, ev)
+		}
+	}
+	if c := nextEvent(t, m, "connecting").Data.(*slack.ConnectingEvent); c.Attempt != 3 {
+		t.Errorf("Expected the third attempt to be counted from the first, got %d", c.Attempt)
+	}
+	if ev := nextEvent(t, m, RTMReconnected).Data.(*RTMReconnectedEvent); ev.Attempts != 3 || ev.Down < 25*time.Millisecond {
+		t.Errorf("Expected the connection back after 3 attempts and both backoffs, got %+v", ev)
+	}
+
+	// A dropped connection is tried again straight away
+	s.CloseConnections()
+	if ev := nextEvent(t, m, RTMReconnecting).Data.(*RTMReconnectingEvent); ev.Attempt != 1 || ev.Backoff != 0 || ev.Err != nil {
+		t.Errorf("Expected the first attempt after the drop, got %+v", ev)
+	}
+	if ev := nextEvent(t, m, RTMReconnected).Data.(*RTMReconnectedEvent); ev.Attempts != 1 {
+		t.Errorf("Expected the connection back after 1 attempt, got %+v", ev)
+	}
+}
+
+func TestRTMManagerRetries(t *testing.T) {
+	s := slacktest.NewServer(slacktest.Chaos{})
+	defer s.Close()
+	s.FailNext(slacktest.FaultServerError, 10)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	m := NewRTMManager(ctx, slack.New("TOKEN", slack.OptionAPIURL(s.APIURL())))
+	m.Backoff = RTMBackoff{Initial: time.Millisecond, Retries: 3}
+	m.Connect()
+	if ev := nextEvent(t, m, RTMReconnectFailed).Data.(*RTMReconnectFailedEvent); ev.Attempts != 3 || ev.Err == nil {
+		t.Errorf("Expected to give up after 3 attempts, got %+v", ev)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if n := len(s.Calls("rtm.connect")); n != 3 {
+		t.Errorf("Expected no attempts after giving up, got %d", n)
+	}
+	if err := m.SendMessage("C1", "gave up"); err != ErrRTMNotConnected {
+		t.Errorf("Expected ErrRTMNotConnected after giving up, got %v", err)
+	}
+
+	// Disconnecting stops the manager waiting to reconnect
+	m.Backoff.Retries = 0
+	m.Backoff.Initial = time.Hour
+	m.Connect()
+	nextEvent(t, m, RTMReconnecting)
+	if err := m.Disconnect(); err != nil {
+		t.Fatalf("Unexpected error disconnecting: %s", err)
+	}
+	if err := m.Connect(); err != nil {
+		t.Fatalf("Unexpected error connecting: %s", err)
+	}
+	nextEvent(t, m, RTMReconnecting)
+	if n := len(s.Calls("rtm.connect")); n != 5 {
+		t.Errorf("Expected Connect to try again straight away, got %d attempts", n)
+	}
+}
+
+func TestRTMBackoffWait(t *testing.T) {
+	b := RTMBackoff{Initial: time.Second, Max: 5 * time.Second}
+	for n, want := range map[int]time.Duration{1: time.Second, 2: 2 * time.Second, 3: 4 * time.Second, 4: 5 * time.Second, 100: 5 * time.Second} {
+		if got := b.wait(n); got != want {
+			t.Errorf("Expected %s after %d failures, got %s", want, n, got)
+		}
+	}
+	b.Jitter = time.Second
+	if got := b.wait(1); got < time.Second || got >= 2*time.Second {
+		t.Errorf("Expected up to a second of jitter, got %s", got)
+	}
+	if got := (RTMBackoff{Initial: time.Second}).wait(100); got <= 0 || got > 48*time.Hour {
+		t.Errorf("Expected an unlimited backoff to stop doubling, got %s", got)
+	}
+}
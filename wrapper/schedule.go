@@ -0,0 +1,42 @@
+package wrapper
+
+import (
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/nlopes/slack"
+)
+
+// ScheduleMessage schedules a message to be posted by the bot at postAt,
+// returning the ID of the scheduled message. The options are the same as for
+// PostMessage but only the text, blocks, attachments and thread are sent.
+func (s *Slack) ScheduleMessage(channelID string, postAt time.Time, options ...slack.MsgOption) (string, error) {
+	if err := s.guard("ScheduleMessage"); err != nil {
+		return "", err
+	}
+	_, values, err := slack.UnsafeApplyMsgOptions(s.botToken, channelID, s.apiURL, options...)
+	if err != nil {
+		return "", err
+	}
+	req := map[string]interface{}{
+		"channel": channelID,
+		"post_at": strconv.FormatInt(postAt.Unix(), 10),
+		"text":    values.Get("text"),
+	}
+	for _, k := range []string{"blocks", "attachments"} {
+		if v := values.Get(k); v != "" {
+			req[k] = json.RawMessage(v)
+		}
+	}
+	if ts := values.Get("thread_ts"); ts != "" {
+		req["thread_ts"] = ts
+	}
+	var resp struct {
+		ScheduledMessageID string `json:"scheduled_message_id"`
+	}
+	if err := s.postJSON("chat.scheduleMessage", s.botToken, req, &resp); err != nil {
+		return "", err
+	}
+	return resp.ScheduledMessageID, nil
+}
@@ -0,0 +1,43 @@
+package wrapper
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/nlopes/slack"
+
+	"github.com/skybet/go-helpdesk/slacktest"
+)
+
+func TestScheduleMessage(t *testing.T) {
+	s := slacktest.NewServer(slacktest.Chaos{})
+	defer s.Close()
+	s.Handle("chat.scheduleMessage", func(w http.ResponseWriter, c *slacktest.Call) {
+		var req struct {
+			Channel string            `json:"channel"`
+			PostAt  string            `json:"post_at"`
+			Text    string            `json:"text"`
+			Blocks  []json.RawMessage `json:"blocks"`
+		}
+		json.Unmarshal(c.Body, &req)
+		if req.Channel != "U1" || req.PostAt != "1552896000" || req.Text != "Good morning" || len(req.Blocks) != 1 {
+			slacktest.ReplyError(w, "invalid_arguments")
+			return
+		}
+		slacktest.Reply(w, map[string]interface{}{"scheduled_message_id": "Q1"})
+	})
+	sw, err := New("APP", "BOT", WithAPIURL(s.APIURL()))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	id, err := sw.ScheduleMessage("U1", time.Unix(1552896000, 0), slack.MsgOptionText("Good morning", false), slack.MsgOptionBlocks(slack.NewDividerBlock()))
+	if err != nil || id != "Q1" {
+		t.Fatalf("Expected the message to be scheduled, got %q %v", id, err)
+	}
+	if auth := s.Calls("chat.scheduleMessage")[0].Header.Get("Authorization"); auth != "Bearer BOT" {
+		t.Errorf("Expected messages to be scheduled with the bot token, got %s", auth)
+	}
+}
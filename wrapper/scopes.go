@@ -0,0 +1,162 @@
+package wrapper
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/nlopes/slack"
+)
+
+// scopeNeeded is the bot token scope each method needs, the methods made with
+// the app token need none beyond the slash command's. Those reading
+// conversations need it for public channels, see conversationScopes.
+var scopeNeeded = map[string]string{
+	"PostMessage":            "chat:write",
+	"ScheduleMessage":        "chat:write",
+	"UpdateMessage":          "chat:write",
+	"AddReaction":            "reactions:write",
+	"RemovePin":              "pins:write",
+	"UploadFile":             "files:write",
+	"DownloadFile":           "files:read",
+	"CreateChannel":          "channels:manage",
+	"InviteUsers":            "channels:manage",
+	"SetTopic":               "channels:manage",
+	"SetPurpose":             "channels:manage",
+	"AddPin":                 "pins:write",
+	"UsergroupMembers":       "usergroups:read",
+	"ListConversations":      "channels:read",
+	"GetConversationMembers": "channels:read",
+	"GetConversationHistory": "channels:history",
+	"PostThreadReply":        "chat:write",
+	"BroadcastThreadReply":   "chat:write",
+	"GetThreadReplies":       "channels:history",
+}
+
+// conversationScopes are the scopes reading each type of conversation needs,
+// keyed by the scope for public channels
+var conversationScopes = map[string]map[string]string{
+	"channels:read":    {"public_channel": "channels:read", "private_channel": "groups:read", "im": "im:read", "mpim": "mpim:read"},
+	"channels:history": {"public_channel": "channels:history", "private_channel": "groups:history", "im": "im:history", "mpim": "mpim:history"},
+}
+
+// typesOf returns the conversation types a channel may be from its ID's
+// prefix. Private channels created since 2021 have C IDs like public ones and
+// multi-person DMs share the G prefix of older private channels.
+func typesOf(channelID string) []string {
+	switch {
+	case strings.HasPrefix(channelID, "G"):
+		return []string{"private_channel", "mpim"}
+	case strings.HasPrefix(channelID, "D"):
+		return []string{"im"}
+	case strings.HasPrefix(channelID, "C"):
+		return []string{"public_channel", "private_channel"}
+	}
+	return []string{"public_channel"}
+}
+
+// ErrMissingScope is returned instead of calling Slack when the bot token has
+// not been granted the scope an operation needs
+type ErrMissingScope struct {
+	Need      string
+	Operation string
+}
+
+func (e *ErrMissingScope) Error() string {
+	return fmt.Sprintf("%s needs the %s scope, which the bot token has not been granted: add it under OAuth & Permissions in the app's settings and reinstall the app", e.Operation, e.Need)
+}
+
+// guard returns ErrMissingScope if the bot token lacks the scope op needs.
+// Nothing is refused if Slack did not report the token's scopes.
+func (s *Slack) guard(op string) error {
+	need, ok := scopeNeeded[op]
+	if !ok {
+		return nil
+	}
+	return s.require(op, need)
+}
+
+// guardChannel is guard for an op reading channelID, which needs the scope
+// of any of the types the channel may be
+func (s *Slack) guardChannel(op, channelID string) error {
+	var any []string
+	for _, typ := range typesOf(channelID) {
+		any = append(any, conversationScopes[scopeNeeded[op]][typ])
+	}
+	return s.require(op, any...)
+}
+
+// guardTypes is guard for an op reading conversations of types, public
+// channels if there are none, which needs the scope of each type
+func (s *Slack) guardTypes(op string, types []string) error {
+	if len(types) == 0 {
+		types = []string{"public_channel"}
+	}
+	for _, typ := range types {
+		need, ok := conversationScopes[scopeNeeded[op]][typ]
+		if !ok {
+			continue
+		}
+		if err := s.require(op, need); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// require returns ErrMissingScope unless the bot token has any of the scopes
+func (s *Slack) require(op string, any ...string) error {
+	if s.botScopes == nil {
+		return nil
+	}
+	for _, scope := range any {
+		if s.botScopes[scope] {
+			return nil
+		}
+	}
+	return &ErrMissingScope{Need: strings.Join(any, " or "), Operation: op}
+}
+
+// Scopes returns the OAuth scopes granted to the app and bot tokens
+func (s *Slack) Scopes() (app, bot []string, err error) {
+	if app, err = s.scopes(s.appToken); err != nil {
+		return nil, nil, fmt.Errorf("error checking app token scopes: %s", err)
+	}
+	if bot, err = s.scopes(s.botToken); err != nil {
+		return nil, nil, fmt.Errorf("error checking bot token scopes: %s", err)
+	}
+	return app, bot, nil
+}
+
+// scopes reads the X-OAuth-Scopes header Slack sends with every response,
+// from the cheapest method there is
+func (s *Slack) scopes(token string) ([]string, error) {
+	r, err := http.NewRequest("POST", s.apiURL+"auth.test", nil)
+	if err != nil {
+		return nil, err
+	}
+	r.Header.Set("Authorization", "Bearer "+token)
+	res, err := s.httpClient.Do(r)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("slack server error: %s", res.Status)
+	}
+	var sr slack.SlackResponse
+	if err := json.NewDecoder(res.Body).Decode(&sr); err != nil {
+		return nil, fmt.Errorf("error decoding auth.test response: %s", err)
+	}
+	if err := sr.Err(); err != nil {
+		return nil, err
+	}
+	var scopes []string
+	for _, scope := range strings.Split(res.Header.Get("X-OAuth-Scopes"), ",") {
+		if scope = strings.TrimSpace(scope); scope != "" {
+			scopes = append(scopes, scope)
+		}
+	}
+	return scopes, nil
+}
@@ -0,0 +1,108 @@
+package wrapper
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/nlopes/slack"
+
+	"github.com/skybet/go-helpdesk/slacktest"
+)
+
+func TestScopes(t *testing.T) {
+	s := slacktest.NewServer(slacktest.Chaos{})
+	defer s.Close()
+	s.Handle("auth.test", func(w http.ResponseWriter, c *slacktest.Call) {
+		if c.Header.Get("Authorization") == "Bearer BOT" {
+			w.Header().Set("X-OAuth-Scopes", "chat:write, reactions:write")
+		} else {
+			w.Header().Set("X-OAuth-Scopes", "commands")
+		}
+		slacktest.Reply(w, map[string]interface{}{"user_id": "UBOT"})
+	})
+	sw, err := New("APP", "BOT", WithAPIURL(s.APIURL()))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	app, bot, err := sw.Scopes()
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if !reflect.DeepEqual(app, []string{"commands"}) || !reflect.DeepEqual(bot, []string{"chat:write", "reactions:write"}) {
+		t.Errorf("Expected the scopes of each token, got %v and %v", app, bot)
+	}
+}
+
+func TestMissingScope(t *testing.T) {
+	s := slacktest.NewServer(slacktest.Chaos{})
+	defer s.Close()
+	s.Handle("auth.test", func(w http.ResponseWriter, c *slacktest.Call) {
+		w.Header().Set("X-OAuth-Scopes", "chat:write")
+		slacktest.Reply(w, map[string]interface{}{"user_id": "UBOT"})
+	})
+	sw, err := New("APP", "BOT", WithAPIURL(s.APIURL()))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if _, _, err := sw.PostMessage("C1", slack.MsgOptionText("Hello", false)); err != nil {
+		t.Errorf("Expected the granted scope to be allowed, got %s", err)
+	}
+	err = sw.AddReaction("tada", slack.ItemRef{Channel: "C1", Timestamp: "1.1"})
+	missing, ok := err.(*ErrMissingScope)
+	if !ok || missing.Need != "reactions:write" || missing.Operation != "AddReaction" {
+		t.Fatalf("Expected the missing scope to be reported, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "reinstall the app") {
+		t.Errorf("Expected the error to say how to fix it, got %s", err)
+	}
+	if calls := s.Calls("reactions.add"); len(calls) != 0 {
+		t.Errorf("Expected Slack not to be called without the scope, got %d calls", len(calls))
+	}
+}
+
+func TestConversationScopes(t *testing.T) {
+	tests := []struct {
+		granted []string
+		op      string
+		channel string
+		types   []string
+		need    string
+	}{
+		{[]string{"channels:history"}, "GetThreadReplies", "C1", nil, ""},
+		{[]string{"groups:history"}, "GetThreadReplies", "C1", nil, ""},
+		{[]string{"im:history"}, "GetThreadReplies", "C1", nil, "channels:history or groups:history"},
+		{[]string{"groups:history"}, "GetConversationHistory", "G1", nil, ""},
+		{[]string{"mpim:history"}, "GetConversationHistory", "G1", nil, ""},
+		{[]string{"channels:history"}, "GetConversationHistory", "G1", nil, "groups:history or mpim:history"},
+		{[]string{"im:history"}, "GetThreadReplies", "D1", nil, ""},
+		{[]string{"channels:history", "groups:history"}, "GetThreadReplies", "D1", nil, "im:history"},
+		{[]string{"im:read"}, "GetConversationMembers", "D1", nil, ""},
+		{[]string{"channels:read"}, "GetConversationMembers", "G1", nil, "groups:read or mpim:read"},
+		{[]string{"channels:read"}, "ListConversations", "", nil, ""},
+		{[]string{"channels:read"}, "ListConversations", "", []string{"public_channel", "im"}, "im:read"},
+		{[]string{"groups:read", "mpim:read"}, "ListConversations", "", []string{"private_channel", "mpim"}, ""},
+	}
+	for _, tt := range tests {
+		s := &Slack{botScopes: map[string]bool{}}
+		for _, scope := range tt.granted {
+			s.botScopes[scope] = true
+		}
+		var err error
+		if tt.op == "ListConversations" {
+			err = s.guardTypes(tt.op, tt.types)
+		} else {
+			err = s.guardChannel(tt.op, tt.channel)
+		}
+		need := ""
+		if missing, ok := err.(*ErrMissingScope); ok {
+			need = missing.Need
+		} else if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		if need != tt.need {
+			t.Errorf("Expected %s of %s%v with %v to need %q, got %q", tt.op, tt.channel, tt.types, tt.granted, tt.need, need)
+		}
+	}
+}
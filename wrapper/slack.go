@@ -3,13 +3,30 @@ package wrapper
 import (
 	//"github.com/BeepBoopHQ/go-slackbot"
 	"github.com/nlopes/slack"
+	"github.com/skybet/go-helpdesk/logger"
+	"github.com/skybet/go-helpdesk/views"
 
+	"context"
 	"fmt"
+	"io"
+	"net/http"
+	"time"
 )
 
 // SlackWrapper is a interface for Slack to enable test double injection
 type SlackWrapper interface {
 	OpenDialog(triggerID string, dialog slack.Dialog) error
+	OpenView(triggerID string, view *views.View) (*views.View, error)
+	UpdateView(view *views.View, viewID, hash string) (*views.View, error)
+	PushView(triggerID string, view *views.View) (*views.View, error)
+	PublishView(userID string, view *views.View) (*views.View, error)
+	PostMessage(channelID string, options ...slack.MsgOption) (string, string, error)
+	ScheduleMessage(channelID string, postAt time.Time, options ...slack.MsgOption) (string, error)
+	UpdateMessage(channelID, timestamp string, options ...slack.MsgOption) (string, string, string, error)
+	AddReaction(name string, item slack.ItemRef) error
+	RemovePin(channel string, item slack.ItemRef) error
+	UploadFile(params slack.FileUploadParameters) (*slack.File, error)
+	DownloadFile(url string, w io.Writer) error
 	//SendMessage(message, channel string)
 }
 
@@ -17,13 +34,79 @@ type SlackWrapper interface {
 type Slack struct {
 	App *slack.Client
 	Bot *slack.Client
+	// Directory looks up users and channels through the bot client, caching
+	// the lists if configured with WithDirectoryCache
+	Directory       *Directory
+	appToken        string
+	botToken        string
+	apiURL          string
+	httpClient      *http.Client
+	directoryConfig DirectoryConfig
+	requestHooks    []Hook
+	responseHooks   []Hook
+	rateLimits      *RateLimits
+	// botScopes are the scopes granted to the bot token when it was checked
+	// by New, nil if Slack did not say
+	botScopes map[string]bool
+	// ctx is the context calls are made in, set by WithContext
+	ctx context.Context
+	// logger is where the clients log, nil to not log their debugging
+	logger logger.Logger
+}
+
+// Option configures the Slack wrapper
+type Option func(*Slack)
+
+// WithAPIURL sets the base URL of the Slack Web API, only useful for testing
+func WithAPIURL(u string) Option {
+	return func(s *Slack) {
+		s.apiURL = u
+	}
+}
+
+// WithHTTPClient sets the HTTP client used for every Slack API call
+func WithHTTPClient(c *http.Client) Option {
+	return func(s *Slack) {
+		s.httpClient = c
+	}
+}
+
+// WithDirectoryCache caches the users.list and conversations.list endpoints
+// used by Directory
+func WithDirectoryCache(c DirectoryConfig) Option {
+	return func(s *Slack) {
+		s.directoryConfig = c
+	}
+}
+
+// WithLogger logs the clients' debugging, such as the RTM connection's
+// events, to l at debug level, and errors the wrapper does not return
+func WithLogger(l logger.Logger) Option {
+	return func(s *Slack) {
+		s.logger = l
+	}
 }
 
 // New takes an app and bot token, verifies the connection and
 // returns an initialised Slack struct
-func New(appToken, botToken string) (*Slack, error) {
-	slackApp := slack.New(appToken)
-	slackBot := slack.New(botToken)
+func New(appToken, botToken string, opts ...Option) (*Slack, error) {
+	s := &Slack{
+		appToken:   appToken,
+		botToken:   botToken,
+		apiURL:     slack.APIURL,
+		httpClient: &http.Client{},
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	// Hooks see every attempt at a call, including those the limiter retries
+	s.httpClient = limit(hook(trace(s.httpClient), s.requestHooks, s.responseHooks), s.rateLimits)
+	clientOpts := []slack.Option{slack.OptionAPIURL(s.apiURL), slack.OptionHTTPClient(s.httpClient)}
+	if s.logger != nil {
+		clientOpts = append(clientOpts, slack.OptionLog(logger.Output{Logger: s.logger}), slack.OptionDebug(true))
+	}
+	slackApp := slack.New(appToken, clientOpts...)
+	slackBot := slack.New(botToken, clientOpts...)
 
 	// Check tokens are valid
 	_, err := slackApp.AuthTest()
@@ -34,18 +117,91 @@ func New(appToken, botToken string) (*Slack, error) {
 	if _, err = slackBot.AuthTest(); err != nil {
 		return nil, err
 	}
-	return &Slack{App: slackApp, Bot: slackBot}, nil
+	scopes, err := s.scopes(botToken)
+	if err != nil {
+		return nil, fmt.Errorf("error checking bot token scopes: %s", err)
+	}
+	if len(scopes) > 0 {
+		s.botScopes = map[string]bool{}
+		for _, scope := range scopes {
+			s.botScopes[scope] = true
+		}
+	}
+	s.App = slackApp
+	s.Bot = slackBot
+	s.Directory = NewDirectory(slackBot, s.directoryConfig)
+	return s, nil
 }
 
 // OpenDialog opens a Dialog inside Slack
 func (s *Slack) OpenDialog(triggerID string, dialog slack.Dialog) error {
-	err := s.App.OpenDialog(triggerID, dialog)
+	err := s.App.OpenDialogContext(s.callContext(), triggerID, dialog)
 	if err != nil {
-		fmt.Printf("error opening dialog. %s\n", err)
+		s.log().Errorf("error opening dialog. %s", err)
 		return err
 	}
 	return err
 }
+
+// log returns the logger of the request the call is made for, else the one
+// given to WithLogger, else the default logger
+func (s *Slack) log() logger.Logger {
+	if l := logger.FromContext(s.callContext()); l != nil {
+		return l
+	}
+	return logger.Or(s.logger)
+}
+
+// PostMessage posts a message as the bot, returning the channel and timestamp
+// of the message
+func (s *Slack) PostMessage(channelID string, options ...slack.MsgOption) (string, string, error) {
+	if err := s.guard("PostMessage"); err != nil {
+		return "", "", err
+	}
+	return s.Bot.PostMessageContext(s.callContext(), channelID, options...)
+}
+
+// UpdateMessage edits a message previously posted by the bot
+func (s *Slack) UpdateMessage(channelID, timestamp string, options ...slack.MsgOption) (string, string, string, error) {
+	if err := s.guard("UpdateMessage"); err != nil {
+		return "", "", "", err
+	}
+	return s.Bot.UpdateMessageContext(s.callContext(), channelID, timestamp, options...)
+}
+
+// AddReaction adds an emoji reaction to an item as the bot
+func (s *Slack) AddReaction(name string, item slack.ItemRef) error {
+	if err := s.guard("AddReaction"); err != nil {
+		return err
+	}
+	return s.Bot.AddReactionContext(s.callContext(), name, item)
+}
+
+// RemovePin unpins an item from a channel as the bot
+func (s *Slack) RemovePin(channel string, item slack.ItemRef) error {
+	if err := s.guard("RemovePin"); err != nil {
+		return err
+	}
+	return s.Bot.RemovePinContext(s.callContext(), channel, item)
+}
+
+// UploadFile uploads a file as the bot, sharing it in params.Channels
+func (s *Slack) UploadFile(params slack.FileUploadParameters) (*slack.File, error) {
+	if err := s.guard("UploadFile"); err != nil {
+		return nil, err
+	}
+	return s.Bot.UploadFileContext(s.callContext(), params)
+}
+
+// DownloadFile writes the content of a file shared with the bot to w, url is
+// the file's private download URL
+func (s *Slack) DownloadFile(url string, w io.Writer) error {
+	if err := s.guard("DownloadFile"); err != nil {
+		return err
+	}
+	return s.Bot.GetFile(url, w)
+}
+
 //
 //// SendMessage posts a message to Slack that is visible to everyone in the channel
 //func (c slack.Client) SendMessage(channelID, message string, params slack.PostMessageParameters) {
@@ -74,4 +230,4 @@ func (s *Slack) OpenDialog(triggerID string, dialog slack.Dialog) error {
 //		fmt.Printf("%s\n", err)
 //		return
 //	}
-//}
\ No newline at end of file
+//}
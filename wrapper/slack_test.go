@@ -1,6 +1,18 @@
 package wrapper
 
-import "testing"
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/nlopes/slack"
+
+	"github.com/skybet/go-helpdesk/logger"
+	"github.com/skybet/go-helpdesk/slacktest"
+)
 
 func TestInit(t *testing.T) {
 	_, err := New("", "")
@@ -8,3 +20,58 @@ func TestInit(t *testing.T) {
 		t.Errorf("Invalid slack connections did not return an error")
 	}
 }
+
+// lines is a logger keeping what it logs
+type lines struct {
+	mu    sync.Mutex
+	lines []string
+}
+
+func (l *lines) logger() logger.Logger {
+	return logger.Funcs(func(format string, args ...interface{}) {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		l.lines = append(l.lines, fmt.Sprintf(format, args...))
+	}, func(format string, args ...interface{}) {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		l.lines = append(l.lines, "error: "+fmt.Sprintf(format, args...))
+	})
+}
+
+func (l *lines) String() string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return strings.Join(l.lines, "\n")
+}
+
+func TestWithLogger(t *testing.T) {
+	s := slacktest.NewServer(slacktest.Chaos{})
+	defer s.Close()
+	s.Handle("dialog.open", func(w http.ResponseWriter, c *slacktest.Call) {
+		slacktest.ReplyError(w, "expired_trigger_id")
+	})
+	client, request := &lines{}, &lines{}
+	var hooked logger.Logger
+	sw, err := New("APP", "BOT", WithAPIURL(s.APIURL()), WithLogger(client.logger()), WithResponseHook(func(c *Call) {
+		hooked = logger.FromContext(c.Context)
+	}))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if !strings.Contains(client.String(), "Challenging auth") {
+		t.Errorf("Expected the clients' debugging to be logged, got %q", client.String())
+	}
+
+	l := request.logger().With("request_id", "r1")
+	ctx := logger.NewContext(context.Background(), l)
+	if err := WithContext(ctx, sw).OpenDialog("TRIGGER", slack.Dialog{}); err == nil {
+		t.Fatalf("Expected the dialog to fail to open")
+	}
+	if got := request.String(); got != "error: error opening dialog. expired_trigger_id request_id=r1" {
+		t.Errorf("Expected the error to be logged for the request, got %q", got)
+	}
+	if hooked != l {
+		t.Errorf("Expected hooks to be given the request's context")
+	}
+}
@@ -0,0 +1,100 @@
+package wrapper
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/nlopes/slack"
+)
+
+// snapshot is the directory's cached lists and usergroups as saved by Save,
+// with when each was fetched from Slack
+type snapshot struct {
+	Users           []slack.User    `json:"users,omitempty"`
+	UsersFetched    time.Time       `json:"users_fetched"`
+	Channels        []slack.Channel `json:"channels,omitempty"`
+	ChannelsFetched time.Time       `json:"channels_fetched"`
+	// Usergroups are least recently used first
+	Usergroups []groupSnapshot `json:"usergroups,omitempty"`
+}
+
+type groupSnapshot struct {
+	ID      string    `json:"id"`
+	Members []string  `json:"members"`
+	Fetched time.Time `json:"fetched"`
+}
+
+// Save writes the cached user and channel lists and usergroup members to w as
+// JSON, so that a restarted instance can Load them rather than fetch them all
+// from Slack at once
+func (d *Directory) Save(w io.Writer) error {
+	var s snapshot
+	d.users.mu.Lock()
+	if d.users.list != nil {
+		s.Users, s.UsersFetched = d.users.list.([]slack.User), d.users.fetched
+	}
+	d.users.mu.Unlock()
+	d.channels.mu.Lock()
+	if d.channels.list != nil {
+		s.Channels, s.ChannelsFetched = d.channels.list.([]slack.Channel), d.channels.fetched
+	}
+	d.channels.mu.Unlock()
+	d.groups.each(func(id string, members interface{}, fetched time.Time) {
+		s.Usergroups = append(s.Usergroups, groupSnapshot{ID: id, Members: members.([]string), Fetched: fetched})
+	})
+	if err := json.NewEncoder(w).Encode(s); err != nil {
+		return fmt.Errorf("error writing directory snapshot: %s", err)
+	}
+	return nil
+}
+
+// Load fills the caches from a snapshot written by Save. The lists are served
+// straight away, and those older than the TTL are fetched again in the
+// background the first time they are read. Lists already cached are kept. It
+// does nothing if caching is disabled.
+func (d *Directory) Load(r io.Reader) error {
+	if d.config.TTL <= 0 {
+		return nil
+	}
+	var s snapshot
+	if err := json.NewDecoder(r).Decode(&s); err != nil {
+		return fmt.Errorf("error reading directory snapshot: %s", err)
+	}
+	if s.Users != nil {
+		if d.config.MaxUsers > 0 && len(s.Users) > d.config.MaxUsers {
+			s.Users = s.Users[:d.config.MaxUsers]
+		}
+		index := make(map[string]int, len(s.Users))
+		for i, u := range s.Users {
+			index[u.ID] = i
+		}
+		d.users.restore(s.Users, index, s.UsersFetched)
+	}
+	if s.Channels != nil {
+		if d.config.MaxChannels > 0 && len(s.Channels) > d.config.MaxChannels {
+			s.Channels = s.Channels[:d.config.MaxChannels]
+		}
+		index := make(map[string]int, len(s.Channels))
+		for i, c := range s.Channels {
+			index[c.ID] = i
+		}
+		d.channels.restore(s.Channels, index, s.ChannelsFetched)
+	}
+	for _, g := range s.Usergroups {
+		// Usergroups are only looked up one at a time, stale ones are misses
+		d.groups.add(g.ID, g.Members, g.Fetched)
+	}
+	return nil
+}
+
+// restore sets the list from a snapshot unless one has been loaded already
+func (c *listCache) restore(list interface{}, index map[string]int, fetched time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.list != nil || c.loading != nil {
+		return
+	}
+	c.list, c.index, c.fetched, c.restored = list, index, fetched, true
+}
@@ -0,0 +1,82 @@
+package wrapper
+
+import (
+	"bytes"
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/nlopes/slack"
+)
+
+func TestDirectorySnapshot(t *testing.T) {
+	api := &fakeDirectoryAPI{
+		users:    []slack.User{{ID: "U1"}, {ID: "U2"}},
+		channels: [][]slack.Channel{{channel("C1")}},
+		groups:   map[string][]string{"S1": {"U1"}},
+	}
+	config := DirectoryConfig{TTL: time.Minute, Usergroups: 10}
+	d := NewDirectory(api, config)
+	d.Users(context.Background())
+	d.Channels(context.Background())
+	d.UsergroupMembers(context.Background(), "S1")
+	var buf bytes.Buffer
+	if err := d.Save(&buf); err != nil {
+		t.Fatalf("Unexpected error saving: %s", err)
+	}
+
+	// The restarted instance starts after the snapshot has gone stale
+	block := make(chan struct{})
+	restarted := &fakeDirectoryAPI{users: []slack.User{{ID: "U3"}}, channels: [][]slack.Channel{{}}, block: block}
+	d = NewDirectory(restarted, config)
+	now := time.Now().Add(2 * time.Minute)
+	d.now = func() time.Time { return now }
+	if err := d.Load(&buf); err != nil {
+		t.Fatalf("Unexpected error loading: %s", err)
+	}
+	users, err := d.Users(context.Background())
+	if err != nil || len(users) != 2 {
+		t.Fatalf("Expected the snapshot's users to be served while they are fetched again, got %v %v", users, err)
+	}
+	d.Users(context.Background())
+	if members, _ := d.UsergroupMembers(context.Background(), "S1"); members != nil {
+		t.Errorf("Expected stale usergroups to be looked up again, got %v", members)
+	}
+	close(block)
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		if users, _ := d.Users(context.Background()); len(users) == 1 && users[0].ID == "U3" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected the users to be fetched again in the background")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := atomic.LoadInt32(&restarted.listCalls); n != 1 {
+		t.Errorf("Expected the stale users to be fetched once, got %d list calls", n)
+	}
+	if c, err := d.Channel(context.Background(), "C1"); err != nil || c.ID != "C1" || atomic.LoadInt32(&restarted.infoCalls) != 1 {
+		t.Errorf("Expected C1 from the snapshot, got %v %v", c, err)
+	}
+}
+
+func TestDirectorySnapshotFresh(t *testing.T) {
+	api := &fakeDirectoryAPI{users: []slack.User{{ID: "U1"}}, groups: map[string][]string{"S1": {"U1"}}}
+	config := DirectoryConfig{TTL: time.Minute, Usergroups: 10}
+	d := NewDirectory(api, config)
+	d.Users(context.Background())
+	d.UsergroupMembers(context.Background(), "S1")
+	var buf bytes.Buffer
+	d.Save(&buf)
+
+	restarted := &fakeDirectoryAPI{}
+	d = NewDirectory(restarted, config)
+	d.Load(&buf)
+	users, _ := d.Users(context.Background())
+	members, _ := d.UsergroupMembers(context.Background(), "S1")
+	if len(users) != 1 || len(members) != 1 || restarted.listCalls != 0 || restarted.infoCalls != 0 {
+		t.Errorf("Expected a fresh snapshot to be served without calling Slack, got %v %v", users, members)
+	}
+}
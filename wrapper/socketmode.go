@@ -0,0 +1,403 @@
+package wrapper
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/nlopes/slack"
+
+	"github.com/skybet/go-helpdesk/logger"
+)
+
+// ErrSocketModeStopped is returned when using a SocketModeManager after its
+// context has been cancelled
+var ErrSocketModeStopped = errors.New("socket mode manager has stopped")
+
+// Socket Mode envelope types, these are the Type of the slack.RTMEvent each
+// Envelope is delivered in
+const (
+	EnvelopeEventsAPI     = "events_api"
+	EnvelopeInteractive   = "interactive"
+	EnvelopeSlashCommands = "slash_commands"
+)
+
+// maxSocketModeBackoff is the longest the manager waits between attempts to
+// reconnect
+const maxSocketModeBackoff = 30 * time.Second
+
+// fatalConnectionErrors are the apps.connections.open errors which retrying
+// can not fix
+var fatalConnectionErrors = map[string]bool{"invalid_auth": true, "not_authed": true, "account_inactive": true, "token_revoked": true, "not_allowed_token_type": true}
+
+// Envelope is a request Slack sent over a Socket Mode connection, such as an
+// Events API callback. It must be acknowledged with Ack within three seconds
+// or Slack sends it again.
+type Envelope struct {
+	ID      string          `json:"envelope_id"`
+	Type    string          `json:"type"`
+	Payload json.RawMessage `json:"payload"`
+	// AcceptsResponsePayload is set when the acknowledgement can carry the
+	// response, such as the reply to a slash command
+	AcceptsResponsePayload bool `json:"accepts_response_payload"`
+	RetryAttempt           int  `json:"retry_attempt"`
+
+	ack func(v interface{}) error
+}
+
+// Ack acknowledges the envelope, sending payload as the response if the
+// envelope accepts one. Pass nil for no response.
+func (e *Envelope) Ack(payload interface{}) error {
+	msg := map[string]interface{}{"envelope_id": e.ID}
+	if payload != nil && e.AcceptsResponsePayload {
+		msg["payload"] = payload
+	}
+	if err := e.ack(msg); err != nil {
+		return fmt.Errorf("error acknowledging envelope %s: %s", e.ID, err)
+	}
+	return nil
+}
+
+// SocketModeManager owns a Slack Socket Mode connection, which receives the
+// events, interactions and slash commands otherwise sent to the app's public
+// HTTP endpoint. Like RTMManager it is connected and disconnected by a single
+// goroutine, and it reconnects by itself when Slack drops or refreshes the
+// connection.
+//
+// Envelopes are delivered on IncomingEvents as a slack.RTMEvent whose Type is
+// the envelope type and whose Data is the *Envelope, alongside the usual
+// connecting, hello, connection_error and disconnected events.
+type SocketModeManager struct {
+	// Logger, if set before connecting, logs the connection's lifecycle
+	Logger logger.Logger
+	open   func(ctx context.Context) (string, error)
+	dialer *websocket.Dialer
+	events chan slack.RTMEvent
+	ops    chan rtmOp
+	done   chan struct{}
+	// mu guards stopping, which is set by Shutdown, and adding to unacked,
+	// the envelopes delivered which have not been acknowledged
+	mu       sync.Mutex
+	stopping bool
+	unacked  sync.WaitGroup
+}
+
+// socketConn is a single managed connection, reconnected until it is stopped
+type socketConn struct {
+	cancel func()
+	ended  chan struct{}
+	// mu serialises writes, acknowledgements can come from any goroutine
+	mu sync.Mutex
+}
+
+// NewSocketModeManager starts a manager for Socket Mode connections opened
+// with the app-level token. apiURL is the base URL of the Web API, such as
+// slack.APIURL. The manager disconnects and stops once ctx is cancelled.
+func NewSocketModeManager(ctx context.Context, token, apiURL string, client *http.Client) *SocketModeManager {
+	m := &SocketModeManager{
+		open: func(ctx context.Context) (string, error) {
+			return openConnection(ctx, client, apiURL, token)
+		},
+		dialer: websocket.DefaultDialer,
+		events: make(chan slack.RTMEvent, 50),
+		ops:    make(chan rtmOp),
+		done:   make(chan struct{}),
+	}
+	go m.run(ctx)
+	return m
+}
+
+// IncomingEvents returns the envelopes and connection events received across
+// every connection
+func (m *SocketModeManager) IncomingEvents() <-chan slack.RTMEvent {
+	return m.events
+}
+
+// Done returns a channel which is closed once the manager has stopped and
+// its connection has been cleaned up
+func (m *SocketModeManager) Done() <-chan struct{} {
+	return m.done
+}
+
+// Connect starts a managed connection if one is not already running
+func (m *SocketModeManager) Connect() error {
+	return m.do(true)
+}
+
+// Disconnect closes the current connection, if any, and waits for it to be
+// cleaned up
+func (m *SocketModeManager) Disconnect() error {
+	return m.do(false)
+}
+
+// Shutdown stops delivering envelopes, leaving those received afterwards for
+// Slack to send again, waits for the envelopes delivered to be acknowledged
+// or ctx to be done, then closes the connection cleanly
+func (m *SocketModeManager) Shutdown(ctx context.Context) error {
+	m.mu.Lock()
+	m.stopping = true
+	m.mu.Unlock()
+	acked := make(chan struct{})
+	go func() {
+		m.unacked.Wait()
+		close(acked)
+	}()
+	var err error
+	select {
+	case <-acked:
+	case <-ctx.Done():
+		err = fmt.Errorf("envelopes were still unacknowledged: %s", ctx.Err())
+	}
+	if derr := m.Disconnect(); derr != nil && derr != ErrSocketModeStopped && err == nil {
+		err = derr
+	}
+	return err
+}
+
+// deliverable counts an envelope as delivered, false once shutting down
+func (m *SocketModeManager) deliverable() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.stopping {
+		return false
+	}
+	m.unacked.Add(1)
+	return true
+}
+
+func (m *SocketModeManager) do(connect bool) error {
+	op := rtmOp{connect: connect, result: make(chan error, 1)}
+	select {
+	case m.ops <- op:
+	case <-m.done:
+		return ErrSocketModeStopped
+	}
+	return <-op.result
+}
+
+// run is the owner goroutine, nothing else starts or stops connections
+func (m *SocketModeManager) run(ctx context.Context) {
+	defer close(m.done)
+	var conn *socketConn
+	for {
+		var ended chan struct{}
+		if conn != nil {
+			ended = conn.ended
+		}
+		select {
+		case <-ctx.Done():
+			if conn != nil {
+				conn.stop()
+			}
+			return
+		case op := <-m.ops:
+			if op.connect && conn == nil {
+				conn = m.start(ctx)
+			} else if !op.connect && conn != nil {
+				conn.stop()
+				conn = nil
+			}
+			op.result <- nil
+		case <-ended:
+			// The connection gave up by itself, e.g. on invalid auth
+			conn = nil
+		}
+	}
+}
+
+func (m *SocketModeManager) start(parent context.Context) *socketConn {
+	ctx, cancel := context.WithCancel(parent)
+	c := &socketConn{cancel: cancel, ended: make(chan struct{})}
+	go c.manage(ctx, m)
+	return c
+}
+
+// stop disconnects and blocks until the connection's goroutines have exited
+func (c *socketConn) stop() {
+	c.cancel()
+	<-c.ended
+}
+
+// manage keeps a connection open until ctx is cancelled, backing off between
+// failed attempts
+func (c *socketConn) manage(ctx context.Context, m *SocketModeManager) {
+	defer close(c.ended)
+	emit := func(e slack.RTMEvent) bool {
+		countReconnect("socket_mode", e)
+		if m.Logger != nil {
+			logConnection(m.Logger, e)
+		}
+		select {
+		case m.events <- e:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+	for attempt, count := 1, 0; ; attempt++ {
+		if !emit(slack.RTMEvent{Type: "connecting", Data: &slack.ConnectingEvent{Attempt: attempt, ConnectionCount: count}}) {
+			return
+		}
+		ws, err := c.dial(ctx, m)
+		if err == nil {
+			attempt, count = 0, count+1
+			err = c.read(ctx, m, ws, emit)
+		}
+		if ctx.Err() != nil {
+			emit(slack.RTMEvent{Type: "disconnected", Data: &slack.DisconnectedEvent{Intentional: true}})
+			return
+		}
+		var fatal fatalError
+		if errors.As(err, &fatal) {
+			emit(slack.RTMEvent{Type: "disconnected", Data: &slack.DisconnectedEvent{Cause: err}})
+			return
+		}
+		backoff := time.Duration(attempt) * time.Second
+		if backoff > maxSocketModeBackoff {
+			backoff = maxSocketModeBackoff
+		}
+		if !emit(slack.RTMEvent{Type: "connection_error", Data: &slack.ConnectionErrorEvent{Attempt: attempt, Backoff: backoff, ErrorObj: err}}) {
+			return
+		}
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// dial asks Slack for a websocket URL and connects to it. Each URL is only
+// good for one connection.
+func (c *socketConn) dial(ctx context.Context, m *SocketModeManager) (*websocket.Conn, error) {
+	u, err := m.open(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ws, _, err := m.dialer.DialContext(ctx, u, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error connecting to %s: %s", u, err)
+	}
+	return ws, nil
+}
+
+// read delivers the envelopes received on ws until it fails, Slack asks for a
+// reconnect or ctx is cancelled
+func (c *socketConn) read(ctx context.Context, m *SocketModeManager, ws *websocket.Conn, emit func(slack.RTMEvent) bool) error {
+	closed := make(chan struct{})
+	defer close(closed)
+	go func() {
+		select {
+		case <-ctx.Done():
+			// Closing cleanly tells Slack to stop sending to this connection
+			// rather than wait for it to time out
+			ws.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(time.Second))
+		case <-closed:
+		}
+		ws.Close()
+	}()
+	ack := func(v interface{}) error {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		return ws.WriteJSON(v)
+	}
+	for {
+		var frame struct {
+			Envelope
+			Reason string `json:"reason"`
+		}
+		if err := ws.ReadJSON(&frame); err != nil {
+			return fmt.Errorf("error reading from socket: %s", err)
+		}
+		switch frame.Type {
+		case "hello":
+			emit(slack.RTMEvent{Type: "hello", Data: &slack.HelloEvent{}})
+		case "disconnect":
+			// Slack refreshes connections every few hours and warns first
+			return fmt.Errorf("slack closed the connection: %s", frame.Reason)
+		default:
+			if frame.ID == "" {
+				continue
+			}
+			if !m.deliverable() {
+				continue
+			}
+			var once sync.Once
+			e := frame.Envelope
+			e.ack = func(v interface{}) error {
+				defer once.Do(m.unacked.Done)
+				return ack(v)
+			}
+			if !emit(slack.RTMEvent{Type: e.Type, Data: &e}) {
+				once.Do(m.unacked.Done)
+				return ctx.Err()
+			}
+		}
+	}
+}
+
+// fatalError is an apps.connections.open error which retrying can not fix
+type fatalError struct {
+	code string
+}
+
+func (e fatalError) Error() string {
+	return fmt.Sprintf("slack error: %s", e.code)
+}
+
+// openConnection calls apps.connections.open, returning the websocket URL
+func openConnection(ctx context.Context, client *http.Client, apiURL, token string) (string, error) {
+	req, err := http.NewRequest(http.MethodPost, apiURL+"apps.connections.open", nil)
+	if err != nil {
+		return "", err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	res, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("error opening socket mode connection: %s", err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("error opening socket mode connection: %s", res.Status)
+	}
+	var body struct {
+		OK    bool   `json:"ok"`
+		Error string `json:"error"`
+		URL   string `json:"url"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+		return "", fmt.Errorf("error decoding apps.connections.open response: %s", err)
+	}
+	if !body.OK {
+		if fatalConnectionErrors[body.Error] {
+			return "", fatalError{body.Error}
+		}
+		return "", fmt.Errorf("slack error: %s", body.Error)
+	}
+	return body.URL, nil
+}
+
+// logConnection logs an event about the state of a connection at debug level
+func logConnection(l logger.Logger, e slack.RTMEvent) {
+	switch ev := e.Data.(type) {
+	case *slack.ConnectingEvent:
+		l.Debugf("Socket Mode connecting, attempt %d after %d connections", ev.Attempt, ev.ConnectionCount)
+	case *slack.ConnectionErrorEvent:
+		l.Debugf("Socket Mode connection attempt %d failed, retrying in %s: %s", ev.Attempt, ev.Backoff, ev.ErrorObj)
+	case *slack.DisconnectedEvent:
+		if ev.Cause != nil {
+			l.Debugf("Socket Mode disconnected: %s", ev.Cause)
+		} else {
+			l.Debugf("Socket Mode disconnected")
+		}
+	case *slack.HelloEvent:
+		l.Debugf("Socket Mode connected")
+	}
+}
@@ -0,0 +1,160 @@
+package wrapper
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/nlopes/slack"
+	"github.com/skybet/go-helpdesk/slacktest"
+)
+
+// nextEvent returns the next event of the given type from an RTMManager or
+// SocketModeManager, skipping others
+func nextEvent(t *testing.T, m interface{ IncomingEvents() <-chan slack.RTMEvent }, eventType string) slack.RTMEvent {
+	t.Helper()
+	timeout := time.After(2 * time.Second)
+	for {
+		select {
+		case e := <-m.IncomingEvents():
+			if e.Type == eventType {
+				return e
+			}
+		case <-timeout:
+			t.Fatalf("Timed out waiting for a %s event", eventType)
+		}
+	}
+}
+
+func TestSocketModeManager(t *testing.T) {
+	s := slacktest.NewServer(slacktest.Chaos{})
+	defer s.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	m := NewSocketModeManager(ctx, "xapp-TOKEN", s.APIURL(), &http.Client{})
+	logged := &lines{}
+	m.Logger = logged.logger()
+	if err := m.Connect(); err != nil {
+		t.Fatalf("Unexpected error connecting: %s", err)
+	}
+	nextEvent(t, m, "hello")
+	if got := logged.String(); got != "Socket Mode connecting, attempt 1 after 0 connections\nSocket Mode connected" {
+		t.Errorf("Expected the connection to be logged, got %q", got)
+	}
+	if calls := s.Calls("apps.connections.open"); len(calls) != 1 || calls[0].Header.Get("Authorization") != "Bearer xapp-TOKEN" {
+		t.Errorf("Expected the connection to be opened with the app-level token, got %+v", calls)
+	}
+
+	s.SendEvent(map[string]interface{}{
+		"envelope_id":              "E1",
+		"type":                     EnvelopeSlashCommands,
+		"payload":                  map[string]string{"command": "/hd", "text": "help"},
+		"accepts_response_payload": true,
+	})
+	e := nextEvent(t, m, EnvelopeSlashCommands)
+	env, ok := e.Data.(*Envelope)
+	if !ok || env.ID != "E1" || !env.AcceptsResponsePayload {
+		t.Fatalf("Expected the envelope to be delivered, got %+v", e.Data)
+	}
+	if err := env.Ack(map[string]string{"text": "Usage"}); err != nil {
+		t.Fatalf("Unexpected error acknowledging: %s", err)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for len(s.Acks()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if acks := s.Acks(); len(acks) != 1 || acks[0].EnvelopeID != "E1" || string(acks[0].Payload) != `{"text":"Usage"}` {
+		t.Errorf("Expected the envelope to be acknowledged with the response, got %+v", acks)
+	}
+
+	// Slack asks clients to reconnect when it refreshes a connection
+	s.SendEvent(map[string]string{"type": "disconnect", "reason": "refresh_requested"})
+	nextEvent(t, m, "connection_error")
+	nextEvent(t, m, "hello")
+	if calls := s.Calls("apps.connections.open"); len(calls) != 2 {
+		t.Errorf("Expected a new connection to be opened, got %d calls", len(calls))
+	}
+
+	cancel()
+	select {
+	case <-m.Done():
+	case <-time.After(2 * time.Second):
+		t.Fatalf("Expected the manager to stop once its context was cancelled")
+	}
+	if err := m.Connect(); err != ErrSocketModeStopped {
+		t.Errorf("Expected ErrSocketModeStopped after stopping, got %v", err)
+	}
+}
+
+func TestSocketModeManagerInvalidAuth(t *testing.T) {
+	s := slacktest.NewServer(slacktest.Chaos{})
+	defer s.Close()
+	s.Handle("apps.connections.open", func(w http.ResponseWriter, c *slacktest.Call) {
+		slacktest.ReplyError(w, "invalid_auth")
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	m := NewSocketModeManager(ctx, "xapp-WRONG", s.APIURL(), &http.Client{})
+	m.Connect()
+	e := nextEvent(t, m, "disconnected")
+	if d := e.Data.(*slack.DisconnectedEvent); d.Intentional || d.Cause == nil {
+		t.Errorf("Expected the manager to give up on invalid auth, got %+v", d)
+	}
+	// The manager can be asked to connect again, e.g. once the token is fixed
+	m.Connect()
+	nextEvent(t, m, "connecting")
+}
+
+func TestEnvelopeAckWithoutResponse(t *testing.T) {
+	var sent interface{}
+	e := &Envelope{ID: "E1", ack: func(v interface{}) error { sent = v; return nil }}
+	e.Ack(map[string]string{"text": "ignored"})
+	b, _ := json.Marshal(sent)
+	if string(b) != `{"envelope_id":"E1"}` {
+		t.Errorf("Expected envelopes which accept no response to be acknowledged without one, got %s", b)
+	}
+}
+
+func TestSocketModeManagerShutdown(t *testing.T) {
+	s := slacktest.NewServer(slacktest.Chaos{})
+	defer s.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	m := NewSocketModeManager(ctx, "xapp-TOKEN", s.APIURL(), &http.Client{})
+	if err := m.Connect(); err != nil {
+		t.Fatalf("Unexpected error connecting: %s", err)
+	}
+	nextEvent(t, m, "hello")
+	s.SendEvent(map[string]interface{}{"envelope_id": "E1", "type": EnvelopeSlashCommands, "payload": map[string]string{"command": "/hd"}})
+	env := nextEvent(t, m, EnvelopeSlashCommands).Data.(*Envelope)
+
+	stopped := make(chan error)
+	go func() { stopped <- m.Shutdown(context.Background()) }()
+	select {
+	case err := <-stopped:
+		t.Fatalf("Expected Shutdown to wait for the envelope to be acknowledged, got %v", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+	// Envelopes received while shutting down are left for Slack to resend
+	s.SendEvent(map[string]interface{}{"envelope_id": "E2", "type": EnvelopeSlashCommands, "payload": map[string]string{"command": "/hd"}})
+	time.Sleep(20 * time.Millisecond)
+	if err := env.Ack(nil); err != nil {
+		t.Fatalf("Unexpected error acknowledging: %s", err)
+	}
+	if err := <-stopped; err != nil {
+		t.Fatalf("Unexpected error shutting down: %s", err)
+	}
+	for len(m.IncomingEvents()) > 0 {
+		if e := <-m.IncomingEvents(); e.Type == EnvelopeSlashCommands {
+			t.Errorf("Expected no envelopes delivered after shutting down, got %+v", e.Data)
+		}
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for len(s.Acks()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if acks := s.Acks(); len(acks) != 1 || acks[0].EnvelopeID != "E1" {
+		t.Errorf("Expected only the envelope delivered to be acknowledged, got %+v", acks)
+	}
+}
@@ -0,0 +1,36 @@
+package wrapper
+
+import (
+	"context"
+
+	"github.com/nlopes/slack"
+)
+
+// PostThreadReply posts a message as the bot in the thread started by the
+// message at threadTS, such as a ticket's, returning the reply's timestamp
+func (s *Slack) PostThreadReply(channelID, threadTS string, options ...slack.MsgOption) (string, error) {
+	if err := s.guard("PostThreadReply"); err != nil {
+		return "", err
+	}
+	_, ts, err := s.Bot.PostMessageContext(s.callContext(), channelID, append(options[:len(options):len(options)], slack.MsgOptionTS(threadTS))...)
+	return ts, err
+}
+
+// BroadcastThreadReply posts a reply like PostThreadReply which is also sent
+// to the channel, for the updates everyone in it should see
+func (s *Slack) BroadcastThreadReply(channelID, threadTS string, options ...slack.MsgOption) (string, error) {
+	if err := s.guard("BroadcastThreadReply"); err != nil {
+		return "", err
+	}
+	_, ts, err := s.Bot.PostMessageContext(s.callContext(), channelID, append(options[:len(options):len(options)], slack.MsgOptionTS(threadTS), slack.MsgOptionBroadcast())...)
+	return ts, err
+}
+
+// GetThreadReplies returns every reply in the thread started by the message
+// at threadTS, oldest first, see Pager
+func (s *Slack) GetThreadReplies(ctx context.Context, channelID, threadTS string) ([]slack.Message, error) {
+	if err := s.guardChannel("GetThreadReplies", channelID); err != nil {
+		return nil, err
+	}
+	return (&Pager{API: s.Bot}).GetThreadReplies(ctx, channelID, threadTS)
+}
@@ -0,0 +1,53 @@
+package wrapper
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/nlopes/slack"
+
+	"github.com/skybet/go-helpdesk/slacktest"
+)
+
+func TestThreadReplies(t *testing.T) {
+	s := slacktest.NewServer(slacktest.Chaos{})
+	defer s.Close()
+	s.Handle("chat.postMessage", func(w http.ResponseWriter, c *slacktest.Call) {
+		slacktest.Reply(w, map[string]interface{}{"channel": c.Form.Get("channel"), "ts": "1.2"})
+	})
+	s.Handle("conversations.replies", func(w http.ResponseWriter, c *slacktest.Call) {
+		msgs := []map[string]interface{}{{"ts": "1.1", "thread_ts": "1.1", "text": "VPN down"}}
+		meta := map[string]interface{}{}
+		if c.Form.Get("cursor") == "" {
+			msgs = append(msgs, map[string]interface{}{"ts": "1.2", "thread_ts": "1.1", "text": "Looking"})
+			meta["next_cursor"] = "page2"
+		} else {
+			msgs = append(msgs, map[string]interface{}{"ts": "1.3", "thread_ts": "1.1", "text": "Fixed"})
+		}
+		slacktest.Reply(w, map[string]interface{}{"messages": msgs, "has_more": len(meta) > 0, "response_metadata": meta})
+	})
+	sw, err := New("APP", "BOT", WithAPIURL(s.APIURL()))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if ts, err := sw.PostThreadReply("C1", "1.1", slack.MsgOptionText("Looking", false)); err != nil || ts != "1.2" {
+		t.Fatalf("Expected the reply to be posted, got %q %v", ts, err)
+	}
+	if _, err := sw.BroadcastThreadReply("C1", "1.1", slack.MsgOptionText("Fixed", false)); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	calls := s.Calls("chat.postMessage")
+	if f := calls[0].Form; f.Get("thread_ts") != "1.1" || f.Get("reply_broadcast") != "" {
+		t.Errorf("Expected a reply in the thread, got %v", f)
+	}
+	if f := calls[1].Form; f.Get("thread_ts") != "1.1" || f.Get("reply_broadcast") != "true" {
+		t.Errorf("Expected a reply sent to the channel too, got %v", f)
+	}
+
+	replies, err := sw.GetThreadReplies(context.Background(), "C1", "1.1")
+	if err != nil || len(replies) != 2 || replies[0].Text != "Looking" || replies[1].Text != "Fixed" {
+		t.Errorf("Expected both pages of replies without the thread's message, got %+v (%v)", replies, err)
+	}
+}
@@ -0,0 +1,70 @@
+package wrapper
+
+import (
+	"context"
+	"net/http"
+	"path"
+	"strconv"
+
+	"github.com/skybet/go-helpdesk/tracing"
+)
+
+// WithContext returns a copy of the wrapper whose calls are made in ctx, so
+// they are cancelled with it and traced as part of the request it belongs to
+func (s *Slack) WithContext(ctx context.Context) SlackWrapper {
+	c := *s
+	c.ctx = ctx
+	return &c
+}
+
+// callContext returns the context calls are made in
+func (s *Slack) callContext() context.Context {
+	if s.ctx != nil {
+		return s.ctx
+	}
+	return context.Background()
+}
+
+// WithContext returns sw making its calls in ctx if it supports it, such as
+// a *Slack, otherwise sw itself
+func WithContext(ctx context.Context, sw SlackWrapper) SlackWrapper {
+	if c, ok := sw.(interface {
+		WithContext(context.Context) SlackWrapper
+	}); ok {
+		return c.WithContext(ctx)
+	}
+	return sw
+}
+
+// traced records a client span for each request made by next
+type traced struct {
+	next http.RoundTripper
+}
+
+// trace wraps c's transport so each Slack Web API call is traced
+func trace(c *http.Client) *http.Client {
+	next := c.Transport
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	hc := *c
+	hc.Transport = &traced{next: next}
+	return &hc
+}
+
+func (t *traced) RoundTrip(r *http.Request) (*http.Response, error) {
+	method := path.Base(r.URL.Path)
+	ctx, span := tracing.Start(r.Context(), "slack.api "+method, tracing.Client)
+	if span == nil {
+		return t.next.RoundTrip(r)
+	}
+	span.Set("slack.method", method)
+	res, err := t.next.RoundTrip(r.WithContext(ctx))
+	if err != nil {
+		span.Finish(err)
+		return nil, err
+	}
+	span.Set("http.status_code", strconv.Itoa(res.StatusCode))
+	span.Finish(outcome(res))
+	return res, nil
+}
@@ -0,0 +1,72 @@
+package wrapper
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/nlopes/slack"
+
+	"github.com/skybet/go-helpdesk/slacktest"
+	"github.com/skybet/go-helpdesk/tracing"
+	"github.com/skybet/go-helpdesk/views"
+)
+
+type spans struct {
+	mu    sync.Mutex
+	spans []*tracing.Span
+}
+
+func (s *spans) Export(span *tracing.Span) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.spans = append(s.spans, span)
+}
+
+func TestTracing(t *testing.T) {
+	s := slacktest.NewServer(slacktest.Chaos{})
+	defer s.Close()
+	s.Handle("reactions.add", func(w http.ResponseWriter, c *slacktest.Call) {
+		slacktest.ReplyError(w, "already_reacted")
+	})
+	sw, err := New("APP", "BOT", WithAPIURL(s.APIURL()))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	exported := &spans{}
+	tracing.Default.Exporter = exported
+	defer func() { tracing.Default.Exporter = nil }()
+
+	ctx, root := tracing.Start(context.Background(), "slack command /hd", tracing.Server)
+	traced := WithContext(ctx, sw)
+	traced.PostMessage("C1", slack.MsgOptionText("Hello", false))
+	traced.AddReaction("tada", slack.ItemRef{Channel: "C1", Timestamp: "1.1"})
+	traced.OpenView("T1", &views.View{Type: "modal"})
+	sw.PostMessage("C1", slack.MsgOptionText("Untraced", false))
+	root.Finish(nil)
+
+	exported.mu.Lock()
+	defer exported.mu.Unlock()
+	if len(exported.spans) != 5 {
+		t.Fatalf("Expected 5 spans, got %d", len(exported.spans))
+	}
+	for i, want := range []string{"slack.api chat.postMessage", "slack.api reactions.add", "slack.api views.open"} {
+		sp := exported.spans[i]
+		if sp.Name != want || sp.Kind != tracing.Client || sp.ParentID != root.SpanID || sp.TraceID != root.TraceID {
+			t.Errorf("Expected %s as a child of the request, got %+v", want, sp)
+		}
+	}
+	if exported.spans[0].Err != nil || exported.spans[0].Attributes()["http.status_code"] != "200" || exported.spans[1].Err == nil {
+		t.Errorf("Expected the outcome of each call, got %v and %v", exported.spans[0].Err, exported.spans[1].Err)
+	}
+	if untraced := exported.spans[3]; untraced.TraceID == root.TraceID {
+		t.Error("Expected a call without the request's context to start its own trace")
+	}
+
+	// Test doubles embedding the interface have no WithContext of their own
+	var double SlackWrapper = struct{ SlackWrapper }{sw}
+	if WithContext(ctx, double) != double {
+		t.Error("Expected a wrapper without WithContext to be returned unchanged")
+	}
+}
@@ -0,0 +1,55 @@
+package wrapper
+
+import (
+	"github.com/skybet/go-helpdesk/views"
+)
+
+type viewResponse struct {
+	View *views.View `json:"view"`
+}
+
+// OpenView opens a modal for the user who triggered triggerID
+func (s *Slack) OpenView(triggerID string, view *views.View) (*views.View, error) {
+	req := map[string]interface{}{"trigger_id": triggerID, "view": view}
+	var resp viewResponse
+	if err := s.postJSON("views.open", s.appToken, req, &resp); err != nil {
+		return nil, err
+	}
+	return resp.View, nil
+}
+
+// UpdateView replaces an open view. If hash is set the update is rejected when
+// the view has changed since the hash was issued.
+func (s *Slack) UpdateView(view *views.View, viewID, hash string) (*views.View, error) {
+	req := map[string]interface{}{"view": view, "view_id": viewID}
+	if hash != "" {
+		req["hash"] = hash
+	}
+	var resp viewResponse
+	if err := s.postJSON("views.update", s.appToken, req, &resp); err != nil {
+		return nil, err
+	}
+	return resp.View, nil
+}
+
+// PushView adds a view to the top of the stack of the modal the user who
+// triggered triggerID has open, such as from a button in the modal. Slack
+// allows three views in a stack.
+func (s *Slack) PushView(triggerID string, view *views.View) (*views.View, error) {
+	req := map[string]interface{}{"trigger_id": triggerID, "view": view}
+	var resp viewResponse
+	if err := s.postJSON("views.push", s.appToken, req, &resp); err != nil {
+		return nil, err
+	}
+	return resp.View, nil
+}
+
+// PublishView sets the App Home tab of a user, the view must be of type home
+func (s *Slack) PublishView(userID string, view *views.View) (*views.View, error) {
+	req := map[string]interface{}{"user_id": userID, "view": view}
+	var resp viewResponse
+	if err := s.postJSON("views.publish", s.botToken, req, &resp); err != nil {
+		return nil, err
+	}
+	return resp.View, nil
+}
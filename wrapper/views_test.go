@@ -0,0 +1,123 @@
+package wrapper
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/skybet/go-helpdesk/slacktest"
+	"github.com/skybet/go-helpdesk/views"
+)
+
+func TestOpenAndUpdateView(t *testing.T) {
+	s := slacktest.NewServer(slacktest.Chaos{})
+	defer s.Close()
+	s.Handle("views.open", func(w http.ResponseWriter, c *slacktest.Call) {
+		var req struct {
+			TriggerID string      `json:"trigger_id"`
+			View      *views.View `json:"view"`
+		}
+		json.Unmarshal(c.Body, &req)
+		if req.TriggerID != "TRIGGER" || req.View.CallbackID != "new_ticket" {
+			slacktest.ReplyError(w, "invalid_arguments")
+			return
+		}
+		slacktest.Reply(w, map[string]interface{}{"view": map[string]string{"id": "V1", "hash": "H1", "callback_id": "new_ticket"}})
+	})
+	s.Handle("views.update", func(w http.ResponseWriter, c *slacktest.Call) {
+		var req struct {
+			ViewID string `json:"view_id"`
+			Hash   string `json:"hash"`
+		}
+		json.Unmarshal(c.Body, &req)
+		if req.Hash != "H1" {
+			slacktest.ReplyError(w, "hash_conflict")
+			return
+		}
+		slacktest.Reply(w, map[string]interface{}{"view": map[string]string{"id": req.ViewID, "hash": "H2"}})
+	})
+	sw, err := New("APP", "BOT", WithAPIURL(s.APIURL()))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	v, err := sw.OpenView("TRIGGER", views.NewModal("new_ticket", "New ticket"))
+	if err != nil {
+		t.Fatalf("Unexpected error opening view: %s", err)
+	}
+	if v.ID != "V1" {
+		t.Fatalf("Expected the opened view to be returned, got %+v", v)
+	}
+	if auth := s.Calls("views.open")[0].Header.Get("Authorization"); auth != "Bearer APP" {
+		t.Errorf("Expected views to be opened with the app token, got %s", auth)
+	}
+
+	if _, err := sw.UpdateView(views.NewModal("new_ticket", "New ticket"), v.ID, "stale"); err == nil || err.Error() != "hash_conflict" {
+		t.Errorf("Expected the Slack error to be returned, got %v", err)
+	}
+	v, err = sw.UpdateView(views.NewModal("new_ticket", "New ticket"), v.ID, v.Hash)
+	if err != nil {
+		t.Fatalf("Unexpected error updating view: %s", err)
+	}
+	if v.Hash != "H2" {
+		t.Errorf("Expected the updated view to be returned, got %+v", v)
+	}
+}
+
+func TestPushView(t *testing.T) {
+	s := slacktest.NewServer(slacktest.Chaos{})
+	defer s.Close()
+	s.Handle("views.push", func(w http.ResponseWriter, c *slacktest.Call) {
+		var req struct {
+			TriggerID string      `json:"trigger_id"`
+			View      *views.View `json:"view"`
+		}
+		json.Unmarshal(c.Body, &req)
+		if req.TriggerID != "TRIGGER" || req.View.CallbackID != "step_two" {
+			slacktest.ReplyError(w, "invalid_arguments")
+			return
+		}
+		slacktest.Reply(w, map[string]interface{}{"view": map[string]string{"id": "V2", "root_view_id": "V1", "previous_view_id": "V1"}})
+	})
+	sw, err := New("APP", "BOT", WithAPIURL(s.APIURL()))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	v, err := sw.PushView("TRIGGER", views.NewModal("step_two", "Step two"))
+	if err != nil || v.ID != "V2" || v.RootViewID != "V1" {
+		t.Fatalf("Expected the view to be pushed onto the stack, got %+v %v", v, err)
+	}
+	if auth := s.Calls("views.push")[0].Header.Get("Authorization"); auth != "Bearer APP" {
+		t.Errorf("Expected views to be pushed with the app token, got %s", auth)
+	}
+}
+
+func TestPublishView(t *testing.T) {
+	s := slacktest.NewServer(slacktest.Chaos{})
+	defer s.Close()
+	s.Handle("views.publish", func(w http.ResponseWriter, c *slacktest.Call) {
+		var req struct {
+			UserID string      `json:"user_id"`
+			View   *views.View `json:"view"`
+		}
+		json.Unmarshal(c.Body, &req)
+		if req.UserID != "U1" || req.View.Type != "home" {
+			slacktest.ReplyError(w, "invalid_arguments")
+			return
+		}
+		slacktest.Reply(w, map[string]interface{}{"view": map[string]string{"id": "V1", "type": "home"}})
+	})
+	sw, err := New("APP", "BOT", WithAPIURL(s.APIURL()))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	v, err := sw.PublishView("U1", views.NewHome())
+	if err != nil || v.ID != "V1" {
+		t.Fatalf("Expected the home view to be published, got %+v %v", v, err)
+	}
+	if auth := s.Calls("views.publish")[0].Header.Get("Authorization"); auth != "Bearer BOT" {
+		t.Errorf("Expected home views to be published with the bot token, got %s", auth)
+	}
+}